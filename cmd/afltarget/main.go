@@ -0,0 +1,113 @@
+// Afltarget runs the go/parser, go/types and go/format fuzz targets of
+// this repository under AFL++.
+//
+// Persistent mode goes through libFuzzer's entry point, which AFL++'s
+// driver calls in a loop after its forkserver starts. Build a C archive
+// with the compiler's libFuzzer instrumentation and link it with
+// afl-clang-fast:
+//
+//	go build -buildmode=c-archive -tags=libfuzzer -gcflags=all=-d=libfuzzer -o afltarget.a ./cmd/afltarget
+//	afl-clang-fast -fsanitize=fuzzer afltarget.a -lpthread -o afltarget
+//	FUZZ_TARGET=types afl-fuzz -i seeds -o findings -- ./afltarget
+//
+// Built as an ordinary program it reads one input from the file named by
+// its argument, or from stdin, runs the target once and exits, which suits
+// afl-fuzz -Q and -n:
+//
+//	go build -o afltarget ./cmd/afltarget
+//	afl-fuzz -Q -i seeds -o findings -- ./afltarget -target parser @@
+//
+// The target is chosen by -target or $FUZZ_TARGET (default "parser").
+// Failures crash the process with SIGABRT so AFL++ records them.
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+*/
+import "C"
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime/debug"
+	"sort"
+	"unsafe"
+
+	"github.com/geeknik/fuzzing/fuzz/format"
+	"github.com/geeknik/fuzzing/fuzz/parser"
+	"github.com/geeknik/fuzzing/fuzz/types"
+)
+
+var targets = map[string]func([]byte) error{
+	"parser": parser.Check,
+	"types":  func(src []byte) error { return types.Check(src, types.DefaultLimits) },
+	"format": format.Check,
+}
+
+var target func([]byte) error
+
+func init() {
+	// Turn panics into SIGABRT, which AFL++ counts as a crash; a
+	// plain exit status 2 would not be.
+	debug.SetTraceback("crash")
+	if err := use(os.Getenv("FUZZ_TARGET")); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func use(name string) error {
+	if name == "" {
+		name = "parser"
+	}
+	t, ok := targets[name]
+	if !ok {
+		names := make([]string, 0, len(targets))
+		for n := range targets {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("unknown target %q (have %v)", name, names)
+	}
+	target = t
+	return nil
+}
+
+func run(data []byte) {
+	if err := target(data); err != nil {
+		panic(err)
+	}
+}
+
+//export LLVMFuzzerTestOneInput
+func LLVMFuzzerTestOneInput(data *C.uint8_t, size C.size_t) C.int {
+	run(C.GoBytes(unsafe.Pointer(data), C.int(size)))
+	return 0
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("afltarget: ")
+	name := flag.String("target", os.Getenv("FUZZ_TARGET"), "fuzz target `name`: parser, types or format")
+	flag.Parse()
+	if err := use(*name); err != nil {
+		log.Fatal(err)
+	}
+	in := io.Reader(os.Stdin)
+	if flag.NArg() > 0 {
+		f, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	data, err := io.ReadAll(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	run(data)
+}
@@ -0,0 +1,277 @@
+// Corpusconv converts fuzzing corpora between the layouts used by
+// different engines.
+//
+// Usage:
+//
+//	corpusconv -from fmt -to fmt src dst
+//
+// The formats are:
+//
+//	raw     a directory of input files
+//	gofuzz  a dvyukov/go-fuzz workdir: inputs in corpus/ and crashers/,
+//	        crash reports in crashers/<name>.output and .quoted
+//	native  a testdata/fuzz/FuzzXxx directory of "go test fuzz v1" files,
+//	        each holding one []byte (or string) argument
+//
+// File names and modification times are carried over where the
+// destination allows any name. go-fuzz crash reports are copied next to
+// the inputs for raw and gofuzz destinations and into -meta for native
+// ones, since `go test` would reject extra files in its corpus directory.
+// Native entries with more than one argument have no raw form; -arg picks
+// which argument to keep.
+package main
+
+import (
+	"crypto/sha1"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus/native"
+)
+
+var (
+	from = flag.String("from", "", "source `format`: raw, gofuzz or native")
+	to   = flag.String("to", "", "destination `format`: raw, gofuzz or native")
+	meta = flag.String("meta", "", "`directory` for crash reports when converting to native")
+	arg  = flag.Int("arg", 0, "`index` of the native argument to keep")
+)
+
+// An entry is one corpus input with the metadata carried across formats.
+type entry struct {
+	name    string
+	data    []byte
+	crasher bool
+	mtime   time.Time
+	reports map[string][]byte // go-fuzz ".output" and ".quoted" files by extension
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("corpusconv: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: corpusconv -from fmt -to fmt src dst\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	src, dst := flag.Arg(0), flag.Arg(1)
+
+	var es []entry
+	var err error
+	switch *from {
+	case "raw":
+		es, err = readRaw(src, false)
+	case "gofuzz":
+		es, err = readGoFuzz(src)
+	case "native":
+		es, err = readNative(src)
+	default:
+		log.Fatalf("unknown -from format %q", *from)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	switch *to {
+	case "raw":
+		err = writeAll(dst, es, func(e entry) string { return e.name }, identity, true)
+	case "gofuzz":
+		err = writeGoFuzz(dst, es)
+	case "native":
+		err = writeNative(dst, es)
+	default:
+		log.Fatalf("unknown -to format %q", *to)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("converted %d entries from %s to %s\n", len(es), *from, *to)
+}
+
+func identity(b []byte) []byte { return b }
+
+// readRaw reads every regular file in dir. With reports set, go-fuzz
+// .output and .quoted files are attached to the input they describe
+// instead of being read as inputs.
+func readRaw(dir string, reports bool) ([]entry, error) {
+	ds, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var es []entry
+	index := map[string]int{}
+	var pending []string
+	for _, d := range ds {
+		if !d.Type().IsRegular() {
+			continue
+		}
+		ext := filepath.Ext(d.Name())
+		if reports && (ext == ".output" || ext == ".quoted") {
+			pending = append(pending, d.Name())
+			continue
+		}
+		e, err := readEntry(filepath.Join(dir, d.Name()))
+		if err != nil {
+			return nil, err
+		}
+		index[e.name] = len(es)
+		es = append(es, e)
+	}
+	for _, name := range pending {
+		ext := filepath.Ext(name)
+		i, ok := index[strings.TrimSuffix(name, ext)]
+		if !ok {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if es[i].reports == nil {
+			es[i].reports = map[string][]byte{}
+		}
+		es[i].reports[ext] = data
+	}
+	return es, nil
+}
+
+func readEntry(name string) (entry, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return entry{}, err
+	}
+	fi, err := os.Stat(name)
+	if err != nil {
+		return entry{}, err
+	}
+	return entry{name: filepath.Base(name), data: data, mtime: fi.ModTime()}, nil
+}
+
+// readGoFuzz reads a go-fuzz workdir, or a bare go-fuzz corpus directory.
+func readGoFuzz(dir string) ([]entry, error) {
+	if _, err := os.Stat(filepath.Join(dir, "corpus")); err != nil {
+		return readRaw(dir, true)
+	}
+	es, err := readRaw(filepath.Join(dir, "corpus"), false)
+	if err != nil {
+		return nil, err
+	}
+	crashers, err := readRaw(filepath.Join(dir, "crashers"), true)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, c := range crashers {
+		c.crasher = true
+		es = append(es, c)
+	}
+	return es, nil
+}
+
+func readNative(dir string) ([]entry, error) {
+	raw, err := readRaw(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	var es []entry
+	for _, e := range raw {
+		args, err := native.Unmarshal(e.data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", e.name, err)
+		}
+		if *arg >= len(args) {
+			return nil, fmt.Errorf("%s: has %d arguments, -arg is %d", e.name, len(args), *arg)
+		}
+		// Only []byte and string arguments have a raw form.
+		switch a := args[*arg].(type) {
+		case []byte:
+			e.data = a
+		case string:
+			e.data = []byte(a)
+		default:
+			return nil, fmt.Errorf("%s: argument %d is a %T, not []byte or string", e.name, *arg, a)
+		}
+		es = append(es, e)
+	}
+	return es, nil
+}
+
+func writeGoFuzz(dir string, es []entry) error {
+	var corpus, crashers []entry
+	for _, e := range es {
+		// go-fuzz names inputs by SHA-1 and finds crash reports by
+		// that name.
+		e.name = fmt.Sprintf("%x", sha1.Sum(e.data))
+		if e.crasher {
+			crashers = append(crashers, e)
+		} else {
+			corpus = append(corpus, e)
+		}
+	}
+	name := func(e entry) string { return e.name }
+	if err := writeAll(filepath.Join(dir, "corpus"), corpus, name, identity, false); err != nil {
+		return err
+	}
+	return writeAll(filepath.Join(dir, "crashers"), crashers, name, identity, true)
+}
+
+func writeNative(dir string, es []entry) error {
+	name := func(e entry) string {
+		if e.crasher || strings.ContainsAny(e.name, " \t") {
+			return native.Name(native.Bytes(e.data))
+		}
+		return e.name
+	}
+	if err := writeAll(dir, es, name, native.Bytes, false); err != nil {
+		return err
+	}
+	if *meta == "" {
+		return nil
+	}
+	var reported []entry
+	for _, e := range es {
+		if len(e.reports) > 0 {
+			e.name = name(e)
+			reported = append(reported, e)
+		}
+	}
+	return writeAll(*meta, reported, func(e entry) string { return e.name }, nil, true)
+}
+
+// writeAll writes each entry under dir as name(e) with contents
+// encode(e.data); a nil encode writes only the crash reports. With reports
+// set, go-fuzz crash reports are written next to their input.
+func writeAll(dir string, es []entry, name func(entry) string, encode func([]byte) []byte, reports bool) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, e := range es {
+		base := filepath.Join(dir, name(e))
+		if encode != nil {
+			if err := os.WriteFile(base, encode(e.data), 0o644); err != nil {
+				return err
+			}
+			if !e.mtime.IsZero() {
+				if err := os.Chtimes(base, e.mtime, e.mtime); err != nil {
+					return err
+				}
+			}
+		}
+		if !reports {
+			continue
+		}
+		for ext, data := range e.reports {
+			if err := os.WriteFile(base+ext, data, 0o644); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Dedup collapses structurally identical seeds in a corpus.
+//
+// Usage:
+//
+//	dedup [-delete | -o dir] [-v] path ...
+//
+// Seeds are grouped by corpus.ShapeOf, which ignores identifier spelling
+// and literal values in Go files. The first seed of each group in path
+// order is kept. Without -delete or -o, dedup only reports what it would
+// do.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/geeknik/fuzzing/corpus"
+)
+
+var (
+	del     = flag.Bool("delete", false, "remove duplicate seeds in place")
+	outDir  = flag.String("o", "", "copy one seed per shape into `dir` instead")
+	verbose = flag.Bool("v", false, "list every duplicate and the seed it duplicates")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("dedup: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dedup [flags] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *del && *outDir != "" {
+		log.Fatal("-delete and -o are mutually exclusive")
+	}
+
+	first := map[corpus.Shape]string{}
+	var total, dups int
+	for _, root := range flag.Args() {
+		seeds, err := corpus.Read(root)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range seeds {
+			total++
+			sh := corpus.ShapeOf(s)
+			if orig, ok := first[sh]; ok {
+				dups++
+				if *verbose {
+					fmt.Printf("%s duplicates %s\n", s.Path, orig)
+				}
+				if *del {
+					if err := os.RemoveAll(s.Path); err != nil {
+						log.Fatal(err)
+					}
+				}
+				continue
+			}
+			first[sh] = s.Path
+			if *outDir != "" {
+				if err := copySeed(root, s); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	fmt.Printf("%d seeds, %d shapes, %d duplicates\n", total, len(first), dups)
+}
+
+// copySeed writes s under -o at its path relative to root.
+func copySeed(root string, s corpus.Seed) error {
+	rel, err := filepath.Rel(root, s.Path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(s.Path)
+	}
+	dst := filepath.Join(*outDir, rel)
+	if len(s.Files) == 1 && filepath.Base(s.Path) == s.Files[0].Name {
+		dst = filepath.Dir(dst)
+	}
+	return s.Write(dst)
+}
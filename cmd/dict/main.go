@@ -0,0 +1,270 @@
+// Dict writes a fuzzing dictionary of Go source for byte-level fuzzers
+// such as AFL++ and libFuzzer.
+//
+// Usage:
+//
+//	dict [-afl file] [-libfuzzer file] [-max n] [path ...]
+//
+// The dictionary holds the Go keywords, operators and predeclared
+// identifiers, the comment prefixes that make directives (//go:build,
+// //go:linkname, //line and the rest), and the literals and directives
+// found most often in the Go files of the corpus paths, as read by
+// package corpus, up to -max of them. Literals are kept as they are
+// written in the source, quotes and all, since that is what a fuzzer
+// splices into its inputs.
+//
+// -afl writes the dictionary in AFL's syntax, one name="value" per line,
+// and -libfuzzer in libFuzzer's, one "value" per line; with neither, the
+// libFuzzer form goes to standard output. Both take the file as
+//
+//	afl-fuzz -x go.dict ...
+//	./fuzzer -dict=go.dict ...
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/geeknik/fuzzing/corpus"
+)
+
+var (
+	aflOut = flag.String("afl", "", "write the dictionary in AFL syntax to `file`")
+	lfOut  = flag.String("libfuzzer", "", "write the dictionary in libFuzzer syntax to `file`")
+	limit  = flag.Int("max", 200, "`number` of literals and directives to harvest from the corpus")
+)
+
+// maxLen is the longest entry kept. libFuzzer ignores longer ones, and
+// AFL's limit is higher.
+const maxLen = 64
+
+// An entry is one dictionary token and the name AFL shows for it.
+type entry struct {
+	name, value string
+}
+
+// directives are the comment prefixes the go command, the compiler and
+// cgo give meaning to.
+var directives = []string{
+	"//go:build ", "// +build ", "//go:generate ", "//go:embed ", "//go:linkname ",
+	"//go:noinline", "//go:nosplit", "//go:noescape", "//go:norace", "//go:nocheckptr",
+	"//go:uintptrkeepalive", "//go:uintptrescapes", "//go:systemstack", "//go:nowritebarrier",
+	"//go:wasmimport ", "//go:wasmexport ", "//go:debug ", "//go:fix ",
+	"//go:cgo_import_dynamic ", "//go:cgo_unsafe_args", "//export ", "//line ", "/*line ",
+	"// Code generated ", " DO NOT EDIT.", "#cgo ", "import \"C\"",
+}
+
+// predeclared are the identifiers of the universe scope and of package
+// unsafe.
+var predeclared = []string{
+	"any", "bool", "byte", "comparable", "complex64", "complex128", "error", "float32", "float64",
+	"int", "int8", "int16", "int32", "int64", "rune", "string",
+	"uint", "uint8", "uint16", "uint32", "uint64", "uintptr",
+	"true", "false", "iota", "nil",
+	"append", "cap", "clear", "close", "complex", "copy", "delete", "imag", "len", "make",
+	"max", "min", "new", "panic", "print", "println", "real", "recover",
+	"unsafe.Pointer", "unsafe.Sizeof", "unsafe.Offsetof", "unsafe.Alignof",
+	"unsafe.Add", "unsafe.Slice", "unsafe.SliceData", "unsafe.String", "unsafe.StringData",
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("dict: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: dict [flags] [path ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	entries := builtin()
+	if flag.NArg() > 0 {
+		h := &harvest{count: map[string]int{}, kinds: map[string]string{}}
+		for _, p := range flag.Args() {
+			seeds, err := corpus.Read(p)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, s := range seeds {
+				for _, f := range s.Files {
+					if path.Ext(f.Name) == ".go" {
+						h.scan(f.Data)
+					}
+				}
+			}
+		}
+		entries = append(entries, h.top(*limit)...)
+	}
+	entries = dedup(entries)
+
+	if *aflOut == "" && *lfOut == "" {
+		if err := write(os.Stdout, entries, false); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for _, out := range []struct {
+		file string
+		afl  bool
+	}{{*aflOut, true}, {*lfOut, false}} {
+		if out.file == "" {
+			continue
+		}
+		f, err := os.Create(out.file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := write(f, entries, out.afl); err != nil {
+			log.Fatal(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// builtin returns the entries every Go dictionary holds.
+func builtin() []entry {
+	var es []entry
+	for t := token.BREAK; t <= token.VAR; t++ {
+		if t.IsKeyword() {
+			es = append(es, entry{"kw_" + t.String(), t.String()})
+		}
+	}
+	n := 0
+	for t := token.ADD; t <= token.TILDE; t++ {
+		if t.IsOperator() {
+			n++
+			es = append(es, entry{fmt.Sprintf("op_%d", n), t.String()})
+		}
+	}
+	for _, id := range predeclared {
+		es = append(es, entry{"id_" + strings.ReplaceAll(id, ".", "_"), id})
+	}
+	for i, d := range directives {
+		es = append(es, entry{fmt.Sprintf("directive_%d", i+1), d})
+	}
+	return es
+}
+
+// A harvest counts the literals and directives of a corpus.
+type harvest struct {
+	count map[string]int
+	kinds map[string]string // "lit" or "directive"
+}
+
+// directiveRE matches the directive prefix of a comment.
+var directiveRE = regexp.MustCompile(`^//(go:[a-z_]+|line |export |extern )`)
+
+func (h *harvest) scan(src []byte) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, scanner.ScanComments)
+	for {
+		_, t, lit := s.Scan()
+		switch t {
+		case token.EOF:
+			return
+		case token.INT, token.FLOAT, token.IMAG, token.CHAR, token.STRING:
+			h.add(lit, "lit")
+		case token.COMMENT:
+			if m := directiveRE.FindString(lit); m != "" {
+				h.add(m, "directive")
+			}
+		}
+	}
+}
+
+// add counts v. Single characters, digits mostly, are left to the
+// fuzzer's own byte mutations.
+func (h *harvest) add(v, kind string) {
+	if len(v) < 2 || len(v) > maxLen {
+		return
+	}
+	h.count[v]++
+	h.kinds[v] = kind
+}
+
+// top returns the n values seen most often, shorter ones first among
+// equals.
+func (h *harvest) top(n int) []entry {
+	var vs []string
+	for v := range h.count {
+		vs = append(vs, v)
+	}
+	sort.Slice(vs, func(i, j int) bool {
+		a, b := vs[i], vs[j]
+		if h.count[a] != h.count[b] {
+			return h.count[a] > h.count[b]
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return a < b
+	})
+	if len(vs) > n {
+		vs = vs[:n]
+	}
+	var es []entry
+	for i, v := range vs {
+		es = append(es, entry{fmt.Sprintf("corpus_%s_%d", h.kinds[v], i+1), v})
+	}
+	return es
+}
+
+// dedup drops entries whose value an earlier one has.
+func dedup(es []entry) []entry {
+	seen := map[string]bool{}
+	var out []entry
+	for _, e := range es {
+		if !seen[e.value] {
+			seen[e.value] = true
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// write writes es one per line, as name="value" for AFL and "value" for
+// libFuzzer.
+func write(w io.Writer, es []entry, afl bool) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "# Go source dictionary, %d entries. Written by cmd/dict.\n", len(es))
+	for _, e := range es {
+		if afl {
+			b.WriteString(e.name + "=")
+		}
+		b.WriteString(quote(e.value) + "\n")
+	}
+	return b.Flush()
+}
+
+// quote quotes v for a dictionary: printable ASCII stays as it is but for
+// backslash and double quote, and every other byte is escaped as \xNN.
+func quote(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(v); i++ {
+		switch c := v[i]; {
+		case c == '\\' || c == '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, "\\x%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A compiler is a Go front end that can be asked to build a package
+// directory.
+type compiler struct {
+	name string
+	tool string // executable that must be on $PATH
+	args func(out string) []string
+}
+
+var compilers = []compiler{
+	{
+		name: "gc",
+		tool: "go",
+		args: func(out string) []string { return []string{"build", "-o", out, "."} },
+	},
+	{
+		name: "gccgo",
+		tool: "go",
+		args: func(out string) []string { return []string{"build", "-compiler=gccgo", "-o", out, "."} },
+	},
+	{
+		name: "tinygo",
+		tool: "tinygo",
+		args: func(out string) []string { return []string{"build", "-o", out, "."} },
+	},
+}
+
+// available reports whether c can run here. gccgo is driven through the
+// go command but needs the gccgo binary itself.
+func (c compiler) available() bool {
+	tool := c.tool
+	if c.name == "gccgo" {
+		tool = "gccgo"
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// A result is the outcome of compiling one seed with one compiler.
+type result struct {
+	compiler string
+	accepted bool
+	timedOut bool
+	output   string
+	diags    []string // normalized diagnostics
+}
+
+func (c compiler) compile(ctx context.Context, dir string, timeout time.Duration) result {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, c.tool, c.args(filepath.Join(dir, ".out"))...)
+	cmd.Dir = dir
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	r := result{compiler: c.name, accepted: err == nil, output: out.String()}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		r.timedOut = true
+	}
+	r.diags = normalize(r.output, dir)
+	return r
+}
+
+// diagRE matches "file.go:line[:col]: message" diagnostics as printed by
+// all three front ends.
+var diagRE = regexp.MustCompile(`^(?:\./)?([^\s:]+\.go):(\d+)(?::\d+)?: (.*)$`)
+
+// normalize reduces compiler output to "file:line" keys so that
+// diagnostics can be compared across front ends whose wording and column
+// conventions differ.
+func normalize(output, dir string) []string {
+	seen := map[string]bool{}
+	for _, l := range strings.Split(output, "\n") {
+		l = strings.TrimSpace(strings.ReplaceAll(l, dir+string(filepath.Separator), ""))
+		m := diagRE.FindStringSubmatch(l)
+		if m == nil {
+			continue
+		}
+		seen[filepath.Base(m[1])+":"+m[2]] = true
+	}
+	diags := make([]string, 0, len(seen))
+	for d := range seen {
+		diags = append(diags, d)
+	}
+	sort.Strings(diags)
+	return diags
+}
+
+// diverges reports whether the results disagree on acceptance or, with
+// strict set, on the lines they reported errors for.
+func diverges(rs []result, strict bool) bool {
+	for _, r := range rs[1:] {
+		if r.timedOut || rs[0].timedOut {
+			continue
+		}
+		if r.accepted != rs[0].accepted {
+			return true
+		}
+		if strict && !r.accepted && strings.Join(r.diags, " ") != strings.Join(rs[0].diags, " ") {
+			return true
+		}
+	}
+	return false
+}
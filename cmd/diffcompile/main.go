@@ -0,0 +1,218 @@
+// Diffcompile builds each seed of a corpus with every Go front end found
+// on the machine (gc, gccgo, tinygo) and reports seeds that some accept
+// and others reject.
+//
+// Usage:
+//
+//	diffcompile [-strict] [-timeout d] [-lang version] [-compilers gc,gccgo] [-json file] [-sarif file] path ...
+//
+// Each path is a corpus directory or seed file as read by package corpus.
+// A seed without a go.mod is built as module "seed" at the language
+// version given by -lang. With -strict, seeds that every front end
+// rejects but at different lines are reported too.
+//
+// With -json and -sarif, the divergent seeds are also written to files
+// as findings (package internal/findings), each pointing at the first
+// line a front end rejected and naming the generator and seed of the
+// seed's seedgen header.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/gen"
+	"github.com/geeknik/fuzzing/internal/findings"
+)
+
+var (
+	strict  = flag.Bool("strict", false, "also report rejects whose diagnostic lines differ")
+	timeout = flag.Duration("timeout", time.Minute, "per-compile time `limit`")
+	only    = flag.String("compilers", "", "comma-separated `list` of compilers to use (default: all available)")
+	lang    = flag.String("lang", "1.24", "go `version` for seeds without a go.mod")
+	verbose = flag.Bool("v", false, "print every seed, not only divergences")
+	jsonOut = flag.String("json", "", "write divergences as JSON findings to `file`")
+	sarif   = flag.String("sarif", "", "write divergences as SARIF to `file`")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("diffcompile: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: diffcompile [flags] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var use []compiler
+	for _, c := range compilers {
+		if *only != "" && !slices.Contains(strings.Split(*only, ","), c.name) {
+			continue
+		}
+		if !c.available() {
+			log.Printf("%s not installed; skipping", c.name)
+			continue
+		}
+		use = append(use, c)
+	}
+	if len(use) < 2 {
+		log.Fatalf("need at least two compilers, have %d", len(use))
+	}
+
+	var seeds []corpus.Seed
+	for _, p := range flag.Args() {
+		s, err := corpus.Read(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		seeds = append(seeds, s...)
+	}
+
+	ctx := context.Background()
+	var checked, divergent int
+	var fs []findings.Finding
+	for _, s := range seeds {
+		if !isGo(s) {
+			continue
+		}
+		checked++
+		rs, err := run(ctx, s, use)
+		if err != nil {
+			log.Fatal(err)
+		}
+		d := diverges(rs, *strict)
+		if d {
+			divergent++
+			fs = append(fs, finding(s, rs))
+		}
+		if d || *verbose {
+			report(s, rs, d)
+		}
+	}
+	fmt.Printf("%d of %d seeds diverge\n", divergent, checked)
+	if err := findings.Write(*jsonOut, *sarif, "diffcompile", fs); err != nil {
+		log.Fatal(err)
+	}
+	if divergent > 0 {
+		os.Exit(1)
+	}
+}
+
+// run materializes s in a temporary module and compiles it with each of
+// cs.
+func run(ctx context.Context, s corpus.Seed, cs []compiler) ([]result, error) {
+	dir, err := os.MkdirTemp("", "diffcompile")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if !s.Has("go.mod") {
+		s.Files = append(s.Files, gen.File{Name: "go.mod", Data: []byte("module seed\n\ngo " + *lang + "\n")})
+	}
+	if err := s.Write(dir); err != nil {
+		return nil, err
+	}
+	var rs []result
+	for _, c := range cs {
+		rs = append(rs, c.compile(ctx, dir, *timeout))
+	}
+	return rs, nil
+}
+
+func report(s corpus.Seed, rs []result, divergent bool) {
+	mark := "  "
+	if divergent {
+		mark = "! "
+	}
+	fmt.Printf("%s%s\t%s\n", mark, s.Path, strings.Join(verdicts(rs), " "))
+	if !divergent {
+		return
+	}
+	for _, r := range rs {
+		if r.accepted {
+			continue
+		}
+		fmt.Printf("    %s: %s\n", r.compiler, strings.Join(r.diags, " "))
+		for _, l := range firstLines(r.output, 5) {
+			fmt.Printf("      %s\n", l)
+		}
+	}
+}
+
+// verdicts returns the column of report for each result.
+func verdicts(rs []result) []string {
+	var cols []string
+	for _, r := range rs {
+		v := "reject"
+		switch {
+		case r.timedOut:
+			v = "timeout"
+		case r.accepted:
+			v = "ok"
+		}
+		cols = append(cols, r.compiler+"="+v)
+	}
+	return cols
+}
+
+// finding describes the divergence of s for package findings. It points
+// at the first diagnostic of the first front end that rejected s.
+func finding(s corpus.Seed, rs []result) findings.Finding {
+	f := findings.Finding{Kind: "divergence", Message: strings.Join(verdicts(rs), " ")}
+	var srcs [][]byte
+	for _, sf := range s.Files {
+		if path.Ext(sf.Name) == ".go" {
+			if f.Input == "" {
+				f.Input = s.FilePath(sf.Name)
+			}
+			srcs = append(srcs, sf.Data)
+		}
+	}
+	f.Origin = findings.OriginOf(srcs...)
+	for _, r := range rs {
+		if r.accepted || r.timedOut || len(r.diags) == 0 {
+			continue
+		}
+		file, line, _ := strings.Cut(r.diags[0], ":")
+		for _, sf := range s.Files {
+			if path.Base(sf.Name) == file {
+				f.Input = s.FilePath(sf.Name)
+				f.Line, _ = strconv.Atoi(line)
+				break
+			}
+		}
+		f.Output = r.compiler + ": " + strings.Join(firstLines(r.output, 5), "\n")
+		break
+	}
+	return f
+}
+
+func isGo(s corpus.Seed) bool {
+	for _, f := range s.Files {
+		if path.Ext(f.Name) == ".go" {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLines(s string, n int) []string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines
+}
@@ -0,0 +1,77 @@
+// Gomutator exports the structure-aware Go source mutator of package
+// mutate as a libFuzzer custom mutator, for C and C++ fuzz targets whose
+// input is Go source (gollvm, gopherjs and other front ends).
+//
+// Build it as a C archive and link it into the fuzz target:
+//
+//	go build -buildmode=c-archive -o libgomutator.a ./cmd/gomutator
+//	clang++ -fsanitize=fuzzer target.cc libgomutator.a -lpthread -o target
+//
+// libFuzzer then calls LLVMFuzzerCustomMutator and
+// LLVMFuzzerCustomCrossOver from the archive. One mutation in four, and
+// every mutation of an input that does not parse as Go, works on tokens
+// (mutate.MutateTokens) rather than the AST, so that the corpus also
+// holds the almost-valid programs that reach the parser's error paths.
+// Inputs without a Go token are handed to libFuzzer's own
+// LLVMFuzzerMutate.
+package main
+
+/*
+#include <stddef.h>
+#include <stdint.h>
+
+// Provided by libFuzzer. The declaration is weak so that the archive also
+// links into programs without libFuzzer, where mutation falls back to
+// returning the input unchanged.
+__attribute__((weak)) size_t LLVMFuzzerMutate(uint8_t *data, size_t size, size_t max_size);
+
+static size_t fallback_mutate(uint8_t *data, size_t size, size_t max_size) {
+	if (LLVMFuzzerMutate == NULL) {
+		return size;
+	}
+	return LLVMFuzzerMutate(data, size, max_size);
+}
+*/
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/geeknik/fuzzing/mutate"
+)
+
+//export LLVMFuzzerCustomMutator
+func LLVMFuzzerCustomMutator(data *C.uint8_t, size, maxSize C.size_t, seed C.uint) C.size_t {
+	buf := bytes(data, maxSize)
+	var out []byte
+	ok := false
+	if seed%4 != 0 {
+		out, ok = mutate.Mutate(buf[:size], uint64(seed), int(maxSize))
+	}
+	if !ok {
+		out, ok = mutate.MutateTokens(buf[:size], uint64(seed), int(maxSize))
+	}
+	if !ok {
+		return C.fallback_mutate(data, size, maxSize)
+	}
+	return C.size_t(copy(buf, out))
+}
+
+//export LLVMFuzzerCustomCrossOver
+func LLVMFuzzerCustomCrossOver(data1 *C.uint8_t, size1 C.size_t, data2 *C.uint8_t, size2 C.size_t, out *C.uint8_t, maxOutSize C.size_t, seed C.uint) C.size_t {
+	res, ok := mutate.CrossOver(bytes(data1, size1), bytes(data2, size2), uint64(seed), int(maxOutSize))
+	if !ok {
+		return 0
+	}
+	return C.size_t(copy(bytes(out, maxOutSize), res))
+}
+
+// bytes views n bytes of C memory at p as a Go slice.
+func bytes(p *C.uint8_t, n C.size_t) []byte {
+	if p == nil || n == 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(unsafe.Pointer(p)), int(n))
+}
+
+func main() {}
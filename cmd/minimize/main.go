@@ -0,0 +1,119 @@
+// Minimize shrinks a Go seed while a predicate command keeps reporting it
+// as interesting.
+//
+// Usage:
+//
+//	minimize [-o out] [-grep regexp] [-timeout d] seed.go command [arg ...]
+//
+// For every candidate, minimize writes the source to a file with the same
+// base name in a scratch directory and runs command with any "{}" argument
+// replaced by that file's path; the path is also in $SEED and the command
+// runs in the scratch directory. A candidate is interesting if the command
+// exits with status 0, or, with -grep, if its combined output matches the
+// regular expression whatever the exit status. For example, to keep a
+// compiler crash:
+//
+//	minimize -grep 'internal compiler error' crash.go go build {}
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/minimize"
+)
+
+var (
+	out     = flag.String("o", "", "write the result to `file` (default: seed.min.go next to the seed)")
+	grep    = flag.String("grep", "", "interesting if the command output matches `regexp`")
+	timeout = flag.Duration("timeout", 30*time.Second, "per-run time `limit`; a timed-out run is not interesting")
+	quiet   = flag.Bool("q", false, "do not log progress")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("minimize: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: minimize [flags] seed.go command [arg ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	seed, argv := flag.Arg(0), flag.Args()[1:]
+	src, err := os.ReadFile(seed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var re *regexp.Regexp
+	if *grep != "" {
+		if re, err = regexp.Compile(*grep); err != nil {
+			log.Fatal(err)
+		}
+	}
+	dir, err := os.MkdirTemp("", "minimize")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := &predicate{dir: dir, name: filepath.Join(dir, filepath.Base(seed)), argv: argv, re: re}
+	if !p.interesting(src) {
+		log.Fatalf("%s is not interesting to start with", seed)
+	}
+	r := &minimize.Reducer{Interesting: p.interesting}
+	if !*quiet {
+		r.Logf = log.Printf
+	}
+	reduced := r.Reduce(src)
+
+	dst := *out
+	if dst == "" {
+		ext := filepath.Ext(seed)
+		dst = seed[:len(seed)-len(ext)] + ".min" + ext
+	}
+	if err := os.WriteFile(dst, reduced, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("%d -> %d bytes in %d runs; wrote %s", len(src), len(reduced), r.Tests(), dst)
+}
+
+type predicate struct {
+	dir, name string
+	argv      []string
+	re        *regexp.Regexp
+}
+
+func (p *predicate) interesting(src []byte) bool {
+	if err := os.WriteFile(p.name, src, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	args := make([]string, len(p.argv))
+	for i, a := range p.argv {
+		args[i] = strings.ReplaceAll(a, "{}", p.name)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = p.dir
+	cmd.Env = append(os.Environ(), "SEED="+p.name)
+	output, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	if p.re != nil {
+		return p.re.Match(output)
+	}
+	return err == nil
+}
@@ -0,0 +1,323 @@
+// Ossfuzz writes OSS-Fuzz project files for the fuzz harnesses in this
+// repository.
+//
+// Usage:
+//
+//	ossfuzz [-o dir] [-repo url] [root]
+//	ossfuzz -corpus dir [-n count] [root]
+//
+// The first form scans the module at root (default ".") for native
+// FuzzXxx(*testing.F) harnesses and writes project.yaml, Dockerfile and a
+// build.sh that builds each one with compile_native_go_fuzzer. The
+// generated build.sh runs the second form, which writes a
+// <fuzzer>_seed_corpus.zip next to every fuzzer, filled from the same
+// generators the harness seeds itself from with gen.Sample.
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/asn1src"
+	_ "github.com/geeknik/fuzzing/gen/bencodesrc"
+	_ "github.com/geeknik/fuzzing/gen/bigsrc"
+	_ "github.com/geeknik/fuzzing/gen/cborsrc"
+	_ "github.com/geeknik/fuzzing/gen/compresssrc"
+	_ "github.com/geeknik/fuzzing/gen/csrc"
+	_ "github.com/geeknik/fuzzing/gen/csvsrc"
+	_ "github.com/geeknik/fuzzing/gen/debugsrc"
+	_ "github.com/geeknik/fuzzing/gen/dnssrc"
+	_ "github.com/geeknik/fuzzing/gen/fontsrc"
+	_ "github.com/geeknik/fuzzing/gen/gitsrc"
+	_ "github.com/geeknik/fuzzing/gen/gobsrc"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+	_ "github.com/geeknik/fuzzing/gen/htmlsrc"
+	_ "github.com/geeknik/fuzzing/gen/http2src"
+	_ "github.com/geeknik/fuzzing/gen/httpsrc"
+	_ "github.com/geeknik/fuzzing/gen/imagesrc"
+	_ "github.com/geeknik/fuzzing/gen/ipsrc"
+	_ "github.com/geeknik/fuzzing/gen/json5src"
+	_ "github.com/geeknik/fuzzing/gen/jsonsrc"
+	_ "github.com/geeknik/fuzzing/gen/jssrc"
+	_ "github.com/geeknik/fuzzing/gen/jwtsrc"
+	_ "github.com/geeknik/fuzzing/gen/mailsrc"
+	_ "github.com/geeknik/fuzzing/gen/mdsrc"
+	_ "github.com/geeknik/fuzzing/gen/modsrc"
+	_ "github.com/geeknik/fuzzing/gen/multipartsrc"
+	_ "github.com/geeknik/fuzzing/gen/pathsrc"
+	_ "github.com/geeknik/fuzzing/gen/pdfsrc"
+	_ "github.com/geeknik/fuzzing/gen/pemsrc"
+	_ "github.com/geeknik/fuzzing/gen/protosrc"
+	_ "github.com/geeknik/fuzzing/gen/pysrc"
+	_ "github.com/geeknik/fuzzing/gen/quicsrc"
+	_ "github.com/geeknik/fuzzing/gen/regexpsrc"
+	_ "github.com/geeknik/fuzzing/gen/rustsrc"
+	_ "github.com/geeknik/fuzzing/gen/shsrc"
+	_ "github.com/geeknik/fuzzing/gen/sqlsrc"
+	_ "github.com/geeknik/fuzzing/gen/sshsrc"
+	_ "github.com/geeknik/fuzzing/gen/strconvsrc"
+	_ "github.com/geeknik/fuzzing/gen/tarsrc"
+	_ "github.com/geeknik/fuzzing/gen/timesrc"
+	_ "github.com/geeknik/fuzzing/gen/tlssrc"
+	_ "github.com/geeknik/fuzzing/gen/tmplsrc"
+	_ "github.com/geeknik/fuzzing/gen/tomlsrc"
+	_ "github.com/geeknik/fuzzing/gen/urlsrc"
+	_ "github.com/geeknik/fuzzing/gen/wasmsrc"
+	_ "github.com/geeknik/fuzzing/gen/wssrc"
+	_ "github.com/geeknik/fuzzing/gen/xmlsrc"
+	_ "github.com/geeknik/fuzzing/gen/yamlsrc"
+	_ "github.com/geeknik/fuzzing/gen/zipsrc"
+)
+
+var (
+	outDir    = flag.String("o", "oss-fuzz", "output `directory` for the project files")
+	repo      = flag.String("repo", "https://github.com/geeknik/fuzzing", "git `url` the Dockerfile clones")
+	corpusDir = flag.String("corpus", "", "write seed corpus zips to `dir` instead of project files")
+	count     = flag.Int("n", 100, "seeds to generate per generator for the corpus zips")
+)
+
+// A harness is one native fuzz target.
+type harness struct {
+	Pkg     string // import path
+	Func    string // FuzzXxx
+	Name    string // fuzzer binary name
+	Pattern string // gen.Sample generator pattern, "" if none
+	Ext     string // gen.Sample file extension
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ossfuzz: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: ossfuzz [flags] [root]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	root := "."
+	switch flag.NArg() {
+	case 0:
+	case 1:
+		root = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	hs, err := scan(root)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(hs) == 0 {
+		log.Fatalf("no fuzz harnesses under %s", root)
+	}
+	if *corpusDir != "" {
+		if err := writeCorpora(*corpusDir, hs); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if err := writeProject(*outDir, hs); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("wrote %d fuzzers to %s\n", len(hs), *outDir)
+}
+
+// scan finds the fuzz harnesses in the module rooted at root.
+func scan(root string) ([]harness, error) {
+	mod, err := modulePath(filepath.Join(root, "go.mod"))
+	if err != nil {
+		return nil, err
+	}
+	var hs []harness
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "testdata") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		fhs, err := scanFile(p, path.Join(mod, filepath.ToSlash(rel)))
+		hs = append(hs, fhs...)
+		return err
+	})
+	return hs, err
+}
+
+func modulePath(gomod string) (string, error) {
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return "", err
+	}
+	for _, l := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(l), "module "); ok {
+			return strings.Trim(strings.TrimSpace(rest), `"`), nil
+		}
+	}
+	return "", fmt.Errorf("%s: no module line", gomod)
+}
+
+// scanFile returns the harnesses declared in one test file. The seed
+// pattern is taken from a gen.Sample call in the harness body.
+func scanFile(name, pkg string) ([]harness, error) {
+	f, err := parser.ParseFile(token.NewFileSet(), name, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	var hs []harness
+	for _, d := range f.Decls {
+		fn, ok := d.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil || !strings.HasPrefix(fn.Name.Name, "Fuzz") || !takesF(fn) {
+			continue
+		}
+		h := harness{
+			Pkg:  pkg,
+			Func: fn.Name.Name,
+			Name: path.Base(pkg) + "_" + strings.ToLower(strings.TrimPrefix(fn.Name.Name, "Fuzz")),
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 3 || !isSel(call.Fun, "gen", "Sample") {
+				return true
+			}
+			h.Pattern, _ = stringLit(call.Args[0])
+			h.Ext, _ = stringLit(call.Args[1])
+			return false
+		})
+		hs = append(hs, h)
+	}
+	return hs, nil
+}
+
+func takesF(fn *ast.FuncDecl) bool {
+	ps := fn.Type.Params.List
+	if len(ps) != 1 {
+		return false
+	}
+	star, ok := ps[0].Type.(*ast.StarExpr)
+	return ok && isSel(star.X, "testing", "F")
+}
+
+func isSel(e ast.Expr, pkg, name string) bool {
+	sel, ok := e.(*ast.SelectorExpr)
+	if !ok {
+		return false
+	}
+	id, ok := sel.X.(*ast.Ident)
+	return ok && id.Name == pkg && sel.Sel.Name == name
+}
+
+func stringLit(e ast.Expr) (string, bool) {
+	lit, ok := e.(*ast.BasicLit)
+	if !ok || lit.Kind != token.STRING {
+		return "", false
+	}
+	s, err := strconv.Unquote(lit.Value)
+	return s, err == nil
+}
+
+// writeCorpora writes a seed corpus zip for every harness with a seed
+// pattern.
+func writeCorpora(dir string, hs []harness) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, h := range hs {
+		if h.Pattern == "" {
+			continue
+		}
+		var b bytes.Buffer
+		z := zip.NewWriter(&b)
+		for i, src := range gen.Sample(h.Pattern, h.Ext, *count) {
+			w, err := z.Create(fmt.Sprintf("%06d%s", i, h.Ext))
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(src); err != nil {
+				return err
+			}
+		}
+		if err := z.Close(); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, h.Name+"_seed_corpus.zip"), b.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeProject(dir string, hs []harness) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	data := struct {
+		Repo      string
+		Harnesses []harness
+	}{*repo, hs}
+	for _, t := range project.Templates() {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			return err
+		}
+		mode := os.FileMode(0o644)
+		if strings.HasSuffix(t.Name(), ".sh") {
+			mode = 0o755
+		}
+		if err := os.WriteFile(filepath.Join(dir, t.Name()), b.Bytes(), mode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var project = template.Must(template.New("project.yaml").Parse(`homepage: "{{.Repo}}"
+language: go
+main_repo: "{{.Repo}}"
+fuzzing_engines:
+  - libfuzzer
+sanitizers:
+  - address
+`))
+
+func init() {
+	template.Must(project.New("Dockerfile").Parse(`FROM gcr.io/oss-fuzz-base/base-builder-go
+RUN git clone --depth 1 {{.Repo}} fuzzing
+WORKDIR $SRC/fuzzing
+COPY build.sh $SRC/
+`))
+	template.Must(project.New("build.sh").Parse(`#!/bin/bash -eu
+# Generated by cmd/ossfuzz.
+
+cd $SRC/fuzzing
+go get github.com/AdamKorcz/go-118-fuzz-build/testing
+
+{{range .Harnesses -}}
+compile_native_go_fuzzer {{.Pkg}} {{.Func}} {{.Name}}
+{{end}}
+go run ./cmd/ossfuzz -corpus $OUT .
+`))
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus"
+)
+
+// A class is what a run of a seed amounted to, judged from its output.
+type class string
+
+const (
+	classOK       class = "ok"
+	classRace     class = "race"     // the detector reported a data race
+	classBuild    class = "build"    // the seed did not build
+	classTimeout  class = "timeout"  // the build or run hit the time limit
+	classDeadlock class = "deadlock" // every goroutine was blocked
+	classPanic    class = "panic"    // an unrecovered panic
+	classFatal    class = "fatal"    // any other runtime fatal error or signal
+	classRuntime  class = "tsan"     // the race runtime itself failed
+)
+
+// An outcome is the class of one run and the number of goroutines the
+// program reported as leaked, or -1 if it did not report.
+type outcome struct {
+	class  class
+	leaked int
+	output string
+}
+
+func (o outcome) String() string {
+	if o.leaked < 0 {
+		return string(o.class)
+	}
+	return fmt.Sprintf("%s/leaked=%d", o.class, o.leaked)
+}
+
+// An expect is the header line of a seed. A leaked count of -1 means the
+// header did not give one, and a non-empty panic is the value of the
+// unrecovered panic the program must end with.
+type expect struct {
+	race   bool
+	leaked int
+	panic  string
+}
+
+func (e expect) String() string {
+	s := fmt.Sprintf("race=%t", e.race)
+	if e.leaked >= 0 {
+		s += fmt.Sprintf(" leaked=%d", e.leaked)
+	}
+	if e.panic != "" {
+		s += " panic=" + e.panic
+	}
+	return s
+}
+
+// ok reports whether o is an acceptable outcome for a seed expecting
+// want, or for a seed without a header if !known.
+func (o outcome) ok(want expect, known bool) bool {
+	switch o.class {
+	case classTimeout, classRuntime:
+		return false
+	case classOK, classRace:
+		if known && want.panic != "" {
+			return false
+		}
+	case classPanic:
+		if !known || want.panic == "" {
+			return !known
+		}
+		return lastPanic(o.output) == want.panic
+	default:
+		return !known
+	}
+	if !known {
+		return true
+	}
+	return (o.class == classRace) == want.race && (want.leaked < 0 || o.leaked == want.leaked)
+}
+
+// lastPanic returns the value of the last panic the runtime printed,
+// which in a chain of panics from deferred calls is the one that ended
+// the program.
+func lastPanic(out string) string {
+	var last string
+	for l := range strings.Lines(out) {
+		if v, ok := strings.CutPrefix(strings.TrimPrefix(l, "\t"), "panic: "); ok {
+			last = strings.TrimSuffix(v, "\n")
+		}
+	}
+	return last
+}
+
+var headerRE = regexp.MustCompile(`(?m)^// racerun: race=(\w+)(?: leaked=(\d+))?(?: panic=(.+))?$`)
+
+// expectation returns the header of s, if any of its Go files has one.
+func expectation(s corpus.Seed) (expect, bool) {
+	for _, f := range s.Files {
+		m := headerRE.FindSubmatch(f.Data)
+		if m == nil {
+			continue
+		}
+		race, err := strconv.ParseBool(string(m[1]))
+		if err != nil {
+			continue
+		}
+		e := expect{race: race, leaked: -1, panic: string(m[3])}
+		if m[2] != nil {
+			e.leaked, _ = strconv.Atoi(string(m[2]))
+		}
+		return e, true
+	}
+	return expect{}, false
+}
+
+func packageName(src []byte) string {
+	f, err := parser.ParseFile(token.NewFileSet(), "", src, parser.PackageClauseOnly)
+	if err != nil {
+		return ""
+	}
+	return f.Name.Name
+}
+
+// build compiles the package in dir into bin, passing flags to go build.
+func build(ctx context.Context, dir, bin string, flags []string, timeout time.Duration) outcome {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	args := append([]string{"build"}, flags...)
+	cmd := exec.CommandContext(ctx, "go", append(args, "-o", bin, ".")...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	o := outcome{class: classOK, leaked: -1, output: string(out)}
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		o.class = classTimeout
+	case err != nil:
+		o.class = classBuild
+	}
+	return o
+}
+
+// raceEnv keeps the race runtime going after the first report and skips
+// the second it otherwise sleeps at exit to let reports from exiting
+// goroutines through; go/race seeds wait for their goroutines themselves.
+const raceEnv = "GORACE=halt_on_error=0 atexit_sleep_ms=0"
+
+// execute runs bin once and classifies its output.
+func execute(ctx context.Context, dir, bin string, timeout time.Duration) outcome {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), raceEnv)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	o := outcome{class: classOK, leaked: -1, output: out.String()}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		o.class = classTimeout
+		return o
+	}
+	o.class = classify(o.output, err)
+	if m := leakedRE.FindStringSubmatch(o.output); m != nil {
+		o.leaked, _ = strconv.Atoi(m[1])
+	}
+	return o
+}
+
+var leakedRE = regexp.MustCompile(`(?m)^racerun: leaked (\d+)$`)
+
+// classify judges a finished run by its output and exit error. Failures
+// of the race runtime take precedence over everything else, and a Go
+// crash over a race report printed before it.
+func classify(out string, err error) class {
+	var race, panicked, fatal, deadlock bool
+	for l := range strings.Lines(out) {
+		switch {
+		case strings.Contains(l, "ThreadSanitizer"),
+			strings.HasPrefix(l, "race: limit on"),
+			strings.HasPrefix(l, "==") && strings.Contains(l, "ERROR:"):
+			return classRuntime
+		case strings.HasPrefix(l, "WARNING: DATA RACE"):
+			race = true
+		case strings.HasPrefix(l, "fatal error: all goroutines are asleep"):
+			deadlock = true
+		case strings.HasPrefix(l, "panic: "):
+			panicked = true
+		case strings.HasPrefix(l, "fatal error: "),
+			strings.HasPrefix(l, "SIG"),
+			strings.HasPrefix(l, "unexpected signal"):
+			fatal = true
+		}
+	}
+	switch {
+	case deadlock:
+		return classDeadlock
+	case panicked:
+		return classPanic
+	case fatal:
+		return classFatal
+	case race:
+		return classRace
+	case err != nil:
+		// Killed by a signal, or an exit status the program chose.
+		if ee := (*exec.ExitError)(nil); errors.As(err, &ee) && !ee.Exited() {
+			return classFatal
+		}
+	}
+	return classOK
+}
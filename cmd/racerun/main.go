@@ -0,0 +1,212 @@
+// Racerun builds each main-package seed of a corpus with the race
+// detector, runs it under a time limit and classifies what it printed.
+//
+// Usage:
+//
+//	racerun [-runs n] [-timeout d] [-race=false] [-gcflags flags] [-v] [-json file] [-sarif file] path ...
+//
+// Each path is a corpus directory or seed file as read by package corpus.
+// Seeds written by the go/race and go/defer generators carry a header line
+//
+//	// racerun: race=true leaked=2
+//	// racerun: race=false panic=defer: final 0
+//
+// stating whether the detector must report a race, how many goroutines
+// the program leaves blocked if it says, and the value of the panic the
+// program must die of if it is meant to; a run that disagrees with it is
+// reported. For seeds without the header, only outcomes no program should
+// cause are reported: hangs and failures of the race runtime itself.
+//
+// With -race=false seeds are built without the race detector, which can
+// then only be expected not to report; -gcflags is passed to go build, so
+// that -gcflags=-N, say, runs the seeds with open-coded defers disabled.
+//
+// With -json and -sarif, the unexpected outcomes are also written to
+// files as findings (package internal/findings), each naming its seed's
+// main file and the generator and seed of its seedgen header.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/gen"
+	"github.com/geeknik/fuzzing/internal/findings"
+)
+
+var (
+	runs    = flag.Int("runs", 1, "`number` of times to run each seed")
+	timeout = flag.Duration("timeout", 30*time.Second, "per-build and per-run time `limit`")
+	lang    = flag.String("lang", "1.24", "go `version` for seeds without a go.mod")
+	verbose = flag.Bool("v", false, "print every seed, not only unexpected outcomes")
+	race    = flag.Bool("race", true, "build with the race detector")
+	gcflags = flag.String("gcflags", "", "`flags` for go build -gcflags")
+	jsonOut = flag.String("json", "", "write unexpected outcomes as JSON findings to `file`")
+	sarif   = flag.String("sarif", "", "write unexpected outcomes as SARIF to `file`")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("racerun: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: racerun [flags] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || *runs < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var seeds []corpus.Seed
+	for _, p := range flag.Args() {
+		s, err := corpus.Read(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		seeds = append(seeds, s...)
+	}
+
+	ctx := context.Background()
+	var checked, unexpected int
+	var fs []findings.Finding
+	for _, s := range seeds {
+		if !isMain(s) {
+			continue
+		}
+		checked++
+		want, known := expectation(s)
+		outs, err := run(ctx, s)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var bad []outcome
+		for _, o := range outs {
+			if !o.ok(want, known) {
+				bad = append(bad, o)
+			}
+		}
+		if len(bad) > 0 {
+			unexpected++
+			fs = append(fs, finding(s, bad[0], want, known))
+		}
+		if len(bad) > 0 || *verbose {
+			report(s, outs, bad, want, known)
+		}
+	}
+	fmt.Printf("%d of %d seeds unexpected\n", unexpected, checked)
+	if err := findings.Write(*jsonOut, *sarif, "racerun", fs); err != nil {
+		log.Fatal(err)
+	}
+	if unexpected > 0 {
+		os.Exit(1)
+	}
+}
+
+// run materializes s in a temporary module, builds it with -race and runs
+// the binary -runs times. A seed that does not build yields a single
+// outcome of class build.
+func run(ctx context.Context, s corpus.Seed) ([]outcome, error) {
+	dir, err := os.MkdirTemp("", "racerun")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if !s.Has("go.mod") {
+		s.Files = append(s.Files, gen.File{Name: "go.mod", Data: []byte("module seed\n\ngo " + *lang + "\n")})
+	}
+	if err := s.Write(dir); err != nil {
+		return nil, err
+	}
+	bin := filepath.Join(dir, ".out")
+	if o := build(ctx, dir, bin, buildFlags(), *timeout); o.class != classOK {
+		return []outcome{o}, nil
+	}
+	var outs []outcome
+	for range *runs {
+		outs = append(outs, execute(ctx, dir, bin, *timeout))
+	}
+	return outs, nil
+}
+
+func buildFlags() []string {
+	var flags []string
+	if *race {
+		flags = append(flags, "-race")
+	}
+	if *gcflags != "" {
+		flags = append(flags, "-gcflags="+*gcflags)
+	}
+	return flags
+}
+
+func report(s corpus.Seed, outs, bad []outcome, want expect, known bool) {
+	mark := "  "
+	if len(bad) > 0 {
+		mark = "! "
+	}
+	var classes []string
+	for _, o := range outs {
+		classes = append(classes, o.String())
+	}
+	line := fmt.Sprintf("%s%s\t%s", mark, s.Path, strings.Join(classes, " "))
+	if known {
+		line += fmt.Sprintf("\t(want %s)", want)
+	}
+	fmt.Println(line)
+	if len(bad) == 0 {
+		return
+	}
+	for _, l := range firstLines(bad[0].output, 8) {
+		fmt.Printf("    %s\n", l)
+	}
+}
+
+// finding describes the unexpected outcome o of s for package findings.
+func finding(s corpus.Seed, o outcome, want expect, known bool) findings.Finding {
+	f := findings.Finding{
+		Kind:    "unexpected-" + string(o.class),
+		Message: o.String(),
+		Output:  strings.Join(firstLines(o.output, 8), "\n"),
+	}
+	if known {
+		f.Message += ", want " + want.String()
+	}
+	var srcs [][]byte
+	for _, sf := range s.Files {
+		if path.Ext(sf.Name) != ".go" {
+			continue
+		}
+		if f.Input == "" && packageName(sf.Data) == "main" {
+			f.Input = s.FilePath(sf.Name)
+		}
+		srcs = append(srcs, sf.Data)
+	}
+	f.Origin = findings.OriginOf(srcs...)
+	return f
+}
+
+func isMain(s corpus.Seed) bool {
+	for _, f := range s.Files {
+		if path.Ext(f.Name) == ".go" && packageName(f.Data) == "main" {
+			return true
+		}
+	}
+	return false
+}
+
+func firstLines(s string, n int) []string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines
+}
@@ -0,0 +1,4825 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/geeknik/fuzzing/fuzz/printer"
+)
+
+// A driver is how a reproducer calls the API a fuzz target tests.
+type driver struct {
+	// files are where the target's arguments are stored, relative to
+	// the reproducer's root; an empty name means argument i is written
+	// into main.go as a Go literal instead, in place of $argi.
+	files []string
+
+	// main is main.go, without the comment that heads it. It calls the
+	// standard library or the modules listed in require the way the
+	// target does, prints what it returns and leaves a panic to crash
+	// the program.
+	main string
+
+	// run is the command that runs the reproducer, "go run ." if empty.
+	run string
+
+	// require lists the modules main.go imports from, which go.mod
+	// requires at the versions this repository builds with.
+	require []string
+
+	// args, if set, turns the target's arguments into the ones files
+	// and main take, for a target that reworks its input before calling
+	// the API.
+	args func([]any) ([]any, error)
+}
+
+var (
+	xmod    = []string{"golang.org/x/mod"}
+	xnet    = []string{"golang.org/x/net"}
+	ximg    = []string{"golang.org/x/image"}
+	quic    = []string{"github.com/quic-go/quic-go"}
+	dns     = []string{"github.com/miekg/dns", "golang.org/x/net"}
+	ws      = []string{"github.com/coder/websocket", "github.com/gobwas/ws", "github.com/gorilla/websocket"}
+	wasm    = []string{"github.com/tetratelabs/wazero"}
+	pb      = []string{"google.golang.org/protobuf"}
+	yaml    = []string{"gopkg.in/yaml.v3"}
+	toml    = []string{"github.com/BurntSushi/toml", "github.com/pelletier/go-toml/v2"}
+	md      = []string{"github.com/russross/blackfriday/v2", "github.com/yuin/goldmark"}
+	sql     = []string{"github.com/cockroachdb/cockroachdb-parser", "github.com/xwb1989/sqlparser"}
+	js      = []string{"github.com/dop251/goja", "github.com/evanw/esbuild", "github.com/robertkrimen/otto"}
+	py      = []string{"github.com/go-python/gpython", "github.com/smacker/go-tree-sitter"}
+	c       = []string{"modernc.org/cc/v4", "github.com/smacker/go-tree-sitter"}
+	rust    = []string{"github.com/alecthomas/chroma/v2", "github.com/smacker/go-tree-sitter"}
+	sh      = []string{"mvdan.cc/sh/v3"}
+	json5   = []string{"github.com/tailscale/hujson", "github.com/titanous/json5"}
+	git     = []string{"github.com/go-git/go-git/v5"}
+	ssh     = []string{"golang.org/x/crypto"}
+	jwt     = []string{"github.com/go-jose/go-jose/v4", "github.com/golang-jwt/jwt/v5"}
+	cbor    = []string{"github.com/fxamacker/cbor/v2", "github.com/vmihailenco/msgpack/v5"}
+	bencode = []string{"github.com/anacrolix/torrent", "github.com/jackpal/bencode-go", "github.com/zeebo/bencode"}
+	pdf     = []string{"rsc.io/pdf", "github.com/ledongthuc/pdf"}
+)
+
+// drivers are keyed by the base name of the harness package and the
+// target.
+var drivers = map[string]*driver{
+	"parser.FuzzParseFile":         {files: []string{"testdata/input.go"}, main: parserMain},
+	"format.FuzzFormat":            {files: []string{"testdata/input.go"}, main: formatMain},
+	"types.FuzzCheck":              {files: []string{"testdata/input.go"}, main: typesMain},
+	"types.FuzzVersions":           {files: []string{"testdata/input.go"}, main: versionsMain},
+	"cost.FuzzTypesCost":           {files: []string{"testdata/input.go"}, main: typesMain},
+	"cost.FuzzCompileCost":         {files: []string{"testdata/input.go"}, main: compileMain},
+	"build.FuzzMatchFile":          {files: []string{"testdata/input.go"}, main: buildMain},
+	"asm.FuzzAssemble":             {files: []string{"pkg/decl.go", "pkg/asm.s", ""}, main: asmMain},
+	"scanner.FuzzScan":             {files: []string{"testdata/input.go"}, main: scannerMain},
+	"literal.FuzzLiterals":         {files: []string{"testdata/input.go"}, main: literalsMain},
+	"literal.FuzzQuoted":           {files: []string{"testdata/input.txt"}, main: quotedMain},
+	"modfile.FuzzModFile":          {files: []string{"testdata/go.mod"}, main: modMain, run: "go mod tidy && go run .", require: xmod},
+	"modfile.FuzzWorkFile":         {files: []string{"testdata/go.work"}, main: workMain, run: "go mod tidy && go run .", require: xmod},
+	"modfile.FuzzSumFile":          {files: []string{"testdata/go.sum"}, main: sumMain, run: "go mod tidy && go run .", require: xmod},
+	"tag.FuzzTags":                 {files: []string{"testdata/input.go"}, main: tagsMain},
+	"tag.FuzzTag":                  {files: []string{"testdata/input.txt"}, main: tagMain},
+	"printer.FuzzPrint":            {files: []string{"testdata/input.go"}, main: printerMain, args: scatter},
+	"constant.FuzzArith":           {files: []string{"testdata/x.bin", "testdata/y.bin"}, main: constantMain},
+	"doc.FuzzDoc":                  {files: []string{"testdata/input.go"}, main: docMain},
+	"doc.FuzzComment":              {files: []string{"testdata/input.txt"}, main: commentMain},
+	"template.FuzzText":            {files: []string{"testdata/input.tmpl"}, main: templateMain("text/template", "input.tmpl")},
+	"regexp.FuzzParse":             {files: []string{"testdata/pattern.txt"}, main: regexpParseMain},
+	"regexp.FuzzMatch":             {files: []string{"testdata/pattern.txt", "testdata/subject.txt"}, main: regexpMatchMain},
+	"template.FuzzHTML":            {files: []string{"testdata/input.html"}, main: templateMain("html/template", "input.html")},
+	"json.FuzzAny":                 {files: []string{"testdata/input.json"}, main: jsonAnyMain},
+	"json.FuzzStruct":              {files: []string{"testdata/input.json"}, main: jsonStructMain},
+	"xml.FuzzToken":                {files: []string{"testdata/input.xml"}, main: xmlTokenMain},
+	"xml.FuzzUnmarshal":            {files: []string{"testdata/input.xml"}, main: xmlUnmarshalMain},
+	"gob.FuzzDecode":               {files: []string{"testdata/input.gob"}, main: gobDecodeMain},
+	"asn1.FuzzUnmarshal":           {files: []string{"testdata/input.der"}, main: asn1UnmarshalMain},
+	"x509.FuzzParseCertificate":    {files: []string{"testdata/input.der"}, main: x509ParseMain},
+	"tls.FuzzServer":               {files: []string{"testdata/input.tls"}, main: tlsMain("Server")},
+	"tls.FuzzClient":               {files: []string{"testdata/input.tls"}, main: tlsMain("Client")},
+	"http.FuzzReadRequest":         {files: []string{"testdata/input.http"}, main: httpMain("Request")},
+	"http.FuzzReadResponse":        {files: []string{"testdata/input.http"}, main: httpMain("Response")},
+	"textproto.FuzzReadMIMEHeader": {files: []string{"testdata/input.http"}, main: textprotoMain},
+	"http2.FuzzFramer":             {files: []string{"testdata/input.h2"}, main: http2FramerMain, run: "go mod tidy && go run .", require: xnet},
+	"http2.FuzzHPACK":              {files: []string{"testdata/input.hpack"}, main: hpackMain, run: "go mod tidy && go run .", require: xnet},
+	"quic.FuzzInitial":             {files: []string{"testdata/input.quic"}, main: quicMain(false), run: "go mod tidy && go run .", require: quic},
+	"dns.FuzzMessage":              {files: []string{"testdata/input.dns"}, main: dnsMessageMain, run: "go mod tidy && go run .", require: xnet},
+	"dns.FuzzMsg":                  {files: []string{"testdata/input.dns"}, main: miekgMain, run: "go mod tidy && go run .", require: dns},
+	"quic.FuzzParameters":          {files: []string{"testdata/input.qtp"}, main: quicMain(true), run: "go mod tidy && go run .", require: quic},
+	"websocket.FuzzServer":         {files: []string{"testdata/input.ws"}, main: wsMain(true), run: "go mod tidy && go run .", require: ws},
+	"websocket.FuzzClient":         {files: []string{"testdata/input.ws"}, main: wsMain(false), run: "go mod tidy && go run .", require: ws},
+	"url.FuzzParse":                {files: []string{"testdata/input.url"}, main: urlMain},
+	"time.FuzzParse":               {files: []string{"testdata/layout.txt", "testdata/value.txt"}, main: timeParseMain},
+	"time.FuzzDuration":            {files: []string{"testdata/input.dur"}, main: durationMain},
+	"zip.FuzzReader":               {files: []string{"testdata/input.zip"}, main: zipMain},
+	"tar.FuzzReader":               {files: []string{"testdata/input.tar"}, main: tarMain},
+	"compress.FuzzFlate":           {files: []string{"testdata/input.flate"}, main: compressMain("compress/flate", "flate.NewReader(r), error(nil)", "input.flate")},
+	"compress.FuzzGzip":            {files: []string{"testdata/input.gz"}, main: compressMain("compress/gzip", "gzip.NewReader(r)", "input.gz")},
+	"compress.FuzzZlib":            {files: []string{"testdata/input.zlib"}, main: compressMain("compress/zlib", "zlib.NewReader(r)", "input.zlib")},
+	"compress.FuzzBzip2":           {files: []string{"testdata/input.bz2"}, main: compressMain("compress/bzip2", "bzip2.NewReader(r), error(nil)", "input.bz2")},
+	"image.FuzzPNG":                {files: []string{"testdata/input.png"}, main: imageMain("image/png", "png", "input.png")},
+	"image.FuzzJPEG":               {files: []string{"testdata/input.jpg"}, main: imageMain("image/jpeg", "jpeg", "input.jpg")},
+	"image.FuzzGIF":                {files: []string{"testdata/input.gif"}, main: imageMain("image/gif", "gif", "input.gif")},
+	"image.FuzzWebP":               {files: []string{"testdata/input.webp"}, main: imageMain("golang.org/x/image/webp", "webp", "input.webp"), run: "go mod tidy && go run .", require: ximg},
+	"debug.FuzzELF":                {files: []string{"testdata/input.elf"}, main: debugMain("elf", "input.elf", false)},
+	"debug.FuzzPE":                 {files: []string{"testdata/input.exe"}, main: debugMain("pe", "input.exe", false)},
+	"debug.FuzzMachO":              {files: []string{"testdata/input.macho"}, main: debugMain("macho", "input.macho", true)},
+	"debug.FuzzDWARF":              {files: []string{"testdata/abbrev.dwarf", "testdata/info.dwarf", "testdata/line.dwarf", "testdata/ranges.dwarf", "testdata/str.dwarf"}, main: dwarfMain},
+	"wasm.FuzzModule":              {files: []string{"testdata/input.wasm"}, main: wasmMain, run: "go mod tidy && go run .", require: wasm},
+	"protobuf.FuzzUnmarshal":       {files: []string{"testdata/schema.desc", "testdata/input.pb"}, main: protoMain, run: "go mod tidy && go run .", require: pb},
+	"yaml.FuzzDecode":              {files: []string{"testdata/input.yaml"}, main: yamlMain, run: "go mod tidy && go run .", require: yaml},
+	"toml.FuzzDecode":              {files: []string{"testdata/input.toml"}, main: tomlMain, run: "go mod tidy && go run .", require: toml},
+	"csv.FuzzReader":               {files: []string{"testdata/input.csv"}, main: csvMain},
+	"markdown.FuzzRender":          {files: []string{"testdata/input.md"}, main: markdownMain, run: "go mod tidy && go run .", require: md},
+	"sql.FuzzParse":                {files: []string{"testdata/input.sql"}, main: sqlMain, run: "go mod tidy && go run .", require: sql},
+	"js.FuzzScript":                {files: []string{"testdata/input.js"}, main: jsMain, run: "go mod tidy && go run .", require: js},
+	"python.FuzzParse":             {files: []string{"testdata/input.py"}, main: pyMain, run: "go mod tidy && go run .", require: py},
+	"c.FuzzFile":                   {files: []string{"testdata/input.c"}, main: cMain, run: "go mod tidy && go run .", require: c},
+	"rust.FuzzLex":                 {files: []string{"testdata/input.rs"}, main: rustMain, run: "go mod tidy && go run .", require: rust},
+	"shell.FuzzParse":              {files: []string{"testdata/input.sh"}, main: shellMain, run: "go mod tidy && go run .", require: sh},
+	"json5.FuzzDecode":             {files: []string{"testdata/input.json5"}, main: json5Main, run: "go mod tidy && go run .", require: json5},
+	"mail.FuzzAddress":             {files: []string{"testdata/input.addr"}, main: mailAddressMain},
+	"mail.FuzzMediaType":           {files: []string{"testdata/input.ct"}, main: mediaTypeMain},
+	"mail.FuzzMessage":             {files: []string{"testdata/input.eml"}, main: mailMessageMain},
+	"multipart.FuzzReader":         {files: []string{"testdata/input.multipart"}, main: multipartMain},
+	"big.FuzzInt":                  {files: []string{"testdata/input.int"}, main: bigIntMain},
+	"big.FuzzFloat":                {files: []string{"testdata/input.float"}, main: bigFloatMain},
+	"big.FuzzRat":                  {files: []string{"testdata/input.rat"}, main: bigRatMain},
+	"strconv.FuzzFloat":            {files: []string{"testdata/input.float"}, main: strconvFloatMain},
+	"strconv.FuzzInt":              {files: []string{"testdata/input.int"}, main: strconvIntMain},
+	"filepath.FuzzPaths":           {files: []string{"testdata/input.paths"}, main: filepathMain},
+	"strconv.FuzzQuoted":           {files: []string{"testdata/input.quoted"}, main: strconvQuotedMain},
+	"embed.FuzzResolve":            {files: []string{"pkg/embed.go", "testdata/tree.txt"}, main: embedMain},
+	"modzip.FuzzZip":               {files: []string{"testdata/m.zip"}, main: modzipMain, run: "go mod tidy && go run .", require: xmod},
+	"modzip.FuzzProxy":             {files: []string{"testdata/list", "testdata/v.info", "testdata/v.mod", "testdata/v.zip"}, main: modproxyMain, run: "go mod tidy && go run .", require: xmod},
+	"semver.FuzzVersions":          {files: []string{"testdata/input.versions"}, main: semverMain, run: "go mod tidy && go run .", require: xmod},
+	"git.FuzzAdvertisement":        {files: []string{"testdata/refs.pkt"}, main: gitAdvMain, run: "go mod tidy && go run .", require: git},
+	"git.FuzzUploadRequest":        {files: []string{"testdata/request.pkt"}, main: gitRequestMain, run: "go mod tidy && go run .", require: git},
+	"git.FuzzPack":                 {files: []string{"testdata/input.pack"}, main: gitPackMain, run: "go mod tidy && go run .", require: git},
+	"ssh.FuzzServer":               {files: []string{"testdata/input.ssh"}, main: sshMain("Server"), run: "go mod tidy && go run .", require: ssh},
+	"ssh.FuzzClient":               {files: []string{"testdata/input.ssh"}, main: sshMain("Client"), run: "go mod tidy && go run .", require: ssh},
+	"ssh.FuzzMessage":              {files: []string{"testdata/message.ssh"}, main: sshMessageMain, run: "go mod tidy && go run .", require: ssh},
+	"jwt.FuzzJWS":                  {files: []string{"testdata/token.jws"}, main: jwsMain, run: "go mod tidy && go run .", require: jwt},
+	"jwt.FuzzJWE":                  {files: []string{"testdata/token.jwe"}, main: jweMain, run: "go mod tidy && go run .", require: jwt},
+	"pem.FuzzPEM":                  {files: []string{"testdata/input.pem"}, main: pemMain},
+	"pem.FuzzBase64":               {files: []string{"testdata/input.b64"}, main: base64Main},
+	"netip.FuzzAddr":               {files: []string{"testdata/input.ip"}, main: addrMain},
+	"netip.FuzzPrefix":             {files: []string{"testdata/input.cidr"}, main: prefixMain},
+	"cbor.FuzzCBOR":                {files: []string{"testdata/input.cbor"}, main: cborMain, run: "go mod tidy && go run .", require: cbor},
+	"cbor.FuzzMsgpack":             {files: []string{"testdata/input.msgpack"}, main: msgpackMain, run: "go mod tidy && go run .", require: cbor},
+	"bencode.FuzzBencode":          {files: []string{"testdata/input.torrent"}, main: bencodeMain, run: "go mod tidy && go run .", require: bencode},
+	"font.FuzzSFNT":                {files: []string{"testdata/input.ttf"}, main: fontMain("input.ttf", false), run: "go mod tidy && go run .", require: ximg},
+	"font.FuzzCollection":          {files: []string{"testdata/input.ttc"}, main: fontMain("input.ttc", true), run: "go mod tidy && go run .", require: ximg},
+	"pdf.FuzzPDF":                  {files: []string{"testdata/input.pdf"}, main: pdfMain, run: "go mod tidy && go run .", require: pdf},
+	"html.FuzzParse":               {files: []string{"testdata/input.html"}, main: htmlMain, run: "go mod tidy && go run .", require: xnet},
+}
+
+const parserMain = `package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "input.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	fmt.Println("ParseFile error:", err)
+	if f == nil {
+		return
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n != nil && n.End() < n.Pos() {
+			fmt.Printf("%s: %T ends before it starts, at %s\n", fset.Position(n.Pos()), n, fset.Position(n.End()))
+		}
+		return true
+	})
+}
+`
+
+const formatMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"os"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	once, err := format.Source(src)
+	if err != nil {
+		fmt.Println("format(x) error:", err)
+		return
+	}
+	fmt.Printf("--- format(x)\n%s", once)
+	twice, err := format.Source(once)
+	if err != nil {
+		fmt.Println("format(format(x)) error:", err)
+		return
+	}
+	fmt.Printf("--- format(format(x))\n%s", twice)
+	fmt.Println("idempotent:", bytes.Equal(once, twice))
+}
+`
+
+const typesMain = `package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"time"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "input.go", src, parser.ParseComments)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	conf := types.Config{
+		Importer: importer.Default(),
+		Error:    func(err error) { fmt.Println(err) },
+	}
+	start := time.Now()
+	conf.Check("p", fset, []*ast.File{f}, nil)
+	fmt.Println("checked in", time.Since(start))
+}
+`
+
+const versionsMain = `package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"go/version"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "input.go", src, parser.ParseComments)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	last, err := strconv.Atoi(strings.TrimPrefix(version.Lang(runtime.Version()), "go1."))
+	if err != nil {
+		last = 30
+	}
+	for v := 18; v <= last; v++ {
+		var errs []error
+		conf := types.Config{
+			GoVersion: fmt.Sprintf("go1.%d", v),
+			Importer:  importer.Default(),
+			Error:     func(err error) { errs = append(errs, err) },
+		}
+		conf.Check("p", fset, []*ast.File{f}, nil)
+		fmt.Printf("%s: %d errors\n", conf.GoVersion, len(errs))
+		for _, err := range errs {
+			fmt.Printf("\t%v\n", err)
+		}
+	}
+}
+`
+
+const compileMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+func main() {
+	start := time.Now()
+	cmd := exec.Command("go", "tool", "compile", "-p", "p", "-o", os.DevNull, "testdata/input.go")
+	out, err := cmd.CombinedOutput()
+	fmt.Printf("%s", out)
+	fmt.Println("go tool compile:", cmd.ProcessState, err, "after", time.Since(start))
+}
+`
+
+const buildMain = `package main
+
+import (
+	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"os"
+	"strings"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	for l := range strings.Lines(string(src)) {
+		l = strings.TrimSpace(l)
+		if !constraint.IsGoBuild(l) && !constraint.IsPlusBuild(l) {
+			continue
+		}
+		expr, err := constraint.Parse(l)
+		fmt.Printf("%s\n\tParse: %v, %v\n", l, expr, err)
+		if err == nil {
+			lines, err := constraint.PlusBuildLines(expr)
+			fmt.Printf("\tPlusBuildLines: %q, %v\n", lines, err)
+		}
+	}
+	for _, c := range []struct {
+		goos, goarch string
+		cgo          bool
+		tags         []string
+	}{
+		{"linux", "amd64", true, nil},
+		{"windows", "arm64", false, []string{"custom"}},
+		{"darwin", "arm64", true, nil},
+		{"ios", "arm64", false, nil},
+		{"android", "386", true, []string{"integration"}},
+		{"illumos", "amd64", false, nil},
+		{"js", "wasm", false, []string{"custom", "integration"}},
+		{"plan9", "386", false, nil},
+	} {
+		ctxt := build.Default
+		ctxt.GOOS, ctxt.GOARCH, ctxt.CgoEnabled, ctxt.BuildTags = c.goos, c.goarch, c.cgo, c.tags
+		match, err := ctxt.MatchFile("testdata", "input.go")
+		fmt.Printf("%s/%s cgo=%t tags=%q: MatchFile %t, %v\n", c.goos, c.goarch, c.cgo, c.tags, match, err)
+	}
+}
+`
+
+const asmMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const arch = $arg2
+
+func main() {
+	goarch := arch
+	if goarch != "amd64" && goarch != "arm64" {
+		goarch = "amd64"
+	}
+	goroot, err := exec.Command("go", "env", "GOROOT").Output()
+	if err != nil {
+		panic(err)
+	}
+	include := filepath.Join(strings.TrimSpace(string(goroot)), "pkg", "include")
+	for _, args := range [][]string{
+		{"vet", "./pkg"},
+		{"tool", "asm", "-p", "pkg", "-I", include, "-o", os.DevNull, "pkg/asm.s"},
+	} {
+		cmd := exec.Command("go", args...)
+		cmd.Env = append(os.Environ(), "GOARCH="+goarch)
+		out, err := cmd.CombinedOutput()
+		fmt.Printf("GOARCH=%s go %s: %v\n%s", goarch, strings.Join(args, " "), err, out)
+	}
+}
+`
+
+const scannerMain = `package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	for _, mode := range []scanner.Mode{scanner.ScanComments, 0} {
+		fmt.Printf("mode %d:\n", mode)
+		fset := token.NewFileSet()
+		file := fset.AddFile("input.go", -1, len(src))
+		var s scanner.Scanner
+		s.Init(file, src, func(pos token.Position, msg string) { fmt.Printf("\terror %s: %s\n", pos, msg) }, mode)
+		for {
+			pos, tok, lit := s.Scan()
+			fmt.Printf("\t%d %s %q\n", file.Offset(pos), tok, lit)
+			if tok == token.EOF {
+				break
+			}
+		}
+		fmt.Printf("\t%d errors, %d lines\n", s.ErrorCount, file.LineCount())
+	}
+}
+`
+
+const literalsMain = `package main
+
+import (
+	"fmt"
+	"go/constant"
+	"go/scanner"
+	"go/token"
+	"os"
+	"strconv"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	file := fset.AddFile("input.go", -1, len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, msg string) { fmt.Printf("%s: %s\n", pos, msg) }, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.STRING && tok != token.CHAR {
+			continue
+		}
+		v, err := strconv.Unquote(lit)
+		fmt.Printf("%s: %s\n\tUnquote: %q, %v\n\tconstant: %v\n", fset.Position(pos), lit, v, err, constant.MakeFromLiteral(lit, tok, 0))
+	}
+}
+`
+
+const quotedMain = `package main
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"os"
+	"strconv"
+)
+
+func main() {
+	q, err := os.ReadFile("testdata/input.txt")
+	if err != nil {
+		panic(err)
+	}
+	v, err := strconv.Unquote(string(q))
+	fmt.Printf("Unquote(%q) = %q, %v\n", q, v, err)
+	fset := token.NewFileSet()
+	var s scanner.Scanner
+	s.Init(fset.AddFile("input.txt", -1, len(q)), q, func(pos token.Position, msg string) { fmt.Printf("%s: %s\n", pos, msg) }, 0)
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		fmt.Printf("%s %q\n", tok, lit)
+	}
+}
+`
+
+const modMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/mod/modfile"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/go.mod")
+	if err != nil {
+		panic(err)
+	}
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		fmt.Println("Parse:", err)
+		return
+	}
+	out, err := f.Format()
+	if err != nil {
+		fmt.Println("Format:", err)
+		return
+	}
+	fmt.Printf("--- Format\n%s", out)
+	_, err = modfile.Parse("go.mod", out, nil)
+	fmt.Println("reparse:", err)
+}
+`
+
+const workMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/mod/modfile"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/go.work")
+	if err != nil {
+		panic(err)
+	}
+	f, err := modfile.ParseWork("go.work", data, nil)
+	if err != nil {
+		fmt.Println("ParseWork:", err)
+		return
+	}
+	out := modfile.Format(f.Syntax)
+	fmt.Printf("--- Format\n%s", out)
+	_, err = modfile.ParseWork("go.work", out, nil)
+	fmt.Println("reparse:", err)
+}
+`
+
+const sumMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/go.sum")
+	if err != nil {
+		panic(err)
+	}
+	for l := range strings.Lines(string(data)) {
+		f := strings.Fields(l)
+		if len(f) < 2 {
+			continue
+		}
+		path, vers := f[0], strings.TrimSuffix(f[1], "/go.mod")
+		esc, err := module.EscapePath(path)
+		fmt.Printf("%s %s\n\tEscapePath: %q, %v\n", path, vers, esc, err)
+		if err == nil {
+			back, err := module.UnescapePath(esc)
+			fmt.Printf("\tUnescapePath: %q, %v\n", back, err)
+		}
+		fmt.Printf("\tCanonical: %q\n\tCheck: %v\n", semver.Canonical(vers), module.Check(path, vers))
+	}
+}
+`
+
+const tagsMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "input.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if field, ok := n.(*ast.Field); ok && field.Tag != nil {
+			if tag, err := strconv.Unquote(field.Tag.Value); err == nil {
+				check(tag)
+			}
+		}
+		return true
+	})
+}
+
+func check(tag string) {
+	st := reflect.StructTag(tag)
+	v, ok := st.Lookup("json")
+	fmt.Printf("%q\n\tLookup(\"json\"): %q, %t\n", tag, v, ok)
+	typ := reflect.StructOf([]reflect.StructField{{Name: "F", Type: reflect.TypeFor[int](), Tag: st}})
+	val := reflect.New(typ)
+	val.Elem().Field(0).SetInt(7)
+	b, err := json.Marshal(val.Elem().Interface())
+	fmt.Printf("\tMarshal: %s, %v\n", b, err)
+	if err == nil {
+		back := reflect.New(typ)
+		err := json.Unmarshal(b, back.Interface())
+		fmt.Printf("\tUnmarshal: F=%d, %v\n", back.Elem().Field(0).Int(), err)
+	}
+}
+`
+
+const tagMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+)
+
+func main() {
+	tag, err := os.ReadFile("testdata/input.txt")
+	if err != nil {
+		panic(err)
+	}
+	st := reflect.StructTag(tag)
+	v, ok := st.Lookup("json")
+	fmt.Printf("%q\n\tLookup(\"json\"): %q, %t\n", tag, v, ok)
+	typ := reflect.StructOf([]reflect.StructField{{Name: "F", Type: reflect.TypeFor[int](), Tag: st}})
+	val := reflect.New(typ)
+	val.Elem().Field(0).SetInt(7)
+	b, err := json.Marshal(val.Elem().Interface())
+	fmt.Printf("\tMarshal: %s, %v\n", b, err)
+	if err == nil {
+		back := reflect.New(typ)
+		err := json.Unmarshal(b, back.Interface())
+		fmt.Printf("\tUnmarshal: F=%d, %v\n", back.Elem().Field(0).Int(), err)
+	}
+}
+`
+
+const printerMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+)
+
+// configs are gofmt's printer configuration and the raw one.
+var configs = []struct {
+	name string
+	printer.Config
+}{
+	{"gofmt", printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}},
+	{"raw", printer.Config{Mode: printer.RawFormat, Tabwidth: 8}},
+}
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "input.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		fmt.Println("ParseFile error:", err)
+		return
+	}
+	fmt.Printf("comments: %q\n", comments(f))
+	for _, cfg := range configs {
+		var buf bytes.Buffer
+		if err := cfg.Fprint(&buf, fset, f); err != nil {
+			fmt.Printf("%s (mode %v): Fprint error: %v\n", cfg.name, cfg.Mode, err)
+			continue
+		}
+		fmt.Printf("--- %s (mode %v)\n%s", cfg.name, cfg.Mode, buf.Bytes())
+		g, err := parser.ParseFile(token.NewFileSet(), "printed.go", buf.Bytes(), parser.ParseComments|parser.SkipObjectResolution)
+		if err != nil {
+			fmt.Println("printed file does not parse:", err)
+			continue
+		}
+		fmt.Printf("comments: %q\n", comments(g))
+	}
+}
+
+func comments(f *ast.File) []string {
+	var out []string
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			out = append(out, c.Text)
+		}
+	}
+	return out
+}
+`
+
+// scatter puts the comments FuzzPrint's seed chooses into its source, so
+// that the reproducer needs only the one file.
+func scatter(args []any) ([]any, error) {
+	if len(args) == 2 {
+		src, ok := args[0].([]byte)
+		seed, ok2 := args[1].(uint64)
+		if ok && ok2 {
+			return []any{printer.Scatter(src, seed)}, nil
+		}
+	}
+	return nil, fmt.Errorf("FuzzPrint takes a []byte and a uint64")
+}
+
+const constantMain = `package main
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"math"
+	"math/big"
+	"os"
+)
+
+// decode makes an operand of b as the target does. The first byte picks
+// the kind: an integer, a fraction, a float64, a complex number, a float
+// too large or small for a fraction, a boolean, a string or Unknown.
+func decode(b []byte) constant.Value {
+	if len(b) == 0 {
+		return constant.MakeInt64(0)
+	}
+	kind, b := b[0]%8, b[1:]
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	switch kind {
+	case 0:
+		return constant.Make(integer(b))
+	case 1:
+		a, d := new(big.Int).SetBytes(b[:len(b)/2]), new(big.Int).SetBytes(b[len(b)/2:])
+		if d.Sign() == 0 {
+			d.SetInt64(1)
+		}
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			a.Neg(a)
+		}
+		return constant.BinaryOp(constant.Make(a), token.QUO, constant.Make(d))
+	case 2:
+		var u uint64
+		for _, c := range b[:min(8, len(b))] {
+			u = u<<8 | uint64(c)
+		}
+		return constant.MakeFloat64(math.Float64frombits(u))
+	case 3:
+		re, im := integer(b[:len(b)/2]), integer(b[len(b)/2:])
+		return constant.BinaryOp(constant.Make(re), token.ADD, constant.MakeImag(constant.Make(im)))
+	case 4:
+		exp := 0
+		if len(b) >= 2 {
+			exp, b = int(int16(uint16(b[0])<<8|uint16(b[1]))), b[2:]
+		}
+		lit := fmt.Sprintf("0x%sp%d", new(big.Int).SetBytes(b).Text(16), exp)
+		return constant.MakeFromLiteral(lit, token.FLOAT, 0)
+	case 5:
+		return constant.MakeBool(len(b) > 0 && b[0]&1 != 0)
+	case 6:
+		return constant.MakeString(string(b))
+	}
+	return constant.MakeUnknown()
+}
+
+// integer reads a sign byte and a big-endian magnitude.
+func integer(b []byte) *big.Int {
+	if len(b) == 0 {
+		return new(big.Int)
+	}
+	i := new(big.Int).SetBytes(b[1:])
+	if b[0]&1 != 0 {
+		i.Neg(i)
+	}
+	return i
+}
+
+func operand(name string) constant.Value {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		panic(err)
+	}
+	return decode(b)
+}
+
+func numeric(v constant.Value) bool {
+	k := v.Kind()
+	return k == constant.Int || k == constant.Float || k == constant.Complex
+}
+
+func bigInt(v constant.Value) *big.Int {
+	z, _ := new(big.Int).SetString(v.ExactString(), 10)
+	return z
+}
+
+func main() {
+	x, y := operand("testdata/x.bin"), operand("testdata/y.bin")
+	fmt.Printf("x = %s (%v)\ny = %s (%v)\n", x.ExactString(), x.Kind(), y.ExactString(), y.Kind())
+	for _, v := range []constant.Value{x, y} {
+		if !numeric(v) {
+			continue
+		}
+		fmt.Printf("%s:\n\t-x = %s\n\tToInt = %s\n\tToFloat = %s\n\tToComplex = %s\n", v.ExactString(),
+			constant.UnaryOp(token.SUB, v, 0).ExactString(), constant.ToInt(v).ExactString(),
+			constant.ToFloat(v).ExactString(), constant.ToComplex(v).ExactString())
+		if v.Kind() == constant.Int {
+			i, iok := constant.Int64Val(v)
+			u, uok := constant.Uint64Val(v)
+			fmt.Printf("\t^x = %s\n\tInt64Val = %d, %v\n\tUint64Val = %d, %v\n\tBitLen = %d\n",
+				constant.UnaryOp(token.XOR, v, 0).ExactString(), i, iok, u, uok, constant.BitLen(v))
+		}
+	}
+	ops := []token.Token{token.ADD, token.SUB, token.MUL, token.QUO, token.LAND, token.LOR}
+	if x.Kind() == constant.Int && y.Kind() == constant.Int {
+		ops = append(ops, token.QUO_ASSIGN, token.REM, token.AND, token.OR, token.XOR, token.AND_NOT)
+	}
+	for _, op := range ops {
+		switch {
+		case x.Kind() == constant.Unknown || y.Kind() == constant.Unknown:
+		case op == token.LAND || op == token.LOR:
+			if x.Kind() != constant.Bool || y.Kind() != constant.Bool {
+				continue
+			}
+		case x.Kind() == constant.String && y.Kind() == constant.String:
+			if op != token.ADD {
+				continue
+			}
+		case !numeric(x) || !numeric(y):
+			continue
+		case (op == token.QUO || op == token.QUO_ASSIGN || op == token.REM) && constant.Sign(y) == 0:
+			continue
+		}
+		fmt.Printf("x %s y = %s\n", op, constant.BinaryOp(x, op, y).ExactString())
+	}
+	if x.Kind() == constant.Int && y.Kind() == constant.Int {
+		zx, zy := bigInt(x), bigInt(y)
+		if zy.Sign() != 0 {
+			q, r := new(big.Int).QuoRem(zx, zy, new(big.Int))
+			fmt.Printf("math/big: x / y = %s, x %% y = %s\n", q, r)
+		}
+		s := uint(new(big.Int).Abs(zy).Uint64() % (1<<10 + 1))
+		shl := constant.Shift(x, token.SHL, s)
+		fmt.Printf("x << %d = %s (math/big: %s)\n", s, shl.ExactString(), new(big.Int).Lsh(zx, s))
+		fmt.Printf("x >> %d = %s (math/big: %s)\n", s, constant.Shift(x, token.SHR, s).ExactString(), new(big.Int).Rsh(zx, s))
+		fmt.Printf("x << %d >> %d = %s\n", s, s, constant.Shift(shl, token.SHR, s).ExactString())
+	}
+	if x.Kind() == y.Kind() || numeric(x) && numeric(y) {
+		for _, op := range []token.Token{token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ} {
+			if op != token.EQL && op != token.NEQ && (x.Kind() == constant.Bool || x.Kind() == constant.Complex || y.Kind() == constant.Complex) {
+				continue
+			}
+			fmt.Printf("x %s y is %v\n", op, constant.Compare(x, op, y))
+		}
+	}
+}
+`
+
+const docMain = `package main
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"os"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.go")
+	if err != nil {
+		panic(err)
+	}
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "input.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		fmt.Println("ParseFile error:", err)
+		return
+	}
+	pkg, err := doc.NewFromFiles(fset, []*ast.File{f}, "example.com/"+f.Name.Name)
+	if err != nil {
+		fmt.Println("NewFromFiles error:", err)
+		return
+	}
+	fmt.Printf("Synopsis: %q\n", pkg.Synopsis(pkg.Doc))
+	show := func(name, text string) {
+		fmt.Printf("--- %s\n%s--- text\n%s", name, text, pkg.Text(text))
+		pkg.HTML(text)
+		pkg.Markdown(text)
+		pr := pkg.Printer()
+		once := pr.Comment(pkg.Parser().Parse(text))
+		twice := pr.Comment(pkg.Parser().Parse(string(once)))
+		fmt.Printf("--- reformatted\n%s--- reformatted twice\n%s", once, twice)
+	}
+	show("package "+pkg.Name, pkg.Doc)
+	for _, f := range pkg.Funcs {
+		show(f.Name, f.Doc)
+	}
+	for _, t := range pkg.Types {
+		show(t.Name, t.Doc)
+		for _, m := range t.Methods {
+			show(t.Name+"."+m.Name, m.Doc)
+		}
+	}
+}
+`
+
+const commentMain = `package main
+
+import (
+	"fmt"
+	"go/doc/comment"
+	"os"
+)
+
+func main() {
+	text, err := os.ReadFile("testdata/input.txt")
+	if err != nil {
+		panic(err)
+	}
+	var p comment.Parser
+	var pr comment.Printer
+	s := string(text)
+	for i := 1; i <= 3; i++ {
+		d := p.Parse(s)
+		s = string(pr.Comment(d))
+		fmt.Printf("--- reformatted %d times\n%s--- text\n%s", i, s, pr.Text(d))
+	}
+	pr.HTML(p.Parse(string(text)))
+	pr.Markdown(p.Parse(string(text)))
+}
+`
+
+// templateMain executes a template with pkg on a copy of the data
+// fuzz/template executes it with.
+func templateMain(pkg, file string) string {
+	return `package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"` + pkg + `"
+)
+
+type Data struct {
+	Name, URL, CSS, JS string
+
+	N int
+	F float64
+	B bool
+
+	Items  []string
+	M      map[string]string
+	Any    any
+	Nested *Data
+	Nil    *Data
+	Fn     func(int) int
+}
+
+func (d *Data) Method(s string) string { return s + d.Name }
+
+func (d *Data) Err() (string, error) { return "", errors.New("Err called") }
+
+func data() *Data {
+	const inject = "<x-inj"
+	return &Data{
+		Name:  inject + ` + "` a=\"b\" c='d'>&amp;</x-inj>`" + `,
+		URL:   "javascript:alert(1)//" + inject + ">",
+		CSS:   "expression(alert(1))</style>" + inject + ">",
+		JS:    ` + "`\"; alert(1); //</script>`" + ` + inject + ">",
+		N:     3,
+		F:     1.5,
+		B:     true,
+		Items: []string{"a", inject + ">", ` + "`\"'` + \"`\"" + `, "</script>"},
+		M:     map[string]string{"k": inject + ">", "x-y": "v"},
+		Any:   []int{1, 2},
+		Fn:    func(n int) int { return n * 2 },
+	}
+}
+
+func main() {
+	src, err := os.ReadFile("testdata/` + file + `")
+	if err != nil {
+		panic(err)
+	}
+	funcs := map[string]any{
+		"upper": strings.ToUpper,
+		"join":  strings.Join,
+		"fail":  func() (string, error) { return "", errors.New("fail called") },
+		"boom":  func() string { panic("boom called") },
+	}
+	t, err := template.New("page").Funcs(funcs).Parse(string(src))
+	if err != nil {
+		fmt.Println("Parse error:", err)
+		return
+	}
+	d := data()
+	d.Nested = data()
+	d.Nested.Nested = data()
+	for i := 1; i <= 2; i++ {
+		var b strings.Builder
+		err := t.Execute(&b, d)
+		fmt.Printf("--- execution %d (%v)\n%s\n", i, err, b.String())
+	}
+}
+`
+}
+
+const regexpParseMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"regexp/syntax"
+)
+
+func main() {
+	pattern, err := os.ReadFile("testdata/pattern.txt")
+	if err != nil {
+		panic(err)
+	}
+	for _, flags := range []syntax.Flags{syntax.Perl, syntax.POSIX} {
+		re, err := syntax.Parse(string(pattern), flags)
+		if err != nil {
+			fmt.Printf("flags %#x: Parse error: %v\n", flags, err)
+			continue
+		}
+		printed := re.String()
+		fmt.Printf("flags %#x: %#q\n", flags, printed)
+		again, err := syntax.Parse(printed, syntax.Perl)
+		if err != nil {
+			fmt.Println("\treparse error:", err)
+			continue
+		}
+		fmt.Printf("\treprinted: %#q\n", again)
+		_, err = syntax.Compile(re.Simplify())
+		fmt.Println("\tCompile error:", err)
+	}
+}
+`
+
+const regexpMatchMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+func main() {
+	pattern, err := os.ReadFile("testdata/pattern.txt")
+	if err != nil {
+		panic(err)
+	}
+	subject, err := os.ReadFile("testdata/subject.txt")
+	if err != nil {
+		panic(err)
+	}
+	s := string(subject)
+	for _, compile := range []func(string) (*regexp.Regexp, error){regexp.Compile, regexp.CompilePOSIX} {
+		re, err := compile(string(pattern))
+		if err != nil {
+			fmt.Println("compile error:", err)
+			continue
+		}
+		start := time.Now()
+		fmt.Printf("%#q on %d bytes:\n", re, len(s))
+		fmt.Println("\tFindStringIndex:", re.FindStringIndex(s))
+		fmt.Println("\tFindReaderIndex:", re.FindReaderIndex(strings.NewReader(s)))
+		fmt.Println("\tFindStringSubmatchIndex:", re.FindStringSubmatchIndex(s))
+		fmt.Println("\tFindReaderSubmatchIndex:", re.FindReaderSubmatchIndex(strings.NewReader(s)))
+		fmt.Println("\tFindAllStringIndex:", re.FindAllStringIndex(s, -1))
+		fmt.Println("\ttook", time.Since(start))
+	}
+}
+`
+
+const jsonAnyMain = `package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.json")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Valid:", json.Valid(data))
+	var compact bytes.Buffer
+	fmt.Println("Compact error:", json.Compact(&compact, data))
+	var u any
+	fmt.Println("Unmarshal error:", json.Unmarshal(data, &u))
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			if err != io.EOF {
+				fmt.Println("Token error:", err)
+			}
+			break
+		}
+		fmt.Printf("\t%T %v\n", tok, tok)
+	}
+	d = json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		fmt.Println("Decode error:", err)
+		return
+	}
+	out, err := json.Marshal(v)
+	fmt.Printf("decoded %#v\nmarshaled %s (%v)\n", v, out, err)
+}
+`
+
+const jsonStructMain = `package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Upper string
+
+func (u Upper) MarshalText() ([]byte, error) { return []byte(u), nil }
+
+func (u *Upper) UnmarshalText(text []byte) error {
+	*u = Upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+type Inner struct {
+	Text string "json:\"text\""
+	K    int    "json:\",omitempty\""
+}
+
+type Exotic struct {
+	Name  string          "json:\"name\""
+	Dash  int             "json:\"-,\""
+	Skip  int             "json:\"-\""
+	Str   int64           "json:\"str,string\""
+	F     float64         "json:\",string\""
+	B     bool            "json:\"b,string\""
+	Omit  []int           "json:\"omit,omitempty\""
+	Z     Inner           "json:\"z,omitzero\""
+	Raw   json.RawMessage "json:\"raw\""
+	Num   json.Number     "json:\"num\""
+	Any   any             "json:\"any\""
+	Ptr   *Exotic         "json:\"ptr\""
+	Map   map[string]int  "json:\"map\""
+	IMap  map[int]string  "json:\"imap\""
+	Arr   [2]int8         "json:\"arr\""
+	Slice []*Exotic       "json:\"slice\""
+	Bytes []byte          "json:\"bytes\""
+	U8    uint8           "json:\"u8\""
+	Kanji string          "json:\"名前\""
+	Space string          "json:\"a b,omitempty\""
+	Dup1  int             "json:\"dup\""
+	Dup2  int             "json:\"Dup\""
+	Key   map[Upper]Upper "json:\"Key\""
+	Inner
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/input.json")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("Valid:", json.Valid(data))
+	for i := 0; i < 3; i++ {
+		var v Exotic
+		if err := json.Unmarshal(data, &v); err != nil {
+			fmt.Println("Unmarshal error:", err)
+			return
+		}
+		data, err = json.Marshal(&v)
+		fmt.Printf("--- marshaled %d times (%v)\n%s\n", i+1, err, data)
+	}
+}
+`
+
+const xmlTokenMain = `package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.xml")
+	if err != nil {
+		panic(err)
+	}
+	for _, strict := range []bool{true, false} {
+		fmt.Println("--- strict:", strict)
+		d := xml.NewDecoder(bytes.NewReader(data))
+		if !strict {
+			d.Strict = false
+			d.AutoClose = xml.HTMLAutoClose
+			d.Entity = xml.HTMLEntity
+		}
+		var toks []xml.Token
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				fmt.Println("Token error:", err)
+				break
+			}
+			fmt.Printf("\t%d: %T %q\n", d.InputOffset(), tok, tok)
+			toks = append(toks, xml.CopyToken(tok))
+		}
+		if !strict {
+			continue
+		}
+		var b bytes.Buffer
+		e := xml.NewEncoder(&b)
+		for _, t := range toks {
+			if err := e.EncodeToken(t); err != nil {
+				fmt.Println("EncodeToken error:", err)
+				break
+			}
+		}
+		fmt.Println("Flush error:", e.Flush())
+		fmt.Printf("re-encoded:\n%s\n", b.Bytes())
+	}
+}
+`
+
+const xmlUnmarshalMain = `package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type Upper string
+
+func (u Upper) MarshalText() ([]byte, error) { return []byte(u), nil }
+
+func (u *Upper) UnmarshalText(text []byte) error {
+	*u = Upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+type Item struct {
+	Kind  string "xml:\"kind,attr,omitempty\""
+	Inner string "xml:\",innerxml\""
+}
+
+type Note struct {
+	Lang string "xml:\"http://www.w3.org/XML/1998/namespace lang,attr,omitempty\""
+	Text string "xml:\",cdata\""
+}
+
+type Any struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr "xml:\",any,attr\""
+	Text     string     "xml:\",chardata\""
+	Children []Any      "xml:\",any\""
+}
+
+type Doc struct {
+	XMLName xml.Name "xml:\"doc\""
+	ID      string   "xml:\"id,attr,omitempty\""
+	B       bool     "xml:\"b,attr,omitempty\""
+	NSAttr  string   "xml:\"urn:x kind,attr,omitempty\""
+	Name    string   "xml:\"name,omitempty\""
+	NSName  string   "xml:\"urn:y 名前,omitempty\""
+	Path    []string "xml:\"a>b>c\""
+	N       int      "xml:\"n,omitempty\""
+	F       float64  "xml:\"f,omitempty\""
+	Up      Upper    "xml:\"up,omitempty\""
+	Skip    string   "xml:\"-\""
+	Items   []Item   "xml:\"item\""
+	Notes   []Note   "xml:\"note\""
+	Inner   *Doc     "xml:\"doc\""
+	Text    string   "xml:\",chardata\""
+	Comment string   "xml:\",comment\""
+	Any     []Any    "xml:\",any\""
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/input.xml")
+	if err != nil {
+		panic(err)
+	}
+	for i := 0; i < 3; i++ {
+		var v Doc
+		if err := xml.Unmarshal(data, &v); err != nil {
+			fmt.Println("Unmarshal error:", err)
+			return
+		}
+		data, err = xml.Marshal(&v)
+		fmt.Printf("--- marshaled %d times (%v)\n%s\n", i+1, err, data)
+	}
+}
+`
+
+const gobDecodeMain = `package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
+)
+
+type Node struct {
+	Name string
+	N    int
+	U    uint64
+	B    bool
+	F    float64
+	C    complex128
+	Data []byte
+	Next *Node
+	Kids []*Node
+	M    map[string]*Node
+	Tags [3]string
+	Any  any
+}
+
+type (
+	Leaf struct {
+		V int
+	}
+	Ints []int
+)
+
+func main() {
+	gob.RegisterName("Leaf", Leaf{})
+	gob.RegisterName("Node", &Node{})
+	gob.RegisterName("Ints", Ints{})
+	data, err := os.ReadFile("testdata/input.gob")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	d := gob.NewDecoder(bytes.NewReader(data))
+	for i := 0; i <= len(data); i++ {
+		var n Node
+		err := d.Decode(&n)
+		if err == nil {
+			fmt.Printf("%+v\n", n)
+			continue
+		}
+		fmt.Println("Decode:", err)
+		if !strings.Contains(err.Error(), "type mismatch") {
+			break
+		}
+	}
+	d = gob.NewDecoder(bytes.NewReader(data))
+	for {
+		if err := d.DecodeValue(reflect.Value{}); err != nil {
+			fmt.Println("DecodeValue with no destination:", err)
+			break
+		}
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+
+const asn1UnmarshalMain = `package main
+
+import (
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+type Value struct {
+	N     int64
+	Big   *big.Int
+	B     bool
+	Bits  asn1.BitString
+	OID   asn1.ObjectIdentifier
+	Enum  asn1.Enumerated
+	UTF8  string    "asn1:\"utf8\""
+	IA5   string    "asn1:\"ia5\""
+	Print string    "asn1:\"printable\""
+	Num   string    "asn1:\"numeric\""
+	UTC   time.Time "asn1:\"utc\""
+	Gen   time.Time "asn1:\"generalized\""
+	Bytes []byte
+	Raw   asn1.RawValue
+	Set   []int   "asn1:\"set\""
+	Opt   int     "asn1:\"optional,explicit,tag:0\""
+	Def   int     "asn1:\"optional,default:7,tag:1\""
+	Kids  []Value "asn1:\"optional,explicit,tag:2\""
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/input.der")
+	if err != nil {
+		panic(err)
+	}
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(data, &raw)
+	fmt.Printf("RawValue: %+v, %d bytes left (%v)\n", raw, len(rest), err)
+	out, err := asn1.Marshal(asn1.RawValue{Class: raw.Class, Tag: raw.Tag, IsCompound: raw.IsCompound, Bytes: raw.Bytes})
+	fmt.Printf("marshaled again: %x (%v)\n", out, err)
+	for i := 0; i < 3; i++ {
+		var v Value
+		if _, err := asn1.Unmarshal(data, &v); err != nil {
+			fmt.Println("Unmarshal error:", err)
+			return
+		}
+		data, err = asn1.Marshal(v)
+		fmt.Printf("--- %+v\nmarshaled %d times (%v)\n%x\n", v, i+1, err, data)
+	}
+}
+`
+
+const x509ParseMain = `package main
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.der")
+	if err != nil {
+		panic(err)
+	}
+	c, err := x509.ParseCertificate(data)
+	certs, errs := x509.ParseCertificates(data)
+	fmt.Printf("ParseCertificate error: %v\nParseCertificates: %d certificates (%v)\n", err, len(certs), errs)
+	if err != nil {
+		return
+	}
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	template := *c
+	template.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	template.PublicKey = key.Public()
+	out, err := x509.CreateCertificate(nil, &template, &template, key.Public(), key)
+	if err != nil {
+		fmt.Println("CreateCertificate error:", err)
+		return
+	}
+	again, err := x509.ParseCertificate(out)
+	if err != nil {
+		fmt.Printf("the created certificate does not parse: %v\n%x\n", err, out)
+		return
+	}
+	for _, c := range []*x509.Certificate{c, again} {
+		fmt.Printf("---\nserial %v\nsubject %v\nissuer %v\nvalid %v to %v\nkey usage %v %v %v\nCA %v %v path %d %v\nnames %q %q %v %v\nconstraints %q %q %v %v\npolicies %v\n",
+			c.SerialNumber, c.Subject, c.Issuer, c.NotBefore, c.NotAfter, c.KeyUsage, c.ExtKeyUsage, c.UnknownExtKeyUsage,
+			c.BasicConstraintsValid, c.IsCA, c.MaxPathLen, c.MaxPathLenZero, c.DNSNames, c.EmailAddresses, c.IPAddresses, c.URIs,
+			c.PermittedDNSDomains, c.ExcludedDNSDomains, c.PermittedIPRanges, c.ExcludedIPRanges, c.Policies)
+	}
+}
+`
+
+// tlsMain runs the handshake of side, "Server" or "Client", against the
+// input the way fuzz/tls does, and prints its error and what it wrote.
+func tlsMain(side string) string {
+	return `package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+type conn struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (c *conn) Read(p []byte) (int, error)       { return c.in.Read(p) }
+func (c *conn) Write(p []byte) (int, error)      { return c.out.Write(p) }
+func (c *conn) Close() error                     { return nil }
+func (c *conn) LocalAddr() net.Addr              { return addr{} }
+func (c *conn) RemoteAddr() net.Addr             { return addr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+type addr struct{}
+
+func (addr) Network() string { return "pipe" }
+func (addr) String() string  { return "pipe" }
+
+func main() {
+	data, err := os.ReadFile("testdata/input.tls")
+	if err != nil {
+		panic(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	config := &tls.Config{
+		Certificates:       []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		ServerName:         "example.com",
+		InsecureSkipVerify: true,
+		Rand:               zeros{},
+		Time:               func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) },
+		MinVersion:         tls.VersionTLS10,
+		NextProtos:         []string{"h2", "http/1.1"},
+		ClientAuth:         tls.RequestClientCert,
+	}
+	c := &conn{in: bytes.NewReader(data)}
+	err = tls.` + side + `(c, config).Handshake()
+	fmt.Printf("Handshake error: %v\nread %d of %d bytes, wrote:\n%x\n", err, len(data)-c.in.Len(), len(data), c.out.Bytes())
+}
+`
+}
+
+// httpMain reads the input's messages of kind, "Request" or "Response",
+// the way fuzz/http does, and prints each with what it writes back as.
+func httpMain(kind string) string {
+	// ReadResponse takes the request a response answers as well.
+	args := ""
+	if kind == "Response" {
+		args = ", nil"
+	}
+	return `package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.http")
+	if err != nil {
+		panic(err)
+	}
+	br := bufio.NewReader(bytes.NewReader(data))
+	for range 16 {
+		m, err := http.Read` + kind + `(br` + args + `)
+		if err != nil {
+			fmt.Println("Read` + kind + ` error:", err)
+			return
+		}
+		body, err := io.ReadAll(m.Body)
+		fmt.Printf("--- %+v\nbody %q (%v)\n", m, body, err)
+		if err != nil {
+			return
+		}
+		m.Body = io.NopCloser(bytes.NewReader(body))
+		var out bytes.Buffer
+		err = m.Write(&out)
+		fmt.Printf("written as %q (%v)\n", out.Bytes(), err)
+		rb := bufio.NewReader(&out)
+		again, err := http.Read` + kind + `(rb` + args + `)
+		if err != nil {
+			fmt.Println("which does not read back:", err)
+		} else {
+			body, err := io.ReadAll(again.Body)
+			fmt.Printf("which reads back as %+v\nbody %q (%v), %d bytes left\n", again, body, err, rb.Buffered())
+		}
+		if m.Close {
+			return
+		}
+	}
+}
+`
+}
+
+const textprotoMain = `package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.http")
+	if err != nil {
+		panic(err)
+	}
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	line, err := r.ReadLine()
+	fmt.Printf("start line %q (%v)\n", line, err)
+	if err != nil {
+		return
+	}
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		fmt.Println("ReadMIMEHeader error:", err)
+		return
+	}
+	for k, vs := range h {
+		fmt.Printf("%q: %q\n", k, vs)
+	}
+}
+`
+
+const http2FramerMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.h2")
+	if err != nil {
+		panic(err)
+	}
+	rest, ok := bytes.CutPrefix(data, []byte(http2.ClientPreface))
+	if !ok {
+		fmt.Println("no client preface")
+		return
+	}
+	fr := http2.NewFramer(nil, bytes.NewReader(rest))
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	fr.MaxHeaderListSize = 16 << 10
+	for range 4096 {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			fmt.Printf("ReadFrame error: %v (%v)\n", err, fr.ErrorDetail())
+			if _, ok := err.(http2.StreamError); ok {
+				continue
+			}
+			return
+		}
+		fmt.Println(f)
+		if mh, ok := f.(*http2.MetaHeadersFrame); ok {
+			for _, hf := range mh.Fields {
+				fmt.Printf("\t%v\n", hf)
+			}
+			fmt.Println("\ttruncated:", mh.Truncated)
+		}
+	}
+}
+`
+
+const hpackMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/http2/hpack"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.hpack")
+	if err != nil {
+		panic(err)
+	}
+	d := hpack.NewDecoder(4096, nil)
+	d.SetMaxStringLength(16 << 10)
+	fields, err := d.DecodeFull(data)
+	if err != nil {
+		fmt.Println("DecodeFull error:", err)
+		return
+	}
+	var out bytes.Buffer
+	enc := hpack.NewEncoder(&out)
+	for _, f := range fields {
+		fmt.Println(f)
+		if err := enc.WriteField(f); err != nil {
+			fmt.Println("WriteField error:", err)
+		}
+	}
+	fmt.Printf("encoded again as %x\n", out.Bytes())
+	again, err := hpack.NewDecoder(4096, nil).DecodeFull(out.Bytes())
+	fmt.Printf("which decodes to %v (%v)\n", again, err)
+}
+`
+
+// quicMain drives a crypto/tls QUIC server with the CRYPTO data of the
+// input's Initial and Handshake packets the way fuzz/quic does, printing
+// its events; with params, the input is a transport parameter blob, whose
+// integers it prints as quicvarint reads them before it sends the blob in
+// a ClientHello.
+func quicMain(params bool) string {
+	input, parse := "input.quic", ""
+	if params {
+		input = "input.qtp"
+		parse = `
+	read := func(b []byte) (uint64, []byte, bool) {
+		v, n, err := quicvarint.Parse(b)
+		r, rerr := quicvarint.Read(bytes.NewReader(b))
+		fmt.Printf("\tParse %x: %d in %d bytes (%v); Read: %d (%v)\n", b[:min(len(b), 8)], v, n, err, r, rerr)
+		if err != nil {
+			return 0, nil, false
+		}
+		fmt.Printf("\t\twritten back in %d bytes: %x\n", n, quicvarint.AppendWithLen(nil, v, n))
+		return v, b[n:], true
+	}
+	for b := data; len(b) > 0; {
+		fmt.Println("parameter:")
+		_, rest, ok := read(b)
+		if !ok {
+			break
+		}
+		n, rest, ok := read(rest)
+		if !ok || n > uint64(len(rest)) {
+			break
+		}
+		read(rest[:n])
+		b = rest[n:]
+	}
+	data = packet(hello(data))
+`
+	}
+	return `package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// hello returns the ClientHello fuzz/quic sends params in.
+func hello(params []byte) []byte {
+	ext := func(typ int, body []byte) []byte {
+		return slices.Concat([]byte{byte(typ >> 8), byte(typ), byte(len(body) >> 8), byte(len(body))}, body)
+	}
+	share := make([]byte, 32)
+	share[0] = 9
+	exts := slices.Concat(
+		ext(43, []byte{2, 3, 4}),
+		ext(10, []byte{0, 2, 0, 0x1d}),
+		ext(51, slices.Concat([]byte{0, 36, 0, 0x1d, 0, 32}, share)),
+		ext(13, []byte{0, 2, 4, 3}),
+		ext(16, []byte{0, 3, 2, 'h', '3'}),
+		ext(0x39, params),
+	)
+	body := slices.Concat([]byte{3, 3}, make([]byte, 32), []byte{0, 0, 2, 0x13, 0x01, 1, 0, byte(len(exts) >> 8), byte(len(exts))}, exts)
+	return slices.Concat([]byte{1, byte(len(body) >> 16), byte(len(body) >> 8), byte(len(body))}, body)
+}
+
+// packet returns an Initial packet, unprotected, with hello in a CRYPTO
+// frame.
+func packet(hello []byte) []byte {
+	payload := slices.Concat([]byte{0x06, 0x00}, quicvarint.Append(nil, uint64(len(hello))), hello)
+	b := slices.Concat([]byte{0xc0, 0, 0, 0, 1, 8}, make([]byte, 8), []byte{8}, make([]byte, 8), []byte{0})
+	b = quicvarint.Append(b, uint64(1+len(payload)))
+	return slices.Concat(b, []byte{0}, payload)
+}
+
+func varint(b []byte) (uint64, []byte, bool) {
+	v, n, err := quicvarint.Parse(b)
+	if err != nil {
+		return 0, nil, false
+	}
+	return v, b[n:], true
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/` + input + `")
+	if err != nil {
+		panic(err)
+	}` + parse + `
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	q := tls.QUICServer(&tls.QUICConfig{TLSConfig: &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+		Rand:         zeros{},
+		Time:         func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) },
+		MinVersion:   tls.VersionTLS13,
+		NextProtos:   []string{"h3"},
+	}})
+	defer q.Close()
+	if err := q.Start(context.Background()); err != nil {
+		panic(err)
+	}
+	events := func() {
+		for e := q.NextEvent(); e.Kind != tls.QUICNoEvent; e = q.NextEvent() {
+			fmt.Printf("\tevent %d at %v, suite %#04x: %x\n", e.Kind, e.Level, e.Suite, e.Data)
+			if e.Kind == tls.QUICTransportParametersRequired {
+				q.SetTransportParameters([]byte{0x04, 0x04, 0x80, 0x10, 0x00, 0x00})
+			}
+		}
+	}
+
+	// The CRYPTO data of the Initial and Handshake levels, and how much of
+	// each has been handed on.
+	var streams [4][]byte
+	var have [4][]bool
+	var done [4]int
+	var dcid, scid []byte
+	for len(data) >= 5 && data[0]&0xc0 == 0xc0 && data[1] == 0 && data[2] == 0 && data[3] == 0 && data[4] == 1 {
+		typ, pnLen := data[0]>>4&3, int(data[0]&3)+1
+		if typ == 3 || data[0]&0x0c != 0 {
+			fmt.Println("a Retry packet or reserved bits")
+			break
+		}
+		b, ids := data[5:], [2][]byte{}
+		for i := range ids {
+			if len(b) < 1 || b[0] > 20 || int(b[0]) >= len(b) {
+				return
+			}
+			ids[i], b = b[1:1+b[0]], b[1+b[0]:]
+		}
+		if typ == 0 {
+			n, rest, ok := varint(b)
+			if !ok || n > uint64(len(rest)) {
+				return
+			}
+			b = rest[n:]
+		}
+		n, b, ok := varint(b)
+		if !ok || n > uint64(len(b)) || n <= uint64(pnLen) {
+			fmt.Println("a packet of bad length")
+			return
+		}
+		payload := b[pnLen:n]
+		data = b[n:]
+		fmt.Printf("packet of type %d from %x to %x: %x\n", typ, ids[1], ids[0], payload)
+		if dcid == nil {
+			if typ != 0 || len(ids[0]) < 8 {
+				continue
+			}
+			dcid, scid = ids[0], ids[1]
+		}
+		if !bytes.Equal(ids[0], dcid) || !bytes.Equal(ids[1], scid) || typ == 1 {
+			continue
+		}
+		level := int(typ)
+		for len(payload) > 0 {
+			ft, rest, ok := varint(payload)
+			if !ok || quicvarint.Len(ft) != len(payload)-len(rest) {
+				return
+			}
+			payload = rest
+			if ft == 0 || ft == 1 {
+				continue
+			}
+			if ft != 6 {
+				fmt.Printf("frame of type %#x\n", ft)
+				return
+			}
+			off, rest, ok := varint(payload)
+			if !ok {
+				return
+			}
+			n, rest, ok := varint(rest)
+			if !ok || n > uint64(len(rest)) || off+n > 16<<10 {
+				fmt.Println("a CRYPTO frame too long or too far out")
+				return
+			}
+			if end := int(off + n); end > len(streams[level]) {
+				streams[level] = append(streams[level], make([]byte, end-len(streams[level]))...)
+				have[level] = append(have[level], make([]bool, end-len(have[level]))...)
+			}
+			for i, c := range rest[:n] {
+				if !have[level][int(off)+i] {
+					streams[level][int(off)+i], have[level][int(off)+i] = c, true
+				}
+			}
+			payload = rest[n:]
+		}
+		for l := range streams {
+			end := done[l]
+			for end < len(have[l]) && have[l][end] {
+				end++
+			}
+			if end == done[l] {
+				continue
+			}
+			fmt.Printf("HandleData(%v, %x)\n", tls.QUICEncryptionLevel(l), streams[l][done[l]:end])
+			if err := q.HandleData(tls.QUICEncryptionLevel(l), streams[l][done[l]:end]); err != nil {
+				fmt.Println("HandleData error:", err)
+				return
+			}
+			done[l] = end
+			events()
+		}
+	}
+}
+`
+}
+
+const dnsMessageMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.dns")
+	if err != nil {
+		panic(err)
+	}
+	var m dnsmessage.Message
+	if err := m.Unpack(data); err != nil {
+		fmt.Println("Unpack:", err)
+		return
+	}
+	fmt.Printf("%+v\n", m)
+	var p dnsmessage.Parser
+	_, err = p.Start(data)
+	for _, skip := range []func() error{p.SkipAllQuestions, p.SkipAllAnswers, p.SkipAllAuthorities, p.SkipAllAdditionals} {
+		if err == nil {
+			err = skip()
+		}
+	}
+	fmt.Println("skipping:", err)
+	b, err := m.Pack()
+	if err != nil {
+		fmt.Println("Pack:", err)
+		return
+	}
+	fmt.Printf("packed: %x\n", b)
+	var again dnsmessage.Message
+	if err := again.Unpack(b); err != nil {
+		fmt.Println("Unpack of what was packed:", err)
+		return
+	}
+	fmt.Printf("%+v\n", again)
+}
+`
+
+const miekgMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/miekg/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.dns")
+	if err != nil {
+		panic(err)
+	}
+	m := new(dns.Msg)
+	if err := m.Unpack(data); err != nil {
+		fmt.Println("Unpack:", err)
+		return
+	}
+	fmt.Println(m)
+	for _, compress := range []bool{false, true} {
+		m.Compress = compress
+		b, err := m.Pack()
+		if err != nil {
+			fmt.Printf("Pack (compressed: %v): %v\n", compress, err)
+			continue
+		}
+		fmt.Printf("packed (compressed: %v) in %d bytes, Len %d: %x\n", compress, len(b), m.Len(), b)
+		again := new(dns.Msg)
+		if err := again.Unpack(b); err != nil {
+			fmt.Println("Unpack of what was packed:", err)
+			continue
+		}
+		fmt.Println(again)
+	}
+	var xm dnsmessage.Message
+	if err := xm.Unpack(data); err != nil {
+		fmt.Println("dnsmessage Unpack:", err)
+		return
+	}
+	fmt.Printf("dnsmessage: %+v\n", xm)
+}
+`
+
+// wsMain reads the input's frames with gorilla/websocket, coder/websocket
+// and gobwas/ws the way fuzz/websocket does, as a server reads a client's
+// if server is set and as a client reads a server's if not, and prints
+// the messages each reads and the error it stops at.
+func wsMain(server bool) string {
+	side := "false"
+	if server {
+		side = "true"
+	}
+	return `package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	coder "github.com/coder/websocket"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	gorilla "github.com/gorilla/websocket"
+)
+
+const server = ` + side + `
+
+const handshake = "GET /chat HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+	"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"
+
+func request() *http.Request {
+	r, err := http.ReadRequest(bufio.NewReader(strings.NewReader(handshake)))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func response(key string) http.Header {
+	h := sha1.Sum([]byte(key + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return http.Header{
+		"Upgrade":              {"websocket"},
+		"Connection":           {"Upgrade"},
+		"Sec-Websocket-Accept": {base64.StdEncoding.EncodeToString(h[:])},
+	}
+}
+
+// conn reads data; if answer is set, its first read answers the
+// handshake written to it first.
+type conn struct {
+	data   []byte
+	answer bool
+	r      io.Reader
+	req    bytes.Buffer
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	if c.r == nil {
+		c.r = bytes.NewReader(c.data)
+		if c.answer {
+			r, err := http.ReadRequest(bufio.NewReader(&c.req))
+			if err != nil {
+				return 0, err
+			}
+			var head bytes.Buffer
+			head.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+			response(r.Header.Get("Sec-WebSocket-Key")).Write(&head)
+			head.WriteString("\r\n")
+			c.r = io.MultiReader(&head, c.r)
+		}
+	}
+	return c.r.Read(p)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	if c.answer && c.r == nil {
+		c.req.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *conn) Close() error                     { return nil }
+func (c *conn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *conn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+type hijacker struct {
+	c      *conn
+	header http.Header
+}
+
+func (w *hijacker) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *hijacker) Write(p []byte) (int, error) { return len(p), nil }
+func (w *hijacker) WriteHeader(int)             {}
+
+func (w *hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.c, bufio.NewReadWriter(bufio.NewReader(w.c), bufio.NewWriter(w.c)), nil
+}
+
+type upgrade []byte
+
+func (u upgrade) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "101 Switching Protocols",
+		StatusCode: http.StatusSwitchingProtocols,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     response(r.Header.Get("Sec-WebSocket-Key")),
+		Body:       &conn{data: u},
+		Request:    r,
+	}, nil
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/input.ws")
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println("gorilla/websocket:")
+	c := &conn{data: data}
+	var gc *gorilla.Conn
+	if server {
+		u := gorilla.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+		gc, err = u.Upgrade(&hijacker{c: c}, request(), nil)
+	} else {
+		c.answer = true
+		d := gorilla.Dialer{NetDialContext: func(context.Context, string, string) (net.Conn, error) { return c, nil }}
+		gc, _, err = d.Dial("ws://example.com/chat", nil)
+	}
+	if err != nil {
+		panic(err)
+	}
+	gc.SetReadLimit(1 << 20)
+	for {
+		typ, p, err := gc.ReadMessage()
+		if err != nil {
+			fmt.Println("\t", err)
+			break
+		}
+		fmt.Printf("\t%d %q\n", typ, p)
+	}
+
+	fmt.Println("coder/websocket:")
+	ctx := context.Background()
+	var cc *coder.Conn
+	if server {
+		cc, err = coder.Accept(&hijacker{c: &conn{data: data}}, request(), &coder.AcceptOptions{InsecureSkipVerify: true})
+	} else {
+		client := &http.Client{Transport: upgrade(data)}
+		cc, _, err = coder.Dial(ctx, "ws://example.com/chat", &coder.DialOptions{HTTPClient: client})
+	}
+	if err != nil {
+		panic(err)
+	}
+	cc.SetReadLimit(1 << 20)
+	for {
+		typ, p, err := cc.Read(ctx)
+		if err != nil {
+			fmt.Println("\t", err)
+			break
+		}
+		fmt.Printf("\t%v %q\n", typ, p)
+	}
+	cc.CloseNow()
+
+	fmt.Println("gobwas/ws:")
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(data), io.Discard}
+	for {
+		var p []byte
+		var op ws.OpCode
+		if server {
+			p, op, err = wsutil.ReadClientData(rw)
+		} else {
+			p, op, err = wsutil.ReadServerData(rw)
+		}
+		if err != nil {
+			fmt.Println("\t", err)
+			break
+		}
+		fmt.Printf("\t%v %q\n", op, p)
+	}
+}
+`
+}
+
+const urlMain = `package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.url")
+	if err != nil {
+		panic(err)
+	}
+	u, err := url.Parse(string(s))
+	if err != nil {
+		fmt.Println("Parse:", err)
+	} else {
+		fmt.Printf("Parse: %#v\n", u)
+		fmt.Printf("String: %q\n", u.String())
+		again, err := url.Parse(u.String())
+		fmt.Printf("Parse of String: %#v, %v\n", again, err)
+		fmt.Printf("EscapedPath %q, EscapedFragment %q, Hostname %q, Port %q\n", u.EscapedPath(), u.EscapedFragment(), u.Hostname(), u.Port())
+		q, err := url.ParseQuery(u.RawQuery)
+		fmt.Printf("ParseQuery: %v, %v; Encode: %q\n", q, err, q.Encode())
+	}
+	r, err := url.ParseRequestURI(string(s))
+	fmt.Printf("ParseRequestURI: %#v, %v\n", r, err)
+}
+`
+
+const timeParseMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	layout, err := os.ReadFile("testdata/layout.txt")
+	if err != nil {
+		panic(err)
+	}
+	value, err := os.ReadFile("testdata/value.txt")
+	if err != nil {
+		panic(err)
+	}
+	t, err := time.ParseInLocation(string(layout), string(value), time.UTC)
+	if err != nil {
+		fmt.Println("ParseInLocation:", err)
+		return
+	}
+	fmt.Println("parsed:", t)
+	formatted := t.Format(string(layout))
+	fmt.Printf("formatted: %q\n", formatted)
+	again, err := time.ParseInLocation(string(layout), formatted, time.UTC)
+	fmt.Printf("parsed again: %v, %v; formatted: %q\n", again, err, again.Format(string(layout)))
+	text, err := t.MarshalText()
+	fmt.Printf("MarshalText: %s, %v\n", text, err)
+	var u time.Time
+	err = u.UnmarshalText(text)
+	fmt.Printf("UnmarshalText: %v, %v; Equal: %v\n", u, err, u.Equal(t))
+}
+`
+
+const durationMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.dur")
+	if err != nil {
+		panic(err)
+	}
+	d, err := time.ParseDuration(string(s))
+	if err != nil {
+		fmt.Println("ParseDuration:", err)
+		return
+	}
+	fmt.Printf("%d ns, printed as %q\n", int64(d), d.String())
+	again, err := time.ParseDuration(d.String())
+	fmt.Printf("parsed again: %d ns, %v\n", int64(again), err)
+}
+`
+
+const zipMain = `package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.zip")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		fmt.Println("NewReader:", err)
+		return
+	}
+	fmt.Printf("%d entries, comment %q\n", len(r.File), r.Comment)
+	left := int64(16 << 20)
+	for _, f := range r.File {
+		offset, err := f.DataOffset()
+		fmt.Printf("%q: method %d, flags %#x, sizes %d/%d, crc %08x, data at %d (%v)\n",
+			f.Name, f.Method, f.Flags, f.CompressedSize64, f.UncompressedSize64, f.CRC32, offset, err)
+		rc, err := f.Open()
+		if err != nil {
+			fmt.Println("\tOpen:", err)
+			continue
+		}
+		b, err := io.ReadAll(io.LimitReader(rc, left+1))
+		rc.Close()
+		left -= int64(len(b))
+		fmt.Printf("\tread %d bytes, crc %08x: %v\n", len(b), crc32.ChecksumIEEE(b), err)
+		if left < 0 {
+			fmt.Println("\tstopped: 16 MiB extracted")
+			break
+		}
+	}
+	err = fs.WalkDir(r, ".", func(name string, d fs.DirEntry, err error) error {
+		fmt.Printf("fs: %q %v\n", name, err)
+		return err
+	})
+	fmt.Println("WalkDir:", err)
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+
+const tarMain = `package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.tar")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	tr := tar.NewReader(bytes.NewReader(data))
+	left := int64(16 << 20)
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			fmt.Println("Next:", err)
+			break
+		}
+		fmt.Printf("%+v\n", *h)
+		if left <= 0 {
+			continue
+		}
+		n, err := io.Copy(io.Discard, io.LimitReader(tr, left+1))
+		left -= n
+		fmt.Printf("\tread %d bytes: %v\n", n, err)
+		if left < 0 {
+			fmt.Println("\tstopped: 16 MiB read")
+		}
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+
+// compressMain returns main.go for a decompressor of package pkg, which
+// open, an expression of r, gives with an error.
+func compressMain(pkg, open, file string) string {
+	return `package main
+
+import (
+	"bytes"
+	"` + pkg + `"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/` + file + `")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	r := bytes.NewReader(data)
+	zr, err := ` + open + `
+	if err != nil {
+		fmt.Println("open:", err)
+		return
+	}
+	n, err := io.Copy(io.Discard, io.LimitReader(zr, 16<<20+1))
+	fmt.Printf("read %d bytes: %v\n", n, err)
+	if n > 16<<20 {
+		fmt.Println("stopped: 16 MiB read")
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+}
+
+// imageMain returns main.go for the decoder of package pkg, named name,
+// which decodes the configuration of a file, then its pixels unless
+// there are more than 4 megapixels of them.
+func imageMain(pkg, name, file string) string {
+	return `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"` + pkg + `"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/` + file + `")
+	if err != nil {
+		panic(err)
+	}
+	c, err := ` + name + `.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println("DecodeConfig:", err)
+		return
+	}
+	fmt.Printf("config: %dx%d\n", c.Width, c.Height)
+	if c.Width > 1<<22 || c.Height > 1<<22 || c.Width*c.Height > 1<<22 {
+		fmt.Println("stopped: more than 4 megapixels")
+		return
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	img, err := ` + name + `.Decode(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println("Decode:", err)
+	} else {
+		r := img.Bounds()
+		fmt.Printf("decoded a %T of %v\n", img, r)
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.At(x, y).RGBA()
+			}
+		}
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+}
+
+// debugMain returns main.go for package debug/name, which opens a file,
+// or each file of a fat file if fat is set, then reads up to 16 MiB of
+// each section, and the file's imports and DWARF.
+func debugMain(name, file string, fat bool) string {
+	open := `	f, err := ` + name + `.NewFile(bytes.NewReader(data))
+	if err != nil {
+		fmt.Println("NewFile:", err)
+		return
+	}
+	files := []*` + name + `.File{f}
+`
+	if fat {
+		open = `	var files []*macho.File
+	if f, err := macho.NewFile(bytes.NewReader(data)); err == nil {
+		files = append(files, f)
+	} else if ff, ferr := macho.NewFatFile(bytes.NewReader(data)); ferr == nil {
+		for _, a := range ff.Arches {
+			files = append(files, a.File)
+		}
+	} else {
+		fmt.Println("NewFile:", err, "NewFatFile:", ferr)
+		return
+	}
+`
+	}
+	return `package main
+
+import (
+	"bytes"
+	"debug/` + name + `"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/` + file + `")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+` + open + `	for _, f := range files {
+		for _, s := range f.Sections {
+			n, err := io.Copy(io.Discard, io.LimitReader(s.Open(), 16<<20))
+			fmt.Printf("section %q: read %d bytes: %v\n", s.Name, n, err)
+		}
+		syms, err := f.ImportedSymbols()
+		fmt.Printf("imported symbols: %q: %v\n", syms, err)
+		libs, err := f.ImportedLibraries()
+		fmt.Printf("imported libraries: %q: %v\n", libs, err)
+		_, err = f.DWARF()
+		fmt.Println("DWARF:", err)
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+}
+
+const dwarfMain = `package main
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	var secs [5][]byte
+	for i, name := range []string{"abbrev", "info", "line", "ranges", "str"} {
+		b, err := os.ReadFile("testdata/" + name + ".dwarf")
+		if err != nil {
+			panic(err)
+		}
+		secs[i] = b
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	d, err := dwarf.New(secs[0], nil, nil, secs[1], secs[2], nil, secs[3], secs[4])
+	if err != nil {
+		fmt.Println("New:", err)
+		return
+	}
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			fmt.Println("Next:", err)
+			break
+		}
+		fmt.Printf("%#x: %v %v\n", e.Offset, e.Tag, e.Field)
+		if e.Tag == dwarf.TagCompileUnit {
+			lr, err := d.LineReader(e)
+			fmt.Println("LineReader:", err)
+			for lr != nil {
+				var row dwarf.LineEntry
+				if err := lr.Next(&row); err != nil {
+					fmt.Println("line:", err)
+					break
+				}
+				fmt.Printf("line: %#x %d:%d\n", row.Address, row.Line, row.Column)
+			}
+			ranges, err := d.Ranges(e)
+			fmt.Println("Ranges:", ranges, err)
+		}
+		if off, ok := e.Val(dwarf.AttrType).(dwarf.Offset); ok {
+			t, err := d.Type(off)
+			fmt.Println("Type:", t, err)
+		}
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("took %v and allocated %d MiB\n", time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+
+const wasmMain = `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.wasm")
+	if err != nil {
+		panic(err)
+	}
+	ctx := context.Background()
+	for _, engine := range []struct {
+		name   string
+		config wazero.RuntimeConfig
+	}{
+		{"interpreter", wazero.NewRuntimeConfigInterpreter()},
+		{"compiler", wazero.NewRuntimeConfig()},
+	} {
+		fmt.Println("---", engine.name)
+		r := wazero.NewRuntimeWithConfig(ctx, engine.config.WithCloseOnContextDone(true).WithMemoryLimitPages(256))
+		c, err := r.CompileModule(ctx, data)
+		if err != nil {
+			fmt.Println("CompileModule:", err)
+			continue
+		}
+		call, cancel := context.WithTimeout(ctx, time.Second)
+		mod, err := r.InstantiateModule(call, c, wazero.NewModuleConfig().WithName(""))
+		cancel()
+		if err != nil {
+			fmt.Println("InstantiateModule:", err)
+			continue
+		}
+		defs := c.ExportedFunctions()
+		var names []string
+		for name := range defs {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			args := make([]uint64, len(defs[name].ParamTypes()))
+			call, cancel := context.WithTimeout(ctx, time.Second)
+			results, err := mod.ExportedFunction(name).Call(call, args...)
+			cancel()
+			fmt.Printf("%s: %v %v\n", name, results, err)
+		}
+		for name := range c.ExportedMemories() {
+			m := mod.ExportedMemory(name)
+			b, _ := m.Read(0, m.Size())
+			fmt.Printf("memory %s: %d bytes, %x\n", name, len(b), b[:min(len(b), 256)])
+		}
+		r.Close(ctx)
+	}
+}
+`
+
+const protoMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func main() {
+	schema, err := os.ReadFile("testdata/schema.desc")
+	if err != nil {
+		panic(err)
+	}
+	data, err := os.ReadFile("testdata/input.pb")
+	if err != nil {
+		panic(err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(schema, &set); err != nil || len(set.File) == 0 {
+		fmt.Println("schema:", err)
+		return
+	}
+	fmt.Println(prototext.Format(&set))
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		fmt.Println("NewFiles:", err)
+		return
+	}
+	fd, err := files.FindFileByPath(set.File[0].GetName())
+	if err != nil || fd.Messages().Len() == 0 {
+		fmt.Println("FindFileByPath:", err)
+		return
+	}
+	md := fd.Messages().Get(0)
+	m := dynamicpb.NewMessage(md)
+	err = proto.UnmarshalOptions{AllowPartial: true}.Unmarshal(data, m)
+	fmt.Println("Unmarshal error:", err)
+	if err != nil {
+		return
+	}
+	fmt.Println("strict Unmarshal error:", proto.Unmarshal(data, dynamicpb.NewMessage(md)))
+	fmt.Println("CheckInitialized:", proto.CheckInitialized(m))
+	fmt.Println(prototext.Format(m))
+	b, err := proto.MarshalOptions{AllowPartial: true, Deterministic: true}.Marshal(m)
+	fmt.Printf("Marshal: %x %v (Size %d)\n", b, err, proto.Size(m))
+	j, err := protojson.MarshalOptions{AllowPartial: true}.Marshal(m)
+	fmt.Printf("JSON: %s %v\n", j, err)
+}
+`
+
+const yamlMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.yaml")
+	if err != nil {
+		panic(err)
+	}
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		var n yaml.Node
+		if err := d.Decode(&n); err != nil {
+			fmt.Printf("document %d: %v\n", i, err)
+			return
+		}
+		var v any
+		err := n.Decode(&v)
+		fmt.Printf("document %d: %#v (%v)\n", i, v, err)
+		if err != nil {
+			continue
+		}
+		out, err := yaml.Marshal(v)
+		fmt.Printf("Marshal of the value: %v\n%s", err, out)
+		out, err = yaml.Marshal(&n)
+		fmt.Printf("Marshal of the Node: %v\n%s", err, out)
+	}
+}
+`
+
+const tomlMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	gotoml "github.com/pelletier/go-toml/v2"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.toml")
+	if err != nil {
+		panic(err)
+	}
+	var a map[string]any
+	_, err = toml.Decode(string(data), &a)
+	fmt.Printf("BurntSushi/toml: %#v (%v)\n", a, err)
+	if err == nil {
+		var b strings.Builder
+		err := toml.NewEncoder(&b).Encode(a)
+		fmt.Printf("Encode: %v\n%s", err, b.String())
+	}
+	var g map[string]any
+	err = gotoml.Unmarshal(data, &g)
+	fmt.Printf("go-toml: %#v (%v)\n", g, err)
+	if err == nil {
+		out, err := gotoml.Marshal(g)
+		fmt.Printf("Marshal: %v\n%s", err, out)
+	}
+}
+`
+
+const csvMain = `package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.csv")
+	if err != nil {
+		panic(err)
+	}
+	for _, lazy := range []bool{false, true} {
+		for _, fields := range []int{-1, 0} {
+			fmt.Printf("LazyQuotes %v, FieldsPerRecord %d:\n", lazy, fields)
+			var m runtime.MemStats
+			runtime.ReadMemStats(&m)
+			before := m.TotalAlloc
+			start := time.Now()
+			r := csv.NewReader(bytes.NewReader(data))
+			r.LazyQuotes = lazy
+			r.FieldsPerRecord = fields
+			var records [][]string
+			for {
+				record, err := r.Read()
+				if err == io.EOF {
+					break
+				}
+				if record != nil {
+					records = append(records, record)
+					line, col := r.FieldPos(0)
+					fmt.Printf("\t%q at %d:%d, offset %d (%v)\n", record, line, col, r.InputOffset(), err)
+				}
+				if err != nil && record == nil {
+					fmt.Println("\tRead:", err)
+					break
+				}
+			}
+			runtime.ReadMemStats(&m)
+			fmt.Printf("\t%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+			var out bytes.Buffer
+			w := csv.NewWriter(&out)
+			err := w.WriteAll(records)
+			fmt.Printf("\tWriteAll: %v\n\t%q\n", err, out.Bytes())
+		}
+	}
+}
+`
+
+const markdownMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/russross/blackfriday/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.md")
+	if err != nil {
+		panic(err)
+	}
+	md := goldmark.New(goldmark.WithExtensions(extension.GFM, extension.Footnote, extension.DefinitionList, extension.Typographer))
+	measure("goldmark", len(data), func() []byte {
+		var out bytes.Buffer
+		if err := md.Convert(data, &out); err != nil {
+			fmt.Println("Convert:", err)
+		}
+		return out.Bytes()
+	})
+	r := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags | blackfriday.SkipHTML | blackfriday.Safelink,
+	})
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	measure("blackfriday", len(data), func() []byte {
+		return blackfriday.Run(data, blackfriday.WithRenderer(r), blackfriday.WithExtensions(blackfriday.CommonExtensions))
+	})
+}
+
+func measure(name string, n int, render func() []byte) {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	out := render()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%s: %d bytes as %d took %v and allocated %d MiB\n%s\n", name, n, len(out), elapsed, (m.TotalAlloc-before)>>20, out)
+}
+`
+
+const sqlMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/lexbase"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/parser"
+	_ "github.com/cockroachdb/cockroachdb-parser/pkg/sql/plpgsql/parser"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
+	"github.com/xwb1989/sqlparser"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.sql")
+	if err != nil {
+		panic(err)
+	}
+	script := string(data)
+
+	fmt.Println("sqlparser:")
+	pieces, err := sqlparser.SplitStatementToPieces(script)
+	if err != nil {
+		fmt.Println("SplitStatementToPieces:", err)
+	}
+	for _, text := range pieces {
+		fmt.Printf("%q\n", text)
+		stmt, err := sqlparser.ParseStrictDDL(text)
+		if err != nil {
+			fmt.Println("  Parse:", err)
+			continue
+		}
+		out := sqlparser.String(stmt)
+		fmt.Printf("  formats as %q\n", out)
+		if again, err := sqlparser.ParseStrictDDL(out); err != nil {
+			fmt.Println("  which does not parse:", err)
+		} else {
+			fmt.Printf("  which formats as %q\n", sqlparser.String(again))
+		}
+	}
+	fmt.Printf("  quoted: %s and %s\n", sqlparser.String(sqlparser.NewStrVal(data)), sqlparser.String(sqlparser.NewColIdent(script)))
+
+	fmt.Println("cockroachdb-parser:")
+	for rest := script; rest != ""; {
+		text := rest
+		if n, ok := parser.SplitFirstStatement(rest); ok {
+			text = rest[:n]
+		}
+		rest = rest[len(text):]
+		fmt.Printf("%q\n", text)
+		stmt, err := parser.ParseOne(text)
+		if err != nil {
+			fmt.Println("  Parse:", err)
+			continue
+		}
+		out := tree.AsStringWithFlags(stmt.AST, tree.FmtParsable)
+		fmt.Printf("  formats as %q\n", out)
+		if again, err := parser.ParseOne(out); err != nil {
+			fmt.Println("  which does not parse:", err)
+		} else {
+			fmt.Printf("  which formats as %q\n", tree.AsStringWithFlags(again.AST, tree.FmtParsable))
+		}
+	}
+	fmt.Printf("  quoted: %s and %s\n", lexbase.EscapeSQLString(script), lexbase.EscapeSQLIdent(script))
+}
+`
+
+const jsMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/dop251/goja"
+	gojaparser "github.com/dop251/goja/parser"
+	"github.com/evanw/esbuild/pkg/api"
+	ottoparser "github.com/robertkrimen/otto/parser"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.js")
+	if err != nil {
+		panic(err)
+	}
+	src := string(data)
+	_, err = gojaparser.ParseFile(nil, "input.js", src, 0)
+	fmt.Println("goja parser:", err)
+	_, err = ottoparser.ParseFile(nil, "input.js", src, 0)
+	fmt.Println("otto parser:", err)
+
+	out := transform("printed", src, api.TransformOptions{Loader: api.LoaderJS})
+	out = transform("printed again", out, api.TransformOptions{Loader: api.LoaderJS})
+	transform("and again", out, api.TransformOptions{Loader: api.LoaderJS})
+	minified := transform("minified", src, api.TransformOptions{Loader: api.LoaderJS, MinifyWhitespace: true, MinifySyntax: true, MinifyIdentifiers: true})
+	transform("minified, printed", minified, api.TransformOptions{Loader: api.LoaderJS})
+	low := transform("lowered", src, api.TransformOptions{
+		Loader:      api.LoaderJS,
+		Target:      api.ES2017,
+		Format:      api.FormatIIFE,
+		TreeShaking: api.TreeShakingFalse,
+		Supported:   map[string]bool{"bigint": true},
+	})
+
+	prog, err := goja.Compile("input.js", low, false)
+	if err != nil {
+		fmt.Println("goja Compile:", err)
+		return
+	}
+	vm := goja.New()
+	vm.SetMaxCallStackSize(1000)
+	time.AfterFunc(time.Second, func() { vm.Interrupt("timeout") })
+	v, err := vm.RunProgram(prog)
+	fmt.Println("goja RunProgram:", v, err)
+}
+
+func transform(what, src string, opts api.TransformOptions) string {
+	r := api.Transform(src, opts)
+	for _, e := range r.Errors {
+		if e.Location != nil {
+			fmt.Printf("%s: %s at %d:%d: %q\n", what, e.Text, e.Location.Line, e.Location.Column, e.Location.LineText)
+		} else {
+			fmt.Printf("%s: %s\n", what, e.Text)
+		}
+	}
+	fmt.Printf("%s:\n%s\n", what, r.Code)
+	return string(r.Code)
+}
+`
+
+const pyMain = `package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/go-python/gpython/compile"
+	"github.com/go-python/gpython/py"
+	sitter "github.com/smacker/go-tree-sitter"
+	tspython "github.com/smacker/go-tree-sitter/python"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.py")
+	if err != nil {
+		panic(err)
+	}
+	_, err = compile.Compile(string(src), "input.py", py.ExecMode, 0, true)
+	fmt.Println("gpython Compile:", err)
+
+	p := sitter.NewParser()
+	p.SetLanguage(tspython.GetLanguage())
+	tree, err := p.ParseCtx(context.Background(), nil, src)
+	if err != nil {
+		panic(err)
+	}
+	root := tree.RootNode()
+	fmt.Println("tree-sitter:", root)
+	fmt.Println("tree-sitter HasError:", root.HasError())
+
+	// Change the byte in the middle, as CheckParse does, and parse the
+	// file incrementally and from scratch.
+	i := len(src) / 2
+	for i < len(src) && (src[i] == '\n' || src[i] >= 0x80) {
+		i++
+	}
+	if i >= len(src) {
+		return
+	}
+	edited := bytes.Clone(src)
+	edited[i] = '('
+	row := bytes.Count(src[:i], []byte("\n"))
+	col := i - (bytes.LastIndexByte(src[:i], '\n') + 1)
+	start := sitter.Point{Row: uint32(row), Column: uint32(col)}
+	end := sitter.Point{Row: uint32(row), Column: uint32(col + 1)}
+	tree.Edit(sitter.EditInput{StartIndex: uint32(i), OldEndIndex: uint32(i + 1), NewEndIndex: uint32(i + 1), StartPoint: start, OldEndPoint: end, NewEndPoint: end})
+	inc, err := p.ParseCtx(context.Background(), tree, edited)
+	if err != nil {
+		panic(err)
+	}
+	fresh, err := p.ParseCtx(context.Background(), nil, edited)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("byte %d changed to '(', incremental:\n%s\nfresh:\n%s\n", i, inc.RootNode(), fresh.RootNode())
+}
+`
+
+const cMain = `package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"testing/fstest"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	tsc "github.com/smacker/go-tree-sitter/c"
+	tscpp "github.com/smacker/go-tree-sitter/cpp"
+	"modernc.org/cc/v4"
+)
+
+// predefined are the macros CheckFile predefines in place of those of the
+// host's C compiler.
+const predefined = "#define __STDC__ 1\n#define __STDC_VERSION__ 201710L\n#define __STDC_HOSTED__ 1\n#define __GNUC__ 12\n#define __linux__ 1\n#define __x86_64__ 1\n#define __CHAR_BIT__ 8\n" +
+	"#define __SIZE_TYPE__ long unsigned int\n#define __PTRDIFF_TYPE__ long int\n#define __WCHAR_TYPE__ int\n" +
+	"#define __UINT16_TYPE__ short unsigned int\n#define __UINT32_TYPE__ unsigned int\n#define __UINT64_TYPE__ long unsigned int\n"
+
+func config() *cc.Config {
+	abi, err := cc.NewABI("linux", "amd64")
+	if err != nil {
+		panic(err)
+	}
+	return &cc.Config{ABI: abi, FS: fstest.MapFS{}}
+}
+
+func tokens(n cc.Node) string {
+	var b bytes.Buffer
+	for _, t := range cc.NodeTokens(n) {
+		b.Write(t.Src())
+		b.WriteByte(' ')
+	}
+	return b.String()
+}
+
+func main() {
+	src, err := os.ReadFile("testdata/input.c")
+	if err != nil {
+		panic(err)
+	}
+	sources := []cc.Source{
+		{Name: "<predefined>", Value: predefined},
+		{Name: "<builtin>", Value: cc.Builtin},
+		{Name: "input.c", Value: src},
+	}
+	var out bytes.Buffer
+	err = cc.Preprocess(config(), sources, &out)
+	fmt.Println("cc Preprocess:", err)
+	ast, err := cc.Parse(config(), sources)
+	fmt.Println("cc Parse:", err)
+	if err == nil {
+		ast2, err := cc.Parse(config(), []cc.Source{{Name: "input.i", Value: out.Bytes()}})
+		fmt.Println("cc Parse of the preprocessed file:", err)
+		if err == nil {
+			fmt.Println("same tokens:", tokens(ast.TranslationUnit) == tokens(ast2.TranslationUnit))
+		}
+		_, err = cc.Translate(config(), sources)
+		fmt.Println("cc Translate:", err)
+	}
+
+	for _, lang := range []*sitter.Language{tsc.GetLanguage(), tscpp.GetLanguage()} {
+		p := sitter.NewParser()
+		p.SetLanguage(lang)
+		tree, err := p.ParseCtx(context.Background(), nil, src)
+		if err != nil {
+			panic(err)
+		}
+		root := tree.RootNode()
+		fmt.Println("tree-sitter:", root)
+		fmt.Println("tree-sitter HasError:", root.HasError())
+	}
+}
+`
+
+const rustMain = `package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	sitter "github.com/smacker/go-tree-sitter"
+	tsrust "github.com/smacker/go-tree-sitter/rust"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.rs")
+	if err != nil {
+		panic(err)
+	}
+	it, err := lexers.Get("rust").Tokenise(&chroma.TokeniseOptions{State: "root"}, string(src))
+	if err != nil {
+		panic(err)
+	}
+	tokens := it.Tokens()
+	var b strings.Builder
+	for _, t := range tokens {
+		b.WriteString(t.Value)
+	}
+	fmt.Printf("chroma tokens spell:\n%q\n", b.String())
+	fmt.Println("chroma tokens spell the file:", b.String() == string([]rune(string(src))))
+	var out strings.Builder
+	err = html.New(html.WithClasses(true), html.WithLineNumbers(true)).Format(&out, styles.Fallback, chroma.Literator(tokens...))
+	fmt.Println("chroma html Format:", err)
+
+	p := sitter.NewParser()
+	p.SetLanguage(tsrust.GetLanguage())
+	tree, err := p.ParseCtx(context.Background(), nil, src)
+	if err != nil {
+		panic(err)
+	}
+	root := tree.RootNode()
+	fmt.Println("tree-sitter:", root)
+	fmt.Println("tree-sitter HasError:", root.HasError())
+}
+`
+
+const shellMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+func main() {
+	src, err := os.ReadFile("testdata/input.sh")
+	if err != nil {
+		panic(err)
+	}
+	for _, lang := range []syntax.LangVariant{syntax.LangBash, syntax.LangPOSIX, syntax.LangMirBSDKorn} {
+		fmt.Printf("%s:\n", lang)
+		f, err := syntax.NewParser(syntax.Variant(lang), syntax.KeepComments(true)).Parse(bytes.NewReader(src), "input.sh")
+		if err != nil {
+			fmt.Println("  Parse:", err)
+			continue
+		}
+		for _, stmt := range f.Stmts {
+			file := &syntax.File{Stmts: []*syntax.Stmt{stmt}}
+			var out strings.Builder
+			syntax.NewPrinter().Print(&out, file)
+			fmt.Printf("  prints as %q\n", out.String())
+			again, err := syntax.NewParser(syntax.Variant(lang), syntax.KeepComments(true)).Parse(strings.NewReader(out.String()), "")
+			if err != nil {
+				fmt.Println("  which does not parse:", err)
+				continue
+			}
+			var out2 strings.Builder
+			syntax.NewPrinter().Print(&out2, again)
+			fmt.Printf("  which prints as %q\n", out2.String())
+			var min strings.Builder
+			syntax.NewPrinter(syntax.Minify(true)).Print(&min, file)
+			_, err = syntax.NewParser(syntax.Variant(lang)).Parse(strings.NewReader(min.String()), "")
+			fmt.Printf("  minified as %q: %v\n", min.String(), err)
+		}
+		q, err := syntax.Quote(string(src), lang)
+		if err != nil {
+			fmt.Println("  Quote:", err)
+			continue
+		}
+		fmt.Printf("  quoted: %s\n", q)
+		syntax.NewParser(syntax.Variant(lang)).Words(strings.NewReader(q), func(w *syntax.Word) bool {
+			v, err := expand.Literal(nil, w)
+			fmt.Printf("  which reads as the word %q: %v\n", v, err)
+			return true
+		})
+	}
+}
+`
+
+const json5Main = `package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/tailscale/hujson"
+	"github.com/titanous/json5"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.json5")
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("encoding/json: valid %v\n", json.Valid(data))
+	var v any
+	err = json5.Unmarshal(data, &v)
+	fmt.Printf("json5: %#v (%v)\n", v, err)
+	if err == nil {
+		out, err := json.Marshal(v)
+		fmt.Printf("Marshal: %v\n%s\n", err, out)
+	}
+	h, err := hujson.Parse(bytes.Clone(data))
+	fmt.Printf("hujson: %v\n", err)
+	if err != nil {
+		return
+	}
+	std, err := hujson.Standardize(bytes.Clone(data))
+	fmt.Printf("Standardize: %v\n%q\n", err, std)
+	var w any
+	err = json.Unmarshal(std, &w)
+	fmt.Printf("encoding/json of the standard form: %#v (%v)\n", w, err)
+	m := h.Clone()
+	m.Minimize()
+	fmt.Printf("Minimize:\n%s\n", m.Pack())
+	f := h.Clone()
+	f.Format()
+	fmt.Printf("Format:\n%s\n", f.Pack())
+}
+`
+
+const mailAddressMain = `package main
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.addr")
+	if err != nil {
+		panic(err)
+	}
+	a, err := mail.ParseAddress(string(s))
+	fmt.Printf("ParseAddress: %#v (%v)\n", a, err)
+	list, err := mail.ParseAddressList(string(s))
+	fmt.Printf("ParseAddressList: %v\n", err)
+	for _, a := range list {
+		again, err := mail.ParseAddress(a.String())
+		fmt.Printf("%#v\nprints as %q\nwhich parses as %#v (%v)\n", *a, a.String(), again, err)
+	}
+}
+`
+
+const mediaTypeMain = `package main
+
+import (
+	"fmt"
+	"mime"
+	"os"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.ct")
+	if err != nil {
+		panic(err)
+	}
+	mt, params, err := mime.ParseMediaType(string(s))
+	fmt.Printf("ParseMediaType: %q %q (%v)\n", mt, params, err)
+	out := mime.FormatMediaType(mt, params)
+	fmt.Printf("FormatMediaType: %q\n", out)
+	mt, params, err = mime.ParseMediaType(out)
+	fmt.Printf("ParseMediaType of that: %q %q (%v)\n", mt, params, err)
+}
+`
+
+const mailMessageMain = `package main
+
+import (
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"time"
+)
+
+func main() {
+	f, err := os.Open("testdata/input.eml")
+	if err != nil {
+		panic(err)
+	}
+	msg, err := mail.ReadMessage(f)
+	if err != nil {
+		fmt.Println("ReadMessage:", err)
+		return
+	}
+	for k, vs := range msg.Header {
+		fmt.Printf("%s: %q\n", k, vs)
+	}
+	for _, k := range []string{"From", "To", "Cc", "Bcc", "Reply-To", "Sender"} {
+		if list, err := msg.Header.AddressList(k); err != mail.ErrHeaderNotPresent {
+			fmt.Printf("%s: %v (%v)\n", k, list, err)
+		}
+	}
+	if t, err := msg.Header.Date(); err == nil {
+		again, err := mail.ParseDate(t.Format(time.RFC1123Z))
+		fmt.Printf("Date: %v, formatted and parsed %v (%v)\n", t, again, err)
+	}
+	var dec mime.WordDecoder
+	for _, v := range msg.Header["Subject"] {
+		text, err := dec.DecodeHeader(v)
+		enc := mime.QEncoding.Encode("utf-8", text)
+		again, err2 := dec.DecodeHeader(enc)
+		fmt.Printf("Subject: %q (%v), encoded %q, decoded %q (%v)\n", text, err, enc, again, err2)
+	}
+}
+`
+
+const multipartMain = `package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"runtime"
+	"time"
+)
+
+func main() {
+	f, err := os.Open("testdata/input.multipart")
+	if err != nil {
+		panic(err)
+	}
+	br := bufio.NewReader(f)
+	h, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		fmt.Println("ReadMIMEHeader:", err)
+		return
+	}
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	fmt.Printf("Content-Type: %q %q (%v)\n", mt, params, err)
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+	r := multipart.NewReader(br, params["boundary"])
+	for {
+		p, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println("NextPart:", err)
+			break
+		}
+		content, err := io.ReadAll(p)
+		fmt.Printf("%q\nform name %q, file name %q\n%q (%v)\n", p.Header, p.FormName(), p.FileName(), content, err)
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("took %v and allocated %d MiB\n", time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+
+const bigIntMain = `package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.int")
+	if err != nil {
+		panic(err)
+	}
+	for base := 0; base <= big.MaxBase; base++ {
+		if base == 1 {
+			continue
+		}
+		z, ok := new(big.Int).SetString(string(s), base)
+		if !ok {
+			continue
+		}
+		fmt.Printf("base %d: %v", base, z)
+		if base <= 36 {
+			v, err := strconv.ParseInt(string(s), base, 64)
+			fmt.Printf(", ParseInt %d (%v)", v, err)
+		}
+		fmt.Println()
+	}
+	r, ok := new(big.Rat).SetString(string(s))
+	fmt.Printf("Rat: %v (%v)\n", r, ok)
+}
+`
+
+const bigFloatMain = `package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.float")
+	if err != nil {
+		panic(err)
+	}
+	line, s, _ := strings.Cut(string(data), "\n")
+	prec, err := strconv.ParseUint(line, 10, 32)
+	if err != nil {
+		panic(err)
+	}
+	r, ok := new(big.Rat).SetString(s)
+	fmt.Printf("Rat: %v (%v)\n", r, ok)
+	for _, base := range []int{0, 2, 8, 10, 16} {
+		for mode := big.ToNearestEven; mode <= big.ToPositiveInf; mode++ {
+			f, b, err := new(big.Float).SetPrec(uint(prec)).SetMode(mode).Parse(s, base)
+			if err != nil {
+				fmt.Printf("base %d, %v: %v\n", base, mode, err)
+				continue
+			}
+			fmt.Printf("base %d, %v: %s (%v), base %d, precision %d, MinPrec %d\n", base, mode, f.Text('p', 0), f.Acc(), b, f.Prec(), f.MinPrec())
+			if ok && base == 0 {
+				w := new(big.Float).SetPrec(f.Prec()).SetMode(mode).SetRat(r)
+				fmt.Printf("\tRat rounded: %s (%v)\n", w.Text('p', 0), w.Acc())
+			}
+		}
+	}
+}
+`
+
+const bigRatMain = `package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.rat")
+	if err != nil {
+		panic(err)
+	}
+	r, ok := new(big.Rat).SetString(string(s))
+	if !ok {
+		fmt.Println("SetString fails")
+		return
+	}
+	fmt.Println(r)
+	f, exact := r.Float64()
+	fmt.Printf("Float64: %v, exact %v\n", f, exact)
+	v, err := strconv.ParseFloat(string(s), 64)
+	fmt.Printf("ParseFloat: %v (%v)\n", v, err)
+	again, ok := new(big.Rat).SetString(r.String())
+	fmt.Printf("%q reads as %v (%v)\n", r.String(), again, ok)
+}
+`
+
+const strconvFloatMain = `package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+	"strconv"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.float")
+	if err != nil {
+		panic(err)
+	}
+	for _, bitSize := range []int{32, 64} {
+		f, err := strconv.ParseFloat(string(s), bitSize)
+		fmt.Printf("ParseFloat(%d): %v %x (%v)\n", bitSize, f, f, err)
+		if r, ok := new(big.Rat).SetString(string(s)); ok {
+			want, _ := r.Float64()
+			if bitSize == 32 {
+				w, _ := r.Float32()
+				want = float64(w)
+			}
+			fmt.Printf("\tRat rounds to %v %x\n", want, want)
+		}
+		for _, verb := range []byte{'e', 'f', 'g', 'x', 'b'} {
+			out := strconv.FormatFloat(f, verb, -1, bitSize)
+			again, err := strconv.ParseFloat(out, bitSize)
+			fmt.Printf("\t%c: %q parses as %v (%v)\n", verb, out, again, err)
+		}
+	}
+}
+`
+
+const strconvIntMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.int")
+	if err != nil {
+		panic(err)
+	}
+	i, err := strconv.Atoi(string(s))
+	fmt.Printf("Atoi: %d (%v)\n", i, err)
+	for _, base := range []int{0, 2, 8, 10, 16, 36} {
+		for _, bitSize := range []int{8, 16, 32, 64} {
+			v, err := strconv.ParseInt(string(s), base, bitSize)
+			u, errU := strconv.ParseUint(string(s), base, bitSize)
+			fmt.Printf("base %d, %d bits: ParseInt %d (%v), ParseUint %d (%v)\n", base, bitSize, v, err, u, errU)
+		}
+	}
+}
+`
+
+const strconvQuotedMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	s, err := os.ReadFile("testdata/input.quoted")
+	if err != nil {
+		panic(err)
+	}
+	u, err := strconv.Unquote(string(s))
+	fmt.Printf("Unquote: %q (%v)\n", u, err)
+	p, err := strconv.QuotedPrefix(string(s))
+	fmt.Printf("QuotedPrefix: %q (%v)\n", p, err)
+	for _, q := range []string{strconv.Quote(u), strconv.QuoteToASCII(u), strconv.QuoteToGraphic(u)} {
+		again, err := strconv.Unquote(q)
+		fmt.Printf("%q unquotes to %q (%v)\n", q, again, err)
+	}
+}
+`
+
+const filepathMain = `package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.paths")
+	if err != nil {
+		panic(err)
+	}
+	paths := strings.Split(string(data), "\n")
+	for _, p := range paths {
+		fmt.Printf("%q\n", p)
+		fmt.Printf("\tClean %q, path.Clean %q, Join to base %q\n", filepath.Clean(p), path.Clean(p), filepath.Join("base", p))
+		dir, file := filepath.Split(p)
+		fmt.Printf("\tSplit %q %q, Dir %q, Base %q, Ext %q\n", dir, file, filepath.Dir(p), filepath.Base(p), filepath.Ext(p))
+		l, err := filepath.Localize(p)
+		fmt.Printf("\tIsLocal %v, ValidPath %v, Localize %q (%v)\n", filepath.IsLocal(p), fs.ValidPath(p), l, err)
+		for _, targ := range paths {
+			r, err := filepath.Rel(p, targ)
+			fmt.Printf("\tRel to %q: %q (%v), which joins as %q\n", targ, r, err, filepath.Join(p, r))
+		}
+	}
+}
+`
+
+const embedMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	tree, err := os.ReadFile("testdata/tree.txt")
+	if err != nil {
+		panic(err)
+	}
+	files := map[string]string{"go.mod": "module example.com/p\n\ngo 1.22\n"}
+	for _, name := range strings.Split(string(tree), "\n") {
+		if name != "" {
+			files[name] = name + "\n"
+		}
+	}
+	for name, data := range files {
+		file := filepath.Join("pkg", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o777); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(file, []byte(data), 0o666); err != nil {
+			panic(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"list", "-e", "-json=EmbedPatterns,EmbedFiles,Error", "."},
+		{"build", "."},
+	} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = "pkg"
+		cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOTOOLCHAIN=local", "GOWORK=off")
+		out, err := cmd.CombinedOutput()
+		fmt.Printf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+`
+
+const modzipMain = `package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/module"
+	modzip "golang.org/x/mod/zip"
+)
+
+func main() {
+	z, err := zip.OpenReader("testdata/m.zip")
+	if err != nil {
+		panic(err)
+	}
+	name := z.File[0].Name
+	at := strings.Index(name, "@")
+	slash := at + strings.Index(name[at:], "/")
+	m := module.Version{Path: name[:at], Version: name[at+1 : slash]}
+	cf, err := modzip.CheckZip(m, "testdata/m.zip")
+	fmt.Printf("CheckZip(%v): %v\nvalid: %q\n", m, err, cf.Valid)
+	for _, fe := range cf.Invalid {
+		fmt.Printf("invalid: %q: %v\n", fe.Path, fe.Err)
+	}
+	dir, err := os.MkdirTemp("", "repro")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	fmt.Println("Unzip:", modzip.Unzip(dir+"/out", m, "testdata/m.zip"))
+	cf, err = modzip.CheckDir(dir + "/out")
+	fmt.Printf("CheckDir: %v\nvalid: %q\n", err, cf.Valid)
+}
+`
+
+const modproxyMain = `package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/mod/module"
+)
+
+func main() {
+	z, err := zip.OpenReader("testdata/v.zip")
+	if err != nil {
+		panic(err)
+	}
+	name := z.File[0].Name
+	at := strings.Index(name, "@")
+	slash := at + strings.Index(name[at:], "/")
+	m := module.Version{Path: name[:at], Version: name[at+1 : slash]}
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		panic(err)
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		panic(err)
+	}
+	dir, err := os.MkdirTemp("", "repro")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+	vdir := filepath.Join(dir, "proxy", filepath.FromSlash(escPath), "@v")
+	if err := os.MkdirAll(vdir, 0o777); err != nil {
+		panic(err)
+	}
+	for from, to := range map[string]string{"list": "list", "v.info": escVersion + ".info", "v.mod": escVersion + ".mod", "v.zip": escVersion + ".zip"} {
+		data, err := os.ReadFile("testdata/" + from)
+		if err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(filepath.Join(vdir, to), data, 0o666); err != nil {
+			panic(err)
+		}
+	}
+	for _, args := range [][]string{
+		{"mod", "download", "-json", m.String()},
+		{"list", "-e", "-m", "-retracted", "-versions", "-json", m.String()},
+	} {
+		cmd := exec.Command("go", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GOPROXY=file://"+filepath.ToSlash(filepath.Join(dir, "proxy")), "GOMODCACHE="+filepath.Join(dir, "cache"),
+			"GOFLAGS=-modcacherw", "GOSUMDB=off", "GONOSUMDB=", "GOPRIVATE=", "GOTOOLCHAIN=local", "GOWORK=off", "GO111MODULE=on", "GOVCS=*:off")
+		out, err := cmd.CombinedOutput()
+		fmt.Printf("go %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+}
+`
+
+const semverMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.versions")
+	if err != nil {
+		panic(err)
+	}
+	var versions []string
+	for _, l := range strings.Split(string(data), "\n") {
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) == 2 {
+			fmt.Printf("Check(%q, %q): %v\n", f[0], f[1], module.Check(f[0], f[1]))
+		}
+		mf, err := modfile.Parse("go.mod", []byte("module example.com/m\n\nretract "+l+"\n"), nil)
+		if err == nil {
+			for _, r := range mf.Retract {
+				fmt.Printf("retract %s: %v\n", l, r.VersionInterval)
+			}
+		} else {
+			fmt.Printf("retract %s: %v\n", l, err)
+		}
+		versions = append(versions, f[len(f)-1])
+	}
+	for _, v := range versions {
+		fmt.Printf("%q: valid %v, canonical %q, major %q, major.minor %q, prerelease %q, build %q\n",
+			v, semver.IsValid(v), semver.Canonical(v), semver.Major(v), semver.MajorMinor(v), semver.Prerelease(v), semver.Build(v))
+		if module.IsPseudoVersion(v) {
+			base, err := module.PseudoVersionBase(v)
+			t, terr := module.PseudoVersionTime(v)
+			rev, _ := module.PseudoVersionRev(v)
+			fmt.Printf("\tpseudo-version: base %q, %v; time %v, %v; rev %q; again %q\n",
+				base, err, t, terr, rev, module.PseudoVersion(semver.Major(v), base, t, rev))
+		}
+	}
+	for _, a := range versions {
+		for _, b := range versions {
+			fmt.Printf("Compare(%q, %q) = %d, Max %q\n", a, b, semver.Compare(a, b), semver.Max(a, b))
+		}
+	}
+	semver.Sort(versions)
+	fmt.Printf("Sort: %q\n", versions)
+}
+`
+
+const gitAdvMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/refs.pkt")
+	if err != nil {
+		panic(err)
+	}
+	ar := packp.NewAdvRefs()
+	if err := ar.Decode(bytes.NewReader(data)); err != nil {
+		fmt.Println("Decode:", err)
+		return
+	}
+	fmt.Printf("prefix %q\nhead %v\ncapabilities %q\nreferences %v\npeeled %v\nshallows %v\n",
+		ar.Prefix, ar.Head, ar.Capabilities.String(), ar.References, ar.Peeled, ar.Shallows)
+	var out bytes.Buffer
+	if err := ar.Encode(&out); err != nil {
+		fmt.Println("Encode:", err)
+		return
+	}
+	fmt.Printf("encodes as %q\n", out.Bytes())
+	again := packp.NewAdvRefs()
+	err = again.Decode(bytes.NewReader(out.Bytes()))
+	fmt.Printf("which decodes as head %v, capabilities %q, references %v, peeled %v, shallows %v: %v\n",
+		again.Head, again.Capabilities.String(), again.References, again.Peeled, again.Shallows, err)
+}
+`
+
+const gitRequestMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/request.pkt")
+	if err != nil {
+		panic(err)
+	}
+	ur := packp.NewUploadRequest()
+	if err := ur.Decode(bytes.NewReader(data)); err != nil {
+		fmt.Println("Decode:", err)
+		return
+	}
+	fmt.Printf("capabilities %q\nwants %v\nshallows %v\ndepth %#v\n", ur.Capabilities.String(), ur.Wants, ur.Shallows, ur.Depth)
+	var out bytes.Buffer
+	if err := ur.Encode(&out); err != nil {
+		fmt.Println("Encode:", err)
+		return
+	}
+	fmt.Printf("encodes as %q\n", out.Bytes())
+	again := packp.NewUploadRequest()
+	err = again.Decode(bytes.NewReader(out.Bytes()))
+	fmt.Printf("which decodes as capabilities %q, wants %v, shallows %v, depth %#v: %v\n",
+		again.Capabilities.String(), again.Wants, again.Shallows, again.Depth, err)
+}
+`
+
+const gitPackMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+type observer struct{ typ plumbing.ObjectType }
+
+func (o *observer) OnHeader(n uint32) error {
+	fmt.Println("objects:", n)
+	return nil
+}
+
+func (o *observer) OnInflatedObjectHeader(t plumbing.ObjectType, size, offset int64) error {
+	o.typ = t
+	return nil
+}
+
+func (o *observer) OnInflatedObjectContent(h plumbing.Hash, offset int64, crc uint32, _ []byte) error {
+	fmt.Printf("\t%v %v at %d\n", o.typ, h, offset)
+	return nil
+}
+
+func (o *observer) OnFooter(h plumbing.Hash) error {
+	fmt.Println("checksum:", h)
+	return nil
+}
+
+func main() {
+	data, err := os.ReadFile("testdata/input.pack")
+	if err != nil {
+		panic(err)
+	}
+	p, err := packfile.NewParser(packfile.NewScanner(bytes.NewReader(data)), &observer{})
+	if err == nil {
+		_, err = p.Parse()
+	}
+	fmt.Println("Parse:", err)
+
+	// Into storage, from a reader that cannot seek.
+	st := memory.NewStorage()
+	p, err = packfile.NewParserWithStorage(packfile.NewScanner(struct{ io.Reader }{bytes.NewReader(data)}), st)
+	if err == nil {
+		_, err = p.Parse()
+	}
+	fmt.Println("Parse into storage:", err)
+	for h, o := range st.ObjectStorage.Objects {
+		r, err := o.Reader()
+		if err != nil {
+			panic(err)
+		}
+		b, err := io.ReadAll(r)
+		fmt.Printf("\t%v %v: %q, %v\n", o.Type(), h, b, err)
+	}
+}
+`
+
+// sshMain runs the input through a handshake of side, "Server" or
+// "Client", the way fuzz/ssh does, and prints its error and what it
+// wrote.
+func sshMain(side string) string {
+	imports, handshake := "", `_, _, _, err = ssh.NewClientConn(c, "pipe", &ssh.ClientConfig{
+		Config:            config,
+		User:              "fuzz",
+		Auth:              []ssh.AuthMethod{ssh.Password("fuzz")},
+		HostKeyCallback:   ssh.InsecureIgnoreHostKey(),
+		HostKeyAlgorithms: slices.Concat(sup.HostKeys, insec.HostKeys),
+	})`
+	if side == "Server" {
+		imports = "\n\t\"crypto/ed25519\""
+		handshake = `key, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		panic(err)
+	}
+	conf := &ssh.ServerConfig{
+		Config: config,
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, fmt.Errorf("no")
+		},
+	}
+	conf.AddHostKey(key)
+	_, _, _, err = ssh.NewServerConn(c, conf)`
+	}
+	return `package main
+
+import (
+	"bytes"` + imports + `
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type conn struct {
+	in     *bytes.Reader
+	mu     sync.Mutex
+	out    bytes.Buffer
+	closed bool
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.in.Read(p)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	return c.out.Write(p)
+}
+
+func (c *conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+func (c *conn) output() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.out.Bytes())
+}
+
+func (c *conn) LocalAddr() net.Addr              { return addr{} }
+func (c *conn) RemoteAddr() net.Addr             { return addr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+type addr struct{}
+
+func (addr) Network() string { return "pipe" }
+func (addr) String() string  { return "pipe" }
+
+func main() {
+	data, err := os.ReadFile("testdata/input.ssh")
+	if err != nil {
+		panic(err)
+	}
+	sup, insec := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	config := ssh.Config{
+		KeyExchanges: slices.Concat(sup.KeyExchanges, insec.KeyExchanges),
+		Ciphers:      slices.Concat(sup.Ciphers, insec.Ciphers),
+		MACs:         slices.Concat(sup.MACs, insec.MACs),
+	}
+	c := &conn{in: bytes.NewReader(data)}
+	` + handshake + `
+	fmt.Printf("New` + side + `Conn: %v\nread %d of %d bytes, wrote:\n%x\n", err, len(data)-c.in.Len(), len(data), c.output())
+}
+`
+}
+
+const sshMessageMain = `package main
+
+import (
+	"fmt"
+	"math/big"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+)
+
+type (
+	extInfoMsg struct {
+		NumExtensions uint32 ` + "`sshtype:\"7\"`" + `
+		Payload       []byte ` + "`ssh:\"rest\"`" + `
+	}
+	kexInitMsg struct {
+		Cookie                  [16]byte ` + "`sshtype:\"20\"`" + `
+		KexAlgos                []string
+		ServerHostKeyAlgos      []string
+		CiphersClientServer     []string
+		CiphersServerClient     []string
+		MACsClientServer        []string
+		MACsServerClient        []string
+		CompressionClientServer []string
+		CompressionServerClient []string
+		LanguagesClientServer   []string
+		LanguagesServerClient   []string
+		FirstKexFollows         bool
+		Reserved                uint32
+	}
+	kexDHInitMsg struct {
+		X *big.Int ` + "`sshtype:\"30\"`" + `
+	}
+	kexECDHInitMsg struct {
+		ClientPubKey []byte ` + "`sshtype:\"30\"`" + `
+	}
+	kexDHReplyMsg struct {
+		HostKey   []byte ` + "`sshtype:\"31\"`" + `
+		Y         *big.Int
+		Signature []byte
+	}
+	kexECDHReplyMsg struct {
+		HostKey         []byte ` + "`sshtype:\"31\"`" + `
+		EphemeralPubKey []byte
+		Signature       []byte
+	}
+	kexDHGexGroupMsg struct {
+		P *big.Int ` + "`sshtype:\"31\"`" + `
+		G *big.Int
+	}
+	kexDHGexInitMsg struct {
+		X *big.Int ` + "`sshtype:\"32\"`" + `
+	}
+	kexDHGexReplyMsg struct {
+		HostKey   []byte ` + "`sshtype:\"33\"`" + `
+		Y         *big.Int
+		Signature []byte
+	}
+	kexDHGexRequestMsg struct {
+		MinBits       uint32 ` + "`sshtype:\"34\"`" + `
+		PreferredBits uint32
+		MaxBits       uint32
+	}
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/message.ssh")
+	if err != nil {
+		panic(err)
+	}
+	for _, msg := range []any{
+		new(extInfoMsg), new(kexInitMsg), new(kexDHInitMsg), new(kexECDHInitMsg), new(kexDHReplyMsg),
+		new(kexECDHReplyMsg), new(kexDHGexGroupMsg), new(kexDHGexInitMsg), new(kexDHGexReplyMsg), new(kexDHGexRequestMsg),
+	} {
+		if err := ssh.Unmarshal(data, msg); err != nil {
+			fmt.Printf("%T: %v\n", msg, err)
+			continue
+		}
+		enc := ssh.Marshal(msg)
+		fmt.Printf("%T: %+v\n\tencodes as %x\n", msg, msg, enc)
+		again := fmt.Sprintf("%+v", msg)
+		if err := ssh.Unmarshal(enc, msg); err != nil || fmt.Sprintf("%+v", msg) != again {
+			fmt.Printf("\twhich decodes as %+v: %v\n", msg, err)
+		}
+	}
+}
+`
+
+const jwsMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	golangjwt "github.com/golang-jwt/jwt/v5"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/token.jws")
+	if err != nil {
+		panic(err)
+	}
+	tok := string(data)
+	for i, p := range []*golangjwt.Parser{
+		golangjwt.NewParser(),
+		golangjwt.NewParser(golangjwt.WithPaddingAllowed()),
+		golangjwt.NewParser(golangjwt.WithStrictDecoding()),
+	} {
+		name := []string{"ParseUnverified", "ParseUnverified WithPaddingAllowed", "ParseUnverified WithStrictDecoding"}[i]
+		t, _, err := p.ParseUnverified(tok, golangjwt.MapClaims{})
+		if err != nil {
+			fmt.Printf("%s: %v\n", name, err)
+			continue
+		}
+		fmt.Printf("%s: header %v, claims %v, signature %x\n", name, t.Header, t.Claims, t.Signature)
+	}
+	algs := []jose.SignatureAlgorithm{
+		jose.HS256, jose.HS384, jose.HS512, jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512,
+		jose.ES256, jose.ES384, jose.ES512, jose.EdDSA,
+	}
+	obj, err := jose.ParseSigned(tok, algs)
+	if err != nil {
+		fmt.Printf("ParseSigned: %v\n", err)
+		return
+	}
+	fmt.Printf("ParseSigned: payload %q\n", obj.UnsafePayloadWithoutVerification())
+	for _, sig := range obj.Signatures {
+		fmt.Printf("\tsignature %x, alg %q, kid %q\n", sig.Signature, sig.Header.Algorithm, sig.Header.KeyID)
+	}
+	again := obj.FullSerialize()
+	if !strings.HasPrefix(strings.TrimSpace(tok), "{") {
+		again, err = obj.CompactSerialize()
+	}
+	fmt.Printf("serializes as %q: %v\n", again, err)
+	if obj2, err := jose.ParseSigned(again, algs); err != nil {
+		fmt.Printf("\twhich does not read back: %v\n", err)
+	} else {
+		fmt.Printf("\twhich reads back with payload %q\n", obj2.UnsafePayloadWithoutVerification())
+	}
+	if t, err := josejwt.ParseSigned(tok, algs); err != nil {
+		fmt.Printf("jwt.ParseSigned: %v\n", err)
+	} else {
+		var claims map[string]any
+		err := t.UnsafeClaimsWithoutVerification(&claims)
+		fmt.Printf("jwt.ParseSigned: claims %v: %v\n", claims, err)
+	}
+}
+`
+
+const jweMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	jose "github.com/go-jose/go-jose/v4"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/token.jwe")
+	if err != nil {
+		panic(err)
+	}
+	tok := string(data)
+	keyAlgs := []jose.KeyAlgorithm{
+		jose.ED25519, jose.RSA1_5, jose.RSA_OAEP, jose.RSA_OAEP_256, jose.A128KW, jose.A192KW, jose.A256KW, jose.DIRECT,
+		jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A192KW, jose.ECDH_ES_A256KW,
+		jose.A128GCMKW, jose.A192GCMKW, jose.A256GCMKW,
+		jose.PBES2_HS256_A128KW, jose.PBES2_HS384_A192KW, jose.PBES2_HS512_A256KW,
+	}
+	encs := []jose.ContentEncryption{
+		jose.A128CBC_HS256, jose.A192CBC_HS384, jose.A256CBC_HS512, jose.A128GCM, jose.A192GCM, jose.A256GCM,
+	}
+	compact := !strings.HasPrefix(strings.TrimSpace(tok), "{")
+	for range 3 {
+		obj, err := jose.ParseEncrypted(tok, keyAlgs, encs)
+		if err != nil {
+			fmt.Printf("ParseEncrypted: %v\n", err)
+			return
+		}
+		fmt.Printf("ParseEncrypted: alg %q, additional data %q\n", obj.Header.Algorithm, obj.GetAuthData())
+		if tok = obj.FullSerialize(); compact {
+			tok, err = obj.CompactSerialize()
+		}
+		fmt.Printf("serializes as %q: %v\n", tok, err)
+	}
+}
+`
+
+const pemMain = `package main
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+func main() {
+	rest, err := os.ReadFile("testdata/input.pem")
+	if err != nil {
+		panic(err)
+	}
+	for {
+		var p *pem.Block
+		p, rest = pem.Decode(rest)
+		if p == nil {
+			fmt.Printf("no block, %d bytes left: %q\n", len(rest), rest)
+			return
+		}
+		fmt.Printf("block %q, headers %q, %d bytes: %x\n", p.Type, p.Headers, len(p.Bytes), p.Bytes)
+		enc := pem.EncodeToMemory(p)
+		back, left := pem.Decode(enc)
+		fmt.Printf("\tencodes as %q, which decodes as %+v and %q\n", enc, back, left)
+	}
+}
+`
+
+const base64Main = `package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"testing/iotest"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.b64")
+	if err != nil {
+		panic(err)
+	}
+	for _, e := range []struct {
+		name string
+		enc  *base64.Encoding
+	}{
+		{"StdEncoding", base64.StdEncoding},
+		{"URLEncoding", base64.URLEncoding},
+		{"RawStdEncoding", base64.RawStdEncoding},
+		{"RawURLEncoding", base64.RawURLEncoding},
+		{"StdEncoding.Strict", base64.StdEncoding.Strict()},
+		{"URLEncoding.Strict", base64.URLEncoding.Strict()},
+		{"RawStdEncoding.Strict", base64.RawStdEncoding.Strict()},
+		{"RawURLEncoding.Strict", base64.RawURLEncoding.Strict()},
+	} {
+		b, err := e.enc.DecodeString(string(data))
+		fmt.Printf("%s: DecodeString %x (%v)\n", e.name, b, err)
+		if err == nil {
+			fmt.Printf("\tencodes as %q\n", e.enc.EncodeToString(b))
+		}
+		buf := make([]byte, e.enc.DecodedLen(len(data)))
+		n, err := e.enc.Decode(buf, data)
+		fmt.Printf("\tDecode %x (%v)\n", buf[:n], err)
+		streamed, err := io.ReadAll(base64.NewDecoder(e.enc, iotest.OneByteReader(bytes.NewReader(data))))
+		fmt.Printf("\tNewDecoder one byte at a time %x (%v)\n", streamed, err)
+	}
+}
+`
+
+const addrMain = `package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.ip")
+	if err != nil {
+		panic(err)
+	}
+	s := string(data)
+	a, err := netip.ParseAddr(s)
+	fmt.Printf("netip.ParseAddr: %v (%v), As16 %x, Is4 %v, Is4In6 %v, zone %q\n", a, err, a.As16(), a.Is4(), a.Is4In6(), a.Zone())
+	if err == nil {
+		fmt.Printf("\tString %q, StringExpanded %q\n", a.String(), a.StringExpanded())
+		fmt.Printf("\tunspecified %v, loopback %v, private %v, multicast %v, link-local unicast %v, global unicast %v\n",
+			a.IsUnspecified(), a.IsLoopback(), a.IsPrivate(), a.IsMulticast(), a.IsLinkLocalUnicast(), a.IsGlobalUnicast())
+	}
+	ip := net.ParseIP(s)
+	fmt.Printf("net.ParseIP: %v, %x\n", ip, []byte(ip))
+	if ip != nil {
+		fmt.Printf("\tunspecified %v, loopback %v, private %v, multicast %v, link-local unicast %v, global unicast %v\n",
+			ip.IsUnspecified(), ip.IsLoopback(), ip.IsPrivate(), ip.IsMulticast(), ip.IsLinkLocalUnicast(), ip.IsGlobalUnicast())
+	}
+}
+`
+
+const prefixMain = `package main
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.cidr")
+	if err != nil {
+		panic(err)
+	}
+	s, probe, _ := strings.Cut(string(data), " ")
+	p, err := netip.ParsePrefix(s)
+	fmt.Printf("netip.ParsePrefix: %v (%v), masked %v\n", p, err, p.Masked())
+	ip, ipnet, err := net.ParseCIDR(s)
+	fmt.Printf("net.ParseCIDR: %v, %v (%v)\n", ip, ipnet, err)
+	if probe == "" {
+		return
+	}
+	a, err := netip.ParseAddr(probe)
+	fmt.Printf("probe %v (%v): netip Contains %v", a, err, p.Contains(a))
+	if ipnet != nil {
+		fmt.Printf(", net Contains %v", ipnet.Contains(net.ParseIP(probe)))
+	}
+	fmt.Println()
+}
+`
+
+const cborMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.cbor")
+	if err != nil {
+		panic(err)
+	}
+	dm, err := cbor.DecOptions{
+		MaxNestedLevels:  16,
+		MaxArrayElements: 256,
+		MaxMapPairs:      256,
+		DupMapKey:        cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	fmt.Printf("Wellformed: %v\n", dm.Wellformed(data))
+	if diag, err := cbor.Diagnose(data); err == nil {
+		fmt.Printf("diagnostic notation: %s\n", diag)
+	}
+	dec := dm.NewDecoder(bytes.NewReader(data))
+	for {
+		err := dec.Skip()
+		fmt.Printf("Decoder.Skip: %v, at %d\n", err, dec.NumBytesRead())
+		if err != nil {
+			break
+		}
+	}
+	var v any
+	if err := dm.Unmarshal(data, &v); err != nil {
+		fmt.Printf("Unmarshal: %v\n", err)
+		return
+	}
+	fmt.Printf("Unmarshal: %#v\n", v)
+	em, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	enc, err := em.Marshal(v)
+	fmt.Printf("Marshal: %x (%v), same as the input: %v\n", enc, err, bytes.Equal(enc, data))
+	var back any
+	err = dm.Unmarshal(enc, &back)
+	again, _ := em.Marshal(back)
+	fmt.Printf("which decodes as %#v (%v), and encodes as %x\n", back, err, again)
+}
+`
+
+const msgpackMain = `package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.msgpack")
+	if err != nil {
+		panic(err)
+	}
+	var v any
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		fmt.Printf("Unmarshal: %v\n", err)
+		return
+	}
+	fmt.Printf("Unmarshal: %#v\n", v)
+	enc, err := msgpack.Marshal(v)
+	fmt.Printf("Marshal: %x (%v)\n", enc, err)
+	var back any
+	err = msgpack.Unmarshal(enc, &back)
+	fmt.Printf("which unmarshals as %#v (%v)\n", back, err)
+}
+`
+
+const bencodeMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	anacrolix "github.com/anacrolix/torrent/bencode"
+	jackpal "github.com/jackpal/bencode-go"
+	zeebo "github.com/zeebo/bencode"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.torrent")
+	if err != nil {
+		panic(err)
+	}
+	var v any
+	err = anacrolix.Unmarshal(data, &v)
+	fmt.Printf("anacrolix Unmarshal: %#v (%v)\n", v, err)
+	if err == nil {
+		enc, err := anacrolix.Marshal(v)
+		fmt.Printf("anacrolix Marshal: %q (%v), same as the input: %v\n", enc, err, bytes.Equal(enc, data))
+	}
+	dec := anacrolix.NewDecoder(bytes.NewReader(data))
+	for {
+		var v any
+		err := dec.Decode(&v)
+		fmt.Printf("anacrolix Decoder.Decode: %v, at %d\n", err, dec.Offset)
+		if err != nil {
+			break
+		}
+	}
+	zdec := zeebo.NewDecoder(bytes.NewReader(data))
+	zdec.SetFailOnUnorderedKeys(true)
+	var zv any
+	err = zdec.Decode(&zv)
+	fmt.Printf("zeebo Decode: %#v (%v), at %d\n", zv, err, zdec.BytesParsed())
+	if err == nil {
+		enc, err := zeebo.EncodeBytes(zv)
+		fmt.Printf("zeebo EncodeBytes: %q (%v)\n", enc, err)
+	}
+	jv, err := jackpal.Decode(bytes.NewReader(data))
+	fmt.Printf("jackpal Decode: %#v (%v)\n", jv, err)
+	if err == nil {
+		var b bytes.Buffer
+		err := jackpal.Marshal(&b, jv)
+		fmt.Printf("jackpal Marshal: %q (%v)\n", b.Bytes(), err)
+	}
+}
+`
+
+const pdfMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	ledongthuc "github.com/ledongthuc/pdf"
+	rsc "rsc.io/pdf"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.pdf")
+	if err != nil {
+		panic(err)
+	}
+	func() {
+		defer func() {
+			if p := recover(); p != nil {
+				fmt.Println("rsc.io/pdf NewReader panics:", p)
+			}
+		}()
+		r, err := rsc.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			fmt.Println("rsc.io/pdf NewReader:", err)
+			return
+		}
+		fmt.Println("rsc.io/pdf trailer:", r.Trailer())
+		fmt.Println("rsc.io/pdf NumPage:", r.NumPage())
+	}()
+	r, err := ledongthuc.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		fmt.Println("ledongthuc/pdf NewReader:", err)
+		return
+	}
+	fmt.Println("ledongthuc/pdf trailer:", r.Trailer())
+	fmt.Println("ledongthuc/pdf NumPage:", r.NumPage())
+}
+`
+
+// fontMain returns main.go for golang.org/x/image/font/sfnt, which parses
+// a font, or each font of a collection if collection is set, and loads
+// every glyph at as many pixels per em as it has units.
+func fontMain(file string, collection bool) string {
+	parse := `	f, err := sfnt.Parse(data)
+	if err != nil {
+		fmt.Println("Parse:", err)
+		return
+	}
+	fonts := []*sfnt.Font{f}
+`
+	if collection {
+		parse = `	c, err := sfnt.ParseCollection(data)
+	if err != nil {
+		fmt.Println("ParseCollection:", err)
+		return
+	}
+	var fonts []*sfnt.Font
+	for i := range c.NumFonts() {
+		f, err := c.Font(i)
+		if err != nil {
+			fmt.Printf("Font(%d): %v\n", i, err)
+			continue
+		}
+		fonts = append(fonts, f)
+	}
+`
+	}
+	return `package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/` + file + `")
+	if err != nil {
+		panic(err)
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	before := m.TotalAlloc
+	start := time.Now()
+` + parse + `	for i, f := range fonts {
+		var b sfnt.Buffer
+		ppem := fixed.Int26_6(f.UnitsPerEm())
+		segments, failed := 0, 0
+		for x := range f.NumGlyphs() {
+			segs, err := f.LoadGlyph(&b, sfnt.GlyphIndex(x), ppem, nil)
+			if err != nil {
+				if failed == 0 {
+					fmt.Printf("font %d: LoadGlyph(%d): %v\n", i, x, err)
+				}
+				failed++
+				continue
+			}
+			segments += len(segs)
+		}
+		fmt.Printf("font %d: %d glyphs, %d failed to load, %d segments\n", i, f.NumGlyphs(), failed, segments)
+	}
+	runtime.ReadMemStats(&m)
+	fmt.Printf("%d bytes took %v and allocated %d MiB\n", len(data), time.Since(start), (m.TotalAlloc-before)>>20)
+}
+`
+}
+
+const htmlMain = `package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"golang.org/x/net/html"
+)
+
+func main() {
+	data, err := os.ReadFile("testdata/input.html")
+	if err != nil {
+		panic(err)
+	}
+	for i := range 3 {
+		doc, err := html.Parse(bytes.NewReader(data))
+		if err != nil {
+			fmt.Println("Parse error:", err)
+			return
+		}
+		var out bytes.Buffer
+		if err := html.Render(&out, doc); err != nil {
+			fmt.Println("Render error:", err)
+			return
+		}
+		fmt.Printf("round %d renders %q\n", i+1, out.Bytes())
+		if bytes.Equal(out.Bytes(), data) {
+			return
+		}
+		data = out.Bytes()
+	}
+}
+`
@@ -0,0 +1,204 @@
+// Repro turns an input that makes a fuzz target fail into a
+// self-contained reproducer, ready to attach to an issue.
+//
+// Usage:
+//
+//	repro [-o dir] [-lang version] [-timeout d] [-norun] pkg FuzzXxx input
+//
+// The input is a "go test fuzz v1" file, as `go test -fuzz` leaves in
+// testdata/fuzz/FuzzXxx, or a raw input, taken as the single []byte
+// argument. Repro first runs FuzzXxx of the harness package pkg on it to
+// record how it fails (skipped with -norun), then writes dir (default
+// repro-<entry name>) holding:
+//
+//	go.mod        module "repro" at the -lang version
+//	testdata/...  the input, one file per argument
+//	main.go       a driver that calls the standard library API the
+//	              target tests the way the target does and prints the
+//	              results, with no dependency on this repository
+//
+// main.go opens with a comment naming the target, the failure and the
+// command that runs the reproducer, e.g.
+//
+//	// Run: cd repro-0a1b2c3d4e5f6a7b && go run .
+//
+// Only the harnesses of this repository have drivers; repro lists them
+// when given another target.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus/native"
+	"github.com/geeknik/fuzzing/internal/crash"
+)
+
+var (
+	outDir  = flag.String("o", "", "reproducer `directory` (default repro-<entry name>)")
+	lang    = flag.String("lang", "1.24", "go `version` in the reproducer's go.mod")
+	timeout = flag.Duration("timeout", 30*time.Second, "time `limit` for running the target")
+	noRun   = flag.Bool("norun", false, "do not run the target to record the failure")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("repro: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: repro [flags] pkg FuzzXxx input\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 3 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pkg, target, input := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	key := path.Base(filepath.ToSlash(pkg)) + "." + target
+	d := drivers[key]
+	if d == nil {
+		var known []string
+		for k := range drivers {
+			known = append(known, k)
+		}
+		slices.Sort(known)
+		log.Fatalf("no driver for %s; known targets: %s", key, strings.Join(known, ", "))
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		log.Fatal(err)
+	}
+	entry := data
+	args, err := native.Unmarshal(data)
+	if err != nil {
+		entry = native.Bytes(data)
+		args = []any{data}
+	}
+	if d.args != nil {
+		if args, err = d.args(args); err != nil {
+			log.Fatalf("%s: %v", input, err)
+		}
+	}
+	if len(args) != len(d.files) {
+		log.Fatalf("%s: %d arguments, %s takes %d", input, len(args), target, len(d.files))
+	}
+
+	failure := "(not run)"
+	if !*noRun {
+		failure, err = run(pkg, target, entry)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	dir := *outDir
+	if dir == "" {
+		dir = "repro-" + native.Name(entry)
+	}
+	mod := "module repro\n\ngo " + *lang + "\n"
+	if len(d.require) > 0 {
+		// The versions come from the module pkg is in.
+		cmd := exec.Command("go", append([]string{"list", "-m", "-f", "require {{.Path}} {{.Version}}"}, d.require...)...)
+		if fi, err := os.Stat(pkg); err == nil && fi.IsDir() {
+			cmd.Dir = pkg
+		}
+		out, err := cmd.Output()
+		if err != nil {
+			log.Fatalf("go list -m: %v", err)
+		}
+		mod += "\n" + string(out)
+	}
+	if err := write(dir, d, mod, args, header(pkg, target, input, failure, dir, d)); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(dir)
+}
+
+// run runs target on entry and describes how it failed.
+func run(pkg, target string, entry []byte) (string, error) {
+	ctx := context.Background()
+	tmp, err := os.MkdirTemp("", "repro")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	bin := filepath.Join(tmp, "target.test")
+	if err := crash.Build(ctx, pkg, bin); err != nil {
+		return "", err
+	}
+	r, _, err := crash.Run(ctx, bin, target, entry, *timeout)
+	if err != nil {
+		return "", err
+	}
+	if r == nil {
+		log.Printf("warning: %s passed on this input", target)
+		return "(the target passed when repro ran it)", nil
+	}
+	return r.String(), nil
+}
+
+// header returns the comment that opens main.go.
+func header(pkg, target, input, failure, dir string, d *driver) string {
+	cmd := d.run
+	if cmd == "" {
+		cmd = "go run ."
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "// Reproducer for %s in %s, from %s.\n", target, pkg, filepath.Base(input))
+	b.WriteString("//\n// The target failed with:\n//\n")
+	for l := range strings.Lines(failure) {
+		fmt.Fprintf(&b, "//\t%s", strings.TrimSuffix(l, "\n")+"\n")
+	}
+	fmt.Fprintf(&b, "//\n// Run: cd %s && %s\n\n", dir, cmd)
+	return b.String()
+}
+
+// write creates the reproducer in dir.
+func write(dir string, d *driver, mod string, args []any, header string) error {
+	src := d.main
+	files := map[string][]byte{"go.mod": []byte(mod)}
+	for i, a := range args {
+		var data []byte
+		switch a := a.(type) {
+		case []byte:
+			data = a
+		case string:
+			data = []byte(a)
+		default:
+			return fmt.Errorf("argument %d is a %T, not []byte or string", i, a)
+		}
+		if d.files[i] == "" {
+			src = strings.ReplaceAll(src, "$arg"+strconv.Itoa(i), strconv.Quote(string(data)))
+			continue
+		}
+		files[d.files[i]] = data
+	}
+	main, err := format.Source([]byte(header + src))
+	if err != nil {
+		return fmt.Errorf("formatting main.go: %v", err)
+	}
+	files["main.go"] = main
+	for name, data := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+// Speccov reports which Go language features the generated seeds cover.
+//
+// Usage:
+//
+//	speccov [-n count] [-csv] [pattern ...]
+//	speccov -corpus dir [-csv]
+//
+// The first form runs every generator matching the patterns (default
+// "go/*") count times; the second reads a seedgen corpus and groups seeds
+// by their generator directory. The output is a matrix of features (see
+// package spec) by generator, each cell counting the seeds that use the
+// feature, followed by the features no seed covers.
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+	"github.com/geeknik/fuzzing/spec"
+)
+
+var (
+	count     = flag.Int("n", 20, "seeds to generate per generator")
+	corpusDir = flag.String("corpus", "", "read seeds from `dir` instead of generating them")
+	asCSV     = flag.Bool("csv", false, "write the matrix as CSV")
+)
+
+// A matrix counts seeds per feature and group.
+type matrix struct {
+	groups []string
+	counts map[string]map[string]int // feature -> group -> seeds
+	seeds  map[string]int            // group -> seeds scanned
+}
+
+func (m *matrix) add(group string, files []gen.File) {
+	if _, ok := m.seeds[group]; !ok {
+		m.groups = append(m.groups, group)
+	}
+	m.seeds[group]++
+	seen := spec.Set{}
+	for _, f := range files {
+		if filepath.Ext(f.Name) != ".go" {
+			continue
+		}
+		s, err := spec.Scan(f.Data)
+		if err != nil {
+			continue
+		}
+		for name := range s {
+			seen[name] = true
+		}
+	}
+	for name := range seen {
+		if m.counts[name] == nil {
+			m.counts[name] = map[string]int{}
+		}
+		m.counts[name][group]++
+	}
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("speccov: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: speccov [flags] [pattern ...]\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	m := &matrix{counts: map[string]map[string]int{}, seeds: map[string]int{}}
+	if *corpusDir != "" {
+		seeds, err := corpus.Read(*corpusDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range seeds {
+			m.add(group(*corpusDir, s.Path), s.Files)
+		}
+	} else {
+		patterns := flag.Args()
+		if len(patterns) == 0 {
+			patterns = []string{"go/*"}
+		}
+		gens, err := gen.Match(patterns...)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, g := range gens {
+			for i := range *count {
+				m.add(g.Name, g.Generate(i))
+			}
+		}
+	}
+	sort.Strings(m.groups)
+
+	var err error
+	if *asCSV {
+		err = m.writeCSV()
+	} else {
+		err = m.writeTable()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// group names the generator a corpus seed came from: the directories
+// between the corpus root and the seed's index.
+func group(root, path string) string {
+	rel, err := filepath.Rel(root, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return "."
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (m *matrix) row(f spec.Feature) (total int, cells []string) {
+	for _, g := range m.groups {
+		n := m.counts[f.Name][g]
+		total += n
+		cells = append(cells, strconv.Itoa(n))
+	}
+	return total, cells
+}
+
+func (m *matrix) writeTable() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "feature\ttotal\t%s\t\n", strings.Join(m.groups, "\t"))
+	var missing []string
+	for _, f := range spec.Features {
+		total, cells := m.row(f)
+		if total == 0 {
+			missing = append(missing, f.Name)
+			for i := range cells {
+				cells[i] = "-"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t\n", f.Name, total, strings.Join(cells, "\t"))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("\n%d of %d features covered\n", len(spec.Features)-len(missing), len(spec.Features))
+	for _, name := range missing {
+		fmt.Printf("missing: %s\n", name)
+	}
+	return nil
+}
+
+func (m *matrix) writeCSV() error {
+	w := csv.NewWriter(os.Stdout)
+	w.Write(append([]string{"feature", "total"}, m.groups...))
+	for _, f := range spec.Features {
+		total, cells := m.row(f)
+		w.Write(append([]string{f.Name, strconv.Itoa(total)}, cells...))
+	}
+	w.Flush()
+	return w.Error()
+}
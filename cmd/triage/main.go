@@ -0,0 +1,315 @@
+// Triage runs crashing inputs against a fuzz target and sorts them into
+// buckets by the cause of the crash.
+//
+// Usage:
+//
+//	triage [-o dir] [-j n] [-timeout d] [-min] [-v] [-json file] [-sarif file] pkg FuzzXxx path ...
+//
+// Triage builds the test binary of pkg and runs FuzzXxx on each input
+// found under the paths: files in "go test fuzz v1" form, as `go test
+// -fuzz` leaves in testdata/fuzz/FuzzXxx, or raw inputs, which are run
+// as the single []byte argument. Every failing run is reduced to a
+// signature (package internal/crash): the kind of failure, the panic
+// value or error message with its numbers blanked out, and the top stack
+// frames below the testing machinery. Buckets are printed largest first,
+// each with its smallest input as the exemplar.
+//
+// With -min, the exemplar of each bucket is minimized (package
+// minimize) for as long as it still fails with the same signature; this
+// needs a target whose only argument is []byte. With -o, each bucket gets
+// a directory dir/<signature> holding its exemplar as a corpus entry and
+// the output of running it.
+//
+// With -json and -sarif, the buckets are also written to files as
+// findings (package internal/findings): the kind and signature of each,
+// its exemplar and, with -o and -min, the path of the minimized one, and
+// the generator and seed named by the exemplar's seedgen header, if any.
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/corpus/native"
+	"github.com/geeknik/fuzzing/internal/crash"
+	"github.com/geeknik/fuzzing/internal/findings"
+	"github.com/geeknik/fuzzing/minimize"
+)
+
+var (
+	outDir  = flag.String("o", "", "write one exemplar per bucket under `dir`")
+	jobs    = flag.Int("j", runtime.GOMAXPROCS(0), "`number` of inputs to run at once")
+	timeout = flag.Duration("timeout", 30*time.Second, "per-run time `limit`; a run that exceeds it is a hang")
+	doMin   = flag.Bool("min", false, "minimize each bucket's exemplar (not hangs)")
+	verbose = flag.Bool("v", false, "list every input of each bucket")
+	jsonOut = flag.String("json", "", "write the buckets as JSON findings to `file`")
+	sarif   = flag.String("sarif", "", "write the buckets as SARIF to `file`")
+)
+
+// An input is one crasher, in corpus file form.
+type input struct {
+	path string
+	file []byte // "go test fuzz v1" entry
+}
+
+// A result is what running an input gave.
+type result struct {
+	in     input
+	report *crash.Report // nil if the run passed
+	output []byte
+}
+
+// A bucket is every input that failed with one signature.
+type bucket struct {
+	sig       string
+	report    *crash.Report
+	inputs    []input
+	exemplar  result
+	minimized bool   // the exemplar was reduced from the input at its path
+	saved     string // where save wrote the exemplar
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("triage: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: triage [flags] pkg FuzzXxx path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() < 3 || *jobs < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	pkg, target := flag.Arg(0), flag.Arg(1)
+
+	var inputs []input
+	for _, p := range flag.Args()[2:] {
+		seeds, err := corpus.Read(p)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range seeds {
+			for _, f := range s.Files {
+				inputs = append(inputs, input{path: s.FilePath(f.Name), file: entry(f.Data)})
+			}
+		}
+	}
+	if len(inputs) == 0 {
+		log.Fatal("no inputs")
+	}
+
+	ctx := context.Background()
+	tmp, err := os.MkdirTemp("", "triage")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	r := &runner{bin: filepath.Join(tmp, "target.test"), target: target}
+	if err := crash.Build(ctx, pkg, r.bin); err != nil {
+		log.Fatal(err)
+	}
+
+	results := r.runAll(ctx, inputs)
+	var passed []string
+	buckets := map[string]*bucket{}
+	for _, res := range results {
+		if res.report == nil {
+			passed = append(passed, res.in.path)
+			continue
+		}
+		sig := res.report.Signature()
+		b := buckets[sig]
+		if b == nil {
+			b = &bucket{sig: sig, report: res.report, exemplar: res}
+			buckets[sig] = b
+		}
+		b.inputs = append(b.inputs, res.in)
+		if len(res.in.file) < len(b.exemplar.in.file) {
+			b.exemplar = res
+		}
+	}
+
+	var sorted []*bucket
+	for _, b := range buckets {
+		sorted = append(sorted, b)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i].inputs) != len(sorted[j].inputs) {
+			return len(sorted[i].inputs) > len(sorted[j].inputs)
+		}
+		return sorted[i].sig < sorted[j].sig
+	})
+	var fs []findings.Finding
+	for _, b := range sorted {
+		// The header naming the generator does not survive minimizing.
+		origin := findings.OriginOf(sources(b.exemplar.in.file)...)
+		if *doMin {
+			r.minimize(ctx, b)
+		}
+		report(b)
+		if *outDir != "" {
+			if err := save(*outDir, b); err != nil {
+				log.Fatal(err)
+			}
+		}
+		fs = append(fs, finding(b, origin))
+	}
+	fmt.Printf("%d inputs, %d buckets, %d did not fail\n", len(inputs), len(sorted), len(passed))
+	if *verbose {
+		for _, p := range passed {
+			fmt.Printf("\tpassed: %s\n", p)
+		}
+	}
+	if err := findings.Write(*jsonOut, *sarif, "triage", fs); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// entry returns data as a corpus entry: unchanged if it already is one,
+// and as a single []byte argument otherwise.
+func entry(data []byte) []byte {
+	if _, err := native.Unmarshal(data); err == nil {
+		return data
+	}
+	return native.Bytes(data)
+}
+
+// sources returns the []byte and string arguments of a corpus entry.
+func sources(file []byte) [][]byte {
+	args, _ := native.Unmarshal(file)
+	var srcs [][]byte
+	for _, a := range args {
+		switch a := a.(type) {
+		case []byte:
+			srcs = append(srcs, a)
+		case string:
+			srcs = append(srcs, []byte(a))
+		}
+	}
+	return srcs
+}
+
+// A runner runs inputs through the target of a test binary.
+type runner struct {
+	bin, target string
+}
+
+func (r *runner) runAll(ctx context.Context, inputs []input) []result {
+	results := make([]result, len(inputs))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(*jobs, len(inputs)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				rep, out, err := r.run(ctx, inputs[i].file)
+				if err != nil {
+					log.Fatalf("%s: %v", inputs[i].path, err)
+				}
+				results[i] = result{in: inputs[i], report: rep, output: out}
+			}
+		}()
+	}
+	for i := range inputs {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+	return results
+}
+
+func (r *runner) run(ctx context.Context, file []byte) (*crash.Report, []byte, error) {
+	return crash.Run(ctx, r.bin, r.target, file, *timeout)
+}
+
+// minimize shrinks the exemplar of b while it keeps failing with b's
+// signature. Entries other than a single []byte are left alone, and so
+// are hangs, since every test would wait out the timeout.
+func (r *runner) minimize(ctx context.Context, b *bucket) {
+	if b.report.Kind == "hang" {
+		return
+	}
+	args, err := native.Unmarshal(b.exemplar.in.file)
+	if err != nil || len(args) != 1 {
+		return
+	}
+	src, ok := args[0].([]byte)
+	if !ok {
+		return
+	}
+	red := &minimize.Reducer{Interesting: func(src []byte) bool {
+		rep, _, err := r.run(ctx, native.Bytes(src))
+		return err == nil && rep != nil && rep.Signature() == b.sig
+	}}
+	small := native.Bytes(red.Reduce(src))
+	if len(small) >= len(b.exemplar.in.file) {
+		return
+	}
+	rep, out, err := r.run(ctx, small)
+	if err != nil || rep == nil || rep.Signature() != b.sig {
+		return
+	}
+	b.exemplar = result{in: input{path: b.exemplar.in.path, file: small}, report: rep, output: out}
+	b.minimized = true
+}
+
+func report(b *bucket) {
+	fmt.Printf("%s  %d  %s\n", b.sig, len(b.inputs), b.report)
+	path := b.exemplar.in.path
+	if b.minimized {
+		path += " (minimized)"
+	}
+	fmt.Printf("\texemplar: %s (%d bytes)\n", path, len(b.exemplar.in.file))
+	if *verbose {
+		for _, in := range b.inputs {
+			fmt.Printf("\t%s\n", in.path)
+		}
+	}
+}
+
+// save writes the exemplar of b and its run's output to dir/<signature>.
+func save(dir string, b *bucket) error {
+	d := filepath.Join(dir, b.sig)
+	if err := os.MkdirAll(d, 0o755); err != nil {
+		return err
+	}
+	file := b.exemplar.in.file
+	b.saved = filepath.Join(d, native.Name(file))
+	if err := os.WriteFile(b.saved, file, 0o644); err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "%s\n\n", b.report)
+	out.Write(b.exemplar.output)
+	return os.WriteFile(filepath.Join(d, "output.txt"), out.Bytes(), 0o644)
+}
+
+// finding describes b for package findings. origin is that of the
+// exemplar before minimizing.
+func finding(b *bucket, origin *findings.Origin) findings.Finding {
+	f := findings.Finding{
+		Kind:      b.report.Kind,
+		Message:   b.report.Message,
+		Signature: b.sig,
+		Input:     b.exemplar.in.path,
+		Origin:    origin,
+		Count:     len(b.inputs),
+		Frames:    b.report.Frames,
+	}
+	if b.minimized {
+		f.Minimized = b.saved
+	}
+	return f
+}
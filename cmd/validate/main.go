@@ -0,0 +1,108 @@
+// Validate checks the seeds of a corpus, generated or hand-written, the
+// way seedgen -validate checks the seeds it generates.
+//
+// Usage:
+//
+//	validate [-level name] [-delete | -o dir] [-v] path ...
+//
+// Every seed that fails package validate at -level (parse, types or vet;
+// types by default) is listed with the reason:
+//
+//	reject corpus/hand/0003: types: main.go:12:2: declared and not used: x
+//
+// Without -delete or -o, validate only reports. -delete removes the
+// rejected seeds in place, and -o copies the seeds that pass into dir.
+// With -v, seeds that pass are listed too. Validate exits with status 1 if
+// any seed is rejected.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/validate"
+)
+
+var (
+	level   = flag.String("level", "types", "validate up to `level`: parse, types or vet")
+	del     = flag.Bool("delete", false, "remove rejected seeds in place")
+	outDir  = flag.String("o", "", "copy the seeds that pass into `dir` instead")
+	verbose = flag.Bool("v", false, "list the seeds that pass too")
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("validate: ")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: validate [flags] path ...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *del && *outDir != "" {
+		log.Fatal("-delete and -o are mutually exclusive")
+	}
+	lvl, err := validate.ParseLevel(*level)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+	var total, rejected int
+	for _, root := range flag.Args() {
+		seeds, err := corpus.Read(root)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, s := range seeds {
+			total++
+			err := validate.Check(ctx, s.Files, lvl)
+			if _, ok := err.(*validate.Error); ok {
+				rejected++
+				fmt.Printf("reject %s: %v\n", s.Path, err)
+				if *del {
+					if err := os.RemoveAll(s.Path); err != nil {
+						log.Fatal(err)
+					}
+				}
+				continue
+			}
+			if err != nil {
+				log.Fatal(err)
+			}
+			if *verbose {
+				fmt.Printf("ok %s\n", s.Path)
+			}
+			if *outDir != "" {
+				if err := copySeed(root, s); err != nil {
+					log.Fatal(err)
+				}
+			}
+		}
+	}
+	fmt.Printf("%d seeds, %d rejected at level %s\n", total, rejected, lvl)
+	if rejected > 0 {
+		os.Exit(1)
+	}
+}
+
+// copySeed writes s under -o at its path relative to root.
+func copySeed(root string, s corpus.Seed) error {
+	rel, err := filepath.Rel(root, s.Path)
+	if err != nil || rel == "." {
+		rel = filepath.Base(s.Path)
+	}
+	dst := filepath.Join(*outDir, rel)
+	if len(s.Files) == 1 && filepath.Base(s.Path) == s.Files[0].Name {
+		dst = filepath.Dir(dst)
+	}
+	return s.Write(dst)
+}
@@ -0,0 +1,120 @@
+// Package corpus reads seed corpora from disk.
+//
+// Corpora written by seedgen hold single-file seeds as plain files and
+// multi-file seeds as directories named by their decimal index; any other
+// regular file found while walking is treated as a single-file seed too,
+// so hand-written seed directories work as well.
+package corpus
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A Seed is one corpus entry read from disk.
+type Seed struct {
+	// Path is the file or, for multi-file seeds, directory the seed was
+	// read from.
+	Path  string
+	Files []gen.File
+}
+
+// Read returns every seed under root, which may also name a single file.
+func Read(root string) ([]Seed, error) {
+	var seeds []Seed
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if path != root && isIndex(d.Name()) {
+				s, err := readDir(path)
+				if err != nil {
+					return err
+				}
+				seeds = append(seeds, s)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		seeds = append(seeds, Seed{Path: path, Files: []gen.File{{Name: d.Name(), Data: data}}})
+		return nil
+	})
+	return seeds, err
+}
+
+func readDir(dir string) (Seed, error) {
+	s := Seed{Path: dir}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		s.Files = append(s.Files, gen.File{Name: filepath.ToSlash(rel), Data: data})
+		return nil
+	})
+	return s, err
+}
+
+func isIndex(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Write stores the files of s under dir, creating directories as needed.
+func (s Seed) Write(dir string) error {
+	for _, f := range s.Files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, f.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has reports whether s contains a file with the given name.
+func (s Seed) Has(name string) bool {
+	for _, f := range s.Files {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// FilePath returns the path the file of s with the given name was read
+// from.
+func (s Seed) FilePath(name string) string {
+	if len(s.Files) == 1 && filepath.Base(s.Path) == name {
+		return s.Path
+	}
+	return filepath.Join(s.Path, filepath.FromSlash(name))
+}
@@ -0,0 +1,288 @@
+// Package native reads and writes the corpus files of Go's native
+// fuzzing, the "go test fuzz v1" entries `go test -fuzz` keeps in
+// testdata/fuzz/FuzzXxx and in its cache:
+//
+//	go test fuzz v1
+//	[]byte("package p\n")
+//	int(3)
+//
+// Each line after the header holds one argument of the fuzz target as a
+// Go conversion of a literal. Marshal writes the forms `go test` writes;
+// Unmarshal also reads the others it accepts, such as int32(97) for a
+// rune or byte(0x61).
+package native
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+// Header is the first line of every file.
+const Header = "go test fuzz v1"
+
+// Marshal returns a corpus file holding args, which must be of the types
+// a fuzz target may take: []byte, string, bool, the integer types and
+// the float types.
+func Marshal(args ...any) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("native: no arguments")
+	}
+	b := []byte(Header + "\n")
+	for _, a := range args {
+		switch a := a.(type) {
+		case []byte:
+			b = fmt.Appendf(b, "[]byte(%q)\n", a)
+		case string:
+			b = fmt.Appendf(b, "string(%q)\n", a)
+		case byte:
+			b = fmt.Appendf(b, "byte(%q)\n", a)
+		case rune:
+			// A quoted rune cannot hold a surrogate half or a value past
+			// U+10FFFF; %q would quote U+FFFD instead.
+			if utf8.ValidRune(a) {
+				b = fmt.Appendf(b, "rune(%q)\n", a)
+			} else {
+				b = fmt.Appendf(b, "int32(%d)\n", a)
+			}
+		case bool, int, int8, int16, int64, uint, uint16, uint32, uint64:
+			b = fmt.Appendf(b, "%T(%v)\n", a, a)
+		case float32:
+			// A NaN other than the one math.NaN gives is kept bit for bit.
+			if math.IsNaN(float64(a)) && math.Float32bits(a) != math.Float32bits(float32(math.NaN())) {
+				b = fmt.Appendf(b, "math.Float32frombits(0x%x)\n", math.Float32bits(a))
+			} else {
+				b = fmt.Appendf(b, "float32(%v)\n", a)
+			}
+		case float64:
+			if math.IsNaN(a) && math.Float64bits(a) != math.Float64bits(math.NaN()) {
+				b = fmt.Appendf(b, "math.Float64frombits(0x%x)\n", math.Float64bits(a))
+			} else {
+				b = fmt.Appendf(b, "float64(%v)\n", a)
+			}
+		default:
+			return nil, fmt.Errorf("native: unsupported argument type %T", a)
+		}
+	}
+	return b, nil
+}
+
+// Bytes returns a corpus file holding data as the single []byte argument
+// of a target such as func(t *testing.T, src []byte).
+func Bytes(data []byte) []byte {
+	b, _ := Marshal(data)
+	return b
+}
+
+// Name returns the file name `go test` gives file: the first 16 hex
+// digits of its SHA-256.
+func Name(file []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(file))[:16]
+}
+
+// Unmarshal returns the arguments held in a corpus file, with the types
+// their lines name; byte and rune arguments are uint8 and int32.
+func Unmarshal(file []byte) ([]any, error) {
+	lines := bytes.Split(file, []byte("\n"))
+	if string(bytes.TrimSuffix(lines[0], []byte("\r"))) != Header {
+		return nil, fmt.Errorf("native: missing %q header", Header)
+	}
+	var args []any
+	for _, l := range lines[1:] {
+		l = bytes.TrimSpace(l)
+		if len(l) == 0 {
+			continue
+		}
+		a, err := parseArg(string(l))
+		if err != nil {
+			return nil, fmt.Errorf("native: %q: %v", l, err)
+		}
+		args = append(args, a)
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("native: no arguments")
+	}
+	return args, nil
+}
+
+// parseArg reads one argument line: a conversion of a literal, possibly
+// negated, or of one of the identifiers true, false, NaN and Inf.
+func parseArg(line string) (any, error) {
+	e, err := parser.ParseExpr(line)
+	if err != nil {
+		return nil, err
+	}
+	call, ok := e.(*ast.CallExpr)
+	if !ok || len(call.Args) != 1 || call.Ellipsis.IsValid() {
+		return nil, fmt.Errorf("not a conversion")
+	}
+	typ, err := typeName(call.Fun)
+	if err != nil {
+		return nil, err
+	}
+	val, kind, err := literal(call.Args[0])
+	if err != nil {
+		return nil, err
+	}
+	return convert(typ, val, kind)
+}
+
+// typeName returns the name of the conversion's type, with "[]byte" for
+// a byte slice and "float32bits" and "float64bits" for the math
+// functions that turn bits into floats.
+func typeName(fun ast.Expr) (string, error) {
+	switch fun := fun.(type) {
+	case *ast.Ident:
+		return fun.Name, nil
+	case *ast.ArrayType:
+		if elt, ok := fun.Elt.(*ast.Ident); ok && fun.Len == nil && elt.Name == "byte" {
+			return "[]byte", nil
+		}
+	case *ast.SelectorExpr:
+		if x, ok := fun.X.(*ast.Ident); ok && x.Name == "math" {
+			switch fun.Sel.Name {
+			case "Float32frombits":
+				return "float32bits", nil
+			case "Float64frombits":
+				return "float64bits", nil
+			}
+		}
+	}
+	return "", fmt.Errorf("unsupported type")
+}
+
+// literal returns the text and kind of an argument. The identifiers
+// true, false, NaN and Inf have kind IDENT.
+func literal(x ast.Expr) (string, token.Token, error) {
+	sign := ""
+	if u, ok := x.(*ast.UnaryExpr); ok && (u.Op == token.SUB || u.Op == token.ADD) {
+		sign, x = u.Op.String(), u.X
+	}
+	switch x := x.(type) {
+	case *ast.BasicLit:
+		if sign == "+" || sign != "" && x.Kind != token.INT && x.Kind != token.FLOAT {
+			break
+		}
+		return sign + x.Value, x.Kind, nil
+	case *ast.Ident:
+		if sign != "" && x.Name != "Inf" {
+			break
+		}
+		if x.Name == "Inf" && sign == "" {
+			sign = "+"
+		}
+		return sign + x.Name, token.IDENT, nil
+	}
+	return "", 0, fmt.Errorf("not a literal")
+}
+
+func convert(typ, val string, kind token.Token) (any, error) {
+	switch typ {
+	case "[]byte", "string":
+		if kind != token.STRING {
+			return nil, fmt.Errorf("%s of a non-string", typ)
+		}
+		s, err := strconv.Unquote(val)
+		if typ == "[]byte" {
+			return []byte(s), err
+		}
+		return s, err
+	case "bool":
+		if kind == token.IDENT && (val == "true" || val == "false") {
+			return val == "true", nil
+		}
+		return nil, fmt.Errorf("bool of %s", val)
+	case "byte", "rune":
+		if kind == token.CHAR {
+			r, _, _, err := strconv.UnquoteChar(val[1:len(val)-1], '\'')
+			if err != nil {
+				return nil, err
+			}
+			if typ == "rune" {
+				return r, nil
+			}
+			if r > 0xff {
+				return nil, fmt.Errorf("byte of %s", val)
+			}
+			return byte(r), nil
+		}
+		if typ == "rune" {
+			typ = "int32"
+		} else {
+			typ = "uint8"
+		}
+	case "float32", "float64":
+		if kind != token.INT && kind != token.FLOAT && (kind != token.IDENT || val == "true" || val == "false") {
+			return nil, fmt.Errorf("%s of %s", typ, val)
+		}
+		bits := 64
+		if typ == "float32" {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(val, bits)
+		if err != nil {
+			return nil, err
+		}
+		if bits == 32 {
+			return float32(f), nil
+		}
+		return f, nil
+	case "float32bits", "float64bits":
+		if kind != token.INT {
+			return nil, fmt.Errorf("%s of %s", typ, val)
+		}
+		if typ == "float32bits" {
+			n, err := strconv.ParseUint(val, 0, 32)
+			return math.Float32frombits(uint32(n)), err
+		}
+		n, err := strconv.ParseUint(val, 0, 64)
+		return math.Float64frombits(n), err
+	}
+	if kind != token.INT {
+		return nil, fmt.Errorf("%s of %s", typ, val)
+	}
+	return integer(typ, val)
+}
+
+// integer parses val as an integer of the named type. An int is read as
+// 64 bits whatever the platform, as `go test` does, so that corpora
+// written on 64-bit machines load everywhere.
+func integer(typ, val string) (any, error) {
+	switch typ {
+	case "int":
+		n, err := strconv.ParseInt(val, 0, 64)
+		return int(n), err
+	case "int8":
+		n, err := strconv.ParseInt(val, 0, 8)
+		return int8(n), err
+	case "int16":
+		n, err := strconv.ParseInt(val, 0, 16)
+		return int16(n), err
+	case "int32":
+		n, err := strconv.ParseInt(val, 0, 32)
+		return int32(n), err
+	case "int64":
+		return strconv.ParseInt(val, 0, 64)
+	case "uint":
+		n, err := strconv.ParseUint(val, 0, 64)
+		return uint(n), err
+	case "uint8":
+		n, err := strconv.ParseUint(val, 0, 8)
+		return uint8(n), err
+	case "uint16":
+		n, err := strconv.ParseUint(val, 0, 16)
+		return uint16(n), err
+	case "uint32":
+		n, err := strconv.ParseUint(val, 0, 32)
+		return uint32(n), err
+	case "uint64":
+		return strconv.ParseUint(val, 0, 64)
+	}
+	return nil, fmt.Errorf("unsupported type %s", typ)
+}
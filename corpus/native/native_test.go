@@ -0,0 +1,170 @@
+package native
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+var roundTrips = []struct {
+	line string
+	arg  any
+}{
+	{`[]byte("")`, []byte{}},
+	{`[]byte("package p\n\x00\xff")`, []byte("package p\n\x00\xff")},
+	{`string("")`, ""},
+	{`string("a\"bé\xff")`, "a\"bé\xff"},
+	{`bool(true)`, true},
+	{`bool(false)`, false},
+	{`byte('a')`, byte('a')},
+	{`byte('\x00')`, byte(0)},
+	{`byte('ÿ')`, byte(0xff)},
+	{`rune('a')`, 'a'},
+	{`rune('\U0010ffff')`, rune(0x10ffff)},
+	{`int32(55296)`, rune(0xd800)},
+	{`int32(1114112)`, rune(0x110000)},
+	{`int32(-1)`, rune(-1)},
+	{`int(0)`, 0},
+	{`int(-9223372036854775808)`, math.MinInt64},
+	{`int8(-128)`, int8(math.MinInt8)},
+	{`int16(32767)`, int16(math.MaxInt16)},
+	{`int64(9223372036854775807)`, int64(math.MaxInt64)},
+	{`uint(18446744073709551615)`, uint(math.MaxUint64)},
+	{`uint16(65535)`, uint16(math.MaxUint16)},
+	{`uint32(4294967295)`, uint32(math.MaxUint32)},
+	{`uint64(18446744073709551615)`, uint64(math.MaxUint64)},
+	{`float32(1.5)`, float32(1.5)},
+	{`float32(-0)`, float32(math.Copysign(0, -1))},
+	{`float32(+Inf)`, float32(math.Inf(1))},
+	{`float32(-Inf)`, float32(math.Inf(-1))},
+	{`float32(NaN)`, float32(math.NaN())},
+	{`math.Float32frombits(0x7fc00001)`, math.Float32frombits(0x7fc00001)},
+	{`float64(0.1)`, 0.1},
+	{`float64(-0)`, math.Copysign(0, -1)},
+	{`float64(+Inf)`, math.Inf(1)},
+	{`float64(-Inf)`, math.Inf(-1)},
+	{`float64(NaN)`, math.NaN()},
+	{`float64(5e-324)`, 5e-324},
+	{`math.Float64frombits(0x7ff8000000000002)`, math.Float64frombits(0x7ff8000000000002)},
+	{`math.Float64frombits(0xfff0000000000001)`, math.Float64frombits(0xfff0000000000001)},
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, tt := range roundTrips {
+		file := Header + "\n" + tt.line + "\n"
+		b, err := Marshal(tt.arg)
+		if err != nil {
+			t.Errorf("Marshal(%T(%v)): %v", tt.arg, tt.arg, err)
+			continue
+		}
+		if string(b) != file {
+			t.Errorf("Marshal(%T(%v)) = %q, want %q", tt.arg, tt.arg, b, file)
+		}
+		args, err := Unmarshal([]byte(file))
+		if err != nil {
+			t.Errorf("Unmarshal(%q): %v", file, err)
+			continue
+		}
+		if len(args) != 1 || !same(args[0], tt.arg) {
+			t.Errorf("Unmarshal(%q) = %#v, want %#v", file, args, tt.arg)
+		}
+	}
+}
+
+func TestRoundTripAll(t *testing.T) {
+	var want []any
+	for _, tt := range roundTrips {
+		want = append(want, tt.arg)
+	}
+	b, err := Marshal(want...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Unmarshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Unmarshal gives %d arguments, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !same(got[i], want[i]) {
+			t.Errorf("argument %d: got %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestUnmarshalForms checks forms Unmarshal reads that Marshal does not
+// write.
+func TestUnmarshalForms(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		arg  any
+	}{
+		{`byte(0x61)`, byte('a')},
+		{`uint8(97)`, byte('a')},
+		{`int32(97)`, 'a'},
+		{`rune(97)`, 'a'},
+		{`float64(1)`, 1.0},
+		{`float64(0x1p-2)`, 0.25},
+		{`float32(Inf)`, float32(math.Inf(1))},
+		{`int(0x7f)`, 127},
+		{`math.Float64frombits(9221120237041090562)`, math.Float64frombits(0x7ff8000000000002)},
+	} {
+		file := Header + "\n" + tt.line + "\n"
+		args, err := Unmarshal([]byte(file))
+		if err != nil {
+			t.Errorf("Unmarshal(%q): %v", file, err)
+			continue
+		}
+		if len(args) != 1 || !same(args[0], tt.arg) {
+			t.Errorf("Unmarshal(%q) = %#v, want %#v", file, args, tt.arg)
+		}
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	for _, file := range []string{
+		"",
+		"go test fuzz v2\nint(1)\n",
+		Header + "\n",
+		Header + "\nint8(128)\n",
+		Header + "\nbyte('Ā')\n",
+		Header + "\nbool(1)\n",
+		Header + "\nstring(1)\n",
+		Header + "\nfloat64(true)\n",
+		Header + "\n-int(1)\n",
+		Header + "\nint(+1)\n",
+		Header + "\ncomplex128(1)\n",
+		Header + "\nint(1, 2)\n",
+	} {
+		if args, err := Unmarshal([]byte(file)); err == nil {
+			t.Errorf("Unmarshal(%q) = %#v, want an error", file, args)
+		}
+	}
+}
+
+func TestMarshalErrors(t *testing.T) {
+	if _, err := Marshal(); err == nil {
+		t.Error("Marshal() succeeds, want an error")
+	}
+	if _, err := Marshal(complex(1, 2)); err == nil {
+		t.Error("Marshal(complex128) succeeds, want an error")
+	}
+}
+
+// same reports whether a and b have the same type and value, comparing
+// floats bit for bit.
+func same(a, b any) bool {
+	if fmt.Sprintf("%T", a) != fmt.Sprintf("%T", b) {
+		return false
+	}
+	switch a := a.(type) {
+	case float32:
+		return math.Float32bits(a) == math.Float32bits(b.(float32))
+	case float64:
+		return math.Float64bits(a) == math.Float64bits(b.(float64))
+	}
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,120 @@
+package corpus
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"hash"
+	"path"
+	"regexp"
+	"sort"
+)
+
+// A Shape is a hash of a seed's structure: two seeds with the same Shape
+// differ at most in identifier spelling and literal values.
+type Shape [sha256.Size]byte
+
+func (s Shape) String() string {
+	return fmt.Sprintf("%x", s[:8])
+}
+
+// ShapeOf returns the Shape of s. Go files are hashed by normalized AST:
+// non-predeclared identifiers are renamed in order of first appearance,
+// literals are reduced to their kind (import paths excepted), and digit
+// runs in comments are collapsed so that directives and cgo preambles
+// still count. Go files that do not parse, and all other files, are
+// hashed by content.
+func ShapeOf(s Seed) Shape {
+	files := make([]int, len(s.Files))
+	for i := range files {
+		files[i] = i
+	}
+	sort.Slice(files, func(i, j int) bool { return s.Files[files[i]].Name < s.Files[files[j]].Name })
+
+	h := sha256.New()
+	for _, i := range files {
+		f := s.Files[i]
+		// Only the directory structure and extension of the name count:
+		// seedgen names single-file seeds by index.
+		fmt.Fprintf(h, "file %s%s\n", path.Dir(f.Name), path.Ext(f.Name))
+		if path.Ext(f.Name) != ".go" || !shapeGo(h, f.Data) {
+			h.Write(f.Data)
+		}
+		h.Write([]byte{0})
+	}
+	var sh Shape
+	h.Sum(sh[:0])
+	return sh
+}
+
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// shapeGo writes the normalized AST of src to h, reporting false if src
+// does not parse.
+func shapeGo(h hash.Hash, src []byte) bool {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return false
+	}
+	names := map[string]int{}
+	imports := map[*ast.BasicLit]bool{}
+	for _, imp := range f.Imports {
+		imports[imp.Path] = true
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			h.Write([]byte{')'})
+			return true
+		}
+		fmt.Fprintf(h, "(%T", n)
+		switch n := n.(type) {
+		case *ast.Ident:
+			if n.Name == "_" || types.Universe.Lookup(n.Name) != nil {
+				fmt.Fprintf(h, " %s", n.Name)
+				break
+			}
+			id, ok := names[n.Name]
+			if !ok {
+				id = len(names)
+				names[n.Name] = id
+			}
+			fmt.Fprintf(h, " #%d", id)
+		case *ast.BasicLit:
+			if imports[n] {
+				fmt.Fprintf(h, " %s", n.Value)
+			} else {
+				fmt.Fprintf(h, " %s", n.Kind)
+			}
+		case *ast.BinaryExpr:
+			fmt.Fprintf(h, " %s", n.Op)
+		case *ast.UnaryExpr:
+			fmt.Fprintf(h, " %s", n.Op)
+		case *ast.AssignStmt:
+			fmt.Fprintf(h, " %s", n.Tok)
+		case *ast.IncDecStmt:
+			fmt.Fprintf(h, " %s", n.Tok)
+		case *ast.BranchStmt:
+			fmt.Fprintf(h, " %s", n.Tok)
+		case *ast.RangeStmt:
+			fmt.Fprintf(h, " %s", n.Tok)
+		case *ast.GenDecl:
+			fmt.Fprintf(h, " %s", n.Tok)
+		case *ast.ChanType:
+			fmt.Fprintf(h, " %d", n.Dir)
+		}
+		return true
+	})
+	// ast.Inspect skips comments that are not doc comments, so hash them
+	// all here.
+	for _, g := range f.Comments {
+		for _, c := range g.List {
+			h.Write(digits.ReplaceAll([]byte(c.Text), []byte("0")))
+			h.Write([]byte{'\n'})
+		}
+	}
+	return true
+}
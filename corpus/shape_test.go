@@ -0,0 +1,67 @@
+package corpus
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+var shapes = []struct {
+	a, b string
+	same bool
+}{
+	{
+		"package p\n\nfunc f(x int) int { return x + 1 }\n",
+		"package q\n\nfunc g(y int) int { return y + 1 }\n",
+		true,
+	},
+	{
+		"package p\n\ntype T struct{ a, b string }\n\nvar v T\n",
+		"package z\n\ntype Node struct{ left, right string }\n\nvar root Node\n",
+		true,
+	},
+	{
+		"package p\n\nimport \"fmt\"\n\nfunc main() { fmt.Println(\"hi\", 1) }\n",
+		"package p\n\nimport \"fmt\"\n\nfunc run() { fmt.Println(\"bye\", 22) }\n",
+		true,
+	},
+	{
+		// Predeclared identifiers keep their names.
+		"package p\n\nvar x int\n",
+		"package p\n\nvar x string\n",
+		false,
+	},
+	{
+		// Renaming is consistent: x, x is not x, y.
+		"package p\n\nfunc f(x int) int { return x + x }\n",
+		"package p\n\nfunc f(x int) int { return x + y }\n",
+		false,
+	},
+	{
+		"package p\n\nfunc f(x int) int { return x + 1 }\n",
+		"package p\n\nfunc f(x int) int { return x - 1 }\n",
+		false,
+	},
+	{
+		"package p\n\nimport \"fmt\"\n",
+		"package p\n\nimport \"os\"\n",
+		false,
+	},
+	{
+		"package p\n\n//go:noinline\nfunc f() {}\n",
+		"package p\n\n//go:nosplit\nfunc f() {}\n",
+		false,
+	},
+}
+
+func TestShapeOf(t *testing.T) {
+	seed := func(src string) Seed {
+		return Seed{Files: []gen.File{{Name: "0.go", Data: []byte(src)}}}
+	}
+	for _, tt := range shapes {
+		a, b := ShapeOf(seed(tt.a)), ShapeOf(seed(tt.b))
+		if (a == b) != tt.same {
+			t.Errorf("ShapeOf(%q) = %v, ShapeOf(%q) = %v; want same %v", tt.a, a, tt.b, b, tt.same)
+		}
+	}
+}
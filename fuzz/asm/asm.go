@@ -0,0 +1,170 @@
+// Package asm is a fuzz target for the Plan 9 assembler and vet's asmdecl
+// analyzer. Check runs asmdecl over an assembly file and the Go file
+// declaring its functions, which must not panic and must only report
+// positions inside the two files, and then assembles the file with the
+// toolchain's own assembler, which must reject bad input with an error
+// rather than crash.
+package asm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/asmdecl"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Arches are the architectures Check assembles for. Any other arch
+// passed to Check is treated as the first.
+var Arches = []string{"amd64", "arm64"}
+
+// Timeout bounds the asmdecl run and the assembler process.
+var Timeout = 10 * time.Second
+
+// imports is shared between runs so that standard library export data is
+// only loaded once per process.
+var imports = importer.Default()
+
+// Check checks the assembly file asmSrc for arch against the Go
+// prototypes in goSrc. It returns a *harness.Failure if asmdecl or the
+// assembler crashes or hangs. Diagnostics about the input are expected
+// and ignored.
+func Check(goSrc, asmSrc []byte, arch string) error {
+	if !slices.Contains(Arches, arch) {
+		arch = Arches[0]
+	}
+	if err := vet(goSrc, asmSrc, arch); err != nil {
+		return err
+	}
+	return assemble(asmSrc, arch)
+}
+
+func vet(goSrc, asmSrc []byte, arch string) error {
+	name := "asm_" + arch + ".s"
+	fset := token.NewFileSet()
+	var files []*ast.File
+	// asmdecl still checks the assembly when there are no prototypes.
+	if f, err := parser.ParseFile(fset, "seed.go", goSrc, parser.SkipObjectResolution); err == nil {
+		files = append(files, f)
+	}
+	conf := types.Config{Importer: imports, FakeImportC: true, Error: func(error) {}}
+	info := &types.Info{
+		Types: map[ast.Expr]types.TypeAndValue{},
+		Defs:  map[*ast.Ident]types.Object{},
+		Uses:  map[*ast.Ident]types.Object{},
+	}
+	pkg, _ := conf.Check("p", fset, files, info)
+
+	var diags []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer:   asmdecl.Analyzer,
+		Fset:       fset,
+		Files:      files,
+		OtherFiles: []string{name},
+		ReadFile: func(filename string) ([]byte, error) {
+			if filename != name {
+				return nil, os.ErrNotExist
+			}
+			return asmSrc, nil
+		},
+		Pkg:        pkg,
+		TypesInfo:  info,
+		TypesSizes: types.SizesFor("gc", arch),
+		ResultOf:   map[*analysis.Analyzer]any{},
+		Report:     func(d analysis.Diagnostic) { diags = append(diags, d) },
+	}
+	err := harness.Run(Timeout, func() error {
+		_, err := asmdecl.Analyzer.Run(pass)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	for _, d := range diags {
+		f := fset.File(d.Pos)
+		if f == nil || (f.Name() != name && f.Name() != "seed.go") {
+			return fmt.Errorf("asmdecl reported %q at a position outside the input: %d", d.Message, d.Pos)
+		}
+	}
+	return nil
+}
+
+// toolchain returns GOROOT and GOTOOLDIR of the go command on $PATH.
+var toolchain = sync.OnceValues(func() ([]string, error) {
+	out, err := exec.Command("go", "env", "GOROOT", "GOTOOLDIR").Output()
+	if err != nil {
+		return nil, err
+	}
+	dirs := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(dirs) != 2 {
+		return nil, fmt.Errorf("unexpected go env output %q", out)
+	}
+	return dirs, nil
+})
+
+// assemble runs the assembler over src the way the go command does for
+// a package in GOPATH mode. Without a go command, as on OSS-Fuzz runners,
+// it does nothing.
+func assemble(src []byte, arch string) error {
+	dirs, err := toolchain()
+	if err != nil {
+		return nil
+	}
+	goroot, tooldir := dirs[0], dirs[1]
+	tmp, err := os.MkdirTemp("", "fuzzasm")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "asm_"+arch+".s")
+	if err := os.WriteFile(file, src, 0o666); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, filepath.Join(tooldir, "asm"),
+		"-p", "p",
+		"-I", filepath.Join(goroot, "pkg", "include"),
+		"-D", "GOOS_linux", "-D", "GOARCH_"+arch,
+		"-o", filepath.Join(tmp, "asm.o"),
+		file)
+	cmd.Env = append(os.Environ(), "GOOS=linux", "GOARCH="+arch)
+	out, err := cmd.CombinedOutput()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &harness.Failure{Kind: harness.Hang, After: Timeout}
+	}
+	if err == nil {
+		return nil
+	}
+	// The assembler reports bad input with exit status 1; a Go panic
+	// exits with status 2 and a runtime fatal error or signal otherwise.
+	if ee := (*exec.ExitError)(nil); errors.As(err, &ee) && ee.ExitCode() == 1 && !isCrash(out) {
+		return nil
+	}
+	return &harness.Failure{Kind: harness.Panic, Value: fmt.Sprintf("%v\n%s", err, out)}
+}
+
+func isCrash(out []byte) bool {
+	for l := range strings.Lines(string(out)) {
+		if strings.HasPrefix(l, "panic: ") || strings.HasPrefix(l, "fatal error: ") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package asm
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzAssemble(f *testing.F) {
+	g := gen.Lookup("go/asm")
+	for i := range 8 {
+		files := g.Generate(i)
+		var goSrc []byte
+		for _, file := range files {
+			if path.Ext(file.Name) == ".go" {
+				goSrc = file.Data
+			}
+		}
+		for _, file := range files {
+			if path.Ext(file.Name) == ".s" {
+				arch := strings.TrimSuffix(strings.TrimPrefix(file.Name, "asm_"), ".s")
+				f.Add(goSrc, file.Data, arch)
+			}
+		}
+	}
+	f.Fuzz(func(t *testing.T, goSrc, asmSrc []byte, arch string) {
+		if err := Check(goSrc, asmSrc, arch); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+// Package asn1 is a fuzz target for encoding/asn1. CheckUnmarshal reads
+// the first element of the data as a RawValue, which must marshal back
+// to the bytes it was read from, and unmarshals it into Value, whose
+// fields cover the types and tag options the package supports: what it
+// unmarshals to must marshal to DER that unmarshals and marshals again
+// to the same bytes.
+package asn1
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// Value has a field of each type encoding/asn1 supports, fields with
+// each tag option, and a sequence of itself.
+type Value struct {
+	N     int64
+	Big   *big.Int
+	B     bool
+	Bits  asn1.BitString
+	OID   asn1.ObjectIdentifier
+	Enum  asn1.Enumerated
+	UTF8  string    `asn1:"utf8"`
+	IA5   string    `asn1:"ia5"`
+	Print string    `asn1:"printable"`
+	Num   string    `asn1:"numeric"`
+	UTC   time.Time `asn1:"utc"`
+	Gen   time.Time `asn1:"generalized"`
+	Bytes []byte
+	Raw   asn1.RawValue
+	Set   []int   `asn1:"set"`
+	Opt   int     `asn1:"optional,explicit,tag:0"`
+	Def   int     `asn1:"optional,default:7,tag:1"`
+	Kids  []Value `asn1:"optional,explicit,tag:2"`
+}
+
+// CheckUnmarshal checks the first element of data, as a RawValue and as
+// a Value. Data that does not unmarshal, and values the marshaler
+// rejects, such as a UTC time past 2049, are not checked.
+func CheckUnmarshal(data []byte) error {
+	var raw asn1.RawValue
+	rest, err := asn1.Unmarshal(data, &raw)
+	if err != nil {
+		return nil
+	}
+	if read := data[:len(data)-len(rest)]; !bytes.Equal(raw.FullBytes, read) {
+		return fmt.Errorf("a RawValue read from %x has FullBytes %x", read, raw.FullBytes)
+	}
+	out, err := asn1.Marshal(asn1.RawValue{Class: raw.Class, Tag: raw.Tag, IsCompound: raw.IsCompound, Bytes: raw.Bytes})
+	if err != nil {
+		return fmt.Errorf("Marshal of a RawValue read from %x: %v", raw.FullBytes, err)
+	}
+	if !bytes.Equal(out, raw.FullBytes) {
+		return fmt.Errorf("a RawValue read from %x marshals to %x", raw.FullBytes, out)
+	}
+
+	var v Value
+	if _, err := asn1.Unmarshal(data, &v); err != nil {
+		return nil
+	}
+	once, err := asn1.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var w Value
+	rest, err = asn1.Unmarshal(once, &w)
+	if err != nil || len(rest) > 0 {
+		return fmt.Errorf("%+v marshals to %x, which does not unmarshal: %v (%d bytes left)", v, once, err, len(rest))
+	}
+	twice, err := asn1.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("Marshal of %+v: %v", w, err)
+	}
+	if !bytes.Equal(once, twice) {
+		return fmt.Errorf("marshaling again gives\n%x\nnot\n%x", twice, once)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package asn1
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/asn1src"
+)
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, src := range gen.Sample("asn1/*", ".der", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckUnmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
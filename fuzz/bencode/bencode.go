@@ -0,0 +1,239 @@
+// Package bencode is a fuzz target for the bencode decoders of
+// github.com/anacrolix/torrent, github.com/jackpal/bencode-go and
+// github.com/zeebo/bencode, which read the metainfo files and DHT
+// messages that BitTorrent clients, trackers and crawlers take from
+// anyone. CheckBencode reads a value with each and compares it with the
+// harness's reader of BEP 3. anacrolix's Unmarshal must decode the value
+// exactly when the reader finds it well-formed, with its keys in order
+// and nothing after it, to the same value, and its Decoder must read a
+// sequence of values where the reader finds each ends. jackpal's and
+// zeebo's decoders read the first value and leave the rest; they must
+// decode it if it is well-formed, to the same value. What each decodes
+// must encode as bencode the reader finds well-formed and in order, and
+// decode the same; a value that already was must encode as itself, as
+// an infohash, which is taken over the bytes, depends on.
+package bencode
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+	"time"
+
+	anacrolix "github.com/anacrolix/torrent/bencode"
+	"github.com/geeknik/fuzzing/internal/harness"
+	jackpal "github.com/jackpal/bencode-go"
+	zeebo "github.com/zeebo/bencode"
+)
+
+// Timeout bounds checking one input.
+var Timeout = 10 * time.Second
+
+// maxDepth is the deepest CheckBencode has a value nest.
+const maxDepth = 1000
+
+// CheckBencode checks the bencoded value in data.
+func CheckBencode(data []byte) error {
+	return harness.Run(Timeout, func() error { return checkBencode(data) })
+}
+
+func checkBencode(data []byte) error {
+	r := &reader{data: data}
+	want, ok := r.value(0)
+	// Known: none of the three limits how deep lists and dicts nest, and
+	// each reads them by recursion, growing the stack with each level, a
+	// byte of input apiece.
+	if r.deep {
+		return nil
+	}
+	end := r.off
+	sorted := !r.unordered && !r.repeated
+
+	var v any
+	err := anacrolix.Unmarshal(data, &v)
+	whole := ok && end == len(data) && sorted
+	switch {
+	case whole && err != nil:
+		return fmt.Errorf("anacrolix Unmarshal: %v, but the value reads as %#v", err, want)
+	case !whole && err == nil:
+		return fmt.Errorf("anacrolix Unmarshal decodes %#v from a value that is not well-formed, in order and alone", v)
+	case err == nil && !same(v, want):
+		return fmt.Errorf("anacrolix Unmarshal decodes %#v, want %#v", v, want)
+	case err == nil:
+		if err := checkEncoding("anacrolix", v, data, anacrolix.Marshal); err != nil {
+			return err
+		}
+	}
+	if err := checkSequence(data); err != nil {
+		return err
+	}
+
+	// Known: jackpal and zeebo take integers with leading zeros, a plus
+	// sign or minus zero, and reject those outside an int64, which BEP 3
+	// does not bound; jackpal takes string lengths written so too, and
+	// keys repeated and out of order, keeping the last of each; zeebo
+	// takes keys repeated one after another, and lengths with leading
+	// zeros. So what they decode from a value the reader rejects is only
+	// checked to encode back the same. And both make a string as long as
+	// its length says before they read it, so that a length of a few bytes
+	// runs them out of memory, and jackpal panics on one that is negative.
+	if lax := (&laxReader{data: data, anyStart: true}); !lax.overlong() {
+		if err := checkJackpal(data, r, want, ok); err != nil {
+			return err
+		}
+	}
+	if lax := (&laxReader{data: data}); !lax.overlong() {
+		return checkZeebo(data, r, want, ok)
+	}
+	return nil
+}
+
+// checkJackpal decodes the first value in data with jackpal, which must
+// decode want if r read it well-formed.
+func checkJackpal(data []byte, r *reader, want any, ok bool) error {
+	jv, err := decodeJackpal(data)
+	switch {
+	case ok && !r.big && err != nil:
+		return fmt.Errorf("jackpal Decode: %v, but the value reads as %#v", err, want)
+	case ok && err == nil && !same(jv, want):
+		return fmt.Errorf("jackpal Decode decodes %#v, want %#v", jv, want)
+	case err == nil:
+		return checkEncoding("jackpal", jv, data[:prefix(ok, r.off, data)], marshalJackpal)
+	}
+	return nil
+}
+
+// checkZeebo decodes the first value in data with zeebo, which must
+// decode want if r read it well-formed and in order, and stop where it
+// ends.
+func checkZeebo(data []byte, r *reader, want any, ok bool) error {
+	dec := zeebo.NewDecoder(bytes.NewReader(data))
+	dec.SetFailOnUnorderedKeys(true)
+	var zv any
+	err := dec.Decode(&zv)
+	switch {
+	case ok && !r.big && !r.unordered && err != nil:
+		return fmt.Errorf("zeebo Decode: %v, but the value reads as %#v", err, want)
+	case ok && r.unordered && err == nil:
+		return fmt.Errorf("zeebo Decode decodes %#v from a dict with keys out of order", zv)
+	case ok && err == nil && !same(zv, want):
+		return fmt.Errorf("zeebo Decode decodes %#v, want %#v", zv, want)
+	case ok && err == nil && dec.BytesParsed() != r.off:
+		return fmt.Errorf("zeebo Decode reads to %d, but the value ends at %d", dec.BytesParsed(), r.off)
+	case err == nil:
+		return checkEncoding("zeebo", zv, data[:prefix(ok, r.off, data)], zeebo.EncodeBytes)
+	}
+	return nil
+}
+
+// prefix returns where the first value in data ends, if it is
+// well-formed, and the end of data if it is not.
+func prefix(ok bool, end int, data []byte) int {
+	if ok {
+		return end
+	}
+	return len(data)
+}
+
+// checkEncoding encodes v, which name's decoder decoded from data, with
+// marshal, and reads the encoding back. It must be well-formed and in
+// order and hold v, and be data itself if data was.
+func checkEncoding(name string, v any, data []byte, marshal func(any) ([]byte, error)) error {
+	enc, err := marshal(v)
+	if err != nil {
+		return fmt.Errorf("%s Marshal(%#v): %v", name, v, err)
+	}
+	er := &reader{data: enc}
+	back, ok := er.value(0)
+	switch {
+	case !ok || er.off != len(enc) || er.unordered || er.repeated:
+		return fmt.Errorf("%s Marshal(%#v) = %q, which is not a well-formed value in order", name, v, enc)
+	case !same(back, v):
+		return fmt.Errorf("%s Marshal(%#v) = %q, which reads as %#v", name, v, enc, back)
+	}
+	r := &reader{data: data}
+	if _, ok := r.value(0); ok && r.off == len(data) && !r.unordered && !r.repeated && !bytes.Equal(enc, data) {
+		return fmt.Errorf("%s Marshal(%#v) = %q, but the value was %q", name, v, enc, data)
+	}
+	return nil
+}
+
+// checkSequence reads the values in data one after another with an
+// anacrolix Decoder, which must stop at the end of each where the reader
+// does, and fail where the reader does.
+func checkSequence(data []byte) error {
+	dec := anacrolix.NewDecoder(bytes.NewReader(data))
+	r := &reader{data: data}
+	for r.off < len(data) {
+		start := r.off
+		_, ok := r.value(0)
+		if r.deep {
+			return nil
+		}
+		ok = ok && !r.unordered && !r.repeated
+		var v any
+		err := dec.Decode(&v)
+		switch {
+		case ok && err != nil:
+			return fmt.Errorf("anacrolix Decoder.Decode: %v, but the value at %d is well-formed", err, start)
+		case !ok && err == nil:
+			return fmt.Errorf("anacrolix Decoder.Decode reads to %d, but the value at %d is not well-formed and in order", dec.Offset, start)
+		case !ok:
+			return nil
+		case dec.Offset != int64(r.off):
+			return fmt.Errorf("anacrolix Decoder.Decode reads to %d, but the value ends at %d", dec.Offset, r.off)
+		}
+	}
+	// Known: Decode into an any reports the end of the input as
+	// io.ErrUnexpectedEOF even where a value would start, so ReadEOF is
+	// what tells a clean end.
+	if err := dec.ReadEOF(); err != nil {
+		return fmt.Errorf("anacrolix Decoder.ReadEOF at the end: %v", err)
+	}
+	return nil
+}
+
+// decodeJackpal decodes the first value in data with jackpal.
+func decodeJackpal(data []byte) (any, error) {
+	return jackpal.Decode(bytes.NewReader(data))
+}
+
+// marshalJackpal encodes v with jackpal.
+func marshalJackpal(v any) ([]byte, error) {
+	var b bytes.Buffer
+	err := jackpal.Marshal(&b, v)
+	return b.Bytes(), err
+}
+
+// same reports whether a and b are the same decoded value, taking a nil
+// list for an empty one.
+func same(a, b any) bool {
+	switch a := a.(type) {
+	case *big.Int:
+		b, ok := b.(*big.Int)
+		return ok && a.Cmp(b) == 0
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !same(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !same(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
@@ -0,0 +1,22 @@
+package bencode
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/bencodesrc"
+)
+
+func FuzzBencode(f *testing.F) {
+	for _, src := range gen.Sample("bencode/torrent", ".torrent", 64) {
+		f.Add(src)
+	}
+	for _, src := range gen.Sample("bencode/krpc", ".bencode", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckBencode(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+package bencode
+
+import (
+	"bytes"
+	"math/big"
+	"strconv"
+)
+
+// A reader reads bencoded values as BEP 3 has them, into the Go values
+// anacrolix/torrent's bencode decodes each to in an any.
+type reader struct {
+	data []byte
+	off  int
+
+	// big is set by an integer outside an int64, which decodes to a
+	// *big.Int.
+	big bool
+	// unordered is set by a dict key that sorts before the one ahead of
+	// it, and repeated by a key the same as one ahead of it. A dict holds
+	// the last value of a key repeated.
+	unordered, repeated bool
+	// deep is set by a value nested past maxDepth.
+	deep bool
+}
+
+// value reads a value nested depth deep, reporting whether it is
+// well-formed. Whether its keys are in order is for the caller to ask.
+func (r *reader) value(depth int) (any, bool) {
+	if depth > maxDepth {
+		r.deep = true
+		return nil, false
+	}
+	if r.off >= len(r.data) {
+		return nil, false
+	}
+	switch c := r.data[r.off]; {
+	case c == 'i':
+		r.off++
+		return r.integer()
+	case c >= '0' && c <= '9':
+		return r.str()
+	case c == 'l':
+		r.off++
+		l := []any{}
+		for !r.atEnd() {
+			v, ok := r.value(depth + 1)
+			if !ok {
+				return nil, false
+			}
+			l = append(l, v)
+		}
+		return l, true
+	case c == 'd':
+		r.off++
+		return r.dict(depth)
+	}
+	return nil, false
+}
+
+// atEnd reports whether an 'e' is next, and reads it if it is.
+func (r *reader) atEnd() bool {
+	if r.off < len(r.data) && r.data[r.off] == 'e' {
+		r.off++
+		return true
+	}
+	return false
+}
+
+// digits reads a decimal number up to and through end, which has no
+// leading zeros and, if signed allows a sign, is not minus zero.
+func (r *reader) digits(end byte, signed bool) (string, bool) {
+	start := r.off
+	if signed && r.off < len(r.data) && r.data[r.off] == '-' {
+		r.off++
+	}
+	first := r.off
+	for r.off < len(r.data) && r.data[r.off] >= '0' && r.data[r.off] <= '9' {
+		r.off++
+	}
+	n := string(r.data[start:r.off])
+	switch {
+	case r.off == first, r.off >= len(r.data) || r.data[r.off] != end:
+		return "", false
+	case r.data[first] == '0' && (r.off-first > 1 || first > start):
+		return "", false
+	}
+	r.off++
+	return n, true
+}
+
+// integer reads the rest of an integer after its 'i'.
+func (r *reader) integer() (any, bool) {
+	n, ok := r.digits('e', true)
+	if !ok {
+		return nil, false
+	}
+	if i, err := strconv.ParseInt(n, 10, 64); err == nil {
+		return i, true
+	}
+	r.big = true
+	b, _ := new(big.Int).SetString(n, 10)
+	return b, true
+}
+
+// str reads a string and its length.
+func (r *reader) str() (string, bool) {
+	n, ok := r.digits(':', false)
+	if !ok {
+		return "", false
+	}
+	size, err := strconv.Atoi(n)
+	if err != nil || size > len(r.data)-r.off {
+		return "", false
+	}
+	s := string(r.data[r.off : r.off+size])
+	r.off += size
+	return s, true
+}
+
+// dict reads the pairs of a dict after its 'd'. Its keys are strings,
+// and sorted as raw bytes, not as text.
+func (r *reader) dict(depth int) (any, bool) {
+	d := map[string]any{}
+	first := true
+	var last string
+	for !r.atEnd() {
+		if r.off >= len(r.data) || r.data[r.off] < '0' || r.data[r.off] > '9' {
+			return nil, false
+		}
+		k, ok := r.str()
+		if !ok {
+			return nil, false
+		}
+		if _, ok := d[k]; ok {
+			r.repeated = true
+		}
+		if !first && k < last {
+			r.unordered = true
+		}
+		first, last = false, k
+		if d[k], ok = r.value(depth + 1); !ok {
+			return nil, false
+		}
+	}
+	return d, true
+}
+
+// A laxReader follows jackpal's decoder, if anyStart is set, or zeebo's,
+// through a value as far as it gets, rejecting nothing they take, to find
+// whether it reads a string length that is negative or past the end.
+// jackpal reads a string wherever a value does not start with 'i', 'l' or
+// 'd', and zeebo where a value starts with a digit or a key starts at
+// all.
+type laxReader struct {
+	data     []byte
+	off      int
+	anyStart bool
+}
+
+// overlong reports whether the decoder reads a string length that is
+// negative or past the end, or nests past maxDepth.
+func (r *laxReader) overlong() bool {
+	over, _ := r.value(0, false)
+	return over
+}
+
+// value reads a value, or a key if key is set, nested depth deep,
+// reporting whether it found a length that is negative or past the end
+// and whether the decoder reads on.
+func (r *laxReader) value(depth int, key bool) (over, ok bool) {
+	if depth > maxDepth {
+		return true, false
+	}
+	if r.off >= len(r.data) {
+		return false, false
+	}
+	c := r.data[r.off]
+	switch {
+	case key && !r.anyStart, c >= '0' && c <= '9', r.anyStart && c != 'i' && c != 'l' && c != 'd':
+		i := bytes.IndexByte(r.data[r.off:], ':')
+		if i < 0 {
+			return false, false
+		}
+		n, err := strconv.ParseInt(string(r.data[r.off:r.off+i]), 10, 64)
+		r.off += i + 1
+		switch {
+		case err != nil:
+			return false, false
+		case n < 0 || n > int64(len(r.data)-r.off):
+			return true, false
+		}
+		r.off += int(n)
+		return false, true
+	case c == 'i':
+		i := bytes.IndexByte(r.data[r.off:], 'e')
+		r.off += i + 1
+		return false, i >= 0
+	}
+	r.off++
+	for {
+		if r.off >= len(r.data) {
+			return false, false
+		}
+		if r.data[r.off] == 'e' {
+			r.off++
+			return false, true
+		}
+		if c == 'd' {
+			// jackpal reads a key as any value, then rejects one that is
+			// not a string.
+			k := r.data[r.off]
+			if over, ok := r.value(depth+1, true); !ok || r.anyStart && (k == 'i' || k == 'l' || k == 'd') {
+				return over, false
+			}
+		}
+		if over, ok := r.value(depth+1, false); !ok {
+			return over, false
+		}
+	}
+}
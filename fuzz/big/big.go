@@ -0,0 +1,335 @@
+// Package big is a fuzz target for the text parsers of math/big, which
+// protocols reach whenever they read a number of unbounded size. Each
+// check parses within a budget of time and memory linear in the size of
+// the text, past which it is reported as a blowup, so that a number of a
+// few bytes must not cost what its value or an exponent in it would.
+//
+// CheckInt reads an integer with Int.SetString in every base, where
+// strconv.ParseInt must agree with it on what is a number and on the
+// value of those that fit in an int64, and Rat and Float must read a
+// number in base 0 as the same integer. CheckFloat reads a number with
+// Float.Parse in every base and rounding mode at a precision, where the
+// value must be that of the exact number Rat reads, rounded; the number
+// must print, in 'p' and shortest 'g' format, as one that parses back to
+// it. CheckRat reads a number with Rat.SetString, which must print as
+// one that reads back the same and convert to the float64 ParseFloat
+// reads, exactly when it says so. Every Int, Float and Rat read must
+// marshal as text that unmarshals to it again.
+package big
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// DefaultBudget is what parsing a number in every base and mode may
+// cost, per byte of its text. It allows for the powers of ten and two
+// an exponent within Rat's bounds makes, and for reading a number in 62
+// bases.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: time.Second, Memory: 64 << 20},
+	Per:  harness.Cost{Time: 20 * time.Microsecond, Memory: 4 << 10},
+}
+
+// PrecLimit is the precision past which a Float is only read from text
+// whose value it holds exactly in as many bits as the text has digits,
+// since rounding to a precision costs memory in proportion to it.
+const PrecLimit = 1 << 16
+
+// PrintLimit is the size in bits of the largest number, and the largest
+// precision and exponent of a Float, printed in decimal, since printing
+// costs time in proportion to the digits printed, not to the text read.
+const PrintLimit = 1 << 12
+
+// modes are the rounding modes of a Float.
+var modes = []big.RoundingMode{big.ToNearestEven, big.ToNearestAway, big.ToZero, big.AwayFromZero, big.ToNegativeInf, big.ToPositiveInf}
+
+// floatBases are the bases Float.Parse reads.
+var floatBases = []int{0, 2, 8, 10, 16}
+
+// measure runs parse within the budget for n bytes of text, and then
+// check, which is given the time limit of both.
+func measure(n int, b harness.Budget, parse func(), check func() error) error {
+	limit := b.For(n)
+	if err := harness.Measure(fmt.Sprintf("parsing %d bytes", n), limit, parse); err != nil {
+		return err
+	}
+	return harness.Run(harness.HangFactor*limit.Time, check)
+}
+
+// CheckInt checks the integer s, read in every base, within b.
+func CheckInt(s string, b harness.Budget) error {
+	ints := make([]*big.Int, big.MaxBase+1)
+	return measure(len(s), b, func() {
+		for base := range ints {
+			if base != 1 {
+				ints[base], _ = new(big.Int).SetString(s, base)
+			}
+		}
+	}, func() error {
+		for base, z := range ints {
+			if base == 1 {
+				continue
+			}
+			if err := checkInt(s, base, z); err != nil {
+				return fmt.Errorf("%q in base %d: %v", s, base, err)
+			}
+		}
+		if z := ints[0]; z != nil && !octal(s) {
+			return checkInteger(s, z)
+		}
+		return nil
+	})
+}
+
+// checkInt checks z, read from s in base, or nil if s is not a number in
+// base.
+func checkInt(s string, base int, z *big.Int) error {
+	if base <= 36 {
+		// ParseInt reports a number out of range as soon as its digits
+		// overflow, before it reads the rest of them, so that text which
+		// is not a number may be reported so.
+		v, err := strconv.ParseInt(s, base, 64)
+		switch {
+		case err == nil && (z == nil || !z.IsInt64() || z.Int64() != v):
+			return fmt.Errorf("ParseInt reads %d, SetString %v", v, z)
+		case errors.Is(err, strconv.ErrRange) && z != nil && z.IsInt64():
+			return fmt.Errorf("ParseInt reads a number out of range, SetString %v", z)
+		case errors.Is(err, strconv.ErrSyntax) && z != nil:
+			return fmt.Errorf("ParseInt reads no number, SetString %v", z)
+		}
+	}
+	if z == nil {
+		return nil
+	}
+	if z.BitLen() > PrintLimit {
+		return nil
+	}
+	b := max(base, 10)
+	text := z.Text(b)
+	if again, ok := new(big.Int).SetString(text, b); !ok || again.Cmp(z) != 0 {
+		return fmt.Errorf("%v prints in base %d as %q, which reads as %v", z, b, text, again)
+	}
+	out, err := z.MarshalText()
+	again := new(big.Int)
+	if err != nil || again.UnmarshalText(out) != nil || again.Cmp(z) != 0 {
+		return fmt.Errorf("%v marshals as %q (%v), which unmarshals as %v", z, out, err, again)
+	}
+	return nil
+}
+
+// octal reports whether s, after its sign, starts with a 0 and a digit
+// or underscore, which Int reads in base 0 as an octal prefix and Rat
+// and Float as a decimal 0.
+func octal(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && (s[1] == '_' || '0' <= s[1] && s[1] <= '9')
+}
+
+// checkInteger checks that Rat and Float read the integer s, which Int
+// reads in base 0 as z, as z.
+func checkInteger(s string, z *big.Int) error {
+	if r, ok := new(big.Rat).SetString(s); !ok || !r.IsInt() || r.Num().Cmp(z) != 0 {
+		return fmt.Errorf("%q: Int reads %v, Rat %v", s, z, r)
+	}
+	prec := uint(max(z.BitLen(), 64))
+	f, _, err := new(big.Float).SetPrec(prec).Parse(s, 0)
+	if err != nil || f.Acc() != big.Exact {
+		return fmt.Errorf("%q: Int reads %v, Float %v (%v)", s, z, f, err)
+	}
+	if i, _ := f.Int(nil); i.Cmp(z) != 0 {
+		return fmt.Errorf("%q: Int reads %v, Float %v", s, z, i)
+	}
+	return nil
+}
+
+// A parsed is a Float read in a base and mode.
+type parsed struct {
+	base int
+	mode big.RoundingMode
+	f    *big.Float
+	b    int
+	err  error
+}
+
+// CheckFloat checks the number in data, a precision on a line of its own
+// and the text of the number, read in every base and mode, within b.
+func CheckFloat(data string, b harness.Budget) error {
+	line, s, ok := strings.Cut(data, "\n")
+	p, err := strconv.ParseUint(line, 10, 32)
+	if !ok || err != nil {
+		return nil
+	}
+	prec := uint(p)
+	// Known: at precisions within a word of MaxPrec, Parse rounds a
+	// number it does not hold exactly to 2^32 bits, more than MaxPrec,
+	// and reports it exact.
+	if prec > PrecLimit && !exact(s) {
+		return nil
+	}
+	var fs []parsed
+	n := len(s)
+	if prec <= PrecLimit {
+		n += int(prec) / 8
+	}
+	return measure(n, b, func() {
+		for _, base := range floatBases {
+			for _, mode := range modes {
+				f, got, err := new(big.Float).SetPrec(prec).SetMode(mode).Parse(s, base)
+				fs = append(fs, parsed{base, mode, f, got, err})
+			}
+		}
+	}, func() error {
+		r, rok := new(big.Rat).SetString(s)
+		for _, p := range fs {
+			if err := checkFloat(s, prec, p, r, rok); err != nil {
+				return fmt.Errorf("%q in base %d, %v, precision %d: %v", s, p.base, p.mode, prec, err)
+			}
+		}
+		return nil
+	})
+}
+
+// exact reports whether s is a number whose value a Float holds exactly
+// in bits no more than four times its digits: an integer, or a number
+// with a binary, octal or hexadecimal mantissa and a binary exponent.
+func exact(s string) bool {
+	s = strings.ToLower(strings.TrimLeft(s, "+-"))
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0b") || strings.HasPrefix(s, "0o") {
+		return !strings.Contains(s[2:], "e") || strings.HasPrefix(s, "0x")
+	}
+	return !strings.ContainsAny(s, ".ep")
+}
+
+// checkFloat checks p, read from s at prec, against r, the Rat read from
+// s if rok is set.
+func checkFloat(s string, prec uint, p parsed, r *big.Rat, rok bool) error {
+	// Known: Parse returns the number it has read, not nil, with an
+	// error reporting text after it.
+	if p.err == nil && p.f == nil {
+		return fmt.Errorf("Parse returns nil and no error")
+	}
+	if p.err != nil {
+		return nil
+	}
+	f := p.f
+	want := prec
+	if want == 0 {
+		want = 64
+	}
+	// "Inf" is not rounded, and keeps a precision of 0.
+	if f.Prec() != want && !(f.IsInf() && f.Prec() == prec) || f.Mode() != p.mode || f.MinPrec() > f.Prec() {
+		return fmt.Errorf("Parse returns %v of precision %d, mode %v and MinPrec %d", f, f.Prec(), f.Mode(), f.MinPrec())
+	}
+	if !f.IsInf() && (p.base != 0 && p.b != p.base || p.base == 0 && p.b != 2 && p.b != 8 && p.b != 10 && p.b != 16) {
+		return fmt.Errorf("Parse returns base %d", p.b)
+	}
+	if prec > PrecLimit {
+		if f.Acc() != big.Exact {
+			return fmt.Errorf("Parse reads %v, %v", f, f.Acc())
+		}
+		return checkFloatText(f, false)
+	}
+	if p.base == 0 && rok && !f.IsInf() {
+		w := new(big.Float).SetPrec(f.Prec()).SetMode(p.mode).SetRat(r)
+		// Known: Parse reports the accuracy of the last step it rounds
+		// in, so that a number it reads exactly, but with more digits
+		// than its precision, may be reported rounded.
+		if f.Cmp(w) != 0 || f.Acc() == big.Exact && w.Acc() != big.Exact {
+			return fmt.Errorf("Parse reads %s (%v), but Rat reads %v, which rounds to %s (%v)", f.Text('p', 0), f.Acc(), r, w.Text('p', 0), w.Acc())
+		}
+	}
+	return checkFloatText(f, true)
+}
+
+// checkFloatText checks that f prints as text that parses back to it:
+// in 'p' format, and in the shortest 'g' format, as MarshalText prints
+// it, if decimal is set and f is small enough to print.
+func checkFloatText(f *big.Float, decimal bool) error {
+	text := f.Text('p', 0)
+	again, _, err := new(big.Float).SetPrec(f.Prec()).Parse(text, 0)
+	if err != nil || again.Cmp(f) != 0 || again.Acc() != big.Exact {
+		return fmt.Errorf("%s prints as %q, which parses as %v (%v)", f.Text('p', 0), text, again, err)
+	}
+	if !decimal || f.IsInf() || f.Sign() == 0 || f.Prec() > PrintLimit || abs(f.MantExp(nil)) > PrintLimit {
+		return nil
+	}
+	// Known: the shortest 'g' format bounds the numbers that round to a
+	// power of two half an ulp below it, where the next number down is
+	// only half an ulp away, and may print one that parses as that.
+	if f.MinPrec() == 1 {
+		return nil
+	}
+	text = f.Text('g', -1)
+	again, _, err = new(big.Float).SetPrec(f.Prec()).Parse(text, 0)
+	if err != nil || again.Cmp(f) != 0 {
+		return fmt.Errorf("%s prints as %q, which parses as %s (%v)", f.Text('p', 0), text, again.Text('p', 0), err)
+	}
+	out, err := f.MarshalText()
+	again = new(big.Float).SetPrec(f.Prec())
+	if err != nil || again.UnmarshalText(out) != nil || again.Cmp(f) != 0 {
+		return fmt.Errorf("%s marshals as %q (%v), which unmarshals as %s", f.Text('p', 0), out, err, again.Text('p', 0))
+	}
+	return nil
+}
+
+func abs(x int) int {
+	return max(x, -x)
+}
+
+// CheckRat checks the number s, read with Rat.SetString, within b.
+func CheckRat(s string, b harness.Budget) error {
+	var r *big.Rat
+	var ok bool
+	return measure(len(s), b, func() {
+		r, ok = new(big.Rat).SetString(s)
+	}, func() error {
+		if !ok {
+			return nil
+		}
+		if err := checkRat(s, r); err != nil {
+			return fmt.Errorf("%q: %v", s, err)
+		}
+		return nil
+	})
+}
+
+// checkRat checks r, read from s.
+func checkRat(s string, r *big.Rat) error {
+	f, exact := r.Float64()
+	if !strings.Contains(s, "/") {
+		v, err := strconv.ParseFloat(s, 64)
+		if (err == nil || errors.Is(err, strconv.ErrRange)) && v != f {
+			return fmt.Errorf("Rat reads %v, which converts to %v, but ParseFloat reads %v", r, f, v)
+		}
+	}
+	// Known: Float64 reports a number too small for a float64, which it
+	// rounds to zero, as exact, unless it is just half the smallest
+	// float64.
+	if !math.IsInf(f, 0) && (f != 0 || r.Sign() == 0) {
+		if back := new(big.Rat).SetFloat64(f); (back.Cmp(r) == 0) != exact {
+			return fmt.Errorf("%v converts to %v, exact %v", r, f, exact)
+		}
+	}
+	if r.Num().BitLen()+r.Denom().BitLen() > PrintLimit {
+		return nil
+	}
+	for _, text := range []string{r.String(), r.RatString()} {
+		if again, ok := new(big.Rat).SetString(text); !ok || again.Cmp(r) != 0 {
+			return fmt.Errorf("%v prints as %q, which reads as %v", r, text, again)
+		}
+	}
+	out, err := r.MarshalText()
+	again := new(big.Rat)
+	if err != nil || again.UnmarshalText(out) != nil || again.Cmp(r) != 0 {
+		return fmt.Errorf("%v marshals as %q (%v), which unmarshals as %v", r, out, err, again)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package big
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/bigsrc"
+)
+
+func FuzzInt(f *testing.F) {
+	for _, src := range gen.Sample("big/int", ".int", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckInt(s, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzFloat(f *testing.F) {
+	for _, src := range gen.Sample("big/float", ".float", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, data string) {
+		if err := CheckFloat(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzRat(f *testing.F) {
+	for _, src := range gen.Sample("big/rat", ".rat", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckRat(s, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+// Package build is a fuzz target for build constraints: the
+// go/build/constraint parser and its conversions between //go:build and
+// // +build syntax, and go/build.Context.MatchFile, which is checked
+// against a direct evaluation of the file's header for several
+// configurations.
+package build
+
+import (
+	"bytes"
+	"fmt"
+	"go/build"
+	"go/build/constraint"
+	"io"
+	"slices"
+	"strings"
+)
+
+// maxTags bounds the tags in an expression whose truth table is compared
+// exhaustively, and maxLines the distinct constraint lines checked per
+// input, so that large inputs stay fast.
+const (
+	maxTags  = 10
+	maxLines = 16
+)
+
+// A config is one configuration MatchFile is run under.
+type config struct {
+	goos, goarch string
+	cgo          bool
+	tags         []string
+}
+
+var configs = []config{
+	{"linux", "amd64", true, nil},
+	{"windows", "arm64", false, []string{"custom"}},
+	{"darwin", "arm64", true, nil},
+	{"ios", "arm64", false, nil},
+	{"android", "386", true, []string{"integration"}},
+	{"illumos", "amd64", false, nil},
+	{"js", "wasm", false, []string{"custom", "integration"}},
+	{"plan9", "386", false, nil},
+}
+
+// unixOS is the set of GOOS values the "unix" tag matches.
+var unixOS = map[string]bool{
+	"aix": true, "android": true, "darwin": true, "dragonfly": true,
+	"freebsd": true, "hurd": true, "illumos": true, "ios": true,
+	"linux": true, "netbsd": true, "openbsd": true, "solaris": true,
+}
+
+// Check checks every constraint line in src and the result of MatchFile
+// on src.
+func Check(src []byte) error {
+	seen := map[string]bool{}
+	for l := range strings.Lines(string(src)) {
+		l = strings.TrimSpace(l)
+		if seen[l] || !constraint.IsGoBuild(l) && !constraint.IsPlusBuild(l) {
+			continue
+		}
+		if seen[l] = true; len(seen) > maxLines {
+			break
+		}
+		if err := checkLine(l); err != nil {
+			return err
+		}
+	}
+	for _, c := range configs {
+		if err := checkMatch(c, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkLine parses one constraint line and checks that converting it to
+// the other syntax and back preserves its meaning.
+func checkLine(line string) error {
+	x, err := constraint.Parse(line)
+	if err != nil {
+		return nil
+	}
+	constraint.GoVersion(x)
+
+	y, err := constraint.Parse("//go:build " + x.String())
+	if err != nil && strings.Contains(x.String(), "!!") {
+		// Known: Parse accepts "!(!x)" but prints it as "!!x", which
+		// it rejects as a double negation.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%q: printed form %q does not parse: %v", line, x.String(), err)
+	}
+	if y.String() != x.String() {
+		return fmt.Errorf("%q: printing is not stable: %q then %q", line, x.String(), y.String())
+	}
+	if err := equivalent(x, y); err != nil {
+		return fmt.Errorf("%q: reparsed %q: %v", line, x.String(), err)
+	}
+
+	plus, err := constraint.PlusBuildLines(x)
+	if err != nil {
+		// Too complex for // +build lines.
+		return nil
+	}
+	var z constraint.Expr
+	for _, l := range plus {
+		e, err := constraint.Parse(l)
+		if err != nil {
+			return fmt.Errorf("%q: generated %q does not parse: %v", line, l, err)
+		}
+		if z == nil {
+			z = e
+		} else {
+			z = &constraint.AndExpr{X: z, Y: e}
+		}
+	}
+	if z == nil {
+		return fmt.Errorf("%q: no // +build lines generated", line)
+	}
+	if err := equivalent(x, z); err != nil {
+		return fmt.Errorf("%q: // +build form %q: %v", line, plus, err)
+	}
+	return nil
+}
+
+// equivalent compares the truth tables of x and y over their tags.
+func equivalent(x, y constraint.Expr) error {
+	var tags []string
+	collect := func(tag string) bool {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+		return false
+	}
+	x.Eval(collect)
+	y.Eval(collect)
+	if len(tags) > maxTags {
+		return nil
+	}
+	for bits := range 1 << len(tags) {
+		set := func(tag string) bool { return bits>>slices.Index(tags, tag)&1 == 1 }
+		if x.Eval(set) != y.Eval(set) {
+			return fmt.Errorf("differs when %s", describe(tags, bits))
+		}
+	}
+	return nil
+}
+
+func describe(tags []string, bits int) string {
+	var on []string
+	for i, t := range tags {
+		if bits>>i&1 == 1 {
+			on = append(on, t)
+		}
+	}
+	return "tags {" + strings.Join(on, ",") + "} are set"
+}
+
+// checkMatch compares MatchFile with a direct evaluation of src's header
+// under c.
+func checkMatch(c config, src []byte) error {
+	ctx := build.Default
+	ctx.GOOS, ctx.GOARCH, ctx.CgoEnabled, ctx.BuildTags = c.goos, c.goarch, c.cgo, c.tags
+	ctx.Compiler = "gc"
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(src)), nil
+	}
+	got, err := ctx.MatchFile(".", "seed.go")
+	if err != nil {
+		return nil
+	}
+	goBuild, plus, ok := header(src)
+	if !ok {
+		return nil
+	}
+	has := func(tag string) bool { return hasTag(&ctx, tag) }
+	want, why := true, "no constraints"
+	if goBuild != nil {
+		want, why = goBuild.Eval(has), goBuild.String()
+	} else if len(plus) > 0 {
+		var ss []string
+		for _, x := range plus {
+			want = want && x.Eval(has)
+			ss = append(ss, x.String())
+		}
+		why = strings.Join(ss, "; ")
+	}
+	if got != want {
+		return fmt.Errorf("%s/%s: MatchFile = %v, header (%s) evaluates to %v", c.goos, c.goarch, got, why, want)
+	}
+	return nil
+}
+
+// header returns the constraints in effect for src, following the rules
+// in the go/build documentation: a //go:build line preceded only by blank
+// lines and // comments controls; failing that, every // +build line in
+// that run that is followed by a blank line must hold. Headers containing
+// /* */ comments, several //go:build lines or one that does not parse are
+// reported as not ok.
+func header(src []byte) (goBuild constraint.Expr, plus []constraint.Expr, ok bool) {
+	var pending []string // // +build lines not yet followed by a blank line
+Lines:
+	for l := range strings.Lines(string(src)) {
+		l = strings.TrimSpace(l)
+		switch {
+		case l == "":
+			for _, p := range pending {
+				if x, err := constraint.Parse(p); err == nil {
+					plus = append(plus, x)
+				}
+			}
+			pending = nil
+		case strings.HasPrefix(l, "/*"):
+			return nil, nil, false
+		case !strings.HasPrefix(l, "//"):
+			break Lines
+		case constraint.IsGoBuild(l):
+			x, err := constraint.Parse(l)
+			if goBuild != nil || err != nil {
+				return nil, nil, false
+			}
+			goBuild = x
+		case constraint.IsPlusBuild(l):
+			pending = append(pending, l)
+		}
+	}
+	return goBuild, plus, true
+}
+
+// hasTag reports whether tag is satisfied under ctx, as documented for
+// go/build: GOOS, GOARCH, the compiler, cgo, "unix", the implied
+// operating systems, build tags, tool tags and release tags.
+func hasTag(ctx *build.Context, tag string) bool {
+	switch {
+	case tag == "cgo":
+		if ctx.CgoEnabled {
+			return true
+		}
+	case tag == ctx.GOOS || tag == ctx.GOARCH || tag == ctx.Compiler:
+		return true
+	case tag == "unix":
+		if unixOS[ctx.GOOS] {
+			return true
+		}
+	case tag == "linux" && ctx.GOOS == "android",
+		tag == "solaris" && ctx.GOOS == "illumos",
+		tag == "darwin" && ctx.GOOS == "ios":
+		return true
+	case tag == "boringcrypto":
+		tag = "goexperiment.boringcrypto"
+	}
+	return slices.Contains(ctx.BuildTags, tag) || slices.Contains(ctx.ToolTags, tag) ||
+		slices.Contains(ctx.ReleaseTags, tag)
+}
@@ -0,0 +1,19 @@
+package build
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzMatchFile(f *testing.F) {
+	for _, src := range gen.Sample("go/buildtags", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
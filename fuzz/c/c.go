@@ -0,0 +1,150 @@
+// Package c is a fuzz target for the C parsers written in Go:
+// modernc.org/cc/v4, the front end of the C-to-Go translators, and
+// github.com/smacker/go-tree-sitter with its C and C++ grammars. CheckFile
+// preprocesses, parses and type checks a file with cc, which may reject
+// it but not panic. A file cc parses must parse to the same tokens from
+// what cc preprocesses it to, so that its printed output is the
+// translation unit it preprocessed. Each tree-sitter grammar parses the
+// file, recovering from errors into a tree whose nodes must lie inside
+// their parents, in order; a file it parses without error must be covered
+// by its tokens, and reparse incrementally as it parses from scratch.
+//
+// cc runs for linux/amd64 with a few predefined macros of its own in
+// place of those of the host's C compiler, and finds no headers.
+package c
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing/fstest"
+	"time"
+	"unicode"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/geeknik/fuzzing/internal/treesitter"
+	tsc "github.com/smacker/go-tree-sitter/c"
+	tscpp "github.com/smacker/go-tree-sitter/cpp"
+	"modernc.org/cc/v4"
+)
+
+// Timeout bounds checking one file.
+var Timeout = 10 * time.Second
+
+// predefined are the macros a C compiler for linux/amd64 defines that the
+// definitions in cc.Builtin use.
+const predefined = `#define __STDC__ 1
+#define __STDC_VERSION__ 201710L
+#define __STDC_HOSTED__ 1
+#define __GNUC__ 12
+#define __linux__ 1
+#define __x86_64__ 1
+#define __CHAR_BIT__ 8
+#define __SIZE_TYPE__ long unsigned int
+#define __PTRDIFF_TYPE__ long int
+#define __WCHAR_TYPE__ int
+#define __UINT16_TYPE__ short unsigned int
+#define __UINT32_TYPE__ unsigned int
+#define __UINT64_TYPE__ long unsigned int
+`
+
+// grammars are tree-sitter's C and C++ grammars, which skip line splices
+// between tokens.
+var grammars = []*treesitter.Grammar{
+	{Language: tsc.GetLanguage(), Skipped: skipped},
+	{Language: tscpp.GetLanguage(), Skipped: skipped},
+}
+
+func skipped(r rune) bool { return unicode.IsSpace(r) || r == '\\' }
+
+// CheckFile checks the C or C++ file in data.
+func CheckFile(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		if err := unimplemented(func() error { return check(data) }); err != nil {
+			return err
+		}
+		for _, g := range grammars {
+			if err := treesitter.Check(g, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// emptyDefine matches a #define directive without a macro name.
+var emptyDefine = regexp.MustCompile(`(?m)^[ \t]*#[ \t]*define[ \t]*$`)
+
+func check(src []byte) error {
+	// Known: cc panics on a #define directive without a macro name, with
+	// "index out of range [0] with length 0".
+	if emptyDefine.Match(src) {
+		return nil
+	}
+	cfg, err := config()
+	if err != nil {
+		return err
+	}
+	sources := []cc.Source{
+		{Name: "<predefined>", Value: predefined},
+		{Name: "<builtin>", Value: cc.Builtin},
+		{Name: "input.c", Value: src},
+	}
+	var out bytes.Buffer
+	if err := cc.Preprocess(cfg, sources, &out); err != nil {
+		return nil
+	}
+	ast, err := cc.Parse(cfg, sources)
+	if err != nil {
+		return nil
+	}
+	ast2, err := cc.Parse(cfg, []cc.Source{{Name: "input.i", Value: out.Bytes()}})
+	if err != nil {
+		return fmt.Errorf("cc preprocesses a file it parses to\n%s\nwhich it does not parse: %v", out.Bytes(), err)
+	}
+	if got, want := tokens(ast2.TranslationUnit), tokens(ast.TranslationUnit); got != want {
+		return fmt.Errorf("cc preprocesses a file it parses to\n%s\nwhich it parses to the tokens\n%s\nand not\n%s", out.Bytes(), got, want)
+	}
+	cc.Translate(cfg, sources) // errors are ordinary
+	return nil
+}
+
+// unimplemented calls f, recovering from the panics with which cc marks
+// what it does not implement.
+//
+// Known: cc panics with a TODO on input it does not handle yet, such as an
+// invocation of a function-like macro cut off by the end of the file.
+func unimplemented(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			if s, ok := r.(string); !ok || !strings.Contains(s, "\n\tTODO") {
+				panic(r)
+			}
+			err = nil
+		}
+	}()
+	return f()
+}
+
+// config returns a configuration for linux/amd64 that finds no headers.
+// cc writes to the configurations it is given, so each file gets its own.
+func config() (*cc.Config, error) {
+	abi, err := cc.NewABI("linux", "amd64")
+	if err != nil {
+		return nil, err
+	}
+	return &cc.Config{ABI: abi, FS: fstest.MapFS{}}, nil
+}
+
+// tokens returns the tokens of n separated by spaces.
+func tokens(n cc.Node) string {
+	var b bytes.Buffer
+	for i, t := range cc.NodeTokens(n) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.Write(t.Src())
+	}
+	return b.String()
+}
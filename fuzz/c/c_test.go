@@ -0,0 +1,19 @@
+package c
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/csrc"
+)
+
+func FuzzFile(f *testing.F) {
+	for _, src := range gen.Sample("c/*", ".c", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckFile(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
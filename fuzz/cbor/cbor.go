@@ -0,0 +1,149 @@
+// Package cbor is a fuzz target for the decoders of
+// github.com/fxamacker/cbor and github.com/vmihailenco/msgpack, which read
+// what other programs send, under limits that must keep an input from
+// nesting deep or counting high enough to exhaust a stack or memory.
+// CheckCBOR reads a CBOR data item with Wellformed, which must accept it
+// exactly when the harness's reader of RFC 8949 finds it well-formed and
+// within the limits, and with Unmarshal, which must decode it if it is
+// also valid and reject text that is not UTF-8, a repeated map key or a
+// bignum that is not a byte string. What decodes must encode in core
+// deterministic encoding, and decode and encode again the same; an item
+// already in that encoding must encode as itself. A Decoder must skip a
+// sequence of items where the reader finds each ends. CheckMsgpack reads
+// a MessagePack object, which Unmarshal must decode exactly when the
+// harness's reader does, to the same value, and encode and decode back
+// the same.
+package cbor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds checking one input.
+var Timeout = 10 * time.Second
+
+// The limits CheckCBOR decodes under, below fxamacker/cbor's defaults so
+// that small inputs reach them.
+const (
+	maxDepth = 16
+	maxItems = 256
+)
+
+// decMode decodes under the limits, rejecting a repeated map key as
+// RFC 8949 section 5.6 has a decoder do.
+var decMode = func() cbor.DecMode {
+	dm, err := cbor.DecOptions{
+		MaxNestedLevels:  maxDepth,
+		MaxArrayElements: maxItems,
+		MaxMapPairs:      maxItems,
+		DupMapKey:        cbor.DupMapKeyEnforcedAPF,
+	}.DecMode()
+	if err != nil {
+		panic(err)
+	}
+	return dm
+}()
+
+// encMode encodes in core deterministic encoding, RFC 8949 section 4.2.1.
+var encMode = func() cbor.EncMode {
+	em, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		panic(err)
+	}
+	return em
+}()
+
+// CheckCBOR checks the CBOR data item in data.
+func CheckCBOR(data []byte) error {
+	return harness.Run(Timeout, func() error { return checkCBOR(data) })
+}
+
+func checkCBOR(data []byte) error {
+	r := &reader{data: data, canonical: true}
+	ok := readAll(r)
+	err := decMode.Wellformed(data)
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("Wellformed: %v, but the item is well-formed", err)
+	case !ok && err == nil:
+		return errors.New("Wellformed accepts an item that is not well-formed or is past the limits")
+	}
+	if err := checkSequence(data); err != nil {
+		return err
+	}
+
+	var v any
+	err = decMode.Unmarshal(data, &v)
+	switch {
+	case err == nil && (!ok || r.invalid):
+		return fmt.Errorf("Unmarshal decodes %#v from an item that is not well-formed or valid", v)
+	case err != nil && ok && !r.invalid && !r.unsure:
+		return fmt.Errorf("Unmarshal: %v, but the item is valid", err)
+	case err != nil:
+		return nil
+	}
+
+	enc, err := encMode.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Marshal(%#v): %v", v, err)
+	}
+	// Known: Marshal writes every NaN as f97e00, map keys too, so that a
+	// map with two NaN keys, which decodes as no NaN equals another,
+	// encodes with a key repeated, which core deterministic encoding and
+	// decoders that enforce unique keys reject.
+	if er := (&reader{data: enc, canonical: true}); !readAll(er) || !er.canonical && !r.nans {
+		return fmt.Errorf("Marshal(%#v) = %x, which is not in core deterministic encoding", v, enc)
+	}
+	var back any
+	if err := decMode.Unmarshal(enc, &back); err != nil {
+		return fmt.Errorf("Marshal(%#v) = %x, which does not decode: %v", v, enc, err)
+	}
+	if again, err := encMode.Marshal(back); err != nil || !bytes.Equal(again, enc) {
+		return fmt.Errorf("%x decodes as %#v, which encodes as %x: %v", enc, back, again, err)
+	}
+	if r.canonical && !r.lossy && !bytes.Equal(enc, data) {
+		return fmt.Errorf("Marshal(%#v) = %x, but the item was in core deterministic encoding", v, enc)
+	}
+	return nil
+}
+
+// readAll reads one item with r, reporting whether it is well-formed and
+// all there is.
+func readAll(r *reader) bool {
+	_, ok := r.item(0)
+	return ok && r.off == len(r.data)
+}
+
+// checkSequence skips the items in data one after another with a
+// Decoder, which must stop at the end of each where the reader does, and
+// fail where the reader does.
+func checkSequence(data []byte) error {
+	dec := decMode.NewDecoder(bytes.NewReader(data))
+	r := &reader{data: data}
+	for r.off < len(data) {
+		start := r.off
+		_, ok := r.item(0)
+		err := dec.Skip()
+		switch {
+		case ok && err != nil:
+			return fmt.Errorf("Decoder.Skip: %v, but the item at %d is well-formed", err, start)
+		case !ok && err == nil:
+			return fmt.Errorf("Decoder.Skip skips to %d, but the item at %d is not well-formed", dec.NumBytesRead(), start)
+		case !ok:
+			return nil
+		case dec.NumBytesRead() != r.off:
+			return fmt.Errorf("Decoder.Skip skips to %d, but the item ends at %d", dec.NumBytesRead(), r.off)
+		}
+	}
+	if err := dec.Skip(); err != io.EOF {
+		return fmt.Errorf("Decoder.Skip at the end: %v, want EOF", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package cbor
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/cborsrc"
+)
+
+func FuzzCBOR(f *testing.F) {
+	for _, src := range gen.Sample("cbor/item", ".cbor", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckCBOR(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMsgpack(f *testing.F) {
+	for _, src := range gen.Sample("cbor/msgpack", ".msgpack", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMsgpack(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,321 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// maxPackDepth is the deepest CheckMsgpack has an object nest.
+const maxPackDepth = 1000
+
+// CheckMsgpack checks the MessagePack object in data.
+func CheckMsgpack(data []byte) error {
+	return harness.Run(Timeout, func() error { return checkMsgpack(data) })
+}
+
+func checkMsgpack(data []byte) error {
+	r := &packReader{data: data}
+	want, ok := r.object(0)
+	// Known: msgpack makes each array, map and binary as large as its
+	// count or length says before it reads an item, so that a count of a
+	// few bytes runs it out of memory. It reads a string or timestamp past
+	// the end into a buffer it keeps in a pool of decoders, which grows by
+	// a quarter or more each time, so that the same few bytes decoded
+	// again and again run it out of memory too. And it nests without
+	// limit, growing the stack with each level.
+	if r.over || r.deep {
+		return nil
+	}
+	var v any
+	err := msgpack.Unmarshal(data, &v)
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("Unmarshal: %v, but the object reads as %#v", err, want)
+	case !ok && err == nil:
+		return fmt.Errorf("Unmarshal decodes %#v from an object that does not read", v)
+	case !ok:
+		return nil
+	case !same(v, want):
+		return fmt.Errorf("Unmarshal decodes %#v, want %#v", v, want)
+	}
+	enc, err := msgpack.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Marshal(%#v): %v", v, err)
+	}
+	var back any
+	if err := msgpack.Unmarshal(enc, &back); err != nil || !same(back, v) {
+		return fmt.Errorf("%#v marshals as %x, which unmarshals as %#v: %v", v, enc, back, err)
+	}
+	return nil
+}
+
+// same reports whether a and b are the same decoded value: of the same
+// types, with floats of the same bits and times of the same instant.
+// Known: Marshal writes a float32 by way of a float64, which sets the
+// quiet bit of a signaling NaN, so same takes a float32 NaN for the one it
+// quiets to.
+func same(a, b any) bool {
+	switch a := a.(type) {
+	case float32:
+		const quiet = 1 << 22
+		b, ok := b.(float32)
+		x, y := math.Float32bits(a), math.Float32bits(b)
+		return ok && (x == y || a != a && b != b && x|quiet == y|quiet)
+	case float64:
+		b, ok := b.(float64)
+		return ok && math.Float64bits(a) == math.Float64bits(b)
+	case time.Time:
+		b, ok := b.(time.Time)
+		return ok && a.Equal(b)
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && bytes.Equal(a, b)
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !same(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			if w, ok := b[k]; !ok || !same(v, w) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// A packReader reads MessagePack objects as the specification has them,
+// into the Go values msgpack decodes each format to in an any.
+type packReader struct {
+	data []byte
+	off  int
+
+	// over is set by an array or map whose count, or string, binary or
+	// timestamp whose length, is of more items than there are bytes left.
+	over bool
+	// deep is set by an object nested past maxPackDepth.
+	deep bool
+}
+
+// next reads the n bytes that follow, if there are as many.
+func (r *packReader) next(n uint64) ([]byte, bool) {
+	if n > uint64(len(r.data)-r.off) {
+		return nil, false
+	}
+	b := r.data[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b, true
+}
+
+// uint reads a big-endian unsigned integer of size bytes.
+func (r *packReader) uint(size int) (uint64, bool) {
+	b, ok := r.next(uint64(size))
+	if !ok {
+		return 0, false
+	}
+	var b8 [8]byte
+	copy(b8[8-size:], b)
+	return binary.BigEndian.Uint64(b8[:]), true
+}
+
+// length reads the count or length of a format whose code is code: in
+// the code itself for a fixed form from fix, if fix is not zero, to
+// below fix+limit; in a byte after code8, if code8 is not zero; in two
+// after code16; and in four otherwise.
+func (r *packReader) length(code, fix byte, limit int, code8, code16 byte) (uint64, bool) {
+	switch {
+	case fix != 0 && code >= fix && int(code) < int(fix)+limit:
+		return uint64(code - fix), true
+	case code8 != 0 && code == code8:
+		return r.uint(1)
+	case code == code16:
+		return r.uint(2)
+	}
+	return r.uint(4)
+}
+
+// fits reports whether n items of per bytes each fit in the bytes left,
+// and sets over if they do not.
+func (r *packReader) fits(n, per uint64) bool {
+	if n*per > uint64(len(r.data)-r.off) {
+		r.over = true
+		return false
+	}
+	return true
+}
+
+// object reads an object nested depth deep, reporting whether it reads
+// and decodes into an any.
+func (r *packReader) object(depth int) (any, bool) {
+	if depth > maxPackDepth {
+		r.deep = true
+		return nil, false
+	}
+	b, ok := r.next(1)
+	if !ok {
+		return nil, false
+	}
+	code := b[0]
+	switch {
+	case code <= 0x7f || code >= 0xe0:
+		return int8(code), true
+	case code <= 0x8f || code == 0xde || code == 0xdf:
+		n, ok := r.length(code, 0x80, 16, 0, 0xde)
+		if !ok || !r.fits(n, 2) {
+			return nil, false
+		}
+		m := make(map[string]any, n)
+		for range n {
+			k, ok := r.key()
+			if !ok {
+				return nil, false
+			}
+			if m[k], ok = r.object(depth + 1); !ok {
+				return nil, false
+			}
+		}
+		return m, true
+	case code <= 0x9f || code == 0xdc || code == 0xdd:
+		n, ok := r.length(code, 0x90, 16, 0, 0xdc)
+		if !ok || !r.fits(n, 1) {
+			return nil, false
+		}
+		a := make([]any, 0, n)
+		for range n {
+			v, ok := r.object(depth + 1)
+			if !ok {
+				return nil, false
+			}
+			a = append(a, v)
+		}
+		return a, true
+	case code <= 0xbf || code >= 0xd9 && code <= 0xdb:
+		n, ok := r.length(code, 0xa0, 32, 0xd9, 0xda)
+		if !ok || !r.fits(n, 1) {
+			return nil, false
+		}
+		s, _ := r.next(n)
+		return string(s), true
+	case code >= 0xc4 && code <= 0xc6:
+		n, ok := r.length(code, 0, 0, 0xc4, 0xc5)
+		if !ok || !r.fits(n, 1) {
+			return nil, false
+		}
+		s, _ := r.next(n)
+		return bytes.Clone(s), true
+	case code >= 0xc7 && code <= 0xc9 || code >= 0xd4 && code <= 0xd8:
+		return r.ext(code)
+	}
+	switch code {
+	case 0xc0:
+		return nil, true
+	case 0xc2, 0xc3:
+		return code == 0xc3, true
+	case 0xca:
+		n, ok := r.uint(4)
+		return math.Float32frombits(uint32(n)), ok
+	case 0xcb:
+		n, ok := r.uint(8)
+		return math.Float64frombits(n), ok
+	case 0xcc:
+		n, ok := r.uint(1)
+		return uint8(n), ok
+	case 0xcd:
+		n, ok := r.uint(2)
+		return uint16(n), ok
+	case 0xce:
+		n, ok := r.uint(4)
+		return uint32(n), ok
+	case 0xcf:
+		return r.uint(8)
+	case 0xd0:
+		n, ok := r.uint(1)
+		return int8(n), ok
+	case 0xd1:
+		n, ok := r.uint(2)
+		return int16(n), ok
+	case 0xd2:
+		n, ok := r.uint(4)
+		return int32(n), ok
+	case 0xd3:
+		n, ok := r.uint(8)
+		return int64(n), ok
+	}
+	return nil, false // 0xc1, which is never used
+}
+
+// key reads a map key, which msgpack decodes into a string from a string
+// or binary, or from nil as the empty string, and from nothing else.
+func (r *packReader) key() (string, bool) {
+	b, ok := r.next(1)
+	if !ok {
+		return "", false
+	}
+	code := b[0]
+	var n uint64
+	switch {
+	case code == 0xc0:
+		return "", true
+	case code >= 0xa0 && code <= 0xbf || code >= 0xd9 && code <= 0xdb:
+		n, ok = r.length(code, 0xa0, 32, 0xd9, 0xda)
+	case code >= 0xc4 && code <= 0xc6:
+		n, ok = r.length(code, 0, 0, 0xc4, 0xc5)
+	default:
+		return "", false
+	}
+	if !ok || !r.fits(n, 1) {
+		return "", false
+	}
+	s, _ := r.next(n)
+	return string(s), true
+}
+
+// ext reads an extension whose code is code. Of the types, msgpack
+// decodes the timestamp, -1, alone.
+func (r *packReader) ext(code byte) (any, bool) {
+	var n uint64
+	ok := true
+	if code >= 0xd4 {
+		n = 1 << (code - 0xd4)
+	} else if n, ok = r.length(code, 0, 0, 0xc7, 0xc8); !ok {
+		return nil, false
+	}
+	typ, ok := r.next(1)
+	if !ok || int8(typ[0]) != -1 || !r.fits(n, 1) {
+		return nil, false
+	}
+	b, _ := r.next(n)
+	// Known: msgpack takes nanoseconds past a second, which the
+	// specification forbids, and carries them into the seconds.
+	var t time.Time
+	switch len(b) {
+	case 4:
+		t = time.Unix(int64(binary.BigEndian.Uint32(b)), 0)
+	case 8:
+		n := binary.BigEndian.Uint64(b)
+		t = time.Unix(int64(n&(1<<34-1)), int64(n>>34))
+	case 12:
+		t = time.Unix(int64(binary.BigEndian.Uint64(b[4:])), int64(binary.BigEndian.Uint32(b)))
+	default:
+		return nil, false
+	}
+	return t, true
+}
@@ -0,0 +1,354 @@
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// A reader reads CBOR data items as RFC 8949 has them, under the limits
+// CheckCBOR decodes with, and notes what a decoder into any must make of
+// them.
+type reader struct {
+	data []byte
+	off  int
+
+	// invalid is set by an item that is well-formed but not valid, which a
+	// decoder must reject: text that is not UTF-8, a map key repeated, or
+	// a time or bignum tag on content of the wrong type.
+	invalid bool
+	// unsure is set by an item a decoder into any may reject or not: a
+	// time, which it must make sense of, or a map key other than an
+	// integer, string, simple value or float, which it may not hash.
+	unsure bool
+	// lossy is set by an item that decodes to a value that encodes
+	// differently however it was written: a time, undefined, or a tag for
+	// self-described CBOR, which decoders drop.
+	lossy bool
+	// nans is set by a map with more than one NaN key, no two of which
+	// are the same key however they are written.
+	nans bool
+	// canonical is cleared by an item not in core deterministic encoding,
+	// RFC 8949 section 4.2.1.
+	canonical bool
+}
+
+// head reads the head of an item, reporting whether it is well-formed;
+// a break is not, where an item should start.
+func (r *reader) head() (major, ai byte, arg uint64, ok bool) {
+	if r.off >= len(r.data) {
+		return 0, 0, 0, false
+	}
+	b := r.data[r.off]
+	r.off++
+	major, ai = b>>5, b&0x1f
+	size := 0
+	switch {
+	case ai < 24:
+		return major, ai, uint64(ai), true
+	case ai == 24:
+		size = 1
+	case ai == 25:
+		size = 2
+	case ai == 26:
+		size = 4
+	case ai == 27:
+		size = 8
+	case ai == 31:
+		// Indefinite length is for strings, arrays and maps alone, and a
+		// break is not an item.
+		return major, ai, 0, major >= majorBytes && major <= majorMap
+	default:
+		return 0, 0, 0, false // reserved
+	}
+	if len(r.data)-r.off < size {
+		return 0, 0, 0, false
+	}
+	var b8 [8]byte
+	copy(b8[8-size:], r.data[r.off:r.off+size])
+	r.off += size
+	arg = binary.BigEndian.Uint64(b8[:])
+	if major == majorSimple {
+		return major, ai, arg, ai != 24 || arg >= 32
+	}
+	// The argument in more bytes than it needs is not preferred.
+	if ai == 24 && arg < 24 || ai > 24 && arg < 1<<(8<<(ai-25)) {
+		r.canonical = false
+	}
+	return major, ai, arg, true
+}
+
+// atBreak reports whether a break is next, and reads it if it is.
+func (r *reader) atBreak() bool {
+	if r.off < len(r.data) && r.data[r.off] == 0xff {
+		r.off++
+		return true
+	}
+	return false
+}
+
+// The major types.
+const (
+	majorUint = iota
+	majorNint
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// item reads a data item nested depth deep, reporting whether it is
+// well-formed and within the limits. If a decoder into any may use it as a
+// map key, key tells it apart from any other that decodes differently,
+// the way the Go values a decoder makes of them compare; otherwise key is
+// empty.
+func (r *reader) item(depth int) (key string, ok bool) {
+	start := r.off
+	major, ai, arg, ok := r.head()
+	if !ok {
+		return "", false
+	}
+	switch major {
+	case majorUint:
+		return "u" + strconv.FormatUint(arg, 10), true
+	case majorNint:
+		if arg > math.MaxInt64 {
+			return "", true // a big.Int, which does not hash
+		}
+		return "n" + strconv.FormatUint(arg, 10), true
+	case majorBytes, majorText:
+		s, ok := r.str(major, ai, arg)
+		if major == majorBytes {
+			return "b" + s, ok
+		}
+		return "t" + s, ok
+	case majorArray, majorMap:
+		if depth++; depth > maxDepth {
+			return "", false
+		}
+		if major == majorArray {
+			return "", r.array(depth, ai, arg)
+		}
+		return "", r.mapItem(depth, ai, arg)
+	case majorTag:
+		return "", r.tagged(depth, arg)
+	}
+	switch {
+	case ai == 20:
+		return "false", true
+	case ai == 21:
+		return "true", true
+	case ai == 22 || ai == 23:
+		r.lossy = r.lossy || ai == 23
+		return "nil", true
+	case ai < 20 || ai == 24:
+		return "s" + strconv.FormatUint(arg, 10), true
+	}
+	f := floatValue(ai, arg)
+	switch {
+	case math.IsNaN(f):
+		r.canonical = r.canonical && ai == 25 && arg == 0x7e00
+		return "nan" + strconv.Itoa(start), true // equal to no other
+	case ai == 26 && isHalf(f), ai == 27 && float64(float32(f)) == f:
+		r.canonical = false
+	}
+	if f == 0 {
+		f = 0 // -0 and 0 are the same key
+	}
+	return "f" + strconv.FormatFloat(f, 'g', -1, 64), true
+}
+
+// str reads the content of a byte or text string of major type major
+// whose head had ai and arg, and its chunks if it has them.
+func (r *reader) str(major, ai byte, arg uint64) (string, bool) {
+	if ai != 31 {
+		if arg > uint64(len(r.data)-r.off) {
+			return "", false
+		}
+		s := string(r.data[r.off : r.off+int(arg)])
+		r.off += int(arg)
+		if major == majorText && !utf8.ValidString(s) {
+			r.invalid = true
+		}
+		return s, true
+	}
+	r.canonical = false
+	var all []byte
+	for !r.atBreak() {
+		// Each chunk is a definite string of the same type, and a text
+		// chunk is UTF-8 by itself, not only together with the others.
+		if r.off >= len(r.data) || r.data[r.off]>>5 != major || r.data[r.off]&0x1f == 31 {
+			return "", false
+		}
+		_, ai, arg, ok := r.head()
+		if !ok {
+			return "", false
+		}
+		s, ok := r.str(major, ai, arg)
+		if !ok {
+			return "", false
+		}
+		all = append(all, s...)
+	}
+	return string(all), true
+}
+
+// array reads the items of an array whose head had ai and arg.
+func (r *reader) array(depth int, ai byte, arg uint64) bool {
+	if ai == 31 {
+		r.canonical = false
+		for n := 1; !r.atBreak(); n++ {
+			if _, ok := r.item(depth); !ok || n > maxItems {
+				return false
+			}
+		}
+		return true
+	}
+	if arg > maxItems {
+		return false
+	}
+	for range arg {
+		if _, ok := r.item(depth); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// mapItem reads the pairs of a map whose head had ai and arg. Its keys
+// must differ as their decoded values do, and, in core deterministic
+// encoding, their encodings be in order.
+func (r *reader) mapItem(depth int, ai byte, arg uint64) bool {
+	indefinite := ai == 31
+	if indefinite {
+		r.canonical = false
+	} else if arg > maxItems {
+		return false
+	}
+	seen := map[string]bool{}
+	var last []byte
+	nans := 0
+	for n := uint64(1); ; n++ {
+		if indefinite && r.atBreak() || !indefinite && n > arg {
+			return true
+		}
+		if n > maxItems {
+			return false
+		}
+		start := r.off
+		key, ok := r.item(depth)
+		if !ok {
+			return false
+		}
+		enc := r.data[start:r.off]
+		if last != nil && bytes.Compare(last, enc) >= 0 {
+			r.canonical = false
+		}
+		last = enc
+		switch {
+		case key == "":
+			r.unsure = true
+		case seen[key]:
+			r.invalid = true
+		case strings.HasPrefix(key, "nan"):
+			nans++
+			r.nans = r.nans || nans > 1
+		}
+		seen[key] = true
+		// A break where the value should be is not well-formed.
+		if _, ok := r.item(depth); !ok {
+			return false
+		}
+	}
+}
+
+// tagged reads the content of a tag numbered num, at depth. Known:
+// fxamacker/cbor counts a tag toward MaxNestedLevels only when it is the
+// content of another tag, where its documentation says tags count as
+// arrays and maps do; a tag's content is checked at the tag's own depth.
+func (r *reader) tagged(depth int, num uint64) bool {
+	if r.off >= len(r.data) {
+		return false
+	}
+	next := r.data[r.off]
+	switch num {
+	case 0:
+		r.invalid = r.invalid || next>>5 != majorText
+		r.unsure, r.lossy = true, true
+	case 1:
+		r.invalid = r.invalid || next>>5 > majorNint && (next < 0xf9 || next > 0xfb)
+		r.unsure, r.lossy = true, true
+	case 2, 3:
+		r.invalid = r.invalid || next>>5 != majorBytes
+	case 55799:
+		r.lossy = true
+	}
+	if next>>5 == majorTag {
+		if depth++; depth > maxDepth {
+			return false
+		}
+		_, _, inner, ok := r.head()
+		return ok && r.tagged(depth, inner)
+	}
+	key, ok := r.item(depth)
+	if ok && (num == 2 || num == 3) && key != "" && key[0] == 'b' {
+		// A bignum in preferred serialization has no leading zeros, and is
+		// too large for an integer.
+		if b := key[1:]; len(b) <= 8 || b[0] == 0 {
+			r.canonical = false
+		}
+	}
+	return ok
+}
+
+// floatValue returns the value of a float of additional information ai,
+// 25 to 27 for half, single and double, with bits arg.
+func floatValue(ai byte, arg uint64) float64 {
+	switch ai {
+	case 25:
+		return halfValue(uint16(arg))
+	case 26:
+		return float64(math.Float32frombits(uint32(arg)))
+	}
+	return math.Float64frombits(arg)
+}
+
+// halfValue returns the value of the half-precision float with bits h.
+func halfValue(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		f = math.Inf(1)
+		if mant != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(1024+mant, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// halves holds the value of every half-precision float but the NaNs.
+var halves = func() map[float64]bool {
+	m := make(map[float64]bool, 1<<16)
+	for h := range 1 << 16 {
+		m[halfValue(uint16(h))] = true
+	}
+	return m
+}()
+
+// isHalf reports whether a half-precision float holds f exactly.
+func isHalf(f float64) bool {
+	return halves[f]
+}
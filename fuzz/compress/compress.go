@@ -0,0 +1,352 @@
+// Package compress is a fuzz target for the decompressors of package
+// compress. CheckFlate, CheckGzip, CheckZlib and CheckBzip2 decompress
+// a stream as a careful reader would: it may give no more than a fixed
+// number of bytes, so that a bomb is read only that far, and reading it
+// must take time and memory within a budget linear in its size, past
+// which it is reported as a blowup. A stream read a few bytes at a
+// time, by a reader reset onto it where the package has Reset, must
+// give the same bytes and error as it does read all at once. A stream
+// read without error must compress again, where the package can, to
+// one that reads back the same; a gzip stream must give the same bytes
+// member by member as it does whole, under headers that write back the
+// same; and a gzip or bzip2 stream must read, twice over, as its bytes
+// twice over.
+package compress
+
+import (
+	"bytes"
+	"compress/bzip2"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what decompressing a stream may cost, per byte of the
+// stream. Output is how many bytes decompressing it may give; the bytes
+// given are allocated, and Base must allow for them.
+type Budget struct {
+	harness.Budget
+	Output int64
+}
+
+// DefaultBudget decompresses up to 16 MiB, which a few kilobytes of
+// deflate or a few dozen bytes of bzip2 give, and allows for the 3.6
+// MiB a bzip2 reader takes for a block.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	Output: 16 << 20,
+}
+
+// levels are the levels a stream read without error is compressed
+// again at, one chosen by its length.
+var levels = []int{
+	flate.HuffmanOnly, flate.DefaultCompression, flate.NoCompression, flate.BestSpeed,
+	2, 3, 4, 5, 6, 7, 8, flate.BestCompression,
+}
+
+// A codec is how a package reads and writes a stream.
+type codec struct {
+	open func(io.Reader) (io.Reader, error)
+	// reset resets a reader from open onto a new stream, or is nil if
+	// the package has no Reset.
+	reset func(r, src io.Reader) error
+	// writer returns a writer that compresses at level, or is nil if
+	// the package has none.
+	writer func(w io.Writer, level int) (io.WriteCloser, error)
+	// early is whether a reader may report an error before it has given
+	// all it decompressed, which how much a Read asks for decides.
+	early bool
+}
+
+var (
+	flateCodec = codec{
+		open:  func(r io.Reader) (io.Reader, error) { return flate.NewReader(r), nil },
+		reset: func(r, src io.Reader) error { return r.(flate.Resetter).Reset(src, nil) },
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return flate.NewWriter(w, level)
+		},
+	}
+	gzipCodec = codec{
+		open:  func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		reset: func(r, src io.Reader) error { return r.(*gzip.Reader).Reset(src) },
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return gzip.NewWriterLevel(w, level)
+		},
+	}
+	zlibCodec = codec{
+		open:  func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		reset: func(r, src io.Reader) error { return r.(zlib.Resetter).Reset(src, nil) },
+		writer: func(w io.Writer, level int) (io.WriteCloser, error) {
+			return zlib.NewWriterLevel(w, level)
+		},
+	}
+	bzip2Codec = codec{
+		open: func(r io.Reader) (io.Reader, error) { return bzip2.NewReader(r), nil },
+		// Known: Reader returns an error its bit reader meets reading
+		// ahead, as at the end of a stream cut short, with the bytes of
+		// each Read from then on, while the block it has decoded lasts.
+		early: true,
+	}
+)
+
+// CheckFlate decompresses the raw deflate stream in data within b.
+// Errors decompressing are expected and ignored.
+func CheckFlate(data []byte, b Budget) error {
+	_, err := check(data, b, flateCodec)
+	return err
+}
+
+// CheckZlib decompresses the zlib stream in data within b. Errors
+// decompressing are expected and ignored.
+func CheckZlib(data []byte, b Budget) error {
+	_, err := check(data, b, zlibCodec)
+	return err
+}
+
+// CheckBzip2 decompresses the bzip2 stream in data within b. Errors
+// decompressing are expected and ignored.
+func CheckBzip2(data []byte, b Budget) error {
+	res, err := check(data, b, bzip2Codec)
+	if err != nil || res.err != nil || res.capped {
+		return err
+	}
+	return twice(data, res, b, bzip2Codec)
+}
+
+// CheckGzip decompresses the gzip stream in data within b. Errors
+// decompressing are expected and ignored.
+func CheckGzip(data []byte, b Budget) error {
+	res, err := check(data, b, gzipCodec)
+	if err != nil || res.err != nil || res.capped {
+		return err
+	}
+	if err := twice(data, res, b, gzipCodec); err != nil {
+		return err
+	}
+	members, err := readMembers(data)
+	if err != nil {
+		return fmt.Errorf("the stream reads whole, but not member by member: %v", err)
+	}
+	var out []byte
+	for _, m := range members {
+		out = append(out, m.content...)
+	}
+	if !bytes.Equal(out, res.out) {
+		return fmt.Errorf("the stream reads whole as %d bytes, but member by member as %d that differ", len(res.out), len(out))
+	}
+	return rewriteMembers(members, levels[len(data)%len(levels)])
+}
+
+// A result is what a stream gives: its bytes, up to a limit, and the
+// error that ended them.
+type result struct {
+	out []byte
+	err error
+	// capped is whether the stream gave more than the limit, which out
+	// holds the first of.
+	capped bool
+}
+
+// check decompresses data with c within b, then again a few bytes at a
+// time, and checks that both give the same; if the stream reads without
+// error and c can write, it also checks that the bytes compress again
+// to a stream that reads back as them. It returns what data gives.
+func check(data []byte, b Budget, c codec) (result, error) {
+	var r io.Reader
+	var res result
+	err := harness.Measure(fmt.Sprintf("decompressing %d bytes", len(data)), b.For(len(data)), func() {
+		var err error
+		if r, err = c.open(bytes.NewReader(data)); err != nil {
+			r, res.err = nil, err
+		} else {
+			res = readAll(r, b.Output)
+		}
+	})
+	if err != nil {
+		return res, err
+	}
+	if r == nil {
+		return res, nil
+	}
+
+	if c.reset != nil {
+		if err := c.reset(r, bytes.NewReader(data)); err != nil {
+			return res, fmt.Errorf("the stream opens, but a reader reset onto it does not: %v", err)
+		}
+	} else {
+		r, _ = c.open(bytes.NewReader(data))
+	}
+	again, err := readPieces(r, b.Output)
+	if err != nil {
+		return res, err
+	}
+	if c.early && res.err != nil && fmt.Sprint(res.err) == fmt.Sprint(again.err) && (bytes.HasPrefix(res.out, again.out) || bytes.HasPrefix(again.out, res.out)) {
+		again = res
+	}
+	if err := same(res, again); err != nil {
+		return res, fmt.Errorf("read all at once and a few bytes at a time, %v", err)
+	}
+
+	if res.err != nil || res.capped || c.writer == nil {
+		return res, nil
+	}
+	level := levels[len(data)%len(levels)]
+	var buf bytes.Buffer
+	w, err := c.writer(&buf, level)
+	if err != nil {
+		return res, err
+	}
+	w.Write(res.out)
+	if err := w.Close(); err != nil {
+		return res, fmt.Errorf("the bytes read do not compress at level %d: %v", level, err)
+	}
+	r, err = c.open(&buf)
+	if err != nil {
+		return res, fmt.Errorf("the bytes read compress at level %d to a stream that does not open: %v", level, err)
+	}
+	back := readAll(r, 1<<62)
+	if back.err != nil || !bytes.Equal(back.out, res.out) {
+		return res, fmt.Errorf("%d bytes read compress at level %d to a stream that reads back as %d bytes that differ, with %v", len(res.out), level, len(back.out), back.err)
+	}
+	return res, nil
+}
+
+// readAll reads r to its end, or until it has given more than limit
+// bytes.
+func readAll(r io.Reader, limit int64) result {
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if int64(len(out)) > limit {
+		return result{out: out[:limit], capped: true}
+	}
+	return result{out: out, err: err}
+}
+
+// readPieces is readAll, reading into buffers of 1 to 512 bytes in
+// turn. It fails if r reads nothing and no error over and over.
+func readPieces(r io.Reader, limit int64) (result, error) {
+	var out []byte
+	buf := make([]byte, 512)
+	empty := 0
+	for i := 0; ; i++ {
+		n, err := r.Read(buf[:min(int64(i%len(buf)+1), limit+1-int64(len(out)))])
+		out = append(out, buf[:n]...)
+		if int64(len(out)) > limit {
+			return result{out: out[:limit], capped: true}, nil
+		}
+		if err == io.EOF {
+			return result{out: out}, nil
+		}
+		if err != nil {
+			return result{out: out, err: err}, nil
+		}
+		if n > 0 {
+			empty = 0
+		} else if empty++; empty == 100 {
+			return result{}, fmt.Errorf("after %d bytes, Read returns no bytes and no error 100 times over", len(out))
+		}
+	}
+}
+
+// same checks that a and b gave the same.
+func same(a, b result) error {
+	if a.capped != b.capped {
+		return fmt.Errorf("the stream gives more than the limit one way (%v), but not the other (%v)", a.capped, b.capped)
+	}
+	if !bytes.Equal(a.out, b.out) {
+		return fmt.Errorf("the stream gives %d bytes one way and %d that differ the other", len(a.out), len(b.out))
+	}
+	if fmt.Sprint(a.err) != fmt.Sprint(b.err) {
+		return fmt.Errorf("the stream ends with %v one way and %v the other", a.err, b.err)
+	}
+	return nil
+}
+
+// twice checks that data, a stream that reads without error as res,
+// reads twice over as its bytes twice over, where they are within b.
+func twice(data []byte, res result, b Budget, c codec) error {
+	if 2*int64(len(res.out)) > b.Output {
+		return nil
+	}
+	r, err := c.open(bytes.NewReader(append(data[:len(data):len(data)], data...)))
+	if err != nil {
+		return fmt.Errorf("the stream reads, but not twice over: %v", err)
+	}
+	again := readAll(r, b.Output)
+	if again.err != nil || !bytes.Equal(again.out, append(res.out[:len(res.out):len(res.out)], res.out...)) {
+		return fmt.Errorf("the stream reads as %d bytes, but twice over as %d that are not them twice, with %v", len(res.out), len(again.out), again.err)
+	}
+	return nil
+}
+
+// A member is a gzip member: its header and what it holds.
+type member struct {
+	h       gzip.Header
+	content []byte
+}
+
+// readMembers reads the gzip stream in data member by member.
+func readMembers(data []byte) ([]member, error) {
+	br := bytes.NewReader(data)
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+	var members []member
+	for {
+		zr.Multistream(false)
+		content, err := io.ReadAll(zr)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member{zr.Header, content})
+		if err := zr.Reset(br); err == io.EOF {
+			return members, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// rewriteMembers writes members as a new gzip stream, compressed at
+// level, which must read back as the same members.
+func rewriteMembers(members []member, level int) error {
+	var b bytes.Buffer
+	for _, m := range members {
+		w, err := gzip.NewWriterLevel(&b, level)
+		if err != nil {
+			return err
+		}
+		w.Header = m.h
+		if _, err := w.Write(m.content); err != nil {
+			return fmt.Errorf("a member read, with header %+v, does not write: %v", m.h, err)
+		}
+		if err := w.Close(); err != nil {
+			return fmt.Errorf("a member read, with header %+v, does not write: %v", m.h, err)
+		}
+	}
+	again, err := readMembers(b.Bytes())
+	if err != nil {
+		return fmt.Errorf("the members read write a stream that does not read: %v", err)
+	}
+	if len(again) != len(members) {
+		return fmt.Errorf("%d members written read back as %d", len(members), len(again))
+	}
+	for i, a := range again {
+		m := members[i]
+		if a.h.Name != m.h.Name || a.h.Comment != m.h.Comment || !bytes.Equal(a.h.Extra, m.h.Extra) || !a.h.ModTime.Equal(m.h.ModTime) || a.h.OS != m.h.OS {
+			return fmt.Errorf("member %d: header\n%+v\nreads back as\n%+v", i, m.h, a.h)
+		}
+		if !bytes.Equal(a.content, m.content) {
+			return fmt.Errorf("member %d: written as %d bytes, but reads back as %d that differ", i, len(m.content), len(a.content))
+		}
+	}
+	return nil
+}
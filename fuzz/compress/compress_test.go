@@ -0,0 +1,52 @@
+package compress
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/compresssrc"
+)
+
+func FuzzFlate(f *testing.F) {
+	for _, src := range gen.Sample("compress/flate", ".flate", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckFlate(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzGzip(f *testing.F) {
+	for _, src := range gen.Sample("compress/gzip", ".gz", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckGzip(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzZlib(f *testing.F) {
+	for _, src := range gen.Sample("compress/zlib", ".zlib", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckZlib(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzBzip2(f *testing.F) {
+	for _, src := range gen.Sample("compress/bzip2", ".bz2", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckBzip2(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
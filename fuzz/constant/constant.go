@@ -0,0 +1,472 @@
+// Package constant is a fuzz target for go/constant, the arbitrary
+// precision arithmetic under the type checker's constant folding. Check
+// decodes two operands from bytes, applies every operation defined for
+// them and compares the results with math/big: wherever go/constant
+// keeps a value exact, as an integer or a fraction, it must be the exact
+// result, and identities such as x+y-y == x must hold.
+package constant
+
+import (
+	"fmt"
+	"go/constant"
+	"go/token"
+	"math"
+	"math/big"
+)
+
+// maxPayload bounds the bytes of an operand, so that no operation takes
+// long.
+const maxPayload = 64
+
+// maxExp is go/constant's bound on the bits of the numerator and
+// denominator of a fraction it keeps exact.
+const maxExp = 4 << 10
+
+// A num is the exact value of a numeric constant, its imaginary part
+// zero unless it is complex. A nil *num is a value known only to within
+// rounding, or not a number.
+type num struct {
+	re, im *big.Rat
+}
+
+// Decode makes an operand of b. The first byte picks the kind and the
+// rest is its value:
+//
+//	0  an integer: a sign byte and the magnitude, big-endian
+//	1  a fraction: numerator and denominator, each half of the rest
+//	2  a float64 of eight bytes
+//	3  a complex number of two integers
+//	4  a float too large or small to be kept as a fraction: a mantissa
+//	   and a power of two in its first two bytes
+//	5  a boolean
+//	6  a string
+//	7  Unknown
+//
+// It returns the value and, for numbers go/constant holds exactly, the
+// exact value.
+func Decode(b []byte) (constant.Value, *num) {
+	if len(b) == 0 {
+		return constant.MakeInt64(0), &num{new(big.Rat), new(big.Rat)}
+	}
+	kind, b := b[0]%8, b[1:]
+	if len(b) > maxPayload {
+		b = b[:maxPayload]
+	}
+	switch kind {
+	case 0:
+		i := integer(b)
+		return constant.Make(i), ofRat(new(big.Rat).SetInt(i))
+	case 1:
+		a, d := new(big.Int).SetBytes(b[:len(b)/2]), new(big.Int).SetBytes(b[len(b)/2:])
+		if d.Sign() == 0 {
+			d.SetInt64(1)
+		}
+		if len(b) > 0 && b[0]&0x80 != 0 {
+			a.Neg(a)
+		}
+		return constant.BinaryOp(constant.Make(a), token.QUO, constant.Make(d)), ofRat(new(big.Rat).SetFrac(a, d))
+	case 2:
+		var u uint64
+		for _, c := range b[:min(8, len(b))] {
+			u = u<<8 | uint64(c)
+		}
+		f := math.Float64frombits(u)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return constant.MakeFloat64(f), nil
+		}
+		return constant.MakeFloat64(f), ofRat(new(big.Rat).SetFloat64(f))
+	case 3:
+		re, im := integer(b[:len(b)/2]), integer(b[len(b)/2:])
+		v := constant.BinaryOp(constant.Make(re), token.ADD, constant.MakeImag(constant.Make(im)))
+		return v, &num{new(big.Rat).SetInt(re), new(big.Rat).SetInt(im)}
+	case 4:
+		exp := 0
+		if len(b) >= 2 {
+			exp, b = int(int16(uint16(b[0])<<8|uint16(b[1]))), b[2:]
+		}
+		lit := fmt.Sprintf("0x%sp%d", new(big.Int).SetBytes(b).Text(16), exp)
+		return constant.MakeFromLiteral(lit, token.FLOAT, 0), nil
+	case 5:
+		return constant.MakeBool(len(b) > 0 && b[0]&1 != 0), nil
+	case 6:
+		return constant.MakeString(string(b)), nil
+	}
+	return constant.MakeUnknown(), nil
+}
+
+// integer reads a sign byte and a big-endian magnitude.
+func integer(b []byte) *big.Int {
+	if len(b) == 0 {
+		return new(big.Int)
+	}
+	i := new(big.Int).SetBytes(b[1:])
+	if b[0]&1 != 0 {
+		i.Neg(i)
+	}
+	return i
+}
+
+// ofRat returns the exact value of a real number.
+func ofRat(r *big.Rat) *num { return &num{r, new(big.Rat)} }
+
+// exact returns the exact value of v, if go/constant holds it exactly
+// and it is small enough to compare.
+func exact(v constant.Value) *num {
+	switch v.Kind() {
+	case constant.Int:
+		return ofRat(new(big.Rat).SetInt(bigInt(v)))
+	case constant.Float:
+		a, d := constant.Num(v), constant.Denom(v)
+		if a.Kind() != constant.Int || d.Kind() != constant.Int {
+			return nil
+		}
+		return ofRat(new(big.Rat).SetFrac(bigInt(a), bigInt(d)))
+	case constant.Complex:
+		re, im := exact(constant.Real(v)), exact(constant.Imag(v))
+		if re == nil || im == nil {
+			return nil
+		}
+		return &num{re.re, im.re}
+	}
+	return nil
+}
+
+func bigInt(v constant.Value) *big.Int {
+	switch x := constant.Val(v).(type) {
+	case int64:
+		return big.NewInt(x)
+	case *big.Int:
+		return x
+	}
+	panic(fmt.Sprintf("Val of Int %v is not an integer", v))
+}
+
+// small reports whether n is exact and go/constant would keep it so.
+func (n *num) small() bool {
+	if n == nil {
+		return false
+	}
+	for _, r := range []*big.Rat{n.re, n.im} {
+		if r.Num().BitLen() >= maxExp || r.Denom().BitLen() >= maxExp {
+			return false
+		}
+	}
+	return true
+}
+
+func (n *num) equal(m *num) bool {
+	return n.re.Cmp(m.re) == 0 && n.im.Cmp(m.im) == 0
+}
+
+func (n *num) String() string {
+	if n.im.Sign() == 0 {
+		return n.re.RatString()
+	}
+	return fmt.Sprintf("(%s + %si)", n.re.RatString(), n.im.RatString())
+}
+
+// arith returns the exact result of x op y for ADD, SUB, MUL and QUO, or
+// nil if either is not exact or y is 0 for QUO.
+func arith(x *num, op token.Token, y *num) *num {
+	if x == nil || y == nil {
+		return nil
+	}
+	r := &num{new(big.Rat), new(big.Rat)}
+	switch op {
+	case token.ADD:
+		r.re.Add(x.re, y.re)
+		r.im.Add(x.im, y.im)
+	case token.SUB:
+		r.re.Sub(x.re, y.re)
+		r.im.Sub(x.im, y.im)
+	case token.MUL:
+		// (a+bi)(c+di) = (ac-bd) + (ad+bc)i
+		r.re.Sub(new(big.Rat).Mul(x.re, y.re), new(big.Rat).Mul(x.im, y.im))
+		r.im.Add(new(big.Rat).Mul(x.re, y.im), new(big.Rat).Mul(x.im, y.re))
+	case token.QUO:
+		// (a+bi)/(c+di) = ((ac+bd) + (bc-ad)i) / (c²+d²)
+		d := new(big.Rat).Add(new(big.Rat).Mul(y.re, y.re), new(big.Rat).Mul(y.im, y.im))
+		if d.Sign() == 0 {
+			return nil
+		}
+		r.re.Add(new(big.Rat).Mul(x.re, y.re), new(big.Rat).Mul(x.im, y.im))
+		r.im.Sub(new(big.Rat).Mul(x.im, y.re), new(big.Rat).Mul(x.re, y.im))
+		r.re.Quo(r.re, d)
+		r.im.Quo(r.im, d)
+	}
+	return r
+}
+
+func numeric(v constant.Value) bool {
+	k := v.Kind()
+	return k == constant.Int || k == constant.Float || k == constant.Complex
+}
+
+// isZero reports whether the number v is zero.
+func isZero(v constant.Value) bool {
+	return constant.Sign(v) == 0
+}
+
+// Check decodes two operands with Decode and checks every operation
+// defined for them, alone and together.
+func Check(a, b []byte) error {
+	x, nx := Decode(a)
+	y, ny := Decode(b)
+	for _, o := range []struct {
+		v constant.Value
+		n *num
+	}{{x, nx}, {y, ny}} {
+		if err := Value(o.v, o.n); err != nil {
+			return fmt.Errorf("%s: %v", o.v.ExactString(), err)
+		}
+	}
+	if err := Binary(x, nx, y, ny); err != nil {
+		return fmt.Errorf("x = %s, y = %s: %v", x.ExactString(), y.ExactString(), err)
+	}
+	return nil
+}
+
+// Value checks the conversions and unary operations of v, whose exact
+// value is n if it is exact.
+func Value(v constant.Value, n *num) error {
+	_, _ = v.String(), v.ExactString()
+	if n != nil {
+		if got := exact(v); got == nil || !got.equal(n) {
+			return fmt.Errorf("holds %v, want %v", got, n)
+		}
+	}
+	if !numeric(v) {
+		if v.Kind() == constant.Bool && constant.UnaryOp(token.NOT, v, 0) != constant.MakeBool(!constant.BoolVal(v)) {
+			return fmt.Errorf("!x is wrong")
+		}
+		return nil
+	}
+	if c := constant.ToComplex(v); c.Kind() != constant.Complex {
+		return fmt.Errorf("ToComplex gives a %v", c.Kind())
+	} else if !constant.Compare(c, token.EQL, v) {
+		return fmt.Errorf("ToComplex(x) = %s != x", c.ExactString())
+	}
+	re, im := constant.Real(v), constant.Imag(v)
+	if v.Kind() != constant.Complex && (!constant.Compare(re, token.EQL, v) || !isZero(im)) {
+		return fmt.Errorf("Real(x) = %s, Imag(x) = %s", re.ExactString(), im.ExactString())
+	}
+	neg := constant.UnaryOp(token.SUB, v, 0)
+	if sum := constant.BinaryOp(v, token.ADD, neg); !isZero(sum) {
+		return fmt.Errorf("x + -x = %s", sum.ExactString())
+	}
+	if v.Kind() == constant.Complex {
+		return nil
+	}
+	if n != nil && constant.Sign(v) != n.re.Sign() {
+		return fmt.Errorf("Sign(x) = %d, want %d", constant.Sign(v), n.re.Sign())
+	}
+	f := constant.ToFloat(v)
+	if f.Kind() != constant.Float || !constant.Compare(f, token.EQL, v) {
+		return fmt.Errorf("ToFloat(x) = %s", f.ExactString())
+	}
+	// Float64Val underflows to 0 silently, as documented, exact or not.
+	if g, ok := constant.Float64Val(v); ok && g != 0 && !constant.Compare(constant.MakeFloat64(g), token.EQL, v) {
+		return fmt.Errorf("Float64Val(x) = %v, reported exact", g)
+	}
+	if m := constant.Make(constant.Val(v)); !constant.Compare(m, token.EQL, v) {
+		return fmt.Errorf("Make(Val(x)) = %s", m.ExactString())
+	}
+	i := constant.ToInt(v)
+	if n != nil {
+		isInt := n.re.IsInt()
+		if (i.Kind() == constant.Int) != isInt || isInt && !constant.Compare(i, token.EQL, v) {
+			return fmt.Errorf("ToInt(x) = %s", i.ExactString())
+		}
+	}
+	if v.Kind() != constant.Int {
+		return nil
+	}
+	return Int(v, bigInt(v))
+}
+
+// Int checks the operations defined only for integers.
+func Int(v constant.Value, z *big.Int) error {
+	if i, ok := constant.Int64Val(v); ok != z.IsInt64() || ok && i != z.Int64() {
+		return fmt.Errorf("Int64Val(x) = %d, %v", i, ok)
+	}
+	if u, ok := constant.Uint64Val(v); ok != z.IsUint64() || ok && u != z.Uint64() {
+		return fmt.Errorf("Uint64Val(x) = %d, %v", u, ok)
+	}
+	if n := constant.BitLen(v); n != z.BitLen() {
+		return fmt.Errorf("BitLen(x) = %d, want %d", n, z.BitLen())
+	}
+	if z.Sign() >= 0 {
+		if m := constant.MakeFromBytes(constant.Bytes(v)); !constant.Compare(m, token.EQL, v) {
+			return fmt.Errorf("MakeFromBytes(Bytes(x)) = %s", m.ExactString())
+		}
+		if m := constant.MakeFromLiteral(v.ExactString(), token.INT, 0); !constant.Compare(m, token.EQL, v) {
+			return fmt.Errorf("MakeFromLiteral(ExactString(x)) = %s", m.ExactString())
+		}
+	}
+	// ^x = -x - 1, and with a precision, the low prec bits of that.
+	want := new(big.Int).Not(z)
+	if got := constant.UnaryOp(token.XOR, v, 0); bigInt(got).Cmp(want) != 0 {
+		return fmt.Errorf("^x = %s, want %s", got.ExactString(), want)
+	}
+	if z.Sign() >= 0 && z.BitLen() <= 64 {
+		want := new(big.Int).And(new(big.Int).Not(z), new(big.Int).SetUint64(math.MaxUint64))
+		if got := constant.UnaryOp(token.XOR, v, 64); bigInt(got).Cmp(want) != 0 {
+			return fmt.Errorf("^x with precision 64 = %s, want %s", got.ExactString(), want)
+		}
+	}
+	return nil
+}
+
+// maxShift bounds shift counts.
+const maxShift = 1 << 10
+
+// Binary checks every operation defined for x and y.
+func Binary(x constant.Value, nx *num, y constant.Value, ny *num) error {
+	if x.Kind() == constant.Unknown || y.Kind() == constant.Unknown {
+		for _, op := range []token.Token{token.ADD, token.SUB, token.MUL, token.LAND} {
+			if r := constant.BinaryOp(x, op, y); r.Kind() != constant.Unknown {
+				return fmt.Errorf("x %s y with an Unknown is %s", op, r.ExactString())
+			}
+		}
+		if constant.Compare(x, token.EQL, y) || constant.Compare(x, token.NEQ, y) {
+			return fmt.Errorf("Unknown compares true")
+		}
+		return nil
+	}
+	switch {
+	case x.Kind() == constant.Bool && y.Kind() == constant.Bool:
+		bx, by := constant.BoolVal(x), constant.BoolVal(y)
+		if constant.BoolVal(constant.BinaryOp(x, token.LAND, y)) != (bx && by) ||
+			constant.BoolVal(constant.BinaryOp(x, token.LOR, y)) != (bx || by) ||
+			constant.Compare(x, token.EQL, y) != (bx == by) || constant.Compare(x, token.NEQ, y) != (bx != by) {
+			return fmt.Errorf("boolean operations disagree with Go's")
+		}
+	case x.Kind() == constant.String && y.Kind() == constant.String:
+		sx, sy := constant.StringVal(x), constant.StringVal(y)
+		if got := constant.StringVal(constant.BinaryOp(x, token.ADD, y)); got != sx+sy {
+			return fmt.Errorf("x + y = %q", got)
+		}
+		return compare(x, y, cmpString(sx, sy))
+	case numeric(x) && numeric(y):
+		return numbers(x, nx, y, ny)
+	}
+	return nil
+}
+
+func cmpString(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compare checks the comparisons of two ordered values that compare as
+// c does, -1, 0 or 1.
+func compare(x, y constant.Value, c int) error {
+	for op, want := range map[token.Token]bool{
+		token.EQL: c == 0, token.NEQ: c != 0, token.LSS: c < 0,
+		token.LEQ: c <= 0, token.GTR: c > 0, token.GEQ: c >= 0,
+	} {
+		if got := constant.Compare(x, op, y); got != want {
+			return fmt.Errorf("x %s y is %v", op, got)
+		}
+	}
+	return nil
+}
+
+// numbers checks the arithmetic of two numbers against math/big, and
+// identities that hold while results stay exact.
+func numbers(x constant.Value, nx *num, y constant.Value, ny *num) error {
+	results := map[token.Token]constant.Value{}
+	for _, op := range []token.Token{token.ADD, token.SUB, token.MUL, token.QUO} {
+		if op == token.QUO && isZero(y) {
+			continue
+		}
+		r := constant.BinaryOp(x, op, y)
+		results[op] = r
+		if !numeric(r) && r.Kind() != constant.Unknown {
+			return fmt.Errorf("x %s y is a %v", op, r.Kind())
+		}
+		if want := arith(nx, op, ny); want.small() {
+			if got := exact(r); got == nil || !got.equal(want) {
+				return fmt.Errorf("x %s y = %s, want %v", op, r.ExactString(), want)
+			}
+		}
+	}
+	for _, op := range []token.Token{token.ADD, token.MUL} {
+		if a, b := results[op], constant.BinaryOp(y, op, x); a.Kind() != constant.Unknown && !constant.Compare(a, token.EQL, b) {
+			return fmt.Errorf("x %s y = %s but y %s x = %s", op, a.ExactString(), op, b.ExactString())
+		}
+	}
+	if nx.small() && ny.small() {
+		// x+y-y == x and, for y != 0, x*y/y == x, computed by
+		// go/constant alone.
+		if sum := arith(nx, token.ADD, ny); sum.small() {
+			if r := constant.BinaryOp(results[token.ADD], token.SUB, y); !constant.Compare(r, token.EQL, x) {
+				return fmt.Errorf("x + y - y = %s", r.ExactString())
+			}
+		}
+		if prod := arith(nx, token.MUL, ny); prod.small() && !isZero(y) {
+			if r := constant.BinaryOp(results[token.MUL], token.QUO, y); !constant.Compare(r, token.EQL, x) {
+				return fmt.Errorf("x * y / y = %s", r.ExactString())
+			}
+		}
+	}
+	if x.Kind() != constant.Complex && y.Kind() != constant.Complex {
+		if nx != nil && ny != nil {
+			if err := compare(x, y, nx.re.Cmp(ny.re)); err != nil {
+				return err
+			}
+		}
+	} else if nx != nil && ny != nil {
+		if got := constant.Compare(x, token.EQL, y); got != nx.equal(ny) {
+			return fmt.Errorf("x == y is %v", got)
+		}
+	}
+	if x.Kind() == constant.Int && y.Kind() == constant.Int {
+		return integers(x, y)
+	}
+	return nil
+}
+
+// integers checks the operations defined only for two integers.
+func integers(x, y constant.Value) error {
+	zx, zy := bigInt(x), bigInt(y)
+	for op, f := range map[token.Token]func(z, a, b *big.Int) *big.Int{
+		token.AND: (*big.Int).And, token.OR: (*big.Int).Or,
+		token.XOR: (*big.Int).Xor, token.AND_NOT: (*big.Int).AndNot,
+	} {
+		want := f(new(big.Int), zx, zy)
+		if got := constant.BinaryOp(x, op, y); got.Kind() != constant.Int || bigInt(got).Cmp(want) != 0 {
+			return fmt.Errorf("x %s y = %s, want %s", op, got.ExactString(), want)
+		}
+	}
+	// Known: BinaryOp divides int64 values with Go's /, so MinInt64 / -1
+	// wraps around to MinInt64.
+	wraps := zx.IsInt64() && zx.Int64() == math.MinInt64 && zy.IsInt64() && zy.Int64() == -1
+	if zy.Sign() != 0 && !wraps {
+		// Go's integer division truncates: x == x/y*y + x%y, and x%y
+		// has the sign of x.
+		q, r := new(big.Int).QuoRem(zx, zy, new(big.Int))
+		gq, gr := constant.BinaryOp(x, token.QUO_ASSIGN, y), constant.BinaryOp(x, token.REM, y)
+		if gq.Kind() != constant.Int || gr.Kind() != constant.Int || bigInt(gq).Cmp(q) != 0 || bigInt(gr).Cmp(r) != 0 {
+			return fmt.Errorf("x / y = %s, x %% y = %s, want %s and %s", gq.ExactString(), gr.ExactString(), q, r)
+		}
+	}
+	s := uint(new(big.Int).Abs(zy).Uint64() % (maxShift + 1))
+	shl := constant.Shift(x, token.SHL, s)
+	if want := new(big.Int).Lsh(zx, s); shl.Kind() != constant.Int || bigInt(shl).Cmp(want) != 0 {
+		return fmt.Errorf("x << %d = %s, want %s", s, shl.ExactString(), want)
+	}
+	// Rsh rounds toward negative infinity, as Go's >> does.
+	if shr, want := constant.Shift(x, token.SHR, s), new(big.Int).Rsh(zx, s); shr.Kind() != constant.Int || bigInt(shr).Cmp(want) != 0 {
+		return fmt.Errorf("x >> %d = %s, want %s", s, shr.ExactString(), want)
+	}
+	if back := constant.Shift(shl, token.SHR, s); !constant.Compare(back, token.EQL, x) {
+		return fmt.Errorf("x << %d >> %d = %s", s, s, back.ExactString())
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package constant
+
+import (
+	"testing"
+)
+
+func FuzzArith(f *testing.F) {
+	for _, x := range [][]byte{
+		{0, 0, 7},
+		{0, 1, 0x80, 0, 0, 0, 0, 0, 0, 0},
+		{1, 0x83, 3},
+		{2, 0x3f, 0xb9, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a},
+		{3, 0, 1, 1, 2},
+		{4, 0x7f, 0xff, 0xff},
+		{4, 0x80, 0x00, 1},
+		{5, 1},
+		{6, 'g', 'o'},
+		{7},
+	} {
+		for _, y := range [][]byte{{0, 0, 3}, {0, 1, 0xff, 0xff}, {1, 1, 0, 2}, {3, 1, 2, 0, 5}} {
+			f.Add(x, y)
+		}
+	}
+	f.Fuzz(func(t *testing.T, x, y []byte) {
+		if err := Check(x, y); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,141 @@
+// Package cost is a fuzz target for the cost, rather than the
+// correctness, of type checking and compiling Go. Each check gets a
+// budget linear in the size of its input; an input that takes more time
+// or memory than that is reported as a blowup, which is how superlinear
+// behaviour such as exponential generic instantiation shows up.
+package cost
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// TypesBudget is the budget for CheckTypes, per syntax node of the
+// input, where Memory counts bytes allocated by the type checker.
+var TypesBudget = harness.Budget{
+	Base: harness.Cost{Time: time.Second, Memory: 256 << 20},
+	Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 64 << 10},
+}
+
+// CompileBudget is the budget for CheckCompile, per syntax node of the
+// input, where Memory is the peak resident size of the compiler process.
+var CompileBudget = harness.Budget{
+	Base: harness.Cost{Time: 2 * time.Second, Memory: 512 << 20},
+	Per:  harness.Cost{Time: 200 * time.Microsecond, Memory: 64 << 10},
+}
+
+// imports is shared between runs so that standard library export data is
+// only loaded once per process.
+var imports = importer.Default()
+
+// nodes parses src and counts its syntax nodes.
+func nodes(src []byte) (*token.FileSet, *ast.File, int, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	n := 0
+	ast.Inspect(f, func(x ast.Node) bool {
+		if x != nil {
+			n++
+		}
+		return true
+	})
+	return fset, f, n, nil
+}
+
+// CheckTypes type-checks src with go/types and returns a
+// *harness.Failure if the checker panics, hangs or exceeds b. Sources
+// that do not parse are skipped; type errors are expected and ignored.
+func CheckTypes(src []byte, b harness.Budget) error {
+	fset, f, n, err := nodes(src)
+	if err != nil {
+		return nil
+	}
+	return harness.Measure(fmt.Sprintf("type checking %d nodes", n), b.For(n), func() {
+		conf := types.Config{Importer: imports, FakeImportC: true, Error: func(error) {}}
+		info := &types.Info{
+			Types:     map[ast.Expr]types.TypeAndValue{},
+			Instances: map[*ast.Ident]types.Instance{},
+		}
+		conf.Check("p", fset, []*ast.File{f}, info)
+	})
+}
+
+// tooldir returns GOTOOLDIR of the go command on $PATH.
+var tooldir = sync.OnceValues(func() (string, error) {
+	out, err := exec.Command("go", "env", "GOTOOLDIR").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+})
+
+// CheckCompile compiles src as package p with the toolchain's compiler
+// and returns a *harness.Failure if the compiler crashes, hangs or
+// exceeds b. Compile errors are expected and ignored; without a go
+// command, as on OSS-Fuzz runners, it does nothing.
+func CheckCompile(src []byte, b harness.Budget) error {
+	_, _, n, err := nodes(src)
+	if err != nil {
+		return nil
+	}
+	dir, err := tooldir()
+	if err != nil {
+		return nil
+	}
+	tmp, err := os.MkdirTemp("", "fuzzcost")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	file := filepath.Join(tmp, "seed.go")
+	if err := os.WriteFile(file, src, 0o666); err != nil {
+		return err
+	}
+
+	limit := b.For(n)
+	ctx, cancel := context.WithTimeout(context.Background(), harness.HangFactor*limit.Time)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, filepath.Join(dir, "compile"), "-p", "p", "-o", filepath.Join(tmp, "p.o"), file)
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	spent := harness.Cost{Time: time.Since(start)}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &harness.Failure{Kind: harness.Hang, After: harness.HangFactor * limit.Time}
+	}
+	// The compiler reports bad input with exit status 2, as it does a
+	// recovered internal compiler error; a runtime fatal error or signal
+	// exits otherwise.
+	if ee := (*exec.ExitError)(nil); err != nil && (!errors.As(err, &ee) || ee.ExitCode() != 2 || isCrash(out)) {
+		return &harness.Failure{Kind: harness.Panic, Value: fmt.Sprintf("%v\n%s", err, out)}
+	}
+	if cmd.ProcessState != nil {
+		spent.Memory = maxRSS(cmd.ProcessState)
+	}
+	return harness.Over(fmt.Sprintf("compiling %d nodes", n), spent, limit)
+}
+
+func isCrash(out []byte) bool {
+	for l := range strings.Lines(string(out)) {
+		if strings.Contains(l, "internal compiler error") || strings.HasPrefix(l, "panic: ") || strings.HasPrefix(l, "fatal error: ") {
+			return true
+		}
+	}
+	return false
+}
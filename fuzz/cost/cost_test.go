@@ -0,0 +1,30 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzTypesCost(f *testing.F) {
+	for _, src := range gen.Sample("go/instantiate", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := CheckTypes(src, TypesBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzCompileCost(f *testing.F) {
+	for _, src := range gen.Sample("go/instantiate", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := CheckCompile(src, CompileBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cost
+
+import "os"
+
+// maxRSS returns 0: the peak resident size of a process is only known
+// on Unix, so the compiler's memory goes unchecked elsewhere.
+func maxRSS(*os.ProcessState) uint64 {
+	return 0
+}
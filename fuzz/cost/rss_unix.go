@@ -0,0 +1,23 @@
+//go:build unix
+
+package cost
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// maxRSS returns the peak resident size of the exited process p in
+// bytes.
+func maxRSS(p *os.ProcessState) uint64 {
+	ru, ok := p.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	// Darwin reports bytes; Linux and the BSDs report kilobytes.
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return uint64(ru.Maxrss)
+	}
+	return uint64(ru.Maxrss) << 10
+}
@@ -0,0 +1,242 @@
+// Package csv is a fuzz target for encoding/csv. CheckReader reads a
+// document with a Reader in each of the modes LazyQuotes and
+// FieldsPerRecord make, within a budget of time and memory linear in the
+// size of the document, past which it is reported as a blowup: a field of
+// megabytes must cost no more than its bytes. The modes must agree, as
+// FieldsPerRecord only decides which records come with ErrFieldCount and
+// LazyQuotes only reads what a strict Reader rejects. FieldPos must give
+// where each field starts and InputOffset how far reading has come; and
+// the records read must write a document that reads back the same.
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// DefaultBudget is what reading a document in every mode may cost, per
+// byte of the document. It allows for each of the six modes copying a
+// document a few times over, and for the records of a document of
+// one-byte fields.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: time.Second, Memory: 64 << 20},
+	Per:  harness.Cost{Time: time.Microsecond, Memory: 512},
+}
+
+// A mode is how a Reader is set.
+type mode struct {
+	lazy   bool // LazyQuotes
+	fields int  // FieldsPerRecord
+}
+
+func (m mode) String() string {
+	return fmt.Sprintf("LazyQuotes %v, FieldsPerRecord %d", m.lazy, m.fields)
+}
+
+// A reading is what a Reader in one mode reads of a document: each record,
+// the error it came with, the FieldPos of each of its fields and the
+// InputOffset after it; and the error that ended reading, nil at the end
+// of the document.
+type reading struct {
+	mode    mode
+	records [][]string
+	errs    []error
+	pos     [][][2]int
+	offsets []int64
+	end     error
+}
+
+// CheckReader reads the document in data within b. Errors reading are
+// expected, and checked only against each other.
+func CheckReader(data []byte, b harness.Budget) error {
+	limit := b.For(len(data))
+	var readings []reading
+	err := harness.Measure(fmt.Sprintf("reading %d bytes", len(data)), limit, func() {
+		for _, lazy := range []bool{false, true} {
+			all := read(data, mode{lazy, -1})
+			readings = append(readings, all, read(data, mode{lazy, 0}))
+			n := 1
+			if len(all.records) > 0 {
+				n = len(all.records[0])
+			}
+			readings = append(readings, read(data, mode{lazy, n}))
+		}
+	})
+	if err != nil {
+		return err
+	}
+	return harness.Run(harness.HangFactor*limit.Time, func() error {
+		return check(data, readings)
+	})
+}
+
+// read reads data in mode m up to the first error other than
+// ErrFieldCount, after which what a Reader reads is no longer the
+// document's.
+func read(data []byte, m mode) reading {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.LazyQuotes = m.lazy
+	r.FieldsPerRecord = m.fields
+	rd := reading{mode: m}
+	for range len(data) + 1 {
+		record, err := r.Read()
+		if err == io.EOF {
+			rd.offsets = append(rd.offsets, r.InputOffset())
+			break
+		}
+		if err != nil && !errors.Is(err, csv.ErrFieldCount) {
+			rd.end = err
+			break
+		}
+		pos := make([][2]int, len(record))
+		for i := range record {
+			pos[i][0], pos[i][1] = r.FieldPos(i)
+		}
+		rd.records = append(rd.records, record)
+		rd.errs = append(rd.errs, err)
+		rd.pos = append(rd.pos, pos)
+		rd.offsets = append(rd.offsets, r.InputOffset())
+	}
+	return rd
+}
+
+// check checks the readings of data: for each of LazyQuotes false and
+// true, FieldsPerRecord -1, 0 and the length of the first record.
+func check(data []byte, readings []reading) error {
+	lines := []int{0}
+	for i, c := range data {
+		if c == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	for _, rd := range readings {
+		if err := checkPositions(data, lines, rd); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < len(readings); i += 3 {
+		all := readings[i]
+		for _, err := range all.errs {
+			if err != nil {
+				return fmt.Errorf("%v: a record comes with %v", all.mode, err)
+			}
+		}
+		for _, rd := range readings[i+1 : i+3] {
+			if err := checkFields(all, rd); err != nil {
+				return err
+			}
+		}
+	}
+	strict, lazy := readings[0], readings[3]
+	if len(lazy.records) < len(strict.records) || !same(lazy.records[:len(strict.records)], strict.records) {
+		return fmt.Errorf("%v reads\n%q\nbut %v\n%q", strict.mode, strict.records, lazy.mode, lazy.records)
+	}
+	if strict.end == nil && (lazy.end != nil || len(lazy.records) != len(strict.records)) {
+		return fmt.Errorf("%v reads the document to its end, but %v reads\n%q\nand stops at %v", strict.mode, lazy.mode, lazy.records, lazy.end)
+	}
+	if strict.end == nil {
+		for _, crlf := range []bool{false, true} {
+			if err := checkWrite(strict.records, crlf); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkPositions checks that each field of rd starts where FieldPos has
+// it, with its opening quote or its text, and that InputOffset only
+// grows, to the size of data at its end.
+func checkPositions(data []byte, lines []int, rd reading) error {
+	for i, record := range rd.records {
+		for j, field := range record {
+			line, col := rd.pos[i][j][0], rd.pos[i][j][1]
+			if line < 1 || line > len(lines) || col < 1 {
+				return fmt.Errorf("%v: field %d of record %d, %q, is at line %d, column %d, of %d lines", rd.mode, j, i, field, line, col, len(lines))
+			}
+			at := lines[line-1] + col - 1
+			if at > len(data) {
+				return fmt.Errorf("%v: field %d of record %d, %q, is at line %d, column %d, past the end", rd.mode, j, i, field, line, col)
+			}
+			if rest := data[at:]; !bytes.HasPrefix(rest, []byte(`"`)) && !bytes.HasPrefix(rest, []byte(field)) {
+				return fmt.Errorf("%v: field %d of record %d, %q, is at line %d, column %d, where %q is", rd.mode, j, i, field, line, col, rest[:min(len(rest), len(field)+8)])
+			}
+		}
+	}
+	last := int64(0)
+	for _, off := range rd.offsets {
+		if off < last || off > int64(len(data)) {
+			return fmt.Errorf("%v: InputOffset goes from %d to %d in %d bytes", rd.mode, last, off, len(data))
+		}
+		last = off
+	}
+	if rd.end == nil && last != int64(len(data)) {
+		return fmt.Errorf("%v: InputOffset is %d at the end of %d bytes", rd.mode, last, len(data))
+	}
+	return nil
+}
+
+// checkFields checks rd, read with FieldsPerRecord 0 or more, against
+// all, read the same but with FieldsPerRecord -1: the same records must
+// be read, with ErrFieldCount for those whose number of fields is not
+// the one set, or for 0 the first record's.
+func checkFields(all, rd reading) error {
+	if !same(rd.records, all.records) || fmt.Sprint(rd.end) != fmt.Sprint(all.end) {
+		return fmt.Errorf("%v reads\n%q (%v)\nbut %v\n%q (%v)", all.mode, all.records, all.end, rd.mode, rd.records, rd.end)
+	}
+	want := rd.mode.fields
+	if want == 0 && len(rd.records) > 0 {
+		want = len(rd.records[0])
+	}
+	for i, record := range rd.records {
+		if bad := len(record) != want; bad != (rd.errs[i] != nil) {
+			return fmt.Errorf("%v: record %d of %d fields, %q, comes with error %v", rd.mode, i, len(record), record, rd.errs[i])
+		}
+	}
+	return nil
+}
+
+// checkWrite writes records with a Writer, with UseCRLF set to crlf, and
+// checks that they read back the same.
+func checkWrite(records [][]string, crlf bool) error {
+	// Known: a record of one empty field is written as a blank line,
+	// which a Reader skips.
+	if slices.ContainsFunc(records, func(r []string) bool { return len(r) == 1 && r[0] == "" }) {
+		return nil
+	}
+	// Known: a Writer with UseCRLF drops a CR in a field, and one without
+	// writes a CRLF in a field as it is, which a Reader reads as an LF.
+	bad := "\r\n"
+	if crlf {
+		bad = "\r"
+	}
+	if slices.ContainsFunc(records, func(r []string) bool {
+		return slices.ContainsFunc(r, func(f string) bool { return strings.Contains(f, bad) })
+	}) {
+		return nil
+	}
+	var out bytes.Buffer
+	w := csv.NewWriter(&out)
+	w.UseCRLF = crlf
+	if err := w.WriteAll(records); err != nil {
+		return fmt.Errorf("UseCRLF %v: records\n%q\ndo not write: %v", crlf, records, err)
+	}
+	again := read(out.Bytes(), mode{false, -1})
+	if again.end != nil || !same(again.records, records) {
+		return fmt.Errorf("UseCRLF %v: records\n%q\nwrite as\n%q\nwhich reads back as\n%q (%v)", crlf, records, out.Bytes(), again.records, again.end)
+	}
+	return nil
+}
+
+// same reports whether a and b hold the same records.
+func same(a, b [][]string) bool {
+	return slices.EqualFunc(a, b, slices.Equal)
+}
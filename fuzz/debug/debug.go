@@ -0,0 +1,317 @@
+// Package debug is a fuzz target for debug/elf, debug/pe and
+// debug/macho. CheckELF, CheckPE and CheckMachO open an object file and
+// read it as a debugger or linker would: each section, and each segment
+// of a file that has them, through Open and through Data, then its
+// symbols, the libraries and symbols it imports, and its DWARF. What
+// they read may come to no more than a fixed number of bytes, so that a
+// compressed section that is a bomb is read only that far, and nothing
+// is read whole that the sections together are too large for; opening
+// and reading the file must take time and memory within a budget linear
+// in its size, past which it is reported as a blowup. A section or
+// segment that is not compressed must read as the bytes of the file its
+// header points at; Data must give the first bytes of what Open reads,
+// as many as the header says, or fail if Open reads fewer; and each
+// architecture of a Mach-O fat file must read as the same file on its
+// own. CheckDWARF reads DWARF sections on their own: every entry, and
+// the line programs, address ranges and types they point at.
+package debug
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what reading a file may cost, per byte of the file. Read
+// is how many bytes reading its sections and segments may give, all of
+// them together, and how large they may be together for its symbols,
+// imports and DWARF to be read; the bytes read are allocated, and Base
+// must allow for them.
+type Budget struct {
+	harness.Budget
+	Read int64
+}
+
+// DefaultBudget reads up to 16 MiB, which a few kilobytes of compressed
+// zeros give.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	Read: 16 << 20,
+}
+
+// CheckELF reads the ELF file in data within b. Errors reading are
+// expected and ignored.
+func CheckELF(data []byte, b Budget) error {
+	return check(data, b, readELF)
+}
+
+// CheckPE reads the PE file in data within b. Errors reading are
+// expected and ignored.
+func CheckPE(data []byte, b Budget) error {
+	return check(data, b, readPE)
+}
+
+// CheckMachO reads the Mach-O file, or fat file, in data within b.
+// Errors reading are expected and ignored.
+func CheckMachO(data []byte, b Budget) error {
+	return check(data, b, readMachO)
+}
+
+// check runs read on data within b, with b.Read bytes left to read.
+func check(data []byte, b Budget, read func(data []byte, left *int64, budget int64) error) error {
+	return within(len(data), b, func() error {
+		left := b.Read
+		return read(data, &left, b.Read)
+	})
+}
+
+// within runs f, which reads n bytes, within b's time and memory.
+func within(n int, b Budget, f func() error) error {
+	var ferr error
+	err := harness.Measure(fmt.Sprintf("reading %d bytes", n), b.For(n), func() {
+		ferr = f()
+	})
+	if err != nil {
+		return err
+	}
+	return ferr
+}
+
+// readELF opens the ELF file in data and reads its sections, segments,
+// symbols, imports and DWARF.
+func readELF(data []byte, left *int64, budget int64) error {
+	f, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	total := uint64(0)
+	for _, s := range f.Sections {
+		if s.Type == elf.SHT_NOBITS {
+			continue
+		}
+		// Open takes a .zdebug section's size from the section, so
+		// that Size is what it decompresses to only once it is open.
+		r := s.Open()
+		raw := s.Flags&elf.SHF_COMPRESSED == 0 && !strings.HasPrefix(s.Name, ".zdebug")
+		err := checkSection(data, section{s.Name, r, s.Data, s.Size, raw, s.Offset}, left)
+		if err != nil {
+			return err
+		}
+		total += s.Size
+	}
+	for i, p := range f.Progs {
+		err := checkSection(data, section{fmt.Sprintf("segment %d", i), p.Open(), nil, p.Filesz, true, p.Off}, left)
+		if err != nil {
+			return err
+		}
+	}
+	if total > uint64(budget) {
+		return nil
+	}
+	f.Symbols()
+	f.DynamicSymbols()
+	f.ImportedSymbols()
+	f.ImportedLibraries()
+	f.DynamicVersions()
+	f.DynamicVersionNeeds()
+	f.DynString(elf.DT_RUNPATH)
+	f.DWARF()
+	return nil
+}
+
+// readPE opens the PE file in data and reads its sections, imports and
+// DWARF.
+func readPE(data []byte, left *int64, budget int64) error {
+	f, err := pe.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	total := uint64(0)
+	for _, s := range f.Sections {
+		err := checkSection(data, section{s.Name, s.Open(), s.Data, uint64(s.Size), true, uint64(s.Offset)}, left)
+		if err != nil {
+			return err
+		}
+		total += max(uint64(s.Size), inflated(s.Open()))
+	}
+	if total > uint64(budget) {
+		return nil
+	}
+	f.ImportedSymbols()
+	f.ImportedLibraries()
+	f.DWARF()
+	return nil
+}
+
+// readMachO opens the Mach-O file in data, or each file of a fat file,
+// and reads its sections, segments, symbols, imports and DWARF.
+func readMachO(data []byte, left *int64, budget int64) error {
+	f, err := macho.NewFile(bytes.NewReader(data))
+	if err == nil {
+		return readMachOFile(data, f, left, budget)
+	}
+	ff, err := macho.NewFatFile(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	for i, arch := range ff.Arches {
+		// An architecture lies within the file, or runs past its end.
+		if uint64(arch.Offset) > uint64(len(data)) {
+			continue
+		}
+		image := data[arch.Offset:min(uint64(arch.Offset)+uint64(arch.Size), uint64(len(data)))]
+		thin, err := macho.NewFile(bytes.NewReader(image))
+		if err != nil {
+			return fmt.Errorf("architecture %d (%v) reads in the fat file, but not on its own: %v", i, arch.Cpu, err)
+		}
+		if err := same(arch.File, thin); err != nil {
+			return fmt.Errorf("architecture %d (%v): %v", i, arch.Cpu, err)
+		}
+		if err := readMachOFile(image, arch.File, left, budget); err != nil {
+			return fmt.Errorf("architecture %d (%v): %v", i, arch.Cpu, err)
+		}
+	}
+	return nil
+}
+
+// readMachOFile reads the sections, segments, symbols, imports and
+// DWARF of f, which is the file in data.
+func readMachOFile(data []byte, f *macho.File, left *int64, budget int64) error {
+	total := uint64(0)
+	for _, s := range f.Sections {
+		err := checkSection(data, section{s.Seg + "," + s.Name, s.Open(), s.Data, s.Size, true, uint64(s.Offset)}, left)
+		if err != nil {
+			return err
+		}
+		total += max(s.Size, inflated(s.Open()))
+	}
+	for _, l := range f.Loads {
+		s, ok := l.(*macho.Segment)
+		if !ok {
+			continue
+		}
+		err := checkSection(data, section{s.Name, s.Open(), s.Data, s.Filesz, true, s.Offset}, left)
+		if err != nil {
+			return err
+		}
+	}
+	if total > uint64(budget) {
+		return nil
+	}
+	// Known: NewFile checks that the undefined symbols of the dynamic
+	// symbol table end within the symbol table by adding their index and
+	// count as uint32s, which wraps, so that ImportedSymbols slices the
+	// table from past their end.
+	if d := f.Dysymtab; d == nil || d.Iundefsym+d.Nundefsym >= d.Iundefsym {
+		f.ImportedSymbols()
+	}
+	f.ImportedLibraries()
+	f.DWARF()
+	return nil
+}
+
+// same checks that files a and b have the same load commands, sections
+// and symbols.
+func same(a, b *macho.File) error {
+	if a.FileHeader != b.FileHeader {
+		return fmt.Errorf("header %+v reads on its own as %+v", a.FileHeader, b.FileHeader)
+	}
+	if len(a.Loads) != len(b.Loads) || len(a.Sections) != len(b.Sections) {
+		return fmt.Errorf("%d load commands and %d sections read on their own as %d and %d", len(a.Loads), len(a.Sections), len(b.Loads), len(b.Sections))
+	}
+	for i, s := range a.Sections {
+		if s.SectionHeader != b.Sections[i].SectionHeader {
+			return fmt.Errorf("section %+v reads on its own as %+v", s.SectionHeader, b.Sections[i].SectionHeader)
+		}
+	}
+	if (a.Symtab == nil) != (b.Symtab == nil) || a.Symtab != nil && len(a.Symtab.Syms) != len(b.Symtab.Syms) {
+		return errors.New("the symbol table reads differently on its own")
+	}
+	return nil
+}
+
+// A section is a section or segment of any of the formats: its name,
+// the reader Open gives, its Data method, if it has one, its size, and
+// whether it is read as it is in the file, from off, or is compressed.
+type section struct {
+	name string
+	r    io.Reader
+	data func() ([]byte, error)
+	size uint64
+	raw  bool
+	off  uint64
+}
+
+// checkSection reads s to its end, taking what it reads from *left, and
+// checks that it reads as the bytes of data at its offset, if it is raw,
+// and that its Data is the first bytes it reads.
+func checkSection(data []byte, s section, left *int64) error {
+	content, err := read(s.r, left)
+	if err != nil {
+		return nil
+	}
+	if s.raw && len(content) > 0 {
+		if s.off > uint64(len(data)) || uint64(len(content)) > uint64(len(data))-s.off {
+			return fmt.Errorf("%q: reads %d bytes at %d, past the end of the file (%d bytes)", s.name, len(content), s.off, len(data))
+		}
+		if want := data[s.off : s.off+uint64(len(content))]; !bytes.Equal(content, want) {
+			return fmt.Errorf("%q: reads %q at %d, but the file has %q", s.name, content, s.off, want)
+		}
+	}
+	if s.data == nil || s.size > uint64(*left) {
+		return nil
+	}
+	d, err := s.data()
+	if uint64(len(content)) < s.size {
+		if err == nil {
+			return fmt.Errorf("%q: Data gives %d bytes, but Open reads only %d", s.name, len(d), len(content))
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("%q: Open reads %d bytes, but Data fails for %d: %v", s.name, len(content), s.size, err)
+	}
+	if !bytes.Equal(d, content[:s.size]) {
+		return fmt.Errorf("%q: Data gives %q, but Open reads %q", s.name, d, content[:s.size])
+	}
+	*left -= int64(s.size)
+	return nil
+}
+
+// inflated returns the size a section read from r says it decompresses
+// to, if it starts with "ZLIB" and the size, as DWARF sections named
+// .zdebug_* and __zdebug_* do, or 0.
+func inflated(r io.Reader) uint64 {
+	var h [12]byte
+	if _, err := io.ReadFull(r, h[:]); err != nil || string(h[:4]) != "ZLIB" {
+		return 0
+	}
+	return binary.BigEndian.Uint64(h[4:])
+}
+
+// errLimit is what read returns once it has read all it may.
+var errLimit = errors.New("read limit reached")
+
+// read reads r to its end, taking what it reads from *left; it reads no
+// more than *left bytes, and returns errLimit if r has more.
+func read(r io.Reader, left *int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, *left+1))
+	if int64(len(b)) > *left {
+		*left = 0
+		return nil, errLimit
+	}
+	*left -= int64(len(b))
+	return b, err
+}
@@ -0,0 +1,68 @@
+package debug
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/debugsrc"
+)
+
+func FuzzELF(f *testing.F) {
+	for _, src := range gen.Sample("debug/elf", ".elf", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckELF(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzPE(f *testing.F) {
+	for _, src := range gen.Sample("debug/pe", ".exe", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPE(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMachO(f *testing.F) {
+	for _, src := range gen.Sample("debug/macho", ".macho", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMachO(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzDWARF(f *testing.F) {
+	g := gen.Lookup("debug/dwarf")
+	for i := range 64 {
+		var secs Sections
+		for _, file := range g.Generate(i) {
+			switch file.Name {
+			case "abbrev.dwarf":
+				secs.Abbrev = file.Data
+			case "info.dwarf":
+				secs.Info = file.Data
+			case "line.dwarf":
+				secs.Line = file.Data
+			case "ranges.dwarf":
+				secs.Ranges = file.Data
+			case "str.dwarf":
+				secs.Str = file.Data
+			}
+		}
+		f.Add(secs.Abbrev, secs.Info, secs.Line, secs.Ranges, secs.Str)
+	}
+	f.Fuzz(func(t *testing.T, abbrev, info, line, ranges, str []byte) {
+		if err := CheckDWARF(Sections{abbrev, info, line, ranges, str}, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
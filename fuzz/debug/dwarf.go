@@ -0,0 +1,342 @@
+package debug
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// Sections are the DWARF sections CheckDWARF reads, as dwarf.New takes
+// them.
+type Sections struct {
+	Abbrev, Info, Line, Ranges, Str []byte
+}
+
+func (s Sections) size() int {
+	return len(s.Abbrev) + len(s.Info) + len(s.Line) + len(s.Ranges) + len(s.Str)
+}
+
+// CheckDWARF reads the DWARF in secs within b, as a debugger would:
+// every entry of .debug_info, the line program, address ranges and
+// types of each, and each entry again after seeking to it. An entry must
+// read the same after a seek as it did in order, and a line program the
+// same from the start again and from a position Tell gave. Only b's time
+// and memory apply, as nothing DWARF reads can be larger than the
+// sections. Errors reading are expected and ignored.
+func CheckDWARF(secs Sections, b Budget) error {
+	return within(secs.size(), b, func() error { return readDWARF(secs) })
+}
+
+// readDWARF reads the entries of secs, and the line programs, ranges and
+// types they point at.
+func readDWARF(secs Sections) error {
+	d, err := dwarf.New(secs.Abbrev, nil, nil, secs.Info, secs.Line, nil, secs.Ranges, secs.Str)
+	if err != nil {
+		return nil
+	}
+	var entries []*dwarf.Entry
+	r := d.Reader()
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil {
+			break
+		}
+		if e.Tag != 0 {
+			entries = append(entries, e)
+		}
+		switch e.Tag {
+		case dwarf.TagCompileUnit:
+			if err := checkLines(d, r.ByteOrder(), secs.Line, e); err != nil {
+				return fmt.Errorf("unit at %#x: %v", e.Offset, err)
+			}
+			d.Ranges(e)
+		case dwarf.TagSubprogram, dwarf.TagLexDwarfBlock:
+			d.Ranges(e)
+		}
+	}
+	// Known: Type works out the size of a typedef, qualifier or array
+	// from the type it refers to, which it recurses into until the stack
+	// overflows if that comes back to the type it started from.
+	if !sizeCycle(entries) {
+		for _, e := range entries {
+			off, ok := e.Val(dwarf.AttrType).(dwarf.Offset)
+			if !ok {
+				continue
+			}
+			// Known: so do the String and Size of a type that refers
+			// to itself as it is, a pointer to itself or a structure
+			// without a name with a field of its own type.
+			if t, err := d.Type(off); err == nil && !cyclic(t) {
+				_ = t.String()
+				t.Size()
+			}
+		}
+	}
+	for _, want := range entries {
+		r.Seek(want.Offset)
+		got, err := r.Next()
+		if err != nil || got == nil {
+			return fmt.Errorf("entry at %#x reads in order, but not after a seek: %v", want.Offset, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			return fmt.Errorf("entry at %#x reads in order as %v, but after a seek as %v", want.Offset, want, got)
+		}
+	}
+	return nil
+}
+
+// checkLines reads the line program of the compile unit cu, then reads
+// it again from the start and from the position of each row.
+func checkLines(d *dwarf.Data, bo binary.ByteOrder, line []byte, cu *dwarf.Entry) error {
+	// Known: LineReader makes the directory and file tables of a DWARF 5
+	// line program as large as their counts say before it reads an entry
+	// of them, so that a count of a few bytes runs out of memory.
+	if off, ok := cu.Val(dwarf.AttrStmtList).(int64); ok && !lineTablesFit(line, off, bo) {
+		return nil
+	}
+	lr, err := d.LineReader(cu)
+	if err != nil || lr == nil {
+		return nil
+	}
+	var (
+		rows []dwarf.LineEntry
+		pos  []dwarf.LineReaderPos
+	)
+	for {
+		p := lr.Tell()
+		var row dwarf.LineEntry
+		if err = lr.Next(&row); err != nil {
+			break
+		}
+		rows = append(rows, row)
+		pos = append(pos, p)
+	}
+	if err == io.EOF {
+		lr.Reset()
+	} else {
+		// Known: a LineReader that fails keeps failing after Reset and
+		// Seek, which leave the error it had in place. The rows up to
+		// the failure read the same again from a new one.
+		lr, _ = d.LineReader(cu)
+	}
+	for i, want := range rows {
+		var got dwarf.LineEntry
+		if err := lr.Next(&got); err != nil {
+			return fmt.Errorf("line row %d reads, but not from the start again: %v", i, err)
+		}
+		if !sameRow(got, want) {
+			return fmt.Errorf("line row %d reads as %+v, but from the start again as %+v", i, want, got)
+		}
+	}
+	for i := len(rows) - 1; i >= 0; i -= max(1, len(rows)/16) {
+		lr.Seek(pos[i])
+		var got dwarf.LineEntry
+		if err := lr.Next(&got); err != nil {
+			return fmt.Errorf("line row %d reads, but not after a seek: %v", i, err)
+		}
+		if !sameRow(got, rows[i]) {
+			return fmt.Errorf("line row %d reads as %+v, but after a seek as %+v", i, rows[i], got)
+		}
+	}
+	return nil
+}
+
+// lineTablesFit reports whether the directory and file tables of the
+// line program at off in line, if it is a DWARF 5 one, have no more
+// entries than the program has bytes, reading its header as LineReader
+// does.
+func lineTablesFit(line []byte, off int64, bo binary.ByteOrder) bool {
+	if off < 0 || off > int64(len(line)) {
+		return true
+	}
+	b := line[off:]
+	bad := false
+	take := func(n uint64) []byte {
+		if bad || n > uint64(len(b)) {
+			bad, b = true, nil
+			return make([]byte, 8)
+		}
+		v := b[:n]
+		b = b[n:]
+		return v
+	}
+	word := func(dwarf64 bool) uint64 {
+		if dwarf64 {
+			return bo.Uint64(take(8))
+		}
+		return uint64(bo.Uint32(take(4)))
+	}
+	uleb := func() uint64 {
+		v, n := binary.Uvarint(b)
+		if n <= 0 {
+			bad, b = true, nil
+			return 0
+		}
+		b = b[n:]
+		return v
+	}
+	length := uint64(bo.Uint32(take(4)))
+	dwarf64 := length == 0xffffffff
+	if dwarf64 {
+		length = bo.Uint64(take(8))
+	}
+	if bad || length > uint64(len(b)) {
+		return true
+	}
+	b = b[:length]
+	if bo.Uint16(take(2)) != 5 {
+		return true
+	}
+	take(2) // address and segment selector sizes
+	word(dwarf64)
+	take(5) // minimum instruction length, maximum operations, default is_stmt, line base and range
+	opcodeBase := take(1)[0]
+	take(uint64(max(opcodeBase, 1) - 1))
+	for table := range 2 {
+		formats := make([]uint64, take(1)[0])
+		for i := range formats {
+			uleb()
+			formats[i] = uleb()
+		}
+		n := uleb()
+		if bad {
+			return true
+		}
+		if n > length {
+			return false
+		}
+		if table == 1 {
+			break
+		}
+		for range n {
+			for _, form := range formats {
+				switch form {
+				case 0x08: // DW_FORM_string
+					i := bytes.IndexByte(b, 0)
+					if i < 0 {
+						return true
+					}
+					b = b[i+1:]
+				case 0x0e, 0x1f, 0x1d: // DW_FORM_strp, line_strp and strp_sup
+					word(dwarf64)
+				case 0x1a, 0x0f: // DW_FORM_strx and udata
+					uleb()
+				case 0x25, 0x0b: // DW_FORM_strx1 and data1
+					take(1)
+				case 0x26, 0x05: // DW_FORM_strx2 and data2
+					take(2)
+				case 0x27: // DW_FORM_strx3
+					take(3)
+				case 0x28, 0x06: // DW_FORM_strx4 and data4
+					take(4)
+				case 0x07: // DW_FORM_data8
+					take(8)
+				case 0x1e: // DW_FORM_data16
+					take(16)
+				case 0x09: // DW_FORM_block
+					take(uleb())
+				}
+			}
+			if bad {
+				return true
+			}
+		}
+	}
+	return true
+}
+
+// sameRow reports whether two rows of a line table are the same, with
+// the same file by name.
+func sameRow(a, b dwarf.LineEntry) bool {
+	af, bf := a.File, b.File
+	a.File, b.File = nil, nil
+	if (af == nil) != (bf == nil) || af != nil && af.Name != bf.Name {
+		return false
+	}
+	return a == b
+}
+
+// sizeCycle reports whether the types among entries whose size is that
+// of the type they refer to, typedefs, qualifiers and arrays, refer to
+// each other in a cycle.
+func sizeCycle(entries []*dwarf.Entry) bool {
+	next := map[dwarf.Offset]dwarf.Offset{}
+	for _, e := range entries {
+		switch e.Tag {
+		case dwarf.TagTypedef, dwarf.TagConstType, dwarf.TagVolatileType, dwarf.TagRestrictType, dwarf.TagArrayType:
+			if off, ok := e.Val(dwarf.AttrType).(dwarf.Offset); ok {
+				next[e.Offset] = off
+			}
+		}
+	}
+	// done holds the types whose chain has been followed to its end.
+	done := map[dwarf.Offset]bool{}
+	for start := range next {
+		var chain []dwarf.Offset
+		on := map[dwarf.Offset]bool{}
+		for off := start; !done[off]; {
+			if on[off] {
+				return true
+			}
+			to, ok := next[off]
+			if !ok {
+				break
+			}
+			on[off] = true
+			chain = append(chain, off)
+			off = to
+		}
+		for _, off := range chain {
+			done[off] = true
+		}
+	}
+	return false
+}
+
+// cyclic reports whether the String or Size of t come back to a type
+// they are already working out: through the type a pointer, qualifier,
+// typedef or array refers to, the fields of a structure without a name,
+// which it spells out, or the parameters and result of a function.
+func cyclic(t dwarf.Type) bool {
+	return reaches(t, map[dwarf.Type]bool{})
+}
+
+func reaches(t dwarf.Type, working map[dwarf.Type]bool) bool {
+	if t == nil {
+		return false
+	}
+	if working[t] {
+		return true
+	}
+	working[t] = true
+	defer delete(working, t)
+	var next []dwarf.Type
+	switch u := t.(type) {
+	case *dwarf.QualType:
+		next = append(next, u.Type)
+	case *dwarf.TypedefType:
+		next = append(next, u.Type)
+	case *dwarf.PtrType:
+		next = append(next, u.Type)
+	case *dwarf.ArrayType:
+		next = append(next, u.Type)
+	case *dwarf.StructType:
+		if u.StructName == "" {
+			for _, f := range u.Field {
+				next = append(next, f.Type)
+			}
+		}
+	case *dwarf.FuncType:
+		next = append(next, u.ReturnType)
+		next = append(next, u.ParamType...)
+	}
+	for _, n := range next {
+		if reaches(n, working) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,261 @@
+// Package dns is a fuzz target for golang.org/x/net/dns/dnsmessage and
+// github.com/miekg/dns. CheckMessage unpacks a message with dnsmessage,
+// which must also skip over all of it with a Parser, and packs it again:
+// the message packed must unpack to the same one. CheckMsg does the same
+// with miekg/dns, packed with and without compression, where Len must
+// give no less than the length packed; and where dnsmessage unpacks the message too,
+// the two must agree on its header and its records' types, classes and
+// TTLs.
+package dns
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/miekg/dns"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Timeout bounds checking one message.
+var Timeout = 10 * time.Second
+
+// CheckMessage checks the message in data with dnsmessage.
+func CheckMessage(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkMessage(data)
+	})
+}
+
+func checkMessage(data []byte) error {
+	var m dnsmessage.Message
+	if err := m.Unpack(data); err != nil {
+		return nil
+	}
+	// Known: Unpack does not check that a record it reads field by field
+	// ends within the message, as skipping one does, so only skipping
+	// may run out of data.
+	if err := skip(data); err != nil && skip(append(data, make([]byte, 1<<16)...)) != nil {
+		return fmt.Errorf("a message that unpacks does not skip: %v", err)
+	}
+	b, err := m.Pack()
+	if err != nil {
+		return fmt.Errorf("a message that unpacks does not pack: %v", err)
+	}
+	var again dnsmessage.Message
+	if err := again.Unpack(b); err != nil {
+		return fmt.Errorf("a message packed does not unpack: %v\n%x", err, b)
+	}
+	// A record's length is what it was packed with, not what it says.
+	lengths(&m)
+	lengths(&again)
+	if !reflect.DeepEqual(m, again) {
+		return fmt.Errorf("a message packed unpacks to\n%v\nnot\n%v\n%x", &again, &m, b)
+	}
+	return nil
+}
+
+// skip skips over every section of msg with a Parser.
+func skip(msg []byte) error {
+	var p dnsmessage.Parser
+	if _, err := p.Start(msg); err != nil {
+		return err
+	}
+	for _, f := range []func() error{p.SkipAllQuestions, p.SkipAllAnswers, p.SkipAllAuthorities, p.SkipAllAdditionals} {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lengths clears the lengths of the records of m.
+func lengths(m *dnsmessage.Message) {
+	for _, rs := range [][]dnsmessage.Resource{m.Answers, m.Authorities, m.Additionals} {
+		for i := range rs {
+			rs[i].Header.Length = 0
+		}
+	}
+}
+
+// CheckMsg checks the message in data with miekg/dns, and against
+// dnsmessage.
+func CheckMsg(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkMsg(data)
+	})
+}
+
+func checkMsg(data []byte) error {
+	m := new(dns.Msg)
+	if err := m.Unpack(data); err != nil {
+		return nil
+	}
+	// Known: miekg/dns unpacks some records it does not pack back.
+	for _, rs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		if slices.ContainsFunc(rs, unpacksOnly) {
+			return nil
+		}
+	}
+	text := m.String()
+	for _, compress := range []bool{false, true} {
+		m.Compress = compress
+		b, err := m.Pack()
+		if err != nil {
+			return fmt.Errorf("a message that unpacks does not pack (compressed: %v): %v\n%s", compress, err, text)
+		}
+		// Known: Len counts the escapes character strings have as text,
+		// so it may give more than the length packed. Less would let
+		// Truncate fill a reply past the size it was given.
+		if n := m.Len(); n < len(b) {
+			return fmt.Errorf("Len gives %d for a message packed in %d bytes (compressed: %v)\n%s", n, len(b), compress, text)
+		}
+		again := new(dns.Msg)
+		if err := again.Unpack(b); err != nil {
+			return fmt.Errorf("a message packed does not unpack (compressed: %v): %v\n%s\n%x", compress, err, text, b)
+		}
+		if s := again.String(); s != text {
+			return fmt.Errorf("a message packed unpacks (compressed: %v) to\n%s\nnot\n%s\n%x", compress, s, text, b)
+		}
+	}
+
+	var xm dnsmessage.Message
+	if err := xm.Unpack(data); err != nil {
+		return nil
+	}
+	if got, want := summary(m), xsummary(&xm); !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("miekg/dns reads a message as\n%+v\nand dnsmessage as\n%+v", got, want)
+	}
+	return nil
+}
+
+// unpacksOnly reports whether rr is a record miekg/dns unpacks but does
+// not pack back as it was. It stops reading a record wherever its RDATA
+// ends, keeping a length it read for data that never came, which it
+// packs all the same, and leaving names empty, which some records pack
+// with the wrong rdlength; and it takes an empty SVCB ALPN, which it
+// does not write, and a client subnet address with bits past its
+// prefix, which it masks off.
+func unpacksOnly(rr dns.RR) bool {
+	switch rr := rr.(type) {
+	case *dns.NSEC3:
+		return rr.SaltLength > 0 && rr.Salt == "" || rr.HashLength > 0 && rr.NextDomain == ""
+	case *dns.NSEC3PARAM:
+		return rr.SaltLength > 0 && rr.Salt == ""
+	case *dns.SOA:
+		return rr.Ns == "" || rr.Mbox == ""
+	case *dns.TSIG:
+		return rr.Algorithm == "" || rr.MACSize > 0 && rr.MAC == "" || rr.OtherLen > 0 && rr.OtherData == ""
+	case *dns.TKEY:
+		return rr.Algorithm == "" || rr.KeySize > 0 && rr.Key == "" || rr.OtherLen > 0 && rr.OtherData == ""
+	case *dns.HIP:
+		return rr.HitLength > 0 && rr.Hit == "" || rr.PublicKeyLength > 0 && rr.PublicKey == ""
+	case *dns.SVCB:
+		return emptyALPN(rr.Value)
+	case *dns.HTTPS:
+		return emptyALPN(rr.Value)
+	case *dns.OPT:
+		return slices.ContainsFunc(rr.Option, wideSubnet)
+	}
+	return false
+}
+
+// emptyALPN reports whether an SVCB ALPN in kv has an empty ID.
+func emptyALPN(kv []dns.SVCBKeyValue) bool {
+	for _, v := range kv {
+		if a, ok := v.(*dns.SVCBAlpn); ok && slices.Contains(a.Alpn, "") {
+			return true
+		}
+	}
+	return false
+}
+
+// wideSubnet reports whether o is a client subnet option whose address
+// has bits set past its source prefix.
+func wideSubnet(o dns.EDNS0) bool {
+	e, ok := o.(*dns.EDNS0_SUBNET)
+	if !ok {
+		return false
+	}
+	ip, bits := e.Address, 128
+	if e.Family == 1 {
+		ip, bits = ip.To4(), 32
+	}
+	if int(e.SourceNetmask) > bits {
+		return false
+	}
+	masked := ip.Mask(net.CIDRMask(int(e.SourceNetmask), bits))
+	return masked != nil && !ip.Equal(masked)
+}
+
+// A header is what both packages make of a message's header, and
+// records what they make of its records, section by section.
+type header struct {
+	ID                                   uint16
+	Response                             bool
+	Opcode                               int
+	Authoritative, Truncated             bool
+	RecursionDesired, RecursionAvailable bool
+	AuthenticData, CheckingDisabled      bool
+	RCode                                int
+	Questions                            []question
+	Answers, Authorities, Additionals    []record
+}
+
+type question struct {
+	Type, Class uint16
+}
+
+type record struct {
+	Type, Class uint16
+	TTL         uint32
+}
+
+// summary returns what miekg/dns makes of a message.
+func summary(m *dns.Msg) header {
+	h := header{
+		ID: m.Id, Response: m.Response, Opcode: m.Opcode,
+		Authoritative: m.Authoritative, Truncated: m.Truncated,
+		RecursionDesired: m.RecursionDesired, RecursionAvailable: m.RecursionAvailable,
+		AuthenticData: m.AuthenticatedData, CheckingDisabled: m.CheckingDisabled,
+		// Unpack folds the extended RCODE of an OPT record into Rcode.
+		RCode: m.Rcode & 0xf,
+	}
+	for _, q := range m.Question {
+		h.Questions = append(h.Questions, question{q.Qtype, q.Qclass})
+	}
+	for i, rs := range [][]dns.RR{m.Answer, m.Ns, m.Extra} {
+		var out []record
+		for _, rr := range rs {
+			rh := rr.Header()
+			out = append(out, record{rh.Rrtype, rh.Class, rh.Ttl})
+		}
+		*[]*[]record{&h.Answers, &h.Authorities, &h.Additionals}[i] = out
+	}
+	return h
+}
+
+// xsummary returns what dnsmessage makes of a message.
+func xsummary(m *dnsmessage.Message) header {
+	h := header{
+		ID: m.ID, Response: m.Response, Opcode: int(m.OpCode),
+		Authoritative: m.Authoritative, Truncated: m.Truncated,
+		RecursionDesired: m.RecursionDesired, RecursionAvailable: m.RecursionAvailable,
+		AuthenticData: m.AuthenticData, CheckingDisabled: m.CheckingDisabled,
+		RCode: int(m.RCode),
+	}
+	for _, q := range m.Questions {
+		h.Questions = append(h.Questions, question{uint16(q.Type), uint16(q.Class)})
+	}
+	for i, rs := range [][]dnsmessage.Resource{m.Answers, m.Authorities, m.Additionals} {
+		var out []record
+		for _, r := range rs {
+			out = append(out, record{uint16(r.Header.Type), uint16(r.Header.Class), r.Header.TTL})
+		}
+		*[]*[]record{&h.Answers, &h.Authorities, &h.Additionals}[i] = out
+	}
+	return h
+}
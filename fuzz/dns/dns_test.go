@@ -0,0 +1,30 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/dnssrc"
+)
+
+func FuzzMessage(f *testing.F) {
+	for _, src := range gen.Sample("dns/message", ".dns", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMessage(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMsg(f *testing.F) {
+	for _, src := range gen.Sample("dns/message", ".dns", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMsg(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
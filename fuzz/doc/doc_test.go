@@ -0,0 +1,39 @@
+package doc
+
+import (
+	"go/doc/comment"
+	"go/parser"
+	"go/token"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzDoc(f *testing.F) {
+	for _, src := range gen.Sample("go/doccomment", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzComment(f *testing.F) {
+	for _, src := range gen.Sample("go/doccomment", ".go", 4) {
+		file, err := parser.ParseFile(token.NewFileSet(), "seed.go", src, parser.ParseComments)
+		if err != nil {
+			continue
+		}
+		for _, g := range file.Comments {
+			f.Add(g.Text())
+		}
+	}
+	f.Fuzz(func(t *testing.T, text string) {
+		if err := Comment(new(comment.Parser), new(comment.Printer), text); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,379 @@
+// Package embed is a fuzz target for //go:embed: the patterns go/build
+// finds in a package, the files the go command resolves them to and the
+// compiler that builds them in. Check lays a package out, a Go file and a
+// tree of files named one to a line, in memory for go/build and on disk
+// for the go command.
+//
+// go/build must find the patterns where the file says they are, and find
+// the ones the go command, which reads files with its own copy of the
+// code, finds. The files the go command embeds for them must be those the
+// rules in the embed documentation give, as resolve applies them, and it
+// must reject the patterns those rules reject. Where it accepts them all,
+// the package must build, or fail to, without crashing the compiler.
+package embed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/build"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing/fstest"
+	"time"
+
+	"golang.org/x/mod/module"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds each run of the go command.
+var Timeout = 30 * time.Second
+
+// MaxFiles bounds the files in a tree, and so the work of laying it out
+// on disk.
+const MaxFiles = 64
+
+// goMod is the go.mod of the module the package is the root of.
+const goMod = "module example.com/p\n\ngo 1.22\n"
+
+// Check checks the //go:embed directives in src, a Go file in a package
+// directory holding the files named in tree. It returns a
+// *harness.Failure if the go command or the compiler crashes or hangs.
+// A tree with names no file system holds, or a file in the place of a
+// directory, is skipped.
+func Check(src []byte, tree string) error {
+	names, ok := treeNames(tree)
+	if !ok {
+		return nil
+	}
+	fsys := fstest.MapFS{
+		"go.mod":   {Data: []byte(goMod)},
+		"embed.go": {Data: src},
+	}
+	for _, n := range names {
+		fsys[n] = &fstest.MapFile{Data: []byte(n + "\n")}
+	}
+	pkg, err := importDir(fsys)
+	if err != nil {
+		return nil
+	}
+	if err := checkPositions(src, pkg); err != nil {
+		return err
+	}
+	files, resolveErr := resolve(fsys, pkg.EmbedPatterns)
+	return checkGo(fsys, pkg.EmbedPatterns, files, resolveErr)
+}
+
+// treeNames returns the file names in tree, if every one is a valid,
+// distinct path no other file lies under.
+func treeNames(tree string) ([]string, bool) {
+	var names []string
+	for n := range strings.Lines(tree) {
+		if n = strings.TrimSuffix(n, "\n"); n == "" || slices.Contains(names, n) {
+			continue
+		}
+		if !fs.ValidPath(n) || n == "." || n == "go.mod" || n == "embed.go" || strings.ContainsRune(n, 0) {
+			return nil, false
+		}
+		names = append(names, n)
+	}
+	if len(names) > MaxFiles {
+		return nil, false
+	}
+	for _, a := range names {
+		for _, b := range names {
+			if strings.HasPrefix(b, a+"/") {
+				return nil, false
+			}
+		}
+	}
+	return names, true
+}
+
+// pkgDir is where go/build is told the package lies in fsys.
+const pkgDir = "/pkg"
+
+// importDir reads the package in fsys with go/build.
+func importDir(fsys fs.FS) (*build.Package, error) {
+	rel := func(p string) string {
+		if p = strings.TrimPrefix(strings.TrimPrefix(p, pkgDir), "/"); p == "" {
+			return "."
+		}
+		return p
+	}
+	ctx := build.Default
+	ctx.GOROOT, ctx.GOPATH, ctx.CgoEnabled = "/goroot", "", false
+	ctx.JoinPath = path.Join
+	ctx.IsAbsPath = path.IsAbs
+	ctx.HasSubdir = func(string, string) (string, bool) { return "", false }
+	ctx.IsDir = func(p string) bool {
+		fi, err := fs.Stat(fsys, rel(p))
+		return err == nil && fi.IsDir()
+	}
+	ctx.ReadDir = func(dir string) ([]fs.FileInfo, error) {
+		entries, err := fs.ReadDir(fsys, rel(dir))
+		if err != nil {
+			return nil, err
+		}
+		infos := make([]fs.FileInfo, 0, len(entries))
+		for _, e := range entries {
+			fi, err := e.Info()
+			if err != nil {
+				return nil, err
+			}
+			infos = append(infos, fi)
+		}
+		return infos, nil
+	}
+	ctx.OpenFile = func(p string) (io.ReadCloser, error) { return fsys.Open(rel(p)) }
+	return ctx.ImportDir(pkgDir, 0)
+}
+
+// checkPositions checks that each pattern go/build found is, at each
+// position it gives, written on a //go:embed line, bare or quoted.
+func checkPositions(src []byte, pkg *build.Package) error {
+	lines := strings.Split(string(src), "\n")
+	for _, pat := range pkg.EmbedPatterns {
+		for _, pos := range pkg.EmbedPatternPos[pat] {
+			if pos.Line < 1 || pos.Line > len(lines) || pos.Column < 1 || pos.Column > len(lines[pos.Line-1]) {
+				return fmt.Errorf("pattern %q is at %v, outside the file", pat, pos)
+			}
+			line := lines[pos.Line-1]
+			at := line[pos.Column-1:]
+			if !strings.Contains(line[:pos.Column-1], "//go:embed") || !strings.HasPrefix(at, pat) && at[0] != '"' && at[0] != '`' {
+				return fmt.Errorf("pattern %q is at %v, where the line is %q", pat, pos, line)
+			}
+		}
+	}
+	return nil
+}
+
+// resolve returns the files the patterns embed from the package in fsys,
+// or the error for the first pattern that embeds none or one it must
+// not, following the rules of package embed as the go command applies
+// them. A pattern names files or directories with path.Match globs, and
+// a directory embeds the files in it, but not those in a nested module
+// or, without the all: prefix, those whose names begin with '.' or '_'.
+// No pattern may match a file whose name a module cannot hold.
+func resolve(fsys fs.FS, patterns []string) ([]string, error) {
+	have := map[string]bool{}
+	for _, pattern := range patterns {
+		list, err := resolvePattern(fsys, pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %s: %v", pattern, err)
+		}
+		for _, f := range list {
+			have[f] = true
+		}
+	}
+	files := make([]string, 0, len(have))
+	for f := range have {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func resolvePattern(fsys fs.FS, pattern string) ([]string, error) {
+	glob, all := strings.CutPrefix(pattern, "all:")
+	if _, err := path.Match(glob, ""); err != nil || glob == "." || !fs.ValidPath(glob) {
+		return nil, errors.New("invalid pattern syntax")
+	}
+	match, err := fs.Glob(fsys, glob)
+	if err != nil {
+		return nil, err
+	}
+	var list []string
+	for _, file := range match {
+		fi, err := fs.Stat(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+		what := "file"
+		if fi.IsDir() {
+			what = "directory"
+		}
+		for dir := file; dir != "."; dir = path.Dir(dir) {
+			if _, err := fs.Stat(fsys, dir+"/go.mod"); err == nil {
+				return nil, fmt.Errorf("cannot embed %s %s: in different module", what, file)
+			}
+			if elem := path.Base(dir); badName(elem) {
+				if dir == file {
+					return nil, fmt.Errorf("cannot embed %s %s: invalid name %s", what, file, elem)
+				}
+				return nil, fmt.Errorf("cannot embed %s %s: in invalid directory %s", what, file, elem)
+			}
+		}
+		if !fi.IsDir() {
+			list = append(list, file)
+			continue
+		}
+		count := 0
+		err = fs.WalkDir(fsys, file, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if p != file && (badName(name) || (name[0] == '.' || name[0] == '_') && !all) {
+				switch {
+				case d.IsDir():
+					return fs.SkipDir
+				case name[0] == '.' || name[0] == '_':
+					return nil
+				}
+				return fmt.Errorf("cannot embed file %s: invalid name %s", p, name)
+			}
+			if d.IsDir() {
+				if _, err := fs.Stat(fsys, p+"/go.mod"); err == nil {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			count++
+			list = append(list, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("cannot embed directory %s: contains no embeddable files", file)
+		}
+	}
+	if len(list) == 0 {
+		return nil, errors.New("no matching files found")
+	}
+	return list, nil
+}
+
+// badName reports whether a file or directory named name cannot be in a
+// module, and so cannot be embedded.
+func badName(name string) bool {
+	switch name {
+	case "", ".bzr", ".hg", ".git", ".svn":
+		return true
+	}
+	return module.CheckFilePath(name) != nil
+}
+
+// toolchain reports whether there is a go command on $PATH. On OSS-Fuzz
+// runners there is none, and Check stops at go/build and resolve.
+var toolchain = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("go")
+	return err == nil
+})
+
+// A listed package is what go list reports of the package.
+type listed struct {
+	EmbedPatterns []string
+	EmbedFiles    []string
+	Error         *struct{ Err string }
+}
+
+// checkGo lays fsys out on disk and checks that go list finds patterns
+// and embeds files, or rejects a pattern, as go/build and resolve do,
+// and that if it embeds the files the package builds without crashing.
+func checkGo(fsys fstest.MapFS, patterns, files []string, resolveErr error) error {
+	if !toolchain() {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "fuzzembed")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	for name, f := range fsys {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o777); err != nil {
+			return nil
+		}
+		if err := os.WriteFile(file, f.Data, 0o666); err != nil {
+			return nil
+		}
+	}
+
+	out, err := goCommand(dir, "list", "-e", "-json=EmbedPatterns,EmbedFiles,Error", ".")
+	if err != nil {
+		return err
+	}
+	var p listed
+	if err := json.Unmarshal(out, &p); err != nil {
+		return fmt.Errorf("go list: %v\n%s", err, out)
+	}
+	listErr := ""
+	if p.Error != nil {
+		listErr = p.Error.Err
+		if !strings.HasPrefix(listErr, "pattern ") {
+			return nil // not an embed error
+		}
+	}
+	if !slices.EqualFunc(p.EmbedPatterns, patterns, func(a, b string) bool { return a == asJSON(b) }) {
+		return fmt.Errorf("go/build finds the patterns %q, but go list %q", patterns, p.EmbedPatterns)
+	}
+	switch {
+	case listErr != "" && resolveErr == nil:
+		return fmt.Errorf("the patterns %q embed %q, but go list fails: %s", patterns, files, listErr)
+	case listErr == "" && resolveErr != nil:
+		return fmt.Errorf("the patterns %q fail to embed (%v), but go list embeds %q", patterns, resolveErr, p.EmbedFiles)
+	case listErr != "":
+		return nil
+	case !slices.Equal(p.EmbedFiles, files):
+		return fmt.Errorf("the patterns %q embed %q, but go list embeds %q", patterns, files, p.EmbedFiles)
+	}
+	_, err = goCommand(dir, "build", ".")
+	return err
+}
+
+// asJSON returns s as it comes through JSON, with each byte of invalid
+// UTF-8 in it made U+FFFD.
+func asJSON(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// goCommand runs the go command in dir, isolated from the network and
+// the environment's modules, and returns its standard output. It returns
+// a *harness.Failure if the command crashes or hangs; the command
+// failing is not an error.
+func goCommand(dir string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=-mod=mod", "GOPROXY=off", "GOTOOLCHAIN=local", "GOWORK=off", "GO111MODULE=on", "CGO_ENABLED=0")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, &harness.Failure{Kind: harness.Hang, After: Timeout}
+	}
+	if isCrash(stderr.Bytes()) {
+		return nil, &harness.Failure{Kind: harness.Panic, Value: fmt.Sprintf("go %s: %v\n%s", strings.Join(args, " "), err, stderr.Bytes())}
+	}
+	return stdout.Bytes(), nil
+}
+
+// isCrash reports whether out, the output of the go command, shows it or
+// the compiler crashing rather than reporting errors in the package.
+func isCrash(out []byte) bool {
+	for l := range strings.Lines(string(out)) {
+		if strings.HasPrefix(l, "panic: ") || strings.HasPrefix(l, "fatal error: ") || strings.Contains(l, "internal compiler error") {
+			return true
+		}
+	}
+	return false
+}
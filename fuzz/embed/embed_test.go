@@ -0,0 +1,30 @@
+package embed
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzResolve(f *testing.F) {
+	g := gen.Lookup("go/embed")
+	for i := range 16 {
+		var src []byte
+		var tree []string
+		for _, file := range g.Generate(i) {
+			if file.Name == "embed.go" {
+				src = file.Data
+			} else {
+				tree = append(tree, file.Name)
+			}
+		}
+		f.Add(src, strings.Join(tree, "\n"))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, tree string) {
+		if err := Check(src, tree); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
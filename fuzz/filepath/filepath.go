@@ -0,0 +1,289 @@
+// Package filepath is a fuzz target for the lexical path functions of
+// path/filepath, fs.ValidPath and the resolution of paths through
+// symlinks. A server that cleans, joins or relativizes a path from a
+// request and then opens it is safe only if the functions it checks the
+// path with and the file system it opens it on agree on where it leads.
+//
+// CheckPaths reads paths, one to a line. Each must clean to a path that
+// is clean, keeps its rootedness and holds no dot segments but a leading
+// run of "..", as path.Clean cleans it; split into a directory and a file
+// that make it up again; be local exactly when it cannot climb out of the
+// directory it is joined to; and be valid to fs.ValidPath exactly when it
+// is a clean, unrooted, local slash-separated path in UTF-8. Each pair
+// must give a relative path with Rel that joins to the target again. And
+// each path, taken below the root of a small tree of files and symlinks,
+// must resolve with EvalSymlinks to the file the kernel opens for it, and
+// with an os.Root, if at all, to a file in the tree.
+//
+// The checks are of filepath as it is on Unix, where the Windows forms of
+// "path/windows" are names with backslashes and colons in them; run on
+// Windows, only the checks that hold there are made.
+package filepath
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// MaxPaths bounds the paths read from one input, as there are checks of
+// every pair of them.
+const MaxPaths = 8
+
+// unix is whether filepath is the slash-separated one of Unix.
+const unix = filepath.Separator == '/'
+
+// CheckPaths checks the paths in data, one to a line.
+func CheckPaths(data []byte) error {
+	paths := strings.Split(string(data), "\n")
+	if len(paths) > MaxPaths {
+		paths = paths[:MaxPaths]
+	}
+	for _, p := range paths {
+		for _, check := range []func(string) error{checkClean, checkSplit, checkLocal, checkLinks} {
+			if err := check(p); err != nil {
+				return err
+			}
+		}
+		if unix {
+			if err := checkValid(p); err != nil {
+				return err
+			}
+		}
+	}
+	for _, base := range paths {
+		for _, targ := range paths {
+			if err := checkRel(base, targ); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkClean checks that Clean cleans p to a clean path with the same
+// root and no dot segments but leading ".." ones in a relative path, and
+// that Join cleans as Clean does.
+func checkClean(p string) error {
+	c := filepath.Clean(p)
+	if again := filepath.Clean(c); again != c {
+		return fmt.Errorf("%q cleans to %q, which cleans to %q", p, c, again)
+	}
+	if filepath.IsAbs(c) != filepath.IsAbs(p) {
+		return fmt.Errorf("%q cleans to %q, but IsAbs is %v and then %v", p, c, filepath.IsAbs(p), filepath.IsAbs(c))
+	}
+	if p != "" && filepath.Join(p) != c {
+		return fmt.Errorf("%q cleans to %q, but joins alone as %q", p, c, filepath.Join(p))
+	}
+	rest := c[len(filepath.VolumeName(c)):]
+	rooted := strings.HasPrefix(rest, string(filepath.Separator))
+	if rest = strings.TrimPrefix(rest, string(filepath.Separator)); rest == "" || c == "." {
+		return nil
+	}
+	elems := strings.Split(rest, string(filepath.Separator))
+	for i, e := range elems {
+		bad := e == "" || e == "."
+		if e == ".." {
+			bad = rooted || i > 0 && elems[i-1] != ".."
+		}
+		if bad {
+			return fmt.Errorf("%q cleans to %q, which has the element %q", p, c, e)
+		}
+	}
+	if unix {
+		if pc := path.Clean(p); pc != c {
+			return fmt.Errorf("%q cleans to %q, but path.Clean cleans it to %q", p, c, pc)
+		}
+		if j := filepath.Join("base", p); !rooted && j != filepath.Clean("base/"+p) {
+			return fmt.Errorf("%q joins to base as %q, not %q", p, j, filepath.Clean("base/"+p))
+		}
+	}
+	return nil
+}
+
+// checkSplit checks that Split splits p into a directory and a file that
+// make it up, and that Base, Dir and Ext agree with Split.
+func checkSplit(p string) error {
+	dir, file := filepath.Split(p)
+	if dir+file != p || strings.ContainsRune(file, filepath.Separator) {
+		return fmt.Errorf("%q splits into %q and %q", p, dir, file)
+	}
+	base := filepath.Base(p)
+	if file != "" && base != file {
+		return fmt.Errorf("%q splits into %q and %q, but its base is %q", p, dir, file, base)
+	}
+	if base != string(filepath.Separator) && strings.ContainsRune(base, filepath.Separator) {
+		return fmt.Errorf("the base of %q is %q", p, base)
+	}
+	if unix {
+		if d := filepath.Dir(p); d != filepath.Clean(dir) {
+			return fmt.Errorf("%q splits into %q and %q, but its directory is %q", p, dir, file, d)
+		}
+	}
+	ext := filepath.Ext(p)
+	if !strings.HasSuffix(p, ext) || ext != "" && ext[0] != '.' || strings.ContainsRune(ext, filepath.Separator) {
+		return fmt.Errorf("the extension of %q is %q", p, ext)
+	}
+	return nil
+}
+
+// checkLocal checks that p, if IsLocal holds for it, joins to a base as a
+// path under it, and that IsLocal holds for every path that does on Unix.
+// It checks too that Localize takes only valid paths, to local ones.
+func checkLocal(p string) error {
+	local := filepath.IsLocal(p)
+	if local {
+		j := filepath.Join("base", p)
+		if j != "base" && !strings.HasPrefix(j, "base"+string(filepath.Separator)) {
+			return fmt.Errorf("%q is local, but joins to base as %q", p, j)
+		}
+	}
+	c := filepath.Clean(p)
+	if want := p != "" && !filepath.IsAbs(p) && c != ".." && !strings.HasPrefix(c, "../"); unix && local != want {
+		return fmt.Errorf("IsLocal(%q) = %v, but it cleans to %q", p, local, c)
+	}
+	l, err := filepath.Localize(p)
+	if err != nil {
+		return nil
+	}
+	if !fs.ValidPath(p) || !filepath.IsLocal(l) {
+		return fmt.Errorf("%q localizes as %q, but is not a valid path or is not local", p, l)
+	}
+	if unix && l != p {
+		return fmt.Errorf("%q localizes as %q", p, l)
+	}
+	return nil
+}
+
+// checkValid checks that fs.ValidPath holds for p exactly when p is valid
+// UTF-8 and ".", or clean, unrooted and with no leading "..", and that a
+// valid path is local.
+func checkValid(p string) error {
+	valid := fs.ValidPath(p)
+	want := utf8.ValidString(p) && (p == "." || p != "" && path.Clean(p) == p && p[0] != '/' && p != ".." && !strings.HasPrefix(p, "../"))
+	if valid != want {
+		return fmt.Errorf("ValidPath(%q) = %v", p, valid)
+	}
+	if valid && !filepath.IsLocal(filepath.FromSlash(p)) {
+		return fmt.Errorf("%q is a valid path, but not local", p)
+	}
+	return nil
+}
+
+// checkRel checks that the path from base to targ that Rel gives, if it
+// gives one, is relative and joins to base as targ, cleaned, and that
+// Rel gives the path from a path to itself as ".".
+func checkRel(base, targ string) error {
+	r, err := filepath.Rel(base, targ)
+	if err == nil {
+		if filepath.IsAbs(r) {
+			return fmt.Errorf("Rel(%q, %q) = %q, which is absolute", base, targ, r)
+		}
+		if j := filepath.Join(base, r); j != filepath.Clean(targ) {
+			return fmt.Errorf("Rel(%q, %q) = %q, which joins to the base as %q", base, targ, r, j)
+		}
+	}
+	if base == targ {
+		if r, err := filepath.Rel(targ, targ); err != nil || r != "." {
+			return fmt.Errorf("Rel(%q, %q) = %q (%v)", targ, targ, r, err)
+		}
+	}
+	return nil
+}
+
+// A tree is a directory of files and symlinks to resolve paths in, with a
+// file next to it that links climb out of the tree to.
+type tree struct {
+	dir  string   // the directory, with no symlinks in its path
+	root *os.Root // the directory as an os.Root
+}
+
+// links are the symlinks in the tree, by name and target.
+var links = [][2]string{
+	{"up", ".."}, {"abs", "/"}, {"self", "."}, {"loop", "loop"}, {"down", "a/b"},
+	{"back", "a/b/../.."}, {"dangling", "nowhere"}, {"a/b/up2", "../.."}, {"a/out", "../../outside"},
+}
+
+// theTree returns the tree, which is made once, in a temporary directory
+// that lasts as long as the process does.
+var theTree = sync.OnceValues(func() (*tree, error) {
+	tmp, err := os.MkdirTemp("", "fuzzfilepath")
+	if err != nil {
+		return nil, err
+	}
+	if tmp, err = filepath.EvalSymlinks(tmp); err != nil {
+		return nil, err
+	}
+	dir := filepath.Join(tmp, "root")
+	if err := os.MkdirAll(filepath.Join(dir, "a", "b"), 0o777); err != nil {
+		return nil, err
+	}
+	for _, f := range []string{filepath.Join(tmp, "outside"), filepath.Join(dir, "f"), filepath.Join(dir, "a", "b", "f")} {
+		if err := os.WriteFile(f, []byte("f"), 0o666); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range links {
+		if err := os.Symlink(filepath.FromSlash(l[1]), filepath.Join(dir, filepath.FromSlash(l[0]))); err != nil {
+			return nil, err
+		}
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &tree{dir: dir, root: root}, nil
+})
+
+// maxLinkPath bounds the length of the paths resolved in the tree, well
+// under the PATH_MAX of the kernel.
+const maxLinkPath = 2048
+
+// checkLinks checks that p, taken below the tree's directory, resolves
+// with EvalSymlinks to a clean path with no symlinks in it, and to the
+// file that the kernel opens, if it opens one; and that an os.Root of
+// the directory opens, if anything, that file, and only if it is in the
+// tree. Without a tree, as where there is no temporary directory to make
+// it in, it does nothing.
+func checkLinks(p string) error {
+	t, err := theTree()
+	if err != nil || len(p) > maxLinkPath || !unix {
+		return nil
+	}
+	full := t.dir + "/" + p
+	resolved, err := filepath.EvalSymlinks(full)
+	fi, statErr := os.Stat(full)
+	if statErr == nil && err != nil {
+		return fmt.Errorf("%q opens, but does not resolve: %v", p, err)
+	}
+	if err == nil {
+		if !filepath.IsAbs(resolved) || filepath.Clean(resolved) != resolved {
+			return fmt.Errorf("%q resolves to %q", p, resolved)
+		}
+		if again, err := filepath.EvalSymlinks(resolved); err != nil || again != resolved {
+			return fmt.Errorf("%q resolves to %q, which resolves to %q (%v)", p, resolved, again, err)
+		}
+		if statErr == nil {
+			rfi, err := os.Stat(resolved)
+			if err != nil || !os.SameFile(fi, rfi) {
+				return fmt.Errorf("%q resolves to %q, which is not the file it opens (%v)", p, resolved, err)
+			}
+		}
+	}
+	rootFi, rootErr := t.root.Stat(p)
+	if rootErr != nil {
+		return nil
+	}
+	if statErr != nil || !os.SameFile(fi, rootFi) {
+		return fmt.Errorf("%q opens in the root, but not as the same file outside it (%v)", p, statErr)
+	}
+	if err == nil && resolved != t.dir && !strings.HasPrefix(resolved, t.dir+"/") {
+		return fmt.Errorf("%q opens in the root, but resolves outside it to %q", p, resolved)
+	}
+	return nil
+}
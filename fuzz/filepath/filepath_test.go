@@ -0,0 +1,19 @@
+package filepath
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/pathsrc"
+)
+
+func FuzzPaths(f *testing.F) {
+	for _, src := range gen.Sample("path/*", ".paths", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPaths(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
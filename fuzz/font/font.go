@@ -0,0 +1,404 @@
+// Package font is a fuzz target for golang.org/x/image/font/sfnt.
+// CheckSFNT and CheckCollection parse a font, or a collection of them,
+// as a careful renderer would: they count first how much work loading
+// every glyph is, the points and contours of each simple glyph and of
+// those each compound glyph is built of, and the numbers and operators
+// of each charstring and the subroutines it calls, and parse only fonts
+// with no more than a fixed amount of it, so that a few hundred bytes
+// that stand for millions of segments are refused before they are
+// loaded. Parsing a font and loading its glyphs must take time and
+// memory within a budget linear in its size and that work, past which
+// it is reported as a blowup. A font that parses from a []byte must
+// parse from an io.ReaderAt too, to the same glyphs, advances, glyph
+// names, kerning, character map, names and metrics, and each glyph must
+// load the same with a Buffer and without one. Its glyph count and
+// units per em must be those of its maxp and head tables, its advances
+// those of its hmtx table, and its character map that of the cmap
+// subtable sfnt chooses; the bounds of a glyph must be those of its
+// segments, and a TrueType glyph must start with a move. A font that
+// starts a file must write the file back up to the end of its last
+// table.
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what parsing a font and loading its glyphs may cost: the
+// harness.Budget of the file's bytes, plus PerSegment for each unit of
+// work its glyphs are. Segments is how much work the glyphs of a file
+// may be for it to be parsed at all.
+type Budget struct {
+	harness.Budget
+	PerSegment harness.Cost
+	Segments   int
+}
+
+// For returns the budget for a file of n bytes whose glyphs are
+// segments of work.
+func (b Budget) For(n, segments int) harness.Cost {
+	return b.Budget.For(n).Plus(segments, b.PerSegment)
+}
+
+// DefaultBudget loads fonts of up to a quarter of a million segments
+// of work, and allows for the segments of a glyph of all of them, which
+// a Buffer grows a quarter at a time to hold, and for moving those of a
+// component once for each glyph it is nested in.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	PerSegment: harness.Cost{Time: time.Microsecond, Memory: 512},
+	Segments:   1 << 18,
+}
+
+// loads is how many times checkFont loads each glyph.
+const loads = 4
+
+// CheckSFNT parses the font in data within b, and checks what it
+// parses to. Errors parsing are expected and ignored.
+func CheckSFNT(data []byte, b Budget) error {
+	r, ok := readFont(data, 0)
+	if !ok {
+		return nil
+	}
+	if parse, _ := r.gposFaults(); parse {
+		// Known: sfnt takes a feature index, or a lookup index, equal
+		// to the count of them as one in the list, and indexes past its
+		// end.
+		return nil
+	}
+	work, ok := r.work(b.Segments)
+	if !ok {
+		return nil
+	}
+
+	limit := b.For(len(data), work)
+	var f *sfnt.Font
+	var perr error
+	what := fmt.Sprintf("parsing %d bytes and loading glyphs of %d segments of work", len(data), work)
+	err := harness.Measure(what, limit, func() {
+		f, perr = sfnt.Parse(data)
+		if perr == nil {
+			loadAll(f)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if perr != nil {
+		return nil
+	}
+	g, err := sfnt.ParseReaderAt(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("the font parses from a []byte, but not from an io.ReaderAt: %v", err)
+	}
+	return harness.Run(harness.HangFactor*loads*limit.Time, func() error {
+		return checkFont(f, g, r, true)
+	})
+}
+
+// CheckCollection parses the font collection in data within b, and
+// checks each font in it as CheckSFNT does. A file of a single font is
+// a collection of one. Errors parsing are expected and ignored.
+func CheckCollection(data []byte, b Budget) error {
+	offsets, ok := fontOffsets(data)
+	if !ok {
+		return nil
+	}
+	// A font whose outlines do not read is not parsed, nor counted.
+	readers := make([]*reader, len(offsets))
+	work := 0
+	for i, off := range offsets {
+		r, ok := readFont(data, off)
+		if !ok {
+			continue
+		}
+		if parse, _ := r.gposFaults(); parse {
+			// Known: as in CheckSFNT.
+			return nil
+		}
+		n, ok := r.work(b.Segments - work)
+		if !ok {
+			if n > b.Segments-work {
+				return nil
+			}
+			continue
+		}
+		readers[i] = r
+		work += n
+	}
+
+	limit := b.For(len(data), work)
+	var c *sfnt.Collection
+	var perr error
+	what := fmt.Sprintf("parsing %d bytes and loading glyphs of %d segments of work in %d fonts", len(data), work, len(offsets))
+	err := harness.Measure(what, limit, func() {
+		c, perr = sfnt.ParseCollection(data)
+		if perr != nil {
+			return
+		}
+		for i, r := range readers {
+			if r == nil || i >= c.NumFonts() {
+				continue
+			}
+			if f, err := c.Font(i); err == nil {
+				loadAll(f)
+			}
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if perr != nil {
+		return nil
+	}
+	cg, err := sfnt.ParseCollectionReaderAt(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("the collection parses from a []byte, but not from an io.ReaderAt: %v", err)
+	}
+	if c.NumFonts() != len(offsets) || cg.NumFonts() != len(offsets) {
+		return fmt.Errorf("the header gives %d fonts, but the collection has %d, and %d from an io.ReaderAt", len(offsets), c.NumFonts(), cg.NumFonts())
+	}
+	return harness.Run(harness.HangFactor*loads*limit.Time, func() error {
+		for i, r := range readers {
+			if r == nil {
+				continue
+			}
+			f, ferr := c.Font(i)
+			g, gerr := cg.Font(i)
+			if !follows(ferr, gerr) {
+				return fmt.Errorf("font %d parses with %v from a []byte, but with %v from an io.ReaderAt", i, ferr, gerr)
+			}
+			if ferr != nil {
+				continue
+			}
+			if err := checkFont(f, g, r, offsets[i] == 0); err != nil {
+				return fmt.Errorf("font %d: %v", i, err)
+			}
+		}
+		return nil
+	})
+}
+
+// fontOffsets returns where the directory of each font in data is: at
+// the offsets a ttcf header gives, or at the start of a file of one
+// font. It returns false for a resource fork, whose fonts are found by
+// a map of resources this does not read, and for a header that gives
+// more fonts than sfnt reads, or none.
+func fontOffsets(data []byte) ([]int, bool) {
+	if len(data) < 12 || string(data[:4]) != "ttcf" {
+		return []int{0}, len(data) < 4 || binary.BigEndian.Uint32(data) != 0x100
+	}
+	n := int(binary.BigEndian.Uint32(data[8:]))
+	if n < 1 || n > 256 || len(data) < 12+4*n {
+		return nil, false
+	}
+	offsets := make([]int, n)
+	for i := range offsets {
+		offsets[i] = int(binary.BigEndian.Uint32(data[12+4*i:]))
+	}
+	return offsets, true
+}
+
+// ppem is the size checkFont loads the glyphs of f at: a 26.6 pixel to
+// a unit, so that scaling an advance gives it back as it is.
+func ppem(f *sfnt.Font) fixed.Int26_6 {
+	return fixed.Int26_6(f.UnitsPerEm())
+}
+
+// loadAll loads every glyph of f.
+func loadAll(f *sfnt.Font) {
+	var b sfnt.Buffer
+	for x := range f.NumGlyphs() {
+		f.LoadGlyph(&b, sfnt.GlyphIndex(x), ppem(f), nil)
+	}
+}
+
+// agree reports whether two errors are both nil, both
+// sfnt.ErrNotFound, or both some other error.
+func agree(a, b error) bool {
+	return (a == nil) == (b == nil) && errors.Is(a, sfnt.ErrNotFound) == errors.Is(b, sfnt.ErrNotFound)
+}
+
+// follows reports whether gerr, what reading from an io.ReaderAt gave,
+// follows err, what reading the same from a []byte gave: whether they
+// agree, unless reading from the []byte failed.
+//
+// Known: sfnt checks that the tables of a font end within a []byte,
+// but not an io.ReaderAt, and fails to view no bytes past the end of a
+// []byte, but reads none from an io.ReaderAt, so that what fails from
+// one may not from the other.
+func follows(err, gerr error) bool {
+	return err != nil || agree(err, gerr)
+}
+
+// checkFont checks f, parsed from a []byte, against g, parsed from an
+// io.ReaderAt, and against what r reads of the same font. If whole, the
+// font starts the file, and writes it back.
+func checkFont(f, g *sfnt.Font, r *reader, whole bool) error {
+	n := f.NumGlyphs()
+	if n != r.numGlyphs || g.NumGlyphs() != n {
+		return fmt.Errorf("maxp gives %d glyphs, but the font has %d, and %d from an io.ReaderAt", r.numGlyphs, n, g.NumGlyphs())
+	}
+	if int(f.UnitsPerEm()) != r.unitsPerEm || g.UnitsPerEm() != f.UnitsPerEm() {
+		return fmt.Errorf("head gives %d units per em, but the font has %d, and %d from an io.ReaderAt", r.unitsPerEm, f.UnitsPerEm(), g.UnitsPerEm())
+	}
+	size := ppem(f)
+	var locs []int
+	if !r.cff {
+		locs, _ = r.locations()
+	}
+	var fb, gb sfnt.Buffer
+	for x := range n {
+		i := sfnt.GlyphIndex(x)
+		segs, err := f.LoadGlyph(&fb, i, size, nil)
+		segs = slices.Clone(segs)
+		alone, aerr := f.LoadGlyph(nil, i, size, nil)
+		other, oerr := g.LoadGlyph(&gb, i, size, nil)
+		if !agree(err, aerr) || !follows(err, oerr) {
+			return fmt.Errorf("glyph %d loads with %v, with %v with no Buffer, and with %v from an io.ReaderAt", x, err, aerr, oerr)
+		}
+		if err == nil && (!slices.Equal(segs, alone) || !slices.Equal(segs, other)) {
+			return fmt.Errorf("glyph %d loads to %d segments, to %d with no Buffer, and to %d from an io.ReaderAt, which differ", x, len(segs), len(alone), len(other))
+		}
+		if err == nil && locs != nil && len(segs) > 0 && segs[0].Op != sfnt.SegmentOpMoveTo {
+			// Known: sfnt closes a contour of a single point off the
+			// curve with a curve to the origin, without moving to the
+			// point first.
+			if off, _ := r.offStart(locs, x, 0); !off {
+				return fmt.Errorf("glyph %d starts with %v, not a move", x, segs[0])
+			}
+		}
+
+		adv, aderr := f.GlyphAdvance(&fb, i, size, xfont.HintingNone)
+		if aderr != nil {
+			return fmt.Errorf("glyph %d loads, but its advance does not: %v", x, aderr)
+		}
+		if want, ok := r.advance(x); ok && want*r.unitsPerEm < 1<<31 && adv != fixed.Int26_6(want) {
+			return fmt.Errorf("hmtx gives glyph %d an advance of %d, but it has %d", x, want, adv)
+		}
+		bounds, badv, berr := f.GlyphBounds(&fb, i, size, xfont.HintingNone)
+		if !agree(berr, err) {
+			return fmt.Errorf("glyph %d loads with %v, but its bounds with %v", x, err, berr)
+		}
+		if berr == nil && (bounds != sfnt.Segments(segs).Bounds() || badv != adv) {
+			return fmt.Errorf("glyph %d has bounds %v and advance %v, but its segments are in %v, and its advance is %v", x, bounds, badv, sfnt.Segments(segs).Bounds(), adv)
+		}
+
+		fname, ferr := f.GlyphName(&fb, i)
+		gname, gerr := g.GlyphName(&gb, i)
+		if !follows(ferr, gerr) || ferr == nil && fname != gname {
+			return fmt.Errorf("glyph %d is named %q (%v), but %q (%v) from an io.ReaderAt", x, fname, ferr, gname, gerr)
+		}
+	}
+
+	if err := checkKern(f, g, r); err != nil {
+		return err
+	}
+	if err := checkCmap(f, g, r); err != nil {
+		return err
+	}
+	for id := range sfnt.NameID(26) {
+		fname, ferr := f.Name(&fb, id)
+		alone, aerr := f.Name(nil, id)
+		gname, gerr := g.Name(&gb, id)
+		if !agree(ferr, aerr) || !follows(ferr, gerr) || fname != alone || ferr == nil && fname != gname {
+			return fmt.Errorf("name %d is %q (%v), %q (%v) with no Buffer, and %q (%v) from an io.ReaderAt", id, fname, ferr, alone, aerr, gname, gerr)
+		}
+	}
+	fm, ferr := f.Metrics(&fb, size, xfont.HintingNone)
+	gm, gerr := g.Metrics(&gb, size, xfont.HintingNone)
+	if !follows(ferr, gerr) || ferr == nil && fm != gm {
+		return fmt.Errorf("the font has metrics %+v (%v), but %+v (%v) from an io.ReaderAt", fm, ferr, gm, gerr)
+	}
+
+	if !whole {
+		return nil
+	}
+	want := r.data[:r.end]
+	for _, h := range []*sfnt.Font{f, g} {
+		var buf bytes.Buffer
+		if _, err := h.WriteSourceTo(nil, &buf); err != nil {
+			return fmt.Errorf("the font parses, but does not write back: %v", err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			return fmt.Errorf("the font's tables end at %d, but it writes back %d bytes, which differ", len(want), buf.Len())
+		}
+	}
+	return nil
+}
+
+// kernGlyphs is how many glyphs from the first checkKern kerns each
+// with each other.
+const kernGlyphs = 32
+
+// checkKern checks that kerning the first glyphs of f with each other
+// gives the same with a Buffer and without one, and from g.
+func checkKern(f, g *sfnt.Font, r *reader) error {
+	if _, kern := r.gposFaults(); kern {
+		// Known: sfnt reads the pairs of a glyph, and the value for a
+		// pair of classes, without checking that they are within the
+		// part of a pair adjustment subtable it keeps.
+		return nil
+	}
+	var fb, gb sfnt.Buffer
+	n := min(f.NumGlyphs(), kernGlyphs)
+	for x := range n {
+		for y := range n {
+			a, b := sfnt.GlyphIndex(x), sfnt.GlyphIndex(y)
+			k, err := f.Kern(&fb, a, b, ppem(f), xfont.HintingNone)
+			alone, aerr := f.Kern(nil, a, b, ppem(f), xfont.HintingNone)
+			other, oerr := g.Kern(&gb, a, b, ppem(f), xfont.HintingNone)
+			if !agree(err, aerr) || !follows(err, oerr) || k != alone || err == nil && k != other {
+				return fmt.Errorf("glyphs %d and %d kern by %v (%v), by %v (%v) with no Buffer, and by %v (%v) from an io.ReaderAt", x, y, k, err, alone, aerr, other, oerr)
+			}
+		}
+	}
+	return nil
+}
+
+// maxRunes is how many characters checkCmap asks about past ASCII.
+const maxRunes = 1024
+
+// checkCmap checks that the characters of ASCII, those either side of
+// the segments or groups of the subtable sfnt reads, and a few past the
+// Basic Multilingual Plane and past Unicode, map to the same glyphs in
+// f and g, and to those the subtable gives.
+func checkCmap(f, g *sfnt.Font, r *reader) error {
+	want, runes := r.cmap()
+	if len(runes) > maxRunes {
+		runes = runes[:maxRunes]
+	}
+	runes = append(runes, -1, 0xfffe, 0xffff, 0x10000, 0x1f600, 0x10ffff, 0x110000)
+	for c := range rune(0x80) {
+		runes = append(runes, c)
+	}
+	var fb, gb sfnt.Buffer
+	for _, c := range runes {
+		x, err := f.GlyphIndex(&fb, c)
+		y, gerr := g.GlyphIndex(&gb, c)
+		if !follows(err, gerr) || err == nil && x != y {
+			return fmt.Errorf("%U maps to glyph %d (%v), but to %d (%v) from an io.ReaderAt", c, x, err, y, gerr)
+		}
+		if want == nil {
+			continue
+		}
+		if w, ok := want(c); ok != (err == nil) || ok && uint16(x) != w {
+			return fmt.Errorf("%U maps to glyph %d (%v), but the cmap gives %d (ok %v)", c, x, err, w, ok)
+		}
+	}
+	return nil
+}
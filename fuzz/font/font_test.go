@@ -0,0 +1,33 @@
+package font
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/fontsrc"
+)
+
+func FuzzSFNT(f *testing.F) {
+	for _, src := range gen.Sample("font/ttf", ".ttf", 64) {
+		f.Add(src)
+	}
+	for _, src := range gen.Sample("font/otf", ".otf", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckSFNT(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzCollection(f *testing.F) {
+	for _, src := range gen.Sample("font/ttc", ".ttc", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckCollection(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,1162 @@
+package font
+
+import "encoding/binary"
+
+// A reader reads a font's tables as sfnt does, far enough to know how
+// much work loading its glyphs is, and what its maxp, head, hmtx and
+// cmap tables give. Every offset it reads is one into the whole file.
+type reader struct {
+	data   []byte
+	cff    bool // the font's outlines are in a CFF table
+	tables map[string]entry
+	// end is the end of the table that ends furthest into the file.
+	end                   int
+	numGlyphs, unitsPerEm int
+}
+
+// An entry is where a table is in the file.
+type entry struct{ off, n int }
+
+// readFont reads the directory of the font at offset in data, and
+// reports whether it reads.
+func readFont(data []byte, offset int) (*reader, bool) {
+	r := &reader{data: data, tables: map[string]entry{}}
+	version, ok := r.u32(offset)
+	if !ok {
+		return nil, false
+	}
+	switch version {
+	case 0x4f54544f: // OTTO
+		r.cff = true
+	case 0x00010000, 0x74727565: // true
+	default:
+		return nil, false
+	}
+	n, ok := r.u16(offset + 4)
+	if !ok || offset+12+16*n > len(data) {
+		return nil, false
+	}
+	for i := range n {
+		rec := data[offset+12+16*i:]
+		off, size := int(binary.BigEndian.Uint32(rec[8:])), int(binary.BigEndian.Uint32(rec[12:]))
+		r.tables[string(rec[:4])] = entry{off, size}
+		r.end = max(r.end, off+size)
+	}
+	if maxp, ok := r.tables["maxp"]; ok {
+		r.numGlyphs, _ = r.u16(maxp.off + 4)
+	}
+	if head, ok := r.tables["head"]; ok {
+		r.unitsPerEm, _ = r.u16(head.off + 18)
+	}
+	return r, true
+}
+
+func (r *reader) u16(i int) (int, bool) {
+	if i < 0 || i+2 > len(r.data) {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint16(r.data[i:])), true
+}
+
+func (r *reader) u32(i int) (int, bool) {
+	if i < 0 || i+4 > len(r.data) {
+		return 0, false
+	}
+	return int(binary.BigEndian.Uint32(r.data[i:])), true
+}
+
+// A counter counts work, up to a limit.
+type counter struct{ n, limit int }
+
+// add counts n more, and reports whether the count is still within
+// the limit.
+func (w *counter) add(n int) bool {
+	w.n += n
+	return w.n <= w.limit
+}
+
+// work counts the segments loading every glyph of the font may give,
+// and the lines of charstrings and components it runs through to do
+// so, each glyph once, and the glyphs for 'x' and 'H' a font with no
+// OS/2 table of version 2 or later loads to parse, as if it were the
+// largest twice more. It reports false if the count is more than limit,
+// or if the outlines do not read far enough to count.
+func (r *reader) work(limit int) (int, bool) {
+	w := &counter{limit: limit}
+	var glyph func(x int)
+	switch {
+	case r.cff:
+		cs, ok := r.charStrings()
+		if !ok {
+			return 0, false
+		}
+		glyph = func(x int) { cs.run(r.data, x, w) }
+	case r.tables["loca"].n != 0:
+		locs, ok := r.locations()
+		if !ok {
+			return 0, false
+		}
+		glyph = func(x int) { r.glyf(locs, x, 0, 0, w) }
+	default:
+		// A font of bitmaps, or none, has no outlines to load.
+		return 0, true
+	}
+	largest := 0
+	for x := range r.numGlyphs {
+		before := w.n
+		glyph(x)
+		largest = max(largest, w.n-before)
+		if w.n > limit {
+			return w.n, false
+		}
+	}
+	ok := w.add(2 * largest)
+	return w.n, ok
+}
+
+// locations returns where each glyph in the glyf table starts, and
+// where the last ends, from the loca table, or false if it is not the
+// length the glyphs in maxp make it.
+func (r *reader) locations() ([]int, bool) {
+	loca, glyf := r.tables["loca"], r.tables["glyf"]
+	long, ok := r.u16(r.tables["head"].off + 50)
+	if !ok {
+		return nil, false
+	}
+	size := 2
+	if long != 0 {
+		size = 4
+	}
+	if loca.n != size*(r.numGlyphs+1) || loca.off+loca.n > len(r.data) {
+		return nil, false
+	}
+	locs := make([]int, r.numGlyphs+1)
+	for i := range locs {
+		if long != 0 {
+			locs[i] = int(uint32(binary.BigEndian.Uint32(r.data[loca.off+4*i:])) + uint32(glyf.off))
+		} else {
+			locs[i] = 2*int(binary.BigEndian.Uint16(r.data[loca.off+2*i:])) + glyf.off
+		}
+	}
+	return locs, true
+}
+
+// Limits on the glyphs sfnt loads.
+const (
+	maxGlyphData = 0xffff
+	maxCompound  = 8  // deep a compound glyph may nest
+	maxStack     = 64 // components of a compound glyph and those it nests in
+	maxArgs      = 48
+	maxCalls     = 10
+	maxSubrs     = 40000
+	maxFontDicts = 256
+	maxSegments  = 20000 // of a cmap subtable
+)
+
+// glyf counts the work of loading glyph x from the glyf table: its
+// points and contours if it is simple, and the work of its components
+// if it is compound, which sfnt loads only as deep and as many as it
+// has room on its stack for, one after another.
+func (r *reader) glyf(locs []int, x, depth, stack int, w *counter) {
+	if x >= len(locs)-1 || w.n > w.limit {
+		return
+	}
+	i, j := locs[x], locs[x+1]
+	if j < i || j-i > maxGlyphData || j > len(r.data) {
+		return
+	}
+	d := r.data[i:j]
+	if len(d) < 10 {
+		return
+	}
+	contours := int(int16(binary.BigEndian.Uint16(d)))
+	switch {
+	case contours > 0:
+		if 10+2*contours > len(d) {
+			return
+		}
+		w.add(1 + int(binary.BigEndian.Uint16(d[10+2*contours-2:])) + contours)
+	case contours == -1:
+		if depth++; depth == maxCompound {
+			return
+		}
+		d = d[10:]
+		var parts []int
+		for top := stack; ; top++ {
+			if top >= maxStack || len(d) < 4 {
+				return
+			}
+			flags := binary.BigEndian.Uint16(d)
+			parts = append(parts, int(binary.BigEndian.Uint16(d[2:])))
+			size := 6
+			if flags&0x0001 != 0 { // ARG_1_AND_2_ARE_WORDS
+				size = 8
+			}
+			switch {
+			case flags&0x0008 != 0: // WE_HAVE_A_SCALE
+				size += 2
+			case flags&0x0040 != 0: // WE_HAVE_AN_X_AND_Y_SCALE
+				size += 4
+			case flags&0x0080 != 0: // WE_HAVE_A_TWO_BY_TWO
+				size += 8
+			}
+			if flags&0x0002 == 0 || len(d) < size { // ARGS_ARE_XY_VALUES
+				return
+			}
+			d = d[size:]
+			if flags&0x0020 == 0 { // MORE_COMPONENTS
+				break
+			}
+		}
+		for _, c := range parts {
+			if !w.add(1) {
+				return
+			}
+			r.glyf(locs, c, depth, stack+len(parts), w)
+		}
+	}
+}
+
+// offStart reports whether the first contour glyph x loads to is a
+// single point off the curve. It reports false for found if the glyph
+// has no contours.
+func (r *reader) offStart(locs []int, x, depth int) (off, found bool) {
+	if x >= len(locs)-1 || depth == maxCompound {
+		return false, false
+	}
+	i, j := locs[x], locs[x+1]
+	if j < i || j > len(r.data) || j-i < 12 {
+		return false, false
+	}
+	d := r.data[i:j]
+	contours := int(int16(binary.BigEndian.Uint16(d)))
+	if contours == -1 {
+		d = d[10:]
+		for len(d) >= 4 {
+			flags := binary.BigEndian.Uint16(d)
+			if off, found := r.offStart(locs, int(binary.BigEndian.Uint16(d[2:])), depth+1); found {
+				return off, true
+			}
+			size := 6
+			if flags&0x0001 != 0 {
+				size = 8
+			}
+			switch {
+			case flags&0x0008 != 0:
+				size += 2
+			case flags&0x0040 != 0:
+				size += 4
+			case flags&0x0080 != 0:
+				size += 8
+			}
+			if flags&0x0020 == 0 || len(d) < size {
+				break
+			}
+			d = d[size:]
+		}
+		return false, false
+	}
+	if contours <= 0 || 12+2*contours > len(d) {
+		return false, false
+	}
+	hints := int(binary.BigEndian.Uint16(d[10+2*contours:]))
+	flags := 12 + 2*contours + hints
+	if flags >= len(d) {
+		return false, false
+	}
+	return binary.BigEndian.Uint16(d[10:]) == 0 && d[flags]&0x01 == 0, true
+}
+
+// charStrings are the charstrings of a CFF font: where each glyph's,
+// and each global subroutine's, starts, and where the last ends; the
+// local subroutines of each font DICT, or of the Private DICT alone;
+// and, for a CID-keyed font, the font DICT of each glyph.
+type charStrings struct {
+	glyphs, gsubrs []int
+	subrs          [][]int
+	fdSelect       func(x int) (int, bool)
+}
+
+// A cffReader reads the CFF table from off, as sfnt does: nothing
+// before base or at end or past it.
+type cffReader struct {
+	data           []byte
+	base, off, end int
+	ok             bool
+}
+
+func (p *cffReader) read(n int) []byte {
+	if !p.ok || n < 0 || p.end-p.off < n {
+		p.ok = false
+		return nil
+	}
+	p.off += n
+	return p.data[p.off-n : p.off]
+}
+
+func (p *cffReader) seek(o int) {
+	if o < 0 || p.end-p.base < o {
+		p.ok = false
+	}
+	p.off = p.base + o
+}
+
+// index reads the header and offsets of an INDEX, and returns where
+// each of its items starts in the file, and where the last ends, or nil
+// for an INDEX with none.
+func (p *cffReader) index() []int {
+	b := p.read(2)
+	if !p.ok {
+		return nil
+	}
+	count := int(binary.BigEndian.Uint16(b))
+	if count == 0 {
+		return nil
+	}
+	b = p.read(1)
+	if !p.ok || b[0] < 1 || b[0] > 4 {
+		p.ok = false
+		return nil
+	}
+	size := int(b[0])
+	b = p.read((count + 1) * size)
+	locs := make([]int, count+1)
+	for i := range locs {
+		if !p.ok {
+			return nil
+		}
+		loc := 0
+		for _, c := range b[i*size : (i+1)*size] {
+			loc = loc<<8 | int(c)
+		}
+		loc--
+		switch {
+		case loc < 0, i == 0 && loc != 0, i > 0 && loc <= locs[i-1]-p.off, p.end-p.off < loc:
+			p.ok = false
+		}
+		locs[i] = p.off + loc
+	}
+	return locs
+}
+
+// skip skips an INDEX as sfnt does, by its last offset alone.
+func (p *cffReader) skip() {
+	b := p.read(2)
+	if !p.ok || binary.BigEndian.Uint16(b) == 0 {
+		return
+	}
+	count := int(binary.BigEndian.Uint16(b))
+	b = p.read(1)
+	if !p.ok || b[0] < 1 || b[0] > 4 {
+		p.ok = false
+		return
+	}
+	size := int(b[0])
+	p.read(count * size)
+	loc := 0
+	for _, c := range p.read(size) {
+		loc = loc<<8 | int(c)
+	}
+	if loc--; loc < 0 || p.end-p.off < loc {
+		p.ok = false
+	}
+	p.read(loc)
+}
+
+// dict reads a DICT and returns the operands of each operator in it,
+// keyed by the operator, or by 1200 plus the second byte of one that
+// is two, or false if it does not read.
+func dict(b []byte) (map[int][]int, bool) {
+	ops := map[int][]int{}
+	var stack []int
+	for len(b) > 0 {
+		switch c := b[0]; {
+		case c == 28 && len(b) >= 3:
+			stack = append(stack, int(int16(binary.BigEndian.Uint16(b[1:]))))
+			b = b[3:]
+		case c == 29 && len(b) >= 5:
+			stack = append(stack, int(int32(binary.BigEndian.Uint32(b[1:]))))
+			b = b[5:]
+		case c == 30:
+			// A real number, whose value no operator read here takes.
+			b = b[1:]
+			for len(b) > 0 && b[0]>>4 != 0xf && b[0]&0xf != 0xf {
+				b = b[1:]
+			}
+			if len(b) == 0 {
+				return nil, false
+			}
+			stack = append(stack, 0)
+			b = b[1:]
+		case c >= 32 && c <= 246:
+			stack = append(stack, int(c)-139)
+			b = b[1:]
+		case c >= 247 && c <= 250 && len(b) >= 2:
+			stack = append(stack, (int(c)-247)*256+int(b[1])+108)
+			b = b[2:]
+		case c >= 251 && c <= 254 && len(b) >= 2:
+			stack = append(stack, -(int(c)-251)*256-int(b[1])-108)
+			b = b[2:]
+		case c == 12 && len(b) >= 2:
+			ops[1200+int(b[1])] = stack
+			stack = nil
+			b = b[2:]
+		case c <= 21 && c != 12:
+			ops[int(c)] = stack
+			stack = nil
+			b = b[1:]
+		default:
+			return nil, false
+		}
+		if len(stack) > maxArgs {
+			return nil, false
+		}
+	}
+	return ops, true
+}
+
+// operand returns the last operand of op in ops, 0 if ops has no op, or
+// false if op has no operands.
+func operand(ops map[int][]int, op int) (int, bool) {
+	args, ok := ops[op]
+	if !ok {
+		return 0, true
+	}
+	if len(args) == 0 {
+		return 0, false
+	}
+	return args[len(args)-1], true
+}
+
+// privateAt returns the length and offset of the Private DICT in ops,
+// both 0 if it has none, or false if they are not both there.
+func privateAt(ops map[int][]int) ([]int, bool) {
+	args, ok := ops[18]
+	if !ok {
+		return []int{0, 0}, true
+	}
+	if len(args) < 2 {
+		return nil, false
+	}
+	return args[len(args)-2:], true
+}
+
+// charStrings reads the charstrings of the CFF table.
+func (r *reader) charStrings() (*charStrings, bool) {
+	t := r.tables["CFF "]
+	if t.off+t.n > len(r.data) {
+		return nil, false
+	}
+	p := &cffReader{data: r.data, base: t.off, off: t.off, end: t.off + t.n, ok: true}
+	if b := p.read(4); !p.ok || b[0] != 1 || b[1] != 0 || b[2] != 4 {
+		return nil, false
+	}
+	names := p.index()
+	if !p.ok || len(names) != 2 {
+		return nil, false
+	}
+	p.off = names[1]
+	tops := p.index()
+	if !p.ok || len(tops) != 2 {
+		return nil, false
+	}
+	top, ok := dict(p.read(tops[1] - tops[0]))
+	if !ok || !p.ok {
+		return nil, false
+	}
+	p.skip() // strings
+	cs := &charStrings{}
+	cs.gsubrs = p.index()
+	if !p.ok || len(cs.gsubrs) > maxSubrs+1 {
+		return nil, false
+	}
+	at, ok := operand(top, 17) // CharStrings
+	if !ok {
+		return nil, false
+	}
+	p.seek(at)
+	cs.glyphs = p.index()
+	if !p.ok || len(cs.glyphs) == 0 || len(cs.glyphs) != r.numGlyphs+1 {
+		return nil, false
+	}
+
+	if _, cid := top[1230]; !cid { // ROS
+		priv, ok := privateAt(top)
+		if !ok {
+			return nil, false
+		}
+		subrs, ok := p.private(priv)
+		if !ok {
+			return nil, false
+		}
+		cs.subrs = [][]int{subrs}
+		cs.fdSelect = func(int) (int, bool) { return 0, true }
+		return cs, true
+	}
+
+	at, ok = operand(top, 1237) // FDSelect
+	if !ok {
+		return nil, false
+	}
+	p.seek(at)
+	format := p.read(1)
+	if !p.ok {
+		return nil, false
+	}
+	switch format[0] {
+	case 0:
+		if p.end-p.off < r.numGlyphs {
+			return nil, false
+		}
+		off := p.off
+		cs.fdSelect = func(x int) (int, bool) {
+			if off+x >= len(r.data) {
+				return 0, false
+			}
+			return int(r.data[off+x]), true
+		}
+	case 3:
+		b := p.read(2)
+		if !p.ok {
+			return nil, false
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if p.end-p.off < 3*n+2 {
+			return nil, false
+		}
+		off := p.off
+		cs.fdSelect = func(x int) (int, bool) {
+			lo, hi := 0, n
+			for lo < hi {
+				i := (lo + hi) / 2
+				if off+3*i+5 > len(r.data) {
+					return 0, false
+				}
+				rng := r.data[off+3*i:]
+				switch {
+				case x < int(binary.BigEndian.Uint16(rng)):
+					hi = i
+				case int(binary.BigEndian.Uint16(rng[3:])) <= x:
+					lo = i + 1
+				default:
+					return int(rng[2]), true
+				}
+			}
+			return 0, false
+		}
+	default:
+		return nil, false
+	}
+
+	at, ok = operand(top, 1236) // FDArray
+	if !ok {
+		return nil, false
+	}
+	p.seek(at)
+	fds := p.index()
+	if !p.ok || len(fds) > maxFontDicts+1 {
+		return nil, false
+	}
+	var privs [][]int
+	for i := 1; i < len(fds); i++ {
+		fd, ok := dict(p.read(fds[i] - fds[i-1]))
+		if !ok || !p.ok {
+			return nil, false
+		}
+		priv, ok := privateAt(fd)
+		if !ok {
+			return nil, false
+		}
+		privs = append(privs, priv)
+	}
+	for _, priv := range privs {
+		subrs, ok := p.private(priv)
+		if !ok {
+			return nil, false
+		}
+		cs.subrs = append(cs.subrs, subrs)
+	}
+	return cs, true
+}
+
+// private reads the local subroutines of the Private DICT of length and
+// offset priv.
+func (p *cffReader) private(priv []int) ([]int, bool) {
+	length, offset := priv[0], priv[1]
+	if length == 0 {
+		return nil, true
+	}
+	full := p.end - p.base
+	if offset <= 0 || full < offset || full-offset < length || length < 0 {
+		return nil, false
+	}
+	p.off = p.base + offset
+	ops, ok := dict(p.read(length))
+	if !ok || !p.ok {
+		return nil, false
+	}
+	at, ok := operand(ops, 19) // Subrs
+	if !ok {
+		return nil, false
+	}
+	if at == 0 {
+		return nil, true
+	}
+	p.seek(offset + at)
+	subrs := p.index()
+	return subrs, p.ok && len(subrs) <= maxSubrs+1
+}
+
+// bias is what a charstring adds to the number of a subroutine it
+// calls, among n of them.
+func bias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// run counts the work of loading glyph x: every number and operator of
+// its charstring and the subroutines it calls, as sfnt runs them, up to
+// the end of the charstring or endchar, or an operator sfnt does not
+// know. It follows hints far enough to skip the bytes of hint masks.
+func (cs *charStrings) run(data []byte, x int, w *counter) {
+	i, j := cs.glyphs[x], cs.glyphs[x+1]
+	if j-i > maxGlyphData || j > len(data) {
+		return
+	}
+	code := data[i:j]
+	var stack []int32
+	var calls [][]byte
+	hints := 0
+	for len(code) > 0 && w.add(1) {
+		c := code[0]
+		if c == 28 || c >= 32 {
+			n := 1
+			switch {
+			case c == 28:
+				n = 3
+			case c >= 247 && c <= 254:
+				n = 2
+			case c == 255:
+				n = 5
+			}
+			if len(code) < n || len(stack) == maxArgs {
+				return
+			}
+			var v int32
+			switch {
+			case c == 28:
+				v = int32(int16(binary.BigEndian.Uint16(code[1:])))
+			case c <= 246:
+				v = int32(c) - 139
+			case c <= 250:
+				v = (int32(c)-247)*256 + int32(code[1]) + 108
+			case c <= 254:
+				v = -(int32(c)-251)*256 - int32(code[1]) - 108
+			default:
+				// A 16.16 fixed number, which sfnt rounds to an integer.
+				v = int32(binary.BigEndian.Uint32(code[1:]))
+				v = v>>16 + 1&(v>>15)
+			}
+			stack = append(stack, v)
+			code = code[n:]
+			continue
+		}
+		code = code[1:]
+		switch c {
+		case 10, 29: // callsubr, callgsubr
+			subrs := cs.gsubrs
+			if c == 10 {
+				fd, ok := cs.fdSelect(x)
+				if !ok || fd >= len(cs.subrs) {
+					return
+				}
+				subrs = cs.subrs[fd]
+			}
+			if len(stack) == 0 || len(calls) == maxCalls || len(subrs) == 0 {
+				return
+			}
+			n := int(stack[len(stack)-1]) + bias(len(subrs)-1)
+			stack = stack[:len(stack)-1]
+			if n < 0 || n >= len(subrs)-1 {
+				return
+			}
+			i, j := subrs[n], subrs[n+1]
+			if j-i > maxGlyphData || j > len(data) {
+				return
+			}
+			calls = append(calls, code)
+			code = data[i:j]
+		case 11: // return
+			if len(calls) == 0 {
+				return
+			}
+			code = calls[len(calls)-1]
+			calls = calls[:len(calls)-1]
+		case 14: // endchar
+			return
+		case 1, 3, 18, 23: // hstem, vstem, hstemhm, vstemhm
+			hints += len(stack) / 2
+			stack = stack[:0]
+		case 19, 20: // hintmask, cntrmask
+			hints += len(stack) / 2
+			stack = stack[:0]
+			n := (hints + 7) / 8
+			if len(code) < n {
+				return
+			}
+			code = code[n:]
+		case 4, 5, 6, 7, 8, 21, 22, 24, 25, 26, 27, 30, 31:
+			stack = stack[:0]
+		case 12:
+			if len(code) == 0 {
+				return
+			}
+			pop := map[byte]int{34: 7, 36: 9}[code[0]] // hflex, hflex1
+			if pop == 0 || len(stack) < pop {
+				return
+			}
+			stack = stack[:len(stack)-pop]
+			code = code[1:]
+		default:
+			return
+		}
+	}
+}
+
+// advance returns the advance width of glyph x in the hmtx table, that
+// of the last metric for a glyph past the last, or false if it is not
+// there.
+func (r *reader) advance(x int) (int, bool) {
+	metrics, ok := r.u16(r.tables["hhea"].off + 34)
+	if !ok || metrics == 0 {
+		return 0, false
+	}
+	return r.u16(r.tables["hmtx"].off + 4*min(x, metrics-1))
+}
+
+// A glyphMap gives the glyph a character maps to, or false where sfnt
+// fails to find it.
+type glyphMap func(c rune) (uint16, bool)
+
+// cmap returns the subtable of the cmap table sfnt reads, the widest
+// of those whose platform, encoding and format it supports, and the
+// characters worth asking it about: the first and last of each segment
+// or group, and those either side of them. It returns nil for a table
+// that does not read, a subtable of format 0, and one whose segments or
+// groups are out of order or overlap, where sfnt's binary search may
+// find what a reader looking at each in turn would not.
+func (r *reader) cmap() (glyphMap, []rune) {
+	t := r.tables["cmap"]
+	n, ok := r.u16(t.off + 2)
+	if !ok || t.n < 4+8*n {
+		return nil, nil
+	}
+	width, at, format := 0, 0, 0
+	for i := range n {
+		rec := t.off + 4 + 8*i
+		pid, _ := r.u16(rec)
+		psid, _ := r.u16(rec + 2)
+		w := map[[2]int]int{{0, 3}: 2, {0, 4}: 4, {1, 0}: 1, {3, 0}: 2, {3, 1}: 2, {3, 10}: 4}[[2]int{pid, psid}]
+		if w <= width {
+			continue
+		}
+		off, _ := r.u32(rec + 4)
+		if off > t.n-4 {
+			return nil, nil
+		}
+		f, ok := r.u16(t.off + off)
+		if !ok {
+			return nil, nil
+		}
+		if f == 4 || f == 6 || f == 12 || f == 0 && pid == 1 && psid == 0 {
+			width, at, format = w, off, f
+		}
+	}
+	if width == 0 {
+		return nil, nil
+	}
+	switch format {
+	case 4:
+		return r.format4(t, at)
+	case 6:
+		return r.format6(t, at)
+	case 12:
+		return r.format12(t, at)
+	}
+	return nil, nil
+}
+
+// ends returns c-1, c, d and d+1, the characters either side of a range
+// from c to d.
+func ends(c, d int) []rune {
+	return []rune{rune(c) - 1, rune(c), rune(d), rune(d) + 1}
+}
+
+func (r *reader) format4(t entry, at int) (glyphMap, []rune) {
+	if at+14 > t.n {
+		return nil, nil
+	}
+	x2, _ := r.u16(t.off + at + 6)
+	n := x2 / 2
+	if x2%2 != 0 || n > maxSegments || at+14+8*n+2 > t.n {
+		return nil, nil
+	}
+	arrays := t.off + at + 14
+	type segment struct{ start, end, delta, offset int }
+	segs := make([]segment, n)
+	var runes []rune
+	for i := range segs {
+		s := &segs[i]
+		s.end, _ = r.u16(arrays + 2*i)
+		s.start, _ = r.u16(arrays + 2*n + 2 + 2*i)
+		s.delta, _ = r.u16(arrays + 4*n + 2 + 2*i)
+		s.offset, _ = r.u16(arrays + 6*n + 2 + 2*i)
+		if s.start > s.end || i > 0 && segs[i-1].end >= s.start {
+			return nil, nil
+		}
+		runes = append(runes, ends(s.start, s.end)...)
+	}
+	// The glyph array is the rest of the table.
+	array, arrayLen := arrays+8*n+2, t.n-(at+14+8*n+2)
+	return func(c rune) (uint16, bool) {
+		if uint32(c) > 0xffff {
+			return 0, true
+		}
+		for i, s := range segs {
+			switch {
+			case int(c) < s.start || int(c) > s.end:
+				continue
+			case s.offset == 0:
+				return uint16(int(c) + s.delta), true
+			}
+			// Known: sfnt takes a glyph from the array as it is, without
+			// the segment's delta, which the spec adds to it.
+			off := s.offset + 2*(i-n+int(c)-s.start)
+			if off < 0 || off+2 > arrayLen {
+				return 0, false
+			}
+			g, _ := r.u16(array + off)
+			return uint16(g), true
+		}
+		return 0, true
+	}, runes
+}
+
+func (r *reader) format6(t entry, at int) (glyphMap, []rune) {
+	if at+10 > t.n {
+		return nil, nil
+	}
+	first, _ := r.u16(t.off + at + 6)
+	n, _ := r.u16(t.off + at + 8)
+	if at+10+2*n > t.n {
+		return nil, nil
+	}
+	return func(c rune) (uint16, bool) {
+		// Known: sfnt asks a subtable of format 6 about the character
+		// of the low 16 bits of one past the Basic Multilingual Plane.
+		i := int(uint16(c)) - first
+		if i < 0 || i >= n {
+			return 0, true
+		}
+		g, _ := r.u16(t.off + at + 10 + 2*i)
+		return uint16(g), true
+	}, ends(first, first+n-1)
+}
+
+func (r *reader) format12(t entry, at int) (glyphMap, []rune) {
+	if at+16 > t.n {
+		return nil, nil
+	}
+	length, _ := r.u32(t.off + at + 4)
+	n, _ := r.u32(t.off + at + 12)
+	if length > t.n-at || n > maxSegments || 16+12*n != length {
+		return nil, nil
+	}
+	groups := make([][3]uint32, n)
+	var runes []rune
+	for i := range groups {
+		for j := range groups[i] {
+			v, _ := r.u32(t.off + at + 16 + 12*i + 4*j)
+			groups[i][j] = uint32(v)
+		}
+		g := groups[i]
+		if g[0] > g[1] || i > 0 && groups[i-1][1] >= g[0] {
+			return nil, nil
+		}
+		runes = append(runes, ends(int(g[0]), int(g[1]))...)
+	}
+	return func(c rune) (uint16, bool) {
+		for _, g := range groups {
+			if uint32(c) >= g[0] && uint32(c) <= g[1] {
+				return uint16(uint32(c) - g[0] + g[2]), true
+			}
+		}
+		return 0, true
+	}, runes
+}
+
+// gposFaults walks the GPOS table as sfnt does to find the lookups of
+// the kern feature for Latin, or failing that for the default script,
+// and their pairs. It reports whether sfnt indexes past the end of a
+// list parsing it, and whether it may do so kerning with it: with the
+// pairs of a glyph whose count runs past the end of what sfnt keeps, or
+// a class with no row or column of its own.
+func (r *reader) gposFaults() (parse, kern bool) {
+	t, ok := r.tables["GPOS"]
+	if !ok || t.n < 10 {
+		return false, false
+	}
+	major, _ := r.u16(t.off)
+	minor, _ := r.u16(t.off + 2)
+	if major != 1 || minor > 1 {
+		return false, false
+	}
+	scripts, _ := r.u16(t.off + 4)
+	features, _ := r.u16(t.off + 6)
+	lookups, _ := r.u16(t.off + 8)
+	scripts, features, lookups = t.off+scripts, t.off+features, t.off+lookups
+
+	idxs := r.langSys(scripts, "latn")
+	if len(idxs) == 0 {
+		idxs = r.langSys(scripts, "DFLT")
+	}
+	var lookupIdxs []int
+	nf, ok := r.u16(features)
+	if len(idxs) == 0 || !ok || features+2+6*nf > len(r.data) {
+		return false, false
+	}
+	for _, i := range idxs {
+		switch {
+		case i == nf:
+			return true, true
+		case i > nf:
+			return false, false
+		case string(r.data[features+2+6*i:][:4]) != "kern":
+			continue
+		}
+		off, _ := r.u16(features + 2 + 6*i + 4)
+		n, ok := r.u16(features + off + 2)
+		if !ok || features+off+4+2*n > len(r.data) {
+			return false, false
+		}
+		for j := range n {
+			l, _ := r.u16(features + off + 4 + 2*j)
+			lookupIdxs = append(lookupIdxs, l)
+		}
+	}
+	nl, ok := r.u16(lookups)
+	if !ok || lookups+2+2*nl > len(r.data) {
+		return false, false
+	}
+	for _, i := range lookupIdxs {
+		switch {
+		case i == nl:
+			return true, true
+		case i > nl:
+			return false, false
+		}
+		off, _ := r.u16(lookups + 2 + 2*i)
+		lookup := lookups + off
+		n, ok := r.u16(lookup + 4)
+		if !ok || lookup+6+2*n > len(r.data) {
+			return false, false
+		}
+		typ, _ := r.u16(lookup)
+		flags, _ := r.u16(lookup + 2)
+		subs := make([]int, n)
+		for j := range subs {
+			o, _ := r.u16(lookup + 6 + 2*j)
+			subs[j] = lookup + o
+		}
+		switch typ {
+		case 2:
+		case 9:
+			ext := true
+			for j, sub := range subs {
+				format, ok := r.u16(sub)
+				typ, _ := r.u16(sub + 2)
+				o, _ := r.u32(sub + 4)
+				if !ok || sub+8 > len(r.data) || format != 1 {
+					return false, kern
+				}
+				if typ != 2 {
+					ext = false
+					break
+				}
+				subs[j] += o
+			}
+			if !ext {
+				continue
+			}
+		default:
+			continue
+		}
+		if flags&0x0010 != 0 {
+			continue
+		}
+		for _, sub := range subs {
+			switch r.pairPosFaults(sub) {
+			case errored:
+				return false, kern
+			case faulted:
+				kern = true
+			}
+		}
+	}
+	return false, kern
+}
+
+// A walk is how walking part of a table went: on to the next part, to
+// an error sfnt stops at, or to a fault it may trip on later.
+type walk int
+
+const (
+	fine walk = iota
+	errored
+	faulted
+)
+
+// langSys returns the indexes of the features of the default language
+// of script in the script list at scripts, or none if it has none or
+// does not read.
+func (r *reader) langSys(scripts int, script string) []int {
+	n, ok := r.u16(scripts)
+	if !ok || scripts+2+6*n > len(r.data) {
+		return nil
+	}
+	for i := range n {
+		if string(r.data[scripts+2+6*i:][:4]) != script {
+			continue
+		}
+		off, _ := r.u16(scripts + 2 + 6*i + 4)
+		if off == 0 {
+			return nil
+		}
+		lang, ok := r.u16(scripts + off)
+		if !ok || lang == 0 {
+			return nil
+		}
+		at := scripts + off + lang
+		n, ok := r.u16(at + 4)
+		if !ok || at+6+2*n > len(r.data) {
+			return nil
+		}
+		idxs := make([]int, n)
+		for j := range idxs {
+			idxs[j], _ = r.u16(at + 6 + 2*j)
+		}
+		return idxs
+	}
+	return nil
+}
+
+// pairPosFaults walks the pair adjustment subtable at sub.
+func (r *reader) pairPosFaults(sub int) walk {
+	format, ok := r.u16(sub)
+	cov, _ := r.u16(sub + 2)
+	if !ok || sub+4 > len(r.data) {
+		return errored
+	}
+	if covFormat, _ := r.u16(sub + cov); covFormat != 1 && covFormat != 2 {
+		return errored
+	}
+	switch format {
+	case 1:
+		n, ok := r.u16(sub + 8)
+		if !ok || sub+10+2*n > len(r.data) {
+			return errored
+		}
+		if v1, _ := r.u16(sub + 4); v1 != 4 {
+			return fine
+		}
+		if v2, _ := r.u16(sub + 6); v2 != 0 {
+			return fine
+		}
+		lastSet := 0
+		for i := range n {
+			o, _ := r.u16(sub + 10 + 2*i)
+			lastSet = max(lastSet, o)
+		}
+		count, ok := r.u16(sub + lastSet)
+		length := lastSet + 2 + 4*count
+		if !ok || sub+length > len(r.data) {
+			return errored
+		}
+		// sfnt keeps length bytes of the subtable, and reads the offset
+		// of each glyph's pairs, and the pairs, from them.
+		if 10+2*n > length {
+			return faulted
+		}
+		for i := range n {
+			o, _ := r.u16(sub + 10 + 2*i)
+			if o+1 >= length {
+				continue
+			}
+			if count, _ := r.u16(sub + o); o+2+4*count > length {
+				return faulted
+			}
+		}
+	case 2:
+		if sub+16 > len(r.data) {
+			return errored
+		}
+		if v1, _ := r.u16(sub + 4); v1 != 4 {
+			return fine
+		}
+		if v2, _ := r.u16(sub + 6); v2 != 0 {
+			return fine
+		}
+		def1, _ := r.u16(sub + 8)
+		def2, _ := r.u16(sub + 10)
+		n1, _ := r.u16(sub + 12)
+		n2, _ := r.u16(sub + 14)
+		max1, ok1 := r.maxClass(sub + def1)
+		max2, ok2 := r.maxClass(sub + def2)
+		if !ok1 || !ok2 || sub+16+2*n1*n2 > len(r.data) {
+			return errored
+		}
+		if max1 >= n1 || max2 >= n2 {
+			return faulted
+		}
+	}
+	return fine
+}
+
+// maxClass returns the largest class the class definition at def gives
+// a glyph, 0 for the glyphs it gives none, or false if it does not
+// read.
+func (r *reader) maxClass(def int) (int, bool) {
+	format, ok := r.u16(def)
+	if !ok {
+		return 0, false
+	}
+	most := 0
+	switch format {
+	case 1:
+		n, ok := r.u16(def + 4)
+		if !ok || def+6+2*n > len(r.data) {
+			return 0, false
+		}
+		for i := range n {
+			c, _ := r.u16(def + 6 + 2*i)
+			most = max(most, c)
+		}
+	case 2:
+		n, ok := r.u16(def + 2)
+		if !ok || def+4+6*n > len(r.data) {
+			return 0, false
+		}
+		for i := range n {
+			c, _ := r.u16(def + 4 + 6*i + 4)
+			most = max(most, c)
+		}
+	default:
+		return 0, false
+	}
+	return most, true
+}
@@ -0,0 +1,40 @@
+// Package format is a differential fuzz target for go/format: formatting
+// must be idempotent and must never turn a parsable file into an
+// unparsable one.
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+)
+
+// Check formats src twice and compares the results. Sources that do not
+// parse are ignored.
+func Check(src []byte) error {
+	if !parses(src) {
+		return nil
+	}
+	once, err := format.Source(src)
+	if err != nil {
+		return fmt.Errorf("formatting a valid file failed: %v", err)
+	}
+	if !parses(once) {
+		return fmt.Errorf("formatted output does not parse:\n%s", once)
+	}
+	twice, err := format.Source(once)
+	if err != nil {
+		return fmt.Errorf("reformatting failed: %v\n%s", err, once)
+	}
+	if !bytes.Equal(once, twice) {
+		return fmt.Errorf("format is not idempotent:\n--- format(x)\n%s\n--- format(format(x))\n%s", once, twice)
+	}
+	return nil
+}
+
+func parses(src []byte) bool {
+	_, err := parser.ParseFile(token.NewFileSet(), "seed.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	return err == nil
+}
@@ -0,0 +1,498 @@
+// Package git is a fuzz target for the decoders of git's smart protocol
+// and packfiles in github.com/go-git/go-git. Readers written here to
+// git's documentation of the protocol and pack format read each input
+// too.
+//
+// CheckAdvertisement reads the refs a server advertises and
+// CheckUploadRequest what a client sends upload-pack. go-git's
+// pkt-line scanner must read the pkt-lines the reader here reads, and
+// stop only where it does; an advertisement or request the reader here
+// takes, go-git must decode to the same refs, capabilities, wants,
+// shallows and depth; and one go-git decodes it must encode to one it
+// decodes the same way again.
+//
+// CheckPack reads a packfile with go-git's parser, from a seekable
+// source and into storage from one that is not: a pack the reader here
+// finds whole and valid, it must parse to the same objects, and the
+// objects it parses from any pack must be ones the reader here finds in
+// it, resolving each delta against the same base.
+package git
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp/capability"
+)
+
+// Timeout bounds reading one input.
+var Timeout = 10 * time.Second
+
+const (
+	// maxLines bounds the pkt-lines read from one input.
+	maxLines = 4096
+	// maxPacket is the longest pkt-line git sends or takes, its length
+	// included.
+	maxPacket = 65520
+)
+
+// A pkt is a pkt-line: a flush-pkt, or one holding data.
+type pkt struct {
+	flush bool
+	data  string
+}
+
+// readPkts reads the pkt-lines in data: four hexadecimal digits of
+// length, the digits included, and that much data, or 0000 for a
+// flush-pkt. The delim-pkt and response-end-pkt of protocol v2, 0001
+// and 0002, and 0003 are no pkt-lines of protocol v0; lengths past
+// limit, or past the end of data, end it too. It returns the pkt-lines
+// it read and whether it read all of data.
+func readPkts(data []byte, limit int) ([]pkt, bool) {
+	var pkts []pkt
+	for len(data) > 0 && len(pkts) < maxLines {
+		if len(data) < 4 {
+			return pkts, false
+		}
+		n, err := strconv.ParseUint(string(data[:4]), 16, 16)
+		if err != nil {
+			return pkts, false
+		}
+		switch {
+		case n == 0:
+			pkts = append(pkts, pkt{flush: true})
+			data = data[4:]
+			continue
+		case n < 4, int(n) > limit, int(n) > len(data):
+			return pkts, false
+		}
+		pkts = append(pkts, pkt{data: string(data[4:n])})
+		data = data[n:]
+	}
+	return pkts, len(data) == 0
+}
+
+// checkPkts checks that go-git's scanner reads the pkt-lines readPkts
+// does, and stops only where it does.
+//
+// Known: go-git takes pkt-lines as long as 65524 bytes, of 65520 of data,
+// where git stops at 65520 with the length, and it rejects the empty
+// pkt-line, 0004, which git takes; and its scanner stops at a pkt-line
+// of an error, "ERR ...", as it is meant to.
+func checkPkts(data []byte) error {
+	want, whole := readPkts(data, maxPacket+4)
+	sc := pktline.NewScanner(bytes.NewReader(data))
+	var got []string
+	for len(got) < len(want) && sc.Scan() {
+		got = append(got, string(sc.Bytes()))
+	}
+	for i, p := range got {
+		if p != want[i].data {
+			return fmt.Errorf("pkt-line %d reads as %q, not %q", i, p, want[i].data)
+		}
+	}
+	if len(got) < len(want) {
+		next := want[len(got)]
+		if sc.Err() == nil || !next.flush && next.data != "" && !strings.HasPrefix(next.data, "ERR ") {
+			return fmt.Errorf("the scanner stops after %d pkt-lines, with error %v, not %d", len(got), sc.Err(), len(want))
+		}
+		return nil
+	}
+	if len(want) == maxLines {
+		return nil
+	}
+	if sc.Scan() || (sc.Err() == nil) != whole {
+		return fmt.Errorf("the scanner reads past %d pkt-lines, or stops with error %v", len(want), sc.Err())
+	}
+	return nil
+}
+
+// isHash reports whether s is an object id in hexadecimal.
+func isHash(s string) bool {
+	_, err := hex.DecodeString(s)
+	return len(s) == 40 && err == nil
+}
+
+func hash(s string) plumbing.Hash {
+	var h plumbing.Hash
+	hex.Decode(h[:], []byte(s))
+	return h
+}
+
+// caps returns the capability list s as go-git reads one, written back.
+func caps(s string) (string, error) {
+	l := capability.NewList()
+	if err := l.Decode([]byte(s)); err != nil {
+		return "", err
+	}
+	return l.String(), nil
+}
+
+// reread returns the capability list c as it reads once written.
+//
+// Known: go-git's decoder trims the spaces around a list, and with them
+// capabilities with no name at either end of it.
+func reread(c string) string {
+	if c, err := caps(c); err == nil {
+		return c
+	}
+	return c
+}
+
+// An advertisement is what the refs a server advertises say.
+type advertisement struct {
+	Prefix     []string
+	Head       *plumbing.Hash
+	Caps       string
+	References map[string]plumbing.Hash
+	Peeled     map[string]plumbing.Hash
+	Shallows   []plumbing.Hash
+}
+
+func (a *advertisement) equal(b *advertisement) bool {
+	return slices.Equal(a.Prefix, b.Prefix) && (a.Head == nil) == (b.Head == nil) && (a.Head == nil || *a.Head == *b.Head) &&
+		a.Caps == b.Caps && maps.Equal(a.References, b.References) && maps.Equal(a.Peeled, b.Peeled) &&
+		slices.Equal(a.Shallows, b.Shallows)
+}
+
+// readAdvertisement reads pkts as the refs a server advertises, by the
+// grammar of git's pack protocol: optionally the "# service=" line of
+// smart HTTP and a flush-pkt, then the first ref and the capability
+// list, or the zero id and "capabilities^{}" for no refs at all, the
+// other refs and their peeled values, shallows and a flush-pkt. Each
+// line may end in a newline. Capability lists are read as go-git reads
+// them.
+func readAdvertisement(pkts []pkt) (*advertisement, bool) {
+	a := &advertisement{References: map[string]plumbing.Hash{}, Peeled: map[string]plumbing.Hash{}}
+	i := 0
+	next := func() (string, bool) {
+		if i == len(pkts) || !pkts[i].flush && (pkts[i].data == "" || strings.HasPrefix(pkts[i].data, "ERR ")) {
+			return "", false
+		}
+		i++
+		if pkts[i-1].flush {
+			return "", true
+		}
+		return strings.TrimSuffix(pkts[i-1].data, "\n"), true
+	}
+	line, ok := next()
+	if ok && strings.HasPrefix(line, "#") {
+		a.Prefix = append(a.Prefix, line)
+		if line, ok = next(); ok && line == "" && pkts[i-1].flush {
+			a.Prefix = append(a.Prefix, "")
+			line, ok = next()
+		}
+	}
+	if !ok || len(line) < 41 || !isHash(line[:40]) || line[40] != ' ' {
+		return nil, false
+	}
+	name, list, found := strings.Cut(line[41:], "\x00")
+	if !found || name == "" || strings.Contains(name, " ") {
+		return nil, false
+	}
+	var err error
+	if a.Caps, err = caps(list); err != nil {
+		return nil, false
+	}
+	switch h := hash(line[:40]); {
+	case h.IsZero():
+		if name != "capabilities^{}" {
+			return nil, false
+		}
+	case name == "HEAD":
+		a.Head = &h
+	default:
+		a.References[name] = h
+	}
+	for {
+		if line, ok = next(); !ok {
+			return nil, false
+		}
+		if pkts[i-1].flush {
+			return a, true
+		}
+		if rest, found := strings.CutPrefix(line, "shallow "); found && isHash(rest) {
+			a.Shallows = append(a.Shallows, hash(rest))
+			continue
+		}
+		if len(a.Shallows) > 0 || len(line) < 42 || !isHash(line[:40]) || line[40] != ' ' {
+			return nil, false
+		}
+		name := line[41:]
+		refs := a.References
+		if n, found := strings.CutSuffix(name, "^{}"); found {
+			name, refs = n, a.Peeled
+		}
+		if name == "" || strings.Contains(name, " ") {
+			return nil, false
+		}
+		refs[name] = hash(line[:40])
+	}
+}
+
+// sorted returns a with its shallows sorted, as an encoder writes them.
+//
+// Known: go-git's encoder writes HEAD or, where there is none, the first
+// ref by name on the first line, and leaves out that ref's peeled value,
+// a ref named HEAD beside HEAD, and peeled values of no ref.
+func (a *advertisement) sorted() *advertisement {
+	b := *a
+	b.Caps = reread(a.Caps)
+	first := "HEAD"
+	b.References = maps.Clone(a.References)
+	if a.Head == nil && len(a.References) > 0 {
+		first = slices.Min(slices.Collect(maps.Keys(a.References)))
+	} else if a.Head != nil {
+		delete(b.References, "HEAD")
+	}
+	b.Peeled = map[string]plumbing.Hash{}
+	for name, h := range a.Peeled {
+		if _, ok := b.References[name]; ok && name != first {
+			b.Peeled[name] = h
+		}
+	}
+	b.Shallows = slices.SortedFunc(slices.Values(a.Shallows), compareHash)
+	if len(b.Shallows) == 0 {
+		b.Shallows = nil
+	}
+	return &b
+}
+
+// encodable reports whether go-git's encoder writes ar so that its
+// decoder reads it back.
+//
+// Known: the encoder writes the first ref by name where there is no
+// HEAD, and one with the zero id or no name then reads as the line of an
+// empty repository, and one with a NUL in its name as a shorter name and
+// more capabilities. The decoder takes a space in the first ref's name,
+// but not in the others', where the encoder may write it.
+func encodable(ar *packp.AdvRefs) bool {
+	names := slices.Sorted(maps.Keys(ar.References))
+	if ar.Head == nil && len(names) > 0 {
+		if names[0] == "" || strings.Contains(names[0], "\x00") || ar.References[names[0]].IsZero() {
+			return false
+		}
+		names = names[1:]
+	}
+	for _, name := range names {
+		if strings.Contains(name, " ") {
+			return false
+		}
+	}
+	return true
+}
+
+func fromAdvRefs(ar *packp.AdvRefs) *advertisement {
+	a := &advertisement{Head: ar.Head, Caps: ar.Capabilities.String(), References: ar.References, Peeled: ar.Peeled}
+	for _, p := range ar.Prefix {
+		a.Prefix = append(a.Prefix, string(p))
+	}
+	a.Shallows = ar.Shallows
+	if len(a.Shallows) == 0 {
+		a.Shallows = nil
+	}
+	return a
+}
+
+// CheckAdvertisement checks the refs a server advertises in data.
+func CheckAdvertisement(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		if err := checkPkts(data); err != nil {
+			return err
+		}
+		pkts, _ := readPkts(data, maxPacket)
+		want, ok := readAdvertisement(pkts)
+		ar := packp.NewAdvRefs()
+		err := ar.Decode(bytes.NewReader(data))
+		if ok && err != nil {
+			return fmt.Errorf("Decode: %v, but the advertisement reads as %+v", err, want)
+		}
+		if err != nil {
+			return nil
+		}
+		got := fromAdvRefs(ar)
+		if ok && !got.equal(want) {
+			return fmt.Errorf("the advertisement decodes as\n%+v\nnot\n%+v", got, want)
+		}
+		if !encodable(ar) {
+			return nil
+		}
+		var out bytes.Buffer
+		if err := ar.Encode(&out); err != nil {
+			return fmt.Errorf("Encode: %v", err)
+		}
+		again := packp.NewAdvRefs()
+		if err := again.Decode(bytes.NewReader(out.Bytes())); err != nil {
+			return fmt.Errorf("the advertisement encodes as %q, which decodes with error %v", out.Bytes(), err)
+		}
+		got = got.sorted()
+		if a := fromAdvRefs(again); !a.equal(got) {
+			return fmt.Errorf("the advertisement\n%+v\nencodes as %q, which decodes as\n%+v", got, out.Bytes(), a)
+		}
+		return nil
+	})
+}
+
+// A request is what a client's request to upload-pack says.
+type request struct {
+	Caps     string
+	Wants    []plumbing.Hash
+	Shallows []plumbing.Hash
+	Depth    packp.Depth
+}
+
+func (r *request) equal(s *request) bool {
+	return r.Caps == s.Caps && slices.Equal(r.Wants, s.Wants) && slices.Equal(r.Shallows, s.Shallows) && depth(r.Depth) == depth(s.Depth)
+}
+
+// depth returns d written out, with the zero depths of each kind alike.
+func depth(d packp.Depth) string {
+	switch d := d.(type) {
+	case packp.DepthSince:
+		return fmt.Sprint("since ", time.Time(d).Unix())
+	case packp.DepthReference:
+		return "not " + string(d)
+	case packp.DepthCommits:
+		if d == 0 {
+			return ""
+		}
+		return fmt.Sprint(int(d))
+	}
+	return ""
+}
+
+// readRequest reads pkts as a client's request to upload-pack, by the
+// grammar of git's pack protocol, up to its flush-pkt: wants, the first
+// with the capability list, shallows, and a deepen, deepen-since or
+// deepen-not line. Each line may end in a newline.
+//
+// Known: go-git's decoder reads no filter line, though its encoder
+// writes one, so none is read here either.
+func readRequest(pkts []pkt) (*request, bool) {
+	r := &request{Depth: packp.DepthCommits(0)}
+	for i, p := range pkts {
+		if p.flush {
+			return r, len(r.Wants) > 0
+		}
+		line, _ := strings.CutSuffix(p.data, "\n")
+		if rest, ok := strings.CutPrefix(line, "want "); ok && len(r.Shallows) == 0 && len(rest) >= 40 && isHash(rest[:40]) {
+			r.Wants = append(r.Wants, hash(rest[:40]))
+			if i > 0 && rest != rest[:40] {
+				return nil, false
+			}
+			if i == 0 && rest != rest[:40] {
+				list, ok := strings.CutPrefix(rest[40:], " ")
+				c, err := caps(list)
+				if !ok || err != nil {
+					return nil, false
+				}
+				r.Caps = c
+			}
+			continue
+		}
+		if len(r.Wants) == 0 {
+			return nil, false
+		}
+		if rest, ok := strings.CutPrefix(line, "shallow "); ok && isHash(rest) {
+			r.Shallows = append(r.Shallows, hash(rest))
+			continue
+		}
+		if rest, ok := strings.CutPrefix(line, "deepen "); ok && digits(rest) {
+			n, err := strconv.Atoi(rest)
+			if err != nil {
+				return nil, false
+			}
+			r.Depth = packp.DepthCommits(n)
+		} else if rest, ok := strings.CutPrefix(line, "deepen-since "); ok && digits(rest) {
+			n, err := strconv.ParseInt(rest, 10, 64)
+			if err != nil {
+				return nil, false
+			}
+			r.Depth = packp.DepthSince(time.Unix(n, 0).UTC())
+		} else if rest, ok := strings.CutPrefix(line, "deepen-not "); ok {
+			r.Depth = packp.DepthReference(rest)
+		} else {
+			return nil, false
+		}
+		return r, i+1 < len(pkts) && pkts[i+1].flush
+	}
+	return nil, false
+}
+
+func digits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+func fromUploadRequest(ur *packp.UploadRequest) *request {
+	r := &request{Caps: ur.Capabilities.String(), Wants: ur.Wants, Shallows: ur.Shallows, Depth: ur.Depth}
+	if len(r.Shallows) == 0 {
+		r.Shallows = nil
+	}
+	return r
+}
+
+// sorted returns r with its wants and shallows sorted and without
+// duplicates, as an encoder writes them.
+//
+// Known: go-git's encoder drops a shallow of the zero id.
+func (r *request) sorted() *request {
+	s := *r
+	s.Caps = reread(r.Caps)
+	s.Wants = slices.CompactFunc(slices.SortedFunc(slices.Values(r.Wants), compareHash), equalHash)
+	s.Shallows = nil
+	for _, h := range slices.CompactFunc(slices.SortedFunc(slices.Values(r.Shallows), compareHash), equalHash) {
+		if !h.IsZero() {
+			s.Shallows = append(s.Shallows, h)
+		}
+	}
+	return &s
+}
+
+func compareHash(a, b plumbing.Hash) int { return bytes.Compare(a[:], b[:]) }
+func equalHash(a, b plumbing.Hash) bool  { return a == b }
+
+// CheckUploadRequest checks the request to upload-pack in data.
+func CheckUploadRequest(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		if err := checkPkts(data); err != nil {
+			return err
+		}
+		pkts, _ := readPkts(data, maxPacket)
+		want, ok := readRequest(pkts)
+		ur := packp.NewUploadRequest()
+		err := ur.Decode(bytes.NewReader(data))
+		if ok && err != nil {
+			return fmt.Errorf("Decode: %v, but the request reads as %+v", err, want)
+		}
+		if err != nil {
+			return nil
+		}
+		got := fromUploadRequest(ur)
+		if ok && !got.equal(want) {
+			return fmt.Errorf("the request decodes as\n%+v\nnot\n%+v", got, want)
+		}
+		got = got.sorted()
+		var out bytes.Buffer
+		if err := ur.Encode(&out); err != nil {
+			return fmt.Errorf("Encode: %v", err)
+		}
+		again := packp.NewUploadRequest()
+		if err := again.Decode(bytes.NewReader(out.Bytes())); err != nil {
+			return fmt.Errorf("the request encodes as %q, which decodes with error %v", out.Bytes(), err)
+		}
+		if r := fromUploadRequest(again); !r.equal(got) {
+			return fmt.Errorf("the request\n%+v\nencodes as %q, which decodes as\n%+v", got, out.Bytes(), r)
+		}
+		return nil
+	})
+}
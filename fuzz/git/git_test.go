@@ -0,0 +1,41 @@
+package git
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gitsrc"
+)
+
+func FuzzAdvertisement(f *testing.F) {
+	for _, data := range gen.Sample("git/advertisement", ".pkt", 32) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckAdvertisement(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzUploadRequest(f *testing.F) {
+	for _, data := range gen.Sample("git/upload-request", ".pkt", 32) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckUploadRequest(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzPack(f *testing.F) {
+	for _, data := range gen.Sample("git/pack", ".pack", 32) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPack(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
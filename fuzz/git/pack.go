@@ -0,0 +1,487 @@
+package git
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/packfile"
+	"github.com/go-git/go-git/v5/storage/memory"
+)
+
+// maxObject bounds the size of an object, inflated or resolved.
+const maxObject = 16 << 20
+
+// Object types, as a pack's object headers write them.
+const (
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+var typeNames = [...]string{1: "commit", 2: "tree", 3: "blob", 4: "tag"}
+
+// An entry is an object's entry in a pack, as it is written.
+type entry struct {
+	offset int
+	typ    int
+	// size is the size the header gives, and length the size go-git
+	// reads from it, which it does not bound.
+	size   uint64
+	length int64
+	base   int      // the offset of an offset delta's base
+	ref    [20]byte // the id of a reference delta's base
+	data   []byte
+}
+
+// An object is an object of a pack, its deltas resolved.
+type object struct {
+	typ  int
+	data []byte
+	size uint64 // the size its id is hashed with
+}
+
+// readEntries reads the entries of the pack in data, by git's pack
+// format: "PACK", version 2 or 3, the count of entries, then each entry,
+// a header of its type and size, for a delta the offset back to its base
+// or its base's id, and its data compressed with zlib, then the SHA-1 of
+// all that. An entry of one of the reserved types, 0 and 5, or whose
+// data does not inflate to its size, a checksum that is wrong and bytes
+// after it make the pack invalid but are read past; the first entry that
+// cannot be read at all ends the entries. It also returns the size
+// go-git reads from each header, that entry's too.
+func readEntries(data []byte) (entries []*entry, lengths []int64, valid bool) {
+	if len(data) < 12 || string(data[:4]) != "PACK" {
+		return nil, nil, false
+	}
+	if v := binary.BigEndian.Uint32(data[4:]); v != 2 && v != 3 {
+		return nil, nil, false
+	}
+	count := binary.BigEndian.Uint32(data[8:])
+	at := map[int]bool{}
+	pos := 12
+	valid = true
+	for range count {
+		e := &entry{offset: pos}
+		if pos >= len(data) {
+			return entries, lengths, false
+		}
+		c := data[pos]
+		pos++
+		e.typ = int(c>>4) & 7
+		e.size, e.length = uint64(c&0x0f), int64(c&0x0f)
+		for shift := 4; c&0x80 != 0; shift += 7 {
+			if pos >= len(data) {
+				return entries, lengths, false
+			}
+			if shift > 57 {
+				valid = false
+			}
+			c = data[pos]
+			pos++
+			e.size += uint64(c&0x7f) << shift
+			e.length += int64(c&0x7f) << shift
+		}
+		lengths = append(lengths, e.length)
+		switch e.typ {
+		case 0, 5:
+			valid = false
+		case typeOfsDelta:
+			var back int
+			for i := 0; ; i++ {
+				if pos >= len(data) || back >= 1<<48 {
+					return entries, lengths, false
+				}
+				c = data[pos]
+				pos++
+				if i > 0 {
+					back++
+				}
+				back = back<<7 | int(c&0x7f)
+				if c&0x80 == 0 {
+					break
+				}
+			}
+			e.base = e.offset - back
+			if back == 0 || !at[e.base] {
+				return entries, lengths, false
+			}
+		case typeRefDelta:
+			if len(data)-pos < 20 {
+				return entries, lengths, false
+			}
+			copy(e.ref[:], data[pos:])
+			pos += 20
+		}
+		r := bytes.NewReader(data[pos:])
+		zr, err := zlib.NewReader(r)
+		if err != nil {
+			return entries, lengths, false
+		}
+		e.data, err = io.ReadAll(io.LimitReader(zr, maxObject+1))
+		if err != nil || len(e.data) > maxObject {
+			return entries, lengths, false
+		}
+		if uint64(len(e.data)) != e.size {
+			valid = false
+		}
+		pos += int(r.Size()) - r.Len()
+		at[e.offset] = true
+		entries = append(entries, e)
+	}
+	if sum := sha1.Sum(data[:pos]); !bytes.Equal(data[pos:], sum[:]) {
+		valid = false
+	}
+	return entries, lengths, valid
+}
+
+// id returns the id of o.
+func id(o *object) plumbing.Hash {
+	return sha1.Sum(append(fmt.Appendf(nil, "%s %d\x00", typeNames[o.typ], o.size), o.data...))
+}
+
+// resolve resolves the deltas of entries, in chains of any depth and
+// with reference deltas on any object in the pack, and returns the
+// objects of the pack and the id of each entry that resolved, by its
+// offset. A delta whose base is not in the pack, or that does not apply
+// to it, does not. With strict set, deltas are applied by patch, and
+// otherwise as go-git applies them, by loosePatch.
+func resolve(entries []*entry, strict bool) (map[plumbing.Hash]*object, map[int]plumbing.Hash) {
+	objects := map[plumbing.Hash]*object{}
+	byOffset := map[int]*object{}
+	ids := map[int]plumbing.Hash{}
+	failed := map[int]bool{}
+	for progress := true; progress; {
+		progress = false
+		for _, e := range entries {
+			if byOffset[e.offset] != nil || failed[e.offset] {
+				continue
+			}
+			var o *object
+			switch e.typ {
+			case typeOfsDelta, typeRefDelta:
+				base := byOffset[e.base]
+				if e.typ == typeRefDelta {
+					base = objects[e.ref]
+				}
+				if base == nil {
+					continue
+				}
+				o = &object{typ: base.typ}
+				var err error
+				if strict {
+					o.data, err = patch(base.data, e.data)
+					o.size = uint64(len(o.data))
+				} else {
+					o.data, o.size, err = loosePatch(base.data, e.data)
+				}
+				if errors.Is(err, errZeroID) {
+					// The object has no id, but its deltas apply to it.
+					byOffset[e.offset] = o
+					progress = true
+					continue
+				}
+				if err != nil {
+					failed[e.offset] = true
+					progress = true
+					continue
+				}
+			case 0, 5:
+				continue
+			default:
+				o = &object{typ: e.typ, data: e.data, size: uint64(len(e.data))}
+			}
+			byOffset[e.offset] = o
+			ids[e.offset] = id(o)
+			objects[ids[e.offset]] = o
+			progress = true
+		}
+	}
+	return objects, ids
+}
+
+// readCopy reads the offset and size of a copy instruction cmd from r.
+func readCopy(cmd byte, r *bytes.Reader) (off, n uint64, err error) {
+	for i := range 7 {
+		if cmd&(1<<i) == 0 {
+			continue
+		}
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, 0, errors.New("a copy is cut short")
+		}
+		if i < 4 {
+			off |= uint64(b) << (8 * i)
+		} else {
+			n |= uint64(b) << (8 * (i - 4))
+		}
+	}
+	if n == 0 {
+		n = 0x10000
+	}
+	return off, n, nil
+}
+
+// patch applies delta to base, by git's delta format: the sizes of base
+// and of what the delta makes, as little-endian base-128 numbers, then
+// instructions that copy from base, a size of zero being 64 KiB, or
+// insert the bytes that follow, up to 127 of them, each of which must
+// fit in what the delta makes.
+func patch(base, delta []byte) ([]byte, error) {
+	r := bytes.NewReader(delta)
+	src, err := binary.ReadUvarint(r)
+	if err != nil || src != uint64(len(base)) {
+		return nil, errors.New("the base is not the size the delta gives")
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil || size > maxObject {
+		return nil, errors.New("the delta makes an object too large")
+	}
+	out := make([]byte, 0, size)
+	for r.Len() > 0 {
+		cmd, _ := r.ReadByte()
+		switch {
+		case cmd&0x80 != 0:
+			off, n, err := readCopy(cmd, r)
+			if err != nil {
+				return nil, err
+			}
+			if off+n > uint64(len(base)) || uint64(len(out))+n > size {
+				return nil, errors.New("a copy reaches past the base or the object")
+			}
+			out = append(out, base[off:off+n]...)
+		case cmd != 0:
+			if int(cmd) > r.Len() || uint64(len(out))+uint64(cmd) > size {
+				return nil, errors.New("an insert reaches past the delta or the object")
+			}
+			out = append(out, delta[len(delta)-r.Len():][:cmd]...)
+			r.Seek(int64(cmd), io.SeekCurrent)
+		default:
+			return nil, errors.New("the reserved instruction")
+		}
+	}
+	if uint64(len(out)) != size {
+		return nil, errors.New("the delta makes an object not of the size it gives")
+	}
+	return out, nil
+}
+
+// errZeroID is the error of loosePatch for a delta go-git gives the
+// zero id.
+var errZeroID = errors.New("the delta makes an object with the zero id")
+
+// loosePatch applies delta to base as go-git does, and returns what it
+// makes and the size go-git hashes it with, the size the delta gives.
+//
+// Known: go-git ends a delta once it has made as much as it gives,
+// inserts what is left of a delta cut short, and, for a copy larger than
+// what the delta makes or from outside the base, or the reserved
+// instruction, ends with no error and the zero id; a delta's deltas then
+// apply to what it made.
+func loosePatch(base, delta []byte) ([]byte, uint64, error) {
+	r := bytes.NewReader(delta)
+	src, err := binary.ReadUvarint(r)
+	if err != nil || src != uint64(len(base)) {
+		return nil, 0, errors.New("the base is not the size the delta gives")
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, 0, errors.New("the delta gives no size")
+	}
+	var out []byte
+	for left := size; ; {
+		cmd, err := r.ReadByte()
+		if err != nil || len(out) > maxObject {
+			return nil, 0, errors.New("the delta ends before the object is made")
+		}
+		switch {
+		case cmd&0x80 != 0:
+			off, n, err := readCopy(cmd, r)
+			if err != nil {
+				return nil, 0, err
+			}
+			if n > size || off+n > src {
+				return out, size, errZeroID
+			}
+			out = append(out, base[off:off+n]...)
+			left -= n
+		case cmd != 0:
+			if uint64(cmd) > size {
+				return nil, 0, errors.New("an insert is larger than the object")
+			}
+			n := min(int(cmd), r.Len())
+			out = append(out, delta[len(delta)-r.Len():][:n]...)
+			r.Seek(int64(n), io.SeekCurrent)
+			left -= uint64(cmd)
+		default:
+			return out, size, errZeroID
+		}
+		if left == 0 {
+			return out, size, nil
+		}
+	}
+}
+
+// An observer records the objects a Parser finds.
+type observer struct {
+	typ     plumbing.ObjectType
+	objects map[plumbing.Hash]plumbing.ObjectType
+}
+
+func (o *observer) OnHeader(uint32) error { return nil }
+func (o *observer) OnInflatedObjectHeader(t plumbing.ObjectType, _, _ int64) error {
+	o.typ = t
+	return nil
+}
+func (o *observer) OnInflatedObjectContent(h plumbing.Hash, _ int64, _ uint32, _ []byte) error {
+	o.objects[h] = o.typ
+	return nil
+}
+func (o *observer) OnFooter(plumbing.Hash) error { return nil }
+
+// parseSeekable parses data with a Parser from a seekable source, and
+// returns the ids and types of the objects it finds.
+func parseSeekable(data []byte) (map[plumbing.Hash]plumbing.ObjectType, error) {
+	ob := &observer{objects: map[plumbing.Hash]plumbing.ObjectType{}}
+	p, err := packfile.NewParser(packfile.NewScanner(bytes.NewReader(data)), ob)
+	if err != nil {
+		return nil, err
+	}
+	_, err = p.Parse()
+	return ob.objects, err
+}
+
+// parseStored parses data with a Parser from a source that cannot seek,
+// into storage, and returns the objects it stores.
+func parseStored(data []byte) (map[plumbing.Hash]*object, error) {
+	st := memory.NewStorage()
+	p, err := packfile.NewParserWithStorage(packfile.NewScanner(struct{ io.Reader }{bytes.NewReader(data)}), st)
+	if err != nil {
+		return nil, err
+	}
+	_, perr := p.Parse()
+	objects := map[plumbing.Hash]*object{}
+	for h, eo := range st.ObjectStorage.Objects {
+		r, err := eo.Reader()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		objects[h] = &object{typ: int(eo.Type()), data: b}
+	}
+	return objects, perr
+}
+
+// CheckPack checks the packfile in data.
+func CheckPack(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		// Known: go-git makes room for as many objects as the pack's
+		// header counts before reading any, so a count of more than
+		// the pack could hold is not given to it.
+		if len(data) >= 12 && binary.BigEndian.Uint32(data[8:]) > uint32(len(data)) {
+			return nil
+		}
+		entries, lengths, valid := readEntries(data)
+		// Known: go-git grows a buffer to the size an object's header
+		// gives before reading it, so a header that gives more than
+		// the pack could hold is not given to it.
+		for _, n := range lengths {
+			if n < 0 || n > maxObject {
+				return nil
+			}
+		}
+		// Known: go-git takes a delta to hold an instruction, so it
+		// rejects one that makes an empty object.
+		for _, e := range entries {
+			if e.typ == typeOfsDelta || e.typ == typeRefDelta {
+				r := bytes.NewReader(e.data)
+				binary.ReadUvarint(r)
+				if size, err := binary.ReadUvarint(r); err == nil && size == 0 {
+					valid = false
+				}
+			}
+		}
+		want, ids := resolve(entries, true)
+		// Known: go-git reads packs of version 2 only.
+		valid = valid && len(ids) == len(entries) && binary.BigEndian.Uint32(data[4:]) == 2
+		// Known: go-git indexes the objects of a pack by id as it reads
+		// them, so it fails on a pack that holds an object twice, and
+		// on some whose reference deltas have deltas for bases, which
+		// it has no id for until it resolves them.
+		deltas := map[plumbing.Hash]bool{}
+		for _, e := range entries {
+			if e.typ == typeOfsDelta || e.typ == typeRefDelta {
+				deltas[ids[e.offset]] = true
+			}
+		}
+		for _, e := range entries {
+			if e.typ == typeRefDelta && deltas[e.ref] {
+				valid = false
+			}
+		}
+		if len(want) != len(ids) {
+			valid = false
+		}
+		seen, serr := parseSeekable(data)
+		stored, perr := parseStored(data)
+		if valid && (serr != nil || perr != nil) {
+			return fmt.Errorf("the pack holds %d objects, but parsing it fails: %v; into storage: %v", len(want), serr, perr)
+		}
+		// Known: go-git hashes an object with the size its header
+		// gives, not the size of its data, so where they differ the ids
+		// it finds are not the object's.
+		for _, e := range entries {
+			if e.size != uint64(len(e.data)) {
+				return nil
+			}
+		}
+		lenient, _ := resolve(entries, false)
+		for h, t := range seen {
+			if err := checkObject(h, t, nil, lenient); err != nil {
+				return err
+			}
+		}
+		for h, o := range stored {
+			// Known: go-git stores an empty object for a delta that
+			// does not apply.
+			if len(o.data) == 0 && lenient[h] == nil {
+				continue
+			}
+			if err := checkObject(h, plumbing.ObjectType(o.typ), o.data, lenient); err != nil {
+				return fmt.Errorf("in storage: %v", err)
+			}
+		}
+		if valid && (len(seen) != len(want) || len(stored) != len(want)) {
+			return fmt.Errorf("the pack holds %d objects, but parsing it finds %d, and %d into storage", len(want), len(seen), len(stored))
+		}
+		return nil
+	})
+}
+
+// checkObject checks that an object go-git finds, with id h, type t and,
+// if it is not nil, data, is one of objects.
+//
+// Known: go-git takes entries of the reserved types for objects of those
+// types, and a delta that copies from outside its base or holds the
+// reserved instruction for an object with the zero id.
+func checkObject(h plumbing.Hash, t plumbing.ObjectType, data []byte, objects map[plumbing.Hash]*object) error {
+	if t == plumbing.InvalidObject || t == 5 || h.IsZero() {
+		return nil
+	}
+	o := objects[h]
+	if o == nil {
+		return fmt.Errorf("parsing finds a %v %v the pack does not hold", t, h)
+	}
+	if int(t) != o.typ || data != nil && !bytes.Equal(data, o.data) {
+		return fmt.Errorf("parsing finds %v as a %v of %q, not a %s of %q", h, t, data, typeNames[o.typ], o.data)
+	}
+	return nil
+}
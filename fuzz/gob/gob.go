@@ -0,0 +1,122 @@
+// Package gob is a fuzz target for encoding/gob. CheckDecode decodes a
+// stream twice, into Nodes and discarding every value, within a budget
+// of time and memory linear in the size of the stream, past which it is
+// reported as a blowup; gob's own limits let a few bytes ask for far
+// more. What decodes into a Node must encode, and decode again.
+package gob
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Node is what streams are decoded into. Its fields are matched by name
+// with those of the stream's struct types.
+type Node struct {
+	Name string
+	N    int
+	U    uint64
+	B    bool
+	F    float64
+	C    complex128
+	Data []byte
+	Next *Node
+	Kids []*Node
+	M    map[string]*Node
+	Tags [3]string
+	Any  any
+}
+
+// Leaf and Ints are values an interface may hold.
+type (
+	Leaf struct {
+		V int
+	}
+	Ints []int
+)
+
+func init() {
+	gob.RegisterName("Leaf", Leaf{})
+	gob.RegisterName("Node", &Node{})
+	gob.RegisterName("Ints", Ints{})
+}
+
+// DefaultBudget is per byte of the stream. It allows for the 10 MiB a
+// Decoder may allocate at once for a length it has not yet checked
+// against the stream.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: time.Second, Memory: 64 << 20},
+	Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 64 << 10},
+}
+
+// CheckDecode decodes data within b. Errors decoding are expected and
+// ignored.
+func CheckDecode(data []byte, b harness.Budget) error {
+	var nodes []*Node
+	err := harness.Measure(fmt.Sprintf("decoding %d bytes", len(data)), b.For(len(data)), func() {
+		nodes = decode(data)
+		discard(data)
+	})
+	if err != nil {
+		return err
+	}
+	for _, n := range nodes {
+		if err := reencode(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decode decodes data into Nodes and returns those that decode. A value
+// of another type fails to decode, but the stream goes on after it; each
+// value takes at least a byte, which bounds the values it can hold.
+func decode(data []byte) []*Node {
+	d := gob.NewDecoder(bytes.NewReader(data))
+	var nodes []*Node
+	for range len(data) + 1 {
+		n := new(Node)
+		err := d.Decode(n)
+		if err == nil {
+			nodes = append(nodes, n)
+		} else if !typeMismatch(err) {
+			break
+		}
+	}
+	return nodes
+}
+
+// typeMismatch reports whether err is the error for a value of a type
+// that a Node cannot hold, which leaves the stream where it can go on.
+func typeMismatch(err error) bool {
+	return strings.Contains(err.Error(), "type mismatch")
+}
+
+// discard decodes data without a destination, so that every value goes
+// through the decoder's code for skipping values.
+func discard(data []byte) {
+	d := gob.NewDecoder(bytes.NewReader(data))
+	for range len(data) + 1 {
+		if d.DecodeValue(reflect.Value{}) != nil {
+			return
+		}
+	}
+}
+
+// reencode checks that n encodes to a stream that decodes.
+func reencode(n *Node) error {
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(n); err != nil {
+		return fmt.Errorf("a decoded Node does not encode: %v\n%+v", err, n)
+	}
+	if err := gob.NewDecoder(&b).Decode(new(Node)); err != nil {
+		return fmt.Errorf("a decoded Node encodes to a stream that does not decode: %v\n%+v", err, n)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package gob
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gobsrc"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, src := range gen.Sample("gob/*", ".gob", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckDecode(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,164 @@
+// Package html is a fuzz target for golang.org/x/net/html. CheckParse
+// parses a document, renders the tree and parses the HTML it renders to:
+// the second tree must be the first, and rendering and parsing it again
+// must give it back once more. Render only promises that for trees the
+// parser could have built from markup that spells them out, so a tree
+// with an element nested in one that its start tag would have closed, as
+// misnested markup, foster parenting and the adoption agency can leave,
+// is only checked after the round trip has straightened it out. Parse
+// must not fail on any input but one that nests elements past its limit,
+// and must not give a void element children.
+package html
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"golang.org/x/net/html"
+)
+
+// Timeout bounds checking one input.
+var Timeout = 10 * time.Second
+
+// rounds is how many times CheckParse renders and parses a tree.
+const rounds = 2
+
+// errDeep is the error Parse returns for a document that nests more
+// elements than it keeps open.
+const errDeep = "html: open stack of elements exceeds 512 nodes"
+
+// errSkip stops a check at a tree that cannot be rendered or parsed for
+// a reason that is not a bug.
+var errSkip = errors.New("skip")
+
+// CheckParse checks the round trip of the document in data.
+func CheckParse(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		err := checkParse(data)
+		if err == errSkip {
+			return nil
+		}
+		return err
+	})
+}
+
+func checkParse(data []byte) error {
+	doc, err := parse(data)
+	if err != nil {
+		return err
+	}
+	src := data
+	for range rounds {
+		out, err := render(doc)
+		if err != nil {
+			return err
+		}
+		again, err := parse(out)
+		if err != nil {
+			return err
+		}
+		if q := quirks(src); q == quirks(out) && malformed(doc, q) == "" {
+			want, got := dump(doc), dump(again)
+			if want != got {
+				return fmt.Errorf("parsing\n%q\nrendered from\n%q\ngives another tree:\n%s", out, src, diff(want, got))
+			}
+		}
+		src, doc = out, again
+	}
+	return nil
+}
+
+// parse parses data, stopping at a document nested past the parser's
+// limit. Known: Parse recovers what panics while it parses, runtime
+// errors and all, and returns it as an error, so any other error from
+// reading data is a panic in the parser.
+func parse(data []byte) (*html.Node, error) {
+	doc, err := html.Parse(bytes.NewReader(data))
+	if err != nil {
+		if err.Error() == errDeep {
+			return nil, errSkip
+		}
+		return nil, fmt.Errorf("Parse: %v\n%q", err, data)
+	}
+	if n := voidParent(doc); n != nil {
+		return nil, fmt.Errorf("Parse gives the void element <%s> children, parsing\n%q", n.Data, data)
+	}
+	return doc, nil
+}
+
+// render renders doc, stopping at a tree Render rejects for a reason that
+// is not a bug. Known: Render takes any element named like an HTML void
+// element for one, foreign ones too, which can have children, and
+// refuses to render them.
+func render(doc *html.Node) ([]byte, error) {
+	var b bytes.Buffer
+	if err := html.Render(&b, doc); err != nil {
+		if foreignVoid(doc) {
+			return nil, errSkip
+		}
+		return nil, fmt.Errorf("Render: %v", err)
+	}
+	return b.Bytes(), nil
+}
+
+// voidElements are the HTML elements that have no content.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"keygen": true, "link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+}
+
+// voidParent returns the first HTML void element under n with children.
+func voidParent(n *html.Node) *html.Node {
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && d.Namespace == "" && voidElements[d.Data] && d.FirstChild != nil {
+			return d
+		}
+	}
+	return nil
+}
+
+// foreignVoid reports whether n has an SVG or MathML element named like
+// an HTML void element with children.
+func foreignVoid(n *html.Node) bool {
+	for d := range n.Descendants() {
+		if d.Type == html.ElementNode && d.Namespace != "" && voidElements[d.Data] && d.FirstChild != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// quirks reports whether the parser reads src in quirks mode, in which a
+// table does not close a paragraph. Known: which mode the parser picks
+// turns on how a doctype is written, not only on what the tree keeps of
+// it, so that "<!DOCTYPE html x>" and "<!DOCTYPE HTML>" put a document in
+// quirks mode and render as "<!DOCTYPE html>", which does not; the mode
+// is found by asking the parser about a paragraph and table after the
+// doctype src starts with.
+func quirks(src []byte) bool {
+	z := html.NewTokenizer(bytes.NewReader(src))
+	for {
+		switch z.Next() {
+		case html.CommentToken:
+			continue
+		case html.TextToken:
+			if strings.Trim(string(z.Text()), whitespace) == "" {
+				continue
+			}
+		case html.DoctypeToken:
+			doc, err := html.Parse(strings.NewReader(string(z.Raw()) + "<p><table>"))
+			if err != nil {
+				return true
+			}
+			p := doc.LastChild.LastChild.FirstChild
+			return p != nil && p.FirstChild != nil
+		}
+		return true
+	}
+}
+
+const whitespace = " \t\n\f\r"
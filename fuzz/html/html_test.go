@@ -0,0 +1,19 @@
+package html
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/htmlsrc"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, src := range gen.Sample("html/*", ".html", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckParse(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
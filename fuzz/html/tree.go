@@ -0,0 +1,358 @@
+package html
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// dump writes the tree under n out a node to a line, in the manner of the
+// html5lib tests, with what Render cannot keep of it smoothed over.
+func dump(n *html.Node) string {
+	var b strings.Builder
+	dumpNode(&b, n, 0)
+	return b.String()
+}
+
+func dumpNode(b *strings.Builder, n *html.Node, depth int) {
+	indent := strings.Repeat("  ", depth)
+	switch n.Type {
+	case html.DocumentNode:
+		b.WriteString("#document\n")
+	case html.DoctypeNode:
+		// Render leaves out an empty identifier.
+		fmt.Fprintf(b, "%s<!DOCTYPE %q", indent, n.Data)
+		for _, a := range n.Attr {
+			if a.Val != "" {
+				fmt.Fprintf(b, " %s=%q", a.Key, a.Val)
+			}
+		}
+		b.WriteString(">\n")
+	case html.ElementNode:
+		name := n.Data
+		if n.Namespace != "" {
+			name = n.Namespace + " " + name
+		}
+		fmt.Fprintf(b, "%s<%s>\n", indent, name)
+		for _, a := range n.Attr {
+			key := a.Key
+			if a.Namespace != "" {
+				key = a.Namespace + " " + key
+			}
+			fmt.Fprintf(b, "%s  %s=%q\n", indent, key, a.Val)
+		}
+	case html.TextNode:
+		// Adjacent text, which foster parenting and templates can leave,
+		// reads back as one node.
+		if n.PrevSibling != nil && n.PrevSibling.Type == html.TextNode {
+			return
+		}
+		text := n.Data
+		for s := n.NextSibling; s != nil && s.Type == html.TextNode; s = s.NextSibling {
+			text += s.Data
+		}
+		if n.PrevSibling == nil && n.Parent != nil && n.Parent.Type == html.ElementNode {
+			if text = leading(n.Parent, text); text == "" {
+				break
+			}
+		}
+		fmt.Fprintf(b, "%s%q\n", indent, text)
+	case html.CommentNode:
+		// Known: a comment left open at the end of the document keeps the
+		// carriage returns in it, where a closed one has them read as
+		// newlines.
+		text := strings.ReplaceAll(strings.ReplaceAll(n.Data, "\r\n", "\n"), "\r", "\n")
+		fmt.Fprintf(b, "%s<!-- %q -->\n", indent, text)
+	default:
+		fmt.Fprintf(b, "%snode of type %d %q\n", indent, n.Type, n.Data)
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		dumpNode(b, c, depth+1)
+	}
+}
+
+// leading returns the text that starts the element n as the tree keeps
+// it after a round trip. Known: the parser drops a carriage return at the
+// start of a pre, listing or textarea, and a newline after it, meant for
+// a CRLF that its tokenizer has already made a newline, so one written as
+// "&#13;" goes;
+// and Render writes a newline after the start tag of an element of those
+// names in SVG or MathML as well, where the parser keeps it, so each
+// round trip adds one.
+func leading(n *html.Node, text string) string {
+	switch n.Data {
+	case "pre", "listing", "textarea":
+		if n.Namespace == "" {
+			if t, ok := strings.CutPrefix(text, "\r"); ok {
+				return strings.TrimPrefix(t, "\n")
+			}
+			return text
+		}
+		return strings.TrimLeft(text, "\n")
+	}
+	return text
+}
+
+// diff returns the lines around the first at which want and got differ.
+func diff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	i := 0
+	for i < len(w) && i < len(g) && w[i] == g[i] {
+		i++
+	}
+	from := max(0, i-4)
+	return fmt.Sprintf("%s\n--- want\n%s\n--- got\n%s",
+		strings.Join(w[from:i], "\n"), strings.Join(w[i:min(len(w), i+4)], "\n"), strings.Join(g[i:min(len(g), i+4)], "\n"))
+}
+
+// closesP are the elements whose start tags close a paragraph in button
+// scope; a table does only outside quirks mode.
+var closesP = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "center": true, "details": true, "dialog": true,
+	"dir": true, "div": true, "dl": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"header": true, "hgroup": true, "main": true, "menu": true, "nav": true, "ol": true, "p": true, "search": true,
+	"section": true, "summary": true, "ul": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true,
+	"h6": true, "pre": true, "listing": true, "form": true, "plaintext": true, "xmp": true, "hr": true, "li": true,
+	"dd": true, "dt": true, "table": true,
+}
+
+// headings are the elements whose start tags close one another.
+var headings = map[string]bool{"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true}
+
+// scope are the HTML elements that bound the default scope, which the
+// parser looks for an open element in.
+var scope = map[string]bool{
+	"applet": true, "caption": true, "html": true, "table": true, "td": true, "th": true, "marquee": true,
+	"object": true, "template": true,
+}
+
+// markers are the elements that push a marker onto the list of active
+// formatting elements, past which a link does not look for another.
+var markers = map[string]bool{
+	"applet": true, "caption": true, "marquee": true, "object": true, "td": true, "th": true, "template": true,
+}
+
+// special are the elements of the special category, at which the parser
+// stops looking for a list item to close, but for address, div and p.
+var special = map[string]bool{
+	"applet": true, "area": true, "article": true, "aside": true, "base": true, "basefont": true, "bgsound": true,
+	"blockquote": true, "body": true, "br": true, "button": true, "caption": true, "center": true, "col": true,
+	"colgroup": true, "dd": true, "details": true, "dir": true, "dl": true, "dt": true, "embed": true,
+	"fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true, "frame": true,
+	"frameset": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "head": true,
+	"header": true, "hgroup": true, "hr": true, "html": true, "iframe": true, "img": true, "input": true,
+	"keygen": true, "li": true, "link": true, "listing": true, "main": true, "marquee": true, "menu": true,
+	"meta": true, "nav": true, "noembed": true, "noframes": true, "noscript": true, "object": true, "ol": true,
+	"param": true, "plaintext": true, "pre": true, "script": true, "search": true, "section": true,
+	"select": true, "source": true, "style": true, "summary": true, "table": true, "tbody": true, "td": true,
+	"template": true, "textarea": true, "tfoot": true, "th": true, "thead": true, "title": true, "tr": true,
+	"track": true, "ul": true, "wbr": true, "xmp": true,
+}
+
+// literal are the elements whose text Render writes as it is, so that
+// an element in one reads back as text.
+var literal = map[string]bool{
+	"iframe": true, "noembed": true, "noframes": true, "noscript": true, "plaintext": true, "script": true,
+	"style": true, "xmp": true,
+}
+
+// tableParts are the elements that make up a table, and the elements each
+// can hold.
+var tableParts = map[string]map[string]bool{
+	"table": {"caption": true, "colgroup": true, "tbody": true, "thead": true, "tfoot": true, "tr": true, "template": true, "script": true, "style": true, "form": true, "input": true},
+	"tbody": {"tr": true, "template": true, "script": true, "style": true, "form": true, "input": true},
+	"thead": {"tr": true, "template": true, "script": true, "style": true, "form": true, "input": true},
+	"tfoot": {"tr": true, "template": true, "script": true, "style": true, "form": true, "input": true},
+	"tr":    {"td": true, "th": true, "template": true, "script": true, "style": true, "form": true, "input": true},
+}
+
+// ruby are the ruby annotation elements, whose start tags close the
+// elements with implied end tags, but for an rtc, which only an rb or rtc
+// closes.
+var ruby = map[string]bool{"rb": true, "rp": true, "rt": true, "rtc": true}
+
+// implied are the elements with implied end tags.
+var implied = map[string]bool{
+	"dd": true, "dt": true, "li": true, "optgroup": true, "option": true, "p": true, "rb": true, "rp": true,
+	"rt": true, "rtc": true,
+}
+
+// integration reports whether n is an SVG or MathML element that may
+// hold HTML, which bounds every scope.
+func integration(n *html.Node) bool {
+	switch n.Namespace {
+	case "math":
+		return textIntegration(n) || n.Data == "annotation-xml"
+	case "svg":
+		switch n.Data {
+		case "foreignObject", "desc", "title":
+			return true
+		}
+	}
+	return false
+}
+
+// textIntegration reports whether n is a MathML element holding text,
+// and any HTML element but mglyph and malignmark.
+func textIntegration(n *html.Node) bool {
+	if n.Namespace != "math" {
+		return false
+	}
+	switch n.Data {
+	case "mi", "mo", "mn", "ms", "mtext":
+		return true
+	}
+	return false
+}
+
+// holdsHTML reports whether n is an SVG or MathML element the parser
+// reads HTML in: an integration point, or an annotation-xml element
+// whose encoding is HTML.
+func holdsHTML(n *html.Node) bool {
+	if n.Namespace == "math" && n.Data == "annotation-xml" {
+		for _, a := range n.Attr {
+			if a.Namespace == "" && a.Key == "encoding" {
+				return strings.EqualFold(a.Val, "text/html") || strings.EqualFold(a.Val, "application/xhtml+xml")
+			}
+		}
+		return false
+	}
+	return integration(n)
+}
+
+// within returns the nearest ancestor of n that is an HTML element
+// matching name, looking no further than an element in stop or one that
+// holds HTML in foreign content.
+func within(n *html.Node, name func(string) bool, stop map[string]bool, extra ...string) *html.Node {
+	for p := n.Parent; p != nil && p.Type == html.ElementNode; p = p.Parent {
+		if p.Namespace == "" && name(p.Data) {
+			return p
+		}
+		if integration(p) || p.Namespace == "" && (stop[p.Data] || contains(extra, p.Data)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func is(names ...string) func(string) bool {
+	return func(name string) bool { return contains(names, name) }
+}
+
+// malformed describes the first element of the tree under doc, parsed in
+// quirks mode or not, that its markup could not have put where it is,
+// because its start tag closes an element it is in, or "" if there is
+// none. Render writes such a tree as it is, and the parser reads it back
+// another way.
+func malformed(doc *html.Node, quirks bool) string {
+	forms := 0
+	for n := range doc.Descendants() {
+		if n.Type == html.DoctypeNode && !representable(n) {
+			return fmt.Sprintf("doctype %q %v", n.Data, n.Attr)
+		}
+		if n.Type != html.ElementNode || n.Namespace != "" {
+			continue
+		}
+		parent := ""
+		if p := n.Parent; p != nil && p.Type == html.ElementNode {
+			if p.Namespace != "" && !holdsHTML(p) || textIntegration(p) && (n.Data == "mglyph" || n.Data == "malignmark") {
+				// Known: the parser reopens formatting elements, and
+				// foster parents elements, into SVG and MathML that
+				// reads them as its own.
+				return fmt.Sprintf("<%s> in <%s %s>", n.Data, p.Namespace, p.Data)
+			}
+			if p.Namespace == "" {
+				parent = p.Data
+			}
+		}
+		switch name := n.Data; {
+		case name == "html" && !hasBody(n):
+			// Known: a template left open in the head at the end of the
+			// document leaves the document without a body, where the
+			// parser ought to close it and go on to make one.
+			return "<html> without a <body>"
+		case literal[parent]:
+			// Known: the parser reopens formatting elements in a plaintext
+			// element, whose content Render writes as it is.
+			return fmt.Sprintf("<%s> in <%s>", name, parent)
+		case tableParts[parent] != nil && !tableParts[parent][name]:
+			// Known: the parser reopens formatting elements in a table
+			// where content belongs before it.
+			return fmt.Sprintf("<%s> in <%s>", name, parent)
+		case closesP[name] && (name != "table" || !quirks) && within(n, is("p"), scope, "button") != nil:
+			return fmt.Sprintf("<%s> in <p>", name)
+		case headings[name] && n.Parent != nil && n.Parent.Namespace == "" && headings[n.Parent.Data]:
+			return fmt.Sprintf("<%s> in <%s>", name, n.Parent.Data)
+		case name == "a" && within(n, is("a"), markers) != nil:
+			return "<a> in <a>"
+		case (name == "nobr" || name == "button") && within(n, is(name), scope) != nil:
+			return fmt.Sprintf("<%s> in <%s>", name, name)
+		case name == "li" && within(n, is("li"), special, "address", "div", "p") != nil:
+			return "<li> in <li>"
+		case (name == "dd" || name == "dt") && within(n, is("dd", "dt"), special, "address", "div", "p") != nil:
+			return fmt.Sprintf("<%s> in a definition", name)
+		case (name == "option" || name == "optgroup") && parent == "option":
+			return fmt.Sprintf("<%s> in <option>", name)
+		case ruby[name] && implied[parent] && (parent != "rtc" || name == "rb" || name == "rtc") && within(n, is("ruby"), scope) != nil:
+			return fmt.Sprintf("<%s> in <%s>", name, parent)
+		case name == "form":
+			// Known: the parser keeps a pointer to the form it last opened,
+			// which a form in a table leaves set, and ignores a form start
+			// tag while it is.
+			if forms++; forms > 1 {
+				return "a second <form>"
+			}
+		case name == "plaintext" && followed(n):
+			// Render writes nothing after a plaintext element, whose
+			// content runs to the end of the document.
+			return "content after <plaintext>"
+		}
+	}
+	return ""
+}
+
+// hasBody reports whether the html element n has a body or frameset.
+func hasBody(n *html.Node) bool {
+	for c := range n.ChildNodes() {
+		if c.Type == html.ElementNode && (c.Data == "body" || c.Data == "frameset") {
+			return true
+		}
+	}
+	return false
+}
+
+// followed reports whether any node comes after n in the document.
+func followed(n *html.Node) bool {
+	for ; n != nil; n = n.Parent {
+		if n.NextSibling != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// representable reports whether Render writes the doctype n so that it
+// parses back the same. Known: it escapes the name as it does text,
+// which a doctype has no character references in, and writes each
+// identifier between quotes whatever it holds.
+func representable(n *html.Node) bool {
+	if strings.ContainsAny(n.Data, "&'<>\"\r") {
+		return false
+	}
+	for _, a := range n.Attr {
+		if strings.Contains(a.Val, ">") || strings.Contains(a.Val, `"`) && strings.Contains(a.Val, "'") {
+			return false
+		}
+	}
+	return true
+}
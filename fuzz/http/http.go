@@ -0,0 +1,229 @@
+// Package http is a fuzz target for net/http's HTTP/1.x message parsing.
+// CheckRequest reads pipelined requests and CheckResponse pipelined
+// responses, each with its body. A message that reads must write back
+// as one that reads the same and ends where what was written ends:
+// a reader and a writer that disagree on where a message ends are what
+// request smuggling is made of.
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// maxMessages bounds the messages read from one input.
+const maxMessages = 16
+
+// framing are the headers a writer replaces with its own, and which a
+// reader rewrites to match how it framed a body; a message's framing is
+// compared through its ContentLength, TransferEncoding, Close and
+// Trailer instead.
+var framing = []string{"Content-Length", "Transfer-Encoding", "Trailer", "Connection", "User-Agent", "Host"}
+
+// CheckRequest checks the requests read from data.
+func CheckRequest(data []byte) error {
+	br := bufio.NewReader(bytes.NewReader(data))
+	for range maxMessages {
+		req, err := http.ReadRequest(br)
+		if err != nil {
+			return nil
+		}
+		body, err := readBody(req.Body, len(data))
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return nil
+		}
+		if err := checkRequest(req, body); err != nil {
+			return err
+		}
+		if req.Close {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readBody reads r to the end, which must come within n bytes, the
+// length of the data it was read from. It returns nil, and no error, if
+// the body is malformed or cut short.
+func readBody(r io.ReadCloser, n int) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, int64(n)+1))
+	r.Close()
+	if err != nil {
+		return nil, nil
+	}
+	if len(body) > n {
+		return nil, fmt.Errorf("read a body longer than the %d bytes of data", n)
+	}
+	return append([]byte{}, body...), nil
+}
+
+// checkRequest writes req, with its body, and reads it back.
+func checkRequest(req *http.Request, body []byte) error {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	if len(body) == 0 && req.ContentLength == 0 {
+		req.Body = nil
+	}
+	// The writer sends a User-Agent of its own unless the request has
+	// one, and an empty one stops it.
+	if _, ok := req.Header["User-Agent"]; !ok {
+		req.Header["User-Agent"] = []string{""}
+	}
+	if req.URL.Opaque != "" && !strings.HasPrefix(req.URL.Opaque, "/") {
+		// Known: Write sends a target with a scheme but no authority,
+		// such as "a:b", as its opaque part alone, which may not be a
+		// target at all.
+		return nil
+	}
+	var out bytes.Buffer
+	if err := req.Write(&out); err != nil {
+		// Known: Write rejects Host headers ReadRequest accepts, and
+		// request targets it cannot write as they were read.
+		return nil
+	}
+	br := bufio.NewReader(bytes.NewReader(out.Bytes()))
+	again, err := http.ReadRequest(br)
+	if err != nil {
+		return fmt.Errorf("a request read and written does not read back: %v\n%q", err, out.Bytes())
+	}
+	body2, err := io.ReadAll(again.Body)
+	if err != nil {
+		return fmt.Errorf("the body of a request read and written does not read back: %v\n%q", err, out.Bytes())
+	}
+	if n := br.Buffered(); n > 0 {
+		return fmt.Errorf("%d bytes are left after a request read and written reads back:\n%q", n, out.Bytes())
+	}
+	type fields struct {
+		Method, RequestURI, Host string
+		Header, Trailer          http.Header
+		ContentLength            int64
+		TransferEncoding         []string
+		Body                     string
+	}
+	want := fields{req.Method, req.URL.RequestURI(), req.Host, header(req.Header), header(req.Trailer), req.ContentLength, req.TransferEncoding, string(body)}
+	got := fields{again.Method, again.URL.RequestURI(), again.Host, header(again.Header), header(again.Trailer), again.ContentLength, again.TransferEncoding, string(body2)}
+	if !validHost(req.Host) || strings.HasPrefix(req.Host, "[") && strings.Contains(req.Host, "%") {
+		// Known: Write converts a Host to Punycode, drops an IPv6 zone,
+		// and sends none at all for a Host with bytes a Host may not
+		// have.
+		want.Host = got.Host
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("a request read and written reads back differently:\n%+v\nnot\n%+v\n%q", got, want, out.Bytes())
+	}
+	return nil
+}
+
+// CheckResponse checks the responses read from data, as replies to GET
+// requests.
+func CheckResponse(data []byte) error {
+	br := bufio.NewReader(bytes.NewReader(data))
+	for range maxMessages {
+		resp, err := http.ReadResponse(br, nil)
+		if err != nil {
+			return nil
+		}
+		body, err := readBody(resp.Body, len(data))
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return nil
+		}
+		if err := checkResponse(resp, body); err != nil {
+			return err
+		}
+		if resp.Close {
+			return nil
+		}
+	}
+	return nil
+}
+
+// checkResponse writes resp, with its body, and reads it back.
+func checkResponse(resp *http.Response, body []byte) error {
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if resp.StatusCode/100 == 1 || resp.StatusCode == 204 || resp.StatusCode == 304 {
+		// Known: ReadResponse reports a chunked Transfer-Encoding, and
+		// the trailers it declares, for a status that has no body, and
+		// Write then writes the last chunk of an empty body, which
+		// ReadResponse reads as the start of the next response.
+		resp.TransferEncoding, resp.Trailer = nil, nil
+	}
+	var out bytes.Buffer
+	if err := resp.Write(&out); err != nil {
+		// Known: Write rejects trailer names ReadResponse accepts, such
+		// as a Trailer header's folded into one with a space.
+		return nil
+	}
+	br := bufio.NewReader(bytes.NewReader(out.Bytes()))
+	again, err := http.ReadResponse(br, nil)
+	if err != nil {
+		return fmt.Errorf("a response read and written does not read back: %v\n%q", err, out.Bytes())
+	}
+	body2, err := io.ReadAll(again.Body)
+	if err != nil {
+		return fmt.Errorf("the body of a response read and written does not read back: %v\n%q", err, out.Bytes())
+	}
+	if n := br.Buffered(); n > 0 {
+		return fmt.Errorf("%d bytes are left after a response read and written reads back:\n%q", n, out.Bytes())
+	}
+	type fields struct {
+		StatusCode, ProtoMajor, ProtoMinor int
+		Header, Trailer                    http.Header
+		Body                               string
+	}
+	want := fields{resp.StatusCode, resp.ProtoMajor, resp.ProtoMinor, header(resp.Header), header(resp.Trailer), string(body)}
+	got := fields{again.StatusCode, again.ProtoMajor, again.ProtoMinor, header(again.Header), header(again.Trailer), string(body2)}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("a response read and written reads back differently:\n%+v\nnot\n%+v\n%q", got, want, out.Bytes())
+	}
+	return nil
+}
+
+// header returns h as Write writes it: without the framing headers or
+// those whose names are not tokens, and with values trimmed, which an
+// obsolete line folding can leave ending in a space. It returns nil for
+// no headers.
+func header(h http.Header) http.Header {
+	var out http.Header
+	for k, vs := range h {
+		if slices.Contains(framing, k) || k == "" || strings.IndexFunc(k, func(r rune) bool { return !isToken(r) }) >= 0 {
+			continue
+		}
+		if out == nil {
+			out = http.Header{}
+		}
+		for _, v := range vs {
+			out[k] = append(out[k], textproto.TrimString(v))
+		}
+	}
+	return out
+}
+
+// isToken reports whether r may be part of a token, such as a header
+// name.
+func isToken(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
+
+// validHost reports whether h has only bytes Write lets through in a
+// Host header.
+func validHost(h string) bool {
+	for i := range len(h) {
+		c := h[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!$%&'()*+,-.:;=[]_~", c) >= 0) {
+			return false
+		}
+	}
+	return true
+}
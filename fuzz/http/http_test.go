@@ -0,0 +1,30 @@
+package http
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/httpsrc"
+)
+
+func FuzzReadRequest(f *testing.F) {
+	for _, src := range gen.Sample("http/request", ".http", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckRequest(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzReadResponse(f *testing.F) {
+	for _, src := range gen.Sample("http/response", ".http", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckResponse(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,320 @@
+// Package http2 is a fuzz target for golang.org/x/net/http2's framer and
+// its HPACK decoder. CheckFrames reads a client's connection the way a
+// server does, the preface and then frames, with header blocks decoded
+// across their CONTINUATION frames; a header list may come to no more
+// than the limit set for it, and the frames read must write back as
+// frames that read the same. CheckHPACK decodes one header block whole
+// and in two parts, which must give the same fields, and the fields must
+// encode to a block that decodes to them again.
+package http2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Timeout bounds reading one input.
+var Timeout = 10 * time.Second
+
+const (
+	// maxFrames bounds the frames read from one input.
+	maxFrames = 4096
+	// maxHeaderListSize is the limit on a header list, low enough that
+	// the generated header blocks reach it now and then.
+	maxHeaderListSize = 16 << 10
+	// tableSize is the dynamic table size of a decoder, as a server
+	// starts with.
+	tableSize = 4096
+)
+
+// CheckFrames checks the frames read from data, which must start with
+// the client preface.
+func CheckFrames(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkFrames(data)
+	})
+}
+
+func checkFrames(data []byte) error {
+	rest, ok := bytes.CutPrefix(data, []byte(http2.ClientPreface))
+	if !ok {
+		return nil
+	}
+	fr := newFramer(nil, bytes.NewReader(rest))
+	var out bytes.Buffer
+	w := http2.NewFramer(&out, nil)
+	w.AllowIllegalWrites = true
+	enc := hpack.NewEncoder(&out)
+	var want []frame
+	for range maxFrames {
+		f, err := fr.ReadFrame()
+		var se http2.StreamError
+		if errors.As(err, &se) {
+			continue
+		}
+		if err != nil {
+			break
+		}
+		if _, ok := f.(*http2.ContinuationFrame); ok {
+			// Known: the framer returns the CONTINUATION frames of a
+			// block whose HEADERS frame it rejected with a stream error
+			// on their own, and writing them alone starts no block.
+			continue
+		}
+		if mh, ok := f.(*http2.MetaHeadersFrame); ok {
+			n := uint32(0)
+			for _, hf := range mh.Fields {
+				n += hf.Size()
+			}
+			if n > maxHeaderListSize {
+				return fmt.Errorf("a header list of %d bytes is past the limit of %d", n, maxHeaderListSize)
+			}
+		}
+		fs, err := write(w, enc, &out, f)
+		if err != nil {
+			return fmt.Errorf("writing %v: %v", f, err)
+		}
+		want = append(want, fs)
+	}
+
+	written := out.Bytes()
+	fr = newFramer(nil, bytes.NewReader(written))
+	var got []frame
+	for {
+		f, err := fr.ReadFrame()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("frame %d of those read and written does not read back: %v\n%x", len(got), err, written)
+		}
+		got = append(got, summary(f))
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("frames read and written read back differently:\n%+v\nnot\n%+v\n%x", got, want, written)
+	}
+	return nil
+}
+
+// newFramer returns a framer that decodes header blocks, as a server's
+// does.
+func newFramer(w io.Writer, r io.Reader) *http2.Framer {
+	fr := http2.NewFramer(w, r)
+	fr.ReadMetaHeaders = hpack.NewDecoder(tableSize, nil)
+	fr.MaxHeaderListSize = maxHeaderListSize
+	return fr
+}
+
+// A frame is what a frame says, apart from how it was framed: its
+// padding, flags it has no use for, and how its header block was split
+// and encoded.
+type frame struct {
+	Type     http2.FrameType
+	StreamID uint32
+	Value    any
+}
+
+type (
+	payload struct {
+		End  bool
+		Data string
+	}
+	headers struct {
+		End      bool
+		Fields   []hpack.HeaderField
+		Priority http2.PriorityParam
+	}
+	ping struct {
+		Ack  bool
+		Data [8]byte
+	}
+	pushPromise struct {
+		PromiseID uint32
+		End       bool
+		Fragment  string
+	}
+	goAway struct {
+		LastStreamID uint32
+		Code         http2.ErrCode
+		Debug        string
+	}
+	continuation struct {
+		End      bool
+		Fragment string
+	}
+	unknown struct {
+		Flags   http2.Flags
+		Payload string
+	}
+)
+
+// summary returns what f says.
+func summary(f http2.Frame) frame {
+	h := f.Header()
+	fs := frame{Type: h.Type, StreamID: h.StreamID}
+	switch f := f.(type) {
+	case *http2.DataFrame:
+		fs.Value = payload{f.StreamEnded(), string(f.Data())}
+	case *http2.MetaHeadersFrame:
+		fs.Value = headers{f.StreamEnded(), f.Fields, f.Priority}
+	case *http2.PriorityFrame:
+		fs.Value = f.PriorityParam
+	case *http2.RSTStreamFrame:
+		fs.Value = f.ErrCode
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			fs.Value = "ack"
+			break
+		}
+		var settings []http2.Setting
+		f.ForeachSetting(func(s http2.Setting) error {
+			settings = append(settings, s)
+			return nil
+		})
+		fs.Value = settings
+	case *http2.PushPromiseFrame:
+		fs.Value = pushPromise{f.PromiseID, f.HeadersEnded(), string(f.HeaderBlockFragment())}
+	case *http2.PingFrame:
+		fs.Value = ping{f.IsAck(), f.Data}
+	case *http2.GoAwayFrame:
+		fs.Value = goAway{f.LastStreamID, f.ErrCode, string(f.DebugData())}
+	case *http2.WindowUpdateFrame:
+		fs.Value = f.Increment
+	case *http2.ContinuationFrame:
+		fs.Value = continuation{f.HeadersEnded(), string(f.HeaderBlockFragment())}
+	case *http2.UnknownFrame:
+		fs.Value = unknown{h.Flags, string(f.Payload())}
+	default:
+		fs.Value = fmt.Sprintf("%T", f)
+	}
+	return fs
+}
+
+// write writes f with w, encoding a header list with enc, which writes
+// to out, and returns what it says.
+func write(w *http2.Framer, enc *hpack.Encoder, out *bytes.Buffer, f http2.Frame) (frame, error) {
+	fs := summary(f)
+	id := f.Header().StreamID
+	var err error
+	switch f := f.(type) {
+	case *http2.DataFrame:
+		err = w.WriteData(id, f.StreamEnded(), f.Data())
+	case *http2.MetaHeadersFrame:
+		// The encoder writes to out, so the block is cut from its end.
+		n := out.Len()
+		for _, hf := range f.Fields {
+			if err := enc.WriteField(hf); err != nil {
+				return fs, err
+			}
+		}
+		block := bytes.Clone(out.Bytes()[n:])
+		out.Truncate(n)
+		first := block[:min(len(block), 1<<14)]
+		block = block[len(first):]
+		err = w.WriteHeaders(http2.HeadersFrameParam{
+			StreamID:      id,
+			BlockFragment: first,
+			EndStream:     f.StreamEnded(),
+			EndHeaders:    len(block) == 0,
+			Priority:      f.Priority,
+		})
+		for err == nil && len(block) > 0 {
+			frag := block[:min(len(block), 1<<14)]
+			block = block[len(frag):]
+			err = w.WriteContinuation(id, len(block) == 0, frag)
+		}
+	case *http2.PriorityFrame:
+		err = w.WritePriority(id, f.PriorityParam)
+	case *http2.RSTStreamFrame:
+		err = w.WriteRSTStream(id, f.ErrCode)
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			err = w.WriteSettingsAck()
+		} else {
+			err = w.WriteSettings(fs.Value.([]http2.Setting)...)
+		}
+	case *http2.PushPromiseFrame:
+		err = w.WritePushPromise(http2.PushPromiseParam{
+			StreamID:      id,
+			PromiseID:     f.PromiseID,
+			BlockFragment: f.HeaderBlockFragment(),
+			EndHeaders:    f.HeadersEnded(),
+		})
+	case *http2.PingFrame:
+		err = w.WritePing(f.IsAck(), f.Data)
+	case *http2.GoAwayFrame:
+		err = w.WriteGoAway(f.LastStreamID, f.ErrCode, f.DebugData())
+	case *http2.WindowUpdateFrame:
+		err = w.WriteWindowUpdate(id, f.Increment)
+	case *http2.ContinuationFrame:
+		err = w.WriteContinuation(id, f.HeadersEnded(), f.HeaderBlockFragment())
+	case *http2.UnknownFrame:
+		err = w.WriteRawFrame(f.Type, f.Flags, id, f.Payload())
+	default:
+		err = fmt.Errorf("unexpected %T", f)
+	}
+	return fs, err
+}
+
+// CheckHPACK checks the header block data decoded on a fresh table.
+func CheckHPACK(data []byte) error {
+	fields, err := decode(data)
+	if err != nil {
+		return nil
+	}
+	// A decoder keeps what it cannot yet decode for the next write, so
+	// the block split anywhere must decode the same.
+	i := len(data) / 2
+	if len(data) > 0 {
+		i = int(data[0]) % len(data)
+	}
+	parts, err := decode(data[:i], data[i:])
+	if err != nil {
+		return fmt.Errorf("a header block decodes whole but not split at %d: %v", i, err)
+	}
+	if !reflect.DeepEqual(fields, parts) {
+		return fmt.Errorf("a header block split at %d decodes to\n%v\nnot\n%v", i, parts, fields)
+	}
+
+	var out bytes.Buffer
+	enc := hpack.NewEncoder(&out)
+	for _, f := range fields {
+		if err := enc.WriteField(f); err != nil {
+			return fmt.Errorf("encoding %v: %v", f, err)
+		}
+	}
+	again, err := decode(out.Bytes())
+	if err != nil {
+		return fmt.Errorf("the fields of a header block encode to one that does not decode: %v\n%x", err, out.Bytes())
+	}
+	if !reflect.DeepEqual(fields, again) {
+		return fmt.Errorf("the fields of a header block encode to one that decodes to\n%v\nnot\n%v\n%x", again, fields, out.Bytes())
+	}
+	return nil
+}
+
+// decode decodes the header block written in parts on a fresh table.
+func decode(parts ...[]byte) ([]hpack.HeaderField, error) {
+	var fields []hpack.HeaderField
+	d := hpack.NewDecoder(tableSize, func(f hpack.HeaderField) {
+		fields = append(fields, f)
+	})
+	d.SetMaxStringLength(maxHeaderListSize)
+	for _, p := range parts {
+		if _, err := d.Write(p); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
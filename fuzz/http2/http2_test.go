@@ -0,0 +1,30 @@
+package http2
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/http2src"
+)
+
+func FuzzFramer(f *testing.F) {
+	for _, src := range gen.Sample("http2/client", ".h2", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckFrames(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzHPACK(f *testing.F) {
+	for _, src := range gen.Sample("http2/hpack", ".hpack", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckHPACK(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
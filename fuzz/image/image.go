@@ -0,0 +1,346 @@
+// Package image is a fuzz target for the image decoders of the standard
+// library and golang.org/x/image. CheckPNG, CheckJPEG, CheckGIF and
+// CheckWebP decode a file as a careful reader would: its configuration
+// first, and its pixels only if there are no more of them than a fixed
+// number, so that a file that claims a huge image is refused before it
+// is allocated. Decoding must take time and memory within a budget
+// linear in the size of the file and the number of its pixels, past
+// which it is reported as a blowup. A file must decode to an image with
+// the bounds its configuration gives, every pixel of which has a color,
+// and to the same image, or fail, read a byte at a time. A
+// PNG file that decodes must encode again to one that decodes to the
+// same pixels; the first frame of a GIF must be the image it decodes
+// to, and its frames must encode again to a GIF whose frames, delays,
+// disposals and loop count read back the same.
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing/iotest"
+	"time"
+
+	"golang.org/x/image/riff"
+	"golang.org/x/image/vp8"
+	"golang.org/x/image/vp8l"
+	"golang.org/x/image/webp"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what decoding a file may cost: the harness.Budget of
+// its bytes, plus PerPixel for each pixel of the image it holds. Pixels
+// is how many pixels an image may have for its file to be decoded at
+// all.
+type Budget struct {
+	harness.Budget
+	PerPixel harness.Cost
+	Pixels   int
+}
+
+// For returns the budget for a file of n bytes holding an image of
+// pixels pixels.
+func (b Budget) For(n, pixels int) harness.Cost {
+	return b.Budget.For(n).Plus(pixels, b.PerPixel)
+}
+
+// DefaultBudget decodes images of up to 4 megapixels, and allows for
+// the 8 bytes a pixel of a 16-bit PNG takes, twice over for one that is
+// interlaced, and for the coefficients a progressive JPEG keeps for
+// every block of every component.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	PerPixel: harness.Cost{Time: time.Microsecond, Memory: 64},
+	Pixels:   1 << 22,
+}
+
+// A format is how a package decodes a file.
+type format struct {
+	decode func(io.Reader) (image.Image, error)
+	config func(io.Reader) (image.Config, error)
+	// framed is whether a file decodes to a frame that lies within the
+	// bounds its configuration gives, rather than to all of them.
+	framed bool
+}
+
+var (
+	pngFormat  = format{decode: png.Decode, config: png.DecodeConfig}
+	jpegFormat = format{decode: jpeg.Decode, config: jpeg.DecodeConfig}
+	gifFormat  = format{decode: gif.Decode, config: gif.DecodeConfig, framed: true}
+	webpFormat = format{decode: webp.Decode, config: webp.DecodeConfig}
+)
+
+// CheckPNG decodes the PNG file in data within b, and checks that what
+// it decodes to encodes again. Errors decoding are expected and
+// ignored.
+func CheckPNG(data []byte, b Budget) error {
+	m, err := check(data, b, pngFormat, 0)
+	if err != nil || m == nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, m); err != nil {
+		return fmt.Errorf("a %T of %v decodes, but does not encode: %v", m, m.Bounds(), err)
+	}
+	back, err := png.Decode(&buf)
+	if err != nil {
+		return fmt.Errorf("a %T of %v encodes to a file that does not decode: %v", m, m.Bounds(), err)
+	}
+	if err := same(m, back); err != nil {
+		return fmt.Errorf("encoded and decoded again, %v", err)
+	}
+	return nil
+}
+
+// CheckJPEG decodes the JPEG file in data within b. Errors decoding
+// are expected and ignored.
+func CheckJPEG(data []byte, b Budget) error {
+	_, err := check(data, b, jpegFormat, 0)
+	return err
+}
+
+// CheckGIF decodes the GIF file in data within b, then decodes all its
+// frames, and checks that they encode again. Errors decoding are
+// expected and ignored.
+func CheckGIF(data []byte, b Budget) error {
+	m, err := check(data, b, gifFormat, 0)
+	if err != nil || m == nil {
+		return err
+	}
+	var g *gif.GIF
+	spent, err := harness.Spend(b.For(len(data), b.Pixels), func() {
+		g, _ = gif.DecodeAll(bytes.NewReader(data))
+	})
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		// Known: DecodeAll reads every frame, and Decode only the
+		// first, so that a file with a bad frame after it decodes, but
+		// not all of it.
+		return nil
+	}
+	// Every frame is allocated, so that all of them are budgeted.
+	pixels := 0
+	for _, f := range g.Image {
+		pixels += f.Bounds().Dx() * f.Bounds().Dy()
+	}
+	what := fmt.Sprintf("decoding %d frames of %d pixels from %d bytes", len(g.Image), pixels, len(data))
+	if err := harness.Over(what, spent, b.For(len(data), pixels)); err != nil {
+		return err
+	}
+	if err := same(m, g.Image[0]); err != nil {
+		return fmt.Errorf("decoded alone and with the other frames, the first frame differs: %v", err)
+	}
+	return rewriteGIF(g)
+}
+
+// CheckWebP decodes the WebP file in data within b. Errors decoding are
+// expected and ignored.
+func CheckWebP(data []byte, b Budget) error {
+	// Known: the configuration of an extended file gives the size of
+	// its canvas, but Decode gives its frame, which may be smaller or
+	// larger, so that the frame must be budgeted too.
+	_, err := check(data, b, webpFormat, frameSize(data))
+	return err
+}
+
+// check decodes the configuration of data with f, then its image,
+// unless it, or the frame of frame pixels the image is instead, has
+// more than b.Pixels pixels, and checks that the image has the bounds the configuration gives and
+// colors for every pixel, and that data read a byte at a time decodes
+// to the same. It returns the image, or nil if data does not decode.
+func check(data []byte, b Budget, f format, frame int) (image.Image, error) {
+	var c image.Config
+	var cerr error
+	err := harness.Measure(fmt.Sprintf("decoding the configuration of %d bytes", len(data)), b.For(len(data), 0), func() {
+		c, cerr = f.config(bytes.NewReader(data))
+	})
+	if err != nil {
+		return nil, err
+	}
+	if cerr != nil || c.Width < 0 || c.Height < 0 {
+		return nil, nil
+	}
+	pixels := max(c.Width*c.Height, frame)
+	if c.Width > b.Pixels || c.Height > b.Pixels || pixels > b.Pixels {
+		return nil, nil
+	}
+
+	limit := b.For(len(data), pixels)
+	var m image.Image
+	var derr error
+	err = harness.Measure(fmt.Sprintf("decoding %d bytes to %d pixels", len(data), pixels), limit, func() {
+		m, derr = f.decode(bytes.NewReader(data))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	again, aerr := f.decode(iotest.OneByteReader(bytes.NewReader(data)))
+	// Known: a decoder reads ahead as far as it is let, so that a file
+	// with more than one thing wrong with it may fail with either,
+	// depending on how it is read.
+	if (derr == nil) != (aerr == nil) {
+		return nil, fmt.Errorf("the file decodes with %v all at once and with %v a byte at a time", derr, aerr)
+	}
+	if derr != nil {
+		return nil, nil
+	}
+	// Known: an extended WebP file gives alpha for its canvas, but
+	// color for its frame, so that the alpha of a frame larger than
+	// its canvas runs out.
+	if m, ok := m.(*image.NYCbCrA); ok && !m.Rect.Empty() && len(m.A) < m.AStride*(m.Rect.Dy()-1)+m.Rect.Dx() {
+		return nil, nil
+	}
+	if err := same(m, again); err != nil {
+		return nil, fmt.Errorf("decoded all at once and a byte at a time, %v", err)
+	}
+
+	r := m.Bounds()
+	switch {
+	case f.framed:
+		if !r.In(image.Rect(0, 0, c.Width, c.Height)) {
+			return nil, fmt.Errorf("the configuration gives %dx%d, but the first frame is at %v", c.Width, c.Height, r)
+		}
+	case frame != 0:
+	case r.Empty() && (c.Width == 0 || c.Height == 0):
+		// An empty image may have any empty bounds.
+	case r.Min != (image.Point{}) || r.Dx() != c.Width || r.Dy() != c.Height:
+		return nil, fmt.Errorf("the configuration gives %dx%d, but the image is %v", c.Width, c.Height, r)
+	}
+	return m, walk(m, limit)
+}
+
+// walk asks m for the color of each of its pixels, which must not take
+// much longer than decoding it may.
+func walk(m image.Image, limit harness.Cost) error {
+	return harness.Run(harness.HangFactor*limit.Time, func() error {
+		r := m.Bounds()
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				m.At(x, y).RGBA()
+			}
+		}
+		return nil
+	})
+}
+
+// same checks that a and b have the same bounds and pixels.
+func same(a, b image.Image) error {
+	if a.Bounds() != b.Bounds() {
+		return fmt.Errorf("a %T of %v is a %T of %v the other time", a, a.Bounds(), b, b.Bounds())
+	}
+	r := a.Bounds()
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			ca, cb := color.RGBA64Model.Convert(a.At(x, y)), color.RGBA64Model.Convert(b.At(x, y))
+			if ca != cb {
+				return fmt.Errorf("a %T of %v has %v at (%d, %d), but %v the other time", a, r, ca, x, y, cb)
+			}
+		}
+	}
+	return nil
+}
+
+// rewriteGIF encodes the frames of g, which must decode back to the
+// same frames, with the same delays and disposals and, for an animation,
+// loop count.
+func rewriteGIF(g *gif.GIF) error {
+	for _, m := range g.Image {
+		// Known: the decoder makes the palette of a frame whose
+		// transparent index is past its end long enough to hold it,
+		// with every color it adds transparent, but the encoder writes
+		// one transparent index, and the other colors as black.
+		transparent := 0
+		for _, c := range m.Palette {
+			if _, _, _, a := c.RGBA(); a == 0 {
+				transparent++
+			}
+		}
+		if transparent > 1 {
+			return nil
+		}
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		return fmt.Errorf("%d frames on a %dx%d screen decode, but do not encode: %v", len(g.Image), g.Config.Width, g.Config.Height, err)
+	}
+	back, err := gif.DecodeAll(&buf)
+	if err != nil {
+		return fmt.Errorf("%d frames encode to a file that does not decode: %v", len(g.Image), err)
+	}
+	if len(back.Image) != len(g.Image) {
+		return fmt.Errorf("%d frames encode to a file of %d", len(g.Image), len(back.Image))
+	}
+	for i, m := range g.Image {
+		if err := same(m, back.Image[i]); err != nil {
+			return fmt.Errorf("frame %d: encoded and decoded again, %v", i, err)
+		}
+		// Known: the decoder keeps the disposal of a frame for the
+		// frames after it with no graphic control extension, which the
+		// encoder leaves out of a frame with no delay, disposal or
+		// transparency.
+		disposal := g.Disposal[i]
+		if disposal == 0 && i > 0 && back.Disposal[i] == back.Disposal[i-1] {
+			disposal = back.Disposal[i]
+		}
+		if back.Delay[i] != g.Delay[i] || back.Disposal[i] != disposal {
+			return fmt.Errorf("frame %d: delay %d and disposal %d read back as %d and %d", i, g.Delay[i], g.Disposal[i], back.Delay[i], back.Disposal[i])
+		}
+	}
+	if len(g.Image) > 1 && back.LoopCount != g.LoopCount {
+		return fmt.Errorf("loop count %d reads back as %d", g.LoopCount, back.LoopCount)
+	}
+	return nil
+}
+
+// frameSize returns the pixels of the frame of the WebP file in data,
+// or 0 if it is not an extended file or has no frame whose header
+// reads.
+func frameSize(data []byte) int {
+	form, r, err := riff.NewReader(bytes.NewReader(data))
+	if err != nil || form != (riff.FourCC{'W', 'E', 'B', 'P'}) {
+		return 0
+	}
+	extended := false
+	for {
+		id, n, chunk, err := r.Next()
+		if err != nil {
+			return 0
+		}
+		switch id {
+		case riff.FourCC{'V', 'P', '8', 'X'}:
+			extended = true
+		case riff.FourCC{'V', 'P', '8', ' '}:
+			if !extended {
+				return 0
+			}
+			d := vp8.NewDecoder()
+			d.Init(chunk, int(n))
+			fh, err := d.DecodeFrameHeader()
+			if err != nil {
+				return 0
+			}
+			return max(fh.Width*fh.Height, 1)
+		case riff.FourCC{'V', 'P', '8', 'L'}:
+			if !extended {
+				return 0
+			}
+			c, err := vp8l.DecodeConfig(chunk)
+			if err != nil {
+				return 0
+			}
+			return max(c.Width*c.Height, 1)
+		}
+	}
+}
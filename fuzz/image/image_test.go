@@ -0,0 +1,52 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/imagesrc"
+)
+
+func FuzzPNG(f *testing.F) {
+	for _, src := range gen.Sample("image/png", ".png", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPNG(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzJPEG(f *testing.F) {
+	for _, src := range gen.Sample("image/jpeg", ".jpg", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckJPEG(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzGIF(f *testing.F) {
+	for _, src := range gen.Sample("image/gif", ".gif", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckGIF(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzWebP(f *testing.F) {
+	for _, src := range gen.Sample("image/webp", ".webp", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckWebP(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,193 @@
+// Package js is a fuzz target for the JavaScript tooling written in Go:
+// github.com/evanw/esbuild, and the engines github.com/dop251/goja and
+// github.com/robertkrimen/otto. CheckScript parses a script with each
+// engine, which may reject it but not panic. What esbuild transforms it
+// must print as JavaScript that it parses again, printing it the same
+// after a second pass; and minified, as JavaScript it parses too. Lowered
+// by esbuild to ES2017, the syntax goja implements, as Go programs that
+// host goja do, the script must compile in goja, and it is then run for
+// at most RunTimeout. Running it may throw but not panic.
+package js
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	gojaparser "github.com/dop251/goja/parser"
+	"github.com/evanw/esbuild/pkg/api"
+	"github.com/geeknik/fuzzing/internal/harness"
+	ottoparser "github.com/robertkrimen/otto/parser"
+)
+
+// Timeout bounds checking one script.
+var Timeout = 10 * time.Second
+
+// RunTimeout bounds running one script in goja, after which it is
+// interrupted.
+var RunTimeout = time.Second
+
+// maxCallStackSize bounds the depth of calls in goja, which would
+// otherwise recurse until the Go stack overflows.
+const maxCallStackSize = 1000
+
+// Options of the esbuild transforms.
+var (
+	printOptions  = api.TransformOptions{Loader: api.LoaderJS}
+	minifyOptions = api.TransformOptions{Loader: api.LoaderJS, MinifyWhitespace: true, MinifySyntax: true, MinifyIdentifiers: true}
+	// Lowered scripts keep BigInt literals, which goja supports and
+	// ES2017 does not have, and are wrapped in a function so that
+	// modules compile as scripts. The wrapping turns tree shaking on,
+	// which would drop the declarations nothing uses.
+	lowerOptions = api.TransformOptions{
+		Loader:      api.LoaderJS,
+		Target:      api.ES2017,
+		Format:      api.FormatIIFE,
+		TreeShaking: api.TreeShakingFalse,
+		Supported:   map[string]bool{"bigint": true},
+	}
+)
+
+// CheckScript checks the script in data.
+func CheckScript(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return check(string(data))
+	})
+}
+
+var (
+	// maxEscape matches an escape of U+10FFFF.
+	maxEscape = regexp.MustCompile(`(?i)\\u\{0*10ffff\}`)
+	// computedAccessor matches a getter or setter with a computed name.
+	computedAccessor = regexp.MustCompile(`\b[gs]et\s*\[`)
+	// staticConstructor matches a static method named constructor.
+	staticConstructor = regexp.MustCompile(`\bstatic\s+(async\s+)?(\*\s*)?["']?constructor\b`)
+	// returnStmt matches a return statement.
+	returnStmt = regexp.MustCompile(`\breturn\b`)
+)
+
+func check(src string) error {
+	// Known: goja panics parsing an escape of U+10FFFF in a string or
+	// template, with "unexpected unicode length".
+	if !maxEscape.MatchString(src) {
+		gojaparser.ParseFile(nil, "input.js", src, 0)
+	}
+	ottoparser.ParseFile(nil, "input.js", src, 0)
+
+	out, err := transform(src, printOptions)
+	if err != nil {
+		return nil
+	}
+	out2, err := transform(out, printOptions)
+	if err != nil {
+		return fmt.Errorf("esbuild prints %q as\n%s\nwhich does not parse: %v", src, out, err)
+	}
+	if out3, err := transform(out2, printOptions); err != nil || out3 != out2 {
+		return fmt.Errorf("esbuild prints %q as\n%s\nwhich it prints as\n%s\nand then as\n%s\n(%v)", src, out, out2, out3, err)
+	}
+
+	// Known: esbuild minifies an unused object literal with a spread in
+	// it by keeping only the keys of its other properties, and keeps the
+	// get or set before the computed key of an accessor, as in
+	// ({ ...a, get [b]: 0 }), which is not JavaScript.
+	if !computedAccessor.MatchString(src) {
+		minified, err := transform(src, minifyOptions)
+		if err != nil {
+			return fmt.Errorf("esbuild prints %q but does not minify it: %v", src, err)
+		}
+		if _, err := transform(minified, printOptions); err != nil {
+			return fmt.Errorf("esbuild minifies %q as\n%s\nwhich does not parse: %v", src, minified, err)
+		}
+	}
+
+	// Known: esbuild lowers the fields of a class with a static method
+	// named constructor into that method, as if it were the constructor.
+	if staticConstructor.MatchString(src) {
+		return nil
+	}
+	low, err := transform(src, lowerOptions)
+	if err != nil {
+		return nil // syntax esbuild cannot lower, such as top-level await
+	}
+	if maxEscape.MatchString(low) {
+		return nil
+	}
+	prog, err := goja.Compile("input.js", low, false)
+	if err != nil {
+		if lenient(err) {
+			return nil
+		}
+		return fmt.Errorf("esbuild lowers %q to\n%s\nwhich goja does not compile: %v", src, low, err)
+	}
+	// Known: goja panics when a generator suspended in a try whose
+	// finally block returns is closed, by its return method or by the
+	// end of a loop or destructuring over it.
+	if strings.Contains(low, "function*") && finallyReturns(low) {
+		return nil
+	}
+	vm := goja.New()
+	vm.SetMaxCallStackSize(maxCallStackSize)
+	t := time.AfterFunc(RunTimeout, func() { vm.Interrupt("timeout") })
+	defer t.Stop()
+	vm.RunProgram(prog) // exceptions and interrupts are ordinary
+	return nil
+}
+
+// lenient reports whether goja's error compiling a script lowered by
+// esbuild is one of the errors in the script that esbuild lets through.
+func lenient(err error) bool {
+	for _, msg := range []string{
+		// Known: esbuild takes a destructuring declaration without an
+		// initializer, as var {}.
+		"Missing initializer in destructuring declaration",
+	} {
+		if strings.Contains(err.Error(), msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// transform transforms src with esbuild and returns its first error.
+func transform(src string, opts api.TransformOptions) (string, error) {
+	r := api.Transform(src, opts)
+	if len(r.Errors) > 0 {
+		e := r.Errors[0]
+		if e.Location != nil {
+			return "", fmt.Errorf("%s at %d:%d: %q", e.Text, e.Location.Line, e.Location.Column, e.Location.LineText)
+		}
+		return "", fmt.Errorf("%s", e.Text)
+	}
+	return string(r.Code), nil
+}
+
+// finallyReturns reports whether a finally block in src, as esbuild
+// prints it, has a return statement in it. Braces in strings and
+// regular expressions are counted as any other.
+func finallyReturns(src string) bool {
+	for {
+		i := strings.Index(src, "finally {")
+		if i < 0 {
+			return false
+		}
+		src = src[i+len("finally {"):]
+		depth := 1
+		end := strings.IndexFunc(src, func(r rune) bool {
+			switch r {
+			case '{':
+				depth++
+			case '}':
+				depth--
+			}
+			return depth == 0
+		})
+		if end < 0 {
+			end = len(src)
+		}
+		if returnStmt.MatchString(src[:end]) {
+			return true
+		}
+	}
+}
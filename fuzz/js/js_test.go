@@ -0,0 +1,19 @@
+package js
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/jssrc"
+)
+
+func FuzzScript(f *testing.F) {
+	for _, src := range gen.Sample("js/*", ".js", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckScript(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
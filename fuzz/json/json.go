@@ -0,0 +1,186 @@
+// Package json is a fuzz target for encoding/json. CheckAny decodes a
+// document into an interface value, which must agree with Valid, Compact
+// and Indent on whether it is well formed, and must marshal to a document
+// decoding to the same value. CheckStruct decodes it into Exotic, whose
+// fields cover the tag options and the types with their own encodings,
+// and marshals the result: the output must decode and marshal again to
+// the same bytes.
+package json
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+)
+
+// CheckAny checks data decoded into an interface value, with numbers
+// kept as json.Number so that none is out of range.
+func CheckAny(data []byte) error {
+	valid := json.Valid(data)
+	v, err := decode(data)
+	if (err == nil) != valid {
+		return fmt.Errorf("Valid is %v, but decoding gives %v", valid, err)
+	}
+	var u any
+	err = json.Unmarshal(data, &u)
+	var syntax *json.SyntaxError
+	if valid && errors.As(err, &syntax) || !valid && !errors.As(err, &syntax) {
+		return fmt.Errorf("Valid is %v, but Unmarshal gives %v", valid, err)
+	}
+	if err := tokens(data, valid); err != nil {
+		return err
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); (err == nil) != valid {
+		return fmt.Errorf("Valid is %v, but Compact gives %v", valid, err)
+	}
+	if !valid {
+		return nil
+	}
+	var indented, again bytes.Buffer
+	if err := json.Indent(&indented, data, " ", "\t"); err != nil {
+		return fmt.Errorf("Indent: %v", err)
+	}
+	if err := json.Compact(&again, indented.Bytes()); err != nil {
+		return fmt.Errorf("Compact of the indented document: %v\n%s", err, indented.Bytes())
+	}
+	if !bytes.Equal(again.Bytes(), compact.Bytes()) {
+		return fmt.Errorf("compacting the indented document gives\n%s\nnot\n%s", again.Bytes(), compact.Bytes())
+	}
+	if c, err := decode(compact.Bytes()); err != nil || !reflect.DeepEqual(c, v) {
+		return fmt.Errorf("the compacted document decodes to %#v (%v), not %#v", c, err, v)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Marshal of %#v: %v", v, err)
+	}
+	if w, err := decode(out); err != nil || !reflect.DeepEqual(w, v) {
+		return fmt.Errorf("%#v marshals to %s, which decodes to %#v (%v)", v, out, w, err)
+	}
+	return nil
+}
+
+// decode decodes data, which must hold one value and nothing after it
+// but space, with a Decoder using numbers.
+func decode(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var rest any
+	if err := d.Decode(&rest); err != io.EOF {
+		return nil, fmt.Errorf("after the value: %v", err)
+	}
+	return v, nil
+}
+
+// tokens reads data with Decoder.Token, which must reach the end of a
+// valid document without an error, and must stop on an invalid one.
+func tokens(data []byte, valid bool) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	for n := 0; ; n++ {
+		_, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if valid {
+				return fmt.Errorf("Token %d of a valid document: %v", n, err)
+			}
+			return nil
+		}
+		if n > len(data) {
+			return fmt.Errorf("Token returned %d tokens for %d bytes", n, len(data))
+		}
+	}
+}
+
+// Upper is a text type: it decodes to its text in upper case, so that
+// decoding it is not the identity, and it can key a map.
+type Upper string
+
+// MarshalText returns u.
+func (u Upper) MarshalText() ([]byte, error) { return []byte(u), nil }
+
+// UnmarshalText sets u to text in upper case.
+func (u *Upper) UnmarshalText(text []byte) error {
+	*u = Upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+// Inner is embedded in Exotic, whose JSON object gets its fields.
+type Inner struct {
+	Text string `json:"text"`
+	K    int    `json:",omitempty"`
+}
+
+// Exotic has a field for each tag option, a field of each type with an
+// encoding of its own, fields that recur and ones whose names fold to
+// the same name.
+type Exotic struct {
+	Name  string          `json:"name"`
+	Dash  int             `json:"-,"`
+	Skip  int             `json:"-"`
+	Str   int64           `json:"str,string"`
+	F     float64         `json:",string"`
+	B     bool            `json:"b,string"`
+	Omit  []int           `json:"omit,omitempty"`
+	Z     Inner           `json:"z,omitzero"`
+	Raw   json.RawMessage `json:"raw"`
+	Num   json.Number     `json:"num"`
+	Any   any             `json:"any"`
+	Ptr   *Exotic         `json:"ptr"`
+	Map   map[string]int  `json:"map"`
+	IMap  map[int]string  `json:"imap"`
+	Arr   [2]int8         `json:"arr"`
+	Slice []*Exotic       `json:"slice"`
+	Bytes []byte          `json:"bytes"`
+	U8    uint8           `json:"u8"`
+	Kanji string          `json:"名前"`
+	Space string          `json:"a b,omitempty"`
+	Dup1  int             `json:"dup"`
+	Dup2  int             `json:"Dup"`
+	Key   map[Upper]Upper `json:"Key"`
+	Inner
+}
+
+// CheckStruct checks data decoded into an Exotic. It must decode when
+// Valid says it is well formed, unless a value does not fit its field,
+// and not otherwise. What it decodes to must marshal to a document that
+// decodes to a value marshaling to the same bytes; the first marshaling
+// is not compared with data, which decoding loses information from.
+func CheckStruct(data []byte) error {
+	valid := json.Valid(data)
+	var v Exotic
+	err := json.Unmarshal(data, &v)
+	var syntax *json.SyntaxError
+	if valid && errors.As(err, &syntax) || !valid && err == nil {
+		return fmt.Errorf("Valid is %v, but Unmarshal gives %v", valid, err)
+	}
+	if err != nil {
+		return nil
+	}
+	once, err := json.Marshal(&v)
+	if err != nil {
+		return fmt.Errorf("Marshal of %+v: %v", v, err)
+	}
+	var w Exotic
+	if err := json.Unmarshal(once, &w); err != nil {
+		return fmt.Errorf("%+v marshals to %s, which does not decode: %v", v, once, err)
+	}
+	twice, err := json.Marshal(&w)
+	if err != nil {
+		return fmt.Errorf("Marshal of %+v: %v", w, err)
+	}
+	if !bytes.Equal(once, twice) {
+		return fmt.Errorf("marshaling again gives\n%s\nnot\n%s", twice, once)
+	}
+	return nil
+}
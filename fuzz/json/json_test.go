@@ -0,0 +1,30 @@
+package json
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/jsonsrc"
+)
+
+func FuzzAny(f *testing.F) {
+	for _, src := range gen.Sample("json/*", ".json", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckAny(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzStruct(f *testing.F) {
+	for _, src := range gen.Sample("json/*", ".json", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckStruct(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
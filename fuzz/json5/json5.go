@@ -0,0 +1,277 @@
+// Package json5 is a fuzz target for github.com/tailscale/hujson, which
+// reads JWCC (JSON with comments and trailing commas), and
+// github.com/titanous/json5. A config file checked by one parser and
+// loaded by another is only as safe as their agreement, so CheckDecode
+// holds each to the dialect it claims. hujson must pack a document it
+// parses back to the same bytes, and standardize, minimize and format it
+// to documents encoding/json decodes to one value. JSON5 and JWCC both
+// extend JSON, so a JSON document must decode in json5 as in
+// encoding/json and parse in hujson; and JSON5 extends JWCC, so json5
+// must decode what hujson parses, to the value of its standard form.
+// What json5 decodes must marshal to JSON that decodes to it again.
+package json5
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/tailscale/hujson"
+	titanous "github.com/titanous/json5"
+)
+
+// Timeout bounds checking one document.
+var Timeout = 10 * time.Second
+
+// CheckDecode checks the document in data. A document no parser reads is
+// ignored.
+func CheckDecode(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkDecode(data)
+	})
+}
+
+// crComment matches a line comment with a carriage return in it, which
+// JSON5 ends the comment at and JWCC, which ends comments only at a line
+// feed, does not. The two read the rest of the line differently, as the
+// specifications say they must, so that a member after the CR is read by
+// json5 alone.
+var crComment = regexp.MustCompile("//[^\n]*\r")
+
+func checkDecode(data []byte) error {
+	var v any
+	err := titanous.Unmarshal(data, &v)
+	var syntax *titanous.SyntaxError
+	accepted := !errors.As(err, &syntax)
+	if err == nil {
+		if err := checkMarshal(v); err != nil {
+			return err
+		}
+	}
+	if json.Valid(data) {
+		if !accepted {
+			return fmt.Errorf("a JSON document does not decode in json5: %v", err)
+		}
+		if err := same(data, data); err != nil {
+			return err
+		}
+		if _, err := hujson.Parse(data); err != nil {
+			return fmt.Errorf("a JSON document does not parse in hujson: %v", err)
+		}
+	}
+	// A Value holds slices of the bytes it is parsed from, which the
+	// methods changing it write to.
+	h, err := hujson.Parse(bytes.Clone(data))
+	if err != nil {
+		return nil
+	}
+	std, err := checkHuJSON(data, h)
+	if err != nil {
+		return err
+	}
+	// Known: json5 ends a block comment at the first '/' after a '*',
+	// whether or not the '/' follows at once.
+	if crComment.Match(data) || starSlash(data) {
+		return nil
+	}
+	if !accepted {
+		return fmt.Errorf("hujson parses a document json5 does not decode: %v", syntax)
+	}
+	return same(data, std)
+}
+
+// starSlash reports whether a block comment in data has a '*' in it
+// followed by a '/' other than the one ending it.
+func starSlash(data []byte) bool {
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '"' || data[i] == '\'':
+			q := data[i]
+			for i++; i < len(data) && data[i] != q; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+		case bytes.HasPrefix(data[i:], []byte("//")):
+			n := bytes.IndexByte(data[i:], '\n')
+			if n < 0 {
+				return false
+			}
+			i += n
+		case bytes.HasPrefix(data[i:], []byte("/*")):
+			body := data[i+2:]
+			end := bytes.Index(body, []byte("*/"))
+			if end < 0 {
+				return false
+			}
+			star := bytes.IndexByte(body, '*')
+			if star+bytes.IndexByte(body[star:], '/') != end+1 {
+				return true
+			}
+			i += 2 + end + 1
+		}
+	}
+	return false
+}
+
+// checkHuJSON checks the forms hujson turns a document into, and returns
+// its standard form.
+func checkHuJSON(data []byte, h hujson.Value) ([]byte, error) {
+	if out := h.Pack(); !bytes.Equal(out, data) {
+		return nil, fmt.Errorf("hujson packs a document as\n%q\nnot\n%q", out, data)
+	}
+	// Known: a Value from Clone has no space before a trailing comma
+	// where the original has an empty one, and Standardize drops such a
+	// comma rather than blanking it, moving what follows; the standard
+	// form is taken from a fresh parse, of a copy, since Standardize
+	// blanks comments in place.
+	std, err := hujson.Standardize(bytes.Clone(data))
+	if err != nil {
+		return nil, fmt.Errorf("hujson parses a document it does not standardize: %v", err)
+	}
+	if !json.Valid(std) {
+		return nil, fmt.Errorf("hujson standardizes a document to invalid JSON\n%q", std)
+	}
+	if len(std) != len(data) {
+		return nil, fmt.Errorf("hujson standardizes a document of %d bytes to %d bytes\n%q", len(data), len(std), std)
+	}
+	want, err := decode(std)
+	if err != nil {
+		return nil, fmt.Errorf("the standard form\n%q\ndoes not decode: %v", std, err)
+	}
+	m := h.Clone()
+	m.Minimize()
+	if got, err := decode(m.Pack()); err != nil || !reflect.DeepEqual(got, want) {
+		return nil, fmt.Errorf("hujson minimizes a document to\n%q\nwhich decodes to %#v (%v), not %#v", m.Pack(), got, err, want)
+	}
+	f := h.Clone()
+	f.Format()
+	formatted := f.Pack()
+	again, err := hujson.Parse(bytes.Clone(formatted))
+	if err != nil {
+		return nil, fmt.Errorf("hujson formats a document as\n%q\nwhich it does not parse: %v", formatted, err)
+	}
+	if h.IsStandard() && !f.IsStandard() {
+		return nil, fmt.Errorf("hujson formats a standard document as\n%q", formatted)
+	}
+	again.Format()
+	if out := again.Pack(); !bytes.Equal(out, formatted) {
+		return nil, fmt.Errorf("hujson formats its formatted document\n%q\nas\n%q", formatted, out)
+	}
+	fstd, _ := hujson.Standardize(bytes.Clone(formatted))
+	if got, err := decode(fstd); err != nil || !reflect.DeepEqual(got, want) {
+		return nil, fmt.Errorf("hujson formats a document as\n%q\nwhich decodes to %#v (%v), not %#v", formatted, got, err, want)
+	}
+	return std, nil
+}
+
+// same checks that json5 decodes data to the value encoding/json decodes
+// std to.
+func same(data, std []byte) error {
+	want, err := decode(std)
+	if err != nil {
+		return fmt.Errorf("encoding/json does not decode\n%q\n%v", std, err)
+	}
+	got, err := decode5(data)
+	if err != nil {
+		return fmt.Errorf("json5 decodes a document with Unmarshal but not with a Decoder: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		return fmt.Errorf("json5 decodes a document to %#v, but encoding/json its standard form to %#v", got, want)
+	}
+	return nil
+}
+
+// checkMarshal checks that v, decoded by json5, marshals to JSON that
+// encoding/json and json5 decode to v again. JSON has no NaN or infinity,
+// so a value with one is skipped.
+func checkMarshal(v any) error {
+	if !finite(v) {
+		return nil
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("Marshal of %#v: %v", v, err)
+	}
+	var w, w5 any
+	if err := json.Unmarshal(out, &w); err != nil || !reflect.DeepEqual(w, v) {
+		return fmt.Errorf("%#v marshals to %s, which encoding/json decodes to %#v (%v)", v, out, w, err)
+	}
+	if err := titanous.Unmarshal(out, &w5); err != nil || !reflect.DeepEqual(w5, v) {
+		return fmt.Errorf("%#v marshals to %s, which json5 decodes to %#v (%v)", v, out, w5, err)
+	}
+	return nil
+}
+
+// finite reports whether v has no NaN or infinite number in it.
+func finite(v any) bool {
+	switch v := v.(type) {
+	case float64:
+		return !math.IsNaN(v) && !math.IsInf(v, 0)
+	case map[string]any:
+		for _, e := range v {
+			if !finite(e) {
+				return false
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if !finite(e) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// decode decodes data, which must hold one value and nothing after it
+// but space, with encoding/json, keeping numbers as json.Number.
+func decode(data []byte) (any, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	var rest any
+	if err := d.Decode(&rest); err != io.EOF {
+		return nil, fmt.Errorf("after the value: %v", err)
+	}
+	return v, nil
+}
+
+// decode5 decodes data with json5, keeping numbers as json.Number so that
+// they compare with decode's.
+func decode5(data []byte) (any, error) {
+	d := titanous.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return numbers(v), nil
+}
+
+// numbers returns v with each json5 number in it a json.Number.
+func numbers(v any) any {
+	switch v := v.(type) {
+	case titanous.Number:
+		return json.Number(v)
+	case map[string]any:
+		for k, e := range v {
+			v[k] = numbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = numbers(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,19 @@
+package json5
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/json5src"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, src := range gen.Sample("json5/*", ".json5", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckDecode(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
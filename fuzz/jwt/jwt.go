@@ -0,0 +1,386 @@
+// Package jwt is a fuzz target for github.com/golang-jwt/jwt/v5 and
+// github.com/go-jose/go-jose/v4, on the paths that read a token without
+// verifying it; no key is involved. CheckJWS reads data as a JWS:
+// golang-jwt's Parser.ParseUnverified, with its default options, with
+// WithPaddingAllowed and with WithStrictDecoding, must read a compact
+// token if and only if the harness's reader does, and to the same
+// header, claims and signature; go-jose's ParseSigned must read a token,
+// compact or JSON, only where the reader finds one, to the same payload
+// and signatures, and what it reads must serialize to a token it reads
+// back the same; and claims that go-jose's jwt package reads, golang-jwt
+// must read the same. CheckJWE reads data as a JWE with go-jose's
+// ParseEncrypted, under the same rules.
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	jose "github.com/go-jose/go-jose/v4"
+	josejwt "github.com/go-jose/go-jose/v4/jwt"
+	golangjwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Timeout bounds checking one token.
+var Timeout = 10 * time.Second
+
+// The algorithms each library knows: every one go-jose implements is
+// allowed, so that what it rejects it rejects for the token's form.
+var (
+	golangJWTAlgs = []string{
+		"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512", "EdDSA", "none",
+	}
+	sigAlgs = []jose.SignatureAlgorithm{
+		jose.HS256, jose.HS384, jose.HS512, jose.RS256, jose.RS384, jose.RS512, jose.PS256, jose.PS384, jose.PS512,
+		jose.ES256, jose.ES384, jose.ES512, jose.EdDSA,
+	}
+	keyAlgs = []jose.KeyAlgorithm{
+		jose.ED25519, jose.RSA1_5, jose.RSA_OAEP, jose.RSA_OAEP_256, jose.A128KW, jose.A192KW, jose.A256KW, jose.DIRECT,
+		jose.ECDH_ES, jose.ECDH_ES_A128KW, jose.ECDH_ES_A192KW, jose.ECDH_ES_A256KW,
+		jose.A128GCMKW, jose.A192GCMKW, jose.A256GCMKW,
+		jose.PBES2_HS256_A128KW, jose.PBES2_HS384_A192KW, jose.PBES2_HS512_A256KW,
+	}
+	encs = []jose.ContentEncryption{
+		jose.A128CBC_HS256, jose.A192CBC_HS384, jose.A256CBC_HS512, jose.A128GCM, jose.A192GCM, jose.A256GCM,
+	}
+)
+
+// CheckJWS checks data read as a JWS.
+func CheckJWS(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		tok := string(data)
+		parsers := []struct {
+			name           string
+			p              *golangjwt.Parser
+			padded, strict bool
+		}{
+			{"ParseUnverified", golangjwt.NewParser(), false, false},
+			{"ParseUnverified WithPaddingAllowed", golangjwt.NewParser(golangjwt.WithPaddingAllowed()), true, false},
+			{"ParseUnverified WithStrictDecoding", golangjwt.NewParser(golangjwt.WithStrictDecoding()), false, true},
+		}
+		for _, p := range parsers {
+			if err := checkParseUnverified(tok, p.name, p.p, p.padded, p.strict); err != nil {
+				return err
+			}
+		}
+		if err := checkSigned(tok); err != nil {
+			return err
+		}
+		return checkClaims(tok)
+	})
+}
+
+// A token is what golang-jwt reads from a compact JWS.
+type token struct {
+	header, claims map[string]any
+	alg            string
+	sig            []byte
+}
+
+// unverified reads tok as golang-jwt's ParseUnverified does by RFC 7519:
+// three segments, a header and claims that are JSON objects, as
+// encoding/json decodes them, an alg that golang-jwt knows and a
+// signature. It returns the error golang-jwt wraps where tok does not
+// read: ErrTokenMalformed, or ErrTokenUnverifiable for an alg it does not
+// know.
+func unverified(tok string, padded, strict bool) (token, error) {
+	var t token
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return t, golangjwt.ErrTokenMalformed
+	}
+	h, ok := segment(parts[0], padded, strict)
+	if !ok || json.Unmarshal(h, &t.header) != nil {
+		return t, golangjwt.ErrTokenMalformed
+	}
+	c, ok := segment(parts[1], padded, strict)
+	if !ok || json.Unmarshal(c, &t.claims) != nil {
+		return t, golangjwt.ErrTokenMalformed
+	}
+	if t.alg, ok = t.header["alg"].(string); !ok || !slices.Contains(golangJWTAlgs, t.alg) {
+		return t, golangjwt.ErrTokenUnverifiable
+	}
+	if t.sig, ok = segment(parts[2], padded, strict); !ok {
+		return t, golangjwt.ErrTokenMalformed
+	}
+	return t, nil
+}
+
+func checkParseUnverified(tok, name string, p *golangjwt.Parser, padded, strict bool) error {
+	want, wantErr := unverified(tok, padded, strict)
+	got, _, err := p.ParseUnverified(tok, golangjwt.MapClaims{})
+	switch {
+	case wantErr == nil && err != nil:
+		return fmt.Errorf("%s: %v, but the token reads as %+v", name, err, want)
+	case wantErr != nil && err == nil:
+		return fmt.Errorf("%s read a token the reader does not, %v: header %v, claims %v", name, wantErr, got.Header, got.Claims)
+	case err != nil && !errors.Is(err, wantErr):
+		return fmt.Errorf("%s: %v, want an error that is %v", name, err, wantErr)
+	case err != nil:
+		return nil
+	}
+	claims, _ := got.Claims.(golangjwt.MapClaims)
+	switch {
+	case !same(got.Header, want.header):
+		return fmt.Errorf("%s read a header of %v, want %v", name, got.Header, want.header)
+	case !same(claims, want.claims):
+		return fmt.Errorf("%s read claims of %v, want %v", name, claims, want.claims)
+	case got.Method.Alg() != want.alg:
+		return fmt.Errorf("%s read an alg of %q, want %q", name, got.Method.Alg(), want.alg)
+	case !bytes.Equal(got.Signature, want.sig):
+		return fmt.Errorf("%s read a signature of %x, want %x", name, got.Signature, want.sig)
+	}
+	return nil
+}
+
+// same reports whether a and b hold the same values, an empty map being
+// the same as none.
+func same(a, b map[string]any) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+// checkSigned checks go-jose's ParseSigned, which reads a JWS in the JSON
+// serialization where data starts with a brace after white space, and
+// otherwise in the compact serialization once white space is dropped.
+func checkSigned(tok string) error {
+	obj, err := jose.ParseSigned(tok, sigAlgs)
+	if err != nil {
+		return nil
+	}
+	compact := !strings.HasPrefix(strings.TrimLeftFunc(tok, unicode.IsSpace), "{")
+	var want signed
+	if compact {
+		segs, ok := segments(unspace(tok), 3)
+		if !ok {
+			return fmt.Errorf("ParseSigned read a compact token the reader does not split into three segments")
+		}
+		h, ok := members(segs[0])
+		if !ok {
+			return fmt.Errorf("ParseSigned read a compact token whose header %q is not an object with each member once", segs[0])
+		}
+		if alg, _ := str(h["alg"]); obj.Signatures[0].Header.Algorithm != alg {
+			return fmt.Errorf("ParseSigned read an alg of %q from a header of %q", obj.Signatures[0].Header.Algorithm, segs[0])
+		}
+		want = signed{payload: segs[1], sigs: [][]byte{segs[2]}}
+	} else {
+		var ok bool
+		if want, ok = signedJSON(tok); !ok {
+			return fmt.Errorf("ParseSigned read a JSON token that the reader does not")
+		}
+	}
+	if err := sameSigned("ParseSigned", obj, want); err != nil {
+		return err
+	}
+	// Known: go-jose reads an empty payload, but serializes a token with
+	// one by leaving the payload out, which it then rejects.
+	if len(want.payload) == 0 {
+		return nil
+	}
+	// Known: go-jose neither merges nor checks the unprotected header of
+	// a signature in the general serialization, which it sets only once
+	// it has checked the others; serialized flattened, a token with one
+	// signature has it checked, and rejected if it does not hold.
+	if want.general && want.headers {
+		return nil
+	}
+	var again string
+	if compact {
+		if again, err = obj.CompactSerialize(); err != nil {
+			return fmt.Errorf("CompactSerialize of a compact token: %v", err)
+		}
+	} else {
+		again = obj.FullSerialize()
+	}
+	obj2, err := jose.ParseSigned(again, sigAlgs)
+	if err != nil {
+		return fmt.Errorf("ParseSigned of the serialization %q of a token it read: %v", again, err)
+	}
+	if err := sameSigned("ParseSigned of the serialization of a token", obj2, want); err != nil {
+		return err
+	}
+	var third string
+	if compact {
+		third, _ = obj2.CompactSerialize()
+	} else {
+		third = obj2.FullSerialize()
+	}
+	if third != again {
+		return fmt.Errorf("a token serializes as %q, which reads back and serializes as %q", again, third)
+	}
+	return nil
+}
+
+// A signed is what a JWS holds: its payload and its signatures, and for
+// the JSON serialization whether it is general and whether one of its
+// signatures has an unprotected header.
+type signed struct {
+	payload          []byte
+	sigs             [][]byte
+	general, headers bool
+}
+
+// signedJSON reads tok as a JWS in the JSON serialization, RFC 7515
+// section 7.2, as go-jose does: an object with each member once, and a
+// payload that is a string. A signatures array that is not empty makes
+// tok general, each of its elements a signature; otherwise tok is
+// flattened and its own signature member is the one signature, which is
+// nil where the member is missing.
+func signedJSON(tok string) (signed, bool) {
+	var t signed
+	m, ok := members([]byte(tok))
+	if !ok {
+		return t, false
+	}
+	p, ok := str(m["payload"])
+	if !ok {
+		return t, false
+	}
+	if t.payload, ok = segment(p, false, false); !ok {
+		return t, false
+	}
+	var list []json.RawMessage
+	if s := m["signatures"]; s != nil {
+		if json.Unmarshal(s, &list) != nil {
+			return t, false
+		}
+	}
+	t.general = len(list) > 0
+	if !t.general {
+		list = []json.RawMessage{[]byte(tok)}
+	}
+	for _, e := range list {
+		sm, ok := members(e)
+		if !ok {
+			return t, false
+		}
+		var sig []byte
+		if s, ok := str(sm["signature"]); ok {
+			if sig, ok = segment(s, false, false); !ok {
+				return t, false
+			}
+		}
+		t.sigs = append(t.sigs, sig)
+		t.headers = t.headers || sm["header"] != nil
+	}
+	return t, true
+}
+
+// sameSigned checks that obj holds what want does.
+func sameSigned(name string, obj *jose.JSONWebSignature, want signed) error {
+	if got := obj.UnsafePayloadWithoutVerification(); !bytes.Equal(got, want.payload) {
+		return fmt.Errorf("%s read a payload of %q, want %q", name, got, want.payload)
+	}
+	if len(obj.Signatures) != len(want.sigs) {
+		return fmt.Errorf("%s read %d signatures, want %d", name, len(obj.Signatures), len(want.sigs))
+	}
+	for i, s := range obj.Signatures {
+		if !bytes.Equal(s.Signature, want.sigs[i]) {
+			return fmt.Errorf("%s read signature %d as %x, want %x", name, i, s.Signature, want.sigs[i])
+		}
+	}
+	return nil
+}
+
+// checkClaims checks that claims go-jose's jwt package reads from a
+// compact token, golang-jwt reads to the same values, with the same alg.
+// go-jose rejects a key that is there twice, so the claims it reads are
+// the same whichever of the two a reader keeps.
+func checkClaims(tok string) error {
+	t, err := josejwt.ParseSigned(tok, sigAlgs)
+	if err != nil {
+		return nil
+	}
+	var claims map[string]any
+	if err := t.UnsafeClaimsWithoutVerification(&claims); err != nil {
+		return nil
+	}
+	got, _, err := golangjwt.NewParser().ParseUnverified(tok, golangjwt.MapClaims{})
+	if err != nil {
+		return fmt.Errorf("go-jose's jwt.ParseSigned read claims %v, but golang-jwt's ParseUnverified: %v", claims, err)
+	}
+	if mc, _ := got.Claims.(golangjwt.MapClaims); !same(mc, claims) {
+		return fmt.Errorf("go-jose's jwt.ParseSigned read claims %v, but golang-jwt's ParseUnverified %v", claims, mc)
+	}
+	if alg := t.Headers[0].Algorithm; got.Method.Alg() != alg {
+		return fmt.Errorf("go-jose's jwt.ParseSigned read an alg of %q, but golang-jwt's ParseUnverified %q", alg, got.Method.Alg())
+	}
+	return nil
+}
+
+// CheckJWE checks data read as a JWE by go-jose's ParseEncrypted, which
+// drops white space from data, then reads it in the JSON serialization
+// if it starts with a brace and otherwise in the compact serialization.
+func CheckJWE(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkEncrypted(string(data))
+	})
+}
+
+func checkEncrypted(tok string) error {
+	obj, err := jose.ParseEncrypted(tok, keyAlgs, encs)
+	if err != nil {
+		return nil
+	}
+	tok = unspace(tok)
+	compact := !strings.HasPrefix(tok, "{")
+	if compact {
+		segs, ok := segments(tok, 5)
+		if !ok {
+			return fmt.Errorf("ParseEncrypted read a compact token the reader does not split into five segments")
+		}
+		h, ok := members(segs[0])
+		if !ok {
+			return fmt.Errorf("ParseEncrypted read a compact token whose header %q is not an object with each member once", segs[0])
+		}
+		alg, _ := str(h["alg"])
+		enc, _ := str(h["enc"])
+		if string(obj.Header.Algorithm) != alg || alg == "" || enc == "" {
+			return fmt.Errorf("ParseEncrypted read an alg of %q from a header of %q", obj.Header.Algorithm, segs[0])
+		}
+	} else if _, ok := members([]byte(tok)); !ok {
+		return fmt.Errorf("ParseEncrypted read a JSON token that is not an object with each member once")
+	}
+	var again string
+	if compact {
+		if again, err = obj.CompactSerialize(); err != nil {
+			return fmt.Errorf("CompactSerialize of a compact token: %v", err)
+		}
+	} else {
+		again = obj.FullSerialize()
+	}
+	obj2, err := jose.ParseEncrypted(again, keyAlgs, encs)
+	if err != nil {
+		return fmt.Errorf("ParseEncrypted of the serialization %q of a token it read: %v", again, err)
+	}
+	if !bytes.Equal(obj2.GetAuthData(), obj.GetAuthData()) || obj2.Header.Algorithm != obj.Header.Algorithm {
+		return fmt.Errorf("a token serializes as %q, which reads back with an alg of %q and additional data %q, not %q and %q",
+			again, obj2.Header.Algorithm, obj2.GetAuthData(), obj.Header.Algorithm, obj.GetAuthData())
+	}
+	var third string
+	if compact {
+		third, _ = obj2.CompactSerialize()
+	} else {
+		// Known: go-jose writes an empty encrypted key, ciphertext or
+		// additional data it read as "", but reads "" back as none and
+		// leaves it out; the serialization holds from the second on.
+		third = obj2.FullSerialize()
+		obj3, err := jose.ParseEncrypted(third, keyAlgs, encs)
+		if err != nil {
+			return fmt.Errorf("ParseEncrypted of the serialization %q of a token it read: %v", third, err)
+		}
+		again, third = third, obj3.FullSerialize()
+	}
+	if third != again {
+		return fmt.Errorf("a token serializes as %q, which reads back and serializes as %q", again, third)
+	}
+	return nil
+}
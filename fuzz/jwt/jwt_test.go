@@ -0,0 +1,30 @@
+package jwt
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/jwtsrc"
+)
+
+func FuzzJWS(f *testing.F) {
+	for _, src := range gen.Sample("jwt/*", ".jws", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckJWS(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzJWE(f *testing.F) {
+	for _, src := range gen.Sample("jwt/*", ".jwe", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckJWE(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+package jwt
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"unicode"
+)
+
+// alphabet is base64url's, RFC 4648 section 5.
+const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// segment decodes seg as base64url, RFC 7515 section 2 has it without
+// padding, as encoding/base64 does: it skips carriage returns and line
+// feeds wherever they are, and unless strict takes bits set past the last
+// byte. With padded, seg is first padded to a multiple of four as
+// golang-jwt's WithPaddingAllowed pads it, counting the line breaks, and
+// must then end in the padding its length calls for.
+func segment(seg string, padded, strict bool) ([]byte, bool) {
+	if padded && len(seg)%4 != 0 {
+		seg += strings.Repeat("=", 4-len(seg)%4)
+	}
+	s := strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, seg)
+	if padded {
+		body := strings.TrimRight(s, "=")
+		n := len(s) - len(body)
+		if len(s)%4 != 0 || n > 2 || n > 0 && len(body)%4 != 4-n {
+			return nil, false
+		}
+		s = body
+	}
+	if len(s)%4 == 1 {
+		return nil, false
+	}
+	var out []byte
+	var acc, bits uint
+	for i := range len(s) {
+		v := strings.IndexByte(alphabet, s[i])
+		if v < 0 {
+			return nil, false
+		}
+		acc, bits = acc<<6|uint(v), bits+6
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+			acc &= 1<<bits - 1
+		}
+	}
+	if strict && acc != 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// segments splits a compact token into its segments by its dots and
+// decodes them without padding, or reports that there are not n of them
+// or that one does not decode.
+func segments(tok string, n int) ([][]byte, bool) {
+	parts := strings.Split(tok, ".")
+	if len(parts) != n {
+		return nil, false
+	}
+	segs := make([][]byte, n)
+	for i, p := range parts {
+		var ok bool
+		if segs[i], ok = segment(p, false, false); !ok {
+			return nil, false
+		}
+	}
+	return segs, true
+}
+
+// members returns the members of the JSON object in data, or reports
+// that data is not an object or has a key twice, which go-jose's JSON
+// reader rejects where encoding/json keeps the last. A key counts as
+// what it spells, escapes undone; values are left as they are.
+func members(data []byte) (map[string]json.RawMessage, bool) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil, false
+	}
+	m := map[string]json.RawMessage{}
+	for d.More() {
+		t, err := d.Token()
+		if err != nil {
+			return nil, false
+		}
+		key := t.(string)
+		var v json.RawMessage
+		if err := d.Decode(&v); err != nil {
+			return nil, false
+		}
+		if _, ok := m[key]; ok {
+			return nil, false
+		}
+		m[key] = v
+	}
+	if _, err := d.Token(); err != nil {
+		return nil, false
+	}
+	if _, err := d.Token(); err == nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// str returns the string v holds, or reports that it holds no string.
+func str(v json.RawMessage) (string, bool) {
+	var s string
+	if v == nil || json.Unmarshal(v, &s) != nil || bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+		return "", false
+	}
+	return s, true
+}
+
+// unspace drops the white space from s, as go-jose drops it from a
+// compact JWS and from any JWE before it reads them.
+func unspace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
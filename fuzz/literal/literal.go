@@ -0,0 +1,157 @@
+// Package literal is a differential fuzz target for string and rune
+// literals: go/scanner, strconv and go/constant must agree on which
+// literals are valid and what they decode to, and quoting a value must
+// give back a literal that scans to the same value.
+package literal
+
+import (
+	"fmt"
+	"go/constant"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Check scans src and checks every string and rune literal the scanner
+// accepts with Literal. Literals the scanner reports errors in are
+// skipped: rejecting them is the scanner's job.
+func Check(src []byte) error {
+	fset := token.NewFileSet()
+	file := fset.AddFile("seed.go", -1, len(src))
+	var bad []int
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, _ string) { bad = append(bad, pos.Offset) }, 0)
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return nil
+		}
+		if tok != token.STRING && tok != token.CHAR {
+			continue
+		}
+		start := file.Offset(pos)
+		end := start + len(lit) - 1
+		if src[start] == '`' {
+			end = start + 1 + strings.IndexByte(string(src[start+1:]), '`')
+		}
+		// An unterminated literal's error may lie just past its end.
+		if end <= start || hasError(bad, start, end+1) {
+			continue
+		}
+		if err := Literal(tok, lit); err != nil {
+			return fmt.Errorf("%s: %v", fset.Position(pos), err)
+		}
+		if tok == token.STRING && src[start] == '`' {
+			// The scanner drops carriage returns from raw strings, and so
+			// must Unquote.
+			if u, err := strconv.Unquote(string(src[start : end+1])); err != nil || u != constant.StringVal(constant.MakeFromLiteral(lit, tok, 0)) {
+				return fmt.Errorf("%s: raw string %q unquotes to %q, %v", fset.Position(pos), src[start:end+1], u, err)
+			}
+		}
+	}
+}
+
+// hasError reports whether any of the error offsets lies in [start, end].
+func hasError(bad []int, start, end int) bool {
+	for _, o := range bad {
+		if start <= o && o <= end {
+			return true
+		}
+	}
+	return false
+}
+
+// Literal checks a literal the scanner accepted. strconv must decode it
+// to the value go/constant gives it, and every quoting of that value
+// strconv produces must unquote and rescan to the same value.
+func Literal(tok token.Token, lit string) error {
+	v := constant.MakeFromLiteral(lit, tok, 0)
+	if v.Kind() == constant.Unknown {
+		return fmt.Errorf("go/constant rejects %s %s", tok, lit)
+	}
+	var forms []string
+	switch tok {
+	case token.STRING:
+		want := constant.StringVal(v)
+		u, err := strconv.Unquote(lit)
+		if err != nil {
+			return fmt.Errorf("strconv.Unquote(%s): %v", lit, err)
+		}
+		if u != want {
+			return fmt.Errorf("strconv.Unquote(%s) = %q, go/constant says %q", lit, u, want)
+		}
+		if p, err := strconv.QuotedPrefix(lit); p != lit {
+			return fmt.Errorf("strconv.QuotedPrefix(%s) = %s, %v", lit, p, err)
+		}
+		forms = []string{strconv.Quote(u), strconv.QuoteToASCII(u), strconv.QuoteToGraphic(u)}
+		if strconv.CanBackquote(u) {
+			forms = append(forms, "`"+u+"`")
+		}
+	case token.CHAR:
+		want, _ := constant.Int64Val(v)
+		r, _, tail, err := strconv.UnquoteChar(lit[1:len(lit)-1], '\'')
+		if err != nil || tail != "" {
+			return fmt.Errorf("strconv.UnquoteChar(%s) = %q, tail %q, %v", lit, r, tail, err)
+		}
+		if int64(r) != want {
+			return fmt.Errorf("strconv.UnquoteChar(%s) = %#x, go/constant says %#x", lit, r, want)
+		}
+		forms = []string{strconv.QuoteRune(r), strconv.QuoteRuneToASCII(r), strconv.QuoteRuneToGraphic(r)}
+	}
+	for _, q := range forms {
+		if err := rescan(tok, q, v); err != nil {
+			return fmt.Errorf("requoting %s: %v", lit, err)
+		}
+	}
+	return nil
+}
+
+// rescan checks that q scans as exactly one error-free tok whose value
+// is v.
+func rescan(tok token.Token, q string, v constant.Value) error {
+	fset := token.NewFileSet()
+	var errs []string
+	var s scanner.Scanner
+	s.Init(fset.AddFile("quoted", -1, len(q)), []byte(q), func(_ token.Position, msg string) { errs = append(errs, msg) }, 0)
+	_, got, lit := s.Scan()
+	if _, next, _ := s.Scan(); next != token.SEMICOLON && next != token.EOF {
+		return fmt.Errorf("%s scans as more than one token", q)
+	}
+	if len(errs) > 0 || got != tok {
+		return fmt.Errorf("%s scans as %s with errors %q", q, got, errs)
+	}
+	if w := constant.MakeFromLiteral(lit, tok, 0); !constant.Compare(w, token.EQL, v) {
+		return fmt.Errorf("%s rescans to %s, want %s", q, w.ExactString(), v.ExactString())
+	}
+	return nil
+}
+
+// Quoted checks the other direction: a quoted string strconv.Unquote
+// accepts must, if it is valid source text, scan as a single literal
+// with the same value. The scanner rejects NUL, byte order marks and
+// invalid UTF-8 anywhere in a file, which Unquote allows in raw strings.
+func Quoted(q string) error {
+	u, err := strconv.Unquote(q)
+	if err != nil || !utf8.ValidString(q) || strings.ContainsAny(q, "\x00\ufeff") {
+		return nil
+	}
+	if q == "''" {
+		// Known: Unquote accepts the empty rune literal, decoding
+		// nothing as utf8.RuneError.
+		return nil
+	}
+	tok := token.STRING
+	var v constant.Value = constant.MakeString(u)
+	if q[0] == '\'' {
+		r, _ := utf8.DecodeRuneInString(u)
+		if len(u) == 1 {
+			// A \x or octal escape in a rune literal is the code point,
+			// but Unquote returns it as a single byte.
+			r = rune(u[0])
+		}
+		tok, v = token.CHAR, constant.MakeInt64(int64(r))
+	}
+	return rescan(tok, q, v)
+}
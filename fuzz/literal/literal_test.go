@@ -0,0 +1,30 @@
+package literal
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzLiterals(f *testing.F) {
+	for _, src := range gen.Sample("go/literals", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzQuoted(f *testing.F) {
+	for _, q := range []string{`"\xff\u00e9"`, "`a\r\nb`", `'\U0010ffff'`, `'\377'`, `"\ud7ff"`} {
+		f.Add(q)
+	}
+	f.Fuzz(func(t *testing.T, q string) {
+		if err := Quoted(q); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
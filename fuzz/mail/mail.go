@@ -0,0 +1,141 @@
+// Package mail is a fuzz target for net/mail and the header functions of
+// mime. CheckAddress parses an address list; each address must print,
+// with String, as one that parses to the same name and address, and a
+// list of one must parse with ParseAddress as with ParseAddressList. A
+// mail system that checks the address it parses and delivers to the one
+// it prints is only safe if the two are the same. CheckMediaType parses a
+// media type, which FormatMediaType must write as one that parses to the
+// same type and parameters. CheckMessage reads the header of a message
+// and checks its address and content headers with those, its date, which
+// must format as one that parses to the same instant, and its subject,
+// which must decode, encode and decode again to the same text.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"mime"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// CheckAddress checks the address list s.
+func CheckAddress(s string) error {
+	list, err := mail.ParseAddressList(s)
+	if a, errA := mail.ParseAddress(s); errA == nil {
+		if err != nil || len(list) != 1 || *list[0] != *a {
+			return fmt.Errorf("%q parses as the address %q, but as the list %q (%v)", s, a, list, err)
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	printed := make([]string, len(list))
+	for i, a := range list {
+		printed[i] = a.String()
+		if backslashWord(a.Name) {
+			return nil
+		}
+		again, err := mail.ParseAddress(printed[i])
+		if err != nil {
+			return fmt.Errorf("%q parses as %#v, which prints as %q, which does not parse: %v", s, *a, printed[i], err)
+		}
+		if *again != *a {
+			return fmt.Errorf("%q parses as %#v, which prints as %q, which parses as %#v", s, *a, printed[i], *again)
+		}
+	}
+	if len(list) == 0 {
+		return nil // only empty groups, which print as nothing
+	}
+	joined := strings.Join(printed, ", ")
+	again, err := mail.ParseAddressList(joined)
+	if err != nil {
+		return fmt.Errorf("%q parses as a list that prints as %q, which does not parse: %v", s, joined, err)
+	}
+	if !slices.EqualFunc(again, list, func(a, b *mail.Address) bool { return *a == *b }) {
+		return fmt.Errorf("%q parses as %q, which prints as %q, which parses as %q", s, list, joined, again)
+	}
+	return nil
+}
+
+// backslashWord reports whether String writes name, which is not all
+// printable ASCII, as an encoded word with a backslash in it.
+//
+// Known: String Q-encodes such a name unless it holds one of the
+// characters RFC 2047 keeps out of encoded words in a phrase, a list
+// missing the backslash, so that the word it writes is not an atom.
+func backslashWord(name string) bool {
+	return strings.Contains(name, `\`) && strings.ContainsFunc(name, func(r rune) bool {
+		return (r < ' ' || r > '~') && r != '\t'
+	})
+}
+
+// CheckMediaType checks the media type or disposition s with its
+// parameters.
+func CheckMediaType(s string) error {
+	mt, params, err := mime.ParseMediaType(s)
+	// Known: ParseMediaType reads "*0=v" as the first section of a
+	// parameter with an empty name, which FormatMediaType rejects.
+	if _, empty := params[""]; err != nil || empty {
+		return nil
+	}
+	out := mime.FormatMediaType(mt, params)
+	if out == "" {
+		return fmt.Errorf("%q parses as %q %q, which does not format", s, mt, params)
+	}
+	mt2, params2, err := mime.ParseMediaType(out)
+	if err != nil {
+		return fmt.Errorf("%q parses as %q %q, which formats as %q, which does not parse: %v", s, mt, params, out, err)
+	}
+	if mt2 != mt || !maps.Equal(params2, params) {
+		return fmt.Errorf("%q parses as %q %q, which formats as %q, which parses as %q %q", s, mt, params, out, mt2, params2)
+	}
+	return nil
+}
+
+// CheckMessage checks the header of the message in data.
+func CheckMessage(data []byte) error {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil
+	}
+	h := msg.Header
+	for _, k := range []string{"From", "To", "Cc", "Bcc", "Reply-To", "Sender"} {
+		for _, v := range h[k] {
+			if err := CheckAddress(v); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+		}
+	}
+	for _, k := range []string{"Content-Type", "Content-Disposition"} {
+		for _, v := range h[k] {
+			if err := CheckMediaType(v); err != nil {
+				return fmt.Errorf("%s: %v", k, err)
+			}
+		}
+	}
+	if t, err := h.Date(); err == nil {
+		s := t.Format(time.RFC1123Z)
+		again, err := mail.ParseDate(s)
+		if err != nil || !again.Equal(t) {
+			return fmt.Errorf("Date %q parses as %v, which formats as %q, which parses as %v (%v)", h.Get("Date"), t, s, again, err)
+		}
+	}
+	var dec mime.WordDecoder
+	for _, v := range h["Subject"] {
+		text, err := dec.DecodeHeader(v)
+		if err != nil || !utf8.ValidString(text) {
+			continue
+		}
+		enc := mime.QEncoding.Encode("utf-8", text)
+		again, err := dec.DecodeHeader(enc)
+		if err != nil || again != text {
+			return fmt.Errorf("Subject %q decodes to %q, which encodes as %q, which decodes to %q (%v)", v, text, enc, again, err)
+		}
+	}
+	return nil
+}
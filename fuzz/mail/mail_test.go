@@ -0,0 +1,41 @@
+package mail
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/mailsrc"
+)
+
+func FuzzAddress(f *testing.F) {
+	for _, src := range gen.Sample("mail/address", ".addr", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckAddress(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMediaType(f *testing.F) {
+	for _, src := range gen.Sample("mail/mediatype", ".ct", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckMediaType(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMessage(f *testing.F) {
+	for _, src := range gen.Sample("mail/message", ".eml", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMessage(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,179 @@
+// Package markdown is a fuzz target for github.com/yuin/goldmark and
+// github.com/russross/blackfriday/v2. CheckRender renders a document to
+// HTML with each, its tables, footnotes and other extensions on and raw
+// HTML off, within a budget of time and memory linear in the size of the
+// document and of its HTML, past which it is reported as a blowup: nested
+// emphasis and brackets, reference definition floods and the other inputs
+// that have cost Markdown parsers quadratic time must cost no more than
+// their bytes, or than the bytes of a table whose rows are filled out to
+// the width of its header. Neither may let script through, so the HTML
+// must hold no element or attribute that runs it and no link to a URL
+// that does.
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/russross/blackfriday/v2"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"golang.org/x/net/html"
+)
+
+// DefaultBudget is what rendering a document with one library may cost,
+// per byte of the document and of the HTML. It allows a few
+// microseconds and a few kilobytes a byte, a hundred times what the
+// libraries spend on an ordinary document, so that a document of tens
+// of kilobytes whose cost is worse than quadratic, or quadratic with a
+// large factor, is caught.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: 500 * time.Millisecond, Memory: 32 << 20},
+	Per:  harness.Cost{Time: 5 * time.Microsecond, Memory: 4 << 10},
+}
+
+// A library renders Markdown as HTML.
+type library struct {
+	name   string
+	render func([]byte) ([]byte, error)
+}
+
+var libraries = []library{
+	{"goldmark", renderGoldmark},
+	{"blackfriday", renderBlackfriday},
+}
+
+var md = goldmark.New(goldmark.WithExtensions(
+	extension.GFM,
+	extension.Footnote,
+	extension.DefinitionList,
+	extension.Typographer,
+))
+
+func renderGoldmark(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	err := md.Convert(data, &out)
+	return out.Bytes(), err
+}
+
+func renderBlackfriday(data []byte) ([]byte, error) {
+	r := blackfriday.NewHTMLRenderer(blackfriday.HTMLRendererParameters{
+		Flags: blackfriday.CommonHTMLFlags | blackfriday.SkipHTML | blackfriday.Safelink,
+	})
+	// Known: blackfriday indexes past the end of a document that does not
+	// end in a newline, which version 1 added, so it is given one; and it
+	// loops forever on a footnote that refers to itself, directly or
+	// through others, so it renders without them.
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data[:len(data):len(data)], '\n')
+	}
+	return blackfriday.Run(data,
+		blackfriday.WithRenderer(r),
+		blackfriday.WithExtensions(blackfriday.CommonExtensions),
+	), nil
+}
+
+// CheckRender renders the document in data with each library within b.
+func CheckRender(data []byte, b harness.Budget) error {
+	for _, l := range libraries {
+		// Known: blackfriday looks for a code fence at each byte of a line
+		// in a block quote, and for the end of each fence it finds, which
+		// costs time and memory quadratic in the length of the line.
+		if l.name == "blackfriday" && quotedFence(data) {
+			continue
+		}
+		var out []byte
+		var rerr error
+		spent, err := harness.Spend(b.For(len(data)), func() {
+			out, rerr = l.render(data)
+		})
+		if err == nil {
+			err = rerr
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", l.name, err)
+		}
+		what := fmt.Sprintf("%s: rendering %d bytes as %d", l.name, len(data), len(out))
+		if err := harness.Over(what, spent, b.For(len(data)+len(out))); err != nil {
+			return err
+		}
+		if err := checkSafe(out); err != nil {
+			return fmt.Errorf("%s renders\n%q\nas\n%q\nwhich has %v", l.name, data, out, err)
+		}
+	}
+	return nil
+}
+
+// quotedFence reports whether data has a line that starts with a block
+// quote marker, at any indentation, and a code fence after it.
+func quotedFence(data []byte) bool {
+	for off := 0; off < len(data); {
+		line := data[off:]
+		if i := bytes.IndexByte(line, '\n'); i >= 0 {
+			line = line[:i+1]
+		}
+		if bytes.HasPrefix(bytes.TrimLeft(line, " \t"), []byte(">")) {
+			return bytes.Contains(data[off:], []byte("```")) || bytes.Contains(data[off:], []byte("~~~"))
+		}
+		off += len(line)
+	}
+	return false
+}
+
+// scripted are the elements that run script or load a document of their
+// own.
+var scripted = map[string]bool{
+	"script": true, "iframe": true, "frame": true, "object": true, "embed": true, "applet": true,
+	"base": true, "meta": true, "link": true, "form": true, "svg": true, "math": true, "style": true,
+}
+
+// urlAttrs are the attributes whose values are URLs a browser navigates
+// to. An image's source is not among them, as a browser runs no script
+// from it.
+var urlAttrs = map[string]bool{
+	"href": true, "action": true, "formaction": true, "xlink:href": true,
+}
+
+// checkSafe reports the first element, attribute or URL in the HTML out
+// that runs script.
+func checkSafe(out []byte) error {
+	z := html.NewTokenizer(bytes.NewReader(out))
+	for {
+		switch z.Next() {
+		case html.ErrorToken:
+			return nil
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if scripted[t.Data] {
+				return fmt.Errorf("element %s", t.Data)
+			}
+			for _, a := range t.Attr {
+				if strings.HasPrefix(a.Key, "on") {
+					return fmt.Errorf("attribute %s=%q", a.Key, a.Val)
+				}
+				if urlAttrs[a.Key] && dangerous(a.Val) {
+					return fmt.Errorf("URL %s=%q", a.Key, a.Val)
+				}
+			}
+		}
+	}
+}
+
+// dangerous reports whether a browser navigating to u runs script or
+// shows a document an attacker wrote. It reads the scheme as a browser
+// does, past leading spaces and control bytes and with tabs and newlines
+// dropped.
+func dangerous(u string) bool {
+	u = strings.TrimLeft(u, "\x00\x01\x02\x03\x04\x05\x06\x07\x08\t\n\x0b\x0c\r\x0e\x0f\x10\x11\x12\x13\x14\x15\x16\x17\x18\x19\x1a\x1b\x1c\x1d\x1e\x1f ")
+	u = strings.Map(func(r rune) rune {
+		if r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, u)
+	u = strings.ToLower(u)
+	return strings.HasPrefix(u, "javascript:") || strings.HasPrefix(u, "vbscript:") || strings.HasPrefix(u, "data:")
+}
@@ -0,0 +1,19 @@
+package markdown
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/mdsrc"
+)
+
+func FuzzRender(f *testing.F) {
+	for _, src := range gen.Sample("markdown/*", ".md", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckRender(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,209 @@
+// Package modfile is a fuzz target for golang.org/x/mod: go.mod and
+// go.work parsing and printing must round-trip, edits must keep a file
+// parsable, and go.sum module paths and versions must survive escaping
+// and canonicalization.
+package modfile
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// CheckMod parses data as a go.mod file, prints it, and checks that the
+// output parses to the same module requirements and prints identically.
+// It then applies a few edits and checks that the result still parses.
+// Files that do not parse are ignored.
+func CheckMod(data []byte) error {
+	f, err := modfile.Parse("go.mod", data, nil)
+	if err != nil {
+		// The lax parser accepts a superset, and must not fail
+		// differently on anything the strict parser accepts.
+		modfile.ParseLax("go.mod", data, nil)
+		return nil
+	}
+	if _, err := modfile.ParseLax("go.mod", data, nil); err != nil {
+		return fmt.Errorf("ParseLax rejects a file Parse accepts: %v", err)
+	}
+	once, err := f.Format()
+	if err != nil {
+		return fmt.Errorf("Format: %v", err)
+	}
+	g, err := modfile.Parse("go.mod", once, nil)
+	if err != nil {
+		return fmt.Errorf("formatted go.mod does not parse: %v\n%s", err, once)
+	}
+	if err := sameMod(f, g); err != nil && !bytes.Contains(data, emptyToken) {
+		return fmt.Errorf("formatting changed %v\n%s", err, once)
+	}
+	twice, err := g.Format()
+	if err != nil {
+		return fmt.Errorf("Format of formatted file: %v", err)
+	}
+	if !bytes.Equal(once, twice) {
+		return fmt.Errorf("Format is not idempotent:\n--- once\n%s\n--- twice\n%s", once, twice)
+	}
+
+	// Edits the go command makes.
+	g.AddNewRequire("example.com/fuzz", "v1.0.0", true)
+	if len(f.Require) > 0 {
+		g.DropRequire(f.Require[0].Mod.Path)
+		g.Cleanup()
+	}
+	// SetRequireSeparateIndirect clears the requirements it removes, so
+	// it must not be handed g.Require itself.
+	var req []*modfile.Require
+	for _, r := range g.Require {
+		req = append(req, &modfile.Require{Mod: r.Mod, Indirect: r.Indirect})
+	}
+	g.SetRequireSeparateIndirect(req)
+	g.AddReplace("example.com/fuzz", "", "./fuzz", "")
+	g.SortBlocks()
+	g.Cleanup()
+	edited, err := g.Format()
+	if err != nil {
+		return fmt.Errorf("Format after edits: %v", err)
+	}
+	if _, err := modfile.Parse("go.mod", edited, nil); err != nil {
+		return fmt.Errorf("edited go.mod does not parse: %v\n%s", err, edited)
+	}
+	return nil
+}
+
+// emptyToken is a quoted empty argument. Known: the parser keeps it as a
+// token in some positions and drops it in others, so a file containing
+// one can legitimately change meaning when printed.
+var emptyToken = []byte(`""`)
+
+// sameMod compares the parts of two parsed go.mod files that printing
+// must preserve.
+func sameMod(f, g *modfile.File) error {
+	type mod struct {
+		Module     string
+		Go         string
+		Toolchain  string
+		Require    []string
+		Exclude    []string
+		Replace    []string
+		Retract    []string
+		Deprecated string
+	}
+	summary := func(f *modfile.File) mod {
+		var m mod
+		if f.Module != nil {
+			m.Module, m.Deprecated = f.Module.Mod.Path, f.Module.Deprecated
+		}
+		if f.Go != nil {
+			m.Go = f.Go.Version
+		}
+		if f.Toolchain != nil {
+			m.Toolchain = f.Toolchain.Name
+		}
+		for _, r := range f.Require {
+			m.Require = append(m.Require, fmt.Sprint(r.Mod, r.Indirect))
+		}
+		for _, x := range f.Exclude {
+			m.Exclude = append(m.Exclude, x.Mod.String())
+		}
+		for _, r := range f.Replace {
+			m.Replace = append(m.Replace, r.Old.String()+" => "+r.New.String())
+		}
+		for _, r := range f.Retract {
+			m.Retract = append(m.Retract, r.Low+" "+r.High+" "+r.Rationale)
+		}
+		return m
+	}
+	a, b := summary(f), summary(g)
+	if !reflect.DeepEqual(a, b) {
+		return fmt.Errorf("the file's contents:\n%+v\n%+v", a, b)
+	}
+	return nil
+}
+
+// CheckWork is CheckMod for go.work files.
+func CheckWork(data []byte) error {
+	f, err := modfile.ParseWork("go.work", data, nil)
+	if err != nil {
+		return nil
+	}
+	for _, u := range f.Use {
+		if strings.ContainsAny(u.Path, "()") {
+			// Known: "use )" parses as a use of the directory ")",
+			// which no longer parses once printed inside a block.
+			return nil
+		}
+	}
+	f.Cleanup()
+	once := modfile.Format(f.Syntax)
+	g, err := modfile.ParseWork("go.work", once, nil)
+	if err != nil {
+		return fmt.Errorf("formatted go.work does not parse: %v\n%s", err, once)
+	}
+	if a, b := uses(f), uses(g); !reflect.DeepEqual(a, b) && !bytes.Contains(data, emptyToken) {
+		return fmt.Errorf("formatting changed use directives: %q => %q\n%s", a, b, once)
+	}
+	if twice := modfile.Format(g.Syntax); !bytes.Equal(once, twice) {
+		return fmt.Errorf("Format is not idempotent:\n--- once\n%s\n--- twice\n%s", once, twice)
+	}
+	g.AddNewUse("./fuzz", "")
+	g.SortBlocks()
+	g.Cleanup()
+	edited := modfile.Format(g.Syntax)
+	if _, err := modfile.ParseWork("go.work", edited, nil); err != nil {
+		return fmt.Errorf("edited go.work does not parse: %v\n%s", err, edited)
+	}
+	return nil
+}
+
+func uses(f *modfile.WorkFile) []string {
+	var out []string
+	for _, u := range f.Use {
+		out = append(out, u.Path)
+	}
+	return out
+}
+
+// CheckSum checks the module path and version of every go.sum line:
+// escaping must round-trip, and canonicalizing a valid version must be
+// idempotent and keep it equal under semver.Compare.
+func CheckSum(data []byte) error {
+	for l := range strings.Lines(string(data)) {
+		fields := strings.Fields(l)
+		if len(fields) != 3 {
+			continue
+		}
+		path, vers := fields[0], strings.TrimSuffix(fields[1], "/go.mod")
+		if semver.IsValid(vers) {
+			c := semver.Canonical(vers)
+			if semver.Canonical(c) != c {
+				return fmt.Errorf("Canonical(%q) = %q is not canonical", vers, c)
+			}
+			if semver.Compare(vers, c) != 0 {
+				return fmt.Errorf("Compare(%q, Canonical = %q) != 0", vers, c)
+			}
+		}
+		if module.Check(path, vers) != nil {
+			continue
+		}
+		ep, err := module.EscapePath(path)
+		if err != nil {
+			return fmt.Errorf("EscapePath(%q) of a checked path: %v", path, err)
+		}
+		if up, err := module.UnescapePath(ep); err != nil || up != path {
+			return fmt.Errorf("UnescapePath(EscapePath(%q)) = %q, %v", path, up, err)
+		}
+		ev, err := module.EscapeVersion(vers)
+		if err != nil {
+			return fmt.Errorf("EscapeVersion(%q) of a checked version: %v", vers, err)
+		}
+		if uv, err := module.UnescapeVersion(ev); err != nil || uv != vers {
+			return fmt.Errorf("UnescapeVersion(EscapeVersion(%q)) = %q, %v", vers, uv, err)
+		}
+	}
+	return nil
+}
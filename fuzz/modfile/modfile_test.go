@@ -0,0 +1,31 @@
+package modfile
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/modsrc"
+)
+
+func fuzz(f *testing.F, seeds [][]byte, check func([]byte) error) {
+	for _, data := range seeds {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := check(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzModFile(f *testing.F) {
+	fuzz(f, gen.Sample("mod/gomod", ".mod", 8), CheckMod)
+}
+
+func FuzzWorkFile(f *testing.F) {
+	fuzz(f, gen.Sample("mod/gowork", ".work", 8), CheckWork)
+}
+
+func FuzzSumFile(f *testing.F) {
+	fuzz(f, gen.Sample("mod/gosum", ".sum", 8), CheckSum)
+}
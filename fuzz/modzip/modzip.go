@@ -0,0 +1,529 @@
+// Package modzip is a fuzz target for golang.org/x/mod/zip, which checks
+// and extracts the module zips the go command downloads, and for the go
+// command reading a module proxy.
+//
+// CheckZip takes a zip of the module version its first entry is named
+// for. The files zip.CheckZip finds valid must be ones a module zip may
+// hold: under the zip's prefix, clean, valid to module.CheckFilePath, no
+// two the same under case folding, none where another has a directory,
+// go.mod only at the root and nothing past its size limit. Unzip must
+// extract a zip that checks exactly when each valid entry reads whole,
+// and then write those files and no others, each a regular file with
+// the entry's data, hashing with dirhash as the zip does. The files it
+// writes must check again as a directory, and make a zip of the files
+// that check as valid there.
+//
+// CheckProxy serves a module's @v/list and a version's .info, .mod and
+// .zip from a file:// GOPROXY, and has the go command download the
+// version and list the module's versions. The go command must not crash
+// or hang. A version it downloads must have a .info naming it and a zip
+// zip.CheckZip accepts, extracted as Unzip extracts it and with the
+// hashes dirhash gives; the versions it lists must be valid, in order,
+// and in the list.
+package modzip
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+	modzip "golang.org/x/mod/zip"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds each run of the go command.
+var Timeout = 30 * time.Second
+
+// MaxEntries bounds the entries of a zip, as each valid file is compared
+// with every other.
+const MaxEntries = 1024
+
+// MaxExtract bounds the sizes the entries of a zip may declare, all
+// together, for it to be extracted. Past it, only zip.CheckZip is run: a
+// zip may declare 500 MiB, which Unzip would write in full.
+const MaxExtract = 1 << 20
+
+// CheckZip checks data as a module zip of the version its first entry
+// is named for. A zip that archive/zip does not read, or that is of an
+// invalid module path or a version that is not canonical, is skipped.
+func CheckZip(data []byte) error {
+	z, m, ok := open(data)
+	if !ok {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "fuzzmodzip")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "m.zip")
+	if err := os.WriteFile(file, data, 0o666); err != nil {
+		return err
+	}
+	cf, err := modzip.CheckZip(m, file)
+	if err != nil && cf.Err() == nil {
+		return fmt.Errorf("CheckZip(%v) fails, but finds no invalid file: %v", m, err)
+	}
+	if err := checkValid(m, z, cf); err != nil {
+		return err
+	}
+	var declared uint64
+	for _, zf := range z.File {
+		declared += min(zf.UncompressedSize64, MaxExtract+1)
+	}
+	if declared > MaxExtract {
+		return nil
+	}
+
+	out := filepath.Join(dir, "out")
+	err = modzip.Unzip(out, m, file)
+	if cf.Err() != nil {
+		if err == nil {
+			return fmt.Errorf("Unzip(%v) extracts a zip CheckZip rejects: %v", m, cf.Err())
+		}
+		return nil
+	}
+	want, readErr := readValid(z, m, cf.Valid)
+	switch {
+	case err != nil && readErr == nil:
+		return fmt.Errorf("Unzip(%v) fails on a zip that checks and reads: %v", m, err)
+	case err == nil && readErr != nil:
+		return fmt.Errorf("Unzip(%v) extracts a zip with an entry that does not read: %v", m, readErr)
+	case err != nil:
+		return nil
+	}
+	if err := checkExtracted(out, m, want); err != nil {
+		return err
+	}
+	if !slices.ContainsFunc(z.File, func(zf *zip.File) bool { return strings.HasSuffix(zf.Name, "/") }) {
+		zipHash, err := dirhash.HashZip(file, dirhash.Hash1)
+		if err != nil {
+			return fmt.Errorf("HashZip(%v): %v", m, err)
+		}
+		dirHash, err := dirhash.HashDir(out, m.Path+"@"+m.Version, dirhash.Hash1)
+		if err != nil {
+			return fmt.Errorf("HashDir(%v): %v", m, err)
+		}
+		if zipHash != dirHash {
+			return fmt.Errorf("%v hashes as %s, but extracted as %s", m, zipHash, dirHash)
+		}
+	}
+	return checkRezip(out, dir, m, want)
+}
+
+// open reads data as a zip, and returns it with the module version its
+// first entry, "<path>@<version>/...", is named for, if the version is
+// a valid one of a valid module path.
+func open(data []byte) (*zip.Reader, module.Version, bool) {
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil || len(z.File) == 0 || len(z.File) > MaxEntries {
+		return nil, module.Version{}, false
+	}
+	name := z.File[0].Name
+	at := strings.Index(name, "@")
+	if at < 0 {
+		return nil, module.Version{}, false
+	}
+	slash := strings.Index(name[at:], "/")
+	if slash < 0 {
+		return nil, module.Version{}, false
+	}
+	m := module.Version{Path: name[:at], Version: name[at+1 : at+slash]}
+	if module.CanonicalVersion(m.Version) != m.Version || module.Check(m.Path, m.Version) != nil {
+		return nil, module.Version{}, false
+	}
+	return z, m, true
+}
+
+// checkValid checks that the files cf finds valid in z, a zip of m, are
+// files a module zip may hold, and that every entry of z is valid,
+// invalid or a directory.
+func checkValid(m module.Version, z *zip.Reader, cf modzip.CheckedFiles) error {
+	prefix := m.Path + "@" + m.Version + "/"
+	sizes := make(map[string]uint64) // of the first entry of each name
+	for _, zf := range slices.Backward(z.File) {
+		sizes[zf.Name] = zf.UncompressedSize64
+	}
+	var names []string
+	var total uint64
+	for _, v := range cf.Valid {
+		name, ok := strings.CutPrefix(v, prefix)
+		switch {
+		case !ok || name == "" || strings.HasSuffix(name, "/"):
+			return fmt.Errorf("CheckZip(%v) finds %q valid", m, v)
+		case path.Clean(name) != name:
+			return fmt.Errorf("CheckZip(%v) finds %q valid, but it is not clean", m, v)
+		case module.CheckFilePath(name) != nil:
+			return fmt.Errorf("CheckZip(%v) finds %q valid, but %v", m, v, module.CheckFilePath(name))
+		case strings.EqualFold(path.Base(name), "go.mod") && name != "go.mod":
+			return fmt.Errorf("CheckZip(%v) finds %q valid", m, v)
+		case name == "go.mod" && sizes[v] > modzip.MaxGoMod, name == "LICENSE" && sizes[v] > modzip.MaxLICENSE:
+			return fmt.Errorf("CheckZip(%v) finds %q valid, but it is of %d bytes", m, v, sizes[v])
+		}
+		for _, other := range names {
+			if strings.EqualFold(name, other) {
+				return fmt.Errorf("CheckZip(%v) finds both %q and %q valid", m, other, name)
+			}
+		}
+		names = append(names, name)
+		total += min(sizes[v], modzip.MaxZipFile+1)
+	}
+	if total > modzip.MaxZipFile && cf.SizeError == nil {
+		return fmt.Errorf("CheckZip(%v) finds files of %d bytes valid", m, total)
+	}
+	for _, name := range names {
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if i := slices.IndexFunc(names, func(n string) bool { return strings.EqualFold(n, dir) }); i >= 0 {
+				return fmt.Errorf("CheckZip(%v) finds both %q and %q valid", m, names[i], name)
+			}
+		}
+	}
+	dirs := 0
+	for _, zf := range z.File {
+		if zf.Name == prefix || strings.HasPrefix(zf.Name, prefix) && strings.HasSuffix(zf.Name, "/") {
+			dirs++
+		}
+	}
+	if n := len(cf.Valid) + len(cf.Invalid); n > len(z.File) || n < len(z.File)-dirs {
+		return fmt.Errorf("CheckZip(%v) finds %d files valid and %d invalid of %d entries, %d of them directories", m, len(cf.Valid), len(cf.Invalid), len(z.File), dirs)
+	}
+	return nil
+}
+
+// readValid reads the entries of z that valid names, which are of m,
+// by their names under m's prefix. It fails if one does not read to its
+// end.
+func readValid(z *zip.Reader, m module.Version, valid []string) (map[string][]byte, error) {
+	prefix := m.Path + "@" + m.Version + "/"
+	files := make(map[string][]byte)
+	for _, zf := range z.File {
+		if !slices.Contains(valid, zf.Name) {
+			continue
+		}
+		r, err := zf.Open()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", zf.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", zf.Name, err)
+		}
+		files[strings.TrimPrefix(zf.Name, prefix)] = data
+	}
+	return files, nil
+}
+
+// checkExtracted checks that dir, where a zip of m was extracted, holds
+// the files of want and no others, each a regular file with its data.
+func checkExtracted(dir string, m module.Version, want map[string][]byte) error {
+	n := 0
+	err := filepath.WalkDir(dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		data, ok := want[name]
+		if !ok || !d.Type().IsRegular() {
+			return fmt.Errorf("%v extracts to %s, a %v the zip has no valid file for", m, name, d.Type())
+		}
+		got, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, data) {
+			return fmt.Errorf("%v extracts %s as %q, not %q", m, name, got, data)
+		}
+		n++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if n != len(want) {
+		return fmt.Errorf("%v extracts %d files of the %d it has", m, n, len(want))
+	}
+	return nil
+}
+
+// checkRezip checks that out, where a zip of m was extracted with the
+// files of want, checks as a directory, with every file valid or left
+// out, and that the files valid there make a zip, written into tmp,
+// that checks with the same files valid.
+func checkRezip(out, tmp string, m module.Version, want map[string][]byte) error {
+	cf, err := modzip.CheckDir(out)
+	if err != nil {
+		return fmt.Errorf("%v extracts to a directory that does not check: %v", m, err)
+	}
+	var valid []string
+	for _, v := range cf.Valid {
+		rel, err := filepath.Rel(out, v)
+		if err != nil {
+			return err
+		}
+		valid = append(valid, filepath.ToSlash(rel))
+	}
+	omitted := func(name string) bool {
+		return slices.ContainsFunc(cf.Omitted, func(fe modzip.FileError) bool {
+			rel, err := filepath.Rel(out, fe.Path)
+			rel = filepath.ToSlash(rel)
+			return err == nil && (name == rel || strings.HasPrefix(name, rel+"/"))
+		})
+	}
+	for name := range want {
+		if !slices.Contains(valid, name) && !omitted(name) {
+			return fmt.Errorf("%v extracts %s, which CheckDir neither finds valid nor leaves out", m, name)
+		}
+	}
+
+	var b bytes.Buffer
+	if err := modzip.CreateFromDir(&b, m, out); err != nil {
+		return fmt.Errorf("CreateFromDir(%v) fails on a directory that checks: %v", m, err)
+	}
+	file := filepath.Join(tmp, "again.zip")
+	if err := os.WriteFile(file, b.Bytes(), 0o666); err != nil {
+		return err
+	}
+	again, err := modzip.CheckZip(m, file)
+	if err != nil {
+		return fmt.Errorf("CreateFromDir(%v) makes a zip that does not check: %v", m, err)
+	}
+	prefix := m.Path + "@" + m.Version + "/"
+	for i, v := range again.Valid {
+		again.Valid[i] = strings.TrimPrefix(v, prefix)
+	}
+	slices.Sort(valid)
+	slices.Sort(again.Valid)
+	if !slices.Equal(valid, again.Valid) {
+		return fmt.Errorf("CreateFromDir(%v) makes a zip of %q, but CheckDir finds %q valid", m, again.Valid, valid)
+	}
+	return nil
+}
+
+// toolchain reports whether there is a go command on $PATH. On OSS-Fuzz
+// runners there is none, and CheckProxy does nothing.
+var toolchain = sync.OnceValue(func() bool {
+	_, err := exec.LookPath("go")
+	return err == nil
+})
+
+// A download is what go mod download -json reports of a module version.
+type download struct {
+	Version       string
+	Error         string
+	Info, GoMod   string
+	Zip, Dir      string
+	Sum, GoModSum string
+}
+
+// A listedModule is what go list -m -json reports of a module.
+type listedModule struct {
+	Versions []string
+	Error    *struct{ Err string }
+}
+
+// CheckProxy serves list, the @v/list of a module, and info, mod and
+// zipData, the .info, .mod and .zip of a version of it, from a file://
+// proxy, and checks what the go command downloads and lists from it. The
+// module and version are those zipData's first entry is named for, as
+// for CheckZip; if that is not a valid version of a valid module path,
+// it is skipped. It returns a *harness.Failure if the go command crashes
+// or hangs.
+func CheckProxy(list, info, mod, zipData []byte) error {
+	if !toolchain() {
+		return nil
+	}
+	z, m, ok := open(zipData)
+	if !ok {
+		return nil
+	}
+	escPath, err := module.EscapePath(m.Path)
+	if err != nil {
+		return nil
+	}
+	escVersion, err := module.EscapeVersion(m.Version)
+	if err != nil {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "fuzzmodproxy")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	at := filepath.Join(dir, "proxy", filepath.FromSlash(escPath), "@v")
+	if err := os.MkdirAll(at, 0o777); err != nil {
+		return err
+	}
+	for name, data := range map[string][]byte{
+		"list":               list,
+		escVersion + ".info": info,
+		escVersion + ".mod":  mod,
+		escVersion + ".zip":  zipData,
+	} {
+		if err := os.WriteFile(filepath.Join(at, name), data, 0o666); err != nil {
+			return err
+		}
+	}
+	work := filepath.Join(dir, "work")
+	if err := os.Mkdir(work, 0o777); err != nil {
+		return err
+	}
+	env := []string{
+		"GOPROXY=file://" + filepath.ToSlash(filepath.Join(dir, "proxy")),
+		"GOMODCACHE=" + filepath.Join(dir, "cache"),
+		"GOFLAGS=-modcacherw",
+		"GOSUMDB=off",
+		"GONOSUMDB=",
+		"GOPRIVATE=",
+	}
+
+	out, err := goCommand(work, env, "mod", "download", "-json", m.String())
+	if err != nil {
+		return err
+	}
+	var d download
+	if err := json.Unmarshal(out, &d); err != nil {
+		return fmt.Errorf("go mod download: %v\n%s", err, out)
+	}
+	if d.Error == "" {
+		if err := checkDownload(m, d, z, info, mod, zipData, filepath.Join(at, escVersion+".zip")); err != nil {
+			return err
+		}
+	}
+
+	out, err = goCommand(work, env, "list", "-e", "-m", "-retracted", "-versions", "-json", m.String())
+	if err != nil {
+		return err
+	}
+	var l listedModule
+	if err := json.Unmarshal(out, &l); err != nil {
+		return fmt.Errorf("go list: %v\n%s", err, out)
+	}
+	return checkVersions(list, l.Versions)
+}
+
+// checkDownload checks that d, the download of m, is of the version that
+// info names and of a zip, zipData in file, that zip.CheckZip accepts,
+// extracted as Unzip would, with the hashes dirhash gives the zip and
+// mod.
+func checkDownload(m module.Version, d download, z *zip.Reader, info, mod, zipData []byte, file string) error {
+	var rev struct {
+		Version string
+		Time    time.Time
+	}
+	if err := json.Unmarshal(info, &rev); err != nil || rev.Version != m.Version {
+		return fmt.Errorf("go mod download %v succeeds with the .info %q", m, info)
+	}
+	cf, err := modzip.CheckZip(m, file)
+	if err != nil {
+		return fmt.Errorf("go mod download %v succeeds with a zip CheckZip rejects: %v", m, err)
+	}
+	if d.Version != m.Version {
+		return fmt.Errorf("go mod download %v downloads %s", m, d.Version)
+	}
+	for name, data := range map[string][]byte{d.GoMod: mod, d.Zip: zipData} {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("go mod download %v: %v", m, err)
+		}
+		if !bytes.Equal(got, data) {
+			return fmt.Errorf("go mod download %v caches %s as %q, not %q", m, name, got, data)
+		}
+	}
+	sum, err := dirhash.HashZip(file, dirhash.Hash1)
+	if err != nil {
+		return fmt.Errorf("HashZip(%v): %v", m, err)
+	}
+	modSum, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(mod)), nil
+	})
+	if err != nil {
+		return fmt.Errorf("Hash1(%v go.mod): %v", m, err)
+	}
+	if d.Sum != sum || d.GoModSum != modSum {
+		return fmt.Errorf("go mod download %v gives the hashes %s and %s, not %s and %s", m, d.Sum, d.GoModSum, sum, modSum)
+	}
+	want, err := readValid(z, m, cf.Valid)
+	if err != nil {
+		return fmt.Errorf("go mod download %v succeeds with a zip whose entries do not read: %v", m, err)
+	}
+	return checkExtracted(d.Dir, m, want)
+}
+
+// checkVersions checks that versions, as the go command lists them from
+// list, are valid versions that are not pseudo-versions, in order, and
+// each the first field of a line of list.
+func checkVersions(list []byte, versions []string) error {
+	var listed []string
+	for l := range strings.Lines(string(list)) {
+		if f := strings.Fields(l); len(f) > 0 {
+			listed = append(listed, f[0])
+		}
+	}
+	for i, v := range versions {
+		switch {
+		case !semver.IsValid(v) || module.IsPseudoVersion(v):
+			return fmt.Errorf("go list lists the version %q", v)
+		case !slices.Contains(listed, v):
+			return fmt.Errorf("go list lists %q, which is not in the list %q", v, list)
+		case i > 0 && semver.Compare(versions[i-1], v) > 0:
+			return fmt.Errorf("go list lists %q before %q", versions[i-1], v)
+		}
+	}
+	return nil
+}
+
+// goCommand runs the go command in dir, with env added to an environment
+// isolated from the network and other modules, and returns its standard
+// output. It returns a *harness.Failure if the command crashes or hangs;
+// the command failing is not an error.
+func goCommand(dir string, env []string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local", "GOWORK=off", "GO111MODULE=on", "GOVCS=*:off")
+	cmd.Env = append(cmd.Env, env...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, &harness.Failure{Kind: harness.Hang, After: Timeout}
+	}
+	if isCrash(stderr.Bytes()) {
+		return nil, &harness.Failure{Kind: harness.Panic, Value: fmt.Sprintf("go %s: %v\n%s", strings.Join(args, " "), err, stderr.Bytes())}
+	}
+	return stdout.Bytes(), nil
+}
+
+// isCrash reports whether out, the output of the go command, shows it
+// crashing rather than reporting errors.
+func isCrash(out []byte) bool {
+	for l := range strings.Lines(string(out)) {
+		if strings.HasPrefix(l, "panic: ") || strings.HasPrefix(l, "fatal error: ") {
+			return true
+		}
+	}
+	return false
+}
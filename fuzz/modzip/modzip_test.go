@@ -0,0 +1,45 @@
+package modzip
+
+import (
+	"path"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/modsrc"
+)
+
+func FuzzZip(f *testing.F) {
+	for _, data := range gen.Sample("mod/*", ".zip", 32) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckZip(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzProxy(f *testing.F) {
+	g := gen.Lookup("mod/proxy")
+	for i := range 8 {
+		var list, info, mod, zip []byte
+		for _, file := range g.Generate(i) {
+			switch path.Ext(file.Name) {
+			case "":
+				list = file.Data
+			case ".info":
+				info = file.Data
+			case ".mod":
+				mod = file.Data
+			case ".zip":
+				zip = file.Data
+			}
+		}
+		f.Add(list, info, mod, zip)
+	}
+	f.Fuzz(func(t *testing.T, list, info, mod, zip []byte) {
+		if err := CheckProxy(list, info, mod, zip); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
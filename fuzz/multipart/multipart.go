@@ -0,0 +1,363 @@
+// Package multipart is a fuzz target for mime/multipart. CheckReader
+// reads the header at the start of a document for the boundary of the
+// multipart body after it, and reads the body with a Reader, part by part
+// with NextPart and NextRawPart and whole with ReadForm, and each body
+// nested in a part the same, within a budget of time and memory linear in
+// the size of the document, past which it is reported as a blowup. Parts
+// are read up to PartLimit bytes each and MaxParts to a body, as a server
+// would limit them. NextPart must read what NextRawPart does, with
+// quoted-printable contents decoded; ReadForm must hold the fields and
+// files NextPart reads; FileName must name a file in the directory a form
+// is saved in; and the parts read must write, with a Writer, a body that
+// reads back the same.
+package multipart
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// DefaultBudget is per byte of the document. It allows for reading a
+// body three ways, at every depth it is nested to, and for the header
+// maps of parts of one-byte headers.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: time.Second, Memory: 64 << 20},
+	Per:  harness.Cost{Time: 2 * time.Microsecond, Memory: 1024},
+}
+
+const (
+	PartLimit = 1 << 20 // bytes read of the content of a part
+	MaxParts  = 1000    // parts read of a body, as ReadForm reads
+	MaxDepth  = 4       // bodies nested in a part read
+)
+
+// FormMemory is the maxMemory ReadForm is called with, small so that
+// files are written to disk.
+const FormMemory = 256
+
+var (
+	errPartLimit = errors.New("part longer than PartLimit")
+	errMaxParts  = errors.New("body of more than MaxParts parts")
+)
+
+// A part is what is read of a part: its header, content and the error
+// reading it ended with, and for NextPart, its form and file names.
+type part struct {
+	header             textproto.MIMEHeader
+	content            []byte
+	err                error
+	formName, fileName string
+}
+
+func (p part) String() string {
+	return fmt.Sprintf("%q %q", p.header, p.content)
+}
+
+// A reading is what NextPart or NextRawPart reads of a body: its parts
+// and the error that ended reading, nil at the close delimiter.
+type reading struct {
+	parts []part
+	end   error
+}
+
+// A body is a multipart body and what is read of it.
+type body struct {
+	data         []byte
+	boundary     string
+	depth        int
+	decoded, raw reading
+	form         *multipart.Form
+	formErr      error
+}
+
+// CheckReader reads the document in data within b. A document with no
+// multipart Content-Type is ignored, and errors reading are expected, and
+// checked only against each other.
+func CheckReader(data []byte, b harness.Budget) error {
+	br := bufio.NewReader(bytes.NewReader(data))
+	h, err := textproto.NewReader(br).ReadMIMEHeader()
+	if err != nil {
+		return nil
+	}
+	mt, params, err := mime.ParseMediaType(h.Get("Content-Type"))
+	boundary, ok := params["boundary"]
+	if err != nil || !strings.HasPrefix(mt, "multipart/") || !ok {
+		return nil
+	}
+	rest, _ := io.ReadAll(br)
+	limit := b.For(len(data))
+	var bodies []*body
+	defer func() {
+		for _, bd := range bodies {
+			if bd.form != nil {
+				bd.form.RemoveAll()
+			}
+		}
+	}()
+	err = harness.Measure(fmt.Sprintf("reading %d bytes", len(data)), limit, func() {
+		bodies = readAll(rest, boundary)
+	})
+	if err != nil {
+		return err
+	}
+	return harness.Run(harness.HangFactor*limit.Time, func() error {
+		for _, bd := range bodies {
+			if err := check(bd); err != nil {
+				return fmt.Errorf("body at depth %d, boundary %q: %v", bd.depth, bd.boundary, err)
+			}
+		}
+		return nil
+	})
+}
+
+// readAll reads the body in data, and each body nested in one of its
+// parts, to MaxDepth.
+func readAll(data []byte, boundary string) []*body {
+	bodies := []*body{{data: data, boundary: boundary}}
+	for i := 0; i < len(bodies); i++ {
+		bd := bodies[i]
+		bd.decoded = read(bd.data, bd.boundary, false)
+		bd.raw = read(bd.data, bd.boundary, true)
+		bd.form, bd.formErr = multipart.NewReader(bytes.NewReader(bd.data), bd.boundary).ReadForm(FormMemory)
+		if bd.depth == MaxDepth {
+			continue
+		}
+		for _, p := range bd.raw.parts {
+			mt, params, err := mime.ParseMediaType(p.header.Get("Content-Type"))
+			if b, ok := params["boundary"]; p.err == nil && err == nil && strings.HasPrefix(mt, "multipart/") && ok {
+				bodies = append(bodies, &body{data: p.content, boundary: b, depth: bd.depth + 1})
+			}
+		}
+	}
+	return bodies
+}
+
+// read reads the parts of the body in data with NextRawPart if raw is
+// set, and NextPart if not, up to the first error.
+func read(data []byte, boundary string, raw bool) reading {
+	r := multipart.NewReader(bytes.NewReader(data), boundary)
+	next := r.NextPart
+	if raw {
+		next = r.NextRawPart
+	}
+	var rd reading
+	for range MaxParts {
+		p, err := next()
+		if err == io.EOF {
+			return rd
+		}
+		if err != nil {
+			rd.end = err
+			return rd
+		}
+		pt := part{header: p.Header}
+		pt.content, pt.err = io.ReadAll(io.LimitReader(p, PartLimit+1))
+		if len(pt.content) > PartLimit {
+			pt.content, pt.err = pt.content[:PartLimit], errPartLimit
+		}
+		if !raw {
+			pt.formName, pt.fileName = p.FormName(), p.FileName()
+		}
+		rd.parts = append(rd.parts, pt)
+		if pt.err != nil {
+			rd.end = pt.err
+			return rd
+		}
+	}
+	rd.end = errMaxParts
+	return rd
+}
+
+// check checks what is read of bd.
+func check(bd *body) error {
+	if err := checkDecoded(bd.decoded, bd.raw); err != nil {
+		return err
+	}
+	if err := checkFileNames(bd.decoded); err != nil {
+		return err
+	}
+	if err := checkForm(bd); err != nil {
+		return err
+	}
+	if bd.raw.end == nil {
+		return checkWrite(bd.raw.parts)
+	}
+	return nil
+}
+
+// checkDecoded checks the parts NextPart reads against those NextRawPart
+// does: the same header but for a quoted-printable
+// Content-Transfer-Encoding, which NextPart drops, and the same content,
+// or that content decoded.
+func checkDecoded(decoded, raw reading) error {
+	for i := range min(len(decoded.parts), len(raw.parts)) {
+		d, r := decoded.parts[i], raw.parts[i]
+		want := maps.Clone(r.header)
+		qp := strings.EqualFold(want.Get("Content-Transfer-Encoding"), "quoted-printable")
+		if qp {
+			want.Del("Content-Transfer-Encoding")
+		}
+		if !sameHeader(d.header, want) {
+			return fmt.Errorf("part %d: NextPart reads the header %q, NextRawPart %q", i, d.header, r.header)
+		}
+		if r.err != nil {
+			break
+		}
+		content, err := r.content, error(nil)
+		if qp {
+			content, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(r.content)))
+		}
+		if d.err == errPartLimit {
+			continue
+		}
+		if !bytes.Equal(d.content, content) || (d.err == nil) != (err == nil) {
+			return fmt.Errorf("part %d: NextPart reads %q (%v) of %q, not %q (%v)", i, d.content, d.err, r.content, content, err)
+		}
+	}
+	if raw.end != nil {
+		return nil
+	}
+	n := len(decoded.parts)
+	if decoded.end == nil && n != len(raw.parts) || decoded.end != nil && (n == 0 || decoded.parts[n-1].err != decoded.end) {
+		return fmt.Errorf("NextRawPart reads %d parts to the close delimiter, NextPart %d and stops at %v", len(raw.parts), n, decoded.end)
+	}
+	return nil
+}
+
+// checkFileNames checks that the FileName of each part read is one a
+// file can be saved as in a directory without leaving it.
+func checkFileNames(rd reading) error {
+	for i, p := range rd.parts {
+		name := p.fileName
+		// Known: FileName passes the filename through filepath.Base,
+		// which leaves ".", ".." and the root as they are.
+		if name == "" || name == "." || name == ".." || name == string(filepath.Separator) {
+			continue
+		}
+		if !filepath.IsLocal(name) || filepath.Base(name) != name {
+			return fmt.Errorf("part %d: FileName is %q, with a Content-Disposition of %q", i, name, p.header.Get("Content-Disposition"))
+		}
+	}
+	return nil
+}
+
+// checkForm checks the form ReadForm reads against the parts NextPart
+// does, where NextPart reads to the close delimiter: each part with a
+// form name is a value, or with a file name too, a file.
+func checkForm(bd *body) error {
+	if bd.decoded.end != nil {
+		return nil
+	}
+	// ReadForm limits the headers of the files of a form together, where
+	// NextPart limits those of each part.
+	if errors.Is(bd.formErr, multipart.ErrMessageTooLarge) {
+		return nil
+	}
+	if bd.formErr != nil {
+		return fmt.Errorf("NextPart reads the body to the close delimiter, but ReadForm fails: %v", bd.formErr)
+	}
+	values := map[string][]string{}
+	files := map[string][]part{}
+	for _, p := range bd.decoded.parts {
+		switch {
+		case p.formName == "":
+		case p.fileName == "":
+			values[p.formName] = append(values[p.formName], string(p.content))
+		default:
+			files[p.formName] = append(files[p.formName], p)
+		}
+	}
+	if !maps.EqualFunc(bd.form.Value, values, slices.Equal) {
+		return fmt.Errorf("ReadForm reads the values %q, NextPart %q", bd.form.Value, values)
+	}
+	if len(bd.form.File) != len(files) {
+		return fmt.Errorf("ReadForm reads files named %q, NextPart %d names", slices.Sorted(maps.Keys(bd.form.File)), len(files))
+	}
+	for name, want := range files {
+		got := bd.form.File[name]
+		if len(got) != len(want) {
+			return fmt.Errorf("ReadForm reads %d files named %q, NextPart %d", len(got), name, len(want))
+		}
+		for i, fh := range got {
+			content, err := readFile(fh)
+			if err != nil {
+				return fmt.Errorf("file %d named %q, %q, does not open: %v", i, name, fh.Filename, err)
+			}
+			w := want[i]
+			if fh.Filename != w.fileName || !sameHeader(fh.Header, w.header) || fh.Size != int64(len(content)) || !bytes.Equal(content, w.content) {
+				return fmt.Errorf("file %d named %q: ReadForm reads %q %q of %d bytes, %q, NextPart %q %q, %q", i, name, fh.Filename, fh.Header, fh.Size, content, w.fileName, w.header, w.content)
+			}
+		}
+	}
+	return nil
+}
+
+// readFile returns the content of the file fh.
+func readFile(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// checkWrite writes parts with a Writer and checks that they read back
+// the same with NextRawPart.
+func checkWrite(parts []part) error {
+	// Known: ReadMIMEHeader ends a value folded onto a blank line with a
+	// space, which it trims from a value on one line.
+	if slices.ContainsFunc(parts, func(p part) bool { return trailingSpace(p.header) }) {
+		return nil
+	}
+	var out bytes.Buffer
+	w := multipart.NewWriter(&out)
+	for i, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return fmt.Errorf("part %d, with header %q, does not write: %v", i, p.header, err)
+		}
+		pw.Write(p.content)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("Close: %v", err)
+	}
+	again := read(out.Bytes(), w.Boundary(), true)
+	if again.end != nil || !slices.EqualFunc(again.parts, parts, func(a, b part) bool {
+		return sameHeader(a.header, b.header) && bytes.Equal(a.content, b.content)
+	}) {
+		return fmt.Errorf("parts\n%v\nwrite as\n%q\nwhich reads back as\n%v (%v)", parts, out.Bytes(), again.parts, again.end)
+	}
+	return nil
+}
+
+// trailingSpace reports whether a value in h ends with a space or tab.
+func trailingSpace(h textproto.MIMEHeader) bool {
+	for _, vs := range h {
+		for _, v := range vs {
+			if strings.HasSuffix(v, " ") || strings.HasSuffix(v, "\t") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// sameHeader reports whether a and b hold the same fields.
+func sameHeader(a, b textproto.MIMEHeader) bool {
+	return maps.EqualFunc(a, b, slices.Equal)
+}
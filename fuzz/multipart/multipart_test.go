@@ -0,0 +1,19 @@
+package multipart
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/multipartsrc"
+)
+
+func FuzzReader(f *testing.F) {
+	for _, src := range gen.Sample("multipart/*", ".multipart", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckReader(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
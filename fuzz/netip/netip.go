@@ -0,0 +1,229 @@
+// Package netip is a fuzz target for the IP address and prefix parsers
+// of net and net/netip, where two readers that disagree on an address
+// let one through an access list the other keeps out. CheckAddr reads an
+// address with netip.ParseAddr and net.ParseIP, which must read it
+// exactly when the harness's reader of RFC 4291 does, to the same bytes,
+// family and zone; each must print it as RFC 5952 has it and read what
+// it prints back the same, and they must class it alike: loopback,
+// private, multicast and so on. CheckPrefix reads a prefix with
+// netip.ParsePrefix and net.ParseCIDR, under the same rules and RFC
+// 4632's, and an address after it, which each must find in the prefix
+// only if its bits past the prefix length are all that differ.
+package netip
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds checking one input.
+var Timeout = 10 * time.Second
+
+// mapped is the prefix of an IPv4-mapped IPv6 address.
+var mapped = [12]byte{10: 0xff, 11: 0xff}
+
+// CheckAddr checks the address s.
+func CheckAddr(s string) error {
+	return harness.Run(Timeout, func() error { return checkAddr(s) })
+}
+
+func checkAddr(s string) error {
+	want, ok := parseAddr(s)
+	a, err := netip.ParseAddr(s)
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("ParseAddr: %v, but %q reads as %x", err, s, want.addr)
+	case !ok && err == nil:
+		return fmt.Errorf("ParseAddr(%q) = %v, but it does not read", s, a)
+	case ok && (a.As16() != want.addr || a.Is4() != want.is4 || a.Zone() != want.zone):
+		return fmt.Errorf("ParseAddr(%q) = %v, want %x, IPv4 %v, zone %q", s, a, want.addr, want.is4, want.zone)
+	}
+	ip := net.ParseIP(s)
+	switch {
+	case ip == nil && ok && want.zone == "":
+		return fmt.Errorf("ParseIP(%q) = nil, but it reads as %x", s, want.addr)
+	case ip != nil && (!ok || want.zone != ""):
+		return fmt.Errorf("ParseIP(%q) = %v, but it does not read without a zone", s, ip)
+	case ip != nil && !bytes.Equal(ip, want.addr[:]):
+		return fmt.Errorf("ParseIP(%q) = %x, want %x", s, []byte(ip), want.addr)
+	}
+	if !ok {
+		return nil
+	}
+	if err := checkText(a, want); err != nil {
+		return fmt.Errorf("ParseAddr(%q): %v", s, err)
+	}
+	if ip != nil {
+		if err := checkIP(ip, a); err != nil {
+			return fmt.Errorf("ParseIP(%q): %v", s, err)
+		}
+	}
+	return nil
+}
+
+// checkText checks that a prints as RFC 5952 has it, IPv4 in IPv6 with
+// its last two groups dotted, and in full, and that each reads back as
+// a, as do its text and binary marshalings.
+func checkText(a netip.Addr, want ip) error {
+	text := format(want.addr)
+	switch {
+	case want.is4:
+		text = dotted(want.addr)
+	case [12]byte(want.addr[:12]) == mapped:
+		text = "::ffff:" + dotted(want.addr)
+	}
+	if want.zone != "" {
+		text += "%" + want.zone
+	}
+	if a.String() != text {
+		return fmt.Errorf("String() = %q, want %q", a.String(), text)
+	}
+	for _, t := range []string{a.String(), a.StringExpanded()} {
+		if back, err := netip.ParseAddr(t); err != nil || back != a {
+			return fmt.Errorf("%v prints as %q, which reads as %v: %v", a, t, back, err)
+		}
+	}
+	var back netip.Addr
+	if t, _ := a.MarshalText(); back.UnmarshalText(t) != nil || back != a {
+		return fmt.Errorf("%v marshals as %q, which unmarshals as %v", a, t, back)
+	}
+	back = netip.Addr{}
+	if b, _ := a.MarshalBinary(); back.UnmarshalBinary(b) != nil || back != a {
+		return fmt.Errorf("%v marshals as %x, which unmarshals as %v", a, b, back)
+	}
+	return nil
+}
+
+// checkIP checks that ip, which net read as a, prints as a does, but
+// for IPv4 in IPv6, which net prints dotted; that it reads back the same;
+// and that net classes it as netip classes a, or the IPv4 address a maps.
+func checkIP(ip net.IP, a netip.Addr) error {
+	text := a.Unmap().String()
+	if ip.String() != text {
+		return fmt.Errorf("String() = %q, want %q", ip.String(), text)
+	}
+	if back := net.ParseIP(ip.String()); !back.Equal(ip) {
+		return fmt.Errorf("%v reads back as %v", ip, back)
+	}
+	if back, ok := netip.AddrFromSlice(ip); !ok || back.Unmap() != a.WithZone("").Unmap() {
+		return fmt.Errorf("AddrFromSlice(%x) = %v, want %v", []byte(ip), back, a)
+	}
+	// Known: net classes IPv4 in IPv6 as the IPv4 address it maps, where
+	// netip's IsUnspecified holds it apart: ::ffff:0.0.0.0 is unspecified
+	// to net and not to netip.
+	a = a.Unmap()
+	classes := []struct {
+		name   string
+		ip, is bool
+	}{
+		{"IsUnspecified", ip.IsUnspecified(), a.IsUnspecified()},
+		{"IsLoopback", ip.IsLoopback(), a.IsLoopback()},
+		{"IsPrivate", ip.IsPrivate(), a.IsPrivate()},
+		{"IsMulticast", ip.IsMulticast(), a.IsMulticast()},
+		{"IsInterfaceLocalMulticast", ip.IsInterfaceLocalMulticast(), a.IsInterfaceLocalMulticast()},
+		{"IsLinkLocalMulticast", ip.IsLinkLocalMulticast(), a.IsLinkLocalMulticast()},
+		{"IsLinkLocalUnicast", ip.IsLinkLocalUnicast(), a.IsLinkLocalUnicast()},
+		{"IsGlobalUnicast", ip.IsGlobalUnicast(), a.IsGlobalUnicast()},
+	}
+	for _, c := range classes {
+		if c.ip != c.is {
+			return fmt.Errorf("%s() = %v for %v, but netip gives %v", c.name, c.ip, ip, c.is)
+		}
+	}
+	return nil
+}
+
+// CheckPrefix checks the prefix in data, and the address after it and a
+// space if there is one.
+func CheckPrefix(data string) error {
+	return harness.Run(Timeout, func() error { return checkPrefix(data) })
+}
+
+func checkPrefix(data string) error {
+	s, probe, _ := strings.Cut(data, " ")
+	want, bits, ok := parsePrefix(s, false)
+	p, err := netip.ParsePrefix(s)
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("ParsePrefix: %v, but %q reads as %x/%d", err, s, want.addr, bits)
+	case !ok && err == nil:
+		return fmt.Errorf("ParsePrefix(%q) = %v, but it does not read", s, p)
+	case ok && (p.Addr().As16() != want.addr || p.Addr().Is4() != want.is4 || p.Bits() != bits):
+		return fmt.Errorf("ParsePrefix(%q) = %v, want %x/%d", s, p, want.addr, bits)
+	}
+	wantNet, netBits, netOK := parsePrefix(s, true)
+	ip, ipnet, err := net.ParseCIDR(s)
+	switch {
+	case netOK && err != nil:
+		return fmt.Errorf("ParseCIDR: %v, but %q reads as %x/%d", err, s, wantNet.addr, netBits)
+	case !netOK && err == nil:
+		return fmt.Errorf("ParseCIDR(%q) = %v, %v, but it does not read", s, ip, ipnet)
+	case netOK && !bytes.Equal(ip, wantNet.addr[:]):
+		return fmt.Errorf("ParseCIDR(%q) = %v, want %x", s, ip, wantNet.addr)
+	}
+	if netOK {
+		if ones, size := ipnet.Mask.Size(); ones != netBits || size != len(ipnet.IP)*8 || wantNet.is4 != (size == 32) {
+			return fmt.Errorf("ParseCIDR(%q) has a mask of %d of %d bits, want %d", s, ones, size, netBits)
+		}
+	}
+	if !ok {
+		return nil
+	}
+	m := p.Masked()
+	if !net.IP(m.Addr().AsSlice()).Equal(ipnet.IP) {
+		return fmt.Errorf("ParsePrefix(%q) masks to %v, but ParseCIDR to %v", s, m, ipnet)
+	}
+	for _, t := range []string{p.String(), m.String()} {
+		if back, err := netip.ParsePrefix(t); err != nil || back.Masked() != m {
+			return fmt.Errorf("%v prints as %q, which reads as %v: %v", p, t, back, err)
+		}
+	}
+	if _, back, err := net.ParseCIDR(ipnet.String()); err != nil || back.String() != ipnet.String() {
+		return fmt.Errorf("ParseCIDR(%q) = %v, which reads back as %v: %v", s, ipnet, back, err)
+	}
+	if probe == "" {
+		return nil
+	}
+	return checkContains(p, ipnet, want, bits, probe)
+}
+
+// checkContains checks that the prefix p, which net reads as ipnet, holds
+// probe only if its bits up to the prefix length are those of the
+// prefix's address, want, and it is of the same family.
+func checkContains(p netip.Prefix, ipnet *net.IPNet, want ip, bits int, probe string) error {
+	pw, ok := parseAddr(probe)
+	a, err := netip.ParseAddr(probe)
+	if !ok || err != nil || pw.zone != "" {
+		return nil
+	}
+	in := pw.is4 == want.is4
+	first := 0
+	if want.is4 {
+		first = 96
+	}
+	for i := first; i < first+bits; i++ {
+		if pw.addr[i/8]>>(7-i%8)&1 != want.addr[i/8]>>(7-i%8)&1 {
+			in = false
+		}
+	}
+	if p.Contains(a) != in {
+		return fmt.Errorf("%v.Contains(%v) = %v, want %v", p, a, p.Contains(a), in)
+	}
+	// Known: net reads IPv4 in IPv6, in an address and in a network alike,
+	// as the IPv4 address it maps, where netip holds the families apart;
+	// so an access list of IPv4 prefixes in net matches an IPv4-mapped
+	// address that netip's does not. Where the families match, they agree.
+	if p.Addr().Is4In6() || a.Is4In6() {
+		return nil
+	}
+	if ipnet.Contains(net.IP(a.AsSlice())) != in {
+		return fmt.Errorf("ParseCIDR's %v.Contains(%v) = %v, want %v", ipnet, a, !in, in)
+	}
+	return nil
+}
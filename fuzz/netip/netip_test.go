@@ -0,0 +1,30 @@
+package netip
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/ipsrc"
+)
+
+func FuzzAddr(f *testing.F) {
+	for _, src := range gen.Sample("ip/addr", ".ip", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckAddr(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzPrefix(f *testing.F) {
+	for _, src := range gen.Sample("ip/prefix", ".cidr", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckPrefix(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package netip
+
+import (
+	"strconv"
+	"strings"
+)
+
+// An ip is what an address reads as: its sixteen bytes, IPv4 mapped into
+// IPv6 as RFC 4291 section 2.5.5.2 maps it; whether it was dotted IPv4
+// alone; and its zone.
+type ip struct {
+	addr [16]byte
+	is4  bool
+	zone string
+}
+
+// parseAddr reads s as an IP address: dotted IPv4, or IPv6 with a zone
+// after a percent sign if any, which must not be empty.
+func parseAddr(s string) (ip, bool) {
+	if b, ok := parse4(s); ok {
+		return ip{addr: [16]byte{10: 0xff, 11: 0xff, 12: b[0], 13: b[1], 14: b[2], 15: b[3]}, is4: true}, true
+	}
+	host, zone, zoned := strings.Cut(s, "%")
+	if zoned && zone == "" {
+		return ip{}, false
+	}
+	a, ok := parse6(host)
+	return ip{addr: a, zone: zone}, ok
+}
+
+// parse4 reads s as IPv4 in dotted decimal: four fields of one to three
+// digits, none of them a leading zero, each at most 255. The forms
+// inet_aton also reads, with fewer fields and in octal and hexadecimal,
+// are not addresses.
+func parse4(s string) ([4]byte, bool) {
+	var b [4]byte
+	fields := strings.Split(s, ".")
+	if len(fields) != 4 {
+		return b, false
+	}
+	for i, f := range fields {
+		if f == "" || len(f) > 3 || len(f) > 1 && f[0] == '0' || strings.Trim(f, "0123456789") != "" {
+			return b, false
+		}
+		n, _ := strconv.Atoi(f)
+		if n > 255 {
+			return b, false
+		}
+		b[i] = byte(n)
+	}
+	return b, true
+}
+
+// parse6 reads s as IPv6 by RFC 4291 section 2.2: eight groups of one to
+// four hexadecimal digits between colons, one "::" in place of one group
+// of zeros or more, and the last two groups, if they end the address,
+// maybe dotted IPv4.
+func parse6(s string) ([16]byte, bool) {
+	var a [16]byte
+	head, tail, compressed := strings.Cut(s, "::")
+	if strings.Contains(tail, "::") {
+		return a, false
+	}
+	h, ok := groups(head, !compressed)
+	if !ok {
+		return a, false
+	}
+	t, ok := groups(tail, true)
+	switch {
+	case !ok:
+		return a, false
+	case !compressed && len(h) != 16, compressed && len(h)+len(t) > 14:
+		return a, false
+	}
+	copy(a[:], h)
+	copy(a[16-len(t):], t)
+	return a, true
+}
+
+// groups returns the bytes of the groups of s, none if s is empty, the
+// last of them maybe dotted IPv4 if last, or reports that one is not a
+// group.
+func groups(s string, last bool) ([]byte, bool) {
+	if s == "" {
+		return nil, true
+	}
+	var b []byte
+	fields := strings.Split(s, ":")
+	for i, f := range fields {
+		if last && i == len(fields)-1 && strings.Contains(f, ".") {
+			v4, ok := parse4(f)
+			return append(b, v4[:]...), ok
+		}
+		if f == "" || len(f) > 4 || strings.Trim(f, "0123456789abcdefABCDEF") != "" {
+			return nil, false
+		}
+		n, _ := strconv.ParseUint(f, 16, 16)
+		b = append(b, byte(n>>8), byte(n))
+	}
+	return b, true
+}
+
+// parsePrefix reads s as a prefix by RFC 4632 and RFC 4291 section 2.3:
+// an address with no zone, a slash and a length in decimal with no sign,
+// at most 32 for dotted IPv4 and 128 for IPv6, IPv4 in IPv6 among it.
+// The length has no leading zeros unless zeros.
+func parsePrefix(s string, zeros bool) (ip, int, bool) {
+	addr, length, ok := strings.Cut(s, "/")
+	if !ok {
+		return ip{}, 0, false
+	}
+	a, ok := parseAddr(addr)
+	if !ok || a.zone != "" || length == "" || strings.Trim(length, "0123456789") != "" {
+		return ip{}, 0, false
+	}
+	if zeros {
+		length = strings.TrimLeft(length, "0")
+		if length == "" {
+			length = "0"
+		}
+	}
+	if len(length) > 1 && length[0] == '0' || len(length) > 3 {
+		return ip{}, 0, false
+	}
+	bits, _ := strconv.Atoi(length)
+	if a.is4 && bits > 32 || bits > 128 {
+		return ip{}, 0, false
+	}
+	return a, bits, true
+}
+
+// format writes a, an IPv6 address, as RFC 5952 section 4 has it: in
+// lower case without leading zeros, the longest run of two zero groups
+// or more, the first of the longest, as "::".
+func format(a [16]byte) string {
+	var g [8]uint16
+	for i := range g {
+		g[i] = uint16(a[2*i])<<8 | uint16(a[2*i+1])
+	}
+	start, length := -1, 1
+	for i := 0; i < 8; {
+		j := i
+		for j < 8 && g[j] == 0 {
+			j++
+		}
+		if j-i > length {
+			start, length = i, j-i
+		}
+		i = j + 1
+	}
+	var b strings.Builder
+	for i := 0; i < 8; i++ {
+		if i == start {
+			b.WriteString("::")
+			i += length - 1
+			continue
+		}
+		if i > 0 && i != start+length {
+			b.WriteByte(':')
+		}
+		b.WriteString(strconv.FormatUint(uint64(g[i]), 16))
+	}
+	return b.String()
+}
+
+// dotted writes the last four bytes of a in dotted decimal.
+func dotted(a [16]byte) string {
+	return strconv.Itoa(int(a[12])) + "." + strconv.Itoa(int(a[13])) + "." + strconv.Itoa(int(a[14])) + "." + strconv.Itoa(int(a[15]))
+}
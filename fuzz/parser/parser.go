@@ -0,0 +1,91 @@
+// Package parser is a fuzz target for go/parser. Check parses a source
+// file and verifies that the positions recorded in the resulting AST are
+// self-consistent.
+package parser
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// Mode is the go/parser mode used by Check.
+const Mode = parser.ParseComments | parser.AllErrors
+
+// Check parses src and returns an error describing the first violated
+// position invariant. Syntax errors in src are not failures: the parser is
+// expected to reject bad input. Every node of the partial tree it returns
+// must still end at or after its start, but the stricter checks of
+// Positions only apply to clean parses, since error recovery synthesizes
+// nodes past the end of the file.
+func Check(src []byte) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, Mode)
+	if f == nil {
+		return nil
+	}
+	if err != nil {
+		return walk(fset, f, func(ast.Node) error { return nil })
+	}
+	return Positions(fset, f)
+}
+
+// Positions checks that every node of f ends at or after its start and
+// lies within the file, and that top-level declarations and comment
+// groups appear in order without overlapping.
+func Positions(fset *token.FileSet, f *ast.File) error {
+	tf := fset.File(f.Package)
+	if tf == nil {
+		return nil
+	}
+	lo, hi := token.Pos(tf.Base()), token.Pos(tf.Base()+tf.Size())
+	err := walk(fset, f, func(n ast.Node) error {
+		if n.Pos() < lo || n.End() > hi {
+			return fmt.Errorf("%T at %s: span [%d,%d) outside file [%d,%d]",
+				n, fset.Position(n.Pos()), n.Pos(), n.End(), lo, hi)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for i := 1; i < len(f.Decls); i++ {
+		prev, next := f.Decls[i-1], f.Decls[i]
+		if prev.End() > next.Pos() {
+			return fmt.Errorf("decl %T at %s overlaps previous %T ending at %s",
+				next, fset.Position(next.Pos()), prev, fset.Position(prev.End()))
+		}
+	}
+	for i := 1; i < len(f.Comments); i++ {
+		prev, next := f.Comments[i-1], f.Comments[i]
+		if prev.End() > next.Pos() {
+			return fmt.Errorf("comment group at %s overlaps previous group ending at %s",
+				fset.Position(next.Pos()), fset.Position(prev.End()))
+		}
+	}
+	return nil
+}
+
+// walk checks End >= Pos for every node of f with valid positions, then
+// applies check to it, stopping at the first error.
+func walk(fset *token.FileSet, f *ast.File, check func(ast.Node) error) error {
+	var err error
+	ast.Inspect(f, func(n ast.Node) bool {
+		if err != nil || n == nil {
+			return false
+		}
+		pos, end := n.Pos(), n.End()
+		if !pos.IsValid() || !end.IsValid() {
+			return true
+		}
+		if end < pos {
+			err = fmt.Errorf("%T at %s: End %d < Pos %d", n, fset.Position(pos), end, pos)
+			return false
+		}
+		err = check(n)
+		return err == nil
+	})
+	return err
+}
@@ -0,0 +1,510 @@
+package pdf
+
+import (
+	"io"
+	"strconv"
+)
+
+// The tokens and objects of a file, as both libraries have them.
+type (
+	token   any
+	object  any
+	keyword string
+	name    string
+	dict    map[name]object
+	array   []object
+)
+
+// A stream is a stream's dictionary, the object it is part of, and
+// where its data starts.
+type stream struct {
+	hdr    dict
+	ptr    objptr
+	offset int64
+}
+
+// An objptr is a reference to an object: its number and generation.
+type objptr struct {
+	id  uint32
+	gen uint16
+}
+
+// An objdef is an object with its number and generation, as the file
+// defines it.
+type objdef struct {
+	ptr objptr
+	obj object
+}
+
+// maxDepth is how deep rsc.io/pdf may nest objects before the harness
+// stops it, and forkDepth how deep ledongthuc/pdf nests them before it
+// fails.
+const (
+	maxDepth  = 1 << 14
+	forkDepth = 1000
+)
+
+// maxLoad is how much a buffer may read from an object stream.
+const maxLoad = 64 << 20
+
+// A buffer reads tokens and objects as the libraries' buffer does: a
+// block of up to 4096 bytes at a time, up to the next multiple of 4096
+// of the offset it reads at, so that where it fails, where it seeks back
+// before the block it has and where it meets the end is where theirs
+// does.
+type buffer struct {
+	r        *reader
+	rd       io.Reader
+	buf      []byte
+	pos      int
+	offset   int64 // of the end of buf
+	loaded   int
+	unread   []token
+	allowEOF bool
+	eof      bool
+	key      []byte
+	useAES   bool
+	objptr   objptr
+	depth    int
+}
+
+func (r *reader) newBuffer(rd io.Reader, offset int64) *buffer {
+	return &buffer{r: r, rd: rd, offset: offset, buf: make([]byte, 0, 4096)}
+}
+
+func (b *buffer) readByte() byte {
+	if b.pos >= len(b.buf) {
+		b.reload()
+		if b.pos >= len(b.buf) {
+			return '\n'
+		}
+	}
+	c := b.buf[b.pos]
+	b.pos++
+	return c
+}
+
+func (b *buffer) reload() bool {
+	n := cap(b.buf) - int(b.offset%int64(cap(b.buf)))
+	if n > cap(b.buf) {
+		// Known: both libraries read at a negative offset as far as
+		// the next multiple of 4096 from it, which is past the end of
+		// their block.
+		b.r.crash("reading at offset %d", b.offset)
+	}
+	n, err := b.rd.Read(b.buf[:n])
+	if n == 0 && err != nil {
+		b.buf = b.buf[:0]
+		b.pos = 0
+		if b.allowEOF && err == io.EOF {
+			b.eof = true
+			return false
+		}
+		b.r.fail("malformed PDF: reading at offset %d: %v", b.offset, err)
+	}
+	b.offset += int64(n)
+	b.buf = b.buf[:n]
+	b.pos = 0
+	b.loaded += n
+	b.r.work += n
+	if b.loaded > maxLoad {
+		b.r.hazard("reading more than %d bytes of an object stream", maxLoad)
+	}
+	return true
+}
+
+func (b *buffer) seekForward(offset int64) {
+	for b.offset < offset {
+		if !b.reload() {
+			return
+		}
+	}
+	b.pos = len(b.buf) - int(b.offset-offset)
+	if b.pos < 0 {
+		// Known: both libraries seek to an object in an object stream
+		// from where they have read to, and one before the block they
+		// have is before the start of it.
+		b.r.crash("seeking back to %d in an object stream", offset)
+	}
+}
+
+func (b *buffer) readOffset() int64 {
+	return b.offset - int64(len(b.buf)) + int64(b.pos)
+}
+
+func (b *buffer) unreadByte() {
+	if b.pos > 0 {
+		b.pos--
+	}
+}
+
+func (b *buffer) unreadToken(t token) {
+	b.unread = append(b.unread, t)
+}
+
+// spin is called where the libraries read another byte after a space
+// in a hex string.
+func (b *buffer) spin() {
+	if b.eof {
+		// Known: both libraries read a hex string that the end of an
+		// object stream cuts off as spaces for ever.
+		b.r.hazard("reading a hex string past the end of an object stream")
+	}
+}
+
+func (b *buffer) readToken() token {
+	if n := len(b.unread); n > 0 {
+		t := b.unread[n-1]
+		b.unread = b.unread[:n-1]
+		return t
+	}
+	c := b.readByte()
+	for {
+		if isSpace(c) {
+			if b.eof {
+				return io.EOF
+			}
+			c = b.readByte()
+		} else if c == '%' {
+			for c != '\r' && c != '\n' {
+				c = b.readByte()
+			}
+		} else {
+			break
+		}
+	}
+	switch c {
+	case '<':
+		if b.readByte() == '<' {
+			return keyword("<<")
+		}
+		b.unreadByte()
+		return b.readHexString()
+	case '(':
+		return b.readLiteralString()
+	case '[', ']', '{', '}':
+		return keyword(string(c))
+	case '/':
+		return b.readName()
+	case '>':
+		if b.readByte() == '>' {
+			return keyword(">>")
+		}
+		b.unreadByte()
+		fallthrough
+	default:
+		if isDelim(c) {
+			b.r.fail("unexpected delimiter %#q", rune(c))
+		}
+		b.unreadByte()
+		return b.readKeyword()
+	}
+}
+
+func (b *buffer) readHexString() token {
+	var tmp []byte
+	for {
+		c := b.readByte()
+		for isSpace(c) {
+			b.spin()
+			c = b.readByte()
+		}
+		if c == '>' {
+			break
+		}
+		c2 := b.readByte()
+		for isSpace(c2) {
+			b.spin()
+			c2 = b.readByte()
+		}
+		x := unhex(c)<<4 | unhex(c2)
+		if x < 0 {
+			b.r.fail("malformed hex string %c %c", c, c2)
+		}
+		tmp = append(tmp, byte(x))
+	}
+	return string(tmp)
+}
+
+func unhex(b byte) int {
+	switch {
+	case '0' <= b && b <= '9':
+		return int(b) - '0'
+	case 'a' <= b && b <= 'f':
+		return int(b) - 'a' + 10
+	case 'A' <= b && b <= 'F':
+		return int(b) - 'A' + 10
+	}
+	return -1
+}
+
+func (b *buffer) readLiteralString() token {
+	var tmp []byte
+	depth := 1
+Loop:
+	for !b.r.fork || !b.eof {
+		c := b.readByte()
+		if b.eof && !b.r.fork {
+			// Known: rsc.io/pdf reads a literal string that the end of
+			// an object stream cuts off as newlines for ever.
+			b.r.hazard("reading a literal string past the end of an object stream")
+		}
+		switch c {
+		default:
+			tmp = append(tmp, c)
+		case '(':
+			depth++
+			tmp = append(tmp, c)
+		case ')':
+			if depth--; depth == 0 {
+				break Loop
+			}
+			tmp = append(tmp, c)
+		case '\\':
+			switch c = b.readByte(); c {
+			default:
+				b.r.fail("invalid escape sequence \\%c", c)
+			case 'n':
+				tmp = append(tmp, '\n')
+			case 'r':
+				tmp = append(tmp, '\r')
+			case 'b':
+				tmp = append(tmp, '\b')
+			case 't':
+				tmp = append(tmp, '\t')
+			case 'f':
+				tmp = append(tmp, '\f')
+			case '(', ')', '\\':
+				tmp = append(tmp, c)
+			case '\r':
+				if b.readByte() != '\n' {
+					b.unreadByte()
+				}
+			case '\n':
+			case '0', '1', '2', '3', '4', '5', '6', '7':
+				x := int(c - '0')
+				for range 2 {
+					c = b.readByte()
+					if c < '0' || c > '7' {
+						b.unreadByte()
+						break
+					}
+					x = x*8 + int(c-'0')
+				}
+				if x > 255 {
+					b.r.fail("invalid octal escape \\%03o", x)
+				}
+				tmp = append(tmp, byte(x))
+			}
+		}
+	}
+	return string(tmp)
+}
+
+func (b *buffer) readName() token {
+	var tmp []byte
+	for {
+		c := b.readByte()
+		if isDelim(c) || isSpace(c) {
+			b.unreadByte()
+			break
+		}
+		if c == '#' {
+			x := unhex(b.readByte())<<4 | unhex(b.readByte())
+			if x < 0 {
+				b.r.fail("malformed name")
+			}
+			tmp = append(tmp, byte(x))
+			continue
+		}
+		tmp = append(tmp, c)
+	}
+	return name(tmp)
+}
+
+func (b *buffer) readKeyword() token {
+	var tmp []byte
+	for {
+		c := b.readByte()
+		if isDelim(c) || isSpace(c) {
+			b.unreadByte()
+			break
+		}
+		tmp = append(tmp, c)
+	}
+	s := string(tmp)
+	switch {
+	case s == "true":
+		return true
+	case s == "false":
+		return false
+	case isInteger(s):
+		x, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			b.r.fail("invalid integer %s", s)
+		}
+		return x
+	case isReal(s):
+		x, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			b.r.fail("invalid real %s", s)
+		}
+		return x
+	}
+	return keyword(s)
+}
+
+func isInteger(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || '9' < c {
+			return false
+		}
+	}
+	return true
+}
+
+func isReal(s string) bool {
+	if len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		s = s[1:]
+	}
+	if len(s) == 0 {
+		return false
+	}
+	ndot := 0
+	for _, c := range s {
+		if c == '.' {
+			ndot++
+			continue
+		}
+		if c < '0' || '9' < c {
+			return false
+		}
+	}
+	return ndot == 1
+}
+
+func (b *buffer) readObject() object {
+	b.depth++
+	defer func() { b.depth-- }()
+	if b.r.fork && b.depth > forkDepth {
+		b.r.fail("object nesting exceeds maximum depth %d", forkDepth)
+	}
+	if b.depth > maxDepth {
+		// Known: rsc.io/pdf nests objects as deep as the file does, a
+		// call or two for each.
+		b.r.hazard("nesting objects more than %d deep", maxDepth)
+	}
+	tok := b.readToken()
+	if kw, ok := tok.(keyword); ok {
+		switch kw {
+		case "null":
+			return nil
+		case "<<":
+			return b.readDict()
+		case "[":
+			return b.readArray()
+		case ">>", "]":
+			if b.r.fork {
+				return nil
+			}
+		}
+		b.r.fail("unexpected keyword %q parsing object", kw)
+	}
+	if str, ok := tok.(string); ok && b.key != nil && b.objptr.id != 0 {
+		tok = b.r.decryptString(b.objptr, str)
+	}
+	if t1, ok := tok.(int64); ok && int64(uint32(t1)) == t1 {
+		tok2 := b.readToken()
+		if t2, ok := tok2.(int64); ok && int64(uint16(t2)) == t2 {
+			tok3 := b.readToken()
+			switch tok3 {
+			case keyword("R"):
+				return objptr{uint32(t1), uint16(t2)}
+			case keyword("obj"):
+				old := b.objptr
+				b.objptr = objptr{uint32(t1), uint16(t2)}
+				obj := b.readObject()
+				if _, ok := obj.(stream); !ok {
+					if b.readToken() != keyword("endobj") {
+						b.r.fail("missing endobj after indirect object definition")
+					}
+				}
+				b.objptr = old
+				return objdef{objptr{uint32(t1), uint16(t2)}, obj}
+			}
+			b.unreadToken(tok3)
+		}
+		b.unreadToken(tok2)
+	}
+	return tok
+}
+
+func (b *buffer) readArray() object {
+	var x array
+	for {
+		tok := b.readToken()
+		if tok == keyword("]") || b.r.fork && tok == io.EOF {
+			break
+		}
+		if tok == io.EOF {
+			// Known: rsc.io/pdf reads an array that the end of an object
+			// stream cuts off as the end of it for ever.
+			b.r.hazard("reading an array past the end of an object stream")
+		}
+		b.unreadToken(tok)
+		x = append(x, b.readObject())
+	}
+	return x
+}
+
+func (b *buffer) readDict() object {
+	x := make(dict)
+	for {
+		tok := b.readToken()
+		if tok == keyword(">>") || b.r.fork && tok == io.EOF {
+			break
+		}
+		n, ok := tok.(name)
+		if !ok {
+			b.r.fail("unexpected non-name key %T(%v) parsing dictionary", tok, tok)
+		}
+		x[n] = b.readObject()
+	}
+	tok := b.readToken()
+	if tok != keyword("stream") {
+		b.unreadToken(tok)
+		return x
+	}
+	switch b.readByte() {
+	case '\r':
+		if b.readByte() != '\n' {
+			b.unreadByte()
+		}
+	case '\n':
+	default:
+		b.r.fail("stream keyword not followed by newline")
+	}
+	return stream{x, b.objptr, b.readOffset()}
+}
+
+func isSpace(b byte) bool {
+	switch b {
+	case '\x00', '\t', '\n', '\f', '\r', ' ':
+		return true
+	}
+	return false
+}
+
+func isDelim(b byte) bool {
+	switch b {
+	case '<', '>', '(', ')', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
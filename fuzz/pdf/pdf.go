@@ -0,0 +1,433 @@
+// Package pdf is a fuzz target for rsc.io/pdf and for
+// github.com/ledongthuc/pdf, a fork of it. CheckPDF reads a file first
+// with a reader of its own, which reads it as each library does, quirks
+// and all, and opens it with neither where that would hang, run out of
+// memory or stack, or panic with a runtime error, each of which is
+// Known. Opening a file must take time and memory within a budget linear
+// in its size and the cross-reference entries it has, past which it is
+// reported as a blowup, and must succeed, or fail, or fail for want of a
+// password, as the reader says. From the trailer of a file that opens,
+// each library must give the values the reader does, of the same kinds,
+// keys and lengths, resolve what it does and fail to resolve the rest,
+// and decode the data of a stream to the same bytes. It must count the
+// pages the reader counts, find the ones it finds, and give them the
+// same fonts.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"slices"
+	"time"
+
+	ledongthuc "github.com/ledongthuc/pdf"
+	rsc "rsc.io/pdf"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what opening a file may cost: the harness.Budget of its
+// bytes, plus PerEntry for each cross-reference entry it has. Entries
+// is how many entries a file may have for it to be opened at all.
+type Budget struct {
+	harness.Budget
+	PerEntry harness.Cost
+	Entries  int
+}
+
+// For returns the budget for a file of n bytes with entries
+// cross-reference entries.
+func (b Budget) For(n, entries int) harness.Cost {
+	return b.Budget.For(n).Plus(entries, b.PerEntry)
+}
+
+// DefaultBudget opens files of up to a million cross-reference entries,
+// and allows for the table of them, which rsc.io/pdf grows an entry at
+// a time, and for the inflating of each xref stream.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 16 << 20},
+		Per:  harness.Cost{Time: 10 * time.Microsecond, Memory: 1 << 10},
+	},
+	PerEntry: harness.Cost{Time: time.Microsecond, Memory: 128},
+	Entries:  1 << 20,
+}
+
+// Timeout bounds walking the values and pages of one file.
+var Timeout = 10 * time.Second
+
+// The most values, and streams, of a file that checkFile compares, the
+// most bytes of a stream, the most pages, and how many bytes the reader
+// may read and decode again as it resolves them.
+const (
+	maxValues  = 1 << 12
+	maxStreams = 32
+	maxData    = 1 << 20
+	maxPages   = 64
+	maxWork    = 16 << 20
+)
+
+// CheckPDF opens the file in data with both libraries within b, and
+// checks what they read of it. Errors opening are expected, where the
+// reader fails too.
+func CheckPDF(data []byte, b Budget) error {
+	refs := [2]*reader{newReader(data, false, b.Entries), newReader(data, true, b.Entries)}
+	var fails [2]*failure
+	entries := 0
+	for i, r := range refs {
+		if fails[i] = r.open(); fails[i] != nil && fails[i].hazard {
+			return nil
+		}
+		entries = max(entries, r.entries)
+	}
+
+	limit := b.For(len(data), entries)
+	var rr *rsc.Reader
+	var lr *ledongthuc.Reader
+	var errs [2]error
+	opens := [2]func(){
+		func() { rr, errs[0] = openRSC(data) },
+		func() { lr, errs[1] = ledongthuc.NewReader(bytes.NewReader(data), int64(len(data))) },
+	}
+	for i, open := range opens {
+		what := fmt.Sprintf("%s: opening %d bytes of %d cross-reference entries", libs[i], len(data), entries)
+		if err := harness.Measure(what, limit, open); err != nil {
+			return err
+		}
+	}
+	passwords := [2]error{rsc.ErrInvalidPassword, ledongthuc.ErrInvalidPassword}
+	for i, want := range fails {
+		switch err := errs[i]; {
+		case want == nil && err != nil:
+			return fmt.Errorf("%s: opening fails: %v, but the file reads", libs[i], err)
+		case want != nil && err == nil:
+			return fmt.Errorf("%s: the file opens, but reading it fails: %v", libs[i], want)
+		case want != nil && want.password != (err == passwords[i]):
+			return fmt.Errorf("%s: opening fails: %v, but reading it fails: %v", libs[i], err, want)
+		}
+	}
+
+	return harness.Run(Timeout, func() error {
+		if rr != nil {
+			err := checkFile(&walker[rsc.Value, rsc.ValueKind]{
+				lib:     libs[0],
+				r:       refs[0],
+				numPage: rr.NumPage,
+				page:    func(i int) rsc.Value { return rr.Page(i).V },
+				fonts:   func(i int) []string { return rr.Page(i).Fonts() },
+			}, rr.Trailer())
+			if err != nil {
+				return err
+			}
+		}
+		if lr != nil {
+			return checkFile(&walker[ledongthuc.Value, ledongthuc.ValueKind]{
+				lib:     libs[1],
+				r:       refs[1],
+				numPage: lr.NumPage,
+				page:    func(i int) ledongthuc.Value { return lr.Page(i).V },
+				fonts:   func(i int) []string { return lr.Page(i).Fonts() },
+			}, lr.Trailer())
+		}
+		return nil
+	})
+}
+
+var libs = [2]string{"rsc.io/pdf", "ledongthuc/pdf"}
+
+// openRSC opens data with rsc.io/pdf, which fails as often by a panic as
+// by an error, and returns the panic as the error.
+func openRSC(data []byte) (r *rsc.Reader, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(runtime.Error); ok {
+				panic(p)
+			}
+			r, err = nil, fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return rsc.NewReader(bytes.NewReader(data), int64(len(data)))
+}
+
+// protect calls fn, and returns what it returns, or what it panics with,
+// which is how the libraries fail to resolve a value. A runtime error
+// panics on.
+func protect[T any](fn func() T) (v T, p any) {
+	defer func() {
+		if p = recover(); p != nil {
+			if _, ok := p.(runtime.Error); ok {
+				panic(p)
+			}
+		}
+	}()
+	return fn(), nil
+}
+
+// A value is a Value of either library.
+type value[V any, K ~int] interface {
+	Kind() K
+	Bool() bool
+	Int64() int64
+	Float64() float64
+	RawString() string
+	Name() string
+	Keys() []string
+	Key(string) V
+	Index(int) V
+	Len() int
+	Reader() io.ReadCloser
+}
+
+// A walker walks the values and pages of a file a library has opened,
+// and those the reader reads of it, side by side.
+type walker[V value[V, K], K ~int] struct {
+	lib     string
+	r       *reader
+	numPage func() int
+	page    func(int) V
+	fonts   func(int) []string
+	// seen is the objects walked already, which are compared again only
+	// by kind.
+	seen            map[objptr]bool
+	values, streams int
+}
+
+// errEnough stops a walk that has compared all it may.
+var errEnough = fmt.Errorf("enough")
+
+// checkFile compares the values of a file from its trailer, then its
+// pages.
+func checkFile[V value[V, K], K ~int](w *walker[V, K], trailer V) error {
+	w.seen = map[objptr]bool{}
+	if err := w.compare("trailer", trailer, w.r.trailer); err != nil && err != errEnough {
+		return err
+	}
+	return w.checkPages()
+}
+
+// kind returns the kind of x, numbered as both libraries number them.
+func kind(x object) int {
+	switch x.(type) {
+	case bool:
+		return 1
+	case int64:
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	case name:
+		return 5
+	case dict:
+		return 6
+	case array:
+		return 7
+	case stream:
+		return 8
+	}
+	return 0
+}
+
+func (w *walker[V, K]) compare(path string, got V, want object) error {
+	if w.values++; w.values > maxValues || w.r.work > maxWork {
+		return errEnough
+	}
+	if int(got.Kind()) != kind(want) {
+		return fmt.Errorf("%s: %s is of kind %d, want %d", w.lib, path, got.Kind(), kind(want))
+	}
+	switch want := want.(type) {
+	case bool:
+		if got.Bool() != want {
+			return fmt.Errorf("%s: %s is %v, want %v", w.lib, path, got.Bool(), want)
+		}
+	case int64:
+		if got.Int64() != want {
+			return fmt.Errorf("%s: %s is %d, want %d", w.lib, path, got.Int64(), want)
+		}
+	case float64:
+		if got.Float64() != want {
+			return fmt.Errorf("%s: %s is %v, want %v", w.lib, path, got.Float64(), want)
+		}
+	case string:
+		if got.RawString() != want {
+			return fmt.Errorf("%s: %s is %q, want %q", w.lib, path, got.RawString(), want)
+		}
+	case name:
+		if got.Name() != string(want) {
+			return fmt.Errorf("%s: %s is /%s, want /%s", w.lib, path, got.Name(), want)
+		}
+	case array:
+		if got.Len() != len(want) {
+			return fmt.Errorf("%s: %s has %d elements, want %d", w.lib, path, got.Len(), len(want))
+		}
+		for i, x := range want {
+			err := w.elem(fmt.Sprintf("%s[%d]", path, i), x, func() V { return got.Index(i) })
+			if err != nil {
+				return err
+			}
+		}
+	case dict:
+		return w.compareDict(path, got, want)
+	case stream:
+		if err := w.compareDict(path, got, want.hdr); err != nil {
+			return err
+		}
+		return w.compareData(path, got, want)
+	}
+	return nil
+}
+
+func (w *walker[V, K]) compareDict(path string, got V, want dict) error {
+	ks := keys(want)
+	if !slices.Equal(got.Keys(), ks) {
+		return fmt.Errorf("%s: %s has keys %q, want %q", w.lib, path, got.Keys(), ks)
+	}
+	for _, k := range ks {
+		if err := w.elem(path+"/"+k, want[name(k)], func() V { return got.Key(k) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elem compares what get returns with x, resolved, unless resolving it
+// is a hazard.
+func (w *walker[V, K]) elem(path string, x object, get func() V) error {
+	want, f := try(func() object { return w.r.resolve(x) })
+	if f != nil && f.hazard {
+		return nil
+	}
+	got, p := protect(get)
+	switch {
+	case f != nil && p == nil:
+		return fmt.Errorf("%s: %s resolves to a value of kind %d, but it fails: %v", w.lib, path, got.Kind(), f)
+	case f != nil:
+		return nil
+	case p != nil:
+		return fmt.Errorf("%s: resolving %s panics: %v", w.lib, path, p)
+	}
+	if ptr, ok := x.(objptr); ok {
+		if w.seen[ptr] {
+			if int(got.Kind()) != kind(want) {
+				return fmt.Errorf("%s: %s is of kind %d, want %d", w.lib, path, got.Kind(), kind(want))
+			}
+			return nil
+		}
+		w.seen[ptr] = true
+	}
+	return w.compare(path, got, want)
+}
+
+// A content is what reading a stream gives: up to maxData bytes, and
+// whether reading them failed.
+type content struct {
+	data   []byte
+	failed bool
+}
+
+func readContent(rd io.Reader) content {
+	data, err := io.ReadAll(io.LimitReader(rd, maxData))
+	return content{data, err != nil}
+}
+
+func (w *walker[V, K]) compareData(path string, got V, want stream) error {
+	if w.streams++; w.streams > maxStreams {
+		return nil
+	}
+	wantContent, f := try(func() content { return readContent(w.r.streamReader(want)) })
+	if f != nil && f.hazard {
+		return nil
+	}
+	rc, p := protect(got.Reader)
+	switch {
+	case f != nil && p == nil:
+		return fmt.Errorf("%s: the data of %s reads, but it fails: %v", w.lib, path, f)
+	case f != nil:
+		return nil
+	case p != nil:
+		return fmt.Errorf("%s: reading the data of %s panics: %v", w.lib, path, p)
+	}
+	gotContent, p := protect(func() content { return readContent(rc) })
+	if p != nil {
+		return fmt.Errorf("%s: reading the data of %s panics: %v", w.lib, path, p)
+	}
+	if !bytes.Equal(gotContent.data, wantContent.data) || gotContent.failed != wantContent.failed {
+		return fmt.Errorf("%s: the data of %s reads as %d bytes %q (failing %v), want %d bytes %q (failing %v)", w.lib, path, len(gotContent.data), trim(gotContent.data), gotContent.failed, len(wantContent.data), trim(wantContent.data), wantContent.failed)
+	}
+	return nil
+}
+
+// trim returns data cut to what an error message can show.
+func trim(data []byte) []byte {
+	return data[:min(len(data), 64)]
+}
+
+// checkPages compares the page count, and the pages up to maxPages and
+// past the last, with their fonts.
+func (w *walker[V, K]) checkPages() error {
+	n, f := try(w.r.numPage)
+	if f != nil && f.hazard {
+		return nil
+	}
+	got, p := protect(w.numPage)
+	switch {
+	case f != nil && p == nil:
+		return fmt.Errorf("%s: NumPage is %d, but it fails: %v", w.lib, got, f)
+	case f != nil:
+		return nil
+	case p != nil:
+		return fmt.Errorf("%s: NumPage panics: %v", w.lib, p)
+	case got != n:
+		return fmt.Errorf("%s: NumPage is %d, want %d", w.lib, got, n)
+	}
+	for i := range min(max(n, 0), maxPages) + 2 {
+		if w.r.work > maxWork {
+			return nil
+		}
+		if err := w.checkPage(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *walker[V, K]) checkPage(i int) error {
+	want, f := try(func() node { return w.r.page(i) })
+	if f != nil && f.hazard {
+		return nil
+	}
+	got, p := protect(func() V { return w.page(i) })
+	switch {
+	case f != nil && p == nil:
+		return fmt.Errorf("%s: Page(%d) is of kind %d, but it fails: %v", w.lib, i, got.Kind(), f)
+	case f != nil:
+		return nil
+	case p != nil:
+		return fmt.Errorf("%s: Page(%d) panics: %v", w.lib, i, p)
+	}
+	if int(got.Kind()) != kind(want.v) || !slices.Equal(got.Keys(), keys(want.v)) {
+		return fmt.Errorf("%s: Page(%d) is of kind %d with keys %q, want kind %d with keys %q", w.lib, i, got.Kind(), got.Keys(), kind(want.v), keys(want.v))
+	}
+	if want.v == nil {
+		return nil
+	}
+	wantFonts, f := try(func() []string { return w.r.fonts(want) })
+	if f != nil && f.hazard {
+		return nil
+	}
+	gotFonts, p := protect(func() []string { return w.fonts(i) })
+	switch {
+	case f != nil && p == nil:
+		return fmt.Errorf("%s: the fonts of page %d are %q, but they fail: %v", w.lib, i, gotFonts, f)
+	case f != nil:
+		return nil
+	case p != nil:
+		return fmt.Errorf("%s: the fonts of page %d panic: %v", w.lib, i, p)
+	}
+	if !slices.Equal(gotFonts, wantFonts) || (gotFonts == nil) != (wantFonts == nil) {
+		return fmt.Errorf("%s: the fonts of page %d are %q, want %q", w.lib, i, gotFonts, wantFonts)
+	}
+	return nil
+}
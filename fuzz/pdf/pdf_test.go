@@ -0,0 +1,25 @@
+package pdf
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/pdfsrc"
+)
+
+func FuzzPDF(f *testing.F) {
+	for _, src := range gen.Sample("pdf/xref", ".pdf", 64) {
+		f.Add(src)
+	}
+	for _, src := range gen.Sample("pdf/xrefstream", ".pdf", 64) {
+		f.Add(src)
+	}
+	for _, src := range gen.Sample("pdf/encrypted", ".pdf", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPDF(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,1018 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/ascii85"
+	"fmt"
+	"io"
+	"slices"
+)
+
+// A reader reads a file as rsc.io/pdf does, or as ledongthuc/pdf, a
+// fork of it, does if fork is set: its header, cross-reference sections
+// and trailer, its objects when they are asked for, wherever they are,
+// and the data of its streams, decrypted and decoded, each of them
+// again each time as the libraries do, quirks and all.
+//
+// Where a library fails, by an error or by a panic of its own, the
+// reader panics with a *failure; where it would hang, run out of memory
+// or stack, or panic with a runtime error, the reader panics with a
+// hazard, and the library must not be asked.
+type reader struct {
+	data []byte
+	fork bool
+	// limit is how many cross-reference entries a file may have, and
+	// entries how many reading it read and made room for.
+	limit, entries int
+	opening        bool
+	xref           []xref
+	trailer        dict
+	trailerptr     objptr
+	fileKey        []byte
+	useAES         bool
+	// resolving is the objects being resolved, each of which resolving
+	// again resolves for ever.
+	resolving map[objptr]bool
+	// work is how many bytes the reader has read from the file and
+	// decoded from streams.
+	work int
+}
+
+// An xref is an entry of the cross-reference table, laid out as the
+// libraries' is, so that it grows as theirs does.
+type xref struct {
+	ptr      objptr
+	inStream bool
+	stream   objptr
+	offset   int64
+}
+
+// A failure is how a read fails.
+type failure struct {
+	msg string
+	// hazard is set where the library must not be asked, and password
+	// where it fails with ErrInvalidPassword.
+	hazard, password bool
+}
+
+func (f *failure) Error() string { return f.msg }
+
+func (r *reader) fail(format string, args ...any) {
+	panic(&failure{msg: fmt.Sprintf(format, args...)})
+}
+
+func (r *reader) hazard(format string, args ...any) {
+	panic(&failure{msg: fmt.Sprintf(format, args...), hazard: true})
+}
+
+// crash is where the library panics with a runtime error, which is a
+// hazard unless ledongthuc/pdf is opening the file, and recovers it.
+func (r *reader) crash(format string, args ...any) {
+	if r.fork && r.opening {
+		r.fail(format, args...)
+	}
+	r.hazard(format, args...)
+}
+
+// try calls fn, and returns what it returns, or the failure it panics
+// with.
+func try[T any](fn func() T) (v T, f *failure) {
+	defer func() {
+		if p := recover(); p != nil {
+			var ok bool
+			if f, ok = p.(*failure); !ok {
+				panic(p)
+			}
+		}
+	}()
+	return fn(), nil
+}
+
+func newReader(data []byte, fork bool, limit int) *reader {
+	return &reader{data: data, fork: fork, limit: limit, resolving: map[objptr]bool{}}
+}
+
+// section returns the file from off, as the libraries read it.
+func (r *reader) section(off int64) *buffer {
+	end := int64(len(r.data))
+	return r.newBuffer(io.NewSectionReader(bytes.NewReader(r.data), off, end-off), off)
+}
+
+// open reads the file as NewReader does, with no password, and returns
+// how it fails, if it does.
+func (r *reader) open() *failure {
+	r.opening = true
+	defer func() { r.opening = false }()
+	_, f := try(func() bool {
+		r.readFile()
+		return true
+	})
+	return f
+}
+
+func (r *reader) readFile() {
+	data := r.data
+	buf := make([]byte, 10)
+	copy(buf, data)
+	if !bytes.HasPrefix(buf, []byte("%PDF-1.")) || buf[7] < '0' || buf[7] > '7' || buf[8] != '\r' && buf[8] != '\n' {
+		r.fail("not a PDF file: invalid header")
+	}
+	const endChunk = 100
+	end := int64(len(data))
+	buf = make([]byte, endChunk)
+	if end >= endChunk {
+		copy(buf, data[end-endChunk:])
+	}
+	for len(buf) > 0 && (buf[len(buf)-1] == '\n' || buf[len(buf)-1] == '\r') {
+		buf = buf[:len(buf)-1]
+	}
+	if len(buf) == 0 {
+		// Known: both libraries trim the newlines off the end of the
+		// last hundred bytes of a file, and look at the byte before
+		// the first of them, if they are all newlines.
+		r.crash("looking before a last hundred bytes of newlines")
+	}
+	buf = bytes.TrimRight(buf, "\r\n\t ")
+	if !bytes.HasSuffix(buf, []byte("%%EOF")) {
+		r.fail("not a PDF file: missing %%%%EOF")
+	}
+	i := findLastLine(buf, "startxref")
+	if i < 0 {
+		r.fail("malformed PDF file: missing final startxref")
+	}
+	b := r.section(end - endChunk + int64(i))
+	if b.readToken() != keyword("startxref") {
+		r.fail("malformed PDF file: missing startxref")
+	}
+	startxref, ok := b.readToken().(int64)
+	if !ok {
+		r.fail("malformed PDF file: startxref not followed by integer")
+	}
+	b = r.section(startxref)
+	switch tok := b.readToken(); tok.(type) {
+	case keyword:
+		if tok != keyword("xref") {
+			r.fail("malformed PDF: cross-reference table not found: %v", tok)
+		}
+		r.readXrefTable(b)
+	case int64:
+		b.unreadToken(tok)
+		r.readXrefStream(b)
+	default:
+		r.fail("malformed PDF: cross-reference table not found: %v", tok)
+	}
+	if r.trailer["Encrypt"] != nil {
+		r.initEncrypt()
+	}
+}
+
+func findLastLine(buf []byte, s string) int {
+	bs := []byte(s)
+	max := len(buf)
+	for {
+		i := bytes.LastIndex(buf[:max], bs)
+		if i <= 0 || i+len(bs) >= len(buf) {
+			return -1
+		}
+		if (buf[i-1] == '\n' || buf[i-1] == '\r') && (buf[i+len(bs)] == '\n' || buf[i+len(bs)] == '\r') {
+			return i
+		}
+		max = i
+	}
+}
+
+// prev returns the buffer a Prev entry points to, unless the libraries
+// have been there before.
+func (r *reader) prev(off int64, seen map[int64]bool) *buffer {
+	if seen[off] {
+		// Known: neither library remembers the sections it has read,
+		// and a Prev that points back to one of them reads them for
+		// ever.
+		r.hazard("reading the cross-reference section at %d again", off)
+	}
+	seen[off] = true
+	return r.section(off)
+}
+
+func (r *reader) readXrefTable(b *buffer) {
+	var table []xref
+	table = r.readXrefTableData(b, table)
+	trailer, ok := b.readObject().(dict)
+	if !ok {
+		r.fail("malformed PDF: xref table not followed by trailer dictionary")
+	}
+	seen := map[int64]bool{}
+	for prevoff := trailer["Prev"]; prevoff != nil; {
+		off, ok := prevoff.(int64)
+		if !ok {
+			r.fail("malformed PDF: xref Prev is not integer: %v", prevoff)
+		}
+		b := r.prev(off, seen)
+		if b.readToken() != keyword("xref") {
+			r.fail("malformed PDF: xref Prev does not point to xref")
+		}
+		table = r.readXrefTableData(b, table)
+		trailer, ok := b.readObject().(dict)
+		if !ok {
+			r.fail("malformed PDF: xref Prev table not followed by trailer dictionary")
+		}
+		prevoff = trailer["Prev"]
+	}
+	size, ok := trailer["Size"].(int64)
+	if !ok {
+		r.fail("malformed PDF: trailer missing /Size entry")
+	}
+	if size < int64(len(table)) {
+		if size < 0 {
+			// Known: both libraries cut the table to a Size that is
+			// negative.
+			r.crash("cutting the table to a Size of %d", size)
+		}
+		table = table[:size]
+	}
+	r.xref, r.trailerptr, r.trailer = table, objptr{}, trailer
+}
+
+// grow returns table grown to hold x, as the libraries grow it.
+func (r *reader) grow(table []xref, x int, stream bool) []xref {
+	if x < 0 {
+		// Known: both libraries take the number of an object in a
+		// cross-reference section for an index into their table.
+		r.crash("indexing the table at %d", x)
+	}
+	if x >= r.limit {
+		r.hazard("growing the table to more than %d entries", r.limit)
+	}
+	if r.fork {
+		if x >= len(table) {
+			table = append(table, make([]xref, x-len(table)+1)...)
+		}
+	} else {
+		for cap(table) <= x {
+			table = append(table[:cap(table)], xref{})
+		}
+		if len(table) <= x {
+			if stream {
+				// Known: rsc.io/pdf grows the table of an xref stream
+				// by one past its capacity, and no further.
+				r.crash("indexing the table at %d past its length %d", x, len(table))
+			}
+			table = table[:x+1]
+		}
+	}
+	r.entries = max(r.entries, cap(table))
+	return table
+}
+
+// count counts an entry read from a cross-reference section.
+func (r *reader) count() {
+	r.entries++
+	if r.entries > r.limit {
+		r.hazard("reading more than %d cross-reference entries", r.limit)
+	}
+}
+
+func (r *reader) readXrefTableData(b *buffer, table []xref) []xref {
+	for {
+		tok := b.readToken()
+		if tok == keyword("trailer") {
+			break
+		}
+		start, ok1 := tok.(int64)
+		n, ok2 := b.readToken().(int64)
+		if !ok1 || !ok2 {
+			r.fail("malformed PDF: malformed xref table")
+		}
+		for i := 0; i < int(n); i++ {
+			off, ok1 := b.readToken().(int64)
+			gen, ok2 := b.readToken().(int64)
+			alloc, ok3 := b.readToken().(keyword)
+			if !ok1 || !ok2 || !ok3 || alloc != keyword("f") && alloc != keyword("n") {
+				r.fail("malformed PDF: malformed xref table")
+			}
+			r.count()
+			x := int(start) + i
+			if x < 0 && alloc == "f" {
+				continue
+			}
+			table = r.grow(table, x, false)
+			if alloc == "n" && table[x].offset == 0 {
+				table[x] = xref{ptr: objptr{uint32(x), uint16(gen)}, offset: off}
+			}
+		}
+	}
+	return table
+}
+
+func (r *reader) readXrefStream(b *buffer) {
+	obj, ok := b.readObject().(objdef)
+	if !ok {
+		r.fail("malformed PDF: cross-reference table not found")
+	}
+	strm, ok := obj.obj.(stream)
+	if !ok {
+		r.fail("malformed PDF: cross-reference table not found")
+	}
+	if strm.hdr["Type"] != name("XRef") {
+		r.fail("malformed PDF: xref stream does not have type XRef")
+	}
+	size, ok := strm.hdr["Size"].(int64)
+	if !ok {
+		r.fail("malformed PDF: xref stream missing Size")
+	}
+	if size < 0 {
+		// Known: both libraries make a table of the Size of an xref
+		// stream, which may be negative.
+		r.crash("making a table of %d entries", size)
+	}
+	if size > int64(r.limit) {
+		r.hazard("making a table of more than %d entries", r.limit)
+	}
+	table := make([]xref, size)
+	r.entries = max(r.entries, int(size))
+	table = r.readXrefStreamData(strm, table, size)
+	seen := map[int64]bool{}
+	for prevoff := strm.hdr["Prev"]; prevoff != nil; {
+		off, ok := prevoff.(int64)
+		if !ok {
+			r.fail("malformed PDF: xref Prev is not integer: %v", prevoff)
+		}
+		b := r.prev(off, seen)
+		obj, ok := b.readObject().(objdef)
+		if !ok {
+			r.fail("malformed PDF: xref prev stream not found")
+		}
+		prev, ok := obj.obj.(stream)
+		if !ok {
+			r.fail("malformed PDF: xref prev stream not found")
+		}
+		prevoff = prev.hdr["Prev"]
+		if t, _ := r.key(prev, "Type").(name); t != "XRef" {
+			r.fail("malformed PDF: xref prev stream does not have type XRef")
+		}
+		psize, _ := r.key(prev, "Size").(int64)
+		if psize > size {
+			r.fail("malformed PDF: xref prev stream larger than last stream")
+		}
+		table = r.readXrefStreamData(prev, table, psize)
+	}
+	r.xref, r.trailerptr, r.trailer = table, obj.ptr, strm.hdr
+}
+
+// maxWidth is the widest entry of an xref stream the harness reads.
+const maxWidth = 1 << 16
+
+func (r *reader) readXrefStreamData(strm stream, table []xref, size int64) []xref {
+	index, _ := strm.hdr["Index"].(array)
+	if index == nil {
+		index = array{int64(0), size}
+	}
+	if len(index)%2 != 0 {
+		r.fail("malformed PDF: invalid Index array")
+	}
+	ww, ok := strm.hdr["W"].(array)
+	if !ok {
+		r.fail("malformed PDF: xref stream missing W array")
+	}
+	var w []int
+	for _, x := range ww {
+		i, ok := x.(int64)
+		if !ok {
+			r.fail("malformed PDF: invalid W array")
+		}
+		w = append(w, int(i))
+	}
+	if len(w) < 3 {
+		r.fail("malformed PDF: invalid W array")
+	}
+	wtotal := 0
+	for _, wid := range w {
+		wtotal += wid
+	}
+	if wtotal < 0 {
+		// Known: both libraries make a buffer of the sum of the widths
+		// of an xref stream, which may be negative.
+		r.crash("making a buffer of %d bytes", wtotal)
+	}
+	if wtotal > maxWidth {
+		r.hazard("making a buffer of more than %d bytes", maxWidth)
+	}
+	buf := make([]byte, wtotal)
+	data := r.streamReader(strm)
+	for len(index) > 0 {
+		start, ok1 := index[0].(int64)
+		n, ok2 := index[1].(int64)
+		if !ok1 || !ok2 {
+			r.fail("malformed PDF: malformed Index pair")
+		}
+		index = index[2:]
+		for i := 0; i < int(n); i++ {
+			if _, err := io.ReadFull(data, buf); err != nil {
+				r.fail("malformed PDF: error reading xref stream: %v", err)
+			}
+			r.count()
+			at := 0
+			for _, wid := range w[:3] {
+				if wid < 0 || wid > wtotal-at {
+					// Known: both libraries cut the fields of an entry
+					// out of its buffer by their widths, any of which
+					// may be negative, or more than the sum of them
+					// all.
+					r.crash("cutting fields of widths %v out of %d bytes", w[:3], wtotal)
+				}
+				at += wid
+			}
+			v1 := decodeInt(buf[0:w[0]])
+			if w[0] == 0 {
+				v1 = 1
+			}
+			v2 := decodeInt(buf[w[0] : w[0]+w[1]])
+			v3 := decodeInt(buf[w[0]+w[1] : w[0]+w[1]+w[2]])
+			x := int(start) + i
+			table = r.grow(table, x, true)
+			if table[x].ptr != (objptr{}) {
+				continue
+			}
+			switch v1 {
+			case 0:
+				table[x] = xref{ptr: objptr{0, 65535}}
+			case 1:
+				table[x] = xref{ptr: objptr{uint32(x), uint16(v3)}, offset: int64(v2)}
+			case 2:
+				table[x] = xref{ptr: objptr{uint32(x), 0}, inStream: true, stream: objptr{uint32(v2), 0}, offset: int64(v3)}
+			}
+		}
+	}
+	return table
+}
+
+func decodeInt(b []byte) int {
+	x := 0
+	for _, c := range b {
+		x = x<<8 | int(c)
+	}
+	return x
+}
+
+// key returns the value of k in the dictionary of v, resolved.
+func (r *reader) key(v object, k name) object {
+	switch v := v.(type) {
+	case dict:
+		return r.resolve(v[k])
+	case stream:
+		return r.resolve(v.hdr[k])
+	}
+	return nil
+}
+
+// index returns the ith element of the array v, resolved.
+func (r *reader) index(v object, i int) object {
+	x, ok := v.(array)
+	if !ok || i < 0 || i >= len(x) {
+		return nil
+	}
+	return r.resolve(x[i])
+}
+
+// keys returns the sorted keys of the dictionary of v, or nil if it
+// has none.
+func keys(v object) []string {
+	var d dict
+	switch v := v.(type) {
+	case dict:
+		d = v
+	case stream:
+		d = v.hdr
+	default:
+		return nil
+	}
+	ks := []string{}
+	for k := range d {
+		ks = append(ks, string(k))
+	}
+	slices.Sort(ks)
+	return ks
+}
+
+// resolve returns x, or the object it refers to.
+func (r *reader) resolve(x object) object {
+	if ptr, ok := x.(objptr); ok {
+		x = r.load(ptr)
+	}
+	switch x.(type) {
+	case nil, bool, int64, float64, string, name, dict, array, stream:
+		return x
+	}
+	r.fail("unexpected value type %T in resolve", x)
+	return nil
+}
+
+// load returns the object ptr refers to, as the table says where it is.
+func (r *reader) load(ptr objptr) object {
+	if int(ptr.id) >= len(r.xref) {
+		return nil
+	}
+	xr := r.xref[ptr.id]
+	if xr.ptr != ptr || !xr.inStream && xr.offset == 0 {
+		return nil
+	}
+	if r.resolving[ptr] {
+		// Known: both libraries resolve an object in an object stream
+		// by resolving the stream, and the stream may be in itself, or
+		// its Length may be in it.
+		r.hazard("resolving %d %d R while resolving it", ptr.id, ptr.gen)
+	}
+	r.resolving[ptr] = true
+	defer delete(r.resolving, ptr)
+	if !xr.inStream {
+		b := r.section(xr.offset)
+		b.key, b.useAES = r.fileKey, r.useAES
+		def, ok := b.readObject().(objdef)
+		if !ok {
+			r.fail("loading %v: found no objdef", ptr)
+		}
+		if def.ptr != ptr {
+			r.fail("loading %v: found %v", ptr, def.ptr)
+		}
+		return def.obj
+	}
+	strm := r.resolve(xr.stream)
+	seen := map[int64]bool{}
+	for {
+		s, ok := strm.(stream)
+		if !ok {
+			r.fail("not a stream")
+		}
+		if seen[s.offset] {
+			// Known: both libraries follow the Extends of an object
+			// stream for ever if it comes back to one they have been
+			// to.
+			r.hazard("following Extends back to an object stream")
+		}
+		seen[s.offset] = true
+		if t, _ := r.key(s, "Type").(name); t != "ObjStm" {
+			r.fail("not an object stream")
+		}
+		nn, _ := r.key(s, "N").(int64)
+		n := int(nn)
+		first, _ := r.key(s, "First").(int64)
+		if first == 0 {
+			r.fail("missing First")
+		}
+		b := r.newBuffer(r.streamReader(s), 0)
+		b.allowEOF = true
+		for i := range max(n, 0) {
+			if b.eof && ptr.id != 0 {
+				if n-i > maxEntries {
+					// Known: both libraries read as many entries as the
+					// N of an object stream says, past the end of it.
+					r.hazard("reading %d entries past the end of an object stream", n-i)
+				}
+				break
+			}
+			id, _ := b.readToken().(int64)
+			off, _ := b.readToken().(int64)
+			if uint32(id) == ptr.id {
+				b.seekForward(first + off)
+				return b.readObject()
+			}
+		}
+		strm = r.key(s, "Extends")
+	}
+}
+
+// maxEntries is the most entries of an object stream the harness lets
+// a library read past the end of it.
+const maxEntries = 1 << 20
+
+// maxColumns is the most columns the harness lets a predictor have.
+const maxColumns = 1 << 20
+
+// streamReader returns the data of s as Value.Reader does, decrypted
+// and decoded.
+func (r *reader) streamReader(s stream) io.Reader {
+	length, _ := r.key(s, "Length").(int64)
+	if r.fork && length == 0 {
+		return bytes.NewReader(nil)
+	}
+	var rd io.Reader = io.NewSectionReader(bytes.NewReader(r.data), s.offset, length)
+	if r.fileKey != nil {
+		rd = decryptStream(r.fileKey, r.useAES, s.ptr, rd)
+	}
+	filter := r.key(s, "Filter")
+	param := r.key(s, "DecodeParms")
+	switch f := filter.(type) {
+	default:
+		r.fail("unsupported filter %v", filter)
+	case nil:
+	case name:
+		rd = r.applyFilter(rd, string(f), param)
+	case array:
+		for i := range f {
+			fn, _ := r.index(f, i).(name)
+			rd = r.applyFilter(rd, string(fn), r.index(param, i))
+		}
+	}
+	return rd
+}
+
+func (r *reader) applyFilter(rd io.Reader, filter string, param object) io.Reader {
+	switch filter {
+	case "FlateDecode":
+		zr, err := zlib.NewReader(rd)
+		if err != nil {
+			r.fail("%v", err)
+		}
+		pred := r.key(param, "Predictor")
+		if pred == nil {
+			return zr
+		}
+		columns, _ := r.key(param, "Columns").(int64)
+		if p, _ := pred.(int64); p != 12 {
+			r.fail("pred")
+		}
+		if columns < -1 {
+			// Known: both libraries make a row of a predictor of its
+			// Columns and one, which may be negative, and read the
+			// tag of a row of none.
+			r.crash("making a row of %d bytes", columns+1)
+		}
+		if columns > maxColumns {
+			r.hazard("making a row of more than %d bytes", maxColumns)
+		}
+		return &pngUpReader{ref: r, r: zr, hist: make([]byte, 1+columns), tmp: make([]byte, 1+columns)}
+	case "ASCII85Decode":
+		if r.fork {
+			if keys(param) != nil {
+				r.fail("not expected DecodeParms for ascii85")
+			}
+			return ascii85.NewDecoder(&alphaReader{reader: rd})
+		}
+	}
+	r.fail("unknown filter %s", filter)
+	return nil
+}
+
+// A pngUpReader decodes the rows of PNG Up prediction, as the
+// libraries' does.
+type pngUpReader struct {
+	ref  *reader
+	r    io.Reader
+	hist []byte
+	tmp  []byte
+	pend []byte
+}
+
+func (r *pngUpReader) Read(b []byte) (int, error) {
+	n := 0
+	for len(b) > 0 {
+		if len(r.pend) > 0 {
+			m := copy(b, r.pend)
+			n += m
+			b = b[m:]
+			r.pend = r.pend[m:]
+			continue
+		}
+		if _, err := io.ReadFull(r.r, r.tmp); err != nil {
+			return n, err
+		}
+		if len(r.tmp) == 0 {
+			r.ref.crash("reading the tag of a row of no bytes")
+		}
+		if r.tmp[0] != 2 {
+			return n, fmt.Errorf("malformed PNG-Up encoding")
+		}
+		for i, b := range r.tmp {
+			r.hist[i] += b
+		}
+		r.pend = r.hist[1:]
+	}
+	return n, nil
+}
+
+// An alphaReader keeps the characters of ASCII85Decode, up to ~, as
+// ledongthuc/pdf's does.
+type alphaReader struct {
+	reader io.Reader
+	eod    bool
+}
+
+func (a *alphaReader) Read(p []byte) (int, error) {
+	if a.eod {
+		return 0, io.EOF
+	}
+	n, err := a.reader.Read(p)
+	out := 0
+	for i := range n {
+		c := p[i]
+		if c == '~' {
+			a.eod = true
+			return out, io.EOF
+		}
+		if c >= '!' && c <= 'u' || c == 'z' {
+			p[out] = c
+			out++
+		}
+	}
+	return out, err
+}
+
+var passwordPad = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// initEncrypt finds the file key for the empty password, as algorithm 2
+// makes it, and checks it against U.
+//
+// Known: both libraries leave EncryptMetadata out of the key.
+func (r *reader) initEncrypt() {
+	encrypt, _ := r.resolve(r.trailer["Encrypt"]).(dict)
+	if encrypt["Filter"] != name("Standard") {
+		r.fail("unsupported PDF: encryption filter %v", encrypt["Filter"])
+	}
+	n, _ := encrypt["Length"].(int64)
+	if n == 0 {
+		n = 40
+	}
+	if n%8 != 0 || n > 128 || n < 40 {
+		r.fail("malformed PDF: %d-bit encryption key", n)
+	}
+	v, _ := encrypt["V"].(int64)
+	if v != 1 && v != 2 && (v != 4 || !okayV4(encrypt)) {
+		r.fail("unsupported PDF: encryption version V=%d", v)
+	}
+	ids, ok := r.trailer["ID"].(array)
+	if !ok || len(ids) < 1 {
+		r.fail("malformed PDF: missing ID in trailer")
+	}
+	id, ok := ids[0].(string)
+	if !ok {
+		r.fail("malformed PDF: missing ID in trailer")
+	}
+	rev, _ := encrypt["R"].(int64)
+	if rev < 2 || rev > 4 {
+		r.fail("unsupported PDF: encryption revision R=%d", rev)
+	}
+	o, _ := encrypt["O"].(string)
+	u, _ := encrypt["U"].(string)
+	if len(o) != 32 || len(u) != 32 {
+		r.fail("malformed PDF: missing O= or U= encryption parameters")
+	}
+	p, _ := encrypt["P"].(int64)
+	h := md5.New()
+	h.Write(passwordPad)
+	h.Write([]byte(o))
+	h.Write([]byte{byte(p), byte(p >> 8), byte(p >> 16), byte(p >> 24)})
+	h.Write([]byte(id))
+	key := h.Sum(nil)
+	if rev >= 3 {
+		for range 50 {
+			sum := md5.Sum(key[:n/8])
+			key = sum[:]
+		}
+		key = key[:n/8]
+	} else {
+		key = key[:5]
+	}
+	var want []byte
+	if rev == 2 {
+		want = slices.Clone(passwordPad)
+		rc4XOR(key, want)
+	} else {
+		sum := md5.Sum(append(slices.Clone(passwordPad), id...))
+		want = sum[:]
+		rc4XOR(key, want)
+		for i := 1; i <= 19; i++ {
+			k := slices.Clone(key)
+			for j := range k {
+				k[j] ^= byte(i)
+			}
+			rc4XOR(k, want)
+		}
+	}
+	if !bytes.HasPrefix([]byte(u), want) {
+		panic(&failure{msg: "encrypted PDF: invalid password", password: true})
+	}
+	r.fileKey, r.useAES = key, v == 4
+}
+
+func okayV4(encrypt dict) bool {
+	cf, ok := encrypt["CF"].(dict)
+	if !ok {
+		return false
+	}
+	stmf, ok := encrypt["StmF"].(name)
+	if !ok {
+		return false
+	}
+	strf, ok := encrypt["StrF"].(name)
+	if !ok || stmf != strf {
+		return false
+	}
+	param, _ := cf[stmf].(dict)
+	if param["AuthEvent"] != nil && param["AuthEvent"] != name("DocOpen") {
+		return false
+	}
+	if param["Length"] != nil && param["Length"] != int64(16) {
+		return false
+	}
+	return param["CFM"] == name("AESV2")
+}
+
+func rc4XOR(key, b []byte) {
+	c, _ := rc4.NewCipher(key)
+	c.XORKeyStream(b, b)
+}
+
+// cryptKey returns the key of the object ptr.
+//
+// Known: both libraries keep all sixteen bytes of the hash, where a key
+// of n bytes keeps n+5 of them, so that a key shorter than 88 bits
+// decrypts to nonsense.
+func cryptKey(key []byte, useAES bool, ptr objptr) []byte {
+	h := md5.New()
+	h.Write(key)
+	h.Write([]byte{byte(ptr.id), byte(ptr.id >> 8), byte(ptr.id >> 16), byte(ptr.gen), byte(ptr.gen >> 8)})
+	if useAES {
+		h.Write([]byte("sAlT"))
+	}
+	return h.Sum(nil)
+}
+
+// decryptString decrypts a string of the object ptr. Only
+// ledongthuc/pdf decrypts strings with AES.
+//
+// Known: ledongthuc/pdf leaves the padding on a string it decrypts with
+// AES.
+func (r *reader) decryptString(ptr objptr, x string) string {
+	key := cryptKey(r.fileKey, r.useAES, ptr)
+	data := []byte(x)
+	if !r.useAES {
+		rc4XOR(key, data)
+		return string(data)
+	}
+	if !r.fork {
+		r.fail("AES not implemented")
+	}
+	if len(data) < aes.BlockSize {
+		r.fail("Encrypted text shorter that AES block size")
+	}
+	if len(data)%aes.BlockSize != 0 {
+		r.fail("crypto/cipher: input not full blocks")
+	}
+	block, _ := aes.NewCipher(key)
+	cipher.NewCBCDecrypter(block, data[:aes.BlockSize]).CryptBlocks(data[aes.BlockSize:], data[aes.BlockSize:])
+	return string(data[aes.BlockSize:])
+}
+
+// decryptStream returns rd decrypted as the stream of the object ptr.
+//
+// Known: both libraries leave the padding on a stream they decrypt
+// with AES.
+func decryptStream(key []byte, useAES bool, ptr objptr, rd io.Reader) io.Reader {
+	key = cryptKey(key, useAES, ptr)
+	if !useAES {
+		c, _ := rc4.NewCipher(key)
+		return &cipher.StreamReader{S: c, R: rd}
+	}
+	block, _ := aes.NewCipher(key)
+	iv := make([]byte, aes.BlockSize)
+	io.ReadFull(rd, iv)
+	return &cbcReader{cbc: cipher.NewCBCDecrypter(block, iv), rd: rd, buf: make([]byte, aes.BlockSize)}
+}
+
+// A cbcReader decrypts AES a block at a time, as the libraries' does.
+type cbcReader struct {
+	cbc  cipher.BlockMode
+	rd   io.Reader
+	buf  []byte
+	pend []byte
+}
+
+func (r *cbcReader) Read(b []byte) (int, error) {
+	if len(r.pend) == 0 {
+		if _, err := io.ReadFull(r.rd, r.buf); err != nil {
+			return 0, err
+		}
+		r.cbc.CryptBlocks(r.buf, r.buf)
+		r.pend = r.buf
+	}
+	n := copy(b, r.pend)
+	r.pend = r.pend[n:]
+	return n, nil
+}
+
+// A node is a value of the page tree, and the object it is, if it is
+// one of its own.
+type node struct {
+	v   object
+	ptr objptr
+}
+
+// child returns the value of k in the dictionary of n, and the object
+// it is.
+func (r *reader) child(n node, k name, i int) node {
+	var x object
+	switch v := n.v.(type) {
+	case dict:
+		x = v[k]
+	case stream:
+		x = v.hdr[k]
+	case array:
+		if i >= 0 && i < len(v) {
+			x = v[i]
+		}
+	}
+	ptr, _ := x.(objptr)
+	return node{r.resolve(x), ptr}
+}
+
+// visit notes that the page tree reaches n, and reports whether it has
+// before, for ever as the libraries go round it.
+func visit(seen map[objptr]bool, n node) {
+	if n.ptr == (objptr{}) {
+		return
+	}
+	if seen[n.ptr] {
+		// Known: both libraries go down the Kids of the page tree, and
+		// up the Parent of a page, for as long as it goes on.
+		panic(&failure{msg: fmt.Sprintf("reaching %d %d R in the page tree again", n.ptr.id, n.ptr.gen), hazard: true})
+	}
+	seen[n.ptr] = true
+}
+
+func (r *reader) root() node {
+	return r.child(r.child(node{v: r.trailer}, "Root", 0), "Pages", 0)
+}
+
+// numPage returns the page count as NumPage does.
+func (r *reader) numPage() int {
+	count, _ := r.key(r.root().v, "Count").(int64)
+	return int(count)
+}
+
+// page returns page num as Page does, and the node it is.
+func (r *reader) page(num int) node {
+	num--
+	page := r.root()
+	seen := map[objptr]bool{}
+Search:
+	for typeName(r.key(page.v, "Type")) == "Pages" {
+		visit(seen, page)
+		count, _ := r.key(page.v, "Count").(int64)
+		if int(count) < num {
+			return node{}
+		}
+		kids := r.child(page, "Kids", 0)
+		for i := 0; i < kidsLen(kids.v); i++ {
+			kid := r.child(kids, "", i)
+			if typeName(r.key(kid.v, "Type")) == "Pages" {
+				c, _ := r.key(kid.v, "Count").(int64)
+				if num < int(c) {
+					page = kid
+					continue Search
+				}
+				num -= int(c)
+				continue
+			}
+			if typeName(r.key(kid.v, "Type")) == "Page" {
+				if num == 0 {
+					return kid
+				}
+				num--
+			}
+		}
+		break
+	}
+	return node{}
+}
+
+func typeName(v object) name {
+	n, _ := v.(name)
+	return n
+}
+
+func kidsLen(v object) int {
+	a, _ := v.(array)
+	return len(a)
+}
+
+// fonts returns the fonts of page as Fonts does: the keys of the Font
+// of the Resources it has or inherits.
+func (r *reader) fonts(page node) []string {
+	seen := map[objptr]bool{}
+	var res object
+	for n := page; n.v != nil; n = r.child(n, "Parent", 0) {
+		visit(seen, n)
+		if res = r.key(n.v, "Resources"); res != nil {
+			break
+		}
+	}
+	return keys(r.key(res, "Font"))
+}
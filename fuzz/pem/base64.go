@@ -0,0 +1,201 @@
+package pem
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing/iotest"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+const (
+	stdAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+	urlAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+)
+
+// An encoding is one of encoding/base64's and the rules it reads by.
+type encoding struct {
+	name           string
+	enc            *base64.Encoding
+	alphabet       string
+	padded, strict bool
+}
+
+var encodings = []encoding{
+	{"StdEncoding", base64.StdEncoding, stdAlphabet, true, false},
+	{"URLEncoding", base64.URLEncoding, urlAlphabet, true, false},
+	{"RawStdEncoding", base64.RawStdEncoding, stdAlphabet, false, false},
+	{"RawURLEncoding", base64.RawURLEncoding, urlAlphabet, false, false},
+	{"StdEncoding.Strict", base64.StdEncoding.Strict(), stdAlphabet, true, true},
+	{"URLEncoding.Strict", base64.URLEncoding.Strict(), urlAlphabet, true, true},
+	{"RawStdEncoding.Strict", base64.RawStdEncoding.Strict(), stdAlphabet, false, true},
+	{"RawURLEncoding.Strict", base64.RawURLEncoding.Strict(), urlAlphabet, false, true},
+}
+
+// decode reads data as base64 in alphabet by RFC 4648 as encoding/base64
+// reads it: carriage returns and line feeds are skipped wherever they
+// are; a padded encoding must pad the last group of four to its length
+// with one or two '=' and have nothing after them, and an unpadded one
+// has no padding and so no group of one character; and a strict one
+// rejects bits set past the last byte.
+func decode(data []byte, alphabet string, padded, strict bool) ([]byte, bool) {
+	s := unbreak(data)
+	if padded {
+		body := strings.TrimRight(s, "=")
+		n := len(s) - len(body)
+		if len(s)%4 != 0 || n > 2 {
+			return nil, false
+		}
+		s = body
+	}
+	if len(s)%4 == 1 {
+		return nil, false
+	}
+	out, acc, ok := bits(s, alphabet)
+	if !ok || strict && acc != 0 {
+		return nil, false
+	}
+	return out, true
+}
+
+// bits returns the bytes the characters of s in alphabet spell, six bits
+// each, and what is left of their bits past the last whole byte, or
+// reports that s has a character the alphabet does not.
+func bits(s, alphabet string) (out []byte, rest uint, ok bool) {
+	var bits uint
+	for i := range len(s) {
+		v := strings.IndexByte(alphabet, s[i])
+		if v < 0 {
+			return out, rest, false
+		}
+		rest, bits = rest<<6|uint(v), bits+6
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(rest>>bits))
+			rest &= 1<<bits - 1
+		}
+	}
+	return out, rest, true
+}
+
+// unbreak returns data without its carriage returns and line feeds.
+func unbreak(data []byte) string {
+	return strings.Map(func(r rune) rune {
+		if r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, string(data))
+}
+
+// CheckBase64 checks data read as base64 by each of encoding/base64's
+// encodings, strict and not.
+func CheckBase64(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		for _, e := range encodings {
+			if err := checkDecode(data, e); err != nil {
+				return fmt.Errorf("%s: %v", e.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// checkDecode checks that data decodes exactly when decode reads it, to
+// the same bytes, whether as a string, into a buffer, appended or read
+// one byte at a time, and that the bytes encode back to data when it is
+// in the encoding's one form for them.
+func checkDecode(data []byte, e encoding) error {
+	want, ok := decode(data, e.alphabet, e.padded, e.strict)
+	got, err := e.enc.DecodeString(string(data))
+	var corrupt base64.CorruptInputError
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("DecodeString(%q): %v, but it reads as %x", data, err, want)
+	case !ok && err == nil:
+		return fmt.Errorf("DecodeString(%q) = %x, but it does not read", data, got)
+	case err != nil && (!errors.As(err, &corrupt) || corrupt < 0 || int(corrupt) > len(data)):
+		return fmt.Errorf("DecodeString(%q): %v, which is not a CorruptInputError at an offset in the input", data, err)
+	case ok && !bytes.Equal(got, want):
+		return fmt.Errorf("DecodeString(%q) = %x, want %x", data, got, want)
+	}
+
+	// What Decode writes before an error is what the characters before
+	// the first that is not in the alphabet spell.
+	buf := make([]byte, e.enc.DecodedLen(len(data)))
+	n, err := e.enc.Decode(buf, data)
+	valid := unbreak(data)
+	if i := strings.IndexFunc(valid, func(r rune) bool { return !strings.ContainsRune(e.alphabet, r) }); i >= 0 {
+		valid = valid[:i]
+	}
+	spelled, _, _ := bits(valid, e.alphabet)
+	switch {
+	case (err == nil) != ok:
+		return fmt.Errorf("Decode(%q): %v, but DecodeString agrees with the reader", data, err)
+	case ok && !bytes.Equal(buf[:n], want):
+		return fmt.Errorf("Decode(%q) = %x, want %x", data, buf[:n], want)
+	case n > len(spelled) || !bytes.Equal(buf[:n], spelled[:n]):
+		return fmt.Errorf("Decode(%q) wrote %x before %v, which is not what %q spells", data, buf[:n], err, valid)
+	}
+
+	app, err := e.enc.AppendDecode([]byte(prefix), data)
+	if (err == nil) != ok || ok && string(app) != prefix+string(want) {
+		return fmt.Errorf("AppendDecode(%q) = %q, %v, want %x", data, app, err, want)
+	}
+	// Known: a Decoder whose reader gives it four characters at a time
+	// takes padding that ends a group as the end of one text and reads
+	// on after it as another's.
+	streamed, err := io.ReadAll(base64.NewDecoder(e.enc, iotest.OneByteReader(bytes.NewReader(data))))
+	if text := strings.TrimRight(unbreak(data), "="); e.padded && strings.Contains(text, "=") && err == nil {
+		return nil
+	}
+	if (err == nil) != ok || ok && !bytes.Equal(streamed, want) {
+		return fmt.Errorf("NewDecoder reading %q one byte at a time = %x, %v, want %x", data, streamed, err, want)
+	}
+	if !ok {
+		return nil
+	}
+	return checkEncode(data, want, e)
+}
+
+// prefix is what the Append functions are given to append to.
+const prefix = "prefix:"
+
+// checkEncode checks that b, which data decodes to, encodes to text that
+// decodes back to b, and that is data without its line breaks if data
+// has no bits set past its last byte; that EncodedLen and DecodedLen
+// agree; and that AppendEncode and an Encoder written to in pieces encode
+// the same.
+func checkEncode(data, b []byte, e encoding) error {
+	enc := e.enc.EncodeToString(b)
+	if back, err := e.enc.DecodeString(enc); err != nil || !bytes.Equal(back, b) {
+		return fmt.Errorf("%q decodes as %x, which encodes as %q, which decodes as %x: %v", data, b, enc, back, err)
+	}
+	if _, canonical := decode(data, e.alphabet, e.padded, true); canonical && enc != unbreak(data) {
+		return fmt.Errorf("%q decodes as %x, which encodes as %q", data, b, enc)
+	}
+	if e.enc.EncodedLen(len(b)) != len(enc) || e.enc.DecodedLen(len(enc)) < len(b) {
+		return fmt.Errorf("%x encodes as %q, but EncodedLen gives %d and DecodedLen %d", b, enc, e.enc.EncodedLen(len(b)), e.enc.DecodedLen(len(enc)))
+	}
+	if app := string(e.enc.AppendEncode([]byte(prefix), b)); app != prefix+enc {
+		return fmt.Errorf("%x encodes as %q, but appends as %q", b, enc, app)
+	}
+	for _, size := range []int{1, 2, 3, 5} {
+		var out strings.Builder
+		w := base64.NewEncoder(e.enc, &out)
+		for rest := b; len(rest) > 0; {
+			k := min(size, len(rest))
+			w.Write(rest[:k])
+			rest = rest[k:]
+		}
+		w.Close()
+		if out.String() != enc {
+			return fmt.Errorf("%x encodes as %q, but written %d bytes at a time as %q", b, enc, size, out.String())
+		}
+	}
+	return nil
+}
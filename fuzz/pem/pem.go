@@ -0,0 +1,230 @@
+// Package pem is a fuzz target for encoding/pem and encoding/base64.
+// CheckPEM reads the blocks of data one after another with pem.Decode,
+// which must return each only if the harness's reader finds a block
+// there, between a BEGIN line and the first END line after it, with the
+// same type, headers and bytes, and must not pass over a block the reader
+// finds; each block must encode, in lines of 64 characters, to text that
+// decodes to it again. CheckBase64 decodes data with each of base64's
+// encodings, strict and not, which must read it exactly when the reader
+// of RFC 4648 does, to the same bytes, as a string, into a buffer,
+// appended and streamed; what they read must encode back to data where
+// data is the one encoding of it.
+package pem
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds checking one input.
+var Timeout = 10 * time.Second
+
+// A line is a line of text: its text, without its line feed and a
+// carriage return before it, and the offsets of its start and of the
+// next line's.
+type line struct {
+	text        string
+	start, next int
+}
+
+// lines splits data into lines after each line feed.
+func lines(data string) []line {
+	var ls []line
+	for start := 0; start < len(data); {
+		text, next := data[start:], len(data)
+		if end := strings.IndexByte(text, '\n'); end >= 0 {
+			text, next = strings.TrimSuffix(text[:end], "\r"), start+end+1
+		}
+		ls = append(ls, line{text, start, next})
+		start = next
+	}
+	return ls
+}
+
+// marker returns the type of a BEGIN or END line, kind, which is the
+// text between the marker and the five dashes that end the line, spaces
+// and tabs after them dropped; or reports that l is not one.
+func marker(l line, kind string) (string, bool) {
+	text := strings.TrimRight(l.text, " \t")
+	typ, ok := strings.CutPrefix(text, "-----"+kind+" ")
+	if !ok {
+		return "", false
+	}
+	return strings.CutSuffix(typ, "-----")
+}
+
+// block reads the block from line i, a BEGIN line, to line j, an END
+// line, as pem.Decode reads it by RFC 1421: headers, a line each of a key
+// and a value on either side of the first colon, then the body, base64
+// in the standard encoding with padding, its spaces and tabs dropped. A
+// block with headers must have a line after them before its END line. It
+// reports that the block does not read.
+func block(data string, ls []line, i, j int) (*pem.Block, bool) {
+	typ, _ := marker(ls[i], "BEGIN")
+	b := &pem.Block{Type: typ, Headers: map[string]string{}}
+	k := i + 1
+	for ; k < j; k++ {
+		key, val, ok := strings.Cut(strings.TrimRight(ls[k].text, " \t"), ":")
+		if !ok {
+			break
+		}
+		b.Headers[strings.TrimSpace(key)] = strings.TrimSpace(val)
+	}
+	if len(b.Headers) > 0 && k == j {
+		return nil, false
+	}
+	body := ""
+	if k < j {
+		body = data[ls[k].start : ls[j].start-1]
+	}
+	var ok bool
+	b.Bytes, ok = decode([]byte(strings.NewReplacer(" ", "", "\t", "").Replace(body)), stdAlphabet, true, false)
+	return b, ok
+}
+
+// first returns the first block in data the reader finds and the offset
+// of the line after it. It finds only blocks whose type has no colon,
+// control character or BEGIN marker in it and has no BEGIN marker or END
+// line inside, where pem.Decode must find the same block.
+func first(data string) (*pem.Block, int, bool) {
+	ls := lines(data)
+	for i := range ls {
+		typ, ok := marker(ls[i], "BEGIN")
+		if !ok || strings.ContainsAny(typ, ":\x7f") || strings.Contains(typ, "-----BEGIN ") ||
+			strings.ContainsFunc(typ, func(r rune) bool { return r < ' ' }) {
+			continue
+		}
+		for j := i + 1; j < len(ls); j++ {
+			if strings.HasPrefix(ls[j].text, "-----END ") {
+				if end, ok := marker(ls[j], "END"); ok && end == typ {
+					if b, ok := block(data, ls, i, j); ok {
+						return b, ls[j].next, true
+					}
+				}
+				break
+			}
+			if strings.Contains(ls[j].text, "-----BEGIN ") {
+				break
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// same reports whether a and b have the same type, headers and bytes.
+func same(a, b *pem.Block) bool {
+	return a.Type == b.Type && maps.Equal(a.Headers, b.Headers) && bytes.Equal(a.Bytes, b.Bytes)
+}
+
+// CheckPEM checks the blocks of data.
+func CheckPEM(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		rest := data
+		for {
+			p, next := pem.Decode(rest)
+			if err := checkBlock(string(rest), p, next); err != nil {
+				return err
+			}
+			if p == nil {
+				return nil
+			}
+			if err := checkEncoding(p); err != nil {
+				return err
+			}
+			rest = next
+		}
+	})
+}
+
+// checkBlock checks the block p, with the text next after it, that
+// pem.Decode read from data: it must be the block from the last BEGIN
+// line before its END line, which ends the text it read, to that line,
+// and end no later than the first block the reader finds.
+func checkBlock(data string, p *pem.Block, next []byte) error {
+	want, wantEnd, found := first(data)
+	if p == nil {
+		if string(next) != data {
+			return fmt.Errorf("Decode(%q) found no block, but returned %q after it", data, next)
+		}
+		// Known: pem.Decode reads an END line with a colon in it, right
+		// after a BEGIN line or its headers, as a header, and then looks
+		// no further.
+		if found && !slices.ContainsFunc(lines(data[:wantEnd]), func(l line) bool {
+			return strings.HasPrefix(l.text, "-----END ") && strings.Contains(l.text, ":")
+		}) {
+			return fmt.Errorf("Decode(%q) found no block, but the reader finds %+v", data, want)
+		}
+		return nil
+	}
+	end := len(data) - len(next)
+	if end <= 0 || data[end:] != string(next) {
+		return fmt.Errorf("Decode(%q) returned %q after its block, which is not what is left of the text", data, next)
+	}
+	if found && end > wantEnd {
+		return fmt.Errorf("Decode(%q) read a block ending at %d, after the block %+v the reader finds ending at %d", data, end, want, wantEnd)
+	}
+	read := data[:end]
+	ls := lines(read)
+	j := len(ls) - 1
+	if typ, ok := marker(ls[j], "END"); !ok || typ != p.Type || j == 0 {
+		return fmt.Errorf("Decode(%q) read a block of type %q from %q, which does not end in its END line", data, p.Type, read)
+	}
+	begin := strings.LastIndex(read[:ls[j].start], "-----BEGIN ")
+	i := slices.IndexFunc(ls, func(l line) bool { return l.start == begin })
+	// Known: pem.Decode goes on looking for a block right after the END
+	// marker of one that does not read, and takes a BEGIN marker there as
+	// the start of a line.
+	if i < 0 && begin >= 0 && strings.HasSuffix(read[:begin], "\n-----END ") {
+		i = len(lines(read[:begin])) - 1
+		ls[i].text, ls[i].start = ls[i].text[len("-----END "):], begin
+	}
+	if i < 0 {
+		return fmt.Errorf("Decode(%q) read a block from %q, whose last BEGIN marker does not start a line", data, read)
+	}
+	if typ, ok := marker(ls[i], "BEGIN"); !ok || typ != p.Type {
+		return fmt.Errorf("Decode(%q) read a block of type %q from %q, which has a BEGIN line of %q", data, p.Type, read, ls[i].text)
+	}
+	b, ok := block(read, ls, i, j)
+	switch {
+	case !ok:
+		return fmt.Errorf("Decode(%q) read %+v from %q, which the reader does not read", data, p, read)
+	case !same(p, b):
+		return fmt.Errorf("Decode(%q) read %+v, want %+v", data, p, b)
+	}
+	return nil
+}
+
+// checkEncoding checks that p encodes in lines of 64 characters, the last
+// of them shorter or as long, to text that decodes to p and nothing
+// more, and that encodes the same again.
+func checkEncoding(p *pem.Block) error {
+	enc := pem.EncodeToMemory(p)
+	if enc == nil {
+		return fmt.Errorf("EncodeToMemory(%+v) failed", p)
+	}
+	ls := lines(string(enc))
+	body := ls[1 : len(ls)-1]
+	if len(p.Headers) > 0 {
+		body = body[len(p.Headers)+1:]
+	}
+	for k, l := range body {
+		if len(l.text) > 64 || k < len(body)-1 && len(l.text) != 64 {
+			return fmt.Errorf("%+v encodes as %q, which has a body line of %d characters", p, enc, len(l.text))
+		}
+	}
+	back, rest := pem.Decode(enc)
+	if back == nil || !same(back, p) || len(rest) != 0 {
+		return fmt.Errorf("%+v encodes as %q, which decodes as %+v and %q", p, enc, back, rest)
+	}
+	if again := pem.EncodeToMemory(back); !bytes.Equal(again, enc) {
+		return fmt.Errorf("%+v encodes as %q, which decodes to a block that encodes as %q", p, enc, again)
+	}
+	return nil
+}
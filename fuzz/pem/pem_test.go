@@ -0,0 +1,30 @@
+package pem
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/pemsrc"
+)
+
+func FuzzPEM(f *testing.F) {
+	for _, src := range gen.Sample("pem/blocks", ".pem", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckPEM(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzBase64(f *testing.F) {
+	for _, src := range gen.Sample("pem/base64", ".b64", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckBase64(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
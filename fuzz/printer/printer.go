@@ -0,0 +1,307 @@
+// Package printer is a round-trip fuzz target for go/printer: printing a
+// parsed file must give a file that parses to the same syntax tree, up to
+// positions, with the same comments in the same order. Comment placement
+// is where the printer goes wrong most often, so Check also scatters
+// comments between the tokens of the input before printing it.
+package printer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/doc/comment"
+	"go/parser"
+	"go/printer"
+	"go/scanner"
+	"go/token"
+	"math/rand/v2"
+	"reflect"
+	"strings"
+
+	"github.com/geeknik/fuzzing/fuzz/doc"
+)
+
+// Configs are the printer configurations Check prints with: gofmt's and
+// the raw one.
+var Configs = []printer.Config{
+	{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8},
+	{Mode: printer.RawFormat, Tabwidth: 8},
+}
+
+// Check scatters comments through src, chosen by seed, and checks the
+// round trip of the result under each of Configs. Sources that do not
+// parse, before or after the comments go in, are ignored.
+func Check(src []byte, seed uint64) error {
+	src = Scatter(src, seed)
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	for _, cfg := range Configs {
+		if err := RoundTrip(fset, f, cfg); err != nil {
+			return fmt.Errorf("mode %v: %v\n--- input\n%s", cfg.Mode, err, src)
+		}
+	}
+	return nil
+}
+
+// RoundTrip prints f with cfg, parses the output and compares the two
+// trees.
+func RoundTrip(fset *token.FileSet, f *ast.File, cfg printer.Config) error {
+	var buf bytes.Buffer
+	if err := cfg.Fprint(&buf, fset, f); err != nil {
+		return fmt.Errorf("printing failed: %v", err)
+	}
+	out := buf.Bytes()
+	g, err := parser.ParseFile(token.NewFileSet(), "printed.go", out, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return fmt.Errorf("printed file does not parse: %v\n--- printed\n%s", err, out)
+	}
+	if err := Equal(reflect.ValueOf(f), reflect.ValueOf(g), "File"); err != nil {
+		return fmt.Errorf("printed file parses differently: %v\n--- printed\n%s", err, out)
+	}
+	if a, b := comments(f), comments(g); !reflect.DeepEqual(a, b) {
+		return fmt.Errorf("comments changed:\n\t%q\nbecame\n\t%q\n--- printed\n%s", a, b, out)
+	}
+	return nil
+}
+
+// comments returns the words of the comments of f, in order, normalized
+// so that the printer's own rewriting does not count as a change: build
+// constraints are left out, since the printer writes a //go:build line
+// for "// +build" lines and rewrites them to match it; groups of //-style
+// comments and multi-line /*-style ones are reformatted as doc comments,
+// since the printer does that to those it takes for doc comments; and
+// only words are compared, since it reindents /*-style comments and may
+// move a comment across a bracket, joining it to another group.
+func comments(f *ast.File) []string {
+	var out []string
+	for _, g := range f.Comments {
+		var list []string
+		for _, c := range g.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				list = append(list, c.Text)
+			}
+		}
+		if len(list) > 0 {
+			out = append(out, strings.Fields(docFormat(list))...)
+		}
+	}
+	return out
+}
+
+// docFormat returns the text of a comment group as go/printer formats a
+// doc comment, without the comment markers and with directives last.
+func docFormat(list []string) string {
+	var text string
+	var directives []string
+	switch {
+	case len(list) == 1 && strings.HasPrefix(list[0], "/*"):
+		text = strings.TrimSuffix(list[0][2:], "*/")
+		if !strings.Contains(text, "\n") || allStars(text) {
+			return unmark(list)
+		}
+	case strings.HasPrefix(list[0], "//"):
+		for _, c := range list {
+			after, ok := strings.CutPrefix(c, "//")
+			if !ok {
+				return unmark(list)
+			}
+			if isDirective(after) {
+				directives = append(directives, after)
+				continue
+			}
+			text += strings.TrimPrefix(after, " ") + "\n"
+		}
+	default:
+		return unmark(list)
+	}
+	// Both sides are compared where reformatting settles; see
+	// doc.Settle.
+	return doc.Settle(new(comment.Parser), new(comment.Printer), text) + strings.Join(directives, "\n")
+}
+
+// unmark returns the text of comments without their markers.
+func unmark(list []string) string {
+	var b strings.Builder
+	for _, c := range list {
+		if t, ok := strings.CutPrefix(c, "//"); ok {
+			b.WriteString(t)
+		} else {
+			b.WriteString(strings.TrimSuffix(c[2:], "*/"))
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// isDirective reports whether c, without its //, is a directive, as
+// go/printer decides it.
+func isDirective(c string) bool {
+	if strings.HasPrefix(c, "line ") || strings.HasPrefix(c, "extern ") || strings.HasPrefix(c, "export ") {
+		return true
+	}
+	colon := strings.Index(c, ":")
+	if colon <= 0 || colon+1 >= len(c) {
+		return false
+	}
+	for i := 0; i <= colon+1; i++ {
+		if b := c[i]; i != colon && !('a' <= b && b <= 'z' || '0' <= b && b <= '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// allStars reports whether text is the inside of an old-style /* */
+// comment with a star at the start of each line.
+func allStars(text string) bool {
+	for _, l := range strings.Split(text, "\n")[1:] {
+		if l = strings.TrimLeft(l, " \t"); l != "" && l[0] != '*' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	posType     = reflect.TypeFor[token.Pos]()
+	commentType = reflect.TypeFor[*ast.CommentGroup]()
+	objectType  = reflect.TypeFor[*ast.Object]()
+	scopeType   = reflect.TypeFor[*ast.Scope]()
+
+	fieldListType = reflect.TypeFor[*ast.FieldList]()
+)
+
+// noResults returns a nil field list for a result list of no fields.
+func noResults(v reflect.Value) reflect.Value {
+	if l := v.Interface().(*ast.FieldList); l != nil && l.NumFields() == 0 {
+		return reflect.Zero(fieldListType)
+	}
+	return v
+}
+
+// Equal compares two syntax trees. Positions are only compared for
+// whether they are valid, since that is what says whether an optional
+// token such as a bracket is there, and parenthesized expressions are
+// compared as the expressions inside. Comments are compared apart from
+// the tree, and objects, scopes and the file's GoVersion not at all.
+func Equal(a, b reflect.Value, path string) error {
+	if a.Type() != b.Type() {
+		return fmt.Errorf("%s: %v became %v", path, a.Type(), b.Type())
+	}
+	switch a.Type() {
+	case posType:
+		if va, vb := a.Interface().(token.Pos).IsValid(), b.Interface().(token.Pos).IsValid(); va != vb {
+			return fmt.Errorf("%s: position valid %v became %v", path, va, vb)
+		}
+		return nil
+	case commentType, objectType, scopeType:
+		return nil
+	case fieldListType:
+		if strings.HasSuffix(path, ".Results") {
+			// The printer leaves out an empty result list, and the
+			// parentheses around a single unnamed result.
+			a, b = noResults(a), noResults(b)
+			if !a.IsNil() && !b.IsNil() {
+				return Equal(a.Elem().FieldByName("List"), b.Elem().FieldByName("List"), path+".List")
+			}
+		}
+	}
+	switch a.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				return fmt.Errorf("%s: nil %v became %v", path, a.IsNil(), b.IsNil())
+			}
+			return nil
+		}
+		if x, ok := a.Interface().(ast.Expr); ok && a.Kind() == reflect.Interface {
+			// The printer drops parentheses it does not need, as
+			// around the condition of an if. Those it needs and
+			// drops change the rest of the tree.
+			y := b.Interface().(ast.Expr)
+			a, b = reflect.ValueOf(ast.Unparen(x)), reflect.ValueOf(ast.Unparen(y))
+			return Equal(a.Elem(), b.Elem(), path)
+		}
+		return Equal(a.Elem(), b.Elem(), path)
+	case reflect.Slice:
+		if a.Len() != b.Len() {
+			return fmt.Errorf("%s: %d elements became %d", path, a.Len(), b.Len())
+		}
+		for i := range a.Len() {
+			if err := Equal(a.Index(i), b.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Struct:
+		for i := range a.NumField() {
+			name := a.Type().Field(i).Name
+			if name == "Comments" || name == "GoVersion" {
+				// GoVersion comes from the //go:build line, which the
+				// printer may write.
+				continue
+			}
+			if err := Equal(a.Field(i), b.Field(i), path+"."+name); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if a.Interface() != b.Interface() {
+		return fmt.Errorf("%s: %v became %v", path, a.Interface(), b.Interface())
+	}
+	return nil
+}
+
+// Scatter puts comments before some of the tokens of src: a /*-style
+// comment before any token, and a //-style one before a token that
+// already starts a line, so that the tokens the parser sees stay the
+// same. seed chooses the places.
+func Scatter(src []byte, seed uint64) []byte {
+	if seed == 0 {
+		return src
+	}
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, 0)
+	var out []byte
+	last, n := 0, 0
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.SEMICOLON && lit == "\n" {
+			continue
+		}
+		off := file.Offset(pos)
+		if off < last {
+			// Scanning went wrong; leave the rest as it is.
+			break
+		}
+		if r.IntN(8) == 0 {
+			out = append(out, src[last:off]...)
+			last = off
+			n++
+			if r.IntN(2) == 0 && startsLine(src, off) {
+				out = fmt.Appendf(out, "// c%d\n", n)
+			} else {
+				out = fmt.Appendf(out, " /* c%d */ ", n)
+			}
+		}
+		if tok == token.EOF {
+			break
+		}
+	}
+	return append(out, src[last:]...)
+}
+
+// startsLine reports whether only spaces and tabs come before off on its
+// line.
+func startsLine(src []byte, off int) bool {
+	i := bytes.LastIndexByte(src[:off], '\n')
+	return len(bytes.Trim(src[i+1:off], " \t\r")) == 0
+}
@@ -0,0 +1,20 @@
+package printer
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzPrint(f *testing.F) {
+	for i, src := range gen.Sample("go/*", ".go", 2) {
+		f.Add(src, uint64(0))
+		f.Add(src, uint64(i+1))
+	}
+	f.Fuzz(func(t *testing.T, src []byte, seed uint64) {
+		if err := Check(src, seed); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package protobuf
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// retypedKey reports whether a message of type md in b has, in a map
+// entry anywhere inside it, a key of the wrong wire type after one of the
+// right type, which Unmarshal panics on.
+func retypedKey(b []byte, md protoreflect.MessageDescriptor) bool {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return false
+		}
+		v := b[:m]
+		b = b[m:]
+		fd := md.Fields().ByNumber(num)
+		if fd == nil {
+			continue
+		}
+		switch {
+		case fd.IsMap() && typ == protowire.BytesType:
+			v, _ = protowire.ConsumeBytes(v)
+			if retypedEntryKey(v, fd) {
+				return true
+			}
+		case fd.Kind() == protoreflect.MessageKind && typ == protowire.BytesType:
+			v, _ = protowire.ConsumeBytes(v)
+			if retypedKey(v, fd.Message()) {
+				return true
+			}
+		case fd.Kind() == protoreflect.GroupKind && typ == protowire.StartGroupType:
+			v, _ = protowire.ConsumeGroup(num, v)
+			if retypedKey(v, fd.Message()) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// retypedEntryKey is retypedKey for an entry of map field fd in b.
+func retypedEntryKey(b []byte, fd protoreflect.FieldDescriptor) bool {
+	keyType := wireType(fd.MapKey().Kind())
+	haveKey := false
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		m := protowire.ConsumeFieldValue(num, typ, b)
+		if m < 0 {
+			return false
+		}
+		v := b[:m]
+		b = b[m:]
+		switch num {
+		case 1:
+			if typ == keyType {
+				haveKey = true
+			} else if haveKey {
+				return true
+			}
+		case 2:
+			if val := fd.MapValue(); val.Kind() == protoreflect.MessageKind && typ == protowire.BytesType {
+				v, _ = protowire.ConsumeBytes(v)
+				if retypedKey(v, val.Message()) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// wireType returns the wire type of a key of kind k.
+func wireType(k protoreflect.Kind) protowire.Type {
+	switch k {
+	case protoreflect.Fixed32Kind, protoreflect.Sfixed32Kind:
+		return protowire.Fixed32Type
+	case protoreflect.Fixed64Kind, protoreflect.Sfixed64Kind:
+		return protowire.Fixed64Type
+	case protoreflect.StringKind:
+		return protowire.BytesType
+	}
+	return protowire.VarintType
+}
@@ -0,0 +1,151 @@
+// Package protobuf is a fuzz target for google.golang.org/protobuf.
+// CheckUnmarshal builds the first message type of a FileDescriptorSet
+// with protodesc and unmarshals a message of it in the wire format into
+// a dynamicpb message. A message that unmarshals must be fields protowire
+// reads to its end; unmarshal strictly exactly when it has its required
+// fields; marshal, in as many bytes as Size gives, to one that unmarshals
+// to an equal message and marshals to the same bytes; and, without its
+// unknown fields, come back equal from JSON and from text.
+package protobuf
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Timeout bounds checking one message.
+var Timeout = 10 * time.Second
+
+// CheckUnmarshal checks the message in data, of the first message type
+// of the first file of the FileDescriptorSet in schema. A schema that
+// does not build, and data that does not unmarshal, are ignored.
+func CheckUnmarshal(schema, data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkUnmarshal(schema, data)
+	})
+}
+
+func checkUnmarshal(schema, data []byte) error {
+	md := firstMessage(schema)
+	if md == nil {
+		return nil
+	}
+	// Known: Unmarshal keeps the key it last read in a map entry even when
+	// reading it failed for the wrong wire type, so that after a key of the
+	// right type one of the wrong type leaves it invalid, and it panics.
+	if retypedKey(data, md) {
+		return nil
+	}
+	partial := proto.UnmarshalOptions{AllowPartial: true}
+	m := dynamicpb.NewMessage(md)
+	if err := partial.Unmarshal(data, m); err != nil {
+		if proto.Unmarshal(data, dynamicpb.NewMessage(md)) == nil {
+			return fmt.Errorf("a message unmarshals strictly but not allowing it to be partial: %v", err)
+		}
+		return nil
+	}
+	if err := fields(data); err != nil {
+		return fmt.Errorf("a message unmarshals, but protowire does not read its fields: %v", err)
+	}
+	strict := proto.Unmarshal(data, dynamicpb.NewMessage(md))
+	if init := proto.CheckInitialized(m); (strict == nil) != (init == nil) {
+		return fmt.Errorf("a message unmarshals strictly with error %v, but CheckInitialized gives %v", strict, init)
+	}
+
+	marshal := proto.MarshalOptions{AllowPartial: true, Deterministic: true}
+	b, err := marshal.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("a message that unmarshals does not marshal: %v", err)
+	}
+	if n := proto.Size(m); n != len(b) {
+		return fmt.Errorf("Size gives %d for a message that marshals in %d bytes", n, len(b))
+	}
+	again := dynamicpb.NewMessage(md)
+	if err := partial.Unmarshal(b, again); err != nil {
+		return fmt.Errorf("a message marshaled does not unmarshal: %v\n%x", err, b)
+	}
+	if !proto.Equal(m, again) {
+		return fmt.Errorf("a message marshaled unmarshals to\n%v\nnot\n%v", again, m)
+	}
+	if b2, err := marshal.Marshal(again); err != nil || !bytes.Equal(b, b2) {
+		return fmt.Errorf("a message marshaled as\n%x\nunmarshals to one that marshals as\n%x (%v)", b, b2, err)
+	}
+
+	// JSON and text leave unknown fields out.
+	known := dynamicpb.NewMessage(md)
+	if err := (proto.UnmarshalOptions{AllowPartial: true, DiscardUnknown: true}).Unmarshal(data, known); err != nil {
+		return fmt.Errorf("a message unmarshals, but not discarding unknown fields: %v", err)
+	}
+	for _, f := range formats {
+		// Marshaling fails for what the format cannot hold, such as a
+		// proto2 string that is not UTF-8.
+		out, err := f.marshal(known)
+		if err != nil {
+			continue
+		}
+		back := dynamicpb.NewMessage(md)
+		if err := f.unmarshal(out, back); err != nil {
+			return fmt.Errorf("a message marshaled as %s does not unmarshal: %v\n%s", f.name, err, out)
+		}
+		if !proto.Equal(known, back) {
+			return fmt.Errorf("a message marshaled as %s unmarshals to\n%v\nnot\n%v\n%s", f.name, back, known, out)
+		}
+	}
+	return nil
+}
+
+// formats are the encodings besides the wire format a message must come
+// back from the same.
+var formats = []struct {
+	name      string
+	marshal   func(proto.Message) ([]byte, error)
+	unmarshal func([]byte, proto.Message) error
+}{
+	{"JSON", protojson.MarshalOptions{AllowPartial: true}.Marshal, protojson.UnmarshalOptions{AllowPartial: true}.Unmarshal},
+	{"text", prototext.MarshalOptions{AllowPartial: true}.Marshal, prototext.UnmarshalOptions{AllowPartial: true}.Unmarshal},
+}
+
+// firstMessage returns the first message type of the first file of the
+// FileDescriptorSet in schema, or nil if it does not build or has none.
+func firstMessage(schema []byte) protoreflect.MessageDescriptor {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(schema, &set); err != nil || len(set.File) == 0 {
+		return nil
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil
+	}
+	fd, err := files.FindFileByPath(set.File[0].GetName())
+	if err != nil || fd.Messages().Len() == 0 {
+		return nil
+	}
+	return fd.Messages().Get(0)
+}
+
+// fields reads the fields of a message in b to its end with protowire.
+func fields(b []byte) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return fmt.Errorf("field %d: %v", num, protowire.ParseError(m))
+		}
+		b = b[n+m:]
+	}
+	return nil
+}
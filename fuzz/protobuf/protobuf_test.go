@@ -0,0 +1,29 @@
+package protobuf
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/protosrc"
+)
+
+func FuzzUnmarshal(f *testing.F) {
+	g := gen.Lookup("protobuf/message")
+	for i := range 64 {
+		var schema, data []byte
+		for _, file := range g.Generate(i) {
+			switch file.Name {
+			case "schema.desc":
+				schema = file.Data
+			case "input.pb":
+				data = file.Data
+			}
+		}
+		f.Add(schema, data)
+	}
+	f.Fuzz(func(t *testing.T, schema, data []byte) {
+		if err := CheckUnmarshal(schema, data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+// Package python is a fuzz target for the Python parsers written in Go:
+// github.com/smacker/go-tree-sitter with its Python grammar, which
+// editors and linters use, and github.com/go-python/gpython, whose
+// grammar is that of Python 3.4. CheckParse compiles a file with
+// gpython, which may reject it but not panic, and parses it with
+// tree-sitter, which recovers from errors into a tree whose nodes must
+// lie inside their parents, in order. A file it parses without error
+// must be covered by its tokens, but for what tree-sitter skips between
+// them; and edited a byte at a time, it must parse incrementally to the
+// tree it parses to from scratch, and back to the tree it was.
+package python
+
+import (
+	"time"
+	"unicode"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/geeknik/fuzzing/internal/treesitter"
+	"github.com/go-python/gpython/compile"
+	"github.com/go-python/gpython/py"
+	tspython "github.com/smacker/go-tree-sitter/python"
+)
+
+// Timeout bounds checking one file.
+var Timeout = 10 * time.Second
+
+// CheckParse checks the Python file in data.
+func CheckParse(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		compile.Compile(string(data), "input.py", py.ExecMode, 0, true) // errors are ordinary
+		return treesitter.Check(grammar, data)
+	})
+}
+
+// grammar is tree-sitter's Python grammar. Strings are tokens of their
+// own, as the text between the fields of an f-string is in no token.
+var grammar = &treesitter.Grammar{
+	Language: tspython.GetLanguage(),
+	Leaves:   []string{"string"},
+	Skipped:  skipped,
+}
+
+// skipped reports whether tree-sitter skips r between tokens. It takes
+// any Unicode space, and a few invisible characters, where Python takes
+// only spaces, tabs and form feeds.
+func skipped(r rune) bool {
+	return unicode.IsSpace(r) || r == '\\' || r == '\uFEFF' || r == '\u2060' || r == '\u200B'
+}
@@ -0,0 +1,19 @@
+package python
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/pysrc"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, src := range gen.Sample("py/*", ".py", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckParse(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,626 @@
+// Package quic is a fuzz target for crypto/tls's QUIC hooks and for
+// github.com/quic-go/quic-go/quicvarint. CheckInitial reads a client's
+// long header packets as a server reads them once it has removed their
+// protection, puts the data of their CRYPTO frames back in order at each
+// encryption level and hands it to a tls.QUICConn server as a QUIC stack
+// does. The server must keep to the protocol: the transport parameters
+// it reports must be those of a ClientHello the client sent, its secrets
+// as long as its suite's hash, and what it writes whole handshake
+// messages of the level it writes them at, carrying the parameters it
+// was given; and it must neither finish the handshake nor take keys to
+// read with beyond the Handshake level's, which a client that never saw
+// its flight cannot have. CheckParameters reads a transport parameter
+// blob, whose integers quicvarint must read the same from a slice and
+// from a reader and write back in the bytes they were read from, and
+// sends it in the ClientHello of an Initial packet.
+package quic
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/quic-go/quic-go/quicvarint"
+)
+
+// Timeout bounds one handshake.
+var Timeout = 10 * time.Second
+
+const (
+	// maxPackets bounds the packets read from one input.
+	maxPackets = 256
+	// maxOffset bounds the CRYPTO data of a level, as quic-go's does.
+	maxOffset = 16 << 10
+)
+
+// Long header packet types.
+const (
+	typeInitial   = 0
+	typeZeroRTT   = 1
+	typeHandshake = 2
+	typeRetry     = 3
+)
+
+// Handshake message types.
+const (
+	typeClientHello         = 1
+	typeServerHello         = 2
+	typeEncryptedExtensions = 8
+	typeCertificate         = 11
+	typeCertificateVerify   = 15
+	typeFinished            = 20
+)
+
+// extQUICParams is the quic_transport_parameters extension.
+const extQUICParams = 0x39
+
+// now is the time the server sees, inside the certificate's validity.
+var now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// certificate is the server's: a P-256 key in a certificate it signs
+// itself, made afresh in each process, which changes only the
+// signatures the server sends.
+var certificate = func() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}()
+
+// serverParams are the transport parameters the server sends:
+// initial_max_data, initial_max_streams_bidi and
+// original_destination_connection_id.
+var serverParams = slices.Concat(
+	[]byte{0x04, 0x04}, quicvarint.Append(nil, 1<<20),
+	[]byte{0x08, 0x02}, quicvarint.Append(nil, 100),
+	[]byte{0x00, 0x08}, make([]byte, 8),
+)
+
+// secretLen is the length of a secret of each TLS 1.3 suite QUIC may
+// use, that of its hash.
+var secretLen = map[uint16]int{
+	tls.TLS_AES_128_GCM_SHA256:       32,
+	tls.TLS_AES_256_GCM_SHA384:       48,
+	tls.TLS_CHACHA20_POLY1305_SHA256: 32,
+}
+
+// zeros is a source of randomness that is all zeros, so that what the
+// server sends depends only on what it reads.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// CheckInitial checks a server's handshake reading the packets in data
+// from a client.
+func CheckInitial(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkInitial(data)
+	})
+}
+
+func checkInitial(data []byte) error {
+	s := &server{
+		q: tls.QUICServer(&tls.QUICConfig{TLSConfig: &tls.Config{
+			Certificates: []tls.Certificate{certificate},
+			Rand:         zeros{},
+			Time:         func() time.Time { return now },
+			MinVersion:   tls.VersionTLS13,
+			NextProtos:   []string{"h3"},
+		}}),
+		out:  map[tls.QUICEncryptionLevel][]byte{},
+		keys: map[tls.QUICEncryptionLevel]bool{},
+	}
+	defer s.q.Close()
+	if err := s.q.Start(context.Background()); err != nil {
+		return fmt.Errorf("Start: %v", err)
+	}
+	if err := s.events(); err != nil {
+		return err
+	}
+
+	var dcid, scid []byte
+	for range maxPackets {
+		p, rest, ok := readPacket(data)
+		if !ok || s.failed {
+			break
+		}
+		data = rest
+		if dcid == nil {
+			// The first Initial opens the connection, if its destination
+			// connection ID is as long as a client's first must be.
+			if p.typ != typeInitial || len(p.dcid) < 8 {
+				continue
+			}
+			dcid, scid = p.dcid, p.scid
+		}
+		if !bytes.Equal(p.dcid, dcid) || !bytes.Equal(p.scid, scid) {
+			// Another connection's.
+			continue
+		}
+		var level tls.QUICEncryptionLevel
+		switch p.typ {
+		case typeInitial:
+			level = tls.QUICEncryptionLevelInitial
+		case typeHandshake:
+			level = tls.QUICEncryptionLevelHandshake
+		default:
+			// 0-RTT packets, whose keys a server that has issued no
+			// tickets cannot have.
+			continue
+		}
+		if !s.frames(level, p.payload) {
+			break
+		}
+		if err := s.deliver(); err != nil {
+			return err
+		}
+	}
+	return s.flights()
+}
+
+// A packet is a long header packet with its protection removed.
+type packet struct {
+	typ        byte
+	dcid, scid []byte
+	payload    []byte
+}
+
+// readPacket reads a version 1 long header packet from the start of b
+// and returns the rest. It reports false for a packet a server would
+// close the connection over, or after which it could not find the next.
+func readPacket(b []byte) (p packet, rest []byte, ok bool) {
+	if len(b) < 5 || b[0]&0xc0 != 0xc0 || binary.BigEndian.Uint32(b[1:]) != 1 {
+		return p, nil, false
+	}
+	p.typ = b[0] >> 4 & 3
+	if p.typ == typeRetry || b[0]&0x0c != 0 {
+		// A client sends no Retry, and the reserved bits must be zeros.
+		return p, nil, false
+	}
+	pnLen := int(b[0]&3) + 1
+	rest = b[5:]
+	if p.dcid, rest, ok = connID(rest); !ok {
+		return p, nil, false
+	}
+	if p.scid, rest, ok = connID(rest); !ok {
+		return p, nil, false
+	}
+	if p.typ == typeInitial {
+		var n uint64
+		if n, rest, ok = varint(rest); !ok || n > uint64(len(rest)) {
+			return p, nil, false
+		}
+		rest = rest[n:] // the token, which the server did not issue
+	}
+	n, rest, ok := varint(rest)
+	if !ok || n > uint64(len(rest)) || n <= uint64(pnLen) {
+		return p, nil, false
+	}
+	p.payload = rest[pnLen:n]
+	return p, rest[n:], true
+}
+
+// connID reads a connection ID of version 1, at most 20 bytes long.
+func connID(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 1 || b[0] > 20 || int(b[0]) >= len(b) {
+		return nil, nil, false
+	}
+	n := int(b[0]) + 1
+	return b[1:n], b[n:], true
+}
+
+// varint reads a variable-length integer.
+func varint(b []byte) (uint64, []byte, bool) {
+	v, n, err := quicvarint.Parse(b)
+	if err != nil {
+		return 0, nil, false
+	}
+	return v, b[n:], true
+}
+
+// A server is a tls.QUICConn server and what the harness keeps of what
+// it was sent and what it did.
+type server struct {
+	q *tls.QUICConn
+	// level is the level the server reads at.
+	level tls.QUICEncryptionLevel
+	// streams holds the CRYPTO data of each level not yet handed on.
+	streams [4]stream
+	// initial is the Initial data handed on, and hellos the transport
+	// parameters of the ClientHellos in it that have them.
+	initial []byte
+	hellos  [][]byte
+	// out is what the server wrote at each level, and keys the levels it
+	// has keys to write at.
+	out  map[tls.QUICEncryptionLevel][]byte
+	keys map[tls.QUICEncryptionLevel]bool
+	// failed is whether the handshake failed.
+	failed bool
+}
+
+// frames reads the frames of a packet of level, keeping the data of its
+// CRYPTO frames. It reports false for a frame that closes the
+// connection, as a frame type not in the fewest bytes may.
+func (s *server) frames(level tls.QUICEncryptionLevel, b []byte) bool {
+	for len(b) > 0 {
+		typ, n, err := quicvarint.Parse(b)
+		if err != nil || n != quicvarint.Len(typ) {
+			return false
+		}
+		b = b[n:]
+		switch typ {
+		case 0x00, 0x01: // PADDING and PING
+		case 0x06: // CRYPTO
+			off, rest, ok := varint(b)
+			if !ok {
+				return false
+			}
+			n, rest, ok := varint(rest)
+			if !ok || n > uint64(len(rest)) || off+n > maxOffset {
+				return false
+			}
+			s.streams[level].add(off, rest[:n])
+			b = rest[n:]
+		default:
+			// CONNECTION_CLOSE; an ACK, of packets the server never sent;
+			// and frames an Initial or Handshake packet may not carry.
+			return false
+		}
+	}
+	return true
+}
+
+// deliver hands the server the data of each level now in order, that
+// of a level past the one it reads at waiting for it to get there.
+func (s *server) deliver() error {
+	for more := true; more && !s.failed; {
+		more = false
+		for level := range s.streams {
+			l := tls.QUICEncryptionLevel(level)
+			st := &s.streams[level]
+			if st.ready() == 0 || l > s.level {
+				continue
+			}
+			data := st.take()
+			if l == tls.QUICEncryptionLevelInitial {
+				s.hello(data)
+			}
+			if err := s.q.HandleData(l, data); err != nil {
+				// The events before a failure are dropped with it.
+				s.failed = true
+				return nil
+			}
+			if err := s.events(); err != nil {
+				return err
+			}
+			more = true
+		}
+	}
+	return nil
+}
+
+// hello adds data to the Initial data handed on and keeps the transport
+// parameters of the ClientHellos in it.
+func (s *server) hello(data []byte) {
+	s.initial = append(s.initial, data...)
+	s.hellos = s.hellos[:0]
+	msgs, _ := messages(s.initial)
+	for _, m := range msgs {
+		if m[0] != typeClientHello {
+			continue
+		}
+		if p, ok := helloParams(m[4:]); ok {
+			s.hellos = append(s.hellos, p)
+		}
+	}
+}
+
+// events handles the server's events.
+func (s *server) events() error {
+	for {
+		e := s.q.NextEvent()
+		switch e.Kind {
+		case tls.QUICNoEvent:
+			return nil
+		case tls.QUICTransportParametersRequired:
+			s.q.SetTransportParameters(serverParams)
+		case tls.QUICTransportParameters:
+			if !slices.ContainsFunc(s.hellos, func(p []byte) bool { return bytes.Equal(p, e.Data) }) {
+				return fmt.Errorf("the server reports transport parameters %x, which no ClientHello sent", e.Data)
+			}
+		case tls.QUICSetReadSecret, tls.QUICSetWriteSecret:
+			if n, ok := secretLen[e.Suite]; !ok || len(e.Data) != n {
+				return fmt.Errorf("the server has a secret of %d bytes for %v with suite %#04x", len(e.Data), e.Level, e.Suite)
+			}
+			if e.Kind == tls.QUICSetWriteSecret {
+				s.keys[e.Level] = true
+				break
+			}
+			if e.Level != tls.QUICEncryptionLevelHandshake {
+				return fmt.Errorf("the server reads at %v, whose keys a client that never saw its flight cannot have", e.Level)
+			}
+			s.level = e.Level
+		case tls.QUICWriteData:
+			if e.Level != tls.QUICEncryptionLevelInitial && !s.keys[e.Level] {
+				return fmt.Errorf("the server wrote at %v before it had keys for it", e.Level)
+			}
+			s.out[e.Level] = append(s.out[e.Level], e.Data...)
+		case tls.QUICHandshakeDone:
+			return errors.New("the server finished the handshake with a client that never saw its flight")
+		default:
+			return fmt.Errorf("the server reports an event of kind %d, which a server without tickets does not", e.Kind)
+		}
+	}
+}
+
+// flights checks that what the server wrote at each level is whole
+// handshake messages of that level, and that its EncryptedExtensions
+// carry the transport parameters it was given.
+func (s *server) flights() error {
+	allowed := map[tls.QUICEncryptionLevel][]byte{
+		tls.QUICEncryptionLevelInitial:   {typeServerHello},
+		tls.QUICEncryptionLevelHandshake: {typeEncryptedExtensions, typeCertificate, typeCertificateVerify, typeFinished},
+	}
+	for level, out := range s.out {
+		msgs, ok := messages(out)
+		if !ok {
+			return fmt.Errorf("the server wrote at %v what are not whole handshake messages:\n%x", level, out)
+		}
+		for _, m := range msgs {
+			if !slices.Contains(allowed[level], m[0]) {
+				return fmt.Errorf("the server wrote a handshake message of type %d at %v", m[0], level)
+			}
+			if m[0] != typeEncryptedExtensions {
+				continue
+			}
+			p, ok := extension(m[4:], extQUICParams)
+			if !ok || !bytes.Equal(p, serverParams) {
+				return fmt.Errorf("the server sent EncryptedExtensions without its transport parameters:\n%x", m)
+			}
+		}
+	}
+	return nil
+}
+
+// messages cuts b into handshake messages, reporting false if it does
+// not end where one does.
+func messages(b []byte) ([][]byte, bool) {
+	var msgs [][]byte
+	for len(b) >= 4 {
+		n := 4 + (int(b[1])<<16 | int(b[2])<<8 | int(b[3]))
+		if n > len(b) {
+			break
+		}
+		msgs = append(msgs, b[:n])
+		b = b[n:]
+	}
+	return msgs, len(b) == 0
+}
+
+// helloParams returns the transport parameters of a ClientHello's body,
+// reporting false if it has none or does not parse.
+func helloParams(b []byte) ([]byte, bool) {
+	// The legacy version and the random.
+	if len(b) < 34 {
+		return nil, false
+	}
+	b = b[34:]
+	// The legacy session ID, cipher suites and compression methods.
+	for _, size := range []int{1, 2, 1} {
+		var ok bool
+		if _, b, ok = cut(b, size); !ok {
+			return nil, false
+		}
+	}
+	return extension(b, extQUICParams)
+}
+
+// extension returns the body of the extension of type typ in b, which
+// holds an extension block, reporting false if there is none or the
+// block does not parse.
+func extension(b []byte, typ int) ([]byte, bool) {
+	exts, rest, ok := cut(b, 2)
+	if !ok || len(rest) != 0 {
+		return nil, false
+	}
+	var found []byte
+	for len(exts) > 0 {
+		if len(exts) < 2 {
+			return nil, false
+		}
+		t := int(exts[0])<<8 | int(exts[1])
+		var body []byte
+		if body, exts, ok = cut(exts[2:], 2); !ok {
+			return nil, false
+		}
+		if t == typ {
+			found = body
+		}
+	}
+	return found, found != nil
+}
+
+// cut cuts a vector whose length takes size bytes from the start of b.
+func cut(b []byte, size int) (vec, rest []byte, ok bool) {
+	if len(b) < size {
+		return nil, nil, false
+	}
+	n := 0
+	for _, c := range b[:size] {
+		n = n<<8 | int(c)
+	}
+	if len(b) < size+n {
+		return nil, nil, false
+	}
+	return b[size : size+n], b[size+n:], true
+}
+
+// A stream puts the CRYPTO data of one level back in order.
+type stream struct {
+	// off is the offset of buf[0], the first byte not yet handed on, and
+	// have marks the bytes of buf that have arrived.
+	off  uint64
+	buf  []byte
+	have []bool
+}
+
+// add adds data at off, keeping the bytes that arrived first where
+// frames overlap.
+func (st *stream) add(off uint64, data []byte) {
+	if end := off + uint64(len(data)); end <= st.off {
+		return
+	}
+	if off < st.off {
+		data = data[st.off-off:]
+		off = st.off
+	}
+	i := int(off - st.off)
+	if n := i + len(data); n > len(st.buf) {
+		st.buf = append(st.buf, make([]byte, n-len(st.buf))...)
+		st.have = append(st.have, make([]bool, n-len(st.have))...)
+	}
+	for j, c := range data {
+		if !st.have[i+j] {
+			st.buf[i+j], st.have[i+j] = c, true
+		}
+	}
+}
+
+// ready returns how many bytes are in order and not yet handed on.
+func (st *stream) ready() int {
+	n := 0
+	for n < len(st.have) && st.have[n] {
+		n++
+	}
+	return n
+}
+
+// take returns the bytes ready to be handed on.
+func (st *stream) take() []byte {
+	n := st.ready()
+	data := bytes.Clone(st.buf[:n])
+	st.buf, st.have = st.buf[n:], st.have[n:]
+	st.off += uint64(n)
+	return data
+}
+
+// CheckParameters checks the transport parameters in data.
+func CheckParameters(data []byte) error {
+	if err := checkVarints(data); err != nil {
+		return err
+	}
+	hello := clientHello(data)
+	if len(hello) > maxOffset {
+		return nil
+	}
+	return CheckInitial(initialPacket(hello))
+}
+
+// checkVarints checks the IDs and lengths of the transport parameters
+// in b, and the integer each value starts with, as most are one.
+func checkVarints(b []byte) error {
+	for len(b) > 0 {
+		var v [2]uint64
+		for i := range v {
+			n, err := checkVarint(b)
+			if err != nil || n == 0 {
+				return err
+			}
+			v[i], _, _ = quicvarint.Parse(b)
+			b = b[n:]
+		}
+		if v[1] > uint64(len(b)) {
+			return nil
+		}
+		if _, err := checkVarint(b[:v[1]]); err != nil {
+			return err
+		}
+		b = b[v[1]:]
+	}
+	return nil
+}
+
+// checkVarint checks the variable-length integer at the start of b and
+// returns its length, or 0 if there is none.
+func checkVarint(b []byte) (int, error) {
+	v, n, err := quicvarint.Parse(b)
+	r := bytes.NewReader(b)
+	rv, rerr := quicvarint.Read(r)
+	if (err == nil) != (rerr == nil) {
+		return 0, fmt.Errorf("Parse and Read disagree on %x: %v and %v", b[:min(len(b), 8)], err, rerr)
+	}
+	if err != nil {
+		return 0, nil
+	}
+	if rv != v || len(b)-r.Len() != n {
+		return 0, fmt.Errorf("Parse reads %d in %d bytes from %x, and Read %d in %d", v, n, b[:n], rv, len(b)-r.Len())
+	}
+	if again := quicvarint.AppendWithLen(nil, v, n); !bytes.Equal(again, b[:n]) {
+		return 0, fmt.Errorf("%d, read from %x, is written in %d bytes as %x", v, b[:n], n, again)
+	}
+	short := quicvarint.Append(nil, v)
+	if sv, sn, err := quicvarint.Parse(short); err != nil || sv != v || sn != len(short) || sn != quicvarint.Len(v) {
+		return 0, fmt.Errorf("%d is written as %x, which reads as %d in %d bytes (%v), and Len is %d", v, short, sv, sn, err, quicvarint.Len(v))
+	}
+	return n, nil
+}
+
+// clientHello returns a ClientHello the server can answer, carrying
+// params: TLS 1.3 with AES-128-GCM, an X25519 share of the base point,
+// ECDSA with P-256 and ALPN "h3".
+func clientHello(params []byte) []byte {
+	ext := func(typ int, body []byte) []byte {
+		return slices.Concat([]byte{byte(typ >> 8), byte(typ)}, u16(len(body)), body)
+	}
+	share := make([]byte, 32)
+	share[0] = 9
+	exts := slices.Concat(
+		ext(43, []byte{2, 3, 4}),
+		ext(10, []byte{0, 2, 0, 0x1d}),
+		ext(51, slices.Concat([]byte{0, 36, 0, 0x1d, 0, 32}, share)),
+		ext(13, []byte{0, 2, 4, 3}),
+		ext(16, []byte{0, 3, 2, 'h', '3'}),
+		ext(extQUICParams, params),
+	)
+	body := slices.Concat([]byte{3, 3}, make([]byte, 32), []byte{0, 0, 2, 0x13, 0x01, 1, 0}, u16(len(exts)), exts)
+	return slices.Concat([]byte{typeClientHello, byte(len(body) >> 16)}, u16(len(body)), body)
+}
+
+// initialPacket returns an Initial packet carrying hello in a CRYPTO
+// frame, with its protection removed.
+func initialPacket(hello []byte) []byte {
+	payload := slices.Concat([]byte{0x06, 0x00}, quicvarint.Append(nil, uint64(len(hello))), hello)
+	b := slices.Concat([]byte{0xc0, 0, 0, 0, 1, 8}, make([]byte, 8), []byte{8}, make([]byte, 8), []byte{0})
+	b = quicvarint.Append(b, uint64(1+len(payload)))
+	return slices.Concat(b, []byte{0}, payload)
+}
+
+// u16 returns v big-endian in two bytes.
+func u16(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
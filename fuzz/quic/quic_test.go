@@ -0,0 +1,30 @@
+package quic
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/quicsrc"
+)
+
+func FuzzInitial(f *testing.F) {
+	for _, src := range gen.Sample("quic/initial", ".quic", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckInitial(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzParameters(f *testing.F) {
+	for _, src := range gen.Sample("quic/params", ".qtp", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckParameters(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,174 @@
+// Package regexp is a fuzz target for regexp and regexp/syntax.
+// CheckParse parses a pattern with Perl and POSIX flags and checks that
+// the parsed expression prints to one that parses back to one printing
+// the same. CheckMatch compiles a pattern both ways and matches it
+// against a subject: the ways of asking for the same match must agree,
+// and since the package guarantees time linear in the size of the
+// subject, matching gets a budget linear in the program size times the
+// subject length, and is reported as a blowup past it.
+package regexp
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Flags are the parser flags CheckParse parses with: those of Compile
+// and of CompilePOSIX.
+var Flags = []struct {
+	Name  string
+	Flags syntax.Flags
+}{
+	{"perl", syntax.Perl},
+	{"posix", syntax.POSIX},
+}
+
+// CheckParse parses pattern under each of Flags. A pattern that parses
+// must print to a pattern that parses, with Perl flags, to one that
+// prints the same, and its simplified form must compile; one that does
+// not parse with Perl flags must not compile with regexp.Compile either.
+func CheckParse(pattern string) error {
+	for _, f := range Flags {
+		re, err := syntax.Parse(pattern, f.Flags)
+		if err != nil {
+			if f.Flags == syntax.Perl {
+				if _, cerr := regexp.Compile(pattern); cerr == nil {
+					return fmt.Errorf("%s: syntax.Parse fails (%v) but regexp.Compile succeeds", f.Name, err)
+				}
+			}
+			continue
+		}
+		printed := re.String()
+		again, err := syntax.Parse(printed, syntax.Perl)
+		if err != nil {
+			return fmt.Errorf("%s: %#q prints as %#q, which does not parse: %v", f.Name, pattern, printed, err)
+		}
+		// The printed form says the same with different flags, which
+		// syntax.Regexp.Equal compares, so it is the printing that must
+		// agree.
+		if again.String() != printed {
+			return fmt.Errorf("%s: %#q prints as %#q, which prints as %#q", f.Name, pattern, printed, again)
+		}
+		if _, err := syntax.Compile(re.Simplify()); err != nil {
+			return fmt.Errorf("%s: %#q parses but does not compile: %v", f.Name, pattern, err)
+		}
+	}
+	return nil
+}
+
+// A Budget is the time matching may take: Base, plus PerStep for each
+// instruction of the compiled program and byte of the subject.
+type Budget struct {
+	Base, PerStep time.Duration
+}
+
+// For returns the time for a program of n instructions and a subject of
+// m bytes.
+func (b Budget) For(n, m int) time.Duration {
+	return b.Base + time.Duration(n)*time.Duration(m+1)*b.PerStep
+}
+
+// DefaultBudget is generous enough for instrumented fuzzing builds.
+var DefaultBudget = Budget{Base: 500 * time.Millisecond, PerStep: time.Microsecond}
+
+// CheckMatch compiles pattern with regexp.Compile and CompilePOSIX and
+// checks each against subject with Agree, within b. Patterns that do not
+// compile are ignored.
+func CheckMatch(pattern, subject string, b Budget) error {
+	for _, posix := range []bool{false, true} {
+		compile, name := regexp.Compile, "Compile"
+		flags := syntax.Perl
+		if posix {
+			compile, name = regexp.CompilePOSIX, "CompilePOSIX"
+			flags = syntax.POSIX
+		}
+		re, err := compile(pattern)
+		if err != nil {
+			continue
+		}
+		n := 0
+		if sre, err := syntax.Parse(pattern, flags); err == nil {
+			if prog, err := syntax.Compile(sre.Simplify()); err == nil {
+				n = len(prog.Inst)
+			}
+		}
+		limit := b.For(n, len(subject))
+		var spent time.Duration
+		err = harness.Run(harness.HangFactor*limit, func() error {
+			start := time.Now()
+			err := Agree(re, subject)
+			spent = time.Since(start)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s(%#q) on %q: %w", name, pattern, subject, err)
+		}
+		if spent > limit {
+			return &harness.Failure{
+				Kind:  harness.Blowup,
+				Value: fmt.Sprintf("%s(%#q): matching %d instructions against %d bytes took %v (budget %v)", name, pattern, n, len(subject), spent, limit),
+			}
+		}
+	}
+	return nil
+}
+
+// Agree checks that the methods of re that find the first match agree
+// on where it is in s, whether they take a string, bytes or a reader,
+// and with the matches FindAllStringIndex finds.
+func Agree(re *regexp.Regexp, s string) error {
+	loc := re.FindStringIndex(s)
+	if m := re.MatchString(s); m != (loc != nil) {
+		return fmt.Errorf("MatchString is %v but FindStringIndex is %v", m, loc)
+	}
+	if m := re.Match([]byte(s)); m != (loc != nil) {
+		return fmt.Errorf("Match is %v but FindStringIndex is %v", m, loc)
+	}
+	if m := re.MatchReader(strings.NewReader(s)); m != (loc != nil) {
+		return fmt.Errorf("MatchReader is %v but FindStringIndex is %v", m, loc)
+	}
+	if r := re.FindReaderIndex(strings.NewReader(s)); !slices.Equal(r, loc) {
+		return fmt.Errorf("FindReaderIndex is %v but FindStringIndex is %v", r, loc)
+	}
+	if b := re.FindIndex([]byte(s)); !slices.Equal(b, loc) {
+		return fmt.Errorf("FindIndex is %v but FindStringIndex is %v", b, loc)
+	}
+	sub := re.FindStringSubmatchIndex(s)
+	if sub == nil != (loc == nil) || sub != nil && !slices.Equal(sub[:2], loc) {
+		return fmt.Errorf("FindStringSubmatchIndex is %v but FindStringIndex is %v", sub, loc)
+	}
+	if sub != nil {
+		if len(sub) != 2*(re.NumSubexp()+1) {
+			return fmt.Errorf("FindStringSubmatchIndex has %d indexes for %d groups", len(sub), re.NumSubexp())
+		}
+		for i := 0; i < len(sub); i += 2 {
+			if sub[i] >= 0 && (sub[i] > sub[i+1] || sub[i+1] > len(s)) {
+				return fmt.Errorf("group %d of FindStringSubmatchIndex is [%d, %d] in %d bytes", i/2, sub[i], sub[i+1], len(s))
+			}
+		}
+		if r := re.FindReaderSubmatchIndex(strings.NewReader(s)); !slices.Equal(r, sub) {
+			return fmt.Errorf("FindReaderSubmatchIndex is %v but FindStringSubmatchIndex is %v", r, sub)
+		}
+	}
+	all := re.FindAllStringIndex(s, -1)
+	if (len(all) > 0) != (loc != nil) || loc != nil && !slices.Equal(all[0], loc) {
+		return fmt.Errorf("FindAllStringIndex starts %v but FindStringIndex is %v", all, loc)
+	}
+	for i, m := range all {
+		if m[0] > m[1] || m[1] > len(s) || i > 0 && m[0] < all[i-1][1] {
+			return fmt.Errorf("FindAllStringIndex match %d is %v, after %v", i, m, all[:i])
+		}
+	}
+	if prefix, complete := re.LiteralPrefix(); complete {
+		if i := strings.Index(s, prefix); (i >= 0) != (loc != nil) || loc != nil && loc[0] != i {
+			return fmt.Errorf("the literal %q is at %d in the subject, but FindStringIndex is %v", prefix, i, loc)
+		}
+	}
+	return nil
+}
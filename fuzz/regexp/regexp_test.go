@@ -0,0 +1,42 @@
+package regexp
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/regexpsrc"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, src := range gen.Sample("regexp/*", ".re", 16) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, pattern string) {
+		if err := CheckParse(pattern); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMatch(f *testing.F) {
+	gens, _ := gen.Match("regexp/*")
+	for _, g := range gens {
+		for i := range 16 {
+			var pattern, subject string
+			for _, file := range g.Generate(i) {
+				switch file.Name {
+				case "pattern.re":
+					pattern = string(file.Data)
+				case "input.txt":
+					subject = string(file.Data)
+				}
+			}
+			f.Add(pattern, subject)
+		}
+	}
+	f.Fuzz(func(t *testing.T, pattern, subject string) {
+		if err := CheckMatch(pattern, subject, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Package rust is a fuzz target for the Rust lexers and parsers written
+// in Go or bound to it: github.com/alecthomas/chroma, the syntax
+// highlighter of Hugo and Gitea among others, and
+// github.com/smacker/go-tree-sitter with its Rust grammar. CheckLex
+// tokenises a file with chroma's Rust lexer, whose tokens must spell the
+// file, and highlights it as HTML. It parses the file with tree-sitter,
+// which recovers from errors into a tree whose nodes must lie inside
+// their parents, in order. A file it parses without error must be covered
+// by its tokens, but for what tree-sitter skips between them; and edited
+// a byte at a time, it must parse incrementally to the tree it parses to
+// from scratch, and back to the tree it was.
+package rust
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/geeknik/fuzzing/internal/treesitter"
+	tsrust "github.com/smacker/go-tree-sitter/rust"
+)
+
+// Timeout bounds checking one file.
+var Timeout = 10 * time.Second
+
+// CheckLex checks the Rust file in data.
+func CheckLex(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		if err := lex(string(data)); err != nil {
+			return err
+		}
+		return treesitter.Check(grammar, data)
+	})
+}
+
+// grammar is tree-sitter's Rust grammar. Literals and comments are
+// tokens of their own, as their text is in no token.
+//
+// Known: the grammar keeps no node for the separator of a repetition in
+// a macro, the comma of $($x:expr),*; repetitions are tokens of their own
+// too.
+var grammar = &treesitter.Grammar{
+	Language: tsrust.GetLanguage(),
+	Leaves:   []string{"raw_string_literal", "block_comment", "line_comment", "string_literal", "char_literal", "token_repetition_pattern", "token_repetition"},
+	Skipped:  unicode.IsSpace,
+}
+
+// lex tokenises src with chroma's Rust lexer, checking that the tokens
+// spell src, and highlights it.
+func lex(src string) error {
+	l := lexers.Get("rust")
+	// The lexer reads src as runes, each byte of it that is not UTF-8 as
+	// U+FFFD; and adds a newline to a file that does not end in one.
+	it, err := l.Tokenise(&chroma.TokeniseOptions{State: "root"}, src)
+	if err != nil {
+		return err
+	}
+	var b strings.Builder
+	tokens := it.Tokens()
+	for _, t := range tokens {
+		b.WriteString(t.Value)
+	}
+	want := string([]rune(src))
+	if got := b.String(); got != want && got != want+"\n" {
+		return fmt.Errorf("chroma tokenises\n%q\nto tokens spelling\n%q", want, got)
+	}
+	return html.New(html.WithClasses(true), html.WithLineNumbers(true)).Format(io.Discard, styles.Fallback, chroma.Literator(tokens...))
+}
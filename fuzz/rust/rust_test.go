@@ -0,0 +1,19 @@
+package rust
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/rustsrc"
+)
+
+func FuzzLex(f *testing.F) {
+	for _, src := range gen.Sample("rust/*", ".rs", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckLex(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,181 @@
+// Package scanner is a fuzz target for go/scanner, apart from the parser
+// that usually drives it. Check scans raw bytes and verifies that the
+// scanner always reaches EOF, that token offsets only move forward and
+// match the source, and that errors are counted and reported the same
+// way however it is asked to scan.
+package scanner
+
+import (
+	"bytes"
+	"fmt"
+	"go/scanner"
+	"go/token"
+)
+
+// A Token is one token the scanner returned and where it starts.
+type Token struct {
+	Offset int
+	Tok    token.Token
+	Lit    string
+}
+
+// A Scan is everything one pass of the scanner over a source returned.
+type Scan struct {
+	Tokens []Token // up to and not including EOF
+	Errors []scanner.Error
+	Count  int // the scanner's ErrorCount
+	Lines  int // lines in the file's line table
+}
+
+// Run scans src in mode to EOF, and fails if the scanner goes on longer
+// than a scanner that consumes input can, or keeps returning tokens after
+// EOF.
+func Run(src []byte, mode scanner.Mode) (*Scan, error) {
+	fset := token.NewFileSet()
+	file := fset.AddFile("seed.go", -1, len(src))
+	sc := &Scan{}
+	var s scanner.Scanner
+	s.Init(file, src, func(pos token.Position, msg string) {
+		sc.Errors = append(sc.Errors, scanner.Error{Pos: pos, Msg: msg})
+	}, mode)
+	// Every token but an inserted semicolon consumes at least one byte,
+	// and at most one semicolon follows each.
+	limit := 2*len(src) + 2
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if len(sc.Tokens) == limit {
+			return nil, fmt.Errorf("no EOF after %d tokens of a %d byte input", limit, len(src))
+		}
+		if !pos.IsValid() {
+			return nil, fmt.Errorf("token %s %q has no position", tok, lit)
+		}
+		sc.Tokens = append(sc.Tokens, Token{file.Offset(pos), tok, lit})
+	}
+	for range 2 {
+		if _, tok, lit := s.Scan(); tok != token.EOF {
+			return nil, fmt.Errorf("%s %q after EOF", tok, lit)
+		}
+	}
+	sc.Count = s.ErrorCount
+	sc.Lines = file.LineCount()
+	return sc, nil
+}
+
+// Check scans src with and without comments and checks the invariants
+// of each scan and that they agree.
+func Check(src []byte) error {
+	with, err := Run(src, scanner.ScanComments)
+	if err != nil {
+		return err
+	}
+	if err := Invariants(src, with); err != nil {
+		return fmt.Errorf("ScanComments: %v", err)
+	}
+	without, err := Run(src, 0)
+	if err != nil {
+		return err
+	}
+	if err := Invariants(src, without); err != nil {
+		return err
+	}
+	return Agree(with, without)
+}
+
+// Invariants checks one scan of src: tokens start in order, each after
+// the end of the one before, and spell what the source says at their
+// offset; every line of src is in the line table; and the error handler
+// was called once for each error counted, at an offset within src.
+func Invariants(src []byte, sc *Scan) error {
+	end := 0
+	for i, t := range sc.Tokens {
+		if t.Offset > len(src) {
+			return fmt.Errorf("token %d, %s, at offset %d past the end at %d", i, t.Tok, t.Offset, len(src))
+		}
+		if t.Tok == token.SEMICOLON && t.Lit == "\n" {
+			// An inserted semicolon sits on the newline, or on the comment
+			// or EOF standing in for it, and consumes nothing.
+			if t.Offset < end {
+				return fmt.Errorf("inserted semicolon %d at offset %d inside the token before, which ends at %d", i, t.Offset, end)
+			}
+			continue
+		}
+		if t.Offset < end {
+			return fmt.Errorf("token %d, %s %q, at offset %d overlaps the token before, which ends at %d", i, t.Tok, t.Lit, t.Offset, end)
+		}
+		text, ok := spelling(t)
+		if !ok {
+			end = t.Offset + 1
+			continue
+		}
+		if !bytes.HasPrefix(src[t.Offset:], []byte(text)) {
+			return fmt.Errorf("token %d, %s, is %q but the source at offset %d is %q", i, t.Tok, text, t.Offset, clip(src[t.Offset:], len(text)))
+		}
+		end = t.Offset + len(text)
+	}
+	// A newline that ends the file starts no line.
+	if want := bytes.Count(bytes.TrimSuffix(src, []byte("\n")), []byte("\n")) + 1; sc.Lines != want {
+		return fmt.Errorf("line table has %d lines, the source %d", sc.Lines, want)
+	}
+	if len(sc.Errors) != sc.Count {
+		return fmt.Errorf("error handler called %d times for ErrorCount %d", len(sc.Errors), sc.Count)
+	}
+	for _, e := range sc.Errors {
+		if e.Pos.Offset < 0 || e.Pos.Offset > len(src) {
+			return fmt.Errorf("error %q at offset %d outside the source of %d bytes", e.Msg, e.Pos.Offset, len(src))
+		}
+	}
+	return nil
+}
+
+// spelling returns the source text of t, if it is known exactly. The
+// scanner drops carriage returns from raw strings and comments, and an
+// illegal character may be a byte that is not UTF-8.
+func spelling(t Token) (string, bool) {
+	switch {
+	case t.Tok == token.ILLEGAL, t.Tok == token.COMMENT:
+		return "", false
+	case t.Tok == token.STRING && len(t.Lit) > 0 && t.Lit[0] == '`':
+		return "", false
+	case t.Tok == token.IDENT, t.Tok.IsLiteral(), t.Tok.IsKeyword():
+		return t.Lit, true
+	}
+	return t.Tok.String(), true
+}
+
+func clip(b []byte, n int) []byte {
+	return b[:min(n, len(b))]
+}
+
+// Agree checks that a scan with comments and one without return the same
+// tokens, comments aside, and the same errors. Inserted semicolons may be
+// placed on a comment in one and on the newline after it in the other.
+func Agree(with, without *Scan) error {
+	var a []Token
+	for _, t := range with.Tokens {
+		if t.Tok != token.COMMENT {
+			a = append(a, t)
+		}
+	}
+	b := without.Tokens
+	for i := range max(len(a), len(b)) {
+		if i >= len(a) || i >= len(b) {
+			return fmt.Errorf("%d tokens with comments, %d without", len(a), len(b))
+		}
+		x, y := a[i], b[i]
+		if x.Tok != y.Tok || x.Lit != y.Lit || x.Offset != y.Offset && x.Lit != "\n" {
+			return fmt.Errorf("token %d is %s %q at offset %d with comments, %s %q at %d without", i, x.Tok, x.Lit, x.Offset, y.Tok, y.Lit, y.Offset)
+		}
+	}
+	if with.Count != without.Count {
+		return fmt.Errorf("%d errors with comments, %d without", with.Count, without.Count)
+	}
+	for i := range with.Errors {
+		if x, y := with.Errors[i], without.Errors[i]; x.Pos.Offset != y.Pos.Offset || x.Msg != y.Msg {
+			return fmt.Errorf("error %d is %q at offset %d with comments, %q at %d without", i, x.Msg, x.Pos.Offset, y.Msg, y.Pos.Offset)
+		}
+	}
+	return nil
+}
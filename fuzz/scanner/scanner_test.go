@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+	"github.com/geeknik/fuzzing/mutate"
+)
+
+func FuzzScan(f *testing.F) {
+	// Token-level mutants are almost Go, which is where the scanner's
+	// error paths are.
+	for i, src := range gen.Sample("go/*", ".go", 2) {
+		f.Add(src)
+		if m, ok := mutate.MutateTokens(src, uint64(i), 1<<20); ok {
+			f.Add(m)
+		}
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
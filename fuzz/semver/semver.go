@@ -0,0 +1,449 @@
+// Package semver is a fuzz target for golang.org/x/mod/semver and the
+// version rules of golang.org/x/mod/module. CheckVersions reads lines,
+// each a version, a module path and a version, or a retract interval.
+//
+// A version must be valid to semver exactly when parse, which reads the
+// Semantic Versioning 2.0.0 grammar with Go's "v" prefix and its "vN"
+// and "vN.N" shorthands, takes it, and its canonical form, major,
+// major.minor, prerelease and build must be the ones parse gives. It must
+// be a pseudo-version exactly when it has one of the three pseudo-version
+// forms; one whose timestamp is a time and that has a base version must
+// be made again from its major version, base, time and revision by
+// PseudoVersion. Every pair of versions must compare by the precedence
+// rules of the specification, with invalid versions below valid ones
+// and equal to each other, and Max and Sort must agree with Compare.
+//
+// A module path and version must pass module.Check exactly when the path
+// passes CheckPath and the version is valid and of the major version the
+// path's suffix asks for, and then escape and unescape to themselves. A
+// version or retract interval must parse in a go.mod retract directive
+// to its versions as written and format to a file that parses to the
+// same interval.
+package semver
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+)
+
+// MaxLines bounds the lines read from one input, as every triple of
+// their versions is compared.
+const MaxLines = 16
+
+// CheckVersions checks the versions, module versions and retract
+// intervals in data, one to a line.
+func CheckVersions(data []byte) error {
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > MaxLines {
+		lines = lines[:MaxLines]
+	}
+	var versions []string
+	for _, l := range lines {
+		f := strings.Fields(l)
+		switch {
+		case strings.HasPrefix(l, "[") || strings.HasPrefix(l, "("):
+			if err := checkInterval(l); err != nil {
+				return err
+			}
+		case len(f) == 1:
+			versions = append(versions, f[0])
+			if err := checkInterval(f[0]); err != nil {
+				return err
+			}
+		case len(f) == 2:
+			versions = append(versions, f[1])
+			if err := checkModule(f[0], f[1]); err != nil {
+				return err
+			}
+		}
+	}
+	for _, v := range versions {
+		if err := checkVersion(v); err != nil {
+			return err
+		}
+		if err := checkPseudo(v); err != nil {
+			return err
+		}
+	}
+	return checkOrder(versions)
+}
+
+// A version is a semantic version as parse reads it.
+type version struct {
+	major, minor, patch string
+	short               string   // what a shorthand lacks: ".0" or ".0.0"
+	prerelease          []string // the dot-separated identifiers
+	build               string   // with its "+"
+}
+
+// canonical returns the canonical form of v: complete, with its
+// prerelease and without its build.
+func (v version) canonical() string {
+	s := "v" + v.major + "." + v.minor + "." + v.patch
+	if len(v.prerelease) > 0 {
+		s += "-" + strings.Join(v.prerelease, ".")
+	}
+	return s
+}
+
+// parse reads s as "v" and a semantic version, or as a shorthand with no
+// patch or minor version, prerelease or build.
+func parse(s string) (version, bool) {
+	rest, ok := strings.CutPrefix(s, "v")
+	if !ok {
+		return version{}, false
+	}
+	core, tail := rest, ""
+	if i := strings.IndexAny(rest, "-+"); i >= 0 {
+		core, tail = rest[:i], rest[i:]
+	}
+	nums := strings.Split(core, ".")
+	if len(nums) > 3 || len(nums) < 3 && tail != "" {
+		return version{}, false
+	}
+	for _, n := range nums {
+		if !numeric(n) {
+			return version{}, false
+		}
+	}
+	var v version
+	v.major, v.minor, v.patch = nums[0], "0", "0"
+	switch len(nums) {
+	case 1:
+		v.short = ".0.0"
+	case 2:
+		v.minor, v.short = nums[1], ".0"
+	default:
+		v.minor, v.patch = nums[1], nums[2]
+	}
+	pre, build, hasBuild := strings.Cut(tail, "+")
+	if pre != "" {
+		v.prerelease = strings.Split(pre[1:], ".")
+		for _, id := range v.prerelease {
+			if !identifier(id) || allDigits(id) && !numeric(id) {
+				return version{}, false
+			}
+		}
+	}
+	if hasBuild {
+		for _, id := range strings.Split(build, ".") {
+			if !identifier(id) {
+				return version{}, false
+			}
+		}
+		v.build = "+" + build
+	}
+	return v, true
+}
+
+// numeric reports whether s is a number with no leading zero.
+func numeric(s string) bool {
+	return allDigits(s) && (s == "0" || s[0] != '0')
+}
+
+func allDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// identifier reports whether s is a prerelease or build identifier:
+// ASCII letters, digits and hyphens.
+func identifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range []byte(s) {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkVersion checks that semver reads v as parse does.
+func checkVersion(v string) error {
+	p, ok := parse(v)
+	if semver.IsValid(v) != ok {
+		return fmt.Errorf("IsValid(%q) = %v", v, !ok)
+	}
+	want := [...]string{"", "", "", "", ""}
+	if ok {
+		want = [...]string{p.canonical(), "v" + p.major, "v" + p.major + "." + p.minor, "", p.build}
+		if len(p.prerelease) > 0 {
+			want[3] = "-" + strings.Join(p.prerelease, ".")
+		}
+	}
+	got := [...]string{semver.Canonical(v), semver.Major(v), semver.MajorMinor(v), semver.Prerelease(v), semver.Build(v)}
+	if got != want {
+		return fmt.Errorf("%q has the canonical form, major, major.minor, prerelease and build %q, not %q", v, got, want)
+	}
+	c := semver.Canonical(v)
+	if ok && (semver.Canonical(c) != c || semver.Compare(v, c) != 0) {
+		return fmt.Errorf("%q has the canonical form %q, which is %q canonically and compares %d to it", v, c, semver.Canonical(c), semver.Compare(v, c))
+	}
+	cv := module.CanonicalVersion(v)
+	if wantCV := c + strings.Repeat("+incompatible", btoi(p.build == "+incompatible")); cv != wantCV {
+		return fmt.Errorf("CanonicalVersion(%q) = %q, not %q", v, cv, wantCV)
+	}
+	return nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pseudoRev is the last identifier of a pseudo-version's prerelease: a
+// timestamp and a revision.
+var pseudoRev = regexp.MustCompile(`^([0-9]{14})-([A-Za-z0-9]+)$`)
+
+// pseudoParts returns the timestamp, revision and base of v, if it is a
+// pseudo-version: vX.0.0-yyyymmddhhmmss-rev, with no base; a prerelease
+// vX.Y.Z-pre followed by .0.yyyymmddhhmmss-rev; or vX.Y.(Z+1)-0.yyyymmddhhmmss-rev
+// after the release vX.Y.Z. The base of the third form is returned as
+// vX.Y.(Z+1), with its patch version not yet decremented.
+func pseudoParts(s string) (ts, rev, base string, form int, ok bool) {
+	v, ok := parse(s)
+	if !ok || v.short != "" || len(v.prerelease) == 0 {
+		return "", "", "", 0, false
+	}
+	ids := v.prerelease
+	m := pseudoRev.FindStringSubmatch(ids[len(ids)-1])
+	if m == nil {
+		return "", "", "", 0, false
+	}
+	ts, rev = m[1], m[2]
+	switch {
+	case len(ids) == 1 && v.minor == "0" && v.patch == "0":
+		return ts, rev, "", 1, true
+	case len(ids) >= 2 && ids[len(ids)-2] == "0":
+		pre := ids[:len(ids)-2]
+		v.prerelease = pre
+		if len(pre) == 0 {
+			return ts, rev, v.canonical(), 3, true
+		}
+		return ts, rev, v.canonical(), 2, true
+	}
+	return "", "", "", 0, false
+}
+
+// checkPseudo checks that v is a pseudo-version exactly when pseudoParts
+// finds its parts, and that one that has a base and a timestamp that is
+// a time is made again from them by PseudoVersion.
+func checkPseudo(v string) error {
+	ts, rev, _, form, ok := pseudoParts(v)
+	if module.IsPseudoVersion(v) != ok {
+		return fmt.Errorf("IsPseudoVersion(%q) = %v", v, !ok)
+	}
+	if !ok {
+		return nil
+	}
+	if r, err := module.PseudoVersionRev(v); err != nil || r != rev {
+		return fmt.Errorf("PseudoVersionRev(%q) = %q, %v, not %q", v, r, err, rev)
+	}
+	t, err := module.PseudoVersionTime(v)
+	want, parseErr := time.Parse(module.PseudoVersionTimestampFormat, ts)
+	if (err == nil) != (parseErr == nil) || err == nil && !t.Equal(want) {
+		return fmt.Errorf("PseudoVersionTime(%q) = %v, %v, but its timestamp %s parses as %v, %v", v, t, err, ts, want, parseErr)
+	}
+	base, err := module.PseudoVersionBase(v)
+	if err != nil || parseErr != nil {
+		return nil
+	}
+	if form == 1 && base != "" || form != 1 && !semver.IsValid(base) {
+		return fmt.Errorf("PseudoVersionBase(%q) = %q", v, base)
+	}
+	if again := module.PseudoVersion(semver.Major(v), base, t, rev); again != v {
+		return fmt.Errorf("%q has the base %q, time %v and revision %q, which make %q", v, base, t, rev, again)
+	}
+	return nil
+}
+
+// compare compares a and b by the precedence of the specification.
+func compare(a, b version) int {
+	for _, c := range []int{compareNum(a.major, b.major), compareNum(a.minor, b.minor), compareNum(a.patch, b.patch)} {
+		if c != 0 {
+			return c
+		}
+	}
+	switch x, y := a.prerelease, b.prerelease; {
+	case len(x) == 0 && len(y) == 0:
+		return 0
+	case len(x) == 0:
+		return +1
+	case len(y) == 0:
+		return -1
+	}
+	for i := 0; i < len(a.prerelease) && i < len(b.prerelease); i++ {
+		x, y := a.prerelease[i], b.prerelease[i]
+		var c int
+		switch {
+		case allDigits(x) && allDigits(y):
+			c = compareNum(x, y)
+		case allDigits(x):
+			c = -1
+		case allDigits(y):
+			c = +1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareNum(fmt.Sprint(len(a.prerelease)), fmt.Sprint(len(b.prerelease)))
+}
+
+// compareNum compares numbers with no leading zeros.
+func compareNum(x, y string) int {
+	if len(x) != len(y) {
+		return compareNum(fmt.Sprint(len(x)), fmt.Sprint(len(y)))
+	}
+	return strings.Compare(x, y)
+}
+
+// checkOrder checks that Compare orders every pair of versions as
+// compare does, with invalid versions below valid ones, that Max gives
+// the greater canonically, and that Sort sorts them by Compare.
+func checkOrder(versions []string) error {
+	for _, a := range versions {
+		pa, okA := parse(a)
+		for _, b := range versions {
+			pb, okB := parse(b)
+			want := 0
+			switch {
+			case okA && okB:
+				want = compare(pa, pb)
+			case okA:
+				want = +1
+			case okB:
+				want = -1
+			}
+			if c := semver.Compare(a, b); c != want {
+				return fmt.Errorf("Compare(%q, %q) = %d, not %d", a, b, c, want)
+			}
+			wantMax := semver.Canonical(b)
+			if want > 0 {
+				wantMax = semver.Canonical(a)
+			}
+			if m := semver.Max(a, b); m != wantMax {
+				return fmt.Errorf("Max(%q, %q) = %q, not %q", a, b, m, wantMax)
+			}
+		}
+	}
+	sorted := slices.Clone(versions)
+	semver.Sort(sorted)
+	for i := 1; i < len(sorted); i++ {
+		if semver.Compare(sorted[i-1], sorted[i]) > 0 {
+			return fmt.Errorf("Sort sorts %q before %q", sorted[i-1], sorted[i])
+		}
+	}
+	return nil
+}
+
+// majorOK reports whether v is of the major version path asks for: that
+// of a /vN suffix for N of 2 or more, that of a gopkg.in .vN suffix, with
+// v0.0.0- pseudo-versions also taken for .v1, and v0 or v1 or a version
+// +incompatible for a path with no suffix. path must pass CheckPath.
+func majorOK(path, v string) bool {
+	major := semver.Major(v)
+	if rest, ok := strings.CutPrefix(path, "gopkg.in/"); ok {
+		rest = strings.TrimSuffix(rest, "-unstable")
+		suffix := rest[strings.LastIndex(rest, ".")+1:]
+		return major == suffix || suffix == "v1" && strings.HasPrefix(v, "v0.0.0-")
+	}
+	last := path[strings.LastIndex(path, "/")+1:]
+	if n, ok := strings.CutPrefix(last, "v"); ok && numeric(n) && n != "0" && n != "1" && strings.Contains(path, "/") {
+		return major == last
+	}
+	return major == "v0" || major == "v1" || semver.Build(v) == "+incompatible"
+}
+
+// checkModule checks that module.Check takes path and v exactly when the
+// path is valid and v is a valid version of the major version its suffix
+// asks for, and that such a pair escapes and unescapes to itself.
+func checkModule(path, v string) error {
+	err := module.Check(path, v)
+	want := module.CheckPath(path) == nil && semver.IsValid(v) && majorOK(path, v)
+	if (err == nil) != want {
+		return fmt.Errorf("Check(%q, %q) = %v", path, v, err)
+	}
+	if err != nil {
+		return nil
+	}
+	esc, err := module.EscapePath(path)
+	if err != nil {
+		return fmt.Errorf("EscapePath(%q): %v", path, err)
+	}
+	if strings.ToLower(esc) != esc {
+		return fmt.Errorf("EscapePath(%q) = %q", path, esc)
+	}
+	if p, err := module.UnescapePath(esc); err != nil || p != path {
+		return fmt.Errorf("%q escapes to %q, which unescapes to %q, %v", path, esc, p, err)
+	}
+	escV, err := module.EscapeVersion(v)
+	if err != nil {
+		return fmt.Errorf("EscapeVersion(%q): %v", v, err)
+	}
+	if u, err := module.UnescapeVersion(escV); err != nil || u != v {
+		return fmt.Errorf("%q escapes to %q, which unescapes to %q, %v", v, escV, u, err)
+	}
+	return nil
+}
+
+// plainInterval matches an interval, or a version alone, whose versions
+// are made of the characters of versions, which a go.mod takes as they
+// are.
+var plainInterval = regexp.MustCompile(`^(?:\[([0-9A-Za-z.+_~-]+), ([0-9A-Za-z.+_~-]+)\]|([0-9A-Za-z.+_~-]+))$`)
+
+// checkInterval checks that the retract interval s parses in a go.mod,
+// to its versions as written, and that the file formats to one with the
+// same interval. Intervals in any form but plainInterval's are only
+// parsed.
+//
+// Known: Parse keeps retract versions as written, valid or not, and
+// leaves checking them to the go command, so an invalid version is not
+// an error here.
+func checkInterval(s string) error {
+	f, err := modfile.Parse("go.mod", []byte("module example.com/m\n\nretract "+s+"\n"), nil)
+	m := plainInterval.FindStringSubmatch(s)
+	if m == nil {
+		return nil
+	}
+	want := modfile.VersionInterval{Low: m[1], High: m[2]}
+	if m[3] != "" {
+		want = modfile.VersionInterval{Low: m[3], High: m[3]}
+	}
+	if err != nil || len(f.Retract) != 1 || f.Retract[0].VersionInterval != want {
+		return fmt.Errorf("retract %s parses as %v, %v, not %v", s, retracts(f), err, want)
+	}
+	out, err := f.Format()
+	if err != nil {
+		return fmt.Errorf("retract %s: Format: %v", s, err)
+	}
+	g, err := modfile.Parse("go.mod", out, nil)
+	if err != nil || len(g.Retract) != 1 || g.Retract[0].VersionInterval != want {
+		return fmt.Errorf("retract %s formats as %q, which parses as %v, %v", s, out, retracts(g), err)
+	}
+	return nil
+}
+
+// retracts returns the intervals f retracts, for errors.
+func retracts(f *modfile.File) []modfile.VersionInterval {
+	if f == nil {
+		return nil
+	}
+	var vi []modfile.VersionInterval
+	for _, r := range f.Retract {
+		vi = append(vi, r.VersionInterval)
+	}
+	return vi
+}
@@ -0,0 +1,19 @@
+package semver
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/modsrc"
+)
+
+func FuzzVersions(f *testing.F) {
+	for _, data := range gen.Sample("mod/version", ".versions", 64) {
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckVersions(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,666 @@
+// Package shell is a fuzz target for mvdan.cc/sh/v3, the shell parser and
+// printer behind shfmt, and the interpreter of gosh and of Go build tools.
+// CheckParse parses a script as bash, POSIX shell and mksh; each may
+// reject it but not panic. Each statement a variant parses it must print
+// as a script that it parses again, printing it the same; and minified,
+// and simplified, as scripts it parses too. Each literal in the script,
+// and the script itself, quoted by syntax.Quote must read back as one word
+// that expands to that value and nothing more, as code that builds shell
+// commands from values relies on. Parsed recovering from errors, as
+// editors parse what is being typed, the script must print without
+// panicking.
+package shell
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"mvdan.cc/sh/v3/expand"
+	"mvdan.cc/sh/v3/syntax"
+)
+
+// Timeout bounds checking one script.
+var Timeout = 10 * time.Second
+
+// variants are the shell languages a script is parsed as.
+var variants = []syntax.LangVariant{syntax.LangBash, syntax.LangPOSIX, syntax.LangMirBSDKorn}
+
+// CheckParse checks the shell script in data.
+func CheckParse(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		for _, lang := range variants {
+			if err := check(lang, data); err != nil {
+				return fmt.Errorf("%s: %w", lang, err)
+			}
+		}
+		return nil
+	})
+}
+
+func check(lang syntax.LangVariant, src []byte) error {
+	// Known: recovering from errors, the parser may panic on arithmetic
+	// it cannot parse on the line of an assignment, as in a=$((i j)), and
+	// loop forever on a byte that is not UTF-8 in arithmetic, as in
+	// ${a,$((b:=\x8d.
+	if !arithAssign.Match(src) && utf8.Valid(src) {
+		recovered, _ := parse(src, syntax.Variant(lang), syntax.KeepComments(true), syntax.RecoverErrors(5))
+		if recovered != nil && !patternless(recovered) {
+			format(recovered) // may be any text
+		}
+	}
+	f, err := parse(src, syntax.Variant(lang), syntax.KeepComments(true))
+	if err != nil {
+		return nil
+	}
+	for _, stmt := range f.Stmts {
+		if misprinted(stmt, src) {
+			continue
+		}
+		start, end := stmt.Pos().Offset(), min(stmt.End().Offset(), uint(len(src)))
+		for _, c := range stmt.Comments {
+			start = min(start, c.Pos().Offset())
+		}
+		rest, _, _ := bytes.Cut(src[end:], []byte("\n"))
+		text := src[start : end+uint(len(rest))]
+		// Known: the parser takes a vertical tab or form feed for a blank,
+		// and the printer lays out the statement as if it broke the line,
+		// as in ! \fa, which it writes as ! and a on lines of their own.
+		if bytes.ContainsAny(text, "\v\f") {
+			continue
+		}
+		if err := checkPrint(lang, stmt, bytes.Contains(text, []byte("\\\n"))); err != nil {
+			return err
+		}
+	}
+	for _, v := range append(literals(f), string(src)) {
+		if err := checkQuote(lang, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkPrint checks that stmt prints as a script that parses and prints
+// the same, and minified and simplified as scripts that parse. It
+// simplifies stmt.
+//
+// Known: the printer lays out a statement by the lines of its source, and
+// lays out again on a second printing one that an escaped newline splits.
+// So if escaped is set, the check is of its second printing.
+func checkPrint(lang syntax.LangVariant, stmt *syntax.Stmt, escaped bool) error {
+	file := &syntax.File{Stmts: []*syntax.Stmt{stmt}}
+	out := format(file)
+	again, err := parse([]byte(out), syntax.Variant(lang), syntax.KeepComments(true))
+	if err != nil {
+		return fmt.Errorf("a statement parses, and prints as\n%s\nwhich does not parse: %v", out, err)
+	}
+	if escaped {
+		out = format(again)
+		if again, err = parse([]byte(out), syntax.Variant(lang), syntax.KeepComments(true)); err != nil {
+			return fmt.Errorf("a statement parses, and prints a second time as\n%s\nwhich does not parse: %v", out, err)
+		}
+	}
+	if out2 := format(again); out2 != out {
+		return fmt.Errorf("a statement parses, and prints as\n%s\nwhich parses and prints as\n%s", out, out2)
+	}
+	// Known: minified, the printer ends an empty case item in a lone
+	// semicolon, runs the braces of an empty block, and the reserved words
+	// around an empty loop or if body, into a word, keeps its mark of a
+	// separator past a compound command, running its closing word into the
+	// next, as in esacdone or { if a; then b & fi}, and runs the & of let i &
+	// into its expression.
+	if !emptyBody(file) && !staleClose(file) && !backgroundLet(file) {
+		minified := format(file, syntax.Minify(true))
+		if _, err := parse([]byte(minified), syntax.Variant(lang)); err != nil {
+			return fmt.Errorf("a statement parses, and minified prints as\n%s\nwhich does not parse: %v", minified, err)
+		}
+	}
+	syntax.Simplify(file)
+	simple := format(file)
+	if _, err := parse([]byte(simple), syntax.Variant(lang), syntax.KeepComments(true)); err != nil {
+		return fmt.Errorf("a statement parses, and simplified prints as\n%s\nwhich does not parse: %v", simple, err)
+	}
+	return nil
+}
+
+// patternless reports whether f has a case item without patterns, which
+// recovering from errors may leave.
+//
+// Known: the printer panics on such a case item, as recovered from
+// case x(, taking the position of its first pattern.
+func patternless(f *syntax.File) bool {
+	found := false
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if ci, ok := n.(*syntax.CaseItem); ok && len(ci.Patterns) == 0 {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// arithAssign matches an assignment followed on its line by arithmetic.
+var arithAssign = regexp.MustCompile(`[\w\]]\+?=[^\n]*(\(\(|\$\[)`)
+
+// emptyBody reports whether f has a case item, block, loop or if body
+// with no statements.
+func emptyBody(f *syntax.File) bool {
+	found := false
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.CaseItem:
+			found = found || len(n.Stmts) == 0
+		case *syntax.Block:
+			found = found || len(n.Stmts) == 0
+		case *syntax.WhileClause:
+			found = found || len(n.Do) == 0
+		case *syntax.ForClause:
+			found = found || len(n.Do) == 0
+		case *syntax.IfClause:
+			found = found || len(n.Then) == 0
+		}
+		return !found
+	})
+	return found
+}
+
+// staleClose reports whether f has a compound command whose commands end
+// in a separator the printer writes, and which is not itself followed by
+// one.
+func staleClose(f *syntax.File) bool {
+	found := false
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if s, ok := n.(*syntax.Stmt); ok && !s.Background && !s.Coprocess && !s.Disown {
+			switch s.Cmd.(type) {
+			case *syntax.CallExpr, *syntax.DeclClause, *syntax.LetClause, *syntax.TestClause,
+				*syntax.ArithmCmd, *syntax.TimeClause, *syntax.CoprocClause, *syntax.TestDecl, nil:
+			default:
+				found = found || endsInSemi([]*syntax.Stmt{s})
+			}
+		}
+		return !found
+	})
+	return found
+}
+
+// backgroundLet reports whether f has a let clause run in the background.
+func backgroundLet(f *syntax.File) bool {
+	found := false
+	syntax.Walk(f, func(n syntax.Node) bool {
+		if s, ok := n.(*syntax.Stmt); ok && (s.Background || s.Coprocess || s.Disown) {
+			_, let := s.Cmd.(*syntax.LetClause)
+			found = found || let
+		}
+		return !found
+	})
+	return found
+}
+
+// misprinted reports whether n, parsed from src, has a node that the
+// printer is known to print as a script that does not parse back to n.
+func misprinted(n syntax.Node, src []byte) bool {
+	found := false
+	syntax.Walk(n, func(n syntax.Node) bool {
+		found = found || staleSemi(n) || emptyPattern(n) || signRun(n) ||
+			commentInHeredoc(n, src) || joinedDelimiter(n, src) ||
+			parenSubshell(n) || letComment(n) || commentedEmpty(n) ||
+			danglingEscape(n) || joinedProcSubst(n) || dollarBackquote(n) ||
+			anonymousFunc(n) || bareFunc(n) || emptyFunsub(n) ||
+			bareDollar(n) || commentedBackquote(n) || argBeforeAssign(n) ||
+			escapedComment(n)
+		return !found
+	})
+	return found || heredocBeforeSubst(n)
+}
+
+// heredocBeforeSubst reports whether n has a here-document redirect and,
+// on a later line, a substitution that spans lines.
+//
+// Known: the printer may join the substitution to the line of the redirect,
+// as it does minified after a pipe, and write the here-document at the
+// first newline inside the substitution, where the parser does not look
+// for it.
+func heredocBeforeSubst(n syntax.Node) bool {
+	var line uint
+	found := false
+	syntax.Walk(n, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.Redirect:
+			if (n.Op == syntax.Hdoc || n.Op == syntax.DashHdoc) && line == 0 {
+				line = n.OpPos.Line()
+			}
+		case *syntax.CmdSubst, *syntax.ProcSubst:
+			found = found || line > 0 && n.Pos().Line() > line && n.End().Line() > n.Pos().Line()
+		}
+		return !found
+	})
+	return found
+}
+
+// emptyPattern reports whether n is a replacement of an empty pattern.
+//
+// Known: the printer writes ${x/} as ${x//}, and ${x//} as ${x///}.
+func emptyPattern(n syntax.Node) bool {
+	pe, ok := n.(*syntax.ParamExp)
+	return ok && pe.Repl != nil && pe.Repl.Orig == nil
+}
+
+// signRun reports whether n is an arithmetic operator with a sign on an
+// operand that starts with a prefix operator of the same sign.
+//
+// Known: the printer writes $((- --i)) as $((---i)); minified, it writes
+// $((a - -i)) as $((a--i)).
+func signRun(n syntax.Node) bool {
+	sign := func(n syntax.Node) byte {
+		if u, ok := n.(*syntax.UnaryArithm); ok && !u.Post {
+			switch u.Op {
+			case syntax.Plus, syntax.Inc:
+				return '+'
+			case syntax.Minus, syntax.Dec:
+				return '-'
+			}
+		}
+		return 0
+	}
+	switch n := n.(type) {
+	case *syntax.UnaryArithm:
+		return sign(n) != 0 && sign(n) == sign(n.X)
+	case *syntax.BinaryArithm:
+		switch n.Op {
+		case syntax.Add:
+			return sign(leftmost(n.Y)) == '+'
+		case syntax.Sub:
+			return sign(leftmost(n.Y)) == '-'
+		}
+	}
+	return false
+}
+
+// leftmost returns the operand that x starts with.
+func leftmost(x syntax.ArithmExpr) syntax.ArithmExpr {
+	for {
+		b, ok := x.(*syntax.BinaryArithm)
+		if !ok {
+			return x
+		}
+		x = b.X
+	}
+}
+
+// commentInHeredoc reports whether n is a here-document redirect with a
+// comment on its line.
+//
+// Known: the printer writes the comment twice if the command ends a
+// block, as in { a <<E #c<newline>E<newline>}, and into a command
+// substitution in the here-document if something comes between, as in
+// a <<E; #c, whose here-document $(t) it writes as $(t #c).
+func commentInHeredoc(n syntax.Node, src []byte) bool {
+	r, ok := n.(*syntax.Redirect)
+	if !ok || (r.Op != syntax.Hdoc && r.Op != syntax.DashHdoc) {
+		return false
+	}
+	rest, _, _ := bytes.Cut(src[r.Word.End().Offset():], []byte("\n"))
+	return bytes.Contains(rest, []byte("#"))
+}
+
+// joinedDelimiter reports whether n is a here-document redirect whose
+// body runs into its delimiter, as the parser allows a command
+// substitution to at the end of the script, as in <<-E<newline>$(<newline>)E.
+//
+// Known: the printer drops the text before the delimiter on its line.
+func joinedDelimiter(n syntax.Node, src []byte) bool {
+	r, ok := n.(*syntax.Redirect)
+	if !ok || r.Hdoc == nil {
+		return false
+	}
+	end := r.Hdoc.End().Offset()
+	return end > 0 && end <= uint(len(src)) && src[end-1] != '\n'
+}
+
+// parenSubshell reports whether n is a subshell whose first command
+// starts with a parenthesis.
+//
+// Known: the printer writes a space after the opening parenthesis, which
+// it keeps at the end of the line if the subshell spans lines, as in
+// ( ((i))<newline>), and drops on a second printing.
+func parenSubshell(n syntax.Node) bool {
+	sub, ok := n.(*syntax.Subshell)
+	if !ok || len(sub.Stmts) == 0 {
+		return false
+	}
+	switch sub.Stmts[0].Cmd.(type) {
+	case *syntax.Subshell, *syntax.ArithmCmd:
+		return true
+	}
+	return false
+}
+
+// danglingEscape reports whether n is a literal ending in a backslash
+// that escapes nothing, as one may at the end of a script.
+//
+// Known: the printer writes a newline after it, which it then escapes.
+func danglingEscape(n syntax.Node) bool {
+	lit, ok := n.(*syntax.Lit)
+	if !ok {
+		return false
+	}
+	v := strings.TrimRight(lit.Value, "\\")
+	return (len(lit.Value)-len(v))%2 == 1
+}
+
+// staleSemi reports whether n is a subshell or a command or process
+// substitution whose commands end in a separator the printer writes, as
+// after a & or a case item, however deeply nested.
+//
+// Known: the printer keeps the mark that it wrote that separator past the
+// closing parenthesis, and writes no semicolon before a reserved word
+// after the word, as in until "$(case x in a) ;; esac)" do or
+// if $(a &) then.
+func staleSemi(n syntax.Node) bool {
+	switch n := n.(type) {
+	case *syntax.Subshell:
+		return endsInSemi(n.Stmts)
+	case *syntax.CmdSubst:
+		return endsInSemi(n.Stmts)
+	case *syntax.ProcSubst:
+		return endsInSemi(n.Stmts)
+	}
+	return false
+}
+
+// endsInSemi reports whether the last statement in stmts, or in the
+// commands it ends in, is printed with a trailing separator.
+func endsInSemi(stmts []*syntax.Stmt) bool {
+	if len(stmts) == 0 {
+		return false
+	}
+	s := stmts[len(stmts)-1]
+	if s.Background || s.Coprocess || s.Disown {
+		return true
+	}
+	if s.Semicolon.IsValid() && s.Cmd != nil && s.Semicolon.Line() > s.Cmd.End().Line() {
+		return true // a \<newline>;
+	}
+	switch cmd := s.Cmd.(type) {
+	case *syntax.CaseClause:
+		return true
+	case *syntax.BinaryCmd:
+		return endsInSemi([]*syntax.Stmt{cmd.Y})
+	case *syntax.Block:
+		return len(cmd.Stmts) == 0 || endsInSemi(cmd.Stmts)
+	case *syntax.Subshell:
+		return endsInSemi(cmd.Stmts)
+	case *syntax.ForClause:
+		return endsInSemi(cmd.Do)
+	case *syntax.WhileClause:
+		return endsInSemi(cmd.Do)
+	case *syntax.IfClause:
+		for cmd.Else != nil {
+			cmd = cmd.Else
+		}
+		return endsInSemi(cmd.Then)
+	case *syntax.FuncDecl:
+		return endsInSemi([]*syntax.Stmt{cmd.Body})
+	}
+	return false
+}
+
+// commentedEmpty reports whether n is a loop or if clause with no commands
+// in a body, as mksh allows, and a comment.
+//
+// Known: the printer moves a comment before do or then to after it, and
+// drops one there, writing while a; do #c<newline>done as
+// while a; do<newline>done.
+func commentedEmpty(n syntax.Node) bool {
+	switch n := n.(type) {
+	case *syntax.WhileClause:
+		if len(n.Do) > 0 {
+			return false
+		}
+	case *syntax.ForClause:
+		if len(n.Do) > 0 {
+			return false
+		}
+	case *syntax.IfClause:
+		if len(n.Then) > 0 {
+			return false
+		}
+		if len(n.Last) > 0 {
+			return true // not walked
+		}
+	default:
+		return false
+	}
+	found := false
+	syntax.Walk(n, func(n syntax.Node) bool {
+		if _, ok := n.(*syntax.Comment); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// letComment reports whether n is a statement that ends in a let command
+// and has a comment.
+//
+// Known: the printer writes let x++; #c as let x++ #c, and
+// f() #c<newline>let x++ as f() let x++ #c, in which the parser takes the
+// comment for more expressions and rejects it.
+func letComment(n syntax.Node) bool {
+	s, ok := n.(*syntax.Stmt)
+	return ok && endsInLet(s.Cmd) && len(s.Comments) > 0
+}
+
+// endsInLet reports whether the last command in cmd is a let command.
+func endsInLet(cmd syntax.Command) bool {
+	switch cmd := cmd.(type) {
+	case *syntax.LetClause:
+		return true
+	case *syntax.FuncDecl:
+		return endsInLet(cmd.Body.Cmd)
+	case *syntax.BinaryCmd:
+		return endsInLet(cmd.Y.Cmd)
+	}
+	return false
+}
+
+// escapedComment reports whether n is a comment ending in a backslash,
+// which the parser keeps with the newline after it.
+//
+// Known: the parser takes the backslash to escape the newline after the
+// comment, as in a <#\<newline>b, reading the comment before b, and the
+// printer writes it after b, escaping the newline after.
+func escapedComment(n syntax.Node) bool {
+	c, ok := n.(*syntax.Comment)
+	return ok && strings.HasSuffix(strings.TrimSuffix(c.Text, "\n"), "\\")
+}
+
+// dollarBackquote reports whether n is a word with a backquoted command
+// substitution right after a literal dollar sign, as in $`a`.
+//
+// Known: the printer writes the substitution as $(a), so that the two
+// dollar signs read as the parameter $$.
+func dollarBackquote(n syntax.Node) bool {
+	w, ok := n.(*syntax.Word)
+	if !ok {
+		return false
+	}
+	for i, part := range w.Parts[1:] {
+		cs, ok := part.(*syntax.CmdSubst)
+		lit, after := w.Parts[i].(*syntax.Lit)
+		if ok && cs.Backquotes && after && strings.HasSuffix(lit.Value, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// anonymousFunc reports whether n is a function declaration with no
+// name.
+//
+// Known: the parser takes () for one in any language if its body starts
+// on the next line, and the printer writes it on one line, where only zsh
+// allows it.
+func anonymousFunc(n syntax.Node) bool {
+	fd, ok := n.(*syntax.FuncDecl)
+	return ok && fd.Name == nil && len(fd.Names) == 0
+}
+
+// bareFunc reports whether n declares a function with the function
+// reserved word and no parentheses, and a body other than a block, which
+// the parser allows on the line after the name.
+//
+// Known: the printer writes the body on the line of the name, where
+// function f<newline>(a) reads as function f (a), whose parentheses the
+// parser takes for those after the name, and function f<newline>a as
+// function f a, which declares two functions in zsh and none elsewhere.
+func bareFunc(n syntax.Node) bool {
+	fd, ok := n.(*syntax.FuncDecl)
+	if !ok || !fd.RsrvWord || fd.Parens {
+		return false
+	}
+	_, ok = fd.Body.Cmd.(*syntax.Block)
+	return !ok
+}
+
+// argBeforeAssign reports whether n is a call with an argument before
+// its assignments, as the parser makes of coproc ! a=.
+//
+// Known: the printer writes the assignments first, as in coproc a= !.
+func argBeforeAssign(n syntax.Node) bool {
+	call, ok := n.(*syntax.CallExpr)
+	return ok && len(call.Assigns) > 0 && len(call.Args) > 0 &&
+		call.Args[0].Pos().Offset() < call.Assigns[0].Pos().Offset()
+}
+
+// emptyFunsub reports whether n is a ${ ;} or ${|;} substitution of no
+// commands.
+//
+// Known: the printer writes ${ } as ${;}.
+func emptyFunsub(n syntax.Node) bool {
+	cs, ok := n.(*syntax.CmdSubst)
+	return ok && (cs.TempFile || cs.ReplyVar) && len(cs.Stmts) == 0
+}
+
+// bareDollar reports whether n is a word that ends in a literal dollar
+// sign.
+//
+// Known: minified, the printer writes $(($ ? 1 : 2)) as $(($?1:2)), so
+// that the dollar sign reads as the parameter $?.
+func bareDollar(n syntax.Node) bool {
+	w, ok := n.(*syntax.Word)
+	if !ok || len(w.Parts) == 0 {
+		return false
+	}
+	lit, ok := w.Parts[len(w.Parts)-1].(*syntax.Lit)
+	return ok && strings.HasSuffix(lit.Value, "$")
+}
+
+// commentedBackquote reports whether n is a backquoted command
+// substitution with a comment or here-document.
+//
+// Known: the printer writes `a #c` as $(a #c<newline>), and that as
+// $(<newline>a #c<newline>), laying it out anew, and so with a
+// here-document, which must end a line too.
+func commentedBackquote(n syntax.Node) bool {
+	cs, ok := n.(*syntax.CmdSubst)
+	if !ok || !cs.Backquotes {
+		return false
+	}
+	found := false
+	syntax.Walk(cs, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.Comment:
+			found = true
+		case *syntax.Redirect:
+			found = found || n.Op == syntax.Hdoc || n.Op == syntax.DashHdoc
+		}
+		return !found
+	})
+	return found
+}
+
+// joinedProcSubst reports whether n is a word with a process
+// substitution after its start, or an assignment to an array element of
+// a value that starts with one.
+//
+// Known: the printer writes a space before such a process substitution,
+// as in a"$x" <(b) for a"$x"<(b), and in x[k]= <(a) for x[k]=<(a), an
+// assignment to an element before a command, which the parser rejects.
+func joinedProcSubst(n syntax.Node) bool {
+	var parts []syntax.WordPart
+	switch n := n.(type) {
+	case *syntax.Word:
+		if len(n.Parts) > 1 {
+			parts = n.Parts[1:]
+		}
+	case *syntax.Assign:
+		if n.Index != nil && n.Value != nil {
+			parts = n.Value.Parts[:1]
+		}
+	}
+	return slices.ContainsFunc(parts, func(wp syntax.WordPart) bool {
+		_, ok := wp.(*syntax.ProcSubst)
+		return ok
+	})
+}
+
+// checkQuote checks that v quoted parses as one word that expands to v.
+func checkQuote(lang syntax.LangVariant, v string) error {
+	q, err := syntax.Quote(v, lang)
+	if err != nil {
+		return nil // v cannot be quoted in lang
+	}
+	var words []*syntax.Word
+	err = syntax.NewParser(syntax.Variant(lang)).Words(strings.NewReader(q), func(w *syntax.Word) bool {
+		words = append(words, w)
+		return true
+	})
+	if err != nil {
+		return fmt.Errorf("%q quotes as %s, which does not parse: %v", v, q, err)
+	}
+	if len(words) != 1 {
+		return fmt.Errorf("%q quotes as %s, which parses as %d words", v, q, len(words))
+	}
+	got, err := expand.Literal(nil, words[0])
+	if err != nil {
+		return fmt.Errorf("%q quotes as %s, which does not expand: %v", v, q, err)
+	}
+	if got != v {
+		return fmt.Errorf("%q quotes as %s, which expands to %q", v, q, got)
+	}
+	return nil
+}
+
+// literals returns the values of the unquoted and single-quoted literals
+// in f.
+func literals(f *syntax.File) []string {
+	var vs []string
+	syntax.Walk(f, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.Lit:
+			vs = append(vs, n.Value)
+		case *syntax.SglQuoted:
+			vs = append(vs, n.Value)
+		}
+		return true
+	})
+	return vs
+}
+
+func parse(src []byte, opts ...syntax.ParserOption) (*syntax.File, error) {
+	return syntax.NewParser(opts...).Parse(bytes.NewReader(src), "input.sh")
+}
+
+func format(n syntax.Node, opts ...syntax.PrinterOption) string {
+	var b strings.Builder
+	syntax.NewPrinter(opts...).Print(&b, n) // writes to a strings.Builder do not fail
+	return b.String()
+}
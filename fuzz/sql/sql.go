@@ -0,0 +1,304 @@
+// Package sql is a fuzz target for github.com/xwb1989/sqlparser, the
+// Vitess parser of MySQL, and github.com/cockroachdb/cockroachdb-parser,
+// the CockroachDB parser of PostgreSQL. CheckParse splits a script into
+// statements and parses each with each. What a parser parses it must
+// format as SQL that it parses again to the same statement, formatted
+// the same. And each string and identifier in the script, and the
+// script itself, quoted by the library's own functions for the purpose,
+// must read back as that value and nothing more, as code that builds SQL
+// from values relies on: no value may end the quotes around it early.
+package sql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/lexbase"
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/parser"
+	_ "github.com/cockroachdb/cockroachdb-parser/pkg/sql/plpgsql/parser" // parses the bodies of DO blocks
+	"github.com/cockroachdb/cockroachdb-parser/pkg/sql/sem/tree"
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/xwb1989/sqlparser"
+)
+
+// Timeout bounds checking one script.
+var Timeout = 10 * time.Second
+
+// A dialect is a parser of SQL and the functions that go with it.
+type dialect struct {
+	name string
+	// split splits a script into the text of its statements.
+	split func(string) []string
+	// parse parses a statement, and format formats what it parses.
+	parse  func(string) (any, error)
+	format func(any) string
+	// values returns the strings and identifiers in a script.
+	values func(string) []string
+	// str and ident quote a value as a string and an identifier, and
+	// isStr and isIdent report whether a statement parsed from
+	// "SELECT " and one is the value.
+	str, ident     func(string) string
+	isStr, isIdent func(stmt any, v string) bool
+}
+
+var dialects = []dialect{
+	{
+		name:    "sqlparser",
+		split:   splitVitess,
+		parse:   parseVitess,
+		format:  func(stmt any) string { return sqlparser.String(stmt.(sqlparser.Statement)) },
+		values:  valuesVitess,
+		str:     func(v string) string { return sqlparser.String(sqlparser.NewStrVal([]byte(v))) },
+		ident:   func(v string) string { return sqlparser.String(sqlparser.NewColIdent(v)) },
+		isStr:   isStrVitess,
+		isIdent: isIdentVitess,
+	},
+	{
+		name:    "cockroachdb-parser",
+		split:   splitCockroach,
+		parse:   parseCockroach,
+		format:  func(stmt any) string { return tree.AsStringWithFlags(stmt.(tree.Statement), tree.FmtParsable) },
+		values:  valuesCockroach,
+		str:     lexbase.EscapeSQLString,
+		ident:   lexbase.EscapeSQLIdent,
+		isStr:   isStrCockroach,
+		isIdent: isIdentCockroach,
+	},
+}
+
+// CheckParse checks the script in data. Statements a parser does not
+// parse are ignored.
+func CheckParse(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		for _, d := range dialects {
+			if err := check(d, string(data)); err != nil {
+				return fmt.Errorf("%s: %w", d.name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// versionOnly matches a MySQL comment of up to five digits alone.
+var versionOnly = regexp.MustCompile(`/\*!\p{Nd}{0,5}\*/`)
+
+func check(d dialect, script string) error {
+	// Known: sqlparser panics on a MySQL comment of a version alone, as
+	// /*!50000*/, or of nothing, looking for the end of the version.
+	if d.name == "sqlparser" && versionOnly.MatchString(script) {
+		return nil
+	}
+	for _, text := range d.split(script) {
+		stmt, err := d.parse(text)
+		if err != nil || partial(stmt) {
+			continue
+		}
+		// Known: cockroachdb-parser takes $65536, whose index is 65535,
+		// and formats it as $0, adding 1 to the index as a uint16.
+		if d.name == "cockroachdb-parser" && lastPlaceholder(text) {
+			continue
+		}
+		// Known: sqlparser writes identifiers with runes past U+FFFF
+		// bare, as checkQuote has it.
+		if d.name == "sqlparser" && astral(text) {
+			continue
+		}
+		out := d.format(stmt)
+		again, err := d.parse(out)
+		if err != nil {
+			return fmt.Errorf("%q parses, and formats as\n%q\nwhich does not parse: %v", text, out, err)
+		}
+		if out2 := d.format(again); out2 != out {
+			return fmt.Errorf("%q parses, and formats as\n%q\nwhich parses and formats as\n%q", text, out, out2)
+		}
+	}
+	for _, v := range append(d.values(script), script) {
+		if err := checkQuote(d, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkQuote checks that v quoted as a string, and as an identifier,
+// parses as a select of v.
+func checkQuote(d dialect, v string) error {
+	for _, q := range []struct {
+		kind  string
+		quote func(string) string
+		is    func(any, string) bool
+	}{
+		{"string", d.str, d.isStr},
+		{"identifier", d.ident, d.isIdent},
+	} {
+		if q.kind == "identifier" && v == "" {
+			continue // no identifier is empty
+		}
+		sql := "SELECT " + q.quote(v)
+		// Known: sqlparser writes an identifier a rune at a time, which
+		// turns bytes that are not UTF-8 into U+FFFD; and bare if the low
+		// 16 bits of each rune are a letter, as they may be past U+FFFF,
+		// which it then rejects.
+		if d.name == "sqlparser" && q.kind == "identifier" && (!utf8.ValidString(v) || astral(v)) {
+			continue
+		}
+		stmt, err := d.parse(sql)
+		if err != nil {
+			// cockroachdb-parser takes only UTF-8 strings, and rejects
+			// the literal it quotes any other as, which is safe.
+			if !utf8.ValidString(v) {
+				continue
+			}
+			return fmt.Errorf("%q quoted as a %s is %q, which does not parse: %v", v, q.kind, sql, err)
+		}
+		if !q.is(stmt, v) {
+			return fmt.Errorf("%q quoted as a %s is %q, which parses as %q", v, q.kind, sql, d.format(stmt))
+		}
+	}
+	return nil
+}
+
+func splitVitess(script string) []string {
+	pieces, _ := sqlparser.SplitStatementToPieces(script)
+	return pieces
+}
+
+func parseVitess(text string) (any, error) {
+	return sqlparser.ParseStrictDDL(text)
+}
+
+// astral reports whether s has a rune past U+FFFF.
+func astral(s string) bool {
+	return strings.ContainsFunc(s, func(r rune) bool { return r > 0xffff })
+}
+
+// partial reports whether stmt is one a parser parses only in part,
+// dropping what it does not format.
+func partial(stmt any) bool {
+	switch stmt := stmt.(type) {
+	case *sqlparser.DDL:
+		// Known: sqlparser parses CREATE INDEX, CREATE VIEW and most of
+		// ALTER TABLE as a DDL of their action and table alone, and
+		// EXPLAIN, DESCRIBE, REPAIR and the like as nothing at all, and
+		// formats them so, which is not SQL.
+		return stmt.TableSpec == nil && stmt.Action != sqlparser.DropStr && stmt.Action != sqlparser.RenameStr && stmt.Action != sqlparser.TruncateStr
+	case *sqlparser.OtherRead, *sqlparser.OtherAdmin:
+		return true
+	case tree.Statement:
+		// Known: cockroachdb-parser formats EXECUTE in a PL/pgSQL block
+		// as "EXECUTE a dynamic command", with a TODO to format the
+		// command.
+		return strings.Contains(tree.AsString(stmt), "EXECUTE a dynamic command")
+	}
+	return false
+}
+
+func valuesVitess(script string) []string {
+	var values []string
+	t := sqlparser.NewStringTokenizer(script)
+	for {
+		typ, val := t.Scan()
+		switch typ {
+		case 0, sqlparser.LEX_ERROR:
+			return values
+		case sqlparser.STRING, sqlparser.ID:
+			values = append(values, string(val))
+		}
+	}
+}
+
+// selected returns the one expression selected by stmt, a statement
+// parsed by sqlparser, or nil.
+func selected(stmt any) sqlparser.Expr {
+	sel, ok := stmt.(*sqlparser.Select)
+	if !ok || len(sel.SelectExprs) != 1 {
+		return nil
+	}
+	e, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr)
+	if !ok || !e.As.IsEmpty() {
+		return nil
+	}
+	return e.Expr
+}
+
+func isStrVitess(stmt any, v string) bool {
+	val, ok := selected(stmt).(*sqlparser.SQLVal)
+	return ok && val.Type == sqlparser.StrVal && string(val.Val) == v
+}
+
+func isIdentVitess(stmt any, v string) bool {
+	col, ok := selected(stmt).(*sqlparser.ColName)
+	return ok && col.Qualifier.IsEmpty() && col.Name.String() == v
+}
+
+func splitCockroach(script string) []string {
+	var stmts []string
+	for script != "" {
+		n, ok := parser.SplitFirstStatement(script)
+		if !ok {
+			return append(stmts, script)
+		}
+		stmts = append(stmts, script[:n])
+		script = script[n:]
+	}
+	return stmts
+}
+
+func parseCockroach(text string) (any, error) {
+	stmt, err := parser.ParseOne(text)
+	if err != nil {
+		return nil, err
+	}
+	return stmt.AST, nil
+}
+
+func valuesCockroach(script string) []string {
+	var values []string
+	tokens, _ := parser.Tokens(script)
+	for _, t := range tokens {
+		switch t.TokenID {
+		case parser.SCONST, parser.IDENT, parser.BCONST:
+			values = append(values, t.Str)
+		}
+	}
+	return values
+}
+
+// lastPlaceholder reports whether text has the placeholder $65536.
+func lastPlaceholder(text string) bool {
+	tokens, _ := parser.Tokens(text)
+	for _, t := range tokens {
+		if n, err := strconv.ParseUint(t.Str, 10, 64); t.TokenID == parser.PLACEHOLDER && err == nil && n == tree.MaxPlaceholderIdx+1 {
+			return true
+		}
+	}
+	return false
+}
+
+// selectedExpr returns the one expression selected by stmt, a statement
+// parsed by cockroachdb-parser, or nil.
+func selectedExpr(stmt any) tree.Expr {
+	sel, ok := stmt.(*tree.Select)
+	if !ok {
+		return nil
+	}
+	clause, ok := sel.Select.(*tree.SelectClause)
+	if !ok || len(clause.Exprs) != 1 || clause.Exprs[0].As != "" {
+		return nil
+	}
+	return clause.Exprs[0].Expr
+}
+
+func isStrCockroach(stmt any, v string) bool {
+	s, ok := selectedExpr(stmt).(*tree.StrVal)
+	return ok && s.RawString() == v
+}
+
+func isIdentCockroach(stmt any, v string) bool {
+	n, ok := selectedExpr(stmt).(*tree.UnresolvedName)
+	return ok && n.NumParts == 1 && n.Parts[0] == v
+}
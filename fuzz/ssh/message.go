@@ -0,0 +1,295 @@
+package ssh
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"golang.org/x/crypto/ssh"
+)
+
+// The messages of the key exchange, declared as x/crypto/ssh declares
+// them, which it does not export.
+type (
+	extInfoMsg struct {
+		NumExtensions uint32 `sshtype:"7"`
+		Payload       []byte `ssh:"rest"`
+	}
+	kexInitMsg struct {
+		Cookie                  [16]byte `sshtype:"20"`
+		KexAlgos                []string
+		ServerHostKeyAlgos      []string
+		CiphersClientServer     []string
+		CiphersServerClient     []string
+		MACsClientServer        []string
+		MACsServerClient        []string
+		CompressionClientServer []string
+		CompressionServerClient []string
+		LanguagesClientServer   []string
+		LanguagesServerClient   []string
+		FirstKexFollows         bool
+		Reserved                uint32
+	}
+	kexDHInitMsg struct {
+		X *big.Int `sshtype:"30"`
+	}
+	kexECDHInitMsg struct {
+		ClientPubKey []byte `sshtype:"30"`
+	}
+	kexDHReplyMsg struct {
+		HostKey   []byte `sshtype:"31"`
+		Y         *big.Int
+		Signature []byte
+	}
+	kexECDHReplyMsg struct {
+		HostKey         []byte `sshtype:"31"`
+		EphemeralPubKey []byte
+		Signature       []byte
+	}
+	kexDHGexGroupMsg struct {
+		P *big.Int `sshtype:"31"`
+		G *big.Int
+	}
+	kexDHGexInitMsg struct {
+		X *big.Int `sshtype:"32"`
+	}
+	kexDHGexReplyMsg struct {
+		HostKey   []byte `sshtype:"33"`
+		Y         *big.Int
+		Signature []byte
+	}
+	kexDHGexRequestMsg struct {
+		MinBits       uint32 `sshtype:"34"`
+		PreferredBits uint32
+		MaxBits       uint32
+	}
+)
+
+// A schema is a message: its number and a new struct to decode it into,
+// whose fields say what the message holds.
+type schema struct {
+	typ byte
+	new func() any
+}
+
+// schemas are the messages CheckMessage decodes data as. Numbers 30 and
+// 31 each stand for more than one message, told apart only by the key
+// exchange that was agreed.
+var schemas = []schema{
+	{7, func() any { return new(extInfoMsg) }},
+	{20, func() any { return new(kexInitMsg) }},
+	{30, func() any { return new(kexDHInitMsg) }},
+	{30, func() any { return new(kexECDHInitMsg) }},
+	{31, func() any { return new(kexDHReplyMsg) }},
+	{31, func() any { return new(kexECDHReplyMsg) }},
+	{31, func() any { return new(kexDHGexGroupMsg) }},
+	{32, func() any { return new(kexDHGexInitMsg) }},
+	{33, func() any { return new(kexDHGexReplyMsg) }},
+	{34, func() any { return new(kexDHGexRequestMsg) }},
+}
+
+// CheckMessage checks that ssh.Unmarshal decodes data as each message of
+// the key exchange if and only if the harness's reader does, and to the
+// same values; that ssh.Marshal encodes those values back to data when
+// data is as they encode, and otherwise to bytes that decode to them;
+// and that a host key in a message that ssh.ParsePublicKey reads
+// encodes to a key that reads back the same.
+func CheckMessage(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		for _, sc := range schemas {
+			if err := checkMessage(data, sc); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func checkMessage(data []byte, sc schema) error {
+	msg := sc.new()
+	name := reflect.TypeOf(msg).Elem().Name()
+	want, canonical, ok := read(data, sc.typ, reflect.TypeOf(msg).Elem())
+	err := ssh.Unmarshal(data, msg)
+	switch {
+	case ok && err != nil:
+		return fmt.Errorf("Unmarshal as %s: %v, but the message reads as %v", name, err, want)
+	case !ok && err == nil:
+		return fmt.Errorf("Unmarshal as %s read %+v, but the message does not read", name, msg)
+	case !ok:
+		return nil
+	}
+	if err := same(name, values(msg), want); err != nil {
+		return fmt.Errorf("Unmarshal: %v", err)
+	}
+	enc := ssh.Marshal(msg)
+	if canonical && !bytes.Equal(enc, data) {
+		return fmt.Errorf("Marshal of %s %+v gave %x for %x", name, msg, enc, data)
+	}
+	again := sc.new()
+	if err := ssh.Unmarshal(enc, again); err != nil {
+		return fmt.Errorf("Unmarshal of Marshal of %s %+v: %v", name, msg, err)
+	}
+	if err := same(name, values(again), want); err != nil {
+		return fmt.Errorf("Unmarshal of Marshal: %v", err)
+	}
+	if f := reflect.ValueOf(msg).Elem().FieldByName("HostKey"); f.IsValid() {
+		return checkHostKey(f.Bytes())
+	}
+	return nil
+}
+
+// checkHostKey checks that a host key that parses encodes to one that
+// parses and encodes the same.
+func checkHostKey(blob []byte) error {
+	k, err := ssh.ParsePublicKey(blob)
+	if err != nil {
+		return nil
+	}
+	enc := k.Marshal()
+	again, err := ssh.ParsePublicKey(enc)
+	if err != nil {
+		return fmt.Errorf("ParsePublicKey of the Marshal of a %s key %x: %v", k.Type(), blob, err)
+	}
+	if !bytes.Equal(again.Marshal(), enc) {
+		return fmt.Errorf("a %s key %x encodes as %x, which reads back as %x", k.Type(), blob, enc, again.Marshal())
+	}
+	return nil
+}
+
+// read reads data as the message of number typ whose fields are those
+// of t, by RFC 4251: a byte for a bool, which is true if it is not zero,
+// four for a uint32, a string for a []byte, a name-list for a []string,
+// an mpint for a *big.Int, and for a field tagged ssh:"rest" what is
+// left. It returns the values of the fields, arrays as slices, whether
+// data is as they encode, which is so if its mpints take as few bytes as
+// they can and its bools are zero or one, and whether data reads at all,
+// which is so if each field is whole and nothing comes after the last.
+//
+// RFC 4251 forbids empty names in a name-list, as it does names that are
+// not ASCII, but x/crypto/ssh decodes them, as any other name, and
+// leaves to what uses a list to find that it knows none of them; the
+// reader does the same.
+func read(data []byte, typ byte, t reflect.Type) (vals []any, canonical, ok bool) {
+	if len(data) == 0 || data[0] != typ {
+		return nil, false, false
+	}
+	r := data[1:]
+	canonical = true
+	for i := range t.NumField() {
+		f := t.Field(i)
+		switch {
+		case f.Tag.Get("ssh") == "rest":
+			vals = append(vals, r)
+			r = r[len(r):]
+		case f.Type.Kind() == reflect.Array:
+			if len(r) < f.Type.Len() {
+				return nil, false, false
+			}
+			vals = append(vals, r[:f.Type.Len()])
+			r = r[f.Type.Len():]
+		case f.Type.Kind() == reflect.Bool:
+			if len(r) < 1 {
+				return nil, false, false
+			}
+			vals = append(vals, r[0] != 0)
+			canonical = canonical && r[0] <= 1
+			r = r[1:]
+		case f.Type.Kind() == reflect.Uint32:
+			if len(r) < 4 {
+				return nil, false, false
+			}
+			vals = append(vals, binary.BigEndian.Uint32(r))
+			r = r[4:]
+		default:
+			if len(r) < 4 || uint64(binary.BigEndian.Uint32(r)) > uint64(len(r)-4) {
+				return nil, false, false
+			}
+			s := r[4 : 4+binary.BigEndian.Uint32(r)]
+			r = r[4+len(s):]
+			switch f.Type {
+			case reflect.TypeFor[[]byte]():
+				vals = append(vals, s)
+			case reflect.TypeFor[[]string]():
+				if len(s) == 0 {
+					vals = append(vals, []string{})
+				} else {
+					vals = append(vals, strings.Split(string(s), ","))
+				}
+			case reflect.TypeFor[*big.Int]():
+				n, minimal := mpint(s)
+				vals = append(vals, n)
+				canonical = canonical && minimal
+			default:
+				panic("ssh: no wire type for " + f.Type.String())
+			}
+		}
+	}
+	if len(r) != 0 {
+		return nil, false, false
+	}
+	return vals, canonical, true
+}
+
+// mpint returns the integer s holds in two's complement, big-endian, and
+// whether s takes as few bytes as it can: none for zero, and no leading
+// zero or 0xff byte that the next byte's top bit makes needless.
+func mpint(s []byte) (*big.Int, bool) {
+	n := new(big.Int).SetBytes(s)
+	if len(s) > 0 && s[0]&0x80 != 0 {
+		n.Sub(n, new(big.Int).Lsh(big.NewInt(1), uint(8*len(s))))
+	}
+	switch {
+	case len(s) == 0:
+		return n, true
+	case s[0] == 0:
+		return n, len(s) > 1 && s[1]&0x80 != 0
+	case s[0] == 0xff && len(s) > 1:
+		return n, s[1]&0x80 == 0
+	}
+	return n, true
+}
+
+// values returns the fields of the struct msg points to, arrays as
+// slices.
+func values(msg any) []any {
+	v := reflect.ValueOf(msg).Elem()
+	var vals []any
+	for i := range v.NumField() {
+		f := v.Field(i)
+		if f.Kind() == reflect.Array {
+			b := make([]byte, f.Len())
+			reflect.Copy(reflect.ValueOf(b), f)
+			vals = append(vals, b)
+			continue
+		}
+		vals = append(vals, f.Interface())
+	}
+	return vals
+}
+
+// same reports the first of got that is not what want says.
+func same(name string, got, want []any) error {
+	for i := range want {
+		var eq bool
+		switch w := want[i].(type) {
+		case []byte:
+			eq = bytes.Equal(got[i].([]byte), w)
+		case []string:
+			eq = slices.Equal(got[i].([]string), w)
+		case *big.Int:
+			g := got[i].(*big.Int)
+			eq = g != nil && g.Cmp(w) == 0
+		default:
+			eq = got[i] == w
+		}
+		if !eq {
+			return fmt.Errorf("field %d of %s is %v, want %v", i, name, got[i], want[i])
+		}
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+// Package ssh is a fuzz target for golang.org/x/crypto/ssh. CheckServer
+// runs a server's handshake against data as what a client sent, and
+// CheckClient a client's against data as what a server sent, over a
+// connection that reads the data and nothing more; no network is
+// involved. Both offer every key exchange, cipher and MAC the package
+// implements. Neither handshake may succeed, since the data cannot answer
+// what the harness sends, and what the harness sends before its keys
+// change must be an identification string and well-formed packets, the
+// first a KEXINIT. CheckMessage decodes data as each message of the key
+// exchange and checks what it decodes to against a reader of its own.
+package ssh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"golang.org/x/crypto/ssh"
+)
+
+// Timeout bounds one handshake.
+var Timeout = 10 * time.Second
+
+// hostKey is the server's, an Ed25519 key from a fixed seed.
+var hostKey = func() ssh.Signer {
+	s, err := ssh.NewSignerFromKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}()
+
+// config offers every algorithm the package implements, the insecure
+// ones included.
+func config() ssh.Config {
+	sup, insec := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	return ssh.Config{
+		KeyExchanges: slices.Concat(sup.KeyExchanges, insec.KeyExchanges),
+		Ciphers:      slices.Concat(sup.Ciphers, insec.Ciphers),
+		MACs:         slices.Concat(sup.MACs, insec.MACs),
+	}
+}
+
+// CheckServer checks a server's handshake reading data from a client.
+// The server turns down every password, though the data cannot get as
+// far as sending one.
+func CheckServer(data []byte) error {
+	c := newConn(data)
+	conf := &ssh.ServerConfig{
+		Config: config(),
+		PasswordCallback: func(ssh.ConnMetadata, []byte) (*ssh.Permissions, error) {
+			return nil, errors.New("no")
+		},
+	}
+	conf.AddHostKey(hostKey)
+	return check(c, func() error {
+		_, _, _, err := ssh.NewServerConn(c, conf)
+		return err
+	})
+}
+
+// CheckClient checks a client's handshake reading data from a server.
+// The client takes any host key, so that a server's, which cannot have
+// signed the exchange, gets it as far as the signature.
+func CheckClient(data []byte) error {
+	c := newConn(data)
+	sup, insec := ssh.SupportedAlgorithms(), ssh.InsecureAlgorithms()
+	conf := &ssh.ClientConfig{
+		Config:            config(),
+		User:              "fuzz",
+		Auth:              []ssh.AuthMethod{ssh.Password("fuzz")},
+		HostKeyCallback:   ssh.InsecureIgnoreHostKey(),
+		HostKeyAlgorithms: slices.Concat(sup.HostKeys, insec.HostKeys),
+	}
+	return check(c, func() error {
+		_, _, _, err := ssh.NewClientConn(c, "pipe", conf)
+		return err
+	})
+}
+
+func check(c *conn, handshake func() error) error {
+	err := harness.Run(Timeout, handshake)
+	var f *harness.Failure
+	if errors.As(err, &f) {
+		return err
+	}
+	if err == nil {
+		return fmt.Errorf("the handshake succeeded reading %d bytes and no more", c.in.Size())
+	}
+	return written(c.output())
+}
+
+// written checks what the harness wrote before its keys changed: an
+// identification string, then whole packets up to its first NEWKEYS,
+// after which they are encrypted. The first is a KEXINIT, and each has
+// a payload and four bytes of padding or more, which bring it to a
+// multiple of eight.
+func written(out []byte) error {
+	line, _, ok := bytes.Cut(out, []byte("\r\n"))
+	if !ok || !bytes.HasPrefix(line, []byte("SSH-2.0-")) || len(line)+2 > 255 {
+		return fmt.Errorf("the harness wrote %.300q for its identification string", out)
+	}
+	for off, n := len(line)+2, 0; off < len(out); n++ {
+		if len(out)-off < 5 {
+			return fmt.Errorf("the harness wrote %d bytes of a packet header at %d", len(out)-off, off)
+		}
+		length, padding := int(binary.BigEndian.Uint32(out[off:])), int(out[off+4])
+		switch {
+		case length > 256*1024:
+			return fmt.Errorf("the harness wrote a packet of %d bytes at %d", length, off)
+		case off+4+length > len(out):
+			return fmt.Errorf("the harness wrote %d bytes of a packet of %d at %d", len(out)-off-4, length, off)
+		case padding < 4 || padding+2 > length:
+			return fmt.Errorf("the harness wrote a packet of %d bytes with %d of padding at %d", length, padding, off)
+		case (4+length)%8 != 0:
+			return fmt.Errorf("the harness wrote a packet of %d bytes, not a multiple of eight, at %d", 4+length, off)
+		}
+		payload := out[off+5 : off+4+length-padding]
+		if n == 0 {
+			if err := ssh.Unmarshal(payload, new(kexInitMsg)); err != nil {
+				return fmt.Errorf("the harness wrote a first packet that is not a KEXINIT: %v", err)
+			}
+		}
+		if payload[0] == msgNewKeys {
+			break
+		}
+		off += 4 + length
+	}
+	return nil
+}
+
+// msgNewKeys is the number of NEWKEYS.
+const msgNewKeys = 21
+
+// A conn is a net.Conn that reads from in until it runs out, collects
+// what is written in out until it is closed and never times out. The
+// handshake writes from more than one goroutine, and may go on after it
+// has failed until it sees the conn closed.
+type conn struct {
+	in     *bytes.Reader
+	mu     sync.Mutex
+	out    bytes.Buffer
+	closed bool
+}
+
+func newConn(data []byte) *conn {
+	return &conn{in: bytes.NewReader(data)}
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.in.Read(p)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return 0, net.ErrClosed
+	}
+	return c.out.Write(p)
+}
+
+func (c *conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	return nil
+}
+
+// output returns what was written before the conn was closed.
+func (c *conn) output() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.out.Bytes())
+}
+
+func (c *conn) LocalAddr() net.Addr              { return addr{} }
+func (c *conn) RemoteAddr() net.Addr             { return addr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+type addr struct{}
+
+func (addr) Network() string { return "pipe" }
+func (addr) String() string  { return "pipe" }
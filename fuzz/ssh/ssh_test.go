@@ -0,0 +1,41 @@
+package ssh
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/sshsrc"
+)
+
+func FuzzServer(f *testing.F) {
+	for _, src := range gen.Sample("ssh/client", ".ssh", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckServer(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzClient(f *testing.F) {
+	for _, src := range gen.Sample("ssh/server", ".ssh", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckClient(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzMessage(f *testing.F) {
+	for _, src := range gen.Sample("ssh/message", ".ssh", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckMessage(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,386 @@
+// Package strconv is a fuzz target for the number and quote parsers of
+// strconv and the formatters that undo them. CheckFloat parses a float
+// at both sizes, which must be the one math/big rounds the same text to,
+// and formats it every way, each of which must parse back to it. CheckInt
+// parses an integer in every base and size, which must agree with one
+// another and with Atoi and format back to the same value. CheckQuoted
+// unquotes a literal, which must decode as UnquoteChar reads it one
+// character at a time, be what QuotedPrefix finds, and quote, with every
+// Quote function, as a literal that unquotes to the same string. Each
+// Append function must append what its Format or Quote function returns.
+package strconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// prefix is what the Append functions are given to append to.
+const prefix = "prefix:"
+
+// CheckFloat checks the floating-point number s.
+func CheckFloat(s string) error {
+	for _, bitSize := range []int{32, 64} {
+		f, err := strconv.ParseFloat(s, bitSize)
+		if err := checkNumError(err, "ParseFloat", s); err != nil {
+			return err
+		}
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			continue
+		}
+		if errors.Is(err, strconv.ErrRange) && !math.IsInf(f, 0) {
+			return fmt.Errorf("ParseFloat(%q, %d) = %v out of range", s, bitSize, f)
+		}
+		if bitSize == 32 && float64(float32(f)) != f && !math.IsNaN(f) {
+			return fmt.Errorf("ParseFloat(%q, 32) = %v, which is not a float32", s, f)
+		}
+		if err := checkRounding(s, f, bitSize); err != nil {
+			return err
+		}
+		if err := checkFormat(s, f, bitSize); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRounding checks that f, which ParseFloat read from s, is s rounded
+// to bitSize as big.Rat rounds it. Rat reads no infinities or NaNs, and
+// rejects exponents it cannot hold; those are left to ParseFloat. Nor has
+// a Rat a sign for zero, so a zero is only checked to be one.
+func checkRounding(s string, f float64, bitSize int) error {
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return nil
+	}
+	want, _ := r.Float64()
+	if bitSize == 32 {
+		w, _ := r.Float32()
+		want = float64(w)
+	}
+	if want != f {
+		return fmt.Errorf("ParseFloat(%q, %d) = %v (%#x), but the nearest float is %v (%#x)", s, bitSize, f, f, want, want)
+	}
+	return nil
+}
+
+// checkFormat formats f, which ParseFloat read from s, in every format
+// and at the shortest and full precisions, and checks that each parses
+// back to f and that the Append functions agree.
+func checkFormat(s string, f float64, bitSize int) error {
+	full := 17
+	if bitSize == 32 {
+		full = 9
+	}
+	for _, verb := range []byte{'e', 'E', 'f', 'g', 'G', 'x', 'X', 'b'} {
+		for _, prec := range []int{-1, full} {
+			out := strconv.FormatFloat(f, verb, prec, bitSize)
+			if app := string(strconv.AppendFloat([]byte(prefix), f, verb, prec, bitSize)); app != prefix+out {
+				return fmt.Errorf("%q parses as %v, which formats with %c and %d as %q, but appends as %q", s, f, verb, prec, out, app)
+			}
+			if verb == 'b' {
+				if err := checkBinary(f, out); err != nil {
+					return fmt.Errorf("%q parses as %v: %v", s, f, err)
+				}
+				continue
+			}
+			if verb != 'e' && verb != 'E' && verb != 'g' && verb != 'G' && prec != -1 {
+				continue // the precision is of fraction or hexadecimal digits
+			}
+			again, err := strconv.ParseFloat(out, bitSize)
+			if err != nil || again != f && !(math.IsNaN(again) && math.IsNaN(f)) || math.Signbit(again) != math.Signbit(f) {
+				return fmt.Errorf("%q parses as %v, which formats with %c and %d as %q, which parses as %v (%v)", s, f, verb, prec, out, again, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkBinary checks that out, the 'b' format of f, is a decimal mantissa
+// and a binary exponent whose value is f. ParseFloat reads binary
+// exponents only after a hexadecimal mantissa, but Rat reads them after
+// any.
+func checkBinary(f float64, out string) error {
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return nil
+	}
+	r, ok := new(big.Rat).SetString(out)
+	if !ok {
+		return fmt.Errorf("the 'b' format %q does not parse", out)
+	}
+	if want := new(big.Rat).SetFloat64(f); r.Cmp(want) != 0 {
+		return fmt.Errorf("the 'b' format %q is %v, not %v", out, r, want)
+	}
+	return nil
+}
+
+// bases are the bases integers are parsed in: 0, which reads a prefix,
+// and all the rest.
+var bases = func() []int {
+	b := []int{0}
+	for base := 2; base <= 36; base++ {
+		b = append(b, base)
+	}
+	return b
+}()
+
+// CheckInt checks the integer s.
+func CheckInt(s string) error {
+	i, err := strconv.Atoi(s)
+	j, errJ := strconv.ParseInt(s, 10, 0)
+	if i != int(j) || (err == nil) != (errJ == nil) {
+		return fmt.Errorf("Atoi(%q) = %d (%v), but ParseInt(%q, 10, 0) = %d (%v)", s, i, err, s, j, errJ)
+	}
+	if err := checkNumError(err, "Atoi", s); err != nil {
+		return err
+	}
+	for _, base := range bases {
+		if err := checkBase(s, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkBase checks s as an integer in base at every size.
+func checkBase(s string, base int) error {
+	wide, wideErr := strconv.ParseInt(s, base, 64)
+	uwide, uwideErr := strconv.ParseUint(s, base, 64)
+	for _, bitSize := range []int{8, 16, 32, 64} {
+		v, err := strconv.ParseInt(s, base, bitSize)
+		if err := checkNumError(err, "ParseInt", s); err != nil {
+			return err
+		}
+		lo, hi := int64(-1)<<(bitSize-1), int64(1)<<(bitSize-1)-1
+		switch {
+		case errors.Is(err, strconv.ErrRange):
+			if v != lo && v != hi {
+				return fmt.Errorf("ParseInt(%q, %d, %d) = %d out of range", s, base, bitSize, v)
+			}
+			if wideErr == nil && lo <= wide && wide <= hi {
+				return fmt.Errorf("ParseInt(%q, %d, %d) is out of range, but at 64 bits is %d", s, base, bitSize, wide)
+			}
+		case err == nil:
+			if wideErr != nil || v != wide {
+				return fmt.Errorf("ParseInt(%q, %d, %d) = %d, but at 64 bits is %d (%v)", s, base, bitSize, v, wide, wideErr)
+			}
+		default:
+			if wideErr == nil {
+				return fmt.Errorf("ParseInt(%q, %d, %d): %v, but at 64 bits is %d", s, base, bitSize, err, wide)
+			}
+		}
+
+		u, err := strconv.ParseUint(s, base, bitSize)
+		if err := checkNumError(err, "ParseUint", s); err != nil {
+			return err
+		}
+		most := uint64(1)<<bitSize - 1
+		switch {
+		case errors.Is(err, strconv.ErrRange):
+			if u != most {
+				return fmt.Errorf("ParseUint(%q, %d, %d) = %d out of range", s, base, bitSize, u)
+			}
+			if uwideErr == nil && uwide <= most {
+				return fmt.Errorf("ParseUint(%q, %d, %d) is out of range, but at 64 bits is %d", s, base, bitSize, uwide)
+			}
+		case err == nil:
+			if uwideErr != nil || u != uwide {
+				return fmt.Errorf("ParseUint(%q, %d, %d) = %d, but at 64 bits is %d (%v)", s, base, bitSize, u, uwide, uwideErr)
+			}
+		default:
+			if uwideErr == nil {
+				return fmt.Errorf("ParseUint(%q, %d, %d): %v, but at 64 bits is %d", s, base, bitSize, err, uwide)
+			}
+		}
+	}
+	// ParseInt reads a sign and then the digits as ParseUint does.
+	if s != "" && s[0] != '+' && s[0] != '-' {
+		if wideErr == nil && (uwideErr != nil || uint64(wide) != uwide) {
+			return fmt.Errorf("ParseInt(%q, %d, 64) = %d, but ParseUint is %d (%v)", s, base, wide, uwide, uwideErr)
+		}
+		if uwideErr == nil && uwide <= math.MaxInt64 && wideErr != nil {
+			return fmt.Errorf("ParseUint(%q, %d, 64) = %d, but ParseInt fails: %v", s, base, uwide, wideErr)
+		}
+	}
+	if wideErr == nil {
+		if err := checkFormatInt(s, wide); err != nil {
+			return err
+		}
+	}
+	if uwideErr == nil {
+		if err := checkFormatUint(s, uwide); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkFormatInt checks that v, read from s, formats in every base as an
+// integer that parses back to v, and appends as it formats.
+func checkFormatInt(s string, v int64) error {
+	for _, base := range bases[1:] {
+		out := strconv.FormatInt(v, base)
+		if app := string(strconv.AppendInt([]byte(prefix), v, base)); app != prefix+out {
+			return fmt.Errorf("%q parses as %d, which formats in base %d as %q, but appends as %q", s, v, base, out, app)
+		}
+		if again, err := strconv.ParseInt(out, base, 64); err != nil || again != v {
+			return fmt.Errorf("%q parses as %d, which formats in base %d as %q, which parses as %d (%v)", s, v, base, out, again, err)
+		}
+	}
+	if v == int64(int(v)) && strconv.Itoa(int(v)) != strconv.FormatInt(v, 10) {
+		return fmt.Errorf("%q parses as %d, which Itoa formats as %q", s, v, strconv.Itoa(int(v)))
+	}
+	return nil
+}
+
+// checkFormatUint is checkFormatInt for an unsigned integer.
+func checkFormatUint(s string, v uint64) error {
+	for _, base := range bases[1:] {
+		out := strconv.FormatUint(v, base)
+		if app := string(strconv.AppendUint([]byte(prefix), v, base)); app != prefix+out {
+			return fmt.Errorf("%q parses as %d, which formats in base %d as %q, but appends as %q", s, v, base, out, app)
+		}
+		if again, err := strconv.ParseUint(out, base, 64); err != nil || again != v {
+			return fmt.Errorf("%q parses as %d, which formats in base %d as %q, which parses as %d (%v)", s, v, base, out, again, err)
+		}
+	}
+	return nil
+}
+
+// checkNumError checks that err, from the parse function fn of s, if it
+// is not nil, is a *NumError naming fn, s and one of the errors strconv
+// reports.
+func checkNumError(err error, fn, s string) error {
+	if err == nil {
+		return nil
+	}
+	var ne *strconv.NumError
+	if !errors.As(err, &ne) {
+		return fmt.Errorf("%s(%q) returns %T: %v", fn, s, err, err)
+	}
+	if ne.Func != fn || ne.Num != s || ne.Err != strconv.ErrSyntax && ne.Err != strconv.ErrRange {
+		return fmt.Errorf("%s(%q) returns %#v", fn, s, *ne)
+	}
+	return nil
+}
+
+// CheckQuoted checks the quoted string s.
+func CheckQuoted(s string) error {
+	u, err := strconv.Unquote(s)
+	p, errP := strconv.QuotedPrefix(s)
+	if errP == nil {
+		if !strings.HasPrefix(s, p) {
+			return fmt.Errorf("QuotedPrefix(%q) = %q, which is not a prefix of it", s, p)
+		}
+		if _, err := strconv.Unquote(p); err != nil {
+			return fmt.Errorf("QuotedPrefix(%q) = %q, which does not unquote: %v", s, p, err)
+		}
+	}
+	if err != nil {
+		return nil
+	}
+	if errP != nil || p != s {
+		return fmt.Errorf("%q unquotes to %q, but its quoted prefix is %q (%v)", s, u, p, errP)
+	}
+	if again, err := strconv.QuotedPrefix(s + `"x'`); err != nil || again != s {
+		return fmt.Errorf("%q unquotes, but with more after it its quoted prefix is %q (%v)", s, again, err)
+	}
+	if err := checkChars(s, u); err != nil {
+		return err
+	}
+	for _, q := range []struct {
+		name   string
+		quote  func(string) string
+		append func([]byte, string) []byte
+		valid  func(rune) bool
+	}{
+		{"Quote", strconv.Quote, strconv.AppendQuote, strconv.IsPrint},
+		{"QuoteToASCII", strconv.QuoteToASCII, strconv.AppendQuoteToASCII, func(r rune) bool { return r < utf8.RuneSelf && strconv.IsPrint(r) }},
+		{"QuoteToGraphic", strconv.QuoteToGraphic, strconv.AppendQuoteToGraphic, strconv.IsGraphic},
+	} {
+		out := q.quote(u)
+		if app := string(q.append([]byte(prefix), u)); app != prefix+out {
+			return fmt.Errorf("%q unquotes to %q, which %s quotes as %q, but appends as %q", s, u, q.name, out, app)
+		}
+		if i := strings.IndexFunc(out, func(r rune) bool { return !q.valid(r) }); i >= 0 || !utf8.ValidString(out) {
+			return fmt.Errorf("%q unquotes to %q, which %s quotes as %q, with a character it should escape at %d", s, u, q.name, out, i)
+		}
+		if again, err := strconv.Unquote(out); err != nil || again != u {
+			return fmt.Errorf("%q unquotes to %q, which %s quotes as %q, which unquotes to %q (%v)", s, u, q.name, out, again, err)
+		}
+	}
+	if strconv.CanBackquote(u) {
+		if again, err := strconv.Unquote("`" + u + "`"); err != nil || again != u {
+			return fmt.Errorf("%q unquotes to %q, which can be backquoted, but unquotes backquoted to %q (%v)", s, u, again, err)
+		}
+	}
+	if s[0] == '\'' {
+		return checkRune(s)
+	}
+	return nil
+}
+
+// checkChars checks that u, which the interpreted or rune literal s
+// unquotes to, is what UnquoteChar reads from s a character at a time.
+// Known: Unquote accepts the empty rune literal, two single quotes,
+// which UnquoteChar does not read as anything.
+func checkChars(s, u string) error {
+	if s[0] == '`' || s == "''" {
+		return nil
+	}
+	var b []byte
+	rest := s[1 : len(s)-1]
+	for rest != "" {
+		r, multibyte, tail, err := strconv.UnquoteChar(rest, s[0])
+		if err != nil {
+			return fmt.Errorf("%q unquotes to %q, but UnquoteChar fails at %q: %v", s, u, rest, err)
+		}
+		if multibyte {
+			b = utf8.AppendRune(b, r)
+		} else {
+			b = append(b, byte(r))
+		}
+		rest = tail
+	}
+	if string(b) != u {
+		return fmt.Errorf("%q unquotes to %q, but UnquoteChar reads it as %q", s, u, b)
+	}
+	return nil
+}
+
+// checkRune checks that the rune literal s reads with UnquoteChar as a
+// rune that each QuoteRune function quotes as a literal that reads as
+// the same rune.
+func checkRune(s string) error {
+	if s == "''" {
+		return nil
+	}
+	r, _, _, err := strconv.UnquoteChar(s[1:len(s)-1], '\'')
+	if err != nil {
+		return nil // checkChars has reported it
+	}
+	for _, q := range []struct {
+		name   string
+		quote  func(rune) string
+		append func([]byte, rune) []byte
+	}{
+		{"QuoteRune", strconv.QuoteRune, strconv.AppendQuoteRune},
+		{"QuoteRuneToASCII", strconv.QuoteRuneToASCII, strconv.AppendQuoteRuneToASCII},
+		{"QuoteRuneToGraphic", strconv.QuoteRuneToGraphic, strconv.AppendQuoteRuneToGraphic},
+	} {
+		out := q.quote(r)
+		if app := string(q.append([]byte(prefix), r)); app != prefix+out {
+			return fmt.Errorf("%q is %U, which %s quotes as %q, but appends as %q", s, r, q.name, out, app)
+		}
+		again, _, tail, err := strconv.UnquoteChar(out[1:len(out)-1], '\'')
+		if err != nil || tail != "" || again != r && utf8.ValidRune(r) {
+			return fmt.Errorf("%q is %U, which %s quotes as %q, which reads as %U (%v)", s, r, q.name, out, again, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package strconv
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/strconvsrc"
+)
+
+func FuzzFloat(f *testing.F) {
+	for _, src := range gen.Sample("strconv/float", ".float", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckFloat(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzInt(f *testing.F) {
+	for _, src := range gen.Sample("strconv/int", ".int", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckInt(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzQuoted(f *testing.F) {
+	for _, src := range gen.Sample("strconv/quoted", ".quoted", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckQuoted(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,260 @@
+// Package tag is a fuzz target for struct tags: reflect.StructTag must
+// read every well-formed key:"value" pair the way the convention in its
+// documentation describes, and encoding/json must name, omit and quote
+// a field the way its json tag says.
+package tag
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Check parses src and checks the tag of every struct field in it.
+// Sources that do not parse are ignored.
+func Check(src []byte) error {
+	f, err := parser.ParseFile(token.NewFileSet(), "seed.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	var errs []error
+	ast.Inspect(f, func(n ast.Node) bool {
+		if field, ok := n.(*ast.Field); ok && field.Tag != nil && len(errs) == 0 {
+			tag, err := strconv.Unquote(field.Tag.Value)
+			if err == nil {
+				if err := Tag(tag); err != nil {
+					errs = append(errs, fmt.Errorf("%.80s: %v", field.Tag.Value, err))
+				}
+			}
+		}
+		return len(errs) == 0
+	})
+	if len(errs) > 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+// Tag checks reflect.StructTag and encoding/json against tag.
+func Tag(tag string) error {
+	if err := Lookup(tag); err != nil {
+		return err
+	}
+	return JSON(tag)
+}
+
+// A pair is a key and its unquoted value.
+type pair struct {
+	key, val string
+}
+
+// pairs parses the well-formed prefix of tag: space-separated pairs of a
+// key, a colon and a quoted string that strconv.Unquote accepts. A key
+// is any run of bytes other than space, quote, colon and ASCII control
+// characters; the documentation says non-control characters, but
+// reflect checks bytes, so a key may hold C1 controls. It reports
+// whether the whole tag was well formed.
+func pairs(tag string) ([]pair, bool) {
+	var ps []pair
+	for {
+		tag = strings.TrimLeft(tag, " ")
+		if tag == "" {
+			return ps, true
+		}
+		i := strings.IndexFunc(tag, func(r rune) bool {
+			return r <= ' ' || r == ':' || r == '"' || r == 0x7f
+		})
+		if i <= 0 || !strings.HasPrefix(tag[i:], `:"`) {
+			return ps, false
+		}
+		q, err := strconv.QuotedPrefix(tag[i+1:])
+		if err != nil || q[0] != '"' {
+			return ps, false
+		}
+		v, _ := strconv.Unquote(q)
+		ps = append(ps, pair{tag[:i], v})
+		tag = tag[i+1+len(q):]
+	}
+}
+
+// Lookup checks that reflect.StructTag finds the first value of every
+// key in the well-formed prefix of tag, that Get agrees with Lookup,
+// and, for a tag that is well formed throughout, that keys it does not
+// have are not found and that writing its pairs back out gives a tag
+// that reads the same.
+func Lookup(tag string) error {
+	st := reflect.StructTag(tag)
+	ps, whole := pairs(tag)
+	seen := map[string]bool{}
+	var canon []string
+	for _, p := range ps {
+		canon = append(canon, p.key+":"+strconv.Quote(p.val))
+		if seen[p.key] {
+			continue
+		}
+		seen[p.key] = true
+		v, ok := st.Lookup(p.key)
+		if !ok || v != p.val {
+			return fmt.Errorf("Lookup(%q) = %q, %t, want %q", p.key, v, ok, p.val)
+		}
+		if g := st.Get(p.key); g != v {
+			return fmt.Errorf("Get(%q) = %q, Lookup gives %q", p.key, g, v)
+		}
+	}
+	if !whole {
+		return nil
+	}
+	ct := reflect.StructTag(strings.Join(canon, " "))
+	for _, key := range []string{"json", "xml", "k", "", "absent", "jso", "json "} {
+		if seen[key] {
+			if v, _ := st.Lookup(key); ct.Get(key) != v {
+				return fmt.Errorf("rewritten tag %q gives %q for %q, not %q", ct, ct.Get(key), key, v)
+			}
+			continue
+		}
+		if v, ok := st.Lookup(key); ok {
+			return fmt.Errorf("Lookup(%q) = %q in a tag without that key", key, v)
+		}
+	}
+	return nil
+}
+
+// JSON marshals a struct whose int field has tag and checks that the
+// encoding unmarshals back. For a well-formed json tag, one with a valid
+// name or none and only the options encoding/json documents, it also
+// checks the key used, when the field is omitted and when it is quoted,
+// and that two fields the tag names are in conflict and both dropped.
+// Known: newer toolchains build encoding/json on v2, which reads
+// malformed tags differently from v1, keeping a name like "a\u2028" or
+// "a\\b" that v1 replaced with the field name, so only the round trip
+// is checked for those.
+func JSON(tag string) error {
+	typ := reflect.StructOf([]reflect.StructField{{Name: "F", Type: reflect.TypeFor[int](), Tag: reflect.StructTag(tag)}})
+	jt := reflect.StructTag(tag).Get("json")
+	name, opts, _ := strings.Cut(jt, ",")
+	tagged := validName(name)
+	if !tagged {
+		name = "F"
+	}
+	wellFormed := jt == "-" || tagged || name == ""
+	if opts != "" {
+		for o := range strings.SplitSeq(opts, ",") {
+			wellFormed = wellFormed && (o == "omitempty" || o == "omitzero" || o == "string")
+		}
+	}
+	for _, n := range []int{0, 7} {
+		v := reflect.New(typ).Elem()
+		v.Field(0).SetInt(int64(n))
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Errorf("json.Marshal: %v", err)
+		}
+		got, err := keys(b)
+		if err != nil {
+			return err
+		}
+		if len(got) > 1 {
+			return fmt.Errorf("json tag %q: one field encodes as %s", jt, b)
+		}
+		if wellFormed {
+			want := map[string]string{name: strconv.Itoa(n)}
+			switch {
+			case jt == "-":
+				want = map[string]string{}
+			case n == 0 && (hasOpt(opts, "omitempty") || hasOpt(opts, "omitzero")):
+				want = map[string]string{}
+			case hasOpt(opts, "string"):
+				want[name] = strconv.Quote(want[name])
+			}
+			if !maps.Equal(got, want) {
+				return fmt.Errorf("json tag %q: F=%d encodes as %s, want %v", jt, n, b, want)
+			}
+		}
+		back := reflect.New(typ)
+		if err := json.Unmarshal(b, back.Interface()); err != nil {
+			return fmt.Errorf("json tag %q: unmarshal %s: %v", jt, b, err)
+		}
+		if len(got) == 1 && back.Elem().Field(0).Int() != int64(n) {
+			return fmt.Errorf("json tag %q: %s unmarshals to F=%d, want %d", jt, b, back.Elem().Field(0).Int(), n)
+		}
+	}
+	if !wellFormed {
+		return nil
+	}
+
+	two := reflect.StructOf([]reflect.StructField{
+		{Name: "F", Type: reflect.TypeFor[int](), Tag: reflect.StructTag(tag)},
+		{Name: "G", Type: reflect.TypeFor[int](), Tag: reflect.StructTag(tag)},
+	})
+	b, err := json.Marshal(reflect.New(two).Elem().Interface())
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %v", err)
+	}
+	got, err := keys(b)
+	if err != nil {
+		return err
+	}
+	wantKeys := []string{"F", "G"}
+	switch {
+	case jt == "-", hasOpt(opts, "omitempty"), hasOpt(opts, "omitzero"):
+		wantKeys = nil
+	case tagged:
+		// Both fields are tagged with the same name at the same depth.
+		wantKeys = nil
+	}
+	if len(got) != len(wantKeys) {
+		return fmt.Errorf("json tag %q on two fields encodes as %s, want keys %q", jt, b, wantKeys)
+	}
+	for _, k := range wantKeys {
+		if _, ok := got[k]; !ok {
+			return fmt.Errorf("json tag %q on two fields encodes as %s, want keys %q", jt, b, wantKeys)
+		}
+	}
+	return nil
+}
+
+// validName reports whether encoding/json uses name as a key: it must be
+// non-empty and made of letters, digits and the punctuation the
+// encoding/json documentation allows.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", r) && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasOpt reports whether opt is one of the comma-separated options.
+func hasOpt(opts, opt string) bool {
+	for o := range strings.SplitSeq(opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// keys decodes a JSON object into its keys and raw values.
+func keys(b []byte) (map[string]string, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("json.Marshal wrote %s: %v", b, err)
+	}
+	out := map[string]string{}
+	for k, v := range m {
+		out[k] = string(bytes.TrimSpace(v))
+	}
+	return out, nil
+}
@@ -0,0 +1,30 @@
+package tag
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+func FuzzTags(f *testing.F) {
+	for _, src := range gen.Sample("go/tags", ".go", 8) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzTag(f *testing.F) {
+	for _, tag := range []string{`json:"a,omitempty" xml:"b"`, `json:"-,"`, `k:"\xff" k:"x"`, `json:"a b,string"`} {
+		f.Add(tag)
+	}
+	f.Fuzz(func(t *testing.T, tag string) {
+		if err := Tag(tag); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
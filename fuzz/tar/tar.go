@@ -0,0 +1,219 @@
+// Package tar is a fuzz target for archive/tar. CheckReader reads the
+// headers of a stream and the contents of its entries as a careful
+// extractor would: all entries together may give no more than a fixed
+// number of bytes, so that a sparse file of terabytes of holes or a
+// size far past the end is read only that far, and reading must take
+// time and memory within a budget linear in the size of the stream,
+// past which it is reported as a blowup. An entry read to its end must
+// have the size its header gives; a Reader that cannot seek past the
+// entries it skips must read the same headers as one that can; and the
+// entries read must write a stream that reads back the same.
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what reading a stream may cost, per byte of the stream.
+// Read is how many bytes reading its entries' contents may give, all
+// entries together; the bytes read are allocated, and Base must allow
+// for them.
+type Budget struct {
+	harness.Budget
+	Read int64
+}
+
+// DefaultBudget reads up to 16 MiB, which one sparse header gives, and
+// allows for the 1 MiB package tar reads of a PAX header or GNU long
+// name.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	Read: 16 << 20,
+}
+
+// An entry is a header, and what its entry held if it was read to its
+// end, or the error reading it.
+type entry struct {
+	h       *tar.Header
+	content []byte
+	whole   bool
+	err     error
+}
+
+// CheckReader reads the stream in data within b. Errors reading are
+// expected and ignored.
+func CheckReader(data []byte, b Budget) error {
+	var entries []entry
+	var end error
+	err := harness.Measure(fmt.Sprintf("reading %d bytes", len(data)), b.For(len(data)), func() {
+		entries, end = read(bytes.NewReader(data), b.Read)
+	})
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := checkEntry(e); err != nil {
+			return err
+		}
+	}
+	if err := checkSkip(data, entries, end); err != nil {
+		return err
+	}
+	return rewrite(entries)
+}
+
+// read reads the headers of the stream r and the contents of its
+// entries, until they have given limit bytes. It returns the entries
+// and the error that ended the stream, io.EOF at its end.
+func read(r io.Reader, limit int64) ([]entry, error) {
+	tr := tar.NewReader(r)
+	left := limit
+	var entries []entry
+	for {
+		h, err := tr.Next()
+		if err != nil {
+			return entries, err
+		}
+		e := entry{h: h}
+		if left > 0 {
+			b, err := io.ReadAll(io.LimitReader(tr, left+1))
+			if int64(len(b)) > left {
+				left = 0
+			} else {
+				left -= int64(len(b))
+				e.content, e.whole, e.err = b, err == nil, err
+			}
+		}
+		entries = append(entries, e)
+	}
+}
+
+// checkEntry checks that an entry read to its end held as many bytes as
+// its header says, or none where the header's type has no data.
+func checkEntry(e entry) error {
+	if !e.whole {
+		return nil
+	}
+	want := e.h.Size
+	switch e.h.Typeflag {
+	case tar.TypeLink, tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeDir, tar.TypeFifo:
+		want = 0
+	}
+	if int64(len(e.content)) != want {
+		return fmt.Errorf("%q: type %q, size %d, but read %d bytes", e.h.Name, e.h.Typeflag, e.h.Size, len(e.content))
+	}
+	return nil
+}
+
+// checkSkip reads the headers of data again, through a reader that
+// cannot seek, without reading any entry's contents, and checks that
+// they and the error that ends the stream are those entries and end
+// have. An error reading an entry ends the stream for the Reader that
+// read it, where one that skips it goes on.
+func checkSkip(data []byte, entries []entry, end error) error {
+	if n := len(entries); n > 0 && entries[n-1].err != nil {
+		end = nil
+	}
+	tr := tar.NewReader(struct{ io.Reader }{bytes.NewReader(data)})
+	for i := 0; ; i++ {
+		if i == len(entries) && end == nil {
+			return nil
+		}
+		h, err := tr.Next()
+		if err != nil {
+			if i < len(entries) || (err == io.EOF) != (end == io.EOF) {
+				return fmt.Errorf("skipping entries, the stream ends after %d headers with %v; reading them, after %d with %v", i, err, len(entries), end)
+			}
+			return nil
+		}
+		if i >= len(entries) {
+			return fmt.Errorf("skipping entries, the stream has more than the %d headers it has reading them, which ends with %v:\n%+v", len(entries), end, h)
+		}
+		if !reflect.DeepEqual(h, entries[i].h) {
+			return fmt.Errorf("header %d reads as\n%+v\nskipping entries, but as\n%+v\nreading them", i, h, entries[i].h)
+		}
+	}
+}
+
+// rewrite writes the entries read to their end as a new stream, which
+// must read back as the same entries.
+func rewrite(entries []entry) error {
+	var b bytes.Buffer
+	w := tar.NewWriter(&b)
+	var written []entry
+	for _, e := range entries {
+		if !e.whole {
+			continue
+		}
+		// As Header's documentation asks of a header from Next that is
+		// to be written, only the fields kept are copied, not the
+		// PAX records and format the Reader found.
+		h := &tar.Header{
+			Typeflag: e.h.Typeflag, Name: e.h.Name, Linkname: e.h.Linkname, Size: e.h.Size, Mode: e.h.Mode,
+			Uid: e.h.Uid, Gid: e.h.Gid, Uname: e.h.Uname, Gname: e.h.Gname, ModTime: e.h.ModTime,
+			Devmajor: e.h.Devmajor, Devminor: e.h.Devminor,
+		}
+		switch h.Typeflag {
+		case tar.TypeGNUSparse:
+			// Writer does not write sparse files, but one reads as the
+			// regular file it is with its holes filled in.
+			h.Typeflag = tar.TypeReg
+		case tar.TypeXGlobalHeader:
+			continue
+		}
+		// Known: Reader takes a name that ends in a slash for a
+		// directory only where the type flag is the old NUL, and reads
+		// a regular file or device of that name, which Writer refuses.
+		if strings.HasSuffix(h.Name, "/") && h.Typeflag != tar.TypeDir && h.Typeflag != tar.TypeLink && h.Typeflag != tar.TypeSymlink {
+			continue
+		}
+		// Known: Reader checks a PAX path record for NULs, but not a
+		// GNU.sparse.name record, which names the file in its place.
+		if strings.Contains(h.Name, "\x00") {
+			continue
+		}
+		if err := w.WriteHeader(h); err != nil {
+			return fmt.Errorf("%q: a header read does not write: %v\n%+v", e.h.Name, err, e.h)
+		}
+		if _, err := w.Write(e.content); err != nil {
+			return fmt.Errorf("%q: an entry read does not write: %v", e.h.Name, err)
+		}
+		written = append(written, entry{h: h, content: e.content})
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("the entries read do not write: %v", err)
+	}
+	again, end := read(&b, 1<<62)
+	if end != io.EOF || len(again) != len(written) {
+		return fmt.Errorf("%d entries written read back as %d, ending with %v", len(written), len(again), end)
+	}
+	for i, a := range again {
+		e := written[i]
+		if !same(a.h, e.h) {
+			return fmt.Errorf("header\n%+v\nreads back as\n%+v", e.h, a.h)
+		}
+		if !bytes.Equal(a.content, e.content) {
+			return fmt.Errorf("%q: written as %q, but reads back as %q", e.h.Name, e.content, a.content)
+		}
+	}
+	return nil
+}
+
+// same reports whether h and g describe the same entry, in the fields
+// every format that can hold h writes.
+func same(h, g *tar.Header) bool {
+	return h.Typeflag == g.Typeflag && h.Name == g.Name && h.Linkname == g.Linkname && h.Size == g.Size &&
+		h.Mode == g.Mode && h.Uid == g.Uid && h.Gid == g.Gid && h.Uname == g.Uname && h.Gname == g.Gname &&
+		h.ModTime.Round(time.Second).Equal(g.ModTime.Round(time.Second)) && h.Devmajor == g.Devmajor && h.Devminor == g.Devminor
+}
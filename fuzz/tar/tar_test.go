@@ -0,0 +1,19 @@
+package tar
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/tarsrc"
+)
+
+func FuzzReader(f *testing.F) {
+	for _, src := range gen.Sample("tar/archive", ".tar", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckReader(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,317 @@
+// Package template is a fuzz target for text/template and html/template.
+// CheckText parses a template, checks that its parse trees print to
+// templates that parse back to the same trees, and executes it twice,
+// which must give the same result. CheckHTML parses and executes a
+// template with html/template, whose contextual escaping must keep the
+// data's markup from reaching the output unescaped.
+package template
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Inject is the tag the data's strings carry. html/template must never
+// let it through as markup.
+const Inject = "<x-inj"
+
+// Data is what templates are executed with. Its strings try to break out
+// of the context they are put in.
+type Data struct {
+	Name, URL, CSS, JS string
+
+	N int
+	F float64
+	B bool
+
+	Items  []string
+	M      map[string]string
+	Any    any
+	Nested *Data
+	Nil    *Data
+	Fn     func(int) int
+}
+
+// Method returns s followed by d's name.
+func (d *Data) Method(s string) string { return s + d.Name }
+
+// Err always fails.
+func (d *Data) Err() (string, error) { return "", errors.New("Err called") }
+
+// NewData returns the data templates are executed with: a Data whose
+// .Nested goes two levels deep.
+func NewData() *Data {
+	d := data()
+	d.Nested = data()
+	d.Nested.Nested = data()
+	return d
+}
+
+func data() *Data {
+	return &Data{
+		Name:  Inject + ` a="b" c='d'>&amp;</x-inj>`,
+		URL:   "javascript:alert(1)//" + Inject + ">",
+		CSS:   "expression(alert(1))</style>" + Inject + ">",
+		JS:    `"; alert(1); //</script>` + Inject + ">",
+		N:     3,
+		F:     1.5,
+		B:     true,
+		Items: []string{"a", Inject + ">", `"'` + "`", "</script>"},
+		M:     map[string]string{"k": Inject + ">", "x-y": "v"},
+		Any:   []int{1, 2},
+		Fn:    func(n int) int { return n * 2 },
+	}
+}
+
+// Funcs are the functions templates may call besides the builtins.
+var Funcs = map[string]any{
+	"upper": strings.ToUpper,
+	"join":  strings.Join,
+	"fail":  func() (string, error) { return "", errors.New("fail called") },
+	"boom":  func() string { panic("boom called") },
+}
+
+// Timeout bounds the execution of one template, once Cost has let it
+// run.
+var Timeout = 5 * time.Second
+
+// maxOutput bounds what a template may write; writing past it fails the
+// execution.
+const maxOutput = 1 << 20
+
+// CheckText checks src as a text/template. Sources that do not parse are
+// ignored.
+func CheckText(src []byte) error {
+	t, err := template.New("page").Funcs(Funcs).Parse(string(src))
+	if err != nil {
+		return nil
+	}
+	for _, tt := range t.Templates() {
+		if tt.Tree == nil {
+			continue
+		}
+		if err := reparse(tt.Name(), tt.Tree); err != nil {
+			return err
+		}
+	}
+	if Cost(textTrees(t.Templates())) > maxCost {
+		return nil
+	}
+	return harness.Run(Timeout, func() error {
+		// The same data both times, since printing it prints pointers.
+		d := NewData()
+		once, err1 := execute(t, d)
+		twice, err2 := execute(t, d)
+		if !bytes.Equal(once, twice) || fmt.Sprint(err1) != fmt.Sprint(err2) {
+			return fmt.Errorf("executing twice gave different results:\n--- once (%v)\n%s\n--- twice (%v)\n%s", err1, once, err2, twice)
+		}
+		return nil
+	})
+}
+
+// reparse checks that the tree of a template prints to a template that
+// parses to a tree printing the same.
+func reparse(name string, tree *parse.Tree) error {
+	printed := tree.Root.String()
+	if strings.Contains(printed, "{{{") {
+		// Known: text ending in "{" that a trim marker joined to the
+		// next action, as in "{ {{- x}}", prints as "{{{x}}", which
+		// does not parse. The printer cannot write trim markers back.
+		return nil
+	}
+	t, err := template.New(name).Funcs(Funcs).Parse(printed)
+	if err != nil {
+		return fmt.Errorf("template %q prints to one that does not parse: %v\n--- printed\n%s", name, err, printed)
+	}
+	again := ""
+	if t.Tree != nil {
+		again = t.Tree.Root.String()
+	}
+	if again != printed {
+		return fmt.Errorf("template %q prints differently after a round trip:\n--- printed\n%s\n--- reprinted\n%s", name, printed, again)
+	}
+	return nil
+}
+
+// CheckHTML checks src as an html/template: executing it must not put
+// the data's Inject tag into the output, and executing it again must give
+// the same result. Escaping errors are expected and ignored, and so are
+// sources that do not parse.
+func CheckHTML(src []byte) error {
+	t, err := htmltemplate.New("page").Funcs(Funcs).Parse(string(src))
+	if err != nil {
+		return nil
+	}
+	if Cost(htmlTrees(t.Templates())) > maxCost {
+		return nil
+	}
+	// The template can build the tag itself out of its own text or a
+	// slice of the data's.
+	lower := strings.ToLower(string(src))
+	check := !strings.Contains(lower, "x-inj") && !strings.Contains(lower, "slice")
+	return harness.Run(Timeout, func() error {
+		// The same data both times, since printing it prints pointers.
+		d := NewData()
+		once, err1 := execute(t, d)
+		if check && bytes.Contains(bytes.ToLower(once), []byte(Inject)) {
+			return fmt.Errorf("data reached the output unescaped:\n%s", once)
+		}
+		twice, err2 := execute(t, d)
+		if !bytes.Equal(once, twice) || fmt.Sprint(err1) != fmt.Sprint(err2) {
+			return fmt.Errorf("executing twice gave different results:\n--- once (%v)\n%s\n--- twice (%v)\n%s", err1, once, err2, twice)
+		}
+		return nil
+	})
+}
+
+type executer interface {
+	Execute(w io.Writer, data any) error
+}
+
+// execute runs t on d and returns what it wrote.
+func execute(t executer, d *Data) ([]byte, error) {
+	var w limitWriter
+	err := t.Execute(&w, d)
+	return w.buf.Bytes(), err
+}
+
+// A limitWriter fails writes past maxOutput.
+type limitWriter struct {
+	buf bytes.Buffer
+}
+
+var errTooLong = errors.New("output too long")
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.buf.Len()+len(p) > maxOutput {
+		return 0, errTooLong
+	}
+	return w.buf.Write(p)
+}
+
+// textTrees returns the parse trees of ts, keyed by template name.
+func textTrees(ts []*template.Template) map[string]*parse.Tree {
+	m := map[string]*parse.Tree{}
+	for _, t := range ts {
+		if t.Tree != nil {
+			m[t.Name()] = t.Tree
+		}
+	}
+	return m
+}
+
+// htmlTrees is textTrees for html/template.
+func htmlTrees(ts []*htmltemplate.Template) map[string]*parse.Tree {
+	m := map[string]*parse.Tree{}
+	for _, t := range ts {
+		if t.Tree != nil {
+			m[t.Name()] = t.Tree
+		}
+	}
+	return m
+}
+
+// maxCost is the largest Cost of a template the checks execute.
+const maxCost = 100_000
+
+// Cost estimates how many actions executing the template named "page"
+// among trees runs: a range multiplies the cost of its body by the
+// number it ranges over, if that is a constant, and by 64 otherwise,
+// which is more than any collection in Data holds; a call of another
+// template adds the cost of that template. A template calling itself
+// goes on until the executor's depth limit, so the first such call costs
+// half of maxCost, and recursion that multiplies, by being in a range or
+// by a second call, is infinitely expensive.
+func Cost(trees map[string]*parse.Tree) float64 {
+	c := coster{trees: trees, stack: []frame{{"page", 1}}}
+	if t := trees["page"]; t != nil {
+		return c.list(t.Root, 1)
+	}
+	return 0
+}
+
+type coster struct {
+	trees     map[string]*parse.Tree
+	stack     []frame
+	recursive bool
+}
+
+// A frame is a template being costed, with the multiplier it was called
+// under.
+type frame struct {
+	name string
+	mult float64
+}
+
+func (c *coster) list(l *parse.ListNode, mult float64) float64 {
+	if l == nil {
+		return 0
+	}
+	sum := 0.0
+	for _, n := range l.Nodes {
+		sum += c.node(n, mult)
+		if sum > maxCost {
+			// Stop early: expanding calls to templates that call
+			// others twice each is exponential.
+			return math.Inf(1)
+		}
+	}
+	return sum
+}
+
+func (c *coster) node(n parse.Node, mult float64) float64 {
+	switch n := n.(type) {
+	case *parse.IfNode:
+		return mult + c.list(n.List, mult) + c.list(n.ElseList, mult)
+	case *parse.WithNode:
+		return mult + c.list(n.List, mult) + c.list(n.ElseList, mult)
+	case *parse.RangeNode:
+		return mult + c.list(n.List, mult*iterations(n.Pipe)) + c.list(n.ElseList, mult)
+	case *parse.TemplateNode:
+		if i := slices.IndexFunc(c.stack, func(f frame) bool { return f.name == n.Name }); i >= 0 {
+			if c.recursive || mult > c.stack[i].mult {
+				return math.Inf(1)
+			}
+			c.recursive = true
+			return maxCost / 2
+		}
+		t := c.trees[n.Name]
+		if t == nil {
+			return mult
+		}
+		c.stack = append(c.stack, frame{n.Name, mult})
+		sum := c.list(t.Root, mult)
+		c.stack = c.stack[:len(c.stack)-1]
+		return mult + sum
+	}
+	return mult
+}
+
+// iterations returns how many times a range over pipe runs its body, at
+// most.
+func iterations(pipe *parse.PipeNode) float64 {
+	if len(pipe.Cmds) == 1 && len(pipe.Cmds[0].Args) == 1 {
+		if n, ok := pipe.Cmds[0].Args[0].(*parse.NumberNode); ok {
+			switch {
+			case n.IsInt:
+				return max(float64(n.Int64), 1)
+			case n.IsUint:
+				return max(float64(n.Uint64), 1)
+			case n.IsFloat:
+				return max(n.Float64, 1)
+			}
+		}
+	}
+	return 64
+}
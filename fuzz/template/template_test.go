@@ -0,0 +1,30 @@
+package template
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/tmplsrc"
+)
+
+func FuzzText(f *testing.F) {
+	for _, src := range gen.Sample("tmpl/text", ".tmpl", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := CheckText(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzHTML(f *testing.F) {
+	for _, src := range gen.Sample("tmpl/html", ".html", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := CheckHTML(src); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+// Package textproto is a fuzz target for net/textproto's MIME header
+// parsing, on which net/http's rests. CheckHeader reads a start line and
+// the header after it; every key read must be canonical, and the header
+// written back one line per value must read back the same.
+package textproto
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"net/textproto"
+	"reflect"
+	"slices"
+)
+
+// CheckHeader checks the header read from data after its first line.
+func CheckHeader(data []byte) error {
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+	if _, err := r.ReadLine(); err != nil {
+		return nil
+	}
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		return nil
+	}
+	for k, vs := range h {
+		if c := textproto.CanonicalMIMEHeaderKey(k); c != k {
+			return fmt.Errorf("ReadMIMEHeader gives key %q, not %q", k, c)
+		}
+		// Known: a folded value whose last line is blank ends in the
+		// space the folding became.
+		for i, v := range vs {
+			vs[i] = textproto.TrimString(v)
+		}
+	}
+
+	var out bytes.Buffer
+	for _, k := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[k] {
+			fmt.Fprintf(&out, "%s: %s\r\n", k, v)
+		}
+	}
+	out.WriteString("\r\n")
+	again, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(out.Bytes()))).ReadMIMEHeader()
+	if err != nil {
+		return fmt.Errorf("a header read and written does not read back: %v\n%q", err, out.Bytes())
+	}
+	if len(h) == 0 && len(again) == 0 {
+		return nil
+	}
+	if !reflect.DeepEqual(h, again) {
+		return fmt.Errorf("a header read and written reads back differently:\n%q\nnot\n%q\n%q", again, h, out.Bytes())
+	}
+	return nil
+}
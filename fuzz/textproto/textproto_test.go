@@ -0,0 +1,19 @@
+package textproto
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/httpsrc"
+)
+
+func FuzzReadMIMEHeader(f *testing.F) {
+	for _, src := range gen.Sample("http/*", ".http", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckHeader(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,295 @@
+// Package time is a fuzz target for package time's parsers. CheckParse
+// parses a value with a layout: a time that parses must format with
+// the layout as a value that parses to a time formatting the same, and
+// must marshal and unmarshal as text to the same instant. CheckDuration
+// parses a duration string, which must be accepted exactly when its
+// value, worked out here with exact arithmetic, fits in a Duration,
+// must be within a nanosecond a component of that value, and must print
+// as a string that parses to the same Duration.
+package time
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// CheckParse checks value parsed with layout. It parses in UTC, not the
+// local zone Parse takes zone abbreviations from, so that a check does
+// not depend on where it runs.
+func CheckParse(layout, value string) error {
+	t, err := time.ParseInLocation(layout, value, time.UTC)
+	if err != nil {
+		return nil
+	}
+	formatted := t.Format(layout)
+	if b := t.AppendFormat([]byte("x"), layout); string(b[1:]) != formatted {
+		return fmt.Errorf("%q, %q: AppendFormat gives %q, Format %q", layout, value, b[1:], formatted)
+	}
+	// Known: a number Format writes in one or two digits, or in up to
+	// three for the day of the year, reads greedily, so that where the
+	// next element starts with a digit it may read more of it than it
+	// wrote; a layout with two zones formats each from a different
+	// part of the time's zone; and one that writes a field twice, such
+	// as two fractions of different widths or "2006 06", writes it
+	// differently each time, but Parse keeps only the last. Format
+	// writes no more than nine digits of a fraction, which fails to
+	// read back where the layout has more zeros, and midnight on a
+	// 12-hour clock as 12, which without AM reads back as noon.
+	if ambiguous(layout) {
+		return nil
+	}
+	again, err := time.ParseInLocation(layout, formatted, time.UTC)
+	if err != nil {
+		return fmt.Errorf("%q, %q: %v formats as %q, which does not parse: %v", layout, value, t, formatted, err)
+	}
+	if s := again.Format(layout); s != formatted {
+		return fmt.Errorf("%q, %q: %v formats as %q, which parses as %v, formatting as %q", layout, value, t, formatted, again, s)
+	}
+
+	// MarshalText refuses years and zone offsets RFC 3339 cannot
+	// write.
+	if y := t.Year(); y < 0 || y > 9999 {
+		return nil
+	}
+	_, offset := t.Zone()
+	if offset <= -24*3600 || offset >= 24*3600 {
+		return nil
+	}
+	// Known: MarshalText writes an offset in whole minutes, dropping
+	// the seconds of one like +05:45:30 without an error, so that the
+	// text unmarshals as another instant.
+	if offset%60 != 0 {
+		return nil
+	}
+	text, err := t.MarshalText()
+	if err != nil {
+		return fmt.Errorf("%q, %q: %v does not marshal: %v", layout, value, t, err)
+	}
+	var u time.Time
+	if err := u.UnmarshalText(text); err != nil {
+		return fmt.Errorf("%q, %q: %v marshals as %q, which does not unmarshal: %v", layout, value, t, text, err)
+	}
+	if !u.Equal(t) {
+		return fmt.Errorf("%q, %q: %v marshals as %q, which unmarshals as %v", layout, value, t, text, u)
+	}
+	return nil
+}
+
+// Layout elements, as nextElem returns them.
+var (
+	// unpadded are the numbers Format writes in as few digits as they
+	// take.
+	unpadded = map[string]bool{"1": true, "2": true, "_2": true, "__2": true, "3": true, "4": true, "5": true}
+	// numbers are the elements Format writes as numbers, or as a
+	// number after a space.
+	numbers = map[string]bool{
+		"01": true, "02": true, "03": true, "04": true, "05": true, "06": true, "002": true,
+		"15": true, "2006": true, "1": true, "2": true, "_2": true, "__2": true, "3": true, "4": true, "5": true,
+	}
+	zones = map[string]bool{
+		"MST": true, "-070000": true, "-07:00:00": true, "-0700": true, "-07:00": true, "-07": true,
+		"Z070000": true, "Z07:00:00": true, "Z0700": true, "Z07:00": true, "Z07": true,
+	}
+	// fields are the parts of a time the other elements write.
+	fields = map[string]string{
+		"2006": "year", "06": "year",
+		"January": "month", "Jan": "month", "1": "month", "01": "month",
+		"Monday": "weekday", "Mon": "weekday",
+		"2": "day", "_2": "day", "02": "day",
+		"__2": "yday", "002": "yday",
+		"15": "hour", "3": "hour", "03": "hour",
+		"4": "minute", "04": "minute",
+		"5": "second", "05": "second",
+		"PM": "ampm", "pm": "ampm",
+	}
+)
+
+// field returns the part of a time elem writes, or "" if it is not an
+// element.
+func field(elem string) string {
+	switch {
+	case zones[elem]:
+		return "zone"
+	case elem != "" && (elem[0] == '.' || elem[0] == ','):
+		return "fraction"
+	}
+	return fields[elem]
+}
+
+// ambiguous reports whether layout writes some times as values that
+// do not read back as the same time: where an unpadded number is
+// followed by a digit, where a field is written twice, where a fraction
+// has more than nine zeros, or where a 12-hour clock has no AM or PM.
+func ambiguous(layout string) bool {
+	unpaddedBefore := false
+	seen := map[string]bool{}
+	clock12, ampm := false, false
+	for layout != "" {
+		prefix, elem, suffix := nextElem(layout)
+		if unpaddedBefore && (prefix != "" && isDigit(prefix[0]) || prefix == "" && numbers[elem]) {
+			return true
+		}
+		unpaddedBefore = unpadded[elem]
+		if f := field(elem); f != "" {
+			if seen[f] {
+				return true
+			}
+			seen[f] = true
+		}
+		if len(elem) > 10 && elem[1] == '0' {
+			return true
+		}
+		clock12 = clock12 || elem == "3" || elem == "03"
+		ampm = ampm || elem == "PM" || elem == "pm"
+		layout = suffix
+	}
+	return clock12 && !ampm
+}
+
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
+
+// nextElem splits layout around its first element, as package time
+// does.
+func nextElem(layout string) (prefix, elem, suffix string) {
+	for i := 0; i < len(layout); i++ {
+		rest := layout[i:]
+		for _, e := range []string{
+			"January", "Monday", "MST", "2006", "002", "__2", "_2", "15",
+			"-070000", "-07:00:00", "-0700", "-07:00", "-07",
+			"Z070000", "Z07:00:00", "Z0700", "Z07:00", "Z07", "PM", "pm",
+		} {
+			if strings.HasPrefix(rest, e) {
+				if e == "_2" && strings.HasPrefix(rest, "_2006") {
+					return layout[:i+1], "2006", layout[i+5:]
+				}
+				return layout[:i], e, layout[i+len(e):]
+			}
+		}
+		for _, e := range []string{"Jan", "Mon"} {
+			if strings.HasPrefix(rest, e) && (len(rest) == 3 || rest[3] < 'a' || rest[3] > 'z') {
+				return layout[:i], e, layout[i+3:]
+			}
+		}
+		switch c := rest[0]; {
+		case c == '0' && len(rest) >= 2 && '1' <= rest[1] && rest[1] <= '6':
+			return layout[:i], rest[:2], rest[2:]
+		case c >= '1' && c <= '5':
+			return layout[:i], rest[:1], rest[1:]
+		case (c == '.' || c == ',') && len(rest) >= 2 && (rest[1] == '0' || rest[1] == '9'):
+			j := 1
+			for j < len(rest) && rest[j] == rest[1] {
+				j++
+			}
+			if j == len(rest) || !isDigit(rest[j]) {
+				return layout[:i], rest[:j], rest[j:]
+			}
+		}
+	}
+	return layout, "", ""
+}
+
+// units are ParseDuration's units, in nanoseconds.
+var units = map[string]int64{
+	"ns": 1,
+	"us": 1e3,
+	"µs": 1e3, // U+00B5, the micro sign
+	"μs": 1e3, // U+03BC, the Greek letter mu
+	"ms": 1e6,
+	"s":  1e9,
+	"m":  60e9,
+	"h":  3600e9,
+}
+
+// CheckDuration checks the duration string s.
+func CheckDuration(s string) error {
+	d, err := time.ParseDuration(s)
+	exact, n, ok := duration(s)
+	switch {
+	case !ok && err == nil:
+		return fmt.Errorf("ParseDuration(%q) = %v, but it is not a duration", s, d)
+	case !ok:
+		return nil
+	}
+	// ParseDuration works out fractions in floating point and
+	// truncates each, so it may fall short of the exact value, by up to
+	// a nanosecond a component, and be accepted or not near the limits.
+	lo, hi := big.NewInt(math.MinInt64), big.NewInt(math.MaxInt64)
+	slack := big.NewInt(int64(n))
+	switch {
+	case exact.Cmp(new(big.Int).Sub(lo, slack)) < 0 || exact.Cmp(new(big.Int).Add(hi, slack)) > 0:
+		if err == nil {
+			return fmt.Errorf("ParseDuration(%q) = %v, but %v ns overflows", s, d, exact)
+		}
+		return nil
+	case err != nil:
+		if exact.Cmp(new(big.Int).Add(lo, slack)) >= 0 && exact.Cmp(new(big.Int).Sub(hi, slack)) <= 0 {
+			return fmt.Errorf("ParseDuration(%q) fails (%v), but it is %v ns", s, err, exact)
+		}
+		return nil
+	}
+	if diff := new(big.Int).Sub(exact, big.NewInt(int64(d))); diff.CmpAbs(slack) > 0 {
+		return fmt.Errorf("ParseDuration(%q) = %d ns, but it is %v ns", s, int64(d), exact)
+	}
+
+	printed := d.String()
+	again, err := time.ParseDuration(printed)
+	if err != nil {
+		return fmt.Errorf("ParseDuration(%q) = %d ns, which prints as %q, which does not parse: %v", s, int64(d), printed, err)
+	}
+	if again != d {
+		return fmt.Errorf("ParseDuration(%q) = %d ns, which prints as %q, which parses as %d ns", s, int64(d), printed, int64(again))
+	}
+	return nil
+}
+
+// duration returns the value of the duration string s in nanoseconds,
+// truncated toward zero, and its number of components. It reports
+// whether s is a duration string at all: an optional sign and either
+// "0" or a sequence of decimal numbers, each with an optional fraction
+// and a unit.
+func duration(s string) (*big.Int, int, bool) {
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	if s == "0" {
+		return new(big.Int), 1, true
+	}
+	if s == "" {
+		return nil, 0, false
+	}
+	sum := new(big.Rat)
+	n := 0
+	for s != "" {
+		i := strings.IndexFunc(s, func(r rune) bool { return (r < '0' || r > '9') && r != '.' })
+		if i < 0 {
+			return nil, 0, false // a number without a unit
+		}
+		num := s[:i]
+		s = s[i:]
+		j := strings.IndexFunc(s, func(r rune) bool { return r == '.' || r >= '0' && r <= '9' })
+		if j < 0 {
+			j = len(s)
+		}
+		unit, ok := units[s[:j]]
+		s = s[j:]
+		whole, frac, _ := strings.Cut(num, ".")
+		if !ok || whole == "" && frac == "" || strings.Contains(frac, ".") {
+			return nil, 0, false
+		}
+		r, ok := new(big.Rat).SetString("0" + whole + "." + frac + "0")
+		if !ok {
+			return nil, 0, false
+		}
+		sum.Add(sum, r.Mul(r, new(big.Rat).SetInt64(unit)))
+		n++
+	}
+	if neg {
+		sum.Neg(sum)
+	}
+	return new(big.Int).Quo(sum.Num(), sum.Denom()), n, true
+}
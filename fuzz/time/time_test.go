@@ -0,0 +1,40 @@
+package time
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/timesrc"
+)
+
+func FuzzParse(f *testing.F) {
+	g := gen.Lookup("time/parse")
+	for i := range 64 {
+		var layout, value string
+		for _, file := range g.Generate(i) {
+			switch file.Name {
+			case "layout.txt":
+				layout = string(file.Data)
+			case "value.txt":
+				value = string(file.Data)
+			}
+		}
+		f.Add(layout, value)
+	}
+	f.Fuzz(func(t *testing.T, layout, value string) {
+		if err := CheckParse(layout, value); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzDuration(f *testing.F) {
+	for _, src := range gen.Sample("time/duration", ".dur", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckDuration(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
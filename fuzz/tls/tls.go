@@ -0,0 +1,154 @@
+// Package tls is a fuzz target for crypto/tls. CheckServer runs a
+// server's handshake against data as what a client sent, and CheckClient
+// a client's against data as what a server sent, over a connection that
+// reads the data and nothing more; no network is involved. Neither
+// handshake may succeed, since the data cannot answer what the harness
+// sends, and what the harness sends, alerts included, must be well-formed
+// records.
+package tls
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Timeout bounds one handshake.
+var Timeout = 10 * time.Second
+
+// now is the time both sides see, inside the certificate's validity.
+var now = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// certificate is the server's: a P-256 key, which ECDHE_ECDSA suites of
+// every version can use, in a certificate it signs itself. The key is
+// made afresh in each process, which changes only the signatures the
+// server sends.
+var certificate = func() tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		panic(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}()
+
+// zeros is a source of randomness that is all zeros, so that what the
+// harness sends depends only on what it reads.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// CheckServer checks a server's handshake reading data from a client.
+func CheckServer(data []byte) error {
+	c := newConn(data)
+	return check(c, tls.Server(c, &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		Rand:         zeros{},
+		Time:         func() time.Time { return now },
+		MinVersion:   tls.VersionTLS10,
+		NextProtos:   []string{"h2", "http/1.1"},
+		ClientAuth:   tls.RequestClientCert,
+	}))
+}
+
+// CheckClient checks a client's handshake reading data from a server.
+// The client skips verification, so that a server's certificate, which
+// the data cannot have signed, gets it as far as the key exchange.
+func CheckClient(data []byte) error {
+	c := newConn(data)
+	return check(c, tls.Client(c, &tls.Config{
+		ServerName:         "example.com",
+		InsecureSkipVerify: true,
+		Rand:               zeros{},
+		Time:               func() time.Time { return now },
+		MinVersion:         tls.VersionTLS10,
+		NextProtos:         []string{"h2", "http/1.1"},
+	}))
+}
+
+func check(c *conn, tc *tls.Conn) error {
+	err := harness.Run(Timeout, func() error {
+		return tc.Handshake()
+	})
+	var f *harness.Failure
+	if errors.As(err, &f) {
+		return err
+	}
+	if err == nil {
+		return fmt.Errorf("the handshake succeeded reading %d bytes and no more", c.in.Size())
+	}
+	return records(c.out.Bytes())
+}
+
+// records checks that out is a sequence of whole records of a known
+// type, a version of TLS 1.0 to 1.2, as the record layer's is even in
+// TLS 1.3, and no longer than an encrypted record may be.
+func records(out []byte) error {
+	for off := 0; off < len(out); {
+		if len(out)-off < 5 {
+			return fmt.Errorf("the harness wrote %d bytes of a record header at %d", len(out)-off, off)
+		}
+		typ, version, n := out[off], int(out[off+1])<<8|int(out[off+2]), int(out[off+3])<<8|int(out[off+4])
+		switch {
+		case typ < 20 || typ > 23:
+			return fmt.Errorf("the harness wrote a record of type %d at %d", typ, off)
+		case version < tls.VersionTLS10 || version > tls.VersionTLS12:
+			return fmt.Errorf("the harness wrote a record of version %#04x at %d", version, off)
+		case n > 16384+2048:
+			return fmt.Errorf("the harness wrote a record of %d bytes at %d", n, off)
+		case off+5+n > len(out):
+			return fmt.Errorf("the harness wrote %d bytes of a record of %d at %d", len(out)-off-5, n, off)
+		}
+		off += 5 + n
+	}
+	return nil
+}
+
+// A conn is a net.Conn that reads from in until it runs out, collects
+// what is written in out and never times out.
+type conn struct {
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func newConn(data []byte) *conn {
+	return &conn{in: bytes.NewReader(data)}
+}
+
+func (c *conn) Read(p []byte) (int, error)       { return c.in.Read(p) }
+func (c *conn) Write(p []byte) (int, error)      { return c.out.Write(p) }
+func (c *conn) Close() error                     { return nil }
+func (c *conn) LocalAddr() net.Addr              { return addr{} }
+func (c *conn) RemoteAddr() net.Addr             { return addr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+type addr struct{}
+
+func (addr) Network() string { return "pipe" }
+func (addr) String() string  { return "pipe" }
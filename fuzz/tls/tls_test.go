@@ -0,0 +1,30 @@
+package tls
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/tlssrc"
+)
+
+func FuzzServer(f *testing.F) {
+	for _, src := range gen.Sample("tls/client", ".tls", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckServer(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzClient(f *testing.F) {
+	for _, src := range gen.Sample("tls/server", ".tls", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckClient(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
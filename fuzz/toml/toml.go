@@ -0,0 +1,233 @@
+// Package toml is a fuzz target for github.com/BurntSushi/toml and
+// github.com/pelletier/go-toml/v2. CheckDecode decodes a document into a
+// map with each. BurntSushi/toml reads TOML 1.1, which takes every TOML
+// 1.0 document go-toml reads, so it must decode what go-toml decodes, to
+// the same values; and what each decodes must encode to a document both
+// decode to those values again.
+package toml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	burntsushi "github.com/BurntSushi/toml"
+	"github.com/geeknik/fuzzing/internal/harness"
+	gotoml "github.com/pelletier/go-toml/v2"
+)
+
+// Timeout bounds checking one document.
+var Timeout = 10 * time.Second
+
+// A library decodes and encodes TOML.
+type library struct {
+	name   string
+	decode func([]byte) (map[string]any, error)
+	encode func(map[string]any) ([]byte, error)
+}
+
+var libraries = []library{
+	{"BurntSushi/toml", decodeBurntSushi, encodeBurntSushi},
+	{"go-toml", decodeGoTOML, encodeGoTOML},
+}
+
+func decodeBurntSushi(data []byte) (map[string]any, error) {
+	var m map[string]any
+	_, err := burntsushi.Decode(string(data), &m)
+	return m, err
+}
+
+func encodeBurntSushi(m map[string]any) ([]byte, error) {
+	var b bytes.Buffer
+	err := burntsushi.NewEncoder(&b).Encode(m)
+	return b.Bytes(), err
+}
+
+func decodeGoTOML(data []byte) (map[string]any, error) {
+	var m map[string]any
+	err := gotoml.Unmarshal(data, &m)
+	return m, err
+}
+
+func encodeGoTOML(m map[string]any) ([]byte, error) {
+	return gotoml.Marshal(m)
+}
+
+// CheckDecode checks the document in data. A document neither library
+// decodes is ignored.
+func CheckDecode(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkDecode(data)
+	})
+}
+
+func checkDecode(data []byte) error {
+	var maps [2]map[string]any
+	var errs [2]error
+	for i, l := range libraries {
+		maps[i], errs[i] = l.decode(data)
+	}
+	// BurntSushi/toml decodes documents go-toml does not where they have
+	// the forms TOML 1.1 adds, and, as its tests own, where they add to
+	// a table after it is defined.
+	if errs[0] != nil && errs[1] == nil {
+		return fmt.Errorf("go-toml decodes a document, but BurntSushi/toml gives %v", errs[0])
+	}
+	// Known: BurntSushi/toml decodes an array with a table in it as an
+	// array of tables, dropping what else it holds, where the key of the
+	// array or of the table is empty.
+	if errs[1] == nil && !has(maps[1], emptyKey) && !reflect.DeepEqual(normal(maps[0]), normal(maps[1])) {
+		return fmt.Errorf("BurntSushi/toml decodes a document to\n%#v\nbut go-toml to\n%#v", maps[0], maps[1])
+	}
+	for i, l := range libraries {
+		// Known: BurntSushi/toml decodes offsets past 23:59, which it
+		// encodes for go-toml to reject.
+		if errs[i] != nil || has(maps[i], emptyKey) || has(maps[i], badOffset) {
+			continue
+		}
+		want := normal(maps[i])
+		out, err := l.encode(maps[i])
+		if err != nil {
+			return fmt.Errorf("%s decodes a document to %#v, which it does not encode: %v", l.name, maps[i], err)
+		}
+		for _, m := range libraries {
+			got, err := m.decode(out)
+			if err != nil {
+				return fmt.Errorf("%s decodes a document to %#v, which it encodes as\n%s\nwhich %s does not decode: %v", l.name, maps[i], out, m.name, err)
+			}
+			if !reflect.DeepEqual(normal(got), want) {
+				return fmt.Errorf("%s decodes a document to %#v, which it encodes as\n%s\nwhich %s decodes to %#v", l.name, maps[i], out, m.name, got)
+			}
+		}
+	}
+	return nil
+}
+
+// has reports whether v or a value in it satisfies f.
+func has(v any, f func(v any) bool) bool {
+	if f(v) {
+		return true
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for _, e := range v {
+			if has(e, f) {
+				return true
+			}
+		}
+	case []map[string]any:
+		for _, e := range v {
+			if has(e, f) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if has(e, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emptyKey reports whether v is an array with a table that has the empty
+// key, or a table with the empty key for an array with a table in it.
+func emptyKey(v any) bool {
+	if m, ok := v.(map[string]any); ok {
+		e, ok := m[""]
+		return ok && has(e, tableIn)
+	}
+	return tableIn(v) && has(v, func(v any) bool {
+		m, ok := v.(map[string]any)
+		if ok {
+			_, ok = m[""]
+		}
+		return ok
+	})
+}
+
+// tableIn reports whether v is an array with a table in it.
+func tableIn(v any) bool {
+	switch v := v.(type) {
+	case []map[string]any:
+		return len(v) > 0
+	case []any:
+		for _, e := range v {
+			if _, ok := e.(map[string]any); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// badOffset reports whether v is a datetime with an offset of a day or
+// more, which RFC 3339 does not allow.
+func badOffset(v any) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	_, offset := t.Zone()
+	return offset >= 24*60*60 || offset <= -24*60*60
+}
+
+// nan is NaN as normal gives it, equal to itself.
+type nan struct{}
+
+// A datetime is a datetime, date or time as normal gives it: its kind and
+// its text in a form of its own.
+type datetime struct {
+	kind, text string
+}
+
+// normal returns v with every array a []any, every NaN the same, and
+// every datetime, date and time a datetime, which the libraries give as
+// types of their own.
+func normal(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			m[k] = normal(e)
+		}
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, e := range v {
+			l[i] = normal(e)
+		}
+		return l
+	case []map[string]any:
+		l := make([]any, len(v))
+		for i, e := range v {
+			l[i] = normal(e)
+		}
+		return l
+	case float64:
+		if math.IsNaN(v) {
+			return nan{}
+		}
+	case time.Time:
+		switch v.Location().String() {
+		case "datetime-local":
+			return datetime{"datetime-local", v.Format("2006-01-02T15:04:05.999999999")}
+		case "date-local":
+			return datetime{"date-local", v.Format("2006-01-02")}
+		case "time-local":
+			return datetime{"time-local", v.Format("15:04:05.999999999")}
+		}
+		return datetime{"datetime", v.Format(time.RFC3339Nano)}
+	case gotoml.LocalDateTime:
+		return datetime{"datetime-local", v.AsTime(time.UTC).Format("2006-01-02T15:04:05.999999999")}
+	case gotoml.LocalDate:
+		return datetime{"date-local", v.AsTime(time.UTC).Format("2006-01-02")}
+	case gotoml.LocalTime:
+		t := time.Date(0, 1, 1, v.Hour, v.Minute, v.Second, v.Nanosecond, time.UTC)
+		return datetime{"time-local", t.Format("15:04:05.999999999")}
+	}
+	return v
+}
@@ -0,0 +1,19 @@
+package toml
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/tomlsrc"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, src := range gen.Sample("toml/*", ".toml", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckDecode(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
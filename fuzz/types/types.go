@@ -0,0 +1,92 @@
+// Package types is a fuzz target for go/types. Check type-checks a source
+// file under a time budget and reports panics and hangs of the checker;
+// type errors in the input are expected and ignored.
+package types
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Limits bounds a single Check.
+type Limits struct {
+	// MaxInstDepth skips sources whose index expressions, which include
+	// explicit instantiations, nest deeper than this: F[G[H[int]]] has
+	// depth 3. Zero means no limit.
+	MaxInstDepth int
+
+	// Timeout bounds the types.Check call. Zero means no limit.
+	Timeout time.Duration
+}
+
+// DefaultLimits is generous enough for generated seeds while still
+// catching runaway instantiation.
+var DefaultLimits = Limits{MaxInstDepth: 64, Timeout: 5 * time.Second}
+
+// imports is shared between runs so that standard library export data is
+// only loaded once per process.
+var imports = importer.Default()
+
+// Check parses src and type-checks it within lim. It returns nil for
+// sources that do not parse or exceed lim.MaxInstDepth, and a
+// *harness.Failure if the checker panics or runs past lim.Timeout.
+func Check(src []byte, lim Limits) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	if lim.MaxInstDepth > 0 && InstDepth(f) > lim.MaxInstDepth {
+		return nil
+	}
+	return harness.Run(lim.Timeout, func() error {
+		conf := types.Config{
+			Importer:    imports,
+			FakeImportC: true,
+			Error:       func(error) {},
+		}
+		info := &types.Info{
+			Types:      map[ast.Expr]types.TypeAndValue{},
+			Instances:  map[*ast.Ident]types.Instance{},
+			Defs:       map[*ast.Ident]types.Object{},
+			Uses:       map[*ast.Ident]types.Object{},
+			Selections: map[*ast.SelectorExpr]*types.Selection{},
+		}
+		conf.Check("p", fset, []*ast.File{f}, info)
+		return nil
+	})
+}
+
+// InstDepth returns the deepest nesting of index expressions in n.
+func InstDepth(n ast.Node) int {
+	var deepest, depth int
+	var stack []bool
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			if stack[len(stack)-1] {
+				depth--
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		_, idx := n.(*ast.IndexExpr)
+		if _, list := n.(*ast.IndexListExpr); list {
+			idx = true
+		}
+		if idx {
+			depth++
+			if depth > deepest {
+				deepest = depth
+			}
+		}
+		stack = append(stack, idx)
+		return true
+	})
+	return deepest
+}
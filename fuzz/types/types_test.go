@@ -0,0 +1,41 @@
+package types
+
+import (
+	"flag"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+)
+
+var (
+	instDepth = flag.Int("check.instdepth", DefaultLimits.MaxInstDepth, "skip inputs nesting instantiations deeper than `n`")
+	timeout   = flag.Duration("check.timeout", DefaultLimits.Timeout, "report a hang after `d`")
+)
+
+func FuzzCheck(f *testing.F) {
+	for _, src := range gen.Sample("go/*", ".go", 2) {
+		f.Add(src)
+	}
+	lim := Limits{MaxInstDepth: *instDepth, Timeout: *timeout}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := Check(src, lim); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzVersions(f *testing.F) {
+	for _, src := range gen.Sample("go/typesets", ".go", 8) {
+		f.Add(src)
+	}
+	for _, src := range gen.Sample("go/*", ".go", 1) {
+		f.Add(src)
+	}
+	lim := Limits{MaxInstDepth: *instDepth, Timeout: *timeout}
+	f.Fuzz(func(t *testing.T, src []byte) {
+		if err := CheckVersions(src, lim); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
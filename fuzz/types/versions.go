@@ -0,0 +1,75 @@
+package types
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"slices"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// Versions are the language versions CheckVersions compares, oldest
+// first: the first with generics, the one that let interfaces satisfy
+// comparable, the ones that reworked inference and range, and the
+// checker's newest, written "".
+var Versions = []string{"go1.18", "go1.20", "go1.21", "go1.22", ""}
+
+// CheckVersions type-checks src at each of Versions and reports sources
+// that one version accepts and a newer one rejects, since no language
+// change has yet made a valid program invalid, and versions whose errors
+// differ between two checks of the same file. Sources that do not parse
+// or exceed lim.MaxInstDepth are skipped; panics and hangs are reported
+// as in Check.
+func CheckVersions(src []byte, lim Limits) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil
+	}
+	if lim.MaxInstDepth > 0 && InstDepth(f) > lim.MaxInstDepth {
+		return nil
+	}
+	return harness.Run(lim.Timeout, func() error {
+		errs := make([][]string, len(Versions))
+		for i, v := range Versions {
+			errs[i] = check(fset, f, v)
+			if again := check(fset, f, v); !slices.Equal(errs[i], again) {
+				return fmt.Errorf("%s: errors differ between runs:\n%q\n%q", name(v), errs[i], again)
+			}
+		}
+		for i := range Versions {
+			if len(errs[i]) > 0 {
+				continue
+			}
+			for j := i + 1; j < len(Versions); j++ {
+				if len(errs[j]) > 0 {
+					return fmt.Errorf("accepted at %s but rejected at %s: %s", name(Versions[i]), name(Versions[j]), errs[j][0])
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// check type-checks f at version v and returns its errors.
+func check(fset *token.FileSet, f *ast.File, v string) []string {
+	var errs []string
+	conf := types.Config{
+		GoVersion:   v,
+		Importer:    imports,
+		FakeImportC: true,
+		Error:       func(err error) { errs = append(errs, err.Error()) },
+	}
+	conf.Check("p", fset, []*ast.File{f}, nil)
+	return errs
+}
+
+func name(v string) string {
+	if v == "" {
+		return "the newest version"
+	}
+	return v
+}
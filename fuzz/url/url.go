@@ -0,0 +1,121 @@
+// Package url is a fuzz target for net/url. CheckParse parses a URL
+// reference with Parse and, where it could be the target of a request
+// line, with ParseRequestURI. A URL that parses must print, with
+// String, as one that parses to the same URL and prints the same, its
+// escaped parts must unescape to its decoded ones, and the two parsers
+// must agree on whatever both accept. Where parsers disagree on a URL,
+// the host one of them checks is not the host another connects to.
+package url
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// CheckParse checks the URL reference s.
+func CheckParse(s string) error {
+	u, err := url.Parse(s)
+	if err := checkRequestURI(s, u, err); err != nil {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+	if err := checkParts(u); err != nil {
+		return fmt.Errorf("%q: %v", s, err)
+	}
+
+	// Known: String writes a path that starts with two slashes as it
+	// is when there is no scheme, userinfo or host before it, where it
+	// reads back as an authority: a path of "//evil.com/" prints as a
+	// URL whose host is evil.com.
+	if u.Scheme == "" && u.User == nil && u.Host == "" && strings.HasPrefix(u.Path, "//") {
+		return nil
+	}
+	printed := u.String()
+	again, err := url.Parse(printed)
+	if err != nil {
+		return fmt.Errorf("%q prints as %q, which does not parse: %v", s, printed, err)
+	}
+	if again.String() != printed {
+		return fmt.Errorf("%q prints as %q, which prints as %q", s, printed, again)
+	}
+	// String writes "./" before a relative path whose first segment
+	// has a colon, which would read as a scheme, and Parse keeps it.
+	if u.Scheme == "" && u.Host == "" && again.Path == "./"+u.Path {
+		again.Path = u.Path
+	}
+	if !same(u, again) {
+		return fmt.Errorf("%q prints as %q, which parses differently:\n%#v\n%#v", s, printed, u, again)
+	}
+	return nil
+}
+
+// checkRequestURI checks that ParseRequestURI agrees with Parse, which
+// returned u and err, on s. ParseRequestURI takes everything after the
+// path for the query, a '#' included, and a reference that starts with
+// two slashes for a path, not an authority, as a request line would
+// carry it; they are compared only on URLs with neither.
+func checkRequestURI(s string, u *url.URL, err error) error {
+	if strings.Contains(s, "#") || strings.HasPrefix(s, "//") {
+		return nil
+	}
+	r, rerr := url.ParseRequestURI(s)
+	switch {
+	case rerr == nil && err != nil:
+		return fmt.Errorf("%q: ParseRequestURI accepts it but Parse does not: %v", s, err)
+	case rerr == nil && !same(u, r):
+		return fmt.Errorf("%q: ParseRequestURI and Parse disagree:\n%#v\n%#v", s, r, u)
+	case rerr != nil && err == nil && (u.IsAbs() || strings.HasPrefix(s, "/")):
+		return fmt.Errorf("%q: Parse accepts it but ParseRequestURI does not: %v", s, rerr)
+	}
+	return nil
+}
+
+// checkParts checks that the escaped and decoded forms of u's parts
+// agree.
+func checkParts(u *url.URL) error {
+	if u.Opaque == "" {
+		if p, err := url.PathUnescape(u.EscapedPath()); err != nil || p != u.Path {
+			return fmt.Errorf("EscapedPath %q unescapes to %q (%v), not Path %q", u.EscapedPath(), p, err, u.Path)
+		}
+	}
+	if f, err := url.PathUnescape(u.EscapedFragment()); err != nil || f != u.Fragment {
+		return fmt.Errorf("EscapedFragment %q unescapes to %q (%v), not Fragment %q", u.EscapedFragment(), f, err, u.Fragment)
+	}
+	if host, port := u.Hostname(), u.Port(); port != "" {
+		if u.Host != host+":"+port && u.Host != "["+host+"]:"+port {
+			return fmt.Errorf("Host %q is not Hostname %q and Port %q joined", u.Host, host, port)
+		}
+	}
+	if u.User != nil {
+		user, err := url.Parse("http://" + u.User.String() + "@example.com/")
+		if err != nil {
+			return fmt.Errorf("userinfo %q does not parse: %v", u.User, err)
+		}
+		if !reflect.DeepEqual(user.User, u.User) {
+			return fmt.Errorf("userinfo %q parses as %#v, not %#v", u.User, user.User, u.User)
+		}
+	}
+	if q, err := url.ParseQuery(u.RawQuery); err == nil {
+		encoded := q.Encode()
+		again, err := url.ParseQuery(encoded)
+		if err != nil {
+			return fmt.Errorf("query %q encodes as %q, which does not parse: %v", u.RawQuery, encoded, err)
+		}
+		if !reflect.DeepEqual(again, q) && len(q) > 0 {
+			return fmt.Errorf("query %q encodes as %q, which parses as %v, not %v", u.RawQuery, encoded, again, q)
+		}
+	}
+	return nil
+}
+
+// same reports whether u and v are the same URL, however each was
+// written.
+func same(u, v *url.URL) bool {
+	return u.Scheme == v.Scheme && u.Opaque == v.Opaque && reflect.DeepEqual(u.User, v.User) &&
+		u.Host == v.Host && u.Path == v.Path && u.RawQuery == v.RawQuery && u.Fragment == v.Fragment &&
+		u.ForceQuery == v.ForceQuery && u.OmitHost == v.OmitHost
+}
@@ -0,0 +1,19 @@
+package url
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/urlsrc"
+)
+
+func FuzzParse(f *testing.F) {
+	for _, src := range gen.Sample("url/ref", ".url", 64) {
+		f.Add(string(src))
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		if err := CheckParse(s); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
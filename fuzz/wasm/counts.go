@@ -0,0 +1,315 @@
+package wasm
+
+import "encoding/binary"
+
+// maxLocals is the most locals countsFit lets a function have, as many
+// as browsers take.
+const maxLocals = 50000
+
+// countsFit reports whether every vector, name and byte string in the
+// module in data has no more items than there are bytes after its count,
+// and every function no more than maxLocals locals. It reads the module
+// as wazero does, which reads a section past its size if its contents
+// run on, or past the end of the module if its size does, and stops
+// where the module stops being one.
+func countsFit(data []byte) bool {
+	if len(data) < 8 {
+		return true
+	}
+	rest := data[8:]
+	for len(rest) > 0 {
+		r := &reader{b: rest}
+		id := r.byte()
+		size := r.u32()
+		if r.bad {
+			return true
+		}
+		next := r.b[min(uint64(size), uint64(len(r.b))):]
+		r.section(id, size)
+		if r.over {
+			return false
+		}
+		if r.bad {
+			return true
+		}
+		rest = next
+	}
+	return true
+}
+
+// A reader reads a module. bad is set where it stops being one, and over
+// where a count runs past its end.
+type reader struct {
+	b    []byte
+	bad  bool
+	over bool
+}
+
+func (r *reader) stop() bool { return r.bad || r.over }
+
+func (r *reader) byte() byte {
+	if len(r.b) == 0 {
+		r.bad = true
+		return 0
+	}
+	c := r.b[0]
+	r.b = r.b[1:]
+	return c
+}
+
+// leb reads an LEB128 number, signed or not, of at most n bytes.
+func (r *reader) leb(n int) uint64 {
+	v, k := binary.Uvarint(r.b[:min(n, len(r.b))])
+	if k <= 0 {
+		r.bad = true
+		return 0
+	}
+	r.b = r.b[k:]
+	return v
+}
+
+func (r *reader) u32() uint32 {
+	v := r.leb(5)
+	if v > 1<<32-1 {
+		r.bad = true
+	}
+	return uint32(v)
+}
+
+// count reads the count of a vector.
+func (r *reader) count() uint32 {
+	n := r.u32()
+	if !r.bad && uint64(n) > uint64(len(r.b)) {
+		r.over = true
+	}
+	return n
+}
+
+func (r *reader) skip(n uint32) {
+	if uint64(n) > uint64(len(r.b)) {
+		r.bad = true
+		return
+	}
+	r.b = r.b[n:]
+}
+
+// name reads a name, or any byte string.
+func (r *reader) name() {
+	if n := r.count(); !r.stop() {
+		r.skip(n)
+	}
+}
+
+// valType reads a value type, which a typed reference follows with its
+// heap type.
+func (r *reader) valType() {
+	if t := r.byte(); t == 0x63 || t == 0x64 {
+		r.leb(5)
+	}
+}
+
+func (r *reader) limits() {
+	flags := r.byte()
+	if flags > 3 {
+		r.bad = true
+		return
+	}
+	r.u32()
+	if flags&1 != 0 {
+		r.u32()
+	}
+}
+
+// expr reads a constant expression up to its end.
+func (r *reader) expr() {
+	for !r.stop() {
+		switch op := r.byte(); op {
+		case 0x0b:
+			return
+		case 0x41, 0x23, 0xd2:
+			r.leb(5)
+		case 0x42:
+			r.leb(10)
+		case 0x43:
+			r.skip(4)
+		case 0x44:
+			r.skip(8)
+		case 0xd0:
+			r.leb(5)
+		case 0x6a, 0x6b, 0x6c, 0x7c, 0x7d, 0x7e: // extended constant expressions
+		case 0xfd:
+			if r.u32() != 12 { // v128.const
+				r.bad = true
+			}
+			r.skip(16)
+		default:
+			r.bad = true
+		}
+	}
+}
+
+// vec reads a vector of items read by item.
+func (r *reader) vec(item func()) {
+	n := r.count()
+	for i := uint32(0); i < n && !r.stop(); i++ {
+		item()
+	}
+}
+
+// section reads the contents of a section of the given ID and size.
+func (r *reader) section(id byte, size uint32) {
+	switch id {
+	case 0: // custom
+		start := len(r.b)
+		n := r.count()
+		if r.stop() || uint64(n) > uint64(len(r.b)) {
+			return
+		}
+		name := string(r.b[:n])
+		r.b = r.b[n:]
+		if used := uint32(start - len(r.b)); name == "name" && used <= size {
+			r.names(size - used)
+		}
+	case 1: // type
+		r.vec(func() {
+			if r.byte() != 0x60 {
+				r.bad = true
+				return
+			}
+			r.vec(r.valType)
+			r.vec(r.valType)
+		})
+	case 2: // import
+		r.vec(func() {
+			r.name()
+			r.name()
+			switch r.byte() {
+			case 0:
+				r.u32()
+			case 1:
+				r.valType()
+				r.limits()
+			case 2:
+				r.limits()
+			case 3:
+				r.valType()
+				r.byte()
+			case 4:
+				r.byte()
+				r.u32()
+			default:
+				r.bad = true
+			}
+		})
+	case 3: // function
+		r.vec(func() { r.u32() })
+	case 4: // table
+		r.vec(func() {
+			r.valType()
+			r.limits()
+		})
+	case 5: // memory
+		r.vec(r.limits)
+	case 6: // global
+		r.vec(func() {
+			r.valType()
+			r.byte()
+			r.expr()
+		})
+	case 7: // export
+		r.vec(func() {
+			r.name()
+			r.byte()
+			r.u32()
+		})
+	case 9: // element
+		r.vec(r.element)
+	case 10: // code
+		r.vec(r.code)
+	case 11: // data
+		r.vec(func() {
+			switch r.u32() {
+			case 0:
+				r.expr()
+			case 1:
+			case 2:
+				r.u32()
+				r.expr()
+			default:
+				r.bad = true
+			}
+			r.name()
+		})
+	}
+}
+
+// names reads the contents of the name section, limit bytes of them,
+// as far as the subsections wazero reads: the module's name, and the
+// names of functions and of their locals.
+func (r *reader) names(limit uint32) {
+	end := len(r.b) - int(min(uint64(limit), uint64(len(r.b))))
+	for len(r.b) > end && !r.stop() {
+		id := r.byte()
+		size := r.u32()
+		switch id {
+		case 0:
+			r.name()
+		case 1:
+			r.vec(func() {
+				r.u32()
+				r.name()
+			})
+		case 2:
+			r.vec(func() {
+				r.u32()
+				r.vec(func() {
+					r.u32()
+					r.name()
+				})
+			})
+		default:
+			r.skip(size)
+		}
+	}
+}
+
+// element reads an element segment.
+func (r *reader) element() {
+	flags := r.u32()
+	if flags > 7 {
+		r.bad = true
+		return
+	}
+	if flags&1 == 0 { // active, with a table index for 2 and 6
+		if flags&2 != 0 {
+			r.u32()
+		}
+		r.expr()
+	}
+	if flags&3 != 0 { // an element kind or reference type
+		r.valType()
+	}
+	if flags&4 != 0 {
+		r.vec(r.expr)
+	} else {
+		r.vec(func() { r.u32() })
+	}
+}
+
+// code reads a function body, and stops at the start of its code.
+func (r *reader) code() {
+	size := r.u32()
+	if r.bad || uint64(size) > uint64(len(r.b)) {
+		r.bad = true
+		return
+	}
+	body := &reader{b: r.b[:size]}
+	r.b = r.b[size:]
+	var locals uint64
+	n := body.count()
+	for i := uint32(0); i < n && !body.stop(); i++ {
+		locals += uint64(body.u32())
+		body.valType()
+	}
+	r.over = body.over || locals > maxLocals
+}
@@ -0,0 +1,221 @@
+// Package wasm is a fuzz target for github.com/tetratelabs/wazero.
+// CheckModule compiles a WebAssembly module with the interpreter and with
+// the compiler, which must agree on whether it is valid. It instantiates
+// one that compiles with each, and calls each function it exports with
+// arguments of zero: the two must trap alike, return the same results and
+// leave the same memory behind.
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/sys"
+)
+
+// Timeout bounds checking one module, and CallTimeout each call into
+// it, past which wazero closes the module and the check stops.
+var (
+	Timeout     = 60 * time.Second
+	CallTimeout = time.Second
+)
+
+// MemoryPages is the most 64 KiB pages a memory may have.
+const MemoryPages = 256
+
+// maxCalls is how many exported functions CheckModule calls.
+const maxCalls = 16
+
+// engines are the runtimes CheckModule compares: the interpreter, and
+// the compiler where wazero has one, or the interpreter again.
+var engines = []func() wazero.RuntimeConfig{
+	wazero.NewRuntimeConfigInterpreter,
+	wazero.NewRuntimeConfig,
+}
+
+// CheckModule checks the module in data.
+func CheckModule(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return checkModule(data)
+	})
+}
+
+// An instance is a module instantiated with one engine, and what became
+// of it.
+type instance struct {
+	r   wazero.Runtime
+	mod api.Module
+	err error
+}
+
+func checkModule(data []byte) error {
+	// Known: wazero makes each vector it decodes, and the locals of each
+	// function, as large as their counts say before it reads an item, so
+	// that a count of a few bytes runs it out of memory.
+	if !countsFit(data) {
+		return nil
+	}
+	ctx := context.Background()
+	var (
+		compiled [2]wazero.CompiledModule
+		errs     [2]error
+		insts    [2]instance
+	)
+	for i, config := range engines {
+		r := wazero.NewRuntimeWithConfig(ctx, config().WithCloseOnContextDone(true).WithMemoryLimitPages(MemoryPages))
+		defer r.Close(ctx)
+		insts[i].r = r
+		compiled[i], errs[i] = r.CompileModule(ctx, data)
+	}
+	// Known: the interpreter reads the opcode after the 0xfc prefix as
+	// one byte to work out the instruction's operands, where the rest of
+	// wazero reads it as the LEB128 number it is, so that it rejects one
+	// padded to more bytes.
+	if errs[0] != nil && errs[1] == nil && strings.Contains(errs[0].Error(), "unsupported misc instruction") {
+		return nil
+	}
+	if (errs[0] == nil) != (errs[1] == nil) {
+		return fmt.Errorf("the interpreter compiles a module with error %v, and the compiler with %v", errs[0], errs[1])
+	}
+	if errs[0] != nil {
+		return nil
+	}
+
+	for i := range engines {
+		call, cancel := context.WithTimeout(ctx, CallTimeout)
+		insts[i].mod, insts[i].err = insts[i].r.InstantiateModule(call, compiled[i], wazero.NewModuleConfig().WithName(""))
+		cancel()
+	}
+	if timedOut(insts[0].err) || timedOut(insts[1].err) {
+		return nil
+	}
+	if (insts[0].err == nil) != (insts[1].err == nil) {
+		return fmt.Errorf("the interpreter instantiates a module with error %v, and the compiler with %v", insts[0].err, insts[1].err)
+	}
+	if insts[0].err != nil {
+		// Which of several missing imports an engine reports first is up
+		// to the order of a map, but a trap in the start function must be
+		// the same.
+		if a, b := trap(insts[0].err), trap(insts[1].err); isTrap(a) && a != b {
+			return fmt.Errorf("the interpreter instantiates a module with error %q, and the compiler with %q", a, b)
+		}
+		return nil
+	}
+
+	defs := compiled[0].ExportedFunctions()
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if len(names) > maxCalls {
+		names = names[:maxCalls]
+	}
+	for _, name := range names {
+		def := defs[name]
+		args := make([]uint64, len(def.ParamTypes()))
+		var (
+			results [2][]uint64
+			callErr [2]error
+		)
+		for i := range engines {
+			call, cancel := context.WithTimeout(ctx, CallTimeout)
+			results[i], callErr[i] = insts[i].mod.ExportedFunction(name).Call(call, args...)
+			cancel()
+		}
+		if timedOut(callErr[0]) || timedOut(callErr[1]) {
+			return nil
+		}
+		a, b := trap(callErr[0]), trap(callErr[1])
+		// Where the stack overflows is up to each engine, so what the
+		// calls before did to memory may differ. Known: the compiler
+		// reports it without the "wasm error" of other traps.
+		if strings.Contains(a, "stack overflow") && strings.Contains(b, "stack overflow") {
+			return nil
+		}
+		if a != b {
+			return fmt.Errorf("%s traps with %q in the interpreter, and with %q in the compiler", name, a, b)
+		}
+		if callErr[0] == nil && !sameResults(def.ResultTypes(), results[0], results[1]) {
+			return fmt.Errorf("%s returns %v in the interpreter, and %v in the compiler", name, results[0], results[1])
+		}
+	}
+
+	// Known: Memory returns a nil *MemoryInstance as an api.Memory that
+	// is not nil for a module without memory, whose methods then panic.
+	for name := range compiled[0].ExportedMemories() {
+		m0, m1 := insts[0].mod.ExportedMemory(name), insts[1].mod.ExportedMemory(name)
+		b0, _ := m0.Read(0, m0.Size())
+		b1, _ := m1.Read(0, m1.Size())
+		if !bytes.Equal(b0, b1) {
+			return fmt.Errorf("memory %s is %d bytes after the calls in the interpreter, and %d in the compiler, differing from %d on", name, len(b0), len(b1), firstDiff(b0, b1))
+		}
+	}
+	return nil
+}
+
+// timedOut reports whether err is the one a call gets for running past
+// its deadline.
+func timedOut(err error) bool {
+	var exit *sys.ExitError
+	return errors.As(err, &exit) && exit.ExitCode() == sys.ExitCodeDeadlineExceeded
+}
+
+// trap returns the first line of err, which says what trapped and why
+// without the stack trace the engines write differently, or "" for nil.
+func trap(err error) string {
+	if err == nil {
+		return ""
+	}
+	s, _, _ := strings.Cut(err.Error(), "\n")
+	return s
+}
+
+// isTrap reports whether the first line of an error is that of a trap.
+func isTrap(line string) bool {
+	return strings.Contains(line, "wasm error:")
+}
+
+// sameResults reports whether a and b are the same results of types,
+// taking any NaN for any other, as which NaN an operation gives is up to
+// the engine.
+func sameResults(types []api.ValueType, a, b []uint64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] == b[i] {
+			continue
+		}
+		switch types[i] {
+		case api.ValueTypeF32:
+			if math.IsNaN(float64(math.Float32frombits(uint32(a[i])))) && math.IsNaN(float64(math.Float32frombits(uint32(b[i])))) {
+				continue
+			}
+		case api.ValueTypeF64:
+			if math.IsNaN(math.Float64frombits(a[i])) && math.IsNaN(math.Float64frombits(b[i])) {
+				continue
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func firstDiff(a, b []byte) int {
+	for i := range min(len(a), len(b)) {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return min(len(a), len(b))
+}
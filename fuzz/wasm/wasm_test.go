@@ -0,0 +1,19 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/wasmsrc"
+)
+
+func FuzzModule(f *testing.F) {
+	for _, src := range gen.Sample("wasm/module", ".wasm", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckModule(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,464 @@
+// Package websocket is a fuzz target for the frame readers of
+// github.com/gorilla/websocket, github.com/coder/websocket and
+// github.com/gobwas/ws. CheckServer reads a client's frames as each
+// library's server does once the handshake is done, and CheckClient a
+// server's frames as each one's client does. A reader written here to
+// RFC 6455 reads them too, stopping at the first frame that breaks a
+// rule: each library must read the messages it reads, in order, and
+// none past where it stops; where both end at a close frame, they must
+// agree on its code and reason.
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	coder "github.com/coder/websocket"
+	"github.com/geeknik/fuzzing/internal/harness"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	gorilla "github.com/gorilla/websocket"
+)
+
+// Timeout bounds reading one input with every library.
+var Timeout = 10 * time.Second
+
+const (
+	// maxMessages bounds the messages read from one input.
+	maxMessages = 4096
+	// maxMessage is the read limit, well past what an input holds.
+	maxMessage = 1 << 20
+)
+
+// Opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// key is the Sec-WebSocket-Key of a client's handshake.
+const key = "dGhlIHNhbXBsZSBub25jZQ=="
+
+// handshake is what a client sends to open a connection.
+const handshake = "GET /chat HTTP/1.1\r\nHost: example.com\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+	"Sec-WebSocket-Key: " + key + "\r\nSec-WebSocket-Version: 13\r\n\r\n"
+
+// A stream is what a reader makes of the frames of an input: the data
+// messages it read, in order, and the close frame that ended them, if
+// one did.
+type stream struct {
+	Messages []message
+	Closed   bool
+	Code     int
+	Reason   string
+	Rest     *message // the message data ended in the middle of
+}
+
+type message struct {
+	Binary bool
+	Data   string
+}
+
+// CheckServer checks the frames in data read as a client's.
+func CheckServer(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return check(data, true)
+	})
+}
+
+// CheckClient checks the frames in data read as a server's.
+func CheckClient(data []byte) error {
+	return harness.Run(Timeout, func() error {
+		return check(data, false)
+	})
+}
+
+// quirks are the ways a library reads frames that RFC 6455 does not
+// allow, each of which read lets through when set.
+type quirks struct {
+	text   bool // text messages need not be UTF-8
+	reason bool // close reasons need not be UTF-8
+	eof    bool // the end of the input may end a message
+	masked bool // a client reads masked data frames without unmasking them
+	short  bool // a close frame's one-byte payload reads as none
+	codes  bool // close codes past 4999 are allowed
+}
+
+func check(data []byte, server bool) error {
+	readers := []struct {
+		name string
+		read func([]byte, bool) (stream, error)
+		q    quirks
+	}{
+		// Known: gorilla/websocket leaves checking the UTF-8 of text
+		// messages to the application, and reads a one-byte close
+		// payload as an empty one.
+		{"gorilla/websocket", readGorilla, quirks{text: true, short: true}},
+		// Known: coder/websocket checks no UTF-8, ends a message
+		// where the connection does, and a client does not unmask
+		// data frames a server masked.
+		{"coder/websocket", readCoder, quirks{text: true, reason: true, eof: true, masked: true}},
+		// Known: gobwas/ws allows close codes up to 65535.
+		{"gobwas/ws", readGobwas, quirks{codes: true}},
+	}
+	for _, r := range readers {
+		want := read(data, server, r.q)
+		got, err := r.read(data, server)
+		if err != nil {
+			return fmt.Errorf("%s: %v", r.name, err)
+		}
+		n := len(got.Messages)
+		if n == len(want.Messages)+1 && want.Rest != nil {
+			if m := got.Messages[n-1]; m.Binary == want.Rest.Binary && strings.HasPrefix(want.Rest.Data, m.Data) {
+				n--
+				got.Messages = got.Messages[:n]
+			}
+		}
+		if n > len(want.Messages) {
+			return fmt.Errorf("%s reads %d messages where the frames allow %d:\n%+v", r.name, n, len(want.Messages), got.Messages[len(want.Messages):])
+		}
+		if !slices.Equal(got.Messages, want.Messages[:n]) {
+			return fmt.Errorf("%s reads messages\n%+v\nnot\n%+v", r.name, got.Messages, want.Messages[:n])
+		}
+		if !got.Closed || n < len(want.Messages) {
+			continue
+		}
+		if !want.Closed {
+			return fmt.Errorf("%s reads a close frame of %d %q where the frames allow none", r.name, got.Code, got.Reason)
+		}
+		if got.Code != want.Code || got.Reason != want.Reason {
+			return fmt.Errorf("%s reads a close frame of %d %q, not %d %q", r.name, got.Code, got.Reason, want.Code, want.Reason)
+		}
+	}
+	return nil
+}
+
+// read reads the frames of a client, if server is set, or of a server
+// as RFC 6455 says an endpoint must, bar q, stopping at the end of data
+// or at the first frame that breaks a rule.
+func read(data []byte, server bool, q quirks) stream {
+	var st stream
+	var msg []byte
+	op := -1 // the opcode of the message in progress
+	// cut ends st where data does, in the middle of a frame or of a
+	// message.
+	cut := func() stream {
+		if op >= 0 {
+			st.Rest = &message{op == opBinary, string(msg)}
+		}
+		return st
+	}
+	for len(st.Messages) < maxMessages {
+		if len(data) < 2 {
+			return cut()
+		}
+		fin, rsv, opcode := data[0]&0x80 != 0, data[0]>>4&7, int(data[0]&0xf)
+		masked, n := data[1]&0x80 != 0, uint64(data[1]&0x7f)
+		data = data[2:]
+		switch n {
+		case 126:
+			if len(data) < 2 {
+				return cut()
+			}
+			n, data = uint64(binary.BigEndian.Uint16(data)), data[2:]
+		case 127:
+			if len(data) < 8 {
+				return cut()
+			}
+			n, data = binary.BigEndian.Uint64(data), data[8:]
+		}
+		var mask []byte
+		if masked {
+			if len(data) < 4 {
+				return cut()
+			}
+			mask, data = data[:4], data[4:]
+		}
+		control := opcode&0x8 != 0
+		unmask := masked && (server || control || !q.masked)
+		switch {
+		case rsv != 0, opcode&0x7 > 2, n>>63 != 0:
+			return st
+		case masked != server && !(masked && q.masked):
+			return st
+		case control && (n > 125 || !fin):
+			return st
+		case opcode == opContinuation && op < 0, !control && opcode != opContinuation && op >= 0:
+			return st
+		}
+		p := bytes.Clone(data[:min(n, uint64(len(data)))])
+		data = data[len(p):]
+		if unmask {
+			for i := range p {
+				p[i] ^= mask[i%4]
+			}
+		}
+		if !control {
+			if op < 0 {
+				op = opcode
+			}
+			msg = append(msg, p...)
+		}
+		if uint64(len(p)) < n {
+			return cut()
+		}
+
+		switch opcode {
+		case opClose:
+			st.Code = 1005 // no status received
+			if len(p) == 1 && !q.short {
+				return st
+			}
+			if len(p) >= 2 {
+				st.Code, st.Reason = int(binary.BigEndian.Uint16(p)), string(p[2:])
+				if !(validCode(st.Code) || q.codes && st.Code > 4999) || !q.reason && !utf8.ValidString(st.Reason) {
+					return stream{Messages: st.Messages}
+				}
+			}
+			st.Closed = true
+			return st
+		case opPing, opPong:
+			continue
+		}
+		if len(msg) > maxMessage {
+			return st
+		}
+		if fin {
+			if !q.text && op == opText && !utf8.Valid(msg) {
+				return st
+			}
+			st.Messages = append(st.Messages, message{op == opBinary, string(msg)})
+			op, msg = -1, nil
+		}
+	}
+	return st
+}
+
+// validCode reports whether a close frame may carry code: one RFC 6455
+// or the IANA registry defines for it, or one for libraries or
+// applications.
+func validCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1014:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
+
+// readGorilla reads data with a gorilla/websocket Conn, a server's if
+// server is set.
+func readGorilla(data []byte, server bool) (stream, error) {
+	c := &conn{data: data}
+	var wc *gorilla.Conn
+	var err error
+	if server {
+		u := gorilla.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+		wc, err = u.Upgrade(&hijacker{c: c}, request(), nil)
+	} else {
+		c.answer = true
+		d := gorilla.Dialer{NetDialContext: func(context.Context, string, string) (net.Conn, error) { return c, nil }}
+		wc, _, err = d.Dial("ws://example.com/chat", nil)
+	}
+	if err != nil {
+		return stream{}, fmt.Errorf("handshake: %v", err)
+	}
+	defer wc.Close()
+	wc.SetReadLimit(maxMessage)
+	var st stream
+	for range maxMessages {
+		typ, p, err := wc.ReadMessage()
+		if err != nil {
+			// A connection that ends without a close frame reads as
+			// one of 1006, which no close frame may carry.
+			var ce *gorilla.CloseError
+			if errors.As(err, &ce) && ce.Code != gorilla.CloseAbnormalClosure {
+				st.Closed, st.Code, st.Reason = true, ce.Code, ce.Text
+			}
+			break
+		}
+		st.Messages = append(st.Messages, message{typ == gorilla.BinaryMessage, string(p)})
+	}
+	return st, nil
+}
+
+// readCoder reads data with a coder/websocket Conn, a server's if
+// server is set.
+func readCoder(data []byte, server bool) (stream, error) {
+	ctx := context.Background()
+	var wc *coder.Conn
+	var err error
+	if server {
+		wc, err = coder.Accept(&hijacker{c: &conn{data: data}}, request(), &coder.AcceptOptions{InsecureSkipVerify: true})
+	} else {
+		client := &http.Client{Transport: upgrade(data)}
+		wc, _, err = coder.Dial(ctx, "ws://example.com/chat", &coder.DialOptions{HTTPClient: client})
+	}
+	if err != nil {
+		return stream{}, fmt.Errorf("handshake: %v", err)
+	}
+	defer wc.CloseNow()
+	wc.SetReadLimit(maxMessage)
+	var st stream
+	for range maxMessages {
+		typ, p, err := wc.Read(ctx)
+		if err != nil {
+			var ce coder.CloseError
+			if errors.As(err, &ce) {
+				st.Closed, st.Code, st.Reason = true, int(ce.Code), ce.Reason
+			}
+			break
+		}
+		st.Messages = append(st.Messages, message{typ == coder.MessageBinary, string(p)})
+	}
+	return st, nil
+}
+
+// readGobwas reads data with gobwas/ws's wsutil, as a server if server
+// is set.
+func readGobwas(data []byte, server bool) (stream, error) {
+	rw := struct {
+		io.Reader
+		io.Writer
+	}{bytes.NewReader(data), io.Discard}
+	var st stream
+	for range maxMessages {
+		var p []byte
+		var op ws.OpCode
+		var err error
+		if server {
+			p, op, err = wsutil.ReadClientData(rw)
+		} else {
+			p, op, err = wsutil.ReadServerData(rw)
+		}
+		if err != nil {
+			var ce wsutil.ClosedError
+			if errors.As(err, &ce) {
+				st.Closed, st.Code, st.Reason = true, int(ce.Code), ce.Reason
+			}
+			break
+		}
+		st.Messages = append(st.Messages, message{op == ws.OpBinary, string(p)})
+	}
+	return st, nil
+}
+
+// request returns a client's handshake request.
+func request() *http.Request {
+	r, err := http.ReadRequest(bufio.NewReader(strings.NewReader(handshake)))
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// accept returns the Sec-WebSocket-Accept of a handshake with key k.
+func accept(k string) string {
+	h := sha1.Sum([]byte(k + "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// response returns a server's answer to a handshake with key k.
+func response(k string) http.Header {
+	return http.Header{
+		"Upgrade":              {"websocket"},
+		"Connection":           {"Upgrade"},
+		"Sec-Websocket-Accept": {accept(k)},
+	}
+}
+
+// A conn is a connection whose reads return data and whose writes are
+// dropped. If answer is set, its first read answers the handshake
+// written to it before data.
+type conn struct {
+	data   []byte
+	answer bool
+	r      io.Reader
+	req    bytes.Buffer
+}
+
+func (c *conn) Read(p []byte) (int, error) {
+	if c.r == nil {
+		c.r = bytes.NewReader(c.data)
+		if c.answer {
+			var head bytes.Buffer
+			r, err := http.ReadRequest(bufio.NewReader(&c.req))
+			if err != nil {
+				return 0, err
+			}
+			head.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+			response(r.Header.Get("Sec-WebSocket-Key")).Write(&head)
+			head.WriteString("\r\n")
+			c.r = io.MultiReader(&head, c.r)
+		}
+	}
+	return c.r.Read(p)
+}
+
+func (c *conn) Write(p []byte) (int, error) {
+	if c.answer && c.r == nil {
+		c.req.Write(p)
+	}
+	return len(p), nil
+}
+
+func (c *conn) Close() error                     { return nil }
+func (c *conn) LocalAddr() net.Addr              { return &net.TCPAddr{} }
+func (c *conn) RemoteAddr() net.Addr             { return &net.TCPAddr{} }
+func (c *conn) SetDeadline(time.Time) error      { return nil }
+func (c *conn) SetReadDeadline(time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(time.Time) error { return nil }
+
+// A hijacker is the http.ResponseWriter of a handshake, which hands c
+// over to the library that answers it.
+type hijacker struct {
+	c      *conn
+	header http.Header
+}
+
+func (w *hijacker) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *hijacker) Write(p []byte) (int, error) { return len(p), nil }
+func (w *hijacker) WriteHeader(int)             {}
+
+func (w *hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.c, bufio.NewReadWriter(bufio.NewReader(w.c), bufio.NewWriter(w.c)), nil
+}
+
+// upgrade is an http.RoundTripper that answers a handshake with a
+// connection that reads the frames in it.
+type upgrade []byte
+
+func (u upgrade) RoundTrip(r *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:     "101 Switching Protocols",
+		StatusCode: http.StatusSwitchingProtocols,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     response(r.Header.Get("Sec-WebSocket-Key")),
+		Body:       &conn{data: u},
+		Request:    r,
+	}, nil
+}
@@ -0,0 +1,30 @@
+package websocket
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/wssrc"
+)
+
+func FuzzServer(f *testing.F) {
+	for _, src := range gen.Sample("ws/client", ".ws", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckServer(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzClient(f *testing.F) {
+	for _, src := range gen.Sample("ws/server", ".ws", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckClient(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,157 @@
+// Package x509 is a fuzz target for crypto/x509. A certificate CheckParse
+// parses must be the whole of the data and must parse the same with
+// ParseCertificates; used as a template, it must create a certificate
+// that parses back to the same names, dates, usages, constraints and
+// other fields a template carries over.
+package x509
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"fmt"
+	"math/big"
+	"net"
+	"reflect"
+	"time"
+)
+
+// key signs the certificates CheckParse creates. It is derived from a
+// fixed seed, so that signing is deterministic and generates nothing.
+var key = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+
+// CheckParse checks data parsed as a certificate. Data that does not
+// parse, and certificates CreateCertificate rejects as templates, such
+// as those with negative serials, are not checked further.
+func CheckParse(data []byte) error {
+	c, err := x509.ParseCertificate(data)
+	// ParseCertificates reads certificates until the data runs out, so
+	// it must read exactly one where ParseCertificate reads one.
+	certs, errs := x509.ParseCertificates(data)
+	if (err == nil) != (errs == nil && len(certs) == 1) {
+		return fmt.Errorf("ParseCertificate gives %v, but ParseCertificates gives %d certificates (%v)", err, len(certs), errs)
+	}
+	if err != nil {
+		return nil
+	}
+	if !certs[0].Equal(c) {
+		return fmt.Errorf("ParseCertificates gives a certificate other than the one ParseCertificate does")
+	}
+	if !bytes.Equal(c.Raw, data) {
+		return fmt.Errorf("the certificate parsed from %d bytes has %d raw bytes", len(data), len(c.Raw))
+	}
+	for _, raw := range [][]byte{c.RawTBSCertificate, c.RawSubjectPublicKeyInfo, c.RawSubject, c.RawIssuer} {
+		if !bytes.Contains(data, raw) {
+			return fmt.Errorf("%x is not part of the certificate it was parsed from", raw)
+		}
+	}
+
+	template := *c
+	template.SignatureAlgorithm = x509.UnknownSignatureAlgorithm
+	template.PublicKey = key.Public()
+	out, err := x509.CreateCertificate(nil, &template, &template, key.Public(), key)
+	if err != nil {
+		return nil
+	}
+	again, err := x509.ParseCertificate(out)
+	if err != nil {
+		return fmt.Errorf("a certificate created from one that parses does not parse: %v\n%x", err, out)
+	}
+	// The template is its own parent, so it is its own issuer.
+	want, got := fieldsOf(c), fieldsOf(again)
+	want.Issuer = want.Subject
+	if c.IsCA && len(c.SubjectKeyId) == 0 {
+		// CreateCertificate makes up a key identifier for a CA.
+		want.SubjectKeyID = got.SubjectKeyID
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("a certificate created from one that parses differs:\n%+v\nnot\n%+v", got, want)
+	}
+	return nil
+}
+
+// fields are what CreateCertificate carries over from a template, as
+// values that compare equal whenever they encode the same: times in UTC,
+// and empty byte slices as no bytes at all.
+type fields struct {
+	Serial                *big.Int
+	Subject, Issuer       string
+	NotBefore, NotAfter   time.Time
+	KeyUsage              x509.KeyUsage
+	ExtKeyUsage           []x509.ExtKeyUsage
+	UnknownExtKeyUsage    []string
+	BasicConstraintsValid bool
+	IsCA                  bool
+	MaxPathLen            int
+	MaxPathLenZero        bool
+	SubjectKeyID          string
+	OCSPServer            []string
+	IssuingCertificateURL []string
+	DNSNames              []string
+	EmailAddresses        []string
+	IPAddresses           []string
+	URIs                  []string
+	Critical              bool
+	PermittedDNS          []string
+	ExcludedDNS           []string
+	PermittedIPs          []string
+	ExcludedIPs           []string
+	PermittedEmails       []string
+	ExcludedEmails        []string
+	PermittedURIs         []string
+	ExcludedURIs          []string
+	CRLDistributionPoints []string
+	Policies              []string
+}
+
+func fieldsOf(c *x509.Certificate) fields {
+	f := fields{
+		Serial:                c.SerialNumber,
+		Subject:               c.Subject.String(),
+		Issuer:                c.Issuer.String(),
+		NotBefore:             c.NotBefore.UTC(),
+		NotAfter:              c.NotAfter.UTC(),
+		KeyUsage:              c.KeyUsage,
+		ExtKeyUsage:           c.ExtKeyUsage,
+		BasicConstraintsValid: c.BasicConstraintsValid,
+		IsCA:                  c.IsCA,
+		MaxPathLen:            c.MaxPathLen,
+		MaxPathLenZero:        c.MaxPathLenZero,
+		SubjectKeyID:          string(c.SubjectKeyId),
+		OCSPServer:            c.OCSPServer,
+		IssuingCertificateURL: c.IssuingCertificateURL,
+		DNSNames:              c.DNSNames,
+		EmailAddresses:        c.EmailAddresses,
+		Critical:              c.PermittedDNSDomainsCritical,
+		PermittedDNS:          c.PermittedDNSDomains,
+		ExcludedDNS:           c.ExcludedDNSDomains,
+		PermittedEmails:       c.PermittedEmailAddresses,
+		ExcludedEmails:        c.ExcludedEmailAddresses,
+		PermittedURIs:         c.PermittedURIDomains,
+		ExcludedURIs:          c.ExcludedURIDomains,
+		CRLDistributionPoints: c.CRLDistributionPoints,
+	}
+	for _, u := range c.UnknownExtKeyUsage {
+		f.UnknownExtKeyUsage = append(f.UnknownExtKeyUsage, u.String())
+	}
+	for _, ip := range c.IPAddresses {
+		f.IPAddresses = append(f.IPAddresses, ip.String())
+	}
+	for _, u := range c.URIs {
+		f.URIs = append(f.URIs, u.String())
+	}
+	f.PermittedIPs = ranges(c.PermittedIPRanges)
+	f.ExcludedIPs = ranges(c.ExcludedIPRanges)
+	for _, p := range c.Policies {
+		f.Policies = append(f.Policies, p.String())
+	}
+	return f
+}
+
+func ranges(nets []*net.IPNet) []string {
+	var out []string
+	for _, n := range nets {
+		out = append(out, n.String())
+	}
+	return out
+}
@@ -0,0 +1,19 @@
+package x509
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/asn1src"
+)
+
+func FuzzParseCertificate(f *testing.F) {
+	for _, src := range gen.Sample("asn1/cert", ".der", 32) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckParse(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
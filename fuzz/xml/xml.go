@@ -0,0 +1,323 @@
+// Package xml is a fuzz target for encoding/xml. CheckToken reads a
+// document with Decoder.Token, strictly and as HTML: the tokens must be
+// balanced, with each end element naming the namespace its start did,
+// must not hold more text than the document, and, re-encoded, must read
+// back as the same tokens. CheckUnmarshal unmarshals a document into Doc,
+// whose fields cover the tag options, and marshals the result: the output
+// must unmarshal and marshal again to the same bytes.
+package xml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CheckToken reads data strictly and laxly. Documents a strict Decoder
+// reads to the end are also re-encoded.
+func CheckToken(data []byte) error {
+	for _, strict := range []bool{true, false} {
+		toks, err := tokens(data, strict)
+		if err != nil {
+			return err
+		}
+		if strict && toks != nil {
+			return roundTrip(toks)
+		}
+	}
+	return nil
+}
+
+// tokens reads data and returns copies of its tokens if it is read to
+// the end without an error.
+func tokens(data []byte, strict bool) ([]xml.Token, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	mode := "strict"
+	if !strict {
+		d.Strict = false
+		d.AutoClose = xml.HTMLAutoClose
+		d.Entity = xml.HTMLEntity
+		mode = "lax"
+	}
+	var toks []xml.Token
+	var stack []xml.Name
+	text := 0
+	offset := int64(0)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			if len(stack) > 0 {
+				return nil, fmt.Errorf("%s: EOF with %d elements open", mode, len(stack))
+			}
+			return toks, nil
+		}
+		if err != nil {
+			return nil, nil
+		}
+		o := d.InputOffset()
+		if o < offset || o > int64(len(data)) {
+			return nil, fmt.Errorf("%s: InputOffset goes from %d to %d in %d bytes", mode, offset, o, len(data))
+		}
+		offset = o
+		switch t := tok.(type) {
+		case xml.StartElement:
+			stack = append(stack, t.Name)
+			for _, a := range t.Attr {
+				text += len(a.Value)
+			}
+		case xml.EndElement:
+			// Known: the end elements a lax Decoder invents for ones it
+			// finds missing or mismatched are named by their prefix, not
+			// the namespace it stands for, so only their local names match.
+			if len(stack) == 0 || stack[len(stack)-1] != t.Name && (strict || stack[len(stack)-1].Local != t.Name.Local) {
+				return nil, fmt.Errorf("%s: end element %v does not match the open elements %v", mode, t.Name, stack)
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			text += len(t)
+		}
+		// No reference expands to more than it takes to write, so text
+		// past the size of the document is an entity expanding itself.
+		if text > len(data) {
+			return nil, fmt.Errorf("%s: %d bytes of text and attribute values from %d bytes", mode, text, len(data))
+		}
+		if len(toks) > len(data) {
+			return nil, fmt.Errorf("%s: %d tokens from %d bytes", mode, len(toks), len(data))
+		}
+		toks = append(toks, xml.CopyToken(tok))
+	}
+}
+
+// roundTrip encodes toks and checks that they read back the same, by
+// local name: the encoder declares namespaces itself, under prefixes of
+// its own. Tokens the encoder rejects, such as comments holding "--",
+// are not checked.
+func roundTrip(toks []xml.Token) error {
+	for _, t := range toks {
+		if t, ok := t.(xml.StartElement); ok && splits(t) {
+			return nil
+		}
+	}
+	var b bytes.Buffer
+	e := xml.NewEncoder(&b)
+	for _, t := range toks {
+		if err := e.EncodeToken(t); err != nil {
+			return nil
+		}
+	}
+	if err := e.Flush(); err != nil {
+		return nil
+	}
+	again, err := tokens(b.Bytes(), true)
+	if err != nil {
+		return err
+	}
+	if again == nil {
+		d := xml.NewDecoder(&b)
+		var err error
+		for err == nil {
+			_, err = d.Token()
+		}
+		return fmt.Errorf("the re-encoded tokens do not decode: %v\n%s", err, b.Bytes())
+	}
+	want, got := normalize(toks), normalize(again)
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("the re-encoded tokens decode differently:\n%s\n--- want\n%q\n--- got\n%q", b.Bytes(), want, got)
+	}
+	return nil
+}
+
+// splits reports whether the name of start or of one of its attributes
+// has a local part that is not a name. Known: the Decoder takes a name
+// such as "x:1" apart at the colon without checking that the local part
+// is a name on its own, which the encoder then writes as the name "1".
+func splits(start xml.StartElement) bool {
+	if !startsName(start.Name.Local) {
+		return true
+	}
+	for _, a := range start.Attr {
+		if !startsName(a.Name.Local) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitsName reports whether a start element that splits is among the
+// tokens data begins with.
+func splitsName(data []byte) bool {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return false
+		}
+		if t, ok := t.(xml.StartElement); ok && splits(t) {
+			return true
+		}
+	}
+}
+
+// startsName reports whether s starts with a character that can start
+// an XML name.
+func startsName(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r)
+}
+
+// normalize returns toks as strings, without namespaces or namespace
+// declarations, and with adjacent character data, which CDATA sections
+// split, joined; an empty CDATA section is no character data at all.
+func normalize(toks []xml.Token) []string {
+	var out []string
+	chars := false
+	for _, t := range toks {
+		var s string
+		switch t := t.(type) {
+		case xml.StartElement:
+			var attrs []string
+			for _, a := range t.Attr {
+				if declaration(a) {
+					continue
+				}
+				attrs = append(attrs, fmt.Sprintf("%s=%q", a.Name.Local, a.Value))
+			}
+			s = fmt.Sprintf("<%s %s>", t.Name.Local, strings.Join(attrs, " "))
+		case xml.EndElement:
+			s = fmt.Sprintf("</%s>", t.Name.Local)
+		case xml.CharData:
+			if len(t) == 0 {
+				continue
+			}
+			if chars {
+				out[len(out)-1] += string(t)
+				continue
+			}
+			chars = true
+			out = append(out, string(t))
+			continue
+		default:
+			s = fmt.Sprintf("%T %q", t, t)
+		}
+		chars = false
+		out = append(out, s)
+	}
+	return out
+}
+
+// declaration reports whether a declares a namespace.
+func declaration(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || a.Name.Space == "" && a.Name.Local == "xmlns"
+}
+
+// Upper is a text type: it unmarshals to its text in upper case, so that
+// unmarshaling it is not the identity.
+type Upper string
+
+// MarshalText returns u.
+func (u Upper) MarshalText() ([]byte, error) { return []byte(u), nil }
+
+// UnmarshalText sets u to text in upper case.
+func (u *Upper) UnmarshalText(text []byte) error {
+	*u = Upper(strings.ToUpper(string(text)))
+	return nil
+}
+
+// Item keeps its content as raw XML.
+type Item struct {
+	Kind  string `xml:"kind,attr,omitempty"`
+	Inner string `xml:",innerxml"`
+}
+
+// Note keeps its text as CDATA.
+type Note struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr,omitempty"`
+	Text string `xml:",cdata"`
+}
+
+// Any is any element.
+type Any struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Text     string     `xml:",chardata"`
+	Children []Any      `xml:",any"`
+}
+
+// Doc has a field for each tag option, fields in namespaces, a path of
+// elements, and fields that recur.
+type Doc struct {
+	XMLName xml.Name `xml:"doc"`
+	ID      string   `xml:"id,attr,omitempty"`
+	B       bool     `xml:"b,attr,omitempty"`
+	NSAttr  string   `xml:"urn:x kind,attr,omitempty"`
+	Name    string   `xml:"name,omitempty"`
+	NSName  string   `xml:"urn:y 名前,omitempty"`
+	Path    []string `xml:"a>b>c"`
+	N       int      `xml:"n,omitempty"`
+	F       float64  `xml:"f,omitempty"`
+	Up      Upper    `xml:"up,omitempty"`
+	Skip    string   `xml:"-"`
+	Items   []Item   `xml:"item"`
+	Notes   []Note   `xml:"note"`
+	Inner   *Doc     `xml:"doc"`
+	Text    string   `xml:",chardata"`
+	Comment string   `xml:",comment"`
+	Any     []Any    `xml:",any"`
+}
+
+// CheckUnmarshal checks data unmarshaled into a Doc. What it unmarshals
+// to must marshal to a document that unmarshals to a value marshaling to
+// the same bytes; the first marshaling is not compared with data, which
+// unmarshaling loses information from. Documents that do not unmarshal,
+// and values the marshaler rejects, such as comments holding "--", are
+// not checked.
+func CheckUnmarshal(data []byte) error {
+	var v Doc
+	if err := xml.Unmarshal(data, &v); err != nil || splitsName(data) {
+		return nil
+	}
+	undeclare(&v)
+	once, err := xml.Marshal(&v)
+	if err != nil {
+		return nil
+	}
+	var w Doc
+	if err := xml.Unmarshal(once, &w); err != nil {
+		return fmt.Errorf("%+v marshals to %s, which does not unmarshal: %v", v, once, err)
+	}
+	undeclare(&w)
+	twice, err := xml.Marshal(&w)
+	if err != nil {
+		return fmt.Errorf("Marshal of %+v: %v", w, err)
+	}
+	// Known: the marshaler writes a carriage return into a CDATA section
+	// as it is, which reads back as a newline, as it does in a comment.
+	read := bytes.ReplaceAll(bytes.ReplaceAll(once, []byte("\r\n"), []byte("\n")), []byte("\r"), []byte("\n"))
+	if !bytes.Equal(once, twice) && !bytes.Equal(read, twice) {
+		return fmt.Errorf("marshaling again gives\n%s\nnot\n%s", twice, once)
+	}
+	return nil
+}
+
+// undeclare removes the namespace declarations from the attributes of
+// the Any elements in d. Known: the marshaler declares the namespaces of
+// an element's name and attributes itself, and writes the declarations
+// among them as well, so they would pile up with each round trip.
+func undeclare(d *Doc) {
+	for ; d != nil; d = d.Inner {
+		undeclareAny(d.Any)
+	}
+}
+
+func undeclareAny(as []Any) {
+	for i := range as {
+		as[i].Attrs = slices.DeleteFunc(as[i].Attrs, declaration)
+		undeclareAny(as[i].Children)
+	}
+}
@@ -0,0 +1,30 @@
+package xml
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/xmlsrc"
+)
+
+func FuzzToken(f *testing.F) {
+	for _, src := range gen.Sample("xml/*", ".xml", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckToken(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func FuzzUnmarshal(f *testing.F) {
+	for _, src := range gen.Sample("xml/*", ".xml", 16) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckUnmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
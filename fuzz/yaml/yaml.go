@@ -0,0 +1,392 @@
+// Package yaml is a fuzz target for gopkg.in/yaml.v3. CheckDecode decodes
+// each document of a stream into a Node, and the Node into an interface
+// value, within a budget of time and memory linear in the size of the
+// stream, past which it is reported as a blowup: aliases let a few bytes
+// stand for far more, which the decoder must refuse to expand. Unmarshal
+// must decode the first document to the same value, and a value must
+// marshal, as must its Node, to a document that decodes to it again.
+package yaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultBudget is per byte of the stream. It allows for the expansion
+// the decoder permits a small document, whose aliases may make up
+// nearly all it decodes up to a few hundred thousand values.
+var DefaultBudget = harness.Budget{
+	Base: harness.Cost{Time: 2 * time.Second, Memory: 256 << 20},
+	Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 64 << 10},
+}
+
+// A document is one document of a stream, as a Node and as the value the
+// Node decodes to, or the error decoding it.
+type document struct {
+	node *yaml.Node
+	v    any
+	err  error
+}
+
+// CheckDecode decodes data within b. Errors decoding are expected and
+// ignored.
+func CheckDecode(data []byte, b harness.Budget) error {
+	limit := b.For(len(data))
+	var docs []document
+	err := harness.Measure(fmt.Sprintf("decoding %d bytes", len(data)), limit, func() {
+		docs = decode(data)
+	})
+	if err != nil {
+		return err
+	}
+	return harness.Run(harness.HangFactor*limit.Time, func() error {
+		return check(data, docs)
+	})
+}
+
+// decode decodes the documents of data up to the first that does not
+// parse, after which the Decoder cannot go on.
+func decode(data []byte) []document {
+	d := yaml.NewDecoder(bytes.NewReader(data))
+	var docs []document
+	for range len(data) + 1 {
+		n := new(yaml.Node)
+		if err := d.Decode(n); err != nil {
+			break
+		}
+		// Known: the decoder panics on a key that is a sequence or a mapping
+		// of a mapping it merges, which it hashes before rejecting.
+		if hasNode(n, mergesCollectionKey) {
+			docs = append(docs, document{n, nil, errMerge})
+			continue
+		}
+		var v any
+		err := n.Decode(&v)
+		docs = append(docs, document{n, v, err})
+	}
+	return docs
+}
+
+// errMerge is the error of a document mergesCollectionKey holds for.
+var errMerge = errors.New("merge of a mapping with a collection for a key")
+
+// mergesCollectionKey reports whether n is a mapping that merges one with
+// a key that is a sequence or a mapping.
+func mergesCollectionKey(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if k := n.Content[i]; k.Kind != yaml.ScalarNode || k.ShortTag() != "!!merge" {
+			continue
+		}
+		merged := []*yaml.Node{n.Content[i+1]}
+		if v := deref(n.Content[i+1]); v.Kind == yaml.SequenceNode {
+			merged = v.Content
+		}
+		for _, m := range merged {
+			m = deref(m)
+			for j := 0; j < len(m.Content) && m.Kind == yaml.MappingNode; j += 2 {
+				if k := deref(m.Content[j]).Kind; k == yaml.SequenceNode || k == yaml.MappingNode {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// deref returns the node n is an alias of, or n.
+func deref(n *yaml.Node) *yaml.Node {
+	for range 64 {
+		if n.Kind != yaml.AliasNode || n.Alias == nil {
+			break
+		}
+		n = n.Alias
+	}
+	return n
+}
+
+func check(data []byte, docs []document) error {
+	if len(docs) > 0 && docs[0].err == nil {
+		var v any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf("the first document decodes, but Unmarshal gives %v", err)
+		}
+		if !same(v, docs[0].v) {
+			return fmt.Errorf("the first document decodes to %#v, but Unmarshal gives %#v", docs[0].v, v)
+		}
+	}
+	for i, doc := range docs {
+		if doc.err != nil || has(doc.v, badBlock) || has(doc.v, badKeys) {
+			continue
+		}
+		out, err := yaml.Marshal(doc.v)
+		if err != nil {
+			return fmt.Errorf("document %d decodes to %#v, which does not marshal: %v", i, doc.v, err)
+		}
+		if err := decodesTo(out, doc.v); err != nil {
+			return fmt.Errorf("document %d decodes to %#v, which marshals to\n%s\n%v", i, doc.v, out, err)
+		}
+		// A value keeps only the last of the keys of a mapping that decode
+		// the same, where its Node keeps them all.
+		if hasNode(doc.node, badStyle) || hasNode(doc.node, commentedKey) || hasNode(doc.node, emptyNull) ||
+			hasNode(doc.node, sameKeys) {
+			continue
+		}
+		out, err = yaml.Marshal(doc.node)
+		if err != nil {
+			return fmt.Errorf("the Node of document %d does not marshal: %v", i, err)
+		}
+		if err := decodesTo(out, doc.v); err != nil {
+			return fmt.Errorf("the Node of document %d, which decodes to %#v, marshals to\n%s\n%v", i, doc.v, out, err)
+		}
+	}
+	return nil
+}
+
+// Known: the encoder writes a string of more than a line in a literal
+// block without the line breaks it begins with, and with U+0085, U+2028
+// and U+2029 as they are, which the decoder then reads as line breaks. If
+// it begins with a tab it leaves out the indentation indicator, and if
+// with a space, inside a sequence, gives one the decoder reads from
+// another indentation.
+func badBlock(v any, _ bool) bool {
+	s, ok := v.(string)
+	return ok && (strings.HasPrefix(s, "\n") || strings.ContainsAny(s, "\u0085\u2028\u2029") ||
+		strings.ContainsAny(s[:min(1, len(s))], " \t") && strings.Contains(s, "\n"))
+}
+
+// Known: the encoder writes a map with a NaN key with null for its value,
+// which it looks up by the key; a whole float key as an int, the same as
+// an int key equal to it; and a "<<" key, which came from a quoted or an
+// aliased scalar, plain, as a merge key. The decoder rejects keys written
+// the same as a duplicate though one is a string and the other not.
+func badKeys(v any, key bool) bool {
+	if f, ok := v.(float64); ok && key {
+		return math.IsNaN(f)
+	}
+	if key && v == "<<" {
+		return true
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return false
+	}
+	seen := make(map[any]bool, len(m))
+	texts := make(map[string]bool, len(m))
+	for k := range m {
+		text, ok := k.(string)
+		if !ok {
+			out, _ := yaml.Marshal(k)
+			text = strings.TrimSuffix(string(out), "\n")
+		}
+		if seen[normal(k)] || texts[text] {
+			return true
+		}
+		seen[normal(k)] = true
+		texts[text] = true
+	}
+	return false
+}
+
+// Known: the encoder writes a block of either style with a line break too
+// many after the last of those it keeps when a comment follows, and with
+// an indentation indicator the decoder reads from another indentation if
+// it begins with a space, as in badBlock; with its line comment in the
+// block when another comment is left to write after its header; and, if
+// folded, with a line break too many before a line more indented than the
+// others.
+func badStyle(n *yaml.Node) bool {
+	if n.Kind != yaml.ScalarNode || n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		return false
+	}
+	return strings.HasPrefix(n.Value, " ") || strings.HasSuffix(n.Value, "\n\n") || n.LineComment != "" ||
+		n.Style&yaml.FoldedStyle != 0 && (strings.Contains(n.Value, "\n ") || strings.Contains(n.Value, "\n\t"))
+}
+
+// Known: the encoder writes the line comment of a key, or of a mapping or
+// sequence for its value, after the colon of that key or, for a complex
+// key, the next, so that the anchor or tag of the value begins the next
+// line, where it no longer belongs to it.
+func commentedKey(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	commented := false
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		v := n.Content[i+1]
+		tagged := v.Style&yaml.TaggedStyle != 0 ||
+			v.Kind == yaml.MappingNode && v.ShortTag() != "!!map" ||
+			v.Kind == yaml.SequenceNode && v.ShortTag() != "!!seq"
+		commented = commented || n.Content[i].LineComment != "" || v.Kind != yaml.ScalarNode && v.LineComment != ""
+		if commented && (v.Anchor != "" || tagged) {
+			return true
+		}
+	}
+	return false
+}
+
+// Known: the encoder writes an empty null as an empty string in a flow
+// collection and for a key.
+func emptyNull(n *yaml.Node) bool {
+	flow := n.Style&yaml.FlowStyle != 0
+	for i, c := range n.Content {
+		key := n.Kind == yaml.MappingNode && i%2 == 0
+		if (flow || key) && c.Kind == yaml.ScalarNode && c.Value == "" && c.ShortTag() == "!!null" {
+			return true
+		}
+	}
+	return false
+}
+
+// sameKeys reports whether n is a mapping with scalar keys that decode
+// the same.
+func sameKeys(n *yaml.Node) bool {
+	if n.Kind != yaml.MappingNode {
+		return false
+	}
+	seen := make(map[string]bool, len(n.Content)/2)
+	for i := 0; i < len(n.Content); i += 2 {
+		k := n.Content[i]
+		if deref(k).Kind != yaml.ScalarNode {
+			continue
+		}
+		var v any
+		if k.Decode(&v) != nil {
+			continue
+		}
+		key := fmt.Sprintf("%#v", normal(v))
+		if seen[key] {
+			return true
+		}
+		seen[key] = true
+	}
+	return false
+}
+
+// has reports whether f holds for a key or value in v, of a map or a
+// sequence or v itself.
+func has(v any, f func(v any, key bool) bool) bool {
+	if f(v, false) {
+		return true
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if f(k, true) || has(e, f) {
+				return true
+			}
+		}
+	case map[any]any:
+		for k, e := range v {
+			if f(k, true) || has(k, f) || has(e, f) {
+				return true
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if has(e, f) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// hasNode reports whether f holds for n or a node inside it.
+func hasNode(n *yaml.Node, f func(*yaml.Node) bool) bool {
+	if f(n) {
+		return true
+	}
+	for _, c := range n.Content {
+		if hasNode(c, f) {
+			return true
+		}
+	}
+	return false
+}
+
+// decodesTo returns an error unless data decodes to v.
+func decodesTo(data []byte, v any) error {
+	var w any
+	if err := yaml.Unmarshal(data, &w); err != nil {
+		return err
+	}
+	if !same(v, w) {
+		return fmt.Errorf("which decodes to %#v", w)
+	}
+	return nil
+}
+
+// same reports whether a and b are the same value decoded from YAML.
+func same(a, b any) bool {
+	return reflect.DeepEqual(normal(a), normal(b))
+}
+
+// A num is a number as normal gives it.
+type num string
+
+// An entry is a key and value of a map as normal gives it.
+type entry struct{ k, v any }
+
+// normal returns v with every number a num, so that a float is the same
+// as an int equal to it, as one marshals as the other when it is whole,
+// and NaN the same as NaN; every time a string, as is every string that
+// reads as a timestamp, since Marshal writes a time without its tag and
+// it then decodes as a string; and every map its entries in order, which
+// keys that are the same number cannot collide in.
+func normal(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		m := make(map[any]any, len(v))
+		for k, e := range v {
+			m[k] = e
+		}
+		return normal(m)
+	case map[any]any:
+		m := make([]entry, 0, len(v))
+		for k, e := range v {
+			m = append(m, entry{normal(k), normal(e)})
+		}
+		sort.Slice(m, func(i, j int) bool {
+			return fmt.Sprint(m[i]) < fmt.Sprint(m[j])
+		})
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, e := range v {
+			l[i] = normal(e)
+		}
+		return l
+	case int:
+		return num(new(big.Float).SetInt64(int64(v)).Text('g', -1))
+	case int64:
+		return num(new(big.Float).SetInt64(v).Text('g', -1))
+	case uint64:
+		return num(new(big.Float).SetUint64(v).Text('g', -1))
+	case float64:
+		if math.IsNaN(v) {
+			return num("NaN")
+		}
+		return num(big.NewFloat(v).Text('g', -1))
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case string:
+		var t time.Time
+		if (&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!timestamp", Value: v}).Decode(&t) == nil {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return v
+}
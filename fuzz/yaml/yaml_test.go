@@ -0,0 +1,19 @@
+package yaml
+
+import (
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/yamlsrc"
+)
+
+func FuzzDecode(f *testing.F) {
+	for _, src := range gen.Sample("yaml/*", ".yaml", 64) {
+		f.Add(src)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := CheckDecode(data, DefaultBudget); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+// Package zip is a fuzz target for archive/zip. CheckReader opens an
+// archive and extracts its entries, through File.Open and through the
+// Reader's fs.FS view, as a careful extractor would: all entries
+// together may give no more than a fixed number of bytes, so that a bomb
+// is read only that far, and opening and extracting the archive must
+// take time and memory within a budget linear in its size, past which
+// it is reported as a blowup. An entry read to its end must have the
+// size and checksum its header gives, and a stored one the same bytes
+// raw; the fs.FS view must name no path that leaves its root; and the
+// entries read must write an archive that reads back the same.
+package zip
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/internal/harness"
+)
+
+// A Budget is what reading an archive may cost, per byte of the
+// archive. Extract is how many bytes extracting its entries may give,
+// all entries together; the bytes extracted are allocated, and Base
+// must allow for them.
+type Budget struct {
+	harness.Budget
+	Extract int64
+}
+
+// DefaultBudget extracts up to 16 MiB, which a few kilobytes of deflated
+// zeros give.
+var DefaultBudget = Budget{
+	Budget: harness.Budget{
+		Base: harness.Cost{Time: time.Second, Memory: 128 << 20},
+		Per:  harness.Cost{Time: 100 * time.Microsecond, Memory: 16 << 10},
+	},
+	Extract: 16 << 20,
+}
+
+// An entry is a file read to its end, and what it held.
+type entry struct {
+	f       *zip.File
+	content []byte
+}
+
+// CheckReader reads the archive in data within b. Errors reading are
+// expected and ignored.
+func CheckReader(data []byte, b Budget) error {
+	var r *zip.Reader
+	var entries []entry
+	var xerr error
+	err := harness.Measure(fmt.Sprintf("reading %d bytes", len(data)), b.For(len(data)), func() {
+		r, entries, xerr = extract(data, b.Extract)
+	})
+	if err != nil {
+		return err
+	}
+	if xerr != nil {
+		return xerr
+	}
+	if r == nil {
+		return nil
+	}
+	return rewrite(r.Comment, entries)
+}
+
+// extract opens the archive in data and reads its entries, giving up
+// once they have given limit bytes. It returns the Reader, or nil if
+// the archive does not open, and the entries read to their end.
+func extract(data []byte, limit int64) (*zip.Reader, []entry, error) {
+	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, nil
+	}
+	left := limit
+	var entries []entry
+	for _, f := range r.File {
+		if left <= 0 {
+			break
+		}
+		rc, err := f.Open()
+		if err != nil {
+			continue
+		}
+		content, err := read(rc, &left)
+		rc.Close()
+		if err != nil {
+			continue
+		}
+		if err := checkEntry(f, content, &left); err != nil {
+			return nil, nil, err
+		}
+		entries = append(entries, entry{f, content})
+	}
+	if err := walk(r, &left); err != nil {
+		return nil, nil, err
+	}
+	return r, entries, nil
+}
+
+// errLimit is what read returns once it has read all it may.
+var errLimit = errors.New("extraction limit reached")
+
+// read reads r to its end, taking what it reads from *left; it reads no
+// more than *left bytes, and returns errLimit if r has more.
+func read(r io.Reader, left *int64) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, *left+1))
+	if int64(len(b)) > *left {
+		*left = 0
+		return nil, errLimit
+	}
+	*left -= int64(len(b))
+	return b, err
+}
+
+// checkEntry checks that f, read to its end, holds content.
+func checkEntry(f *zip.File, content []byte, left *int64) error {
+	if uint64(len(content)) != f.UncompressedSize64 {
+		return fmt.Errorf("%q: read %d bytes, but its size is %d", f.Name, len(content), f.UncompressedSize64)
+	}
+	// The checksum is checked against a data descriptor's, or the
+	// header's unless that is zero.
+	if sum := crc32.ChecksumIEEE(content); sum != f.CRC32 && (f.CRC32 != 0 || f.Flags&0x8 != 0) {
+		return fmt.Errorf("%q: read bytes with checksum %08x, but its checksum is %08x", f.Name, sum, f.CRC32)
+	}
+	if f.Method != zip.Store || f.FileInfo().IsDir() {
+		return nil
+	}
+	rr, err := f.OpenRaw()
+	if err != nil {
+		return fmt.Errorf("%q: opens, but not raw: %v", f.Name, err)
+	}
+	raw, err := read(rr, left)
+	if err == errLimit {
+		return nil
+	}
+	if err != nil || !bytes.Equal(raw, content) {
+		return fmt.Errorf("%q: stored as %q, but reads raw as %q (%v)", f.Name, content, raw, err)
+	}
+	return nil
+}
+
+// walk walks r as an fs.FS, reading each file it names, and checks that
+// every path is valid and local, as a path an extractor writes to must
+// be, and names a file that exists.
+func walk(r *zip.Reader, left *int64) error {
+	var bad error
+	fs.WalkDir(r, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !fs.ValidPath(name) || !filepath.IsLocal(name) {
+			bad = fmt.Errorf("fs.WalkDir visits %q, which is not a local path", name)
+			return fs.SkipAll
+		}
+		if d.IsDir() || *left <= 0 {
+			return nil
+		}
+		f, err := r.Open(name)
+		// Known: Open looks a file up by its name with backslashes made
+		// slashes, but ReadDir lists it by the base name of the name
+		// as the archive has it, so that a file named `a\b` is listed
+		// as one that does not exist.
+		if errors.Is(err, fs.ErrNotExist) && !strings.Contains(name, `\`) {
+			bad = fmt.Errorf("fs.WalkDir visits %q, which does not exist: %v", name, err)
+			return fs.SkipAll
+		}
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		read(f, left)
+		return nil
+	})
+	return bad
+}
+
+// rewrite writes entries, read from an archive with comment, as a new
+// archive, which must read back as the same entries.
+func rewrite(comment string, entries []entry) error {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, e := range entries {
+		h := e.f.FileHeader
+		fw, err := w.CreateHeader(&h)
+		if err != nil {
+			return fmt.Errorf("%q: a header read does not write: %v", h.Name, err)
+		}
+		if _, err := fw.Write(e.content); err != nil {
+			return fmt.Errorf("%q: an entry read does not write: %v", h.Name, err)
+		}
+	}
+	if err := w.SetComment(comment); err != nil {
+		return fmt.Errorf("a comment read does not write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("the entries read do not write: %v", err)
+	}
+	r, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+	if err != nil {
+		return fmt.Errorf("the entries read write an archive that does not read: %v", err)
+	}
+	if r.Comment != comment {
+		return fmt.Errorf("comment %q reads back as %q", comment, r.Comment)
+	}
+	if len(r.File) != len(entries) {
+		return fmt.Errorf("%d entries written read back as %d", len(entries), len(r.File))
+	}
+	for i, f := range r.File {
+		e := entries[i]
+		// Writer stores a directory whatever its method, which Open
+		// does not look at for one.
+		if f.Name != e.f.Name || f.Comment != e.f.Comment || f.Method != e.f.Method && !f.FileInfo().IsDir() {
+			return fmt.Errorf("%q (comment %q, method %d) reads back as %q (comment %q, method %d)", e.f.Name, e.f.Comment, e.f.Method, f.Name, f.Comment, f.Method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%q: written, but does not open: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || !bytes.Equal(content, e.content) {
+			return fmt.Errorf("%q: written as %q, but reads back as %q (%v)", f.Name, e.content, content, err)
+		}
+	}
+	return nil
+}
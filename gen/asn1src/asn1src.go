@@ -0,0 +1,548 @@
+// Package asn1src generates DER seeds. It registers the "asn1/..."
+// generators with package gen.
+//
+// "asn1/value" writes values of the struct fuzz/asn1 unmarshals into,
+// field by field, with a field of the wrong type now and then, and
+// sometimes an arbitrary tree of elements instead. "asn1/cert" writes
+// X.509 certificates that keep to the structure x509.ParseCertificate
+// expects but fill it with borderline values: negative and oversized
+// serials, dates at and past the ends of UTCTime and GeneralizedTime,
+// odd string types in names, unusual and malformed extensions and OIDs
+// with many or huge arcs. Signatures are random bytes, which parsing
+// does not check. Each element also has malformed encodings, drawn
+// rarely, because a single bad one fails the whole structure.
+package asn1src
+
+import (
+	"math/big"
+	"slices"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "asn1/value",
+		Doc:  "DER values of a struct with a field of each type encoding/asn1 supports: integers, big integers, bit strings, OIDs, string and time types, sets, optional, default and tagged fields, nested sequences and arbitrary element trees",
+		Func: value,
+	})
+	gen.Register(&gen.Generator{
+		Name: "asn1/cert",
+		Doc:  "DER X.509 certificates with negative, zero and oversized serials, absurd validity dates, odd name string types, weird and duplicate extensions, deeply nested and huge-arc OIDs and oversized bit strings",
+		Func: cert,
+	})
+}
+
+// badRate is the chance that an element is drawn with a malformed
+// encoding. A certificate has a hundred or so elements, so about a
+// fifth of them get one.
+const badRate = 0.001
+
+// Universal tags, and the bits that make a tag context-specific or
+// constructed.
+const (
+	tagBoolean     = 1
+	tagInteger     = 2
+	tagBitString   = 3
+	tagOctetString = 4
+	tagNull        = 5
+	tagOID         = 6
+	tagEnum        = 10
+	tagUTF8        = 12
+	tagSequence    = 0x30
+	tagSet         = 0x31
+	tagNumeric     = 18
+	tagPrintable   = 19
+	tagT61         = 20
+	tagIA5         = 22
+	tagUTCTime     = 23
+	tagGenTime     = 24
+	tagUniversal   = 28
+	tagBMP         = 30
+
+	context     = 0x80
+	constructed = 0x20
+)
+
+// A der writes DER elements, now and then malformed.
+type der struct {
+	s *gen.State
+}
+
+// length returns the DER encoding of the length n.
+func length(n int) []byte {
+	if n < 128 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append(b, byte(n))
+	}
+	slices.Reverse(b)
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// tlv returns the element with tag and the concatenation of contents.
+func (d der) tlv(tag byte, contents ...[]byte) []byte {
+	c := slices.Concat(contents...)
+	if d.s.Chance(badRate) {
+		return d.badTLV(tag, c)
+	}
+	return slices.Concat([]byte{tag}, length(len(c)), c)
+}
+
+// badTLV returns the element with a tag or length DER forbids or one that
+// does not fit its contents.
+func (d der) badTLV(tag byte, c []byte) []byte {
+	n := len(c)
+	switch d.s.Intn(7) {
+	case 0:
+		// A length in more bytes than it needs.
+		return slices.Concat([]byte{tag, 0x82, byte(n >> 8), byte(n)}, c)
+	case 1:
+		// The indefinite length of BER.
+		return slices.Concat([]byte{tag | constructed, 0x80}, c, []byte{0, 0})
+	case 2:
+		// A length that runs past the contents, or stops short of them.
+		return slices.Concat([]byte{tag}, length(n+gen.Pick(d.s, 1, -1, 100)), c)
+	case 3:
+		// A length as long as a length can be.
+		return slices.Concat([]byte{tag, 0x84, 0xff, 0xff, 0xff, 0xff}, c)
+	case 4:
+		// A small tag number in the high-tag-number form.
+		return slices.Concat([]byte{tag&0xe0 | 0x1f, tag & 0x1f}, length(n), c)
+	case 5:
+		// A high tag number with too many bytes.
+		return slices.Concat([]byte{tag | 0x1f, 0x80, 0x81, 0x82, 0x83, 0x84, 0x01}, length(n), c)
+	default:
+		// The primitive form of a constructed element, or the reverse.
+		return slices.Concat([]byte{tag ^ constructed}, length(n), c)
+	}
+}
+
+// seq returns a SEQUENCE of elems.
+func (d der) seq(elems ...[]byte) []byte {
+	return d.tlv(tagSequence, elems...)
+}
+
+// set returns a SET of elems, sorted as DER requires but now and then.
+func (d der) set(elems ...[]byte) []byte {
+	if !d.s.Chance(0.1) {
+		elems = slices.Clone(elems)
+		slices.SortFunc(elems, func(a, b []byte) int { return strings.Compare(string(a), string(b)) })
+	}
+	return d.tlv(tagSet, elems...)
+}
+
+// explicit returns elem wrapped in the context-specific tag n.
+func (d der) explicit(n int, elem []byte) []byte {
+	return d.tlv(context|constructed|byte(n), elem)
+}
+
+// boolean returns a BOOLEAN, or one DER forbids.
+func (d der) boolean(v bool) []byte {
+	c := byte(0)
+	if v {
+		c = 0xff
+	}
+	if d.s.Chance(badRate * 2) {
+		return d.tlv(tagBoolean, gen.Pick(d.s, []byte{0x01}, []byte{0x00, 0x00}, nil, []byte{0x7f}))
+	}
+	return d.tlv(tagBoolean, []byte{c})
+}
+
+// intBytes returns the minimal two's complement encoding of v.
+func intBytes(v *big.Int) []byte {
+	if v.Sign() >= 0 {
+		b := v.Bytes()
+		if len(b) == 0 || b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// -v-1 has the bits of v complemented.
+	m := new(big.Int).Neg(v)
+	m.Sub(m, big.NewInt(1))
+	b := m.Bytes()
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	if len(b) == 0 || b[0]&0x80 == 0 {
+		b = append([]byte{0xff}, b...)
+	}
+	return b
+}
+
+// integer returns an INTEGER, or one padded or empty, which DER forbids.
+func (d der) integer(v *big.Int) []byte {
+	return d.tagged(tagInteger, v)
+}
+
+func (d der) tagged(tag byte, v *big.Int) []byte {
+	b := intBytes(v)
+	if d.s.Chance(badRate * 2) {
+		switch d.s.Intn(3) {
+		case 0:
+			b = append([]byte{0x00}, b...)
+		case 1:
+			b = append([]byte{0xff}, b...)
+		default:
+			b = nil
+		}
+	}
+	return d.tlv(tag, b)
+}
+
+// small returns an INTEGER holding v.
+func (d der) small(v int64) []byte {
+	return d.integer(big.NewInt(v))
+}
+
+// bigInt returns an integer of up to n bytes, of either sign, with the
+// boundaries of the fixed-size integers among them.
+func (d der) bigInt(n int) *big.Int {
+	switch d.s.Intn(6) {
+	case 0:
+		return big.NewInt(int64(gen.Pick(d.s, 0, 1, -1, 127, 128, -128, -129, 255, 256)))
+	case 1:
+		v, _ := new(big.Int).SetString(gen.Pick(d.s,
+			"9223372036854775807", "-9223372036854775808", "9223372036854775808", "-9223372036854775809",
+			"2147483647", "2147483648", "-2147483649", "18446744073709551615", "4294967296",
+		), 10)
+		return v
+	}
+	b := make([]byte, d.s.Range(1, n))
+	for i := range b {
+		b[i] = byte(d.s.Intn(256))
+	}
+	v := new(big.Int).SetBytes(b)
+	if d.s.Chance(0.3) {
+		v.Neg(v)
+	}
+	return v
+}
+
+// fitting returns an integer that fits a signed integer of n bytes, or,
+// now and then, one that may not.
+func (d der) fitting(n int) *big.Int {
+	v := d.bigInt(n)
+	for v.BitLen() >= 8*n && !d.s.Chance(0.05) {
+		v = d.bigInt(n)
+	}
+	return v
+}
+
+// oid returns an OBJECT IDENTIFIER with arcs, or one encoded badly.
+func (d der) oid(arcs ...uint64) []byte {
+	var b []byte
+	for i, a := range arcs {
+		if i == 0 {
+			continue
+		}
+		if i == 1 {
+			a += 40 * arcs[0]
+		}
+		b = base128(b, a)
+	}
+	if d.s.Chance(badRate * 2) {
+		switch d.s.Intn(4) {
+		case 0:
+			b = append([]byte{0x80}, b...) // an arc with a leading zero digit
+		case 1:
+			b = nil
+		case 2:
+			b = append(b, 0x81) // an arc with no last digit
+		default:
+			// An arc past 64 bits.
+			b = append(b, 0x82, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f)
+		}
+	}
+	return d.tlv(tagOID, b)
+}
+
+// base128 appends a as the base-128 digits of an OID arc.
+func base128(b []byte, a uint64) []byte {
+	var digits []byte
+	for {
+		digits = append(digits, byte(a&0x7f))
+		a >>= 7
+		if a == 0 {
+			break
+		}
+	}
+	for i := len(digits) - 1; i > 0; i-- {
+		b = append(b, digits[i]|0x80)
+	}
+	return append(b, digits[0])
+}
+
+// arcs returns the arcs of an OID: a short one, one nested many arcs
+// deep, or one with huge arcs.
+func (d der) arcs() []uint64 {
+	switch d.s.Intn(10) {
+	case 0:
+		n := d.s.Depth(d.s.Limits.Literal, 40)
+		arcs := []uint64{1, 3, 6, 1, 4, 1}
+		for range n {
+			arcs = append(arcs, uint64(d.s.Intn(1000)))
+		}
+		return arcs
+	case 1:
+		return []uint64{2, gen.Pick(d.s, uint64(999), 1<<31, 1<<63-1, 1<<64-1-80), uint64(d.s.Intn(3)), gen.Pick(d.s, uint64(1<<32), 1<<56, 1<<64-1)}
+	case 2, 3:
+		return []uint64{uint64(d.s.Intn(3)), uint64(d.s.Intn(40))}
+	}
+	return []uint64{1, 2, uint64(d.s.Range(1, 999)), uint64(d.s.Intn(10)), uint64(d.s.Intn(10))}
+}
+
+// bits returns a BIT STRING of n bytes, the last unused bits of which
+// are zero, or a malformed one.
+func (d der) bits(n, unused int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(d.s.Intn(256))
+	}
+	if n == 0 {
+		unused = 0
+	}
+	if n > 0 {
+		b[n-1] &^= byte(1<<unused - 1)
+	}
+	if d.s.Chance(badRate * 2) {
+		// Unused bits that are not zero, more than a byte of them, or
+		// some in no byte at all.
+		switch d.s.Intn(3) {
+		case 0:
+			if n > 0 {
+				b[n-1] |= 1
+			}
+			unused = max(unused, 1)
+		case 1:
+			unused = gen.Pick(d.s, 8, 0xff)
+		default:
+			b, unused = nil, 3
+		}
+	}
+	return d.tlv(tagBitString, []byte{byte(unused)}, b)
+}
+
+// str returns a string of one of the string types.
+func (d der) str(tag byte, s string) []byte {
+	c := []byte(s)
+	switch tag {
+	case tagBMP:
+		c = nil
+		for _, u := range utf16.Encode([]rune(s)) {
+			c = append(c, byte(u>>8), byte(u))
+		}
+		if d.s.Chance(badRate * 2) {
+			c = append(c, 0xd8) // an odd byte, or half a surrogate
+		}
+	case tagUniversal:
+		c = nil
+		for _, r := range s {
+			c = append(c, byte(r>>24), byte(r>>16), byte(r>>8), byte(r))
+		}
+	}
+	return d.tlv(tag, c)
+}
+
+// text returns text for a string, some of which only some string types
+// can hold.
+func (d der) text() string {
+	if d.s.Chance(badRate * 5) {
+		return gen.Pick(d.s, "\xff", "\xc3", "a\x00b", "\xed\xa0\x80")
+	}
+	return gen.Pick(d.s,
+		"", "x", "Example", "example.com", "a b", "1234", "a*b", "a@b", "a&b", "a_b", "名前", "é", "😀",
+		"Test CA", "O'Brien", "a=b,c", "+", " lead", "trail ", "\t", "a\nb",
+	)
+}
+
+// utcTimes and genTimes are times as UTCTime and GeneralizedTime write
+// them, at the ends of what each can say and past them. The last of each
+// are ones that DER or the calendar forbids.
+var (
+	utcTimes = []string{
+		"210101000000Z", "491231235959Z", "500101000000Z", "991231235959Z", "000101000000Z", "000229120000Z",
+		"700101000000Z", "380119031408Z", "380119031407Z",
+		"2101010000Z", "210101000000+0100", "210101000000-2359", "210230000000Z", "211301000000Z",
+		"210101000060Z", "21010100000Z", "210101000000", "210101246000Z", "210101000000.5Z",
+	}
+	genTimes = []string{
+		"20210101000000Z", "99991231235959Z", "00000101000000Z", "00010101000000Z", "20500101000000Z",
+		"19491231235959Z", "19500101000000Z", "20491231235959Z", "21060207062816Z", "20000229000000Z",
+		"20210101000000.999Z", "20210101000000.5Z", "20210101000000.000000001Z", "20210101000000+0000",
+		"2021010100Z", "20211301000000Z", "20210101000000", "19000229000000Z", "20210101000000,5Z",
+		"20210101000000.Z", "20210101000000.50Z", "-0010101000000Z",
+	}
+)
+
+// utc and generalized return times, usually ones DER allows.
+func (d der) utc() []byte {
+	n := len(utcTimes) - 10
+	if d.s.Chance(badRate * 5) {
+		n = len(utcTimes)
+	}
+	return d.tlv(tagUTCTime, []byte(gen.Pick(d.s, utcTimes[:n]...)))
+}
+
+func (d der) generalized() []byte {
+	n := len(genTimes) - 12
+	if d.s.Chance(badRate * 5) {
+		n = len(genTimes)
+	}
+	return d.tlv(tagGenTime, []byte(gen.Pick(d.s, genTimes[:n]...)))
+}
+
+// any returns an arbitrary element: a primitive of a universal type, or a
+// constructed one holding more of them, to depth.
+func (d der) any(depth int) []byte {
+	if depth > 0 && d.s.Chance(0.4) {
+		var kids [][]byte
+		for range d.s.Range(0, 3) {
+			kids = append(kids, d.any(depth-1))
+		}
+		tag := gen.Pick(d.s, byte(tagSequence), tagSet, context|constructed, context|constructed|3, 0x60|1, 0xe0|2)
+		return d.tlv(tag, kids...)
+	}
+	switch d.s.Intn(12) {
+	case 0:
+		return d.boolean(d.s.Chance(0.5))
+	case 1:
+		return d.integer(d.bigInt(20))
+	case 2:
+		return d.bits(d.s.Intn(8), d.s.Intn(8))
+	case 3:
+		return d.tlv(tagOctetString, []byte(d.text()))
+	case 4:
+		return d.tlv(tagNull, nil)
+	case 5:
+		return d.oid(d.arcs()...)
+	case 6:
+		return d.tagged(tagEnum, d.bigInt(4))
+	case 7:
+		return d.str(gen.Pick(d.s, byte(tagUTF8), tagPrintable, tagIA5, tagT61, tagBMP, tagNumeric, tagUniversal), d.text())
+	case 8:
+		return d.utc()
+	case 9:
+		return d.generalized()
+	case 10:
+		// A tag number in the high-tag-number form.
+		return []byte{0x9f, 0x81, 0x00, 0x01, 0x00}
+	}
+	return d.tlv(context|byte(d.s.Intn(31)), []byte(d.text()))
+}
+
+// value writes a Value of fuzz/asn1, or an arbitrary tree of elements.
+func value(s *gen.State) []gen.File {
+	d := der{s}
+	var data []byte
+	if s.Chance(0.15) {
+		data = d.any(s.Depth(s.Limits.Literal, 4))
+	} else {
+		data = d.value(s.Depth(s.Limits.Literal, 3))
+	}
+	if s.Chance(0.05) {
+		// Data after the value, which Unmarshal returns rather than
+		// rejects.
+		data = append(data, gen.Pick(s, []byte{0}, []byte{0x05, 0x00}, []byte{0x30})...)
+	}
+	return []gen.File{{Name: "value.der", Data: data}}
+}
+
+// value returns a Value, with a field of another type now and then.
+func (d der) value(depth int) []byte {
+	s := d.s
+	field := func(right func() []byte) []byte {
+		if s.Chance(0.02) {
+			return d.any(1)
+		}
+		return right()
+	}
+	fields := [][]byte{
+		field(func() []byte { return d.integer(d.fitting(8)) }),
+		field(func() []byte { return d.integer(d.bigInt(gen.Pick(s, 1, 16, 64, 300))) }),
+		field(func() []byte { return d.boolean(s.Chance(0.5)) }),
+		field(func() []byte { return d.bits(gen.Pick(s, 0, 1, 2, 9, 300), s.Intn(8)) }),
+		field(func() []byte {
+			if s.Chance(0.3) {
+				return d.oid(d.arcs()...)
+			}
+			return d.oid(uint64(s.Intn(3)), uint64(s.Intn(40)), uint64(s.Intn(1<<16)))
+		}),
+		field(func() []byte { return d.tagged(tagEnum, d.fitting(gen.Pick(s, 1, 4))) }),
+		field(func() []byte { return d.stringField(tagUTF8, 0.05) }),
+		field(func() []byte { return d.stringField(tagIA5, 0.05) }),
+		field(func() []byte { return d.stringField(tagPrintable, 0.05) }),
+		field(func() []byte { return d.stringField(tagNumeric, 0.05) }),
+		field(func() []byte {
+			if s.Chance(0.1) {
+				return d.generalized()
+			}
+			return d.utc()
+		}),
+		field(func() []byte {
+			if s.Chance(0.1) {
+				return d.utc()
+			}
+			return d.generalized()
+		}),
+		field(func() []byte { return d.tlv(tagOctetString, []byte(d.text())) }),
+		d.any(depth),
+	}
+	var set [][]byte
+	for range s.Range(0, 4) {
+		set = append(set, d.integer(d.fitting(2)))
+	}
+	fields = append(fields, d.set(set...))
+	if s.Chance(0.5) {
+		fields = append(fields, d.explicit(0, d.integer(d.fitting(4))))
+	}
+	if s.Chance(0.5) {
+		// The default, which DER leaves out, now and then written out.
+		v := d.fitting(4)
+		if s.Chance(0.2) {
+			v = big.NewInt(7)
+		}
+		fields = append(fields, d.tagged(context|1, v))
+	}
+	if depth > 0 && s.Chance(0.6) {
+		var kids [][]byte
+		for range s.Range(0, 3) {
+			kids = append(kids, d.value(depth-1))
+		}
+		fields = append(fields, d.explicit(2, d.seq(kids...)))
+	}
+	if s.Chance(0.02) {
+		// A field the struct has no room for.
+		fields = append(fields, d.any(1))
+	}
+	return d.seq(fields...)
+}
+
+// stringField returns a string of type tag holding text that type
+// allows, or, with chance wild, one of another type or holding any text,
+// which a parser or a field's type may reject.
+func (d der) stringField(tag byte, wild float64) []byte {
+	if d.s.Chance(wild) {
+		tag = gen.Pick(d.s, byte(tagUTF8), tagPrintable, tagIA5, tagT61, tagBMP, tagNumeric)
+	}
+	switch tag {
+	case tagNumeric:
+		if !d.s.Chance(wild) {
+			return d.str(tag, gen.Pick(d.s, "", "0", "123 456", "9999999999"))
+		}
+	case tagPrintable:
+		if !d.s.Chance(wild) {
+			return d.str(tag, gen.Pick(d.s, "", "Example", "a b", "a'()+,-./:=?", "US"))
+		}
+	case tagIA5:
+		if !d.s.Chance(wild) {
+			return d.str(tag, gen.Pick(d.s, "", "a@example.com", "*.example.com", "a\x00b", "~"))
+		}
+	}
+	return d.str(tag, d.text())
+}
@@ -0,0 +1,400 @@
+package asn1src
+
+import (
+	"encoding/hex"
+	"math/big"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// corruptRate is the chance that a whole certificate is truncated,
+// extended or has a byte overwritten, on top of its malformed elements.
+const corruptRate = 0.1
+
+// OIDs of the attributes, algorithms and extensions a certificate has.
+var (
+	oidCN        = []uint64{2, 5, 4, 3}
+	oidCountry   = []uint64{2, 5, 4, 6}
+	oidOrg       = []uint64{2, 5, 4, 10}
+	oidOrgUnit   = []uint64{2, 5, 4, 11}
+	oidLocality  = []uint64{2, 5, 4, 7}
+	oidProvince  = []uint64{2, 5, 4, 8}
+	oidSerial    = []uint64{2, 5, 4, 5}
+	oidEmail     = []uint64{1, 2, 840, 113549, 1, 9, 1}
+	oidDomainCmp = []uint64{0, 9, 2342, 19200300, 100, 1, 25}
+
+	oidRSA       = []uint64{1, 2, 840, 113549, 1, 1, 1}
+	oidRSAPSS    = []uint64{1, 2, 840, 113549, 1, 1, 10}
+	oidSHA256RSA = []uint64{1, 2, 840, 113549, 1, 1, 11}
+	oidMD5RSA    = []uint64{1, 2, 840, 113549, 1, 1, 4}
+	oidEC        = []uint64{1, 2, 840, 10045, 2, 1}
+	oidP256      = []uint64{1, 2, 840, 10045, 3, 1, 7}
+	oidP384      = []uint64{1, 3, 132, 0, 34}
+	oidECDSA256  = []uint64{1, 2, 840, 10045, 4, 3, 2}
+	oidEd25519   = []uint64{1, 3, 101, 112}
+
+	oidBasicConstraints = []uint64{2, 5, 29, 19}
+	oidKeyUsage         = []uint64{2, 5, 29, 15}
+	oidExtKeyUsage      = []uint64{2, 5, 29, 37}
+	oidSAN              = []uint64{2, 5, 29, 17}
+	oidSubjectKeyID     = []uint64{2, 5, 29, 14}
+	oidAuthorityKeyID   = []uint64{2, 5, 29, 35}
+	oidNameConstraints  = []uint64{2, 5, 29, 30}
+	oidPolicies         = []uint64{2, 5, 29, 32}
+	oidCRLPoints        = []uint64{2, 5, 29, 31}
+	oidAuthorityInfo    = []uint64{1, 3, 6, 1, 5, 5, 7, 1, 1}
+)
+
+// p256 is the base point of P-256, a public key on the curve that needs
+// no arithmetic to write.
+var p256, _ = hex.DecodeString("04" +
+	"6b17d1f2e12c4247f8bce6e563a440f277037d812deb33a0f4a13945d898c296" +
+	"4fe342e2fe1a7f9b8ee7eb4a7c0f9e162bce33576b315ececbb6406837bf51f5")
+
+// cert writes a certificate.
+func cert(s *gen.State) []gen.File {
+	d := der{s}
+	alg := d.sigAlg()
+	var tbs [][]byte
+	switch {
+	case s.Chance(0.1):
+		// Version 1, which has no version field, or one written out.
+		if s.Chance(0.3) {
+			tbs = append(tbs, d.explicit(0, d.small(0)))
+		}
+	case s.Chance(0.05):
+		tbs = append(tbs, d.explicit(0, d.small(int64(gen.Pick(s, 1, 3, 5, -1, 1<<40)))))
+	default:
+		tbs = append(tbs, d.explicit(0, d.small(2)))
+	}
+	tbs = append(tbs, d.integer(d.serial()), alg, d.name(), d.validity(), d.name(), d.publicKey())
+	if s.Chance(0.05) {
+		// The unique identifiers of version 2.
+		tbs = append(tbs, d.tlv(context|1, []byte{0}, []byte(d.text())))
+		tbs = append(tbs, d.tlv(context|2, []byte{0}, []byte(d.text())))
+	}
+	if !s.Chance(0.1) {
+		tbs = append(tbs, d.explicit(3, d.extensions()))
+	}
+	outer := alg
+	if s.Chance(0.02) {
+		// An outer algorithm that is not the inner one.
+		outer = d.sigAlg()
+	}
+	data := d.seq(d.seq(tbs...), outer, d.bits(gen.Pick(s, 64, 72, 256, 0, 1), 0))
+	if s.Chance(corruptRate) {
+		data = d.corrupt(data)
+	}
+	return []gen.File{{Name: "cert.der", Data: data}}
+}
+
+// corrupt returns data truncated, extended or with a byte overwritten.
+func (d der) corrupt(data []byte) []byte {
+	switch d.s.Intn(3) {
+	case 0:
+		return data[:d.s.Intn(len(data))]
+	case 1:
+		return append(data, gen.Pick(d.s, []byte{0}, []byte{0x30, 0x00}, data)...)
+	}
+	data = slices.Clone(data)
+	data[d.s.Intn(len(data))] = gen.Pick(d.s, byte(0), 0x80, 0xff, 0x30, 0x05)
+	return data
+}
+
+// serial returns a serial number: usually a positive one of up to the
+// 20 bytes RFC 5280 allows, sometimes a zero, negative or longer one.
+func (d der) serial() *big.Int {
+	if d.s.Chance(0.2) {
+		return d.bigInt(gen.Pick(d.s, 21, 32, 128))
+	}
+	b := make([]byte, d.s.Range(1, 20))
+	for i := range b {
+		b[i] = byte(d.s.Intn(256))
+	}
+	return new(big.Int).SetBytes(b)
+}
+
+// sigAlg returns a signature AlgorithmIdentifier.
+func (d der) sigAlg() []byte {
+	switch d.s.Intn(7) {
+	case 0:
+		return d.seq(d.oid(oidECDSA256...))
+	case 1:
+		return d.seq(d.oid(oidEd25519...))
+	case 2:
+		// RSA-PSS, whose parameters are a structure of their own.
+		return d.seq(d.oid(oidRSAPSS...), d.seq(
+			d.explicit(0, d.seq(d.oid(2, 16, 840, 1, 101, 3, 4, 2, 1), d.tlv(tagNull, nil))),
+			d.explicit(1, d.seq(d.oid(1, 2, 840, 113549, 1, 1, 8), d.seq(d.oid(2, 16, 840, 1, 101, 3, 4, 2, 1), d.tlv(tagNull, nil)))),
+			d.explicit(2, d.small(int64(gen.Pick(d.s, 32, 0, -1, 1<<20)))),
+		))
+	case 3:
+		return d.seq(d.oid(gen.Pick(d.s, oidMD5RSA, d.arcs())...))
+	case 4:
+		// RSA without the NULL parameters, or with others.
+		return d.seq(d.oid(oidSHA256RSA...), gen.Pick(d.s, nil, d.small(0), d.seq()))
+	}
+	return d.seq(d.oid(oidSHA256RSA...), d.tlv(tagNull, nil))
+}
+
+// name returns a Name: a sequence of sets of attributes, some sets with
+// more than one.
+func (d der) name() []byte {
+	var rdns [][]byte
+	for range d.s.Range(0, 5) {
+		var atvs [][]byte
+		for range gen.Pick(d.s, 1, 1, 1, 2, 0) {
+			atvs = append(atvs, d.attribute())
+		}
+		rdns = append(rdns, d.set(atvs...))
+	}
+	if d.s.Chance(0.05) {
+		// A name nested deep in sets of names.
+		for range d.s.Depth(d.s.Limits.Literal, 20) {
+			rdns = [][]byte{d.set(d.seq(d.oid(oidCN...), d.seq(rdns...)))}
+		}
+	}
+	return d.seq(rdns...)
+}
+
+// attribute returns an attribute type and value.
+func (d der) attribute() []byte {
+	oid := gen.Pick(d.s, oidCN, oidCN, oidCountry, oidOrg, oidOrgUnit, oidLocality, oidProvince, oidSerial, oidEmail, oidDomainCmp, []uint64{1, 2, 3, 4})
+	if d.s.Chance(0.03) {
+		oid = d.arcs()
+	}
+	v := d.stringField(gen.Pick(d.s, byte(tagUTF8), tagUTF8, tagPrintable, tagPrintable, tagIA5, tagT61, tagBMP, tagUniversal, tagNumeric), 0.02)
+	if d.s.Chance(0.02) {
+		// A value that is no string at all.
+		v = d.any(1)
+	}
+	return d.seq(d.oid(oid...), v)
+}
+
+// validity returns the period a certificate is valid for, in the time
+// type RFC 5280 asks for or the other one.
+func (d der) validity() []byte {
+	t := func() []byte {
+		if d.s.Chance(0.3) {
+			return d.generalized()
+		}
+		return d.utc()
+	}
+	return d.seq(t(), t())
+}
+
+// publicKey returns a SubjectPublicKeyInfo.
+func (d der) publicKey() []byte {
+	switch d.s.Intn(5) {
+	case 0, 1:
+		n := new(big.Int).Lsh(big.NewInt(1), uint(gen.Pick(d.s, 1023, 2047, 511, 7, 8191)))
+		n.Add(n, big.NewInt(int64(d.s.Intn(1<<16)|1)))
+		e := big.NewInt(65537)
+		if d.s.Chance(0.2) {
+			n = d.bigInt(gen.Pick(d.s, 1, 8, 300))
+			e, _ = new(big.Int).SetString(gen.Pick(d.s, "3", "1", "0", "-3", "2147483647", "2147483648", "18446744073709551617"), 10)
+		}
+		params := d.tlv(tagNull, nil)
+		if d.s.Chance(0.05) {
+			params = gen.Pick(d.s, nil, d.small(0))
+		}
+		return d.seq(d.seq(d.oid(oidRSA...), params), d.tlv(tagBitString, []byte{0}, d.seq(d.integer(n), d.integer(e))))
+	case 2:
+		key := slices.Clone(p256)
+		curve := oidP256
+		if d.s.Chance(0.2) {
+			switch d.s.Intn(4) {
+			case 0:
+				key[len(key)-1] ^= 1 // a point off the curve
+			case 1:
+				key = slices.Concat([]byte{0x02}, key[1:33]) // a compressed point
+			case 2:
+				curve = gen.Pick(d.s, oidP384, d.arcs()) // a curve the point is not on
+			default:
+				key = key[:d.s.Intn(len(key))]
+			}
+		}
+		return d.seq(d.seq(d.oid(oidEC...), d.oid(curve...)), d.tlv(tagBitString, []byte{0}, key))
+	case 3:
+		key := make([]byte, 32)
+		if d.s.Chance(0.1) {
+			key = make([]byte, gen.Pick(d.s, 31, 33, 0))
+		}
+		for i := range key {
+			key[i] = byte(d.s.Intn(256))
+		}
+		alg := d.seq(d.oid(oidEd25519...))
+		if d.s.Chance(0.05) {
+			alg = d.seq(d.oid(oidEd25519...), d.tlv(tagNull, nil))
+		}
+		return d.seq(alg, d.tlv(tagBitString, []byte{0}, key))
+	}
+	// An algorithm no parser knows, with a key of any shape.
+	return d.seq(d.seq(d.oid(d.arcs()...), d.any(1)), d.bits(d.s.Range(0, 40), d.s.Intn(8)))
+}
+
+// extensions returns the Extensions of a certificate.
+func (d der) extensions() []byte {
+	// Kinds of extension, each drawn once, as a certificate may have
+	// each extension once.
+	kinds := make([]int, 11)
+	for i := range kinds {
+		j := d.s.Intn(i + 1)
+		kinds[i], kinds[j] = kinds[j], i
+	}
+	var exts [][]byte
+	for _, k := range kinds[:d.s.Range(0, 8)] {
+		exts = append(exts, d.extension(k))
+	}
+	if len(exts) > 0 && d.s.Chance(0.05) {
+		exts = append(exts, exts[d.s.Intn(len(exts))]) // a duplicate
+	}
+	return d.seq(exts...)
+}
+
+// extension returns an extension of the given kind, critical or not.
+func (d der) extension(kind int) []byte {
+	oid, v := d.extValue(kind)
+	fields := [][]byte{d.oid(oid...)}
+	switch {
+	case d.s.Chance(0.2):
+		fields = append(fields, d.boolean(true))
+	case d.s.Chance(0.02):
+		fields = append(fields, d.boolean(false)) // the default, which DER leaves out
+	}
+	return d.seq(append(fields, d.tlv(tagOctetString, v))...)
+}
+
+// extValue returns the OID and value of an extension of the given kind,
+// the last of which are ones no parser knows.
+func (d der) extValue(kind int) ([]uint64, []byte) {
+	switch kind {
+	case 0:
+		var fields [][]byte
+		if d.s.Chance(0.8) {
+			fields = append(fields, d.boolean(d.s.Chance(0.9)))
+		}
+		if d.s.Chance(0.5) {
+			fields = append(fields, d.integer(gen.Pick(d.s, big.NewInt(0), big.NewInt(1), big.NewInt(-1), d.bigInt(9))))
+		}
+		return oidBasicConstraints, d.seq(fields...)
+	case 1:
+		// Key usage bits, usually in a byte or two, sometimes in many.
+		return oidKeyUsage, d.bits(gen.Pick(d.s, 1, 1, 2, 0, 3, 64), d.s.Intn(8))
+	case 2:
+		var usages [][]byte
+		for range d.s.Range(0, 4) {
+			if d.s.Chance(0.2) {
+				usages = append(usages, d.oid(d.arcs()...))
+				continue
+			}
+			usages = append(usages, d.oid(gen.Pick(d.s,
+				[]uint64{1, 3, 6, 1, 5, 5, 7, 3, uint64(d.s.Range(1, 9))}, []uint64{2, 5, 29, 37, 0},
+				[]uint64{1, 3, 6, 1, 4, 1, 311, 10, 3, 3}, []uint64{2, 16, 840, 1, 113730, 4, 1},
+			)...))
+		}
+		return oidExtKeyUsage, d.seq(usages...)
+	case 3:
+		var names [][]byte
+		for range d.s.Range(0, 5) {
+			names = append(names, d.generalName())
+		}
+		return oidSAN, d.seq(names...)
+	case 4:
+		return oidSubjectKeyID, d.tlv(tagOctetString, d.bytes(gen.Pick(d.s, 20, 0, 64)))
+	case 5:
+		return oidAuthorityKeyID, d.seq(d.tlv(context, d.bytes(gen.Pick(d.s, 20, 0, 64))))
+	case 6:
+		// Permitted and excluded subtrees, each a sequence implicitly
+		// tagged.
+		subtrees := func(n int) []byte {
+			var trees [][]byte
+			for range d.s.Range(1, 3) {
+				trees = append(trees, d.seq(d.constraintName()))
+			}
+			return d.tlv(context|constructed|byte(n), trees...)
+		}
+		var fields [][]byte
+		if d.s.Chance(0.7) {
+			fields = append(fields, subtrees(0))
+		}
+		if len(fields) == 0 || d.s.Chance(0.4) {
+			fields = append(fields, subtrees(1))
+		}
+		return oidNameConstraints, d.seq(fields...)
+	case 7:
+		var policies [][]byte
+		for range d.s.Range(1, 3) {
+			policy := gen.Pick(d.s, []uint64{2, 5, 29, 32, 0}, []uint64{2, 23, 140, 1, 2, 1}, []uint64{1, 3, 6, 1, 4, 1, 44947, 1, 1, 1})
+			if d.s.Chance(0.2) {
+				policy = d.arcs()
+			}
+			p := [][]byte{d.oid(policy...)}
+			if d.s.Chance(0.3) {
+				p = append(p, d.seq(d.seq(d.oid(1, 3, 6, 1, 5, 5, 7, 2, 1), d.str(tagIA5, "http://example.com/cps"))))
+			}
+			policies = append(policies, d.seq(p...))
+		}
+		return oidPolicies, d.seq(policies...)
+	case 8:
+		uri := d.tlv(context|6, []byte(d.uri()))
+		return oidCRLPoints, d.seq(d.seq(d.explicit(0, d.tlv(context|constructed, uri))))
+	case 9:
+		var access [][]byte
+		for range d.s.Range(1, 2) {
+			method := gen.Pick(d.s, []uint64{1, 3, 6, 1, 5, 5, 7, 48, 1}, []uint64{1, 3, 6, 1, 5, 5, 7, 48, 2})
+			access = append(access, d.seq(d.oid(method...), d.tlv(context|6, []byte(d.uri()))))
+		}
+		return oidAuthorityInfo, d.seq(access...)
+	}
+	// An extension no parser knows, holding anything or nothing at all.
+	return d.arcs(), gen.Pick(d.s, d.any(2), nil, []byte(d.text()))
+}
+
+// bytes returns n random bytes.
+func (d der) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(d.s.Intn(256))
+	}
+	return b
+}
+
+// generalName returns a GeneralName of a subject alternative name.
+func (d der) generalName() []byte {
+	switch d.s.Intn(7) {
+	case 0, 1:
+		return d.tlv(context|2, []byte(gen.Pick(d.s, "example.com", "*.example.com", "a.*.example.com", "例え.jp", "xn--r8jz45g.jp", "", ".", "a..b", "localhost", "EXAMPLE.COM.", "a b", d.text())))
+	case 2:
+		return d.tlv(context|1, []byte(gen.Pick(d.s, "a@example.com", "@example.com", "a@", "a@b@c", "\"a b\"@example.com", "名前@example.com", d.text())))
+	case 3:
+		return d.tlv(context|7, d.bytes(gen.Pick(d.s, 4, 16, 0, 5, 8, 32)))
+	case 4:
+		return d.tlv(context|6, []byte(d.uri()))
+	case 5:
+		return d.explicit(4, d.name())
+	}
+	// An otherName, which is an OID and a value.
+	return d.tlv(context|constructed, d.oid(d.arcs()...), d.explicit(0, d.str(tagUTF8, d.text())))
+}
+
+// constraintName returns a GeneralName of a name constraint: an IP
+// address with a mask, or a domain, address or URI domain.
+func (d der) constraintName() []byte {
+	switch d.s.Intn(4) {
+	case 0:
+		ip := gen.Pick(d.s, []byte{10, 0, 0, 0, 255, 0, 0, 0}, []byte{10, 0, 0, 0, 255, 0, 255, 0}, []byte{10, 0, 0, 0}, d.bytes(32), d.bytes(33))
+		return d.tlv(context|7, ip)
+	case 1:
+		return d.tlv(context|1, []byte(gen.Pick(d.s, "example.com", ".example.com", "a@example.com", "@", "")))
+	case 2:
+		return d.tlv(context|6, []byte(gen.Pick(d.s, "example.com", ".example.com", "http://example.com", "")))
+	}
+	return d.tlv(context|2, []byte(gen.Pick(d.s, "example.com", ".example.com", "", ".", "*.example.com", "a..b")))
+}
+
+// uri returns a URI, some of which do not parse.
+func (d der) uri() string {
+	return gen.Pick(d.s, "http://example.com/crl", "https://例え.jp/", "ldap:///cn=x", "http://[::1]/", "http://[::1", "http://a b/", "", "%zz", "http://example.com:99999/", "urn:x")
+}
@@ -0,0 +1,420 @@
+// Package bencodesrc generates seeds for bencode decoders. It registers
+// the "bencode/..." generators with package gen.
+//
+// "bencode/torrent" writes a metainfo file, input.torrent, as BEP 3 has
+// it and BEP 52 extends it: announce URLs and tiers of them, a comment
+// and creation date, and an info dict of a single file or a list of
+// files, with piece hashes, a v2 file tree of dicts nested by path and
+// piece layers; names and paths in UTF-8 and not; and keys no client
+// knows, holding anything. "bencode/krpc" writes a DHT message,
+// input.bencode, as BEP 5 and BEP 44 have them: queries, responses and
+// errors, with node IDs, compact nodes and peers, tokens and stored
+// items. Integers run from small to past 64 bits, and dict keys are
+// sorted as raw bytes. Now and then an integer has leading zeros, a plus
+// sign, no digits or is minus zero; a string length has leading zeros or
+// a sign, or runs short or past the end; keys are out of order,
+// repeated, or not strings; lists nest deeper than a decoder should; or
+// the value is cut short or followed by more.
+package bencodesrc
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "bencode/torrent",
+		Doc:  "BitTorrent metainfo files: announce tiers, single- and multi-file info dicts, piece hashes, v2 file trees and piece layers, non-UTF-8 names and keys, integers past 64 bits, with leading zeros, minus zero, bad string lengths, unsorted and repeated keys, deep nesting and truncation",
+		Func: torrentFile,
+	})
+	gen.Register(&gen.Generator{
+		Name: "bencode/krpc",
+		Doc:  "DHT KRPC messages: ping, find_node, get_peers, announce_peer, get and put queries, responses with compact nodes and peers, and errors, with the same integer, length, key order and truncation faults",
+		Func: krpcFile,
+	})
+}
+
+// badRate is the chance that an integer or string breaks a rule of
+// bencode; a dict breaks one at four times the rate, and so does the
+// value as a whole. A metainfo file has fifty integers and strings and
+// ten dicts or so, so about one in three breaks a rule; a KRPC message
+// has fewer.
+const badRate = 0.005
+
+// A dict is a bencode dict as a generator builds it, its pairs in any
+// order; encoding sorts them.
+type dict []pair
+
+type pair struct {
+	key string
+	val any
+}
+
+// A literal is an integer written out, for those too large for an int64.
+type literal string
+
+// An encoder writes values as bencode, breaking a rule now and then.
+// Values are int64, literal, string, []any and dict.
+type encoder struct {
+	s   *gen.State
+	out []byte
+}
+
+// value appends v.
+func (e *encoder) value(v any) {
+	switch v := v.(type) {
+	case int64:
+		e.integer(strconv.FormatInt(v, 10))
+	case literal:
+		e.integer(string(v))
+	case string:
+		e.str(v)
+	case []any:
+		e.out = append(e.out, 'l')
+		for _, x := range v {
+			e.value(x)
+		}
+		e.out = append(e.out, 'e')
+	case dict:
+		e.dict(v)
+	}
+}
+
+// integer appends an integer of decimal digits n, now and then written
+// in a way BEP 3 forbids or not as an integer at all.
+func (e *encoder) integer(n string) {
+	s := e.s
+	if s.Chance(badRate) {
+		n = gen.Pick(s, "0"+n, "00", "+"+n, "-0", "", "-", "--"+n, n+".5", " "+n, "1e3", "0x1f", strings.TrimPrefix(n, "-")+"-")
+	}
+	e.out = append(e.out, 'i')
+	e.out = append(e.out, n...)
+	e.out = append(e.out, 'e')
+}
+
+// str appends b with its length, now and then a length written with
+// leading zeros or a sign, or one that does not match.
+func (e *encoder) str(b string) {
+	s := e.s
+	n := strconv.Itoa(len(b))
+	if s.Chance(badRate) {
+		n = gen.Pick(s, "0"+n, "+"+n, "-"+n, strconv.Itoa(len(b)+1), strconv.Itoa(len(b)+s.Range(2, 1<<20)), strconv.Itoa(max(len(b)-1, 0)), "4294967296", "99999999999999999999")
+	}
+	e.out = append(e.out, n...)
+	e.out = append(e.out, ':')
+	e.out = append(e.out, b...)
+}
+
+// dict appends d with its keys sorted as raw bytes, which BEP 3 requires
+// and infohashes depend on; now and then out of order, with a key
+// repeated, or with a key that is not a string.
+func (e *encoder) dict(d dict) {
+	s := e.s
+	d = slices.Clone(d)
+	if s.Chance(badRate * 4) {
+		gen.Shuffle(s, d)
+	} else {
+		slices.SortStableFunc(d, func(a, b pair) int { return strings.Compare(a.key, b.key) })
+	}
+	if len(d) > 0 && s.Chance(badRate*4) {
+		i := s.Intn(len(d))
+		d = slices.Insert(d, i+1, pair{d[i].key, gen.Pick(s, any(int64(0)), any(""), d[i].val)})
+	}
+	e.out = append(e.out, 'd')
+	for _, p := range d {
+		if s.Chance(badRate) {
+			e.value(gen.Pick(s, any(int64(1)), any([]any{}), any(dict{})))
+		} else {
+			e.str(p.key)
+		}
+		e.value(p.val)
+	}
+	e.out = append(e.out, 'e')
+}
+
+// finish returns what e has written, now and then nested deep in lists,
+// cut short or followed by more.
+func (e *encoder) finish() []byte {
+	s := e.s
+	if s.Chance(badRate * 4) {
+		n := s.Range(2, 1200)
+		e.out = append(append([]byte(strings.Repeat("l", n)), e.out...), strings.Repeat("e", n)...)
+	}
+	switch {
+	case s.Chance(badRate * 8):
+		e.out = e.out[:s.Intn(len(e.out))]
+	case s.Chance(badRate * 4):
+		e.out = append(e.out, gen.Pick(s, "e", "i0e", "\n", "0:", "d", "x")...)
+	}
+	return e.out
+}
+
+// ints are integers at the edges of the sizes decoders store them in.
+var ints = []int64{0, 1, -1, 7, 42, 255, -256, 1 << 14, 1 << 18, 1 << 31, -1 << 31, 1 << 32, 1<<53 + 1, 1<<63 - 1, -1 << 63}
+
+// bigs are integers just past an int64 and far past it.
+var bigs = []literal{
+	"9223372036854775808", "-9223372036854775809", "18446744073709551615", "18446744073709551616",
+	"-18446744073709551616", "340282366920938463463374607431768211456",
+}
+
+// names are file and directory names: plain, with separators and dots a
+// client must not follow, and in encodings other than UTF-8.
+var names = []string{
+	"ubuntu-24.04-desktop-amd64.iso", "a", "README.txt", "Season 1", "ü.mkv", "水.flac", "..", ".", "",
+	"a/b", "..\\..\\boot.ini", "\x00", "CON", "\xfc\xe9.txt", "\xff\xfe", "\xed\xa0\x80", "caf\xe9",
+}
+
+// keys are dict keys: of metainfo, of KRPC, unknown, empty and not UTF-8.
+var keys = []string{"", "a", "b", "info", "length", "name", "x", "zz", "\x00", "\xff", "\xc3(", "\xe9t\xe9", "\x80abc", "A", "aa"}
+
+// someInt returns an integer, now and then one too large for an int64.
+func someInt(s *gen.State) any {
+	if s.Chance(0.1) {
+		return gen.Pick(s, bigs...)
+	}
+	if s.Chance(0.05) {
+		n := strings.Repeat("9", s.Range(20, 80))
+		if s.Chance(0.5) {
+			n = "-" + n
+		}
+		return literal(n)
+	}
+	return gen.Pick(s, ints...)
+}
+
+// bytesOf returns n bytes of s's choosing, as a hash or ID is.
+func bytesOf(s *gen.State, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	return string(b)
+}
+
+// anyValue returns a value nested at most depth deep.
+func anyValue(s *gen.State, depth int) any {
+	n := 4
+	if depth > 0 {
+		n = 6
+	}
+	switch s.Intn(n) {
+	case 0, 1:
+		return someInt(s)
+	case 2:
+		return gen.Pick(s, names...)
+	case 3:
+		return bytesOf(s, gen.Pick(s, 0, 1, 20, 32))
+	case 4:
+		l := make([]any, s.Range(0, 4))
+		for i := range l {
+			l[i] = anyValue(s, depth-1)
+		}
+		return l
+	}
+	d := make(dict, s.Range(0, 4))
+	for i := range d {
+		d[i] = pair{gen.Pick(s, keys...), anyValue(s, depth-1)}
+	}
+	return d
+}
+
+// extra returns d with a few keys no client knows, now and then.
+func extra(s *gen.State, d dict) dict {
+	for s.Chance(0.2) {
+		d = append(d, pair{gen.Pick(s, keys...), anyValue(s, 2)})
+	}
+	return d
+}
+
+func torrentFile(s *gen.State) []gen.File {
+	e := &encoder{s: s}
+	e.value(metainfo(s))
+	return []gen.File{{Name: "input.torrent", Data: e.finish()}}
+}
+
+// metainfo returns a metainfo dict.
+func metainfo(s *gen.State) dict {
+	d := dict{{"announce", gen.Pick(s, "http://tracker.example.com:6969/announce", "udp://tracker.example.org:1337", "", "\xff")}}
+	if s.Chance(0.5) {
+		tiers := make([]any, s.Range(0, 3))
+		for i := range tiers {
+			tiers[i] = []any{"udp://t" + strconv.Itoa(i) + ".example.net:80/announce", "wss://tracker.example.com"}
+		}
+		d = append(d, pair{"announce-list", tiers})
+	}
+	if s.Chance(0.5) {
+		d = append(d, pair{"comment", gen.Pick(s, names...)}, pair{"created by", "mktorrent 1.1"})
+	}
+	if s.Chance(0.5) {
+		d = append(d, pair{"creation date", gen.Pick(s, any(int64(1700000000)), any(int64(-1)), someInt(s))})
+	}
+	if s.Chance(0.3) {
+		d = append(d, pair{"encoding", gen.Pick(s, "UTF-8", "ISO-8859-1", "")})
+	}
+	if s.Chance(0.2) {
+		d = append(d, pair{"url-list", gen.Pick(s, any("http://mirror.example.com/"), any([]any{"http://a.example/", "ftp://b.example/"}))})
+	}
+	v2 := s.Chance(0.3)
+	if v2 && s.Chance(0.7) {
+		d = append(d, pair{"piece layers", dict{{bytesOf(s, 32), bytesOf(s, 32*s.Range(1, 3))}}})
+	}
+	return extra(s, append(d, pair{"info", info(s, v2)}))
+}
+
+// info returns an info dict, of version 1, or of version 2 as well if v2
+// is set.
+func info(s *gen.State, v2 bool) dict {
+	pieceLength := gen.Pick(s, 1<<14, 1<<18, 1<<20)
+	d := dict{
+		{"name", gen.Pick(s, names...)},
+		{"piece length", gen.Pick(s, any(int64(pieceLength)), any(int64(0)), any(int64(-1)), someInt(s))},
+	}
+	files := s.Range(1, 3)
+	if !v2 || s.Chance(0.5) {
+		d = append(d, pair{"pieces", bytesOf(s, 20*gen.Pick(s, 0, 1, 3))})
+		if s.Chance(0.5) {
+			d = append(d, pair{"length", someInt(s)})
+		} else {
+			list := make([]any, files)
+			for i := range list {
+				path := make([]any, s.Range(1, 3))
+				for j := range path {
+					path[j] = gen.Pick(s, names...)
+				}
+				list[i] = extra(s, dict{{"length", someInt(s)}, {"path", path}})
+			}
+			d = append(d, pair{"files", list})
+		}
+	}
+	if v2 {
+		d = append(d, pair{"meta version", gen.Pick(s, int64(2), 1, 3)}, pair{"file tree", fileTree(s, s.Depth(0, 3))})
+	}
+	if s.Chance(0.2) {
+		d = append(d, pair{"private", gen.Pick(s, int64(1), 0)})
+	}
+	return extra(s, d)
+}
+
+// fileTree returns a BEP 52 file tree nested at most depth deep: dicts
+// keyed by path component, with a file's attributes under the empty key.
+func fileTree(s *gen.State, depth int) dict {
+	d := make(dict, s.Range(1, 3))
+	for i := range d {
+		name := gen.Pick(s, names...)
+		if depth <= 0 || s.Chance(0.5) {
+			file := dict{{"length", someInt(s)}}
+			if s.Chance(0.7) {
+				file = append(file, pair{"pieces root", bytesOf(s, 32)})
+			}
+			d[i] = pair{name, dict{{"", file}}}
+			continue
+		}
+		d[i] = pair{name, fileTree(s, depth-1)}
+	}
+	return d
+}
+
+// nodes returns n nodes in compact form: a 20-byte ID and an IPv4
+// address and port, or for nodes6 an IPv6 one.
+func nodes(s *gen.State, n, addr int) string {
+	return bytesOf(s, n*(20+addr+2))
+}
+
+func krpcFile(s *gen.State) []gen.File {
+	e := &encoder{s: s}
+	e.value(message(s))
+	return []gen.File{{Name: "input.bencode", Data: e.finish()}}
+}
+
+// message returns a KRPC query, response or error.
+func message(s *gen.State) dict {
+	d := dict{{"t", bytesOf(s, gen.Pick(s, 2, 4, 0))}}
+	if s.Chance(0.5) {
+		d = append(d, pair{"v", gen.Pick(s, "LT\x01\x02", "UT\xb5\x04", "")})
+	}
+	if s.Chance(0.2) {
+		d = append(d, pair{"ip", bytesOf(s, gen.Pick(s, 6, 18))})
+	}
+	switch s.Intn(3) {
+	case 0:
+		q := gen.Pick(s, "ping", "find_node", "get_peers", "announce_peer", "get", "put", "sample_infohashes", "vote")
+		d = append(d, pair{"y", "q"}, pair{"q", q}, pair{"a", arguments(s, q)})
+		if s.Chance(0.2) {
+			d = append(d, pair{"ro", int64(1)})
+		}
+	case 1:
+		d = append(d, pair{"y", "r"}, pair{"r", response(s)})
+	default:
+		e := []any{gen.Pick(s, any(int64(201)), any(int64(202)), any(int64(203)), any(int64(204)), any(int64(301)), someInt(s)), gen.Pick(s, "A Generic Error Ocurred", "Protocol Error, such as a malformed packet, invalid arguments, or bad token", "")}
+		if s.Chance(badRate * 20) {
+			e = e[:s.Intn(2)]
+		}
+		d = append(d, pair{"y", "e"}, pair{"e", e})
+	}
+	return extra(s, d)
+}
+
+// arguments returns the arguments of a query of method q.
+func arguments(s *gen.State, q string) dict {
+	a := dict{{"id", bytesOf(s, gen.Pick(s, 20, 20, 19, 0))}}
+	switch q {
+	case "find_node":
+		a = append(a, pair{"target", bytesOf(s, 20)})
+	case "get_peers", "sample_infohashes":
+		a = append(a, pair{"info_hash", bytesOf(s, 20)})
+	case "announce_peer":
+		a = append(a, pair{"info_hash", bytesOf(s, 20)}, pair{"port", gen.Pick(s, any(int64(6881)), any(int64(0)), any(int64(65536)), someInt(s))},
+			pair{"token", bytesOf(s, 8)}, pair{"implied_port", gen.Pick(s, int64(0), 1)})
+	case "get":
+		a = append(a, pair{"target", bytesOf(s, 20)})
+		if s.Chance(0.5) {
+			a = append(a, pair{"seq", someInt(s)})
+		}
+	case "put":
+		a = append(a, pair{"token", bytesOf(s, 8)}, pair{"v", anyValue(s, 3)})
+		if s.Chance(0.6) {
+			a = append(a, pair{"k", bytesOf(s, 32)}, pair{"sig", bytesOf(s, 64)}, pair{"seq", someInt(s)})
+			if s.Chance(0.3) {
+				a = append(a, pair{"cas", someInt(s)})
+			}
+			if s.Chance(0.3) {
+				a = append(a, pair{"salt", bytesOf(s, s.Range(0, 64))})
+			}
+		}
+	}
+	if s.Chance(0.2) {
+		a = append(a, pair{"want", []any{"n4", "n6"}})
+	}
+	return extra(s, a)
+}
+
+// response returns the values of a response.
+func response(s *gen.State) dict {
+	r := dict{{"id", bytesOf(s, 20)}}
+	if s.Chance(0.6) {
+		r = append(r, pair{"nodes", nodes(s, s.Range(0, 8), 4)})
+	}
+	if s.Chance(0.2) {
+		r = append(r, pair{"nodes6", nodes(s, s.Range(0, 4), 16)})
+	}
+	if s.Chance(0.4) {
+		r = append(r, pair{"token", bytesOf(s, 8)})
+		values := make([]any, s.Range(0, 4))
+		for i := range values {
+			values[i] = bytesOf(s, gen.Pick(s, 6, 6, 18, 5))
+		}
+		r = append(r, pair{"values", values})
+	}
+	if s.Chance(0.2) {
+		r = append(r, pair{"v", anyValue(s, 3)}, pair{"seq", someInt(s)})
+	}
+	if s.Chance(0.1) {
+		r = append(r, pair{"samples", bytesOf(s, 20*s.Range(0, 4))}, pair{"interval", someInt(s)}, pair{"num", someInt(s)})
+	}
+	return extra(s, r)
+}
@@ -0,0 +1,223 @@
+// Package bigsrc generates seeds for the text parsers of math/big. It
+// registers the "big/..." generators with package gen.
+//
+// "big/int" writes an integer, input.int, as Int.SetString reads it in
+// some base: with or without a base prefix, with underscores where Go
+// literals allow them and where they do not, in the digits of bases up
+// to 62, and as long as a protocol field might let it be. Values sit at
+// the edges of the machine integers, where a parser that falls back to
+// big numbers takes over from one that does not.
+//
+// "big/float" writes a precision on a line of its own and a number,
+// input.float, as Float.Parse reads it: decimal, binary, octal or
+// hexadecimal mantissas with a decimal or binary exponent, the exponent
+// at the float64 limits, at the int32 limits of a Float's exponent and
+// past them, and infinities. Precisions are the usual ones, one bit, and
+// those at and just under MaxPrec, where the sums a parser makes with a
+// precision overflow.
+//
+// "big/rat" writes a fraction or a floating-point number, input.rat, as
+// Rat.SetString reads it, with exponents at and past the bounds Rat puts
+// on them and denominators of zero.
+package bigsrc
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "big/int",
+		Doc:  "big.Int.SetString text: base prefixes and bases up to 62, underscores in and out of place, values at the int64 and uint64 limits and digit strings thousands long",
+		Func: integer,
+	})
+	gen.Register(&gen.Generator{
+		Name: "big/float",
+		Doc:  "big.Float.Parse text with a precision: binary, octal, decimal and hexadecimal mantissas, decimal and binary exponents at the float64 and int32 limits and past them, infinities, and precisions of one bit and at MaxPrec",
+		Func: float,
+	})
+	gen.Register(&gen.Generator{
+		Name: "big/rat",
+		Doc:  "big.Rat.SetString text: fractions with prefixed numerators and denominators, zero denominators, and floating-point forms with exponents at and past Rat's bounds",
+		Func: rat,
+	})
+}
+
+// badRate is the chance that a part of a number is malformed. A number
+// has a sign, a prefix, digits and an exponent, so about one in twenty
+// gets one.
+const badRate = 0.015
+
+const digits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// edges are integers at the limits of the machine integers.
+var edges = []string{
+	"0", "1", "127", "128", "255", "256", "32767", "32768", "65535", "65536",
+	"2147483647", "2147483648", "4294967295", "4294967296",
+	"9223372036854775807", "9223372036854775808", "18446744073709551615", "18446744073709551616",
+	"99999999999999999999", "340282366920938463463374607431768211456",
+}
+
+func integer(s *gen.State) []gen.File {
+	var b strings.Builder
+	b.WriteString(sign(s))
+	if s.Chance(0.3) {
+		b.WriteString(gen.Pick(s, edges...))
+	} else {
+		prefix, base := prefix(s, true)
+		b.WriteString(prefix)
+		b.WriteString(digitString(s, base, prefix != ""))
+	}
+	return []gen.File{{Name: "input.int", Data: []byte(b.String())}}
+}
+
+// sign returns a sign, or none.
+func sign(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "--", "+-", " ", "−")
+	}
+	return gen.Pick(s, "", "", "", "-", "+")
+}
+
+// prefix returns a base prefix, or none, and the base of the digits after
+// it. octal is whether a bare "0" counts as an octal prefix, as it does
+// for integers.
+func prefix(s *gen.State, octal bool) (string, int) {
+	switch {
+	case s.Chance(0.4):
+		return "", gen.Pick(s, 10, 10, 10, 2, 8, 16, 36, 37, 62)
+	case s.Chance(badRate * 2):
+		return gen.Pick(s, "0x_", "0_x", "x", "0z", "00x"), 16
+	}
+	p := gen.Pick(s, "0x", "0X", "0b", "0B", "0o", "0O", "0")
+	if p == "0" && !octal {
+		return "0", 10
+	}
+	switch strings.ToLower(p) {
+	case "0x":
+		return p, 16
+	case "0b":
+		return p, 2
+	}
+	return p, 8
+}
+
+// digitString returns digits in base, with underscores between them now
+// and then if under is set.
+func digitString(s *gen.State, base int, under bool) string {
+	n := gen.Pick(s, 1, 1, 2, 3, 8, 19, 20, 64, s.Range(1, 5000))
+	var b strings.Builder
+	if under && s.Chance(0.1) {
+		b.WriteByte('_')
+	}
+	for i := range n {
+		if under && i > 0 && s.Chance(0.1) {
+			b.WriteByte('_')
+		}
+		if s.Chance(badRate / 4) {
+			b.WriteString(gen.Pick(s, "__", "_", " ", ".", "٣", "１", "z"))
+		}
+		b.WriteByte(digits[s.Intn(base)])
+	}
+	if s.Chance(badRate) {
+		b.WriteString(gen.Pick(s, "_", "\x00", "n", "L", "e5"))
+	}
+	return b.String()
+}
+
+// precisions are precisions a Float is given: the default, the sizes of
+// the IEEE formats, one bit, and those near MaxPrec.
+var precisions = []uint{
+	0, 0, 1, 2, 11, 24, 53, 53, 64, 64, 113, 237, 256, 1000, 1 << 16,
+	1<<31 - 1, 1 << 31, big.MaxPrec - 64, big.MaxPrec - 1, big.MaxPrec,
+}
+
+// exponents are exponents near the limits of float64, of a Float's int32
+// exponent and of an int64, and past them.
+var exponents = []string{
+	"0", "1", "-1", "10", "-10", "+5", "308", "309", "-307", "-308", "-323", "-324", "-325",
+	"1023", "1024", "-1022", "-1074", "-1075", "-1076", "38", "-45", "-149", "-150",
+	"2147483647", "2147483648", "-2147483648", "-2147483649", "1000000", "-1000000", "1000001", "10000000", "-10000001",
+	"9223372036854775807", "-9223372036854775808", "99999999999999999999",
+}
+
+func float(s *gen.State) []gen.File {
+	prec := gen.Pick(s, precisions...)
+	if s.Chance(0.2) {
+		prec = uint(s.Range(1, 4096))
+	}
+	text := floatText(s, false)
+	return []gen.File{{Name: "input.float", Data: []byte(strconv.FormatUint(uint64(prec), 10) + "\n" + text)}}
+}
+
+// floatText returns a floating-point number, which for a Rat, if rat is
+// set, has no infinities.
+func floatText(s *gen.State, rat bool) string {
+	var b strings.Builder
+	b.WriteString(sign(s))
+	if !rat && s.Chance(0.05) {
+		b.WriteString(gen.Pick(s, "Inf", "inf", "INF", "Infinity", "NaN"))
+		return b.String()
+	}
+	prefix, base := prefix(s, false)
+	b.WriteString(prefix)
+	base = min(base, 16) // Parse reads no larger base
+	switch {
+	case s.Chance(0.15):
+		b.WriteString(gen.Pick(s, edges...))
+	case s.Chance(0.6):
+		b.WriteString(digitString(s, base, prefix != ""))
+		if s.Chance(0.6) {
+			b.WriteByte('.')
+			if s.Chance(0.8) {
+				b.WriteString(digitString(s, base, prefix != ""))
+			}
+		}
+	default:
+		b.WriteByte('.')
+		b.WriteString(digitString(s, base, prefix != ""))
+	}
+	if s.Chance(0.6) {
+		e := gen.Pick(s, "e", "E", "p", "P")
+		if base == 16 && s.Chance(0.9) {
+			e = gen.Pick(s, "p", "P")
+		}
+		b.WriteString(e)
+		if s.Chance(0.5) {
+			b.WriteString(gen.Pick(s, exponents...))
+		} else {
+			b.WriteString(strconv.Itoa(s.Range(-400, 400)))
+		}
+		if s.Chance(badRate) {
+			b.WriteString(gen.Pick(s, "_", ".5", "e1", "-", ""))
+		}
+	}
+	return b.String()
+}
+
+func rat(s *gen.State) []gen.File {
+	var text string
+	if s.Chance(0.5) {
+		text = floatText(s, true)
+	} else {
+		num := sign(s)
+		p, base := prefix(s, true)
+		num += p + digitString(s, base, false)
+		den := "1"
+		switch {
+		case s.Chance(0.1):
+			den = gen.Pick(s, "0", "00", "0x0", "-1", "+2", "", "1/2", "1e3")
+		case s.Chance(0.3):
+			den = gen.Pick(s, edges...)
+		default:
+			p, base := prefix(s, true)
+			den = p + digitString(s, base, false)
+		}
+		text = num + "/" + den
+	}
+	return []gen.File{{Name: "input.rat", Data: []byte(text)}}
+}
@@ -0,0 +1,444 @@
+// Package cborsrc generates seeds for CBOR and MessagePack decoders. It
+// registers the "cbor/..." generators with package gen.
+//
+// "cbor/item" writes a CBOR data item, input.cbor, by RFC 8949: integers
+// at the edges of each head size, byte and text strings, arrays and maps
+// of definite and indefinite length, indefinite strings in chunks, tags
+// nested in tags, bignums, times, simple values and floats of each width.
+// Most items are in preferred serialization, with map keys in order; now
+// and then a head is longer than it needs to be, a float wider, a
+// bignum small enough for an integer or padded with zeros, keys out of
+// order or repeated, or text not UTF-8. A few are not well-formed: a
+// chunk of the wrong type, a stray break, a reserved additional value, a
+// simple value below 32 in two bytes, a length past the end, nesting
+// past a decoder's limit, or data after the item.
+//
+// "cbor/msgpack" writes a MessagePack object, input.msgpack, by the
+// MessagePack specification: every format family, integers in the
+// smallest format and in wider ones, strings and binary, arrays and maps
+// in each width, timestamps of each length and other extension types. A
+// few use the unused byte 0xc1, give a count or length past the end, nest
+// deeper than a decoder should, or are cut short.
+package cborsrc
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "cbor/item",
+		Doc:  "CBOR data items: indefinite-length strings, arrays and maps, nested tags, bignums, times, simple values and floats of each width, with non-preferred heads, unsorted and duplicate keys, invalid UTF-8, stray breaks, reserved values and nesting past decoder limits",
+		Func: itemFile,
+	})
+	gen.Register(&gen.Generator{
+		Name: "cbor/msgpack",
+		Doc:  "MessagePack objects of every format, integers in wide formats, timestamps and other extensions, with the unused 0xc1, counts past the end, deep nesting and truncation",
+		Func: msgpackFile,
+	})
+}
+
+// badRate is the chance that a part of an item breaks a rule: of
+// preferred serialization for most parts, of well-formedness for a few.
+// An item has a dozen parts or so, so about one in five has one.
+const badRate = 0.02
+
+// The major types.
+const (
+	majorUint = iota
+	majorNint
+	majorBytes
+	majorText
+	majorArray
+	majorMap
+	majorTag
+	majorSimple
+)
+
+// A buf is CBOR-encoded data.
+type buf []byte
+
+// head appends a head of major type major and argument arg, in the
+// fewest bytes it fits in.
+func (b *buf) head(major byte, arg uint64) {
+	switch {
+	case arg < 24:
+		*b = append(*b, major<<5|byte(arg))
+	case arg <= math.MaxUint8:
+		*b = append(*b, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16:
+		*b = binary.BigEndian.AppendUint16(append(*b, major<<5|25), uint16(arg))
+	case arg <= math.MaxUint32:
+		*b = binary.BigEndian.AppendUint32(append(*b, major<<5|26), uint32(arg))
+	default:
+		*b = binary.BigEndian.AppendUint64(append(*b, major<<5|27), arg)
+	}
+}
+
+// wide appends a head of major type major and argument arg in more bytes
+// than it needs, which is well-formed but not preferred.
+func (b *buf) wide(s *gen.State, major byte, arg uint64) {
+	switch {
+	case arg < 24 && s.Chance(0.5):
+		*b = append(*b, major<<5|24, byte(arg))
+	case arg <= math.MaxUint16 && s.Chance(0.5):
+		*b = binary.BigEndian.AppendUint32(append(*b, major<<5|26), uint32(arg))
+	default:
+		*b = binary.BigEndian.AppendUint64(append(*b, major<<5|27), arg)
+	}
+}
+
+// An items writes one seed's data items.
+type items struct {
+	s   *gen.State
+	out buf
+}
+
+// arg appends a head, now and then wider than it needs to be.
+func (it *items) arg(major byte, arg uint64) {
+	if it.s.Chance(badRate) {
+		it.out.wide(it.s, major, arg)
+		return
+	}
+	it.out.head(major, arg)
+}
+
+// edges are integers at the edges of each head size.
+var edges = []uint64{0, 1, 10, 23, 24, 25, 100, 255, 256, 1000, 65535, 65536, 1 << 31, math.MaxUint32, 1 << 32, 1 << 53, math.MaxInt64, 1 << 63, math.MaxUint64}
+
+// texts are text strings with characters of one to four bytes, and
+// times as tag 0 has them.
+var texts = []string{
+	"", "a", "IETF", "\"\\", "ü", "水", "𐅑", "key", "name", "value", "\x00",
+	"2013-03-21T20:04:00Z", "2013-03-21T20:04:00.5+01:00",
+}
+
+// badTexts are text strings that are not UTF-8.
+var badTexts = []string{"\xff", "\xc3", "\xed\xa0\x80", "\xf4\x90\x80\x80", "a\x80b", "\xc0\xaf"}
+
+// float16s are the bits of half-precision floats: zeros, one, the
+// largest, subnormals, infinities and NaNs.
+var float16s = []uint16{0x0000, 0x8000, 0x3c00, 0x3e00, 0xc400, 0x7bff, 0x0001, 0x03ff, 0x0400, 0x7c00, 0xfc00, 0x7e00, 0x7c01, 0xfe00}
+
+// floats are floats no half holds exactly, some of them exactly a single.
+var floats = []float64{0.1, 1.1, -4.1, 1.0e300, 100000.0, 3.4028234663852886e+38, 1e-40, 16777217, 1.0000001192092896}
+
+// tags are tag numbers that decoders treat specially or not at all.
+var tags = []uint64{0, 1, 2, 3, 4, 5, 21, 22, 23, 24, 32, 33, 34, 36, 258, 55799, 1 << 16, 1 << 32, math.MaxUint64}
+
+func itemFile(s *gen.State) []gen.File {
+	it := &items{s: s}
+	it.item(s.Depth(0, 5))
+	switch {
+	case s.Chance(badRate * 4):
+		it.malformed()
+	case s.Chance(0.05):
+		// A sequence of items, which a streaming decoder reads one after
+		// another and Unmarshal rejects.
+		for range s.Range(1, 3) {
+			it.item(s.Range(0, 2))
+		}
+	}
+	return []gen.File{{Name: "input.cbor", Data: it.out}}
+}
+
+// item appends a data item nested at most depth deep.
+func (it *items) item(depth int) {
+	s := it.s
+	n := 12
+	if depth <= 0 {
+		n = 7
+	}
+	switch s.Intn(n) {
+	case 0:
+		it.arg(majorUint, gen.Pick(s, edges...))
+	case 1:
+		it.arg(majorNint, gen.Pick(s, edges...))
+	case 2:
+		it.bytes()
+	case 3:
+		it.text()
+	case 4:
+		it.simple()
+	case 5:
+		it.float()
+	case 6:
+		it.bignum()
+	case 7, 8:
+		it.array(depth)
+	case 9, 10:
+		it.mapItem(depth)
+	default:
+		it.tag(depth)
+	}
+}
+
+// bytes appends a byte string, definite or in chunks.
+func (it *items) bytes() {
+	b := []byte(gen.Pick(it.s, "", "\x01\x02\x03\x04", "\x00", strings.Repeat("\xaa", 24), strings.Repeat("x", 300)))
+	if it.s.Chance(0.15) {
+		it.chunks(majorBytes, string(b))
+		return
+	}
+	it.arg(majorBytes, uint64(len(b)))
+	it.out = append(it.out, b...)
+}
+
+// text appends a text string, definite or in chunks, now and then not
+// UTF-8.
+func (it *items) text() {
+	t := gen.Pick(it.s, texts...)
+	if it.s.Chance(badRate * 2) {
+		t = gen.Pick(it.s, badTexts...)
+	}
+	if it.s.Chance(0.15) {
+		it.chunks(majorText, t)
+		return
+	}
+	it.arg(majorText, uint64(len(t)))
+	it.out = append(it.out, t...)
+}
+
+// chunks appends t as an indefinite-length string of major type major,
+// split into chunks anywhere, even inside a character, which RFC 8949
+// does not allow a text string. Now and then a chunk is of the other
+// string type or itself indefinite, which is not well-formed.
+func (it *items) chunks(major byte, t string) {
+	s := it.s
+	it.out = append(it.out, major<<5|31)
+	for t != "" || s.Chance(0.3) {
+		k := s.Range(0, len(t))
+		chunk := major
+		if s.Chance(badRate) {
+			chunk = gen.Pick(s, majorBytes+majorText-major, majorUint, majorArray)
+		}
+		if s.Chance(badRate) {
+			it.out = append(it.out, chunk<<5|31, 0xff)
+			continue
+		}
+		it.arg(chunk, uint64(k))
+		it.out = append(it.out, t[:k]...)
+		t = t[k:]
+	}
+	it.out = append(it.out, 0xff)
+}
+
+// simple appends false, true, null, undefined or another simple value,
+// in one byte or, not well-formed below 32, in two.
+func (it *items) simple() {
+	s := it.s
+	switch {
+	case s.Chance(0.7):
+		it.out = append(it.out, majorSimple<<5|byte(s.Range(20, 23)))
+	case s.Chance(badRate * 5):
+		it.out = append(it.out, 0xf8, byte(s.Range(0, 31)))
+	case s.Chance(0.5):
+		it.out = append(it.out, majorSimple<<5|byte(s.Range(0, 19)))
+	default:
+		it.out = append(it.out, 0xf8, byte(s.Range(32, 255)))
+	}
+}
+
+// float appends a half, single or double float, in the narrowest width
+// that holds it or, now and then, a wider one; or a NaN with a payload.
+func (it *items) float() {
+	s := it.s
+	var f float64
+	switch {
+	case s.Chance(badRate * 2):
+		f = math.Float64frombits(0x7ff0000000000000 | s.Uint64()&0xfffffffffffff | 1)
+	case s.Chance(0.3):
+		h := gen.Pick(s, float16s...)
+		if !s.Chance(badRate * 4) {
+			it.out = binary.BigEndian.AppendUint16(append(it.out, 0xf9), h)
+			return
+		}
+		f = halfValue(h)
+	default:
+		f = gen.Pick(s, floats...)
+	}
+	if float64(float32(f)) == f && !s.Chance(badRate*2) || math.IsNaN(f) && s.Chance(0.5) {
+		it.out = binary.BigEndian.AppendUint32(append(it.out, 0xfa), math.Float32bits(float32(f)))
+		return
+	}
+	it.out = binary.BigEndian.AppendUint64(append(it.out, 0xfb), math.Float64bits(f))
+}
+
+// halfValue returns the value of the half-precision float with bits h.
+func halfValue(h uint16) float64 {
+	exp, mant := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(mant, -24)
+	case 0x1f:
+		f = math.Inf(1)
+		if mant != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(1024+mant, exp-25)
+	}
+	if h&0x8000 != 0 {
+		f = -f
+	}
+	return f
+}
+
+// bignum appends a tag 2 or 3 bignum, usually too large for an integer,
+// now and then one that is not or has leading zeros.
+func (it *items) bignum() {
+	s := it.s
+	it.out.head(majorTag, uint64(s.Range(2, 3)))
+	b := gen.Pick(s, "\x01\x00\x00\x00\x00\x00\x00\x00\x00", "\xff\xff\xff\xff\xff\xff\xff\xff\xff\xff", "\x01"+strings.Repeat("\x00", 31))
+	if s.Chance(badRate * 5) {
+		b = gen.Pick(s, "", "\x00", "\x01", "\x00\x01\x00\x00\x00\x00\x00\x00\x00\x00", "\xff\xff\xff\xff\xff\xff\xff\xff")
+	}
+	it.arg(majorBytes, uint64(len(b)))
+	it.out = append(it.out, b...)
+}
+
+// array appends an array, definite or indefinite.
+func (it *items) array(depth int) {
+	s := it.s
+	n := s.Range(0, 4)
+	if s.Chance(0.2) {
+		it.out = append(it.out, majorArray<<5|31)
+		for range n {
+			it.item(depth - 1)
+		}
+		it.out = append(it.out, 0xff)
+		return
+	}
+	it.arg(majorArray, uint64(n))
+	for range n {
+		it.item(depth - 1)
+	}
+}
+
+// mapItem appends a map, definite or indefinite, with its keys in the
+// order of their encodings as RFC 8949 section 4.2.1 has them; now and
+// then out of order, repeated, or arrays and maps themselves.
+func (it *items) mapItem(depth int) {
+	s := it.s
+	n := s.Range(0, 4)
+	keys := make([]buf, n)
+	for i := range keys {
+		k := &items{s: s}
+		switch {
+		case s.Chance(0.5):
+			k.text()
+		case s.Chance(0.6):
+			k.arg(gen.Pick(s, byte(majorUint), majorNint), uint64(s.Range(0, 30)))
+		case s.Chance(0.8):
+			k.item(0)
+		default:
+			k.item(1)
+		}
+		keys[i] = k.out
+	}
+	if !s.Chance(badRate * 2) {
+		slices.SortFunc(keys, func(a, b buf) int { return strings.Compare(string(a), string(b)) })
+	}
+	if n > 1 && s.Chance(badRate*2) {
+		keys[n-1] = keys[0]
+	}
+	indefinite := s.Chance(0.2)
+	if indefinite {
+		it.out = append(it.out, majorMap<<5|31)
+	} else {
+		it.arg(majorMap, uint64(n))
+	}
+	for _, k := range keys {
+		it.out = append(it.out, k...)
+		it.item(depth - 1)
+	}
+	if indefinite {
+		if s.Chance(badRate) {
+			it.item(0) // a key without a value
+		}
+		it.out = append(it.out, 0xff)
+	}
+}
+
+// tag appends a tag, or a chain of them, and its content: what the tag
+// calls for, usually, or anything.
+func (it *items) tag(depth int) {
+	s := it.s
+	t := gen.Pick(s, tags...)
+	if s.Chance(0.1) {
+		it.out.head(majorTag, 55799) // self-described CBOR
+	}
+	it.arg(majorTag, t)
+	if s.Chance(0.3) {
+		it.item(depth - 1)
+		return
+	}
+	switch t {
+	case 0:
+		it.arg(majorText, 20)
+		it.out = append(it.out, gen.Pick(s, "2013-03-21T20:04:00Z", "2013-03-21 20:04:00Z", "0000-00-00T00:00:00Z", "9999-12-31T23:59:60Z")...)
+	case 1:
+		if s.Chance(0.5) {
+			it.float()
+		} else {
+			it.arg(gen.Pick(s, byte(majorUint), majorNint), gen.Pick(s, edges...))
+		}
+	case 2, 3:
+		it.arg(majorBytes, 9)
+		it.out = append(it.out, 1, 0, 0, 0, 0, 0, 0, 0, 0)
+	case 4, 5:
+		it.arg(majorArray, 2)
+		it.arg(gen.Pick(s, byte(majorUint), majorNint), uint64(s.Range(0, 30)))
+		it.bignum()
+	case 24:
+		inner := &items{s: s}
+		inner.item(depth - 1)
+		it.arg(majorBytes, uint64(len(inner.out)))
+		it.out = append(it.out, inner.out...)
+	case 55799:
+		it.tag(depth - 1)
+	default:
+		it.item(depth - 1)
+	}
+}
+
+// malformed appends or splices in what makes the item not well-formed,
+// or past what decoders accept.
+func (it *items) malformed() {
+	s := it.s
+	switch s.Intn(7) {
+	case 0:
+		it.out = it.out[:s.Range(0, max(len(it.out)-1, 0))]
+	case 1:
+		it.out = append(it.out, 0xff) // a break with nothing to end
+	case 2:
+		// A reserved additional value, or an indefinite integer or tag,
+		// after the item in an array.
+		it.out = append(append(buf{0x82}, it.out...), gen.Pick(s, byte(0x1c), 0x3d, 0x5e, 0x7c, 0x9d, 0xbe, 0xdc, 0xfc, 0x1f, 0x3f, 0xdf), 0x00, 0x00)
+	case 3:
+		// A length far past the end.
+		major := gen.Pick(s, byte(majorBytes), majorText, majorArray, majorMap)
+		var b buf
+		b.head(major, gen.Pick(s, uint64(1<<16), 1<<31, math.MaxUint32, 1<<62, math.MaxUint64))
+		it.out = append(b, it.out...)
+	case 4:
+		// Nesting past the limits decoders set, of 32 levels by default.
+		n := gen.Pick(s, 16, 17, 31, 32, 33, 1000, 100000)
+		open := gen.Pick(s, "\x81", "\x9f", "\xa1\x00", "\xc6", "\xd8\xff")
+		it.out = append(buf(strings.Repeat(open, n)), 0x00)
+		if open == "\x9f" {
+			it.out = append(it.out, strings.Repeat("\xff", n)...)
+		}
+	case 5:
+		it.out = append(it.out, gen.Pick(s, "\x00", "\xf6", "\x61a")...) // data after the item
+	case 6:
+		// A simple value below 32 in two bytes.
+		it.out = append(append(buf{0x82}, it.out...), 0xf8, byte(s.Range(0, 31)))
+	}
+}
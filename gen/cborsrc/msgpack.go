@@ -0,0 +1,222 @@
+package cborsrc
+
+import (
+	"encoding/binary"
+	"math"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A packer writes one seed's MessagePack object.
+type packer struct {
+	s   *gen.State
+	out []byte
+}
+
+func msgpackFile(s *gen.State) []gen.File {
+	p := &packer{s: s}
+	p.object(s.Depth(0, 5))
+	if s.Chance(badRate * 4) {
+		p.malformed()
+	}
+	return []gen.File{{Name: "input.msgpack", Data: p.out}}
+}
+
+// length appends a head of one of the families with a fixed form, if fix
+// is not zero, for n below limit, then codes for 8, 16 and 32 bits, of
+// which code8 may be zero where the family has none; in the fewest bytes
+// that hold n or, now and then, more.
+func (p *packer) length(fix byte, limit int, code8, code16, code32 byte, n int) {
+	wide := p.s.Chance(badRate)
+	switch {
+	case fix != 0 && n < limit && !wide:
+		p.out = append(p.out, fix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8 && !wide:
+		p.out = append(p.out, code8, byte(n))
+	case n <= math.MaxUint16 && !(wide && p.s.Chance(0.5)):
+		p.out = binary.BigEndian.AppendUint16(append(p.out, code16), uint16(n))
+	default:
+		p.out = binary.BigEndian.AppendUint32(append(p.out, code32), uint32(n))
+	}
+}
+
+// ints are integers at the edges of each format.
+var ints = []int64{0, 1, 127, 128, 255, 256, 65535, 65536, math.MaxUint32, 1 << 32, math.MaxInt64, -1, -32, -33, -128, -129, -32768, -32769, math.MinInt32, math.MinInt32 - 1, math.MinInt64}
+
+// integer appends an integer in the smallest format that holds it or,
+// now and then, a wider one, signed or not.
+func (p *packer) integer() {
+	s := p.s
+	n := gen.Pick(s, ints...)
+	if s.Chance(0.1) {
+		// Above the largest int64, only uint 64 holds it.
+		p.out = binary.BigEndian.AppendUint64(append(p.out, 0xcf), gen.Pick(s, uint64(1<<63), math.MaxUint64))
+		return
+	}
+	switch {
+	case n >= 0 && n <= 127 && !s.Chance(badRate*2):
+		p.out = append(p.out, byte(n))
+	case n < 0 && n >= -32 && !s.Chance(badRate*2):
+		p.out = append(p.out, byte(n))
+	case n >= 0 && s.Chance(0.5):
+		switch {
+		case n <= math.MaxUint8 && !s.Chance(badRate*2):
+			p.out = append(p.out, 0xcc, byte(n))
+		case n <= math.MaxUint16 && !s.Chance(badRate*2):
+			p.out = binary.BigEndian.AppendUint16(append(p.out, 0xcd), uint16(n))
+		case n <= math.MaxUint32 && !s.Chance(badRate*2):
+			p.out = binary.BigEndian.AppendUint32(append(p.out, 0xce), uint32(n))
+		default:
+			p.out = binary.BigEndian.AppendUint64(append(p.out, 0xcf), uint64(n))
+		}
+	default:
+		switch {
+		case n >= math.MinInt8 && n <= math.MaxInt8 && !s.Chance(badRate*2):
+			p.out = append(p.out, 0xd0, byte(n))
+		case n >= math.MinInt16 && n <= math.MaxInt16 && !s.Chance(badRate*2):
+			p.out = binary.BigEndian.AppendUint16(append(p.out, 0xd1), uint16(n))
+		case n >= math.MinInt32 && n <= math.MaxInt32 && !s.Chance(badRate*2):
+			p.out = binary.BigEndian.AppendUint32(append(p.out, 0xd2), uint32(n))
+		default:
+			p.out = binary.BigEndian.AppendUint64(append(p.out, 0xd3), uint64(n))
+		}
+	}
+}
+
+// float appends a float 32 or float 64.
+func (p *packer) float() {
+	f := gen.Pick(p.s, 0, math.Copysign(0, -1), 1.5, 0.1, -4.1, 1e300, math.Inf(1), math.Inf(-1), math.NaN(), math.SmallestNonzeroFloat64, math.MaxFloat32)
+	if float64(float32(f)) == f || math.IsNaN(f) && p.s.Chance(0.5) {
+		p.out = binary.BigEndian.AppendUint32(append(p.out, 0xca), math.Float32bits(float32(f)))
+		return
+	}
+	p.out = binary.BigEndian.AppendUint64(append(p.out, 0xcb), math.Float64bits(f))
+}
+
+// str appends t as a str, or now and then as bin.
+func (p *packer) str(t string) {
+	if p.s.Chance(0.1) {
+		p.length(0, 0, 0xc4, 0xc5, 0xc6, len(t))
+	} else {
+		p.length(0xa0, 32, 0xd9, 0xda, 0xdb, len(t))
+	}
+	p.out = append(p.out, t...)
+}
+
+// strs are strings of each width of str, some of them not UTF-8.
+var strs = []string{"", "a", "key", "name", "ü", "水", "𐅑", "\x00", "\xff\xfe", strings.Repeat("s", 31), strings.Repeat("s", 32), strings.Repeat("s", 255), strings.Repeat("s", 256)}
+
+// timestamp appends a timestamp extension of 32, 64 or 96 bits; now and
+// then one whose nanoseconds are past a second, which the specification
+// forbids, or of a length it has no form for.
+func (p *packer) timestamp() {
+	s := p.s
+	sec := gen.Pick(s, int64(0), 1, 1<<32-1, 1<<32, 1<<34-1, 1<<34, -1, math.MinInt64, math.MaxInt64, 1700000000)
+	nsec := gen.Pick(s, uint32(0), 1, 999999999)
+	if s.Chance(badRate * 5) {
+		nsec = gen.Pick(s, uint32(1000000000), 1<<30-1, math.MaxUint32)
+	}
+	switch {
+	case s.Chance(badRate * 2):
+		n := gen.Pick(s, 0, 1, 2, 5, 16)
+		p.out = append(p.out, 0xc7, byte(n), 0xff)
+		p.out = append(p.out, make([]byte, n)...)
+	case nsec == 0 && sec >= 0 && sec < 1<<32 && !s.Chance(badRate*2):
+		p.out = binary.BigEndian.AppendUint32(append(p.out, 0xd6, 0xff), uint32(sec))
+	case nsec < 1<<30 && sec >= 0 && sec < 1<<34 && !s.Chance(badRate*2):
+		p.out = binary.BigEndian.AppendUint64(append(p.out, 0xd7, 0xff), uint64(nsec)<<34|uint64(sec))
+	default:
+		p.out = binary.BigEndian.AppendUint32(append(p.out, 0xc7, 12, 0xff), nsec)
+		p.out = binary.BigEndian.AppendUint64(p.out, uint64(sec))
+	}
+}
+
+// ext appends an extension of a type other than the timestamp's, in a
+// fixext or ext format.
+func (p *packer) ext() {
+	s := p.s
+	typ := byte(gen.Pick(s, 0, 1, 13, 127, -2, -128))
+	n := gen.Pick(s, 1, 2, 4, 8, 16, 0, 3, 17, 300)
+	fixed := map[int]byte{1: 0xd4, 2: 0xd5, 4: 0xd6, 8: 0xd7, 16: 0xd8}
+	if code, ok := fixed[n]; ok && !s.Chance(badRate*2) {
+		p.out = append(p.out, code, typ)
+	} else {
+		p.length(0, 0, 0xc7, 0xc8, 0xc9, n)
+		p.out = append(p.out, typ)
+	}
+	for i := range n {
+		p.out = append(p.out, byte(i))
+	}
+}
+
+// object appends an object nested at most depth deep.
+func (p *packer) object(depth int) {
+	s := p.s
+	n := 10
+	if depth <= 0 {
+		n = 6
+	}
+	switch s.Intn(n) {
+	case 0:
+		p.out = append(p.out, gen.Pick(s, byte(0xc0), 0xc2, 0xc3))
+	case 1, 2:
+		p.integer()
+	case 3:
+		p.float()
+	case 4:
+		p.str(gen.Pick(s, strs...))
+	case 5:
+		if s.Chance(0.7) {
+			p.timestamp()
+		} else {
+			p.ext()
+		}
+	case 6, 7:
+		k := s.Range(0, 4)
+		p.length(0x90, 16, 0, 0xdc, 0xdd, k)
+		for range k {
+			p.object(depth - 1)
+		}
+	default:
+		// Keys are strings, as most decoders want them, but for a few.
+		k := s.Range(0, 4)
+		p.length(0x80, 16, 0, 0xde, 0xdf, k)
+		for range k {
+			if s.Chance(0.9) {
+				p.str(gen.Pick(s, "a", "b", "key", "name", "", "a"))
+			} else {
+				p.object(0)
+			}
+			p.object(depth - 1)
+		}
+	}
+}
+
+// malformed splices in what no decoder should take: the unused code, a
+// count or length past the end, nesting deeper than a stack should go,
+// or an object cut short.
+func (p *packer) malformed() {
+	s := p.s
+	switch s.Intn(4) {
+	case 0:
+		p.out = append([]byte{0x92, 0xc1}, p.out...)
+	case 1:
+		code := gen.Pick(s, byte(0xdc), 0xdd, 0xde, 0xdf, 0xc6, 0xdb, 0xc9)
+		p.out = append([]byte{code}, p.out...)
+		n := gen.Pick(s, uint32(1<<16-1), 1<<20, 1<<31, math.MaxUint32)
+		if code == 0xdc || code == 0xde {
+			p.out = binary.BigEndian.AppendUint16(p.out[:1], uint16(n))
+			p.out = append(p.out, 0xc0)
+			break
+		}
+		head := binary.BigEndian.AppendUint32([]byte{code}, n)
+		p.out = append(head, p.out[1:]...)
+	case 2:
+		n := gen.Pick(s, 100, 1000, 100000)
+		open := gen.Pick(s, "\x91", "\x81\xa1a", "\xdc\x00\x01")
+		p.out = append([]byte(strings.Repeat(open, n)), 0xc0)
+	case 3:
+		p.out = p.out[:s.Range(0, max(len(p.out)-1, 0))]
+	}
+}
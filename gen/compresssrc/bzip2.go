@@ -0,0 +1,343 @@
+package compresssrc
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "compress/bzip2",
+		Doc:  "bzip2 streams compressed from scratch: random code tables and selectors, small, empty and run-bomb blocks, concatenated streams, and bad checksums, origin pointers, table counts, selectors, code lengths and zero runs",
+		Func: bzip2Seed,
+	})
+}
+
+// The magic numbers that start a block and end a stream.
+const (
+	blockMagic = 0x314159265359
+	endMagic   = 0x177245385090
+)
+
+// crcTable is the table of the CRC-32 bzip2 computes, most significant
+// bit first.
+var crcTable = func() (t [256]uint32) {
+	for i := range t {
+		c := uint32(i) << 24
+		for range 8 {
+			if c&(1<<31) != 0 {
+				c = c<<1 ^ 0x04c11db7
+			} else {
+				c <<= 1
+			}
+		}
+		t[i] = c
+	}
+	return t
+}()
+
+// blockCRC returns the checksum of a block that holds data.
+func blockCRC(data []byte) uint32 {
+	crc := ^uint32(0)
+	for _, b := range data {
+		crc = crc<<8 ^ crcTable[byte(crc>>24)^b]
+	}
+	return ^crc
+}
+
+// A bgen writes a bzip2 stream.
+type bgen struct {
+	s     *gen.State
+	w     msbWriter
+	level int
+	// crc is the stream's checksum, of the checksums of its blocks.
+	crc uint32
+}
+
+// bzip2Seed writes one or more bzip2 streams, one after the other.
+func bzip2Seed(s *gen.State) []gen.File {
+	var b []byte
+	for range gen.Pick(s, 1, 1, 1, 1, 2, 3) {
+		b = append(b, bzip2Stream(s)...)
+	}
+	switch {
+	case s.Chance(badRate * 2):
+		b = append(b, gen.Pick(s, "\x00\x00\x00\x00", "BZ", "BZh9", "garbage")...)
+	case s.Chance(badRate * 2):
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.bz2", Data: b}}
+}
+
+// bzip2Stream returns one stream: its header, its blocks and its end.
+func bzip2Stream(s *gen.State) []byte {
+	g := &bgen{s: s, level: s.Range(1, 9)}
+	if s.Chance(0.03) {
+		// A bomb: runs of 255 bytes, each five bytes in the block, in
+		// a block as large as the level allows for them.
+		runs := s.Range(1000, 80000)
+		g.level = max(g.level, (runs*5+99999)/100000)
+		g.header()
+		g.block(bytes.Repeat([]byte{byte(s.Intn(256))}, runs*255))
+	} else {
+		g.header()
+		for range gen.Pick(s, 0, 1, 1, 1, 2, 3) {
+			data := content(s)
+			if len(data) == 0 && !s.Chance(badRate*10) {
+				continue
+			}
+			g.block(data)
+		}
+	}
+	g.w.write(endMagic>>24, 24)
+	g.w.write(endMagic&0xffffff, 24)
+	crc := g.crc
+	if s.Chance(badRate) {
+		crc ^= 1 << s.Intn(32)
+	}
+	g.w.write(uint64(crc), 32)
+	g.w.align()
+	return g.w.b
+}
+
+// header writes the stream's magic and block size.
+func (g *bgen) header() {
+	level := byte('0' + g.level)
+	if g.s.Chance(badRate) {
+		level = gen.Pick[byte](g.s, '0', 'a', 0)
+	}
+	g.w.b = append(g.w.b, 'B', 'Z', 'h', level)
+}
+
+// block writes a block of data.
+func (g *bgen) block(data []byte) {
+	s := g.s
+	crc := blockCRC(data)
+	g.crc = (g.crc<<1 | g.crc>>31) ^ crc
+	if s.Chance(badRate) {
+		crc ^= 1 << s.Intn(32)
+	}
+	last, ptr := bwt(rle(data))
+	if s.Chance(badRate) {
+		ptr = gen.Pick(s, len(last), len(last)+1, 1<<24-1)
+	}
+
+	// The symbols: the positions of the bytes of last in a list of the
+	// bytes used that moves each to the front as it is used, with runs
+	// of zeros written in bijective base 2 as RUNA and RUNB.
+	var used [256]bool
+	for _, b := range last {
+		used[b] = true
+	}
+	var order []byte
+	for i, u := range used {
+		if u {
+			order = append(order, byte(i))
+		}
+	}
+	if len(order) == 0 && !s.Chance(badRate*10) {
+		order = append(order, byte(s.Intn(256)))
+		used[order[0]] = true
+	}
+	mtf := slices.Clone(order)
+	var syms []int
+	zeros := 0
+	flush := func() {
+		for n := zeros; n > 0; {
+			if n&1 == 1 {
+				syms = append(syms, 0)
+				n = (n - 1) / 2
+			} else {
+				syms = append(syms, 1)
+				n = (n - 2) / 2
+			}
+		}
+		zeros = 0
+	}
+	for _, b := range last {
+		i := bytes.IndexByte(mtf, b)
+		if i == 0 {
+			zeros++
+			continue
+		}
+		flush()
+		syms = append(syms, i+1)
+		copy(mtf[1:i+1], mtf[:i])
+		mtf[0] = b
+	}
+	flush()
+	if s.Chance(badRate) {
+		// A run of zeros longer than any block.
+		for range 24 {
+			syms = append(syms, 1)
+		}
+	}
+	if !s.Chance(badRate) {
+		syms = append(syms, len(order)+1)
+	}
+	alpha := len(order) + 2
+
+	// The tables, and which of them each group of 50 symbols uses.
+	groups := s.Range(2, 6)
+	tables := make([]code, groups)
+	for i := range tables {
+		lens := shape(s, alpha, 20)
+		gen.Shuffle(s, lens)
+		tables[i] = newCode(lens)
+	}
+	selectors := make([]int, (len(syms)+49)/50)
+	same := s.Chance(0.3)
+	for i := range selectors {
+		if !same || i == 0 {
+			selectors[i] = s.Intn(groups)
+		} else {
+			selectors[i] = selectors[i-1]
+		}
+	}
+
+	g.w.write(blockMagic>>24, 24)
+	g.w.write(blockMagic&0xffffff, 24)
+	g.w.write(uint64(crc), 32)
+	g.w.write(b2u(s.Chance(badRate)), 1)
+	g.w.write(uint64(ptr), 24)
+	var ranges uint64
+	for i := range 16 {
+		if slices.Contains(used[i*16:i*16+16], true) {
+			ranges |= 1 << (15 - i)
+		}
+	}
+	g.w.write(ranges, 16)
+	for i := range 16 {
+		if ranges&(1<<(15-i)) == 0 {
+			continue
+		}
+		var bits uint64
+		for j, u := range used[i*16 : i*16+16] {
+			if u {
+				bits |= 1 << (15 - j)
+			}
+		}
+		g.w.write(bits, 16)
+	}
+	n := groups
+	if s.Chance(badRate) {
+		n = gen.Pick(s, 0, 1, 7)
+	}
+	g.w.write(uint64(n), 3)
+
+	written := selectors
+	if s.Chance(badRate) {
+		written = slices.Clone(selectors[:s.Intn(len(selectors)+1)])
+	}
+	if s.Chance(badRate) {
+		for range gen.Pick(s, 1, 18003) {
+			written = append(written, s.Intn(groups))
+		}
+	}
+	g.w.write(uint64(len(written)), 15)
+	list := make([]int, groups)
+	for i := range list {
+		list[i] = i
+	}
+	for _, sel := range written {
+		i := slices.Index(list, sel)
+		if s.Chance(badRate / 10) {
+			i = groups
+		}
+		for range i {
+			g.w.write(1, 1)
+		}
+		g.w.write(0, 1)
+		if i < groups {
+			copy(list[1:i+1], list[:i])
+			list[0] = sel
+		}
+	}
+
+	for _, t := range tables {
+		lens := t.lengths
+		if s.Chance(badRate) {
+			// Lengths that make the code oversubscribed or incomplete,
+			// or that leave the range of 1 to 20 bits.
+			lens = slices.Clone(lens)
+			i := s.Intn(len(lens))
+			lens[i] = gen.Pick[uint8](s, max(lens[i], 2)-1, min(lens[i], 19)+1, 0, 21)
+		}
+		curr := lens[0]
+		g.w.write(uint64(curr), 5)
+		for _, l := range lens {
+			for ; curr < l; curr++ {
+				g.w.write(2, 2)
+			}
+			for ; curr > l; curr-- {
+				g.w.write(3, 2)
+			}
+			g.w.write(0, 1)
+		}
+	}
+	for i, sym := range syms {
+		t := tables[selectors[i/50]]
+		g.w.write(uint64(t.codes[sym]), uint(t.lengths[sym]))
+	}
+}
+
+// rle returns data with each run of 4 to 255 of a byte written as four
+// of it and a count of the rest, as bzip2 does before it sorts a block.
+func rle(data []byte) []byte {
+	var b []byte
+	for i := 0; i < len(data); {
+		c := data[i]
+		n := 1
+		for n < 255 && i+n < len(data) && data[i+n] == c {
+			n++
+		}
+		if n >= 4 {
+			b = append(b, c, c, c, c, byte(n-4))
+		} else {
+			b = append(b, data[i:i+n]...)
+		}
+		i += n
+	}
+	return b
+}
+
+// bwt returns the Burrows-Wheeler transform of data: the last bytes of
+// its rotations, sorted, and where among them data itself is. Data that
+// repeats a few bytes over and over, as a bomb does, is transformed by
+// transforming the bytes it repeats.
+func bwt(data []byte) ([]byte, int) {
+	n := len(data)
+	for p := 1; p <= 16 && p < n; p++ {
+		if n%p != 0 || !bytes.Equal(data[p:], data[:n-p]) {
+			continue
+		}
+		last, ptr := bwt(data[:p])
+		k := n / p
+		b := make([]byte, 0, n)
+		for _, c := range last {
+			for range k {
+				b = append(b, c)
+			}
+		}
+		return b, ptr * k
+	}
+	double := append(slices.Clone(data), data...)
+	rot := make([]int, n)
+	for i := range rot {
+		rot[i] = i
+	}
+	slices.SortStableFunc(rot, func(a, b int) int {
+		return bytes.Compare(double[a:a+n], double[b:b+n])
+	})
+	last := make([]byte, n)
+	ptr := 0
+	for i, r := range rot {
+		last[i] = double[r+n-1]
+		if r == 0 {
+			ptr = i
+		}
+	}
+	return last, ptr
+}
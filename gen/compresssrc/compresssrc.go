@@ -0,0 +1,199 @@
+// Package compresssrc generates compressed seeds. It registers the
+// "compress/..." generators with package gen.
+//
+// "compress/flate" writes a raw deflate stream, input.flate, bit by bit:
+// stored, fixed and dynamic blocks, the last of them final, holding
+// literals and matches found in the text they compress or made up, with
+// lengths of 258 written both ways, distances as far back as the window
+// goes, and matches that overlap what they copy. A dynamic block's codes
+// are drawn at random, from balanced to as lopsided as fifteen bits
+// allow, with symbols the block never uses, a single distance code or
+// none, and code lengths run-length coded across the boundary between
+// the two alphabets. Now and then a stream is a bomb, a few kilobytes
+// of one-bit codes for 258-byte copies; a few break the format: codes
+// that are oversubscribed or incomplete, symbols 286, 287, 30 and 31,
+// repeats with nothing to repeat or that run past the end, distances
+// past the start of the output, a stored block whose length does not
+// match its complement, the reserved block type, and streams cut short
+// or with no final block.
+//
+// "compress/gzip" writes one or more gzip members, input.gz, each with
+// a header that may carry extra fields (BGZF, Apollo and made-up
+// subfields), a Latin-1 name and comment, and a header checksum, and a
+// deflate stream from the flate generator or package compress/flate;
+// streams are concatenated members, BGZF blocks with their empty end
+// block, or members followed by padding, and a few have wrong methods,
+// reserved flags, names too long to read, or checksums and sizes that
+// do not match.
+//
+// "compress/zlib" writes a zlib stream, input.zlib, with every window
+// size and level in its header, preset dictionaries that are empty or
+// unknown, and now and then a header or checksum that is wrong.
+//
+// "compress/bzip2" writes a bzip2 stream, input.bz2, compressed here
+// from scratch: runs, the Burrows-Wheeler transform, move-to-front,
+// zero runs and Huffman codes, with two to six random code tables and
+// selectors to switch between them. Blocks are small, empty, or bombs
+// of runs that expand to tens of megabytes; streams are concatenated;
+// and a few break the format: checksums, origin pointers, table counts,
+// selectors and code lengths that are wrong or out of range, the
+// deprecated randomized bit, zero runs longer than a block, and blocks
+// without an end of block symbol.
+package compresssrc
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// badRate is the chance that one of the many fields of a stream is
+// wrong, so that one stream in ten or so is broken.
+const badRate = 0.004
+
+// texts are what streams compress.
+var texts = []string{
+	"hello, world\n", "", "a", "package main\n\nfunc main() {}\n",
+	"The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog.\n",
+	"<html><head><title>x</title></head><body><p>x</p><p>x</p></body></html>",
+	"abababababababababababababababababab", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa",
+	"\x00\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x00\x00\x00\x00\x02",
+	"{\"a\":1,\"b\":[1,2,3],\"c\":{\"a\":1,\"b\":[1,2,3]}}", "日本語のテキスト、日本語のテキスト",
+}
+
+// content returns bytes to compress: a text, a text repeated, random
+// bytes, runs of one byte or a few of these together.
+func content(s *gen.State) []byte {
+	var b []byte
+	for range s.Range(1, 3) {
+		switch s.Intn(6) {
+		case 0:
+			b = append(b, make([]byte, s.Intn(600))...)
+		case 1:
+			for range s.Intn(1024) {
+				b = append(b, byte(s.Intn(256)))
+			}
+		case 2:
+			b = append(b, bytes.Repeat([]byte{byte(s.Intn(256))}, gen.Pick(s, 3, 4, 5, 251, 255, 256, 259, s.Intn(2000)))...)
+		case 3:
+			b = append(b, strings.Repeat(gen.Pick(s, texts...), s.Range(2, 20))...)
+		default:
+			b = append(b, gen.Pick(s, texts...)...)
+		}
+	}
+	return b
+}
+
+// shape returns the lengths of the codes of a complete prefix code of n
+// symbols, none longer than limit bits, in no particular order: a tree
+// grown from its root by splitting leaves, the shallowest one or one at
+// random, so that codes range from balanced to lopsided. A code of one
+// symbol is one bit long.
+func shape(s *gen.State, n, limit int) []uint8 {
+	if n <= 1 {
+		return []uint8{1}
+	}
+	leaves := []uint8{0}
+	balanced := s.Chance(0.5)
+	for len(leaves) < n {
+		i := s.Intn(len(leaves))
+		if balanced || leaves[i] == uint8(limit) {
+			i = 0
+			for j, l := range leaves {
+				if l < leaves[i] {
+					i = j
+				}
+			}
+		}
+		leaves[i]++
+		leaves = append(leaves, leaves[i])
+	}
+	return leaves
+}
+
+// canonical returns the codes of the canonical prefix code with the
+// given code lengths, shorter codes first and symbols in order within a
+// length, as deflate and bzip2 both assign them. A symbol of length 0
+// has no code.
+func canonical(lengths []uint8) []uint32 {
+	var count [33]uint32
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [33]uint32
+	code := uint32(0)
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for i, l := range lengths {
+		if l != 0 {
+			codes[i] = next[l]
+			next[l]++
+		}
+	}
+	return codes
+}
+
+// A bitWriter writes bits least significant first, as deflate packs
+// them.
+type bitWriter struct {
+	b    []byte
+	bits uint64
+	n    uint
+}
+
+// write writes the n low bits of v.
+func (w *bitWriter) write(v uint64, n uint) {
+	w.bits |= (v & (1<<n - 1)) << w.n
+	w.n += n
+	for w.n >= 8 {
+		w.b = append(w.b, byte(w.bits))
+		w.bits >>= 8
+		w.n -= 8
+	}
+}
+
+// code writes a Huffman code of n bits, most significant first.
+func (w *bitWriter) code(c uint32, n uint8) {
+	var r uint64
+	for range n {
+		r = r<<1 | uint64(c&1)
+		c >>= 1
+	}
+	w.write(r, uint(n))
+}
+
+// align writes zeros up to the next byte.
+func (w *bitWriter) align() {
+	if w.n > 0 {
+		w.write(0, 8-w.n)
+	}
+}
+
+// A msbWriter writes bits most significant first, as bzip2 packs them.
+type msbWriter struct {
+	b    []byte
+	bits uint64
+	n    uint
+}
+
+// write writes the n low bits of v, n at most 32.
+func (w *msbWriter) write(v uint64, n uint) {
+	w.bits = w.bits<<n | v&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		w.b = append(w.b, byte(w.bits>>(w.n-8)))
+		w.n -= 8
+	}
+}
+
+// align writes zeros up to the next byte.
+func (w *msbWriter) align() {
+	if w.n > 0 {
+		w.write(0, 8-w.n)
+	}
+}
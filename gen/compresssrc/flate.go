@@ -0,0 +1,452 @@
+package compresssrc
+
+import (
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "compress/flate",
+		Doc:  "raw deflate streams written bit by bit: stored, fixed and dynamic blocks, random balanced and lopsided codes, overlapping and window-long matches, bombs, and oversubscribed or incomplete codes, invalid symbols, bad repeats and distances, and truncation",
+		Func: flateSeed,
+	})
+}
+
+// window is how far back a match may reach.
+const window = 32768
+
+// Lengths and distances: the least each length or distance symbol
+// stands for, and the extra bits that follow it.
+var (
+	lengthBase  = []int{3, 4, 5, 6, 7, 8, 9, 10, 11, 13, 15, 17, 19, 23, 27, 31, 35, 43, 51, 59, 67, 83, 99, 115, 131, 163, 195, 227, 258}
+	lengthExtra = []uint{0, 0, 0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 2, 2, 2, 2, 3, 3, 3, 3, 4, 4, 4, 4, 5, 5, 5, 5, 0}
+	distBase    = []int{1, 2, 3, 4, 5, 7, 9, 13, 17, 25, 33, 49, 65, 97, 129, 193, 257, 385, 513, 769, 1025, 1537, 2049, 3073, 4097, 6145, 8193, 12289, 16385, 24577}
+	distExtra   = []uint{0, 0, 0, 0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5, 6, 6, 7, 7, 8, 8, 9, 9, 10, 10, 11, 11, 12, 12, 13, 13}
+)
+
+// clOrder is the order a dynamic block gives the lengths of the code
+// length code in.
+var clOrder = []int{16, 17, 18, 0, 8, 7, 9, 6, 10, 5, 11, 4, 12, 3, 13, 2, 14, 1, 15}
+
+// The fixed codes.
+var fixedLit, fixedDist = func() ([]uint8, []uint8) {
+	lit := make([]uint8, 288)
+	for i := range lit {
+		switch {
+		case i < 144:
+			lit[i] = 8
+		case i < 256:
+			lit[i] = 9
+		case i < 280:
+			lit[i] = 7
+		default:
+			lit[i] = 8
+		}
+	}
+	dist := make([]uint8, 32)
+	for i := range dist {
+		dist[i] = 5
+	}
+	return lit, dist
+}()
+
+// An op is a literal, or a match of length bytes dist back.
+type op struct {
+	lit          byte
+	length, dist int
+	// long is whether a match of 258 bytes is written as symbol 284
+	// with all its extra bits set, not as 285.
+	long bool
+}
+
+// A code is a prefix code: its code lengths and codes by symbol.
+type code struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func newCode(lengths []uint8) code {
+	return code{lengths, canonical(lengths)}
+}
+
+// An fgen writes a deflate stream.
+type fgen struct {
+	s *gen.State
+	w bitWriter
+	// out is what the stream decodes to so far, as far as it is well
+	// formed.
+	out []byte
+}
+
+// flateSeed writes one raw deflate stream.
+func flateSeed(s *gen.State) []gen.File {
+	data, _ := deflate(s, content(s))
+	switch {
+	case s.Chance(0.05):
+		data = append(data, gen.Pick(s, "\x00", "garbage", "\x03\x00")...)
+	case s.Chance(badRate * 2):
+		data = data[:s.Intn(len(data)+1)]
+	}
+	return []gen.File{{Name: "input.flate", Data: data}}
+}
+
+// deflate returns a deflate stream of data and what it decodes to,
+// which is data unless the stream is a bomb or broken.
+func deflate(s *gen.State, data []byte) (stream, out []byte) {
+	f := &fgen{s: s}
+	if s.Chance(0.03) {
+		f.bomb()
+		f.w.align()
+		return f.w.b, f.out
+	}
+	if s.Chance(0.03) {
+		// A first block as long as the window, so that a match can
+		// reach all the way back.
+		f.stored(make([]byte, window+s.Intn(100)), false)
+		f.fixed([]op{{length: s.Range(3, 258), dist: window}}, false)
+	}
+	n := s.Range(1, 4)
+	for i := range n {
+		part := data[:len(data)*(i+1)/n][len(data)*i/n:]
+		final := i == n-1 && !s.Chance(badRate)
+		switch {
+		case s.Chance(badRate):
+			// The reserved block type.
+			f.w.write(b2u(final), 1)
+			f.w.write(3, 2)
+			f.w.write(s.Uint64(), 32)
+		case s.Chance(0.05):
+			// An empty stored block, as a flush writes.
+			f.stored(nil, false)
+			f.dynamic(f.ops(part), final, false)
+		case s.Chance(0.2):
+			f.stored(part, final)
+		case s.Chance(0.35):
+			f.fixed(f.ops(part), final)
+		default:
+			f.dynamic(f.ops(part), final, false)
+		}
+	}
+	f.w.align()
+	return f.w.b, f.out
+}
+
+// stored writes data as stored blocks of up to 65535 bytes, the last of
+// them final if final is.
+func (f *fgen) stored(data []byte, final bool) {
+	s := f.s
+	for {
+		n := min(len(data), 0xffff)
+		last := n == len(data)
+		f.w.write(b2u(final && last), 1)
+		f.w.write(0, 2)
+		f.w.align()
+		nlen := ^n
+		if s.Chance(badRate) {
+			nlen = gen.Pick(s, n, ^n^1, 0)
+		}
+		f.w.write(uint64(n), 16)
+		f.w.write(uint64(nlen)&0xffff, 16)
+		f.w.b = append(f.w.b, data[:n]...)
+		f.out = append(f.out, data[:n]...)
+		data = data[n:]
+		if last {
+			return
+		}
+	}
+}
+
+// fixed writes ops as a block with the fixed codes.
+func (f *fgen) fixed(ops []op, final bool) {
+	f.w.write(b2u(final), 1)
+	f.w.write(1, 2)
+	f.emit(ops, newCode(fixedLit), newCode(fixedDist), nil)
+}
+
+// ops returns data as literals and the matches a greedy search of the
+// last few hundred bytes finds, with a few matches made up at its end:
+// a run of the last byte, one as far back as the output goes, and
+// rarely one further back than that.
+func (f *fgen) ops(data []byte) []op {
+	s := f.s
+	start := max(len(f.out)-window, 0)
+	all := append(f.out[start:len(f.out):len(f.out)], data...)
+	i := len(f.out) - start
+	literal := s.Chance(0.1)
+	var ops []op
+	for i < len(all) {
+		best, dist := 0, 0
+		for j := max(i-512, 0); j < i && !literal; j++ {
+			n := 0
+			for n < 258 && i+n < len(all) && all[j+n] == all[i+n] {
+				n++
+			}
+			if n > best {
+				best, dist = n, i-j
+			}
+		}
+		if best >= 3 && !s.Chance(0.05) {
+			ops = append(ops, op{length: best, dist: dist, long: best == 258 && s.Chance(0.2)})
+			i += best
+			continue
+		}
+		ops = append(ops, op{lit: all[i]})
+		i++
+	}
+	if len(all) > 0 && s.Chance(0.2) {
+		ops = append(ops, op{length: gen.Pick(s, 3, 258, s.Range(3, 258)), dist: gen.Pick(s, 1, min(len(all), window))})
+	}
+	if s.Chance(badRate) {
+		ops = append(ops, op{length: s.Range(3, 258), dist: gen.Pick(s, min(len(all)+1, window), s.Range(1, window))})
+	}
+	return ops
+}
+
+// bomb writes a dynamic block of one literal and then many matches of
+// 258 bytes one back, each a bit or two.
+func (f *fgen) bomb() {
+	s := f.s
+	ops := []op{{lit: byte(s.Intn(256))}}
+	for range s.Range(1000, 80000) {
+		ops = append(ops, op{length: 258, dist: 1})
+	}
+	f.dynamic(ops, true, true)
+}
+
+// dynamic writes ops as a block with codes of its own: random ones,
+// for the symbols ops use and a few they do not unless plain is set.
+func (f *fgen) dynamic(ops []op, final, plain bool) {
+	s := f.s
+	var litUsed [288]bool
+	var distUsed [32]bool
+	litUsed[256] = true
+	for _, o := range ops {
+		if o.length == 0 {
+			litUsed[o.lit] = true
+			continue
+		}
+		sym, _, _ := lengthSym(o)
+		litUsed[sym] = true
+		sym, _, _ = distSym(o.dist)
+		distUsed[sym] = true
+	}
+	if !plain {
+		for range s.Intn(20) {
+			litUsed[s.Intn(286)] = true
+		}
+		for range s.Intn(3) {
+			distUsed[s.Intn(30)] = true
+		}
+	}
+	// bad are a literal or length symbol and a distance symbol that no
+	// block may use, written before the end of the block.
+	badLit, badDist := -1, -1
+	if s.Chance(badRate) {
+		badLit = gen.Pick(s, 286, 287)
+		litUsed[badLit] = true
+	}
+	if s.Chance(badRate) {
+		badDist = gen.Pick(s, 30, 31)
+		litUsed[257], distUsed[badDist] = true, true
+	}
+	if !slices.Contains(distUsed[:], true) && s.Chance(0.5) {
+		// One distance code, where a block with no matches may have
+		// none at all.
+		distUsed[s.Intn(30)] = true
+	}
+	lit := newCode(lengths(s, litUsed[:]))
+	dist := newCode(lengths(s, distUsed[:]))
+
+	// The lengths written are those of the codes used, but now and
+	// then changed so that they are not a valid code.
+	litLens := append([]uint8(nil), lit.lengths...)
+	distLens := append([]uint8(nil), dist.lengths...)
+	if s.Chance(badRate) {
+		lens := gen.Pick(s, litLens, distLens)
+		i := s.Intn(len(lens))
+		switch s.Intn(3) {
+		case 0:
+			lens[i] = max(lens[i], 2) - 1
+		case 1:
+			lens[i] = min(lens[i]+1, 15)
+		case 2:
+			litLens[256] = 0
+		}
+	}
+	hlit, hdist := 257, 1
+	for i, l := range litLens {
+		if l != 0 {
+			hlit = max(hlit, i+1)
+		}
+	}
+	for i, l := range distLens {
+		if l != 0 {
+			hdist = max(hdist, i+1)
+		}
+	}
+	if s.Chance(0.1) {
+		hlit = s.Range(hlit, max(hlit, 286))
+		hdist = s.Range(hdist, max(hdist, 30))
+	}
+	if s.Chance(badRate) {
+		hlit, hdist = 288, 32
+	}
+
+	// The code lengths, run-length coded across both alphabets.
+	type item struct {
+		sym   int
+		extra uint64
+		bits  uint
+	}
+	seq := append(litLens[:hlit:hlit], distLens[:hdist]...)
+	var items []item
+	if s.Chance(badRate) {
+		items = append(items, item{16, 0, 2})
+	}
+	rle := s.Chance(0.8)
+	for i := 0; i < len(seq); {
+		v := seq[i]
+		run := 1
+		for i+run < len(seq) && seq[i+run] == v {
+			run++
+		}
+		switch {
+		case rle && v == 0 && run >= 11:
+			n := min(run, 138)
+			items = append(items, item{18, uint64(n - 11), 7})
+			i += n
+		case rle && v == 0 && run >= 3:
+			items = append(items, item{17, uint64(run - 3), 3})
+			i += run
+		case rle && i > 0 && seq[i-1] == v && run >= 3:
+			n := min(run, 6)
+			items = append(items, item{16, uint64(n - 3), 2})
+			i += n
+		default:
+			items = append(items, item{int(v), 0, 0})
+			i++
+		}
+	}
+	if s.Chance(badRate) {
+		items = append(items, item{18, 127, 7})
+	}
+	var clUsed [19]bool
+	for _, it := range items {
+		clUsed[it.sym] = true
+	}
+	cl := newCode(lengths(s, clUsed[:]))
+	hclen := 4
+	for i, sym := range clOrder {
+		if cl.lengths[sym] != 0 {
+			hclen = max(hclen, i+1)
+		}
+	}
+
+	f.w.write(b2u(final), 1)
+	f.w.write(2, 2)
+	f.w.write(uint64(hlit-257), 5)
+	f.w.write(uint64(hdist-1), 5)
+	f.w.write(uint64(hclen-4), 4)
+	for _, sym := range clOrder[:hclen] {
+		f.w.write(uint64(cl.lengths[sym]), 3)
+	}
+	for _, it := range items {
+		f.w.code(cl.codes[it.sym], cl.lengths[it.sym])
+		f.w.write(it.extra, it.bits)
+	}
+	var bad []int
+	if badLit >= 0 {
+		bad = append(bad, badLit)
+	}
+	if badDist >= 0 {
+		bad = append(bad, 257, 288+badDist)
+	}
+	f.emit(ops, lit, dist, bad)
+}
+
+// lengths returns code lengths for the symbols used, random and
+// complete, with none for the rest.
+func lengths(s *gen.State, used []bool) []uint8 {
+	var syms []int
+	for i, u := range used {
+		if u {
+			syms = append(syms, i)
+		}
+	}
+	lens := make([]uint8, len(used))
+	if len(syms) == 0 {
+		return lens
+	}
+	limit := 15
+	if len(used) == 19 {
+		limit = 7
+	}
+	shaped := shape(s, len(syms), limit)
+	gen.Shuffle(s, shaped)
+	for i, sym := range syms {
+		lens[sym] = shaped[i]
+	}
+	return lens
+}
+
+// emit writes ops with the codes lit and dist, then the symbols bad,
+// distance symbols among them numbered from 288, and the end of the
+// block. It adds what ops decode to to the output.
+func (f *fgen) emit(ops []op, lit, dist code, bad []int) {
+	for _, o := range ops {
+		if o.length == 0 {
+			f.w.code(lit.codes[o.lit], lit.lengths[o.lit])
+			f.out = append(f.out, o.lit)
+			continue
+		}
+		sym, extra, bits := lengthSym(o)
+		f.w.code(lit.codes[sym], lit.lengths[sym])
+		f.w.write(extra, bits)
+		sym, extra, bits = distSym(o.dist)
+		f.w.code(dist.codes[sym], dist.lengths[sym])
+		f.w.write(extra, bits)
+		if o.dist <= len(f.out) {
+			for range o.length {
+				f.out = append(f.out, f.out[len(f.out)-o.dist])
+			}
+		}
+	}
+	for _, sym := range bad {
+		if sym >= 288 {
+			f.w.code(dist.codes[sym-288], dist.lengths[sym-288])
+			continue
+		}
+		f.w.code(lit.codes[sym], lit.lengths[sym])
+	}
+	f.w.code(lit.codes[256], lit.lengths[256])
+}
+
+// lengthSym returns the symbol and extra bits for the length of o.
+func lengthSym(o op) (sym int, extra uint64, bits uint) {
+	if o.long {
+		return 284, 31, 5
+	}
+	i := len(lengthBase) - 1
+	for lengthBase[i] > o.length {
+		i--
+	}
+	return 257 + i, uint64(o.length - lengthBase[i]), lengthExtra[i]
+}
+
+// distSym returns the symbol and extra bits for distance d.
+func distSym(d int) (sym int, extra uint64, bits uint) {
+	i := len(distBase) - 1
+	for distBase[i] > d {
+		i--
+	}
+	return i, uint64(d - distBase[i]), distExtra[i]
+}
+
+func b2u(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,228 @@
+package compresssrc
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/adler32"
+	"hash/crc32"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "compress/gzip",
+		Doc:  "gzip streams: members with extra fields, Latin-1 names and comments and header checksums, concatenated members, BGZF blocks, padding, and bad methods, flags, checksums and sizes",
+		Func: gzipSeed,
+	})
+	gen.Register(&gen.Generator{
+		Name: "compress/zlib",
+		Doc:  "zlib streams: every window size and level, empty and unknown preset dictionaries, and bad headers and checksums",
+		Func: zlibSeed,
+	})
+}
+
+// Header flags of a gzip member.
+const (
+	flagText    = 1 << 0
+	flagHCRC    = 1 << 1
+	flagExtra   = 1 << 2
+	flagName    = 1 << 3
+	flagComment = 1 << 4
+)
+
+var (
+	// fileNames are names a gzip member gives, in Latin-1.
+	fileNames = []string{"", "a.txt", "data.tar", "\xe9t\xe9.txt", "dir/file", "../../etc/passwd", "C:\\x", "\xff\xfe", "name with spaces"}
+	// fileComments are comments a gzip member gives, in Latin-1.
+	fileComments = []string{"", "a comment", "caf\xe9", "line\nbreak", "\x01\x7f\x80\x9f"}
+)
+
+// gzipSeed writes one gzip stream.
+func gzipSeed(s *gen.State) []gen.File {
+	var b []byte
+	switch {
+	case s.Chance(0.1):
+		// BGZF: members of at most 64 KiB, each giving its size in an
+		// extra field, and an empty one to end.
+		for range s.Range(1, 4) {
+			b = member(s, b, content(s), true)
+		}
+		b = member(s, b, nil, true)
+	default:
+		for range gen.Pick(s, 1, 1, 1, 2, 3, 8) {
+			data := content(s)
+			if s.Chance(0.1) {
+				data = nil
+			}
+			b = member(s, b, data, false)
+		}
+	}
+	switch {
+	case s.Chance(0.05):
+		b = append(b, make([]byte, s.Range(1, 512))...)
+	case s.Chance(badRate * 2):
+		b = append(b, gen.Pick(s, "\x1f", "\x1f\x8b", "garbage", "\x1f\x8b\x08\x00")...)
+	case s.Chance(badRate * 2):
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.gz", Data: b}}
+}
+
+// member appends a gzip member of data to b, with the extra field BGZF
+// gives its blocks if bgzf is set.
+func member(s *gen.State, b, data []byte, bgzf bool) []byte {
+	start := len(b)
+	flags := 0
+	if s.Chance(0.1) {
+		flags |= flagText
+	}
+	if s.Chance(0.1) {
+		flags |= flagHCRC
+	}
+	if bgzf || s.Chance(0.15) {
+		flags |= flagExtra
+	}
+	if s.Chance(0.3) {
+		flags |= flagName
+	}
+	if s.Chance(0.15) {
+		flags |= flagComment
+	}
+	if s.Chance(badRate) {
+		flags |= gen.Pick(s, 0x20, 0x40, 0x80)
+	}
+	method := byte(8)
+	if s.Chance(badRate) {
+		method = gen.Pick[byte](s, 0, 7, 9, 0xff)
+	}
+	b = append(b, 0x1f, 0x8b, method, byte(flags))
+	b = binary.LittleEndian.AppendUint32(b, gen.Pick[uint32](s, 0, 0, 1, 1700000000, 1<<31, 0xffffffff))
+	b = append(b, gen.Pick[byte](s, 0, 2, 4), gen.Pick[byte](s, 0, 3, 3, 7, 11, 255))
+
+	stream, out := body(s, data)
+	if flags&flagExtra != 0 {
+		var f []byte
+		if bgzf {
+			// The size of the member less one, filled in once it is
+			// known.
+			f = append(f, 'B', 'C', 2, 0, 0, 0)
+		}
+		for range s.Intn(3) {
+			sub := []byte(gen.Pick(s, "", "x", "\x00\x00\x00\x00", strings.Repeat("e", 300)))
+			f = append(f, gen.Pick(s, "AP", "RA", "sx", "\x00\x00")...)
+			n := len(sub)
+			if s.Chance(badRate) {
+				n = gen.Pick(s, n+1, 0xffff)
+			}
+			f = binary.LittleEndian.AppendUint16(f, uint16(n))
+			f = append(f, sub...)
+		}
+		if bgzf {
+			binary.LittleEndian.PutUint16(f[4:], uint16(len(b)-start+2+len(f)+len(stream)+8-1))
+		}
+		n := len(f)
+		if s.Chance(badRate) {
+			n = gen.Pick(s, n+1, n+100, 0xffff)
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(n))
+		b = append(b, f...)
+	}
+	if flags&flagName != 0 {
+		b = append(b, latin1(s, fileNames)...)
+		b = append(b, 0)
+	}
+	if flags&flagComment != 0 {
+		b = append(b, latin1(s, fileComments)...)
+		b = append(b, 0)
+	}
+	if flags&flagHCRC != 0 {
+		sum := crc32.ChecksumIEEE(b[start:])
+		if s.Chance(badRate) {
+			sum++
+		}
+		b = binary.LittleEndian.AppendUint16(b, uint16(sum))
+	}
+	b = append(b, stream...)
+	sum, size := crc32.ChecksumIEEE(out), uint32(len(out))
+	if s.Chance(badRate) {
+		sum ^= 1 << s.Intn(32)
+	}
+	if s.Chance(badRate) {
+		size = gen.Pick(s, size+1, size-1, 0, 0xffffffff)
+	}
+	b = binary.LittleEndian.AppendUint32(b, sum)
+	return binary.LittleEndian.AppendUint32(b, size)
+}
+
+// latin1 returns one of xs, or now and then a string too long for a
+// reader to take as a name or comment.
+func latin1(s *gen.State, xs []string) string {
+	if s.Chance(0.03) {
+		return strings.Repeat("n", gen.Pick(s, 511, 512, 1024, 70000))
+	}
+	return gen.Pick(s, xs...)
+}
+
+// body returns a deflate stream of data, from the flate generator or
+// package flate, and what it decodes to.
+func body(s *gen.State, data []byte) (stream, out []byte) {
+	if s.Chance(0.5) {
+		return deflate(s, data)
+	}
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, gen.Pick(s, flate.HuffmanOnly, flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression))
+	if err != nil {
+		panic(err)
+	}
+	w.Write(data)
+	if s.Chance(0.2) {
+		w.Flush()
+	}
+	w.Close()
+	return b.Bytes(), data
+}
+
+// zlibSeed writes one zlib stream.
+func zlibSeed(s *gen.State) []gen.File {
+	cinfo := gen.Pick(s, 7, 7, 7, s.Intn(8))
+	method := 8
+	if s.Chance(badRate) {
+		cinfo = s.Range(8, 15)
+	}
+	if s.Chance(badRate) {
+		method = gen.Pick(s, 0, 7, 15)
+	}
+	cmf := cinfo<<4 | method
+	flg := s.Intn(4) << 6
+	dict := s.Chance(0.05)
+	if dict {
+		flg |= 0x20
+	}
+	flg |= 31 - (cmf<<8|flg)%31
+	if s.Chance(badRate) {
+		flg ^= 1 << s.Intn(5)
+	}
+	b := []byte{byte(cmf), byte(flg)}
+	if dict {
+		// The checksum of the empty dictionary, which a reader
+		// without a dictionary has, or of one it does not.
+		b = binary.BigEndian.AppendUint32(b, gen.Pick[uint32](s, 1, 1, adler32.Checksum([]byte("dictionary")), uint32(s.Uint64())))
+	}
+	stream, out := body(s, content(s))
+	b = append(b, stream...)
+	sum := adler32.Checksum(out)
+	if s.Chance(badRate) {
+		sum ^= 1 << s.Intn(32)
+	}
+	b = binary.BigEndian.AppendUint32(b, sum)
+	if s.Chance(0.05) {
+		b = append(b, gen.Pick(s, "\x00", "trailing", "\x78\x9c")...)
+	}
+	if s.Chance(badRate * 2) {
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.zlib", Data: b}}
+}
@@ -0,0 +1,826 @@
+// Package csrc generates C and C++ seeds. It registers the "c/..."
+// generators with package gen.
+//
+// "c/polyglot" writes the C side of what go/cgo writes for Go: a dense
+// file that is C and C++ at once, for fuzzing cgo's handling of C and the
+// C preprocessors, parsers and tools written in Go. The preprocessor is
+// abused with stringizing and pasting, variadic macros counting their
+// arguments, self-referential and mutually recursive macros, X-macros,
+// function-like macros named without a call, #if arithmetic in the
+// preprocessor's own types, #line, _Pragma, line splices inside
+// identifiers and directives, and digraphs in place of brackets and
+// hashes. What only C takes, such as _Generic, designated and ranged
+// initializers, VLA parameters, old-style definitions and a typedef name
+// hidden by a variable, sits under #ifndef __cplusplus; what only C++
+// takes, such as variadic and partially specialized templates, lambdas
+// of every capture, raw string literals holding what looks like
+// directives, user-defined literals and attribute specifiers, under
+// #ifdef. Both sides share GNU statement expressions, labels as values
+// and case ranges. A few constructs are malformed.
+package csrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "c/polyglot",
+		Doc:  "files that are C and C++ at once, dense with preprocessor abuse (pasting, stringizing, variadic, recursive and X-macros, #if arithmetic, #line, _Pragma, line splices), digraphs, C-only designated initializers, _Generic and VLAs, and C++-only templates, lambdas, raw string literals, user-defined literals and attribute specifiers, with a few malformed constructs",
+		Func: polyglot,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// file draws a few dozen, so about one file in ten gets one.
+const badRate = 0.006
+
+// A cgen accumulates a C and C++ file.
+type cgen struct {
+	s        *gen.State
+	b        strings.Builder
+	depth    int  // expressions
+	digraphs bool // whether brackets and hashes are now and then digraphs
+	// consts are integer constant expressions in both languages, such as
+	// enumeration constants; ints are other expressions of type int:
+	// global variables, object-like macros and calls.
+	consts, ints []string
+	// The statements main runs in both languages, and in C or C++ alone.
+	uses, cUses, cppUses []string
+}
+
+func polyglot(s *gen.State) []gen.File {
+	g := &cgen{s: s, depth: s.Depth(s.Limits.Expr, 3), digraphs: s.Chance(0.4)}
+	g.line("/* c/polyglot: C and C++ at once. */")
+	g.prelude()
+	for range s.Range(4, 9) {
+		gen.Pick(s, g.pasting, g.variadic, g.recursive, g.xmacro, g.conditions, g.splices, g.protected, g.pragmas, g.shared, g.shared, g.declarators, g.gnu)()
+	}
+	g.line("#ifdef __cplusplus")
+	for range s.Range(3, 7) {
+		gen.Pick(s, g.templates, g.templates, g.lambdas, g.lambdas, g.rawStrings, g.literals, g.attributes, g.modern)()
+	}
+	g.line("#else")
+	for range s.Range(3, 6) {
+		gen.Pick(s, g.generic, g.initializers, g.vla, g.oldStyle, g.typedefNames, g.c11)()
+	}
+	g.line("#endif")
+	g.main()
+	return []gen.File{{Name: "input.c", Data: []byte(g.b.String())}}
+}
+
+func (g *cgen) broken() bool { return g.s.Chance(badRate) }
+
+// line writes a line formatted as by fmt.Sprintf.
+func (g *cgen) line(format string, args ...any) {
+	fmt.Fprintf(&g.b, format, args...)
+	g.b.WriteByte('\n')
+}
+
+// digraph returns alt, if the file has digraphs, a third of the time, and
+// otherwise tok.
+func (g *cgen) digraph(tok, alt string) string {
+	if g.digraphs && g.s.Intn(3) == 0 {
+		return alt
+	}
+	return tok
+}
+
+// lbrack, rbrack, lbrace and rbrace return brackets and braces, as
+// digraphs now and then.
+func (g *cgen) lbrack() string { return g.digraph("[", "<:") }
+func (g *cgen) rbrack() string { return g.digraph("]", ":>") }
+func (g *cgen) lbrace() string { return g.digraph("{", "<%") }
+func (g *cgen) rbrace() string { return g.digraph("}", "%>") }
+
+// hash returns the hash that begins a directive, padded or spelled as a
+// digraph.
+func (g *cgen) hash() string {
+	return g.digraph(gen.Pick(g.s, "#", "#", "# ", "  #  ", "#/**/", "/* c */#"), "%:")
+}
+
+// directive writes a directive whose hash is spelled now and then as a
+// digraph or padded.
+func (g *cgen) directive(format string, args ...any) {
+	g.line("%s"+format, append([]any{g.hash()}, args...)...)
+}
+
+// integer returns an integer literal valid in both languages.
+func (g *cgen) integer() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, "09", "0x", "1ulu", "0b2", "1'000", "08.", "1e", "0xg")
+	}
+	return gen.Pick(s, "0", "1", "42", "0x7f", "0X1F", "017", "0b101", "1u", "2U", "3l", "4L", "5ll", "6LL", "7ul", "8LLU",
+		"2147483647", "0xffffffffu", "'a'", "'\\x41'", "'\\0'", "'\\''", "'\"'", "L'w'", "'\\377'", "'\\n'", "sizeof(int)", "sizeof 'a'")
+}
+
+// small returns an integer literal that fits in any int and may appear
+// in #if.
+func (g *cgen) small() string {
+	return gen.Pick(g.s, "0", "1", "2", "0x10", "017", "'a'", "-1", "3u", "4L")
+}
+
+// expr returns an integer constant expression at most d deep over the
+// constants defined so far.
+func (g *cgen) expr(d int) string {
+	s := g.s
+	if d <= 0 || s.Chance(0.3) {
+		if len(g.consts) > 0 && s.Chance(0.5) {
+			return gen.Pick(s, g.consts...)
+		}
+		return g.integer()
+	}
+	switch s.Intn(6) {
+	case 0:
+		return "(" + gen.Pick(s, "-", "~", "!", "+", "- -", "!!") + g.expr(d-1) + ")"
+	case 1:
+		return "(" + g.expr(d-1) + " ? " + g.expr(d-1) + " : " + g.expr(d-1) + ")"
+	case 2:
+		return "((int)" + g.expr(d-1) + ")"
+	case 3:
+		// Division only by constants that are not zero, and shifts only
+		// by small amounts, to keep the file free of undefined behavior
+		// the compiler diagnoses.
+		return "(" + g.expr(d-1) + gen.Pick(s, " / 3", " % 7", " << 2", " >> 1") + ")"
+	}
+	op := gen.Pick(s, "+", "-", "*", "&", "|", "^", "&&", "||", "==", "!=", "<", ">=", "<=")
+	return "(" + g.expr(d-1) + " " + op + " " + g.expr(d-1) + ")"
+}
+
+// prelude writes the macros the rest of the file relies on.
+func (g *cgen) prelude() {
+	s := g.s
+	g.directive("ifndef POLYGLOT_H")
+	g.directive("define POLYGLOT_H 1")
+	g.directive("endif")
+	g.line("#ifdef __cplusplus")
+	g.line("#define SA(e, m) static_assert(e, m)")
+	g.line("#define EXTERN_C extern \"C\"")
+	g.line("#else")
+	g.line("#define SA(e, m) _Static_assert(e, m)")
+	g.line("#define EXTERN_C")
+	g.line("#endif")
+	g.directive("define CAT(a, b) a ## b")
+	g.directive("define XCAT(a, b) CAT(a, b)")
+	g.directive("define STR(x) #x")
+	g.directive("define XSTR(x) STR(x)")
+	g.directive("define EMPTY")
+	if g.digraphs {
+		g.directive("define CAT3(a, b, c) a %%:%%: b %%:%%: c") // digraphs of ##
+	} else {
+		g.directive("define CAT3(a, b, c) a##b##c")
+	}
+	if s.Chance(0.3) {
+		g.line("#if __has_include(<stddef.h>)")
+		g.line("#include <stddef.h>")
+		g.line("#endif")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "#include <no/such/header.h>", "#ifdef", "#define", "#define 1 2", "#define CAT(a) a", "#if 1 +", "#endif", "#undef CAT CAT", "#line 0x10", "#error broken"))
+	}
+}
+
+// pasting writes declarations named by pasting and stringizing.
+func (g *cgen) pasting() {
+	s := g.s
+	name := s.Fresh("pasted")
+	half := len(name) / 2
+	g.line("static int CAT(%s, %s) = %s;", name[:half], name[half:], g.expr(g.depth))
+	g.ints = append(g.ints, name)
+	str := s.Fresh("str")
+	g.line("static const char %s%s%s = XSTR(__LINE__) STR(%s) STR( a  \"b\\n\"  'c' ) XSTR(EMPTY) STR(/* comment */ x);",
+		str, g.lbrack(), g.rbrack(), gen.Pick(s, "a + b", "\"q\"", "'\\\\'", "", "(,)", "L\"w\""))
+	g.uses = append(g.uses, fmt.Sprintf("(void)%s;", str))
+	m := strings.ToUpper(s.Fresh("P"))
+	g.directive("define %s(x) CAT3(x, _, %s)", m, name)
+	g.line("static int %s(v) = 1;", m)
+	g.ints = append(g.ints, "v_"+name)
+	if g.broken() {
+		g.line("int CAT(+, /) = 1;")
+	}
+}
+
+// variadic writes variadic macros, some counting their arguments.
+func (g *cgen) variadic() {
+	s := g.s
+	if !strings.Contains(g.b.String(), "define COUNT(") {
+		g.directive("define COUNT_(a, b, c, d, e, n, ...) n")
+		g.directive("define COUNT(...) COUNT_(__VA_ARGS__, 5, 4, 3, 2, 1, 0)")
+		g.directive("define FIRST(x, ...) x")
+		g.directive("define REST(x, ...) __VA_ARGS__")
+		g.directive("define CALL(f, ...) f(__VA_ARGS__)")
+		g.directive("define OPT(x, ...) x __VA_OPT__(+ FIRST(__VA_ARGS__))")
+	}
+	n := s.Range(1, 5)
+	args := make([]string, n)
+	for i := range args {
+		args[i] = gen.Pick(s, "a", "(b, c)", "1", "\"s,t\"", "'\\''", "f()", "EMPTY", "[x]")
+	}
+	g.line("SA(COUNT(%s) == %d, \"COUNT\");", strings.Join(args, ", "), n)
+	name := s.Fresh("opt")
+	g.line("static int %s = CALL(FIRST, %s, 2) + FIRST(%s) + FIRST(REST(, %s), 0);", name, g.small(), g.small(), g.small())
+	g.ints = append(g.ints, name)
+	if s.Chance(0.3) {
+		// __VA_OPT__ is C23 and C++20, which GCC takes in C17 and C++17.
+		g.line("static int %s_opt = OPT(%s) + OPT(%s, %s);", name, g.small(), g.small(), g.small())
+		g.ints = append(g.ints, name+"_opt")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "#define V(...) __VA_ARGS__ __VA_ARGS", "#define W(x, ...) #__VA_ARGS__ ##", "int COUNT() = 0;", "#define __VA_ARGS__ 1"))
+	}
+}
+
+// recursive writes macros that refer to themselves or to each other,
+// which expand once and leave their names as they are.
+func (g *cgen) recursive() {
+	s := g.s
+	a, b := s.Fresh("self"), s.Fresh("mut")
+	c := s.Fresh("mut")
+	g.line("static int %s = 1, %s = 2, %s = 3;", a, b, c)
+	g.directive("define %s (4 + %s)", a, a)
+	g.directive("define %s (%s * 2)", b, c)
+	g.directive("define %s (%s - 1)", c, b)
+	g.ints = append(g.ints, a, b, c)
+	g.line("SA(sizeof(%s) == sizeof(int), \"self-reference\");", a)
+	if s.Chance(0.5) {
+		// twice(twice_id)(1) expands to twice_id(1), a call of the
+		// function: the name of twice_id comes out of its own expansion.
+		f := s.Fresh("twice")
+		g.line("static int %s_id(int x) %s return x; %s", f, g.lbrace(), g.rbrace())
+		g.directive("define %s(x) x(x)", f)
+		g.directive("define %s_id(x) x", f)
+		g.ints = append(g.ints, fmt.Sprintf("%s(%s_id)(1)", f, f))
+	}
+}
+
+// xmacro writes an enum and a table from one list macro.
+func (g *cgen) xmacro() {
+	s := g.s
+	list := strings.ToUpper(s.Fresh("LIST"))
+	var items []string
+	for range s.Range(1, 4) {
+		k := strings.ToUpper(s.Fresh("K"))
+		items = append(items, fmt.Sprintf("X(%s, %s)", k, g.small()))
+		g.consts = append(g.consts, "(int)"+k)
+	}
+	g.directive("define %s(X) %s", list, strings.Join(items, " \\\n\t"))
+	g.directive("define ENUM_%s(n, v) n = v,", list)
+	g.directive("define NAME_%s(n, v) #n,", list)
+	g.line("enum %s %s %s(ENUM_%s) %s_END %s;", strings.ToLower(list), g.lbrace(), list, list, list, g.rbrace())
+	g.line("static const char *const %s_names%s%s = %s %s(NAME_%s) 0 %s;", strings.ToLower(list), g.lbrack(), g.rbrack(), g.lbrace(), list, list, g.rbrace())
+	g.directive("undef ENUM_%s", list)
+	g.uses = append(g.uses, fmt.Sprintf("(void)%s_names;", strings.ToLower(list)))
+}
+
+// conditions writes #if groups whose conditions are evaluated in the
+// preprocessor's intmax_t and uintmax_t, with an #error in the branch
+// taken if the preprocessor gets them wrong.
+func (g *cgen) conditions() {
+	s := g.s
+	type cond struct {
+		expr string
+		want bool
+	}
+	conds := []cond{
+		{"-1 > 0u", true},
+		{"-1 < 0", true},
+		{"0x7fffffffffffffff + 0 > 0", true},
+		{"(2 || 1 / 0)", true},
+		{"(0 && 1 / 0)", false},
+		{"defined(CAT) && defined CAT", true},
+		{"defined(NO_SUCH_MACRO) || NO_SUCH_MACRO", false},
+		{"'a' == 97", true},
+		{"(1 ? 2 : 3) == 2", true},
+		{"~0u == 0xffffffffffffffff", true},
+		{"-1 >> 1 == -1", true},
+		{"0x10 == 16 && 010 == 8 && 0b10 == 2", true},
+		{"__LINE__ > 0", true},
+		{"1 == 1L && 1 == 1ULL", true},
+		{"(3, 4) == 4", true},
+		{"!defined(__cplusplus) || __cplusplus >= 201103L", true},
+		{"true", false},
+	}
+	for range s.Range(1, 3) {
+		c := gen.Pick(s, conds...)
+		if c.expr == "true" {
+			// true is an identifier, and 0, in C; and true in C++.
+			g.line("#if true")
+			g.line("#ifndef __cplusplus")
+			g.line("#error \"true is 0 in the C preprocessor\"")
+			g.line("#endif")
+			g.line("#endif")
+			continue
+		}
+		if c.expr == "(3, 4) == 4" && s.Chance(0.5) {
+			continue // a comma in #if is an error before C23 and C++20
+		}
+		g.directive("if %s", c.expr)
+		if !c.want {
+			g.line("#error \"%s is false\"", strings.ReplaceAll(c.expr, "\"", ""))
+		}
+		g.directive("elif %s", g.small())
+		g.directive("else")
+		if c.want {
+			g.line("#error \"%s is true\"", strings.ReplaceAll(c.expr, "\"", ""))
+		}
+		g.directive("endif")
+	}
+	// A false group need hold only preprocessing tokens, and its
+	// directives other than conditionals are ignored.
+	g.line("#if 0")
+	g.line("%s", gen.Pick(s, "#error never", "#include <nonexistent.h>", "#define NEVER 1", "#pragma whatever", "#garbage directive", "#if 1\n#else garbage\n#endif"))
+	g.line("#endif")
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "#if\n#endif", "#if 1\n#else\n#else\n#endif", "#elif 1", "#if (1\n#endif", "#if 1.0\n#endif", "#if 1 /\n#endif", "#if 0\n#endif junk(", "#ifdef 1\n#endif"))
+	}
+}
+
+// splices writes tokens and directives split by backslash-newlines, and
+// comments where the lexer must not see them.
+func (g *cgen) splices() {
+	s := g.s
+	name := s.Fresh("spl")
+	cut := 1 + s.Intn(len(name)-1)
+	switch s.Intn(5) {
+	case 0:
+		g.line("static in\\\nt %s\\\n%s = 1;", name[:cut], name[cut:])
+	case 1:
+		g.line("#def\\\nine %s 1 /* a comment\n spanning lines */ + 1", name)
+	case 2:
+		g.line("static int %s = 1 /\\\n* a comment begun across a splice *\\\n/;", name)
+	case 3:
+		g.line("// a line comment ending in a backslash \\\nstatic int %s_gone = 1;", name)
+		g.line("static int %s = 2;", name)
+	default:
+		g.line("static int/**/%s/**/=/**/1;", name)
+	}
+	g.ints = append(g.ints, name)
+	g.line("/* ??= ??( ??) trigraphs, which C17 may and C++17 must ignore */")
+	if s.Chance(0.3) {
+		g.line("static const char %s_tri%s%s = \"??=??(??)??<??>??-??!\";", name, g.lbrack(), g.rbrack())
+		g.uses = append(g.uses, fmt.Sprintf("(void)%s_tri;", name))
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "/* unterminated comment", "static int x = 1 \\", "#define SPL\\", "\"unterminated string", "'unterminated"))
+	}
+}
+
+// protected writes a function that a function-like macro of the same
+// name would expand, but for the parentheses around its name.
+func (g *cgen) protected() {
+	s := g.s
+	f := s.Fresh("inc")
+	g.directive("define %s(x) ((x) + 1)", f)
+	g.line("static int (%s)(int x) %s return x; %s", f, g.lbrace(), g.rbrace())
+	g.line("static int (*const %s_ptr)(int) = %s;", f, f)
+	g.ints = append(g.ints, fmt.Sprintf("%s(%s)", f, g.integer()), fmt.Sprintf("(%s)(%s)", f, g.integer()))
+	g.uses = append(g.uses, fmt.Sprintf("(void)%s_ptr(1);", f))
+}
+
+// pragmas writes #line, #pragma and _Pragma.
+func (g *cgen) pragmas() {
+	s := g.s
+	switch s.Intn(4) {
+	case 0:
+		n := s.Range(1, 1<<20)
+		g.directive("line %d \"renamed%s.c\"", n, gen.Pick(s, "", "\\\\dir", " spaced", "\\\"quoted"))
+		g.line("SA(__LINE__ == %d, \"#line\");", n)
+	case 1:
+		st := s.Fresh("packed")
+		g.directive("pragma pack(push, 1)")
+		g.line("struct %s %s char c; int i; %s;", st, g.lbrace(), g.rbrace())
+		g.directive("pragma pack(pop)")
+		g.line("SA(sizeof(struct %s) == 1 + sizeof(int), \"pack\");", st)
+	case 2:
+		g.line("_Pragma(\"GCC diagnostic push\") _Pragma(\"GCC diagnostic ignored \\\"-Wunused-variable\\\"\")")
+		g.line("static int %s;", s.Fresh("quiet"))
+		g.line("_Pragma(\"GCC diagnostic pop\")")
+	default:
+		g.directive("define DO_PRAGMA(x) _Pragma(#x)")
+		g.line("DO_PRAGMA(GCC diagnostic ignored \"-Wunused-function\")")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "_Pragma(1)", "_Pragma(\"unterminated)", "#line -1", "#line 1 2", "#pragma pack(pop, pop, pop)\n_Pragma"))
+	}
+}
+
+// shared writes declarations that mean the same in both languages.
+func (g *cgen) shared() {
+	s := g.s
+	switch s.Intn(6) {
+	case 0:
+		st := s.Fresh("rec")
+		g.line("struct %s %s", st, g.lbrace())
+		g.line("\tunsigned a : %d, : 0, b : %d;", s.Range(1, 31), s.Range(1, 31))
+		g.line("\tstruct %s *next;", st)
+		g.line("\tunion %s int i; float f; %s u;", g.lbrace(), g.rbrace())
+		g.line("\tint arr%s%d%s;", g.lbrack(), s.Range(1, 8), g.rbrack())
+		g.line("%s;", g.rbrace())
+		v := s.Fresh("r")
+		g.line("static struct %s %s = %s %s %s;", st, v, g.lbrace(), gen.Pick(s, "0", "1", "'a'", "0x7u"), g.rbrace())
+		g.uses = append(g.uses, fmt.Sprintf("(void)%s.next;", v))
+	case 1:
+		e := s.Fresh("en")
+		a, b := strings.ToUpper(s.Fresh("E")), strings.ToUpper(s.Fresh("E"))
+		g.line("enum %s %s %s = %s, %s, %s;", e, g.lbrace(), a, g.small(), b, g.rbrace())
+		g.consts = append(g.consts, "(int)"+a, "(int)"+b)
+	case 2:
+		// A function with external linkage has C linkage in both.
+		f := s.Fresh("fn")
+		g.line("EXTERN_C int %s(int x);", f)
+		g.line("int %s(int x) %s return %s; %s", f, g.lbrace(), g.exprWith("x"), g.rbrace())
+		g.ints = append(g.ints, fmt.Sprintf("%s(%s)", f, g.integer()))
+	case 3:
+		v := s.Fresh("g")
+		g.line("static int %s = %s;", v, g.expr(g.depth))
+		g.ints = append(g.ints, v)
+	case 4:
+		f := s.Fresh("sw")
+		g.line("static int %s(int n) %s", f, g.lbrace())
+		g.line("\tint r = 0;")
+		g.line("\tswitch (n) %s", g.lbrace())
+		g.line("\tcase 0: do %s r++;", g.lbrace())
+		g.line("\tcase 1: r++;")
+		g.line("\tcase 2: r++;")
+		g.line("\t%s while (--n > 0);", g.rbrace())
+		g.line("\t\tbreak;")
+		g.line("\tdefault:;")
+		g.line("\t%s", g.rbrace())
+		g.line("\treturn r;")
+		g.line("%s", g.rbrace())
+		g.ints = append(g.ints, f+"(3)")
+	default:
+		f := s.Fresh("loop")
+		g.line("static int %s(void) %s", f, g.lbrace())
+		g.line("\tint t = 0;")
+		g.line("\tfor (int i = 0, j = 10; i < j; i++, j--) %s if (i %% 2) continue; t += i; %s", g.lbrace(), g.rbrace())
+		g.line("\tgoto out; out: return t;")
+		g.line("%s", g.rbrace())
+		g.ints = append(g.ints, f+"()")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "struct { int x; }", "int f(int x { return x; }", "static int 1x;", "enum { A, A };", "int a[2] = { 1, 2, 3 };", "int;", "goto nowhere;", "break;"))
+	}
+}
+
+// exprWith returns an int expression that uses the variable v.
+func (g *cgen) exprWith(v string) string {
+	return "(" + v + " " + gen.Pick(g.s, "+", "*", "^", "-") + " " + g.expr(g.depth-1) + ")"
+}
+
+// declarators writes declarations with declarators nested around
+// pointers, arrays and functions.
+func (g *cgen) declarators() {
+	s := g.s
+	f, h := s.Fresh("dbl"), s.Fresh("get")
+	g.line("static int %s(int x) %s return x * 2; %s", f, g.lbrace(), g.rbrace())
+	g.line("static int (*%s(void))(int) %s return %s; %s", h, g.lbrace(), f, g.rbrace())
+	g.ints = append(g.ints, fmt.Sprintf("%s()(%s)", h, g.integer()))
+	switch s.Intn(4) {
+	case 0:
+		a := s.Fresh("arr")
+		g.line("static int %s%s3%s%s4%s;", a, g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack())
+		g.line("static int (*%s_p)%s4%s = %s;", a, g.lbrack(), g.rbrack(), a)
+		g.line("static int (*(*%s_f)(int))%s4%s;", a, g.lbrack(), g.rbrack())
+		g.ints = append(g.ints, fmt.Sprintf("%s_p%s1%s%s2%s", a, g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack()), fmt.Sprintf("2%s%s%s%s0%s", g.lbrack(), a, g.rbrack(), g.lbrack(), g.rbrack()))
+		g.uses = append(g.uses, fmt.Sprintf("(void)%s_f;", a))
+	case 1:
+		t := s.Fresh("fnp")
+		g.line("typedef int (*%s)(int);", t)
+		g.line("static %s %s_table%s%s = %s %s, %s, 0 %s;", t, t, g.lbrack(), g.rbrack(), g.lbrace(), f, f, g.rbrace())
+		g.ints = append(g.ints, fmt.Sprintf("%s_table%s0%s(%s)", t, g.lbrack(), g.rbrack(), g.integer()), fmt.Sprintf("(*%s_table)(1)", t), fmt.Sprintf("(**%s_table)(2)", t))
+	case 2:
+		v := s.Fresh("cv")
+		g.line("static const volatile int *const %s = 0, *volatile %s_2 = 0;", v, v)
+		g.line("static int const %s_c = %s;", v, g.integer())
+		g.uses = append(g.uses, fmt.Sprintf("(void)%s; (void)%s_2;", v, v))
+		g.ints = append(g.ints, v+"_c")
+	default:
+		v := s.Fresh("cast")
+		g.line("static int %s = (int)(long)(char)(unsigned short)%s;", v, g.integer())
+		g.ints = append(g.ints, v, fmt.Sprintf("(int)sizeof(int (*)%s2%s)", g.lbrack(), g.rbrack()))
+	}
+}
+
+// gnu writes the GNU extensions both languages take: statement
+// expressions, labels as values, case ranges and __typeof__.
+func (g *cgen) gnu() {
+	s := g.s
+	f := s.Fresh("gnu")
+	g.line("static int %s(int n) %s", f, g.lbrace())
+	g.line("\tstatic void *const labels%s%s = %s &&zero, &&other %s;", g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace())
+	g.line("\t__typeof__(n) m = __extension__ (%s int t = n; t + %s; %s);", g.lbrace(), g.integer(), g.rbrace())
+	g.line("\tswitch (m) %s", g.lbrace())
+	g.line("\tcase 0 ... 9: m += 1; break;")
+	g.line("\tcase 'a' ... 'z': m += 2; break;")
+	g.line("\t%s", g.rbrace())
+	g.line("\tgoto *labels%s(n & 1)%s;", g.lbrack(), g.rbrack())
+	g.line("zero: return m;")
+	g.line("other: return __builtin_expect(m, 0) + (int)__alignof__(long);")
+	g.line("%s", g.rbrace())
+	g.ints = append(g.ints, f+"("+g.integer()+")")
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "static int bad = ({ 1; });", "void f(void) { goto *0; case 1: ; }", "static void *p = &&nolabel;"))
+	}
+}
+
+// templates writes C++ templates, variadic, defaulted, specialized and
+// passed as arguments.
+func (g *cgen) templates() {
+	s := g.s
+	t := s.Fresh("Box")
+	g.line("template <typename T, int N = %d> struct %s %s T a%sN%s; static const int size = N; %s;", s.Range(1, 4), t, g.lbrace(), g.lbrack(), g.rbrack(), g.rbrace())
+	g.line("template <typename T> struct %s<T *, 2> %s static const int size = -2; %s;", t, g.lbrace(), g.rbrace())
+	switch s.Intn(6) {
+	case 0:
+		// >> closes two template argument lists, and a > in an argument
+		// needs parentheses.
+		g.line("SA(%s<%s<int>>::size == %s<int>::size, \">>\");", t, t, t)
+		g.line("SA((%s<int, (3 > 2)>::size == 1), \"parenthesized >\");", t)
+		g.line("SA((%s<int, (8 >> 2)>::size == 2), \"parenthesized >>\");", t)
+	case 1:
+		f := s.Fresh("sum")
+		g.line("template <class... Ts> constexpr auto %s(Ts... ts) %s return (ts + ... + 0); %s", f, g.lbrace(), g.rbrace())
+		g.line("template <class... Ts> constexpr int %s_n = sizeof...(Ts);", f)
+		g.line("SA((%s(1, 2, 3) == 6 && %s_n<int, char> == 2), \"fold\");", f, f)
+		g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s();", f))
+	case 2:
+		h := s.Fresh("Apply")
+		g.line("template <template <typename, int> class B, typename T> struct %s %s typedef B<T, 1> type; %s;", h, g.lbrace(), g.rbrace())
+		g.line("SA((%s<%s, char>::type::size == 1), \"template template\");", h, t)
+		g.line("template <typename T> using %s_ptr = typename %s<%s, T>::type *;", h, h, t)
+	case 3:
+		h := s.Fresh("Get")
+		g.line("struct %s %s template <int I> static constexpr int get() %s return I; %s %s;", h, g.lbrace(), g.lbrace(), g.rbrace(), g.rbrace())
+		g.line("template <typename T> constexpr int %s_call() %s return T::template get<3>(); %s", h, g.lbrace(), g.rbrace())
+		g.line("SA(%s_call<%s>() == 3, \"template disambiguator\");", h, h)
+	case 4:
+		v := s.Fresh("pi")
+		g.line("template <class T> constexpr T %s = T(3.1415926535897932385L);", v)
+		g.line("SA(%s<int> == 3, \"variable template\");", v)
+	default:
+		h := s.Fresh("Enable")
+		g.line("template <bool B, class T = void> struct %s %s%s;", h, g.lbrace(), g.rbrace())
+		g.line("template <class T> struct %s<true, T> %s typedef T type; %s;", h, g.lbrace(), g.rbrace())
+		g.line("template <class T> typename %s<(sizeof(T) > 1), int>::type %s_f(T) %s return 1; %s", h, h, g.lbrace(), g.rbrace())
+		g.line("template <class T> typename %s<(sizeof(T) <= 1), int>::type %s_f(T) %s return 0; %s", h, h, g.lbrace(), g.rbrace())
+		g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s_f('c'); (void)%s_f(1.0);", h, h))
+	}
+	if g.broken() {
+		g.line(gen.Pick(s, "SA(%s<int, 3 > 2>::size, \"\");", "template <typename T> struct %s<T> {};", "%s<%s<int>> > x;", "template <> struct %s {};", "template struct %s<>;"), t, t)
+	}
+}
+
+// lambdas writes C++ lambdas with every kind of capture.
+func (g *cgen) lambdas() {
+	s := g.s
+	f := s.Fresh("lam")
+	g.line("static int %s(int y) %s", f, g.lbrace())
+	g.line("\tint z = %s;", g.integer())
+	for range s.Range(1, 4) {
+		g.line("\t{ %s }", gen.Pick(s,
+			"y += [](int x) { return x + 1; }(y);",
+			"y += [=]() mutable { return ++z; }();",
+			"y += [&, z]() -> int { return y + z; }();",
+			"y += [w = y * 2, &r = z]() { return w + r; }();",
+			"y += [](auto... a) { return (0 + ... + a); }(1, 2, 3);",
+			"y += [&]{ return [&]{ return z; }(); }();",
+			"y += (+[](int x) { return x; })(4);",
+			"y += []() constexpr { return 5; }();",
+			"auto rec = [](auto self, int n) -> int { return n ? n + self(self, n - 1) : 0; }; y += rec(rec, 3);",
+			"y += [y]<%int *p = nullptr; return p ? 0 : y;%>();",
+			"y += [](int a<::> = nullptr) { return a ? 1 : 0; }();",
+		))
+	}
+	g.line("\treturn y;")
+	g.line("%s", g.rbrace())
+	g.line("#if __cplusplus >= 202002L")
+	g.line("static auto %s_t = []<class T>(T t) { return sizeof(t); };", f)
+	g.line("#endif")
+	g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s(%s);", f, g.integer()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "auto bad = [](int x) -> { return x; };", "auto bad = [=, =]() {};", "auto bad = [&this]() {};", "auto bad = [x]() { return x; };", "auto bad = [](){"))
+	}
+}
+
+// rawStrings writes C++ raw string literals holding what would be
+// comments, escapes, splices and directives outside them.
+func (g *cgen) rawStrings() {
+	s := g.s
+	v := s.Fresh("raw")
+	macro := strings.ToUpper(s.Fresh("NOT_A_MACRO"))
+	delim := gen.Pick(s, "", "x", "delim", "()", "\"\"", "a-b", "__")
+	if delim == "()" || delim == "\"\"" {
+		delim = "d" // parentheses and quotes may not be in a delimiter
+	}
+	body := gen.Pick(s,
+		`)" not the end`,
+		`/* not a comment */ // nor this`,
+		`\n is two characters, \`+"\n"+`and this line is not spliced`,
+		"\n#define "+macro+" 1\n#endif not a directive",
+		`??= ??/ are not trigraphs`,
+		"\"quoted\" 'single' \\\" \\' \\\\",
+		"",
+		")"+delim+" only closes with the quote",
+	)
+	if delim == "" && strings.Contains(body, ")\"") {
+		delim = "x"
+	}
+	prefix := gen.Pick(s, "R", "u8R", "LR", "uR", "UR")
+	g.line("static const auto %s = %s\"%s(%s)%s\";", v, prefix, delim, body, delim)
+	g.line("#ifdef %s", macro)
+	g.line("#error \"a directive in a raw string\"")
+	g.line("#endif")
+	g.line("SA(sizeof %s > 0, \"raw\");", v)
+	g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s;", v))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, `const char *bad = R"x(unterminated)";`, `const char *bad = R"a b(x)a b";`, `const char *bad = R"(x)y";`, `const char *bad = R"0123456789abcdefg(x)0123456789abcdefg";`))
+	}
+}
+
+// literals writes C++ user-defined literals, digit separators and the
+// literals C++ adds.
+func (g *cgen) literals() {
+	s := g.s
+	k := "_" + s.Fresh("k")
+	g.line("constexpr unsigned long long operator\"\"%s(unsigned long long v) %s return v * 1000; %s", k, g.lbrace(), g.rbrace())
+	g.line("constexpr long double operator\"\" %s(long double v) %s return v; %s", k, g.lbrace(), g.rbrace())
+	g.line("constexpr decltype(sizeof 0) operator\"\"%s(const char *, decltype(sizeof 0) n) %s return n; %s", k, g.lbrace(), g.rbrace())
+	g.line("SA(3%s == 3000 && \"abc\"%s == 3 && 1.5%s > 1, \"user-defined literals\");", k, k, k)
+	g.line("SA(1'000'000 == 1000000 && 0b1010'1010 == 0xAA && 0x1p-2 == 0.25 && 0xF'F == 255, \"digit separators\");")
+	if s.Chance(0.3) {
+		g.line("SA(sizeof(u8\"\\u00e9\") == 3 && sizeof(U'\\U0001F600') == 4 && u'\\uffff' == 0xffff, \"character literals\");")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "int bad = 1''000;", "int bad = 1000'", "int bad = 0x'1;", "int bad = 3_undefined;", "int operator\"\"bad(unsigned long long);"))
+	}
+}
+
+// attributes writes C++ attribute specifiers on declarations, statements
+// and types.
+func (g *cgen) attributes() {
+	s := g.s
+	f := s.Fresh("attr")
+	g.line("[[nodiscard, gnu::always_inline]] inline int %s(%s int x) %s", f, gen.Pick(s, "[[maybe_unused]]", "[[gnu::unused]]", "[[maybe_unused, gnu::unused]]"), g.lbrace())
+	g.line("\tswitch (x) %s", g.lbrace())
+	g.line("\tcase 1: x++; [[fallthrough]];")
+	g.line("\tcase 2: [[likely]] return x;")
+	g.line("\tdefault: break;")
+	g.line("\t%s", g.rbrace())
+	g.line("\t[[maybe_unused]] alignas(16) int y%s4%s = %s%s;", g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace())
+	g.line("\treturn x;")
+	g.line("%s", g.rbrace())
+	g.line("struct [[deprecated(\"use something else\")]] %s_old %s%s;", f, g.lbrace(), g.rbrace())
+	g.line("enum class [[nodiscard]] %s_e : unsigned char %s a [[maybe_unused]], b = 2 %s;", f, g.lbrace(), g.rbrace())
+	g.line("[[using gnu: cold, noinline]] static void %s_cold() %s%s", f, g.lbrace(), g.rbrace())
+	g.line("namespace [[deprecated]] %s_ns %s int v; %s", f, g.lbrace(), g.rbrace())
+	g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s(%s); %s_cold();", f, g.integer(), f))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "[[ nodiscard int bad();", "int [[nodiscard]] bad;", "[[]] [[ ]] int bad; [[fallthrough]];", "alignas(3) int bad;", "[[using gnu: gnu::cold]] void bad();"))
+	}
+}
+
+// modern writes the other C++11 to C++17 syntax a C parser has never
+// seen.
+func (g *cgen) modern() {
+	s := g.s
+	n := s.Fresh("ns")
+	g.line("namespace %s::inner %s inline namespace v1 %s struct P %s int x, y; %s; %s %s", n, g.lbrace(), g.lbrace(), g.lbrace(), g.rbrace(), g.rbrace(), g.rbrace())
+	f := s.Fresh("modern")
+	g.line("static auto %s(int v) -> decltype(v + 1) %s", f, g.lbrace())
+	g.line("\tauto [a, b] = %s::inner::P%s v, 2 %s;", n, g.lbrace(), g.rbrace())
+	g.line("\tif constexpr (sizeof(int) >= 2) %s a++; %s", g.lbrace(), g.rbrace())
+	g.line("\tif (int t = a * b; t > 0) %s return t; %s", g.lbrace(), g.rbrace())
+	g.line("\tswitch (int u = b; u) %s default: break; %s", g.lbrace(), g.rbrace())
+	g.line("\tint vexing(int()); // a declaration of a function")
+	g.line("\tint (w)(%s); // and of a variable", g.integer())
+	g.line("\tdecltype(auto) r = (w);")
+	g.line("\treturn r + a + (int)sizeof(&vexing) * 0;")
+	g.line("%s", g.rbrace())
+	g.cppUses = append(g.cppUses, fmt.Sprintf("(void)%s(%s);", f, g.integer()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "auto [a, a] = 1;", "namespace ::bad {}", "if constexpr int x;", "decltype(auto) *bad = 0;"))
+	}
+}
+
+// generic writes C _Generic selections.
+func (g *cgen) generic() {
+	s := g.s
+	m := strings.ToUpper(s.Fresh("TYPEOF"))
+	g.line("#define %s(x) _Generic((x), int: 1, char *: 2, const char *: 3, long double: 4, default: 0)", m)
+	g.line("SA(%s(1) == 1 && %s(\"s\") == 2 && %s(1.0L) == 4 && %s('a') == 1 && %s((char)'a') == 0, \"_Generic\");", m, m, m, m, m)
+	g.line("SA(_Generic(1 ? (void *)0 : (int *)0, int *: 1, default: 0), \"null pointer constant\");")
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "SA(_Generic(1, int: 1, int: 2), \"\");", "SA(_Generic(1), \"\");", "SA(_Generic(1, default: 1, default: 2), \"\");"))
+	}
+}
+
+// initializers writes C designated, ranged and nested initializers and
+// compound literals.
+func (g *cgen) initializers() {
+	s := g.s
+	st := s.Fresh("pt")
+	g.line("struct %s %s int x, y; int a%s4%s; struct %s int z; %s in; %s;", st, g.lbrace(), g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace(), g.rbrace())
+	v := s.Fresh("d")
+	g.line("static struct %s %s = %s .y = 2, .x = 1, .a%s3%s = 3, .a%s1%s = 4, .in = %s 5 %s %s;", st, v, g.lbrace(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace(), g.rbrace())
+	g.line("static int %s_arr%s%s = %s %s9%s = 1, %s2 ... 4%s = 3, %s1%s = 2 %s;", v, g.lbrack(), g.rbrack(), g.lbrace(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.rbrace())
+	g.line("SA(sizeof %s_arr == 10 * sizeof(int), \"designators\");", v)
+	g.cUses = append(g.cUses, fmt.Sprintf("(void)%s; (void)(struct %s)%s .x = (int)%s_arr%s0%s %s; (void)((int%s%s)%s 1, 2, 3 %s)%s1%s;",
+		v, st, g.lbrace(), v, g.lbrack(), g.rbrack(), g.rbrace(), g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace(), g.lbrack(), g.rbrack()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "static int bad[2] = { [2] = 1 };", "static struct { int x; } bad = { .y = 1 };", "static int bad[] = { [1 ... 0] = 1 };", "static int bad[] = { [-1] = 1 };"))
+	}
+}
+
+// vla writes C variable length arrays and array parameters with
+// qualifiers and static.
+func (g *cgen) vla() {
+	s := g.s
+	f := s.Fresh("vla")
+	if s.Chance(0.5) {
+		g.line("static int %s(int n, int a%sstatic 1%s, int m%s*%s, const int p%sconst restrict%s);", f, g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack())
+	}
+	g.line("static int %s(int n, int a%sstatic 1%s, int m%sn%s, const int p%sconst restrict%s) %s", f, g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrace())
+	g.line("\tint local%sn + 1%s%sn + 1%s;", g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack())
+	g.line("\tlocal%s0%s%s0%s = a%s0%s + (int)sizeof local + (p ? 1 : 0) + (m ? 1 : 0);", g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack())
+	g.line("\ttypedef int row%sn%s;", g.lbrack(), g.rbrack())
+	g.line("\treturn local%s0%s%s0%s + (int)sizeof(row);", g.lbrack(), g.rbrack(), g.lbrack(), g.rbrack())
+	g.line("%s", g.rbrace())
+	g.cUses = append(g.cUses, fmt.Sprintf("%s int one%s1%s = %s 1 %s; (void)%s(1, one, 0, one); %s", g.lbrace(), g.lbrack(), g.rbrack(), g.lbrace(), g.rbrace(), f, g.rbrace()))
+}
+
+// oldStyle writes C function definitions with identifier lists, which C23
+// removes.
+func (g *cgen) oldStyle() {
+	s := g.s
+	f := s.Fresh("kr")
+	g.line("static int %s(a, b, c)", f)
+	g.line("\tint a; char *b;")
+	g.line("\tdouble c;")
+	g.line("%s return a + (b != 0) + (int)c; %s", g.lbrace(), g.rbrace())
+	g.cUses = append(g.cUses, fmt.Sprintf("(void)%s(1, 0, 2.0);", f))
+}
+
+// typedefNames writes C blocks where a typedef name is hidden by a
+// variable, so that the same tokens declare and multiply.
+func (g *cgen) typedefNames() {
+	s := g.s
+	t := s.Fresh("T")
+	f := s.Fresh("hide")
+	g.line("typedef int %s;", t)
+	g.line("static int %s(int y) %s", f, g.lbrace())
+	g.line("\t%s * p = &y; /* a declaration */", t)
+	g.line("\t%s", g.lbrace())
+	g.line("\t\tint %s = 3;", t)
+	g.line("\t\ty = %s * y; /* a multiplication */", t)
+	g.line("\t\ty += (%s)+1; /* not a cast */", t)
+	g.line("\t%s", g.rbrace())
+	g.line("\ty += (%s)+1; /* a cast */", t)
+	g.line("\treturn *p + (int)sizeof(%s) + (int)sizeof y;", t)
+	g.line("%s", g.rbrace())
+	g.cUses = append(g.cUses, fmt.Sprintf("(void)%s(%s);", f, g.integer()))
+}
+
+// c11 writes the C11 keywords and types C++ spells otherwise.
+func (g *cgen) c11() {
+	s := g.s
+	v := s.Fresh("c11")
+	g.line("static _Alignas(16) char %s_buf%s16%s;", v, g.lbrack(), g.rbrack())
+	g.line("static _Thread_local int %s_tls;", v)
+	g.line("static _Atomic int %s_atomic;", v)
+	g.line("static _Bool %s_b = 2;", v)
+	g.line("static double _Complex %s_z = 1.0 + 2.0i;", v)
+	g.line("static _Noreturn void %s_exit(void) %s for (;;); %s", v, g.lbrace(), g.rbrace())
+	g.line("SA(_Alignof(%s_buf) == 16 && sizeof(_Bool) == 1, \"C11\");", v)
+	g.cUses = append(g.cUses, fmt.Sprintf("(void)%s_tls; (void)%s_atomic; (void)%s_b; (void)%s_z; if (0) %s_exit();", v, v, v, v, v))
+}
+
+// main writes main, which uses what the file defines.
+func (g *cgen) main() {
+	s := g.s
+	g.line("int main(void) %s", g.lbrace())
+	g.line("\tint sink = 0;")
+	ints := append(append([]string(nil), g.consts...), g.ints...)
+	gen.Shuffle(s, ints)
+	for _, e := range ints[:min(len(ints), 12)] {
+		g.line("\tsink += %s;", e)
+	}
+	for _, u := range g.uses {
+		g.line("\t%s", u)
+	}
+	g.line("#ifdef __cplusplus")
+	for _, u := range g.cppUses {
+		g.line("\t%s", u)
+	}
+	g.line("#else")
+	for _, u := range g.cUses {
+		g.line("\t%s", u)
+	}
+	g.line("#endif")
+	g.line("\treturn sink & 0;")
+	g.line("%s", g.rbrace())
+}
@@ -0,0 +1,159 @@
+// Package csvsrc generates CSV seeds. It registers the "csv/..."
+// generator with package gen.
+//
+// A seed is one comma-separated document, input.csv, of records ended by
+// LF, CRLF or a mix of the two, with or without a last line ending and
+// now and then a byte order mark or blank lines. Most records have the
+// same number of fields; some documents are ragged. Fields are empty,
+// plain or quoted, and quoted ones hold commas, doubled quotes, LFs, CRLFs
+// and lone CRs. A few fields are forms only LazyQuotes reads, bare quotes
+// and text after a closing quote, or a quote left open to the end; and a
+// few documents hold one field of megabytes, plain or quoted, to make
+// reading cost show.
+package csvsrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "csv/records",
+		Doc:  "CSV documents: LF, CRLF and mixed line endings, byte order marks, blank lines, ragged records, empty, plain and quoted fields holding commas, doubled quotes, LFs, CRLFs and lone CRs, bare quotes and text after closing quotes that only LazyQuotes reads, unterminated quotes, and now and then a field of megabytes",
+		Func: records,
+	})
+}
+
+// lazyRate is the chance that a field is written in a form only
+// LazyQuotes reads. A document has a few dozen fields, so about a third
+// of them get one.
+const lazyRate = 0.01
+
+// bigRate is the chance that a document holds a field of megabytes.
+const bigRate = 0.02
+
+// words are the text of fields and of the pieces of quoted ones.
+var words = []string{
+	"a", "b", "name", "value", "id", "42", "-7", "3.14", "1e10", "0x1F", "true", "NULL", "N/A",
+	"hello world", " leading", "trailing ", "\ttab", "a;b", "a|b", "#comment", `\.`, "=1+2",
+	"日本語", "héllo", "\u00a0", "\u3000", "😀", "\x00", "\xff\xfe", "\ufeff",
+}
+
+// A cgen writes the document of one seed.
+type cgen struct {
+	s   *gen.State
+	b   strings.Builder
+	nl  string
+	big bool // a field of megabytes is still to be written
+}
+
+func records(s *gen.State) []gen.File {
+	d := &cgen{s: s, nl: gen.Pick(s, "\n", "\n", "\r\n"), big: s.Chance(bigRate)}
+	if s.Chance(0.05) {
+		d.b.WriteString("\ufeff")
+	}
+	n := gen.Pick(s, 0, 1, 2, s.Range(1, 8), s.Range(1, 20), s.Range(20, 200))
+	width := s.Range(1, 8)
+	ragged := s.Chance(0.2)
+	bigAt := s.Intn(max(n, 1))
+	for i := range n {
+		if s.Chance(0.03) {
+			d.b.WriteString(gen.Pick(s, "\n", "\r\n"))
+		}
+		k := width
+		if ragged && s.Chance(0.4) {
+			k = s.Range(1, width+3)
+		}
+		for j := range k {
+			if j > 0 {
+				d.b.WriteByte(',')
+			}
+			if d.big && i == bigAt && j == k-1 {
+				d.huge()
+				d.big = false
+				continue
+			}
+			d.field()
+		}
+		if i < n-1 || s.Chance(0.8) {
+			d.end()
+		}
+	}
+	return []gen.File{{Name: "input.csv", Data: []byte(d.b.String())}}
+}
+
+// end writes a line ending, now and then not the document's own.
+func (d *cgen) end() {
+	if d.s.Chance(0.05) {
+		d.b.WriteString(gen.Pick(d.s, "\n", "\r\n", "\r\r\n", "\r"))
+		return
+	}
+	d.b.WriteString(d.nl)
+}
+
+// field writes one field.
+func (d *cgen) field() {
+	s := d.s
+	if s.Chance(lazyRate) {
+		d.b.WriteString(gen.Pick(s,
+			`a"b`, `a""`, `"a"b`, `"a" `, ` "a"`, `"a""`, `""a`, `"`, `"a"""b"`,
+			"\"unterminated", "\"open"+d.nl+"line",
+		))
+		return
+	}
+	switch s.Intn(10) {
+	case 0:
+	case 1, 2, 3, 4:
+		d.b.WriteString(d.plain())
+	default:
+		d.quoted()
+	}
+}
+
+// plain returns the text of a field that needs no quotes.
+func (d *cgen) plain() string {
+	s := d.s
+	w := gen.Pick(s, words...)
+	if s.Chance(0.2) {
+		w += gen.Pick(s, words...)
+	}
+	return w
+}
+
+// quoted writes a quoted field.
+func (d *cgen) quoted() {
+	s := d.s
+	d.b.WriteByte('"')
+	for range gen.Pick(s, 0, 1, 1, 2, s.Range(1, 6)) {
+		switch s.Intn(8) {
+		case 0:
+			d.b.WriteString(`""`)
+		case 1:
+			d.b.WriteString(",")
+		case 2:
+			d.b.WriteString(gen.Pick(s, "\n", "\r\n", "\r", "\n\n", "\r\n\r\n"))
+		default:
+			d.b.WriteString(gen.Pick(s, words...))
+		}
+	}
+	d.b.WriteByte('"')
+}
+
+// huge writes a field of megabytes: plain text, or a quoted field of
+// long lines, of many short lines or of many doubled quotes.
+func (d *cgen) huge() {
+	s := d.s
+	n := s.Range(1<<20, 3<<20)
+	switch s.Intn(4) {
+	case 0:
+		d.b.WriteString(strings.Repeat(gen.Pick(s, "a", "0123456789", "日本"), n/4))
+	case 1:
+		d.b.WriteString(`"` + strings.Repeat("x", n) + `"`)
+	case 2:
+		d.b.WriteString(`"` + strings.Repeat("ab"+gen.Pick(s, "\n", "\r\n"), n/4) + `"`)
+	default:
+		d.b.WriteString(`"` + strings.Repeat(`""`, n/2) + `"`)
+	}
+}
@@ -0,0 +1,217 @@
+// Package debugsrc generates object file seeds. It registers the
+// "debug/..." generators with package gen.
+//
+// "debug/elf" writes an ELF file, input.elf: an executable, shared
+// object, relocatable object or core file, 32- or 64-bit and in either
+// byte order, for one of a dozen machines. It has text, data and bss
+// sections, a symbol table of up to a few thousand symbols, and, for a
+// file a dynamic linker loads, an interpreter, dynamic symbols, symbol
+// versions, version needs and definitions and a dynamic section naming
+// its libraries, with program headers to load them; a relocatable
+// object may carry relocations for its DWARF, and any file DWARF that
+// is compressed, in an SHF_COMPRESSED section or a .zdebug one, or not.
+// A few break the format: section headers whose data lies past the end
+// of the file or over another section's, sizes too large to read, and
+// links, names and entry sizes out of range; program headers that
+// overlap or run past the end; counts that need extended numbering or
+// are too large; symbol tables that are not a whole number of symbols
+// or whose symbols are in sections there are not; string tables cut
+// short; compressed sections of unknown types, with sizes that lie, or
+// that are bombs; and files cut short.
+//
+// "debug/pe" writes a PE file, input.exe: an executable for 386, amd64,
+// arm or arm64 behind an MS-DOS stub, with a 32- or 64-bit optional
+// header and its data directories, or a COFF object with relocations
+// and neither; its sections are named directly or through the string
+// table, an executable's import directory names DLLs and the functions
+// imported from them by name or ordinal, and a COFF symbol table has
+// auxiliary records. A few break the format: section counts and
+// optional header sizes that are wrong, sections and symbol tables past
+// the end of the file, string tables whose length lies, symbols with
+// more auxiliary records than there are, import directories at
+// addresses no section holds or that do not end, and names that lie
+// past the end of their section.
+//
+// "debug/macho" writes a Mach-O file, input.macho: an object, executable
+// or dylib, 32- or 64-bit, for amd64, arm64, 386, arm or ppc, with
+// segments and their sections, a symbol table, a dynamic symbol table
+// with indirect symbols, the dylibs it loads, run paths and a UUID, or a
+// fat file holding several of them. A few break the format: load command
+// counts and sizes that disagree, commands that run past the end or are
+// not aligned, section counts too large for their command, symbol
+// tables past the end of the file, string indexes past the end of the
+// string table, undefined symbols the dynamic symbol table puts past
+// the end of the symbol table, and fat files whose architectures
+// overlap or lie past the end.
+//
+// "debug/dwarf" writes the DWARF sections of a few compile units, each
+// to its own file: abbrev.dwarf, info.dwarf, line.dwarf, ranges.dwarf
+// and str.dwarf. A unit is DWARF 2 to 5, 32- or 64-bit DWARF, with
+// 4- or 8-byte addresses, and has base, pointer, qualified, array,
+// structure, union, enumeration and function types, functions with
+// parameters and nested scopes, and variables; its line program has
+// sequences of special, standard and extended opcodes, and a DWARF 5
+// one directory and file tables of varied entry formats. The object
+// files of the other generators carry the same sections. A few break
+// the format: abbreviations defined twice, never ended or with
+// thousands of attributes, forms that are bogus, indirect to another
+// indirect form or from a later version, references and siblings that
+// loop or point past the unit, types that refer to themselves, scopes
+// nested thousands deep, unit and header lengths that lie, and line
+// programs with bad versions, opcode lengths, file indexes and
+// extended opcode lengths, or cut short.
+package debugsrc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// badRate is the chance that one of the many fields of a file is wrong,
+// so that one file in ten or so is broken.
+const badRate = 0.004
+
+// symbols are the names symbols are given, C, C++, Go and Objective-C
+// ones among them.
+var symbols = []string{
+	"main", "_start", "printf", "malloc", "free", "memcpy", "__libc_start_main",
+	"_ZN3foo3barEv", "_ZNSt6vectorIiSaIiEE9push_backERKi", "main.main", "runtime.morestack",
+	"type:*main.T", "go:buildid", "_OBJC_CLASS_$_NSObject", "__imp_ExitProcess",
+	"_Z1fIiEvT_", "$x", "$d", ".L.str", "", "a", "very_long_symbol_name_that_goes_on_and_on_and_on_for_a_while",
+}
+
+// symbolNames returns n names for symbols, some repeated, some numbered
+// to keep them apart.
+func symbolNames(s *gen.State, n int) []string {
+	names := make([]string, n)
+	for i := range names {
+		names[i] = gen.Pick(s, symbols...)
+		if s.Chance(0.5) {
+			names[i] += "." + string(rune('0'+i%10)) + string(rune('a'+i/10%26))
+		}
+	}
+	return names
+}
+
+// strtab returns a string table holding names, each ended by a NUL after
+// the empty string at offset 0, and where each name starts; a name
+// already in the table is not written again.
+func strtab(names []string) ([]byte, []uint32) {
+	b := []byte{0}
+	offs := make([]uint32, len(names))
+	seen := map[string]uint32{"": 0}
+	for i, name := range names {
+		off, ok := seen[name]
+		if !ok {
+			off = uint32(len(b))
+			seen[name] = off
+			b = append(b, name...)
+			b = append(b, 0)
+		}
+		offs[i] = off
+	}
+	return b, offs
+}
+
+// truncate returns b cut short: without its last byte, which ends a
+// string table, or in the middle.
+func truncate(s *gen.State, b []byte) []byte {
+	if len(b) == 0 {
+		return b
+	}
+	if s.Chance(0.5) {
+		return b[:len(b)-1]
+	}
+	return b[:s.Intn(len(b))]
+}
+
+// code returns bytes for a text section: instructions that decode as
+// something on most machines, padding, or random bytes.
+func code(s *gen.State) []byte {
+	switch s.Intn(3) {
+	case 0:
+		return []byte(gen.Pick(s,
+			"\x55\x48\x89\xe5\x31\xc0\x5d\xc3",                 // amd64: push, mov, xor, pop, ret
+			"\xfd\x7b\xbf\xa9\xfd\x03\x00\x91\xc0\x03\x5f\xd6", // arm64: stp, mov, ret
+			"\x13\x00\x00\x00\x67\x80\x00\x00",                 // riscv: nop, ret
+			"\xcc\xcc\xcc\xcc"))
+	case 1:
+		return bytes.Repeat([]byte{gen.Pick[byte](s, 0x90, 0x00, 0xcc)}, s.Range(1, 64))
+	default:
+		return samples(s, s.Intn(256))
+	}
+}
+
+// samples returns n random bytes.
+func samples(s *gen.State, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	return b
+}
+
+// zlibBytes returns data compressed with zlib.
+func zlibBytes(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// A debugSection is a DWARF section: its name, as ELF gives it, and its
+// contents.
+type debugSection struct {
+	name string
+	data []byte
+}
+
+// dwarfSections returns the DWARF sections of a few compile units in
+// byte order bo, as "debug/dwarf" writes them: an abbreviation table,
+// the units, their line programs and the strings and address ranges
+// they point at.
+func dwarfSections(s *gen.State, bo binary.AppendByteOrder) []debugSection {
+	d := newDWARF(s, bo, dwarfBadRate)
+	secs := []debugSection{{".debug_abbrev", d.abbrev}, {".debug_info", d.info}, {".debug_line", d.line}}
+	if len(d.ranges) > 0 {
+		secs = append(secs, debugSection{".debug_ranges", d.ranges})
+	}
+	if len(d.str) > 0 {
+		secs = append(secs, debugSection{".debug_str", d.str})
+	}
+	return secs
+}
+
+// A writer writes the fields of a file of one class, 32- or 64-bit, and
+// one byte order.
+type writer struct {
+	bo   binary.AppendByteOrder
+	is64 bool
+}
+
+func (w writer) u16(b []byte, v uint16) []byte { return w.bo.AppendUint16(b, v) }
+func (w writer) u32(b []byte, v uint32) []byte { return w.bo.AppendUint32(b, v) }
+
+// put32 writes v over the first four bytes of b.
+func (w writer) put32(b []byte, v uint32) { w.bo.AppendUint32(b[:0], v) }
+
+// word appends an address, offset or size: 4 bytes in a 32-bit file, 8
+// in a 64-bit one.
+func (w writer) word(b []byte, v uint64) []byte {
+	if w.is64 {
+		return w.bo.AppendUint64(b, v)
+	}
+	return w.bo.AppendUint32(b, uint32(v))
+}
+
+// wordSize is the size of an address in bytes.
+func wordSize(w writer) int {
+	if w.is64 {
+		return 8
+	}
+	return 4
+}
@@ -0,0 +1,1216 @@
+package debugsrc
+
+import (
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "debug/dwarf",
+		Doc:  "DWARF .debug_abbrev, .debug_info, .debug_line, .debug_ranges and .debug_str sections, versions 2 to 5, 32- and 64-bit DWARF in both byte orders: compile units with types, functions and nested scopes and their line programs, with abbreviations redefined or never ended, bogus and indirect forms, references and siblings that loop, trees nested thousands deep and malformed line program headers and opcodes",
+		Func: dwarfSeed,
+	})
+}
+
+// The DWARF constants the generator uses.
+const (
+	tagArrayType             = 0x01
+	tagEnumerationType       = 0x04
+	tagFormalParameter       = 0x05
+	tagLexicalBlock          = 0x0b
+	tagMember                = 0x0d
+	tagPointerType           = 0x0f
+	tagCompileUnit           = 0x11
+	tagStructType            = 0x13
+	tagSubroutineType        = 0x15
+	tagTypedef               = 0x16
+	tagUnionType             = 0x17
+	tagUnspecifiedParameters = 0x18
+	tagSubrangeType          = 0x21
+	tagBaseType              = 0x24
+	tagConstType             = 0x26
+	tagEnumerator            = 0x28
+	tagSubprogram            = 0x2e
+	tagVariable              = 0x34
+	tagVolatileType          = 0x35
+	tagRestrictType          = 0x37
+
+	atSibling       = 0x01
+	atLocation      = 0x02
+	atName          = 0x03
+	atByteSize      = 0x0b
+	atStmtList      = 0x10
+	atLowPC         = 0x11
+	atHighPC        = 0x12
+	atLanguage      = 0x13
+	atCompDir       = 0x1b
+	atConstValue    = 0x1c
+	atProducer      = 0x25
+	atPrototyped    = 0x27
+	atUpperBound    = 0x2f
+	atCount         = 0x37
+	atDataMemberLoc = 0x38
+	atDeclFile      = 0x3a
+	atDeclLine      = 0x3b
+	atEncoding      = 0x3e
+	atExternal      = 0x3f
+	atType          = 0x49
+	atRanges        = 0x55
+
+	formAddr          = 0x01
+	formBlock2        = 0x03
+	formBlock4        = 0x04
+	formData2         = 0x05
+	formData4         = 0x06
+	formData8         = 0x07
+	formString        = 0x08
+	formBlock         = 0x09
+	formBlock1        = 0x0a
+	formData1         = 0x0b
+	formFlag          = 0x0c
+	formSdata         = 0x0d
+	formStrp          = 0x0e
+	formUdata         = 0x0f
+	formRefAddr       = 0x10
+	formRef1          = 0x11
+	formRef2          = 0x12
+	formRef4          = 0x13
+	formRef8          = 0x14
+	formRefUdata      = 0x15
+	formIndirect      = 0x16
+	formSecOffset     = 0x17
+	formExprloc       = 0x18
+	formFlagPresent   = 0x19
+	formStrx          = 0x1a
+	formData16        = 0x1e
+	formLineStrp      = 0x1f
+	formImplicitConst = 0x21
+	formStrx1         = 0x25
+	formAddrx1        = 0x29
+
+	lnsCopy             = 1
+	lnsAdvancePC        = 2
+	lnsAdvanceLine      = 3
+	lnsSetFile          = 4
+	lnsSetColumn        = 5
+	lnsNegateStmt       = 6
+	lnsSetBasicBlock    = 7
+	lnsConstAddPC       = 8
+	lnsFixedAdvancePC   = 9
+	lnsSetPrologueEnd   = 10
+	lnsSetEpilogueBegin = 11
+	lnsSetISA           = 12
+
+	lneEndSequence      = 1
+	lneSetAddress       = 2
+	lneDefineFile       = 3
+	lneSetDiscriminator = 4
+
+	lnctPath           = 1
+	lnctDirectoryIndex = 2
+	lnctSize           = 4
+	lnctMD5            = 5
+)
+
+// dwarfBadRate is the chance that one of the fields of a DWARF seed is
+// wrong, so that one seed in two or so is broken.
+const dwarfBadRate = 0.002
+
+// dwarfSeed writes the sections of a seed, each to its own file, as
+// dwarf.New takes them.
+func dwarfSeed(s *gen.State) []gen.File {
+	var bo binary.AppendByteOrder = binary.LittleEndian
+	if s.Chance(0.25) {
+		bo = binary.BigEndian
+	}
+	d := newDWARF(s, bo, dwarfBadRate)
+	return []gen.File{
+		{Name: "abbrev.dwarf", Data: d.abbrev},
+		{Name: "info.dwarf", Data: d.info},
+		{Name: "line.dwarf", Data: d.line},
+		{Name: "ranges.dwarf", Data: d.ranges},
+		{Name: "str.dwarf", Data: d.str},
+	}
+}
+
+// A die is a debugging information entry: its tag, attributes and
+// children, and whether its abbreviation says it has children, which
+// it may say of one that has none.
+type die struct {
+	tag      uint64
+	attrs    []dieAttr
+	kids     []*die
+	children bool
+	off      int // where it is written in .debug_info
+}
+
+// A dieAttr is an attribute of an entry, the form it is written in and
+// its value: a uint64, an int64, a string, a []byte or the *die it
+// refers to. An attribute written through DW_FORM_indirect has the
+// forms the entry gives for it in via, the last of which is usually
+// form.
+type dieAttr struct {
+	at, form uint64
+	val      any
+	via      []uint64
+}
+
+func (e *die) attr(at, form uint64, val any) {
+	e.attrs = append(e.attrs, dieAttr{at: at, form: form, val: val})
+}
+
+func (e *die) add(kid *die) *die {
+	e.kids = append(e.kids, kid)
+	e.children = true
+	return kid
+}
+
+// A dieRef is a reference to an entry, written at in .debug_info in
+// form, to be filled in once the entry it refers to has been written.
+type dieRef struct {
+	at   int
+	form uint64
+	to   *die
+}
+
+// A dwarfGen writes the DWARF sections of a seed: a few compile units
+// and their abbreviation tables, the line programs they point at, and
+// the strings and address ranges their entries do. Each of its fields
+// is broken with chance bad.
+type dwarfGen struct {
+	s   *gen.State
+	bo  binary.AppendByteOrder
+	bad float64
+
+	abbrev, info, line, ranges, str []byte
+	strs                            map[string]uint64
+
+	// The unit being written: its version, whether it is 64-bit DWARF,
+	// the size of an address, the offset of its header, its
+	// abbreviation table and the codes in it by the abbreviation they
+	// stand for, and the references among its entries.
+	version int
+	is64    bool
+	asize   int
+	base    int
+	table   []byte
+	codes   map[string]uint64
+	refs    []dieRef
+}
+
+// newDWARF writes the sections of one to four compile units in byte
+// order bo, breaking fields with chance bad.
+func newDWARF(s *gen.State, bo binary.AppendByteOrder, bad float64) *dwarfGen {
+	d := &dwarfGen{s: s, bo: bo, bad: bad, strs: map[string]uint64{}}
+	for range gen.Pick(s, 1, 1, 1, 2, 4) {
+		d.unit()
+	}
+	if d.broken() {
+		// Trailing bytes that are not a unit: padding, or the start of
+		// one cut short.
+		d.info = append(d.info, samples(s, s.Range(1, 12))...)
+	}
+	return d
+}
+
+func (d *dwarfGen) broken() bool { return d.s.Chance(d.bad) }
+
+// offset appends a section offset: 4 bytes in 32-bit DWARF, 8 in 64-bit.
+func (d *dwarfGen) offset(b []byte, v uint64) []byte {
+	if d.is64 {
+		return d.bo.AppendUint64(b, v)
+	}
+	return d.bo.AppendUint32(b, uint32(v))
+}
+
+// addr appends an address of the unit's size, which may be a size
+// there are no addresses of.
+func (d *dwarfGen) addr(b []byte, v uint64) []byte {
+	return appendN(d.bo, b, v, d.asize)
+}
+
+// appendN appends the low n bytes of v, up to 8, in byte order bo.
+func appendN(bo binary.AppendByteOrder, b []byte, v uint64, n int) []byte {
+	w, n := bo.AppendUint64(nil, v), min(n, 8)
+	if bo == binary.AppendByteOrder(binary.BigEndian) {
+		return append(b, w[8-n:]...)
+	}
+	return append(b, w[:n]...)
+}
+
+// unitLength appends the length of a unit or line program of n bytes,
+// with the escape before it in 64-bit DWARF.
+func (d *dwarfGen) unitLength(b []byte, n int) []byte {
+	if d.broken() {
+		// A length that runs past the end or stops short, one of the
+		// reserved ones, or 0, which some readers take as padding.
+		n = gen.Pick(d.s, n+d.s.Range(1, 64), n/2, 0, 0xfffffff0, 0xfffffffe, 1<<31)
+	}
+	if d.is64 {
+		return d.bo.AppendUint64(d.bo.AppendUint32(b, 0xffffffff), uint64(n))
+	}
+	return d.bo.AppendUint32(b, uint32(n))
+}
+
+// strp returns the offset of str in .debug_str, adding it if it is not
+// there yet.
+func (d *dwarfGen) strp(str string) uint64 {
+	if d.broken() {
+		// Past the end, or into the middle of another string.
+		return gen.Pick(d.s, uint64(len(d.str))+uint64(d.s.Range(0, 100)), uint64(d.s.Intn(len(d.str)+1)), 1<<31)
+	}
+	off, ok := d.strs[str]
+	if !ok {
+		off = uint64(len(d.str))
+		d.strs[str] = off
+		d.str = append(d.str, str...)
+		d.str = append(d.str, 0)
+	}
+	return off
+}
+
+// strForm is the form a name is written in: inline, or in .debug_str.
+func (d *dwarfGen) strForm() uint64 {
+	return gen.Pick[uint64](d.s, formString, formString, formStrp)
+}
+
+// offsetForm is the form of an offset into another section.
+func (d *dwarfGen) offsetForm() uint64 {
+	switch {
+	case d.version >= 4:
+		return formSecOffset
+	case d.is64:
+		return formData8
+	}
+	return formData4
+}
+
+// unit writes a compile unit to .debug_info, its abbreviation table to
+// .debug_abbrev and its line program to .debug_line.
+func (d *dwarfGen) unit() {
+	d.version = gen.Pick(d.s, 2, 3, 4, 4, 5, 5)
+	d.is64 = d.s.Chance(0.1)
+	d.asize = gen.Pick(d.s, 8, 8, 4)
+	d.table, d.codes, d.refs = nil, map[string]uint64{}, nil
+	cu := d.compileUnit()
+
+	abbrevOff := uint64(len(d.abbrev))
+	if d.broken() {
+		// A table that starts elsewhere: in the middle of this one or
+		// the one before, or past the end.
+		abbrevOff = gen.Pick(d.s, 0, abbrevOff+uint64(d.s.Range(1, 8)), abbrevOff+1<<20, 1<<32-1)
+	}
+	version := uint16(d.version)
+	if d.broken() {
+		version = gen.Pick[uint16](d.s, 0, 1, 6, 0xffff, 0x0200)
+	}
+	asize := byte(d.asize)
+	if d.broken() {
+		asize = gen.Pick[byte](d.s, 0, 1, 2, 3, 16, 255)
+	}
+	var h []byte
+	h = d.bo.AppendUint16(h, version)
+	if d.version >= 5 {
+		utype := byte(1) // DW_UT_compile
+		if d.broken() {
+			// A unit of another type, with the fields that type has in
+			// its header or without them, or of no type at all.
+			utype = gen.Pick[byte](d.s, 0, 2, 4, 5, 6, 0x80)
+		}
+		h = append(h, utype, asize)
+		h = d.offset(h, abbrevOff)
+		switch utype {
+		case 2, 6: // type units: a signature and the offset of the type
+			h = d.bo.AppendUint64(h, d.s.Uint64())
+			h = d.offset(h, uint64(d.s.Intn(64)))
+		case 4, 5: // skeleton and split units: an ID
+			h = d.bo.AppendUint64(h, d.s.Uint64())
+		}
+	} else {
+		h = d.offset(h, abbrevOff)
+		h = append(h, asize)
+	}
+
+	// The length goes in once the entries are written, over 4 bytes,
+	// or 12 in 64-bit DWARF.
+	d.base = len(d.info)
+	d.info = append(d.info, make([]byte, 4)...)
+	if d.is64 {
+		d.info = append(d.info, make([]byte, 8)...)
+	}
+	start := len(d.info)
+	d.info = append(d.info, h...)
+	d.siblings(cu)
+	d.entry(cu)
+	copy(d.info[d.base:], d.unitLength(nil, len(d.info)-start))
+	d.patch()
+
+	if !d.broken() {
+		d.table = append(d.table, 0)
+	}
+	d.abbrev = append(d.abbrev, d.table...)
+}
+
+// patch fills in the references among the entries of the unit.
+func (d *dwarfGen) patch() {
+	for _, r := range d.refs {
+		v := uint64(r.to.off - d.base)
+		if r.form == formRefAddr {
+			v = uint64(r.to.off)
+		}
+		if d.broken() {
+			// A reference past the end of the unit or the section, into
+			// the middle of an entry, or to the unit's header.
+			v = gen.Pick(d.s, v+1, v-1, 0, uint64(len(d.info)+d.s.Range(0, 100)), 1<<31, 1<<64-1)
+		}
+		switch r.form {
+		case formRef1:
+			d.info[r.at] = byte(v)
+		case formRef2:
+			d.bo.AppendUint16(d.info[r.at:r.at], uint16(v))
+		case formRef4:
+			d.bo.AppendUint32(d.info[r.at:r.at], uint32(v))
+		case formRef8:
+			d.bo.AppendUint64(d.info[r.at:r.at], v)
+		case formRefUdata:
+			paddedUvarint(d.info[r.at:r.at], v)
+		case formRefAddr:
+			switch {
+			case d.version == 2:
+				appendN(d.bo, d.info[r.at:r.at], v, d.asize)
+			default:
+				d.offset(d.info[r.at:r.at], v)
+			}
+		}
+	}
+}
+
+// paddedUvarint appends v as a ULEB128 number five bytes long, padded
+// with continuation bytes as a linker leaves room to fill one in; it
+// holds values up to 35 bits.
+func paddedUvarint(b []byte, v uint64) []byte {
+	for range 4 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v)&0x7f)
+}
+
+// appendVarint appends v as an SLEB128 number.
+func appendVarint(b []byte, v int64) []byte {
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+// abbreviation returns the code of the abbreviation for e, adding it to
+// the unit's table if the table does not have it yet.
+func (d *dwarfGen) abbreviation(e *die) uint64 {
+	for i := range e.attrs {
+		if d.broken() {
+			d.bogusForm(&e.attrs[i])
+		}
+	}
+	children := byte(0)
+	if e.children {
+		children = 1
+	}
+	if d.broken() {
+		// Any byte but 1 means no children, to a reader that checks.
+		children = gen.Pick[byte](d.s, 2, 0x80, 0xff)
+	}
+	var a []byte
+	a = binary.AppendUvarint(a, e.tag)
+	a = append(a, children)
+	for _, at := range e.attrs {
+		form := at.form
+		if at.via != nil {
+			form = formIndirect
+		}
+		a = binary.AppendUvarint(a, at.at)
+		a = binary.AppendUvarint(a, form)
+		if form == formImplicitConst {
+			a = appendVarint(a, number(at.val))
+		}
+	}
+	if code, ok := d.codes[string(a)]; ok {
+		return code
+	}
+	code := uint64(len(d.codes) + 1)
+	if len(d.codes) > 0 && d.broken() {
+		// A code the table already has, for something else: the reader
+		// keeps one of them.
+		code = uint64(d.s.Range(1, len(d.codes)))
+	}
+	d.codes[string(a)] = code
+	if d.broken() {
+		// A code so large it takes more bytes than a uint64 holds.
+		d.table = append(d.table, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01)
+	} else {
+		d.table = binary.AppendUvarint(d.table, code)
+	}
+	d.table = append(d.table, a...)
+	if d.broken() {
+		// An abbreviation with more attributes than its entries have,
+		// thousands of them, which take no room in an entry.
+		for range d.s.Range(10, 2000) {
+			d.table = binary.AppendUvarint(d.table, uint64(d.s.Range(1, 0x8c)))
+			d.table = append(d.table, formFlagPresent)
+		}
+	}
+	if !d.broken() {
+		// The end of the list, without which it runs on into the next
+		// abbreviation.
+		d.table = append(d.table, 0, 0)
+	}
+	return code
+}
+
+// bogusForm breaks the form of a: a code no form has, DW_FORM_indirect
+// that names another indirect form or a bogus one, a form of a later
+// version, or one of the wrong class for the attribute.
+func (d *dwarfGen) bogusForm(a *dieAttr) {
+	switch d.s.Intn(5) {
+	case 0:
+		a.form = gen.Pick[uint64](d.s, 0, 0x2d, 0x40, 0x7f, 0x1f01, 0x1f20, 1<<40)
+	case 1:
+		a.via = []uint64{a.form}
+	case 2:
+		a.via = gen.Pick(d.s, []uint64{formIndirect, a.form}, []uint64{formIndirect, formIndirect, formIndirect, a.form}, []uint64{0x7f}, []uint64{0})
+	case 3:
+		a.form = gen.Pick[uint64](d.s, formStrx, formStrx1, formAddrx1, formData16, formLineStrp, formImplicitConst)
+	default:
+		a.form = gen.Pick[uint64](d.s, formString, formData4, formSdata, formBlock1, formFlagPresent, formRef4, formExprloc)
+	}
+}
+
+// entry writes e and its children to .debug_info.
+func (d *dwarfGen) entry(e *die) {
+	e.off = len(d.info)
+	code := d.abbreviation(e)
+	if d.broken() {
+		// A code the table does not have, or 0, which ends the parent's
+		// children early.
+		code = gen.Pick(d.s, code+1000, 0, 1<<63)
+	}
+	d.info = binary.AppendUvarint(d.info, code)
+	for _, a := range e.attrs {
+		d.value(a)
+	}
+	for _, kid := range e.kids {
+		d.entry(kid)
+	}
+	if e.children && !d.broken() {
+		d.info = append(d.info, 0)
+	}
+}
+
+// number returns v as an integer, if it is one, or a number it stands
+// for.
+func number(v any) int64 {
+	switch v := v.(type) {
+	case uint64:
+		return int64(v)
+	case int64:
+		return v
+	case string:
+		return int64(len(v))
+	case []byte:
+		return int64(len(v))
+	}
+	return 0
+}
+
+// value writes the value of a to .debug_info in its form.
+func (d *dwarfGen) value(a dieAttr) {
+	b := d.info
+	for _, f := range a.via {
+		b = binary.AppendUvarint(b, f)
+	}
+	if to, ok := a.val.(*die); ok {
+		switch a.form {
+		case formRef1, formRef2, formRef4, formRef8, formRefUdata, formRefAddr:
+			d.refs = append(d.refs, dieRef{at: len(b), form: a.form, to: to})
+		}
+	}
+	n := number(a.val)
+	switch a.form {
+	case formAddr:
+		b = d.addr(b, uint64(n))
+	case formData1, formRef1, formFlag, formStrx1, formAddrx1:
+		b = append(b, byte(n))
+	case formData2, formRef2:
+		b = d.bo.AppendUint16(b, uint16(n))
+	case formData4, formRef4:
+		b = d.bo.AppendUint32(b, uint32(n))
+	case formData8, formRef8:
+		b = d.bo.AppendUint64(b, uint64(n))
+	case formData16:
+		b = append(b, make([]byte, 16)...)
+	case formSdata:
+		b = appendVarint(b, n)
+	case formUdata, formStrx:
+		b = binary.AppendUvarint(b, uint64(n))
+	case formRefUdata:
+		b = paddedUvarint(b, uint64(n))
+	case formString:
+		str, _ := a.val.(string)
+		b = append(b, str...)
+		b = append(b, 0)
+	case formStrp, formLineStrp:
+		str, ok := a.val.(string)
+		if !ok {
+			b = d.offset(b, uint64(n))
+			break
+		}
+		b = d.offset(b, d.strp(str))
+	case formSecOffset:
+		b = d.offset(b, uint64(n))
+	case formRefAddr:
+		if d.version == 2 {
+			b = d.addr(b, uint64(n))
+		} else {
+			b = d.offset(b, uint64(n))
+		}
+	case formBlock1, formBlock2, formBlock4, formBlock, formExprloc:
+		block, ok := a.val.([]byte)
+		if !ok {
+			block = []byte{0x9c} // DW_OP_call_frame_cfa
+		}
+		switch a.form {
+		case formBlock1:
+			b = append(b, byte(len(block)))
+		case formBlock2:
+			b = d.bo.AppendUint16(b, uint16(len(block)))
+		case formBlock4:
+			b = d.bo.AppendUint32(b, uint32(len(block)))
+		default:
+			b = binary.AppendUvarint(b, uint64(len(block)))
+		}
+		b = append(b, block...)
+	case formFlagPresent, formImplicitConst:
+	default:
+		// A form there is no such thing as: whatever follows.
+		b = append(b, samples(d.s, d.s.Intn(4))...)
+	}
+	d.info = b
+}
+
+// siblings gives entries that have children a DW_AT_sibling attribute,
+// now and then, pointing at the entry after them, as a producer does to
+// let readers skip their children.
+func (d *dwarfGen) siblings(e *die) {
+	for i, kid := range e.kids {
+		if len(kid.kids) > 0 && i+1 < len(e.kids) && d.s.Chance(0.2) {
+			to := e.kids[i+1]
+			if d.broken() {
+				// A sibling that loops back: the entry itself, its
+				// parent, or the first of its siblings.
+				to = gen.Pick(d.s, kid, e, e.kids[0])
+			}
+			kid.attrs = append([]dieAttr{{at: atSibling, form: formRef4, val: to}}, kid.attrs...)
+		}
+		d.siblings(kid)
+	}
+}
+
+// ref returns an attribute referring to e, usually in DW_FORM_ref4.
+func (d *dwarfGen) ref(at uint64, e *die) dieAttr {
+	form := uint64(formRef4)
+	if d.s.Chance(0.2) {
+		form = gen.Pick[uint64](d.s, formRefAddr, formRef8, formRefUdata, formRef2)
+	}
+	return dieAttr{at: at, form: form, val: e}
+}
+
+// compileUnit returns the entries of a compile unit: the unit, with
+// the line program it points at, and its types, functions and
+// variables.
+func (d *dwarfGen) compileUnit() *die {
+	cu := &die{tag: tagCompileUnit, children: true}
+	files := []string{gen.Pick(d.s, "main.c", "a.go", "lib.rs", "x.cc", "/abs/path/y.c", "")}
+	for range d.s.Intn(4) {
+		files = append(files, gen.Pick(d.s, "stdio.h", "../include/z.h", "runtime/proc.go", "", "sub/dir/w.h"))
+	}
+	cu.attr(atProducer, d.strForm(), gen.Pick(d.s, "GNU C17 13.2.0 -O2 -g", "clang version 18.1.0", "Go cmd/compile go1.24", "rustc version 1.80.0", ""))
+	cu.attr(atLanguage, gen.Pick[uint64](d.s, formData1, formData1, formData2), gen.Pick[uint64](d.s, 0x0c, 0x1d, 0x16, 0x21, 0x1c, 0x8001))
+	cu.attr(atName, d.strForm(), files[0])
+	cu.attr(atCompDir, d.strForm(), gen.Pick(d.s, "/tmp", "/home/user/src", "", "C:\\src", "relative/dir"))
+	low := gen.Pick[uint64](d.s, 0x401000, 0x1000, 0, 0xffffffff00000000)
+	size := uint64(d.s.Range(0x10, 0x10000))
+	if d.version < 5 && d.s.Chance(0.3) {
+		cu.attr(atLowPC, formAddr, uint64(0))
+		cu.attr(atRanges, d.offsetForm(), d.rangeList(low, size))
+	} else {
+		cu.attr(atLowPC, formAddr, low)
+		if d.version >= 4 && d.s.Chance(0.7) {
+			cu.attr(atHighPC, formData4, size)
+		} else {
+			cu.attr(atHighPC, formAddr, low+size)
+		}
+	}
+	off := d.lineProgram(low, files)
+	if d.broken() {
+		off = gen.Pick(d.s, off+1, off+uint64(d.s.Range(2, 40)), uint64(len(d.line))+1000, 1<<32-1)
+	}
+	cu.attr(atStmtList, d.offsetForm(), off)
+
+	types := d.types(cu)
+	for range d.s.Intn(5) {
+		d.function(cu, types, low, size, len(files))
+	}
+	for range d.s.Intn(3) {
+		v := cu.add(&die{tag: tagVariable})
+		v.attr(atName, d.strForm(), gen.Pick(d.s, "counter", "main.x", "g_table", ""))
+		v.attrs = append(v.attrs, d.ref(atType, gen.Pick(d.s, types...)))
+		v.attr(atExternal, formFlag, uint64(1))
+		v.attr(atLocation, d.exprForm(), d.addrExpr(low+size))
+	}
+	if d.s.Chance(0.05) {
+		// Scopes nested thousands deep, which a reader that recurses
+		// follows as deep.
+		e := cu
+		for range d.s.Range(100, 5000) {
+			e = e.add(&die{tag: tagLexicalBlock})
+		}
+		e.add(&die{tag: tagVariable}).attrs = []dieAttr{{at: atName, form: formString, val: "deep"}}
+	}
+	return cu
+}
+
+// exprForm is the form of a location expression.
+func (d *dwarfGen) exprForm() uint64 {
+	if d.version >= 4 {
+		return formExprloc
+	}
+	return formBlock1
+}
+
+// addrExpr returns a location expression giving an address: DW_OP_addr
+// and the address.
+func (d *dwarfGen) addrExpr(a uint64) []byte {
+	return d.addr([]byte{0x03}, a)
+}
+
+// rangeList appends an address range list for code of size bytes from
+// low to .debug_ranges and returns its offset.
+func (d *dwarfGen) rangeList(low, size uint64) uint64 {
+	off := uint64(len(d.ranges))
+	if d.s.Chance(0.3) {
+		// A base address selection entry: the largest address, then
+		// the base.
+		d.ranges = d.addr(d.ranges, 1<<64-1)
+		d.ranges = d.addr(d.ranges, low)
+		low = 0
+	}
+	for range d.s.Range(1, 6) {
+		n := uint64(d.s.Range(1, int(size)))
+		if d.broken() {
+			// A range that ends before it starts.
+			d.ranges = d.addr(d.addr(d.ranges, low+n), low)
+		} else {
+			d.ranges = d.addr(d.addr(d.ranges, low), low+n)
+		}
+		low += n + uint64(d.s.Intn(16))
+	}
+	if !d.broken() {
+		d.ranges = d.addr(d.addr(d.ranges, 0), 0)
+	}
+	if d.broken() {
+		off = gen.Pick(d.s, off+1, uint64(len(d.ranges)), uint64(len(d.ranges))+64)
+	}
+	return off
+}
+
+// baseTypes are the base types a unit has: name, DW_ATE_ encoding and
+// size.
+var baseTypes = []struct {
+	name     string
+	encoding uint64
+	size     uint64
+}{
+	{"int", 0x05, 4}, {"char", 0x06, 1}, {"unsigned long", 0x07, 8}, {"double", 0x04, 8},
+	{"_Bool", 0x02, 1}, {"float complex", 0x03, 8}, {"uint8", 0x08, 1}, {"", 0x05, 0}, {"__int128", 0x05, 16},
+}
+
+// types adds the types of a unit to cu: base types, and pointers,
+// qualifiers, typedefs, arrays, structures, unions, enumerations and
+// function types built on them and on each other, a structure now and
+// then pointing at itself. It returns them.
+func (d *dwarfGen) types(cu *die) []*die {
+	var types []*die
+	add := func(e *die) *die {
+		cu.add(e)
+		types = append(types, e)
+		return e
+	}
+	for range d.s.Range(1, 4) {
+		bt := gen.Pick(d.s, baseTypes...)
+		e := add(&die{tag: tagBaseType})
+		e.attr(atName, d.strForm(), bt.name)
+		e.attr(atEncoding, formData1, bt.encoding)
+		e.attr(atByteSize, formData1, bt.size)
+	}
+	for range d.s.Intn(12) {
+		to := gen.Pick(d.s, types...)
+		switch d.s.Intn(8) {
+		case 0:
+			e := add(&die{tag: tagPointerType})
+			e.attr(atByteSize, formData1, uint64(d.asize))
+			if d.s.Chance(0.8) {
+				e.attrs = append(e.attrs, d.ref(atType, to))
+			}
+		case 1:
+			e := add(&die{tag: gen.Pick[uint64](d.s, tagConstType, tagVolatileType, tagRestrictType)})
+			e.attrs = append(e.attrs, d.ref(atType, to))
+		case 2:
+			e := add(&die{tag: tagTypedef})
+			e.attr(atName, d.strForm(), gen.Pick(d.s, "size_t", "T", "myint", ""))
+			e.attrs = append(e.attrs, d.ref(atType, to))
+		case 3:
+			e := add(&die{tag: tagArrayType})
+			e.attrs = append(e.attrs, d.ref(atType, to))
+			for range d.s.Range(1, 3) {
+				sub := e.add(&die{tag: tagSubrangeType})
+				sub.attrs = append(sub.attrs, d.ref(atType, types[0]))
+				if d.s.Chance(0.5) {
+					sub.attr(atUpperBound, gen.Pick[uint64](d.s, formData1, formData2, formSdata, formUdata), gen.Pick[int64](d.s, 0, 3, 15, 255, -1))
+				} else {
+					sub.attr(atCount, formData1, uint64(d.s.Intn(100)))
+				}
+			}
+		case 4, 5:
+			st := &die{tag: gen.Pick[uint64](d.s, tagStructType, tagStructType, tagUnionType)}
+			if d.s.Chance(0.7) {
+				st.attr(atName, d.strForm(), gen.Pick(d.s, "node", "point", "list_head", "u"))
+			}
+			st.attr(atByteSize, formData1, uint64(d.s.Intn(64)))
+			if d.s.Chance(0.3) {
+				// A member pointing at the structure itself, as a
+				// linked list's does.
+				p := add(&die{tag: tagPointerType})
+				p.attr(atByteSize, formData1, uint64(d.asize))
+				p.attrs = append(p.attrs, d.ref(atType, st))
+				to = p
+			}
+			add(st)
+			for i := range d.s.Intn(6) {
+				m := st.add(&die{tag: tagMember})
+				m.attr(atName, d.strForm(), gen.Pick(d.s, "next", "x", "y", "data", ""))
+				m.attrs = append(m.attrs, d.ref(atType, to))
+				if d.s.Chance(0.8) {
+					m.attr(atDataMemberLoc, formData1, uint64(i*8))
+				} else {
+					m.attr(atDataMemberLoc, d.exprForm(), []byte{0x23, byte(i * 8)}) // DW_OP_plus_uconst
+				}
+				to = gen.Pick(d.s, types...)
+			}
+		case 6:
+			e := add(&die{tag: tagEnumerationType})
+			e.attr(atName, d.strForm(), gen.Pick(d.s, "color", "state", ""))
+			e.attr(atByteSize, formData1, uint64(4))
+			for i := range d.s.Range(0, 8) {
+				v := e.add(&die{tag: tagEnumerator})
+				v.attr(atName, d.strForm(), "E"+string(rune('A'+i)))
+				v.attr(atConstValue, gen.Pick[uint64](d.s, formSdata, formData1, formUdata, formData8), gen.Pick(d.s, int64(i), -1, 1<<62))
+			}
+		default:
+			e := add(&die{tag: tagSubroutineType})
+			e.attr(atPrototyped, d.flagForm(), uint64(1))
+			if d.s.Chance(0.7) {
+				e.attrs = append(e.attrs, d.ref(atType, to))
+			}
+			for range d.s.Intn(4) {
+				e.add(&die{tag: tagFormalParameter}).attrs = []dieAttr{d.ref(atType, gen.Pick(d.s, types...))}
+			}
+			if d.s.Chance(0.2) {
+				e.add(&die{tag: tagUnspecifiedParameters})
+			}
+		}
+	}
+	if d.broken() {
+		// Types that refer to themselves, or to each other, through
+		// nothing that ends the loop: a typedef of itself, qualifiers
+		// of each other, an array of itself, a pointer to itself.
+		a := add(&die{tag: gen.Pick[uint64](d.s, tagTypedef, tagConstType, tagArrayType, tagPointerType)})
+		b := a
+		if d.s.Chance(0.5) {
+			b = add(&die{tag: gen.Pick[uint64](d.s, tagTypedef, tagVolatileType, tagPointerType)})
+		}
+		a.attrs = append(a.attrs, d.ref(atType, b))
+		if b != a {
+			b.attrs = append(b.attrs, d.ref(atType, a))
+		}
+	}
+	return types
+}
+
+// flagForm is the form of a flag that is set.
+func (d *dwarfGen) flagForm() uint64 {
+	if d.version >= 4 && d.s.Chance(0.7) {
+		return formFlagPresent
+	}
+	return formFlag
+}
+
+// function adds a function in code of size bytes from low to cu, with
+// its parameters and variables, and scopes nested in it.
+func (d *dwarfGen) function(cu *die, types []*die, low, size uint64, files int) {
+	f := cu.add(&die{tag: tagSubprogram})
+	f.attr(atExternal, d.flagForm(), uint64(1))
+	f.attr(atName, d.strForm(), gen.Pick(d.s, "main", "f", "main.main", "_ZN3foo3barEv", ""))
+	declForm := uint64(formData1)
+	if d.version >= 5 && d.s.Chance(0.5) {
+		declForm = formImplicitConst
+	}
+	f.attr(atDeclFile, declForm, uint64(d.s.Intn(files+1)))
+	f.attr(atDeclLine, gen.Pick[uint64](d.s, formData1, formData2, declForm), uint64(d.s.Range(1, 300)))
+	if d.s.Chance(0.7) {
+		f.attrs = append(f.attrs, d.ref(atType, gen.Pick(d.s, types...)))
+	}
+	f.attr(atLowPC, formAddr, low)
+	f.attr(atHighPC, formAddr, low+size/2)
+	d.scope(f, types, 0)
+}
+
+// scope adds the parameters, variables and nested scopes of a function
+// or scope to e.
+func (d *dwarfGen) scope(e *die, types []*die, depth int) {
+	for range d.s.Intn(4) {
+		tag := uint64(tagVariable)
+		if e.tag == tagSubprogram {
+			tag = gen.Pick[uint64](d.s, tagFormalParameter, tagVariable)
+		}
+		v := e.add(&die{tag: tag})
+		v.attr(atName, d.strForm(), gen.Pick(d.s, "i", "argc", "argv", "err", "p", ""))
+		v.attrs = append(v.attrs, d.ref(atType, gen.Pick(d.s, types...)))
+		v.attr(atLocation, d.exprForm(), appendVarint([]byte{0x91}, int64(-8*d.s.Range(1, 16)))) // DW_OP_fbreg
+	}
+	if depth < 4 && d.s.Chance(0.5) {
+		for range d.s.Range(1, 2) {
+			d.scope(e.add(&die{tag: tagLexicalBlock}), types, depth+1)
+		}
+	}
+}
+
+// The lengths, in operands, of the standard opcodes of a line program.
+var standardOpcodeLengths = []byte{0, 1, 1, 1, 1, 0, 0, 0, 1, 0, 0, 1}
+
+// lineProgram appends a line program for code from low naming files to
+// .debug_line and returns its offset.
+func (d *dwarfGen) lineProgram(low uint64, files []string) uint64 {
+	off := uint64(len(d.line))
+	version := d.version
+	if version < 5 && d.s.Chance(0.3) {
+		version = d.s.Range(2, 4)
+	}
+	if d.broken() {
+		version = gen.Pick(d.s, 0, 1, 6, 0xffff)
+	}
+	minInst := gen.Pick[byte](d.s, 1, 1, 2, 4)
+	maxOps := byte(1)
+	lineBase := gen.Pick(d.s, -5, -3, -1)
+	lineRange := gen.Pick(d.s, 14, 12, 4)
+	opcodeBase := 13
+	if version == 2 {
+		opcodeBase = 10
+	}
+	if d.s.Chance(0.1) {
+		// Opcodes past the standard ones, which a reader skips by the
+		// lengths the header gives them.
+		opcodeBase += d.s.Range(1, 6)
+	}
+	if d.broken() {
+		minInst = 0
+	}
+	if d.broken() {
+		maxOps = gen.Pick[byte](d.s, 0, 3, 255)
+	}
+	if d.broken() {
+		lineBase, lineRange = gen.Pick(d.s, 10, -128, 127), gen.Pick(d.s, 0, 1, 255)
+	}
+	if d.broken() {
+		opcodeBase = gen.Pick(d.s, 0, 1, 4, 255)
+	}
+
+	var h []byte
+	h = append(h, minInst)
+	if version >= 4 {
+		h = append(h, maxOps)
+	}
+	h = append(h, byte(d.s.Intn(2)), byte(lineBase), byte(lineRange), byte(opcodeBase))
+	var lengths []byte
+	for i := 1; i < opcodeBase; i++ {
+		n := byte(d.s.Intn(3))
+		if i <= len(standardOpcodeLengths) {
+			n = standardOpcodeLengths[i-1]
+		}
+		if d.broken() {
+			n++
+		}
+		lengths = append(lengths, n)
+	}
+	h = append(h, lengths...)
+	dirs := []string{"/usr/include", "src", "../lib", "C:\\include"}[:d.s.Intn(5)]
+	if version < 5 {
+		for _, dir := range dirs {
+			h = append(h, dir...)
+			h = append(h, 0)
+		}
+		h = append(h, 0)
+		for _, f := range files {
+			if f == "" {
+				f = "x.c" // an empty name ends the list
+			}
+			h = append(h, f...)
+			h = append(h, 0)
+			dir := uint64(d.s.Intn(len(dirs) + 1))
+			if d.broken() {
+				dir = uint64(len(dirs) + d.s.Range(1, 1000))
+			}
+			h = binary.AppendUvarint(h, dir)
+			h = binary.AppendUvarint(h, uint64(d.s.Intn(1<<20)))
+			h = binary.AppendUvarint(h, uint64(d.s.Intn(1<<16)))
+		}
+		h = append(h, 0)
+	} else {
+		h = d.entryFormats(h, dirs, files)
+	}
+
+	p := d.linePrograms(low, version, lineRange, opcodeBase, lengths, len(files), len(dirs))
+	headerLength := uint64(len(h))
+	if d.broken() {
+		// A header that ends before its tables do, or after the
+		// program starts, or past the end.
+		headerLength = gen.Pick(d.s, headerLength-1, headerLength+1, 0, headerLength+uint64(len(p))+1, 1<<32-1)
+	}
+	var b []byte
+	b = d.bo.AppendUint16(b, uint16(version))
+	if version >= 5 {
+		b = append(b, byte(d.asize), 0)
+	}
+	b = d.offset(b, headerLength)
+	b = append(b, h...)
+	b = append(b, p...)
+	d.line = d.unitLength(d.line, len(b))
+	d.line = append(d.line, b...)
+	return off
+}
+
+// entryFormats appends the directory and file tables of a DWARF 5 line
+// program: the format of an entry of each, then the entries.
+func (d *dwarfGen) entryFormats(h []byte, dirs, files []string) []byte {
+	pathForm := d.strForm()
+	if d.broken() {
+		pathForm = gen.Pick[uint64](d.s, formLineStrp, formData16, formBlock, formUdata, formStrx1, 0x7f)
+	}
+	// The directory table starts with the compilation directory.
+	dirs = append([]string{"/tmp"}, dirs...)
+	h = append(h, 1)
+	h = binary.AppendUvarint(h, lnctPath)
+	h = binary.AppendUvarint(h, pathForm)
+	n := uint64(len(dirs))
+	if d.broken() {
+		// More entries than there are.
+		n += uint64(d.s.Range(1, 1000))
+	}
+	h = binary.AppendUvarint(h, n)
+	for _, dir := range dirs {
+		h = d.pathValue(h, pathForm, dir)
+	}
+
+	type format struct{ lnct, form uint64 }
+	formats := []format{{lnctPath, pathForm}, {lnctDirectoryIndex, gen.Pick[uint64](d.s, formUdata, formData1, formData2)}}
+	if d.s.Chance(0.3) {
+		formats = append(formats, format{lnctMD5, formData16})
+	}
+	if d.s.Chance(0.2) {
+		formats = append(formats, format{lnctSize, formUdata})
+	}
+	if d.s.Chance(0.1) {
+		formats = append(formats, format{0x2001, formBlock}) // a vendor's, which a reader skips
+	}
+	gen.Shuffle(d.s, formats)
+	h = append(h, byte(len(formats)))
+	for _, f := range formats {
+		h = binary.AppendUvarint(h, f.lnct)
+		h = binary.AppendUvarint(h, f.form)
+	}
+	n = uint64(len(files))
+	if d.broken() {
+		n += uint64(d.s.Range(1, 1000))
+	}
+	h = binary.AppendUvarint(h, n)
+	for _, file := range files {
+		for _, f := range formats {
+			switch f.lnct {
+			case lnctPath:
+				h = d.pathValue(h, f.form, file)
+			case lnctDirectoryIndex:
+				dir := uint64(d.s.Intn(len(dirs)))
+				if d.broken() {
+					dir = uint64(len(dirs) + d.s.Intn(100))
+				}
+				switch f.form {
+				case formUdata:
+					h = binary.AppendUvarint(h, dir)
+				case formData1:
+					h = append(h, byte(dir))
+				default:
+					h = d.bo.AppendUint16(h, uint16(dir))
+				}
+			case lnctMD5:
+				h = append(h, samples(d.s, 16)...)
+			case lnctSize:
+				h = binary.AppendUvarint(h, uint64(d.s.Intn(1<<20)))
+			default:
+				h = append(h, 2, 0xde, 0xad)
+			}
+		}
+	}
+	return h
+}
+
+// pathValue appends a path in form to a DWARF 5 line program header.
+func (d *dwarfGen) pathValue(h []byte, form uint64, path string) []byte {
+	switch form {
+	case formString:
+		return append(append(h, path...), 0)
+	case formStrp, formLineStrp:
+		return d.offset(h, d.strp(path))
+	case formData16:
+		return append(h, make([]byte, 16)...)
+	case formBlock:
+		return append(binary.AppendUvarint(h, uint64(len(path))), path...)
+	case formUdata:
+		return binary.AppendUvarint(h, uint64(len(path)))
+	case formStrx1:
+		return append(h, 0)
+	}
+	return h
+}
+
+// linePrograms returns the opcodes of a line program: a few sequences,
+// each from an address to the end of a run of code, with rows for the
+// lines of it, from special opcodes and standard and extended ones. The
+// standard opcodes are those below opcodeBase, which take the number of
+// operands lengths gives them.
+func (d *dwarfGen) linePrograms(low uint64, version, lineRange, opcodeBase int, lengths []byte, files, dirs int) []byte {
+	var p []byte
+	ext := func(op byte, args []byte) {
+		n := uint64(len(args) + 1)
+		if d.broken() {
+			// A length that stops short of the operands or runs past
+			// them, or past the end of the section.
+			n = gen.Pick(d.s, 0, n-1, n+uint64(d.s.Range(1, 8)), 1<<32, 1<<63)
+		}
+		p = append(p, 0)
+		p = binary.AppendUvarint(p, n)
+		p = append(p, op)
+		p = append(p, args...)
+	}
+	// std appends a standard opcode and its operands, if the program has
+	// the opcode; it is a special opcode if not.
+	std := func(op byte, operands ...uint64) {
+		if int(op) >= opcodeBase {
+			return
+		}
+		p = append(p, op)
+		switch op {
+		case lnsAdvanceLine:
+			p = appendVarint(p, int64(operands[0]))
+		case lnsFixedAdvancePC:
+			p = d.bo.AppendUint16(p, uint16(operands[0]))
+		default:
+			for _, v := range operands {
+				p = binary.AppendUvarint(p, v)
+			}
+		}
+	}
+	firstFile := 1
+	if version >= 5 {
+		firstFile = 0
+	}
+	for range d.s.Range(1, 3) {
+		ext(lneSetAddress, d.addr(nil, low))
+		for range d.s.Intn(40) {
+			switch d.s.Intn(16) {
+			case 0, 1, 2, 3, 4, 5:
+				// A special opcode: an address and line advance at once.
+				if lineRange <= 0 {
+					p = append(p, byte(d.s.Range(opcodeBase, 255)))
+					break
+				}
+				op := d.s.Intn(lineRange) + lineRange*d.s.Intn(4) + opcodeBase
+				if op > 255 {
+					std(lnsCopy)
+					break
+				}
+				p = append(p, byte(op))
+			case 6:
+				std(lnsAdvancePC, uint64(d.s.Range(1, 64)))
+				std(lnsCopy)
+			case 7:
+				std(lnsAdvanceLine, uint64(d.s.Range(-20, 100)))
+				std(lnsCopy)
+			case 8:
+				file := uint64(firstFile + d.s.Intn(files))
+				if d.broken() {
+					file = uint64(files + d.s.Range(1, 1<<20))
+				}
+				std(lnsSetFile, file)
+			case 9:
+				std(lnsSetColumn, uint64(d.s.Intn(120)))
+			case 10:
+				std(gen.Pick[byte](d.s, lnsNegateStmt, lnsSetBasicBlock, lnsConstAddPC, lnsSetPrologueEnd, lnsSetEpilogueBegin))
+			case 11:
+				std(lnsFixedAdvancePC, uint64(d.s.Intn(1<<16)))
+			case 12:
+				std(lnsSetISA, uint64(d.s.Intn(4)))
+			case 13:
+				ext(lneSetDiscriminator, binary.AppendUvarint(nil, uint64(d.s.Intn(10))))
+			case 14:
+				if version < 5 {
+					name := gen.Pick(d.s, "gen.c", "/abs/gen.h")
+					if d.broken() {
+						name = "" // which ends the list, and there is none
+					}
+					args := append([]byte(name), 0)
+					args = binary.AppendUvarint(args, uint64(d.s.Intn(dirs+1)))
+					ext(lneDefineFile, append(args, 0, 0))
+					break
+				}
+				fallthrough
+			default:
+				if opcodeBase > 13 {
+					// An opcode past the standard ones, with as many
+					// operands as the header says.
+					op := byte(d.s.Range(13, opcodeBase-1))
+					operands := make([]uint64, lengths[op-1])
+					for i := range operands {
+						operands[i] = uint64(d.s.Intn(1000))
+					}
+					std(op, operands...)
+				} else {
+					// An extended opcode of a vendor's.
+					ext(gen.Pick[byte](d.s, 0x80, 0xff, 0x05), samples(d.s, d.s.Intn(8)))
+				}
+			}
+		}
+		if !d.broken() {
+			ext(lneEndSequence, nil)
+		}
+		low += 0x100
+	}
+	if d.broken() {
+		p = truncate(d.s, p)
+	}
+	return p
+}
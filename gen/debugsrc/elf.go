@@ -0,0 +1,711 @@
+package debugsrc
+
+import (
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "debug/elf",
+		Doc:  "ELF executables, shared objects and relocatable objects, 32- and 64-bit in both byte orders: program headers, symbol and dynamic symbol tables, dynamic sections, version tables, relocations, notes and compressed DWARF sections, with section headers that disagree, program headers that overlap, huge symbol tables and truncated string tables",
+		Func: elfSeed,
+	})
+}
+
+// The ELF constants the generator uses.
+const (
+	etRel  = 1
+	etExec = 2
+	etDyn  = 3
+	etCore = 4
+
+	shtProgbits = 1
+	shtSymtab   = 2
+	shtStrtab   = 3
+	shtRela     = 4
+	shtDynamic  = 6
+	shtNote     = 7
+	shtNobits   = 8
+	shtRel      = 9
+	shtDynsym   = 11
+	shtVerdef   = 0x6ffffffd
+	shtVerneed  = 0x6ffffffe
+	shtVersym   = 0x6fffffff
+
+	shfWrite      = 0x1
+	shfAlloc      = 0x2
+	shfExec       = 0x4
+	shfCompressed = 0x800
+
+	ptLoad    = 1
+	ptDynamic = 2
+	ptInterp  = 3
+	ptNote    = 4
+	ptPhdr    = 6
+	ptTLS     = 7
+	ptStack   = 0x6474e551
+
+	shnAbs    = 0xfff1
+	shnCommon = 0xfff2
+	shnXindex = 0xffff
+)
+
+// An elfMachine is an architecture an ELF file may be for, with the
+// relocation types DWARF sections use on it.
+type elfMachine struct {
+	id         uint16
+	is64, rela bool
+	// abs32 and abs64 are its 32- and 64-bit absolute relocations; a
+	// 32-bit machine has no abs64.
+	abs32, abs64 uint32
+}
+
+var elfMachines = []elfMachine{
+	{id: 62, is64: true, rela: true, abs32: 10, abs64: 1},     // x86-64
+	{id: 183, is64: true, rela: true, abs32: 258, abs64: 257}, // arm64
+	{id: 243, is64: true, rela: true, abs32: 1, abs64: 2},     // riscv
+	{id: 21, is64: true, rela: true, abs32: 1, abs64: 38},     // ppc64
+	{id: 22, is64: true, rela: true, abs32: 5, abs64: 22},     // s390x
+	{id: 258, is64: true, rela: true, abs32: 1, abs64: 2},     // loong64
+	{id: 8, is64: true, rela: true, abs32: 2, abs64: 18},      // mips64
+	{id: 43, is64: true, rela: true, abs32: 3, abs64: 54},     // sparc64
+	{id: 3, abs32: 1},                                        // 386
+	{id: 40, abs32: 2},                                       // arm
+	{id: 8, abs32: 2},                                        // mips
+	{id: 20, rela: true, abs32: 1},                           // ppc
+	{id: 62, rela: true, abs32: 10},                          // x32
+	{id: 0x9026, is64: true, rela: true, abs32: 1, abs64: 2}, // unknown
+}
+
+// An elfSection is a section of an ELF file before it is laid out.
+type elfSection struct {
+	name       string
+	typ        uint32
+	flags      uint64
+	addr       uint64
+	link, info uint32
+	align      uint64
+	entsize    uint64
+	data       []byte
+	// size is the size of a NOBITS section, which has no data.
+	size uint64
+	// nameOff is where its name is in the section name table, and off
+	// where its data goes in the file, set by layout.
+	nameOff uint32
+	off     uint64
+}
+
+// elfSeed writes one ELF file.
+func elfSeed(s *gen.State) []gen.File {
+	m := gen.Pick(s, elfMachines...)
+	w := writer{bo: binary.LittleEndian, is64: m.is64}
+	if s.Chance(0.25) {
+		w.bo = binary.BigEndian
+	}
+	typ := gen.Pick(s, etExec, etExec, etDyn, etDyn, etRel, etRel, etCore)
+	if s.Chance(badRate) {
+		typ = gen.Pick(s, 0, 5, 0xfe00, 0xffff)
+	}
+
+	secs := []*elfSection{{}}
+	add := func(sec *elfSection) int {
+		secs = append(secs, sec)
+		return len(secs) - 1
+	}
+	text := add(&elfSection{name: ".text", typ: shtProgbits, flags: shfAlloc | shfExec, addr: 0x401000, align: 16, data: code(s)})
+	rodata, _ := strtab(symbolNames(s, s.Intn(8)))
+	add(&elfSection{name: ".rodata", typ: shtProgbits, flags: shfAlloc, addr: 0x402000, align: 8, data: rodata})
+	data := add(&elfSection{name: ".data", typ: shtProgbits, flags: shfAlloc | shfWrite, addr: 0x403000, align: 8, data: samples(s, s.Intn(64))})
+	bss := add(&elfSection{name: ".bss", typ: shtNobits, flags: shfAlloc | shfWrite, addr: 0x404000, align: 32, size: gen.Pick[uint64](s, 0, 8, 4096, 1<<20)})
+	if s.Chance(0.5) {
+		note := w.u32(nil, 4)
+		note = w.u32(note, 20)
+		note = w.u32(note, 3)
+		note = append(note, "GNU\x00"...)
+		note = append(note, samples(s, 20)...)
+		if s.Chance(badRate) {
+			note = note[:s.Intn(len(note))]
+		}
+		add(&elfSection{name: ".note.gnu.build-id", typ: shtNote, flags: shfAlloc, align: 4, data: note})
+	}
+
+	// The symbol table, which a stripped file leaves out.
+	var syms []string
+	symtab := 0
+	if typ == etRel || s.Chance(0.7) {
+		syms = symbolNames(s, gen.Pick(s, 0, 1, 3, 10, 40, s.Range(100, 2000)))
+		strs := add(&elfSection{name: ".strtab", typ: shtStrtab, align: 1})
+		symtab = add(&elfSection{name: ".symtab", typ: shtSymtab, link: uint32(strs), align: 8})
+		secs[symtab].data, secs[symtab].info = elfSymbols(s, w, syms, secs[strs], text, data)
+		secs[symtab].entsize = uint64(symSize(w))
+		if s.Chance(badRate) {
+			secs[symtab].link = gen.Pick[uint32](s, 0, uint32(symtab), 1<<16, 1<<32-1)
+		}
+	}
+
+	// What the dynamic linker reads: the interpreter, the dynamic
+	// symbols, their versions, and the libraries they come from.
+	var interp, dynamic int
+	if typ == etExec || typ == etDyn {
+		if s.Chance(0.7) {
+			interp = add(&elfSection{name: ".interp", typ: shtProgbits, flags: shfAlloc, align: 1, data: []byte(gen.Pick(s, "/lib64/ld-linux-x86-64.so.2\x00", "/lib/ld-linux.so.2\x00", "/lib/ld-musl-aarch64.so.1\x00", "/system/bin/linker64\x00"))})
+		}
+		if s.Chance(0.7) {
+			libs := gen.Pick(s, []string{"libc.so.6"}, []string{"libc.so.6", "libpthread.so.0", "libm.so.6"}, []string{"libc.so"}, nil)
+			dynsyms := symbolNames(s, gen.Pick(s, 0, 2, 5, 20))
+			dynstr := add(&elfSection{name: ".dynstr", typ: shtStrtab, flags: shfAlloc, align: 1})
+			dynsym := add(&elfSection{name: ".dynsym", typ: shtDynsym, flags: shfAlloc, link: uint32(dynstr), align: 8})
+			secs[dynsym].data, secs[dynsym].info = elfSymbols(s, w, dynsyms, secs[dynstr], text, data)
+			secs[dynsym].entsize = uint64(symSize(w))
+			if len(libs) > 0 && s.Chance(0.7) {
+				add(&elfSection{name: ".gnu.version", typ: shtVersym, flags: shfAlloc, link: uint32(dynsym), align: 2, entsize: 2, data: versym(s, w, len(dynsyms)+1, len(libs))})
+				verneed := add(&elfSection{name: ".gnu.version_r", typ: shtVerneed, flags: shfAlloc, link: uint32(dynstr), align: 4})
+				secs[verneed].data, secs[verneed].info = elfVerneed(s, w, libs, secs[dynstr])
+			}
+			if typ == etDyn && s.Chance(0.3) {
+				verdef := add(&elfSection{name: ".gnu.version_d", typ: shtVerdef, flags: shfAlloc, link: uint32(dynstr), align: 4})
+				secs[verdef].data, secs[verdef].info = elfVerdef(s, w, secs[dynstr])
+			}
+			dynamic = add(&elfSection{name: ".dynamic", typ: shtDynamic, flags: shfAlloc | shfWrite, link: uint32(dynstr), align: 8})
+			secs[dynamic].data = elfDynamic(s, w, libs, secs[dynstr])
+			secs[dynamic].entsize = uint64(2 * wordSize(w))
+			if s.Chance(badRate) {
+				secs[dynstr].data = truncate(s, secs[dynstr].data)
+			}
+		}
+	}
+
+	// DWARF, compressed or not, with the relocations a relocatable
+	// object needs applied to it.
+	if s.Chance(0.5) {
+		compress := gen.Pick(s, "", "", "shf", "zdebug")
+		for _, d := range dwarfSections(s, w.bo) {
+			name, payload, flags := d.name, d.data, uint64(0)
+			switch compress {
+			case "shf":
+				payload, flags = elfCompressed(s, w, payload), shfCompressed
+			case "zdebug":
+				name, payload = ".zdebug"+name[len(".debug"):], zdebug(s, payload)
+			}
+			sec := add(&elfSection{name: name, typ: shtProgbits, flags: flags, align: 1, data: payload})
+			if typ == etRel && symtab != 0 && name == ".debug_info" && s.Chance(0.7) {
+				add(elfRelocations(s, w, m, sec, symtab, len(syms), len(d.data)))
+			}
+		}
+	}
+	if s.Chance(0.1) {
+		add(&elfSection{name: gen.Pick(s, ".comment", ".note.go.buildid", ".gopclntab", ".unknown"), typ: gen.Pick[uint32](s, shtProgbits, shtNote, 0x70000001), align: 1, data: samples(s, s.Intn(100))})
+	}
+	shstrtab := add(&elfSection{name: ".shstrtab", typ: shtStrtab, align: 1})
+	names := make([]string, len(secs))
+	for i, sec := range secs {
+		names[i] = sec.name
+	}
+	var offs []uint32
+	secs[shstrtab].data, offs = strtab(names)
+	for i, sec := range secs {
+		sec.nameOff = offs[i]
+	}
+	if s.Chance(badRate * 2) {
+		secs[shstrtab].data = truncate(s, secs[shstrtab].data)
+	}
+
+	f := &elfFile{w: w, m: m, typ: typ, secs: secs, data: data, bss: bss, interp: interp, dynamic: dynamic}
+	return []gen.File{{Name: "input.elf", Data: f.layout(s)}}
+}
+
+// An elfFile is an ELF file before it is laid out: its sections, and
+// which of them its program headers cover.
+type elfFile struct {
+	w    writer
+	m    elfMachine
+	typ  int
+	secs []*elfSection
+	// data, bss, interp and dynamic are the indexes of those sections,
+	// the last two 0 if the file has none.
+	data, bss, interp, dynamic int
+}
+
+// layout lays out the sections of f and writes it: the header, program
+// headers for an executable, shared object or core file, the section
+// data, and the section headers, before the data or after it.
+func (f *elfFile) layout(s *gen.State) []byte {
+	w, secs := f.w, f.secs
+	ehsize, phentsize, shentsize := 52, 32, 40
+	if w.is64 {
+		ehsize, phentsize, shentsize = 64, 56, 64
+	}
+	var phdrs [][7]uint64 // type, flags, off, vaddr, filesz, memsz, align
+	progs := f.typ == etExec || f.typ == etDyn || f.typ == etCore
+	nph := 0
+	if progs {
+		nph = 6
+	}
+	off := uint64(ehsize + nph*phentsize)
+	shFirst := s.Chance(0.1)
+	shoff := uint64(0)
+	if shFirst {
+		shoff = off
+		off += uint64(len(secs) * shentsize)
+	}
+	for _, sec := range secs[1:] {
+		if sec.align > 1 {
+			off = (off + sec.align - 1) &^ (sec.align - 1)
+		}
+		sec.off = off
+		off += uint64(len(sec.data))
+	}
+	if !shFirst {
+		off = (off + 7) &^ 7
+		shoff = off
+	}
+	if s.Chance(0.1) {
+		// A stripped file with no section headers at all.
+		shoff = 0
+	}
+
+	if progs {
+		phdrs = append(phdrs, [7]uint64{ptPhdr, 4, uint64(ehsize), 0x400000 + uint64(ehsize), uint64(nph * phentsize), uint64(nph * phentsize), 8})
+		end := uint64(0)
+		for _, sec := range secs {
+			if sec.flags&shfAlloc != 0 {
+				end = max(end, sec.off+uint64(len(sec.data)))
+			}
+		}
+		phdrs = append(phdrs, [7]uint64{ptLoad, 5, 0, 0x400000, end, end + secs[f.bss].size, 0x1000})
+		if f.interp != 0 {
+			i := secs[f.interp]
+			phdrs = append(phdrs, [7]uint64{ptInterp, 4, i.off, 0x400000 + i.off, uint64(len(i.data)), uint64(len(i.data)), 1})
+		}
+		if f.dynamic != 0 {
+			d := secs[f.dynamic]
+			phdrs = append(phdrs, [7]uint64{ptDynamic, 6, d.off, 0x400000 + d.off, uint64(len(d.data)), uint64(len(d.data)), 8})
+		}
+		if s.Chance(0.3) {
+			phdrs = append(phdrs, [7]uint64{ptTLS, 4, secs[f.data].off, secs[f.data].addr, 8, 16, 8})
+		}
+		for len(phdrs) < nph {
+			phdrs = append(phdrs, [7]uint64{ptStack, 6, 0, 0, 0, 0, 16})
+		}
+		if s.Chance(0.05) {
+			// Segments that overlap: a second load of part of the
+			// first, or a note over the headers.
+			i := s.Range(2, nph-1)
+			phdrs[i] = gen.Pick(s,
+				[7]uint64{ptLoad, 6, end / 2, 0x400000 + end/2, end - end/2, end, 0x1000},
+				[7]uint64{ptNote, 4, 0, 0x400000, uint64(ehsize), uint64(ehsize), 4},
+				phdrs[1])
+		}
+		if s.Chance(badRate * 2) {
+			// A segment past the end of the file, or larger in the
+			// file than in memory.
+			i := s.Intn(nph)
+			switch s.Intn(3) {
+			case 0:
+				phdrs[i][2] = off + gen.Pick[uint64](s, 0, 1, 1<<32, 1<<63)
+			case 1:
+				phdrs[i][4] = gen.Pick[uint64](s, off+1, 1<<40, 1<<64-1)
+			default:
+				phdrs[i][4], phdrs[i][5] = phdrs[i][5]+1, phdrs[i][4]
+			}
+		}
+	}
+
+	b := []byte{0x7f, 'E', 'L', 'F', 1, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	if w.is64 {
+		b[4] = 2
+	}
+	if w.bo == binary.BigEndian {
+		b[5] = 2
+	}
+	b[7] = gen.Pick[byte](s, 0, 0, 0, 3, 9, 0xff)
+	if s.Chance(badRate) {
+		b[4+s.Intn(3)] = gen.Pick[byte](s, 0, 3, 0xff)
+	}
+	b = w.u16(b, uint16(f.typ))
+	b = w.u16(b, f.m.id)
+	b = w.u32(b, 1)
+	b = w.word(b, secs[1].addr)
+	phoff := uint64(0)
+	if progs {
+		phoff = uint64(ehsize)
+	}
+	b = w.word(b, phoff)
+	b = w.word(b, shoff)
+	b = w.u32(b, 0)
+	shnum, shstrndx := len(secs), len(secs)-1
+	if shoff == 0 {
+		shnum, shstrndx = 0, 0
+	}
+	fields := []int{ehsize, phentsize, nph, shentsize, shnum, shstrndx}
+	if s.Chance(badRate * 3) {
+		// A header whose sizes, counts or string table index are wrong:
+		// an entry size too small, a count past the end of the file or
+		// that needs extended numbering, or an index out of range.
+		i := s.Range(1, 5)
+		fields[i] = gen.Pick(s, 0, fields[i]-1, fields[i]+1, 0xff00, 0xffff)
+	}
+	for _, v := range fields {
+		b = w.u16(b, uint16(v))
+	}
+
+	for _, p := range phdrs {
+		b = w.u32(b, uint32(p[0]))
+		if w.is64 {
+			b = w.u32(b, uint32(p[1]))
+		}
+		b = w.word(b, p[2])
+		b = w.word(b, p[3])
+		b = w.word(b, p[3])
+		b = w.word(b, p[4])
+		b = w.word(b, p[5])
+		if !w.is64 {
+			b = w.u32(b, uint32(p[1]))
+		}
+		b = w.word(b, p[6])
+	}
+
+	shdrs := elfHeaders(s, w, secs)
+	if shFirst {
+		b = append(b, shdrs...)
+	}
+	for _, sec := range secs[1:] {
+		for uint64(len(b)) < sec.off {
+			b = append(b, 0)
+		}
+		b = append(b, sec.data...)
+	}
+	if !shFirst && shoff != 0 {
+		for uint64(len(b)) < shoff {
+			b = append(b, 0)
+		}
+		b = append(b, shdrs...)
+	}
+	if s.Chance(badRate * 2) {
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return b
+}
+
+// elfHeaders returns the section headers of secs, which are laid out,
+// now and then with one that disagrees with the file: data past its
+// end or overlapping another section's, a size too large to read, or a
+// link, name or entry size out of range.
+func elfHeaders(s *gen.State, w writer, secs []*elfSection) []byte {
+	var b []byte
+	for i, sec := range secs {
+		name, off, size := sec.nameOff, sec.off, uint64(len(sec.data))
+		if sec.typ == shtNobits {
+			size = sec.size
+		}
+		link, info, entsize := sec.link, sec.info, sec.entsize
+		if i > 0 && s.Chance(badRate) {
+			switch s.Intn(6) {
+			case 0:
+				off = gen.Pick[uint64](s, 1<<32, 1<<63, secs[s.Intn(len(secs))].off)
+			case 1:
+				size = gen.Pick[uint64](s, size+1, 1<<31, 1<<48, 1<<64-1)
+			case 2:
+				link = gen.Pick[uint32](s, uint32(len(secs)), uint32(i), 1<<32-1)
+			case 3:
+				name = gen.Pick[uint32](s, 1<<20, 1<<32-1)
+			case 4:
+				entsize = gen.Pick[uint64](s, 0, 1, 7, 1<<62)
+			default:
+				info = gen.Pick[uint32](s, uint32(len(secs)), 1<<32-1)
+			}
+		}
+		b = w.u32(b, name)
+		b = w.u32(b, sec.typ)
+		b = w.word(b, sec.flags)
+		b = w.word(b, sec.addr)
+		b = w.word(b, off)
+		b = w.word(b, size)
+		b = w.u32(b, link)
+		b = w.u32(b, info)
+		b = w.word(b, sec.align)
+		b = w.word(b, entsize)
+	}
+	return b
+}
+
+// elfSymbols returns a symbol table of the given names, with their
+// strings as the data of strs, and the index of its first global
+// symbol. The symbols are defined in the sections text and data,
+// absolute, common or undefined; a few are in no section there is.
+func elfSymbols(s *gen.State, w writer, names []string, strs *elfSection, text, data int) ([]byte, uint32) {
+	var offs []uint32
+	strs.data, offs = strtab(names)
+	if s.Chance(badRate * 2) {
+		strs.data = truncate(s, strs.data)
+	}
+	locals := s.Intn(len(names) + 1)
+	var b []byte
+	sym := func(name uint32, info, other byte, shndx uint16, value, size uint64) {
+		if w.is64 {
+			b = w.u32(b, name)
+			b = append(b, info, other)
+			b = w.u16(b, shndx)
+			b = w.word(b, value)
+			b = w.word(b, size)
+			return
+		}
+		b = w.u32(b, name)
+		b = w.word(b, value)
+		b = w.word(b, size)
+		b = append(b, info, other)
+		b = w.u16(b, shndx)
+	}
+	sym(0, 0, 0, 0, 0, 0)
+	for i, name := range offs {
+		bind := byte(0)
+		if i >= locals {
+			bind = gen.Pick[byte](s, 1, 1, 1, 2, 10)
+		}
+		kind := gen.Pick[byte](s, 0, 1, 2, 2, 3, 4, 6)
+		shndx := gen.Pick(s, uint16(text), uint16(text), uint16(data), 0, shnAbs, shnCommon)
+		if s.Chance(badRate) {
+			shndx = gen.Pick[uint16](s, 0xfe00, 0xff00, shnXindex)
+		}
+		sym(name, bind<<4|kind, byte(s.Intn(4)), shndx, uint64(s.Intn(1<<16)), uint64(gen.Pick(s, 0, 1, 8, 16, 256)))
+	}
+	if s.Chance(badRate) {
+		// A table whose size is not a whole number of symbols.
+		b = b[:len(b)-s.Range(1, 8)]
+	}
+	return b, uint32(locals + 1)
+}
+
+// elfDynamic returns a dynamic section naming libs, with their strings
+// added to dynstr.
+func elfDynamic(s *gen.State, w writer, libs []string, dynstr *elfSection) []byte {
+	var b []byte
+	entry := func(tag, val uint64) {
+		b = w.word(b, tag)
+		b = w.word(b, val)
+	}
+	add := func(str string) uint64 {
+		off := uint64(len(dynstr.data))
+		dynstr.data = append(dynstr.data, str...)
+		dynstr.data = append(dynstr.data, 0)
+		return off
+	}
+	for _, lib := range libs {
+		entry(1, add(lib)) // DT_NEEDED
+	}
+	if s.Chance(0.3) {
+		entry(14, add(gen.Pick(s, "libfoo.so.1", "libgo.so"))) // DT_SONAME
+	}
+	if s.Chance(0.2) {
+		entry(gen.Pick[uint64](s, 15, 29), add(gen.Pick(s, "$ORIGIN/../lib", "/usr/local/lib:/opt/lib", ""))) // DT_RPATH, DT_RUNPATH
+	}
+	entry(5, 0)                            // DT_STRTAB
+	entry(10, uint64(len(dynstr.data)))    // DT_STRSZ
+	entry(11, uint64(symSize(w)))          // DT_SYMENT
+	entry(0x6ffffffb, uint64(s.Intn(256))) // DT_FLAGS_1
+	if s.Chance(badRate * 2) {
+		// A string past the end of the table.
+		entry(1, gen.Pick[uint64](s, uint64(len(dynstr.data)), 1<<31, 1<<64-1))
+	}
+	if !s.Chance(badRate) {
+		entry(0, 0) // DT_NULL
+	}
+	return b
+}
+
+// versym returns the versions of n dynamic symbols, indexes into the
+// nlibs libraries' version needs, or local and global.
+func versym(s *gen.State, w writer, n, nlibs int) []byte {
+	var b []byte
+	for range n {
+		v := uint16(gen.Pick(s, 0, 1, 2+s.Intn(nlibs)))
+		if s.Chance(0.05) {
+			v |= 0x8000 // hidden
+		}
+		if s.Chance(badRate) {
+			v = gen.Pick[uint16](s, 0x7fff, uint16(nlibs+2))
+		}
+		b = w.u16(b, v)
+	}
+	return b
+}
+
+// elfVerneed returns the version needs of libs, with their strings added
+// to dynstr, and how many there are.
+func elfVerneed(s *gen.State, w writer, libs []string, dynstr *elfSection) ([]byte, uint32) {
+	add := func(str string) uint32 {
+		off := uint32(len(dynstr.data))
+		dynstr.data = append(dynstr.data, str...)
+		dynstr.data = append(dynstr.data, 0)
+		return off
+	}
+	var b []byte
+	index := uint16(2)
+	for i, lib := range libs {
+		versions := gen.Pick(s, []string{"GLIBC_2.2.5"}, []string{"GLIBC_2.2.5", "GLIBC_2.34"}, []string{"GLIBC_2.17"})
+		b = w.u16(b, 1)
+		b = w.u16(b, uint16(len(versions)))
+		b = w.u32(b, add(lib))
+		b = w.u32(b, 16)
+		next := uint32(16 + 16*len(versions))
+		if i == len(libs)-1 {
+			next = 0
+		}
+		if s.Chance(badRate) {
+			next = gen.Pick[uint32](s, 1, 1<<31, uint32(-len(b)+8))
+		}
+		b = w.u32(b, next)
+		for j, v := range versions {
+			b = w.u32(b, elfHash(v))
+			b = w.u16(b, 0)
+			b = w.u16(b, index)
+			index++
+			b = w.u32(b, add(v))
+			aux := uint32(16)
+			if j == len(versions)-1 {
+				aux = 0
+			}
+			b = w.u32(b, aux)
+		}
+	}
+	return b, uint32(len(libs))
+}
+
+// elfVerdef returns the version definitions of a shared object, with
+// their strings added to dynstr, and how many there are.
+func elfVerdef(s *gen.State, w writer, dynstr *elfSection) ([]byte, uint32) {
+	names := gen.Pick(s, []string{"libfoo.so.1", "FOO_1.0"}, []string{"libfoo.so.1", "FOO_1.0", "FOO_2.0"})
+	var b []byte
+	for i, name := range names {
+		off := uint32(len(dynstr.data))
+		dynstr.data = append(dynstr.data, name...)
+		dynstr.data = append(dynstr.data, 0)
+		flags := uint16(0)
+		if i == 0 {
+			flags = 1 // VER_FLG_BASE
+		}
+		b = w.u16(b, 1)
+		b = w.u16(b, flags)
+		b = w.u16(b, uint16(i+1))
+		b = w.u16(b, 1)
+		b = w.u32(b, elfHash(name))
+		b = w.u32(b, 20)
+		next := uint32(28)
+		if i == len(names)-1 {
+			next = 0
+		}
+		if s.Chance(badRate) {
+			next = gen.Pick[uint32](s, 1, 1<<31)
+		}
+		b = w.u32(b, next)
+		b = w.u32(b, off)
+		b = w.u32(b, 0)
+	}
+	return b, uint32(len(names))
+}
+
+// elfHash is the hash of a version name.
+func elfHash(name string) uint32 {
+	var h uint32
+	for _, c := range []byte(name) {
+		h = h<<4 + uint32(c)
+		if g := h & 0xf0000000; g != 0 {
+			h ^= g >> 24
+		}
+		h &^= 0xf0000000
+	}
+	return h
+}
+
+// elfRelocations returns the relocations of section sec, n bytes long,
+// against symbols 1 to nsyms of the symbol table symtab, of the absolute
+// types the DWARF reader applies on m and now and then others.
+func elfRelocations(s *gen.State, w writer, m elfMachine, sec, symtab, nsyms, n int) *elfSection {
+	rel := &elfSection{name: ".rel.debug_info", typ: shtRel, info: uint32(sec), link: uint32(symtab), align: 8}
+	if m.rela {
+		rel.name, rel.typ = ".rela.debug_info", shtRela
+	}
+	for range s.Range(1, 20) {
+		size := 4
+		typ := m.abs32
+		if m.is64 && s.Chance(0.5) {
+			size, typ = 8, m.abs64
+		}
+		if s.Chance(0.1) {
+			typ = uint32(s.Intn(300))
+		}
+		off := uint64(s.Intn(max(n-size+1, 1)))
+		if s.Chance(badRate * 2) {
+			off = gen.Pick[uint64](s, uint64(n), 1<<32-1)
+		}
+		sym := uint64(s.Range(0, nsyms))
+		if s.Chance(badRate) {
+			sym = uint64(nsyms + 1 + s.Intn(1000))
+		}
+		rel.data = w.word(rel.data, off)
+		if w.is64 {
+			rel.data = w.word(rel.data, sym<<32|uint64(typ))
+		} else {
+			rel.data = w.word(rel.data, sym<<8|uint64(typ&0xff))
+		}
+		if m.rela {
+			rel.data = w.word(rel.data, uint64(gen.Pick(s, 0, 1, 8, -1)))
+		}
+	}
+	rel.entsize = uint64(2 * wordSize(w))
+	if m.rela {
+		rel.entsize += uint64(wordSize(w))
+	}
+	return rel
+}
+
+// elfCompressed returns data compressed behind an ELF compression
+// header: zlib, now and then with a size that does not match, a type
+// that is zstd or unknown, or data that does not decompress.
+func elfCompressed(s *gen.State, w writer, data []byte) []byte {
+	typ, size := uint32(1), uint64(len(data))
+	payload := zlibBytes(data)
+	if s.Chance(0.02) {
+		// A bomb: megabytes of zeros in a few kilobytes.
+		size = uint64(s.Range(1, 16) << 20)
+		payload = zlibBytes(make([]byte, size))
+	}
+	if s.Chance(badRate * 2) {
+		switch s.Intn(3) {
+		case 0:
+			size = gen.Pick[uint64](s, 0, size/2, size+1, 1<<40, 1<<64-1)
+		case 1:
+			typ = gen.Pick[uint32](s, 2, 3, 0x60000000)
+		default:
+			payload = truncate(s, payload)
+		}
+	}
+	b := w.u32(nil, typ)
+	if w.is64 {
+		b = w.u32(b, 0)
+	}
+	b = w.word(b, size)
+	b = w.word(b, 1)
+	return append(b, payload...)
+}
+
+// zdebug returns data as a .zdebug section holds it: "ZLIB", its size,
+// big-endian, and the zlib stream.
+func zdebug(s *gen.State, data []byte) []byte {
+	size := uint64(len(data))
+	if s.Chance(badRate * 2) {
+		size = gen.Pick[uint64](s, size+1, 1<<40)
+	}
+	b := append([]byte("ZLIB"), binary.BigEndian.AppendUint64(nil, size)...)
+	return append(b, zlibBytes(data)...)
+}
+
+// symSize is the size of a symbol in bytes.
+func symSize(w writer) int {
+	if w.is64 {
+		return 24
+	}
+	return 16
+}
@@ -0,0 +1,653 @@
+package debugsrc
+
+import (
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "debug/macho",
+		Doc:  "Mach-O objects, executables and dylibs, 32- and 64-bit for amd64, arm64, 386, arm and ppc, and fat files of several: segments and sections, relocations, symbol and dynamic symbol tables with indirect symbols, dylibs, run paths, UUIDs and DWARF, with load commands that disagree with the header, huge section counts, symbol tables past the end and overlapping fat architectures",
+		Func: machoSeed,
+	})
+}
+
+// The Mach-O constants the generator uses.
+const (
+	mhObject  = 1
+	mhExecute = 2
+	mhDylib   = 6
+
+	lcSegment      = 0x1
+	lcSymtab       = 0x2
+	lcDysymtab     = 0xb
+	lcLoadDylib    = 0xc
+	lcIDDylib      = 0xd
+	lcLoadDylinker = 0xe
+	lcSegment64    = 0x19
+	lcUUID         = 0x1b
+	lcRpath        = 0x8000001c
+	lcMain         = 0x80000028
+
+	nExt  = 0x01
+	nSect = 0x0e
+
+	sZerofill             = 0x1
+	sSymbolStubs          = 0x8
+	sAttrPureInstructions = 0x80000000
+	indirectSymbolLocal   = 0x80000000
+
+	machoPageZero     = 0x100000000
+	machoRelocSize    = 8
+	machoSymtabSize   = 24
+	machoDysymtabSize = 80
+)
+
+// A machoCPU is an architecture a Mach-O file may be for.
+type machoCPU struct {
+	typ, sub uint32
+	is64, be bool
+}
+
+var machoCPUs = []machoCPU{
+	{typ: 0x01000007, sub: 3, is64: true},           // amd64
+	{typ: 0x0100000c, sub: 0, is64: true},           // arm64
+	{typ: 7, sub: 3},                                // 386
+	{typ: 12, sub: 9},                               // arm
+	{typ: 18, sub: 0, be: true},                     // ppc
+	{typ: 0x01000012, sub: 0, is64: true, be: true}, // ppc64
+}
+
+// A machoSection is a section of a Mach-O file before it is laid out.
+type machoSection struct {
+	name, seg string
+	data      []byte
+	// size is the size of a zero-filled section, which has no data.
+	size   uint64
+	align  uint32
+	flags  uint32
+	relocs []byte
+	// addr is its address, and off and reloff where its data and
+	// relocations go in the file, set by layout.
+	addr        uint64
+	off, reloff uint32
+}
+
+// A machoSegment is a segment and the sections in it.
+type machoSegment struct {
+	name       string
+	addr       uint64
+	prot       uint32
+	secs       []*machoSection
+	linkedit   bool
+	off, fsize uint64
+}
+
+// machoSeed writes one Mach-O file, or a fat file of several.
+func machoSeed(s *gen.State) []gen.File {
+	if !s.Chance(0.15) {
+		cpu := gen.Pick(s, machoCPUs...)
+		typ := gen.Pick[uint32](s, mhObject, mhExecute, mhExecute, mhDylib)
+		return []gen.File{{Name: "input.macho", Data: machoImage(s, cpu, typ)}}
+	}
+
+	// A fat file: a big-endian header and the architectures it holds,
+	// each at an aligned offset, all of one type.
+	cpus := append([]machoCPU(nil), machoCPUs...)
+	gen.Shuffle(s, cpus)
+	cpus = cpus[:s.Range(1, 3)]
+	if s.Chance(badRate * 2) {
+		cpus = append(cpus, cpus[0])
+	}
+	typ := gen.Pick[uint32](s, mhExecute, mhDylib, mhObject)
+	images := make([][]byte, len(cpus))
+	for i, cpu := range cpus {
+		t := typ
+		if s.Chance(badRate) {
+			t = mhExecute + mhDylib - t
+		}
+		images[i] = machoImage(s, cpu, t)
+	}
+	align := gen.Pick[uint32](s, 4, 12, 14)
+	be := binary.BigEndian
+	b := be.AppendUint32(nil, 0xcafebabe)
+	n := uint32(len(cpus))
+	if s.Chance(badRate) {
+		n = gen.Pick[uint32](s, 0, n+1, 1<<31)
+	}
+	b = be.AppendUint32(b, n)
+	off := uint32(8 + 20*len(cpus))
+	var offs []uint32
+	for i, cpu := range cpus {
+		off = (off + 1<<align - 1) &^ (1<<align - 1)
+		offs = append(offs, off)
+		size := uint32(len(images[i]))
+		at := off
+		if i > 0 && s.Chance(badRate*2) {
+			// An architecture over another, or past the end of the file.
+			switch s.Intn(2) {
+			case 0:
+				at = offs[0]
+			default:
+				at, size = gen.Pick(s, at, 1<<31), gen.Pick(s, size+1<<20, 1<<32-1)
+			}
+		}
+		b = be.AppendUint32(b, cpu.typ)
+		b = be.AppendUint32(b, cpu.sub)
+		b = be.AppendUint32(b, at)
+		b = be.AppendUint32(b, size)
+		b = be.AppendUint32(b, align)
+		off += uint32(len(images[i]))
+	}
+	for i, img := range images {
+		for uint32(len(b)) < offs[i] {
+			b = append(b, 0)
+		}
+		b = append(b, img...)
+	}
+	return []gen.File{{Name: "input.macho", Data: b}}
+}
+
+// machoImage returns a Mach-O file of type typ for cpu.
+func machoImage(s *gen.State, cpu machoCPU, typ uint32) []byte {
+	w := writer{bo: binary.LittleEndian, is64: cpu.is64}
+	if cpu.be {
+		w.bo = binary.BigEndian
+	}
+	object := typ == mhObject
+
+	// The segments: for an object, one with no name holding every
+	// section; otherwise a page zero, text, data, DWARF and the link
+	// edit segment, which holds the symbol and string tables.
+	text := &machoSection{name: "__text", seg: "__TEXT", data: code(s), align: 4, flags: sAttrPureInstructions | 0x400}
+	cstr, _ := strtab(symbolNames(s, s.Intn(8)))
+	cstring := &machoSection{name: "__cstring", seg: "__TEXT", data: cstr, flags: 0x2}
+	data := &machoSection{name: "__data", seg: "__DATA", data: samples(s, s.Intn(64)), align: 3}
+	bss := &machoSection{name: "__bss", seg: "__DATA", size: gen.Pick[uint64](s, 8, 4096, 1<<20), align: 3, flags: sZerofill}
+	textSecs := []*machoSection{text, cstring}
+	var stubs *machoSection
+	if !object && s.Chance(0.5) {
+		stubs = &machoSection{name: "__stubs", seg: "__TEXT", align: 1, flags: sSymbolStubs | sAttrPureInstructions}
+		textSecs = append(textSecs, stubs)
+	}
+	var dwarf []*machoSection
+	if s.Chance(0.5) {
+		z := s.Chance(0.2)
+		for _, d := range dwarfSections(s, w.bo) {
+			name, payload := "__"+d.name[1:], d.data
+			if z {
+				name, payload = "__zdebug_"+d.name[len(".debug_"):], zdebug(s, payload)
+			}
+			dwarf = append(dwarf, &machoSection{name: name, seg: "__DWARF", data: payload})
+		}
+	}
+	var segs []*machoSegment
+	if object {
+		all := append(append(textSecs, data, bss), dwarf...)
+		segs = append(segs, &machoSegment{prot: 7, secs: all})
+	} else {
+		segs = append(segs,
+			&machoSegment{name: "__PAGEZERO"},
+			&machoSegment{name: "__TEXT", prot: 5, secs: textSecs},
+			&machoSegment{name: "__DATA", prot: 3, secs: []*machoSection{data, bss}})
+		if dwarf != nil {
+			segs = append(segs, &machoSegment{name: "__DWARF", prot: 1, secs: dwarf})
+		}
+		segs = append(segs, &machoSegment{name: "__LINKEDIT", prot: 1, linkedit: true})
+	}
+
+	// The symbols, locals then external definitions then undefined
+	// ones, as the dynamic symbol table expects, with the indirect
+	// symbols of the stubs.
+	var locals, defs, undefs []string
+	if object || s.Chance(0.85) {
+		locals = symbolNames(s, gen.Pick(s, 0, 1, 3, 10))
+		defs = symbolNames(s, gen.Pick(s, 1, 3, 10, s.Range(50, 1000)))
+		undefs = symbolNames(s, gen.Pick(s, 0, 1, 4, 20))
+	}
+	names := append(append(append([]string{}, locals...), defs...), undefs...)
+	for i := range names {
+		names[i] = "_" + names[i]
+	}
+	strs, offs := strtab(names)
+	strs = append([]byte{' '}, strs...) // the first string is " " by custom
+	bad := -1
+	if s.Chance(badRate * 2) {
+		bad = s.Intn(len(names) + 1) // a string past the end of the table
+	}
+	var syms []byte
+	for i, off := range offs {
+		off++
+		if i == bad {
+			off = gen.Pick(s, uint32(len(strs)), 1<<31)
+		}
+		typ, sect, value := byte(nSect), byte(1), uint64(s.Intn(len(text.data)+1))
+		switch {
+		case i >= len(locals)+len(defs):
+			typ, sect, value = nExt, 0, 0
+		case i >= len(locals):
+			typ = nSect | nExt
+		}
+		if s.Chance(0.1) {
+			sect = byte(s.Range(2, 4)) // in the data, or beyond
+		}
+		syms = w.u32(syms, off)
+		syms = append(syms, typ, sect)
+		syms = w.u16(syms, 0)
+		syms = w.word(syms, value)
+	}
+	var indirect []byte
+	if stubs != nil {
+		for i := range undefs {
+			idx := uint32(len(locals) + len(defs) + i)
+			if s.Chance(0.05) {
+				idx = indirectSymbolLocal
+			}
+			if s.Chance(badRate) {
+				idx = gen.Pick[uint32](s, uint32(len(names)), 1<<30)
+			}
+			indirect = w.u32(indirect, idx)
+			stubs.data = append(stubs.data, 0xff, 0x25, 0, 0, 0, 0) // jmp *
+		}
+	}
+	if object {
+		for _, sec := range append([]*machoSection{text, data}, dwarf...) {
+			if len(sec.data) > 0 && len(names) > 0 && s.Chance(0.5) {
+				sec.relocs = machoRelocations(s, w, len(names), len(sec.data))
+			}
+		}
+	}
+
+	// The other load commands, whose sizes do not depend on where things
+	// are.
+	var cmds [][]byte
+	pad := 4
+	if w.is64 {
+		pad = 8
+	}
+	str := func(cmd uint32, fields []uint32, str string) []byte {
+		b := w.u32(nil, cmd)
+		b = w.u32(b, 0)
+		at := uint32(8 + 4*len(fields) + 4)
+		b = w.u32(b, at)
+		for _, f := range fields {
+			b = w.u32(b, f)
+		}
+		b = append(b, str...)
+		b = append(b, 0)
+		for len(b)%pad != 0 {
+			b = append(b, 0)
+		}
+		if s.Chance(badRate) {
+			// A string that starts past the end of its command, or a
+			// command of a size that is not aligned.
+			switch s.Intn(2) {
+			case 0:
+				b[8] = 0xff
+				b[9], b[10], b[11] = b[8], b[8], b[8]
+			default:
+				b = append(b, 0)
+			}
+		}
+		w.put32(b[4:], uint32(len(b)))
+		return b
+	}
+	if !object {
+		if typ == mhExecute {
+			cmds = append(cmds, str(lcLoadDylinker, nil, "/usr/lib/dyld"))
+			main := w.u32(nil, lcMain)
+			main = w.u32(main, 24)
+			main = w.bo.AppendUint64(main, uint64(s.Intn(0x1000)))
+			main = w.bo.AppendUint64(main, 0)
+			cmds = append(cmds, main)
+		} else {
+			cmds = append(cmds, str(lcIDDylib, []uint32{2, 0x10000, 0x10000}, gen.Pick(s, "@rpath/libfoo.dylib", "/usr/local/lib/libbar.1.dylib")))
+		}
+		libs := gen.Pick(s, []string{"/usr/lib/libSystem.B.dylib"}, []string{"/usr/lib/libSystem.B.dylib", "/System/Library/Frameworks/Foundation.framework/Versions/C/Foundation", "@rpath/libswiftCore.dylib"}, nil)
+		for _, lib := range libs {
+			cmds = append(cmds, str(lcLoadDylib, []uint32{2, 0x5000000, 0x10000}, lib))
+		}
+		for range gen.Pick(s, 0, 0, 1, 2) {
+			cmds = append(cmds, str(lcRpath, nil, gen.Pick(s, "@executable_path/../Frameworks", "/usr/local/lib", "@loader_path")))
+		}
+	}
+	if s.Chance(0.7) {
+		uuid := w.u32(nil, lcUUID)
+		uuid = w.u32(uuid, 24)
+		cmds = append(cmds, append(uuid, samples(s, 16)...))
+	}
+	if s.Chance(0.05) {
+		// A command this reader does not know.
+		unknown := w.u32(nil, gen.Pick[uint32](s, 0x32, 0x26, 0x80000022, 0x7fff))
+		unknown = w.u32(unknown, 16)
+		cmds = append(cmds, append(unknown, samples(s, 8)...))
+	}
+
+	f := &machoFile{w: w, cpu: cpu, typ: typ, segs: segs, cmds: cmds, syms: syms, nsyms: len(names), strs: strs, indirect: indirect,
+		nlocal: len(locals), ndef: len(defs), nundef: len(undefs)}
+	return f.layout(s)
+}
+
+// A machoFile is a Mach-O file before it is laid out.
+type machoFile struct {
+	w    writer
+	cpu  machoCPU
+	typ  uint32
+	segs []*machoSegment
+	// cmds are the load commands other than segments and symbol
+	// tables, already written.
+	cmds [][]byte
+	// syms are the symbols, nsyms of them, nlocal local, ndef defined
+	// and exported and nundef undefined, strs their names, and indirect
+	// the indirect symbol table.
+	syms, strs, indirect []byte
+	nsyms                int
+	nlocal, ndef, nundef int
+}
+
+// layout lays out f and writes it: the header, the load commands, the
+// section data, relocations and the symbol, indirect symbol and string
+// tables.
+func (f *machoFile) layout(s *gen.State) []byte {
+	w := f.w
+	hdrSize, segSize, secSize := 28, 56, 68
+	if w.is64 {
+		hdrSize, segSize, secSize = 32, 72, 80
+	}
+	symtab := f.nsyms > 0 || s.Chance(0.3)
+	dysymtab := symtab && (f.typ != mhObject || s.Chance(0.3)) || s.Chance(badRate)
+	cmdsSize := 0
+	for _, seg := range f.segs {
+		cmdsSize += segSize + secSize*len(seg.secs)
+	}
+	if symtab {
+		cmdsSize += machoSymtabSize
+	}
+	if dysymtab {
+		cmdsSize += machoDysymtabSize
+	}
+	for _, cmd := range f.cmds {
+		cmdsSize += len(cmd)
+	}
+
+	// Where everything goes: the text segment of an image starts with the
+	// header, every other segment with its first section.
+	off := uint64(hdrSize + cmdsSize)
+	addr := uint64(0x1000)
+	if w.is64 {
+		addr = machoPageZero
+	}
+	for _, seg := range f.segs {
+		switch {
+		case seg.name == "__PAGEZERO":
+			continue
+		case seg.name == "__TEXT":
+			seg.off, seg.addr = 0, addr
+		default:
+			off = (off + 0xf) &^ 0xf
+			seg.off, seg.addr = off, addr+off
+		}
+		for _, sec := range seg.secs {
+			a := uint64(1) << sec.align
+			off = (off + a - 1) &^ (a - 1)
+			sec.addr = addr + off
+			if sec.flags&sZerofill == 0 {
+				sec.off = uint32(off)
+				off += uint64(len(sec.data))
+			}
+		}
+		if !seg.linkedit {
+			seg.fsize = off - seg.off
+		}
+	}
+	var secs []*machoSection
+	for _, seg := range f.segs {
+		secs = append(secs, seg.secs...)
+	}
+	for _, sec := range secs {
+		if len(sec.relocs) > 0 {
+			sec.reloff = uint32(off)
+			off += uint64(len(sec.relocs))
+		}
+	}
+	off = (off + 7) &^ 7
+	symoff := off
+	off += uint64(len(f.syms))
+	indoff := off
+	off += uint64(len(f.indirect))
+	stroff := off
+	off += uint64(len(f.strs))
+	for _, seg := range f.segs {
+		if seg.linkedit {
+			seg.off, seg.addr, seg.fsize = symoff, addr+symoff, off-symoff
+		}
+	}
+
+	// The header.
+	b := w.u32(nil, 0xfeedface)
+	if w.is64 {
+		b = w.u32(nil, 0xfeedfacf)
+	}
+	ncmds := len(f.segs) + len(f.cmds)
+	if symtab {
+		ncmds++
+	}
+	if dysymtab {
+		ncmds++
+	}
+	nfield, sizeField := uint32(ncmds), uint32(cmdsSize)
+	if s.Chance(badRate * 3) {
+		// Counts and sizes that disagree with the commands, or are too
+		// large.
+		switch s.Intn(2) {
+		case 0:
+			nfield = gen.Pick(s, nfield+1, nfield-1, 1<<20, 1<<32-1)
+		default:
+			sizeField = gen.Pick(s, sizeField-4, sizeField+8, 1<<31, 1<<32-1)
+		}
+	}
+	b = w.u32(b, f.cpu.typ)
+	b = w.u32(b, f.cpu.sub)
+	b = w.u32(b, f.typ)
+	b = w.u32(b, nfield)
+	b = w.u32(b, sizeField)
+	flags := uint32(0)
+	if f.typ != mhObject {
+		flags = 0x200085 // no undefs, dyld link, two-level, PIE
+	}
+	b = w.u32(b, flags)
+	if w.is64 {
+		b = w.u32(b, 0)
+	}
+
+	// The load commands.
+	for _, seg := range f.segs {
+		cmd := uint32(lcSegment)
+		if w.is64 {
+			cmd = lcSegment64
+		}
+		start := len(b)
+		b = w.u32(b, cmd)
+		b = w.u32(b, uint32(segSize+secSize*len(seg.secs)))
+		b = machoName(b, seg.name)
+		vmsize := seg.fsize
+		if seg.name == "__PAGEZERO" {
+			vmsize = addr
+		}
+		b = w.word(b, seg.addr)
+		b = w.word(b, (vmsize+0xfff)&^0xfff)
+		b = w.word(b, seg.off)
+		b = w.word(b, seg.fsize)
+		b = w.u32(b, seg.prot)
+		b = w.u32(b, seg.prot)
+		nsect := uint32(len(seg.secs))
+		if s.Chance(badRate) {
+			nsect = gen.Pick(s, nsect+1, nsect+100, 1<<32-1)
+		}
+		b = w.u32(b, nsect)
+		b = w.u32(b, 0)
+		for _, sec := range seg.secs {
+			size := uint64(len(sec.data))
+			if sec.flags&sZerofill != 0 {
+				size = sec.size
+			}
+			off := sec.off
+			if s.Chance(badRate) {
+				// Data past the end of the file, or too large to read.
+				switch s.Intn(2) {
+				case 0:
+					off = gen.Pick[uint32](s, 1<<24, 1<<31, 1<<32-1)
+				default:
+					size = gen.Pick[uint64](s, size+1, 1<<31, 1<<40, 1<<63)
+				}
+			}
+			nreloc := uint32(len(sec.relocs) / machoRelocSize)
+			if nreloc > 0 && s.Chance(badRate) {
+				nreloc = gen.Pick[uint32](s, nreloc+1, 1<<28, 1<<32-1)
+			}
+			b = machoName(b, sec.name)
+			b = machoName(b, sec.seg)
+			b = w.word(b, sec.addr)
+			b = w.word(b, size)
+			b = w.u32(b, off)
+			b = w.u32(b, sec.align)
+			b = w.u32(b, sec.reloff)
+			b = w.u32(b, nreloc)
+			b = w.u32(b, sec.flags)
+			b = w.u32(b, 0) // reserved1: where the stubs' indirect symbols start
+			b = w.u32(b, 6) // reserved2: the size of a stub
+			if w.is64 {
+				b = w.u32(b, 0)
+			}
+		}
+		if s.Chance(badRate) {
+			// A command larger than the rest of the commands.
+			w.put32(b[start+4:], gen.Pick[uint32](s, uint32(len(b)-start-1), 1<<20, 4))
+		}
+	}
+	if symtab {
+		nsyms, so := uint32(f.nsyms), uint32(symoff)
+		if s.Chance(badRate * 2) {
+			// A symbol table past the end of the file, or larger than it.
+			switch s.Intn(2) {
+			case 0:
+				so = gen.Pick(s, uint32(off), 1<<31, 1<<32-1)
+			default:
+				nsyms = gen.Pick(s, nsyms+1, nsyms+1000, 1<<28, 1<<32-1)
+			}
+		}
+		b = w.u32(b, lcSymtab)
+		b = w.u32(b, machoSymtabSize)
+		b = w.u32(b, so)
+		b = w.u32(b, nsyms)
+		b = w.u32(b, uint32(stroff))
+		strsize := uint32(len(f.strs))
+		if s.Chance(badRate) {
+			strsize = gen.Pick(s, strsize+1, 1<<31)
+		}
+		b = w.u32(b, strsize)
+	}
+	if dysymtab {
+		iundef, nundef := uint32(f.nlocal+f.ndef), uint32(f.nundef)
+		if s.Chance(badRate * 2) {
+			// Undefined symbols beyond the symbol table, by an index or a
+			// count too large, or a count so large that the end wraps
+			// around.
+			switch s.Intn(3) {
+			case 0:
+				iundef = uint32(f.nsyms + 1)
+			case 1:
+				nundef++
+			default:
+				nundef = 1<<32 - 1
+			}
+		}
+		nind := uint32(len(f.indirect) / 4)
+		if s.Chance(badRate) {
+			nind = gen.Pick(s, nind+1, 1<<30)
+		}
+		fields := []uint32{
+			0, uint32(f.nlocal), // local symbols
+			uint32(f.nlocal), uint32(f.ndef), // external definitions
+			iundef, nundef, // undefined symbols
+			0, 0, 0, 0, 0, 0, // table of contents, modules, external references
+			uint32(indoff), nind, // indirect symbols
+			0, 0, 0, 0, // external and local relocations
+		}
+		b = w.u32(b, lcDysymtab)
+		b = w.u32(b, machoDysymtabSize)
+		for _, v := range fields {
+			b = w.u32(b, v)
+		}
+	}
+	for _, cmd := range f.cmds {
+		b = append(b, cmd...)
+	}
+
+	for _, sec := range secs {
+		if sec.flags&sZerofill != 0 {
+			continue
+		}
+		for uint32(len(b)) < sec.off {
+			b = append(b, 0)
+		}
+		b = append(b, sec.data...)
+	}
+	for _, sec := range secs {
+		if len(sec.relocs) > 0 {
+			for uint32(len(b)) < sec.reloff {
+				b = append(b, 0)
+			}
+			b = append(b, sec.relocs...)
+		}
+	}
+	for uint64(len(b)) < symoff {
+		b = append(b, 0)
+	}
+	b = append(b, f.syms...)
+	b = append(b, f.indirect...)
+	b = append(b, f.strs...)
+	if s.Chance(badRate * 2) {
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return b
+}
+
+// machoName appends name as a segment or section name: 16 bytes, padded
+// with NULs, or cut short if it is longer.
+func machoName(b []byte, name string) []byte {
+	var n [16]byte
+	copy(n[:], name)
+	return append(b, n[:]...)
+}
+
+// machoRelocations returns relocations for a section of n bytes against
+// symbols 0 to nsyms-1, external or by section, and now and then
+// scattered ones.
+func machoRelocations(s *gen.State, w writer, nsyms, n int) []byte {
+	var b []byte
+	for range s.Range(1, 20) {
+		addr := uint32(s.Intn(max(n-3, 1)))
+		if s.Chance(0.1) {
+			// Scattered: the address, type, length and pc-relative flag
+			// in the first word, a value in the second.
+			b = w.u32(b, 1<<31|uint32(s.Intn(2))<<30|2<<28|uint32(s.Intn(16))<<24|addr&0xffffff)
+			b = w.u32(b, uint32(s.Intn(1<<16)))
+			continue
+		}
+		sym := uint32(s.Intn(nsyms))
+		if s.Chance(badRate) {
+			sym = gen.Pick[uint32](s, uint32(nsyms), 1<<24-1)
+		}
+		pcrel, length, ext, typ := uint32(s.Intn(2)), uint32(gen.Pick(s, 2, 3)), uint32(s.Intn(2)), uint32(s.Intn(10))
+		b = w.u32(b, addr)
+		if w.bo == binary.BigEndian {
+			b = w.u32(b, sym<<8|pcrel<<7|length<<5|ext<<4|typ)
+		} else {
+			b = w.u32(b, sym|pcrel<<24|length<<25|ext<<27|typ<<28)
+		}
+	}
+	return b
+}
@@ -0,0 +1,577 @@
+package debugsrc
+
+import (
+	"encoding/binary"
+	"strconv"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "debug/pe",
+		Doc:  "PE executables, DLLs and COFF objects for 386, amd64, arm and arm64: 32- and 64-bit optional headers, data directories, long section names, import directories by name and ordinal, COFF symbols with auxiliary records, relocations and DWARF, with wrong section counts, lying string tables and import directories that do not end",
+		Func: peSeed,
+	})
+}
+
+// The PE constants the generator uses.
+const (
+	peFileAlign    = 0x200
+	peSectionAlign = 0x1000
+
+	scnCode         = 0x20
+	scnData         = 0x40
+	scnBSS          = 0x80
+	scnDiscardable  = 0x2000000
+	scnMemExecute   = 0x20000000
+	scnMemRead      = 0x40000000
+	scnMemWrite     = 0x80000000
+	scnAlign1       = 0x100000
+	scnAlign16      = 0x500000
+	dirImport       = 1
+	classExternal   = 2
+	classStatic     = 3
+	classFile       = 0x67
+	peSymbolSize    = 18
+	peRelocSize     = 10
+	peSectionHeader = 40
+)
+
+// A peMachine is an architecture a PE file may be for, with the
+// relocation types an object file uses on it.
+type peMachine struct {
+	id     uint16
+	is64   bool
+	relocs []uint16
+}
+
+var peMachines = []peMachine{
+	{id: 0x14c, relocs: []uint16{6, 7, 20}},              // 386: DIR32, DIR32NB, REL32
+	{id: 0x8664, is64: true, relocs: []uint16{1, 2, 4}},  // amd64: ADDR64, ADDR32, REL32
+	{id: 0xaa64, is64: true, relocs: []uint16{1, 3, 14}}, // arm64: ADDR32, BRANCH26, ADDR64
+	{id: 0x1c4, relocs: []uint16{1, 3, 20}},              // arm: ADDR32, BRANCH24, BLX23T
+}
+
+// A peSection is a section of a PE file before it is laid out.
+type peSection struct {
+	name  string
+	chars uint32
+	data  []byte
+	// vsize is its size in memory, at least that of its data; va is its
+	// address, set as it is added, and off where its data goes in the
+	// file, set by layout.
+	vsize, va, off uint32
+	// nameOff is where its name is in the string table, if it is too
+	// long to go in its header.
+	nameOff uint32
+	relocs  []byte
+}
+
+// A peStrings is the string table of a PE file as it is built.
+type peStrings struct {
+	b []byte
+}
+
+// add adds name to the table and returns its offset, which counts the
+// length before the strings.
+func (t *peStrings) add(name string) uint32 {
+	off := uint32(4 + len(t.b))
+	t.b = append(t.b, name...)
+	t.b = append(t.b, 0)
+	return off
+}
+
+// peSeed writes one PE file.
+func peSeed(s *gen.State) []gen.File {
+	m := gen.Pick(s, peMachines...)
+	if s.Chance(badRate) {
+		m.id = gen.Pick[uint16](s, 0, 0x1234, 0x5a4d)
+	}
+	// An object file has no MS-DOS stub and no optional header.
+	object := s.Chance(0.2)
+
+	var secs []*peSection
+	va := uint32(peSectionAlign)
+	add := func(sec *peSection) *peSection {
+		sec.vsize = max(sec.vsize, uint32(len(sec.data)))
+		if !object {
+			sec.va = va
+			va += (sec.vsize + peSectionAlign - 1) &^ (peSectionAlign - 1)
+		}
+		secs = append(secs, sec)
+		return sec
+	}
+	text := add(&peSection{name: ".text", chars: scnCode | scnMemExecute | scnMemRead | scnAlign16, data: code(s)})
+	rdata, _ := strtab(symbolNames(s, s.Intn(8)))
+	add(&peSection{name: ".rdata", chars: scnData | scnMemRead | scnAlign16, data: rdata})
+	add(&peSection{name: ".data", chars: scnData | scnMemRead | scnMemWrite | scnAlign16, data: samples(s, s.Intn(64))})
+	if s.Chance(0.6) {
+		add(&peSection{name: ".bss", chars: scnBSS | scnMemRead | scnMemWrite | scnAlign16, vsize: gen.Pick[uint32](s, 8, 4096, 1<<20)})
+	}
+
+	var dirs [16][2]uint32
+	if !object && s.Chance(0.8) {
+		data, size := peImports(s, m, va)
+		idata := add(&peSection{name: ".idata", chars: scnData | scnMemRead | scnMemWrite, data: data})
+		dirs[dirImport] = [2]uint32{idata.va, size}
+		if s.Chance(badRate * 2) {
+			// An import directory at an address no section holds.
+			dirs[dirImport][0] = gen.Pick[uint32](s, 0, va, 1<<31, 1<<32-20)
+		}
+	}
+	if s.Chance(0.5) {
+		for _, d := range dwarfSections(s, binary.LittleEndian) {
+			add(&peSection{name: d.name, chars: scnData | scnDiscardable | scnMemRead | scnAlign1, data: d.data})
+		}
+	}
+	if s.Chance(0.1) {
+		add(&peSection{name: gen.Pick(s, ".reloc", ".rsrc", ".tls", ".pdata", ".xdata", ".very_long_section_name"), chars: scnData | scnMemRead, data: samples(s, s.Intn(100))})
+	}
+
+	// The COFF symbols, which an object has and an image may, and the
+	// string table after them, which also holds the long section names.
+	st := &peStrings{}
+	for _, sec := range secs {
+		if len(sec.name) > 8 || s.Chance(0.05) {
+			sec.nameOff = st.add(sec.name)
+		}
+	}
+	var syms []byte
+	nsyms := 0
+	if object || s.Chance(0.3) {
+		syms, nsyms = peSymbols(s, secs, st)
+	}
+	if object {
+		for _, sec := range secs {
+			if sec == text || s.Chance(0.3) {
+				sec.relocs = peRelocations(s, m, nsyms, len(sec.data))
+			}
+		}
+	}
+
+	f := &peFile{m: m, object: object, secs: secs, syms: syms, nsyms: nsyms, strs: st.b, dirs: dirs, imageSize: va}
+	return []gen.File{{Name: "input.exe", Data: f.layout(s)}}
+}
+
+// A peFile is a PE file before it is laid out.
+type peFile struct {
+	m      peMachine
+	object bool
+	secs   []*peSection
+	// syms are the COFF symbols, nsyms of them counting auxiliary
+	// records, and strs the string table after them, without its
+	// length.
+	syms      []byte
+	nsyms     int
+	strs      []byte
+	dirs      [16][2]uint32
+	imageSize uint32
+}
+
+// layout lays out the sections of f and writes it: the MS-DOS stub of an
+// image, the COFF header, the optional header, the section headers, the
+// section data, relocations, and the symbol and string tables.
+func (f *peFile) layout(s *gen.State) []byte {
+	le := binary.LittleEndian
+	var b []byte
+	if !f.object {
+		b = append(b, "MZ\x90\x00\x03\x00\x00\x00\x04\x00\x00\x00\xff\xff\x00\x00\xb8"...)
+		for len(b) < 0x3c {
+			b = append(b, 0)
+		}
+		lfanew := uint32(0x80)
+		if s.Chance(badRate * 2) {
+			lfanew = gen.Pick[uint32](s, 0x3c, 0x10000, 1<<31)
+		}
+		b = le.AppendUint32(b, lfanew)
+		b = append(b, "\x0e\x1f\xba\x0e\x00\xb4\x09\xcd\x21\xb8\x01\x4c\xcd\x21This program cannot be run in DOS mode.\r\r\n$"...)
+		for len(b) < 0x80 {
+			b = append(b, 0)
+		}
+		b = append(b, "PE\x00\x00"...)
+		if s.Chance(badRate) {
+			b[len(b)-2] = 'X'
+		}
+	}
+
+	optSize := 0
+	if !f.object {
+		optSize = 96 + 16*8
+		if f.m.is64 {
+			optSize = 112 + 16*8
+		}
+	}
+	headers := len(b) + 20 + optSize + len(f.secs)*peSectionHeader
+	off := uint32(headers+peFileAlign-1) &^ (peFileAlign - 1)
+	for _, sec := range f.secs {
+		if len(sec.data) == 0 {
+			continue
+		}
+		sec.off = off
+		off += uint32(len(sec.data)+peFileAlign-1) &^ (peFileAlign - 1)
+	}
+	relocStart := off
+	relocOffs := make([]uint32, len(f.secs))
+	for i, sec := range f.secs {
+		if len(sec.relocs) > 0 {
+			relocOffs[i] = off
+			off += uint32(len(sec.relocs))
+		}
+	}
+	symOff := uint32(0)
+	if f.nsyms > 0 || len(f.strs) > 0 {
+		symOff = off
+	}
+
+	// The COFF header.
+	nsecs, nsyms := len(f.secs), uint32(f.nsyms)
+	if s.Chance(badRate * 2) {
+		nsecs = gen.Pick(s, 0, nsecs+1, nsecs+100, 0xffff)
+	}
+	if f.nsyms > 0 && s.Chance(badRate*2) {
+		// A symbol table past the end of the file, or larger than it is.
+		switch s.Intn(2) {
+		case 0:
+			symOff = gen.Pick[uint32](s, off+1<<20, 1<<31, 1<<32-1)
+		default:
+			nsyms = gen.Pick[uint32](s, nsyms+1, nsyms+1000, 1<<28, 1<<32-1)
+		}
+	}
+	if s.Chance(badRate * 2) {
+		optSizeField := gen.Pick(s, 0, 1, optSize-8, optSize+8, 0xffff)
+		if f.object {
+			optSizeField = gen.Pick(s, 96+16*8, 2)
+		}
+		b = f.coffHeader(b, nsecs, symOff, nsyms, optSizeField)
+	} else {
+		b = f.coffHeader(b, nsecs, symOff, nsyms, optSize)
+	}
+	if !f.object {
+		b = f.optionalHeader(s, b, headers)
+	}
+
+	for i, sec := range f.secs {
+		var name [8]byte
+		if sec.nameOff == 0 {
+			copy(name[:], sec.name)
+		} else {
+			n := sec.nameOff
+			if s.Chance(badRate) {
+				n = gen.Pick[uint32](s, 1, uint32(len(f.strs)+5), 9999999)
+			}
+			copy(name[:], "/"+strconv.Itoa(int(n)))
+		}
+		b = append(b, name[:]...)
+		size, off := uint32(len(sec.data)+peFileAlign-1)&^(peFileAlign-1), sec.off
+		if s.Chance(badRate) {
+			// Data past the end of the file, or too large to read.
+			switch s.Intn(2) {
+			case 0:
+				off = gen.Pick[uint32](s, 1<<24, 1<<31, 1<<32-1)
+			default:
+				size = gen.Pick[uint32](s, size+peFileAlign, 1<<30, 1<<32-1)
+			}
+		}
+		nrelocs := uint16(len(sec.relocs) / peRelocSize)
+		if nrelocs > 0 && s.Chance(badRate) {
+			nrelocs = gen.Pick[uint16](s, nrelocs+1, 0xffff)
+		}
+		b = le.AppendUint32(b, sec.vsize)
+		b = le.AppendUint32(b, sec.va)
+		b = le.AppendUint32(b, size)
+		b = le.AppendUint32(b, off)
+		b = le.AppendUint32(b, relocOffs[i])
+		b = le.AppendUint32(b, 0)
+		b = le.AppendUint16(b, nrelocs)
+		b = le.AppendUint16(b, 0)
+		b = le.AppendUint32(b, sec.chars)
+	}
+
+	for _, sec := range f.secs {
+		if len(sec.data) == 0 {
+			continue
+		}
+		for uint32(len(b)) < sec.off {
+			b = append(b, 0)
+		}
+		b = append(b, sec.data...)
+	}
+	for uint32(len(b)) < relocStart {
+		b = append(b, 0)
+	}
+	for _, sec := range f.secs {
+		b = append(b, sec.relocs...)
+	}
+	if symOff != 0 {
+		b = append(b, f.syms...)
+		n := uint32(len(f.strs) + 4)
+		if s.Chance(badRate * 2) {
+			n = gen.Pick[uint32](s, 0, 3, n+1, n+1000, 1<<31, 1<<32-1)
+		}
+		b = le.AppendUint32(b, n)
+		b = append(b, f.strs...)
+	}
+	if s.Chance(badRate * 2) {
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return b
+}
+
+// coffHeader appends the COFF file header to b.
+func (f *peFile) coffHeader(b []byte, nsecs int, symOff, nsyms uint32, optSize int) []byte {
+	le := binary.LittleEndian
+	b = le.AppendUint16(b, f.m.id)
+	b = le.AppendUint16(b, uint16(nsecs))
+	b = le.AppendUint32(b, 0x5f000000) // time stamp
+	b = le.AppendUint32(b, symOff)
+	b = le.AppendUint32(b, nsyms)
+	b = le.AppendUint16(b, uint16(optSize))
+	chars := uint16(0)
+	if !f.object {
+		chars = 0x0002 | 0x0020 // executable, large address aware
+		if !f.m.is64 {
+			chars |= 0x0100 // 32-bit
+		}
+	}
+	return le.AppendUint16(b, chars)
+}
+
+// optionalHeader appends the optional header of an image, PE32 or PE32+,
+// with its data directories, now and then one whose magic or number of
+// directories is wrong.
+func (f *peFile) optionalHeader(s *gen.State, b []byte, headers int) []byte {
+	le := binary.LittleEndian
+	magic := uint16(0x10b)
+	if f.m.is64 {
+		magic = 0x20b
+	}
+	if s.Chance(badRate) {
+		magic = gen.Pick[uint16](s, 0, 0x107, 0x10b^0x20b^magic)
+	}
+	word := func(b []byte, v uint64) []byte {
+		if f.m.is64 {
+			return le.AppendUint64(b, v)
+		}
+		return le.AppendUint32(b, uint32(v))
+	}
+	b = le.AppendUint16(b, magic)
+	b = append(b, 14, 0) // linker version
+	b = le.AppendUint32(b, uint32(len(f.secs[0].data)))
+	b = le.AppendUint32(b, 0x1000)
+	b = le.AppendUint32(b, 0)
+	b = le.AppendUint32(b, f.secs[0].va) // entry point
+	b = le.AppendUint32(b, f.secs[0].va) // base of code
+	if !f.m.is64 {
+		b = le.AppendUint32(b, f.secs[1].va) // base of data
+	}
+	b = word(b, gen.Pick[uint64](s, 0x400000, 0x140000000, 0x10000000))
+	b = le.AppendUint32(b, peSectionAlign)
+	b = le.AppendUint32(b, peFileAlign)
+	b = le.AppendUint16(b, 6) // OS version
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint16(b, 0) // image version
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint16(b, 6) // subsystem version
+	b = le.AppendUint16(b, 0)
+	b = le.AppendUint32(b, 0)
+	b = le.AppendUint32(b, f.imageSize)
+	b = le.AppendUint32(b, uint32(headers+peFileAlign-1)&^(peFileAlign-1))
+	b = le.AppendUint32(b, 0)                         // checksum
+	b = le.AppendUint16(b, gen.Pick[uint16](s, 2, 3)) // GUI or console
+	b = le.AppendUint16(b, 0x8160)                    // DLL characteristics
+	b = word(b, 1<<20)
+	b = word(b, 0x1000)
+	b = word(b, 1<<20)
+	b = word(b, 0x1000)
+	b = le.AppendUint32(b, 0)
+	n := uint32(16)
+	if s.Chance(badRate) {
+		n = gen.Pick[uint32](s, 0, 15, 17, 1<<32-1)
+	}
+	b = le.AppendUint32(b, n)
+	for _, d := range f.dirs {
+		b = le.AppendUint32(b, d[0])
+		b = le.AppendUint32(b, d[1])
+	}
+	return b
+}
+
+// peImports returns an import directory, to be loaded at va, and the
+// size of its descriptors: for each DLL a descriptor, the lookup table
+// of the functions imported from it by name or ordinal, a copy of it for
+// the address table, their hints and names, and the DLL's name. Now and
+// then the directory does not end, or a name or table lies past the end
+// of the section.
+func peImports(s *gen.State, m peMachine, va uint32) ([]byte, uint32) {
+	le := binary.LittleEndian
+	dlls := gen.Pick(s, []string{"KERNEL32.dll"}, []string{"KERNEL32.dll", "USER32.dll", "msvcrt.dll"}, []string{"ntdll.dll", "ws2_32.dll"}, nil)
+	imports := make([][]string, len(dlls))
+	for i := range imports {
+		imports[i] = symbolNames(s, gen.Pick(s, 1, 2, 5, 20))
+	}
+	thunk := uint32(4)
+	if m.is64 {
+		thunk = 8
+	}
+	end := !s.Chance(badRate * 2)
+	ndesc := len(dlls)
+	if end {
+		ndesc++
+	}
+
+	// Where each part goes: the descriptors, then each DLL's tables,
+	// then the names.
+	off := uint32(20 * ndesc)
+	tables := make([]uint32, len(dlls))
+	for i, fns := range imports {
+		tables[i] = off
+		off += 2 * thunk * uint32(len(fns)+1)
+	}
+	var names []byte
+	nameAt := func(hint bool, name string) uint32 {
+		at := off + uint32(len(names))
+		if hint {
+			names = le.AppendUint16(names, uint16(s.Intn(1000)))
+		}
+		names = append(names, name...)
+		names = append(names, 0)
+		if len(names)%2 == 1 {
+			names = append(names, 0)
+		}
+		return at
+	}
+
+	var b, thunks []byte
+	for i, dll := range dlls {
+		lookup := va + tables[i]
+		name := nameAt(false, dll) + va
+		if s.Chance(badRate) {
+			// A name or lookup table past the end of the section.
+			switch s.Intn(2) {
+			case 0:
+				name = gen.Pick[uint32](s, va+1<<20, va-1, 1<<32-1)
+			default:
+				lookup = gen.Pick[uint32](s, va+1<<20, va-1, va+off-2)
+			}
+		}
+		b = le.AppendUint32(b, lookup)
+		b = le.AppendUint32(b, 0)
+		b = le.AppendUint32(b, 0)
+		b = le.AppendUint32(b, name)
+		b = le.AppendUint32(b, va+tables[i]+thunk*uint32(len(imports[i])+1))
+
+		var table []byte
+		for _, fn := range imports[i] {
+			var v uint64
+			if s.Chance(0.2) {
+				v = 1<<(8*thunk-1) | uint64(s.Intn(1<<16)) // by ordinal
+			} else {
+				v = uint64(nameAt(true, fn) + va)
+				if s.Chance(badRate) {
+					v = gen.Pick[uint64](s, 1<<20+uint64(va), 1)
+				}
+			}
+			if m.is64 {
+				table = le.AppendUint64(table, v)
+			} else {
+				table = le.AppendUint32(table, uint32(v))
+			}
+		}
+		table = append(table, make([]byte, thunk)...)
+		thunks = append(thunks, table...)
+		thunks = append(thunks, table...)
+	}
+	if end {
+		b = append(b, make([]byte, 20)...)
+	}
+	b = append(b, thunks...)
+	b = append(b, names...)
+	return b, uint32(20 * ndesc)
+}
+
+// peSymbols returns a COFF symbol table for secs and how many records it
+// has, with the names too long to go in a symbol added to st: a file
+// symbol, whose name is in its auxiliary records, a static symbol for
+// each section with an auxiliary record describing it, and functions and
+// data defined in those sections or imported. Now and then a symbol
+// claims more auxiliary records than there are, or is in a section that
+// is not there.
+func peSymbols(s *gen.State, secs []*peSection, st *peStrings) ([]byte, int) {
+	le := binary.LittleEndian
+	var b []byte
+	n := 0
+	sym := func(name string, value uint32, section int16, typ uint16, class, naux byte) {
+		var short [8]byte
+		if len(name) <= 8 {
+			copy(short[:], name)
+		} else {
+			le.PutUint32(short[4:], st.add(name))
+		}
+		b = append(b, short[:]...)
+		b = le.AppendUint32(b, value)
+		b = le.AppendUint16(b, uint16(section))
+		b = le.AppendUint16(b, typ)
+		b = append(b, class, naux)
+		n++
+	}
+	aux := func(data []byte) {
+		var rec [peSymbolSize]byte
+		copy(rec[:], data)
+		b = append(b, rec[:]...)
+		n++
+	}
+
+	if s.Chance(0.5) {
+		file := gen.Pick(s, "main.c", "a_rather_long_source_file_name.cpp")
+		nrec := (len(file) + peSymbolSize - 1) / peSymbolSize
+		sym(".file", 0, -2, 0, classFile, byte(nrec))
+		for i := range nrec {
+			aux([]byte(file[i*peSymbolSize : min(len(file), (i+1)*peSymbolSize)]))
+		}
+	}
+	for i, sec := range secs {
+		sym(sec.name, 0, int16(i+1), 0, classStatic, 1)
+		rec := le.AppendUint32(nil, uint32(len(sec.data)))
+		rec = le.AppendUint16(rec, uint16(len(sec.relocs)/peRelocSize))
+		rec = le.AppendUint16(rec, 0)
+		rec = le.AppendUint32(rec, 0) // checksum
+		rec = le.AppendUint16(rec, uint16(i+1))
+		rec = append(rec, byte(s.Intn(3)))
+		aux(rec)
+	}
+	for _, name := range symbolNames(s, gen.Pick(s, 0, 1, 5, 20, s.Range(50, 500))) {
+		section := int16(s.Range(0, min(len(secs), 3)))
+		if s.Chance(badRate) {
+			section = gen.Pick[int16](s, int16(len(secs)+1), 0x7fff, -3)
+		}
+		typ := gen.Pick[uint16](s, 0, 0x20)
+		sym(name, uint32(s.Intn(64)), section, typ, gen.Pick[byte](s, classExternal, classExternal, classStatic), 0)
+	}
+	if s.Chance(badRate) {
+		// More auxiliary records than there are symbols left.
+		b[len(b)-1] = gen.Pick[byte](s, 1, 5, 0xff)
+	}
+	return b, n
+}
+
+// peRelocations returns relocations for a section of n bytes against
+// symbols 0 to nsyms-1, of m's types and now and then others.
+func peRelocations(s *gen.State, m peMachine, nsyms, n int) []byte {
+	le := binary.LittleEndian
+	var b []byte
+	for range s.Range(1, 20) {
+		typ := gen.Pick(s, m.relocs...)
+		if s.Chance(0.05) {
+			typ = uint16(s.Intn(0x20))
+		}
+		sym := uint32(s.Intn(max(nsyms, 1)))
+		if s.Chance(badRate) {
+			sym = gen.Pick[uint32](s, uint32(nsyms), 1<<32-1)
+		}
+		b = le.AppendUint32(b, uint32(s.Intn(max(n, 1))))
+		b = le.AppendUint32(b, sym)
+		b = le.AppendUint16(b, typ)
+	}
+	return b
+}
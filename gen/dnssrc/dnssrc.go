@@ -0,0 +1,955 @@
+// Package dnssrc generates DNS seeds. It registers the "dns/..."
+// generators with package gen.
+//
+// "dns/message" writes one DNS message as it goes over UDP: a query, or
+// a response with answers, authority and additional records. Names are
+// compressed against the suffixes already written, now and then through
+// a pointer to a pointer or a chain of them longer than some decoders
+// follow; and, rarely, a name loops back on itself, points forward, into
+// the header or past the end, has a label of a reserved type or cut
+// short, or comes to more than 255 bytes. Records carry RDATA of some
+// twenty types, each malformed in its own ways now and then, and EDNS
+// OPT records carry options of their own; rdlengths, section counts and
+// where the message ends are rarely right.
+package dnssrc
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "dns/message",
+		Doc:  "DNS queries and responses: compressed names with pointer chains, loops and bad offsets, oversized labels and names, EDNS options, and RDATA of many types, malformed per type",
+		Func: message,
+	})
+}
+
+// badRate is the chance that a record's RDATA, an option or a length is
+// malformed. A message has a dozen or two of them, so about one in ten
+// has one.
+const badRate = 0.005
+
+// badName is the chance that a name is malformed, higher than badRate
+// since compression is where decoders go wrong most.
+const badName = 0.01
+
+// Record types.
+const (
+	typeA      = 1
+	typeNS     = 2
+	typeCNAME  = 5
+	typeSOA    = 6
+	typePTR    = 12
+	typeHINFO  = 13
+	typeMX     = 15
+	typeTXT    = 16
+	typeAAAA   = 28
+	typeLOC    = 29
+	typeSRV    = 33
+	typeNAPTR  = 35
+	typeDNAME  = 39
+	typeOPT    = 41
+	typeDS     = 43
+	typeSSHFP  = 44
+	typeRRSIG  = 46
+	typeNSEC   = 47
+	typeDNSKEY = 48
+	typeNSEC3  = 50
+	typeTLSA   = 52
+	typeSVCB   = 64
+	typeHTTPS  = 65
+	typeTSIG   = 250
+	typeIXFR   = 251
+	typeAXFR   = 252
+	typeANY    = 255
+	typeURI    = 256
+	typeCAA    = 257
+	typePriv   = 65280
+)
+
+// Classes.
+const (
+	classIN   = 1
+	classCH   = 3
+	classHS   = 4
+	classNONE = 254
+	classANY  = 255
+)
+
+// EDNS option codes.
+const (
+	optNSID      = 3
+	optECS       = 8
+	optExpire    = 9
+	optCookie    = 10
+	optKeepalive = 11
+	optPadding   = 12
+	optChain     = 13
+	optKeyTag    = 14
+	optEDE       = 15
+)
+
+var (
+	// answered are the types a response answers a query with.
+	answered = []int{
+		typeA, typeA, typeA, typeAAAA, typeAAAA, typeNS, typeCNAME, typeSOA, typePTR, typeMX, typeTXT, typeTXT,
+		typeSRV, typeNAPTR, typeDNAME, typeDS, typeDNSKEY, typeRRSIG, typeNSEC, typeNSEC3, typeTLSA, typeSSHFP,
+		typeSVCB, typeHTTPS, typeHTTPS, typeCAA, typeURI, typeLOC, typeHINFO, typePriv,
+	}
+	// queried are types only a query asks for, and ones no query should.
+	queried = []int{typeANY, typeAXFR, typeIXFR, typeOPT, typeTSIG, 0, 65535}
+
+	zones = [][]string{
+		{"example", "com"}, {"example", "org"}, {"test"}, {"xn--bcher-kva", "example"},
+		{"co", "uk"}, {"2", "0", "192", "in-addr", "arpa"}, {"Example", "COM"},
+	}
+	hosts = []string{"www", "mail", "ns1", "ns2", "api", "cdn-1", "_dmarc", "_443", "_tcp", "_sip", "*", "WWW", "a", "b"}
+	// odd are labels that are fine on the wire but hard to write as
+	// text: dots, spaces, quotes, backslashes, NULs and high bytes.
+	odd = []string{"a.b", "a b", `a\b`, "\x00", "\xff\xfe", "@", "$ORIGIN", `"q"`, "(", ";", "\t", "é"}
+)
+
+// An mgen writes one message.
+type mgen struct {
+	s *gen.State
+	b []byte
+	// suffixes maps each name suffix written, in wire form, to where a
+	// pointer to it points.
+	suffixes map[string]int
+	// names are the names the message has, for records to reuse.
+	names [][]string
+}
+
+// message writes one message.
+func message(s *gen.State) []gen.File {
+	m := &mgen{s: s, b: make([]byte, 12), suffixes: map[string]int{}}
+	m.b[0], m.b[1] = byte(s.Intn(256)), byte(s.Intn(256))
+	resp := s.Chance(0.6)
+	flags := 0
+	if resp {
+		flags |= 0x8000
+	}
+	if s.Chance(0.05) {
+		flags |= gen.Pick(s, 1, 2, 4, 5, 6, 15) << 11
+	}
+	if resp && s.Chance(0.5) {
+		flags |= 0x0400 // AA
+	}
+	if resp && s.Chance(0.05) {
+		flags |= 0x0200 // TC
+	}
+	if s.Chance(0.8) {
+		flags |= 0x0100 // RD
+	}
+	if resp && s.Chance(0.9) {
+		flags |= 0x0080 // RA
+	}
+	if s.Chance(0.02) {
+		flags |= 0x0040 // Z
+	}
+	if s.Chance(0.2) {
+		flags |= 0x0020 // AD
+	}
+	if s.Chance(0.1) {
+		flags |= 0x0010 // CD
+	}
+	rcode := 0
+	if resp {
+		switch {
+		case s.Chance(0.15):
+			rcode = 3 // NXDOMAIN
+		case s.Chance(0.05):
+			rcode = 2 // SERVFAIL
+		case s.Chance(0.03):
+			rcode = gen.Pick(s, 1, 4, 5, 6, 9, 10, 15)
+		}
+	}
+	flags |= rcode
+	m.b[2], m.b[3] = byte(flags>>8), byte(flags)
+
+	var counts [4]int
+	qname, qtype := m.hostname(), gen.Pick(s, answered...)
+	if s.Chance(0.05) {
+		qtype = gen.Pick(s, queried...)
+	}
+	counts[0] = 1
+	if s.Chance(0.05) {
+		counts[0] = gen.Pick(s, 0, 2, 3)
+	}
+	for i := range counts[0] {
+		if i > 0 {
+			qname = m.hostname()
+		}
+		m.question(qname, qtype)
+	}
+
+	if s.Chance(0.05) {
+		// A chain of pointers through the RDATA of a private type, which
+		// the names after it that end in its name follow.
+		m.chain(qname, gen.Pick(s, 2, 9, 10, 11, 12, 63, 126, 127, 200))
+		counts[1]++
+	}
+	if resp {
+		n := m.answers(qname, qtype, rcode)
+		counts[1] += n
+		counts[2] = m.authority(qname, qtype, n == 0)
+		counts[3] = m.additional()
+	}
+	if s.Chance(0.7) {
+		m.opt(resp)
+		counts[3]++
+		if s.Chance(0.02) {
+			m.opt(resp)
+			counts[3]++
+		}
+	}
+	if s.Chance(0.03) {
+		m.tsig()
+		counts[3]++
+	}
+
+	if s.Chance(badRate * 4) {
+		i := s.Intn(4)
+		counts[i] = gen.Pick(s, counts[i]+1, max(counts[i]-1, 0), 0, 0xffff)
+	}
+	for i, n := range counts {
+		m.b[4+2*i], m.b[5+2*i] = byte(n>>8), byte(n)
+	}
+	if s.Chance(badRate) {
+		m.b = m.b[:s.Intn(len(m.b))]
+	}
+	if s.Chance(badRate) {
+		m.b = append(m.b, m.bytes(s.Range(1, 16))...)
+	}
+	return []gen.File{{Name: "message.dns", Data: m.b}}
+}
+
+// question writes a question for name.
+func (m *mgen) question(name []string, typ int) {
+	class := classIN
+	if m.s.Chance(0.05) {
+		class = gen.Pick(m.s, classCH, classHS, classNONE, classANY, 0)
+	}
+	m.name(name, true)
+	m.u16(typ)
+	m.u16(class)
+}
+
+// answers writes the answers to a query for name of type typ, now and
+// then behind a CNAME or two, and returns how many it wrote.
+func (m *mgen) answers(name []string, typ, rcode int) int {
+	if rcode != 0 && !m.s.Chance(0.1) {
+		return 0
+	}
+	n := 0
+	if typ != typeCNAME && m.s.Chance(0.15) {
+		for range m.s.Range(1, 2) {
+			target := m.hostname()
+			m.record(name, typeCNAME, m.ttl(), func() { m.name(target, true) })
+			name = target
+			n++
+		}
+	}
+	if m.s.Chance(0.1) {
+		return n // NODATA
+	}
+	if !slices.Contains(answered, typ) {
+		typ = 0
+	}
+	for range m.s.Range(1, 4) {
+		t := typ
+		if t == 0 || m.s.Chance(0.05) {
+			t = gen.Pick(m.s, answered...)
+		}
+		m.record(name, t, m.ttl(), func() { m.rdata(t) })
+		n++
+	}
+	if m.s.Chance(0.2) {
+		m.record(name, typeRRSIG, m.ttl(), func() { m.rrsig(typ) })
+		n++
+	}
+	return n
+}
+
+// authority writes a zone's SOA for a response without answers, or now
+// and then its NS records, and returns how many it wrote.
+func (m *mgen) authority(name []string, typ int, empty bool) int {
+	zone := name[max(len(name)-2, 0):]
+	if empty || m.s.Chance(0.05) {
+		m.record(zone, typeSOA, m.ttl(), func() { m.rdata(typeSOA) })
+		return 1
+	}
+	if !m.s.Chance(0.3) {
+		return 0
+	}
+	n := m.s.Range(1, 3)
+	for range n {
+		m.record(zone, typeNS, m.ttl(), func() { m.rdata(typeNS) })
+	}
+	return n
+}
+
+// additional writes addresses for some of the names in the message, as
+// glue, and returns how many it wrote.
+func (m *mgen) additional() int {
+	n := m.s.Intn(4)
+	for range n {
+		name := gen.Pick(m.s, m.names...)
+		t := gen.Pick(m.s, typeA, typeA, typeAAAA)
+		m.record(name, t, m.ttl(), func() { m.rdata(t) })
+	}
+	return n
+}
+
+// ttl returns a TTL, now and then one with the high bit set.
+func (m *mgen) ttl() uint32 {
+	return gen.Pick[uint32](m.s, 300, 3600, 86400, 60, 0, 1, 0x7fffffff, 0x80000000, 0xffffffff, uint32(m.s.Uint64()))
+}
+
+// record writes a record of type typ owned by name, with RDATA written
+// by rdata and its length, now and then wrong, in front of it.
+func (m *mgen) record(name []string, typ int, ttl uint32, rdata func()) {
+	class := classIN
+	if m.s.Chance(0.02) {
+		class = gen.Pick(m.s, classCH, classHS, classNONE, classANY, 0, 0xffff)
+	}
+	m.name(name, true)
+	m.u16(typ)
+	m.u16(class)
+	m.u32(ttl)
+	m.rdlength(rdata)
+}
+
+// rdlength writes what rdata writes preceded by its length, now and
+// then a length past it or short of it.
+func (m *mgen) rdlength(rdata func()) {
+	at := len(m.b)
+	m.u16(0)
+	rdata()
+	n := len(m.b) - at - 2
+	if m.s.Chance(badRate) {
+		n = gen.Pick(m.s, n+1, n-1, 0, n+m.s.Range(2, 64), 0xffff)
+	}
+	m.b[at], m.b[at+1] = byte(n>>8), byte(n)
+}
+
+// rdata writes the RDATA of a record of type typ, now and then
+// malformed the way that type can be.
+func (m *mgen) rdata(typ int) {
+	s := m.s
+	bad := s.Chance(badRate)
+	switch typ {
+	case typeA:
+		n := 4
+		if bad {
+			n = gen.Pick(s, 3, 5, 0)
+		}
+		m.b = append(m.b, m.bytes(n)...)
+	case typeAAAA:
+		n := 16
+		if bad {
+			n = gen.Pick(s, 15, 17, 4)
+		}
+		m.b = append(m.b, m.bytes(n)...)
+	case typeNS, typeCNAME, typePTR, typeDNAME:
+		if bad && s.Chance(0.5) {
+			return
+		}
+		m.name(m.hostname(), typ != typeDNAME)
+		if bad {
+			m.b = append(m.b, 0)
+		}
+	case typeMX:
+		m.u16(gen.Pick(s, 10, 20, 0, 0xffff))
+		if bad {
+			m.b = m.b[:len(m.b)-1]
+			return
+		}
+		m.name(m.hostname(), true)
+	case typeSOA:
+		zone := gen.Pick(s, zones...)
+		m.name(append([]string{"ns1"}, zone...), true)
+		m.name(append([]string{gen.Pick(s, "hostmaster", "hostmaster", "dns", "dns", "admin.ops")}, zone...), true)
+		for range 5 {
+			m.u32(gen.Pick[uint32](s, 2024010101, 3600, 600, 604800, 0, 0xffffffff))
+		}
+		if bad {
+			m.b = m.b[:len(m.b)-s.Range(1, 20)]
+		}
+	case typeTXT:
+		if bad && s.Chance(0.5) {
+			return
+		}
+		for range s.Range(1, 3) {
+			m.str(gen.Pick(s, "v=spf1 include:_spf.example.com ~all", "v=DMARC1; p=none", "", "hello world",
+				strings.Repeat("t", 255), "k=v\x00\xff", `"quoted" \escaped`))
+		}
+		if bad {
+			m.b = append(m.b, byte(s.Range(1, 255)), 'x')
+		}
+	case typeHINFO:
+		m.str(gen.Pick(s, "INTEL-386", "RFC8482", ""))
+		if !bad {
+			m.str(gen.Pick(s, "UNIX", "Linux", ""))
+		}
+	case typeSRV:
+		m.u16(gen.Pick(s, 0, 10, 0xffff))
+		m.u16(gen.Pick(s, 0, 5, 60))
+		m.u16(gen.Pick(s, 443, 5060, 0, 0xffff))
+		// A target may not be compressed, but decoders take one that is.
+		m.name(gen.Pick(s, m.hostname(), nil), s.Chance(0.3))
+		if bad {
+			m.b = m.b[:len(m.b)-1]
+		}
+	case typeNAPTR:
+		m.u16(gen.Pick(s, 100, 10, 0))
+		m.u16(gen.Pick(s, 10, 50))
+		m.str(gen.Pick(s, "S", "A", "U", "", "P"))
+		m.str(gen.Pick(s, "SIP+D2U", "E2U+sip", "x-foo", ""))
+		re := gen.Pick(s, "!^.*$!sip:info@example.com!", "", `!^(.*)$!\1!i`, "!!!")
+		if bad {
+			m.b = append(m.b, byte(len(re)+s.Range(1, 50)))
+			m.b = append(m.b, re...)
+			return
+		}
+		m.str(re)
+		m.name(gen.Pick(s, m.hostname(), nil), false)
+	case typeDS:
+		m.u16(s.Intn(1 << 16))
+		m.b = append(m.b, byte(gen.Pick(s, 8, 13, 15, 5, 0)))
+		dt := gen.Pick(s, 1, 2, 2, 4)
+		n := map[int]int{1: 20, 2: 32, 4: 48}[dt]
+		if bad {
+			n = gen.Pick(s, 0, n-1, n+1, 64)
+		}
+		m.b = append(m.b, byte(dt))
+		m.b = append(m.b, m.bytes(n)...)
+	case typeDNSKEY:
+		m.u16(gen.Pick(s, 256, 257, 0, 0xffff))
+		if bad {
+			m.b = append(m.b, 3)
+			return
+		}
+		m.b = append(m.b, byte(gen.Pick(s, 3, 3, 3, 2)), byte(gen.Pick(s, 8, 13, 15, 5)))
+		m.b = append(m.b, m.bytes(gen.Pick(s, 32, 64, 260, 1))...)
+	case typeRRSIG:
+		m.rrsig(gen.Pick(s, answered...))
+	case typeNSEC:
+		m.name(gen.Pick(s, m.hostname(), nil), false)
+		m.bitmap(bad)
+	case typeNSEC3:
+		m.b = append(m.b, byte(gen.Pick(s, 1, 1, 0, 2)), byte(s.Intn(2)))
+		m.u16(gen.Pick(s, 0, 10, 150, 0xffff))
+		salt := m.bytes(gen.Pick(s, 0, 8, 255))
+		hash := m.bytes(gen.Pick(s, 20, 20, 1))
+		if bad {
+			m.b = append(m.b, byte(len(salt)+s.Range(1, 200)))
+			m.b = append(m.b, salt...)
+			return
+		}
+		m.b = append(append(m.b, byte(len(salt))), salt...)
+		m.b = append(append(m.b, byte(len(hash))), hash...)
+		m.bitmap(false)
+	case typeTLSA, typeSSHFP:
+		if typ == typeTLSA {
+			m.b = append(m.b, byte(gen.Pick(s, 3, 2, 0, 255)), byte(gen.Pick(s, 1, 0, 2)))
+		} else {
+			m.b = append(m.b, byte(gen.Pick(s, 1, 3, 4, 6, 0)))
+		}
+		m.b = append(m.b, byte(gen.Pick(s, 1, 2, 0)))
+		if !bad {
+			m.b = append(m.b, m.bytes(gen.Pick(s, 32, 20, 64, 1))...)
+		}
+	case typeSVCB, typeHTTPS:
+		m.svcb(bad)
+	case typeCAA:
+		m.b = append(m.b, byte(gen.Pick(s, 0, 0, 128, 1)))
+		tag := gen.Pick(s, "issue", "issuewild", "iodef", "contactemail", "Issue")
+		switch {
+		case !bad:
+			m.str(tag)
+		case s.Chance(0.5):
+			m.b = append(m.b, 0)
+		default:
+			m.b = append(m.b, byte(gen.Pick(s, 16, 255)))
+		}
+		m.b = append(m.b, gen.Pick(s, "letsencrypt.org", "ca.example.net; account=230123", "mailto:security@example.com", "", ";")...)
+	case typeURI:
+		m.u16(gen.Pick(s, 10, 1, 0))
+		m.u16(gen.Pick(s, 1, 0, 0xffff))
+		if !bad {
+			m.b = append(m.b, gen.Pick(s, "https://www.example.com/path", "ftp://ftp1.example.com/public", "x")...)
+		}
+	case typeLOC:
+		version := 0
+		if bad {
+			version = gen.Pick(s, 1, 255)
+		}
+		m.b = append(m.b, byte(version), 0x12, 0x16, 0x13)
+		m.u32(gen.Pick[uint32](s, 0x8b3556c8, 0x80000000, 0, 0xffffffff))
+		m.u32(gen.Pick[uint32](s, 0x7f2b6e38, 0x80000000, 0, 0xffffffff))
+		m.u32(gen.Pick[uint32](s, 0x00989680, 0, 0xffffffff))
+	default:
+		m.b = append(m.b, m.bytes(gen.Pick(s, 0, 1, 4, 16, 100))...)
+	}
+}
+
+// rrsig writes the RDATA of an RRSIG record covering typ.
+func (m *mgen) rrsig(typ int) {
+	s := m.s
+	m.u16(typ)
+	m.b = append(m.b, byte(gen.Pick(s, 8, 13, 15, 5, 0)), byte(gen.Pick(s, 2, 3, 0, 255)))
+	m.u32(m.ttl())
+	m.u32(gen.Pick[uint32](s, 1767225600, 1735689600, 0, 0xffffffff))
+	m.u32(gen.Pick[uint32](s, 1735689600, 1767225600, 0))
+	m.u16(s.Intn(1 << 16))
+	if s.Chance(badRate) {
+		return
+	}
+	// The signer may not be compressed either.
+	m.name(gen.Pick(s, zones...), s.Chance(0.1))
+	m.b = append(m.b, m.bytes(gen.Pick(s, 64, 256, 0, 1))...)
+}
+
+// bitmap writes an NSEC type bitmap of a few types, or, if bad, one
+// whose windows are out of order, repeated, empty or too long.
+func (m *mgen) bitmap(bad bool) {
+	windows := map[int][]byte{}
+	for range m.s.Range(1, 6) {
+		t := gen.Pick(m.s, answered...)
+		if m.s.Chance(0.1) {
+			t = gen.Pick(m.s, typeOPT, typeTSIG, 0, 65535)
+		}
+		w := windows[t>>8]
+		if len(w) <= t&0xff>>3 {
+			w = append(w, make([]byte, t&0xff>>3+1-len(w))...)
+		}
+		w[t&0xff>>3] |= 0x80 >> (t & 7)
+		windows[t>>8] = w
+	}
+	keys := slices.Sorted(maps.Keys(windows))
+	if bad {
+		switch m.s.Intn(4) {
+		case 0:
+			slices.Reverse(keys)
+			keys = append(keys, 0)
+		case 1:
+			keys = append(keys, keys[0])
+		case 2:
+			windows[keys[0]] = nil
+		default:
+			windows[keys[0]] = m.bytes(33)
+		}
+	}
+	for _, k := range keys {
+		w := windows[k]
+		m.b = append(m.b, byte(k), byte(len(w)))
+		m.b = append(m.b, w...)
+	}
+}
+
+// SVCB parameter keys.
+const (
+	keyMandatory = iota
+	keyALPN
+	keyNoDefaultALPN
+	keyPort
+	keyIPv4Hint
+	keyECH
+	keyIPv6Hint
+	keyDoHPath
+)
+
+// svcb writes the RDATA of an SVCB or HTTPS record: an alias, or a
+// service with parameters in order. If bad, the parameters are out of
+// order or repeated, an alias has them, or one has a value wrong for its
+// key.
+func (m *mgen) svcb(bad bool) {
+	s := m.s
+	alias := s.Chance(0.2)
+	if alias {
+		m.u16(0)
+		m.name(m.hostname(), false)
+		if !bad {
+			return
+		}
+	} else {
+		m.u16(gen.Pick(s, 1, 1, 2, 0xffff))
+		m.name(gen.Pick(s, nil, m.hostname()), false)
+	}
+	type param struct {
+		key   int
+		value []byte
+	}
+	var params []param
+	for _, key := range []int{keyMandatory, keyALPN, keyNoDefaultALPN, keyPort, keyIPv4Hint, keyECH, keyIPv6Hint, keyDoHPath, 65000} {
+		if !s.Chance(0.3) {
+			continue
+		}
+		var v []byte
+		switch key {
+		case keyMandatory:
+			v = []byte{0, keyALPN, 0, keyPort}
+		case keyALPN:
+			for range s.Range(1, 3) {
+				id := gen.Pick(s, "h2", "h3", "http/1.1", "h3-29")
+				v = append(append(v, byte(len(id))), id...)
+			}
+		case keyPort:
+			v = []byte{0x01, 0xbb}
+		case keyIPv4Hint:
+			v = m.bytes(4 * s.Range(1, 3))
+		case keyIPv6Hint:
+			v = m.bytes(16 * s.Range(1, 2))
+		case keyECH:
+			v = m.bytes(gen.Pick(s, 0, 64))
+		case keyDoHPath:
+			v = []byte("/dns-query{?dns}")
+		}
+		params = append(params, param{key, v})
+	}
+	if bad && len(params) > 0 {
+		i := s.Intn(len(params))
+		switch s.Intn(5) {
+		case 0:
+			slices.Reverse(params)
+			params = append(params, param{keyALPN, []byte{2, 'h', '2'}}, param{keyMandatory, nil})
+		case 1:
+			params = slices.Insert(params, i, params[i])
+		case 2:
+			params[i].value = append(params[i].value, 0)
+		case 3:
+			params = append(params, param{keyMandatory, []byte{0, keyMandatory}})
+		default:
+			params[i].value = []byte{0}
+		}
+	}
+	for _, p := range params {
+		m.u16(p.key)
+		m.u16(len(p.value))
+		m.b = append(m.b, p.value...)
+	}
+}
+
+// opt writes an EDNS OPT record, for a query or a response: the UDP
+// size, DO bit and options a client or server sends, and, rarely, an
+// owner other than the root, a version past 0 or an option malformed.
+func (m *mgen) opt(resp bool) {
+	s := m.s
+	if s.Chance(badRate) {
+		m.name(m.hostname(), true)
+	} else {
+		m.b = append(m.b, 0)
+	}
+	m.u16(typeOPT)
+	m.u16(gen.Pick(s, 1232, 4096, 512, 1400, 65535, 0))
+	ext := 0
+	if s.Chance(0.03) {
+		ext = gen.Pick(s, 1, 2, 255) // BADVERS and beyond
+	}
+	version := 0
+	if s.Chance(badRate) {
+		version = gen.Pick(s, 1, 255)
+	}
+	flags := 0
+	if s.Chance(0.4) {
+		flags |= 0x8000
+	}
+	if s.Chance(0.02) {
+		flags |= s.Intn(0x8000)
+	}
+	m.b = append(m.b, byte(ext), byte(version))
+	m.u16(flags)
+	m.rdlength(func() {
+		for range s.Intn(4) {
+			m.option(resp)
+		}
+	})
+}
+
+// option writes an EDNS option.
+func (m *mgen) option(resp bool) {
+	s := m.s
+	bad := s.Chance(badRate)
+	code := gen.Pick(s, optNSID, optECS, optECS, optCookie, optCookie, optKeepalive, optPadding, optEDE,
+		optExpire, optChain, optKeyTag, 65001, 0)
+	var v []byte
+	switch code {
+	case optNSID:
+		if resp {
+			v = []byte(gen.Pick(s, "ns1.example", "\x00\xff", "a"))
+		}
+	case optECS:
+		family := gen.Pick(s, 1, 1, 2)
+		source := gen.Pick(s, 24, 32, 0, 56, 64, 128)
+		if family == 1 {
+			source = min(source, 32)
+		}
+		scope := 0
+		if resp {
+			scope = gen.Pick(s, 0, source, 16)
+		}
+		addr := m.bytes((source + 7) / 8)
+		if len(addr) > 0 && source%8 != 0 {
+			addr[len(addr)-1] &= byte(0xff << (8 - source%8))
+		}
+		if bad {
+			switch s.Intn(4) {
+			case 0:
+				family = gen.Pick(s, 0, 3, 0xffff)
+			case 1:
+				source = gen.Pick(s, 33, 129, 255)
+			case 2:
+				addr = append(addr, 0xff)
+			default:
+				addr = append(addr[:max(len(addr)-1, 0)], 0x01)
+			}
+		}
+		v = slices.Concat(u16(family), []byte{byte(source), byte(scope)}, addr)
+	case optCookie:
+		n := 8
+		if resp {
+			n += gen.Pick(s, 8, 16, 32)
+		}
+		if bad {
+			n = gen.Pick(s, 0, 7, 9, 15, 41)
+		}
+		v = m.bytes(n)
+	case optKeepalive:
+		switch {
+		case bad:
+			v = m.bytes(gen.Pick(s, 1, 3))
+		case resp:
+			v = m.bytes(2)
+		}
+	case optPadding:
+		v = make([]byte, gen.Pick(s, 0, 1, 64, 400))
+		if bad && len(v) > 0 {
+			v[0] = 0xff
+		}
+	case optEDE:
+		v = slices.Concat(u16(gen.Pick(s, 0, 6, 18, 22, 0xffff)), []byte(gen.Pick(s, "", "no reachable authority", "\xff\xfe", "DNSSEC Bogus")))
+		if bad {
+			v = v[:1]
+		}
+	case optExpire:
+		if resp {
+			v = m.bytes(4)
+		}
+	case optChain:
+		v = wire(gen.Pick(s, zones...))
+	case optKeyTag:
+		v = m.bytes(2 * s.Range(1, 3))
+	default:
+		v = m.bytes(s.Intn(16))
+	}
+	m.u16(code)
+	n := len(v)
+	if bad && s.Chance(0.5) {
+		n = gen.Pick(s, n+1, n+100, 0xffff)
+	}
+	m.u16(n)
+	m.b = append(m.b, v...)
+}
+
+// tsig writes a TSIG record, which must come last in a message.
+func (m *mgen) tsig() {
+	s := m.s
+	m.name([]string{"key", "example", "com"}, true)
+	m.u16(typeTSIG)
+	m.u16(classANY)
+	m.u32(0)
+	m.rdlength(func() {
+		m.name(gen.Pick(s, []string{"hmac-sha256"}, []string{"hmac-md5", "sig-alg", "reg", "int"}, []string{"x"}), false)
+		m.u16(0)
+		m.u32(uint32(s.Uint64()))
+		m.u16(300)
+		mac := m.bytes(gen.Pick(s, 32, 16, 0))
+		n := len(mac)
+		if s.Chance(badRate) {
+			n += s.Range(1, 200)
+		}
+		m.u16(n)
+		m.b = append(m.b, mac...)
+		m.b = append(m.b, m.b[0], m.b[1])
+		m.u16(gen.Pick(s, 0, 16, 18))
+		m.u16(0)
+	})
+}
+
+// hostname returns a name in one of the zones, with up to three labels
+// in front of it: now and then one that is hard to write as text, or
+// one as long as a label may be.
+func (m *mgen) hostname() []string {
+	s := m.s
+	var labels []string
+	for range s.Intn(4) {
+		l := gen.Pick(s, hosts...)
+		switch {
+		case s.Chance(0.03):
+			l = gen.Pick(s, odd...)
+		case s.Chance(0.03):
+			l = strings.Repeat(gen.Pick(s, "a", "z", "-"), 63)
+		}
+		labels = append(labels, l)
+	}
+	name := append(labels, gen.Pick(s, zones...)...)
+	if s.Chance(0.03) {
+		name = nil // the root
+	}
+	m.names = append(m.names, name)
+	return name
+}
+
+// name writes the name of labels, if compress compressed against the
+// suffixes already written, or, rarely, a malformed one.
+func (m *mgen) name(labels []string, compress bool) {
+	if m.s.Chance(badName) {
+		m.badName(labels)
+		return
+	}
+	for i := range labels {
+		key := string(wire(labels[i:]))
+		if off, ok := m.suffixes[key]; ok && compress && !m.s.Chance(0.1) {
+			if m.s.Chance(0.2) && len(m.b) < 0x4000 {
+				// Later names point to this pointer, which points on.
+				m.suffixes[key] = len(m.b)
+			}
+			m.pointer(off)
+			return
+		}
+		if len(m.b) < 0x4000 {
+			m.suffixes[key] = len(m.b)
+		}
+		m.b = append(m.b, byte(len(labels[i])))
+		m.b = append(m.b, labels[i]...)
+	}
+	m.b = append(m.b, 0)
+}
+
+// badName writes a malformed name, in some way not, as names go, of
+// labels.
+func (m *mgen) badName(labels []string) {
+	s := m.s
+	if s.Chance(0.5) {
+		m.b = append(m.b, wire(labels[:s.Intn(len(labels)+1)])...)
+		m.b = m.b[:len(m.b)-1]
+	}
+	switch s.Intn(9) {
+	case 0:
+		// A pointer to itself.
+		m.pointer(len(m.b))
+	case 1:
+		// A label and a pointer back to it, over and over.
+		at := len(m.b)
+		m.b = append(m.b, 1, 'a')
+		m.pointer(at)
+	case 2:
+		// A pointer forward, to what comes after the name.
+		m.pointer(len(m.b) + 2 + s.Intn(16))
+	case 3:
+		// A pointer into the header.
+		m.pointer(s.Intn(12))
+	case 4:
+		// A pointer past the end of any message.
+		m.pointer(gen.Pick(s, 0x3fff, len(m.b)+1000))
+	case 5:
+		// A label of a reserved type.
+		m.b = append(m.b, byte(gen.Pick(s, 0x40, 0x41, 0x80, 0xbf)), 'x', 0)
+	case 6:
+		// A label longer than what follows it.
+		m.b = append(m.b, 63)
+		m.b = append(m.b, "short"...)
+	case 7:
+		// A name past 255 bytes, in labels of 63.
+		for range s.Range(4, 5) {
+			m.b = append(m.b, 63)
+			m.b = append(m.b, strings.Repeat("x", 63)...)
+		}
+		m.b = append(m.b, 0)
+	default:
+		// Long labels in front of a suffix already written, which together
+		// may come to more than 255 bytes.
+		for range s.Range(2, 3) {
+			m.b = append(m.b, 63)
+			m.b = append(m.b, strings.Repeat("y", 63)...)
+		}
+		if len(m.suffixes) == 0 {
+			m.b = append(m.b, 0)
+			return
+		}
+		offs := make([]int, 0, len(m.suffixes))
+		for _, off := range m.suffixes {
+			offs = append(offs, off)
+		}
+		slices.Sort(offs)
+		m.pointer(gen.Pick(s, offs...))
+	}
+}
+
+// chain writes a record of a private type owned by name whose RDATA is
+// n pointers, each to the one before it and the first to name, and
+// makes names ending in name point to the last, so that they follow all
+// n. It is how a message gets past the pointers a decoder follows
+// without a loop.
+func (m *mgen) chain(name []string, n int) {
+	m.name(name, true)
+	m.u16(typePriv)
+	m.u16(classIN)
+	m.u32(0)
+	key := string(wire(name))
+	m.rdlength(func() {
+		off, ok := m.suffixes[key]
+		if !ok {
+			return
+		}
+		for range n {
+			if len(m.b) >= 0x4000 {
+				break
+			}
+			at := len(m.b)
+			m.pointer(off)
+			off = at
+		}
+		m.suffixes[key] = off
+	})
+}
+
+// pointer writes a compression pointer to off.
+func (m *mgen) pointer(off int) {
+	m.b = append(m.b, 0xc0|byte(off>>8&0x3f), byte(off))
+}
+
+// wire returns the name of labels in wire form, uncompressed.
+func wire(labels []string) []byte {
+	var b []byte
+	for _, l := range labels {
+		b = append(append(b, byte(len(l))), l...)
+	}
+	return append(b, 0)
+}
+
+// str writes a character string.
+func (m *mgen) str(v string) {
+	m.b = append(append(m.b, byte(len(v))), v...)
+}
+
+// bytes returns n random bytes.
+func (m *mgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(m.s.Intn(256))
+	}
+	return b
+}
+
+func (m *mgen) u16(v int)    { m.b = append(m.b, u16(v)...) }
+func (m *mgen) u32(v uint32) { m.b = append(m.b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v)) }
+
+// u16 returns v big-endian in two bytes.
+func u16(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
@@ -0,0 +1,728 @@
+package fontsrc
+
+import (
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "font/otf",
+		Doc:  "OpenType fonts with CFF outlines: Type 2 charstrings with widths, stem hints and hint masks, every line and curve operator, and local and global subroutines, a Private DICT, or a CID-keyed font with an FDArray and FDSelect, with subroutines that call themselves or fan out into millions of segments, operands past the stack, charstrings that never end, and INDEXes with bad offsets",
+		Func: otfSeed,
+	})
+}
+
+func otfSeed(s *gen.State) []gen.File {
+	f := newFace(s, numGlyphs(s))
+	return []gen.File{{Name: "input.otf", Data: fontFile(s, f.openType())}}
+}
+
+// openType returns a font of f with CFF outlines.
+func (f *face) openType() *font {
+	// The outlines are not followed point by point, so the bounds are
+	// those of a typical glyph.
+	f.bbox = [4]int{-f.upem / 10, -f.upem / 5, f.upem, f.upem * 4 / 5}
+	ts := append([]*table{{"CFF ", f.cff()}}, f.common(true)...)
+	return &font{version: "OTTO", tables: ts}
+}
+
+// A csOp is an operator of a charstring with its operands, or, if call
+// is set, a call to a subroutine, whose number is known only once every
+// subroutine is.
+type csOp struct {
+	data []byte
+	call *subr
+	// path is set for the operators that move and draw, which may go
+	// into a subroutine anywhere after the first; hints and hint masks
+	// stay where they are, since a mask's length depends on the hints
+	// before it.
+	path bool
+}
+
+// A subr is a subroutine: its operators, whether it is global or local,
+// and, once numbered, its index in its INDEX.
+type subr struct {
+	ops    []csOp
+	global bool
+	index  int
+}
+
+// Charstring operators, with the escaped ones as 12 and the second byte.
+const (
+	csHstem      = 1
+	csVstem      = 3
+	csVmoveto    = 4
+	csRlineto    = 5
+	csHlineto    = 6
+	csVlineto    = 7
+	csRrcurveto  = 8
+	csCallsubr   = 10
+	csReturn     = 11
+	csEndchar    = 14
+	csHstemhm    = 18
+	csHintmask   = 19
+	csCntrmask   = 20
+	csRmoveto    = 21
+	csHmoveto    = 22
+	csVstemhm    = 23
+	csRcurveline = 24
+	csRlinecurve = 25
+	csVvcurveto  = 26
+	csHhcurveto  = 27
+	csCallgsubr  = 29
+	csVhcurveto  = 30
+	csHvcurveto  = 31
+	csHflex      = 12<<8 | 34
+	csFlex       = 12<<8 | 35
+	csHflex1     = 12<<8 | 36
+	csFlex1      = 12<<8 | 37
+)
+
+// operator appends the operator op, escaped if it is two bytes.
+func operator(b []byte, op int) []byte {
+	if op > 0xff {
+		return append(b, byte(op>>8), byte(op))
+	}
+	return append(b, byte(op))
+}
+
+// csNum appends v as a charstring operand, in the shortest encoding or
+// now and then a longer one: a 16-bit integer or a 16.16 fixed number.
+func csNum(s *gen.State, b []byte, v int) []byte {
+	switch {
+	case s.Chance(0.03) && v >= -0x8000 && v <= 0x7fff:
+		return u32(append(b, 255), v<<16)
+	case s.Chance(0.03), v < -1131 || v > 1131:
+		return u16(append(b, 28), v)
+	}
+	return dictInt(b, v)
+}
+
+// cs returns an operator of a charstring with its operands.
+func (f *face) cs(args []int, op int) []byte {
+	var b []byte
+	for _, a := range args {
+		b = csNum(f.s, b, a)
+	}
+	return operator(b, op)
+}
+
+// charstring returns the operators of a glyph: now and then a width
+// before the first, stem hints, with or without hint masks, contours
+// each started by a move and drawn by lines and curves, and endchar. A
+// few break it: operands past the 48 the stack holds, no endchar, an
+// endchar that is seac's, and operators no reader knows.
+func (f *face) charstring() []csOp {
+	s := f.s
+	var ops []csOp
+	width := s.Chance(0.5)
+	first := func(args []int) []int {
+		if width {
+			width = false
+			return append([]int{s.Range(-f.upem/2, f.upem)}, args...)
+		}
+		return args
+	}
+	stems := func(n int) []int {
+		var args []int
+		for range n {
+			args = append(args, s.Range(0, f.upem/2), s.Range(1, max(f.upem/8, 1)))
+		}
+		return args
+	}
+
+	hints := 0
+	masks := s.Chance(0.4)
+	hstem, vstem := csHstem, csVstem
+	if masks {
+		hstem, vstem = csHstemhm, csVstemhm
+	}
+	if n := gen.Pick(s, 0, 0, 1, 2, 3, s.Range(4, 12)); n > 0 {
+		ops = append(ops, csOp{data: f.cs(first(stems(n)), hstem)})
+		hints += n
+	}
+	var implicit []int
+	if n := gen.Pick(s, 0, 0, 1, 2, 3, s.Range(4, 12)); n > 0 {
+		if masks && s.Chance(0.3) {
+			// vstems given to the first hintmask rather than by vstem.
+			implicit = first(stems(n))
+		} else {
+			ops = append(ops, csOp{data: f.cs(first(stems(n)), vstem)})
+		}
+		hints += n
+	}
+	mask := func(op int) csOp {
+		b := f.cs(implicit, op)
+		implicit = nil
+		return csOp{data: append(b, samples(s, (hints+7)/8)...)}
+	}
+	if masks && hints > 0 {
+		ops = append(ops, mask(csHintmask))
+	}
+
+	for c := range gen.Pick(s, 0, 1, 1, 2, 3, s.Range(1, 8)) {
+		if c > 0 && masks && hints > 0 && s.Chance(0.3) {
+			ops = append(ops, mask(gen.Pick(s, csHintmask, csHintmask, csCntrmask)))
+		}
+		switch s.Intn(3) {
+		case 0:
+			ops = append(ops, csOp{data: f.cs(first([]int{f.delta(), f.delta()}), csRmoveto), path: true})
+		case 1:
+			ops = append(ops, csOp{data: f.cs(first([]int{f.delta()}), csHmoveto), path: true})
+		default:
+			ops = append(ops, csOp{data: f.cs(first([]int{f.delta()}), csVmoveto), path: true})
+		}
+		for range s.Range(1, 6) {
+			ops = append(ops, csOp{data: f.segment(), path: true})
+		}
+	}
+	switch {
+	case f.bad():
+		ops = append(ops, csOp{data: f.cs(first([]int{0, 0, 65, 97}), csEndchar)})
+	case f.bad() && len(ops) > 0:
+		// No endchar: the charstring runs off its end.
+	case f.bad():
+		args := make([]int, gen.Pick(s, 49, 100, 48))
+		for i := range args {
+			args[i] = f.delta()
+		}
+		ops = append(ops, csOp{data: f.cs(args, csRlineto)}, csOp{data: f.cs(nil, csEndchar)})
+	case f.bad():
+		ops = append(ops, csOp{data: gen.Pick(s, []byte{9}, []byte{12, 0}, []byte{csReturn}, []byte{12, 99})}, csOp{data: f.cs(nil, csEndchar)})
+	default:
+		ops = append(ops, csOp{data: f.cs(first(nil), csEndchar)})
+	}
+	return ops
+}
+
+// delta returns how far a point of an outline is from the one before.
+func (f *face) delta() int {
+	if f.s.Chance(0.1) {
+		return 0
+	}
+	return f.s.Range(-f.upem/3, f.upem/3)
+}
+
+// segment returns an operator drawing lines or curves, with as many
+// operands as it takes for one to a few of them.
+func (f *face) segment() []byte {
+	s := f.s
+	args := func(n int) []int {
+		a := make([]int, n)
+		for i := range a {
+			a[i] = f.delta()
+		}
+		return a
+	}
+	n := s.Range(1, 4)
+	if s.Chance(0.01) {
+		// flex and flex1, which not every reader supports.
+		if s.Chance(0.5) {
+			return f.cs(append(args(12), 50), csFlex)
+		}
+		return f.cs(args(11), csFlex1)
+	}
+	switch s.Intn(12) {
+	case 0:
+		return f.cs(args(2*n), csRlineto)
+	case 1:
+		return f.cs(args(n), csHlineto)
+	case 2:
+		return f.cs(args(n), csVlineto)
+	case 3:
+		return f.cs(args(6*n), csRrcurveto)
+	case 4:
+		return f.cs(args(4*n+s.Intn(2)), csHhcurveto)
+	case 5:
+		return f.cs(args(4*n+s.Intn(2)), csVvcurveto)
+	case 6:
+		return f.cs(args(4*n+s.Intn(2)), csHvcurveto)
+	case 7:
+		return f.cs(args(4*n+s.Intn(2)), csVhcurveto)
+	case 8:
+		return f.cs(args(6*n+2), csRcurveline)
+	case 9:
+		return f.cs(args(2*n+6), csRlinecurve)
+	case 10:
+		return f.cs(args(7), csHflex)
+	}
+	return f.cs(args(9), csHflex1)
+}
+
+// subroutines moves runs of the operators of charstrings into
+// subroutines, local ones into the locals of the Font DICT fd gives
+// each charstring, and global ones, shared by all, into globals. Some
+// subroutines call others, and some are called from several glyphs. A
+// few, once in a font, call themselves, call each other, or call the
+// next of a chain of them many times over, so that a glyph draws
+// millions of lines.
+func (f *face) subroutines(charstrings [][]csOp, fd []int, locals [][]*subr) (globals []*subr, _ [][]*subr) {
+	s := f.s
+	var shared []*subr
+	add := func(sub *subr, fd int) {
+		if sub.global {
+			globals = append(globals, sub)
+		} else {
+			locals[fd] = append(locals[fd], sub)
+		}
+	}
+	// extract moves a run of ops into a subroutine, global if global is
+	// set: a global subroutine is called from glyphs of any Font DICT,
+	// and so calls only other global ones.
+	var extract func(ops []csOp, fd, depth int, global bool) []csOp
+	extract = func(ops []csOp, fd, depth int, global bool) []csOp {
+		i, j := pathRun(s, ops)
+		if i == j {
+			return ops
+		}
+		sub := &subr{ops: append([]csOp(nil), ops[i:j]...), global: global || s.Chance(0.4)}
+		if depth < 3 && s.Chance(0.3) {
+			sub.ops = extract(sub.ops, fd, depth+1, sub.global)
+		}
+		sub.ops = append(sub.ops, csOp{data: []byte{csReturn}})
+		add(sub, fd)
+		if sub.global {
+			shared = append(shared, sub)
+		}
+		call := csOp{call: sub, path: true}
+		return append(append(append([]csOp(nil), ops[:i]...), call), ops[j:]...)
+	}
+	for g, ops := range charstrings {
+		if s.Chance(0.4) {
+			charstrings[g] = extract(ops, fd[g], 0, false)
+		}
+	}
+	for g, ops := range charstrings {
+		if len(shared) > 0 && len(ops) > 1 && ops[len(ops)-2].path && s.Chance(0.1) {
+			// A global subroutine of another glyph's, drawn at the end of
+			// this one's last contour.
+			call := csOp{call: gen.Pick(s, shared...), path: true}
+			charstrings[g] = append(ops[:len(ops)-1:len(ops)-1], call, ops[len(ops)-1])
+		}
+	}
+
+	if len(charstrings) == 0 {
+		return globals, locals
+	}
+	g := s.Intn(len(charstrings))
+	global := s.Chance(0.5)
+	var loop *subr
+	switch {
+	case s.Chance(0.03):
+		loop = &subr{global: global}
+		loop.ops = []csOp{{data: f.cs([]int{f.delta(), f.delta()}, csRlineto)}, {call: loop}, {data: []byte{csReturn}}}
+		add(loop, fd[g])
+	case s.Chance(0.03):
+		loop = &subr{global: global}
+		other := &subr{global: global, ops: []csOp{{call: loop}, {data: []byte{csReturn}}}}
+		loop.ops = []csOp{{call: other}, {data: []byte{csReturn}}}
+		add(loop, fd[g])
+		add(other, fd[g])
+	case s.Chance(0.03):
+		// Each subroutine of the chain calls the next k times, within the
+		// ten calls deep a reader allows, for up to two million lines.
+		shape := gen.Pick(s, [2]int{3, 16}, [2]int{9, 2}, [2]int{9, 4}, [2]int{6, 16}, [2]int{8, 8})
+		levels, k := shape[0], shape[1]
+		last := &subr{global: global, ops: []csOp{{data: f.cs([]int{f.delta(), f.delta()}, csRlineto)}, {data: []byte{csReturn}}}}
+		add(last, fd[g])
+		loop = last
+		for range levels - 1 {
+			next := &subr{global: global}
+			for range k {
+				next.ops = append(next.ops, csOp{call: loop})
+			}
+			next.ops = append(next.ops, csOp{data: []byte{csReturn}})
+			add(next, fd[g])
+			loop = next
+		}
+	}
+	if loop != nil {
+		ops := charstrings[g]
+		moveto := csOp{data: f.cs([]int{f.delta(), f.delta()}, csRmoveto)}
+		charstrings[g] = append(ops[:len(ops)-1:len(ops)-1], moveto, csOp{call: loop}, ops[len(ops)-1])
+	}
+	return globals, locals
+}
+
+// pathRun returns the bounds of a run of path operators of ops to move into
+// a subroutine, or i == j if there is none.
+func pathRun(s *gen.State, ops []csOp) (i, j int) {
+	var paths []int
+	for k, op := range ops {
+		if op.path {
+			paths = append(paths, k)
+		}
+	}
+	if len(paths) == 0 {
+		return 0, 0
+	}
+	k := s.Intn(len(paths))
+	i = paths[k]
+	j = i + 1
+	for n := s.Range(1, 4); n > 1 && j < len(ops) && ops[j].path; n-- {
+		j++
+	}
+	return i, j
+}
+
+// encodeOps returns a charstring or subroutine of ops, calling subroutines
+// by their index less the bias the size of their INDEX sets.
+func (f *face) encodeOps(ops []csOp, localBias, globalBias int) []byte {
+	var b []byte
+	for _, op := range ops {
+		switch {
+		case op.call == nil:
+			b = append(b, op.data...)
+		case op.call.global:
+			b = csNum(f.s, b, op.call.index-globalBias)
+			b = append(b, csCallgsubr)
+		default:
+			b = csNum(f.s, b, op.call.index-localBias)
+			b = append(b, csCallsubr)
+		}
+	}
+	return b
+}
+
+// bias returns the bias of subroutine numbers in an INDEX of n of them.
+func bias(n int) int {
+	switch {
+	case n < 1240:
+		return 107
+	case n < 33900:
+		return 1131
+	}
+	return 32768
+}
+
+// dictInt appends v as a DICT operand, in the shortest encoding, which
+// is a charstring's for integers of 16 bits.
+func dictInt(b []byte, v int) []byte {
+	switch {
+	case v >= -107 && v <= 107:
+		return append(b, byte(v+139))
+	case v >= 108 && v <= 1131:
+		v -= 108
+		return append(b, byte(247+v>>8), byte(v))
+	case v >= -1131 && v <= -108:
+		v = -v - 108
+		return append(b, byte(251+v>>8), byte(v))
+	case v >= -0x8000 && v <= 0x7fff:
+		return u16(append(b, 28), v)
+	}
+	return u32(append(b, 29), v)
+}
+
+// dictOffset appends v as a DICT operand of five bytes whatever its
+// value, so that a DICT's length does not depend on the offsets in it.
+func dictOffset(b []byte, v int) []byte { return u32(append(b, 29), v) }
+
+// The DICT operators the generator writes.
+const (
+	dictFullName    = 2
+	dictFontBBox    = 5
+	dictBlueValues  = 6
+	dictStdHW       = 10
+	dictStdVW       = 11
+	dictCharStrings = 17
+	dictPrivate     = 18
+	dictSubrs       = 19
+	dictDefaultW    = 20
+	dictNominalW    = 21
+	dictFontMatrix  = 12<<8 | 7
+	dictROS         = 12<<8 | 30
+	dictCIDCount    = 12<<8 | 34
+	dictFDArray     = 12<<8 | 36
+	dictFDSelect    = 12<<8 | 37
+	dictFontName    = 12<<8 | 38
+)
+
+// cffIndex returns an INDEX of items: a count, the size of an offset,
+// offsets from one, and the items. A few break it: a size outside one to
+// four bytes, a first offset other than one, offsets that go backward,
+// and a last one past the end.
+func (f *face) cffIndex(items [][]byte) []byte {
+	s := f.s
+	b := u16(nil, len(items))
+	if len(items) == 0 {
+		return b
+	}
+	total := 1
+	for _, it := range items {
+		total += len(it)
+	}
+	size := 1
+	for total>>(8*size) > 0 {
+		size++
+	}
+	if s.Chance(0.1) {
+		size = min(size+1, 4)
+	}
+	written := size
+	if f.bad() {
+		written = gen.Pick(s, 0, 5, 0xff)
+	}
+	b = append(b, byte(written))
+	offsets := []int{1}
+	for _, it := range items {
+		offsets = append(offsets, offsets[len(offsets)-1]+len(it))
+	}
+	switch {
+	case f.bad():
+		offsets[0] = gen.Pick(s, 0, 2)
+	case len(offsets) > 2 && f.bad():
+		i := s.Range(1, len(offsets)-2)
+		offsets[i] = offsets[i+1] + s.Range(0, 8)
+	case f.bad():
+		offsets[len(offsets)-1] += gen.Pick(s, 1, 0x100, 0xffff)
+	}
+	for _, off := range offsets {
+		for k := size - 1; k >= 0; k-- {
+			b = append(b, byte(off>>(8*k)))
+		}
+	}
+	for _, it := range items {
+		b = append(b, it...)
+	}
+	return b
+}
+
+// cff returns a CFF table of f's glyphs: a header, the Name, Top DICT,
+// String and global subroutine INDEXes, the CharStrings INDEX, and a
+// Private DICT with its subroutines; or, for a CID-keyed font, an
+// FDSelect giving each glyph one of the Font DICTs of an FDArray, each
+// with a Private DICT and subroutines of its own.
+func (f *face) cff() []byte {
+	s := f.s
+	cid := s.Chance(0.25)
+	fds := 1
+	if cid {
+		fds = gen.Pick(s, 1, 2, 3, s.Range(1, 16))
+	}
+	fd := make([]int, f.glyphs)
+	for g := range fd {
+		fd[g] = s.Intn(fds)
+	}
+	charstrings := make([][]csOp, f.glyphs)
+	for g := range charstrings {
+		charstrings[g] = f.charstring()
+	}
+	globals, locals := f.subroutines(charstrings, fd, make([][]*subr, fds))
+	for i, sub := range globals {
+		sub.index = i
+	}
+	for _, subs := range locals {
+		for i, sub := range subs {
+			sub.index = i
+		}
+	}
+	gbias := bias(len(globals))
+	lbias := func(fd int) int {
+		if fd < len(locals) {
+			return bias(len(locals[fd]))
+		}
+		return 107
+	}
+	var gsubrs, encoded [][]byte
+	for _, sub := range globals {
+		gsubrs = append(gsubrs, f.encodeOps(sub.ops, lbias(0), gbias))
+	}
+	for g, ops := range charstrings {
+		encoded = append(encoded, f.encodeOps(ops, lbias(fd[g]), gbias))
+	}
+	lsubrs := make([][]byte, fds)
+	for i, subs := range locals {
+		var items [][]byte
+		for _, sub := range subs {
+			items = append(items, f.encodeOps(sub.ops, lbias(i), gbias))
+		}
+		lsubrs[i] = f.cffIndex(items)
+	}
+	switch {
+	case len(encoded) > 1 && f.bad():
+		encoded = encoded[:len(encoded)-1]
+	case f.bad():
+		encoded = append(encoded, encoded[0])
+	}
+
+	header := []byte{1, 0, 4, byte(s.Range(1, 4))}
+	if f.bad() {
+		header = gen.Pick(s, []byte{2, 0, 4, 4}, []byte{1, 0, 5, 4, 0}, []byte{1, 1, 4, 4})
+	}
+	names := f.cffIndex([][]byte{[]byte("FuzzSans-Regular")})
+	strs := f.cffIndex([][]byte{[]byte("Adobe"), []byte("Identity"), []byte("Fuzz Sans Regular")})
+	gsubrIndex := f.cffIndex(gsubrs)
+	charStrings := f.cffIndex(encoded)
+	privates := make([][]byte, fds)
+	for i := range privates {
+		privates[i] = f.privateDict(len(locals[i]) > 0 || s.Chance(0.1))
+	}
+	var fdSelect []byte
+	if cid {
+		fdSelect = f.fdSelect(fd, fds)
+	}
+
+	// The DICTs' lengths are the same whatever the offsets in them, so
+	// their INDEXes are written once to learn where the rest goes, and
+	// the DICTs with the offsets copied over their ends.
+	matrix := f.fontMatrix()
+	top := f.cffIndex([][]byte{f.topDict(cid, matrix, 0, 0, 0, 0, 0)})
+	charStringsAt := len(header) + len(names) + len(top) + len(strs) + len(gsubrIndex)
+	afterAt := charStringsAt + len(charStrings)
+	var tail []byte
+	if !cid {
+		dict := f.topDict(false, matrix, charStringsAt, len(privates[0]), afterAt, 0, 0)
+		copy(top[len(top)-len(dict):], dict)
+		tail = append(append(tail, privates[0]...), lsubrs[0]...)
+	} else {
+		fdSelectAt := afterAt
+		fdArrayAt := fdSelectAt + len(fdSelect)
+		fontDicts := make([][]byte, fds)
+		for i := range fontDicts {
+			fontDicts[i] = f.fontDict(0, 0)
+		}
+		fdArray := f.cffIndex(fontDicts)
+		privAt := fdArrayAt + len(fdArray)
+		var dicts, privs []byte
+		for i := range fontDicts {
+			dicts = append(dicts, f.fontDict(len(privates[i]), privAt+len(privs))...)
+			privs = append(append(privs, privates[i]...), lsubrs[i]...)
+		}
+		copy(fdArray[len(fdArray)-len(dicts):], dicts)
+		dict := f.topDict(true, matrix, charStringsAt, 0, 0, fdArrayAt, fdSelectAt)
+		copy(top[len(top)-len(dict):], dict)
+		tail = append(append(fdSelect, fdArray...), privs...)
+	}
+	b := append(header, names...)
+	b = append(b, top...)
+	b = append(b, strs...)
+	b = append(b, gsubrIndex...)
+	b = append(b, charStrings...)
+	return append(b, tail...)
+}
+
+// topDict returns a Top DICT: names, bounds, the font matrix whose
+// operands are matrix, if any, and the offsets of the CharStrings and of the Private DICT, or,
+// if cid is set, the registry, ordering and supplement and the offsets
+// of the FDArray and FDSelect.
+func (f *face) topDict(cid bool, matrix []byte, charStrings, privSize, priv, fdArray, fdSelect int) []byte {
+	s := f.s
+	var b []byte
+	if cid {
+		b = operator(dictInt(dictInt(dictInt(b, 391), 392), 0), dictROS)
+		b = operator(dictInt(b, f.glyphs), dictCIDCount)
+	}
+	b = operator(dictInt(b, 393), dictFullName)
+	for _, v := range f.bbox {
+		b = dictInt(b, v)
+	}
+	b = operator(b, dictFontBBox)
+	if matrix != nil {
+		b = operator(append(b, matrix...), dictFontMatrix)
+	}
+	off := func(v int) int {
+		if f.bad() {
+			return gen.Pick(s, 0, -1, v+s.Range(1, 64), 0x7fffffff)
+		}
+		return v
+	}
+	b = operator(dictOffset(b, off(charStrings)), dictCharStrings)
+	if cid {
+		b = operator(dictOffset(b, off(fdArray)), dictFDArray)
+		b = operator(dictOffset(b, off(fdSelect)), dictFDSelect)
+	} else {
+		b = operator(dictOffset(dictOffset(b, off(privSize)), off(priv)), dictPrivate)
+	}
+	return b
+}
+
+// fontMatrix returns the operands of a FontMatrix, 0.001 0 0 0.001 0 0
+// as real numbers, or now and then nil for none.
+func (f *face) fontMatrix() []byte {
+	if !f.s.Chance(0.2) {
+		return nil
+	}
+	milli := []byte{30, 0x0a, 0x00, 0x1f}
+	zero := []byte{30, 0x0f}
+	if f.bad() {
+		// A nibble of 0xd, which no real number has.
+		milli = []byte{30, 0x0a, 0xd0, 0x1f}
+	}
+	var b []byte
+	for _, v := range [][]byte{milli, zero, zero, milli, zero, zero} {
+		b = append(b, v...)
+	}
+	return b
+}
+
+// fontDict returns a Font DICT of a CID-keyed font's FDArray, which says
+// where its Private DICT is.
+func (f *face) fontDict(privSize, priv int) []byte {
+	b := operator(dictInt(nil, 393), dictFontName)
+	if f.bad() {
+		priv += gen.Pick(f.s, 1, -1, 0x10000)
+	}
+	return operator(dictOffset(dictOffset(b, privSize), priv), dictPrivate)
+}
+
+// privateDict returns a Private DICT: blue zones, stem widths, default
+// and nominal widths, and, if subrs is set, the offset of the local
+// subroutines from its own start, which is just past its end.
+func (f *face) privateDict(subrs bool) []byte {
+	s := f.s
+	b := dictInt(dictInt(nil, -f.upem/50), f.upem/50)
+	b = dictInt(dictInt(b, f.upem/2), f.upem/50)
+	b = operator(b, dictBlueValues)
+	b = operator(dictInt(b, s.Range(1, 100)), dictStdHW)
+	b = operator(dictInt(b, s.Range(1, 100)), dictStdVW)
+	b = operator(dictInt(b, f.upem/2), dictDefaultW)
+	b = operator(dictInt(b, f.upem/2), dictNominalW)
+	if !subrs {
+		return b
+	}
+	at := len(b) + 6
+	if f.bad() {
+		at = gen.Pick(s, 0, at+s.Range(1, 64), -at, 0x7fffffff)
+	}
+	return operator(dictOffset(b, at), dictSubrs)
+}
+
+// fdSelect returns an FDSelect of format 0, a Font DICT for each glyph,
+// or of format 3, ranges of glyphs with the same one and a sentinel
+// past the last glyph. A few break it: Font DICTs past the FDArray,
+// ranges out of order and formats no reader supports.
+func (f *face) fdSelect(fd []int, fds int) []byte {
+	s := f.s
+	pick := func(i int) int {
+		if f.bad() {
+			return fds + s.Intn(4)
+		}
+		return i
+	}
+	if s.Chance(0.4) {
+		b := []byte{0}
+		for _, i := range fd {
+			b = append(b, byte(pick(i)))
+		}
+		if f.bad() {
+			b[0] = byte(gen.Pick(s, 1, 2, 4))
+		}
+		return b
+	}
+	type span struct{ first, fd int }
+	var spans []span
+	for g, i := range fd {
+		if n := len(spans); n == 0 || spans[n-1].fd != i {
+			spans = append(spans, span{g, i})
+		}
+	}
+	if len(spans) > 1 && f.bad() {
+		i := s.Intn(len(spans) - 1)
+		spans[i], spans[i+1] = spans[i+1], spans[i]
+	}
+	b := u16([]byte{3}, len(spans))
+	for _, sp := range spans {
+		b = append(u16(b, sp.first), byte(pick(sp.fd)))
+	}
+	return u16(b, len(fd))
+}
@@ -0,0 +1,311 @@
+package fontsrc
+
+import (
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A subtable is a subtable of a cmap: the platform and encoding it is
+// for, and its contents, which several subtables may share.
+type subtable struct {
+	platform, encoding int
+	data               []byte
+}
+
+// cmap returns a cmap table: a subtable of format 4 of the characters in
+// the Basic Multilingual Plane for Windows, or for Unicode, and others
+// for the rest of them, for Macintosh Roman, and for encodings and in
+// formats no reader here supports.
+func (f *face) cmap() []byte {
+	s := f.s
+	var subs []subtable
+	bmp := f.format4()
+	switch s.Intn(4) {
+	case 0:
+		subs = append(subs, subtable{0, 3, bmp})
+	case 1:
+		subs = append(subs, subtable{0, 3, bmp}, subtable{3, 1, bmp})
+	default:
+		subs = append(subs, subtable{3, 1, bmp})
+	}
+	if n := len(f.chars); n > 0 && f.chars[n-1].r > 0xffff || s.Chance(0.2) {
+		all := f.format12()
+		subs = append(subs, subtable{gen.Pick(s, 0, 3), 0, all})
+		subs[len(subs)-1].encoding = map[int]int{0: 4, 3: 10}[subs[len(subs)-1].platform]
+		if s.Chance(0.3) {
+			subs = append(subs, subtable{0, 4, all})
+		}
+	}
+	if s.Chance(0.3) {
+		if s.Chance(0.5) {
+			subs = append(subs, subtable{1, 0, f.format0()})
+		} else {
+			subs = append(subs, subtable{1, 0, f.format6()})
+		}
+	}
+	if s.Chance(0.1) {
+		subs = append(subs, subtable{3, 0, f.format4()})
+	}
+	if s.Chance(0.1) {
+		subs = append(subs, subtable{0, 3, f.format6()})
+	}
+	if s.Chance(0.15) {
+		// Formats and encodings no reader here supports: variation
+		// sequences, many-to-one ranges and high-byte mappings.
+		switch s.Intn(3) {
+		case 0:
+			subs = append(subs, subtable{0, 5, u32(u32(u32(u16(nil, 14), 10), 0), 0)[:10]})
+		case 1:
+			subs = append(subs, subtable{3, 10, u32(u32(u32(u32(u16(u16(nil, 13), 0), 28), 0), 1), 0x20)})
+			subs[len(subs)-1].data = u32(u32(subs[len(subs)-1].data, 0x7e), 1)
+		default:
+			subs = append(subs, subtable{1, 0, u16(u16(u16(nil, 2), 518), 0)})
+			subs[len(subs)-1].data = append(subs[len(subs)-1].data, make([]byte, 512)...)
+		}
+	}
+	slices.SortStableFunc(subs, func(a, b subtable) int {
+		if a.platform != b.platform {
+			return a.platform - b.platform
+		}
+		return a.encoding - b.encoding
+	})
+
+	n := len(subs)
+	if f.bad() {
+		n = gen.Pick(s, n+1, n+s.Range(2, 100), 0)
+	}
+	b := u16(u16(nil, 0), n)
+	at := 4 + 8*len(subs)
+	var body []byte
+	written := map[*byte]int{}
+	for _, sub := range subs {
+		off, ok := written[&sub.data[0]]
+		if !ok {
+			off = at + len(body)
+			written[&sub.data[0]] = off
+			body = append(body, sub.data...)
+		}
+		if f.bad() {
+			off = gen.Pick(s, at+len(body), at+len(body)+s.Range(1, 64), 0xffffffff, 2)
+		}
+		b = u32(u16(u16(b, sub.platform), sub.encoding), off)
+	}
+	return append(b, body...)
+}
+
+// A run is a run of characters mapped to glyphs: they are one after
+// another, and so, if delta is set, are their glyphs.
+type run struct {
+	chars []mapping
+	delta bool
+}
+
+// runs returns the characters of f up to last, in runs of characters
+// one after another. A run whose glyphs follow one another is cut where
+// one does not, unless it has a single character, which may start a run
+// whose glyphs are listed.
+func (f *face) runs(last rune) []run {
+	var rs []run
+	for _, m := range f.chars {
+		if m.r > last {
+			break
+		}
+		if n := len(rs); n > 0 {
+			r := &rs[n-1]
+			prev := r.chars[len(r.chars)-1]
+			next := prev.r+1 == m.r
+			switch {
+			case next && (!r.delta || prev.glyph+1 == m.glyph):
+				r.chars = append(r.chars, m)
+				continue
+			case next && len(r.chars) == 1 && f.s.Chance(0.7):
+				r.delta = false
+				r.chars = append(r.chars, m)
+				continue
+			}
+		}
+		rs = append(rs, run{chars: []mapping{m}, delta: true})
+	}
+	return rs
+}
+
+// format4 returns a subtable of format 4: segments of characters in the
+// Basic Multilingual Plane, whose glyphs are the characters plus a
+// delta, or listed in an array an offset from the segment points into,
+// ended by a segment for U+FFFF. A few break it: an odd count of
+// segments, or a huge one, segments out of order or overlapping, an
+// offset past the end of the array, and no final segment.
+func (f *face) format4() []byte {
+	s := f.s
+	type segment struct{ start, end, delta, offset int }
+	var segs []segment
+	var glyphIDs []int
+	var arrayAt []int // where each segment's glyphs start in glyphIDs, or -1
+	for _, r := range f.runs(0xfffe) {
+		seg := segment{start: int(r.chars[0].r), end: int(r.chars[len(r.chars)-1].r)}
+		if r.delta && s.Chance(0.9) {
+			seg.delta = (r.chars[0].glyph - seg.start) & 0xffff
+			arrayAt = append(arrayAt, -1)
+		} else {
+			arrayAt = append(arrayAt, len(glyphIDs))
+			for _, m := range r.chars {
+				glyphIDs = append(glyphIDs, m.glyph)
+			}
+		}
+		segs = append(segs, seg)
+	}
+	if !f.bad() {
+		segs = append(segs, segment{start: 0xffff, end: 0xffff, delta: 1})
+		arrayAt = append(arrayAt, -1)
+	}
+	if len(segs) > 1 {
+		switch {
+		case f.bad():
+			i := s.Intn(len(segs) - 1)
+			segs[i], segs[i+1] = segs[i+1], segs[i]
+			arrayAt[i], arrayAt[i+1] = arrayAt[i+1], arrayAt[i]
+		case f.bad():
+			i := s.Intn(len(segs) - 1)
+			segs[i].end = segs[i+1].start + s.Intn(4)
+		}
+	}
+	n := len(segs)
+	for i, at := range arrayAt {
+		if at >= 0 {
+			segs[i].offset = 2*(n-i) + 2*at
+			if f.bad() {
+				segs[i].offset += gen.Pick(s, 2*len(glyphIDs), 0xfffe, 1)
+			}
+		}
+	}
+
+	segCountX2 := 2 * n
+	if f.bad() {
+		segCountX2 = gen.Pick(s, segCountX2+1, 0xfffe, 40002)
+	}
+	sel := 0
+	for 2<<sel <= n {
+		sel++
+	}
+	b := u16(u16(u16(nil, 4), 0), gen.Pick(s, 0, 0, 1))
+	b = u16(u16(u16(u16(b, segCountX2), 2<<sel), sel), 2*n-2<<sel)
+	for _, seg := range segs {
+		b = u16(b, seg.end)
+	}
+	b = u16(b, 0)
+	for _, seg := range segs {
+		b = u16(b, seg.start)
+	}
+	for _, seg := range segs {
+		b = u16(b, seg.delta)
+	}
+	for _, seg := range segs {
+		b = u16(b, seg.offset)
+	}
+	for _, g := range glyphIDs {
+		b = u16(b, g)
+	}
+	length := len(b)
+	if f.bad() {
+		length = gen.Pick(s, 0, length+2, 0xffff)
+	}
+	put16(b[2:], length)
+	return b
+}
+
+// format12 returns a subtable of format 12: groups of characters one
+// after another, anywhere in Unicode, whose glyphs are one after
+// another too. A few break it: groups out of order, overlapping or
+// covering every code point, counts too large and wrong lengths.
+func (f *face) format12() []byte {
+	s := f.s
+	var groups [][3]int
+	for _, r := range f.runs(0x10ffff) {
+		if r.delta {
+			groups = append(groups, [3]int{int(r.chars[0].r), int(r.chars[len(r.chars)-1].r), r.chars[0].glyph})
+			continue
+		}
+		for _, m := range r.chars {
+			groups = append(groups, [3]int{int(m.r), int(m.r), m.glyph})
+		}
+	}
+	switch {
+	case f.bad():
+		groups = append(groups, [3]int{0, gen.Pick(s, 0x10ffff, 0x7fffffff, 0xffffffff), s.Intn(4)})
+	case len(groups) > 1 && f.bad():
+		i := s.Intn(len(groups) - 1)
+		groups[i], groups[i+1] = groups[i+1], groups[i]
+	case len(groups) > 1 && f.bad():
+		i := s.Intn(len(groups) - 1)
+		groups[i][1] = groups[i+1][0] + s.Intn(4)
+	}
+	n := len(groups)
+	if f.bad() {
+		n = gen.Pick(s, n+1, 20001, 0xffffffff)
+	}
+	length := 16 + 12*len(groups)
+	if f.bad() {
+		length = gen.Pick(s, length+12, length-1, 0xffffffff)
+	}
+	b := u32(u32(u32(u16(u16(nil, 12), 0), length), 0), n)
+	for _, g := range groups {
+		b = u32(u32(u32(b, g[0]), g[1]), g[2])
+	}
+	return b
+}
+
+// format6 returns a subtable of format 6: the glyphs of the characters
+// from the first up to a few hundred after it, with 0 for those not
+// mapped.
+func (f *face) format6() []byte {
+	s := f.s
+	first := 0
+	if len(f.chars) > 0 && f.chars[0].r <= 0xffff {
+		first = int(f.chars[0].r)
+	}
+	glyphs := map[int]int{}
+	last := first
+	for _, m := range f.chars {
+		if int(m.r) >= first+512 || m.r > 0xffff {
+			break
+		}
+		glyphs[int(m.r)] = m.glyph
+		last = int(m.r)
+	}
+	count := last - first + 1
+	n := count
+	if f.bad() {
+		n = gen.Pick(s, n+1, 0xffff)
+	}
+	b := u16(u16(u16(nil, 6), 10+2*count), 0)
+	b = u16(u16(b, first), n)
+	for c := first; c <= last; c++ {
+		b = u16(b, glyphs[c])
+	}
+	return b
+}
+
+// format0 returns a subtable of format 0: the glyphs of the 256
+// characters of Macintosh Roman, which are ASCII below 128 and mapped
+// at random above, each in a byte.
+func (f *face) format0() []byte {
+	s := f.s
+	b := u16(u16(u16(nil, 0), 262), 0)
+	var glyphs [256]byte
+	for _, m := range f.chars {
+		if m.r < 128 {
+			glyphs[m.r] = byte(m.glyph)
+		}
+	}
+	for c := 128; c < 256; c++ {
+		if s.Chance(0.2) {
+			glyphs[c] = byte(s.Intn(min(f.glyphs, 256)))
+		}
+	}
+	b = append(b, glyphs[:]...)
+	if f.bad() {
+		b = gen.Pick(s, b[:s.Intn(len(b))], append(b, 0))
+	}
+	return b
+}
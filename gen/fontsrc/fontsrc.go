@@ -0,0 +1,962 @@
+// Package fontsrc generates font seeds. It registers the "font/..."
+// generators with package gen.
+//
+// "font/ttf" writes a TrueType font, input.ttf: a table directory, its
+// tables sorted by tag, aligned and checksummed, and the tables a
+// renderer reads. Its glyphs are simple outlines of quadratic contours,
+// their flags packed with repeats and their coordinates as short or
+// long deltas, and compound glyphs built of others, moved, scaled or
+// transformed, placed by a short or long loca table. Around them go
+// head, hhea, maxp and hmtx tables, a cmap of format 0, 4, 6 and 12
+// subtables for Macintosh, Unicode and Windows encodings and some no
+// reader supports, a name table in Macintosh Roman, UCS-2 and
+// encodings Name does not read, a post table of version 1, 2 or 3, an
+// OS/2 table of any version or none, and kerning pairs in a kern table
+// or a GPOS pair adjustment lookup, by glyph or by class.
+//
+// "font/otf" writes an OpenType font with CFF outlines, input.otf: a
+// CFF table of Name, Top DICT, String and global subroutine INDEXes,
+// CharStrings of Type 2 charstrings with widths, stem hints and hint
+// masks, every line and curve operator, and calls to local and global
+// subroutines, and a Private DICT with its own subroutines; or a
+// CID-keyed font, with Font DICTs in an FDArray and an FDSelect of
+// format 0 or 3 choosing among them. Its other tables are as in a
+// TrueType font, with a short maxp.
+//
+// "font/ttc" writes a font collection, input.ttc: a ttcf header of
+// version 1 or 2 and the fonts it points at, TrueType or CFF, which
+// share the tables they have alike.
+//
+// A few break the format. Table directories have tags out of order or
+// repeated, counts too large, and tables misaligned, overlapping or
+// past the end of the file. Compound glyphs refer to themselves, loop
+// through each other, nest past the depth a reader allows, or refer to
+// the next glyph many times over for several levels, so that a few
+// hundred bytes stand for millions of points; subroutines do the same,
+// or call themselves. Simple glyphs have contour ends that go backward
+// or disagree with their points, and flags and coordinates that run
+// short. cmap subtables have odd or huge segment counts, segments out
+// of order or overlapping, glyph ID offsets past the end, and groups
+// that cover every code point. INDEXes have bad offset sizes and
+// offsets, charstrings overflow the argument stack or never end, and
+// lengths, counts and versions of other tables are wrong.
+package fontsrc
+
+import (
+	"cmp"
+	"encoding/binary"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// badRate is the chance that one of the many fields of a font is wrong,
+// so that one font in ten or so is broken.
+const badRate = 0.003
+
+// u16 and u32 append v to b, big-endian, as every field of a font is.
+func u16(b []byte, v int) []byte { return binary.BigEndian.AppendUint16(b, uint16(v)) }
+func u32(b []byte, v int) []byte { return binary.BigEndian.AppendUint32(b, uint32(v)) }
+
+// put16 writes v over the first two bytes of b.
+func put16(b []byte, v int) { binary.BigEndian.PutUint16(b, uint16(v)) }
+
+// pad4 returns b padded with zeros to a multiple of four bytes.
+func pad4(b []byte) []byte {
+	for len(b)%4 != 0 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// A table is one table of a font: its tag and its contents.
+type table struct {
+	tag  string
+	data []byte
+}
+
+// A font is a font as the generators assemble it: the version its offset
+// table starts with, "\x00\x01\x00\x00", "OTTO" or "true", and its
+// tables. Fonts in a collection share a table by sharing its pointer.
+type font struct {
+	version string
+	tables  []*table
+}
+
+// get returns the table of f tagged tag, or nil.
+func (f *font) get(tag string) *table {
+	for _, t := range f.tables {
+		if t.tag == tag {
+			return t
+		}
+	}
+	return nil
+}
+
+// set replaces the table of f tagged t.tag with t, or adds it.
+func (f *font) set(t *table) {
+	for i, u := range f.tables {
+		if u.tag == t.tag {
+			f.tables[i] = t
+			return
+		}
+	}
+	f.tables = append(f.tables, t)
+}
+
+// checksum returns the checksum of b, the sum of its big-endian words
+// with b padded to a whole one.
+func checksum(b []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(b); i += 4 {
+		var w [4]byte
+		copy(w[:], b[i:])
+		sum += binary.BigEndian.Uint32(w[:])
+	}
+	return sum
+}
+
+// fontFile returns the file of a single font, with head's
+// checkSumAdjustment set so that the whole file sums to the magic the
+// specification gives, and now and then cut short.
+func fontFile(s *gen.State, f *font) []byte {
+	b, _ := writeFonts(s, nil, []*font{f})
+	if head := tableAt(b, 0, "head"); head >= 0 && head+12 <= len(b) {
+		binary.BigEndian.PutUint32(b[head+8:], 0xb1b0afba-checksum(b))
+	}
+	return truncate(s, b)
+}
+
+// tableAt returns where the directory at off in b says the table tagged
+// tag is, or -1.
+func tableAt(b []byte, off int, tag string) int {
+	if off+12 > len(b) {
+		return -1
+	}
+	n := int(binary.BigEndian.Uint16(b[off+4:]))
+	for i := range n {
+		r := off + 12 + 16*i
+		if r+16 > len(b) {
+			break
+		}
+		if string(b[r:r+4]) == tag {
+			return int(binary.BigEndian.Uint32(b[r+8:]))
+		}
+	}
+	return -1
+}
+
+// truncate returns b, or now and then b cut short.
+func truncate(s *gen.State, b []byte) []byte {
+	if s.Chance(badRate*4) && len(b) > 0 {
+		return b[:s.Intn(len(b))]
+	}
+	return b
+}
+
+// writeFonts appends to b the table directories of fonts, one after
+// another, and then their tables, each written once however many fonts
+// share it. It returns the file and where each directory starts.
+func writeFonts(s *gen.State, b []byte, fonts []*font) ([]byte, []int) {
+	sorted := make([][]*table, len(fonts))
+	dirs := make([]int, len(fonts))
+	for i, f := range fonts {
+		sorted[i] = slices.Clone(f.tables)
+		slices.SortStableFunc(sorted[i], func(a, b *table) int {
+			return cmp.Compare(a.tag, b.tag)
+		})
+		dirs[i] = len(b)
+		b = append(b, make([]byte, 12+16*len(sorted[i]))...)
+	}
+	b = pad4(b)
+	offsets := map[*table]int{}
+	for _, ts := range sorted {
+		for _, t := range ts {
+			if _, ok := offsets[t]; !ok {
+				offsets[t] = len(b)
+				b = pad4(append(b, t.data...))
+			}
+		}
+	}
+	for i, f := range fonts {
+		directory(s, b[dirs[i]:], f.version, sorted[i], offsets, len(b))
+	}
+	return b, dirs
+}
+
+// directory writes the offset table and table records of a font over
+// the start of b, for its tables ts at offsets in a file of size bytes.
+// A few break it: a version a reader does not know, a table count too
+// large, records out of order or repeated, and tables misaligned,
+// overlapping another or running past the end of the file.
+func directory(s *gen.State, b []byte, version string, ts []*table, offsets map[*table]int, size int) {
+	switch {
+	case s.Chance(badRate):
+		version = gen.Pick(s, "ttcf", "\x00\x02\x00\x00", "OTTO", "true", "\x00\x00\x01\x00", "wOFF")
+	case version == "\x00\x01\x00\x00" && s.Chance(0.05):
+		version = "true"
+	}
+	copy(b, version)
+	n := len(ts)
+	if s.Chance(badRate * 2) {
+		n = gen.Pick(s, n+1, n+s.Range(2, 40), 257, 0xffff, max(n-1, 0))
+	}
+	put16(b[4:], n)
+	// searchRange, entrySelector and rangeShift, which no reader trusts.
+	sel := 0
+	for 2<<sel <= len(ts) {
+		sel++
+	}
+	put16(b[6:], 16<<sel)
+	put16(b[8:], sel)
+	put16(b[10:], 16*len(ts)-16<<sel)
+
+	recs := make([][16]byte, len(ts))
+	for i, t := range ts {
+		r := &recs[i]
+		copy(r[:4], t.tag+"    ")
+		binary.BigEndian.PutUint32(r[4:], checksum(t.data))
+		off, length := offsets[t], len(t.data)
+		switch {
+		case s.Chance(badRate):
+			off += gen.Pick(s, 1, 2, 3)
+		case s.Chance(badRate):
+			length += gen.Pick(s, 1, 4, size, 1<<29, 1<<31)
+		case s.Chance(badRate):
+			off = gen.Pick(s, 0, size, size+4, 1<<29, 1<<31)
+		case s.Chance(badRate) && len(ts) > 1:
+			other := ts[s.Intn(len(ts))]
+			off, length = offsets[other], len(other.data)
+		}
+		binary.BigEndian.PutUint32(r[8:], uint32(off))
+		binary.BigEndian.PutUint32(r[12:], uint32(length))
+	}
+	if len(recs) > 1 {
+		switch {
+		case s.Chance(badRate * 2):
+			i := s.Intn(len(recs) - 1)
+			recs[i], recs[i+1] = recs[i+1], recs[i]
+		case s.Chance(badRate * 2):
+			i := s.Intn(len(recs) - 1)
+			recs[i+1] = recs[i]
+		}
+	}
+	for i, r := range recs {
+		copy(b[12+16*i:], r[:])
+	}
+}
+
+// A face is what the tables of a font say besides its outlines: how
+// many glyphs it has and what they are called, their advances, the
+// characters they are for, and its metrics.
+type face struct {
+	s      *gen.State
+	glyphs int
+	upem   int
+	// longLoca is whether loca holds 32-bit offsets.
+	longLoca bool
+	// hMetrics is how many glyphs have an advance of their own in hmtx;
+	// the rest have the last one's.
+	hMetrics int
+	advances []int
+	chars    []mapping
+	names    []string
+	// bbox is the bounds of every glyph, xMin, yMin, xMax and yMax, as
+	// the outlines set it.
+	bbox [4]int
+}
+
+// A mapping maps a character to a glyph.
+type mapping struct {
+	r     rune
+	glyph int
+}
+
+// repertoires are the ranges of characters a font is for.
+var repertoires = [][2]rune{
+	{0x20, 0x7e}, {0x20, 0x7e}, {0xa0, 0xff}, {0x100, 0x17f}, {0x370, 0x3ff},
+	{0x400, 0x4ff}, {0x5d0, 0x5ea}, {0x600, 0x6ff}, {0x3040, 0x309f},
+	{0x4e00, 0x4eff}, {0xac00, 0xac7f}, {0xe000, 0xe01f}, {0xf000, 0xf0ff},
+	{0xfff0, 0xffff}, {0x1f600, 0x1f64f}, {0x1d400, 0x1d4ff}, {0x10fff0, 0x10ffff},
+}
+
+// glyphNames are the names glyphs are given in a post table of version
+// 2, standard Macintosh ones among them.
+var glyphNames = []string{
+	".notdef", ".null", "nonmarkingreturn", "space", "A", "B", "a", "b", "zero",
+	"uni4E2D", "u1F600", "f_f_i", "a.sc", "Aacute", "gravecomb", "", "x",
+	"very.long.glyph.name.that.goes.on.and.on.for.a.while.and.then.some.more",
+}
+
+// numGlyphs returns how many glyphs a font has: a few, as most seeds
+// do, or now and then hundreds.
+func numGlyphs(s *gen.State) int {
+	if s.Chance(0.05) {
+		return s.Range(200, 1200)
+	}
+	return gen.Pick(s, 1, 2, 3, s.Range(4, 16), s.Range(4, 64))
+}
+
+// newFace returns a face of n glyphs.
+func newFace(s *gen.State, n int) *face {
+	f := &face{
+		s:      s,
+		glyphs: n,
+		upem:   gen.Pick(s, 1000, 1000, 2048, 2048, 1024, 256, 16, 1, 16384, s.Range(16, 16384)),
+	}
+	f.longLoca = s.Chance(0.3)
+	f.hMetrics = n
+	if s.Chance(0.4) {
+		f.hMetrics = s.Range(1, n)
+	}
+	f.advances = make([]int, n)
+	for i := range f.advances {
+		if i < f.hMetrics {
+			f.advances[i] = gen.Pick(s, 0, f.upem/2, f.upem, s.Intn(2*f.upem+1), s.Intn(0x10000))
+		} else {
+			f.advances[i] = f.advances[f.hMetrics-1]
+		}
+	}
+	f.names = make([]string, n)
+	for i := range f.names {
+		f.names[i] = gen.Pick(s, glyphNames...)
+		if s.Chance(0.5) {
+			f.names[i] += "." + string(rune('a'+i%26)) + string(rune('a'+i/26%26))
+		}
+	}
+	f.chars = f.charMap()
+	return f
+}
+
+// charMap returns the characters of a few repertoires, or of some of
+// them, mapped to the glyphs in order or at random, sorted by
+// character.
+func (f *face) charMap() []mapping {
+	s := f.s
+	seen := map[rune]bool{}
+	var m []mapping
+	next := 1
+	for range s.Range(1, 4) {
+		rep := gen.Pick(s, repertoires...)
+		all := s.Chance(0.5)
+		for r := rep[0]; r <= rep[1]; r++ {
+			if seen[r] || !all && s.Chance(0.6) {
+				continue
+			}
+			seen[r] = true
+			g := next % max(f.glyphs, 1)
+			switch {
+			case s.Chance(0.1):
+				g = s.Intn(max(f.glyphs, 1))
+			case s.Chance(badRate):
+				g = f.glyphs + s.Intn(16)
+			default:
+				next++
+			}
+			m = append(m, mapping{r, g})
+		}
+	}
+	slices.SortFunc(m, func(a, b mapping) int { return int(a.r - b.r) })
+	return m
+}
+
+// bad reports whether a field is to be wrong.
+func (f *face) bad() bool { return f.s.Chance(badRate) }
+
+// common returns the tables of a font besides its outlines, for
+// TrueType outlines or, if cff is set, CFF ones. The outlines must
+// have set f.bbox.
+func (f *face) common(cff bool) []*table {
+	s := f.s
+	ts := []*table{
+		{"head", f.head()},
+		{"hhea", f.hhea()},
+		{"maxp", f.maxp(cff)},
+		{"hmtx", f.hmtx()},
+		{"cmap", f.cmap()},
+		{"post", f.post()},
+	}
+	if s.Chance(0.9) {
+		ts = append(ts, &table{"name", f.name()})
+	}
+	if s.Chance(0.7) {
+		ts = append(ts, &table{"OS/2", f.os2()})
+	}
+	switch {
+	case s.Chance(0.3):
+		ts = append(ts, &table{"kern", f.kern()})
+	case s.Chance(0.2):
+		ts = append(ts, &table{"GPOS", f.gpos()})
+	}
+	if s.Chance(0.1) {
+		// Tables no reader here reads.
+		ts = append(ts, &table{gen.Pick(s, "DSIG", "gasp", "prep", "fpgm", "cvt ", "VORG", "zzzz"), samples(s, s.Intn(64))})
+	}
+	return ts
+}
+
+// samples returns n random bytes.
+func samples(s *gen.State, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	return b
+}
+
+// head returns a head table, which is 54 bytes.
+func (f *face) head() []byte {
+	s := f.s
+	b := u32(nil, 0x00010000)
+	b = u32(b, 0x00010000+s.Intn(0x10000)) // fontRevision
+	b = u32(b, 0)                          // checkSumAdjustment, set once the file is written
+	b = u32(b, 0x5f0f3cf5)
+	b = u16(b, gen.Pick(s, 0x000b, 0x0003, 0x001f, s.Intn(0x10000)))
+	upem := f.upem
+	if f.bad() {
+		upem = 0
+	}
+	b = u16(b, upem)
+	b = binary.BigEndian.AppendUint64(b, uint64(3600000000+s.Intn(1<<28))) // created
+	b = binary.BigEndian.AppendUint64(b, uint64(3600000000+s.Intn(1<<28))) // modified
+	for _, v := range f.bbox {
+		b = u16(b, v)
+	}
+	b = u16(b, s.Intn(4))           // macStyle
+	b = u16(b, gen.Pick(s, 8, 9))   // lowestRecPPEM
+	b = u16(b, gen.Pick(s, 2, 0))   // fontDirectionHint
+	b = u16(b, boolInt(f.longLoca)) // indexToLocFormat
+	b = u16(b, 0)                   // glyphDataFormat
+	if f.bad() {
+		b = gen.Pick(s, b[:52], append(b, 0, 0))
+	}
+	return b
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// hhea returns an hhea table, which is 36 bytes.
+func (f *face) hhea() []byte {
+	s := f.s
+	b := u32(nil, 0x00010000)
+	b = u16(b, f.bbox[3])                     // ascender
+	b = u16(b, f.bbox[1])                     // descender
+	b = u16(b, gen.Pick(s, 0, f.upem/10))     // lineGap
+	b = u16(b, slices.Max(f.advances))        // advanceWidthMax
+	b = u16(b, f.bbox[0])                     // minLeftSideBearing
+	b = u16(b, 0)                             // minRightSideBearing
+	b = u16(b, f.bbox[2])                     // xMaxExtent
+	b = u16(b, gen.Pick(s, 1, 1, 1, 0, 1000)) // caretSlopeRise
+	b = u16(b, gen.Pick(s, 0, 0, 0, 1, 200))  // caretSlopeRun
+	b = u16(b, 0)                             // caretOffset
+	b = append(b, make([]byte, 10)...)        // reserved and metricDataFormat
+	n := f.hMetrics
+	if f.bad() {
+		n = gen.Pick(s, 0, f.glyphs+1, 0xffff)
+	}
+	b = u16(b, n)
+	if f.bad() {
+		b = b[:s.Intn(len(b))]
+	}
+	return b
+}
+
+// maxp returns a maxp table: of version 0.5, six bytes, for CFF outlines,
+// or of version 1, 32 bytes, for TrueType ones.
+func (f *face) maxp(cff bool) []byte {
+	s := f.s
+	n := f.glyphs
+	if f.bad() {
+		n = gen.Pick(s, 0, n-1, n+1, 0xffff)
+	}
+	if cff {
+		b := u16(u32(nil, 0x00005000), n)
+		if f.bad() {
+			b = append(b, make([]byte, 26)...)
+		}
+		return b
+	}
+	b := u16(u32(nil, 0x00010000), n)
+	for _, v := range []int{256, 16, 512, 32, 2, 0, 0, 0, 0, 256, 0, 16, s.Range(0, 9)} {
+		b = u16(b, v)
+	}
+	if f.bad() {
+		b = b[:6]
+	}
+	return b
+}
+
+// hmtx returns an hmtx table: an advance and left side bearing for each
+// of the first f.hMetrics glyphs, and a left side bearing for each of
+// the rest, which some fonts leave out.
+func (f *face) hmtx() []byte {
+	s := f.s
+	var b []byte
+	for i := range f.hMetrics {
+		b = u16(u16(b, f.advances[i]), s.Range(-50, 100))
+	}
+	if s.Chance(0.9) {
+		for range f.glyphs - f.hMetrics {
+			b = u16(b, s.Range(-50, 100))
+		}
+	}
+	if f.bad() {
+		b = append(b, 0, 0)
+	}
+	return b
+}
+
+// post returns a post table of version 1, whose glyph names are the
+// standard Macintosh ones, 2, with names of its own, or 3, with none.
+func (f *face) post() []byte {
+	s := f.s
+	version := gen.Pick(s, 0x00010000, 0x00020000, 0x00020000, 0x00030000)
+	if f.bad() {
+		version = gen.Pick(s, 0x00025000, 0x00040000, 0)
+	}
+	b := u32(nil, version)
+	b = u32(b, gen.Pick(s, 0, -12<<16, -0x000b8000)) // italicAngle
+	b = u16(b, -f.upem/10)                           // underlinePosition
+	b = u16(b, f.upem/20)                            // underlineThickness
+	b = u32(b, s.Intn(2))                            // isFixedPitch
+	b = append(b, make([]byte, 16)...)               // memory use, which no reader needs
+	if version != 0x00020000 {
+		return b
+	}
+	n := f.glyphs
+	if f.bad() {
+		n = gen.Pick(s, n-1, n+1, 0)
+	}
+	b = u16(b, n)
+	// The names are indexed from 258, after the standard ones.
+	var custom []string
+	index := map[string]int{}
+	for i := range f.glyphs {
+		name := f.names[i]
+		j, ok := index[name]
+		switch {
+		case s.Chance(0.2):
+			j = s.Intn(258)
+		case !ok:
+			j = 258 + len(custom)
+			index[name] = j
+			custom = append(custom, name)
+		}
+		if f.bad() {
+			j = gen.Pick(s, 258+len(custom)+s.Intn(8), 32768, 0xffff)
+		}
+		b = u16(b, j)
+	}
+	for _, name := range custom {
+		if len(name) > 255 {
+			name = name[:255]
+		}
+		b = append(b, byte(len(name)))
+		b = append(b, name...)
+	}
+	if len(b) > 36 && f.bad() {
+		b = b[:len(b)-s.Range(1, 2)]
+	}
+	return b
+}
+
+// nameStrings are the strings a name table holds.
+var nameStrings = []string{
+	"Fuzz Sans", "Regular", "Bold Italic", "FuzzSans-Regular", "Version 1.000",
+	"Copyright (c) nobody", "Gothic éèü", "中文字体",
+	"\U0001f600 emoji", "", "a\x00b",
+}
+
+// name returns a name table of format 0: records for name IDs from 0 to
+// 25 and beyond, in Macintosh Roman, as UCS-2 for Windows, and in
+// encodings Name does not read, pointing into the strings after them.
+func (f *face) name() []byte {
+	s := f.s
+	type record struct {
+		platform, encoding, language, id int
+		data                             []byte
+	}
+	var recs []record
+	for range s.Range(1, 24) {
+		str := gen.Pick(s, nameStrings...)
+		id := gen.Pick(s, 1, 2, 3, 4, 5, 6, s.Intn(26), s.Range(256, 300))
+		r := record{id: id}
+		switch s.Intn(5) {
+		case 0, 1:
+			r.platform, r.encoding, r.language = 3, 1, 0x409
+			for _, c := range str {
+				if c > 0xffff {
+					c = 0xfffd
+				}
+				r.data = u16(r.data, int(c))
+			}
+		case 2, 3:
+			r.platform, r.encoding = 1, 0
+			r.data = []byte(str)
+		default:
+			r.platform, r.encoding = gen.Pick(s, 0, 3, 2), gen.Pick(s, 3, 10, 0)
+			r.data = []byte(str)
+		}
+		if f.bad() {
+			r.data = append(r.data, 0)
+		}
+		recs = append(recs, r)
+	}
+	slices.SortStableFunc(recs, func(a, b record) int {
+		if a.platform != b.platform {
+			return a.platform - b.platform
+		}
+		if a.encoding != b.encoding {
+			return a.encoding - b.encoding
+		}
+		return a.id - b.id
+	})
+	count := len(recs)
+	if f.bad() {
+		count += s.Range(1, 100)
+	}
+	b := u16(u16(u16(nil, 0), count), 6+12*len(recs))
+	var storage []byte
+	for _, r := range recs {
+		off := len(storage)
+		if i := bytesIndex(storage, r.data); i >= 0 {
+			off = i
+		} else {
+			storage = append(storage, r.data...)
+		}
+		if f.bad() {
+			off = gen.Pick(s, 0xffff, len(storage)+s.Intn(16))
+		}
+		b = u16(u16(u16(u16(b, r.platform), r.encoding), r.language), r.id)
+		b = u16(u16(b, len(r.data)), off)
+	}
+	return append(b, storage...)
+}
+
+// bytesIndex returns where sub first is in b, if it is not empty, or -1.
+func bytesIndex(b, sub []byte) int {
+	if len(sub) == 0 {
+		return -1
+	}
+	for i := 0; i+len(sub) <= len(b); i++ {
+		if string(b[i:i+len(sub)]) == string(sub) {
+			return i
+		}
+	}
+	return -1
+}
+
+// os2 returns an OS/2 table of version 0 to 5, each as long as its
+// version makes it.
+func (f *face) os2() []byte {
+	s := f.s
+	version := s.Intn(6)
+	size := [...]int{78, 86, 96, 96, 96, 100}[version]
+	if f.bad() {
+		size = gen.Pick(s, 0, 1, 2, 68, 90)
+	}
+	b := u16(nil, version)
+	b = u16(b, f.upem/2)                   // xAvgCharWidth
+	b = u16(b, gen.Pick(s, 400, 700, 100)) // usWeightClass
+	b = u16(b, 5)                          // usWidthClass
+	b = u16(b, 0)                          // fsType
+	b = append(b, make([]byte, 20)...)     // subscript, superscript and strikeout
+	b = u16(b, 0)                          // sFamilyClass
+	b = append(b, make([]byte, 10)...)     // panose
+	for range 4 {
+		b = u32(b, int(s.Uint64())) // ulUnicodeRange
+	}
+	b = append(b, "FUZZ"...)
+	b = u16(b, 0x40)
+	first, last := 0xffff, 0
+	if len(f.chars) > 0 {
+		first, last = min(int(f.chars[0].r), 0xffff), min(int(f.chars[len(f.chars)-1].r), 0xffff)
+	}
+	b = u16(u16(b, first), last)
+	b = u16(u16(u16(b, f.bbox[3]), f.bbox[1]), 0)                  // sTypo ascender, descender and line gap
+	b = u16(u16(b, f.bbox[3]), -f.bbox[1])                         // usWin ascent and descent
+	b = u32(u32(b, 1), 0)                                          // ulCodePageRange
+	b = u16(b, gen.Pick(s, f.upem/2, 0, -f.upem, s.Intn(0x10000))) // sxHeight
+	b = u16(b, gen.Pick(s, f.upem*7/10, 0, -1, s.Intn(0x10000)))   // sCapHeight
+	b = u16(u16(u16(b, 0), 0x20), 2)                               // default and break chars, max context
+	b = u16(u16(b, 0), 0xffff)                                     // optical point sizes
+	for len(b) < size {
+		b = append(b, 0)
+	}
+	return b[:size]
+}
+
+// kern returns a kern table of version 0 with one subtable of format 0:
+// pairs of glyphs sorted by their indexes, and how far apart to move
+// them; or, now and then, an Apple kern table of version 1, or a
+// subtable of another format or for vertical kerning.
+func (f *face) kern() []byte {
+	s := f.s
+	type pair struct{ left, right, value int }
+	var pairs []pair
+	seen := map[[2]int]bool{}
+	for range s.Intn(min(f.glyphs*f.glyphs, 200) + 1) {
+		p := pair{s.Intn(f.glyphs), s.Intn(f.glyphs), s.Range(-f.upem/4, f.upem/4)}
+		if !seen[[2]int{p.left, p.right}] {
+			seen[[2]int{p.left, p.right}] = true
+			pairs = append(pairs, p)
+		}
+	}
+	slices.SortFunc(pairs, func(a, b pair) int {
+		if a.left != b.left {
+			return a.left - b.left
+		}
+		return a.right - b.right
+	})
+	if len(pairs) > 1 {
+		switch {
+		case f.bad():
+			i := s.Intn(len(pairs) - 1)
+			pairs[i], pairs[i+1] = pairs[i+1], pairs[i]
+		case f.bad():
+			i := s.Intn(len(pairs) - 1)
+			pairs[i+1] = pairs[i]
+		}
+	}
+	if f.bad() {
+		return append(u32(nil, 0x00010000), u32(nil, 0)...)
+	}
+	b := u16(u16(nil, 0), gen.Pick(s, 1, 1, 1, 2, 0))
+	sub := u16(nil, 0)
+	length := 14 + 6*len(pairs)
+	if f.bad() {
+		length = gen.Pick(s, length+6, length-6, 0, 0xffff)
+	}
+	sub = u16(sub, length)
+	coverage := 0x0001
+	if f.bad() {
+		coverage = gen.Pick(s, 0x0000, 0x0201, 0x0003, 0x0005)
+	}
+	sub = u16(sub, coverage)
+	n := len(pairs)
+	if f.bad() {
+		n = gen.Pick(s, n+1, 0xffff)
+	}
+	sub = u16(sub, n)
+	sel := 0
+	for 2<<sel <= n {
+		sel++
+	}
+	sub = u16(u16(u16(sub, 6<<sel), sel), 6*n-6<<sel)
+	for _, p := range pairs {
+		sub = u16(u16(u16(sub, p.left), p.right), p.value)
+	}
+	return append(b, sub...)
+}
+
+// gpos returns a GPOS table whose "kern" feature, for the Latin or the
+// default script, has pair adjustment lookups: by pairs of glyphs, or by
+// classes of them, with coverage as a list or as ranges, now and then
+// behind an extension lookup.
+func (f *face) gpos() []byte {
+	s := f.s
+	var subs [][]byte
+	for range s.Range(1, 3) {
+		if s.Chance(0.5) {
+			subs = append(subs, f.pairPosGlyphs())
+		} else {
+			subs = append(subs, f.pairPosClasses())
+		}
+	}
+	// The lookup list: one lookup of type 2, or of type 9 whose
+	// subtables point on to ones of type 2.
+	extension := s.Chance(0.2)
+	lookupType := 2
+	if extension {
+		lookupType = 9
+	}
+	if f.bad() {
+		lookupType = gen.Pick(s, 1, 3, 8)
+	}
+	lookup := u16(u16(u16(nil, lookupType), gen.Pick(s, 0, 0, 0x0010, 0x0008)), len(subs))
+	at := 6 + 2*len(subs)
+	var body []byte
+	for _, sub := range subs {
+		lookup = u16(lookup, at+len(body))
+		if extension {
+			ext := u32(u16(u16(nil, 1), 2), 8)
+			sub = append(ext, sub...)
+		}
+		body = append(body, sub...)
+	}
+	lookup = append(lookup, body...)
+	lookups := u16(u16(nil, 1), 4)
+	lookupIndex := 0
+	if f.bad() {
+		lookupIndex = gen.Pick(s, 1, 2, 0xffff)
+	}
+	lookups = append(lookups, lookup...)
+
+	// The feature list: "kern", and now and then another before it.
+	var features []byte
+	featureIndex := 0
+	if s.Chance(0.3) {
+		features = u16(nil, 2)
+		features = u16(append(features, "liga"...), 14)
+		features = u16(append(features, "kern"...), 20)
+		features = u16(u16(u16(features, 0), 1), 0)
+		featureIndex = 1
+	} else {
+		features = u16(nil, 1)
+		features = u16(append(features, "kern"...), 8)
+	}
+	features = u16(u16(u16(features, 0), 1), lookupIndex)
+	if f.bad() {
+		featureIndex = gen.Pick(s, featureIndex+1, featureIndex+2, 0xffff)
+	}
+
+	// The script list: the Latin or default script, whose default
+	// language system has the feature.
+	scripts := u16(nil, 1)
+	scripts = u16(append(scripts, gen.Pick(s, "latn", "DFLT", "cyrl")...), 8)
+	scripts = u16(scripts, 4)                                 // defaultLangSys
+	scripts = u16(u16(scripts, 0), 0)                         // langSysCount, and lookupOrder
+	scripts = u16(u16(u16(scripts, 0xffff), 1), featureIndex) // requiredFeatureIndex and the features
+
+	b := u16(u16(nil, 1), 0)
+	if f.bad() {
+		b = u16(u16(nil, 1), 2)
+	}
+	b = u16(b, 10)
+	b = u16(b, 10+len(scripts))
+	b = u16(b, 10+len(scripts)+len(features))
+	b = append(b, scripts...)
+	b = append(b, features...)
+	return append(b, lookups...)
+}
+
+// coverage returns a coverage table of glyphs, sorted: a list, or ranges
+// of them with the coverage index each starts at.
+func (f *face) coverage(glyphs []int) []byte {
+	s := f.s
+	if len(glyphs) > 1 && f.bad() {
+		i := s.Intn(len(glyphs) - 1)
+		glyphs[i], glyphs[i+1] = glyphs[i+1], glyphs[i]
+	}
+	if s.Chance(0.5) {
+		b := u16(u16(nil, 1), len(glyphs))
+		for _, g := range glyphs {
+			b = u16(b, g)
+		}
+		return b
+	}
+	var ranges [][3]int
+	for i, g := range glyphs {
+		if n := len(ranges); n > 0 && ranges[n-1][1]+1 == g {
+			ranges[n-1][1] = g
+			continue
+		}
+		ranges = append(ranges, [3]int{g, g, i})
+	}
+	b := u16(u16(nil, 2), len(ranges))
+	for _, r := range ranges {
+		b = u16(u16(u16(b, r[0]), r[1]), r[2])
+	}
+	return b
+}
+
+// distinct returns up to n glyphs of f, sorted and without repeats.
+func (f *face) distinct(n int) []int {
+	seen := map[int]bool{}
+	var gs []int
+	for range n {
+		g := f.s.Intn(f.glyphs)
+		if !seen[g] {
+			seen[g] = true
+			gs = append(gs, g)
+		}
+	}
+	slices.Sort(gs)
+	return gs
+}
+
+// pairPosGlyphs returns a pair adjustment subtable of format 1: for each
+// first glyph its coverage has, a set of second glyphs, sorted, and the
+// advance to add between them.
+func (f *face) pairPosGlyphs() []byte {
+	s := f.s
+	firsts := f.distinct(s.Range(1, 8))
+	valueFormat := 0x0004
+	if f.bad() {
+		valueFormat = gen.Pick(s, 0x0001, 0x0005, 0)
+	}
+	head := u16(u16(u16(u16(nil, 1), 0), valueFormat), 0)
+	head = u16(head, len(firsts))
+	at := len(head) + 2*len(firsts)
+	var sets []byte
+	for range firsts {
+		off := at + len(sets)
+		if f.bad() {
+			off = gen.Pick(s, 0xffff, at+len(sets)+s.Range(1, 64))
+		}
+		head = u16(head, off)
+		seconds := f.distinct(s.Range(0, 8))
+		count := len(seconds)
+		if f.bad() {
+			count += s.Range(1, 16)
+		}
+		sets = u16(sets, count)
+		for _, g := range seconds {
+			sets = u16(u16(sets, g), s.Range(-f.upem/4, f.upem/4))
+		}
+	}
+	b := append(head, sets...)
+	put16(b[2:], len(b))
+	return append(b, f.coverage(firsts)...)
+}
+
+// pairPosClasses returns a pair adjustment subtable of format 2: classes
+// of first and second glyphs, by a class definition of format 1 or 2,
+// and the advance to add between glyphs of each pair of classes.
+func (f *face) pairPosClasses() []byte {
+	s := f.s
+	n1, n2 := s.Range(1, 4), s.Range(1, 4)
+	firsts := f.distinct(s.Range(1, 8))
+	b := u16(u16(u16(u16(nil, 2), 0), 0x0004), 0)
+	b = u16(u16(u16(u16(b, 0), 0), n1), n2)
+	for range n1 * n2 {
+		b = u16(b, s.Range(-f.upem/4, f.upem/4))
+	}
+	put16(b[2:], len(b))
+	b = append(b, f.coverage(firsts)...)
+	put16(b[8:], len(b))
+	b = append(b, f.classDef(firsts, n1)...)
+	put16(b[10:], len(b))
+	return append(b, f.classDef(f.distinct(s.Range(1, 8)), n2)...)
+}
+
+// classDef returns a class definition giving each of glyphs a class
+// below n: of format 1, a class for each glyph from the first to the
+// last, or of format 2, ranges of glyphs in a class.
+func (f *face) classDef(glyphs []int, n int) []byte {
+	s := f.s
+	class := func() int {
+		if f.bad() {
+			return n + s.Intn(8)
+		}
+		return s.Intn(n)
+	}
+	if s.Chance(0.5) {
+		first, last := glyphs[0], glyphs[len(glyphs)-1]
+		b := u16(u16(u16(nil, 1), first), last-first+1)
+		for range last - first + 1 {
+			b = u16(b, class())
+		}
+		return b
+	}
+	b := u16(u16(nil, 2), len(glyphs))
+	for _, g := range glyphs {
+		b = u16(u16(u16(b, g), g), class())
+	}
+	return b
+}
@@ -0,0 +1,482 @@
+package fontsrc
+
+import (
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "font/ttf",
+		Doc:  "TrueType fonts: simple glyphs of quadratic contours with packed flags and short and long deltas, compound glyphs moved, scaled and transformed, short and long loca, cmap formats 0, 4, 6 and 12, name, post, OS/2, kern and GPOS pair adjustment, with compound glyphs that loop, nest too deep or fan out into millions of points, contours that disagree with their points, and table directories out of order or past the end",
+		Func: ttfSeed,
+	})
+}
+
+func ttfSeed(s *gen.State) []gen.File {
+	f := newFace(s, numGlyphs(s))
+	return []gen.File{{Name: "input.ttf", Data: fontFile(s, f.trueType())}}
+}
+
+// trueType returns a font of f with TrueType outlines in glyf and loca
+// tables, or now and then none but color bitmaps.
+func (f *face) trueType() *font {
+	var ts []*table
+	if f.s.Chance(0.03) {
+		// An empty CBLC table of version 3, and the CBDT it indexes.
+		f.bbox = [4]int{0, -f.upem / 5, f.upem, f.upem * 4 / 5}
+		ts = []*table{{"CBLC", u32(u32(nil, 0x00030000), 0)}, {"CBDT", u32(nil, 0x00030000)}}
+	} else {
+		glyf, loca := f.glyfLoca(f.outlines())
+		ts = []*table{{"glyf", glyf}, {"loca", loca}}
+	}
+	return &font{version: "\x00\x01\x00\x00", tables: append(ts, f.common(false)...)}
+}
+
+// A glyph is a TrueType glyph as the generator builds it: contours of
+// points, or components placing other glyphs. A glyph with neither is
+// empty, as a space is.
+type glyph struct {
+	contours   [][]point
+	components []component
+}
+
+// A point is a point of a contour, on the curve or a control point off
+// it.
+type point struct {
+	x, y int
+	on   bool
+}
+
+// A component places a glyph in a compound glyph, moved by dx and dy,
+// and scaled or transformed by transform, F2Dot14 numbers: none, one
+// scale, scales for x and y, or a two by two matrix.
+type component struct {
+	glyph     int
+	dx, dy    int
+	transform []int
+	// flags are those of a component's flags that do not follow from
+	// the rest: rounding, metrics and overlap.
+	flags int
+}
+
+// The flags of a compound glyph's components.
+const (
+	argsAreWords    = 0x0001
+	argsAreXY       = 0x0002
+	roundXYToGrid   = 0x0004
+	haveScale       = 0x0008
+	moreComponents  = 0x0020
+	haveXYScale     = 0x0040
+	haveTwoByTwo    = 0x0080
+	haveInstr       = 0x0100
+	useMyMetrics    = 0x0200
+	overlapCompound = 0x0400
+	scaledOffset    = 0x0800
+	unscaledOffset  = 0x1000
+)
+
+// outlines returns the glyphs of f: a box or nothing for .notdef, and
+// simple glyphs, empty ones and compound ones of the glyphs before them.
+// Now and then a compound glyph refers to itself, two loop through each
+// other, a chain of them nests deeper than a reader follows, or each
+// level of a chain refers to the next many times over.
+func (f *face) outlines() []*glyph {
+	s := f.s
+	gs := make([]*glyph, f.glyphs)
+	for i := range gs {
+		switch {
+		case i == 0 && s.Chance(0.8):
+			w, h := f.upem/2, f.upem*7/10
+			gs[0] = &glyph{contours: [][]point{
+				{{0, 0, true}, {0, h, true}, {w, h, true}, {w, 0, true}},
+				{{w / 10, h / 10, true}, {w * 9 / 10, h / 10, true}, {w * 9 / 10, h * 9 / 10, true}, {w / 10, h * 9 / 10, true}},
+			}}
+		case i == 0 || s.Chance(0.1):
+			gs[i] = &glyph{}
+		case i > 1 && s.Chance(0.2):
+			g := &glyph{}
+			for range gen.Pick(s, 1, 1, 2, 2, 3, s.Range(4, 12)) {
+				g.components = append(g.components, f.component(s.Intn(i)))
+			}
+			gs[i] = g
+		default:
+			gs[i] = f.simple()
+		}
+	}
+	n := len(gs)
+	switch {
+	case n > 1 && s.Chance(0.03):
+		i := s.Range(1, n-1)
+		gs[i] = &glyph{components: []component{f.component(i), f.component(s.Intn(n))}}
+		gen.Shuffle(s, gs[i].components)
+	case n > 2 && s.Chance(0.03):
+		i, j := s.Range(1, n-1), s.Range(1, n-1)
+		gs[i] = &glyph{components: []component{f.component(j)}}
+		gs[j] = &glyph{components: []component{f.component(i), f.component(0)}}
+	case n > 2 && s.Chance(0.03):
+		// A chain deeper than the eight levels a reader follows, if
+		// there are glyphs enough.
+		for i := 1; i < min(n-1, 12); i++ {
+			gs[i] = &glyph{components: []component{f.component(i + 1)}}
+		}
+		gs[min(n-1, 12)] = f.simple()
+	case n > 2 && s.Chance(0.03):
+		// Each level refers to the next k times, so that the first is
+		// k to the power of the levels copies of the last, a triangle:
+		// up to half a million of them, within the 64 components a
+		// reader keeps track of at once.
+		shape := gen.Pick(s, [2]int{3, 8}, [2]int{5, 8}, [2]int{7, 4}, [2]int{6, 9})
+		levels, k := min(n-2, shape[0]), shape[1]
+		for i := 1; i <= levels; i++ {
+			g := &glyph{}
+			for range k {
+				g.components = append(g.components, f.component(i+1))
+			}
+			gs[i] = g
+		}
+		gs[levels+1] = &glyph{contours: [][]point{{{0, 0, true}, {f.upem / 2, f.upem, true}, {f.upem, 0, true}}}}
+	}
+	return gs
+}
+
+// simple returns a glyph of a few contours, each a ring of points on
+// and off the curve around a center, or now and then one of many
+// points.
+func (f *face) simple() *glyph {
+	s := f.s
+	g := &glyph{}
+	for range gen.Pick(s, 1, 1, 2, 3, s.Range(1, 20)) {
+		n := gen.Pick(s, 1, 2, 3, 4, s.Range(3, 24), s.Range(3, 24))
+		if s.Chance(0.02) {
+			n = s.Range(100, 400)
+		}
+		cx, cy := s.Intn(f.upem+1), s.Intn(f.upem+1)
+		offCurve := gen.Pick(s, 0.0, 0.3, 0.5, 1.0)
+		c := make([]point, n)
+		for i := range c {
+			r := s.Range(1, max(f.upem/2, 1))
+			x, y := ring(i, n, r)
+			c[i] = point{cx + x, cy + y, !s.Chance(offCurve)}
+			if s.Chance(0.01) {
+				c[i].x, c[i].y = gen.Pick(s, -16384, 16383, 0), gen.Pick(s, -16384, 16383, 0)
+			}
+		}
+		g.contours = append(g.contours, c)
+	}
+	return g
+}
+
+// ring returns the ith of n points spaced about a circle of radius r,
+// taken as an octagon, which needs no trigonometry.
+func ring(i, n, r int) (int, int) {
+	oct := [8][2]int{{2, 0}, {1, 1}, {0, 2}, {-1, 1}, {-2, 0}, {-1, -1}, {0, -2}, {1, -1}}
+	a := 8 * i / n
+	p := oct[a]
+	q := oct[(a+1)%8]
+	t := 8 * i % n
+	return (p[0]*(n-t) + q[0]*t) * r / (2 * n), (p[1]*(n-t) + q[1]*t) * r / (2 * n)
+}
+
+// component returns a component placing glyph g, moved a little or a
+// lot, and now and then scaled or transformed.
+func (f *face) component(g int) component {
+	s := f.s
+	c := component{glyph: g}
+	if s.Chance(0.5) {
+		c.dx, c.dy = s.Range(-127, 127), s.Range(-127, 127)
+	} else {
+		c.dx, c.dy = s.Range(-f.upem, f.upem), s.Range(-f.upem, f.upem)
+	}
+	f2dot14 := func() int { return gen.Pick(s, 0x4000, 0x2000, -0x4000, 0, 0x7fff, s.Range(-0x8000, 0x7fff)) }
+	switch s.Intn(6) {
+	case 0:
+		c.transform = []int{f2dot14()}
+	case 1:
+		c.transform = []int{f2dot14(), f2dot14()}
+	case 2:
+		c.transform = []int{f2dot14(), f2dot14(), f2dot14(), f2dot14()}
+	}
+	if s.Chance(0.2) {
+		c.flags |= gen.Pick(s, roundXYToGrid, useMyMetrics, overlapCompound, scaledOffset, unscaledOffset)
+	}
+	return c
+}
+
+// glyfLoca returns the glyf and loca tables of gs, and sets f.bbox to
+// the bounds of every glyph. A few break them: locations out of order or
+// past the end of glyf, and too many of them or too few.
+func (f *face) glyfLoca(gs []*glyph) (glyf, loca []byte) {
+	s := f.s
+	bounds := make([][4]int, len(gs))
+	for i, g := range gs {
+		bounds[i] = box(gs, i, map[int]bool{})
+		if i == 0 || g != nil && (len(g.contours) > 0 || len(g.components) > 0) && bounds[i] != [4]int{} {
+			f.bbox = union(f.bbox, bounds[i])
+		}
+	}
+	// Glyphs are aligned to four bytes, or to the two a short loca
+	// needs; an empty glyph has no data, not even padding.
+	align := gen.Pick(s, 2, 4, 4)
+	locs := make([]int, 0, len(gs)+1)
+	for i, g := range gs {
+		locs = append(locs, len(glyf))
+		glyf = append(glyf, f.encode(g, bounds[i])...)
+		for len(glyf)%align != 0 {
+			glyf = append(glyf, 0)
+		}
+	}
+	locs = append(locs, len(glyf))
+	if len(glyf) > 0x1fffe {
+		f.longLoca = true
+	}
+	switch {
+	case len(locs) > 2 && f.bad():
+		i := s.Range(1, len(locs)-2)
+		locs[i], locs[i+1] = locs[i+1], locs[i]
+	case f.bad():
+		locs[s.Intn(len(locs))] = len(glyf) + gen.Pick(s, 2, 0x100, 0x1fffe)
+	case f.bad():
+		locs = locs[:len(locs)-1]
+	case f.bad():
+		locs = append(locs, len(glyf))
+	}
+	for _, l := range locs {
+		if f.longLoca {
+			loca = u32(loca, l)
+		} else {
+			loca = u16(loca, l/2)
+		}
+	}
+	return glyf, loca
+}
+
+// box returns the bounds of the ith of gs, a compound glyph's as far as
+// its components do not loop.
+func box(gs []*glyph, i int, seen map[int]bool) [4]int {
+	g := gs[i]
+	if g == nil || seen[i] {
+		return [4]int{}
+	}
+	seen[i] = true
+	defer delete(seen, i)
+	var b [4]int
+	first := true
+	for _, c := range g.contours {
+		for _, p := range c {
+			if first {
+				b, first = [4]int{p.x, p.y, p.x, p.y}, false
+			}
+			b = union(b, [4]int{p.x, p.y, p.x, p.y})
+		}
+	}
+	for _, c := range g.components {
+		if c.glyph >= len(gs) || seen[c.glyph] {
+			continue
+		}
+		cb := box(gs, c.glyph, seen)
+		cb = [4]int{cb[0] + c.dx, cb[1] + c.dy, cb[2] + c.dx, cb[3] + c.dy}
+		if first {
+			b, first = cb, false
+		}
+		b = union(b, cb)
+	}
+	return b
+}
+
+// union returns the bounds of two boxes together.
+func union(a, b [4]int) [4]int {
+	return [4]int{min(a[0], b[0]), min(a[1], b[1]), max(a[2], b[2]), max(a[3], b[3])}
+}
+
+// The flags of a simple glyph's points.
+const (
+	onCurve     = 0x01
+	xShort      = 0x02
+	yShort      = 0x04
+	repeat      = 0x08
+	xSameOrPos  = 0x10
+	ySameOrPos  = 0x20
+	overlapping = 0x40
+)
+
+// encode returns the data of g in glyf, whose bounds are b: nothing for
+// an empty glyph, or a header and the contours or components.
+func (f *face) encode(g *glyph, b [4]int) []byte {
+	s := f.s
+	if g == nil || len(g.contours) == 0 && len(g.components) == 0 {
+		if s.Chance(0.1) {
+			// A glyph of no contours that still has a header.
+			return u16(u16(u16(u16(u16(nil, 0), 0), 0), 0), 0)
+		}
+		return nil
+	}
+	contours := len(g.contours)
+	if len(g.components) > 0 {
+		contours = -1
+	}
+	if f.bad() {
+		contours = gen.Pick(s, -2, -0x8000, contours+1, 0x7fff)
+	}
+	out := u16(nil, contours)
+	for _, v := range b {
+		out = u16(out, v)
+	}
+	if len(g.components) > 0 {
+		return f.encodeCompound(out, g)
+	}
+	return f.encodeSimple(out, g)
+}
+
+// encodeSimple appends to out the contours of g: where each ends, its
+// instructions, and the flags and coordinates of its points, the flags
+// packed by repeating them and the coordinates as deltas of a byte and
+// a sign, of two bytes, or none.
+func (f *face) encodeSimple(out []byte, g *glyph) []byte {
+	s := f.s
+	end := -1
+	var ends []int
+	var pts []point
+	for _, c := range g.contours {
+		end += len(c)
+		ends = append(ends, end)
+		pts = append(pts, c...)
+	}
+	switch {
+	case len(ends) > 1 && f.bad():
+		i := s.Intn(len(ends) - 1)
+		ends[i], ends[i+1] = ends[i+1], ends[i]
+	case f.bad():
+		ends[len(ends)-1] += gen.Pick(s, 1, -1, 1000)
+	}
+	for _, e := range ends {
+		out = u16(out, e)
+	}
+	instr := 0
+	if s.Chance(0.3) {
+		instr = s.Range(1, 32)
+	}
+	n := instr
+	if f.bad() {
+		n += s.Range(1, 0x1000)
+	}
+	out = append(u16(out, n), samples(s, instr)...)
+
+	var flags []byte
+	var xs, ys []byte
+	x, y := 0, 0
+	for i, p := range pts {
+		flag := 0
+		if p.on {
+			flag |= onCurve
+		}
+		if i == 0 && s.Chance(0.1) {
+			flag |= overlapping
+		}
+		flag, xs = delta(s, flag, xs, p.x-x, xShort, xSameOrPos)
+		flag, ys = delta(s, flag, ys, p.y-y, yShort, ySameOrPos)
+		x, y = p.x, p.y
+		flags = append(flags, byte(flag))
+	}
+	out = append(out, packFlags(s, flags, f.bad())...)
+	data := append(xs, ys...)
+	if f.bad() && len(data) > 0 {
+		data = data[:s.Intn(len(data))]
+	}
+	return append(out, data...)
+}
+
+// delta appends d, a delta of one coordinate, to coords, and sets the
+// bits of flag for it: short and its sign if it fits in a byte, same
+// and nothing appended if it is zero, and two bytes otherwise.
+func delta(s *gen.State, flag int, coords []byte, d, short, sameOrPos int) (int, []byte) {
+	switch {
+	case d == 0 && s.Chance(0.9):
+		return flag | sameOrPos, coords
+	case d > -256 && d < 256 && s.Chance(0.9):
+		flag |= short
+		if d >= 0 {
+			flag |= sameOrPos
+		} else {
+			d = -d
+		}
+		return flag, append(coords, byte(d))
+	}
+	return flag, u16(coords, d)
+}
+
+// packFlags returns flags with runs of the same flag packed as one with
+// the repeat bit and a count; if bad, now and then a count runs past
+// the points.
+func packFlags(s *gen.State, flags []byte, bad bool) []byte {
+	var out []byte
+	for i := 0; i < len(flags); {
+		j := i + 1
+		for j < len(flags) && flags[j] == flags[i] && j-i <= 255 {
+			j++
+		}
+		if j-i > 1 && s.Chance(0.9) {
+			count := j - i - 1
+			if bad {
+				count = min(count+s.Range(1, 8), 255)
+			}
+			out = append(out, flags[i]|repeat, byte(count))
+		} else {
+			for k := i; k < j; k++ {
+				out = append(out, flags[k])
+			}
+		}
+		i = j
+	}
+	return out
+}
+
+// encodeCompound appends to out the components of g, each with its
+// flags, glyph, offsets of one byte or two, and transform, and the
+// instructions after the last. A few break them: offsets that are
+// points to match rather than distances, and a last component that
+// says there are more.
+func (f *face) encodeCompound(out []byte, g *glyph) []byte {
+	s := f.s
+	instr := s.Chance(0.1)
+	for i, c := range g.components {
+		flags := argsAreXY | c.flags
+		if f.bad() {
+			flags &^= argsAreXY
+		}
+		words := c.dx < -128 || c.dx > 127 || c.dy < -128 || c.dy > 127 || s.Chance(0.1)
+		if words {
+			flags |= argsAreWords
+		}
+		switch len(c.transform) {
+		case 1:
+			flags |= haveScale
+		case 2:
+			flags |= haveXYScale
+		case 4:
+			flags |= haveTwoByTwo
+		}
+		if i < len(g.components)-1 || f.bad() {
+			flags |= moreComponents
+		} else if instr {
+			flags |= haveInstr
+		}
+		glyph := c.glyph
+		if f.bad() {
+			glyph = gen.Pick(s, f.glyphs, f.glyphs+1, 0xffff)
+		}
+		out = u16(u16(out, flags), glyph)
+		if words {
+			out = u16(u16(out, c.dx), c.dy)
+		} else {
+			out = append(out, byte(c.dx), byte(c.dy))
+		}
+		for _, v := range c.transform {
+			out = u16(out, v)
+		}
+	}
+	if instr {
+		n := s.Range(1, 16)
+		out = append(u16(out, n), samples(s, n)...)
+	}
+	return out
+}
@@ -0,0 +1,92 @@
+package fontsrc
+
+import (
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "font/ttc",
+		Doc:  "font collections: a ttcf header of version 1 or 2 and TrueType and CFF fonts sharing their outlines and other tables, with font counts too large, offsets past the end or at the header, and the faults of the fonts in it",
+		Func: ttcSeed,
+	})
+}
+
+func ttcSeed(s *gen.State) []gen.File {
+	return []gen.File{{Name: "input.ttc", Data: collection(s)}}
+}
+
+// collection returns a font collection: a ttcf header, the offsets of
+// the fonts' directories, and their tables. Fonts of one face share its
+// outlines and metrics, each with names, character maps and glyph names
+// of its own, as the styles of a family might; some fonts have a face of
+// their own, and some are listed twice. A few break it: a count of fonts
+// too large, or none, and offsets past the end of the file or at the
+// header rather than a directory.
+func collection(s *gen.State) []byte {
+	var fonts []*font
+	for range gen.Pick(s, 1, 2, 2, 3, s.Range(1, 8)) {
+		switch {
+		case len(fonts) > 0 && s.Chance(0.1):
+			fonts = append(fonts, gen.Pick(s, fonts...))
+		case len(fonts) > 0 && s.Chance(0.6):
+			fonts = append(fonts, restyle(s, gen.Pick(s, fonts...)))
+		default:
+			f := newFace(s, numGlyphs(s))
+			if s.Chance(0.7) {
+				fonts = append(fonts, f.trueType())
+			} else {
+				fonts = append(fonts, f.openType())
+			}
+		}
+	}
+	version := gen.Pick(s, 1, 1, 2)
+	header := u16(u16([]byte("ttcf"), version), 0)
+	header = u32(header, len(fonts))
+	header = append(header, make([]byte, 4*len(fonts))...)
+	if version == 2 {
+		// No DSIG table: its tag, length and offset are zero.
+		header = append(header, make([]byte, 12)...)
+	}
+	b, dirs := writeFonts(s, header, fonts)
+	for i, d := range dirs {
+		if s.Chance(badRate * 2) {
+			d = gen.Pick(s, 0, 4, len(b), len(b)+0x1000, 0xffffffff)
+		}
+		binary.BigEndian.PutUint32(b[12+4*i:], uint32(d))
+	}
+	if s.Chance(badRate * 4) {
+		binary.BigEndian.PutUint32(b[8:], uint32(gen.Pick(s, 0, len(fonts)+1, 0x10000, 0xffffffff)))
+	}
+	return truncate(s, b)
+}
+
+// restyle returns a font sharing the outlines and metrics of f, with a
+// cmap, post, name and OS/2 table of its own.
+func restyle(s *gen.State, f *font) *font {
+	g := &font{version: f.version}
+	n := 1
+	if maxp := f.get("maxp"); maxp != nil && len(maxp.data) >= 6 {
+		n = max(int(binary.BigEndian.Uint16(maxp.data[4:])), 1)
+	}
+	face := newFace(s, n)
+	if head := f.get("head"); head != nil && len(head.data) >= 20 {
+		face.upem = max(int(binary.BigEndian.Uint16(head.data[18:])), 1)
+	}
+	for _, t := range f.tables {
+		switch t.tag {
+		case "cmap":
+			t = &table{t.tag, face.cmap()}
+		case "post":
+			t = &table{t.tag, face.post()}
+		case "name":
+			t = &table{t.tag, face.name()}
+		case "OS/2":
+			t = &table{t.tag, face.os2()}
+		}
+		g.tables = append(g.tables, t)
+	}
+	return g
+}
@@ -0,0 +1,261 @@
+// Package gen is the core of the seed generators: a registry that
+// generator modules add themselves to from init, and the per-seed State
+// they draw their random choices from.
+package gen
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// A File is one file of a generated seed. Name is a slash-separated path
+// relative to the seed's root.
+type File struct {
+	Name string
+	Data []byte
+}
+
+// A Generator produces seeds for one construct family.
+type Generator struct {
+	// Name identifies the generator, e.g. "go/builtins". The part before
+	// the slash names the language or format of the output.
+	Name string
+
+	// Doc is a one-line description shown by seedgen -list.
+	Doc string
+
+	// Dangerous marks generators whose seeds may corrupt memory or crash
+	// the runtime when built and run, such as unsafe.Pointer misuse or
+	// //go:linkname into runtime internals. Tools leave them out unless
+	// asked for them.
+	Dangerous bool
+
+	// Func emits the files of a single seed.
+	Func func(s *State) []File
+}
+
+var (
+	mu         sync.RWMutex
+	generators = map[string]*Generator{}
+)
+
+// Register adds g to the registry. It panics if a generator with the same
+// name was already registered.
+func Register(g *Generator) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := generators[g.Name]; dup {
+		panic("gen: Register called twice for " + g.Name)
+	}
+	generators[g.Name] = g
+}
+
+// Lookup returns the generator with the given name, or nil.
+func Lookup(name string) *Generator {
+	mu.RLock()
+	defer mu.RUnlock()
+	return generators[name]
+}
+
+// All returns every registered generator sorted by name.
+func All() []*Generator {
+	mu.RLock()
+	defer mu.RUnlock()
+	all := make([]*Generator, 0, len(generators))
+	for _, g := range generators {
+		all = append(all, g)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Match returns the registered generators whose names match any of the
+// path.Match patterns. An empty pattern list matches everything.
+func Match(patterns ...string) ([]*Generator, error) {
+	all := All()
+	if len(patterns) == 0 {
+		return all, nil
+	}
+	var out []*Generator
+	for _, g := range all {
+		for _, p := range patterns {
+			ok, err := path.Match(p, g.Name)
+			if err != nil {
+				return nil, fmt.Errorf("gen: bad pattern %q: %v", p, err)
+			}
+			if ok {
+				out = append(out, g)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// masterSeed is the master seed of Generate and Sample. It is 1, so that
+// fuzz targets are seeded with the same corpus on every run, unless the
+// environment variable GEN_SEED sets another. It is read on first use
+// rather than in init, so that go test sees the variable and does not
+// reuse results cached under another seed.
+var masterSeed = sync.OnceValue(func() uint64 {
+	v := os.Getenv("GEN_SEED")
+	if v == "" {
+		return 1
+	}
+	seed, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		panic(fmt.Sprintf("gen: bad GEN_SEED %q: %v", v, err))
+	}
+	return seed
+})
+
+// Generate writes seed i of g from a State seeded with the master seed
+// plus i: by default, seed i of `seedgen -seed 1`.
+func (g *Generator) Generate(i int) []File {
+	return g.Func(NewStateSeed(masterSeed() + uint64(i)))
+}
+
+// Sample generates seeds 0 to n-1 of each generator matching pattern and
+// returns the contents of every generated file whose name has the
+// extension ext. It is meant for seeding fuzz targets from the generated
+// corpus.
+func Sample(pattern, ext string, n int) [][]byte {
+	gens, err := Match(pattern)
+	if err != nil {
+		panic(err)
+	}
+	var out [][]byte
+	for _, g := range gens {
+		for i := range n {
+			for _, f := range g.Generate(i) {
+				if path.Ext(f.Name) == ext {
+					out = append(out, f.Data)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// originLine matches the header gen/gosrc puts at the top of each file.
+var originLine = regexp.MustCompile(`(?m)^// Code generated by seedgen \(([^,]+), seed (\d+)\)\. DO NOT EDIT\.$`)
+
+// Origin reads the header of a Go file generated by gen/gosrc and
+// returns the generator that wrote it and the seed of its State, which
+// NewStateSeed turns back into the same file. It reports false for files
+// without the header, such as minimized or hand-written ones.
+func Origin(src []byte) (generator string, seed uint64, ok bool) {
+	m := originLine.FindSubmatch(src)
+	if m == nil {
+		return "", 0, false
+	}
+	seed, err := strconv.ParseUint(string(m[2]), 10, 64)
+	if err != nil {
+		return "", 0, false
+	}
+	return string(m[1]), seed, true
+}
+
+// Limits bound how deeply generators nest recursive structure. A zero
+// field leaves the bound to the generator, which keeps it shallow; large
+// values (10000 and up) are for probing stack limits in the parser, type
+// checker and printer.
+type Limits struct {
+	Expr    int // parentheses, unary and binary operators, calls and index expressions
+	Literal int // composite literals inside composite literals
+	Generic int // type arguments, as in G[G[G[int]]]
+	Block   int // blocks, if/for/switch statements and function literals
+}
+
+// State carries the choices made while generating a single seed. Every
+// choice is drawn from a random source seeded with a single integer, so
+// a generator run again on a State with the same seed and Limits writes
+// the same seed files.
+type State struct {
+	// Limits is consulted through Depth by generators of recursive
+	// structure.
+	Limits Limits
+
+	seed  uint64
+	rand  *rand.Rand
+	names map[string]int
+}
+
+// NewState returns a State ready for one seed, with a random seed.
+func NewState() *State {
+	return NewStateSeed(rand.Uint64())
+}
+
+// NewStateSeed returns a State whose choices are determined by seed.
+func NewStateSeed(seed uint64) *State {
+	return &State{
+		seed:  seed,
+		rand:  rand.New(rand.NewPCG(seed, seed^0x6a09e667f3bcc909)),
+		names: map[string]int{},
+	}
+}
+
+// Seed returns the seed s was created with.
+func (s *State) Seed() uint64 {
+	return s.seed
+}
+
+// Intn returns a random int in [0, n).
+func (s *State) Intn(n int) int {
+	return s.rand.IntN(n)
+}
+
+// Range returns a random int in [lo, hi].
+func (s *State) Range(lo, hi int) int {
+	return lo + s.rand.IntN(hi-lo+1)
+}
+
+// Chance reports true with probability p.
+func (s *State) Chance(p float64) bool {
+	return s.rand.Float64() < p
+}
+
+// Uint64 returns a random 64-bit value.
+func (s *State) Uint64() uint64 {
+	return s.rand.Uint64()
+}
+
+// Depth returns a nesting depth for a structure bounded by limit, one of
+// the fields of s.Limits, or by def if limit is zero. A quarter of the
+// draws return the bound itself so that a large limit is actually reached.
+func (s *State) Depth(limit, def int) int {
+	if limit <= 0 {
+		limit = def
+	}
+	if s.Chance(0.25) {
+		return limit
+	}
+	return s.Range(1, limit)
+}
+
+// Fresh returns a name built from prefix that has not been handed out
+// before in this seed, e.g. "v0", "v1".
+func (s *State) Fresh(prefix string) string {
+	n := s.names[prefix]
+	s.names[prefix] = n + 1
+	return fmt.Sprintf("%s%d", prefix, n)
+}
+
+// Pick returns a random element of xs.
+func Pick[T any](s *State, xs ...T) T {
+	return xs[s.Intn(len(xs))]
+}
+
+// Shuffle permutes xs in place.
+func Shuffle[T any](s *State, xs []T) {
+	for i := len(xs) - 1; i > 0; i-- {
+		j := s.Intn(i + 1)
+		xs[i], xs[j] = xs[j], xs[i]
+	}
+}
@@ -0,0 +1,290 @@
+// Package gitsrc generates seeds of git's smart protocol. It registers
+// the "git/..." generators with package gen.
+//
+// "git/advertisement" writes the refs a server advertises, refs.pkt, as
+// pkt-lines: now and then behind the "# service=" line and flush of
+// smart HTTP, the first ref with the capability list after a NUL, or the
+// zero id and "capabilities^{}" for an empty repository, then the other
+// refs with tags followed by their peeled values, shallow lines and a
+// flush. Capabilities are those upload-pack and receive-pack send, with
+// symrefs, agents and object formats, and a few take values they must
+// not, lack ones they need or come twice.
+//
+// "git/upload-request" writes what a client sends to upload-pack,
+// request.pkt: wants, the first with the client's capabilities, shallow
+// lines, a deepen, deepen-since or deepen-not line, now and then a
+// filter, a flush and the haves and done that follow it.
+//
+// "git/pack" writes a packfile, input.pack: blobs, trees, commits and
+// tags, and offset and reference deltas of them, in chains now and then
+// tens of deltas deep, with copies from anywhere in the base, inserts
+// and copies of 64 KiB written as size zero, then the pack's SHA-1.
+//
+// Each writes a few inputs that break the format. Pkt-lines have
+// lengths that are not hexadecimal, are too short, overrun the input or
+// pass the largest git allows, or are the delim and response-end
+// packets of protocol v2; hashes are short or not hexadecimal, and ERR
+// lines stand where refs should. Packs have objects whose sizes lie or
+// take more bytes than they need, deltas whose bases are past the start
+// of the pack, between objects or not in it, that copy from outside the
+// base, use the reserved command or make more or less than they say,
+// the reserved object types, counts that do not match, broken zlib
+// streams, wrong checksums and junk after the end.
+package gitsrc
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "git/advertisement",
+		Doc:  "ref advertisements of git's smart protocol as pkt-lines: smart HTTP service lines, capability lists with symrefs and agents, empty repositories, peeled tags and shallows, and bad pkt-line lengths, hashes, capabilities and ERR lines",
+		Func: advertisement,
+	})
+	gen.Register(&gen.Generator{
+		Name: "git/upload-request",
+		Doc:  "upload-pack requests as pkt-lines: wants with the client's capabilities, shallows, deepen, deepen-since and deepen-not, filters, haves and done, and bad pkt-line lengths, hashes and depths",
+		Func: uploadRequest,
+	})
+}
+
+// badRate is the chance that a pkt-line, or a part of one, breaks the
+// format. An input has about ten lines, so about one in ten has one.
+const badRate = 0.01
+
+var (
+	// refNames are ref names a server advertises, a few of which
+	// check-ref-format would reject but the protocol carries.
+	refNames = []string{
+		"refs/heads/main", "refs/heads/master", "refs/heads/feature/x", "refs/heads/release-1.0",
+		"refs/tags/v1.0.0", "refs/tags/v2.0.0-rc.1", "refs/pull/1/head", "refs/pull/1/merge",
+		"refs/remotes/origin/HEAD", "refs/notes/commits", "refs/heads/ünïcode", "refs/heads/a..b",
+		"refs/heads/x.lock", "refs/stash",
+	}
+	// badRefNames are names that no ref line can carry whole.
+	badRefNames = []string{"", "refs/heads/with space", "refs/heads/a\x00b", "refs/heads/a\nb"}
+	// serverCaps are the capabilities upload-pack and receive-pack
+	// advertise.
+	serverCaps = []string{
+		"multi_ack", "thin-pack", "side-band", "side-band-64k", "ofs-delta", "shallow", "deepen-since",
+		"deepen-not", "deepen-relative", "no-progress", "include-tag", "multi_ack_detailed",
+		"allow-tip-sha1-in-want", "allow-reachable-sha1-in-want", "no-done", "filter",
+		"symref=HEAD:refs/heads/main", "object-format=sha1", "agent=git/2.45.2", "report-status",
+		"report-status-v2", "delete-refs", "quiet", "atomic", "push-options", "session-id=abc123",
+		"x-custom", "x-custom=value",
+	}
+	// clientCaps are the capabilities a client asks upload-pack for.
+	clientCaps = []string{
+		"multi_ack_detailed", "multi_ack", "side-band-64k", "side-band", "thin-pack", "ofs-delta",
+		"no-progress", "include-tag", "shallow", "deepen-since", "deepen-not", "filter", "no-done",
+		"agent=go-git/5.x", "agent=git/2.45.2",
+	}
+	// badCaps are capabilities with values they must not take, lacking
+	// the values they need or with empty ones, and empty names.
+	badCaps = []string{"ofs-delta=1", "agent", "symref", "agent=", "=", "multi_ack=x", "object-format="}
+)
+
+// A pgen writes pkt-lines.
+type pgen struct {
+	s *gen.State
+	b strings.Builder
+	// done is whether a line's length ran past the end of the input,
+	// after which nothing more can be written.
+	done bool
+}
+
+// line writes a pkt-line holding payload. Rarely its length is not
+// hexadecimal, is too short for the length itself, is one of the special
+// packets of protocol v2, overruns the input or passes the largest git
+// allows.
+func (p *pgen) line(payload string) {
+	s := p.s
+	if p.done {
+		return
+	}
+	n := len(payload) + 4
+	head := fmt.Sprintf("%04x", n)
+	if s.Chance(0.05) {
+		head = strings.ToUpper(head)
+	}
+	switch {
+	case s.Chance(badRate):
+		head = gen.Pick(s, "0001", "0002", "0003", "0004")
+	case s.Chance(badRate):
+		head = gen.Pick(s, "00g5", " 012", "-001", "0x10", head[:3]+"z", "+012")
+	case s.Chance(badRate):
+		head = fmt.Sprintf("%04x", n+gen.Pick(s, 1, 100, 0xffff-n))
+		p.done = true
+	case s.Chance(badRate):
+		payload = strings.Repeat("a", gen.Pick(s, 65516, 65517, 65520))
+		head = fmt.Sprintf("%04x", len(payload)+4)
+	}
+	p.b.WriteString(head + payload)
+}
+
+// flush writes a flush-pkt.
+func (p *pgen) flush() {
+	if !p.done {
+		p.b.WriteString("0000")
+	}
+}
+
+// eol returns the newline that ends most payloads, which the protocol
+// lets a sender leave off.
+func (p *pgen) eol() string {
+	if p.s.Chance(0.1) {
+		return ""
+	}
+	return "\n"
+}
+
+// hash returns an object id: lowercase hexadecimal, now and then
+// uppercase, and rarely short or not hexadecimal.
+func (p *pgen) hash() string {
+	s := p.s
+	b := make([]byte, 20)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	h := hex.EncodeToString(b)
+	if s.Chance(0.03) {
+		h = strings.ToUpper(h)
+	}
+	if s.Chance(badRate) {
+		h = gen.Pick(s, h[:39], h[:38]+"zz", h[:39]+"g", "0x"+h[2:], h+"0")
+	}
+	return h
+}
+
+// caps returns a capability list drawn from list, rarely with a bad
+// capability, one twice or spaces where they do not belong.
+func (p *pgen) caps(list []string) string {
+	s := p.s
+	c := append([]string(nil), list...)
+	gen.Shuffle(s, c)
+	c = c[:s.Intn(len(c)+1)]
+	if s.Chance(badRate * 2) {
+		c = append(c, gen.Pick(s, badCaps...))
+	}
+	if len(c) > 0 && s.Chance(badRate) {
+		c = append(c, c[0])
+	}
+	out := strings.Join(c, " ")
+	if s.Chance(badRate) {
+		out = gen.Pick(s, " "+out, out+" ", strings.ReplaceAll(out, " ", "  "))
+	}
+	return out
+}
+
+// refName returns a ref name, rarely one no line can carry.
+func (p *pgen) refName() string {
+	if p.s.Chance(badRate) {
+		return gen.Pick(p.s, badRefNames...)
+	}
+	return gen.Pick(p.s, refNames...)
+}
+
+// errLine writes, rarely, an ERR line where another is due.
+func (p *pgen) errLine() {
+	if p.s.Chance(badRate) {
+		p.line("ERR " + gen.Pick(p.s, "access denied", "repository not found", "") + p.eol())
+	}
+}
+
+// advertisement writes the refs a server advertises.
+func advertisement(s *gen.State) []gen.File {
+	p := &pgen{s: s}
+	if s.Chance(0.3) {
+		p.line("# service=" + gen.Pick(s, "git-upload-pack", "git-receive-pack") + "\n")
+		if !s.Chance(0.1) {
+			p.flush()
+		}
+	}
+	if s.Chance(badRate) {
+		p.line("version 1\n")
+	}
+	p.errLine()
+	caps := p.caps(serverCaps)
+	if s.Chance(0.1) {
+		// An empty repository.
+		p.line("0000000000000000000000000000000000000000 capabilities^{}\x00" + caps + p.eol())
+	} else {
+		first := "HEAD"
+		if s.Chance(0.3) {
+			first = p.refName()
+		}
+		sep := "\x00"
+		if s.Chance(badRate) {
+			sep = gen.Pick(s, "", " ", "\x00\x00")
+		}
+		p.line(p.hash() + " " + first + sep + caps + p.eol())
+		for range s.Range(0, 8) {
+			p.errLine()
+			name := p.refName()
+			p.line(p.hash() + " " + name + p.eol())
+			if strings.HasPrefix(name, "refs/tags/") && s.Chance(0.7) || s.Chance(badRate) {
+				p.line(p.hash() + " " + name + "^{}" + p.eol())
+			}
+		}
+	}
+	if s.Chance(0.15) {
+		for range s.Range(1, 3) {
+			p.line("shallow " + p.hash() + p.eol())
+		}
+	}
+	if !s.Chance(badRate) {
+		p.flush()
+	}
+	return []gen.File{{Name: "refs.pkt", Data: []byte(p.b.String())}}
+}
+
+// uploadRequest writes what a client sends to upload-pack.
+func uploadRequest(s *gen.State) []gen.File {
+	p := &pgen{s: s}
+	p.errLine()
+	caps := p.caps(clientCaps)
+	if caps != "" {
+		caps = " " + caps
+	}
+	p.line("want " + p.hash() + caps + p.eol())
+	for range s.Range(0, 4) {
+		p.line("want " + p.hash() + p.eol())
+	}
+	if s.Chance(0.2) {
+		for range s.Range(1, 3) {
+			p.line("shallow " + p.hash() + p.eol())
+		}
+	}
+	switch {
+	case s.Chance(badRate):
+		p.line(gen.Pick(s, "deepen -1", "deepen abc", "deepen 99999999999999999999", "deepen ", "deepen-since x", "deepen-since -5", "deepen-since 18446744073709551616", "deepen-relative") + p.eol())
+	case s.Chance(0.15):
+		p.line(fmt.Sprintf("deepen %d", gen.Pick(s, 1, 1, 2, 10, 0, 2147483647)) + p.eol())
+	case s.Chance(0.1):
+		p.line(fmt.Sprintf("deepen-since %d", gen.Pick(s, 1700000000, 0, 1, 4102444800)) + p.eol())
+	case s.Chance(0.1):
+		p.line("deepen-not " + p.refName() + p.eol())
+	}
+	if s.Chance(0.05) {
+		p.line("filter " + gen.Pick(s, "blob:none", "blob:limit=1k", "tree:0", "sparse:oid=main:.sparse") + p.eol())
+	}
+	if s.Chance(badRate) {
+		return []gen.File{{Name: "request.pkt", Data: []byte(p.b.String())}}
+	}
+	p.flush()
+	if s.Chance(0.5) {
+		for range s.Range(0, 6) {
+			p.line("have " + p.hash() + p.eol())
+		}
+		if s.Chance(0.5) {
+			p.flush()
+		}
+		p.line("done" + p.eol())
+	}
+	return []gen.File{{Name: "request.pkt", Data: []byte(p.b.String())}}
+}
@@ -0,0 +1,412 @@
+package gitsrc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "git/pack",
+		Doc:  "git packfiles: blobs, trees, commits and tags with offset and reference delta chains, copies of 64 KiB written as size zero, and lying sizes, overlong size varints, bases outside the pack, copies past the base, the reserved delta command, reserved types, bad counts, broken zlib, wrong checksums and trailing junk",
+		Func: pack,
+	})
+}
+
+// packBadRate is the chance that an object, or a delta's instruction,
+// breaks the format. A pack has a handful of objects, so about one in
+// ten is invalid.
+const packBadRate = 0.015
+
+// Object types, as a pack's object headers write them.
+const (
+	typeCommit   = 1
+	typeTree     = 2
+	typeBlob     = 3
+	typeTag      = 4
+	typeOfsDelta = 6
+	typeRefDelta = 7
+)
+
+var typeNames = map[int]string{typeCommit: "commit", typeTree: "tree", typeBlob: "blob", typeTag: "tag"}
+
+// An object is one in a pack, as it is once its deltas are resolved.
+type object struct {
+	typ  int
+	data []byte
+	id   [20]byte
+	// offset is where its entry starts in the pack.
+	offset int
+}
+
+// A kgen writes a pack.
+type kgen struct {
+	s       *gen.State
+	b       bytes.Buffer
+	objects []*object
+}
+
+// pack writes a packfile.
+func pack(s *gen.State) []gen.File {
+	k := &kgen{s: s}
+	// A base first, so that deltas have something to build on, then a
+	// mix of both.
+	k.base()
+	for range s.Range(0, 7) {
+		if s.Chance(0.5) {
+			k.base()
+		} else {
+			k.delta()
+		}
+	}
+	if s.Chance(0.05) {
+		// A chain tens of deltas deep.
+		for range s.Range(20, 60) {
+			k.deltaOn(k.objects[len(k.objects)-1], true)
+		}
+	}
+	n := uint32(len(k.objects))
+	if s.Chance(packBadRate) {
+		n = gen.Pick(s, n+1, n-1, 0, 1<<32-1)
+	}
+	version := uint32(gen.Pick(s, 2, 2, 2, 3))
+	if s.Chance(packBadRate) {
+		version = gen.Pick(s, uint32(0), 1, 4)
+	}
+	var head bytes.Buffer
+	head.WriteString("PACK")
+	if s.Chance(packBadRate) {
+		head.Reset()
+		head.WriteString(gen.Pick(s, "pack", "PACk", "KCAP"))
+	}
+	binary.Write(&head, binary.BigEndian, version)
+	binary.Write(&head, binary.BigEndian, n)
+	out := append(head.Bytes(), k.b.Bytes()...)
+	sum := sha1.Sum(out)
+	if s.Chance(packBadRate) {
+		sum[s.Intn(len(sum))] ^= 1
+	}
+	out = append(out, sum[:]...)
+	if s.Chance(packBadRate) {
+		out = append(out, gen.Pick(s, "junk", "\x00", "PACK\x00\x00\x00\x02\x00\x00\x00\x00")...)
+	}
+	if s.Chance(packBadRate) {
+		out = out[:s.Intn(len(out))]
+	}
+	return []gen.File{{Name: "input.pack", Data: out}}
+}
+
+// base writes a blob, tree, commit or tag whole.
+func (k *kgen) base() {
+	s := k.s
+	var o *object
+	switch s.Intn(6) {
+	case 0, 1:
+		o = k.blob()
+	case 2:
+		o = k.tree()
+	case 3, 4:
+		o = k.commit()
+	default:
+		o = k.tag()
+	}
+	typ := o.typ
+	if s.Chance(packBadRate) {
+		typ = gen.Pick(s, 0, 5)
+	}
+	k.entry(o, typ, nil, o.data)
+}
+
+// delta writes a delta on an object already written: usually its latest,
+// as a pack's deltas mostly follow what they change.
+func (k *kgen) delta() {
+	base := k.objects[len(k.objects)-1]
+	if k.s.Chance(0.4) {
+		base = gen.Pick(k.s, k.objects...)
+	}
+	k.deltaOn(base, k.s.Chance(0.7))
+}
+
+// deltaOn writes an offset or reference delta of a change to base.
+func (k *kgen) deltaOn(base *object, ofs bool) {
+	s := k.s
+	target := k.change(base.data)
+	o := &object{typ: base.typ, data: target}
+	o.id = id(o.typ, o.data)
+	d := k.instructions(base.data, target)
+	var ref []byte
+	typ := typeRefDelta
+	if ofs {
+		typ = typeOfsDelta
+		// The offset is back from the start of this entry's header.
+		back := k.b.Len() + 12 - base.offset
+		if s.Chance(packBadRate) {
+			back = gen.Pick(s, 0, back+1, back-1, k.b.Len()+12, k.b.Len()+13, 1<<40)
+		}
+		ref = offset(back)
+	} else {
+		h := base.id
+		if s.Chance(packBadRate) {
+			// A base the pack does not have, as in a thin pack.
+			h[0] ^= 0xff
+		}
+		ref = h[:]
+	}
+	k.entry(o, typ, ref, d)
+}
+
+// entry writes the entry of o: a header of typ and the size of data,
+// then ref for a delta, then data compressed. Now and then the size
+// takes more bytes than it needs; rarely it is wrong, or the zlib stream
+// is broken.
+func (k *kgen) entry(o *object, typ int, ref, data []byte) {
+	s := k.s
+	o.offset = k.b.Len() + 12
+	k.objects = append(k.objects, o)
+	size := uint64(len(data))
+	if s.Chance(packBadRate) {
+		size = gen.Pick(s, size+1, max(size, 1)-1, 0, 1<<40, 1<<63, 1<<64-1)
+	}
+	var e []byte
+	c := byte(typ<<4) | byte(size&0x0f)
+	size >>= 4
+	pad := s.Chance(0.03)
+	for size > 0 || pad {
+		e = append(e, c|0x80)
+		c = byte(size & 0x7f)
+		size >>= 7
+		if size == 0 && pad {
+			pad = false
+			e = append(e, c|0x80)
+			c = 0
+		}
+	}
+	e = append(e, c)
+	e = append(e, ref...)
+	var z bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&z, gen.Pick(s, zlib.DefaultCompression, zlib.NoCompression, zlib.BestSpeed, zlib.BestCompression))
+	w.Write(data)
+	w.Close()
+	zb := z.Bytes()
+	if s.Chance(packBadRate) {
+		switch s.Intn(3) {
+		case 0:
+			zb[s.Intn(len(zb))] ^= byte(s.Range(1, 255))
+		case 1:
+			zb = zb[:s.Intn(len(zb))]
+		default:
+			zb = zb[:len(zb)-4]
+		}
+	}
+	e = append(e, zb...)
+	k.b.Write(e)
+}
+
+// offset encodes the distance back to an offset delta's base as git
+// does: seven bits at a time, most significant first, with one added to
+// each group but the last.
+func offset(n int) []byte {
+	b := []byte{byte(n & 0x7f)}
+	for n >>= 7; n > 0; n >>= 7 {
+		n--
+		b = append([]byte{byte(0x80 | n&0x7f)}, b...)
+	}
+	return b
+}
+
+// id returns the object id of data of type typ.
+func id(typ int, data []byte) [20]byte {
+	return sha1.Sum(append([]byte(fmt.Sprintf("%s %d\x00", typeNames[typ], len(data))), data...))
+}
+
+// change returns data changed: with text inserted, deleted or repeated,
+// and now and then more than 64 KiB long, to need copies of that size.
+func (k *kgen) change(data []byte) []byte {
+	s := k.s
+	out := append([]byte(nil), data...)
+	for range s.Range(1, 4) {
+		i := s.Intn(len(out) + 1)
+		j := min(len(out), i+s.Intn(16))
+		switch s.Intn(3) {
+		case 0:
+			out = append(out[:i:i], append([]byte(gen.Pick(s, "x", "hello\n", "// comment\n", "\x00\xff")), out[i:]...)...)
+		case 1:
+			out = append(out[:i:i], out[j:]...)
+		default:
+			out = append(out[:j:j], append(append([]byte(nil), out[i:j]...), out[j:]...)...)
+		}
+	}
+	if s.Chance(0.03) {
+		out = append(out, bytes.Repeat(out, 0x10000/(len(out)+1)+1)...)
+	}
+	return out
+}
+
+// instructions returns a delta that makes target from base: the sizes of
+// both, then copies of the runs target shares with base and inserts of
+// what it does not. Rarely the sizes are wrong, a copy reaches outside
+// the base, the reserved command stands in for one, or bytes follow the
+// last instruction.
+func (k *kgen) instructions(base, target []byte) []byte {
+	s := k.s
+	srcSize, dstSize := uint64(len(base)), uint64(len(target))
+	if s.Chance(packBadRate) {
+		srcSize = gen.Pick(s, srcSize+1, max(srcSize, 1)-1, 1<<62)
+	}
+	if s.Chance(packBadRate) {
+		dstSize = gen.Pick(s, dstSize+1, max(dstSize, 1)-1, 0, 1<<40)
+	}
+	d := binary.AppendUvarint(nil, srcSize)
+	d = binary.AppendUvarint(d, dstSize)
+	for i := 0; i < len(target); {
+		// The longest run of base that target has here, of those at
+		// the same offset and a few others: a search of every offset
+		// is quadratic, and deltas of 64 KiB are common enough.
+		at, n := 0, 0
+		for c := range 16 {
+			j := i
+			if c > 0 && len(base) > 0 {
+				j = s.Intn(len(base))
+			}
+			m := 0
+			for i+m < len(target) && j+m < len(base) && target[i+m] == base[j+m] {
+				m++
+			}
+			if m > n {
+				at, n = j, m
+			}
+		}
+		if n >= 4 && !s.Chance(0.1) {
+			for n > 0 {
+				size := min(n, gen.Pick(s, 0x10000, 0x10000, 0xffffff))
+				if s.Chance(packBadRate) {
+					d = k.copy(d, uint64(len(base))-uint64(s.Intn(2)), uint64(size))
+				} else {
+					d = k.copy(d, uint64(at), uint64(size))
+				}
+				at, n, i = at+size, n-size, i+size
+			}
+			continue
+		}
+		m := min(len(target)-i, s.Range(1, 127))
+		if s.Chance(packBadRate) {
+			d = append(d, 0)
+		}
+		d = append(d, byte(m))
+		d = append(d, target[i:i+m]...)
+		i += m
+	}
+	if s.Chance(packBadRate) {
+		d = append(d, gen.Pick(s, []byte{0}, []byte{0x80}, []byte{1, 'x'}, []byte{0x91, 0, 1})...)
+	}
+	return d
+}
+
+// copy appends a copy instruction: a command with a bit for each byte of
+// the offset and size that is not zero, then those bytes. A size of 64
+// KiB is written as zero, and so takes no bytes at all.
+func (k *kgen) copy(d []byte, off, size uint64) []byte {
+	if size == 0x10000 && !k.s.Chance(0.1) {
+		size = 0
+	}
+	cmd := byte(0x80)
+	var args []byte
+	for i := range 4 {
+		if b := byte(off >> (8 * i)); b != 0 {
+			cmd |= 1 << i
+			args = append(args, b)
+		}
+	}
+	for i := range 3 {
+		if b := byte(size >> (8 * i)); b != 0 {
+			cmd |= 0x10 << i
+			args = append(args, b)
+		}
+	}
+	return append(append(d, cmd), args...)
+}
+
+// blob returns a blob of text or bytes.
+func (k *kgen) blob() *object {
+	s := k.s
+	var b strings.Builder
+	for range s.Range(0, 12) {
+		b.WriteString(gen.Pick(s, "package main\n", "func main() {}\n", "hello, world\n", "# README\n", "\x00\x01\x02\xff", "line\r\n", "日本語\n", ""))
+	}
+	o := &object{typ: typeBlob, data: []byte(b.String())}
+	o.id = id(o.typ, o.data)
+	return o
+}
+
+// tree returns a tree of entries for the objects written so far, and now
+// and then for ones not in the pack.
+func (k *kgen) tree() *object {
+	s := k.s
+	var b []byte
+	for i := range s.Range(0, 5) {
+		h := [20]byte{byte(i)}
+		if len(k.objects) > 0 && s.Chance(0.7) {
+			h = gen.Pick(s, k.objects...).id
+		}
+		b = fmt.Appendf(b, "%s %s\x00", gen.Pick(s, "100644", "100755", "40000", "120000", "160000"), gen.Pick(s, "main.go", "README.md", "dir", "link", fmt.Sprintf("f%d", i)))
+		b = append(b, h[:]...)
+	}
+	o := &object{typ: typeTree, data: b}
+	o.id = id(o.typ, o.data)
+	return o
+}
+
+// commit returns a commit, of a tree and parents in the pack when it has
+// them.
+func (k *kgen) commit() *object {
+	s := k.s
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %x\n", k.idOf(typeTree))
+	for range s.Range(0, 2) {
+		fmt.Fprintf(&b, "parent %x\n", k.idOf(typeCommit))
+	}
+	when := gen.Pick(s, "1700000000 +0000", "0 -0000", "1700000000 +0530", "99999999999 +1400")
+	fmt.Fprintf(&b, "author A U Thor <author@example.com> %s\ncommitter C O Mitter <committer@example.com> %s\n", when, when)
+	if s.Chance(0.1) {
+		b.WriteString("gpgsig -----BEGIN PGP SIGNATURE-----\n \n -----END PGP SIGNATURE-----\n")
+	}
+	b.WriteString("\n" + gen.Pick(s, "Initial commit\n", "Fix the build\n\nA longer body.\n", "", "no newline"))
+	o := &object{typ: typeCommit, data: []byte(b.String())}
+	o.id = id(o.typ, o.data)
+	return o
+}
+
+// tag returns an annotated tag of an object in the pack.
+func (k *kgen) tag() *object {
+	s := k.s
+	typ := gen.Pick(s, typeCommit, typeTree, typeBlob)
+	data := fmt.Sprintf("object %x\ntype %s\ntag v%d.0\ntagger T Agger <t@example.com> 1700000000 +0000\n\nRelease\n", k.idOf(typ), typeNames[typ], s.Intn(10))
+	o := &object{typ: typeTag, data: []byte(data)}
+	o.id = id(o.typ, o.data)
+	return o
+}
+
+// idOf returns the id of an object of type typ in the pack, or one made
+// up if there is none.
+func (k *kgen) idOf(typ int) [20]byte {
+	var ids [][20]byte
+	for _, o := range k.objects {
+		if o.typ == typ {
+			ids = append(ids, o.id)
+		}
+	}
+	if len(ids) == 0 {
+		var h [20]byte
+		for i := range h {
+			h[i] = byte(k.s.Intn(256))
+		}
+		return h
+	}
+	return gen.Pick(k.s, ids...)
+}
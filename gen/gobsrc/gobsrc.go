@@ -0,0 +1,465 @@
+// Package gobsrc generates encoding/gob seeds. It registers the "gob/..."
+// generators with package gen.
+//
+// A seed is a gob stream written out by hand rather than by gob.Encoder,
+// whose type ids depend on what else the process has encoded, so that
+// the same seed always gives the same stream and so that it can say
+// what an Encoder never would. Its types mirror those fuzz/gob decodes
+// into: a recursive Node struct with fields of every basic kind, a slice,
+// a map and an array of Nodes, and an interface field holding the
+// registered Leaf, Node and Ints. The stream starts out valid; most
+// seeds then have it corrupted.
+package gobsrc
+
+import (
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "gob/stream",
+		Doc:  "gob streams of recursive Node values, valid then corrupted: self-referential and undefined type ids, duplicate definitions, huge field deltas, lengths and counts, unregistered interface names and truncated messages",
+		Func: stream,
+	})
+}
+
+// corruptRate is the chance that a stream is corrupted once it is
+// written. Unlike the text generators, a malformed gob is what is most
+// worth decoding, so most seeds are.
+const corruptRate = 0.7
+
+// The predefined type ids.
+const (
+	tBool      = 1
+	tInt       = 2
+	tUint      = 3
+	tFloat     = 4
+	tBytes     = 5
+	tString    = 6
+	tComplex   = 7
+	tInterface = 8
+)
+
+// The ids of the stream's own types, from the first a user type gets.
+const (
+	tNode = 65 + iota
+	tNodes
+	tNodeMap
+	tTags
+	tLeaf
+	tInts
+)
+
+// A field of a struct type definition.
+type field struct {
+	name string
+	id   int
+}
+
+// nodeFields are Node's fields, in the order they are encoded.
+var nodeFields = []field{
+	{"Name", tString}, {"N", tInt}, {"U", tUint}, {"B", tBool}, {"F", tFloat}, {"C", tComplex}, {"Data", tBytes},
+	{"Next", tNode}, {"Kids", tNodes}, {"M", tNodeMap}, {"Tags", tTags}, {"Any", tInterface},
+}
+
+// A buf is gob-encoded data.
+type buf []byte
+
+// uint appends x as gob encodes an unsigned integer: in one byte if it
+// is less than 128, and otherwise as a negated byte count followed by
+// its bytes, big-endian.
+func (b *buf) uint(x uint64) {
+	if x < 128 {
+		*b = append(*b, byte(x))
+		return
+	}
+	n := (bits.Len64(x) + 7) / 8
+	*b = append(*b, byte(-n))
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, byte(x>>(8*i)))
+	}
+}
+
+// int appends x with its sign in the low bit.
+func (b *buf) int(x int64) {
+	if x < 0 {
+		b.uint(uint64(^x<<1) | 1)
+		return
+	}
+	b.uint(uint64(x << 1))
+}
+
+// float appends f as the byte-reversed bits of its IEEE 754 form.
+func (b *buf) float(f float64) {
+	b.uint(bits.ReverseBytes64(math.Float64bits(f)))
+}
+
+func (b *buf) str(s string) {
+	b.uint(uint64(len(s)))
+	*b = append(*b, s...)
+}
+
+// message appends a message: the length of the type id and payload, then
+// both.
+func (b *buf) message(id int, payload buf) {
+	var m buf
+	m.int(int64(id))
+	m = append(m, payload...)
+	b.uint(uint64(len(m)))
+	*b = append(*b, m...)
+}
+
+// A gobs accumulates the messages of a stream.
+type gobs struct {
+	s    *gen.State
+	msgs []buf
+}
+
+func stream(s *gen.State) []gen.File {
+	g := &gobs{s: s}
+	g.types()
+	for range s.Range(1, 4) {
+		g.value()
+	}
+	if s.Chance(corruptRate) {
+		for range s.Range(1, 3) {
+			g.corrupt()
+		}
+	}
+	var out buf
+	for _, m := range g.msgs {
+		out = append(out, m...)
+	}
+	return []gen.File{{Name: "stream.gob", Data: out}}
+}
+
+// add appends a message to the stream.
+func (g *gobs) add(id int, payload buf) {
+	var m buf
+	m.message(id, payload)
+	g.msgs = append(g.msgs, m)
+}
+
+// common appends a CommonType: the type's name and id.
+func common(p *buf, name string, id int) {
+	p.uint(1)
+	p.str(name)
+	p.uint(1)
+	p.int(int64(id))
+	p.uint(0)
+}
+
+// define adds the definition of a struct type.
+func (g *gobs) define(id int, name string, fields []field) {
+	var p buf
+	p.uint(3) // wireType.StructT
+	p.uint(1) // structType.CommonType
+	common(&p, name, id)
+	p.uint(1) // structType.Field
+	p.uint(uint64(len(fields)))
+	for _, f := range fields {
+		p.uint(1)
+		p.str(f.name)
+		p.uint(1)
+		p.int(int64(f.id))
+		p.uint(0)
+	}
+	p.uint(0)
+	p.uint(0)
+	g.add(-id, p)
+}
+
+// defineSlice adds the definition of a slice type.
+func (g *gobs) defineSlice(id int, name string, elem int) {
+	var p buf
+	p.uint(2) // wireType.SliceT
+	p.uint(1)
+	common(&p, name, id)
+	p.uint(1)
+	p.int(int64(elem))
+	p.uint(0)
+	p.uint(0)
+	g.add(-id, p)
+}
+
+// defineArray adds the definition of an array type.
+func (g *gobs) defineArray(id int, name string, elem int, n int64) {
+	var p buf
+	p.uint(1) // wireType.ArrayT
+	p.uint(1)
+	common(&p, name, id)
+	p.uint(1)
+	p.int(int64(elem))
+	p.uint(1)
+	p.int(n)
+	p.uint(0)
+	p.uint(0)
+	g.add(-id, p)
+}
+
+// defineMap adds the definition of a map type.
+func (g *gobs) defineMap(id int, name string, key, elem int) {
+	var p buf
+	p.uint(4) // wireType.MapT
+	p.uint(1)
+	common(&p, name, id)
+	p.uint(1)
+	p.int(int64(key))
+	p.uint(1)
+	p.int(int64(elem))
+	p.uint(0)
+	p.uint(0)
+	g.add(-id, p)
+}
+
+// types adds the definitions of the stream's types. An Encoder sends
+// each before the first value that needs it; they are all sent first
+// here, which a Decoder accepts as well.
+func (g *gobs) types() {
+	g.define(tNode, "Node", nodeFields)
+	g.defineSlice(tNodes, "[]*gob.Node", tNode)
+	g.defineMap(tNodeMap, "map[string]*gob.Node", tString, tNode)
+	g.defineArray(tTags, "[3]string", tString, 3)
+	g.define(tLeaf, "Leaf", []field{{"V", tInt}})
+	g.defineSlice(tInts, "gob.Ints", tInt)
+}
+
+// value adds a value message: usually a Node, sometimes a value sent as
+// a singleton, which is preceded by a zero field delta.
+func (g *gobs) value() {
+	var p buf
+	switch g.s.Intn(6) {
+	case 0:
+		p.uint(0)
+		p.int(int64(g.s.Intn(1000) - 500))
+		g.add(tInt, p)
+	case 1:
+		p.uint(0)
+		n := g.s.Intn(4)
+		p.uint(uint64(n))
+		for range n {
+			g.node(&p, 1)
+		}
+		g.add(tNodes, p)
+	default:
+		g.node(&p, g.s.Depth(g.s.Limits.Literal, 4))
+		g.add(tNode, p)
+	}
+}
+
+// node appends a Node with some of its fields set, as a struct is
+// encoded: a field delta before each field and a zero delta after the
+// last.
+func (g *gobs) node(p *buf, depth int) {
+	if depth > 10 && g.s.Chance(0.9) {
+		// Only a long chain of Next pointers gets this deep.
+		p.uint(8) // Next
+		g.node(p, depth-1)
+		p.uint(0)
+		return
+	}
+	last := -1
+	for i, f := range nodeFields {
+		if !g.s.Chance(0.4) || depth <= 0 && f.id >= tNode {
+			continue
+		}
+		p.uint(uint64(i - last))
+		last = i
+		switch f.name {
+		case "Name":
+			p.str(gen.Pick(g.s, "", "node", "名前", "\xff", "a\x00b"))
+		case "N":
+			p.int(gen.Pick(g.s, int64(0), 1, -1, math.MaxInt64, math.MinInt64, 1<<31, -1<<31))
+		case "U":
+			p.uint(gen.Pick(g.s, uint64(0), 1, 127, 128, 255, 256, math.MaxUint32, math.MaxUint64))
+		case "B":
+			p.uint(uint64(g.s.Intn(2)))
+		case "F":
+			p.float(gen.Pick(g.s, 0, 1.5, math.Copysign(0, -1), math.Inf(1), math.NaN(), math.MaxFloat64, math.SmallestNonzeroFloat64, 1e300))
+		case "C":
+			p.float(gen.Pick(g.s, 0, 1.0, math.NaN()))
+			p.float(gen.Pick(g.s, 0, -1.0, math.Inf(-1)))
+		case "Data":
+			p.str(gen.Pick(g.s, "", "\x00\x01\x02", "data"))
+		case "Next":
+			g.node(p, depth-1)
+		case "Kids":
+			n := g.s.Range(0, 3)
+			p.uint(uint64(n))
+			for range n {
+				g.node(p, depth-1)
+			}
+		case "M":
+			// At most one entry, as a map with more would be encoded in
+			// the order the Encoder happened to range over it.
+			n := g.s.Intn(2)
+			p.uint(uint64(n))
+			for range n {
+				p.str(gen.Pick(g.s, "", "k", "名前"))
+				g.node(p, depth-1)
+			}
+		case "Tags":
+			p.uint(3)
+			for range 3 {
+				p.str(gen.Pick(g.s, "", "tag", "x"))
+			}
+		case "Any":
+			g.iface(p, depth-1)
+		}
+	}
+	p.uint(0)
+}
+
+// iface appends an interface value: the name its concrete type was
+// registered under, then, unless it is nil, the type's id and the value,
+// counted, encoded as a value message is.
+func (g *gobs) iface(p *buf, depth int) {
+	if g.s.Chance(0.2) {
+		p.uint(0) // nil
+		return
+	}
+	var v buf
+	var name string
+	var id int
+	switch g.s.Intn(3) {
+	case 0:
+		name, id = "Leaf", tLeaf
+		v.uint(1)
+		v.int(int64(g.s.Intn(100)))
+		v.uint(0)
+	case 1:
+		name, id = "Ints", tInts
+		v.uint(0)
+		n := g.s.Intn(4)
+		v.uint(uint64(n))
+		for i := range n {
+			v.int(int64(i))
+		}
+	default:
+		name, id = "Node", tNode
+		g.node(&v, depth)
+	}
+	p.str(name)
+	p.int(int64(id))
+	p.uint(uint64(len(v)))
+	*p = append(*p, v...)
+}
+
+// corrupt damages the stream in one of the ways a gob decoder has to
+// survive.
+func (g *gobs) corrupt() {
+	s := g.s
+	last := len(g.msgs) - 1
+	switch s.Intn(10) {
+	case 0:
+		// A recursive type: a slice, map or array of itself.
+		id := 100 + s.Intn(3)
+		switch s.Intn(3) {
+		case 0:
+			g.defineSlice(id, "T", id)
+		case 1:
+			g.defineMap(id, "T", id, id)
+		default:
+			g.defineArray(id, "T", id, 2)
+		}
+		var p buf
+		p.uint(0)
+		p.uint(uint64(s.Intn(3)))
+		g.add(id, p)
+	case 1:
+		// A definition referring to a type never defined, or to the
+		// type of type definitions.
+		g.define(110, "Bad", []field{{"Name", gen.Pick(s, 999, 16, 0, -1, tNode)}, {"Next", 110}})
+		var p buf
+		p.uint(1)
+		p.str("x")
+		p.uint(0)
+		g.add(110, p)
+	case 2:
+		// A definition sent twice, or of a predefined id.
+		g.insert(g.msgs[s.Intn(6)])
+		if s.Chance(0.5) {
+			g.define(gen.Pick(s, tInt, tInterface, 16, 64, 0), "Node", nodeFields)
+		}
+	case 3:
+		// An array as long as it can be.
+		g.defineArray(120, "[]string", tString, gen.Pick(s, int64(math.MaxInt64), -1, 1<<40, 0))
+		var p buf
+		p.uint(0)
+		p.uint(gen.Pick(s, uint64(math.MaxUint64), 1<<40, 3))
+		g.add(120, p)
+	case 4:
+		// A value whose field delta or length is huge, inserted before
+		// the end of a value message.
+		m := g.msgs[s.Range(6, last)]
+		if len(m) == 0 {
+			return // truncated away
+		}
+		at := s.Range(len(m)/2, len(m)-1)
+		var huge buf
+		huge.uint(gen.Pick(s, uint64(math.MaxUint64), math.MaxInt64, 1<<31, 1<<40, 13))
+		g.msgs = append(g.msgs, nil)
+		g.msgs[last+1] = slices.Concat(m[:at], huge, m[at:])
+		g.msgs[last+1] = g.recount(g.msgs[last+1])
+	case 5:
+		// A message length past what the stream holds, or past the
+		// decoder's limit.
+		m := g.msgs[last]
+		var p buf
+		p.uint(gen.Pick(s, uint64(len(m)), 1<<30, 1<<33, math.MaxUint64, 9))
+		g.msgs[last] = append(p, m[lenSize(m):]...)
+	case 6:
+		// A truncated stream.
+		m := g.msgs[last]
+		g.msgs[last] = m[:s.Intn(max(len(m), 1))]
+	case 7:
+		// A byte overwritten, late in the stream.
+		m := slices.Clone(g.msgs[s.Range(last/2, last)])
+		if len(m) == 0 {
+			return
+		}
+		m[s.Intn(len(m))] = gen.Pick(s, byte(0), 0x7f, 0x80, 0xf8, 0xff, 0x01)
+		g.msgs = append(g.msgs, m)
+	case 8:
+		// An interface value with an unregistered name, or an id of the
+		// wrong type.
+		var p buf
+		p.uint(12) // Any
+		p.str(gen.Pick(s, "Unregistered", "Leaf", ""))
+		p.int(int64(gen.Pick(s, tNode, tInts, 999, tString)))
+		p.uint(gen.Pick(s, uint64(0), 2, 1000))
+		p.uint(1)
+		p.uint(0)
+		p.uint(0)
+		g.add(tNode, p)
+	default:
+		// A definition missing, so later values have no type.
+		g.msgs[s.Intn(6)] = nil
+	}
+}
+
+// insert appends a copy of m.
+func (g *gobs) insert(m buf) {
+	g.msgs = append(g.msgs, slices.Clone(m))
+}
+
+// lenSize returns the size of the length that message m starts with.
+func lenSize(m buf) int {
+	if len(m) == 0 || m[0] < 128 {
+		return min(len(m), 1)
+	}
+	return min(len(m), 1+256-int(m[0]))
+}
+
+// recount rewrites the length message m starts with to fit what follows
+// it.
+func (g *gobs) recount(m buf) buf {
+	body := m[lenSize(m):]
+	var out buf
+	out.uint(uint64(len(body)))
+	return append(out, body...)
+}
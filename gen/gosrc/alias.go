@@ -0,0 +1,150 @@
+package gosrc
+
+import (
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/alias",
+		Doc:  "generic type alias declarations and uses (Go 1.24)",
+		Func: aliases,
+	})
+}
+
+func aliases(s *gen.State) []gen.File {
+	f := newFile(s, "go/alias")
+	fill(s, f, 4, 10,
+		aliasBasic, aliasBasic,
+		aliasPartial,
+		aliasChain,
+		aliasGenericUse,
+		badAlias,
+	)
+	return f.files()
+}
+
+// aliasRHS is the right-hand side of a one-parameter generic alias along
+// with a value of the alias instantiated with int.
+type aliasRHS struct {
+	typ, val string
+}
+
+func oneParamRHS(s *gen.State) aliasRHS {
+	return gen.Pick(s,
+		aliasRHS{"[]T", "[]int{1, 2}"},
+		aliasRHS{"*T", "new(int)"},
+		aliasRHS{"func(T) T", "func(x int) int { return -x }"},
+		aliasRHS{"chan<- T", "make(chan int, 1)"},
+		aliasRHS{"struct{ X T }", "struct{ X int }{X: 1}"},
+		aliasRHS{"[4]T", "[4]int{3: 1}"},
+		aliasRHS{"map[string]T", `map[string]int{"a": 1}`},
+		aliasRHS{"interface{ ~int }", ""},
+		aliasRHS{"int", "7"},
+	)
+}
+
+func aliasBasic(s *gen.State, f *file) {
+	a := s.Fresh("A")
+	rhs := oneParamRHS(s)
+	constraint := gen.Pick(s, "any", "comparable", "interface{ ~int | ~int64 }", "~int", "interface{}")
+	f.line("type %s[T %s] = %s", a, constraint, rhs.typ)
+	if rhs.val == "" {
+		// An alias for a constraint interface is only usable as a
+		// constraint.
+		f.line("func %s[X %s[int]](x X) X { return x }", s.Fresh("f"), a)
+		return
+	}
+	f.line("var %s %s[int] = %s", s.Fresh("v"), a, rhs.val)
+	if s.Chance(0.5) {
+		f.line("var %s = %s%s[int]{}", s.Fresh("v"), gen.Pick(s, "map[int]", "[]", "[1]"), a)
+	}
+}
+
+func aliasPartial(s *gen.State, f *file) {
+	pair, a := s.Fresh("Pair"), s.Fresh("A")
+	f.open("type %s[K comparable, V any] struct {", pair)
+	f.line("Key K")
+	f.line("Val V")
+	f.close("}")
+	f.blank()
+	switch s.Intn(3) {
+	case 0:
+		f.line("type %s[V any] = %s[int, V]", a, pair)
+		f.line("func %s() %s[string] { return %s[int, string]{Key: 1, Val: \"x\"} }", s.Fresh("f"), a, pair)
+	case 1:
+		f.line("type %s[K comparable] = %s[K, K]", a, pair)
+		f.line("var %s = %s[string]{\"k\", \"v\"}", s.Fresh("v"), a)
+	default:
+		// Type parameters may be reordered and left unused.
+		f.line("type %s[V any, K comparable, _ any] = %s[K, []V]", a, pair)
+		f.line("var %s %s[byte, rune, struct{}]", s.Fresh("v"), a)
+	}
+}
+
+func aliasChain(s *gen.State, f *file) {
+	n := s.Range(2, 6)
+	names := make([]string, n)
+	for i := range names {
+		names[i] = s.Fresh("C")
+	}
+	f.line("type %s[T any] = []T", names[0])
+	for i := 1; i < n; i++ {
+		if s.Chance(0.3) {
+			// Detour through a two-parameter link, then narrow back to
+			// one parameter for the rest of the chain.
+			wide := s.Fresh("C")
+			f.line("type %s[T, U any] = %s[U]", wide, names[i-1])
+			f.line("type %s[T any] = %s[int, T]", names[i], wide)
+			continue
+		}
+		f.line("type %s[T any] = %s[T]", names[i], names[i-1])
+	}
+	f.line("var %s %s[%s[int]] = [][]int{{1}}", s.Fresh("v"), names[n-1], names[0])
+}
+
+func aliasGenericUse(s *gen.State, f *file) {
+	a, fn := s.Fresh("A"), s.Fresh("f")
+	f.line("type %s[T comparable] = map[T]T", a)
+	f.blank()
+	f.open("func %s[T comparable](m %s[T], k T) %s[T] {", fn, a, a)
+	f.line("m[k] = k")
+	f.line("return m")
+	f.close("}")
+	f.blank()
+	f.line("var %s = %s(map[string]string{}, \"k\")", s.Fresh("v"), fn)
+	if s.Chance(0.5) {
+		// Inference through the alias on the argument side.
+		f.line("var %s = %s(%s[int]{}, 1)", s.Fresh("v"), fn, a)
+	}
+}
+
+// badAlias emits a generic alias declaration or use that must be rejected.
+func badAlias(s *gen.State, f *file) {
+	a := s.Fresh("Bad")
+	switch s.Intn(8) {
+	case 0:
+		f.line("type %s[T any] = []T", a)
+		f.line("func (%s[T]) M() {}", a)
+	case 1:
+		f.line("type %s[T any] = []%s[T]", a, a)
+	case 2:
+		p := s.Fresh("Pair")
+		f.line("type %s[K comparable, V any] struct{ k K; v V }", p)
+		f.line("type %s[T any] = %s[T, int]", a, p)
+	case 3:
+		f.line("type %s[T any] = []T", a)
+		f.line("var _ %s[int, string]", a)
+	case 4:
+		f.line("type %s[T any] = []T", a)
+		f.line("var _ %s", a)
+	case 5:
+		f.line("func %s() { type L[T any] = []T; var _ L[int] }", s.Fresh("f"))
+	case 6:
+		f.line("type %s[T any] = interface{ T }", a)
+		f.line("var _ %s[int]", a)
+	default:
+		f.line("type %s[T any] = T", a)
+		f.line("type %s %s[%s]", s.Fresh("D"), a, a)
+	}
+}
@@ -0,0 +1,508 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/asm",
+		Doc:  "Plan 9 assembly for amd64 and arm64 with Go prototypes that match it or not",
+		Func: asmSeed,
+	})
+}
+
+// An asmArch holds the spelling of the instructions asmSeed uses on one
+// GOARCH.
+type asmArch struct {
+	name               string
+	mov                map[int]string // load or store by operand size
+	regs               []string
+	add, xor, shl, mul string
+	jmp, dec, jnz      string
+	addr               string // loads the address of %s into %s
+	index              string // %s + 8*%s
+	nop                string // a NOP spelled as raw machine code
+}
+
+var asmArches = []*asmArch{
+	{
+		name: "amd64",
+		mov:  map[int]string{1: "MOVB", 4: "MOVL", 8: "MOVQ"},
+		regs: []string{"AX", "BX", "CX", "DX", "SI", "DI", "R8", "R9"},
+		add:  "ADDQ", xor: "XORQ", shl: "SHLQ", mul: "IMULQ",
+		jmp: "JMP", dec: "DECQ %s", jnz: "JNZ",
+		addr:  "LEAQ %s, %s",
+		index: "(%s)(%s*8)",
+		nop:   "BYTE $0x90",
+	},
+	{
+		name: "arm64",
+		mov:  map[int]string{1: "MOVBU", 4: "MOVWU", 8: "MOVD"},
+		regs: []string{"R0", "R1", "R2", "R3", "R4", "R5", "R6", "R7"},
+		add:  "ADD", xor: "EOR", shl: "LSL", mul: "MUL",
+		jmp: "B", dec: "SUBS $1, %[1]s, %[1]s", jnz: "BNE",
+		addr:  "MOVD $%s, %s",
+		index: "(%s)(%s<<3)",
+		nop:   "WORD $0xd503201f",
+	},
+}
+
+// An asmFile is the assembly for one architecture.
+type asmFile struct {
+	arch *asmArch
+	b    strings.Builder
+}
+
+func (a *asmFile) line(format string, args ...any) {
+	fmt.Fprintf(&a.b, format, args...)
+	a.b.WriteByte('\n')
+}
+
+// ins writes one indented instruction.
+func (a *asmFile) ins(format string, args ...any) {
+	a.line("\t"+format, args...)
+}
+
+func (a *asmFile) reg(i int) string {
+	return a.arch.regs[i%len(a.arch.regs)]
+}
+
+// asmGen is a seed under construction: the Go file with the prototypes
+// and one assembly file per architecture. Snippets make their random
+// choices once and then write them out for every architecture, so the
+// files implement the same functions.
+type asmGen struct {
+	f   *file
+	asm []*asmFile
+}
+
+func (g *asmGen) each(fn func(a *asmFile)) {
+	for _, a := range g.asm {
+		fn(a)
+	}
+}
+
+func asmSeed(s *gen.State) []gen.File {
+	g := &asmGen{f: newFile(s, "go/asm")}
+	g.f.lead = append(g.f.lead, "//go:build amd64 || arm64")
+	for _, arch := range asmArches {
+		a := &asmFile{arch: arch}
+		a.line("#include \"textflag.h\"")
+		g.asm = append(g.asm, a)
+	}
+	pool := []func(*gen.State, *asmGen){
+		asmArith, asmArith, asmArith,
+		asmData, asmMacro, asmIfdef, asmLoop, asmTail, asmImmediates, asmOneLine,
+	}
+	for range s.Range(3, 8) {
+		gen.Pick(s, pool...)(s, g)
+	}
+	if s.Chance(0.15) {
+		badAsm(s, g)
+	}
+	files := g.f.files()
+	for _, a := range g.asm {
+		files = append(files, gen.File{Name: "asm_" + a.arch.name + ".s", Data: []byte(a.b.String())})
+	}
+	return files
+}
+
+// An asmType is a Go parameter type and the words of its ABI0 stack
+// slot, named by suffix the way vet's asmdecl names them.
+type asmType struct {
+	name  string
+	words []asmWord
+}
+
+type asmWord struct {
+	suffix string
+	size   int
+}
+
+var (
+	asmScalars = []asmType{
+		{"int64", []asmWord{{"", 8}}},
+		{"uint64", []asmWord{{"", 8}}},
+		{"int32", []asmWord{{"", 4}}},
+		{"uint8", []asmWord{{"", 1}}},
+		{"bool", []asmWord{{"", 1}}},
+		{"uintptr", []asmWord{{"", 8}}},
+		{"*byte", []asmWord{{"", 8}}},
+		{"float64", []asmWord{{"", 8}}},
+	}
+	asmParams = append([]asmType{
+		{"string", []asmWord{{"_base", 8}, {"_len", 8}}},
+		{"[]byte", []asmWord{{"_base", 8}, {"_len", 8}, {"_cap", 8}}},
+	}, asmScalars...)
+)
+
+// An asmVar is one word of the argument frame.
+type asmVar struct {
+	name      string
+	size, off int
+}
+
+func (v asmVar) String() string {
+	return fmt.Sprintf("%s+%d(FP)", v.name, v.off)
+}
+
+// An asmProto is a Go function declaration and its argument frame.
+type asmProto struct {
+	name            string
+	params, results []asmVar
+	size            int
+	decl            string
+}
+
+// asmDeclare picks a signature for a new function, writes its
+// declaration to g.f unless hide is set, and lays out its frame.
+func asmDeclare(s *gen.State, g *asmGen, params, results []asmType, hide bool) *asmProto {
+	p := &asmProto{name: s.Fresh("asm")}
+	off := 0
+	var ps, rs []string
+	for i, t := range params {
+		name := string(rune('a' + i))
+		ps = append(ps, name+" "+t.name)
+		// Every word of these types is naturally aligned.
+		off = (off + t.words[0].size - 1) &^ (t.words[0].size - 1)
+		for _, w := range t.words {
+			p.params = append(p.params, asmVar{name + w.suffix, w.size, off})
+			off += w.size
+		}
+	}
+	off = (off + 7) &^ 7
+	for i, t := range results {
+		name := "ret"
+		if i > 0 {
+			name += fmt.Sprint(i)
+		}
+		rs = append(rs, t.name)
+		off = (off + t.words[0].size - 1) &^ (t.words[0].size - 1)
+		p.results = append(p.results, asmVar{name, t.words[0].size, off})
+		off += t.words[0].size
+	}
+	p.size = off
+
+	res := strings.Join(rs, ", ")
+	if len(rs) > 1 {
+		res = "(" + res + ")"
+	}
+	p.decl = strings.TrimSpace(fmt.Sprintf("func %s(%s) %s", p.name, strings.Join(ps, ", "), res))
+	if !hide {
+		if g.f.body.Len() > 0 {
+			g.f.blank()
+		}
+		for _, t := range params {
+			if strings.HasPrefix(t.name, "*") || t.name == "[]byte" {
+				if s.Chance(0.5) {
+					g.f.line("//go:noescape")
+				}
+				break
+			}
+		}
+		g.f.line("%s", p.decl)
+	}
+	return p
+}
+
+// asmFlags returns TEXT flags that suit a frame with locals bytes of
+// locals.
+func asmFlags(s *gen.State, locals int) string {
+	if locals == 0 && s.Chance(0.2) {
+		return "NOSPLIT|NOFRAME"
+	}
+	// 7 is NOPROF|DUPOK|NOSPLIT, as old code spells it.
+	return gen.Pick(s, "NOSPLIT", "NOSPLIT", "7", "NOSPLIT|TOPFRAME", "0")
+}
+
+// asmMismatch kinds: ways an assembly function disagrees with its Go
+// prototype that the assembler accepts and vet's asmdecl reports.
+const (
+	asmMatch = iota
+	asmWrongSize
+	asmWrongOffset
+	asmWrongWidth
+	asmNoResult
+	asmUnknownName
+	asmNoDecl
+	numMismatch
+)
+
+func asmArith(s *gen.State, g *asmGen) {
+	params := make([]asmType, s.Range(1, 4))
+	for i := range params {
+		params[i] = gen.Pick(s, asmParams...)
+	}
+	results := make([]asmType, s.Range(1, 2))
+	for i := range results {
+		results[i] = gen.Pick(s, asmScalars...)
+	}
+	mismatch := asmMatch
+	if s.Chance(0.25) {
+		mismatch = 1 + s.Intn(numMismatch-1)
+	}
+	p := asmDeclare(s, g, params, results, mismatch == asmNoDecl)
+
+	locals := gen.Pick(s, 0, 0, 16)
+	flags := asmFlags(s, locals)
+	argSize := p.size
+	if mismatch == asmWrongSize {
+		argSize += 8
+	}
+	loads := p.params
+	if len(loads) > len(asmArches[0].regs) {
+		loads = loads[:len(asmArches[0].regs)]
+	}
+	ops := make([]int, len(loads))
+	for i := range ops {
+		ops[i] = s.Intn(4)
+	}
+	pad := gen.Pick(s, "", "", "PCALIGN $16", "nop")
+	wrongWidth := gen.Pick(s, 1, 4)
+	if loads[0].size != 8 {
+		wrongWidth = 8
+	}
+
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB), %s, $%d-%d", p.name, flags, locals, argSize)
+		switch pad {
+		case "":
+		case "nop":
+			a.ins("%s", a.arch.nop)
+		default:
+			a.ins("%s", pad)
+		}
+		for i, v := range loads {
+			mov := a.arch.mov[v.size]
+			if i == 0 {
+				switch mismatch {
+				case asmWrongOffset:
+					v.off += 8
+				case asmWrongWidth:
+					mov = a.arch.mov[wrongWidth]
+				case asmUnknownName:
+					v.name = "bogus"
+				}
+			}
+			a.ins("%s %s, %s", mov, v, a.reg(i))
+		}
+		for i := 1; i < len(loads); i++ {
+			op := []string{a.arch.add, a.arch.xor, a.arch.mul, a.arch.add}[ops[i]]
+			a.ins("%s %s, %s", op, a.reg(i), a.reg(0))
+		}
+		if ops[0] == 3 {
+			a.ins("%s $%d, %s", a.arch.shl, 1+len(loads), a.reg(0))
+		}
+		if locals > 0 {
+			a.ins("%s %s, tmp-8(SP)", a.arch.mov[8], a.reg(0))
+			a.ins("%s tmp-8(SP), %s", a.arch.mov[8], a.reg(0))
+		}
+		if mismatch != asmNoResult {
+			for _, r := range p.results {
+				a.ins("%s %s, %s", a.arch.mov[r.size], a.reg(0), r)
+			}
+		}
+		a.ins("RET")
+	})
+}
+
+func asmData(s *gen.State, g *asmGen) {
+	sym := "·" + s.Fresh("tbl") + "<>"
+	flags := gen.Pick(s, "RODATA", "RODATA|NOPTR", "NOPTR")
+	if s.Chance(0.3) {
+		p := asmDeclare(s, g, nil, []asmType{asmScalars[3]}, false)
+		at := s.Range(0, 11)
+		g.each(func(a *asmFile) {
+			a.line("")
+			a.line("DATA %s+0(SB)/8, $\"hello, w\"", sym)
+			a.line("DATA %s+8(SB)/4, $\"orld\"", sym)
+			a.line("GLOBL %s(SB), %s, $12", sym, flags)
+			a.line("")
+			a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+			a.ins(a.arch.addr, sym+"(SB)", a.reg(0))
+			a.ins("%s %d(%s), %s", a.arch.mov[1], at, a.reg(0), a.reg(1))
+			a.ins("%s %s, %s", a.arch.mov[1], a.reg(1), p.results[0])
+			a.ins("RET")
+		})
+		return
+	}
+	vals := []string{
+		gen.Pick(s, "0x0123456789abcdef", "-1", "~0", "(1<<63)", "'a'", "(1<<32|1)"),
+		gen.Pick(s, "0.5", "1e308", "-0.0", "0x7fffffffffffffff", "0"),
+	}
+	p := asmDeclare(s, g, nil, []asmType{asmScalars[1]}, false)
+	g.each(func(a *asmFile) {
+		a.line("")
+		for i, v := range vals {
+			a.line("DATA %s+%d(SB)/8, $%s", sym, 8*i, v)
+		}
+		a.line("GLOBL %s(SB), %s, $16", sym, flags)
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.ins(a.arch.addr, sym+"(SB)", a.reg(0))
+		a.ins("%s $1, %s", a.arch.mov[8], a.reg(1))
+		a.ins("%s "+a.arch.index+", %s", a.arch.mov[8], a.reg(0), a.reg(1), a.reg(2))
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(2), p.results[0])
+		a.ins("RET")
+	})
+}
+
+func asmMacro(s *gen.State, g *asmGen) {
+	m := strings.ToUpper(s.Fresh("twice"))
+	p := asmDeclare(s, g, []asmType{asmScalars[0]}, []asmType{asmScalars[0]}, false)
+	multiline, undef := s.Chance(0.5), s.Chance(0.3)
+	g.each(func(a *asmFile) {
+		a.line("")
+		if multiline {
+			a.line("#define %s(x, y) \\", m)
+			a.line("\t%s x, y; \\", a.arch.add)
+			a.line("\t%s x, y", a.arch.add)
+		} else {
+			a.line("#define %s(x, y) %s x, y; %s x, y", m, a.arch.add, a.arch.add)
+		}
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.ins("%s %s, %s", a.arch.mov[8], p.params[0], a.reg(0))
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(0), a.reg(1))
+		a.ins("%s(%s, %s)", m, a.reg(0), a.reg(1))
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(1), p.results[0])
+		a.ins("RET")
+		if undef {
+			a.line("")
+			a.line("#undef %s", m)
+		}
+	})
+}
+
+func asmIfdef(s *gen.State, g *asmGen) {
+	cond := gen.Pick(s, "#ifdef GOARCH_amd64", "#ifdef GOOS_linux", "#ifndef GOOS_windows", "#ifdef SEED_UNDEFINED", "#ifdef GOAMD64_v3")
+	p := asmDeclare(s, g, nil, []asmType{asmScalars[0]}, false)
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.line("%s", cond)
+		a.ins("%s $1, %s", a.arch.mov[8], a.reg(0))
+		a.line("#else")
+		a.ins("%s $2, %s", a.arch.mov[8], a.reg(0))
+		a.line("#endif")
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(0), p.results[0])
+		a.ins("RET")
+	})
+}
+
+func asmLoop(s *gen.State, g *asmGen) {
+	p := asmDeclare(s, g, []asmType{asmScalars[0]}, []asmType{asmScalars[0]}, false)
+	label := gen.Pick(s, "loop", "again", "L1")
+	skip := s.Chance(0.5)
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.ins("%s %s, %s", a.arch.mov[8], p.params[0], a.reg(0))
+		a.ins("%s $0, %s", a.arch.mov[8], a.reg(1))
+		a.line("%s:", label)
+		a.ins("%s $3, %s", a.arch.add, a.reg(1))
+		if skip {
+			// A PC-relative jump over the next instruction.
+			a.ins("%s 2(PC)", a.arch.jmp)
+			a.ins("%s %s, %s", a.arch.add, a.reg(1), a.reg(1))
+		}
+		a.ins(a.arch.dec, a.reg(0))
+		a.ins("%s %s", a.arch.jnz, label)
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(1), p.results[0])
+		a.ins("RET")
+	})
+}
+
+// asmTail declares two functions with the same signature, the second a
+// tail call of the first.
+func asmTail(s *gen.State, g *asmGen) {
+	t := asmScalars[s.Intn(len(asmScalars))]
+	p := asmDeclare(s, g, []asmType{t}, []asmType{t}, false)
+	q := asmDeclare(s, g, []asmType{t}, []asmType{t}, false)
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.ins("%s %s, %s", a.arch.mov[p.params[0].size], p.params[0], a.reg(0))
+		a.ins("%s %s, %s", a.arch.mov[p.results[0].size], a.reg(0), p.results[0])
+		a.ins("RET")
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", q.name, q.size)
+		a.ins("%s ·%s(SB)", a.arch.jmp, p.name)
+	})
+}
+
+// asmImmediates loads constant expressions in the assembler's own
+// operator syntax.
+func asmImmediates(s *gen.State, g *asmGen) {
+	imms := make([]string, s.Range(2, 5))
+	for i := range imms {
+		imms[i] = gen.Pick(s,
+			"$(1<<40|0xff)", "$~0", "$-1", "$'a'", "$'\\n'", "$0x7fffffffffffffff",
+			"$(0x7ff>>3)", "$((3*4+2)/2)", "$(100%7)", "$(0xf0&~0x30)", "$(1<<62|1)", "$-(8)",
+		)
+	}
+	p := asmDeclare(s, g, nil, []asmType{asmScalars[0]}, false)
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB), NOSPLIT, $0-%d", p.name, p.size)
+		a.ins("%s %s, %s", a.arch.mov[8], imms[0], a.reg(0))
+		for _, imm := range imms[1:] {
+			a.ins("%s %s, %s", a.arch.mov[8], imm, a.reg(1))
+			a.ins("%s %s, %s", a.arch.xor, a.reg(1), a.reg(0))
+		}
+		a.ins("%s %s, %s", a.arch.mov[8], a.reg(0), p.results[0])
+		a.ins("RET")
+	})
+}
+
+// asmOneLine writes a whole function on one line, with semicolons.
+func asmOneLine(s *gen.State, g *asmGen) {
+	p := asmDeclare(s, g, nil, []asmType{asmScalars[0]}, false)
+	n := s.Range(0, 100)
+	g.each(func(a *asmFile) {
+		a.line("")
+		a.line("TEXT ·%s(SB),NOSPLIT,$0-%d; %s $%d, %s; %s %s, %s; RET",
+			p.name, p.size, a.arch.mov[8], n, a.reg(0), a.arch.mov[8], a.reg(0), p.results[0])
+	})
+}
+
+// badAsm adds a function or directive the assembler must reject.
+func badAsm(s *gen.State, g *asmGen) {
+	p := asmDeclare(s, g, nil, nil, false)
+	kind := s.Intn(9)
+	g.each(func(a *asmFile) {
+		a.line("")
+		text := fmt.Sprintf("TEXT ·%s(SB), NOSPLIT, $0-0", p.name)
+		var body string
+		switch kind {
+		case 0:
+			body = fmt.Sprintf("FROB %s, %s", a.reg(0), a.reg(1))
+		case 1:
+			body = fmt.Sprintf("%s %s", a.arch.mov[8], a.reg(0))
+		case 2:
+			body = fmt.Sprintf("%s $1, R99", a.arch.mov[8])
+		case 3:
+			body = fmt.Sprintf("%s 0(FP), %s", a.arch.mov[8], a.reg(0))
+		case 4:
+			body = fmt.Sprintf("%s nowhere", a.arch.jmp)
+		case 5:
+			a.line("#include \"nonexistent.h\"")
+		case 6:
+			a.line("#ifdef SEED_UNTERMINATED")
+		case 7:
+			a.line("DATA ·%s<>+0(SB)/3, $1", p.name)
+		default:
+			text = fmt.Sprintf("TEXT ·%s<ABIInternal>(SB), NOSPLIT, $0-0", p.name)
+		}
+		a.line("%s", text)
+		if body != "" {
+			a.ins("%s", body)
+		}
+		a.ins("RET")
+	})
+}
@@ -0,0 +1,190 @@
+package gosrc
+
+import (
+	"fmt"
+	"go/build/constraint"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/buildtags",
+		Doc:  "packages split across files by interacting //go:build and // +build constraints",
+		Func: buildTags,
+	})
+}
+
+// buildTags writes a package whose files are selected by build
+// constraints. Constrained files come in complementary pairs defining the
+// same function, so every configuration builds exactly one of each pair.
+func buildTags(s *gen.State) []gen.File {
+	main := newFile(s, "go/buildtags")
+	var files []gen.File
+	for range s.Range(1, 4) {
+		fn := s.Fresh("impl")
+		main.line("var _ = %s()", fn)
+		files = append(files, gen.Pick(s, tagPair, tagPair, legacyPair, suffixPair)(s, fn)...)
+	}
+	for range s.Range(0, 2) {
+		files = append(files, gen.Pick(s, ignoredHeader, ignoredFile)(s))
+	}
+	if s.Chance(0.1) {
+		files = append(files, badTags(s))
+	}
+	return append([]gen.File{{Name: "main.go", Data: main.bytes()}}, files...)
+}
+
+// buildTag returns a tag that some configuration satisfies.
+func buildTag(s *gen.State) string {
+	return gen.Pick(s,
+		"linux", "darwin", "windows", "android", "ios", "illumos", "solaris", "js", "wasip1", "plan9",
+		"amd64", "arm64", "386", "wasm", "riscv64", "s390x",
+		"unix", "cgo", "gc", "gccgo",
+		"go1.1", "go1.18", "go1.21", "go1.24", "go1.99",
+		"custom", "integration", "goexperiment.rangefunc", "boringcrypto",
+	)
+}
+
+func tagExpr(s *gen.State, depth int) constraint.Expr {
+	if depth == 0 || s.Chance(0.35) {
+		return &constraint.TagExpr{Tag: buildTag(s)}
+	}
+	switch s.Intn(3) {
+	case 0:
+		return not(tagExpr(s, depth-1))
+	case 1:
+		return &constraint.AndExpr{X: tagExpr(s, depth-1), Y: tagExpr(s, depth-1)}
+	default:
+		return &constraint.OrExpr{X: tagExpr(s, depth-1), Y: tagExpr(s, depth-1)}
+	}
+}
+
+// not negates x. Double negation is a syntax error in //go:build lines.
+func not(x constraint.Expr) constraint.Expr {
+	if n, ok := x.(*constraint.NotExpr); ok {
+		return n.X
+	}
+	return &constraint.NotExpr{X: x}
+}
+
+// constrained returns a file defining fn, headed by the given constraint
+// lines.
+func constrained(s *gen.State, name, fn, val string, header ...string) gen.File {
+	f := newFile(s, "go/buildtags")
+	f.lead = header
+	f.line("func %s() string { return %q }", fn, val)
+	return gen.File{Name: name, Data: f.bytes()}
+}
+
+// tagPair writes fn twice, under x and under !x. Some files repeat the
+// constraint in the legacy syntax, and some of those repeat a different
+// one: //go:build wins and vet complains.
+func tagPair(s *gen.State, fn string) []gen.File {
+	x := tagExpr(s, 3)
+	var out []gen.File
+	for i, e := range []constraint.Expr{x, not(x)} {
+		header := []string{"//go:build " + e.String()}
+		switch {
+		case s.Chance(0.4):
+			if lines, err := constraint.PlusBuildLines(e); err == nil {
+				header = append(header, lines...)
+			}
+		case s.Chance(0.2):
+			if lines, err := constraint.PlusBuildLines(tagExpr(s, 2)); err == nil {
+				header = append(header, lines...)
+			}
+		}
+		out = append(out, constrained(s, fmt.Sprintf("%s_%c.go", fn, 'a'+i), fn, e.String(), header...))
+	}
+	return out
+}
+
+// legacyPair writes fn twice under // +build lines alone, as files from
+// before Go 1.17 did.
+func legacyPair(s *gen.State, fn string) []gen.File {
+	x := tagExpr(s, 2)
+	var out []gen.File
+	for i, e := range []constraint.Expr{x, not(x)} {
+		lines, err := constraint.PlusBuildLines(e)
+		if err != nil {
+			return tagPair(s, fn)
+		}
+		out = append(out, constrained(s, fmt.Sprintf("%s_legacy%d.go", fn, i), fn, e.String(), lines...))
+	}
+	return out
+}
+
+// suffixPair writes fn once in a file selected by its _GOOS or _GOARCH
+// name suffix and once in a file constrained to the other configurations.
+func suffixPair(s *gen.State, fn string) []gen.File {
+	goos := gen.Pick(s, "linux", "windows", "darwin", "android", "ios", "illumos", "js")
+	goarch := gen.Pick(s, "amd64", "arm64", "wasm", "386")
+	var suffix, other string
+	switch s.Intn(3) {
+	case 0:
+		suffix, other = goos, "!"+goos
+	case 1:
+		suffix, other = goarch, "!"+goarch
+	default:
+		suffix, other = goos+"_"+goarch, fmt.Sprintf("!(%s && %s)", goos, goarch)
+	}
+	// The name suffix matches the same implied tags as the constraint,
+	// e.g. _linux.go files build on android.
+	if goos == "android" || goos == "ios" || goos == "illumos" {
+		other = "!" + goos
+		suffix = goos
+	}
+	return []gen.File{
+		constrained(s, fmt.Sprintf("%s_%s.go", fn, suffix), fn, suffix),
+		constrained(s, fn+"_other.go", fn, other, "//go:build "+other),
+	}
+}
+
+// ignoredHeader writes a file whose constraint lines are not in effect,
+// so it always builds; it defines a name of its own.
+func ignoredHeader(s *gen.State) gen.File {
+	v := s.Fresh("always")
+	tag := buildTag(s)
+	var b strings.Builder
+	switch s.Intn(3) {
+	case 0:
+		// // +build must be followed by a blank line.
+		fmt.Fprintf(&b, "// +build %s\npackage p\n", tag)
+	case 1:
+		// // +build lines count only before the first line that is not
+		// a // comment.
+		fmt.Fprintf(&b, "/* header */\n\n// +build %s\n\npackage p\n", tag)
+	default:
+		fmt.Fprintf(&b, "// Package p.\n//\n// go:build %s\n\npackage p\n", tag)
+	}
+	fmt.Fprintf(&b, "\nvar %s = %q\n", v, tag)
+	return gen.File{Name: v + ".go", Data: []byte(b.String())}
+}
+
+// ignoredFile writes a file that no configuration builds, so it may
+// contain anything.
+func ignoredFile(s *gen.State) gen.File {
+	header := gen.Pick(s, "//go:build ignore", "//go:build linux && !linux", "//go:build go1.1 && !go1.1", "// +build ignore")
+	return gen.File{
+		Name: s.Fresh("ignored") + ".go",
+		Data: []byte(header + "\n\npackage main\n\nfunc main() { undefined() }\n"),
+	}
+}
+
+// badTags writes a file whose header go/build must reject.
+func badTags(s *gen.State) gen.File {
+	v := s.Fresh("bad")
+	header := gen.Pick(s,
+		"//go:build linux\n//go:build darwin",
+		"//go:build linux &&",
+		"//go:build (linux",
+		"//go:build linux darwin",
+		"//go:build !!",
+		"//go:build !!linux",
+		"//go:build linux,amd64",
+		"//go:build",
+	)
+	return gen.File{Name: v + ".go", Data: fmt.Appendf(nil, "%s\n\npackage p\n\nvar %s int\n", header, v)}
+}
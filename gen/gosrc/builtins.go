@@ -0,0 +1,245 @@
+package gosrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/builtins",
+		Doc:  "min, max and clear in constant and runtime contexts (Go 1.21)",
+		Func: builtins,
+	})
+}
+
+func builtins(s *gen.State) []gen.File {
+	f := newFile(s, "go/builtins")
+	fill(s, f, 4, 12,
+		constMinMax, constMinMax,
+		runtimeMinMax, runtimeMinMax,
+		genericMinMax,
+		clearCalls, genericClear,
+		badBuiltin,
+	)
+	return f.files()
+}
+
+// untypedNum returns an untyped numeric constant expression. Mixing
+// integer, rune and floating-point kinds in one min/max call is legal and
+// yields the "largest" kind.
+func untypedNum(s *gen.State) string {
+	return gen.Pick(s,
+		"0", "-1", "7", "0x7f", "0b1010", "0o17", "1_000_000",
+		"'a'", "'\\x00'", "'\\U0010FFFF'",
+		"2.5", "-0.0", "1e300", "0x1p-1074", ".5", "1.",
+		"1 << 62", "-1 << 63", "1 << 100", "(1 << 200) >> 190",
+	)
+}
+
+func minmax(s *gen.State) string {
+	return gen.Pick(s, "min", "max")
+}
+
+func args(s *gen.State, n int, arg func(*gen.State) string) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = arg(s)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func constMinMax(s *gen.State, f *file) {
+	c := s.Fresh("c")
+	switch s.Intn(6) {
+	case 0:
+		f.line("const %s = %s(%s)", c, minmax(s), args(s, s.Range(1, 5), untypedNum))
+	case 1:
+		strs := func(s *gen.State) string { return gen.Pick(s, `""`, `"a"`, `"ab"`, "`\\x00`", `"é"`, `"é"`) }
+		f.line("const %s = %s(%s)", c, minmax(s), args(s, s.Range(1, 4), strs))
+	case 2:
+		typ, lo, hi := intBounds(s)
+		f.line("const %s %s = %s(%s, %s, %s)", c, typ, minmax(s), lo, hi, gen.Pick(s, "0", lo, hi))
+	case 3:
+		a := s.Fresh("a")
+		f.line("var %s [%s(%d, %d)]byte", a, minmax(s), s.Range(0, 8), s.Range(0, 8))
+		f.line("const %s = len(%s)", c, a)
+	case 4:
+		f.line("const %s = %s(%s(%s), %s(%s)) + %s(%s)", c,
+			minmax(s), minmax(s), args(s, 2, untypedNum), minmax(s), args(s, 2, untypedNum),
+			minmax(s), untypedNum(s))
+	default:
+		f.open("const (")
+		f.line("%s = iota", c)
+		for range s.Range(1, 4) {
+			f.line("%s = %s(iota, %s)", s.Fresh("c"), minmax(s), gen.Pick(s, "2", "-1", "iota*2", "1.5"))
+		}
+		f.close(")")
+	}
+}
+
+// intBounds returns an integer type and the extremes of its range as
+// constant expressions.
+func intBounds(s *gen.State) (typ, lo, hi string) {
+	switch s.Intn(5) {
+	case 0:
+		return "int8", "-128", "127"
+	case 1:
+		return "uint8", "0", "255"
+	case 2:
+		return "int64", "-1 << 63", "1<<63 - 1"
+	case 3:
+		return "uint64", "0", "1<<64 - 1"
+	default:
+		return "rune", "-1 << 31", "0x7fffffff"
+	}
+}
+
+// ordered is an ordered type usable with min and max along with a
+// constant of that type.
+type ordered struct {
+	typ, lit string
+}
+
+func orderedType(s *gen.State) ordered {
+	return gen.Pick(s,
+		ordered{"int", "3"},
+		ordered{"int8", "-128"},
+		ordered{"uint", "0"},
+		ordered{"uintptr", "1"},
+		ordered{"float32", "1.5"},
+		ordered{"float64", "-0.0"},
+		ordered{"string", `"m"`},
+		ordered{"byte", "'a'"},
+	)
+}
+
+func runtimeMinMax(s *gen.State, f *file) {
+	o := orderedType(s)
+	fn := s.Fresh("f")
+	typ := o.typ
+	if s.Chance(0.3) {
+		typ = s.Fresh("T")
+		f.line("type %s %s", typ, o.typ)
+		f.blank()
+	}
+	f.open("func %s(a, b %s, xs []%s) %s {", fn, typ, typ, typ)
+	f.line("x := %s(a, b)", minmax(s))
+	f.open("for _, v := range xs {")
+	f.line("x = %s(x, v, %s)", minmax(s), o.lit)
+	f.close("}")
+	if strings.HasPrefix(o.typ, "float") {
+		f.use("math")
+		f.line("nan := %s(math.NaN())", typ)
+		f.line("x = %s(x, nan, -x)", minmax(s))
+	}
+	f.line("return %s(x, a+b, %s(b, a))", minmax(s), minmax(s))
+	f.close("}")
+}
+
+func genericMinMax(s *gen.State, f *file) {
+	c, fn := s.Fresh("Ord"), s.Fresh("g")
+	set := gen.Pick(s,
+		[2]string{"~int | ~int64 | ~float64 | ~string", "string"},
+		[2]string{"~uint8 | ~uint16", "uint8"},
+		[2]string{"~float32 | ~float64", "float32"},
+		[2]string{"~string", "string"},
+	)
+	f.line("type %s interface {", c)
+	f.line("\t%s", set[0])
+	f.line("}")
+	f.blank()
+	f.open("func %s[T %s](xs ...T) T {", fn, c)
+	f.open("if len(xs) == 0 {")
+	f.line("var zero T")
+	f.line("return zero")
+	f.close("}")
+	f.line("m := xs[0]")
+	f.open("for _, x := range xs[1:] {")
+	f.line("m = %s(m, x)", minmax(s))
+	f.close("}")
+	f.line("return %s(m, xs[0], xs[len(xs)-1])", minmax(s))
+	f.close("}")
+	f.blank()
+	f.line("var _ = %s[%s]()", fn, set[1])
+}
+
+func clearCalls(s *gen.State, f *file) {
+	f.open("func %s() {", s.Fresh("f"))
+	switch s.Intn(3) {
+	case 0:
+		f.use("math")
+		f.line("m := map[float64]int{math.NaN(): 1, math.NaN(): 2, 0: 3}")
+		f.line("clear(m)")
+		f.line("m[math.Inf(1)] = len(m)")
+	case 1:
+		f.line("xs := []int{%s}", args(s, s.Range(0, 6), func(s *gen.State) string { return gen.Pick(s, "1", "2", "-1") }))
+		f.line("clear(xs[%s])", gen.Pick(s, "1:", ":0", ":", "len(xs):", ":cap(xs)"))
+		f.line("clear(xs)")
+	default:
+		f.line("var m map[string]struct{}")
+		f.line("var xs []*int")
+		f.line("clear(m)")
+		f.line("clear(xs)")
+		f.line("clear(map[[2]int]bool{{1, 2}: true})")
+		f.line("clear([]string{})")
+	}
+	f.close("}")
+}
+
+func genericClear(s *gen.State, f *file) {
+	fn := s.Fresh("g")
+	switch s.Intn(3) {
+	case 0:
+		f.open("func %s[S ~[]E, E any](s S) S {", fn)
+		f.line("clear(s)")
+		f.line("return s[:0]")
+	case 1:
+		f.open("func %s[M ~map[K]V, K comparable, V any](m M) int {", fn)
+		f.line("clear(m)")
+		f.line("return len(m)")
+	default:
+		// Every type in the type set is a map or a slice, which is all
+		// clear requires of a type parameter.
+		f.open("func %s[T interface{ ~[]int | ~map[int]int }](t T) {", fn)
+		f.line("clear(t)")
+	}
+	f.close("}")
+}
+
+// badBuiltin emits a use of min, max or clear that a conforming type
+// checker must reject.
+func badBuiltin(s *gen.State, f *file) {
+	fn := s.Fresh("bad")
+	switch s.Intn(14) {
+	case 0:
+		f.line("const %s = %s()", fn, minmax(s))
+	case 1:
+		f.line("const %s = %s(1, \"a\")", fn, minmax(s))
+	case 2:
+		f.line("const %s = %s(1i, 2)", fn, minmax(s))
+	case 3:
+		f.line("const %s int8 = %s(1, 300)", fn, minmax(s))
+	case 4:
+		f.line("var %s = %s", fn, gen.Pick(s, "min", "max", "clear"))
+	case 5:
+		f.line("func %s(a [3]int) { clear(a) }", fn)
+	case 6:
+		f.line("func %s(a *[3]int) { clear(a) }", fn)
+	case 7:
+		f.line("func %s(m map[int]int) { _ = clear(m) }", fn)
+	case 8:
+		f.line("func %s(m map[int]int, s []int) { clear(m, s) }", fn)
+	case 9:
+		f.line("func %s(x int) { const c = %s(x, 1); _ = c }", fn, minmax(s))
+	case 10:
+		f.line("func %s[T any](a, b T) T { return %s(a, b) }", fn, minmax(s))
+	case 11:
+		f.line("func %s[T ~[]int | ~[3]int](t T) { clear(t) }", fn)
+	case 12:
+		f.line("func %s(a, b struct{}) struct{} { return %s(a, b) }", fn, minmax(s))
+	default:
+		f.line("func %s() { %s(1, 2) }", fn, minmax(s))
+	}
+}
@@ -0,0 +1,398 @@
+package gosrc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/cgo",
+		Doc:  "cgo preambles, #cgo directives, //export and C.* conversions",
+		Func: cgoSeed,
+	})
+}
+
+// cgoFile collects the pieces of a cgo seed. The preamble and the Go body
+// are built side by side so that every C declaration gets a Go use.
+type cgoFile struct {
+	s          *gen.State
+	directives []string
+	includes   map[string]bool
+	preamble   []string
+	impl       []string // definitions for impl.c; prototypes go in the preamble
+	body       []string // statements of the Go function using the preamble
+	*file
+}
+
+func (c *cgoFile) include(h string) {
+	c.includes[h] = true
+}
+
+func (c *cgoFile) c(format string, args ...any) {
+	c.preamble = append(c.preamble, fmt.Sprintf(format, args...))
+}
+
+func (c *cgoFile) stmt(format string, args ...any) {
+	c.body = append(c.body, fmt.Sprintf(format, args...))
+}
+
+func cgoSeed(s *gen.State) []gen.File {
+	c := &cgoFile{s: s, includes: map[string]bool{}, file: newFile(s, "go/cgo")}
+	c.include("stdlib.h")
+	c.include("stdint.h")
+	for range s.Range(1, 4) {
+		c.directives = append(c.directives, cgoDirective(s))
+	}
+	items := []func(*cgoFile){
+		cgoUnion, cgoBitfields, cgoStatic, cgoMacros, cgoEnum,
+		cgoStrings, cgoStrings, cgoGlobals, cgoFuncPtr, cgoKeywordFields,
+		cgoPacked, cgoArray, cgoComplex, cgoFlexible, cgoErrno,
+	}
+	for range s.Range(3, 8) {
+		gen.Pick(s, items...)(c)
+	}
+	if s.Chance(0.3) {
+		cgoImpl(c)
+	}
+	if s.Chance(0.15) {
+		gen.Pick(s, badCgo...)(c)
+	}
+
+	fn := s.Fresh("use")
+	c.file.open("func %s() {", fn)
+	for _, st := range c.body {
+		c.file.line("%s", st)
+	}
+	c.file.close("}")
+	if s.Chance(0.5) {
+		c.file.blank()
+		cgoExports(c)
+	}
+
+	files := []gen.File{{Name: "seed.go", Data: c.bytes()}}
+	if len(c.impl) > 0 {
+		files = append(files, gen.File{
+			Name: "impl.c",
+			Data: []byte("#include <stdint.h>\n#include \"_cgo_export.h\"\n\n" + strings.Join(c.impl, "\n") + "\n"),
+		})
+	}
+	return files
+}
+
+// bytes assembles the cgo file. The import "C" and its preamble are
+// written by hand because the preamble must be the doc comment of the
+// import.
+func (c *cgoFile) bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(c.file.header())
+	fmt.Fprintf(&b, "package %s\n\n", c.file.pkg)
+
+	var pre []string
+	pre = append(pre, c.directives...)
+	for _, h := range sortedKeys(c.includes) {
+		pre = append(pre, "#include <"+h+">")
+	}
+	pre = append(pre, "")
+	pre = append(pre, c.preamble...)
+
+	if c.s.Chance(0.7) {
+		b.WriteString("/*\n")
+		for _, l := range pre {
+			b.WriteString(l + "\n")
+		}
+		b.WriteString("*/\n")
+	} else {
+		for _, l := range pre {
+			b.WriteString(strings.TrimRight("// "+l, " ") + "\n")
+		}
+	}
+	b.WriteString("import \"C\"\n\n")
+	c.file.use("unsafe")
+	b.WriteString("import (\n")
+	for _, p := range sortedKeys(c.file.imports) {
+		fmt.Fprintf(&b, "\t%s\n", c.file.importSpec(p))
+	}
+	b.WriteString(")\n\n")
+	b.Write(c.file.body.Bytes())
+	b.WriteString("\nvar _ = unsafe.Pointer(nil)\n")
+	return b.Bytes()
+}
+
+func cgoDirective(s *gen.State) string {
+	cond := gen.Pick(s, "", "", "linux ", "!windows ", "linux,amd64 ", "darwin linux ", "!cgo_custom_tag ", "arm64,!ios ")
+	switch s.Intn(5) {
+	case 0:
+		return "#cgo " + cond + "CFLAGS: " + gen.Pick(s,
+			"-O2 -DNDEBUG",
+			"-I${SRCDIR}/include -I${SRCDIR}",
+			"-Wall -Wno-unused-function -Wno-unused-variable",
+			"-DVALUE=42",
+			"-D'QUOTED=1'",
+			`"-DSPACED=a b"`,
+			"-std=c99 -fno-strict-aliasing",
+		)
+	case 1:
+		return "#cgo " + cond + "LDFLAGS: " + gen.Pick(s,
+			"-lm",
+			"-L${SRCDIR}/lib -lm",
+			"-Wl,--as-needed -lm",
+			"-Wl,-rpath,${SRCDIR}",
+			"-pthread",
+		)
+	case 2:
+		return "#cgo " + cond + "CPPFLAGS: " + gen.Pick(s, "-DCPP=1", "-U_FORTIFY_SOURCE -D_FORTIFY_SOURCE=2", "-isystem ${SRCDIR}/sys")
+	case 3:
+		return "#cgo " + cond + gen.Pick(s, "CXXFLAGS: -std=c++17", "FFLAGS: -O1")
+	default:
+		return "#cgo " + cond + "CFLAGS: -DSEED_" + s.Fresh("D") + "=" + fmt.Sprint(s.Range(-1, 1<<20))
+	}
+}
+
+func cgoUnion(c *cgoFile) {
+	u := c.s.Fresh("pun")
+	c.c("typedef union { int32_t i; float f; unsigned char b[4]; struct { uint16_t lo, hi; } h; } %s;", u)
+	v := c.s.Fresh("u")
+	// A union is an opaque byte array on the Go side.
+	c.stmt("var %s C.%s", v, u)
+	c.stmt("*(*C.int32_t)(unsafe.Pointer(&%s)) = %d", v, c.s.Range(-1<<31, 1<<31-1))
+	c.stmt("_ = %s[%d]", v, c.s.Intn(4))
+}
+
+func cgoBitfields(c *cgoFile) {
+	st := c.s.Fresh("bits")
+	c.c("struct %s {", st)
+	c.c("\tunsigned a : %d;", c.s.Range(1, 31))
+	c.c("\tunsigned : 0;")
+	c.c("\tsigned b : %d;", c.s.Range(1, 31))
+	c.c("\tuint64_t c : %d;", c.s.Range(1, 63))
+	c.c("\tint d;")
+	c.c("\tunsigned char e : 1;")
+	c.c("};")
+	v := c.s.Fresh("bf")
+	c.stmt("var %s C.struct_%s", v, st)
+	c.stmt("%s.d = %d", v, c.s.Range(-5, 5))
+	c.stmt("_ = C.sizeof_struct_%s", st)
+}
+
+func cgoStatic(c *cgoFile) {
+	fn := c.s.Fresh("cadd")
+	c.c("static inline int %s(int a, int b) { return a + b; }", fn)
+	c.stmt("_ = C.%s(%d, C.int(%d))", fn, c.s.Range(-9, 9), c.s.Range(-9, 9))
+	if c.s.Chance(0.3) {
+		c.directives = append(c.directives, "#cgo "+gen.Pick(c.s, "noescape", "nocallback")+" "+fn)
+	}
+	if c.s.Chance(0.5) {
+		vd := c.s.Fresh("cvoid")
+		c.c("static void %s(void) {}", vd)
+		c.stmt("C.%s()", vd)
+	}
+}
+
+func cgoMacros(c *cgoFile) {
+	k := strings.ToUpper(c.s.Fresh("k"))
+	switch c.s.Intn(4) {
+	case 0:
+		c.c("#define %s %d", k, c.s.Range(-1000, 1000))
+	case 1:
+		c.c("#define %s \"macro\\tstring\"", k)
+	case 2:
+		c.c("#define %s (1ULL << %d)", k, c.s.Range(0, 63))
+	default:
+		c.c("#define %s 3.25e%d", k, c.s.Range(-300, 300))
+	}
+	c.stmt("_ = C.%s", k)
+}
+
+func cgoEnum(c *cgoFile) {
+	e := c.s.Fresh("color")
+	a, b, d := strings.ToUpper(c.s.Fresh("E")), strings.ToUpper(c.s.Fresh("E")), strings.ToUpper(c.s.Fresh("E"))
+	c.c("enum %s { %s, %s = %d, %s = 0x7fffffff };", e, a, b, c.s.Range(-100, 100), d)
+	c.stmt("_ = C.enum_%s(C.%s)", e, gen.Pick(c.s, a, b, d))
+}
+
+func cgoStrings(c *cgoFile) {
+	v := c.s.Fresh("cs")
+	lit := gen.Pick(c.s, `"hello"`, `""`, `"embedded\x00nul"`, `"ünïcödé"`, `"\xff\xfe"`, "`raw\\n`")
+	c.stmt("%s := C.CString(%s)", v, lit)
+	c.stmt("defer C.free(unsafe.Pointer(%s))", v)
+	switch c.s.Intn(4) {
+	case 0:
+		c.stmt("_ = C.GoString(%s)", v)
+	case 1:
+		c.stmt("_ = C.GoStringN(%s, C.int(%d))", v, c.s.Range(0, 4))
+	case 2:
+		c.stmt("_ = C.GoBytes(unsafe.Pointer(%s), %d)", v, c.s.Range(0, 1))
+	default:
+		b := c.s.Fresh("cb")
+		c.stmt("%s := C.CBytes([]byte(%s))", b, lit)
+		c.stmt("C.free(%s)", b)
+	}
+}
+
+func cgoGlobals(c *cgoFile) {
+	g := c.s.Fresh("g")
+	typ := gen.Pick(c.s, "int", "double", "uint8_t", "long long", "const char *")
+	switch typ {
+	case "const char *":
+		c.c("static %s%s = \"global\";", typ, g)
+		c.stmt("_ = C.GoString(C.%s)", g)
+	default:
+		c.c("static %s %s = 1;", typ, g)
+		c.stmt("C.%s = %d", g, c.s.Range(0, 100))
+	}
+}
+
+func cgoFuncPtr(c *cgoFile) {
+	t, call := c.s.Fresh("fp"), c.s.Fresh("call")
+	c.c("typedef int (*%s)(int);", t)
+	c.c("static int %s(%s f, int x) { return f ? f(x) : -1; }", call, t)
+	c.stmt("var %s C.%s", t, t)
+	c.stmt("_ = C.%s(%s, 3)", call, t)
+}
+
+func cgoKeywordFields(c *cgoFile) {
+	st := c.s.Fresh("kw")
+	c.c("struct %s { int type; int range; char *func; int go; };", st)
+	v := c.s.Fresh("k")
+	c.stmt("var %s C.struct_%s", v, st)
+	// Go keywords used as C field names are reached with a leading
+	// underscore.
+	c.stmt("%s._type, %s._range, %s._go = 1, 2, 3", v, v, v)
+}
+
+func cgoPacked(c *cgoFile) {
+	st := c.s.Fresh("packed")
+	c.c("struct __attribute__((packed)) %s { char c; int64_t x; int16_t y; };", st)
+	c.c("struct %s_al { char c; int x __attribute__((aligned(%d))); };", st, gen.Pick(c.s, 8, 16, 64))
+	c.stmt("_ = C.sizeof_struct_%s + C.sizeof_struct_%s_al", st, st)
+	c.stmt("var %s C.struct_%s", st, st)
+	c.stmt("_ = %s.c", st)
+}
+
+func cgoArray(c *cgoFile) {
+	a := c.s.Fresh("arr")
+	n := c.s.Range(1, 64)
+	c.c("static int %s[%d];", a, n)
+	c.stmt("_ = unsafe.Slice(&C.%s[0], %d)", a, n)
+	c.stmt("_ = len(C.%s)", a)
+}
+
+func cgoComplex(c *cgoFile) {
+	t := c.s.Fresh("cplx")
+	c.c("typedef double _Complex %s;", t)
+	c.c("typedef float _Complex %sf;", t)
+	c.stmt("var _ C.%s = %s", t, gen.Pick(c.s, "1 + 2i", "0", "-1i"))
+	c.stmt("var _ C.%sf = 3i", t)
+}
+
+func cgoFlexible(c *cgoFile) {
+	st := c.s.Fresh("flex")
+	c.c("struct %s { size_t n; int data[]; };", st)
+	c.c("struct %s_anon { union { int i; float f; }; struct { int x, y; }; };", st)
+	c.stmt("var %s C.struct_%s", st, st)
+	c.stmt("%s.n = %d", st, c.s.Range(0, 10))
+	c.stmt("_ = C.sizeof_struct_%s_anon", st)
+}
+
+func cgoErrno(c *cgoFile) {
+	c.include("math.h")
+	c.include("errno.h")
+	fn := c.s.Fresh("seterr")
+	c.c("static int %s(int e) { errno = e; return -1; }", fn)
+	r, e := c.s.Fresh("r"), c.s.Fresh("err")
+	c.stmt("%s, %s := C.%s(C.EDOM)", r, e, fn)
+	c.stmt("_, _ = %s, %s", r, e)
+	c.stmt("_, _ = C.sqrt(%s)", gen.Pick(c.s, "-1", "2", "C.double(0)"))
+}
+
+// cgoImpl moves a definition out of the preamble into impl.c, the only
+// legal place for non-static definitions once the file has //export.
+func cgoImpl(c *cgoFile) {
+	fn := c.s.Fresh("impl")
+	c.c("int64_t %s(int64_t x);", fn)
+	c.impl = append(c.impl, fmt.Sprintf("int64_t %s(int64_t x) { return x * %d; }", fn, c.s.Range(-3, 3)))
+	c.stmt("_ = C.%s(C.int64_t(%d))", fn, c.s.Range(-1<<40, 1<<40))
+}
+
+func cgoExports(c *cgoFile) {
+	for range c.s.Range(1, 3) {
+		fn := c.s.Fresh("Go")
+		switch c.s.Intn(4) {
+		case 0:
+			c.file.line("//export %s", fn)
+			c.file.line("func %s(a, b C.int) C.int { return a + b }", fn)
+		case 1:
+			c.file.line("//export %s", fn)
+			c.file.line("func %s(s string, p unsafe.Pointer) (C.int, *C.char) { return C.int(len(s)), (*C.char)(p) }", fn)
+		case 2:
+			c.file.line("//export %s", fn)
+			c.file.line("func %s() {}", fn)
+		default:
+			// Not a directive: the space makes it an ordinary comment.
+			c.file.line("// export %s", fn)
+			c.file.line("func %s(x C.double) C.double { return -x }", fn)
+		}
+		c.file.blank()
+	}
+}
+
+// badCgo lists misuses that cgo or the C toolchain must reject.
+var badCgo = []func(*cgoFile){
+	func(c *cgoFile) {
+		// Non-static definition in a preamble that is also used by
+		// //export: duplicate symbol at link time.
+		fn := c.s.Fresh("dup")
+		c.c("int %s(void) { return 1; }", fn)
+		c.stmt("_ = C.%s()", fn)
+		c.file.line("//export Bad%s", fn)
+		c.file.line("func Bad%s() {}", fn)
+		c.file.blank()
+	},
+	func(c *cgoFile) {
+		c.directives = append(c.directives, "#cgo CFLAGS: -fplugin=evil.so")
+	},
+	func(c *cgoFile) {
+		c.directives = append(c.directives, gen.Pick(c.s,
+			"#cgo CFLAGS -O2",
+			"#cgo : -O0",
+			"#cgo linux,,amd64 CFLAGS: -O0",
+			"#cgo CFLAGS: ${NOSUCHVAR}",
+			`#cgo CFLAGS: -DNAME=\"seed\"`,
+			"#cgo linux noescape nosuchfunc",
+		))
+	},
+	func(c *cgoFile) {
+		c.include("stdio.h")
+		c.stmt(`C.printf(C.CString("variadic\n"))`)
+	},
+	func(c *cgoFile) {
+		st := c.s.Fresh("bits")
+		c.c("struct %s { unsigned a : 3; };", st)
+		c.stmt("_ = C.struct_%s{}.a", st)
+	},
+	func(c *cgoFile) {
+		m := strings.ToUpper(c.s.Fresh("fm"))
+		c.c("#define %s(x) ((x) * 2)", m)
+		c.stmt("_ = C.%s(2)", m)
+	},
+	func(c *cgoFile) {
+		t := c.s.Fresh("fp")
+		c.c("typedef int (*%s)(int);", t)
+		c.stmt("var bad C.%s", t)
+		c.stmt("_ = bad(1)")
+	},
+	func(c *cgoFile) {
+		c.file.line("//export BadName")
+		c.file.line("func %s() {}", c.s.Fresh("notBadName"))
+		c.file.blank()
+	},
+	func(c *cgoFile) {
+		c.file.line("//export BadGeneric")
+		c.file.line("func BadGeneric[T any](t T) {}")
+		c.file.blank()
+	},
+}
@@ -0,0 +1,429 @@
+package gosrc
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/consts",
+		Doc:  "huge untyped constant arithmetic, conversion boundaries and exploding iota blocks, with compile-time checks of the folded values",
+		Func: consts,
+	})
+}
+
+// consts writes constant declarations whose values the generator works
+// out with math/big. Each value is pinned by an assertion that only
+// type checks if the checker folded the constant to the same value:
+//
+//	var _ = [1]struct{}{}[c0 - 1234]
+//
+// so a seed that fails to type check without a badConst is a finding.
+func consts(s *gen.State) []gen.File {
+	f := newFile(s, "go/consts")
+	fill(s, f, 4, 10,
+		constTree, constTree, constTree,
+		constShiftEdge,
+		constBounds, constBounds,
+		constComplex,
+		constIota, constIota,
+		constStrings,
+	)
+	if s.Chance(0.15) {
+		badConst(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// untypedBits is the precision go/types and the compiler give untyped
+// integer constants; a larger intermediate value is an overflow.
+const untypedBits = 512
+
+// shiftBound is the largest shift count of a constant the type checkers
+// accept, enough to express the smallest float64 denormal.
+const shiftBound = 1023 - 1 + 52
+
+// assertEq pins the constant expression c to v.
+func assertEq(f *file, c string, v *big.Int) {
+	f.line("var _ = [1]struct{}{}[%s - %s]", c, bigLit(v))
+}
+
+// bigLit writes v as a constant expression. Values too long to read are
+// written in hex.
+func bigLit(v *big.Int) string {
+	if v.BitLen() > 64 {
+		if v.Sign() < 0 {
+			return fmt.Sprintf("(-0x%x)", new(big.Int).Neg(v))
+		}
+		return fmt.Sprintf("0x%x", v)
+	}
+	if v.Sign() < 0 {
+		return "(" + v.String() + ")"
+	}
+	return v.String()
+}
+
+// A constExpr is the source of a constant expression with an integral
+// value, that value, and whether the expression is of floating-point
+// kind.
+type constExpr struct {
+	src   string
+	val   *big.Int
+	float bool
+}
+
+func constLeaf(s *gen.State) constExpr {
+	switch s.Intn(6) {
+	case 0:
+		n := int64(s.Range(-20, 300))
+		return constExpr{src: fmt.Sprint(n), val: big.NewInt(n)}
+	case 1:
+		k := s.Range(0, untypedBits-1)
+		return constExpr{src: fmt.Sprintf("(1 << %d)", k), val: new(big.Int).Lsh(big.NewInt(1), uint(k))}
+	case 2:
+		r := gen.Pick(s, 'a', 'z', 0x10FFFF, 0, 0x7f, 'é')
+		return constExpr{src: fmt.Sprintf("%q", r), val: big.NewInt(int64(r))}
+	case 3:
+		v := s.Uint64()
+		return constExpr{src: fmt.Sprintf("0x%x", v), val: new(big.Int).SetUint64(v)}
+	case 4:
+		// Integral float and complex constants take part in integer
+		// arithmetic with an integer kind once converted by context.
+		n := int64(s.Range(0, 1000))
+		return constExpr{fmt.Sprintf("%d.0", n), big.NewInt(n), true}
+	default:
+		v := gen.Pick(s, "0b1", "0o777", "1_000_000", "0x_FF")
+		b, _ := new(big.Int).SetString(strings.ReplaceAll(v, "_", ""), 0)
+		return constExpr{src: v, val: b}
+	}
+}
+
+// constBinary combines x and y with a random operator, returning false
+// if the exact result would overflow, divide by zero or shift by an
+// out-of-range count.
+func constBinary(s *gen.State, x, y constExpr) (constExpr, bool) {
+	z := new(big.Int)
+	op := gen.Pick(s, "+", "-", "*", "/", "%", "&", "|", "^", "&^", "<<", ">>")
+	float := x.float || y.float
+	switch op {
+	case "+":
+		z.Add(x.val, y.val)
+	case "-":
+		z.Sub(x.val, y.val)
+	case "*":
+		z.Mul(x.val, y.val)
+	case "/", "%":
+		if y.val.Sign() == 0 || float {
+			// Division of a float constant is exact, not truncated.
+			return constExpr{}, false
+		}
+		if op == "/" {
+			z.Quo(x.val, y.val)
+		} else {
+			z.Rem(x.val, y.val)
+		}
+	case "&", "|", "^", "&^":
+		if float {
+			return constExpr{}, false
+		}
+		switch op {
+		case "&":
+			z.And(x.val, y.val)
+		case "|":
+			z.Or(x.val, y.val)
+		case "^":
+			z.Xor(x.val, y.val)
+		default:
+			z.AndNot(x.val, y.val)
+		}
+	default:
+		if !y.val.IsInt64() || y.val.Sign() < 0 || y.val.Int64() > shiftBound {
+			return constExpr{}, false
+		}
+		// Shifting an untyped constant yields an integer.
+		float = false
+		n := uint(y.val.Int64())
+		if op == "<<" {
+			z.Lsh(x.val, n)
+		} else {
+			z.Rsh(x.val, n)
+		}
+	}
+	if z.BitLen() > untypedBits {
+		return constExpr{}, false
+	}
+	return constExpr{fmt.Sprintf("(%s %s %s)", x.src, op, y.src), z, float}, true
+}
+
+// constTreeOf builds an expression of depth at most d.
+func constTreeOf(s *gen.State, d int) constExpr {
+	if d == 0 || s.Chance(0.2) {
+		return constLeaf(s)
+	}
+	x := constTreeOf(s, d-1)
+	for range 4 {
+		y := constTreeOf(s, d-1)
+		if s.Chance(0.3) {
+			// Small counts keep shifts from overflowing.
+			n := int64(s.Range(0, 64))
+			y = constExpr{src: fmt.Sprint(n), val: big.NewInt(n)}
+		}
+		if z, ok := constBinary(s, x, y); ok {
+			return z
+		}
+	}
+	if !x.float && s.Chance(0.5) {
+		// Unary ^ on an untyped integer constant is -x-1.
+		return constExpr{src: "(^" + x.src + ")", val: new(big.Int).Not(x.val)}
+	}
+	return constExpr{"(-" + x.src + ")", new(big.Int).Neg(x.val), x.float}
+}
+
+func constTree(s *gen.State, f *file) {
+	c := s.Fresh("c")
+	e := constTreeOf(s, s.Depth(s.Limits.Expr, 5))
+	// A float constant with an integral value is a valid index too.
+	f.line("const %s = %s", c, e.src)
+	assertEq(f, c, e.val)
+	if t, ok := fittingType(s, e.val); ok {
+		f.line("const %s %s = %s", s.Fresh("t"), t, c)
+	}
+}
+
+// fitsInt reports whether v is representable in a bits-wide integer.
+func fitsInt(v *big.Int, bits int, signed bool) bool {
+	if !signed {
+		return v.Sign() >= 0 && v.BitLen() <= bits
+	}
+	lo := new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), uint(bits-1)))
+	return v.Cmp(lo) >= 0 && v.BitLen() <= bits-1
+}
+
+// intTypes lists the integer types with their width and signedness.
+var intTypes = []struct {
+	name   string
+	bits   int
+	signed bool
+}{
+	{"int8", 8, true}, {"int16", 16, true}, {"int32", 32, true}, {"int64", 64, true},
+	{"uint8", 8, false}, {"uint16", 16, false}, {"uint32", 32, false}, {"uint64", 64, false},
+	{"rune", 32, true}, {"byte", 8, false},
+}
+
+// fittingType picks an integer type v is representable in, if any.
+func fittingType(s *gen.State, v *big.Int) (string, bool) {
+	var fit []string
+	for _, t := range intTypes {
+		if fitsInt(v, t.bits, t.signed) {
+			fit = append(fit, t.name)
+		}
+	}
+	if len(fit) == 0 {
+		return "", false
+	}
+	return gen.Pick(s, fit...), true
+}
+
+// constShiftEdge shifts right up to the bounds of untyped precision and
+// shift counts.
+func constShiftEdge(s *gen.State, f *file) {
+	c := s.Fresh("c")
+	switch s.Intn(5) {
+	case 0:
+		k := s.Range(untypedBits-4, untypedBits-1)
+		v := new(big.Int).Lsh(big.NewInt(1), uint(k))
+		f.line("const %s = 1 << %d", c, k)
+		assertEq(f, c, v)
+		f.line("const %s = %s>>%d - 1", s.Fresh("c"), c, s.Range(0, k))
+	case 1:
+		// 2**512 - 1 still has 512 bits.
+		f.line("const %s = 1<<(%d-1) - 1 + 1<<(%d-1)", c, untypedBits, untypedBits)
+		v := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), untypedBits), big.NewInt(1))
+		assertEq(f, c, v)
+		f.line("const %s = -%s", s.Fresh("c"), c)
+	case 2:
+		n := s.Range(shiftBound-10, shiftBound)
+		f.line("const %s = 1 >> %d", c, n)
+		assertEq(f, c, big.NewInt(0))
+		f.line("var _ = [1]struct{}{}[-1>>%d + 1]", n)
+	case 3:
+		// An untyped float constant with an integral value may be
+		// shifted; the result is an integer.
+		k := s.Range(0, 300)
+		f.line("const %s = %s << %d", c, gen.Pick(s, "1.0", "2.0e0", "0x1p0", "1 + 0i"), k)
+		f.line("const %s = %s >> %d", s.Fresh("c"), c, k)
+	default:
+		t := gen.Pick(s, intTypes...)
+		f.line("const %s = %s(1) << %d", c, t.name, t.bits-2)
+		f.line("const %s = %s(0) >> %d", s.Fresh("c"), t.name, s.Range(0, shiftBound))
+		if !t.signed {
+			f.line("const %s = ^%s(0) >> 1", s.Fresh("c"), t.name)
+		}
+	}
+}
+
+// constBounds converts the extremes of each numeric type.
+func constBounds(s *gen.State, f *file) {
+	c := s.Fresh("c")
+	// mathOr picks one of xs, importing math if it names a constant
+	// from there.
+	mathOr := func(xs ...string) string {
+		x := gen.Pick(s, xs...)
+		if strings.Contains(x, "math.") {
+			f.use("math")
+		}
+		return x
+	}
+	switch s.Intn(5) {
+	case 0:
+		t := gen.Pick(s, intTypes...)
+		hi := new(big.Int).Lsh(big.NewInt(1), uint(t.bits))
+		lo := big.NewInt(0)
+		if t.signed {
+			hi.Rsh(hi, 1)
+			lo.Neg(hi)
+		}
+		hi.Sub(hi, big.NewInt(1))
+		f.line("const %s, %s = %s(%s), %s(%s)", c, c+"lo", t.name, bigLit(hi), t.name, bigLit(lo))
+		f.line("const %s = %s(%s.0)", s.Fresh("c"), t.name, hi)
+		if t.name == "int64" {
+			f.use("math")
+			f.line("var _ = [1]struct{}{}[%s - math.MaxInt64 + %s - math.MinInt64]", c, c+"lo")
+		}
+	case 1:
+		f.line("const %s = float32(%s)", c, mathOr(
+			"math.MaxFloat32", "-math.MaxFloat32", "math.SmallestNonzeroFloat32",
+			"0x1.fffffep127", "1e-46", "0x1p-149", "(1 << 128) - (1 << 104)"))
+	case 2:
+		f.line("const %s = float64(%s)", c, mathOr(
+			"math.MaxFloat64", "math.SmallestNonzeroFloat64", "0x1p-1074", "0x1p-1075",
+			"0x1p1023", "0x1.fffffffffffffp1023", "1e308 * 10 / 10", "4.9e-324 / 2"))
+	case 3:
+		f.line("const %s = uint64(%s)", c,
+			mathOr("math.MaxUint64", "1<<64 - 1", "0xFFFF_FFFF_FFFF_FFFF", "18446744073709551615.0"))
+		f.line("const %s = %s - 1<<63", s.Fresh("c"), c)
+	default:
+		f.line("const %s = int(%s)", c, mathOr(
+			"1e18", "-0.0", "1e2 + 0i", "'a' * 1.0", "math.Pi * 0", "(1 + 1i) * (1 - 1i)", "9.0 / 3"))
+	}
+}
+
+// constComplex mixes untyped integer, rune, float and complex constants.
+// Gaussian integer products are exact, so their parts are pinned.
+func constComplex(s *gen.State, f *file) {
+	a, b, c, d := int64(s.Range(-50, 50)), int64(s.Range(-50, 50)), int64(s.Range(-50, 50)), int64(s.Range(-50, 50))
+	z := s.Fresh("z")
+	f.line("const %s = (%d + %di) * (%d + %di)", z, a, b, c, d)
+	f.line("var _ = [1]struct{}{}[real(%s) - (%d)]", z, a*c-b*d)
+	f.line("var _ = [1]struct{}{}[imag(%s) - (%d)]", z, a*d+b*c)
+	switch s.Intn(4) {
+	case 0:
+		f.line("const %s = %s / (1 + 1i) * (1 + 1i)", s.Fresh("z"), z)
+	case 1:
+		f.line("const %s float64 = real(%s) + 'a' + %s", s.Fresh("c"), z, gen.Pick(s, "1e300", "0.5", "1e-300", "0x1p-60"))
+	case 2:
+		f.line("const %s = complex(%s, %s)", s.Fresh("z"), gen.Pick(s, "1e308", "0.1", "-0.0", "1 << 100"), gen.Pick(s, "1e-308", "'x'", "2"))
+	default:
+		f.line("const %s complex64 = %s * 1e30 * 1e-30", s.Fresh("z"), z)
+	}
+}
+
+// constIota writes a const block whose entries grow with iota.
+func constIota(s *gen.State, f *file) {
+	f.open("const (")
+	switch s.Intn(4) {
+	case 0:
+		// 1 << (iota*step) stays within untyped precision for n entries.
+		step := s.Range(8, 64)
+		n := (untypedBits-1)/step + 1
+		first := s.Fresh("k")
+		f.line("%s = 1 << (iota * %d)", first, step)
+		for range n - 1 {
+			f.line("%s", s.Fresh("k"))
+		}
+		f.close(")")
+		assertEq(f, first, big.NewInt(1))
+		return
+	case 1:
+		// Repeated squaring doubles the bit length each line.
+		prev := s.Fresh("sq")
+		f.line("%s = %d", prev, s.Range(2, 3))
+		for range s.Range(3, 8) {
+			next := s.Fresh("sq")
+			f.line("%s = %s * %s", next, prev, prev)
+			prev = next
+		}
+	case 2:
+		f.line("%s = iota * iota * iota * iota << (iota * %d)", s.Fresh("k"), s.Range(1, 16))
+		for range s.Range(2, 12) {
+			f.line("%s", s.Fresh("k"))
+		}
+	default:
+		t := gen.Pick(s, "float64", "complex128", "float32")
+		f.line("%s %s = 1 << iota * 1e%d", s.Fresh("k"), t, s.Range(0, 30))
+		for range s.Range(2, 12) {
+			f.line("%s", s.Fresh("k"))
+		}
+	}
+	f.close(")")
+}
+
+// constStrings doubles a string constant, so its length grows
+// exponentially with the number of lines.
+func constStrings(s *gen.State, f *file) {
+	prev := s.Fresh("str")
+	f.line("const %s = %q", prev, gen.Pick(s, "ab", "é", "\x00", "日本"))
+	n := s.Range(2, 14)
+	for range n {
+		next := s.Fresh("str")
+		f.line("const %s = %s + %s", next, prev, prev)
+		prev = next
+	}
+	f.line("const %s = len(%s)", s.Fresh("n"), prev)
+	f.line("var _ = %s[len(%s)-1]", prev, prev)
+}
+
+// badConst emits a constant a conforming type checker must reject.
+func badConst(s *gen.State, f *file) {
+	c := s.Fresh("bad")
+	switch s.Intn(12) {
+	case 0:
+		f.line("const %s = 1 << %d", c, untypedBits)
+	case 1:
+		f.line("const %s = (1 << 600) >> 100", c)
+	case 2:
+		f.line("const %s = 1 >> %d", c, shiftBound+1)
+	case 3:
+		t := gen.Pick(s, intTypes...)
+		f.line("const %s = %s(1) << %d", c, t.name, t.bits)
+	case 4:
+		f.line("const %s int8 = %s", c, gen.Pick(s, "128", "-129", "1.5", "'é'"))
+	case 5:
+		f.line("const %s = uint(%s)", c, gen.Pick(s, "-1", "1 << 64", "0.5"))
+	case 6:
+		f.line("const %s = %s / 0", c, gen.Pick(s, "1", "1.0", "1i", "0"))
+	case 7:
+		f.line("const %s = float32(1e39)", c)
+	case 8:
+		f.line("const %s = 1.5 << 2", c)
+	case 9:
+		f.open("const (")
+		f.line("%s = 2", c)
+		prev := c
+		for range 9 {
+			next := s.Fresh("bad")
+			f.line("%s = %s * %s", next, prev, prev)
+			prev = next
+		}
+		f.close(")")
+	case 10:
+		f.line("const %s = int64(1) << 63", c)
+	default:
+		f.line("const %s = %q + 1", c, "s")
+	}
+}
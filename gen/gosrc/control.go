@@ -0,0 +1,521 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/control",
+		Doc:  "self-checking programs of labeled loops, goto state machines, fallthrough chains and terminating statements, and jumps the compiler must reject",
+		Func: control,
+	})
+}
+
+// control writes a main package whose functions compute a result through
+// unusual control flow and compare it with the result the generator
+// worked out by simulating the same flow. A seed without a badJump that
+// fails to compile, panics or exits non-zero is a finding.
+func control(s *gen.State) []gen.File {
+	f := newFile(s, "go/control")
+	f.pkg = "main"
+	f.open("func expect(got, want int, what string) {")
+	f.open("if got != want {")
+	f.line("println(what, \"got\", got, \"want\", want)")
+	f.line("panic(\"control: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	calls := fillMain(s, f, 3, 7,
+		ctlLabeled, ctlLabeled,
+		ctlGoto, ctlGoto,
+		ctlFallthrough, ctlFallthrough,
+		ctlTerminating,
+		ctlEscape,
+	)
+	if s.Chance(0.15) {
+		badJump(s, f)
+		f.blank()
+	}
+	f.main(calls)
+	return f.files()
+}
+
+// A loopAction is a break or continue at the innermost of a nest of
+// labeled loops, taken when the sum and loop variables, weighted, are r
+// modulo m.
+type loopAction struct {
+	cont    bool
+	target  int
+	weights []int
+	m, r    int
+}
+
+func (a loopAction) taken(sum int, iv []int) bool {
+	v := sum
+	for i, w := range a.weights {
+		v += w * iv[i]
+	}
+	return v%a.m == a.r
+}
+
+// ctlLabeled writes a nest of labeled for loops whose innermost body
+// breaks or continues each of them under some condition. Every loop but
+// the innermost adds its depth to the sum after its inner loop finishes,
+// so breaking an inner loop and continuing its outer one differ.
+func ctlLabeled(s *gen.State, f *file) string {
+	fn := s.Fresh("loops")
+	d := s.Range(2, 4)
+	bounds := make([]int, d)
+	for i := range bounds {
+		bounds[i] = s.Range(2, 6)
+	}
+	step := make([]int, d)
+	for i := range step {
+		step[i] = s.Range(0, 4)
+	}
+	// One action per loop, so that every label is used.
+	actions := make([]loopAction, d)
+	for i, k := range perm(s, d) {
+		a := loopAction{cont: s.Chance(0.5), target: k, weights: make([]int, d), m: s.Range(3, 9)}
+		for j := range a.weights {
+			a.weights[j] = s.Range(0, 3)
+		}
+		a.r = s.Intn(a.m)
+		actions[i] = a
+	}
+
+	// Simulate.
+	sum := 0
+	iv := make([]int, d)
+	// run runs loop l and returns a break or continue it left for an
+	// outer loop to finish, if any.
+	type signal struct {
+		cont   bool
+		target int
+	}
+	var run func(l int) (signal, bool)
+	run = func(l int) (signal, bool) {
+		for iv[l] = 0; iv[l] < bounds[l]; iv[l]++ {
+			var sig signal
+			var jumped bool
+			if l == d-1 {
+				for k := range iv {
+					sum += step[k] * iv[k]
+				}
+				sum++
+				for _, a := range actions {
+					if a.taken(sum, iv) {
+						sig, jumped = signal{a.cont, a.target}, true
+						break
+					}
+				}
+			} else {
+				sig, jumped = run(l + 1)
+			}
+			if !jumped {
+				if l < d-1 {
+					sum += l + 1
+				}
+				continue
+			}
+			if sig.target != l {
+				return sig, true
+			}
+			if !sig.cont {
+				return signal{}, false
+			}
+		}
+		return signal{}, false
+	}
+	run(0)
+
+	f.open("func %s() {", fn)
+	f.line("sum := 0")
+	for l := range d {
+		f.label(fmt.Sprintf("L%d", l))
+		f.open("for i%d := 0; i%d < %d; i%d++ {", l, l, bounds[l], l)
+	}
+	var terms []string
+	for k := range d {
+		terms = append(terms, fmt.Sprintf("%d*i%d", step[k], k))
+	}
+	f.line("sum += %s + 1", strings.Join(terms, " + "))
+	for _, a := range actions {
+		cond := "sum"
+		for j, w := range a.weights {
+			if w != 0 {
+				cond += fmt.Sprintf(" + %d*i%d", w, j)
+			}
+		}
+		verb := "break"
+		if a.cont {
+			verb = "continue"
+		}
+		f.open("if (%s)%%%d == %d {", cond, a.m, a.r)
+		f.line("%s L%d", verb, a.target)
+		f.close("}")
+	}
+	for l := d - 1; l >= 0; l-- {
+		f.close("}")
+		if l > 0 {
+			f.line("sum += %d", l)
+		}
+	}
+	f.line("expect(sum, %d, %q)", sum, fn)
+	f.close("}")
+	return fn
+}
+
+// ctlGoto writes a state machine of labeled blocks joined by gotos, each
+// state updating x inside a nested block so that forward jumps skip no
+// declaration in the function's own block.
+func ctlGoto(s *gen.State, f *file) string {
+	fn := s.Fresh("machine")
+	n := s.Range(2, 6)
+	limit := s.Range(10, 60)
+	type state struct {
+		mul, add, mod int
+		m, r, onTrue  int
+		next          int
+	}
+	states := make([]state, n)
+	for i := range states {
+		states[i] = state{
+			mul: s.Range(2, 7), add: s.Range(0, 9), mod: gen.Pick(s, 97, 101, 251, 1009),
+			m: s.Range(2, 5), onTrue: s.Intn(n), next: (i + 1) % n,
+		}
+		states[i].r = s.Intn(states[i].m)
+	}
+	x0 := s.Range(1, 50)
+
+	x, steps, cur := x0, 0, 0
+	for {
+		steps++
+		if steps > limit {
+			break
+		}
+		st := states[cur]
+		x = (x*st.mul + st.add) % st.mod
+		if x%st.m == st.r {
+			cur = st.onTrue
+		} else {
+			cur = st.next
+		}
+	}
+
+	f.open("func %s() {", fn)
+	f.line("x, steps := %d, 0", x0)
+	for i, st := range states {
+		f.label(fmt.Sprintf("S%d", i))
+		f.line("steps++")
+		f.open("if steps > %d {", limit)
+		f.line("goto done")
+		f.close("}")
+		f.open("{")
+		f.line("t := x*%d + %d", st.mul, st.add)
+		f.line("x = t %% %d", st.mod)
+		f.close("}")
+		f.open("if x%%%d == %d {", st.m, st.r)
+		f.line("goto S%d", st.onTrue)
+		f.close("}")
+		f.line("goto S%d", st.next)
+	}
+	f.label("done")
+	f.line("expect(x*1000+steps, %d, %q)", x*1000+steps, fn)
+	f.close("}")
+	return fn
+}
+
+// ctlFallthrough writes a switch in a loop whose clauses, default among
+// them and not always last, fall through into each other in chains.
+func ctlFallthrough(s *gen.State, f *file) string {
+	fn := s.Fresh("chain")
+	k := s.Range(3, 8)
+	n := s.Range(2, min(k, 6))
+	type clause struct {
+		vals      []int
+		def       bool
+		mul, add  int
+		fallsThru bool
+	}
+	clauses := make([]clause, n)
+	vals := perm(s, k)
+	for i := range clauses {
+		clauses[i] = clause{mul: s.Range(2, 9), add: s.Range(1, 9), fallsThru: i < n-1 && s.Chance(0.6)}
+	}
+	for _, v := range vals[:s.Range(n, k)] {
+		c := &clauses[s.Intn(n)]
+		c.vals = append(c.vals, v)
+	}
+	if s.Chance(0.6) {
+		clauses[s.Intn(n)].def = true
+	}
+	for i := range clauses {
+		if len(clauses[i].vals) == 0 && !clauses[i].def {
+			// A clause needs a value; give it one no x matches.
+			clauses[i].vals = []int{k + i}
+		}
+	}
+	const mod = 1000003
+	rounds := s.Range(k, 3*k)
+
+	r := 0
+	for x := range rounds {
+		at := -1
+		for i, c := range clauses {
+			for _, v := range c.vals {
+				if v == x%k {
+					at = i
+				}
+			}
+		}
+		if at < 0 {
+			for i, c := range clauses {
+				if c.def {
+					at = i
+				}
+			}
+		}
+		for at >= 0 {
+			c := clauses[at]
+			r = (r*c.mul + c.add) % mod
+			at++
+			if !c.fallsThru {
+				at = -1
+			}
+		}
+	}
+
+	f.open("func %s() {", fn)
+	f.line("r := 0")
+	f.open("for x := 0; x < %d; x++ {", rounds)
+	f.line("switch x %% %d {", k)
+	for _, c := range clauses {
+		switch {
+		case c.def && len(c.vals) > 0:
+			// default can't share a clause, so its values get a
+			// clause of their own that falls into it.
+			f.line("case %s:", joinInts(c.vals))
+			f.line("\tfallthrough")
+			f.line("default:")
+		case c.def:
+			f.line("default:")
+		default:
+			f.line("case %s:", joinInts(c.vals))
+		}
+		f.line("\tr = (r*%d + %d) %% %d", c.mul, c.add, mod)
+		if c.fallsThru {
+			f.line("\tfallthrough")
+		}
+	}
+	f.line("}")
+	f.close("}")
+	f.line("expect(r, %d, %q)", r, fn)
+	f.close("}")
+	return fn
+}
+
+// perm returns a random permutation of [0, n).
+func perm(s *gen.State, n int) []int {
+	p := make([]int, n)
+	for i := range p {
+		p[i] = i
+	}
+	gen.Shuffle(s, p)
+	return p
+}
+
+func joinInts(vs []int) string {
+	ss := make([]string, len(vs))
+	for i, v := range vs {
+		ss[i] = fmt.Sprint(v)
+	}
+	return strings.Join(ss, ", ")
+}
+
+// ctlTerminating writes a function with results but no final return: it
+// ends in a terminating statement, with unreachable code after some of
+// its jumps.
+func ctlTerminating(s *gen.State, f *file) string {
+	fn, check := s.Fresh("term"), s.Fresh("checkTerm")
+	lim, step, n0 := s.Range(5, 40), s.Range(1, 5), s.Range(0, 4)
+	want := n0
+	for want <= lim {
+		want += step
+	}
+	f.open("func %s(n int) int {", fn)
+	switch s.Intn(5) {
+	case 0:
+		f.open("for {")
+		f.open("if n > %d {", lim)
+		f.line("return n")
+		f.line("n = -1 // unreachable")
+		f.close("}")
+		f.line("n += %d", step)
+		f.close("}")
+	case 1:
+		f.label("L")
+		f.open("if n > %d {", lim)
+		f.line("return n")
+		f.close("}")
+		f.line("n += %d", step)
+		f.line("goto L")
+	case 2:
+		f.open("for {")
+		f.line("switch {")
+		f.line("case n > %d:", lim)
+		f.line("\treturn n")
+		f.line("default:")
+		f.line("\tn += %d", step)
+		f.line("}")
+		f.close("}")
+	case 3:
+		f.open("if n <= %d {", lim)
+		f.line("return %s(n + %d)", fn, step)
+		f.mid("} else {")
+		f.line("return n")
+		f.close("}")
+	default:
+		// A switch is terminating if every clause, default included,
+		// ends in one and none breaks.
+		f.line("switch {")
+		f.line("case n > %d:", lim)
+		f.line("\treturn n")
+		f.line("default:")
+		f.line("\tpanic(%s(n + %d))", fn, step)
+		f.line("}")
+		f.close("}")
+		f.blank()
+		f.open("func %s() {", check)
+		f.line("defer func() { expect(recover().(int), %d, %q) }()", want, fn)
+		f.line("%s(%d)", fn, n0)
+		f.close("}")
+		return check
+	}
+	f.close("}")
+	f.blank()
+	f.open("func %s() {", check)
+	f.line("expect(%s(%d), %d, %q)", fn, n0, want, fn)
+	f.close("}")
+	return check
+}
+
+// ctlEscape writes a loop whose switch or select uses a plain break,
+// which leaves only the switch or select, and a labeled one, which leaves
+// the loop.
+func ctlEscape(s *gen.State, f *file) string {
+	fn := s.Fresh("escape")
+	k := s.Range(3, 9)
+	r, m := s.Intn(k), s.Range(2, 4)
+	n := 0
+	for i := 1; ; i++ {
+		if i%k == r {
+			break
+		}
+		if i%m == 0 {
+			n += i
+		}
+		n++
+	}
+	f.open("func %s() {", fn)
+	f.line("n := 0")
+	f.label("Loop")
+	f.open("for i := 1; ; i++ {")
+	if s.Chance(0.5) {
+		f.line("switch {")
+		f.line("case i%%%d == %d:", k, r)
+		f.line("\tbreak Loop")
+		f.line("case i%%%d == 0:", m)
+		f.line("\tn += i")
+		f.line("\tbreak")
+		f.line("\tn = -1 // unreachable")
+		f.line("}")
+	} else {
+		f.line("select {")
+		f.line("default:")
+		f.line("\tif i%%%d == %d {", k, r)
+		f.line("\t\tbreak Loop")
+		f.line("\t}")
+		f.line("\tif i%%%d != 0 {", m)
+		f.line("\t\tbreak")
+		f.line("\t}")
+		f.line("\tn += i")
+		f.line("}")
+	}
+	f.line("n++")
+	f.close("}")
+	f.line("expect(n, %d, %q)", n, fn)
+	f.close("}")
+	return fn
+}
+
+// badJump writes one jump or label the spec forbids.
+func badJump(s *gen.State, f *file) {
+	b := s.Fresh("bad")
+	f.open("func %s(n int) int {", b)
+	switch s.Intn(9) {
+	case 0:
+		f.line("goto L")
+		f.line("v := n // jumped over")
+		f.label("L")
+		f.line("return v")
+	case 1:
+		f.line("goto L")
+		f.open("{")
+		f.label("L")
+		f.line("n++")
+		f.close("}")
+		f.line("return n")
+	case 2:
+		f.label("L")
+		f.line("return n")
+	case 3:
+		f.line("switch n {")
+		f.line("case 0:")
+		f.line("\tn++")
+		f.line("default:")
+		f.line("\tfallthrough")
+		f.line("}")
+		f.line("return n")
+	case 4:
+		f.line("var x any = n")
+		f.line("switch x.(type) {")
+		f.line("case int:")
+		f.line("\tfallthrough")
+		f.line("default:")
+		f.line("}")
+		f.line("return n")
+	case 5:
+		f.label("L")
+		f.line("n++")
+		f.open("for {")
+		f.line("break L")
+		f.close("}")
+		f.line("return n")
+	case 6:
+		f.label("L")
+		f.line("switch {")
+		f.line("default:")
+		f.open("for {")
+		f.line("continue L")
+		f.close("}")
+		f.line("}")
+		f.line("return n")
+	case 7:
+		f.open("for {")
+		f.line("if n > 0 {")
+		f.line("\tbreak")
+		f.line("}")
+		f.close("}") // not terminating: missing return
+	default:
+		f.label("L")
+		f.line("n++")
+		f.label("L")
+		f.line("goto L")
+	}
+	f.close("}")
+}
@@ -0,0 +1,418 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/defer",
+		Doc:  "self-checking programs with hundreds of defers, defers in loops, open-coded defers around panics, re-panic chains, runtime errors and Goexit",
+		Func: defers,
+	})
+}
+
+// deferMod keeps the values defers fold into a result small.
+const deferMod = 1000003
+
+// defers writes a main package for cmd/racerun, whose header line states
+// how the program must end:
+//
+//	// racerun: race=false
+//	// racerun: race=false panic=defer: final 0
+//
+// Every check holds on a correct runtime. The second form ends in a
+// chain of unrecovered panics, and the runtime must report the last of
+// them.
+func defers(s *gen.State) []gen.File {
+	f := newFile(s, "go/defer")
+	f.pkg = "main"
+	f.use("runtime")
+	f.open("func check(ok bool, what string) {")
+	f.open("if !ok {")
+	f.line("panic(\"defer: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	f.line("// catch returns the runtime error fn panics with, if any.")
+	f.open("func catch(fn func()) (err runtime.Error) {")
+	f.line("defer func() { err, _ = recover().(runtime.Error) }()")
+	f.line("fn()")
+	f.line("return nil")
+	f.close("}")
+	f.blank()
+	calls := fillMain(s, f, 3, 7,
+		deferMany, deferMany,
+		deferLoop, deferLoop,
+		deferOpenCoded, deferOpenCoded,
+		deferRepanic, deferRepanic,
+		deferRuntime,
+		deferGoexit,
+		deferMethods,
+	)
+	header := "// racerun: race=false"
+	if s.Chance(0.3) {
+		fn, want := deferFinal(s, f)
+		f.blank()
+		calls = append(calls, fn)
+		header += " panic=" + want
+	}
+	f.lead = append(f.lead, header)
+	f.main(calls)
+	return f.files()
+}
+
+// A deferStep folds a value into a result: r = (r*mul + add) % deferMod.
+type deferStep struct{ mul, add int }
+
+func (d deferStep) apply(r int) int { return (r*d.mul + d.add) % deferMod }
+
+func deferSteps(s *gen.State, n int) []deferStep {
+	steps := make([]deferStep, n)
+	for i := range steps {
+		steps[i] = deferStep{s.Range(2, 9), s.Range(0, 99)}
+	}
+	return steps
+}
+
+// deferMany writes a function with more defers than the compiler
+// open-codes, each folding a step into a named result after the return
+// statement set it, so the result records the order they ran in.
+func deferMany(s *gen.State, f *file) string {
+	fn, chk := s.Fresh("many"), s.Fresh("checkMany")
+	steps := deferSteps(s, s.Range(9, 400))
+	r0 := s.Range(0, 1000)
+	want := r0
+	for i := len(steps) - 1; i >= 0; i-- {
+		want = steps[i].apply(want)
+	}
+	f.open("func %s() (r int) {", fn)
+	for _, d := range steps {
+		if s.Chance(0.3) {
+			// Arguments are evaluated when the defer statement runs.
+			f.line("defer func(m, a int) { r = (r*m + a) %% %d }(%d, %d)", deferMod, d.mul, d.add)
+		} else {
+			f.line("defer func() { r = (r*%d + %d) %% %d }()", d.mul, d.add, deferMod)
+		}
+	}
+	f.line("return %d", r0)
+	f.close("}")
+	f.blank()
+	f.open("func %s() {", chk)
+	f.line("check(%s() == %d, %q)", fn, want, fn)
+	f.close("}")
+	return chk
+}
+
+// deferLoop defers in a loop, which puts the defer records on the heap,
+// with closures capturing the per-iteration loop variable, arguments
+// evaluated at each defer, or a variable shared by all of them that they
+// read only when they run.
+func deferLoop(s *gen.State, f *file) string {
+	fn, chk := s.Fresh("loopDefers"), s.Fresh("checkLoop")
+	n, k := s.Range(1, 60), s.Range(1, 9)
+	d := deferStep{s.Range(2, 9), 0}
+	r0 := s.Range(0, 1000)
+	mode := s.Intn(3)
+	args := make([]int, n)
+	x := 0
+	for i := range n {
+		switch mode {
+		case 0:
+			args[i] = i
+		case 1:
+			args[i] = i * k
+		}
+		x += i
+	}
+	if mode == 2 {
+		for i := range args {
+			args[i] = x
+		}
+	}
+	want := r0
+	for i := n - 1; i >= 0; i-- {
+		want = deferStep{d.mul, args[i]}.apply(want)
+	}
+	f.open("func %s() (r int) {", fn)
+	if mode == 2 {
+		f.line("x := 0")
+	}
+	if s.Chance(0.5) {
+		f.open("for i := range %d {", n)
+	} else {
+		f.open("for i := 0; i < %d; i++ {", n)
+	}
+	switch mode {
+	case 0:
+		f.line("defer func() { r = (r*%d + i) %% %d }()", d.mul, deferMod)
+	case 1:
+		f.line("defer func(v int) { r = (r*%d + v) %% %d }(i * %d)", d.mul, deferMod, k)
+	default:
+		f.line("defer func() { r = (r*%d + x) %% %d }()", d.mul, deferMod)
+		f.line("x += i")
+	}
+	f.close("}")
+	f.line("return %d", r0)
+	f.close("}")
+	f.blank()
+	f.open("func %s() {", chk)
+	f.line("check(%s() == %d, %q)", fn, want, fn)
+	f.close("}")
+	return chk
+}
+
+// deferOpenCoded writes a function with few enough defers to be
+// open-coded, some of them conditional, and a conditional panic among
+// them that the first defer recovers. It is called with every pattern of
+// conditions, and the deferred calls that ran are checked against those
+// registered before the panic.
+func deferOpenCoded(s *gen.State, f *file) string {
+	fn, chk := s.Fresh("openCoded"), s.Fresh("checkOpen")
+	f.use("slices")
+	n := s.Range(1, 7)
+	type deferred struct {
+		cond int // index of the condition guarding it, or -1
+	}
+	conds := 0
+	ds := make([]deferred, n)
+	for i := range ds {
+		ds[i].cond = -1
+		if s.Chance(0.4) && conds < 3 {
+			ds[i].cond = conds
+			conds++
+		}
+	}
+	panicAt := s.Range(0, n) // the panic comes before ds[panicAt]
+	params := make([]string, conds+1)
+	for i := range conds {
+		params[i] = fmt.Sprintf("c%d", i)
+	}
+	params[conds] = "p"
+
+	f.open("func %s(%s bool) (trace []int, rec any) {", fn, strings.Join(params, ", "))
+	f.line("defer func() { rec = recover() }()")
+	for i, d := range ds {
+		if i == panicAt {
+			f.line("if p { panic(%q) }", fn)
+		}
+		if d.cond >= 0 {
+			f.line("if c%d { defer func() { trace = append(trace, %d) }() }", d.cond, i)
+		} else {
+			f.line("defer func() { trace = append(trace, %d) }()", i)
+		}
+	}
+	if panicAt == n {
+		f.line("if p { panic(%q) }", fn)
+	}
+	f.line("return nil, nil")
+	f.close("}")
+	f.blank()
+
+	f.open("func %s() {", chk)
+	for bits := range 1 << (conds + 1) {
+		var want []string
+		panicked := bits>>conds&1 == 1
+		for i := n - 1; i >= 0; i-- {
+			if panicked && i >= panicAt {
+				continue
+			}
+			if c := ds[i].cond; c >= 0 && bits>>c&1 == 0 {
+				continue
+			}
+			want = append(want, fmt.Sprint(i))
+		}
+		args := make([]string, conds+1)
+		for i := range args {
+			args[i] = fmt.Sprint(bits>>i&1 == 1)
+		}
+		rec := "nil"
+		if panicked {
+			rec = fmt.Sprintf("%q", fn)
+		}
+		f.open("if trace, rec := %s(%s); !slices.Equal(trace, []int{%s}) || rec != any(%s) {", fn, strings.Join(args, ", "), strings.Join(want, ", "), rec)
+		f.line("panic(%q)", fmt.Sprintf("defer: %s(%s)", fn, strings.Join(args, ", ")))
+		f.close("}")
+	}
+	f.close("}")
+	return chk
+}
+
+// deferRepanic writes panics raised inside deferred calls: chains that
+// recover and re-panic, new panics replacing one in flight, a deferred
+// call recovering a panic of its own, and recovers that must return nil.
+func deferRepanic(s *gen.State, f *file) string {
+	fn := s.Fresh("repanic")
+	f.open("func %s() {", fn)
+	switch s.Intn(5) {
+	case 0:
+		// Each deferred call recovers the value in flight and panics
+		// with it extended; the last to run is recovered for good.
+		k := s.Range(1, 12)
+		want := "p"
+		for i := range k {
+			want += fmt.Sprintf("+%d", i)
+		}
+		f.open("got := func() (got any) {")
+		f.line("defer func() { got = recover() }()")
+		for i := k - 1; i >= 0; i-- {
+			f.line("defer func() { panic(recover().(string) + \"+%d\") }()", i)
+		}
+		f.line("panic(\"p\")")
+		f.close("}()")
+		f.line("check(got == %q, %q)", want, fn)
+	case 1:
+		// Without recovering, a new panic replaces the one in flight.
+		k := s.Range(1, 12)
+		f.open("got := func() (got any) {")
+		f.line("defer func() { got = recover() }()")
+		for i := range k {
+			f.line("defer func() { panic(%d) }()", i)
+		}
+		f.line("panic(-1)")
+		f.close("}()")
+		f.line("check(got == 0, %q)", fn)
+	case 2:
+		f.open("inner, outer := func() (inner, outer any) {")
+		f.line("defer func() { outer = recover() }()")
+		f.open("defer func() {")
+		f.line("defer func() { inner = recover() }()")
+		f.line("panic(\"inner\")")
+		f.close("}()")
+		f.line("panic(\"outer\")")
+		f.close("}()")
+		f.line("check(inner == \"inner\" && outer == \"outer\", %q)", fn)
+	case 3:
+		// recover only stops a panic when a deferred function calls it
+		// directly.
+		f.open("got := func() (got any) {")
+		f.open("defer func() {")
+		f.line("indirect := func() any { return recover() }")
+		f.line("check(indirect() == nil, \"indirect recover\")")
+		f.line("got = recover()")
+		f.close("}()")
+		f.line("panic(%q)", fn)
+		f.close("}()")
+		f.line("check(got == %q, %q)", fn, fn)
+		f.line("check(recover() == nil, \"recover while not panicking\")")
+	default:
+		f.open("got := func() (got any) {")
+		f.line("defer func() { got = recover() }()")
+		f.line("panic(nil)")
+		f.close("}()")
+		f.line("_, ok := got.(*runtime.PanicNilError)")
+		f.line("check(ok, \"panic(nil)\")")
+	}
+	f.close("}")
+	return fn
+}
+
+// deferRuntime recovers runtime errors from operations on values the
+// compiler cannot see through.
+func deferRuntime(s *gen.State, f *file) string {
+	fn := s.Fresh("runtimeErrors")
+	f.open("func %s(zero int, nilMap map[int]int, nilPtr *[4]int, x any) {", fn)
+	ops := []string{
+		"nilMap[zero] = 1",
+		"_ = 1 / zero",
+		"_ = nilPtr[zero]",
+		"_ = []int{1}[zero+1]",
+		"_ = x.(string)",
+		"_ = make([]int, zero-1)",
+		"var c chan int; close(c)",
+		"_ = \"abc\"[zero+4:]",
+	}
+	gen.Shuffle(s, ops)
+	for _, op := range ops[:s.Range(1, len(ops))] {
+		f.line("check(catch(func() { %s }) != nil, %q)", op, op)
+	}
+	f.close("}")
+	f.blank()
+	chk := s.Fresh("checkRuntime")
+	f.open("func %s() {", chk)
+	f.line("%s(0, nil, nil, 1)", fn)
+	f.close("}")
+	return chk
+}
+
+// deferGoexit runs defers through runtime.Goexit, which runs them like a
+// panic does but leaves nothing to recover, and which stops a panic in
+// flight when deferred.
+func deferGoexit(s *gen.State, f *file) string {
+	fn := s.Fresh("goexit")
+	k := s.Range(1, 20)
+	f.open("func %s() {", fn)
+	f.line("done := make(chan int)")
+	f.open("go func() {")
+	f.line("n := 0")
+	f.line("defer func() { done <- n }()")
+	if s.Chance(0.5) {
+		f.line("defer func() { check(recover() == nil, \"recover during Goexit\"); n++ }()")
+		for range k - 1 {
+			f.line("defer func() { n++ }()")
+		}
+		f.line("runtime.Goexit()")
+	} else {
+		for range k - 1 {
+			f.line("defer func() { n++ }()")
+		}
+		f.line("defer func() { n++; runtime.Goexit() }()")
+		f.line("panic(%q)", fn)
+	}
+	f.close("}()")
+	f.line("check(<-done == %d, %q)", k, fn)
+	f.close("}")
+	return fn
+}
+
+// deferMethods defers method calls, whose receivers are evaluated with
+// the defer statement: a value receiver is copied then and a pointer
+// receiver sees later changes.
+func deferMethods(s *gen.State, f *file) string {
+	fn, acc := s.Fresh("methods"), s.Fresh("Acc")
+	n0, n1, k := s.Range(0, 50), s.Range(51, 100), s.Range(1, 20)
+	f.line("type %s struct{ n int }", acc)
+	f.line("func (a %s) Val(out *int) { *out = a.n }", acc)
+	f.line("func (a *%s) Add(k int) { a.n += k }", acc)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("a := %s{%d}", acc, n0)
+	f.line("var v int")
+	f.open("func() {")
+	f.line("%s", gen.Pick(s,
+		"defer a.Val(&v)",
+		"defer "+acc+".Val(a, &v)",
+		"var i interface{ Val(*int) } = a; defer i.Val(&v)",
+		"val := a.Val; defer val(&v)",
+	))
+	f.line(gen.Pick(s, "defer a.Add(%d)", "defer (*"+acc+").Add(&a, %d)", "add := a.Add; defer add(%d)"), k)
+	f.line("a.n = %d", n1)
+	f.close("}()")
+	f.line("check(v == %d && a.n == %d, %q)", n0, n1+k, fn)
+	f.close("}")
+	return fn
+}
+
+// deferFinal writes a function that ends the program in a chain of
+// panics, some recovered along the way, and returns it with the value of
+// the panic the runtime must report last.
+func deferFinal(s *gen.State, f *file) (fn, want string) {
+	fn = s.Fresh("final")
+	k := s.Range(1, 6)
+	f.open("func %s() {", fn)
+	want = fmt.Sprintf("defer: %s 0", fn)
+	f.line("defer func() { panic(%q) }()", want)
+	for i := 1; i < k; i++ {
+		if s.Chance(0.4) {
+			f.line("defer func() { recover() }()")
+		} else {
+			f.line("defer func() { panic(%q) }()", fmt.Sprintf("defer: %s %d", fn, i))
+		}
+	}
+	f.line("panic(%q)", fmt.Sprintf("defer: %s %d", fn, k))
+	f.close("}")
+	return fn, want
+}
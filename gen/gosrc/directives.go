@@ -0,0 +1,222 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/directives",
+		Doc:  "//go: and //line directives in legal and borderline positions",
+		Func: directives,
+	})
+}
+
+func directives(s *gen.State) []gen.File {
+	f := newFile(s, "go/directives")
+	if s.Chance(0.4) {
+		f.lead = append(f.lead, gen.Pick(s, "//go:build !nodirectives", "//go:build go1.21", "//go:generate echo seed"))
+	}
+	f.use("unsafe")
+	// //go:linkname needs package unsafe imported.
+	f.line("var _ unsafe.Pointer")
+	f.blank()
+	fill(s, f, 4, 10,
+		dirFunc, dirFunc, dirFunc,
+		dirSpacing,
+		dirLinkname,
+		dirLine, dirLine,
+		dirMisplacedBuild,
+		badDirective,
+	)
+	if s.Chance(0.3) {
+		asm := dirNoescape(s, f)
+		return append(f.files(), asm)
+	}
+	return f.files()
+}
+
+// funcPragma returns a directive that may precede any function declaration.
+func funcPragma(s *gen.State) string {
+	return gen.Pick(s,
+		"//go:noinline", "//go:nosplit", "//go:norace", "//go:nocheckptr",
+		"//go:noinline // trailing comment", "//go:generate go version",
+	)
+}
+
+func dirFunc(s *gen.State, f *file) {
+	pragmas := func() {
+		for range s.Range(1, 3) {
+			f.line("%s", funcPragma(s))
+		}
+	}
+	switch s.Intn(4) {
+	case 0:
+		pragmas()
+		f.line("func %s(x int) int { return x * 2 }", s.Fresh("f"))
+	case 1:
+		// uintptrescapes only means something with uintptr parameters.
+		pragmas()
+		f.line("//go:uintptrescapes")
+		f.line("func %s(p uintptr, n int) uintptr { return p + uintptr(n) }", s.Fresh("f"))
+	case 2:
+		t := s.Fresh("T")
+		f.line("type %s struct{ n int }", t)
+		f.blank()
+		pragmas()
+		f.line("func (t *%s) %s() int { return t.n }", t, s.Fresh("M"))
+	default:
+		pragmas()
+		f.line("func %s[T any](x T) T { return x }", s.Fresh("g"))
+	}
+}
+
+// dirSpacing writes comments that look like directives but are not, and
+// directives separated from their function by a blank line or comment.
+func dirSpacing(s *gen.State, f *file) {
+	f.line("%s", gen.Pick(s,
+		"// go:noinline",
+		"//  go:nosplit",
+		"//go: noinline",
+		"//go:noinline\t",
+		"/*go:noinline*/",
+		"//go:NOINLINE",
+		"//go:",
+	))
+	fn := s.Fresh("f")
+	if s.Chance(0.5) {
+		f.blank()
+	} else if s.Chance(0.5) {
+		f.line("// %s does nothing.", fn)
+	}
+	f.line("func %s() {}", fn)
+}
+
+func dirLinkname(s *gen.State, f *file) {
+	switch s.Intn(3) {
+	case 0:
+		// A "pull" of a runtime symbol that is kept linkable for
+		// compatibility.
+		fn := s.Fresh("nanotime")
+		f.line("//go:linkname %s runtime.nanotime", fn)
+		f.line("func %s() int64", fn)
+	case 1:
+		// The one-argument form marks a local symbol as linkable.
+		fn := s.Fresh("Pushed")
+		f.line("//go:linkname %s", fn)
+		f.line("func %s() int { return 1 }", fn)
+	default:
+		fn := s.Fresh("local")
+		f.line("//go:linkname %s %s.%s", fn, gen.Pick(s, "example.com/other", "main", "p"), s.Fresh("Remote"))
+		f.line("func %s() {}", fn)
+	}
+}
+
+// lineDirective returns a //line or /*line*/ comment body. Positions
+// out of range make the scanner reject the file, so they are rare;
+// malformed ones that are not directives at all are harmless.
+func lineDirective(s *gen.State) string {
+	name := gen.Pick(s, "seed.go", "other.go", "", "/abs/path/x.go", `C:\dir\x.go`, "with space.go", "a:b.go", "ünï.go")
+	pos := gen.Pick(s, ":1", ":10", ":1:1", ":100:7", ":5000000", ":5:1000000")
+	if !strings.Contains(name, ":") && s.Chance(0.1) {
+		// Without a colon the comment is not a directive.
+		pos = ""
+	}
+	if s.Chance(0.05) {
+		pos = gen.Pick(s, ":0", ":1:0", ":-1", ":x", "::", ":1073741824", "")
+	}
+	return "line " + name + pos
+}
+
+func dirLine(s *gen.State, f *file) {
+	fn := s.Fresh("f")
+	switch s.Intn(4) {
+	case 0:
+		f.line("//%s", lineDirective(s))
+		f.line("func %s() int { return 1 }", fn)
+	case 1:
+		f.open("func %s() int {", fn)
+		f.line("x := 1")
+		f.line("//%s", lineDirective(s))
+		f.line("x++")
+		f.line("return x")
+		f.close("}")
+	case 2:
+		// /*line*/ comments apply from the next character on, so they
+		// can move positions in the middle of a line.
+		f.line("func %s() int { return /*%s*/ 1 + /*%s*/ 2 }", fn, lineDirective(s), lineDirective(s))
+	default:
+		f.line("//%s", lineDirective(s))
+		f.line("//%s", lineDirective(s))
+		f.line("var %s = %q", s.Fresh("v"), "two directives in a row")
+	}
+}
+
+// dirMisplacedBuild writes build constraints after the package clause.
+// The old syntax is a plain comment there; the compiler rejects the new
+// one.
+func dirMisplacedBuild(s *gen.State, f *file) {
+	if s.Chance(0.5) {
+		f.line("%s", gen.Pick(s, "// +build ignore", "// +build linux,!linux"))
+		f.line("var %s int", s.Fresh("v"))
+		return
+	}
+	f.line("%s", gen.Pick(s, "//go:build ignore", "//go:build linux && !linux"))
+	f.line("var %s int", s.Fresh("bad"))
+}
+
+// badDirective writes a directive that go/types ignores but the compiler
+// rejects: wrong declaration kind, a body where none is allowed, or a
+// directive reserved to other packages or ports.
+func badDirective(s *gen.State, f *file) {
+	v := s.Fresh("bad")
+	switch s.Intn(8) {
+	case 0:
+		f.line("%s", funcPragma(s))
+		f.line("var %s int", v)
+	case 1:
+		f.line("//go:noinline")
+		f.line("type %s struct{}", v)
+	case 2:
+		f.line("//go:noescape")
+		f.line("func %s(p *int) {}", v)
+	case 3:
+		f.open("func %s() {", v)
+		f.line("//go:noinline")
+		f.line("_ = func() {}")
+		f.close("}")
+	case 4:
+		f.line("//go:cgo_import_dynamic %s %s \"libc.so.6\"", v, v)
+		f.line("func %sUse() {}", v)
+	case 5:
+		f.line("//go:wasmimport env %s", v)
+		f.line("func %s(int32) int32", v)
+	case 6:
+		f.line("//go:embed nonexistent.txt")
+		f.line("var %s string", v)
+	default:
+		f.line("//go:linkname")
+		f.line("func %s() {}", v)
+	}
+}
+
+// dirNoescape declares body-less //go:noescape functions in f and returns
+// the assembly file that implements them.
+func dirNoescape(s *gen.State, f *file) gen.File {
+	var asm strings.Builder
+	asm.WriteString("#include \"textflag.h\"\n")
+	for range s.Range(1, 3) {
+		fn := s.Fresh("asm")
+		f.blank()
+		f.line("//go:noescape")
+		if s.Chance(0.5) {
+			f.line("//go:nosplit")
+		}
+		f.line("func %s(p *byte, n int)", fn)
+		fmt.Fprintf(&asm, "\nTEXT ·%s(SB), NOSPLIT, $0-16\n\tRET\n", fn)
+	}
+	return gen.File{Name: "stub.s", Data: []byte(asm.String())}
+}
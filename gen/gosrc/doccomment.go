@@ -0,0 +1,306 @@
+package gosrc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/doccomment",
+		Doc:  "doc comments with huge and nested lists, doc links, code blocks, headings, Deprecated markers and malformed directives",
+		Func: docComments,
+	})
+}
+
+// docComments writes declarations of every kind go/doc collects, each
+// under a doc comment built from the blocks of the Go 1.19 doc comment
+// syntax and the near misses around them. Comments cannot make a file
+// invalid, so every seed type checks.
+func docComments(s *gen.State) []gen.File {
+	f := newFile(s, "go/doccomment")
+	f.pkg = gen.Pick(s, "p", "doc")
+	f.use("fmt")
+	f.line("var _ = fmt.Sprint")
+	f.blank()
+	fill(s, f, 3, 8,
+		docFunc, docFunc,
+		docType, docType,
+		docConsts,
+		docVar,
+	)
+	// The package comment is written last, so that its links can name
+	// what the body declared.
+	doc := docText(s)
+	if s.Chance(0.3) {
+		doc = append([]string{"Package " + f.pkg + " " + gen.Pick(s, "does things.", "is generated.", "", "—")}, doc...)
+	}
+	f.doc = docLines(s, doc)
+	return f.files()
+}
+
+// docText returns the lines of a doc comment of one to six blocks,
+// without comment markers.
+func docText(s *gen.State) []string {
+	var lines []string
+	for i := range s.Range(1, 6) {
+		if i > 0 && s.Chance(0.85) {
+			lines = append(lines, "")
+		}
+		lines = append(lines, gen.Pick(s,
+			docParagraph, docParagraph,
+			docList, docList,
+			docCode,
+			docHeading,
+			docLinkDefs,
+			docDeprecated,
+		)(s)...)
+	}
+	return lines
+}
+
+// docLines turns text into comment lines: usually // lines, sometimes
+// one /* */ comment, plain or in the old style with a star on each line.
+func docLines(s *gen.State, text []string) []string {
+	block := !slices.ContainsFunc(text, func(l string) bool { return strings.Contains(l, "*/") })
+	switch {
+	case block && s.Chance(0.15):
+		return append(append([]string{"/*"}, text...), "*/")
+	case block && s.Chance(0.05):
+		out := []string{"/*"}
+		for _, l := range text {
+			out = append(out, " * "+l)
+		}
+		return append(out, " */")
+	}
+	out := make([]string, len(text))
+	for i, l := range text {
+		switch {
+		case l == "":
+			out[i] = "//"
+		case strings.HasPrefix(l, "\t"), s.Chance(0.05):
+			out[i] = "//" + l
+		default:
+			out[i] = "// " + l
+		}
+	}
+	if s.Chance(0.3) {
+		out = docDirectives(s, out)
+	}
+	return out
+}
+
+// docDirectives puts directives, and comments that look like them,
+// among doc comment lines: the printer moves directives to the end of a
+// doc comment, and go/doc must leave them out of the text.
+func docDirectives(s *gen.State, lines []string) []string {
+	for range s.Range(1, 3) {
+		d := gen.Pick(s,
+			"//go:noinline", "//go:generate echo doc", "//line doc.go:1", "//export Doc",
+			"//extern doc", "//lint:ignore U1000 doc", "//nolint:all", "//go:", "//go:X",
+			"//go:build doc", "//go:embed doc.txt", "//0:0", "//a:b c", "//A:b", "// go:noinline",
+		)
+		i := s.Intn(len(lines) + 1)
+		lines = append(lines[:i], append([]string{d}, lines[i:]...)...)
+	}
+	return lines
+}
+
+// docWords are the words paragraphs and items are made of, including
+// URLs, doc links, Markdown look-alikes and text that is nearly one of
+// those.
+var docWords = []string{
+	"the", "value", "returns", "nil", "Doc", "x", "a_b", "and", "or",
+	"[fmt.Println]", "[fmt]", "[*T]", "[T.M]", "[Deprecated]", "[error]", "[os.File.Close]",
+	"[fmt.Stringer.String]", "[encoding/json]", "[example.com/m.F]", "[not a link]", "[x]", "[]", "[[x]]",
+	"[unterminated", "]", "[1]", "[Text][ref]", "[ref]",
+	"https://go.dev/doc/comment", "http://example.com/a_(b)_c.", "https://example.com/a?b=c&d=e#f",
+	"https://", "http:///x", "mailto:a@b.c", "go.dev/x",
+	"`code`", "*emph*", "**strong**", "_x_", "<b>", "&amp;", "&", "<", "\"quote\"", "'quote'",
+	"é", "名前", "\u200b", "\u00a0", "\u3000", "--", "...", "#", "1.", "-",
+}
+
+// docSentence returns a few words ending in a full stop, or not.
+func docSentence(s *gen.State) string {
+	var ws []string
+	for range s.Range(1, 12) {
+		ws = append(ws, gen.Pick(s, docWords...))
+	}
+	return strings.Join(ws, " ") + gen.Pick(s, ".", ".", "", ":", "!")
+}
+
+func docParagraph(s *gen.State) []string {
+	var lines []string
+	for range s.Range(1, 4) {
+		l := docSentence(s)
+		if s.Chance(0.05) {
+			// A line far past any sensible wrapping width.
+			l = strings.Repeat(l+" ", s.Range(20, 200))
+		}
+		lines = append(lines, l)
+	}
+	return lines
+}
+
+// docList returns a bullet or numbered list: huge ones, nested-looking
+// ones, lists with blank lines between items and with paragraphs run
+// straight into them.
+func docList(s *gen.State) []string {
+	var lines []string
+	if s.Chance(0.4) {
+		// A list right after a paragraph line, with no blank line.
+		lines = append(lines, docSentence(s))
+	}
+	n := s.Range(1, 8)
+	if s.Chance(0.1) {
+		n = s.Range(100, 2000)
+	}
+	numbered := s.Chance(0.4)
+	marker := gen.Pick(s, "-", "*", "+", "•")
+	indent := gen.Pick(s, " ", "  ", "   ", "\t", "", "    ")
+	start := gen.Pick(s, 1, 0, 1, 9, 99, 1000000)
+	for i := range n {
+		m := marker
+		if numbered {
+			m = fmt.Sprintf("%d%s", start+i, gen.Pick(s, ".", ")", ".", "."))
+		} else if s.Chance(0.05) {
+			m = gen.Pick(s, "-", "*", "+", "•")
+		}
+		in := indent
+		if s.Chance(0.1) {
+			// A deeper item, which the syntax does not nest.
+			in += "  "
+		}
+		lines = append(lines, in+m+" "+docSentence(s))
+		if s.Chance(0.1) {
+			lines = append(lines, in+"  "+docSentence(s))
+		}
+		if s.Chance(0.1) {
+			lines = append(lines, "")
+		}
+	}
+	return lines
+}
+
+// docCode returns an indented code block, its indentation mixed or
+// interrupted by blank lines, sometimes a Go program of its own.
+func docCode(s *gen.State) []string {
+	indent := gen.Pick(s, "\t", "\t", "  ", " ", "\t ", "        ")
+	var lines []string
+	for range s.Range(1, 10) {
+		switch s.Intn(6) {
+		case 0:
+			lines = append(lines, "")
+		case 1:
+			lines = append(lines, indent+"\t"+gen.Pick(s, "return x", "}", "// nested comment", "/* block */", "if err != nil {"))
+		case 2:
+			lines = append(lines, gen.Pick(s, "\t", " ", indent)+gen.Pick(s, "x := 1", "fmt.Println(x)", "go run .", "$ go test ./..."))
+		default:
+			lines = append(lines, indent+gen.Pick(s, "func main() {", "x := []int{1, 2}", "}", "# not a heading", "- not a list", "[not.a.Link]", "https://example.com/"))
+		}
+	}
+	return lines
+}
+
+// docHeading returns a heading in the new syntax, an old-style one, or a
+// near miss of either.
+func docHeading(s *gen.State) []string {
+	return []string{gen.Pick(s,
+		"# Heading", "# Heading with [fmt.Println] in it", "#Heading", "## Heading", "#",
+		"# Heading.", "# heading", "#\tHeading",
+		"Old Style Heading", "Old Style Heading.", "Old style: heading", "Old Style Heading (maybe)",
+		"Ünïcode Heading", "A", "Deprecated",
+	)}
+}
+
+// docLinkDefs returns link definitions, well formed and not.
+func docLinkDefs(s *gen.State) []string {
+	var lines []string
+	for range s.Range(1, 4) {
+		lines = append(lines, gen.Pick(s,
+			"[ref]: https://go.dev/ref/spec", "[Text]: https://example.com/text", "[ref]: https://example.com/dup",
+			"[ref]:https://example.com/nospace", "[ref]: not a url", "[ref]:", "[]: https://example.com/",
+			"[a b]: https://example.com/a%20b", "[名前]: https://example.com/名前", "  [indented]: https://example.com/",
+		))
+	}
+	return lines
+}
+
+// docDeprecated returns a Deprecated notice, or something that is not
+// quite one.
+func docDeprecated(s *gen.State) []string {
+	return []string{gen.Pick(s,
+		"Deprecated: use [fmt.Sprint] instead.", "Deprecated: ", "Deprecated:", "Deprecated: no longer supported.",
+		"deprecated: lower case.", "DEPRECATED: shouting.", "Deprecated - wrong separator.",
+		"It is Deprecated: in the middle.",
+	)}
+}
+
+// docComment writes a doc comment for a declaration.
+func docComment(s *gen.State, f *file) {
+	for _, l := range docLines(s, docText(s)) {
+		f.line("%s", l)
+	}
+}
+
+func docFunc(s *gen.State, f *file) {
+	docComment(s, f)
+	f.line("func %s(x int) int { return x }", s.Fresh("F"))
+}
+
+// docType writes a type with doc comments on it, its fields or methods,
+// and its constructor, which go/doc files under the type.
+func docType(s *gen.State, f *file) {
+	t := s.Fresh("T")
+	docComment(s, f)
+	f.open("type %s struct {", t)
+	for range s.Range(0, 4) {
+		if s.Chance(0.5) {
+			docComment(s, f)
+		}
+		field := s.Fresh("Field")
+		if s.Chance(0.3) {
+			f.line("%s int // %s", field, docSentence(s))
+		} else {
+			f.line("%s int", field)
+		}
+	}
+	f.close("}")
+	f.blank()
+	if s.Chance(0.6) {
+		docComment(s, f)
+		f.line("func New%s() *%s { return &%s{} }", t, t, t)
+		f.blank()
+	}
+	for range s.Range(0, 3) {
+		docComment(s, f)
+		f.line("func (*%s) %s() {}", t, s.Fresh("M"))
+		f.blank()
+	}
+}
+
+func docConsts(s *gen.State, f *file) {
+	docComment(s, f)
+	f.open("const (")
+	for i := range s.Range(1, 5) {
+		if s.Chance(0.4) {
+			for _, l := range docLines(s, docDeprecated(s)) {
+				f.line("%s", l)
+			}
+		}
+		if i == 0 {
+			f.line("%s = iota", s.Fresh("C"))
+		} else {
+			f.line("%s", s.Fresh("C"))
+		}
+	}
+	f.close(")")
+}
+
+func docVar(s *gen.State, f *file) {
+	docComment(s, f)
+	f.line("var %s = %q", s.Fresh("V"), docSentence(s))
+}
@@ -0,0 +1,186 @@
+package gosrc
+
+import (
+	"path"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/embed",
+		Doc:  "//go:embed directives over a tree of files: globs, all: prefixes, quoted patterns, invalid patterns, hidden and oddly named files, nested modules, and directives on the wrong declarations",
+		Func: embed,
+	})
+}
+
+// embedTree is the tree of files a package embeds from: plain, hidden
+// and underscore files, names with spaces and glob characters in them, a
+// directory of nothing but hidden files, and a directory that is a
+// module of its own.
+var embedTree = []string{
+	"hello.txt", "a.txt", "b.txt", "c.md", "static/index.html", "static/app.js", "static/.hidden", "static/_partial.html",
+	"static/sub/deep.txt", "static/sub/.keep", "static/img/logo.png", "hidden/.a", "hidden/_b", "mod/go.mod", "mod/x.txt",
+	"a b.txt", "\u00e9.txt", "-dash.txt", "~tilde", "trailing.", "x[1].txt", "testdata/t.txt", "vendor/v.txt",
+}
+
+// oddTree are files no module holds, which a pattern must not match:
+// names with quotes, glob metacharacters, colons and backslashes, and
+// version control directories.
+var oddTree = []string{
+	"q?.txt", "star*.txt", "a:b.txt", `a"b.txt`, "a'b.txt", `back\slash.txt`, "con.txt", ".git/config", "static/.hg/x", "static/a:b",
+}
+
+// embedPatterns are patterns that embed from embedTree: names, globs,
+// directories and all: directories.
+var embedPatterns = []string{
+	"hello.txt", "a.txt", "*.txt", "static", "static/*", "static/*.html", "all:static", "all:hidden",
+	"static/sub", "static/.hidden", "static/_partial.html", "[ab].txt", "?.txt", "static/*/deep.txt",
+	"testdata", "vendor", "x\\[1\\].txt", "c.md", "static/img", "\u00e9.txt", "a b.txt", "*.md",
+}
+
+// badEmbedPatterns are patterns the go command rejects: those that are
+// not valid paths or globs, match nothing, match only hidden files or
+// reach into another module.
+var badEmbedPatterns = []string{
+	"../hello.txt", "/hello.txt", "./hello.txt", "static/", ".", "static//sub", "[", "\\", "*\\", "all:", "all:.",
+	"nomatch.txt", "*.nomatch", "static/./sub", "static/..", "\xff", ".git", ".git/config", "a\\b", "all:../x",
+	"hidden", "mod", "mod/x.txt", "*", "all:*", "mod/go.mod",
+}
+
+// embed writes a package that embeds files from embedTree into string,
+// []byte and embed.FS variables, and the files themselves.
+func embed(s *gen.State) []gen.File {
+	f := newFile(s, "go/embed")
+	switch {
+	case s.Chance(0.1):
+		// Without package embed imported, go/build does not look for
+		// the directives at all.
+	case s.Chance(0.2):
+		f.useAs("_", "embed")
+	default:
+		f.use("embed")
+	}
+	for range s.Range(1, 4) {
+		if s.Chance(0.1) {
+			embedMisplaced(s, f)
+		} else {
+			embedVar(s, f)
+		}
+		f.blank()
+	}
+	if name, ok := f.imports["embed"]; ok && name == "" {
+		f.line("var _ embed.FS")
+	}
+	files := []gen.File{{Name: "embed.go", Data: f.bytes()}}
+	for _, name := range embedTree {
+		if s.Chance(0.9) {
+			files = append(files, gen.File{Name: name, Data: embedData(name)})
+		}
+	}
+	for _, name := range oddTree {
+		if s.Chance(0.03) {
+			files = append(files, gen.File{Name: name, Data: []byte(name + "\n")})
+		}
+	}
+	return files
+}
+
+// embedData returns what the file of embedTree called name holds: a
+// module line for the go.mod that makes its directory another module,
+// and its own name for any other.
+func embedData(name string) []byte {
+	if path.Base(name) == "go.mod" {
+		return []byte("module example.com/nested\n")
+	}
+	return []byte(name + "\n")
+}
+
+// embedDirective returns a //go:embed line of one or a few patterns,
+// quoted now and then.
+func embedDirective(s *gen.State) string {
+	var args []string
+	for range s.Range(1, 3) {
+		p := gen.Pick(s, embedPatterns...)
+		if s.Chance(0.03) {
+			p = gen.Pick(s, badEmbedPatterns...)
+		}
+		switch {
+		case strings.Contains(p, " ") || s.Chance(0.1):
+			p = `"` + strings.ReplaceAll(p, `\`, `\\`) + `"`
+		case s.Chance(0.05):
+			p = "`" + p + "`"
+		case s.Chance(0.02):
+			p = gen.Pick(s, `"unterminated`, "`unterminated", `"\q"`, `""`, "``", `"a b.txt"`, `"\x41.txt"`, `"a\"b.txt"`)
+		}
+		args = append(args, p)
+	}
+	sep := " "
+	if s.Chance(0.1) {
+		sep = gen.Pick(s, "\t", "  ", " \t")
+	}
+	return "//go:embed" + sep + strings.Join(args, sep)
+}
+
+// embedVar writes a package-level variable with one or more //go:embed
+// lines above it, an embed.FS only where package embed is imported by
+// name.
+func embedVar(s *gen.State, f *file) {
+	v := s.Fresh("embedded")
+	for range s.Range(1, 2) {
+		f.line("%s", embedDirective(s))
+	}
+	name, imported := f.imports["embed"]
+	switch {
+	case imported && name == "" && s.Chance(0.4):
+		f.line("var %s embed.FS", v)
+	case s.Chance(0.5):
+		f.line("var %s string", v)
+	default:
+		f.line("var %s []byte", v)
+	}
+}
+
+// embedMisplaced writes a //go:embed line where the compiler must reject
+// it: on a function, a constant, a variable with a value or of another
+// type, two variables, a local variable, after a blank line, or hidden
+// where go/build must not see it.
+func embedMisplaced(s *gen.State, f *file) {
+	v := s.Fresh("misplaced")
+	d := embedDirective(s)
+	switch s.Intn(9) {
+	case 0:
+		f.line("%s", d)
+		f.line("func %s() {}", v)
+	case 1:
+		f.line("%s", d)
+		f.line("const %s = \"\"", v)
+	case 2:
+		f.line("%s", d)
+		f.line("var %s = \"x\"", v)
+	case 3:
+		f.line("%s", d)
+		f.line("var %s int", v)
+	case 4:
+		f.line("%s", d)
+		f.line("var %s, %s2 string", v, v)
+	case 5:
+		f.open("func %s() {", v)
+		f.line("%s", d)
+		f.line("var x string")
+		f.line("_ = x")
+		f.close("}")
+	case 6:
+		f.line("%s", d)
+		f.blank()
+		f.line("var %s string", v)
+	case 7:
+		f.line("/*")
+		f.line("%s", d)
+		f.line("*/")
+		f.line("var %s string", v)
+	default:
+		f.line("var %s = %q", v, d)
+	}
+}
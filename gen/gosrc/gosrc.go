@@ -0,0 +1,170 @@
+// Package gosrc generates Go source seeds. Each file in the package covers
+// one construct family and registers a "go/..." generator with package gen.
+//
+// Seeds are written as text rather than printed from go/ast so that they
+// can deliberately include constructs the type checker (or the parser)
+// must reject next to the ones it must accept.
+package gosrc
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A snippet appends one self-contained group of declarations to f.
+type snippet func(s *gen.State, f *file)
+
+// file accumulates the body of a generated Go file and the imports it
+// needs.
+type file struct {
+	gen     string
+	seed    uint64   // of the State the file was generated from
+	lead    []string // lines between the header and the package clause
+	doc     []string // package doc comment, right above the package clause
+	pkg     string
+	imports map[string]string // import path to local name, "" for none
+	body    bytes.Buffer
+	depth   int
+}
+
+func newFile(s *gen.State, generator string) *file {
+	return &file{gen: generator, seed: s.Seed(), pkg: "p", imports: map[string]string{}}
+}
+
+// use records that the body refers to the package at path.
+func (f *file) use(path string) {
+	f.useAs("", path)
+}
+
+// useAs records an import of path under name, which may be "." or "_".
+func (f *file) useAs(name, path string) {
+	f.imports[path] = name
+}
+
+// importSpec returns the import spec for path as written in an import
+// block.
+func (f *file) importSpec(path string) string {
+	if name := f.imports[path]; name != "" {
+		return fmt.Sprintf("%s %q", name, path)
+	}
+	return fmt.Sprintf("%q", path)
+}
+
+// line writes one indented line to the body.
+func (f *file) line(format string, args ...any) {
+	for range f.depth {
+		f.body.WriteByte('\t')
+	}
+	fmt.Fprintf(&f.body, format, args...)
+	f.body.WriteByte('\n')
+}
+
+// open writes a line ending a block opener and indents what follows.
+func (f *file) open(format string, args ...any) {
+	f.line(format, args...)
+	f.depth++
+}
+
+// close outdents and writes the closing line of a block.
+func (f *file) close(s string) {
+	f.depth--
+	f.line("%s", s)
+}
+
+// mid writes a line that closes one block and opens the next, such as
+// "} else {" or a case clause.
+func (f *file) mid(format string, args ...any) {
+	f.depth--
+	f.line(format, args...)
+	f.depth++
+}
+
+// label writes a label, outdented as gofmt does.
+func (f *file) label(name string) {
+	f.mid("%s:", name)
+}
+
+func (f *file) blank() {
+	f.body.WriteByte('\n')
+}
+
+// header returns the first line of a generated file. It names the
+// generator and the seed of its State, which gen.NewStateSeed turns back
+// into the same file.
+func (f *file) header() string {
+	return fmt.Sprintf("// Code generated by seedgen (%s, seed %d). DO NOT EDIT.\n\n", f.gen, f.seed)
+}
+
+// bytes assembles the header, package clause, imports and body.
+func (f *file) bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString(f.header())
+	for _, l := range f.lead {
+		b.WriteString(l + "\n")
+	}
+	if len(f.lead) > 0 {
+		b.WriteByte('\n')
+	}
+	for _, l := range f.doc {
+		b.WriteString(l + "\n")
+	}
+	fmt.Fprintf(&b, "package %s\n\n", f.pkg)
+	if len(f.imports) > 0 {
+		b.WriteString("import (\n")
+		for _, p := range sortedKeys(f.imports) {
+			fmt.Fprintf(&b, "\t%s\n", f.importSpec(p))
+		}
+		b.WriteString(")\n\n")
+	}
+	b.Write(f.body.Bytes())
+	return b.Bytes()
+}
+
+// files returns f as a single-file seed.
+func (f *file) files() []gen.File {
+	return []gen.File{{Name: "seed.go", Data: f.bytes()}}
+}
+
+// fill appends between lo and hi snippets drawn from pool to f.
+func fill(s *gen.State, f *file, lo, hi int, pool ...snippet) {
+	for range s.Range(lo, hi) {
+		gen.Pick(s, pool...)(s, f)
+		f.blank()
+	}
+}
+
+// A mainSnippet appends a function with no parameters to a main package
+// and returns its name.
+type mainSnippet func(s *gen.State, f *file) string
+
+// fillMain appends between lo and hi snippets drawn from pool to f and
+// returns the functions they declared, in order.
+func fillMain(s *gen.State, f *file, lo, hi int, pool ...mainSnippet) []string {
+	var calls []string
+	for range s.Range(lo, hi) {
+		calls = append(calls, gen.Pick(s, pool...)(s, f))
+		f.blank()
+	}
+	return calls
+}
+
+// main writes a main function calling each of calls.
+func (f *file) main(calls []string) {
+	f.open("func main() {")
+	for _, c := range calls {
+		f.line("%s()", c)
+	}
+	f.close("}")
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
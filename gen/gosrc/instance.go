@@ -0,0 +1,199 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/instantiate",
+		Doc:  "generic instantiation bombs: doubling type arguments, deep nesting, wide type parameter lists, cyclic bounds and inference chains",
+		Func: instantiations,
+	})
+}
+
+// instantiations writes shapes whose cost to check or compile grows with
+// a size drawn from the Generic limit. At the default sizes every seed
+// is cheap; raising -depth.generic turns them into compile-time bombs for
+// fuzz/cost to measure.
+func instantiations(s *gen.State) []gen.File {
+	f := newFile(s, "go/instantiate")
+	fill(s, f, 2, 5,
+		instDoubling, instDoubling,
+		instNested,
+		instWide,
+		instCyclicBounds,
+		instInference,
+		instMethods,
+	)
+	if s.Chance(0.1) {
+		badInst(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// typeParams returns "P0, P1, ..., Pn-1" for prefix P.
+func typeParams(prefix string, n int) []string {
+	ps := make([]string, n)
+	for i := range ps {
+		ps[i] = fmt.Sprintf("%s%d", prefix, i)
+	}
+	return ps
+}
+
+// instDoubling chains generic functions that each call the next with a
+// pair of their own type argument, so the type at the end of a chain of
+// n has 2**n leaves. The type checker shares the structure; the compiler
+// has historically not.
+func instDoubling(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 8)
+	p, d := s.Fresh("Pair"), s.Fresh("double")
+	f.line("type %s[A, B any] struct {", p)
+	f.line("\ta A")
+	f.line("\tb B")
+	f.line("}")
+	f.blank()
+	arg := gen.Pick(s, p+"[T, T]", p+"[T, *T]", "["+fmt.Sprint(s.Range(1, 2))+"]"+p+"[T, T]")
+	for i := range n {
+		f.line("func %s_%d[T any]() int { return %s_%d[%s]() + 1 }", d, i, d, i+1, arg)
+	}
+	f.line("func %s_%d[T any]() int { var x T; _ = x; return 0 }", d, n)
+	f.blank()
+	f.line("var _ = %s_0[%s]()", d, gen.Pick(s, "int", "string", "struct{}", "[]byte"))
+}
+
+// instNested writes one type n instantiations deep.
+func instNested(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 16)
+	g := s.Fresh("Box")
+	switch s.Intn(3) {
+	case 0:
+		f.line("type %s[T any] struct{ v T }", g)
+		f.line("var %s %s", s.Fresh("v"), wrap(n, g+"[", "int", "]"))
+	case 1:
+		f.line("type %s[K comparable, V any] map[K]V", g)
+		f.line("var %s %s", s.Fresh("v"), wrap(n, g+"[int, ", "string", "]"))
+	default:
+		// Generic aliases are expanded at each use.
+		a := s.Fresh("Alias")
+		f.line("type %s[T any] struct{ v T }", g)
+		f.line("type %s[T any] = []%s[T]", a, g)
+		f.line("var %s %s", s.Fresh("v"), wrap(n, a+"[", "int", "]"))
+	}
+}
+
+// instWide declares a type with a long type parameter list and
+// instantiates it, once with its own instance in every position.
+func instWide(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 32)
+	w := s.Fresh("Wide")
+	ps := typeParams("T", n)
+	f.line("type %s[%s any] struct {", w, strings.Join(ps, ", "))
+	for i, p := range ps {
+		f.line("\tf%d %s", i, p)
+	}
+	f.line("}")
+	f.blank()
+	args := make([]string, n)
+	for i := range args {
+		args[i] = gen.Pick(s, "int", "string", "bool", "[]int", "*int", "error", "any")
+	}
+	inner := fmt.Sprintf("%s[%s]", w, strings.Join(args, ", "))
+	f.line("var %s %s", s.Fresh("v"), inner)
+	if s.Chance(0.5) {
+		outer := make([]string, n)
+		for i := range outer {
+			outer[i] = inner
+		}
+		f.line("var %s %s[%s]", s.Fresh("v"), w, strings.Join(outer, ", "))
+	}
+}
+
+// instCyclicBounds writes a function whose type parameters bound each
+// other in a cycle, X0's method returning X1 and so on back to X0, and
+// the types that satisfy it.
+func instCyclicBounds(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 6)
+	fn, t := s.Fresh("cyclic"), s.Fresh("Link")
+	ps := typeParams("X", n)
+	bounds := make([]string, n)
+	for i, p := range ps {
+		bounds[i] = fmt.Sprintf("%s interface{ next() %s }", p, ps[(i+1)%n])
+	}
+	f.line("func %s[%s](x X0) X0 {", fn, strings.Join(bounds, ", "))
+	f.line("\treturn x%s", strings.Repeat(".next()", n))
+	f.line("}")
+	f.blank()
+	types := typeParams(t+"_", n)
+	for i, ty := range types {
+		f.line("type %s struct{}", ty)
+		f.line("func (%s) next() %s { return %s{} }", ty, types[(i+1)%n], types[(i+1)%n])
+	}
+	f.blank()
+	if s.Chance(0.5) {
+		f.line("var _ = %s[%s](%s{})", fn, strings.Join(types, ", "), types[0])
+	} else {
+		// Inference has to walk the cycle from X0 alone.
+		f.line("var _ = %s(%s{})", fn, types[0])
+	}
+}
+
+// instInference writes a function whose type parameters are each the
+// core element type of the one before, and calls it on a nested slice so
+// that inference recovers all of them.
+func instInference(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 8)
+	fn := s.Fresh("peel")
+	ps := typeParams("S", n+1)
+	bounds := make([]string, n+1)
+	for i := range n {
+		bounds[i] = fmt.Sprintf("%s ~[]%s", ps[i], ps[i+1])
+	}
+	bounds[n] = ps[n] + " any"
+	f.line("func %s[%s](s %s) %s {", fn, strings.Join(bounds, ", "), ps[0], ps[n])
+	f.line("\treturn s%s", strings.Repeat("[0]", n))
+	f.line("}")
+	f.blank()
+	f.line("var _ = %s(%sint%s)", fn, strings.Repeat("[]", n), wrap(n, "{", "1", "}"))
+}
+
+// instMethods calls a method through n levels of a generic type, each
+// call instantiating the method set of the next level down.
+func instMethods(s *gen.State, f *file) {
+	n := s.Depth(s.Limits.Generic, 12)
+	l := s.Fresh("Layer")
+	f.line("type %s[T any] struct{ v T }", l)
+	f.blank()
+	f.line("func (l %s[T]) Get() T { return l.v }", l)
+	f.line("func (l *%s[T]) Set(v T) { l.v = v }", l)
+	f.blank()
+	f.open("func %s() int {", s.Fresh("layers"))
+	f.line("var x %s", wrap(n, l+"[", "int", "]"))
+	f.line("x%s.Set(1)", strings.Repeat(".v", n-1))
+	f.line("return x%s", strings.Repeat(".Get()", n))
+	f.close("}")
+}
+
+// badInst writes an instantiation cycle, which a conforming type checker
+// must reject rather than expand forever.
+func badInst(s *gen.State, f *file) {
+	n := s.Fresh("Bad")
+	switch s.Intn(4) {
+	case 0:
+		f.line("type %s[T any] struct{ v T }", n)
+		f.line("func (b %s[T]) Wrap() %s[%s[T]] { return %s[%s[T]]{b} }", n, n, n, n, n)
+	case 1:
+		f.line("type %s[T any] struct{ next *%s[%s[T]] }", n, n, n)
+		f.line("var _ %s[int]", n)
+	case 2:
+		f.line("func %s[T any](n int) { if n > 0 { %s[*T](n - 1) } }", n, n)
+	default:
+		g := s.Fresh("bad")
+		f.line("func %s[T any]() { %s[[]T]() }", n, g)
+		f.line("func %s[T any]() { %s[map[int]T]() }", g, n)
+	}
+}
@@ -0,0 +1,423 @@
+package gosrc
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/iota",
+		Doc:  "const groups with iota in nested expressions, blank and multi-name specs, implicit repetition, typed constants at their overflow edge and function-local and shadowed iota",
+		Func: iotaSeed,
+	})
+}
+
+// iotaSeed writes const groups whose every constant is pinned to the
+// value the generator works out for it, as in go/consts, so a seed that
+// fails to type check without a badIota is a finding.
+func iotaSeed(s *gen.State) []gen.File {
+	f := newFile(s, "go/iota")
+	fill(s, f, 3, 8,
+		iotaGroup, iotaGroup, iotaGroup,
+		iotaTyped, iotaTyped,
+		iotaLocal,
+		iotaShadow,
+	)
+	if s.Chance(0.15) {
+		badIota(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// An iotaType is the integer type of a typed constant, or the zero value
+// for an untyped one.
+type iotaType struct {
+	name   string
+	bits   int
+	signed bool
+}
+
+// fits reports whether v is representable in t.
+func (t iotaType) fits(v *big.Int) bool {
+	return t.name == "" || fitsInt(v, t.bits, t.signed)
+}
+
+// An iotaVal is the value of a constant expression and its type.
+type iotaVal struct {
+	v   *big.Int
+	typ iotaType
+}
+
+// An iotaExpr is a constant expression in iota. Its value depends on the
+// line of the const group it is evaluated on; eval reports false if the
+// expression is invalid there.
+type iotaExpr struct {
+	src  string
+	eval func(iota int64) (iotaVal, bool)
+}
+
+// A named constant from an earlier line of the group, which later lines
+// may use.
+type iotaConst struct {
+	name string
+	val  iotaVal
+}
+
+// iotaLeaf returns iota, a literal, an earlier constant or an array
+// length that is iota.
+func iotaLeaf(s *gen.State, t iotaType, prev []iotaConst) iotaExpr {
+	switch n := s.Intn(10); {
+	case n < 4:
+		return iotaExpr{"iota", func(i int64) (iotaVal, bool) {
+			return iotaVal{big.NewInt(i), iotaType{}}, true
+		}}
+	case n < 6:
+		k := int64(s.Range(-3, 17))
+		if s.Chance(0.2) {
+			k = int64(s.Range(-1000, 70000))
+		}
+		src := fmt.Sprint(k)
+		if k < 0 {
+			src = "(" + src + ")"
+		}
+		return iotaExpr{src, func(int64) (iotaVal, bool) {
+			return iotaVal{big.NewInt(k), iotaType{}}, true
+		}}
+	case n < 7 && len(prev) > 0:
+		c := gen.Pick(s, prev...)
+		return iotaExpr{c.name, func(int64) (iotaVal, bool) { return c.val, true }}
+	case n < 8:
+		// An array length is evaluated with the enclosing iota, and
+		// len of an array literal without calls is a constant of type
+		// int. An iota in a const declaration inside a function literal
+		// in that array is that declaration's own, 0.
+		src := "len([iota]func(){})"
+		if s.Chance(0.4) {
+			z := s.Fresh("z")
+			src = fmt.Sprintf("(len([iota + 1]func(){func() { const %s = iota; var _ = [1]struct{}{}[%s] }}) - 1)", z, z)
+		}
+		return iotaExpr{src, func(i int64) (iotaVal, bool) {
+			return iotaVal{big.NewInt(i), iotaType{"int", 64, true}}, true
+		}}
+	case t.name != "":
+		// A conversion makes the rest of the expression typed.
+		x := iotaTree(s, iotaType{}, prev, 1)
+		return iotaExpr{fmt.Sprintf("%s(%s)", t.name, x.src), func(i int64) (iotaVal, bool) {
+			v, ok := x.eval(i)
+			if !ok || !t.fits(v.v) {
+				return iotaVal{}, false
+			}
+			return iotaVal{v.v, t}, true
+		}}
+	default:
+		return iotaExpr{"iota", func(i int64) (iotaVal, bool) {
+			return iotaVal{big.NewInt(i), iotaType{}}, true
+		}}
+	}
+}
+
+// iotaTree returns an expression of depth at most d over iotaLeaf.
+func iotaTree(s *gen.State, t iotaType, prev []iotaConst, d int) iotaExpr {
+	if d == 0 || s.Chance(0.25) {
+		return iotaLeaf(s, t, prev)
+	}
+	if s.Chance(0.15) {
+		x := iotaTree(s, t, prev, d-1)
+		op := gen.Pick(s, "-", "^", "+")
+		return iotaExpr{fmt.Sprintf("(%s%s)", op, x.src), func(i int64) (iotaVal, bool) {
+			v, ok := x.eval(i)
+			if !ok {
+				return iotaVal{}, false
+			}
+			return iotaUnary(op, v)
+		}}
+	}
+	x, y := iotaTree(s, t, prev, d-1), iotaTree(s, t, prev, d-1)
+	op := gen.Pick(s, "+", "-", "*", "/", "%", "&", "|", "^", "&^", "<<", ">>")
+	if op == "<<" || op == ">>" {
+		if s.Chance(0.5) {
+			y = iotaExpr{"iota", func(i int64) (iotaVal, bool) {
+				return iotaVal{big.NewInt(i), iotaType{}}, true
+			}}
+		}
+	}
+	return iotaExpr{fmt.Sprintf("(%s %s %s)", x.src, op, y.src), func(i int64) (iotaVal, bool) {
+		a, ok := x.eval(i)
+		if !ok {
+			return iotaVal{}, false
+		}
+		b, ok := y.eval(i)
+		if !ok {
+			return iotaVal{}, false
+		}
+		return iotaBinary(op, a, b)
+	}}
+}
+
+// iotaUnary applies op to v. ^ on an untyped or signed constant is
+// -v-1; on an unsigned typed one it flips the type's bits.
+func iotaUnary(op string, v iotaVal) (iotaVal, bool) {
+	z := new(big.Int)
+	switch op {
+	case "+":
+		z.Set(v.v)
+	case "-":
+		z.Neg(v.v)
+	default:
+		if v.typ.name != "" && !v.typ.signed {
+			mask := new(big.Int).Lsh(big.NewInt(1), uint(v.typ.bits))
+			z.Xor(v.v, mask.Sub(mask, big.NewInt(1)))
+		} else {
+			z.Not(v.v)
+		}
+	}
+	return iotaVal{z, v.typ}, v.typ.fits(z)
+}
+
+// iotaBinary applies op to a and b. The result of a shift has the left
+// operand's type, any other result the type of a typed operand, and
+// operands of two different types do not mix; an untyped operand is
+// converted to the other's type, and a typed result must be
+// representable in its type, an untyped one in untypedBits.
+func iotaBinary(op string, a, b iotaVal) (iotaVal, bool) {
+	typ := a.typ
+	if op != "<<" && op != ">>" {
+		if typ.name == "" {
+			typ = b.typ
+		} else if b.typ.name != "" && b.typ != typ {
+			return iotaVal{}, false
+		}
+		if !typ.fits(a.v) || !typ.fits(b.v) {
+			return iotaVal{}, false
+		}
+	}
+	z := new(big.Int)
+	switch op {
+	case "+":
+		z.Add(a.v, b.v)
+	case "-":
+		z.Sub(a.v, b.v)
+	case "*":
+		z.Mul(a.v, b.v)
+	case "/", "%":
+		if b.v.Sign() == 0 {
+			return iotaVal{}, false
+		}
+		if op == "/" {
+			z.Quo(a.v, b.v)
+		} else {
+			z.Rem(a.v, b.v)
+		}
+	case "&":
+		z.And(a.v, b.v)
+	case "|":
+		z.Or(a.v, b.v)
+	case "^":
+		z.Xor(a.v, b.v)
+	case "&^":
+		z.AndNot(a.v, b.v)
+	default:
+		if !b.v.IsInt64() || b.v.Sign() < 0 || b.v.Int64() > shiftBound {
+			return iotaVal{}, false
+		}
+		if op == "<<" {
+			z.Lsh(a.v, uint(b.v.Int64()))
+		} else {
+			z.Rsh(a.v, uint(b.v.Int64()))
+		}
+	}
+	if z.BitLen() > untypedBits || !typ.fits(z) {
+		return iotaVal{}, false
+	}
+	return iotaVal{z, typ}, true
+}
+
+// iotaSpec is one explicit line of a const group and the lines that
+// repeat it implicitly.
+type iotaSpec struct {
+	typ   iotaType
+	exprs []iotaExpr
+	lines int
+}
+
+// iotaWrite writes a const group of specs of the given types, each with
+// a few tries to find expressions valid on all of its lines, and pins
+// every named constant.
+func iotaWrite(s *gen.State, f *file, types ...iotaType) {
+	var prev []iotaConst
+	var lines []string
+	var next int64
+	for range s.Range(1, 4) {
+		t := gen.Pick(s, types...)
+		names := s.Range(1, 3)
+		n := s.Range(1, 8)
+		var spec iotaSpec
+		var vals [][]iotaVal
+		for try := 0; ; try++ {
+			spec = iotaSpec{t, nil, n}
+			for range names {
+				e := iotaTree(s, t, prev, s.Range(1, 3))
+				if try > 8 {
+					e = iotaLeaf(s, iotaType{}, nil)
+				}
+				spec.exprs = append(spec.exprs, e)
+			}
+			if vals = iotaEval(spec, next); vals != nil {
+				break
+			}
+		}
+		for l := range spec.lines {
+			var idents []string
+			for j := range spec.exprs {
+				name := "_"
+				if !s.Chance(0.2) {
+					name = s.Fresh("k")
+					prev = append(prev, iotaConst{name, vals[l][j]})
+				}
+				idents = append(idents, name)
+			}
+			line := strings.Join(idents, ", ")
+			if l == 0 {
+				if t.name != "" {
+					line += " " + t.name
+				}
+				var srcs []string
+				for _, e := range spec.exprs {
+					srcs = append(srcs, e.src)
+				}
+				line += " = " + strings.Join(srcs, ", ")
+			}
+			lines = append(lines, line)
+		}
+		next += int64(spec.lines)
+	}
+	if len(lines) == 1 && s.Chance(0.5) {
+		f.line("const %s", lines[0])
+	} else {
+		f.open("const (")
+		for i, l := range lines {
+			switch {
+			case i == 0:
+			case s.Chance(0.05):
+				// Neither comments nor blank lines are specs.
+				f.line("// iota is not incremented here")
+			case s.Chance(0.05):
+				f.blank()
+			}
+			f.line("%s", l)
+		}
+		f.close(")")
+	}
+	for _, c := range prev {
+		assertEq(f, c.name, c.val.v)
+	}
+}
+
+// iotaEval evaluates the spec's expressions on each of its lines, the
+// first of which has the given iota, and converts them to the spec's
+// type. It returns nil if any is invalid.
+func iotaEval(spec iotaSpec, iota int64) [][]iotaVal {
+	vals := make([][]iotaVal, spec.lines)
+	for l := range spec.lines {
+		for _, e := range spec.exprs {
+			v, ok := e.eval(iota + int64(l))
+			if !ok || !spec.typ.fits(v.v) || (v.typ.name != "" && spec.typ.name != "" && v.typ != spec.typ) {
+				return nil
+			}
+			if spec.typ.name != "" {
+				v.typ = spec.typ
+			}
+			vals[l] = append(vals[l], v)
+		}
+	}
+	return vals
+}
+
+func iotaGroup(s *gen.State, f *file) {
+	iotaWrite(s, f, iotaType{})
+}
+
+// iotaTyped declares an integer type and mixes constants of it with
+// untyped ones. Typed arithmetic must stay within the type, so shifts
+// and ^ land on its edges.
+func iotaTyped(s *gen.State, f *file) {
+	it := gen.Pick(s, intTypes...)
+	t := iotaType{it.name, it.bits, it.signed}
+	if s.Chance(0.6) {
+		named := s.Fresh("T")
+		f.line("type %s %s", named, it.name)
+		f.blank()
+		t.name = named
+	}
+	iotaWrite(s, f, t, t, iotaType{})
+}
+
+// iotaLocal writes const groups inside a function, where iota starts
+// again at 0 in each, pinned by blank variables.
+func iotaLocal(s *gen.State, f *file) {
+	f.open("func %s() {", s.Fresh("local"))
+	for range s.Range(1, 3) {
+		iotaWrite(s, f, iotaType{})
+	}
+	f.close("}")
+}
+
+// iotaShadow declares a local constant or type named iota, after which
+// iota in a const group means that declaration and no longer counts.
+func iotaShadow(s *gen.State, f *file) {
+	f.open("func %s() {", s.Fresh("shadow"))
+	k := s.Range(-5, 100)
+	f.line("const iota = %d", k)
+	c := s.Fresh("k")
+	f.open("const (")
+	f.line("%s = iota * 2", c)
+	d := s.Fresh("k")
+	f.line("%s", d)
+	f.close(")")
+	assertEq(f, c, big.NewInt(int64(2*k)))
+	assertEq(f, d, big.NewInt(int64(2*k)))
+	f.close("}")
+}
+
+// badIota writes an iota use that a type checker must reject.
+func badIota(s *gen.State, f *file) {
+	b := s.Fresh("Bad")
+	switch s.Intn(7) {
+	case 0:
+		f.line("var %s = iota", b)
+	case 1:
+		// Implicit repetition needs as many names as expressions.
+		f.open("const (")
+		f.line("%s, %s_ = iota, iota", b, b)
+		f.line("%s2", b)
+		f.close(")")
+	case 2:
+		f.open("const (")
+		f.line("%s0 = 1 / (iota - 1)", b)
+		f.line("%s1", b)
+		f.close(")")
+	case 3:
+		f.open("const (")
+		f.line("%s uint8 = 255 + iota", b)
+		f.line("%s1", b)
+		f.close(")")
+	case 4:
+		f.open("const (")
+		f.line("%s int8 = -iota - 127", b)
+		f.line("%s1", b)
+		f.line("%s2", b)
+		f.close(")")
+	case 5:
+		f.line("const %s uint16 = ^uint16(iota) + 1", b)
+	default:
+		// A const group cannot start by repeating nothing.
+		f.open("const (")
+		f.line("%s", b)
+		f.close(")")
+	}
+}
@@ -0,0 +1,253 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/literals",
+		Doc:  "string and rune literals with invalid UTF-8 escapes, boundary code points, raw strings with carriage returns, and escapes the spec forbids",
+		Func: literals,
+	})
+}
+
+// literals writes string and rune constants whose decoded values the
+// generator knows, pinned by compile-time assertions: a rune by index
+// arithmetic, a string by its length and by a map literal that has a
+// duplicate key unless the string equals its all-\x spelling:
+//
+//	var _ = map[bool]int{false: 0, s0 == "\x61\xff": 1}
+//
+// A seed without a badLiteral that fails to type check is a finding.
+func literals(s *gen.State) []gen.File {
+	f := newFile(s, "go/literals")
+	fill(s, f, 4, 10,
+		litString, litString, litString,
+		litRaw, litRaw,
+		litRune, litRune,
+		litConvert,
+	)
+	if s.Chance(0.2) {
+		badLiteral(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// A litPiece is a fragment of a literal's source text and the bytes it
+// stands for.
+type litPiece struct {
+	src, val string
+}
+
+// litBoundaries are code points at the edges of UTF-8 encoding lengths,
+// of the surrogate range and of Unicode.
+var litBoundaries = []rune{
+	0, 0x7f, 0x80, 0xff, 0x7ff, 0x800, 0xd7ff, 0xe000, 0xfeff, 0xfffd,
+	0xfffe, 0xffff, 0x10000, 0x1f600, 0xe0001, 0xfffff, 0x10fffd, 0x10ffff,
+}
+
+// litCodePoint returns a valid code point, usually a boundary.
+func litCodePoint(s *gen.State) rune {
+	if s.Chance(0.7) {
+		return gen.Pick(s, litBoundaries...)
+	}
+	for {
+		r := rune(s.Intn(utf8.MaxRune + 1))
+		if utf8.ValidRune(r) {
+			return r
+		}
+	}
+}
+
+// escapePiece returns an escape sequence valid in interpreted strings.
+func escapePiece(s *gen.State) litPiece {
+	switch s.Intn(6) {
+	case 0:
+		return gen.Pick(s,
+			litPiece{`\a`, "\a"}, litPiece{`\b`, "\b"}, litPiece{`\f`, "\f"},
+			litPiece{`\n`, "\n"}, litPiece{`\r`, "\r"}, litPiece{`\t`, "\t"},
+			litPiece{`\v`, "\v"}, litPiece{`\\`, "\\"}, litPiece{`\"`, "\""},
+		)
+	case 1:
+		b := byte(s.Intn(256))
+		return litPiece{`\x` + hexDigits(s, fmt.Sprintf("%02x", b)), string([]byte{b})}
+	case 2:
+		b := byte(s.Intn(256))
+		return litPiece{fmt.Sprintf(`\%03o`, b), string([]byte{b})}
+	case 3:
+		// Byte sequences that are not UTF-8: overlong, surrogate,
+		// truncated, past the last code point, lone continuation.
+		return gen.Pick(s,
+			litPiece{`\xc0\x80`, "\xc0\x80"}, litPiece{`\xed\xa0\x80`, "\xed\xa0\x80"},
+			litPiece{`\xed\xbf\xbf`, "\xed\xbf\xbf"}, litPiece{`\xe2\x82`, "\xe2\x82"},
+			litPiece{`\xf4\x90\x80\x80`, "\xf4\x90\x80\x80"}, litPiece{`\x80`, "\x80"},
+			litPiece{`\xff\xfe`, "\xff\xfe"}, litPiece{`\360\237\230`, "\xf0\x9f\x98"},
+		)
+	default:
+		r := litCodePoint(s)
+		if r <= 0xffff && s.Chance(0.6) {
+			return litPiece{`\u` + hexDigits(s, fmt.Sprintf("%04x", r)), string(r)}
+		}
+		return litPiece{`\U` + hexDigits(s, fmt.Sprintf("%08x", r)), string(r)}
+	}
+}
+
+// hexDigits returns h in upper, lower or mixed case.
+func hexDigits(s *gen.State, h string) string {
+	switch s.Intn(3) {
+	case 0:
+		return strings.ToUpper(h)
+	case 1:
+		return h
+	}
+	b := []byte(h)
+	for i := range b {
+		if s.Chance(0.5) {
+			b[i] = strings.ToUpper(string(b[i]))[0]
+		}
+	}
+	return string(b)
+}
+
+// rawPiece returns text written as itself, valid in interpreted strings
+// if !raw and in raw strings otherwise.
+func rawPiece(s *gen.State, raw bool) litPiece {
+	// Decomposed é, a zero-width space, a no-break space, U+FFFD and a
+	// right-to-left override are written as themselves, not escaped.
+	t := gen.Pick(s, "a", " ", "'", "\t", "\u00e9", "e\u0301", "日本", "\U0001F600", "\u200b", "\u00a0", "\ufffd", "%v")
+	if raw {
+		t = gen.Pick(s, t, `"`, `\`, `\n`, `\x41`, "\n", "\r\n")
+	} else {
+		t = gen.Pick(s, t, "`", "{}", "\u202e")
+	}
+	return litPiece{t, strings.ReplaceAll(t, "\r", "")}
+}
+
+// hexString writes v with every byte as a \x escape.
+func hexString(v string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := range len(v) {
+		fmt.Fprintf(&b, `\x%02x`, v[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// pinString writes the assertions that the string constant c is v.
+func pinString(f *file, c, v string) {
+	f.line("var _ = [1]struct{}{}[len(%s) - %d]", c, len(v))
+	f.line("var _ = map[bool]int{false: 0, %s == %s: 1}", c, hexString(v))
+}
+
+// litString writes an interpreted string literal of escapes and text.
+func litString(s *gen.State, f *file) {
+	var src, val strings.Builder
+	for range s.Range(0, 12) {
+		p := rawPiece(s, false)
+		if s.Chance(0.6) {
+			p = escapePiece(s)
+		}
+		src.WriteString(p.src)
+		val.WriteString(p.val)
+	}
+	c := s.Fresh("s")
+	f.line("const %s = \"%s\"", c, src.String())
+	pinString(f, c, val.String())
+}
+
+// litRaw writes a raw string literal, some with carriage returns, which
+// are dropped from its value, and some joined to an interpreted one.
+func litRaw(s *gen.State, f *file) {
+	var src, val strings.Builder
+	for range s.Range(0, 10) {
+		p := rawPiece(s, true)
+		if s.Chance(0.2) {
+			p = litPiece{"\r", ""}
+		}
+		src.WriteString(p.src)
+		val.WriteString(p.val)
+	}
+	c := s.Fresh("s")
+	if s.Chance(0.3) {
+		e := escapePiece(s)
+		f.line("const %s = `%s` + \"%s\"", c, src.String(), e.src)
+		val.WriteString(e.val)
+	} else {
+		f.line("const %s = `%s`", c, src.String())
+	}
+	pinString(f, c, val.String())
+}
+
+var runeEscapes = map[rune]string{
+	'\a': `\a`, '\b': `\b`, '\f': `\f`, '\n': `\n`, '\r': `\r`,
+	'\t': `\t`, '\v': `\v`, '\\': `\\`, '\'': `\'`,
+}
+
+// litRune writes a rune literal in one of the notations its value
+// allows.
+func litRune(s *gen.State, f *file) {
+	r := litCodePoint(s)
+	if s.Chance(0.3) {
+		r = rune(s.Intn(256))
+	}
+	var forms []string
+	if r < 256 {
+		forms = append(forms, `\x`+hexDigits(s, fmt.Sprintf("%02x", r)), fmt.Sprintf(`\%03o`, r))
+	}
+	if r <= 0xffff {
+		forms = append(forms, `\u`+hexDigits(s, fmt.Sprintf("%04x", r)))
+	}
+	forms = append(forms, `\U`+hexDigits(s, fmt.Sprintf("%08x", r)))
+	if e, ok := runeEscapes[r]; ok {
+		forms = append(forms, e)
+	}
+	switch r {
+	case '\n', '\\', '\'', 0, 0xfeff:
+		// Not allowed as themselves in a rune literal or anywhere in
+		// source text.
+	default:
+		if r >= ' ' && r != 0x7f {
+			forms = append(forms, string(r))
+		}
+	}
+	c := s.Fresh("r")
+	f.line("const %s = '%s'", c, gen.Pick(s, forms...))
+	f.line("var _ = [1]struct{}{}[%s - %d]", c, r)
+	if s.Chance(0.3) {
+		// A rune literal is an untyped rune constant, usable as any
+		// integer type it fits.
+		f.line("var _ %s = %s", gen.Pick(s, "int32", "int64", "uint32", "float64", "complex64"), c)
+	}
+}
+
+// litConvert converts integer constants to strings, which yields the
+// UTF-8 encoding of valid code points and U+FFFD for anything else.
+func litConvert(s *gen.State, f *file) {
+	r := gen.Pick(s, rune(-1), 0xd800, 0xdfff, 0x110000, 0x7fffffff, litCodePoint(s))
+	want := string(r)
+	if !utf8.ValidRune(r) {
+		want = "\ufffd"
+	}
+	c := s.Fresh("s")
+	f.line("const %s = string(rune(%d))", c, r)
+	pinString(f, c, want)
+}
+
+// badLiteral writes a literal the scanner or type checker must reject.
+// Some of them, a raw NUL or invalid UTF-8 byte, are not valid source
+// text at all.
+func badLiteral(s *gen.State, f *file) {
+	b := s.Fresh("bad")
+	f.line("const %s = %s", b, gen.Pick(s,
+		`'\uD800'`, `"\uDFFF"`, `'\U00110000'`, `"\U7fffffff"`, `'\400'`,
+		`''`, `'ab'`, `"\q"`, `"\x4"`, `"\u12"`, `'\"'`, `"\'"`,
+		"\"\xff\"", "\"a\x00b\"", "`\xc0\x80`", "'\xe2\x82'",
+	))
+}
@@ -0,0 +1,317 @@
+package gosrc
+
+import (
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/module",
+		Doc:  "module trees of several multi-file packages: internal packages, import DAGs, dot and blank imports",
+		Func: module,
+	})
+}
+
+// modulePath is the module path of generated module trees.
+const modulePath = "seed"
+
+// A modPkg is one package of a generated module.
+type modPkg struct {
+	dir   string // slash-separated, relative to the module root
+	name  string
+	files []*file
+	names map[*file]string // file names other than the default
+	deps  []*modPkg        // imported for use
+	blank []*modPkg        // imported for side effects only
+
+	// Exported API.
+	c, v, fn, typ, iface, alias, box, gAlias, mapFn string
+}
+
+func (p *modPkg) path() string {
+	if p.dir == "" {
+		return modulePath
+	}
+	return modulePath + "/" + p.dir
+}
+
+// canImport reports whether the internal package rule lets p import q.
+func (p *modPkg) canImport(q *modPkg) bool {
+	i := strings.LastIndex("/"+q.dir+"/", "/internal/")
+	if i < 0 {
+		return true
+	}
+	parent := strings.TrimSuffix(("/" + q.dir)[:i], "/")
+	parent = strings.TrimPrefix(parent, "/")
+	return parent == "" || p.dir == parent || strings.HasPrefix(p.dir, parent+"/")
+}
+
+// newFile adds a file to p.
+func (p *modPkg) newFile(s *gen.State) *file {
+	f := newFile(s, "go/module")
+	f.pkg = p.name
+	p.files = append(p.files, f)
+	return f
+}
+
+// file returns one of p's files at random.
+func (p *modPkg) file(s *gen.State) *file {
+	return gen.Pick(s, p.files...)
+}
+
+// decl writes a one-line declaration to one of p's files.
+func (p *modPkg) decl(s *gen.State, format string, args ...any) {
+	f := p.file(s)
+	f.line(format, args...)
+	f.blank()
+}
+
+// qual returns the qualifier f uses for names from q, importing q into f
+// first if needed.
+func qual(s *gen.State, f *file, q *modPkg) string {
+	name, ok := f.imports[q.path()]
+	if !ok {
+		switch {
+		case s.Chance(0.15):
+			name = "."
+		case s.Chance(0.15):
+			name = s.Fresh(q.name)
+		}
+		f.useAs(name, q.path())
+	}
+	switch name {
+	case ".":
+		return ""
+	case "":
+		return q.name + "."
+	}
+	return name + "."
+}
+
+func module(s *gen.State) []gen.File {
+	pkgs := modLayout(s)
+	// Declare leaves first so importers know the names of their deps.
+	for i := len(pkgs) - 1; i >= 0; i-- {
+		modDeclare(s, pkgs[i])
+		for _, d := range pkgs[i].deps {
+			modUse(s, pkgs[i], d)
+		}
+		if len(pkgs[i].blank) > 0 {
+			f := pkgs[i].newFile(s)
+			for _, d := range pkgs[i].blank {
+				f.useAs("_", d.path())
+			}
+		}
+	}
+	if s.Chance(0.2) {
+		pkgs = badModule(s, pkgs)
+	}
+
+	out := []gen.File{{Name: "go.mod", Data: []byte("module " + modulePath + "\n\ngo 1.24\n")}}
+	for _, p := range pkgs {
+		for i, f := range p.files {
+			name := p.names[f]
+			if name == "" {
+				name = fmt.Sprintf("%s%d.go", p.name, i)
+			}
+			out = append(out, gen.File{Name: path.Join(p.dir, name), Data: f.bytes()})
+		}
+	}
+	return out
+}
+
+// modLayout picks the packages of the module and the import edges
+// between them. Package 0 is the main package at the root; a package only
+// imports packages after it, so the graph is acyclic.
+func modLayout(s *gen.State) []*modPkg {
+	pkgs := []*modPkg{{name: "main", names: map[*file]string{}}}
+	used := map[string]bool{"main": true}
+	for range s.Range(1, 5) {
+		name := gen.Pick(s, "util", "store", "api", "core", "codec", "wire", "x")
+		for used[name] {
+			name += "x"
+		}
+		used[name] = true
+		p := &modPkg{name: name, names: map[*file]string{}}
+		switch {
+		case s.Chance(0.4):
+			// Internal to the root or to an earlier package.
+			parent := gen.Pick(s, pkgs...)
+			p.dir = path.Join(parent.dir, "internal", name)
+		case s.Chance(0.2):
+			// A major version directory; the package keeps its name.
+			p.dir = name + "/v2"
+		default:
+			p.dir = name
+		}
+		pkgs = append(pkgs, p)
+	}
+	for j := 1; j < len(pkgs); j++ {
+		var importers []*modPkg
+		for _, p := range pkgs[:j] {
+			if p.canImport(pkgs[j]) {
+				importers = append(importers, p)
+			}
+		}
+		// Every package is imported at least once, by a random
+		// importer, then maybe by others.
+		first := gen.Pick(s, importers...)
+		for _, p := range importers {
+			if p != first && !s.Chance(0.4) {
+				continue
+			}
+			if p != first && s.Chance(0.2) {
+				p.blank = append(p.blank, pkgs[j])
+			} else {
+				p.deps = append(p.deps, pkgs[j])
+			}
+		}
+	}
+	for _, p := range pkgs {
+		for range s.Range(1, 3) {
+			p.newFile(s)
+		}
+	}
+	return pkgs
+}
+
+// modDeclare writes p's exported API, spread over its files.
+func modDeclare(s *gen.State, p *modPkg) {
+	p.c, p.v, p.fn = s.Fresh("Name"), s.Fresh("V"), s.Fresh("F")
+	p.typ, p.iface, p.alias = s.Fresh("T"), s.Fresh("I"), s.Fresh("A")
+	p.box, p.gAlias, p.mapFn = s.Fresh("Box"), s.Fresh("GA"), s.Fresh("Map")
+
+	p.decl(s, "const %s = %q", p.c, p.path())
+
+	// Package initialization order follows dependencies across files.
+	w := s.Fresh("w")
+	p.decl(s, "var %s = %s + 1", p.v, w)
+	p.decl(s, "var %s = len(%s)", w, p.c)
+
+	f := p.file(s)
+	f.open("func %s() int {", p.fn)
+	f.line("n := %s", p.v)
+	for _, d := range p.deps {
+		f.line("n += %s%s()", qual(s, f, d), d.fn)
+	}
+	f.line("return n")
+	f.close("}")
+	f.blank()
+
+	f = p.file(s)
+	f.open("type %s struct {", p.typ)
+	f.line("X int")
+	f.line("unexported int")
+	f.close("}")
+	f.blank()
+	// Methods may live in a different file than their type.
+	p.decl(s, "func (t %s) M() int { return t.X + t.unexported }", p.typ)
+	p.decl(s, "type %s interface{ M() int }", p.iface)
+	p.decl(s, "type %s = %s", p.alias, p.typ)
+
+	f = p.file(s)
+	f.line("type %s[E any] struct{ V E }", p.box)
+	f.line("func (b %s[E]) Get() E { return b.V }", p.box)
+	f.blank()
+	p.decl(s, "type %s[E any] = %s[E]", p.gAlias, p.box)
+	f = p.file(s)
+	f.open("func %s[E, R any](xs []E, f func(E) R) []R {", p.mapFn)
+	f.line("out := make([]R, 0, len(xs))")
+	f.open("for _, x := range xs {")
+	f.line("out = append(out, f(x))")
+	f.close("}")
+	f.line("return out")
+	f.close("}")
+	f.blank()
+
+	for range s.Range(0, 2) {
+		// Any number of init functions, in any file.
+		p.decl(s, "func init() { _ = %s }", p.c)
+	}
+	if p.name == "main" {
+		p.decl(s, "func main() { println(%s()) }", p.fn)
+	}
+}
+
+// modUse writes a use of d's API into one of p's files.
+func modUse(s *gen.State, p, d *modPkg) {
+	f := p.file(s)
+	q := qual(s, f, d)
+	switch s.Intn(6) {
+	case 0:
+		f.line("var _ = %s%s() + len(%s%s)", q, d.fn, q, d.c)
+	case 1:
+		// Promotion through an embedded type from another package.
+		e := s.Fresh("Embeds")
+		f.line("type %s struct{ %s%s }", e, q, d.typ)
+		f.line("var _ = %s{}.M()", e)
+	case 2:
+		f.line("var _ = %s%s([]int{1, 2}, func(elem int) %s%s[int] { return %s%s[int]{V: elem} })", q, d.mapFn, q, d.box, q, d.box)
+	case 3:
+		f.line("var _ %s%s = %s%s{X: 1}", q, d.iface, q, d.alias)
+	case 4:
+		f.line("var _ %s%s[string] = %s%s[string]{V: \"s\"}", q, d.gAlias, q, d.box)
+	default:
+		f.line("var _ = %s%s.M", q, d.typ)
+	}
+	f.blank()
+}
+
+// badModule adds one file breaking a package-level rule to a library
+// package and returns the packages of the module, which it may extend.
+func badModule(s *gen.State, pkgs []*modPkg) []*modPkg {
+	p := gen.Pick(s, pkgs[1:]...)
+	f := p.newFile(s)
+	var d *modPkg
+	if len(p.deps) > 0 {
+		d = gen.Pick(s, p.deps...)
+	}
+	switch s.Intn(6) {
+	case 0:
+		// Import an importer of p, or p itself, closing a cycle.
+		anc := []*modPkg{p}
+		for _, q := range pkgs[1:] {
+			if slices.Contains(q.deps, p) {
+				anc = append(anc, q)
+			}
+		}
+		q := gen.Pick(s, anc...)
+		p.names[f] = "bad_cycle.go"
+		f.line("var _ = %s%s()", qual(s, f, q), q.fn)
+	case 1:
+		q := &modPkg{dir: "elsewhere/internal/hidden", name: "hidden", fn: "Hidden", names: map[*file]string{}}
+		q.newFile(s).line("func Hidden() int { return 0 }")
+		pkgs = append(pkgs, q)
+		p.names[f] = "bad_internal.go"
+		f.line("var _ = %s%s()", qual(s, f, q), q.fn)
+	case 2:
+		p.names[f] = "bad_unexported.go"
+		if d == nil {
+			f.line("var _ = badUndefined.unexported")
+			break
+		}
+		f.useAs("bad", d.path())
+		f.line("var _ = bad.%s{unexported: 1}", d.typ)
+	case 3:
+		p.names[f] = "bad_pkg.go"
+		f.pkg = p.name + "_other"
+		f.line("var bad int")
+	case 4:
+		p.names[f] = "bad_dot.go"
+		if d == nil {
+			f.line("var %s int // bad: redeclared", p.fn)
+			break
+		}
+		f.useAs(".", d.path())
+		f.line("var %s = 1 // bad: already declared through dot-import", d.fn)
+	default:
+		p.names[f] = "bad_main.go"
+		f.useAs("_", pkgs[0].path())
+	}
+	return pkgs
+}
@@ -0,0 +1,191 @@
+package gosrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/nesting",
+		Doc:  "deeply nested expressions, composite literals, type arguments and blocks, bounded by the State's Limits",
+		Func: nesting,
+	})
+}
+
+// nesting writes one snippet of each kind. Every seed type checks, so the
+// only thing being stressed is depth; the defaults stay small enough for
+// fuzz targets to seed from.
+func nesting(s *gen.State) []gen.File {
+	f := newFile(s, "go/nesting")
+	snippets := []snippet{nestExpr, nestLiteral, nestGeneric, nestBlock}
+	gen.Shuffle(s, snippets)
+	for _, sn := range snippets[:s.Range(2, len(snippets))] {
+		sn(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// wrap returns open repeated n times, then inner, then close repeated n
+// times.
+func wrap(n int, open, inner, close string) string {
+	var b strings.Builder
+	b.Grow(n*(len(open)+len(close)) + len(inner))
+	for range n {
+		b.WriteString(open)
+	}
+	b.WriteString(inner)
+	for range n {
+		b.WriteString(close)
+	}
+	return b.String()
+}
+
+// chain returns n copies of elem joined by sep.
+func chain(n int, elem, sep string) string {
+	return strings.Repeat(elem+sep, n-1) + elem
+}
+
+func nestExpr(s *gen.State, f *file) {
+	d := s.Depth(s.Limits.Expr, 12)
+	f.open("func %s(x int, b bool, a []int) int {", s.Fresh("expr"))
+	f.line("id := func(x int) int { return x }")
+	f.line("_, _, _ = id, b, a")
+	switch s.Intn(9) {
+	case 0:
+		f.line("return %s", wrap(d, "(", "x", ")"))
+	case 1:
+		// Right-nested binary expressions need the parentheses.
+		op := gen.Pick(s, "+", "-", "|", "^", "&^")
+		f.line("return %s", wrap(d, "x "+op+" (", "x", ")"))
+	case 2:
+		// A flat chain parses to a left-nested tree just as deep.
+		f.line("return %s", chain(d+1, "x", gen.Pick(s, " + ", " - ", " | ", " << 0 + ")))
+	case 3:
+		// Repeated - or + would lex as -- or ++.
+		if s.Chance(0.5) {
+			f.line("return %s", wrap(d, "^", "x", ""))
+		} else {
+			f.line("return %s", wrap(d, gen.Pick(s, "-(", "+("), "x", ")"))
+		}
+	case 4:
+		f.open("if %s {", wrap(d, "!", "b", ""))
+		f.line("return 1")
+		f.close("}")
+		f.line("return 0")
+	case 5:
+		f.line("return %s", wrap(d, "id(", "x", ")"))
+	case 6:
+		f.line("a = append(a, 0)")
+		f.line("a[0] = 0")
+		f.line("return %s", wrap(d, "a[", "0", "]"))
+	case 7:
+		f.line("return %s", wrap(d, "*&", "x", ""))
+	default:
+		w := gen.Pick(s, [2]string{"int(", ")"}, [2]string{"int(int64(", "))"}, [2]string{"func() int { return ", " }()"})
+		f.line("return %s", wrap(d, w[0], "x", w[1]))
+	}
+	f.close("}")
+}
+
+func nestLiteral(s *gen.State, f *file) {
+	d := s.Depth(s.Limits.Literal, 6)
+	v := s.Fresh("lit")
+	switch s.Intn(5) {
+	case 0:
+		// Inner literal types are elided.
+		f.line("var %s = %s%s", v, strings.Repeat("[]", d)+"int", wrap(d, "{", "1", "}"))
+	case 1:
+		var b strings.Builder
+		for i := range d {
+			b.WriteString(strings.Repeat("[]", d-i) + "int{")
+		}
+		f.line("var %s = %s", v, b.String()+"1"+strings.Repeat("}", d))
+	case 2:
+		f.line("var %s any = %s", v, wrap(d, "map[int]any{0: ", "map[int]any{}", "}"))
+	case 3:
+		n := s.Fresh("node")
+		f.line("type %s struct {", n)
+		f.line("\tnext *%s", n)
+		f.line("\tv    int")
+		f.line("}")
+		f.blank()
+		f.line("var %s = %s", v, wrap(d, "&"+n+"{v: 1, next: ", "nil", "}"))
+	default:
+		f.line("var %s = %s%s", v, strings.Repeat("[1]", d)+"int", wrap(d, "{", "1", "}"))
+	}
+}
+
+func nestGeneric(s *gen.State, f *file) {
+	d := s.Depth(s.Limits.Generic, 6)
+	g, p, v := s.Fresh("G"), s.Fresh("Pair"), s.Fresh("gv")
+	f.line("type %s[T any] struct{ v T }", g)
+	f.line("type %s[K comparable, V any] struct {", p)
+	f.line("\tk K")
+	f.line("\tv V")
+	f.line("}")
+	f.blank()
+	switch s.Intn(4) {
+	case 0:
+		f.line("var %s %s", v, wrap(d, g+"[", "int", "]"))
+		f.line("var _ int = %s%s", v, strings.Repeat(".v", d))
+	case 1:
+		f.line("var %s %s", v, wrap(d, p+"[int, ", "string", "]"))
+		f.line("var _ string = %s%s", v, strings.Repeat(".v", d))
+	case 2:
+		f.line("var %s %s", v, wrap(d, gen.Pick(s, "[]", "*", "map[int]", "func() ", "chan ")+g+"[", "int", "]"))
+	default:
+		fn := s.Fresh("ident")
+		f.line("func %s[T any](x T) T { return x }", fn)
+		f.blank()
+		f.line("var %s = %s[%s](%s{})", v, fn, wrap(d, g+"[", "int", "]"), wrap(d, g+"[", "int", "]"))
+	}
+}
+
+// nestBlock nests statements. Past a modest depth the whole body goes on
+// one line rather than paying for the indentation.
+func nestBlock(s *gen.State, f *file) {
+	d := s.Depth(s.Limits.Block, 6)
+	f.open("func %s() int {", s.Fresh("block"))
+	f.line("n := 0")
+	type pair struct{ open, close string }
+	kinds := []pair{
+		{"{", "}"},
+		{"if n >= 0 {", "}"},
+		{"for range 1 {", "}"},
+		{"switch { case true:", "}"},
+		{"func() {", "}()"},
+		{"select { default:", "}"},
+	}
+	if s.Chance(0.5) {
+		kinds = kinds[:1+s.Intn(len(kinds))]
+	}
+	var closes []string
+	if d <= 16 {
+		for range d {
+			k := gen.Pick(s, kinds...)
+			f.open("%s", k.open)
+			closes = append(closes, k.close)
+		}
+		f.line("n++")
+		for i := len(closes) - 1; i >= 0; i-- {
+			f.close(closes[i])
+		}
+	} else {
+		var b strings.Builder
+		for range d {
+			k := gen.Pick(s, kinds...)
+			b.WriteString(k.open + " ")
+			closes = append(closes, k.close)
+		}
+		b.WriteString("n++")
+		for i := len(closes) - 1; i >= 0; i-- {
+			b.WriteString("; " + closes[i])
+		}
+		f.line("%s", b.String())
+	}
+	f.line("return n")
+	f.close("}")
+}
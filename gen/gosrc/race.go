@@ -0,0 +1,622 @@
+package gosrc
+
+import (
+	"fmt"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/race",
+		Doc:  "runnable programs with benign data races, contended sync primitives and leaked goroutines, for running under -race",
+		Func: raceSeed,
+	})
+}
+
+// A raceFile is a main package together with what running it under the
+// race detector must show. raceSeed records both in a header line that
+// cmd/racerun checks:
+//
+//	// racerun: race=true leaked=2
+type raceFile struct {
+	*file
+	racy   bool // the detector must report at least one race
+	leaked int  // goroutines left blocked when main returns
+}
+
+// A raceSnippet appends a function with no parameters to f and returns
+// its name.
+type raceSnippet func(s *gen.State, f *raceFile) string
+
+// raceSeed writes a main package calling between two and six snippets.
+// About half of the seeds include one or two racy snippets; the rest must
+// run without a report, so a race in them is a detector false positive.
+// main ends by printing how many goroutines are left, so that leaks the
+// seed did not plan for show up too.
+func raceSeed(s *gen.State) []gen.File {
+	f := &raceFile{file: newFile(s, "go/race")}
+	f.pkg = "main"
+	f.use("runtime")
+	f.use("time")
+	pool := []raceSnippet{
+		syncMutex, syncMutex,
+		syncRWMutex,
+		syncOnce,
+		syncCond,
+		syncAtomic, syncAtomic,
+		syncPipeline, syncPipeline,
+		syncMap,
+		syncDisjoint,
+		leakRecv,
+		leakSend,
+		leakLocked,
+		leakForever,
+	}
+	var calls []string
+	for range s.Range(2, 6) {
+		calls = append(calls, gen.Pick(s, pool...)(s, f))
+		f.blank()
+	}
+	if s.Chance(0.5) {
+		for range s.Range(1, 2) {
+			calls = append(calls, gen.Pick(s,
+				raceCounter, raceCounter,
+				raceFlag,
+				raceLazy,
+				raceMixedAtomic,
+				raceSliceElem,
+			)(s, f))
+			f.blank()
+		}
+		gen.Shuffle(s, calls)
+	}
+	f.lead = append(f.lead, fmt.Sprintf("// racerun: race=%t leaked=%d", f.racy, f.leaked))
+
+	f.open("func check(ok bool, what string) {")
+	f.open("if !ok {")
+	f.line("panic(\"race: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	f.line("const wantLeaked = %d", f.leaked)
+	f.blank()
+	f.line("// leaked waits for goroutines that are done to exit and reports how")
+	f.line("// many are left.")
+	f.open("func leaked() {")
+	f.line("n := runtime.NumGoroutine() - 1")
+	f.open("for i := 0; n > wantLeaked && i < 100; i++ {")
+	f.line("time.Sleep(10 * time.Millisecond)")
+	f.line("n = runtime.NumGoroutine() - 1")
+	f.close("}")
+	f.line("println(\"racerun: leaked\", n)")
+	f.close("}")
+	f.blank()
+	f.main(append(calls, "leaked"))
+	return f.files()
+}
+
+// raceWorkers returns a goroutine count and a per-goroutine iteration
+// count for a contended snippet.
+func raceWorkers(s *gen.State) (int, int) {
+	return gen.Pick(s, 2, 3, 8, 32), gen.Pick(s, 1, 10, 100, 1000)
+}
+
+// raceCounter increments a plain int from several goroutines.
+func raceCounter(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("racyCounter")
+	f.racy = true
+	f.use("sync")
+	k, m := raceWorkers(s)
+	f.open("func %s() {", fn)
+	f.line("var n %s", gen.Pick(s, "int", "int64", "uint32"))
+	f.line("var wg sync.WaitGroup")
+	f.open("for range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for range %d {", m)
+	f.line("n++")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.line("_ = n")
+	f.close("}")
+	return fn
+}
+
+// raceFlag publishes a result through an unsynchronized flag. The channel
+// only keeps the goroutine alive until main has read the flag.
+func raceFlag(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("racyFlag")
+	f.racy = true
+	f.open("func %s() {", fn)
+	f.line("var done bool")
+	f.line("var result int")
+	f.line("exited := make(chan struct{})")
+	f.open("go func() {")
+	f.line("result = %d", s.Intn(100))
+	f.line("done = true")
+	f.line("close(exited)")
+	f.close("}()")
+	if s.Chance(0.5) {
+		f.line("time.Sleep(%d * time.Millisecond)", s.Intn(5))
+	} else {
+		f.line("runtime.Gosched()")
+	}
+	f.open("if done {")
+	f.line("_ = result")
+	f.close("}")
+	f.line("<-exited")
+	f.close("}")
+	return fn
+}
+
+// raceLazy initializes a shared pointer on first use without a lock.
+func raceLazy(s *gen.State, f *raceFile) string {
+	fn, t := s.Fresh("racyLazy"), s.Fresh("config")
+	f.racy = true
+	f.use("sync")
+	f.line("type %s struct{ n int }", t)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("var cfg *%s", t)
+	f.line("var wg sync.WaitGroup")
+	f.open("for i := range %d {", s.Range(2, 8))
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.line("c := cfg")
+	f.open("if c == nil {")
+	f.line("c = &%s{n: i}", t)
+	f.line("cfg = c")
+	f.close("}")
+	f.line("_ = c.n")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.close("}")
+	return fn
+}
+
+// raceMixedAtomic updates a word atomically in one goroutine and reads it
+// plainly in another.
+func raceMixedAtomic(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("racyMixed")
+	f.racy = true
+	f.use("sync/atomic")
+	m := gen.Pick(s, 1, 10, 100)
+	f.open("func %s() {", fn)
+	f.line("var x int64")
+	f.line("exited := make(chan struct{})")
+	f.open("go func() {")
+	f.open("for range %d {", m)
+	f.line("atomic.AddInt64(&x, 1)")
+	f.close("}")
+	f.line("close(exited)")
+	f.close("}()")
+	f.line("v := x")
+	f.line("<-exited")
+	f.line("check(v <= %d && atomic.LoadInt64(&x) == %d, \"mixed atomic\")", m, m)
+	f.close("}")
+	return fn
+}
+
+// raceSliceElem has several goroutines store to the same slice element.
+func raceSliceElem(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("racySlice")
+	f.racy = true
+	f.use("sync")
+	k := s.Range(2, 8)
+	f.open("func %s() {", fn)
+	f.line("out := make([]int, %d)", k)
+	f.line("var wg sync.WaitGroup")
+	f.open("for i := range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.line("out[%d] = i", s.Intn(k))
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.close("}")
+	return fn
+}
+
+// syncMutex counts under a mutex and checks the total.
+func syncMutex(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("mutex")
+	f.use("sync")
+	k, m := raceWorkers(s)
+	f.open("func %s() {", fn)
+	f.line("var mu sync.Mutex")
+	f.line("var wg sync.WaitGroup")
+	f.line("n := 0")
+	f.open("for range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for range %d {", m)
+	if s.Chance(0.3) {
+		f.open("for !mu.TryLock() {")
+		f.line("runtime.Gosched()")
+		f.close("}")
+	} else {
+		f.line("mu.Lock()")
+	}
+	f.line("n++")
+	f.line("mu.Unlock()")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.line("check(n == %d, \"mutex count\")", k*m)
+	f.close("}")
+	return fn
+}
+
+// syncRWMutex mixes readers and writers of a map behind a RWMutex.
+func syncRWMutex(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("rwmutex")
+	f.use("sync")
+	readers, writers := s.Range(1, 16), s.Range(1, 4)
+	f.open("func %s() {", fn)
+	f.line("var mu sync.RWMutex")
+	f.line("var wg sync.WaitGroup")
+	f.line("m := map[int]int{}")
+	f.open("for w := range %d {", writers)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for i := range 50 {")
+	f.line("mu.Lock()")
+	f.line("m[w*50+i] = i")
+	f.line("mu.Unlock()")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.open("for range %d {", readers)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for i := range 50 {")
+	f.line("mu.RLock()")
+	f.line("_ = m[i]")
+	f.line("_ = len(m)")
+	f.line("mu.RUnlock()")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.line("check(len(m) == %d, \"rwmutex map size\")", writers*50)
+	f.close("}")
+	return fn
+}
+
+// syncOnce runs an initializer from many goroutines at once.
+func syncOnce(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("once")
+	f.use("sync")
+	k, _ := raceWorkers(s)
+	f.open("func %s() {", fn)
+	f.line("calls := 0")
+	if s.Chance(0.5) {
+		f.line("get := sync.OnceValue(func() []int { calls++; return make([]int, %d) })", k)
+	} else {
+		f.line("var once sync.Once")
+		f.line("var buf []int")
+		f.line("get := func() []int { once.Do(func() { calls++; buf = make([]int, %d) }); return buf }", k)
+	}
+	f.line("var wg sync.WaitGroup")
+	f.line("start := make(chan struct{})")
+	f.open("for i := range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.line("<-start")
+	f.line("get()[i] = i")
+	f.close("}()")
+	f.close("}")
+	f.line("close(start)")
+	f.line("wg.Wait()")
+	f.line("check(calls == 1 && len(get()) == %d, \"once\")", k)
+	f.close("}")
+	return fn
+}
+
+// syncCond hands items from producers to consumers through a
+// condition variable.
+func syncCond(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("cond")
+	f.use("sync")
+	producers, consumers := s.Range(1, 4), s.Range(1, 4)
+	items := producers * 20
+	f.open("func %s() {", fn)
+	f.line("var mu sync.Mutex")
+	f.line("c := sync.NewCond(&mu)")
+	f.line("var queue []int")
+	f.line("taken, closed := 0, false")
+	f.line("var wg, pwg sync.WaitGroup")
+	f.open("for p := range %d {", producers)
+	f.line("pwg.Add(1)")
+	f.open("go func() {")
+	f.line("defer pwg.Done()")
+	f.open("for i := range 20 {")
+	f.line("mu.Lock()")
+	f.line("queue = append(queue, p*20+i)")
+	f.line("mu.Unlock()")
+	f.line("c.%s()", gen.Pick(s, "Signal", "Broadcast"))
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.open("for range %d {", consumers)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.line("mu.Lock()")
+	f.line("defer mu.Unlock()")
+	f.open("for {")
+	f.open("for len(queue) == 0 && !closed {")
+	f.line("c.Wait()")
+	f.close("}")
+	f.open("if len(queue) == 0 {")
+	f.line("return")
+	f.close("}")
+	f.line("queue = queue[1:]")
+	f.line("taken++")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("pwg.Wait()")
+	f.line("mu.Lock()")
+	f.line("closed = true")
+	f.line("mu.Unlock()")
+	f.line("c.Broadcast()")
+	f.line("wg.Wait()")
+	f.line("check(taken == %d, \"cond items\")", items)
+	f.close("}")
+	return fn
+}
+
+// syncAtomic sums with atomic adds or a compare-and-swap loop.
+func syncAtomic(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("atomics")
+	f.use("sync")
+	f.use("sync/atomic")
+	k, m := raceWorkers(s)
+	f.open("func %s() {", fn)
+	f.line("var n atomic.Int64")
+	f.line("var hi atomic.Uint32")
+	f.line("var wg sync.WaitGroup")
+	f.open("for w := range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for range %d {", m)
+	if s.Chance(0.5) {
+		f.line("n.Add(1)")
+	} else {
+		f.open("for {")
+		f.line("old := n.Load()")
+		f.open("if n.CompareAndSwap(old, old+1) {")
+		f.line("break")
+		f.close("}")
+		f.close("}")
+	}
+	f.close("}")
+	f.open("for {")
+	f.line("old := hi.Load()")
+	f.open("if uint32(w) <= old || hi.CompareAndSwap(old, uint32(w)) {")
+	f.line("break")
+	f.close("}")
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.line("check(n.Load() == %d && hi.Load() == %d, \"atomic sum\")", k*m, k-1)
+	f.close("}")
+	return fn
+}
+
+// syncPipeline fans work out to goroutines over channels and back in.
+func syncPipeline(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("pipeline")
+	f.use("sync")
+	k, _ := raceWorkers(s)
+	n := gen.Pick(s, 1, 10, 100, 500)
+	f.open("func %s() {", fn)
+	f.line("in := make(chan int, %d)", gen.Pick(s, 0, 1, 16))
+	f.line("out := make(chan int, %d)", gen.Pick(s, 0, 1, 16))
+	f.open("go func() {")
+	f.line("defer close(in)")
+	f.open("for i := range %d {", n)
+	f.line("in <- i")
+	f.close("}")
+	f.close("}()")
+	f.line("var wg sync.WaitGroup")
+	f.open("for range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for v := range in {")
+	if s.Chance(0.3) {
+		f.open("select {")
+		f.line("case out <- v * 2:")
+		f.line("case <-time.After(time.Minute):")
+		f.line("\tpanic(\"pipeline stalled\")")
+		f.close("}")
+	} else {
+		f.line("out <- v * 2")
+	}
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("go func() { wg.Wait(); close(out) }()")
+	f.line("sum := 0")
+	f.open("for v := range out {")
+	f.line("sum += v")
+	f.close("}")
+	f.line("check(sum == %d, \"pipeline sum\")", n*(n-1))
+	f.close("}")
+	return fn
+}
+
+// syncMap has goroutines race to store the same keys in a sync.Map.
+func syncMap(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("syncMap")
+	f.use("sync")
+	k, _ := raceWorkers(s)
+	keys := s.Range(1, 20)
+	f.open("func %s() {", fn)
+	f.line("var m sync.Map")
+	f.line("var stored atomic.Int32")
+	f.use("sync/atomic")
+	f.line("var wg sync.WaitGroup")
+	f.open("for w := range %d {", k)
+	f.line("wg.Add(1)")
+	f.open("go func() {")
+	f.line("defer wg.Done()")
+	f.open("for i := range %d {", keys)
+	f.open("if _, loaded := m.LoadOrStore(i, w); !loaded {")
+	f.line("stored.Add(1)")
+	f.close("}")
+	if s.Chance(0.5) {
+		f.line("m.CompareAndDelete(i, -1)")
+		f.line("m.Range(func(k, v any) bool { return k.(int) < i })")
+	}
+	f.close("}")
+	f.close("}()")
+	f.close("}")
+	f.line("wg.Wait()")
+	f.line("check(stored.Load() == %d, \"sync.Map stores\")", keys)
+	f.close("}")
+	return fn
+}
+
+// syncDisjoint writes neighbouring but distinct memory from different
+// goroutines. None of it is a race; a report would be a false positive
+// of the detector's shadow memory granularity.
+func syncDisjoint(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("disjoint")
+	f.use("sync")
+	f.open("func %s() {", fn)
+	f.line("var wg sync.WaitGroup")
+	switch s.Intn(3) {
+	case 0:
+		k := gen.Pick(s, 2, 7, 8, 9, 16)
+		f.line("var arr [%d]%s", k, gen.Pick(s, "byte", "int16", "int32"))
+		f.open("for i := range arr {")
+		f.line("wg.Add(1)")
+		f.line("go func() { defer wg.Done(); arr[i]++ }()")
+		f.close("}")
+	case 1:
+		f.line("var st struct {")
+		f.line("\ta, b byte")
+		f.line("\tc    int16")
+		f.line("\td    int32")
+		f.line("\te    [3]byte")
+		f.line("}")
+		f.line("wg.Add(5)")
+		f.line("go func() { defer wg.Done(); st.a++ }()")
+		f.line("go func() { defer wg.Done(); st.b++ }()")
+		f.line("go func() { defer wg.Done(); st.c++ }()")
+		f.line("go func() { defer wg.Done(); st.d++ }()")
+		f.line("go func() { defer wg.Done(); st.e[1]++ }()")
+	default:
+		// Each iteration has its own loop variable.
+		k := s.Range(2, 16)
+		f.line("out := make([]int, %d)", k)
+		f.open("for i := range out {")
+		f.line("wg.Add(1)")
+		f.line("go func() { defer wg.Done(); out[i] = i }()")
+		f.close("}")
+	}
+	f.line("wg.Wait()")
+	f.close("}")
+	return fn
+}
+
+// leakRecv leaves goroutines waiting on a channel nobody sends on.
+func leakRecv(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("leakRecv")
+	k := s.Range(1, 3)
+	f.leaked += k
+	f.open("func %s() {", fn)
+	f.line("ch := make(chan int)")
+	f.open("for range %d {", k)
+	f.line("go func() { <-ch }()")
+	f.close("}")
+	f.close("}")
+	return fn
+}
+
+// leakSend abandons a result channel, the shape of a request that gave up
+// waiting. The sender only starts once the receiver has gone, so the leak
+// does not depend on scheduling.
+func leakSend(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("leakSend")
+	f.leaked++
+	f.open("func %s() {", fn)
+	f.line("result := make(chan int)")
+	f.line("start := make(chan struct{})")
+	f.open("go func() {")
+	f.line("<-start")
+	f.line("result <- %d", s.Intn(10))
+	f.close("}()")
+	f.open("select {")
+	f.line("case <-result:")
+	f.line("\tpanic(\"received before start\")")
+	if s.Chance(0.5) {
+		f.line("case <-time.After(time.Millisecond):")
+	} else {
+		f.line("default:")
+	}
+	f.close("}")
+	f.line("close(start)")
+	f.close("}")
+	return fn
+}
+
+// leakLocked leaves goroutines blocked on a lock that is never released.
+func leakLocked(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("leakLocked")
+	f.use("sync")
+	f.leaked++
+	f.open("func %s() {", fn)
+	switch s.Intn(3) {
+	case 0:
+		f.line("mu := new(sync.Mutex)")
+		f.line("mu.Lock()")
+		f.line("go mu.Lock()")
+	case 1:
+		f.line("mu := new(sync.RWMutex)")
+		f.line("mu.RLock()")
+		f.line("go func() { mu.Lock(); mu.Unlock() }()")
+	default:
+		f.line("var wg sync.WaitGroup")
+		f.line("wg.Add(1)")
+		f.line("go wg.Wait()")
+	}
+	f.close("}")
+	return fn
+}
+
+// leakForever starts goroutines that can never be woken.
+func leakForever(s *gen.State, f *raceFile) string {
+	fn := s.Fresh("leakForever")
+	f.leaked++
+	f.open("func %s() {", fn)
+	switch s.Intn(3) {
+	case 0:
+		f.line("go func() { select {} }()")
+	case 1:
+		f.line("go func() { var ch chan int; ch <- 1 }()")
+	default:
+		f.use("sync")
+		f.line("c := sync.NewCond(new(sync.Mutex))")
+		f.line("go func() { c.L.Lock(); c.Wait() }()")
+	}
+	f.close("}")
+	return fn
+}
@@ -0,0 +1,399 @@
+package gosrc
+
+import (
+	"fmt"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/reflect",
+		Doc:  "self-checking programs building types with reflect.StructOf/FuncOf/MapOf and calling through reflect.Value",
+		Func: reflectSeed,
+	})
+}
+
+// reflectSeed writes a main package meant to be run. Every check holds
+// on a correct runtime, so a seed that panics or exits non-zero is a
+// finding; there are no deliberately bad variants.
+func reflectSeed(s *gen.State) []gen.File {
+	f := newFile(s, "go/reflect")
+	f.pkg = "main"
+	f.use("reflect")
+	f.open("func check(ok bool, what string) {")
+	f.open("if !ok {")
+	f.line("panic(\"reflect: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	f.open("func mustPanic(what string, fn func()) {")
+	f.line("defer func() { check(recover() != nil, what+\" did not panic\") }()")
+	f.line("fn()")
+	f.close("}")
+	f.blank()
+	calls := fillMain(s, f, 3, 8,
+		reflectStructOf, reflectStructOf,
+		reflectFuncOf, reflectFuncOf,
+		reflectMapOf,
+		reflectComposite,
+		reflectMethods, reflectMethods,
+		reflectConvert,
+		reflectSettable,
+		reflectDeepEqual,
+		reflectSelect,
+	)
+	f.main(calls)
+	return f.files()
+}
+
+// A reflectType is a Go type for building reflected types from, with a
+// non-zero value of it.
+type reflectType struct {
+	typ, val   string
+	comparable bool
+}
+
+var reflectTypes = []reflectType{
+	{"int64", "int64(-7)", true},
+	{"string", `"str"`, true},
+	{"float64", "float64(2.5)", true},
+	{"[3]uint8", "[3]uint8{1, 2, 3}", true},
+	{"*int", "new(int)", true},
+	{"any", "any(1)", true},
+	{"struct{}", "struct{}{}", true},
+	{"complex128", "complex128(1i)", true},
+	{"[]byte", "[]byte(\"b\")", false},
+	{"map[string]int", "map[string]int{\"k\": 1}", false},
+	{"func() int", "func() int { return 1 }", false},
+	{"chan int", "make(chan int)", true},
+}
+
+// typeFor returns an expression for the reflect.Type of t.
+func typeFor(t reflectType) string {
+	return fmt.Sprintf("reflect.TypeFor[%s]()", t.typ)
+}
+
+func reflectStructOf(s *gen.State, f *file) string {
+	fn := s.Fresh("structOf")
+	n := s.Range(1, 5)
+	types := make([]reflectType, n)
+	comparable := true
+	for i := range types {
+		types[i] = gen.Pick(s, reflectTypes...)
+		comparable = comparable && types[i].comparable
+	}
+	f.open("func %s() {", fn)
+	f.open("fields := []reflect.StructField{")
+	for i, t := range types {
+		tag := ""
+		if s.Chance(0.4) {
+			tag = fmt.Sprintf(", Tag: `json:\"f%d,omitempty\" x:\"%s\"`", i, gen.Pick(s, "", "a b", "\\\"q\\\""))
+		}
+		f.line("{Name: \"F%d\", Type: %s%s},", i, typeFor(t), tag)
+	}
+	if s.Chance(0.3) {
+		// A trailing zero-size field gets padding so that a pointer to
+		// it does not point past the struct.
+		f.line("{Name: \"Z\", Type: reflect.TypeFor[[0]int64]()},")
+	}
+	f.close("}")
+	f.line("t := reflect.StructOf(fields)")
+	f.line("check(t == reflect.StructOf(fields), \"StructOf is not canonical\")")
+	f.line("check(t.NumField() == len(fields), \"NumField\")")
+	f.line("check(t.Comparable() == %t, \"Comparable\")", comparable)
+	f.line("v := reflect.New(t).Elem()")
+	f.line("check(v.IsZero(), \"new struct is not zero\")")
+	for i, t := range types {
+		f.line("v.Field(%d).Set(reflect.ValueOf(%s))", i, t.val)
+	}
+	if types[0].comparable {
+		f.line("check(v.Field(0).Interface() == v.FieldByName(\"F0\").Interface(), \"FieldByName\")")
+	}
+	f.line("check(!v.IsZero() || t.Field(0).Type.Size() == 0, \"set struct is zero\")")
+	f.line("i := v.Interface()")
+	f.line("check(reflect.TypeOf(i) == t, \"interface round trip\")")
+	if comparable {
+		f.line("check(i == reflect.ValueOf(i).Interface(), \"comparison through any\")")
+		f.line("m := map[any]int{i: 1}")
+		f.line("check(m[v.Interface()] == 1, \"struct as map key\")")
+	}
+	for i := range types {
+		f.line("check(t.Field(%[1]d).Offset%%uintptr(t.Field(%[1]d).Type.Align()) == 0, \"field %[1]d misaligned\")", i)
+	}
+	f.close("}")
+	return fn
+}
+
+func reflectFuncOf(s *gen.State, f *file) string {
+	fn := s.Fresh("funcOf")
+	variadic := s.Chance(0.4)
+	last := "[]string"
+	if variadic {
+		last = "...string"
+	}
+	f.open("func %s() {", fn)
+	f.line("ft := reflect.FuncOf([]reflect.Type{reflect.TypeFor[int](), reflect.TypeFor[[]string]()}, []reflect.Type{reflect.TypeFor[int](), reflect.TypeFor[error]()}, %t)", variadic)
+	f.line("check(ft == reflect.TypeFor[func(int, %s) (int, error)](), \"FuncOf is not canonical\")", last)
+	f.open("impl := func(args []reflect.Value) []reflect.Value {")
+	f.line("n := int(args[0].Int()) + args[1].Len()")
+	f.line("return []reflect.Value{reflect.ValueOf(n), reflect.Zero(reflect.TypeFor[error]())}")
+	f.close("}")
+	f.line("fv := reflect.MakeFunc(ft, impl)")
+	if variadic {
+		f.line("out := fv.Call([]reflect.Value{reflect.ValueOf(2), reflect.ValueOf(\"a\"), reflect.ValueOf(\"b\")})")
+		f.line("check(out[0].Int() == 4 && out[1].IsNil(), \"Call variadic\")")
+		f.line("out = fv.CallSlice([]reflect.Value{reflect.ValueOf(1), reflect.ValueOf([]string{\"x\"})})")
+		f.line("check(out[0].Int() == 2, \"CallSlice\")")
+		f.line("typed := fv.Interface().(func(int, ...string) (int, error))")
+		k := s.Intn(10)
+		f.line("n, err := typed(%d)", k)
+		f.line("check(n == %d && err == nil, \"typed call with no variadic args\")", k)
+	} else {
+		f.line("out := fv.Call([]reflect.Value{reflect.ValueOf(2), reflect.ValueOf([]string{\"a\", \"b\"})})")
+		f.line("check(out[0].Int() == 4 && out[1].IsNil(), \"Call\")")
+		f.line("typed := fv.Interface().(func(int, []string) (int, error))")
+		f.line("n, _ := typed(1, nil)")
+		f.line("check(n == 1, \"typed call\")")
+		f.line("mustPanic(\"Call with too few arguments\", func() { fv.Call(nil) })")
+	}
+	if s.Chance(0.5) {
+		arg := `[]string{"s"}`
+		if variadic {
+			arg = `"s"`
+		}
+		f.line("done := make(chan int)")
+		f.line("go func() { n, _ := typed(3, %s); done <- n }()", arg)
+		f.line("check(<-done == 4, \"call from another goroutine\")")
+	}
+	f.close("}")
+	return fn
+}
+
+func reflectMapOf(s *gen.State, f *file) string {
+	fn := s.Fresh("mapOf")
+	var keys []reflectType
+	for _, t := range reflectTypes {
+		if t.comparable && t.typ != "any" && t.typ != "chan int" && t.typ != "*int" {
+			keys = append(keys, t)
+		}
+	}
+	k, v := gen.Pick(s, keys...), gen.Pick(s, reflectTypes...)
+	f.open("func %s() {", fn)
+	f.line("mt := reflect.MapOf(%s, %s)", typeFor(k), typeFor(v))
+	f.line("check(mt == reflect.TypeFor[map[%s]%s](), \"MapOf is not canonical\")", k.typ, v.typ)
+	f.line("m := reflect.MakeMapWithSize(mt, %d)", s.Intn(100))
+	f.line("m.SetMapIndex(reflect.ValueOf(%s), reflect.ValueOf(%s))", k.val, v.val)
+	f.line("check(m.Len() == 1, \"SetMapIndex\")")
+	f.line("check(m.MapIndex(reflect.ValueOf(%s)).IsValid(), \"MapIndex\")", k.val)
+	f.line("for it := m.MapRange(); it.Next(); {")
+	f.line("\tcheck(it.Key().Type() == mt.Key(), \"MapRange key type\")")
+	f.line("}")
+	f.line("m.SetMapIndex(reflect.ValueOf(%s), reflect.Value{})", k.val)
+	f.line("check(m.Len() == 0, \"delete through SetMapIndex\")")
+	if s.Chance(0.5) {
+		// NaN keys never match, so each insertion adds an entry.
+		f.line("nan := reflect.MakeMap(reflect.MapOf(reflect.TypeFor[float64](), reflect.TypeFor[int]()))")
+		f.line("zero := 0.0")
+		f.line("for range 3 {")
+		f.line("\tnan.SetMapIndex(reflect.ValueOf(zero/zero), reflect.ValueOf(1))")
+		f.line("}")
+		f.line("check(nan.Len() == 3, \"NaN keys\")")
+		f.line("nan.Clear()")
+		f.line("check(nan.Len() == 0, \"Clear\")")
+	}
+	f.line("mustPanic(\"MapOf with a slice key\", func() { reflect.MapOf(reflect.TypeFor[[]int](), reflect.TypeFor[int]()) })")
+	f.close("}")
+	return fn
+}
+
+// reflectComposite builds array, slice, pointer and channel types.
+func reflectComposite(s *gen.State, f *file) string {
+	fn := s.Fresh("composite")
+	t := gen.Pick(s, reflectTypes...)
+	n := gen.Pick(s, 0, 1, 7, 1<<10)
+	f.open("func %s() {", fn)
+	f.line("et := %s", typeFor(t))
+	f.line("val := reflect.ValueOf(%s)", t.val)
+	f.line("check(reflect.ArrayOf(%d, et) == reflect.TypeFor[[%d]%s](), \"ArrayOf\")", n, n, t.typ)
+	f.line("check(reflect.SliceOf(et) == reflect.TypeFor[[]%s](), \"SliceOf\")", t.typ)
+	f.line("check(reflect.PointerTo(et) == reflect.TypeFor[*%s](), \"PointerTo\")", t.typ)
+	f.line("check(reflect.ChanOf(reflect.RecvDir, et) == reflect.TypeFor[<-chan %s](), \"ChanOf\")", t.typ)
+	f.line("sl := reflect.MakeSlice(reflect.SliceOf(et), 0, %d)", s.Intn(4))
+	f.line("sl = reflect.Append(sl, val, reflect.Zero(et))")
+	f.line("sl = reflect.AppendSlice(sl, sl)")
+	f.line("check(sl.Len() == 4 && sl.Index(3).IsZero(), \"Append\")")
+	f.line("grown := reflect.New(sl.Type()).Elem()")
+	f.line("grown.Set(sl)")
+	f.line("grown.Grow(%d)", s.Intn(64))
+	f.line("check(grown.Cap() >= sl.Len(), \"Grow\")")
+	f.line("mustPanic(\"Grow of an unaddressable slice\", func() { sl.Grow(1) })")
+	f.line("ch := reflect.MakeChan(reflect.ChanOf(reflect.BothDir, et), 1)")
+	f.line("check(ch.TrySend(sl.Index(0)), \"TrySend\")")
+	f.line("got, ok := ch.TryRecv()")
+	f.line("check(ok && got.Type() == et, \"TryRecv\")")
+	if t.comparable {
+		f.line("check(got.Equal(val), \"Equal\")")
+	}
+	f.line("arr := reflect.New(reflect.ArrayOf(2, et)).Elem()")
+	f.line("reflect.Copy(arr, sl)")
+	f.line("check(arr.Index(1).IsZero(), \"Copy\")")
+	f.close("}")
+	return fn
+}
+
+func reflectMethods(s *gen.State, f *file) string {
+	fn, t := s.Fresh("methods"), s.Fresh("T")
+	f.line("type %s struct{ n int }", t)
+	f.blank()
+	f.line("func (t %s) Get() int { return t.n }", t)
+	f.line("func (t *%s) Set(n int) { t.n = n }", t)
+	f.open("func (t %s) Sum(xs ...int) int {", t)
+	f.line("for _, x := range xs {")
+	f.line("\tt.n += x")
+	f.line("}")
+	f.line("return t.n")
+	f.close("}")
+	f.line("func (%s) hidden() {}", t)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("p := reflect.ValueOf(&%s{n: 1})", t)
+	f.line("check(reflect.TypeFor[%s]().NumMethod() == 2 && p.Type().NumMethod() == 3, \"NumMethod\")", t)
+	f.line("check(p.Type().Method(0).Name == \"Get\" && p.Type().Method(2).Name == \"Sum\", \"method order\")")
+	f.line("p.MethodByName(\"Set\").Call([]reflect.Value{reflect.ValueOf(%d)})", s.Intn(100))
+	f.line("get := p.Elem().MethodByName(\"Get\")")
+	f.line("check(get.Call(nil)[0].Int() == int64(p.Elem().Field(0).Int()), \"Get after Set\")")
+	f.line("check(get.Interface().(func() int)() == p.Interface().(*%s).n, \"method value\")", t)
+	f.line("sum := p.MethodByName(\"Sum\").Call([]reflect.Value{reflect.ValueOf(1), reflect.ValueOf(2)})")
+	f.line("check(sum[0].Int() == p.Elem().Field(0).Int()+3, \"variadic method\")")
+	f.line("check(!p.MethodByName(\"hidden\").IsValid() && !p.MethodByName(\"Missing\").IsValid(), \"unexported method\")")
+	switch s.Intn(3) {
+	case 0:
+		// Method expressions take the receiver as first argument.
+		f.line("m, _ := p.Type().MethodByName(\"Get\")")
+		f.line("check(m.Func.Call([]reflect.Value{p})[0].Int() == get.Call(nil)[0].Int(), \"method expression\")")
+	case 1:
+		f.line("it := reflect.TypeFor[interface{ Get() int }]()")
+		f.line("check(p.Type().Implements(it) && p.Elem().Type().Implements(it), \"Implements\")")
+		f.line("check(!reflect.TypeFor[%s]().Implements(reflect.TypeFor[interface{ Set(int) }]()), \"value method set\")", t)
+	default:
+		f.line("mustPanic(\"Call on a zero Value\", func() { reflect.Value{}.Call(nil) })")
+		f.line("mustPanic(\"Call with a wrong type\", func() { p.MethodByName(\"Set\").Call([]reflect.Value{reflect.ValueOf(\"x\")}) })")
+	}
+	f.close("}")
+	return fn
+}
+
+// reflectConvert round-trips values through interfaces and conversions.
+func reflectConvert(s *gen.State, f *file) string {
+	fn, named := s.Fresh("convert"), s.Fresh("Named")
+	f.line("type %s string", named)
+	f.blank()
+	f.line("func (n %s) Name() string { return string(n) }", named)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("it := reflect.TypeFor[interface{ Name() string }]()")
+	f.line("nv := reflect.ValueOf(%s(%q)).Convert(it)", named, gen.Pick(s, "a", "", "日本"))
+	f.line("check(nv.Type() == it && nv.Interface().(interface{ Name() string }).Name() == nv.Elem().String(), \"Convert to interface\")")
+	f.line("var x any = %s", gen.Pick(s, reflectTypes...).val)
+	f.line("iv := reflect.ValueOf(&x).Elem()")
+	f.line("check(iv.Kind() == reflect.Interface && iv.Elem().Type() == reflect.TypeOf(x), \"interface Elem\")")
+	f.line("iv.Set(reflect.ValueOf(%s(\"b\")))", named)
+	f.line("check(x.(%s) == \"b\", \"Set through interface\")", named)
+	f.line("iv.SetZero()")
+	f.line("check(x == nil, \"SetZero\")")
+	switch s.Intn(4) {
+	case 0:
+		f.line("b := reflect.ValueOf(\"bytes\").Convert(reflect.TypeFor[[]byte]())")
+		f.line("check(b.Len() == 5 && b.Convert(reflect.TypeFor[%s]()).String() == \"bytes\", \"string <-> []byte\")", named)
+	case 1:
+		f.line("sl := reflect.ValueOf([]int{1, 2, 3})")
+		f.line("check(sl.CanConvert(reflect.TypeFor[[3]int]()) && !sl.CanConvert(reflect.TypeFor[[4]int]()), \"slice to array\")")
+		f.line("check(sl.Convert(reflect.TypeFor[*[2]int]()).Elem().Index(1).Int() == 2, \"slice to array pointer\")")
+	case 2:
+		f.line("fl := reflect.ValueOf(%s).Convert(reflect.TypeFor[float32]())", gen.Pick(s, "1<<62", "-1", "int8(-128)", "uint64(1<<63)"))
+		f.line("check(fl.Convert(reflect.TypeFor[int64]()).Kind() == reflect.Int64, \"numeric conversion\")")
+	default:
+		f.line("check(!reflect.ValueOf(1).CanConvert(reflect.TypeFor[[]byte]()), \"CanConvert\")")
+		f.line("mustPanic(\"invalid Convert\", func() { reflect.ValueOf(1).Convert(reflect.TypeFor[[]byte]()) })")
+	}
+	f.close("}")
+	return fn
+}
+
+// reflectSettable checks which values may be set.
+func reflectSettable(s *gen.State, f *file) string {
+	fn, t := s.Fresh("settable"), s.Fresh("S")
+	f.line("type %s struct {", t)
+	f.line("\tExported   int")
+	f.line("\tunexported int")
+	f.line("\t*%s", t)
+	f.line("}")
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("v := reflect.ValueOf(%s{})", t)
+	f.line("check(!v.Field(0).CanSet() && !v.CanAddr(), \"field of unaddressable struct\")")
+	f.line("p := reflect.ValueOf(&%s{}).Elem()", t)
+	f.line("check(p.Field(0).CanSet() && !p.Field(1).CanSet() && p.Field(1).CanAddr(), \"CanSet\")")
+	f.line("p.Field(0).SetInt(%d)", s.Intn(1000))
+	f.line("mustPanic(\"set of unexported field\", func() { p.Field(1).SetInt(1) })")
+	f.line("mustPanic(\"Interface of unexported field\", func() { p.Field(1).Interface() })")
+	f.line("mustPanic(\"promoted field through nil pointer\", func() { p.FieldByIndex([]int{2, 0}) })")
+	f.line("_, err := p.FieldByIndexErr([]int{2, 0})")
+	f.line("check(err != nil, \"FieldByIndexErr\")")
+	f.line("p.Field(2).Set(reflect.New(p.Type()))")
+	f.line("check(p.FieldByIndex([]int{2, 0}).CanSet(), \"promoted field\")")
+	f.close("}")
+	return fn
+}
+
+func reflectDeepEqual(s *gen.State, f *file) string {
+	fn, l := s.Fresh("deepEqual"), s.Fresh("L")
+	f.line("type %s struct {", l)
+	f.line("\tnext *%s", l)
+	f.line("\tm    map[int]any")
+	f.line("}")
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("a, b := &%s{}, &%s{}", l, l)
+	f.line("a.next, b.next = %s", gen.Pick(s, "a, b", "b, a", "a, a"))
+	f.line("check(reflect.DeepEqual(a, b), \"cyclic lists\")")
+	f.line("a.m, b.m = map[int]any{1: a}, map[int]any{1: b}")
+	f.line("check(reflect.DeepEqual(a, b), \"cycle through map\")")
+	f.line("zero := 0.0")
+	f.line("check(!reflect.DeepEqual(map[float64]int{zero / zero: 1}, map[float64]int{zero / zero: 1}), \"NaN keys\")")
+	f.line("nans := []any{zero / zero}")
+	f.line("check(reflect.DeepEqual(nans, nans) && !reflect.DeepEqual(nans, []any{zero / zero}), \"NaN elements\")")
+	f.line("check(!reflect.DeepEqual([]int{}, []int(nil)) && reflect.DeepEqual(map[int]int(nil), map[int]int(nil)), \"nil vs empty\")")
+	f.line("var fnil func()")
+	f.line("check(reflect.DeepEqual(fnil, fnil) && !reflect.DeepEqual(%s, %s), \"funcs\")", "func() {}", "func() {}")
+	f.close("}")
+	return fn
+}
+
+func reflectSelect(s *gen.State, f *file) string {
+	fn := s.Fresh("selectCases")
+	n := s.Range(1, 5)
+	f.open("func %s() {", fn)
+	f.line("var cases []reflect.SelectCase")
+	f.open("for i := range %d {", n)
+	f.line("ch := reflect.MakeChan(reflect.TypeFor[chan int](), i)")
+	f.line("c := reflect.SelectCase{Dir: reflect.SelectRecv, Chan: ch}")
+	f.open("if i%%2 == 1 {")
+	f.line("c.Dir, c.Send = reflect.SelectSend, reflect.ValueOf(i)")
+	f.close("}")
+	f.line("cases = append(cases, c)")
+	f.close("}")
+	if s.Chance(0.5) {
+		f.line("cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv}) // nil channel: never ready")
+	}
+	f.line("cases = append(cases, reflect.SelectCase{Dir: reflect.SelectDefault})")
+	f.line("chosen, _, ok := reflect.Select(cases)")
+	// Only the buffered send cases and default can proceed.
+	f.line("check(cases[chosen].Dir != reflect.SelectRecv && !ok, \"Select\")")
+	f.line("mustPanic(\"two default cases\", func() {")
+	f.line("\treflect.Select([]reflect.SelectCase{{Dir: reflect.SelectDefault}, {Dir: reflect.SelectDefault}})")
+	f.line("})")
+	f.close("}")
+	return fn
+}
@@ -0,0 +1,423 @@
+package gosrc
+
+import (
+	"fmt"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/select",
+		Doc:  "self-checking programs exercising select with zero, one and duplicate cases, nil and closed channels, send and receive on one channel, and directional conversions",
+		Func: selects,
+	})
+}
+
+// selects writes a main package for running. Every check holds on a
+// correct runtime, so a seed without a badChan that panics, deadlocks or
+// exits non-zero is a finding.
+func selects(s *gen.State) []gen.File {
+	f := newFile(s, "go/select")
+	f.pkg = "main"
+	f.open("func check(ok bool, what string) {")
+	f.open("if !ok {")
+	f.line("panic(\"select: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	f.open("func mustPanic(what string, fn func()) {")
+	f.line("defer func() { check(recover() != nil, what+\" did not panic\") }()")
+	f.line("fn()")
+	f.close("}")
+	f.blank()
+	calls := fillMain(s, f, 3, 7,
+		selEmpty,
+		selSingle, selSingle,
+		selDuplicate, selDuplicate,
+		selNil, selNil,
+		selSameChan, selSameChan,
+		selDirections,
+		selMixed, selMixed,
+		selClosed,
+	)
+	if s.Chance(0.15) {
+		badChan(s, f)
+		f.blank()
+	}
+	f.main(calls)
+	return f.files()
+}
+
+// chanElem is a comparable channel element type with a value of it for
+// each index.
+type chanElem struct {
+	typ string
+	val func(i int) string
+}
+
+var chanElems = []chanElem{
+	{"int", func(i int) string { return fmt.Sprint(i) }},
+	{"string", func(i int) string { return fmt.Sprintf("%q", fmt.Sprint(i)) }},
+	{"struct{ a, b int }", func(i int) string { return fmt.Sprintf("struct{ a, b int }{%d, %d}", i, -i) }},
+	{"[2]byte", func(i int) string { return fmt.Sprintf("[2]byte{%d, %d}", i%256, i/256%256) }},
+	{"any", func(i int) string { return fmt.Sprintf("any(%d)", i) }},
+	{"*int", func(int) string { return "nil" }},
+	{"struct{}", func(int) string { return "struct{}{}" }},
+}
+
+// selEmpty parks goroutines on a select with no cases, and on one whose
+// only cases are nil channels, and leaves them there.
+func selEmpty(s *gen.State, f *file) string {
+	fn := s.Fresh("empty")
+	f.open("func %s() {", fn)
+	for range s.Range(1, 4) {
+		if s.Chance(0.5) {
+			f.line("go func() { select {} }()")
+		} else {
+			f.line("go func() {")
+			f.line("\tvar a chan int")
+			f.line("\tvar b chan<- string")
+			f.line("\tselect {")
+			f.line("\tcase <-a:")
+			f.line("\tcase b <- \"x\":")
+			f.line("\t}")
+			f.line("\tpanic(\"nil channel case chosen\")")
+			f.line("}()")
+		}
+	}
+	f.close("}")
+	return fn
+}
+
+// selSingle writes selects with one communication case, with and without
+// default, which must behave like the bare operation.
+func selSingle(s *gen.State, f *file) string {
+	fn := s.Fresh("single")
+	e := gen.Pick(s, chanElems...)
+	n := s.Range(1, 5)
+	f.open("func %s() {", fn)
+	f.line("c := make(chan %s, %d)", e.typ, n)
+	f.open("for i := 0; i < %d; i++ {", n)
+	f.line("select {")
+	f.line("case c <- %s:", e.val(s.Intn(9)))
+	f.line("}")
+	f.close("}")
+	f.line("select {")
+	f.line("case c <- %s:", e.val(0))
+	f.line("\tpanic(\"send to full channel\")")
+	f.line("default:")
+	f.line("}")
+	f.line("got := 0")
+	f.open("for range %d {", n)
+	f.line("select {")
+	f.line("case _, ok := <-c:")
+	f.line("\tcheck(ok, \"receive from buffered channel\")")
+	f.line("\tgot++")
+	f.line("}")
+	f.close("}")
+	f.line("select {")
+	f.line("case <-c:")
+	f.line("\tpanic(\"receive from empty channel\")")
+	f.line("default:")
+	f.line("}")
+	f.line("check(got == %d && len(c) == 0, %q)", n, fn)
+	f.close("}")
+	return fn
+}
+
+// selDuplicate selects on the same channel in several cases. Ready cases
+// are chosen uniformly, so over enough rounds each must be taken.
+func selDuplicate(s *gen.State, f *file) string {
+	fn := s.Fresh("duplicate")
+	k := s.Range(2, 5)
+	rounds := 400 * k
+	send := s.Chance(0.5)
+	f.open("func %s() {", fn)
+	f.line("c := make(chan int, %d)", rounds)
+	if !send {
+		f.open("for i := range %d {", rounds)
+		f.line("c <- i")
+		f.close("}")
+	}
+	f.line("var hits [%d]int", k)
+	f.open("for range %d {", rounds)
+	f.line("select {")
+	for i := range k {
+		if send {
+			f.line("case c <- %d:", i)
+		} else {
+			f.line("case <-c:")
+		}
+		f.line("\thits[%d]++", i)
+	}
+	f.line("}")
+	f.close("}")
+	f.line("total := 0")
+	f.open("for _, h := range hits {")
+	f.line("check(h > 0, %q)", fn+": case never chosen")
+	f.line("total += h")
+	f.close("}")
+	if send {
+		f.line("check(total == %d && len(c) == %d, %q)", rounds, rounds, fn)
+	} else {
+		f.line("check(total == %d && len(c) == 0, %q)", rounds, fn)
+	}
+	f.close("}")
+	return fn
+}
+
+// selNil merges channels in a loop, disabling each case by setting its
+// channel to nil once it is closed.
+func selNil(s *gen.State, f *file) string {
+	fn := s.Fresh("merge")
+	n := s.Range(2, 5)
+	counts := make([]int, n)
+	want := 0
+	for i := range counts {
+		counts[i] = s.Range(0, 20)
+		for j := range counts[i] {
+			want += (i + 1) * (j + 1)
+		}
+	}
+	f.open("func %s() {", fn)
+	f.line("var cs [%d]chan int", n)
+	f.open("for i := range cs {")
+	f.line("cs[i] = make(chan int%s)", gen.Pick(s, "", ", 1", ", 8"))
+	f.close("}")
+	for i, c := range counts {
+		f.open("go func() {")
+		f.open("for j := 1; j <= %d; j++ {", c)
+		f.line("cs[%d] <- %d * j", i, i+1)
+		f.close("}")
+		f.line("close(cs[%d])", i)
+		f.close("}()")
+	}
+	f.line("sum, open := 0, %d", n)
+	f.open("for open > 0 {")
+	f.line("select {")
+	for i := range n {
+		f.line("case v, ok := <-cs[%d]:", i)
+		f.line("\tif !ok {")
+		f.line("\t\tcs[%d] = nil", i)
+		f.line("\t\topen--")
+		f.line("\t\tcontinue")
+		f.line("\t}")
+		f.line("\tsum += v")
+	}
+	f.line("}")
+	f.close("}")
+	f.line("check(sum == %d, %q)", want, fn)
+	if s.Chance(0.5) {
+		// With every channel nil only default is ready.
+		f.line("select {")
+		for i := range n {
+			f.line("case <-cs[%d]:", i)
+			f.line("\tpanic(\"nil channel ready\")")
+		}
+		f.line("default:")
+		f.line("}")
+	}
+	f.close("}")
+	return fn
+}
+
+// selSameChan sends and receives on one buffered channel in one select.
+// Only the send is ready when it is empty and only the receive when it
+// is full, so the buffer's length must stay within bounds and match the
+// difference of sends and receives.
+func selSameChan(s *gen.State, f *file) string {
+	fn := s.Fresh("sameChan")
+	c := s.Range(0, 4)
+	rounds := s.Range(10, 200)
+	f.open("func %s() {", fn)
+	f.line("c := make(chan int, %d)", c)
+	f.line("sent, received, idle := 0, 0, 0")
+	f.open("for i := range %d {", rounds)
+	f.line("select {")
+	f.line("case c <- i:")
+	f.line("\tsent++")
+	f.line("case v := <-c:")
+	f.line("\treceived++")
+	f.line("\tcheck(v < i, \"received a value not yet sent\")")
+	if c == 0 {
+		// Unbuffered with no other goroutine: neither side is ever
+		// ready.
+		f.line("default:")
+		f.line("\tidle++")
+	}
+	f.line("}")
+	f.line("check(sent-received == len(c) && len(c) <= %d, %q)", c, fn)
+	f.close("}")
+	if c == 0 {
+		f.line("check(idle == %d, %q)", rounds, fn)
+	} else {
+		f.line("check(idle == 0 && sent >= %d, %q)", (rounds+1)/2, fn)
+	}
+	f.close("}")
+	return fn
+}
+
+// selDirections converts a channel to its directional types, named and
+// unnamed, and selects over the converted values.
+func selDirections(s *gen.State, f *file) string {
+	fn := s.Fresh("directions")
+	in, out := s.Fresh("In"), s.Fresh("Out")
+	e := gen.Pick(s, chanElems...)
+	n := s.Range(1, 6)
+	f.line("type %s <-chan %s", in, e.typ)
+	f.line("type %s chan<- %s", out, e.typ)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("c := make(chan %s, %d)", e.typ, n)
+	sendAs := gen.Pick(s, "chan<- "+e.typ+"(c)", out+"(c)", "(chan<- "+e.typ+")(c)")
+	// Unlike chan<- T(c), <-chan T(c) would parse as a receive.
+	recvAs := gen.Pick(s, "(<-chan "+e.typ+")(c)", in+"(c)", "c")
+	f.line("var w %s = %s", out, sendAs)
+	f.line("var r %s = %s", in, recvAs)
+	f.line("var rr <-chan %s = r", e.typ)
+	f.open("for range %d {", n)
+	f.line("select {")
+	f.line("case w <- %s:", e.val(1))
+	f.line("}")
+	f.close("}")
+	f.line("got := 0")
+	f.open("for range %d {", n)
+	f.line("select {")
+	f.line("case <-rr:")
+	f.line("\tgot++")
+	f.line("}")
+	f.close("}")
+	f.line("check(got == %d && cap(w) == %d && len(r) == 0, %q)", n, n, fn)
+	f.line("close(w)")
+	f.line("_, ok := <-r")
+	f.line("check(!ok, \"receive from closed directional channel\")")
+	f.close("}")
+	return fn
+}
+
+// selMixed runs producers over unbuffered and buffered channels into one
+// consumer selecting over all of them, with a done channel closed once
+// every producer has finished.
+func selMixed(s *gen.State, f *file) string {
+	fn := s.Fresh("mixed")
+	f.use("sync")
+	n := s.Range(2, 6)
+	per := s.Range(1, 50)
+	want := 0
+	for i := range n {
+		want += (i + 1) * per
+	}
+	f.open("func %s() {", fn)
+	f.line("var cs [%d]chan int", n)
+	for i := range n {
+		f.line("cs[%d] = make(chan int, %d)", i, gen.Pick(s, 0, 0, 1, per))
+	}
+	f.line("done := make(chan struct{})")
+	f.line("var wg sync.WaitGroup")
+	f.open("for i := range cs {")
+	f.line("wg.Add(1)")
+	f.open("go func(i int) {")
+	f.line("defer wg.Done()")
+	f.open("for range %d {", per)
+	f.line("cs[i] <- i + 1")
+	f.close("}")
+	f.close("}(i)")
+	f.close("}")
+	f.line("go func() { wg.Wait(); close(done) }()")
+	f.line("sum := 0")
+	f.label("Loop")
+	f.open("for {")
+	f.line("select {")
+	for i := range n {
+		f.line("case v := <-cs[%d]:", i)
+		f.line("\tsum += v")
+	}
+	f.line("case <-done:")
+	f.line("\tbreak Loop")
+	f.line("}")
+	f.close("}")
+	// Producers may finish while buffered values are still queued.
+	f.open("for i := range cs {")
+	f.open("for len(cs[i]) > 0 {")
+	f.line("sum += <-cs[i]")
+	f.close("}")
+	f.close("}")
+	f.line("check(sum == %d, %q)", want, fn)
+	f.close("}")
+	return fn
+}
+
+// selClosed checks the closed-channel rules: receives are always ready
+// and yield the zero value, and sending to or closing a closed channel,
+// like closing a nil one, panics, in a select as anywhere.
+func selClosed(s *gen.State, f *file) string {
+	fn := s.Fresh("closed")
+	e := gen.Pick(s, chanElems...)
+	n := s.Range(0, 4)
+	f.open("func %s() {", fn)
+	f.line("c := make(chan %s, %d)", e.typ, n)
+	for i := range n {
+		f.line("c <- %s", e.val(i+1))
+	}
+	f.line("close(c)")
+	f.line("got := 0")
+	f.open("for range %d {", n+s.Range(1, 4))
+	f.line("select {")
+	f.line("case v, ok := <-c:")
+	f.line("\tvar zero %s", e.typ)
+	f.line("\tif ok {")
+	f.line("\t\tgot++")
+	f.line("\t} else {")
+	f.line("\t\tcheck(v == zero, \"zero value from closed channel\")")
+	f.line("\t}")
+	f.line("default:")
+	f.line("\tpanic(\"closed channel not ready\")")
+	f.line("}")
+	f.close("}")
+	f.line("check(got == %d, %q)", n, fn)
+	f.line("mustPanic(\"send on closed channel\", func() {")
+	f.line("\tselect {")
+	f.line("\tcase c <- %s:", e.val(0))
+	f.line("\tdefault:")
+	f.line("\t}")
+	f.line("})")
+	f.line("mustPanic(\"close of closed channel\", func() { close(c) })")
+	f.line("mustPanic(\"close of nil channel\", func() { var c chan %s; close(c) })", e.typ)
+	f.close("}")
+	return fn
+}
+
+// badChan writes one channel operation the spec forbids.
+func badChan(s *gen.State, f *file) {
+	b := s.Fresh("bad")
+	f.open("func %s(c chan int, r <-chan int, w chan<- int) {", b)
+	switch s.Intn(8) {
+	case 0:
+		f.line("r <- 1")
+	case 1:
+		f.line("<-w")
+	case 2:
+		f.line("close(r)")
+	case 3:
+		f.line("_ = chan int(r)")
+	case 4:
+		f.line("_ = (<-chan int)(w)")
+	case 5:
+		f.line("select {")
+		f.line("default:")
+		f.line("default:")
+		f.line("}")
+	case 6:
+		f.line("select {")
+		f.line("case v := <-w:")
+		f.line("\t_ = v")
+		f.line("}")
+	default:
+		f.line("select {")
+		f.line("case r <- 1:")
+		f.line("}")
+	}
+	f.line("_, _, _ = c, r, w")
+	f.close("}")
+}
@@ -0,0 +1,681 @@
+package gosrc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/shadow",
+		Doc:  "self-checking programs that shadow variables, err, builtin functions and types, type parameters and package names in nested scopes, init clauses and closures",
+		Func: shadow,
+	})
+}
+
+// shadow writes a main package whose functions redeclare the same few
+// names in nested blocks, if, for and switch init clauses and closures,
+// note the values they read, and compare the notes with those the
+// generator got by running the same code through a small interpreter
+// that resolves each name to its declaration. A seed without a
+// badShadow that fails to compile, panics or exits non-zero is a
+// finding.
+func shadow(s *gen.State) []gen.File {
+	f := newFile(s, "go/shadow")
+	f.pkg = "main"
+	// Loop variables are per iteration from Go 1.22 on, which the
+	// expected notes assume.
+	f.lead = append(f.lead, "//go:build go1.22")
+	f.use("fmt")
+	f.use("slices")
+	f.line("var notes []int")
+	f.blank()
+	f.line("func note(v int) { notes = append(notes, v) }")
+	f.blank()
+	f.open("func expect(what string, want ...int) {")
+	f.open("if !slices.Equal(notes, want) {")
+	f.line("panic(fmt.Sprintf(\"shadow: %%s: noted %%v, want %%v\", what, notes, want))")
+	f.close("}")
+	f.line("notes = nil")
+	f.close("}")
+	f.blank()
+	f.open("func check(ok bool, what string) {")
+	f.open("if !ok {")
+	f.line("panic(\"shadow: \" + what)")
+	f.close("}")
+	f.close("}")
+	f.blank()
+	f.open("func step(k int) (int, error) {")
+	f.open("if k%%3 == 0 {")
+	f.line("return 0, fmt.Errorf(\"step %%d\", k)")
+	f.close("}")
+	f.line("return k * 2, nil")
+	f.close("}")
+	f.blank()
+	f.open("func errText(err error) string {")
+	f.open("if err == nil {")
+	f.line("return \"\"")
+	f.close("}")
+	f.line("return err.Error()")
+	f.close("}")
+	f.blank()
+	calls := fillMain(s, f, 3, 7,
+		shdNest, shdNest, shdNest,
+		shdErr, shdErr,
+		shdBuiltins,
+		shdTypeParams,
+	)
+	if s.Chance(0.3) {
+		// Package-level declarations shadow the universe in every
+		// function, so there is at most one set of them.
+		calls = append(calls, shdPackage(s, f))
+		f.blank()
+	}
+	if s.Chance(0.15) {
+		badShadow(s, f)
+		f.blank()
+	}
+	f.main(calls)
+	return f.files()
+}
+
+// shdNames are the names shdNest declares over and over. Some are
+// predeclared identifiers the rest of the seed does not need in those
+// functions.
+var shdNames = []string{"x", "x", "y", "v", "err", "ok", "len", "new", "true", "nil", "min", "max", "print"}
+
+// A shdSlot is one declaration of a name.
+type shdSlot struct {
+	name     string
+	fn       bool // a func() int rather than an int
+	readonly bool // a loop variable, which its body must not assign
+	used     bool
+}
+
+// A shdScope is a block and the declarations made in it so far.
+type shdScope struct {
+	parent *shdScope
+	slots  []*shdSlot
+}
+
+func (sc *shdScope) local(name string) *shdSlot {
+	for _, d := range sc.slots {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// visible returns the declarations names resolve to in sc that pass
+// keep.
+func (sc *shdScope) visible(keep func(*shdSlot) bool) []*shdSlot {
+	var out []*shdSlot
+	seen := map[string]bool{}
+	for c := sc; c != nil; c = c.parent {
+		for i := len(c.slots) - 1; i >= 0; i-- {
+			d := c.slots[i]
+			if !seen[d.name] {
+				seen[d.name] = true
+				if keep(d) {
+					out = append(out, d)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func (sc *shdScope) declare(d *shdSlot) *shdSlot {
+	sc.slots = append(sc.slots, d)
+	return d
+}
+
+// At run time each execution of a declaration makes a new cell, and a
+// closure keeps the cells visible where it was made.
+type shdCell struct {
+	v  int64
+	fn *shdClosure
+}
+
+type shdEnv map[*shdSlot]*shdCell
+
+func (env shdEnv) clone() shdEnv {
+	c := make(shdEnv, len(env))
+	for k, v := range env {
+		c[k] = v
+	}
+	return c
+}
+
+type shdClosure struct {
+	env  shdEnv
+	body func(shdEnv)
+	ret  func(shdEnv) int64
+}
+
+func (c *shdClosure) call() int64 {
+	env := c.env.clone()
+	c.body(env)
+	return c.ret(env)
+}
+
+// shdGen writes the body of a shdNest function and builds, alongside,
+// the code that runs it.
+type shdGen struct {
+	s       *gen.State
+	f       *file
+	budget  int
+	loops   int
+	closure bool // in a closure body, which calls nothing, so that calls do not multiply
+	notes   []int64
+}
+
+func (g *shdGen) int(sc *shdScope) []*shdSlot {
+	return sc.visible(func(d *shdSlot) bool { return !d.fn })
+}
+
+// expr returns an int expression over literals and visible variables.
+func (g *shdGen) expr(sc *shdScope, d int) (string, func(shdEnv) int64) {
+	vars := g.int(sc)
+	if d == 0 || g.s.Chance(0.4) {
+		if len(vars) == 0 || g.s.Chance(0.3) {
+			k := int64(g.s.Range(0, 9))
+			return fmt.Sprint(k), func(shdEnv) int64 { return k }
+		}
+		v := gen.Pick(g.s, vars...)
+		v.used = true
+		return v.name, func(env shdEnv) int64 { return env[v].v }
+	}
+	xs, x := g.expr(sc, d-1)
+	ys, y := g.expr(sc, d-1)
+	switch op := gen.Pick(g.s, "+", "-", "^"); op {
+	case "+":
+		return fmt.Sprintf("(%s + %s)", xs, ys), func(env shdEnv) int64 { return x(env) + y(env) }
+	case "-":
+		return fmt.Sprintf("(%s - %s)", xs, ys), func(env shdEnv) int64 { return x(env) - y(env) }
+	default:
+		return fmt.Sprintf("(%s ^ %s)", xs, ys), func(env shdEnv) int64 { return x(env) ^ y(env) }
+	}
+}
+
+// block writes statements into the new scope sc, then uses every
+// variable declared there that nothing read.
+func (g *shdGen) block(sc *shdScope, depth int) func(shdEnv) {
+	var run []func(shdEnv)
+	for range g.s.Range(1, 4) {
+		if g.budget <= 0 {
+			break
+		}
+		g.budget--
+		run = append(run, g.stmt(sc, depth))
+	}
+	for _, d := range sc.slots {
+		if !d.used {
+			g.f.line("_ = %s", d.name)
+			d.used = true
+		}
+	}
+	return func(env shdEnv) {
+		for _, r := range run {
+			r(env)
+		}
+	}
+}
+
+func (g *shdGen) note(es string, e func(shdEnv) int64) func(shdEnv) {
+	g.f.line("note(%s)", es)
+	return func(env shdEnv) { g.notes = append(g.notes, e(env)) }
+}
+
+// stmt writes one statement in sc and returns the code that runs it.
+func (g *shdGen) stmt(sc *shdScope, depth int) func(shdEnv) {
+	s, f := g.s, g.f
+	name := gen.Pick(s, shdNames...)
+	nested := depth < 3
+	switch k := s.Intn(12); {
+	case k < 2:
+		// x := x + 1: the right side still sees the outer x.
+		es, e := g.expr(sc, 2)
+		if d := sc.local(name); d != nil {
+			if d.fn || d.readonly {
+				return g.note(es, e)
+			}
+			f.line("%s = %s", name, es)
+			return func(env shdEnv) { env[d].v = e(env) }
+		}
+		d := sc.declare(&shdSlot{name: name})
+		f.line("%s := %s", name, es)
+		return func(env shdEnv) { env[d] = &shdCell{v: e(env)} }
+	case k < 3:
+		// a, err := ...: names already declared in this block are
+		// assigned, the others declared.
+		other := gen.Pick(s, shdNames...)
+		if other == name || (sc.local(name) != nil && sc.local(other) != nil) {
+			es, e := g.expr(sc, 1)
+			return g.note(es, e)
+		}
+		for _, n := range []string{name, other} {
+			if d := sc.local(n); d != nil && (d.fn || d.readonly) {
+				es, e := g.expr(sc, 1)
+				return g.note(es, e)
+			}
+		}
+		as, a := g.expr(sc, 1)
+		bs, b := g.expr(sc, 1)
+		var slots [2]*shdSlot
+		var fresh [2]bool
+		for i, n := range []string{name, other} {
+			if slots[i] = sc.local(n); slots[i] == nil {
+				fresh[i] = true
+			}
+		}
+		for i, n := range []string{name, other} {
+			if fresh[i] {
+				slots[i] = sc.declare(&shdSlot{name: n})
+			}
+		}
+		f.line("%s, %s := %s, %s", name, other, as, bs)
+		return func(env shdEnv) {
+			vals := [2]int64{a(env), b(env)}
+			for i, d := range slots {
+				if fresh[i] {
+					env[d] = &shdCell{v: vals[i]}
+				} else {
+					env[d].v = vals[i]
+				}
+			}
+		}
+	case k < 4:
+		vars := sc.visible(func(d *shdSlot) bool { return !d.fn && !d.readonly })
+		es, e := g.expr(sc, 2)
+		if len(vars) == 0 {
+			return g.note(es, e)
+		}
+		d := gen.Pick(s, vars...)
+		op := gen.Pick(s, "=", "+=", "-=", "^=")
+		f.line("%s %s %s", d.name, op, es)
+		return func(env shdEnv) {
+			c, v := env[d], e(env)
+			switch op {
+			case "=":
+				c.v = v
+			case "+=":
+				c.v += v
+			case "-=":
+				c.v -= v
+			default:
+				c.v ^= v
+			}
+		}
+	case k < 6:
+		es, e := g.expr(sc, 2)
+		return g.note(es, e)
+	case k < 7 && nested:
+		f.open("{")
+		body := g.block(&shdScope{parent: sc}, depth+1)
+		f.close("}")
+		return body
+	case k < 8 && nested:
+		// if x := x * 2; x > k { ... } else { ... }
+		cond := &shdScope{parent: sc}
+		es, e := g.expr(sc, 2)
+		d := cond.declare(&shdSlot{name: name, used: true})
+		lim := int64(s.Range(-3, 12))
+		f.open("if %s := %s; %s > %d {", name, es, name, lim)
+		then := g.block(&shdScope{parent: cond}, depth+1)
+		f.mid("} else {")
+		els := g.block(&shdScope{parent: cond}, depth+1)
+		f.close("}")
+		return func(env shdEnv) {
+			env[d] = &shdCell{v: e(env)}
+			if env[d].v > lim {
+				then(env)
+			} else {
+				els(env)
+			}
+		}
+	case k < 9 && nested && g.loops < 2:
+		// The loop variable is new in every iteration, and the body is
+		// a block of its own where it may be redeclared.
+		loop := &shdScope{parent: sc}
+		d := loop.declare(&shdSlot{name: name, readonly: true, used: true})
+		n := int64(s.Range(0, 3))
+		rangeNote := s.Chance(0.5)
+		if rangeNote {
+			// A range variable is only used if the body reads it.
+			f.open("for %s := range %d {", name, n)
+			f.line("note(%s)", name)
+		} else {
+			f.open("for %s := 0; %s < %d; %s++ {", name, name, n, name)
+		}
+		g.loops++
+		body := g.block(&shdScope{parent: loop}, depth+1)
+		g.loops--
+		f.close("}")
+		return func(env shdEnv) {
+			for i := range n {
+				env[d] = &shdCell{v: i}
+				if rangeNote {
+					g.notes = append(g.notes, i)
+				}
+				body(env)
+			}
+		}
+	case k < 10 && nested:
+		// switch x := ...; { case x > a: ... default: ... }
+		init := &shdScope{parent: sc}
+		es, e := g.expr(sc, 2)
+		d := init.declare(&shdSlot{name: name, used: true})
+		f.open("switch %s := %s; {", name, es)
+		var lims []int64
+		var bodies []func(shdEnv)
+		for range s.Range(1, 3) {
+			lim := int64(s.Range(-3, 12))
+			f.mid("case %s > %d:", name, lim)
+			lims = append(lims, lim)
+			bodies = append(bodies, g.block(&shdScope{parent: init}, depth+1))
+		}
+		f.mid("default:")
+		def := g.block(&shdScope{parent: init}, depth+1)
+		f.close("}")
+		return func(env shdEnv) {
+			env[d] = &shdCell{v: e(env)}
+			for i, lim := range lims {
+				if env[d].v > lim {
+					bodies[i](env)
+					return
+				}
+			}
+			def(env)
+		}
+	case k < 11 && nested:
+		// name := func() int { ...; return ... }
+		if sc.local(name) != nil {
+			es, e := g.expr(sc, 1)
+			return g.note(es, e)
+		}
+		inner := &shdScope{parent: sc}
+		f.open("%s := func() int {", name)
+		loops, closure := g.loops, g.closure
+		g.loops, g.closure = 2, true
+		body := g.block(inner, depth+1)
+		g.loops, g.closure = loops, closure
+		rs, r := g.expr(inner, 2)
+		f.line("return %s", rs)
+		f.close("}")
+		d := sc.declare(&shdSlot{name: name, fn: true})
+		return func(env shdEnv) {
+			env[d] = &shdCell{fn: &shdClosure{env.clone(), body, r}}
+		}
+	default:
+		fns := sc.visible(func(d *shdSlot) bool { return d.fn })
+		if len(fns) == 0 || g.closure {
+			vars := g.int(sc)
+			if len(vars) == 0 || !nested {
+				es, e := g.expr(sc, 1)
+				return g.note(es, e)
+			}
+			// switch x := any(x).(type) { case int: ... }
+			v := gen.Pick(s, vars...)
+			v.used = true
+			init := &shdScope{parent: sc}
+			f.open("switch %s := any(%s).(type) {", v.name, v.name)
+			f.mid("case string:")
+			f.line("_ = %s", v.name)
+			f.mid("case int:")
+			d := init.declare(&shdSlot{name: v.name, used: true})
+			body := g.block(init, depth+1)
+			f.close("}")
+			return func(env shdEnv) {
+				env[d] = &shdCell{v: env[v].v}
+				body(env)
+			}
+		}
+		fn := gen.Pick(s, fns...)
+		fn.used = true
+		if sc.local(fn.name) == nil && s.Chance(0.3) {
+			// len := len(): the new int shadows the func it came from.
+			d := sc.declare(&shdSlot{name: fn.name})
+			f.line("%s := %s()", fn.name, fn.name)
+			return func(env shdEnv) { env[d] = &shdCell{v: env[fn].fn.call()} }
+		}
+		f.line("note(%s())", fn.name)
+		return func(env shdEnv) { g.notes = append(g.notes, env[fn].fn.call()) }
+	}
+}
+
+// shdNest writes a function of randomly nested shadowing declarations
+// and checks the values it notes.
+func shdNest(s *gen.State, f *file) string {
+	fn := s.Fresh("nest")
+	g := &shdGen{s: s, f: f, budget: s.Range(8, 30)}
+	f.open("func %s() {", fn)
+	top := &shdScope{}
+	var run []func(shdEnv)
+	for g.budget > 0 {
+		g.budget--
+		run = append(run, g.stmt(top, 0))
+	}
+	for _, d := range top.slots {
+		if !d.used {
+			f.line("_ = %s", d.name)
+		}
+	}
+	env := shdEnv{}
+	for _, r := range run {
+		r(env)
+	}
+	want := make([]string, len(g.notes))
+	for i, n := range g.notes {
+		want[i] = fmt.Sprint(n)
+	}
+	f.line("expect(%q%s)", fn, strings.Join(append([]string{""}, want...), ", "))
+	f.close("}")
+	return fn
+}
+
+// shdErr writes the err patterns that go wrong in practice: err reused
+// by a second :=, shadowed in an if init, a block or a closure, assigned
+// through a closure, a loop or a named result, checking after each which
+// error the outer err holds.
+func shdErr(s *gen.State, f *file) string {
+	fn := s.Fresh("errs")
+	f.open("func %s() {", fn)
+	k := s.Range(0, 9)
+	v, err := shdStep(k)
+	f.line("v, err := step(%d)", k)
+	f.line("check(v == %d, %q)", v, fn+": first value")
+	for i := range s.Range(2, 7) {
+		k := s.Range(0, 9)
+		kv, kerr := shdStep(k)
+		switch s.Intn(7) {
+		case 0:
+			// At least one new name on the left, so err is assigned.
+			w := s.Fresh("w")
+			f.line("%s, err := step(%d)", w, k)
+			f.line("check(%s == %d, %q)", w, kv, fn+": "+w)
+			err = kerr
+		case 1:
+			f.open("if v, err := step(%d); err != nil {", k)
+			f.line("check(errText(err) == %q, %q)", kerr, fn+": if err")
+			f.mid("} else {")
+			f.line("check(v == %d, %q)", kv, fn+": if value")
+			f.close("}")
+		case 2:
+			f.open("{")
+			f.line("_, err := step(%d)", k)
+			f.line("check(errText(err) == %q, %q)", kerr, fn+": block err")
+			f.close("}")
+		case 3:
+			f.open("func() {")
+			f.line("_, err := step(%d)", k)
+			f.line("_ = err")
+			f.close("}()")
+		case 4:
+			f.open("func() {")
+			f.line("v, err = step(%d)", k)
+			f.close("}()")
+			v, err = kv, kerr
+		case 5:
+			// A named result shadowed in an if init has to be returned
+			// explicitly.
+			f.open("err = func() (err error) {")
+			f.open("if _, err := step(%d); err != nil {", k)
+			f.line("return err")
+			f.close("}")
+			f.line("return")
+			f.close("}()")
+			err = kerr
+		default:
+			n := s.Range(1, 3)
+			f.open("for i := range %d {", n)
+			f.line("v, err = step(%d + i)", k)
+			f.close("}")
+			v, err = shdStep(k + n - 1)
+		}
+		f.line("check(v == %d && errText(err) == %q, %q)", v, err, fmt.Sprintf("%s: after %d", fn, i))
+	}
+	f.close("}")
+	return fn
+}
+
+// shdStep is the generated step function.
+func shdStep(k int) (int, string) {
+	if k%3 == 0 {
+		return 0, fmt.Sprintf("step %d", k)
+	}
+	return k * 2, ""
+}
+
+// shdBuiltins shadows predeclared types, functions and constants inside
+// blocks, some with declarations whose right side still uses the
+// predeclared meaning.
+func shdBuiltins(s *gen.State, f *file) string {
+	fn := s.Fresh("builtins")
+	f.open("func %s() {", fn)
+	blocks := slices.Clone(shdBuiltinBlocks)
+	gen.Shuffle(s, blocks)
+	for _, b := range blocks[:s.Range(2, 5)] {
+		f.open("{")
+		for _, l := range strings.Split(b, "\n") {
+			f.line("%s", strings.ReplaceAll(l, "WHAT", fn))
+		}
+		f.close("}")
+	}
+	f.close("}")
+	return fn
+}
+
+var shdBuiltinBlocks = []string{
+	"type int = string\nvar s int = \"abc\"\ncheck(len(s) == 3, \"WHAT: int as string\")",
+	"type string []byte\nb := string(\"hi\")\nb[0] = 'H'\ncheck(len(b) == 2 && b[0] == 'H', \"WHAT: string as []byte\")",
+	"append := func(xs []int, _ ...int) []int { return xs }\nxs := append([]int{1}, 2, 3)\ncheck(len(xs) == 1, \"WHAT: append\")",
+	"len := func(string) int { return 42 }\ncheck(len(\"a\") == 42, \"WHAT: len\")",
+	"true, false := false, true\ncheck(!true && false, \"WHAT: true and false\")",
+	"nil := 5\niota := nil * 2\ncheck(iota == 10, \"WHAT: nil and iota\")",
+	"type error = int\nvar e error = 3\ncheck(e+1 == 4, \"WHAT: error as int\")",
+	"called := false\npanic := func(any) { called = true }\npanic(\"not really\")\ncheck(called, \"WHAT: panic\")",
+	"cap := cap(make([]int, 0, 5))\ncheck(cap == 5, \"WHAT: cap from cap\")",
+	"len := len(\"abcd\")\nlen++\ncheck(len == 5, \"WHAT: len from len\")",
+	"string := \"s\"\nx := string + \"t\"\ncheck(x == \"st\", \"WHAT: string as a value\")",
+	"int := 3\nvar f float64 = float64(int)\ncheck(f == 3, \"WHAT: int as a value\")",
+	"type any = struct{ n int }\nvar a any\na.n = 2\ncheck(a.n == 2, \"WHAT: any as a struct\")",
+	"type bool int\nconst yes bool = 1\ncheck(yes == 1 && 1 > 0, \"WHAT: bool as int\")",
+}
+
+// shdTypeParams names type parameters after predeclared types and after
+// their own function.
+func shdTypeParams(s *gen.State, f *file) string {
+	fn := s.Fresh("tparams")
+	a, b, c, d := s.Fresh("swap"), s.Fresh("pick"), s.Fresh("wrap"), s.Fresh("box")
+	f.line("func %s[int any, string comparable](x int, y string) (string, int) { return y, x }", a)
+	f.blank()
+	f.line("func %s[any comparable, T interface{}](_ T, y any) any { return y }", b)
+	f.blank()
+	f.line("func %s[%s any](x %s) %s { return x }", c, c, c, c)
+	f.blank()
+	f.line("type %s[T any] struct{ v T }", d)
+	f.blank()
+	f.line("func (b %s[int]) get() int { return b.v }", d)
+	f.blank()
+	f.line("func (len %s[cap]) len() cap { return len.v }", d)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("y, x := %s[float64, bool](1.5, true)", a)
+	f.line("check(y && x == 1.5, %q)", fn+": swap")
+	f.line("check(%s(1, \"s\") == \"s\", %q)", b, fn+": pick")
+	f.line("check(%s(%s[int](7)) == 7, %q)", c, c, fn+": wrap")
+	f.line("check(%s[string]{\"v\"}.get() == \"v\", %q)", d, fn+": receiver type parameter int")
+	f.line("check(%s[int]{3}.len() == 3, %q)", d, fn+": receiver len, type parameter cap")
+	f.close("}")
+	return fn
+}
+
+// shdPackage shadows predeclared names for the whole package and an
+// imported package name inside a function.
+func shdPackage(s *gen.State, f *file) string {
+	fn := s.Fresh("pkgScope")
+	f.use("strings")
+	f.line("func max(a, b int) int { return a }")
+	f.blank()
+	f.line("var real = 7")
+	f.blank()
+	f.line("type clear struct{ n int }")
+	f.blank()
+	f.line("const complex = \"c\"")
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("check(max(1, 2) == 1, %q)", fn+": max")
+	f.line("check(real == 7 && clear{3}.n == 3 && complex+\"x\" == \"cx\", %q)", fn+": real, clear, complex")
+	f.open("{")
+	f.line("max := max(5, 6)")
+	f.line("check(max == 5, %q)", fn+": max from max")
+	f.close("}")
+	f.line("n := strings.Count(\"aaa\", \"a\")")
+	f.open("{")
+	f.line("strings := n * 2")
+	f.line("check(strings == 6, %q)", fn+": strings as a value")
+	f.close("}")
+	f.line("fmt := fmt.Sprint(n)")
+	f.line("check(fmt == \"3\", %q)", fn+": fmt from fmt")
+	f.close("}")
+	return fn
+}
+
+// badShadow writes a declaration or use that scoping makes invalid.
+func badShadow(s *gen.State, f *file) {
+	b := s.Fresh("Bad")
+	switch s.Intn(10) {
+	case 0:
+		f.line("func %s() { x := 1; x := 2; _ = x }", b)
+	case 1:
+		f.line("func %s() { if y := 1; y > 0 {}; _ = y }", b)
+	case 2:
+		f.line("func %s() { len := 3; _ = len(\"a\") }", b)
+	case 3:
+		f.line("func %s() { type int string; var _ int = 1 }", b)
+	case 4:
+		f.line("func %s() { make := new; _ = make }", b)
+	case 5:
+		f.line("func %s() { g := func() int { return g() }; _ = g }", b)
+	case 6:
+		// err is shadowed at the bare return.
+		f.open("func %s() (err error) {", b)
+		f.open("if _, err := step(0); err != nil {")
+		f.line("return")
+		f.close("}")
+		f.line("return nil")
+		f.close("}")
+	case 7:
+		f.line("func %s() { func() { x := 1 }() }", b)
+	case 8:
+		f.line("func %s() { for i := range 3 { i := i } }", b)
+	default:
+		f.line("var fmt = %s", gen.Pick(s, "1", "\"fmt\""))
+		f.line("var %s = fmt", b)
+	}
+}
@@ -0,0 +1,220 @@
+package gosrc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/tags",
+		Doc:  "struct fields with malformed, duplicated, enormous and unicode-laden tags, and conversions between structs that differ only in tags",
+		Func: tags,
+	})
+}
+
+// tags writes struct types for the tag parsers in reflect and
+// encoding/json to read. Any string is a valid tag, so every seed
+// without a badTag type checks however broken its tags are.
+func tags(s *gen.State) []gen.File {
+	f := newFile(s, "go/tags")
+	fill(s, f, 2, 6,
+		tagStruct, tagStruct, tagStruct,
+		tagNested,
+		tagConvert,
+	)
+	if s.Chance(0.1) {
+		badTag(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// tagKeys are keys in the reflect.StructTag convention: anything but
+// spaces, quotes, colons and control characters.
+var tagKeys = []string{
+	"json", "json", "json", "xml", "yaml", "db", "protobuf", "validate",
+	"env", "mapstructure", "k", "a-b", "a.b", "#", "{}", "JSON",
+	"ключ", "鍵", "kéy", "\U0001F511", "\u00a0",
+}
+
+// jsonNames are the name parts of json tags: valid ones, some with
+// punctuation the encoder allows, and invalid ones it replaces with the
+// field name.
+var jsonNames = []string{
+	"", "name", "Name", "-", "a b", "x-y.z", "ключ", "名前", "$ref", "@id",
+	"<&>", "a\"b", "a\\b", "a,b", "\u00e9", "e\u0301", "\u2028", "1", "_",
+}
+
+var jsonOptions = []string{
+	"omitempty", "string", "omitzero", "inline", "", "omitempty ", "OMITEMPTY",
+}
+
+// tagValue returns a value for key, usually a json tag when key is
+// "json".
+func tagValue(s *gen.State, key string) string {
+	if key == "json" && s.Chance(0.8) {
+		v := gen.Pick(s, jsonNames...)
+		for range s.Range(0, 3) {
+			v += "," + gen.Pick(s, jsonOptions...)
+		}
+		return v
+	}
+	return gen.Pick(s, "", "x", "a b", "required,min=1", "\t", "\\", "\"", "\x00", "\xff", "\u00e9", "\U0010FFFF", "%v", "`")
+}
+
+// tagElement returns one key:"value" element of a tag, sometimes malformed.
+func tagElement(s *gen.State) string {
+	key := gen.Pick(s, tagKeys...)
+	val := strconv.Quote(tagValue(s, key))
+	if s.Chance(0.15) {
+		val = strconv.QuoteToASCII(tagValue(s, key))
+	}
+	if !s.Chance(0.2) {
+		return key + ":" + val
+	}
+	// Malformed: an unquoted or single-quoted value, space around the
+	// colon, no key, no colon, an unterminated value, an escape Unquote
+	// rejects, or a control character in the key.
+	return gen.Pick(s,
+		key+":"+strings.Trim(val, `"`),
+		key+":'x'",
+		key+": "+val,
+		key+" :"+val,
+		":"+val,
+		key+val,
+		key+":"+val[:len(val)-1],
+		key+`:"\q"`,
+		key+`:"`+"\n"+`"`,
+		"k\x01:"+val,
+		"k\x7f:"+val,
+		key,
+	)
+}
+
+// tagString returns the contents of a tag.
+func tagString(s *gen.State) string {
+	var elems []string
+	switch s.Intn(8) {
+	case 0:
+		// Enormous: a long value or a great many pairs.
+		if s.Chance(0.5) {
+			return `json:"` + strings.Repeat(gen.Pick(s, "a", "é", `\"`, "\\u00e9"), s.Range(1<<8, 1<<13)) + `"`
+		}
+		for i := range s.Range(50, 500) {
+			elems = append(elems, fmt.Sprintf("k%d:%q", i, tagValue(s, "")))
+		}
+		elems = append(elems, `json:"last"`)
+	case 1:
+		// Duplicated: the first pair for a key wins.
+		key := gen.Pick(s, tagKeys...)
+		for range s.Range(2, 4) {
+			elems = append(elems, key+":"+strconv.Quote(tagValue(s, key)))
+		}
+	default:
+		for range s.Range(0, 5) {
+			elems = append(elems, tagElement(s))
+		}
+	}
+	sep := gen.Pick(s, " ", " ", " ", "", "  ", "\t")
+	tag := strings.Join(elems, sep)
+	if s.Chance(0.1) {
+		tag = gen.Pick(s, " ", "\t", "\n") + tag + gen.Pick(s, " ", "", "garbage")
+	}
+	return tag
+}
+
+// tagLit writes tag as a raw string literal where it can be one.
+func tagLit(s *gen.State, tag string) string {
+	if s.Chance(0.7) && strconv.CanBackquote(tag) {
+		return "`" + tag + "`"
+	}
+	return strconv.Quote(tag)
+}
+
+// tagFields writes the fields of a struct type with tags.
+func tagFields(s *gen.State, f *file, n int) {
+	for i := range n {
+		typ := gen.Pick(s, "int", "string", "*int", "[]byte", "bool", "float64", "map[string]int", "struct{}", "any")
+		name := fmt.Sprintf("F%d", i)
+		switch {
+		case s.Chance(0.1):
+			name = "_"
+		case s.Chance(0.1):
+			name = "f" + strconv.Itoa(i)
+		}
+		tag := ""
+		if s.Chance(0.85) {
+			tag = " " + tagLit(s, tagString(s))
+		}
+		f.line("%s %s%s", name, typ, tag)
+	}
+}
+
+func tagStruct(s *gen.State, f *file) {
+	f.open("type %s struct {", s.Fresh("T"))
+	tagFields(s, f, s.Range(1, 6))
+	f.close("}")
+}
+
+// tagNested writes tags on embedded fields and on the fields of
+// anonymous struct types.
+func tagNested(s *gen.State, f *file) {
+	e := s.Fresh("E")
+	f.open("type %s struct {", e)
+	tagFields(s, f, s.Range(1, 3))
+	f.close("}")
+	f.blank()
+	f.open("type %s struct {", s.Fresh("T"))
+	f.line("%s%s %s", gen.Pick(s, "", "*"), e, tagLit(s, tagString(s)))
+	f.open("Inner struct {")
+	tagFields(s, f, s.Range(1, 3))
+	f.close("} " + tagLit(s, tagString(s)))
+	f.close("}")
+}
+
+// tagConvert writes two struct types that differ only in their tags.
+// They are distinct types but convertible to each other.
+func tagConvert(s *gen.State, f *file) {
+	a, b := s.Fresh("T"), s.Fresh("T")
+	types := make([]string, s.Range(1, 4))
+	for i := range types {
+		types[i] = gen.Pick(s, "int", "string", "[]byte", "struct{ X int }")
+	}
+	for _, t := range []string{a, b} {
+		f.open("type %s struct {", t)
+		for i, typ := range types {
+			f.line("F%d %s %s", i, typ, tagLit(s, tagString(s)))
+		}
+		f.close("}")
+		f.blank()
+	}
+	f.line("var _ = %s(%s{})", b, a)
+	f.line("var _ = (*%s)(&%s{})", a, b)
+	f.open("var _ = %s(struct {", a)
+	for i, typ := range types {
+		f.line("F%d %s", i, typ)
+	}
+	f.close("}{})")
+}
+
+// badTag writes a tag that is not a string literal or an assignment
+// between types that differ only in their tags.
+func badTag(s *gen.State, f *file) {
+	b := s.Fresh("Bad")
+	switch s.Intn(4) {
+	case 0:
+		f.line("type %s struct { F int \"a\" + \"b\" }", b)
+	case 1:
+		f.line("type %s struct { F int 'x' }", b)
+	case 2:
+		f.line("const %sTag = `json:\"f\"`", b)
+		f.line("type %s struct { F int %sTag }", b, b)
+	default:
+		f.line("type %s struct { F int `json:\"a\"` }", b)
+		f.line("var _ %s = struct { F int `json:\"b\"` }{}", b)
+	}
+}
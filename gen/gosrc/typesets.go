@@ -0,0 +1,337 @@
+package gosrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/typesets",
+		Doc:  "constraint interfaces mixing unions, ~T terms, comparable and methods, their intersections and empty type sets, and unions the spec forbids",
+		Func: typeSets,
+	})
+}
+
+// typeSets writes constraint interfaces and generic functions that only
+// type check if the checker computes the constraint's type set right:
+// every operation in a function body is one all types in the set
+// support, and every instantiation uses a type in the set. A seed without
+// a badTypeSet that fails to type check at the newest Go version is a
+// finding.
+func typeSets(s *gen.State) []gen.File {
+	f := newFile(s, "go/typesets")
+	fill(s, f, 3, 7,
+		tsUnion, tsUnion,
+		tsIntersect, tsIntersect,
+		tsMethods,
+		tsPointer,
+		tsComparable,
+		tsNestedUnion,
+	)
+	if s.Chance(0.2) {
+		badTypeSet(s, f)
+		f.blank()
+	}
+	return f.files()
+}
+
+// A term is a union term: a predeclared or literal type, approximated
+// with ~ or not.
+type term struct {
+	tilde bool
+	typ   string
+}
+
+func (t term) String() string {
+	if t.tilde {
+		return "~" + t.typ
+	}
+	return t.typ
+}
+
+// A tsGroup is a set of types and an operation every one of them
+// supports, written over parameters a and b of type T.
+type tsGroup struct {
+	types  []string
+	body   string
+	result string
+}
+
+var (
+	tsInts   = []string{"int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr"}
+	tsFloats = []string{"float32", "float64"}
+
+	tsGroups = []tsGroup{
+		{tsInts, "a%b | a<<1 ^ b", "T"},
+		{tsInts, "a &^ b", "T"},
+		{concat(tsInts, tsFloats), "a*b - a/b", "T"},
+		{concat(tsInts, tsFloats, []string{"string"}), "a + b", "T"},
+		{concat(tsInts, tsFloats, []string{"string"}), "a < b || a >= b", "bool"},
+		{concat(tsInts, tsFloats, []string{"complex64", "complex128"}), "-a + b", "T"},
+		{[]string{"bool", "string", "int", "[2]int", "struct{}", "*int", "chan int", "[1]string"}, "a == b", "bool"},
+		{[]string{"[]byte", "string"}, "T(append([]byte(nil), a...))", "T"},
+		{[]string{"string", "[]int", "[4]int", "*[4]int", "map[int]int", "chan int"}, "len(a) + len(b)", "int"},
+	}
+)
+
+func concat(ss ...[]string) []string {
+	var all []string
+	for _, s := range ss {
+		all = append(all, s...)
+	}
+	return all
+}
+
+// terms picks a union of n distinct types from g, each approximated with
+// probability 1/2.
+func terms(s *gen.State, g tsGroup, n int) []term {
+	types := append([]string(nil), g.types...)
+	gen.Shuffle(s, types)
+	var ts []term
+	seen := map[string]bool{}
+	for _, t := range types {
+		if len(ts) == n {
+			break
+		}
+		if seen[t] {
+			continue
+		}
+		seen[t] = true
+		ts = append(ts, term{s.Chance(0.5), t})
+	}
+	return ts
+}
+
+func union(ts []term) string {
+	parts := make([]string, len(ts))
+	for i, t := range ts {
+		parts[i] = t.String()
+	}
+	return strings.Join(parts, " | ")
+}
+
+// intersect returns the terms in both a and b. The types are all
+// distinct predeclared or literal types, so terms only meet on the same
+// type, and ~T meets T in T.
+func intersect(a, b []term) []term {
+	var ts []term
+	for _, x := range a {
+		for _, y := range b {
+			if x.typ == y.typ {
+				ts = append(ts, term{x.tilde && y.tilde, x.typ})
+			}
+		}
+	}
+	return ts
+}
+
+// instantiate writes a call of fn with a type argument drawn from ts: the
+// type itself, or for an approximated term sometimes a defined type with
+// it as underlying type.
+func instantiate(s *gen.State, f *file, fn string, ts []term) {
+	t := gen.Pick(s, ts...)
+	arg := t.typ
+	if t.tilde && s.Chance(0.6) {
+		arg = s.Fresh("Def")
+		f.line("type %s %s", arg, t.typ)
+	}
+	z := s.Fresh("z")
+	f.line("var %s %s", z, arg)
+	if s.Chance(0.5) {
+		f.line("var _ = %s(%s, %s)", fn, z, z)
+	} else {
+		f.line("var _ = %s[%s](%s, %s)", fn, arg, z, z)
+	}
+}
+
+// constraintFunc writes a function constrained by c that applies g's
+// operation.
+func constraintFunc(s *gen.State, f *file, c string, g tsGroup) string {
+	fn := s.Fresh("op")
+	f.line("func %s[T %s](a, b T) %s { return %s }", fn, c, g.result, g.body)
+	return fn
+}
+
+// tsUnion writes a union constraint and instantiates a function using it
+// with every kind of type its terms allow.
+func tsUnion(s *gen.State, f *file) {
+	g := gen.Pick(s, tsGroups...)
+	ts := terms(s, g, s.Range(1, 5))
+	c := s.Fresh("Union")
+	if s.Chance(0.3) {
+		// A single-term constraint may be written without interface{}.
+		f.line("func %s[T %s](a, b T) %s { return %s }", c, union(ts), g.result, g.body)
+		instantiate(s, f, c, ts)
+		return
+	}
+	f.line("type %s interface{ %s }", c, union(ts))
+	fn := constraintFunc(s, f, c, g)
+	for range s.Range(1, 3) {
+		instantiate(s, f, fn, ts)
+	}
+}
+
+// tsIntersect embeds two unions in one interface, whose type set is their
+// intersection. An empty intersection is a valid constraint no type
+// satisfies, so its function is declared but does nothing and is not
+// instantiated.
+func tsIntersect(s *gen.State, f *file) {
+	g := gen.Pick(s, tsGroups...)
+	a, b := terms(s, g, s.Range(1, 5)), terms(s, g, s.Range(1, 5))
+	ca, cb, c := s.Fresh("Left"), s.Fresh("Right"), s.Fresh("Both")
+	both := intersect(a, b)
+	f.line("type %s interface{ %s }", ca, union(a))
+	f.line("type %s interface{ %s }", cb, union(b))
+	if len(both) == 0 {
+		f.line("// %s has an empty type set.", c)
+	}
+	if s.Chance(0.5) {
+		f.line("type %s interface{ %s; %s }", c, ca, cb)
+	} else {
+		f.open("type %s interface {", c)
+		f.line("%s", ca)
+		f.line("%s", union(b))
+		f.close("}")
+	}
+	if len(both) == 0 {
+		// No operation is defined on an empty type set, not even ==.
+		f.line("func %s[T %s](a, b T) {}", s.Fresh("op"), c)
+		return
+	}
+	instantiate(s, f, constraintFunc(s, f, c, g), both)
+}
+
+// tsMethods writes a constraint with both a type element and a method,
+// satisfied only by a defined type with that method.
+func tsMethods(s *gen.State, f *file) {
+	c, d, fn := s.Fresh("Sized"), s.Fresh("Def"), s.Fresh("size")
+	under := gen.Pick(s, "[]int", "string", "map[string]int", "[]string", "chan int")
+	empty := false
+	f.line("type %s %s", d, under)
+	f.line("func (x %s) Size() int { return len(x) }", d)
+	f.blank()
+	if s.Chance(0.5) {
+		f.line("type %s interface{ ~%s; Size() int }", c, under)
+	} else {
+		f.open("type %s interface {", c)
+		f.line("Size() int")
+		f.line("~%s", under)
+		if s.Chance(0.5) {
+			f.line("comparable")
+			empty = under != "string" && under != "chan int"
+		}
+		f.close("}")
+	}
+	if empty {
+		// Slices and maps aren't comparable, so nothing satisfies c and
+		// only its method may be used.
+		f.line("func %s[T %s](x T) int { return x.Size() }", fn, c)
+		return
+	}
+	f.line("func %s[T %s](x T) int { return x.Size() + len(x) }", fn, c)
+	f.line("var _ = %s(*new(%s))", fn, d)
+}
+
+// tsPointer writes the pointer-method pattern: a second type parameter
+// constrained to *T plus a method, inferred from the first.
+func tsPointer(s *gen.State, f *file) {
+	st, c, fn := s.Fresh("Cell"), s.Fresh("Setter"), s.Fresh("fresh")
+	f.line("type %s struct{ v int }", st)
+	f.line("func (c *%s) Set(v int) { c.v = v }", st)
+	f.blank()
+	f.line("type %s[T any] interface{ *T; Set(int) }", c)
+	f.open("func %s[T any, PT %s[T]](v int) T {", fn, c)
+	f.line("var x T")
+	f.line("PT(&x).Set(v)")
+	f.line("return x")
+	f.close("}")
+	if s.Chance(0.5) {
+		f.line("var _ = %s[%s](1)", fn, st)
+	} else {
+		f.line("var _ = %s[%s, *%s](1)", fn, st, st)
+	}
+}
+
+// tsComparable instantiates comparable constraints with types that are
+// comparable only in the spec's loose sense (interfaces, and arrays and
+// structs of them), which satisfy comparable since Go 1.20 but not before,
+// and with comparable plus methods.
+func tsComparable(s *gen.State, f *file) {
+	fn := s.Fresh("eq")
+	if s.Chance(0.5) {
+		f.line("func %s[T comparable](a, b T) bool { return a == b }", fn)
+		for range s.Range(1, 3) {
+			arg := gen.Pick(s, "any", "error", "interface{ M() }", "[2]any", "struct{ x any }", "*any", "[0]error", "int")
+			f.line("var _ = %s[%s]", fn, arg)
+		}
+		return
+	}
+	c, d := s.Fresh("Key"), s.Fresh("Def")
+	f.line("type %s interface{ comparable; String() string }", c)
+	f.line("type %s %s", d, gen.Pick(s, "int", "string", "struct{ a, b int }", "[3]bool", "float64"))
+	f.line("func (%s) String() string { return \"\" }", d)
+	f.line("func %s[T %s](a, b T) bool { return a == b && a.String() == b.String() }", fn, c)
+	f.line("var _ = %s(*new(%s), *new(%s))", fn, d, d)
+	if s.Chance(0.5) {
+		// A map key may be any comparable type parameter.
+		f.line("var _ map[%s]int", d)
+	}
+}
+
+// tsNestedUnion writes unions whose terms are themselves interfaces
+// without methods, including any, which make the union's type set the
+// set of all types.
+func tsNestedUnion(s *gen.State, f *file) {
+	g := gen.Pick(s, tsGroups...)
+	a, b := terms(s, g, s.Range(1, 3)), terms(s, g, s.Range(1, 3))
+	inner := s.Fresh("Inner")
+	f.line("type %s interface{ %s }", inner, union(a))
+	c := s.Fresh("Outer")
+	if s.Chance(0.3) {
+		f.line("type %s interface{ any | %s }", c, inner)
+		f.line("func %s[T %s](x T) any { return x }", s.Fresh("id"), c)
+		return
+	}
+	// Terms of b already in a would overlap, which is only an error
+	// between non-interface terms, so b's may repeat a's freely.
+	f.line("type %s interface{ %s | interface{ %s } }", c, inner, union(b))
+	fn := constraintFunc(s, f, c, g)
+	instantiate(s, f, fn, append(a, b...))
+}
+
+// badTypeSet writes one constraint or use the spec forbids.
+func badTypeSet(s *gen.State, f *file) {
+	b := s.Fresh("Bad")
+	switch s.Intn(10) {
+	case 0:
+		f.use("fmt")
+		f.line("type %s interface{ int | fmt.Stringer }", b) // union term with methods
+	case 1:
+		f.line("type %s interface{ comparable | int }", b)
+	case 2:
+		d := s.Fresh("Def")
+		f.line("type %s int", d)
+		f.line("type %s interface{ ~%s }", b, d) // ~ needs an underlying type
+	case 3:
+		t := gen.Pick(s, tsInts...)
+		f.line("type %s interface{ ~%s | %s }", b, t, t) // overlapping terms
+	case 4:
+		f.line("func %s[T any, U interface{ T | int }]() {}", b)
+	case 5:
+		f.line("var %s interface{ ~int }", b) // constraint used as a type
+	case 6:
+		f.line("type %s interface{ ~error }", b)
+	case 7:
+		f.line("func %s[T interface{ ~int | ~string }](a, b T) T { return a - b }", b) // - not defined on string
+	case 8:
+		d := s.Fresh("Def")
+		f.line("type %s int", d)
+		f.line("func %s[T interface{ int | string }](x T) {}", b)
+		f.line("var _ = %s[%s]", b, d) // a defined type is not in a union of exact terms
+	default:
+		f.line("func %s[T comparable]() {}", b)
+		f.line("var _ = %s[func()]", b)
+	}
+}
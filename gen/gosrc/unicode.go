@@ -0,0 +1,210 @@
+package gosrc
+
+import (
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "go/unicode",
+		Doc:  "non-ASCII, confusable and invisible identifiers; bidi text in comments and strings",
+		Func: unicodeIdents,
+	})
+}
+
+func unicodeIdents(s *gen.State) []gen.File {
+	f := newFile(s, "go/unicode")
+	fill(s, f, 4, 10,
+		uniScripts, uniScripts,
+		uniExported,
+		uniNormalization,
+		uniHomoglyph, uniHomoglyph,
+		uniInvisible,
+		uniBidi,
+	)
+	// Characters that are not letters or digits are rejected by the
+	// scanner, which fails the whole file, so only some seeds get one.
+	if s.Chance(0.3) {
+		badUnicode(s, f)
+	}
+	return f.files()
+}
+
+// letterPrefix returns an identifier prefix from a script whose words are
+// letters only (no combining vowel signs), so any of them is a valid
+// identifier start.
+func letterPrefix(s *gen.State) string {
+	return gen.Pick(s,
+		"α", "λx", "привет", "шаг", "שלום", "مرحبا", "変数", "値", "변수",
+		"ß", "ø", "ıi", "ǆ", "ſ", "ℓ", "𝑥", "𝔘", "ｘ", "_é",
+	)
+}
+
+// digitSuffix returns a run of decimal digits, not all of them ASCII; any
+// Unicode digit may follow the first character of an identifier.
+func digitSuffix(s *gen.State) string {
+	return gen.Pick(s, "", "0", "٣", "۷", "߀", "१", "೯", "１２", "𝟘", "٠1")
+}
+
+func uniScripts(s *gen.State, f *file) {
+	v, fn := s.Fresh(letterPrefix(s)), s.Fresh(letterPrefix(s))
+	f.line("var %s%s = %q", v, digitSuffix(s), gen.Pick(s, "日本", "ü", "\U0001F600", ""))
+	f.open("func %s(%s int) (%s string) {", fn, s.Fresh(letterPrefix(s)), s.Fresh(letterPrefix(s)))
+	lbl := s.Fresh(gen.Pick(s, "ループ", "цикл", "Λ"))
+	f.open("%s:", lbl)
+	i := s.Fresh(letterPrefix(s))
+	f.open("for %s := range 3 {", i)
+	f.line("_ = %s", i)
+	f.line("break %s", lbl)
+	f.close("}")
+	f.depth--
+	f.line("return")
+	f.close("}")
+}
+
+// uniExported mixes exported (upper case, category Lu) and unexported
+// non-ASCII names. Title case letters such as ǅ are not upper case, so
+// they do not export.
+func uniExported(s *gen.State, f *file) {
+	t := s.Fresh(gen.Pick(s, "Ω", "Δ", "Жук", "Ä", "Σ", "𝐀"))
+	f.open("type %s struct {", t)
+	f.line("%s int", s.Fresh(gen.Pick(s, "Φ", "Ψ", "Ü")))
+	f.line("%s int", s.Fresh(gen.Pick(s, "ǅ", "ᾈ", "ǲ")))
+	f.line("%s string", s.Fresh(gen.Pick(s, "φ", "ü", "é")))
+	f.close("}")
+	f.blank()
+	m := s.Fresh(gen.Pick(s, "Ψ", "Ж", "Ǆ", "ǅ"))
+	f.line("func (%s) %s() {}", t, m)
+	f.line("func %s[%s any](x %s) %s { return x }", s.Fresh(gen.Pick(s, "Ñ", "ñ")), "Τ", "Τ", "Τ")
+	f.line("var _ interface{ %s() } = %s{}", m, t)
+}
+
+// uniNormalization declares identifiers that are distinct to Go but equal
+// (or look equal) after Unicode normalization: singleton decompositions,
+// compatibility ligatures and precomposed letters.
+func uniNormalization(s *gen.State, f *file) {
+	pair := gen.Pick(s,
+		[2]string{"\u212b", "\u00c5"},  // ANGSTROM SIGN, A WITH RING ABOVE
+		[2]string{"\u212a", "K"},       // KELVIN SIGN
+		[2]string{"\u2126", "\u03a9"},  // OHM SIGN, OMEGA
+		[2]string{"\u00b5", "\u03bc"},  // MICRO SIGN, MU
+		[2]string{"\ufb01", "fi"},      // LATIN SMALL LIGATURE FI
+		[2]string{"\u017f", "s"},       // LONG S
+		[2]string{"\u01c6", "d\u017e"}, // DZ WITH CARON, D + Z WITH CARON
+		[2]string{"\uff58", "x"},       // FULLWIDTH X
+	)
+	suffix := s.Fresh("_")
+	a, b := pair[0]+suffix, pair[1]+suffix
+	switch s.Intn(3) {
+	case 0:
+		f.line("var %s, %s = 1, 2", a, b)
+		f.line("var _ = %s + %s", a, b)
+	case 1:
+		f.line("type %s struct{ %s, %s int }", s.Fresh("N"), a, b)
+	default:
+		f.line("func %s() { %s := 1; %s := %s; _ = %s }", s.Fresh("f"), a, b, a, b)
+	}
+}
+
+// uniHomoglyph declares Latin identifiers next to look-alikes spelled with
+// Cyrillic or Greek letters.
+func uniHomoglyph(s *gen.State, f *file) {
+	pair := gen.Pick(s,
+		[2]string{"pass", "pаss"},   // Cyrillic а
+		[2]string{"open", "оpen"},   // Cyrillic о
+		[2]string{"copy", "сору"},   // Cyrillic с, о, р, у
+		[2]string{"Exec", "Ехес"},   // Cyrillic Е, х, е, с
+		[2]string{"Alpha", "Αlpha"}, // Greek Α
+		[2]string{"ok", "οk"},       // Greek ο
+		[2]string{"ix", "іх"},       // Cyrillic і, х
+		[2]string{"l1", "ӏ1"},       // Cyrillic palochka
+	)
+	suffix := s.Fresh("_")
+	a, b := pair[0]+suffix, pair[1]+suffix
+	switch s.Intn(4) {
+	case 0:
+		f.line("var %s, %s = true, false", a, b)
+	case 1:
+		f.line("func %s() int { return 1 }", a)
+		f.line("func %s() int { return 2 }", b)
+		f.line("var _ = %s() + %s()", a, b)
+	case 2:
+		// The inner declaration does not shadow the outer one.
+		f.open("func %s() int {", s.Fresh("f"))
+		f.line("%s := 1", a)
+		f.open("{")
+		f.line("%s := 2", b)
+		f.line("_ = %s", b)
+		f.close("}")
+		f.line("return %s", a)
+		f.close("}")
+	default:
+		f.line("type %s struct{ %s, %s int }", s.Fresh("H"), a, b)
+	}
+}
+
+// uniInvisible uses Hangul fillers, which are letters (category Lo) that
+// render as blank space, so identifiers can differ only by them or
+// consist of nothing visible.
+func uniInvisible(s *gen.State, f *file) {
+	filler := gen.Pick(s, "\u3164", "\u115f", "\u1160", "\uffa0")
+	v := s.Fresh("v")
+	switch s.Intn(3) {
+	case 0:
+		f.line("var %s, %s%s = 1, 2", v, v, filler)
+	case 1:
+		f.line("var %s%s = %s", filler, s.Fresh(""), gen.Pick(s, "1", `"invisible"`))
+	default:
+		fn := s.Fresh("f")
+		f.line("func %s(%s, %s%s int) int { return %s - %s%s }", fn, v, v, filler, v, v, filler)
+	}
+}
+
+// uniBidi uses right-to-left identifiers and puts bidirectional control
+// characters in comments and strings, where they are legal.
+func uniBidi(s *gen.State, f *file) {
+	switch s.Intn(3) {
+	case 0:
+		f.line("var %s, %s = 1, 2", s.Fresh("אבג"), s.Fresh("سلام"))
+	case 1:
+		f.open("func %s(admin bool) bool {", s.Fresh("f"))
+		f.line("/* \u202e } \u2066if admin\u2069 \u2066 begin admins only */")
+		f.line("return admin // \u2067check\u2069")
+		f.close("}")
+	default:
+		f.line("var %s = %s", s.Fresh("s"), gen.Pick(s,
+			"\"user\u202e \u2066// comment\u2069\u2066\"",
+			"`\u200f\u200e\u061c`",
+			"\"\\u202e\" + \"\u202d\"",
+			"'\u202e'",
+		))
+	}
+}
+
+// badUnicode emits an identifier or token separator the scanner must
+// reject.
+func badUnicode(s *gen.State, f *file) {
+	v := s.Fresh("bad")
+	switch s.Intn(10) {
+	case 0:
+		f.line("var cafe\u0301%s int // NFD: combining acute accent", v)
+	case 1:
+		f.line("var a\u200d%s int // zero width joiner", v)
+	case 2:
+		f.line("var a\u200c%s int // zero width non-joiner", v)
+	case 3:
+		f.line("var %s\u202e int", v)
+	case 4:
+		f.line("var %s\ufeff int", v)
+	case 5:
+		f.line("var Ⅻ%s int // letter number", v)
+	case 6:
+		f.line("var ٣%s int // leading digit", v)
+	case 7:
+		f.line("var \U0001F600%s int", v)
+	case 8:
+		f.line("var %s\u00a0int", v)
+	default:
+		f.line("var क्%s int // virama", v)
+	}
+}
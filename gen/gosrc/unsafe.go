@@ -0,0 +1,282 @@
+package gosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name:      "go/unsafe",
+		Doc:       "unsafe.Pointer arithmetic, unsafe.Slice/String/Add, uintptr round trips and //go:linkname into the runtime",
+		Dangerous: true,
+		Func:      unsafeSeed,
+	})
+}
+
+// unsafeSeed writes a main package whose main calls every snippet, so
+// that running a seed with -race or -gcflags=all=-d=checkptr exercises
+// the checkptr instrumentation and not only the compiler.
+func unsafeSeed(s *gen.State) []gen.File {
+	f := newFile(s, "go/unsafe")
+	f.pkg = "main"
+	f.use("unsafe")
+	f.line("var _ unsafe.Pointer")
+	f.blank()
+	calls := fillMain(s, f, 3, 8,
+		unsafeArith, unsafeArith,
+		unsafeSliceString, unsafeSliceString,
+		unsafeRoundTrip,
+		unsafePun,
+		unsafeLayout,
+		unsafeEscape,
+		unsafeLinkname, unsafeLinkname,
+	)
+	if s.Chance(0.1) {
+		badUnsafe(s, f)
+		f.blank()
+	}
+	f.main(calls)
+	return f.files()
+}
+
+// unsafeElem returns an element type and a list of four values of it.
+func unsafeElem(s *gen.State) (typ, vals string) {
+	e := gen.Pick(s,
+		[2]string{"int64", "1, -2, 3, 1 << 40"},
+		[2]string{"byte", "'a', 'b', 'c', 0xff"},
+		[2]string{"int32", "7, 8, 9, -1"},
+		[2]string{"float64", "0.5, -0, 1e308, 3"},
+		[2]string{"struct{ a byte; b int64 }", "{1, 2}, {3, 4}, {}, {b: 5}"},
+		[2]string{"*int", "nil, new(int), nil, new(int)"},
+	)
+	return e[0], e[1]
+}
+
+// unsafeArith walks an array by pointer arithmetic. The dangerous form
+// steps one element past the end, which checkptr reports.
+func unsafeArith(s *gen.State, f *file) string {
+	fn := s.Fresh("arith")
+	typ, vals := unsafeElem(s)
+	n := "len(arr)"
+	if s.Chance(0.2) {
+		n = "len(arr) + 1"
+	}
+	f.open("func %s() {", fn)
+	f.line("arr := [4]%s{%s}", typ, vals)
+	f.open("for i := range %s {", n)
+	switch s.Intn(3) {
+	case 0:
+		// The conversion and arithmetic must be one expression.
+		f.line("p := (*%s)(unsafe.Pointer(uintptr(unsafe.Pointer(&arr[0])) + uintptr(i)*unsafe.Sizeof(arr[0])))", typ)
+	case 1:
+		f.line("p := (*%s)(unsafe.Add(unsafe.Pointer(&arr[0]), i*int(unsafe.Sizeof(arr[0]))))", typ)
+	default:
+		f.line("p := (*%s)(unsafe.Add(unsafe.Pointer(unsafe.SliceData(arr[:])), uintptr(i)*unsafe.Sizeof(arr[i%%len(arr)])))", typ)
+	}
+	f.line("_ = *p")
+	f.close("}")
+	f.close("}")
+	return fn
+}
+
+func unsafeSliceString(s *gen.State, f *file) string {
+	fn := s.Fresh("views")
+	f.open("func %s() {", fn)
+	switch s.Intn(4) {
+	case 0:
+		f.line("b := []byte(%q)", gen.Pick(s, "hello, unsafe", "", "\xff\xfe", "日本"))
+		f.line("str := unsafe.String(unsafe.SliceData(b), len(b))")
+		f.line("bs := unsafe.Slice(unsafe.StringData(str), len(str))")
+		f.line("println(str, len(bs), cap(bs))")
+	case 1:
+		// Zero-length views of nil are allowed.
+		f.line("var np *int")
+		f.line("println(len(unsafe.Slice(np, 0)), unsafe.String((*byte)(nil), 0) == \"\", unsafe.SliceData([]int(nil)) == nil)")
+	case 2:
+		f.line("arr := [8]uint16{1, 2, 3}")
+		f.line("half := unsafe.Slice(&arr[%d], %s)", s.Intn(4), gen.Pick(s, "4", "len(arr)/2", "uint8(4)", "int64(2)"))
+		f.line("half[0] = 9")
+		f.line("println(arr[0], len(half))")
+	default:
+		// Dangerous: the slice runs past the end of x.
+		f.line("x := %s", gen.Pick(s, "int32(1)", "[2]byte{}", "struct{ a, b uint16 }{}"))
+		f.line("over := unsafe.Slice((*byte)(unsafe.Pointer(&x)), unsafe.Sizeof(x)+%d)", s.Range(0, 8))
+		f.line("println(len(over))")
+	}
+	f.close("}")
+	return fn
+}
+
+// unsafeRoundTrip stores a pointer as a uintptr, which vet's unsafeptr
+// check reports when the conversion back is a separate expression.
+func unsafeRoundTrip(s *gen.State, f *file) string {
+	fn := s.Fresh("roundTrip")
+	f.open("func %s() {", fn)
+	f.line("x := new([4]int)")
+	f.line("u := uintptr(unsafe.Pointer(x))")
+	if s.Chance(0.5) {
+		f.line("u += unsafe.Sizeof(x[0]) * %d", s.Intn(4))
+	}
+	if s.Chance(0.5) {
+		f.use("runtime")
+		f.line("runtime.GC()")
+	}
+	f.line("p := (*int)(unsafe.Pointer(u))")
+	f.line("println(*p)")
+	if s.Chance(0.3) {
+		f.use("runtime")
+		f.line("runtime.KeepAlive(x)")
+	}
+	f.close("}")
+	return fn
+}
+
+// unsafePun reinterprets memory as another type: same-size puns are
+// fine, larger or misaligned ones are what checkptr looks for.
+func unsafePun(s *gen.State, f *file) string {
+	fn := s.Fresh("pun")
+	f.open("func %s() {", fn)
+	switch s.Intn(5) {
+	case 0:
+		f.line("fl := %s", gen.Pick(s, "1.5", "-0.0", "1e308"))
+		f.line("bits := *(*uint64)(unsafe.Pointer(&fl))")
+		f.line("println(bits)")
+	case 1:
+		f.line("pair := struct{ lo, hi int32 }{1, 2}")
+		f.line("arr := (*[2]int32)(unsafe.Pointer(&pair))")
+		f.line("println(arr[1])")
+	case 2:
+		f.line("str := %q", gen.Pick(s, "header", "x"))
+		f.line("words := (*[2]uintptr)(unsafe.Pointer(&str))")
+		f.line("println(words[1])")
+	case 3:
+		// Dangerous: the result is larger than the value it points to.
+		f.line("small := int32(7)")
+		f.line("big := (*[4]int64)(unsafe.Pointer(&small))")
+		f.line("println(big[0])")
+	default:
+		// Dangerous: a misaligned conversion.
+		f.line("b := make([]byte, 16)")
+		f.line("w := (*uint64)(unsafe.Pointer(&b[%d]))", s.Range(1, 7))
+		f.line("*w = 1")
+	}
+	f.close("}")
+	return fn
+}
+
+// unsafeLayout uses Sizeof, Offsetof and Alignof, including as constants
+// in array lengths and on a field promoted from an embedded struct.
+func unsafeLayout(s *gen.State, f *file) string {
+	fn, t, e := s.Fresh("layout"), s.Fresh("T"), s.Fresh("E")
+	f.line("type %s struct{ e bool }", e)
+	f.blank()
+	f.open("type %s struct {", t)
+	f.line("a byte")
+	f.line("b %s", gen.Pick(s, "int64", "[0]int64", "complex128", "struct{}", "[3]byte"))
+	f.line("c %s", gen.Pick(s, "uint16", "[0]func()", "*byte", "string"))
+	f.line("%s", e)
+	f.close("}")
+	f.blank()
+	f.line("var _ [unsafe.Sizeof(%s{})]byte", t)
+	f.line("const _ = unsafe.Offsetof(%s{}.c) + unsafe.Alignof(%s{}.b)", t, t)
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("var v %s", t)
+	f.line("println(unsafe.Sizeof(v), unsafe.Offsetof(v.b), unsafe.Alignof(v), unsafe.Offsetof(v.e))")
+	f.close("}")
+	return fn
+}
+
+// unsafeEscape hides pointers from escape analysis in uintptrs. The
+// dangerous form dereferences the address of a dead stack variable.
+func unsafeEscape(s *gen.State, f *file) string {
+	fn, leak, hide := s.Fresh("escape"), s.Fresh("leak"), s.Fresh("hide")
+	f.line("//go:noinline")
+	f.line("func %s() unsafe.Pointer { x := %d; return unsafe.Pointer(&x) }", leak, s.Intn(100))
+	f.blank()
+	f.line("//go:noinline")
+	f.line("func %s() uintptr { x := %d; return uintptr(unsafe.Pointer(&x)) }", hide, s.Intn(100))
+	f.blank()
+	f.open("func %s() {", fn)
+	f.line("println(*(*int)(%s()))", leak)
+	if s.Chance(0.3) {
+		f.line("println(*(*int)(unsafe.Pointer(%s())))", hide)
+	} else {
+		f.line("println(%s() != 0)", hide)
+	}
+	f.close("}")
+	return fn
+}
+
+// runtimeLinknames are runtime functions that the runtime marks as
+// reachable by //go:linkname from other packages, with their signatures
+// and a call.
+var runtimeLinknames = []struct{ name, sig, call string }{
+	{"nanotime", "() int64", "println(%s() > 0)"},
+	{"cputicks", "() int64", "println(%s())"},
+	{"fastrand", "() uint32", "println(%s())"},
+	{"fastrandn", "(n uint32) uint32", "println(%s(10))"},
+	{"fastrand64", "() uint64", "println(%s())"},
+	{"cheaprand", "() uint32", "println(%s())"},
+	{"memhash", "(p unsafe.Pointer, h, s uintptr) uintptr", "x := 42; println(%s(unsafe.Pointer(&x), 0, unsafe.Sizeof(x)))"},
+	{"strhash", "(p unsafe.Pointer, h uintptr) uintptr", "str := \"k\"; println(%s(unsafe.Pointer(&str), 1))"},
+	{"procPin", "() int", "println(%s())"},
+	{"noescape", "(p unsafe.Pointer) unsafe.Pointer", "x := 1; println(*(*int)(%s(unsafe.Pointer(&x))))"},
+	{"memmove", "(to, from unsafe.Pointer, n uintptr)", "a, b := [4]byte{1}, [4]byte{}; %s(unsafe.Pointer(&b), unsafe.Pointer(&a), 4); println(b[0])"},
+	{"memclrNoHeapPointers", "(p unsafe.Pointer, n uintptr)", "a := [8]byte{1}; %s(unsafe.Pointer(&a), 8); println(a[0])"},
+	{"mallocgc", "(size uintptr, typ unsafe.Pointer, needzero bool) unsafe.Pointer", "println(%s(16, nil, true) != nil)"},
+}
+
+// unsafeLinkname pulls a runtime function in with //go:linkname.
+func unsafeLinkname(s *gen.State, f *file) string {
+	fn := s.Fresh("linked")
+	r := gen.Pick(s, runtimeLinknames...)
+	local := s.Fresh(r.name)
+	f.line("//go:linkname %s runtime.%s", local, r.name)
+	f.line("func %s%s", local, r.sig)
+	f.blank()
+	unpin := ""
+	if r.name == "procPin" {
+		unpin = s.Fresh("procUnpin")
+		f.line("//go:linkname %s runtime.procUnpin", unpin)
+		f.line("func %s()", unpin)
+		f.blank()
+	}
+	f.open("func %s() {", fn)
+	for _, l := range strings.Split(fmt.Sprintf(r.call, local), "; ") {
+		f.line("%s", l)
+	}
+	if unpin != "" {
+		f.line("%s()", unpin)
+	}
+	f.close("}")
+	return fn
+}
+
+// badUnsafe emits a use of package unsafe that must be rejected at
+// compile or link time.
+func badUnsafe(s *gen.State, f *file) {
+	switch s.Intn(6) {
+	case 0:
+		f.line("var _ = unsafe.Slice(new(int), -1)")
+	case 1:
+		v := s.Fresh("bad")
+		f.line("var %s int", v)
+		f.line("var _ = unsafe.Offsetof(%s)", v)
+	case 2:
+		f.line("var _ = unsafe.Add(nil, 1.5)")
+	case 3:
+		f.line("var _ = unsafe.String(new(byte), \"n\")")
+	case 4:
+		// The runtime does not mark gcount as linkable.
+		local := s.Fresh("gcount")
+		f.line("//go:linkname %s runtime.gcount", local)
+		f.line("func %s() int32", local)
+		f.line("func init() { %s() }", local)
+	default:
+		f.line("var _ = unsafe.Sizeof(unsafe.Pointer)")
+	}
+}
@@ -0,0 +1,591 @@
+// Package htmlsrc generates HTML seeds. It registers the "html/..."
+// generators with package gen.
+//
+// The HTML5 tree construction algorithm has dozens of insertion modes,
+// and most of what it does that is interesting happens when markup is
+// wrong: formatting elements closed out of order go through the adoption
+// agency, content in a table is foster parented out of it, a tag in SVG
+// or MathML breaks back out into HTML, and a template switches modes by
+// its first child. A document here is a sequence of such constructs,
+// nested in each other, among text thick with character references.
+package htmlsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "html/doc",
+		Doc:  "HTML documents: misnested formatting and block elements, tables with foster-parented content, SVG and MathML with integration points and breakout tags, templates in every insertion mode, raw text elements, comments, doctypes for each quirks mode, and named and numeric character references with and without semicolons",
+		Func: doc,
+	})
+}
+
+// A hdoc accumulates an HTML document.
+type hdoc struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &hdoc{s: s}
+	if s.Chance(0.7) {
+		d.doctype()
+	}
+	if s.Chance(0.5) {
+		d.write("<html%s>", d.attrs())
+	}
+	if s.Chance(0.6) {
+		d.head()
+	}
+	if s.Chance(0.2) {
+		d.b.WriteString(gen.Pick(s, "\n", "<!-- between -->", " x ", "</head>", "<meta charset=utf-8>", "<style>p{}</style>"))
+	}
+	depth := s.Depth(s.Limits.Block, 4)
+	switch {
+	case s.Chance(0.03):
+		d.frameset()
+	case s.Chance(0.05):
+		// Nesting deeper than the open element stack ought to go, which
+		// the adoption agency walks for each misnested end tag.
+		n := s.Depth(s.Limits.Block, 600)
+		open := gen.Pick(s, "<div>", "<b>", "<span>", "<a>", "<i><b>", "<table><tr><td>", "<svg><g>", "<math><mi>")
+		d.b.WriteString(strings.Repeat(open, n))
+		d.text()
+		if s.Chance(0.5) {
+			d.b.WriteString(strings.Repeat(gen.Pick(s, "</b>", "</a>", "</div>", "</p>", "</td>"), n))
+		}
+	default:
+		if s.Chance(0.6) {
+			d.write("<body%s>", d.attrs())
+		}
+		d.flow(depth)
+	}
+	if s.Chance(0.3) {
+		d.b.WriteString(gen.Pick(s, "</body>", "</body></html>", "</html>", "</body></html>\n"))
+		if s.Chance(0.3) {
+			// Content after the body or the document, which goes back into
+			// the body, or a comment, which does not.
+			d.b.WriteString(gen.Pick(s, "<!-- after -->", "text", "<p>p", "<body x=y>", "<html a=b>", "</html>", "\n", "<frameset>"))
+		}
+	}
+	return []gen.File{{Name: "doc.html", Data: []byte(d.b.String())}}
+}
+
+func (d *hdoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+// doctype writes a doctype putting the document in no-quirks, limited
+// quirks or quirks mode, or a malformed one.
+func (d *hdoc) doctype() {
+	d.b.WriteString(gen.Pick(d.s,
+		"<!DOCTYPE html>", "<!DOCTYPE html>", "<!doctype html>", "<!DOCTYPE HTML>\n",
+		`<!DOCTYPE html SYSTEM "about:legacy-compat">`,
+		`<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN">`,
+		`<!DOCTYPE HTML PUBLIC "-//W3C//DTD HTML 4.01 Transitional//EN" "http://www.w3.org/TR/html4/loose.dtd">`,
+		`<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "http://www.w3.org/TR/xhtml1/DTD/xhtml1-strict.dtd">`,
+		`<!DOCTYPE html PUBLIC "-//W3O//DTD W3 HTML Strict 3.0//EN//">`,
+		`<!DOCTYPE html PUBLIC '' ''>`, `<!DOCTYPE html PUBLIC "a'b" 'c"d'>`, `<!DOCTYPE html SYSTEM "x`,
+		"<!DOCTYPE>", "<!DOCTYPE >", "<!DOCTYPEhtml>", "<!DOCTYPE html x>", "<!DOCTYPE html PUBLIC>", "<!DOCTYPE \x00>",
+	))
+}
+
+// head writes a head element of metadata, some of which only belongs in
+// the body.
+func (d *hdoc) head() {
+	if d.s.Chance(0.8) {
+		d.write("<head%s>", d.attrs())
+	}
+	for range d.s.Range(0, 4) {
+		switch d.s.Intn(8) {
+		case 0:
+			d.write("<title>%s</title>", d.chars())
+		case 1:
+			d.b.WriteString(gen.Pick(d.s, `<meta charset="utf-8">`, `<meta http-equiv="Content-Type" content="text/html; charset=windows-1252">`, `<base href="/x/">`, `<link rel=stylesheet href=s.css>`))
+		case 2:
+			d.rawText()
+		case 3:
+			d.template(1)
+		case 4:
+			d.write("<noscript>%s</noscript>", gen.Pick(d.s, "<link rel=x>", "<p>p", "x", "<style></style>", "</head>"))
+		case 5:
+			d.comment()
+		case 6:
+			// What does not belong in a head closes it, and an end tag
+			// that does not belong there is ignored.
+			d.b.WriteString(gen.Pick(d.s, "<p>p", "x", "</br>", "</p>", "<head>", "</html>", "<body>", "</template>", "<frameset>"))
+		default:
+			d.b.WriteString(gen.Pick(d.s, " ", "\n", "\t", "\f", "\r\n"))
+		}
+	}
+	if d.s.Chance(0.8) {
+		d.b.WriteString("</head>")
+	}
+}
+
+// flow writes a run of body content.
+func (d *hdoc) flow(depth int) {
+	for range d.s.Range(1, 5) {
+		if depth <= 0 {
+			d.text()
+			continue
+		}
+		switch d.s.Intn(12) {
+		case 0, 1:
+			d.element(depth - 1)
+		case 2, 3:
+			d.misnested(depth - 1)
+		case 4:
+			d.table(depth - 1)
+		case 5:
+			d.foreign(depth - 1)
+		case 6:
+			d.template(depth - 1)
+		case 7:
+			d.rawText()
+		case 8:
+			d.comment()
+		case 9:
+			d.b.WriteString(gen.Pick(d.s, "<br>", "<hr>", "<img src=x>", "<input>", "<wbr>", "<br/>", "<img/>", "<embed>", "<area>", "<keygen>", "<image src=x>", "<param>", "<source>", "<track>"))
+		default:
+			d.text()
+		}
+	}
+}
+
+// elements are ordinary elements, block and inline.
+var elements = []string{
+	"div", "p", "span", "ul", "ol", "li", "dl", "dt", "dd", "h1", "h2", "h6", "pre", "listing", "blockquote",
+	"section", "article", "main", "nav", "address", "center", "details", "summary", "figure", "fieldset",
+	"label", "button", "select", "option", "optgroup", "ruby", "rb", "rt", "rp", "rtc", "dialog", "menu",
+	"x-custom", "foo", "applet", "marquee", "object",
+}
+
+// formatting are the elements the adoption agency reconstructs.
+var formatting = []string{"a", "b", "big", "code", "em", "font", "i", "nobr", "s", "small", "strike", "strong", "tt", "u"}
+
+// element writes a well-nested element.
+func (d *hdoc) element(depth int) {
+	name := gen.Pick(d.s, elements...)
+	if d.s.Chance(0.3) {
+		name = gen.Pick(d.s, formatting...)
+	}
+	if d.s.Chance(0.05) {
+		name = strings.ToUpper(name)
+	}
+	d.write("<%s%s>", name, d.attrs())
+	if name == "pre" || name == "listing" {
+		d.b.WriteString(gen.Pick(d.s, "", "\n", "\n\n", "\r\n"))
+	}
+	d.flow(depth)
+	if d.s.Chance(0.85) {
+		d.write("</%s>", name)
+	}
+}
+
+// misnested writes elements the parser must fix up: formatting closed out
+// of order, blocks inside formatting, elements that close each other
+// implicitly, and end tags with nothing to close.
+func (d *hdoc) misnested(depth int) {
+	switch d.s.Intn(10) {
+	case 0:
+		// Formatting elements closed in another order than opened.
+		names := make([]string, d.s.Range(2, 5))
+		for i := range names {
+			names[i] = gen.Pick(d.s, formatting...)
+			d.write("<%s%s>", names[i], d.attrs())
+			d.text()
+		}
+		if d.s.Chance(0.5) {
+			d.write("<%s>", gen.Pick(d.s, "div", "p", "table", "address", "h1", "li", "marquee", "object", "button"))
+		}
+		d.flow(depth)
+		gen.Shuffle(d.s, names)
+		for _, n := range names {
+			d.write("</%s>", n)
+			if d.s.Chance(0.3) {
+				d.text()
+			}
+		}
+	case 1:
+		// The same formatting element, with the same attributes, more
+		// than the three times the Noah's Ark clause keeps.
+		f := gen.Pick(d.s, formatting...)
+		a := d.attrs()
+		n := d.s.Range(3, 12)
+		for range n {
+			d.write("<%s%s>", f, a)
+		}
+		d.write("<%s>", gen.Pick(d.s, "p", "div", "table", "td"))
+		d.text()
+		for range d.s.Range(0, n) {
+			d.write("</%s>", f)
+		}
+	case 2:
+		// A link in a link, or a nobr in a nobr, which closes the first.
+		t := gen.Pick(d.s, "a", "nobr", "button", "form", "li", "dd", "dt", "h1", "p", "option", "select")
+		u := gen.Pick(d.s, t, t, "a", "h2", "dt", "li", "p", "optgroup", "option", "select", "input", "textarea", "keygen")
+		d.write("<%s%s>", t, d.attrs())
+		d.text()
+		d.write("<%s%s>", u, d.attrs())
+		d.flow(depth)
+		if d.s.Chance(0.5) {
+			d.write("</%s>", t)
+		}
+	case 3:
+		// A formatting element around a block that is then closed: the
+		// adoption agency moves the block out and clones the element.
+		f := gen.Pick(d.s, formatting...)
+		b := gen.Pick(d.s, "div", "p", "blockquote", "ul", "pre", "section")
+		d.write("<%s>", f)
+		d.text()
+		d.write("<%s>", b)
+		d.flow(depth)
+		d.write("</%s>", f)
+		d.text()
+		d.write("</%s>", b)
+	case 4:
+		// End tags with no element to close, some of which the parser
+		// treats as start tags.
+		for range d.s.Range(1, 3) {
+			d.b.WriteString(gen.Pick(d.s, "</p>", "</br>", "</div>", "</b>", "</a>", "</li>", "</table>", "</td>", "</tr>", "</select>", "</form>", "</template>", "</body>", "</html>", "</head>", "</svg>", "</math>", "</h3>", "</x-custom>", "</>", "</ p>", "</3>"))
+			d.text()
+		}
+	case 5:
+		// A second body or html tag, whose attributes merge into the
+		// first, or a frameset after content, which is ignored.
+		d.b.WriteString(gen.Pick(d.s, "<body class=second onload=x()>", "<html lang=fr>", "<frameset>", "<head>", "<body>"))
+		d.flow(depth)
+	case 6:
+		// Lists and headings that close each other implicitly.
+		for range d.s.Range(2, 6) {
+			d.write("<%s>", gen.Pick(d.s, "li", "dt", "dd", "h1", "h2", "p", "ul", "ol", "option", "rb", "rt", "rp", "optgroup"))
+			d.text()
+		}
+	case 7:
+		// A select, which ignores most tags and is closed by some.
+		d.write("<select%s>", d.attrs())
+		for range d.s.Range(1, 5) {
+			d.b.WriteString(gen.Pick(d.s, "<option>o", "<optgroup>", "</optgroup>", "<option selected>", "<select>", "<input>", "<textarea>t</textarea>", "<keygen>", "<div>d", "<hr>", "<b>b", "</select>", "<script>s</script>", "<template>t</template>", "<table>", "<td>", "<svg>"))
+		}
+		if d.s.Chance(0.7) {
+			d.b.WriteString("</select>")
+		}
+	case 8:
+		// A form in a form, and a form end tag that leaves elements open.
+		d.write("<form%s>", d.attrs())
+		d.text()
+		d.write("<%s>", gen.Pick(d.s, "div", "form", "table", "fieldset"))
+		d.b.WriteString(gen.Pick(d.s, "<form>", "<input>", "<button>", "</form>", "<table><form>"))
+		d.flow(depth)
+		d.b.WriteString(gen.Pick(d.s, "</form>", "</div></form>", "", "</form></form>"))
+	default:
+		// Ruby, whose annotations close each other.
+		d.b.WriteString("<ruby>")
+		for range d.s.Range(1, 4) {
+			d.write("<%s>", gen.Pick(d.s, "rb", "rt", "rtc", "rp"))
+			d.text()
+		}
+		if d.s.Chance(0.7) {
+			d.b.WriteString("</ruby>")
+		}
+	}
+}
+
+// table writes a table, with content in places content does not belong,
+// which the parser moves before the table.
+func (d *hdoc) table(depth int) {
+	d.write("<table%s>", d.attrs())
+	if d.s.Chance(0.2) {
+		d.write("<caption>%s</caption>", d.chars())
+	}
+	if d.s.Chance(0.2) {
+		d.b.WriteString(gen.Pick(d.s, "<col>", "<colgroup><col span=2></colgroup>", "<colgroup>x<col>", "<col><col>"))
+	}
+	section := d.s.Chance(0.5)
+	if section {
+		d.write("<%s>", gen.Pick(d.s, "thead", "tbody", "tfoot"))
+	}
+	for range d.s.Range(0, 3) {
+		if d.s.Chance(0.15) {
+			d.misplaced(depth)
+		}
+		if d.s.Chance(0.8) {
+			d.b.WriteString("<tr>")
+		}
+		for range d.s.Range(0, 3) {
+			if d.s.Chance(0.1) {
+				d.misplaced(depth)
+			}
+			cell := gen.Pick(d.s, "td", "td", "th")
+			d.write("<%s%s>", cell, d.attrs())
+			d.flow(depth)
+			if d.s.Chance(0.6) {
+				d.write("</%s>", cell)
+			}
+		}
+		if d.s.Chance(0.5) {
+			d.b.WriteString("</tr>")
+		}
+	}
+	if section && d.s.Chance(0.5) {
+		d.b.WriteString(gen.Pick(d.s, "</thead>", "</tbody>", "</tfoot>"))
+	}
+	if d.s.Chance(0.9) {
+		d.b.WriteString("</table>")
+	}
+}
+
+// misplaced writes what is out of place in a table.
+func (d *hdoc) misplaced(depth int) {
+	switch d.s.Intn(4) {
+	case 0:
+		d.text()
+	case 1:
+		d.element(depth)
+	case 2:
+		d.b.WriteString(gen.Pick(d.s, " ", "\n", "<input type=hidden>", "<input type=HIDDEN name=x>", "<input>", "<form>", "<form><input>", "</form>", "<table>", "</table>", "<caption>", "</caption>", "<col>", "</body>", "</br>", "</td>", "<tbody>", "</tbody>", "<select><option>", "<style></style>", "<script></script>", "<template><td></template>", "<svg>", "<math>", "<frameset>", "<html>", "<body>", "<p>", "</p>"))
+	default:
+		d.comment()
+	}
+}
+
+// svgElements are SVG elements, some of whose names the parser adjusts
+// to camel case.
+var svgElements = []string{"g", "path", "circle", "rect", "text", "tspan", "use", "defs", "clipPath", "clippath", "linearGradient", "lineargradient", "feBlend", "feblend", "animateMotion", "altGlyph", "glyphRef", "textPath", "image", "a", "font", "switch"}
+
+// mathElements are MathML elements, the first five of which are text
+// integration points.
+var mathElements = []string{"mi", "mo", "mn", "ms", "mtext", "mrow", "mfrac", "msqrt", "semantics", "annotation", "malignmark", "mglyph"}
+
+// foreign writes SVG or MathML, with HTML integration points holding HTML
+// content, tags that break back out of foreign content, and the CDATA
+// sections that only foreign content has.
+func (d *hdoc) foreign(depth int) {
+	root := gen.Pick(d.s, "svg", "math")
+	d.write("<%s%s>", root, d.foreignAttrs())
+	for range d.s.Range(1, 4) {
+		switch d.s.Intn(7) {
+		case 0:
+			d.text()
+		case 1:
+			d.write("<![CDATA[%s]]>", gen.Pick(d.s, "", "x", "<b>", "]]", "&amp;", "]", "\x00", "-->"))
+		case 2:
+			// A tag that breaks out into HTML, closing the foreign
+			// elements; a font does only with a color, face or size.
+			d.b.WriteString(gen.Pick(d.s, "<p>", "<b>", "<div>", "<br>", "<table>", "<ul>", "<font color=red>", "<font face=x>", "<font>", "<span>", "<h1>", "<pre>", "<body>", "</p>", "</br>", "<center>", "<listing>", "<nobr>", "<img>"))
+			d.text()
+		case 3:
+			if depth > 0 {
+				d.integration(root, depth-1)
+			} else {
+				d.text()
+			}
+		case 4:
+			d.b.WriteString(gen.Pick(d.s, "<script>1</script>", "<style><b>x</b></style>", "<title>t<b>b</b></title>", "<svg>", "<math>", "</svg>", "</math>", "<g/>", "<path/>", "<mi/>", "<template>t</template>", "<textarea><b></textarea>", "<plaintext>"))
+		default:
+			names := svgElements
+			if root == "math" {
+				names = mathElements
+			}
+			name := gen.Pick(d.s, names...)
+			if d.s.Chance(0.3) {
+				d.write("<%s%s/>", name, d.foreignAttrs())
+				continue
+			}
+			d.write("<%s%s>", name, d.foreignAttrs())
+			d.text()
+			if d.s.Chance(0.7) {
+				d.write("</%s>", name)
+			}
+		}
+	}
+	if d.s.Chance(0.8) {
+		d.write("</%s>", root)
+	}
+}
+
+// integration writes an HTML integration point or MathML text integration
+// point holding HTML.
+func (d *hdoc) integration(root string, depth int) {
+	var name, attrs string
+	if root == "svg" {
+		name = gen.Pick(d.s, "foreignObject", "foreignobject", "desc", "title")
+	} else {
+		name = gen.Pick(d.s, "mi", "mo", "mn", "ms", "mtext", "annotation-xml")
+		if name == "annotation-xml" {
+			attrs = gen.Pick(d.s, ` encoding="text/html"`, ` encoding="TEXT/HTML"`, ` encoding="application/xhtml+xml"`, ` encoding="application/mathml+xml"`, "")
+		}
+	}
+	d.write("<%s%s>", name, attrs)
+	switch d.s.Intn(3) {
+	case 0:
+		d.foreign(depth)
+	case 1:
+		d.b.WriteString(gen.Pick(d.s, "<mglyph>", "<malignmark>", "<svg>", "<math>", "<mi>"))
+		d.text()
+	default:
+		d.flow(depth)
+	}
+	if d.s.Chance(0.7) {
+		d.write("</%s>", name)
+	}
+}
+
+// foreignAttrs returns attributes the parser adjusts in foreign content:
+// ones it puts in the XLink, XML and XMLNS namespaces, and ones it camel
+// cases.
+func (d *hdoc) foreignAttrs() string {
+	var b strings.Builder
+	for range d.s.Range(0, 2) {
+		name := gen.Pick(d.s,
+			"xlink:href", "xlink:title", "xml:lang", "xml:space", "xmlns", "xmlns:xlink", "xlink", "xml:base",
+			"viewbox", "viewBox", "definitionurl", "preserveaspectratio", "attributename", "d", "fill", "color", "id",
+		)
+		fmt.Fprintf(&b, ` %s="%s"`, name, strings.ReplaceAll(d.value(), `"`, ""))
+	}
+	return b.String()
+}
+
+// template writes a template, whose content starts in a mode its first
+// child picks, in the body, a table or a select.
+func (d *hdoc) template(depth int) {
+	wrap := gen.Pick(d.s, "", "", "table", "select", "colgroup", "tr", "frameset")
+	if wrap == "tr" {
+		d.b.WriteString("<table><tr>")
+	} else if wrap != "" {
+		d.write("<%s>", wrap)
+	}
+	d.write("<template%s>", gen.Pick(d.s, "", "", " shadowrootmode=open", " id=t", " shadowrootmode=closed"))
+	for range d.s.Range(0, 3) {
+		switch d.s.Intn(5) {
+		case 0:
+			d.b.WriteString(gen.Pick(d.s, "<tr><td>", "<td>", "<th>", "<col>", "<caption>", "<tbody>", "<colgroup>", "<frame>", "<frameset>", "<html>", "<head>", "<body>", "</template>", "</td>", "</table>", "<option>", "<li>"))
+			d.text()
+		case 1:
+			if depth > 0 {
+				d.template(depth - 1)
+			}
+		case 2:
+			if depth > 0 {
+				d.flow(depth - 1)
+			}
+		case 3:
+			d.text()
+		default:
+			d.comment()
+		}
+	}
+	if d.s.Chance(0.8) {
+		d.b.WriteString("</template>")
+	}
+	if wrap == "tr" {
+		d.b.WriteString("</table>")
+	} else if wrap != "" && d.s.Chance(0.5) {
+		d.write("</%s>", wrap)
+	}
+}
+
+// frameset writes a frameset in place of a body.
+func (d *hdoc) frameset() {
+	d.b.WriteString("<frameset>")
+	for range d.s.Range(0, 3) {
+		d.b.WriteString(gen.Pick(d.s, "<frame src=a>", "<frameset><frame></frameset>", "x", " ", "<noframes><p>n</noframes>", "<!-- c -->", "<p>", "</frameset>", "<template>", "</frame>"))
+	}
+	if d.s.Chance(0.7) {
+		d.b.WriteString("</frameset>")
+	}
+}
+
+// rawText writes an element whose content is not parsed as markup, with
+// content that looks like its end or like markup.
+func (d *hdoc) rawText() {
+	if d.s.Chance(0.02) {
+		// Plaintext runs to the end of the document.
+		d.b.WriteString("<plaintext>" + gen.Pick(d.s, "</plaintext>", "<b>", "&amp;", "x"))
+		return
+	}
+	name := gen.Pick(d.s, "script", "script", "style", "textarea", "title", "xmp", "iframe", "noembed", "noframes", "noscript")
+	d.write("<%s>", name)
+	switch name {
+	case "script":
+		d.b.WriteString(gen.Pick(d.s,
+			"x = 1", "<!--", "<!--<script>", "<!--<script></script>-->", "<!--<script>x</script>y-->", "</scrip", "</script ", "<!-- -->",
+			"if (a < b && c > d) {}", "'</'+'script>'", "<\\/script>", "<!--<SCRIPT>", "-->", "<!-- <script", "\x00", "&amp;",
+		))
+	case "textarea", "title":
+		d.b.WriteString(gen.Pick(d.s, "\n", "", "\n\n", "\r\n", ""))
+		d.text()
+		d.b.WriteString(gen.Pick(d.s, "", "<b>b</b>", "</"+name+" ", "<!--", "</ti"))
+	default:
+		d.b.WriteString(gen.Pick(d.s, "p { color: red }", "<b>b</b>", "&amp;", "<!--", "</"+name+"x>", "]]>", "\x00", "<"+name+">", "</st"))
+	}
+	d.write("</%s>", gen.Pick(d.s, name, name, strings.ToUpper(name), name+" x=y", name+"/"))
+}
+
+// comment writes a comment, or what the tokenizer reads as a bogus one.
+func (d *hdoc) comment() {
+	d.b.WriteString(gen.Pick(d.s,
+		"<!-- c -->", "<!---->", "<!-->", "<!--->", "<!-- a -- b -->", "<!-- x --!>", "<!-- x --!", "<!--<!-- -->",
+		"<!-- --!-->", "<!----->", "<!-- - -->", "<? pi ?>", "<!x>", "</ x>", "<!>", "<![CDATA[x]]>", "<!--\x00-->",
+		"<!-- <b> -->", "<!--\r\n-->",
+	))
+}
+
+// attrs returns the attributes of a start tag.
+func (d *hdoc) attrs() string {
+	var b strings.Builder
+	for range d.s.Range(0, 3) {
+		if d.s.Chance(0.6) {
+			break
+		}
+		name := gen.Pick(d.s, "id", "class", "title", "href", "src", "color", "face", "size", "type", "name", "value", "lang", "style",
+			"ID", "Class", "data-x", "x:y", "xlink:href", "on", "onclick", "a\x00b", "<", "\"", "'", "=", "a=b")
+		b.WriteString(gen.Pick(d.s, " ", " ", "\n", "\t", "/", " /"))
+		switch d.s.Intn(5) {
+		case 0:
+			b.WriteString(name)
+		case 1:
+			fmt.Fprintf(&b, "%s=%s", name, strings.NewReplacer(" ", "", ">", "", `"`, "", "'", "").Replace(d.value()))
+		case 2:
+			fmt.Fprintf(&b, "%s='%s'", name, strings.ReplaceAll(d.value(), "'", ""))
+		default:
+			fmt.Fprintf(&b, `%s="%s"`, name, strings.ReplaceAll(d.value(), `"`, ""))
+		}
+	}
+	return b.String()
+}
+
+// text writes a run of text.
+func (d *hdoc) text() {
+	for range d.s.Range(1, 3) {
+		d.b.WriteString(d.chars())
+	}
+}
+
+// chars returns a piece of text or a character reference: named ones with
+// and without their semicolons, ones that are a prefix of others, and
+// numeric ones for code points the tokenizer replaces.
+func (d *hdoc) chars() string {
+	return gen.Pick(d.s,
+		"x", "text", " ", "  ", "\n", "\r\n", "\r", "\t", "\f", "\x00", "日本語", "é", "😀", "a b", "\ufeff", "<", ">", "<>", "< b", "&", "& ", "&&",
+		"&amp;", "&amp", "&AMP", "&AMP;", "&lt;", "&lt", "&LT", "&gt;", "&quot;", "&nbsp;", "&nbsp", "&copy", "&copy;", "&not", "&notin;", "&notit;",
+		"&notin", "&NotNestedGreaterGreater;", "&nGt;", "&acE;", "&bogus;", "&ampx;", "&amp;amp;", "&;", "&#", "&#;", "&#x;", "&#65", "&#65;",
+		"&#x41;", "&#X41;", "&#x41g", "&#0;", "&#13;", "&#x80;", "&#x9F;", "&#x8A;", "&#xD800;", "&#xDFFF;", "&#x110000;", "&#99999999999;",
+		"&#xFFFE;", "&#x10FFFF;", "&#x1F600;", "&#0000065;", "&#x0000000041;",
+	)
+}
+
+// value returns an attribute value, whose character references the
+// tokenizer reads differently from those in text.
+func (d *hdoc) value() string {
+	return gen.Pick(d.s,
+		"", "x", "a b", "red", "#fff", "1", "-1", "javascript:x()", "M0 0L1 1", "text/html", "http://www.w3.org/1999/xlink",
+		"&amp;", "&amp=", "&copy=x", "&copy", "&notin=", "&notit", "&lt;b&gt;", "&#0;", "&#x80;", "&quot;", "'", `"`, ">", "<b>", "\x00", "\n",
+	)
+}
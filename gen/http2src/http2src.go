@@ -0,0 +1,648 @@
+// Package http2src generates HTTP/2 seeds. It registers the "http2/..."
+// generators with package gen.
+//
+// "http2/client" writes what a client sends on a connection, for a
+// server to read: the preface, its SETTINGS and frames on a few streams;
+// "http2/hpack" writes one HPACK header block. Header blocks are encoded
+// by hand, against a model of the decoder's dynamic table, so that they
+// can abuse the table: size updates to nothing and back, entries that
+// fill or overflow it, and references to entries evicted or never added.
+// Frames carry padding, priorities that depend on their own stream or on
+// each other, header blocks split over floods of CONTINUATION frames
+// that now and then never end, and, rarely, lengths and stream IDs
+// wrong for their type.
+package http2src
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+	"golang.org/x/net/http2/hpack"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "http2/client",
+		Doc:  "HTTP/2 client connections: the preface, SETTINGS and frames on a few streams, with padding, priority loops, CONTINUATION floods, HPACK dynamic table abuse and malformed frames",
+		Func: client,
+	})
+	gen.Register(&gen.Generator{
+		Name: "http2/hpack",
+		Doc:  "HPACK header blocks: static and dynamic references, literals with and without indexing, Huffman strings, table size updates, evictions and malformed integers, strings and indexes",
+		Func: block,
+	})
+}
+
+// badRate is the chance that a frame is malformed. A connection has
+// about twenty frames, so about one in ten has one.
+const badRate = 0.005
+
+// badField is the chance that a header field, or one of its strings or
+// integers, is malformed, or that a block resizes the table out of
+// bounds. A connection has a few dozen fields, and a field that does not
+// decode ends it, so this is lower than badRate.
+const badField = 0.001
+
+// Frame types and flags.
+const (
+	frameData         = 0x0
+	frameHeaders      = 0x1
+	framePriority     = 0x2
+	frameRSTStream    = 0x3
+	frameSettings     = 0x4
+	framePushPromise  = 0x5
+	framePing         = 0x6
+	frameGoAway       = 0x7
+	frameWindowUpdate = 0x8
+	frameContinuation = 0x9
+	framePriorityUpd  = 0x10
+
+	flagEndStream  = 0x1
+	flagAck        = 0x1
+	flagEndHeaders = 0x4
+	flagPadded     = 0x8
+	flagPriority   = 0x20
+)
+
+// preface is what a client sends before its first frame.
+const preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// tableSize is the dynamic table size decoders start with and, since
+// the client cannot raise what the server's decoder allows, the largest
+// a size update may ask for.
+const tableSize = 4096
+
+// static is HPACK's static table; the field at index i is static[i-1].
+var static = [][2]string{
+	{":authority", ""}, {":method", "GET"}, {":method", "POST"}, {":path", "/"}, {":path", "/index.html"},
+	{":scheme", "http"}, {":scheme", "https"}, {":status", "200"}, {":status", "204"}, {":status", "206"},
+	{":status", "304"}, {":status", "400"}, {":status", "404"}, {":status", "500"}, {"accept-charset", ""},
+	{"accept-encoding", "gzip, deflate"}, {"accept-language", ""}, {"accept-ranges", ""}, {"accept", ""},
+	{"access-control-allow-origin", ""}, {"age", ""}, {"allow", ""}, {"authorization", ""}, {"cache-control", ""},
+	{"content-disposition", ""}, {"content-encoding", ""}, {"content-language", ""}, {"content-length", ""},
+	{"content-location", ""}, {"content-range", ""}, {"content-type", ""}, {"cookie", ""}, {"date", ""},
+	{"etag", ""}, {"expect", ""}, {"expires", ""}, {"from", ""}, {"host", ""}, {"if-match", ""},
+	{"if-modified-since", ""}, {"if-none-match", ""}, {"if-range", ""}, {"if-unmodified-since", ""},
+	{"last-modified", ""}, {"link", ""}, {"location", ""}, {"max-forwards", ""}, {"proxy-authenticate", ""},
+	{"proxy-authorization", ""}, {"range", ""}, {"referer", ""}, {"refresh", ""}, {"retry-after", ""},
+	{"server", ""}, {"set-cookie", ""}, {"strict-transport-security", ""}, {"transfer-encoding", ""},
+	{"user-agent", ""}, {"vary", ""}, {"via", ""}, {"www-authenticate", ""},
+}
+
+var (
+	methods = []string{"GET", "GET", "GET", "POST", "POST", "PUT", "HEAD", "DELETE", "OPTIONS", "PATCH"}
+	paths   = []string{"/", "/", "/index.html", "/a/b/c", "/search?q=h2&lang=en", "/api/v1/items/42", "/%2e%2e/etc/passwd", "/" + strings.Repeat("p", 300)}
+	hosts   = []string{"example.com", "example.com:8443", "127.0.0.1", "[::1]:443", "xn--r8jz45g.jp"}
+	agents  = []string{"curl/8.5.0", "Mozilla/5.0 (X11; Linux x86_64)", "Go-http-client/2.0", "grpc-go/1.60.0"}
+	// bad are fields a header list may not have: uppercase and empty
+	// names, connection-specific fields, TE other than trailers, pseudo
+	// fields unknown or out of place, and values with line breaks or
+	// NULs.
+	bad = [][2]string{
+		{"Content-Type", "text/plain"}, {"", "empty"}, {"connection", "keep-alive"}, {"keep-alive", "timeout=5"},
+		{"transfer-encoding", "chunked"}, {"te", "gzip"}, {"upgrade", "h2c"}, {":unknown", "x"}, {":status", "200"},
+		{":path", ""}, {"x-a", "a\r\nb: c"}, {"x-a", "\x00"}, {"x a", "b"}, {"x-a", " padded "}, {"x-a\x7f", "b"},
+	}
+)
+
+// A field is an entry of the decoder's dynamic table.
+type field struct {
+	name, value string
+}
+
+// size is the size RFC 7541 gives an entry.
+func (f field) size() int { return len(f.name) + len(f.value) + 32 }
+
+// An hgen encodes header blocks against a model of the decoder's
+// dynamic table, which lasts for the connection.
+type hgen struct {
+	s     *gen.State
+	table []field // newest first
+	max   int
+}
+
+func newHgen(s *gen.State) *hgen {
+	return &hgen{s: s, max: tableSize}
+}
+
+// add adds f to the table, evicting the oldest entries to fit it; an
+// entry larger than the table empties it.
+func (h *hgen) add(f field) {
+	h.table = slices.Insert(h.table, 0, f)
+	h.evict()
+}
+
+func (h *hgen) evict() {
+	used := 0
+	for i, f := range h.table {
+		if used += f.size(); used > h.max {
+			h.table = h.table[:i]
+			return
+		}
+	}
+}
+
+// varint appends i as an integer with an n-bit prefix, the bits above
+// which are flags, now and then padded with redundant zero groups.
+func (h *hgen) varint(b []byte, flags byte, n uint, i int) []byte {
+	limit := 1<<n - 1
+	if i < limit {
+		return append(b, flags|byte(i))
+	}
+	b = append(b, flags|byte(limit))
+	for i -= limit; i >= 0x80; i >>= 7 {
+		b = append(b, byte(i)|0x80)
+	}
+	if h.s.Chance(badField) {
+		b = append(b, byte(i)|0x80)
+		for range gen.Pick(h.s, 0, 1, 8) {
+			b = append(b, 0x80)
+		}
+		i = 0
+	}
+	return append(b, byte(i))
+}
+
+// str appends a string literal, Huffman-coded half the time. Now and
+// then its length runs past its end or its Huffman code is padded with
+// more than seven bits or holds the EOS symbol.
+func (h *hgen) str(b []byte, v string) []byte {
+	s := h.s
+	if s.Chance(badField) {
+		switch s.Intn(3) {
+		case 0:
+			b = h.varint(b, 0, 7, len(v)+gen.Pick(s, 1, 64))
+			return append(b, v...)
+		case 1:
+			code := append(hpack.AppendHuffmanString(nil, v), 0xff, 0xff)
+			b = h.varint(b, 0x80, 7, len(code))
+			return append(b, code...)
+		default:
+			code := append(hpack.AppendHuffmanString(nil, v), 0xff, 0xff, 0xff, 0xfc)
+			b = h.varint(b, 0x80, 7, len(code))
+			return append(b, code...)
+		}
+	}
+	if s.Chance(0.5) {
+		b = h.varint(b, 0x80, 7, int(hpack.HuffmanEncodeLength(v)))
+		return hpack.AppendHuffmanString(b, v)
+	}
+	b = h.varint(b, 0, 7, len(v))
+	return append(b, v...)
+}
+
+// sizeUpdate appends a dynamic table size update to v.
+func (h *hgen) sizeUpdate(b []byte, v int) []byte {
+	b = h.varint(b, 0x20, 5, v)
+	if v <= tableSize {
+		h.max = v
+		h.evict()
+	}
+	return b
+}
+
+// encode returns fields as a header block: indexed when the static or
+// dynamic table has them most of the time, and otherwise as literals,
+// with and without indexing and never indexed, naming an indexed name
+// when there is one. Now and then the block starts by resizing the
+// table, or refers to an index past its end.
+func (h *hgen) encode(fields [][2]string) []byte {
+	s := h.s
+	var b []byte
+	switch {
+	case s.Chance(0.05):
+		// Evict everything and start again, as an encoder that wants a
+		// clean table does.
+		b = h.sizeUpdate(b, 0)
+		b = h.sizeUpdate(b, tableSize)
+	case s.Chance(0.05):
+		b = h.sizeUpdate(b, gen.Pick(s, 0, 32, 256, 1024, tableSize))
+	case s.Chance(badField):
+		b = h.sizeUpdate(b, gen.Pick(s, tableSize+1, 1<<16, 1<<31))
+	}
+	for i, f := range fields {
+		if i > 0 && s.Chance(badField) {
+			// Size updates must come first.
+			b = h.sizeUpdate(b, gen.Pick(s, 0, tableSize))
+		}
+		if s.Chance(badField) {
+			b = h.varint(b, 0x80, 7, gen.Pick(s, 0, len(static)+len(h.table)+1, len(static)+len(h.table)+100, 1<<20))
+			continue
+		}
+		name, pair := 0, 0
+		for j, e := range static {
+			if e[0] == f[0] {
+				name = first(name, j+1)
+				if e[1] == f[1] {
+					pair = j + 1
+				}
+			}
+		}
+		for j, e := range h.table {
+			if e.name == f[0] {
+				name = first(name, len(static)+j+1)
+				if e.value == f[1] && pair == 0 {
+					pair = len(static) + j + 1
+				}
+			}
+		}
+		if pair != 0 && s.Chance(0.8) {
+			b = h.varint(b, 0x80, 7, pair)
+			continue
+		}
+		if name != 0 && s.Chance(0.2) {
+			name = 0
+		}
+		switch s.Intn(10) {
+		case 0, 1, 2, 3, 4:
+			b = h.varint(b, 0x40, 6, name)
+			h.add(field{f[0], f[1]})
+		case 5, 6, 7:
+			b = h.varint(b, 0x00, 4, name)
+		default:
+			b = h.varint(b, 0x10, 4, name)
+		}
+		if name == 0 {
+			b = h.str(b, f[0])
+		}
+		b = h.str(b, f[1])
+	}
+	return b
+}
+
+// first returns the first non-zero of a and b.
+func first(a, b int) int {
+	if a != 0 {
+		return a
+	}
+	return b
+}
+
+// request returns the header list of a request: its pseudo-header
+// fields and a few regular ones, which repeat across streams so that the
+// dynamic table has them, and now and then fields large enough to fill
+// the table or to overflow it, or one a list may not have.
+func (h *hgen) request() [][2]string {
+	s := h.s
+	method := gen.Pick(s, methods...)
+	var fields [][2]string
+	switch {
+	case s.Chance(0.05):
+		fields = [][2]string{{":method", "CONNECT"}, {":authority", gen.Pick(s, hosts...)}}
+	case s.Chance(0.03):
+		// An extended CONNECT, as WebSockets over HTTP/2 use.
+		fields = [][2]string{{":method", "CONNECT"}, {":protocol", "websocket"}, {":scheme", "https"}, {":authority", gen.Pick(s, hosts...)}, {":path", "/chat"}}
+	default:
+		fields = [][2]string{{":method", method}, {":scheme", gen.Pick(s, "https", "https", "http")}, {":authority", gen.Pick(s, hosts...)}, {":path", gen.Pick(s, paths...)}}
+		if s.Chance(0.3) {
+			gen.Shuffle(s, fields)
+		}
+	}
+	fields = append(fields, [2]string{"user-agent", gen.Pick(s, agents...)})
+	for range s.Range(0, 4) {
+		switch s.Intn(8) {
+		case 0:
+			fields = append(fields, [2]string{"accept", gen.Pick(s, "*/*", "text/html", "application/json")})
+		case 1:
+			fields = append(fields, [2]string{"accept-encoding", gen.Pick(s, "gzip, deflate", "gzip, deflate, br", "identity")})
+		case 2:
+			// Cookies may be split over fields, to index them apart.
+			for i := range s.Range(1, 4) {
+				fields = append(fields, [2]string{"cookie", fmt.Sprintf("c%d=%s", i, strings.Repeat("v", gen.Pick(s, 1, 16, 200)))})
+			}
+		case 3:
+			fields = append(fields, [2]string{"content-type", gen.Pick(s, "application/grpc", "application/json", "text/plain; charset=utf-8")})
+		case 4:
+			fields = append(fields, [2]string{"te", "trailers"})
+		case 5:
+			fields = append(fields, [2]string{"x-request-id", fmt.Sprintf("%016x", s.Uint64())})
+		case 6:
+			// A value whose entry fills the table exactly, or overflows
+			// and so empties it, or evicts most of it.
+			fields = append(fields, [2]string{"x-big", strings.Repeat("b", gen.Pick(s, tableSize-32-5, tableSize-32-4, 1000, 2000))})
+		default:
+			fields = append(fields, [2]string{"authorization", "Bearer " + strings.Repeat("t", gen.Pick(s, 8, 64, 512))})
+		}
+	}
+	if s.Chance(badRate * 4) {
+		f := gen.Pick(s, bad...)
+		fields = slices.Insert(fields, s.Intn(len(fields)+1), f)
+	}
+	return fields
+}
+
+// A cgen writes the frames of a client connection.
+type cgen struct {
+	s *gen.State
+	h *hgen
+	b []byte
+}
+
+// frame appends a frame, now and then with a length that is wrong for
+// its payload or the reserved bit of its stream ID set.
+func (c *cgen) frame(typ, flags byte, stream uint32, payload []byte) {
+	s := c.s
+	n := len(payload)
+	if s.Chance(badRate) {
+		n = gen.Pick(s, n+1, max(n-1, 0), 0, 1<<14+1)
+	}
+	if s.Chance(badRate) {
+		stream |= 1 << 31
+	}
+	c.header(n, typ, flags, stream)
+	c.b = append(c.b, payload...)
+}
+
+// raw appends a frame as it is, for frames that come in floods.
+func (c *cgen) raw(typ, flags byte, stream uint32, payload []byte) {
+	c.header(len(payload), typ, flags, stream)
+	c.b = append(c.b, payload...)
+}
+
+func (c *cgen) header(n int, typ, flags byte, stream uint32) {
+	c.b = append(c.b, byte(n>>16), byte(n>>8), byte(n), typ, flags, byte(stream>>24), byte(stream>>16), byte(stream>>8), byte(stream))
+}
+
+// pad pads payload a tenth of the time, now and then with a pad length
+// longer than the payload, and reports whether the padding is sound.
+func (c *cgen) pad(flags byte, payload []byte) (byte, []byte, bool) {
+	s := c.s
+	if !s.Chance(0.1) {
+		return flags, payload, true
+	}
+	n := gen.Pick(s, 0, 1, 7, 255)
+	padded := append([]byte{byte(n)}, payload...)
+	padded = append(padded, make([]byte, n)...)
+	if s.Chance(badRate * 4) {
+		padded[0] = 255
+		return flags | flagPadded, padded[:min(len(padded), 255)], false
+	}
+	return flags | flagPadded, padded, true
+}
+
+// priority returns the five bytes of a priority for stream: on the
+// root, on another stream, now and then exclusively, or on itself.
+func (c *cgen) priority(stream uint32) []byte {
+	s := c.s
+	dep := gen.Pick(s, 0, 0, 1, stream-2, stream+2, stream)
+	if dep > 1<<31 {
+		dep = 0
+	}
+	if s.Chance(0.2) {
+		dep |= 1 << 31
+	}
+	return []byte{byte(dep >> 24), byte(dep >> 16), byte(dep >> 8), byte(dep), byte(gen.Pick(s, 15, 15, 0, 255, 219))}
+}
+
+// headers writes a header block on stream, most of the time in a single
+// HEADERS frame and otherwise split over CONTINUATION frames, or over a
+// flood of them, tiny and empty, that now and then never ends. It
+// reports whether the block ended.
+func (c *cgen) headers(stream uint32, fields [][2]string, end bool) bool {
+	s := c.s
+	table, size := slices.Clone(c.h.table), c.h.max
+	block := c.h.encode(fields)
+	flags := byte(0)
+	if end {
+		flags |= flagEndStream
+	}
+	var prio []byte
+	if s.Chance(0.15) {
+		flags |= flagPriority
+		prio = c.priority(stream)
+	}
+	var frags [][]byte
+	ended := true
+	switch {
+	case s.Chance(0.05):
+		for i := range block {
+			frags = append(frags, block[i:i+1])
+		}
+		for range s.Range(16, 512) {
+			frags = append(frags, nil)
+		}
+		ended = !s.Chance(0.3)
+	case s.Chance(0.1):
+		for len(block) > 0 {
+			n := min(len(block), s.Range(0, 64))
+			frags, block = append(frags, block[:n]), block[n:]
+		}
+		frags = append(frags, block)
+	default:
+		frags = [][]byte{block}
+	}
+	if ended && len(frags) == 1 {
+		flags |= flagEndHeaders
+	}
+	flags, payload, ok := c.pad(flags, append(prio, frags[0]...))
+	if !ok {
+		// The decoder never sees a block whose HEADERS frame is
+		// rejected.
+		c.h.table, c.h.max = table, size
+	}
+	c.frame(frameHeaders, flags, stream, payload)
+	// Anything but a CONTINUATION of the same stream in the middle of a
+	// header block is a connection error.
+	interrupt := -1
+	if s.Chance(badRate * 2) {
+		interrupt = s.Intn(len(frags))
+	}
+	for i, frag := range frags[1:] {
+		flags := byte(0)
+		if ended && i == len(frags)-2 {
+			flags = flagEndHeaders
+		}
+		if i == interrupt {
+			if s.Chance(0.5) {
+				c.raw(framePing, 0, 0, make([]byte, 8))
+			} else {
+				stream += 2
+			}
+		}
+		c.raw(frameContinuation, flags, stream, frag)
+	}
+	return ended
+}
+
+// settings returns a SETTINGS payload: the usual settings and values,
+// now and then ones out of range, unknown identifiers and duplicates.
+func (c *cgen) settings() []byte {
+	s := c.s
+	var b []byte
+	set := func(id int, v uint32) {
+		b = append(b, byte(id>>8), byte(id), byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	}
+	for range s.Range(0, 6) {
+		switch s.Intn(10) {
+		case 0:
+			set(1, gen.Pick[uint32](s, 0, 4096, 65536))
+		case 1:
+			set(2, gen.Pick[uint32](s, 0, 0, 1))
+		case 2:
+			set(3, gen.Pick[uint32](s, 100, 1000, 0, 1<<31))
+		case 3:
+			set(4, gen.Pick[uint32](s, 65535, 1<<20, 0, 1<<31-1))
+		case 4:
+			set(5, gen.Pick[uint32](s, 16384, 1<<20, 1<<24-1))
+		case 5:
+			set(6, gen.Pick[uint32](s, 8192, 16384, 1<<20, 0))
+		case 6:
+			set(8, 1) // SETTINGS_ENABLE_CONNECT_PROTOCOL
+		case 7:
+			set(9, 1) // SETTINGS_NO_RFC7540_PRIORITIES
+		default:
+			set(gen.Pick(s, 0x0a0a, 0x1a1a, 0xff, 0), uint32(s.Uint64()))
+		}
+	}
+	if s.Chance(badRate * 4) {
+		switch s.Intn(4) {
+		case 0:
+			set(2, 2)
+		case 1:
+			set(4, 1<<31)
+		case 2:
+			set(5, gen.Pick[uint32](s, 16383, 1<<24))
+		default:
+			b = append(b, 0, 1, 0)
+		}
+	}
+	return b
+}
+
+// client writes a client connection: the preface and SETTINGS, then a
+// few streams of requests, each a HEADERS block, DATA and trailers, with
+// PRIORITY frames, some of them in loops, and connection-level frames
+// between them.
+func client(s *gen.State) []gen.File {
+	c := &cgen{s: s, h: newHgen(s)}
+	if s.Chance(badRate) {
+		c.b = append(c.b, gen.Pick(s, "PRI * HTTP/2.0\r\n\r\nSM\r\n\r", "PRI * HTTP/1.1\r\n\r\nSM\r\n\r\n", "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")...)
+	} else {
+		c.b = append(c.b, preface...)
+	}
+	if s.Chance(0.95) {
+		c.frame(frameSettings, 0, 0, c.settings())
+	}
+	if s.Chance(0.5) {
+		c.frame(frameWindowUpdate, 0, 0, u32(gen.Pick[uint32](s, 1<<30-65535, 1<<24, 15663105)))
+	}
+	stream := uint32(1)
+	for range gen.Pick(s, 1, 1, 2, 3, 5) {
+		if s.Chance(badRate * 2) {
+			stream = gen.Pick(s, stream-2, stream+1, 0, 1<<31-1)
+		}
+		body := s.Chance(0.3)
+		if !c.headers(stream, c.h.request(), !body) {
+			return c.files()
+		}
+		if body {
+			for i := range s.Range(1, 3) {
+				flags := byte(0)
+				if i == 0 && s.Chance(0.7) {
+					flags = flagEndStream
+				}
+				data := []byte(strings.Repeat("d", gen.Pick(s, 0, 1, 10, 1000)))
+				flags, data, _ = c.pad(flags, data)
+				c.frame(frameData, flags, stream, data)
+			}
+			if s.Chance(0.1) {
+				if !c.headers(stream, [][2]string{{"grpc-status", "0"}, {"x-trailer", "t"}}, true) {
+					return c.files()
+				}
+			}
+		}
+		c.extra(stream)
+		stream += 2
+	}
+	return c.files()
+}
+
+// extra writes frames between streams: priorities, loops among them
+// included, resets, pings, settings acknowledgements, window updates,
+// GOAWAY and frames of types the framer does not know.
+func (c *cgen) extra(stream uint32) {
+	s := c.s
+	for range s.Range(0, 3) {
+		switch s.Intn(12) {
+		case 0, 1:
+			c.frame(framePriority, 0, stream, c.priority(stream))
+		case 2:
+			// A loop: each stream depends on the other.
+			p := c.priority(stream)
+			c.frame(framePriority, 0, stream, append(u32(stream+2), p[4]))
+			c.frame(framePriority, 0, stream+2, append(u32(stream), p[4]))
+		case 3:
+			c.frame(frameRSTStream, 0, stream, u32(gen.Pick[uint32](s, 0, 1, 2, 8, 0xff)))
+		case 4:
+			c.frame(framePing, gen.Pick[byte](s, 0, flagAck), 0, []byte("h2ping!!"))
+		case 5:
+			c.frame(frameSettings, flagAck, 0, nil)
+		case 6:
+			c.frame(frameWindowUpdate, 0, gen.Pick(s, 0, stream), u32(gen.Pick[uint32](s, 1, 65535, 1<<31-1)))
+		case 7:
+			c.frame(framePriorityUpd, 0, 0, append(u32(stream), gen.Pick(s, "u=0", "u=3, i", "u=7", "i=?0")...))
+		case 8:
+			c.frame(gen.Pick[byte](s, 0x0a, 0x0c, 0xff), 0, gen.Pick(s, 0, stream), []byte("unknown"))
+		case 9:
+			c.frame(frameGoAway, 0, 0, append(u32(stream), append(u32(0), gen.Pick(s, "", "bye")...)...))
+		default:
+			c.frame(frameData, 0, stream, []byte("late"))
+		}
+	}
+	if s.Chance(badRate * 4) {
+		switch s.Intn(7) {
+		case 0:
+			c.frame(framePing, 0, 0, make([]byte, gen.Pick(s, 0, 7, 9)))
+		case 1:
+			c.frame(framePriority, 0, stream, make([]byte, 4))
+		case 2:
+			c.frame(frameWindowUpdate, 0, stream, u32(0))
+		case 3:
+			c.frame(frameSettings, flagAck, 0, make([]byte, 6))
+		case 4:
+			c.frame(frameSettings, 0, stream, nil)
+		case 5:
+			c.frame(frameRSTStream, 0, 0, u32(8))
+		default:
+			// Clients may not push.
+			c.frame(framePushPromise, flagEndHeaders, stream, append(u32(stream+1), c.h.encode(c.h.request())...))
+		}
+	}
+}
+
+func (c *cgen) files() []gen.File {
+	return []gen.File{{Name: "client.h2", Data: c.b}}
+}
+
+// u32 returns v big-endian in four bytes.
+func u32(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+// block writes one header block, of a request or a response, on a
+// fresh dynamic table, which it fills to refer back to as it goes.
+func block(s *gen.State) []gen.File {
+	h := newHgen(s)
+	var fields [][2]string
+	if s.Chance(0.5) {
+		fields = h.request()
+		if s.Chance(0.3) {
+			// The same request again, which the table now has.
+			fields = append(fields, fields...)
+		}
+	} else {
+		fields = [][2]string{{":status", gen.Pick(s, "200", "200", "204", "304", "404", "500", "103")}}
+		for range s.Range(0, 6) {
+			fields = append(fields, gen.Pick(s,
+				[2]string{"content-type", "text/html; charset=utf-8"},
+				[2]string{"content-length", fmt.Sprint(s.Intn(100000))},
+				[2]string{"cache-control", "private, max-age=0"},
+				[2]string{"set-cookie", "id=" + strings.Repeat("s", gen.Pick(s, 8, 100, 1000)) + "; Secure; HttpOnly"},
+				[2]string{"server", "nginx"},
+				[2]string{"x-big", strings.Repeat("b", gen.Pick(s, tableSize-32-5, tableSize-32-4))},
+				[2]string{"date", "Mon, 01 Jan 2024 00:00:00 GMT"},
+			))
+		}
+	}
+	return []gen.File{{Name: "block.hpack", Data: h.encode(fields)}}
+}
@@ -0,0 +1,371 @@
+// Package httpsrc generates HTTP/1.x seeds. It registers the "http/..."
+// generators with package gen.
+//
+// A seed is what one side of a connection sends: "http/request" writes
+// one to three pipelined requests, "http/response" one to three
+// responses. Bodies are framed by Content-Length, by chunked
+// Transfer-Encoding, with extensions and trailers, or by the end of the
+// connection, and most messages are framed unambiguously; the rest are
+// the shapes request smuggling takes, where two parsers may disagree on
+// where a message ends: both framing headers, duplicate and obfuscated
+// ones, folded and space-padded header lines, bare line feeds, and
+// lengths that cover the start of another message.
+package httpsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "http/request",
+		Doc:  "HTTP/1.x requests, pipelined: origin, absolute, authority and asterisk forms, Content-Length and chunked bodies with extensions and trailers, folded and duplicate headers, and CL.TE, TE.CL and TE.TE smuggling shapes",
+		Func: request,
+	})
+	gen.Register(&gen.Generator{
+		Name: "http/response",
+		Doc:  "HTTP/1.x responses, pipelined: 1xx, 204 and 304 without bodies, Content-Length, chunked and close-delimited bodies, odd status lines, folded and duplicate headers, and conflicting framing",
+		Func: response,
+	})
+}
+
+// badRate is the chance that a line is malformed: a bare line feed, a
+// space before a colon, a control byte. A message has about ten lines,
+// so about one in twenty has one; smuggling shapes are drawn apart from
+// it.
+const badRate = 0.005
+
+// smuggleRate is the chance that a message is framed ambiguously.
+const smuggleRate = 0.2
+
+// An hgen writes the messages of one seed.
+type hgen struct {
+	s *gen.State
+	b strings.Builder
+}
+
+// line writes a line and its CRLF, now and then a bare LF or a CR alone.
+func (h *hgen) line(format string, args ...any) {
+	fmt.Fprintf(&h.b, format, args...)
+	if h.s.Chance(badRate) {
+		h.b.WriteString(gen.Pick(h.s, "\n", "\r", "\r\r\n", "\n\r"))
+		return
+	}
+	h.b.WriteString("\r\n")
+}
+
+// header writes a header line, now and then folded onto a second line,
+// padded with whitespace or malformed.
+func (h *hgen) header(name, value string) {
+	s := h.s
+	switch {
+	case s.Chance(0.02):
+		// An obsolete line folding, which joins the lines with a space.
+		i := s.Intn(len(value) + 1)
+		h.line("%s: %s", name, value[:i])
+		h.line("%s%s", gen.Pick(s, " ", "\t", "  "), value[i:])
+	case s.Chance(0.03):
+		h.line("%s:%s%s%s", name, gen.Pick(s, "", "  ", "\t"), value, gen.Pick(s, " ", "\t", ""))
+	case s.Chance(badRate):
+		h.line("%s", gen.Pick(s,
+			name+" : "+value,
+			name+"\t: "+value,
+			" "+name+": "+value,
+			name+": "+value+"\x00",
+			name+": \x7f"+value,
+			name+value,
+			name+"\x01: "+value,
+			":"+value,
+		))
+	default:
+		h.line("%s: %s", name, value)
+	}
+}
+
+var (
+	methods = []string{"GET", "GET", "GET", "POST", "POST", "PUT", "HEAD", "OPTIONS", "DELETE", "PATCH", "CONNECT", "TRACE", "M-SEARCH", "PROPFIND", "get", "PRI"}
+	paths   = []string{
+		"/", "/index.html", "/a/b/c", "/search?q=go&lang=en", "/%2e%2e/%2e%2e/etc/passwd", "/a%2Fb", "/;x=1",
+		"//double", "/?", "/#frag", "/caf%C3%A9", "/~user/", "/a?b=c?d", "/" + strings.Repeat("x", 200),
+	}
+	hosts    = []string{"example.com", "example.com:8080", "EXAMPLE.com", "127.0.0.1", "[::1]:80", "xn--r8jz45g.jp", "a.b.c.d.example", "example.com.", "localhost"}
+	versions = []string{"HTTP/1.1", "HTTP/1.1", "HTTP/1.1", "HTTP/1.1", "HTTP/1.0"}
+	// odd are versions the protocol has no use for, which readers may
+	// reject, accept or take for another.
+	odd = []string{"HTTP/0.9", "HTTP/2.0", "HTTP/1.10", "HTTP/01.1", "HTTP/1.1 ", "http/1.1", "HTTP/1", "HTTP/3.0", "HTTP/1.01", "HTTPS/1.1"}
+)
+
+// version returns an HTTP version, rarely an odd one.
+func (h *hgen) version() string {
+	if h.s.Chance(badRate * 4) {
+		return gen.Pick(h.s, odd...)
+	}
+	return gen.Pick(h.s, versions...)
+}
+
+// extras writes headers that do not frame the body.
+func (h *hgen) extras(request bool) {
+	s := h.s
+	for range s.Range(0, 5) {
+		switch s.Intn(14) {
+		case 0:
+			h.header("User-Agent", gen.Pick(s, "curl/8.5.0", "Mozilla/5.0 (X11; Linux x86_64)", "", "Go-http-client/1.1"))
+		case 1:
+			h.header("Accept", gen.Pick(s, "*/*", "text/html,application/xhtml+xml;q=0.9", "application/json"))
+		case 2:
+			h.header("Connection", gen.Pick(s, "keep-alive", "close", "Upgrade", "close, TE", "Keep-Alive, Transfer-Encoding", "CLOSE"))
+		case 3:
+			h.header("Content-Type", gen.Pick(s, "text/plain; charset=utf-8", "application/x-www-form-urlencoded", "multipart/form-data; boundary=x"))
+		case 4:
+			if request {
+				h.header("Expect", gen.Pick(s, "100-continue", "100-Continue", "200-ok"))
+			} else {
+				h.header("Server", gen.Pick(s, "nginx", "Apache/2.4.58", ""))
+			}
+		case 5:
+			if request {
+				h.header("Cookie", gen.Pick(s, "a=b", "a=b; c=d", "session=\"quoted\"", "=empty", "a=b;;c"))
+			} else {
+				h.header("Set-Cookie", gen.Pick(s, "a=b; Path=/; HttpOnly", "id=1; Expires=Wed, 21 Oct 2015 07:28:00 GMT", "x=\"y\""))
+			}
+		case 6:
+			h.header("X-Forwarded-For", gen.Pick(s, "203.0.113.1", "203.0.113.1, 198.51.100.2", "unknown", "[2001:db8::1]"))
+		case 7:
+			h.header("Upgrade", gen.Pick(s, "h2c", "websocket", "HTTP/2.0, SHTTP/1.3"))
+		case 8:
+			h.header("TE", gen.Pick(s, "trailers", "gzip, deflate;q=0.5", "chunked"))
+		case 9:
+			h.header("Cache-Control", gen.Pick(s, "no-cache", "max-age=0", "private, no-store"))
+		case 10:
+			h.header("Content-Encoding", gen.Pick(s, "gzip", "identity", "br, gzip"))
+		case 11:
+			h.header("X-Long", strings.Repeat(gen.Pick(s, "a", "ab,", " "), s.Range(100, 2000)))
+		case 12:
+			h.header(gen.Pick(s, "x-lower", "X-UPPER", "x-Mixed_Case", "X-Dot.Name", "1x", "X-Empty"), gen.Pick(s, "v", "", "v, w", "é", "a\tb"))
+		default:
+			h.header("Date", gen.Pick(s, "Mon, 02 Jan 2006 15:04:05 GMT", "Sunday, 06-Nov-94 08:49:37 GMT", "Sun Nov  6 08:49:37 1994", "0"))
+		}
+	}
+}
+
+// body returns n bytes of a body, now and then holding what looks like
+// the start of another message, which is where smuggled requests go.
+func (h *hgen) body(n int) string {
+	s := h.s
+	if n > 0 && s.Chance(0.2) {
+		inner := gen.Pick(s, "GET /admin HTTP/1.1\r\nHost: localhost\r\n\r\n", "0\r\n\r\n", "HTTP/1.1 200 OK\r\n\r\n", "\r\nX: y\r\n")
+		if n <= len(inner) {
+			return inner[:n]
+		}
+		return inner + strings.Repeat("b", n-len(inner))
+	}
+	return strings.Repeat(gen.Pick(s, "a", "x=1&", "{}", "\x00", "é"), n)[:n]
+}
+
+// chunked writes body as chunks: sizes in either case, with leading
+// zeros and extensions now and then, the last chunk and trailers.
+func (h *hgen) chunked(body string, trailers []string) {
+	s := h.s
+	for len(body) > 0 {
+		n := min(len(body), gen.Pick(s, 1, 3, 16, 100, len(body)))
+		size := fmt.Sprintf(gen.Pick(s, "%x", "%x", "%x", "%X", "%04x"), n)
+		if s.Chance(0.1) {
+			size += gen.Pick(s, ";ext", ";name=value", `;q="quoted;chunk"`, " ;ext", ";a=b;c", "\t;x")
+		}
+		if s.Chance(badRate * 2) {
+			size = gen.Pick(s, "0x"+size, "+"+size, " "+size, size+" ", "-"+size, "ffffffffffffffff1", size+";\x00")
+		}
+		h.line("%s", size)
+		h.b.WriteString(body[:n])
+		h.line("")
+		body = body[n:]
+	}
+	h.line("%s", gen.Pick(s, "0", "0", "0", "000", "0;ext=1"))
+	for _, t := range trailers {
+		h.header(t, gen.Pick(s, "v", "deadbeef", ""))
+	}
+	if !s.Chance(badRate * 2) {
+		h.line("")
+	}
+}
+
+// framed writes the framing headers of a message whose body is n bytes,
+// and the blank line and the body after them.
+func (h *hgen) framed(n int, request bool, version string) {
+	s := h.s
+	body := h.body(n)
+	switch {
+	case s.Chance(smuggleRate):
+		h.smuggled(body, version)
+	case n > 0 && s.Chance(0.4) && version != "HTTP/1.0":
+		var trailers []string
+		if s.Chance(0.15) {
+			trailers = gen.Pick(s, []string{"X-Checksum"}, []string{"Expires", "X-A"}, []string{"Content-Length"})
+			h.header("Trailer", strings.Join(trailers, ", "))
+		}
+		h.header("Transfer-Encoding", gen.Pick(s, "chunked", "chunked", "chunked", "Chunked", "CHUNKED"))
+		h.line("")
+		h.chunked(body, trailers)
+	case n > 0 || s.Chance(0.3):
+		h.header("Content-Length", fmt.Sprint(n))
+		h.line("")
+		h.b.WriteString(body)
+	case !request && s.Chance(0.5):
+		// A body that runs to the end of the connection.
+		h.line("")
+		h.b.WriteString(body)
+	default:
+		h.line("")
+	}
+}
+
+// smuggled writes headers that frame body two ways, or one way two
+// readers may read differently, and the body.
+func (h *hgen) smuggled(body, version string) {
+	s := h.s
+	n := len(body)
+	cl := func(v string) { h.header("Content-Length", v) }
+	te := func(v string) { h.header("Transfer-Encoding", v) }
+	switch s.Intn(8) {
+	case 0:
+		// CL.TE: a length that covers a chunked body and then some.
+		cl(fmt.Sprint(n + gen.Pick(s, 5, 7, 30)))
+		te("chunked")
+		h.line("")
+		h.chunked(body, nil)
+		h.b.WriteString("GET /smuggled HTTP/1.1\r\n")
+	case 1:
+		// TE.CL: a chunked body whose first chunk a length-reader would
+		// stop in the middle of.
+		te("chunked")
+		cl(fmt.Sprint(gen.Pick(s, 3, 4, 6)))
+		h.line("")
+		h.chunked(body+"GET /smuggled HTTP/1.1\r\nHost: localhost\r\n\r\n", nil)
+	case 2:
+		// TE.TE: a Transfer-Encoding some readers ignore.
+		te(gen.Pick(s, "chunked", "xchunked", "chunked, identity", "identity, chunked", "gzip, chunked", " chunked", "chunked\x0b", "chunk", "\"chunked\""))
+		if s.Chance(0.5) {
+			te(gen.Pick(s, "identity", "cow", "chunked"))
+		}
+		cl(fmt.Sprint(n))
+		h.line("")
+		h.b.WriteString(body)
+	case 3:
+		// Two Content-Lengths, the same or not.
+		a, b := fmt.Sprint(n), fmt.Sprint(gen.Pick(s, n, n, n+1, 0))
+		cl(a)
+		cl(b)
+		h.line("")
+		h.b.WriteString(body)
+	case 4:
+		// A Content-Length in a form some readers parse and others do not.
+		cl(gen.Pick(s, fmt.Sprintf("%d, %d", n, n), fmt.Sprintf("0%d", n), fmt.Sprintf("+%d", n), fmt.Sprintf("%d.0", n), fmt.Sprintf(" %d ", n), fmt.Sprintf("%x", n), "-1", "18446744073709551616", fmt.Sprintf("%d\x00", n)))
+		h.line("")
+		h.b.WriteString(body)
+	case 5:
+		// A framing header behind an obsolete folding, or with a space
+		// before its colon.
+		if s.Chance(0.5) {
+			h.line("Transfer-Encoding:")
+			h.line(" chunked")
+		} else {
+			h.line("Transfer-Encoding : chunked")
+		}
+		cl(fmt.Sprint(n))
+		h.line("")
+		h.b.WriteString(body)
+	case 6:
+		// Chunked in HTTP/1.0, which readers are told to ignore.
+		te("chunked")
+		if version == "HTTP/1.0" && s.Chance(0.5) {
+			cl(fmt.Sprint(n))
+		}
+		h.line("")
+		h.chunked(body, nil)
+	default:
+		// A body with a length too short or too long for it, the rest
+		// read as the start of the next message.
+		cl(fmt.Sprint(max(n+gen.Pick(s, -1, 1, -n, 10), 0)))
+		h.line("")
+		h.b.WriteString(body)
+	}
+}
+
+// request writes pipelined requests.
+func request(s *gen.State) []gen.File {
+	h := &hgen{s: s}
+	for range gen.Pick(s, 1, 1, 1, 2, 3) {
+		method := gen.Pick(s, methods...)
+		target := gen.Pick(s, paths...)
+		switch {
+		case method == "CONNECT":
+			target = gen.Pick(s, "example.com:443", "example.com", "[::1]:443", "/")
+		case method == "OPTIONS" && s.Chance(0.5):
+			target = "*"
+		case s.Chance(0.1):
+			target = gen.Pick(s, "http://", "https://", "HTTP://") + gen.Pick(s, hosts...) + target
+		}
+		if s.Chance(badRate * 4) {
+			target = gen.Pick(s, "/a b", "/\x00", "", "a", "/\xff", "/é", "*/x", "http://[::1/")
+		}
+		version := h.version()
+		if s.Chance(badRate) {
+			h.line("%s %s", method, target) // no version, as in HTTP/0.9
+		} else {
+			sep := " "
+			if s.Chance(badRate * 2) {
+				sep = gen.Pick(s, "  ", "\t", " \t")
+			}
+			h.line("%s%s%s %s", method, sep, target, version)
+		}
+		switch {
+		case s.Chance(0.85):
+			h.header(gen.Pick(s, "Host", "Host", "Host", "host", "HOST"), gen.Pick(s, hosts...))
+		case s.Chance(0.3):
+			h.header("Host", gen.Pick(s, hosts...))
+			h.header("Host", gen.Pick(s, hosts...))
+		case s.Chance(0.3):
+			h.header("Host", gen.Pick(s, "", "a b", "example.com/", "exa\x00mple", "@evil", "example.com:port"))
+		}
+		h.extras(true)
+		n := 0
+		if method != "GET" && method != "HEAD" || s.Chance(0.1) {
+			n = gen.Pick(s, 0, 1, 5, 13, 64, 300)
+		}
+		h.framed(n, true, version)
+	}
+	return []gen.File{{Name: "request.http", Data: []byte(h.b.String())}}
+}
+
+// statuses are status codes, with the reasons servers send for them.
+var statuses = []string{
+	"200 OK", "200 OK", "200 OK", "201 Created", "204 No Content", "206 Partial Content", "301 Moved Permanently",
+	"304 Not Modified", "400 Bad Request", "404 Not Found", "500 Internal Server Error", "100 Continue",
+	"101 Switching Protocols", "103 Early Hints", "299 Custom", "599 Custom",
+}
+
+// response writes pipelined responses.
+func response(s *gen.State) []gen.File {
+	h := &hgen{s: s}
+	for range gen.Pick(s, 1, 1, 1, 2, 3) {
+		version := h.version()
+		status := gen.Pick(s, statuses...)
+		if s.Chance(badRate * 4) {
+			status = gen.Pick(s, "200", "200 ", "999 Unknown", "20 Short", "2000 Long", "-1 Negative", "abc Word", "200\tTab", "099 Low", "0200 Zero")
+		}
+		h.line("%s %s", version, status)
+		h.extras(false)
+		n := gen.Pick(s, 0, 1, 5, 13, 64, 300)
+		if code := status[0]; code == '1' || strings.HasPrefix(status, "204") || strings.HasPrefix(status, "304") {
+			// No body, though a few say otherwise.
+			if !s.Chance(0.1) {
+				n = 0
+			}
+		}
+		h.framed(n, false, version)
+	}
+	return []gen.File{{Name: "response.http", Data: []byte(h.b.String())}}
+}
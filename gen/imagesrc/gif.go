@@ -0,0 +1,210 @@
+package imagesrc
+
+import (
+	"bytes"
+	"compress/lzw"
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "image/gif",
+		Doc:  "GIF files: screens from empty to 65535 square, frames placed anywhere on them with global or local color tables, every disposal method, transparent indexes in and out of the palette, interlacing, every LZW code size, and loop, comment, plain text and application extensions",
+		Func: gifSeed,
+	})
+}
+
+// gifSeed writes one GIF file.
+func gifSeed(s *gen.State) []gen.File {
+	sw, sh := size(s), size(s)
+	if s.Chance(0.03) {
+		// A screen much larger than its frames, or none at all.
+		sw, sh = gen.Pick(s, 0, 65535, 4096), gen.Pick(s, 0, 65535, 1)
+	}
+	b := []byte(gen.Pick(s, "GIF89a", "GIF89a", "GIF87a"))
+	if s.Chance(badRate) {
+		b = []byte(gen.Pick(s, "GIF88a", "GIF8", "gif89a"))
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(sw))
+	b = binary.LittleEndian.AppendUint16(b, uint16(sh))
+	global := 0
+	flags := byte(s.Intn(8)) << 4
+	if s.Chance(0.8) {
+		depth := s.Range(1, 8)
+		global = 1 << depth
+		flags |= 0x80 | byte(depth-1)
+	}
+	b = append(b, flags, byte(s.Intn(max(global, 1))), gen.Pick[byte](s, 0, 0, 49))
+	b = append(b, samples(s, 3*global)...)
+
+	if s.Chance(0.3) {
+		// The loop count, in the Netscape extension or its twin.
+		b = append(b, 0x21, 0xff, 11)
+		b = append(b, gen.Pick(s, "NETSCAPE2.0", "NETSCAPE2.0", "ANIMEXTS1.0")...)
+		b = append(b, 3, 1)
+		b = binary.LittleEndian.AppendUint16(b, gen.Pick[uint16](s, 0, 1, 5, 65535))
+		b = append(b, 0)
+	}
+
+	frames := gen.Pick(s, 1, 1, 1, 2, 3, 5, s.Range(6, 20))
+	if sw*sh > 1<<14 {
+		frames = min(frames, 2)
+	}
+	for range frames {
+		b = gifExtensions(s, b)
+		b = gifFrame(s, b, sw, sh, global)
+	}
+	if s.Chance(0.05) {
+		b = gifExtensions(s, b)
+	}
+	if !s.Chance(badRate * 2) {
+		b = append(b, 0x3b)
+	}
+	switch {
+	case s.Chance(0.02):
+		b = append(b, gen.Pick(s, "\x00", "trailing", "\x3b\x3b")...)
+	case s.Chance(badRate * 2):
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.gif", Data: b}}
+}
+
+// gifExtensions appends the extensions that may come before a frame: a
+// graphic control extension, most of the time, and now and then a
+// comment, plain text or an application extension no decoder knows.
+func gifExtensions(s *gen.State, b []byte) []byte {
+	if s.Chance(0.1) {
+		b = append(b, 0x21, 0xfe)
+		b = subBlocks(s, b, []byte(gen.Pick(s, "a comment", "", string(samples(s, 300)))))
+	}
+	if s.Chance(0.03) {
+		// Plain text, which decoders skip: a grid, cells and colors,
+		// then the text.
+		b = append(b, 0x21, 0x01, 12)
+		b = append(b, samples(s, 12)...)
+		b = subBlocks(s, b, []byte("Hello"))
+	}
+	if s.Chance(0.05) {
+		b = append(b, 0x21, 0xff)
+		app := gen.Pick(s, "XMP DataXMP", "ICCRGBG1012", "MGK8BIM0000", "ADOBE:2.0")
+		b = append(b, byte(len(app)))
+		b = append(b, app...)
+		b = subBlocks(s, b, samples(s, s.Intn(600)))
+	}
+	if s.Chance(badRate) {
+		// An extension no decoder knows.
+		b = append(b, 0x21, gen.Pick[byte](s, 0x00, 0x02, 0xfa))
+		b = subBlocks(s, b, samples(s, 3))
+	}
+	if s.Chance(0.6) {
+		// The disposal method, user input flag and transparency flag,
+		// then the delay and transparent index.
+		b = append(b, 0x21, 0xf9, 4)
+		if s.Chance(badRate) {
+			b[len(b)-1] = gen.Pick[byte](s, 0, 3, 5)
+		}
+		b = append(b, byte(s.Intn(8))<<2|byte(s.Intn(2))<<1|byte(s.Intn(2)))
+		b = binary.LittleEndian.AppendUint16(b, gen.Pick[uint16](s, 0, 1, 10, 100, 65535))
+		b = append(b, gen.Pick[byte](s, 0, 1, 255, byte(s.Intn(256))))
+		if s.Chance(badRate) {
+			b = append(b, 1)
+		}
+		b = append(b, 0)
+	}
+	return b
+}
+
+// gifFrame appends an image descriptor and its pixels to b, on a screen
+// of sw by sh with a global color table of global colors.
+func gifFrame(s *gen.State, b []byte, sw, sh, global int) []byte {
+	fw, fh := s.Intn(sw+1), s.Intn(sh+1)
+	if s.Chance(0.5) {
+		fw, fh = sw, sh
+	}
+	fw, fh = min(fw, 400), min(fh, 400)
+	x, y := s.Intn(sw-fw+1), s.Intn(sh-fh+1)
+	if s.Chance(badRate * 2) {
+		// A frame that leaves the screen.
+		x, fw = sw, max(fw, 1)
+	}
+	b = append(b, 0x2c)
+	for _, v := range []int{x, y, fw, fh} {
+		b = binary.LittleEndian.AppendUint16(b, uint16(v))
+	}
+	flags := byte(0)
+	if s.Chance(0.2) {
+		flags |= 0x40 // interlaced
+	}
+	colors, local := global, 0
+	if global == 0 && !s.Chance(badRate) || s.Chance(0.2) {
+		depth := s.Range(1, 8)
+		colors, local = 1<<depth, 1<<depth
+		flags |= 0x80 | byte(depth-1)
+		if s.Chance(0.1) {
+			flags |= 0x20 // sorted
+		}
+	}
+	b = append(b, flags)
+	b = append(b, samples(s, 3*local)...)
+
+	// The minimum code size must hold the largest index, and is at
+	// least 2.
+	width := max(2, bits.Len(uint(max(colors, 1)-1)))
+	width = s.Range(width, 8)
+	pix := samples(s, fw*fh)
+	past := s.Chance(badRate * 2)
+	for i := range pix {
+		pix[i] &= byte(1<<width - 1)
+		if colors > 0 && int(pix[i]) >= colors && !past {
+			pix[i] %= byte(colors)
+		}
+	}
+	if s.Chance(badRate * 2) {
+		if len(pix) > 0 && s.Chance(0.5) {
+			pix = pix[:s.Intn(len(pix))]
+		} else {
+			pix = append(pix, samples(s, s.Range(1, 64))...)
+			for i := range pix {
+				pix[i] &= byte(1<<width - 1)
+			}
+		}
+	}
+	var z bytes.Buffer
+	w := lzw.NewWriter(&z, lzw.LSB, width)
+	w.Write(pix)
+	w.Close()
+	code := byte(width)
+	if s.Chance(badRate) {
+		code = gen.Pick[byte](s, 0, 1, 9, 12)
+	}
+	b = append(b, code)
+	data := z.Bytes()
+	if s.Chance(0.02) {
+		// A stray byte after the LZW data, which some encoders write.
+		data = append(data, 0)
+	}
+	return subBlocks(s, b, data)
+}
+
+// subBlocks appends data in sub-blocks of at most 255 bytes, each led by
+// its length, and the empty block that ends them. Most writers fill each
+// block; some do not.
+func subBlocks(s *gen.State, b, data []byte) []byte {
+	full := s.Chance(0.8)
+	for len(data) > 0 {
+		n := min(len(data), 255)
+		if !full {
+			n = s.Range(1, n)
+		}
+		b = append(b, byte(n))
+		b = append(b, data[:n]...)
+		data = data[n:]
+	}
+	if s.Chance(badRate) {
+		return b
+	}
+	return append(b, 0)
+}
@@ -0,0 +1,153 @@
+// Package imagesrc generates image seeds. It registers the "image/..."
+// generators with package gen.
+//
+// "image/png" writes a PNG file, input.png, chunk by chunk: every color
+// type at every bit depth it allows, interlaced or not, with scanlines
+// filtered by every filter and compressed into IDAT chunks split at
+// random, and with palettes, transparency, text, APNG and unknown
+// chunks around them. A few break the format: dimensions that are zero
+// or too large to allocate, bit depths and color types that do not go
+// together, unknown filters and methods, palettes too long or short for
+// the pixels, chunks out of order, bad CRCs and lengths, too much or
+// too little pixel data, and files without an IEND or with data after
+// it.
+//
+// "image/jpeg" writes a JPEG file, input.jpg, marker by marker, with
+// coefficients made up rather than transformed from pixels: grayscale,
+// YCbCr with every sampling ratio the decoder allows and CMYK with an
+// Adobe marker, baseline, extended and progressive frames, restart
+// intervals, and Huffman tables built for each scan. Progressive files
+// send their coefficients in spectral bands and bit planes, with runs of
+// end of bands, and a few abuse their scans: bands sent twice or never,
+// refinements with nothing to refine, bit positions that skip or go
+// backward, and AC scans of several components. Other breaks are bad
+// marker lengths, tables that are oversubscribed or undefined, missing
+// or wrong restart markers, and scans cut short.
+//
+// "image/gif" writes a GIF file, input.gif, with a logical screen from
+// empty to 65535 pixels square and a run of frames, each with its own
+// place on it, a global or local color table, a graphic control
+// extension with every disposal method, delays and transparent indexes
+// in and out of the palette, interlacing, and LZW data packed into
+// sub-blocks at every minimum code size. Around them go loop counts,
+// comments, plain text and unknown application extensions, and a few
+// break the format: frames that leave the screen, code sizes out of
+// range, too much or too little pixel data, pixels past the palette,
+// and files without a trailer.
+//
+// "image/webp" writes a WebP file, input.webp: a RIFF container holding
+// a lossy VP8 key frame, whose header is written with a boolean encoder
+// and whose macroblocks are random bits, which any VP8 decoder reads as
+// some image; or a lossless VP8L image, encoded here with transforms,
+// color caches, meta prefix codes, backward references, and simple and
+// normal prefix codes; or either of them after a VP8X header, with an
+// alpha chunk, raw or lossless and filtered, and metadata chunks. A few
+// break the format: sizes that disagree, chunks out of order or cut
+// short, bad RIFF lengths and padding, and codes that do not decode.
+package imagesrc
+
+import (
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// badRate is the chance that one of the many fields of a file is wrong,
+// so that one file in ten or so is broken.
+const badRate = 0.004
+
+// size returns the width or height of an image: small, as most seeds
+// are, or now and then a few hundred.
+func size(s *gen.State) int {
+	if s.Chance(0.05) {
+		return s.Range(100, 400)
+	}
+	return gen.Pick(s, 1, 1, 2, 3, 7, 8, 9, 15, 16, 17, s.Range(1, 64))
+}
+
+// samples returns n bytes for a picture: all alike, random, a ramp, a
+// few values repeated, or stripes.
+func samples(s *gen.State, n int) []byte {
+	b := make([]byte, n)
+	switch s.Intn(5) {
+	case 0:
+		v := byte(s.Intn(256))
+		for i := range b {
+			b[i] = v
+		}
+	case 1:
+		for i := range b {
+			b[i] = byte(s.Intn(256))
+		}
+	case 2:
+		step := s.Range(1, 7)
+		for i := range b {
+			b[i] = byte(i * step)
+		}
+	case 3:
+		vs := make([]byte, s.Range(2, 4))
+		for i := range vs {
+			vs[i] = byte(s.Intn(256))
+		}
+		for i := range b {
+			b[i] = vs[s.Intn(len(vs))]
+		}
+	default:
+		period := s.Range(2, 40)
+		for i := range b {
+			b[i] = byte(i / period * 97)
+		}
+	}
+	return b
+}
+
+// shape returns the lengths of the codes of a complete prefix code of n
+// symbols, none longer than limit bits, in no particular order, grown
+// from its root by splitting the shallowest leaf or one at random so
+// that codes range from balanced to lopsided. A code of one symbol is
+// one bit long.
+func shape(s *gen.State, n, limit int) []uint8 {
+	if n <= 1 {
+		return []uint8{1}
+	}
+	leaves := []uint8{0}
+	balanced := s.Chance(0.5)
+	for len(leaves) < n {
+		i := s.Intn(len(leaves))
+		if balanced || leaves[i] == uint8(limit) {
+			i = 0
+			for j, l := range leaves {
+				if l < leaves[i] {
+					i = j
+				}
+			}
+		}
+		leaves[i]++
+		leaves = append(leaves, leaves[i])
+	}
+	return leaves
+}
+
+// canonical returns the codes of the canonical prefix code with the
+// given code lengths, which JPEG and VP8L assign as deflate does:
+// shorter codes first, and symbols in order within a length. A symbol
+// of length 0 has no code.
+func canonical(lengths []uint8) []uint32 {
+	var count [33]uint32
+	for _, l := range lengths {
+		count[l]++
+	}
+	count[0] = 0
+	var next [33]uint32
+	code := uint32(0)
+	for l := 1; l < len(next); l++ {
+		code = (code + count[l-1]) << 1
+		next[l] = code
+	}
+	codes := make([]uint32, len(lengths))
+	for i, l := range lengths {
+		if l != 0 {
+			codes[i] = next[l]
+			next[l]++
+		}
+	}
+	return codes
+}
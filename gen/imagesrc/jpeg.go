@@ -0,0 +1,859 @@
+package imagesrc
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "image/jpeg",
+		Doc:  "JPEG files marker by marker: grayscale, YCbCr at every sampling ratio the decoder takes and Adobe CMYK, baseline, extended and progressive frames with spectral bands, bit planes and end-of-band runs, restart intervals, per-scan Huffman tables, and scans sent twice, never or out of order, and bad markers, tables, restarts and scan parameters",
+		Func: jpegSeed,
+	})
+}
+
+// The markers of JPEG.
+const (
+	mSOF0 = 0xc0
+	mSOF1 = 0xc1
+	mSOF2 = 0xc2
+	mDHT  = 0xc4
+	mRST0 = 0xd0
+	mSOI  = 0xd8
+	mEOI  = 0xd9
+	mSOS  = 0xda
+	mDQT  = 0xdb
+	mDRI  = 0xdd
+	mAPP0 = 0xe0
+	mCOM  = 0xfe
+)
+
+// A jblock is the coefficients of a block in zig-zag order, which is
+// all the order a writer needs.
+type jblock [64]int32
+
+// A jcomp is a component of a frame and its blocks, wanted and as far
+// as the scans written so far have sent them.
+type jcomp struct {
+	id, h, v, tq int
+	// want is the coefficients the scans send, and got what a decoder
+	// has of them after the scans written so far.
+	want, got []jblock
+}
+
+// A jscan is a scan: its components, by index, its spectral band and
+// its successive approximation.
+type jscan struct {
+	comps          []int
+	ss, se, ah, al int
+}
+
+// A jop is a Huffman coded symbol of a scan, bits written as they are,
+// or a restart marker.
+type jop struct {
+	// class is 0 or 1 for a DC or AC symbol, bitsOp or markerOp.
+	class int8
+	slot  int8
+	v     uint32
+	n     uint8
+}
+
+const (
+	bitsOp   = -1
+	markerOp = -2
+)
+
+// A jtable is a Huffman table: the code of each symbol it has, and how
+// long it is.
+type jtable struct {
+	codes [256]uint32
+	lens  [256]uint8
+}
+
+// A jgen writes a JPEG file.
+type jgen struct {
+	s *gen.State
+	b []byte
+
+	w, h        int
+	comps       []*jcomp
+	maxH, maxV  int
+	mxx, myy    int
+	progressive bool
+	baseline    bool
+	ri          int
+	// bad is the chance that a field of a scan is wrong, lower in
+	// progressive files, which have many scans.
+	bad float64
+
+	// ops are the symbols of the scan being written.
+	ops []jop
+	// run is how many blocks the end-of-band run being written covers,
+	// and corrections the correction bits that follow its symbol.
+	run         int
+	corrections []jop
+	// slots are the table slots each component of the scan codes with.
+	dcSlot, acSlot []int8
+}
+
+// jpegSeed writes one JPEG file.
+func jpegSeed(s *gen.State) []gen.File {
+	g := &jgen{s: s, w: size(s), h: size(s)}
+	sof := gen.Pick(s, mSOF0, mSOF0, mSOF1, mSOF2, mSOF2)
+	g.baseline, g.progressive = sof == mSOF0, sof == mSOF2
+	g.bad = badRate
+	if g.progressive {
+		g.bad = badRate / 4
+	}
+
+	n := gen.Pick(s, 1, 3, 3, 3, 4)
+	adobe := n == 4 && !s.Chance(badRate*5) || n == 3 && s.Chance(0.1)
+	for i := range n {
+		c := &jcomp{id: i + 1, h: 1, v: 1, tq: min(i, 1)}
+		switch {
+		case n == 1:
+			c.h, c.v = gen.Pick(s, 1, 1, 2), gen.Pick(s, 1, 1, 2)
+		case n == 3:
+			c.h, c.v = gen.Pick(s, 1, 1, 2, 4), gen.Pick(s, 1, 1, 2, 4)
+			if i == 0 && s.Chance(0.6) {
+				c.h, c.v = gen.Pick(s, 1, 2, 2), gen.Pick(s, 1, 2, 2)
+			}
+			if c.h*c.v > 10 && !s.Chance(badRate*5) {
+				// No scan may hold more than ten blocks of a
+				// macroblock, even a scan of one component.
+				c.v = 2
+			}
+		case n == 4 && (i == 0 || i == 3):
+			if i == 0 && s.Chance(0.3) {
+				c.h, c.v = 2, 2
+			}
+			if i == 3 {
+				c.h, c.v = g.comps[0].h, g.comps[0].v
+			}
+		}
+		if s.Chance(0.1) {
+			c.tq = s.Intn(4)
+		}
+		g.comps = append(g.comps, c)
+	}
+	transform := byte(gen.Pick(s, 0, 1, 2))
+	rgb := n == 3 && s.Chance(0.05)
+	if rgb {
+		for i, id := range "RGB" {
+			g.comps[i].id = int(id)
+		}
+	}
+	if rgb || n == 3 && adobe && transform == 0 {
+		// An RGB image, which decoders upsample only as they would
+		// YCbCr: the last two components sampled alike, and no more
+		// often than the first.
+		for _, c := range g.comps[1:] {
+			c.h, c.v = 1, 1
+		}
+	}
+
+	g.b = []byte{0xff, mSOI}
+	if s.Chance(0.7) {
+		g.segment(mAPP0, []byte("JFIF\x00\x01\x01\x00\x00\x01\x00\x01\x00\x00"))
+	}
+	if s.Chance(0.1) {
+		g.segment(mAPP0+1, []byte("Exif\x00\x00II*\x00\x08\x00\x00\x00\x00\x00"))
+	}
+	if s.Chance(0.05) {
+		g.segment(mAPP0+2, append([]byte("ICC_PROFILE\x00\x01\x01"), samples(s, 128)...))
+	}
+	if adobe {
+		// The Adobe marker: its version, flags, and the transform, which
+		// says what the components are.
+		g.segment(mAPP0+14, []byte{'A', 'd', 'o', 'b', 'e', 0, 100, 0, 0, 0, 0, transform})
+	}
+	if s.Chance(0.1) {
+		g.segment(mCOM, []byte(gen.Pick(s, "a comment", "", "CREATOR: gd-jpeg v1.0")))
+	}
+
+	var tqs []int
+	for _, c := range g.comps {
+		if !slices.Contains(tqs, c.tq) {
+			tqs = append(tqs, c.tq)
+		}
+	}
+	for _, tq := range tqs {
+		g.dqt(tq)
+	}
+	g.sof(sof)
+	g.fill()
+	if s.Chance(0.2) {
+		g.dri()
+	}
+
+	var scans []jscan
+	if g.progressive {
+		scans = g.script()
+	} else {
+		scans = g.sequential()
+	}
+	for _, sc := range scans {
+		if s.Chance(0.03) {
+			g.dri()
+		}
+		g.scan(sc)
+	}
+
+	if !s.Chance(badRate * 2) {
+		g.b = append(g.b, 0xff, mEOI)
+	}
+	switch {
+	case s.Chance(0.02):
+		g.b = append(g.b, gen.Pick(s, "\x00", "trailing", "\xff\xd9")...)
+	case s.Chance(badRate * 2):
+		g.b = g.b[:s.Intn(len(g.b)+1)]
+	}
+	return []gen.File{{Name: "input.jpg", Data: g.b}}
+}
+
+// segment appends a marker segment, now and then with fill bytes before
+// it or a bad length.
+func (g *jgen) segment(marker byte, data []byte) {
+	if g.s.Chance(0.02) {
+		g.b = append(g.b, 0xff, 0xff)
+	}
+	n := len(data) + 2
+	if g.s.Chance(badRate / 2) {
+		n = gen.Pick(g.s, n+1, n-1, 0, 1)
+	}
+	g.b = append(g.b, 0xff, marker)
+	g.b = binary.BigEndian.AppendUint16(g.b, uint16(n))
+	g.b = append(g.b, data...)
+}
+
+// dqt writes quantization table tq, of 8 or 16 bit values.
+func (g *jgen) dqt(tq int) {
+	s := g.s
+	pq := 0
+	if !g.baseline && s.Chance(0.1) {
+		pq = 1
+	}
+	if s.Chance(badRate) {
+		pq, tq = gen.Pick(s, pq, 2), gen.Pick(s, tq, 4)
+	}
+	data := []byte{byte(pq<<4 | tq)}
+	for range 64 {
+		q := gen.Pick(s, 1, 1, 2, 16, 99, 255, s.Intn(256))
+		if pq == 1 {
+			data = binary.BigEndian.AppendUint16(data, uint16(q*gen.Pick(s, 1, 1, 256)))
+		} else {
+			data = append(data, byte(q))
+		}
+	}
+	g.segment(mDQT, data)
+}
+
+// sof writes the frame header and sets up the blocks of the components.
+func (g *jgen) sof(marker int) {
+	s := g.s
+	data := []byte{8}
+	if s.Chance(badRate) {
+		data[0] = gen.Pick[byte](s, 12, 16, 0)
+	}
+	// The size the header gives, which is not always the one the scans
+	// are written for.
+	w, h := g.w, g.h
+	if s.Chance(0.02) {
+		w, h = gen.Pick(s, w, w+8, 65535, 0), gen.Pick(s, h, h+8, 65535, 0)
+	}
+	data = binary.BigEndian.AppendUint16(data, uint16(h))
+	data = binary.BigEndian.AppendUint16(data, uint16(w))
+	data = append(data, byte(len(g.comps)))
+	for _, c := range g.comps {
+		hv := byte(c.h<<4 | c.v)
+		if s.Chance(badRate) {
+			hv = gen.Pick[byte](s, 0x31, 0x13, 0x00, 0x51, 0x21)
+		}
+		data = append(data, byte(c.id), hv, byte(c.tq))
+	}
+	if s.Chance(badRate) {
+		data = append(data, 1, 0x11, 0)
+	}
+	g.segment(byte(marker), data)
+
+	// A decoder takes a grayscale image to be sampled one to one, what
+	// its header says.
+	if len(g.comps) == 1 {
+		g.comps[0].h, g.comps[0].v = 1, 1
+	}
+	for _, c := range g.comps {
+		g.maxH, g.maxV = max(g.maxH, c.h), max(g.maxV, c.v)
+	}
+	g.mxx = (g.w + 8*g.maxH - 1) / (8 * g.maxH)
+	g.myy = (g.h + 8*g.maxV - 1) / (8 * g.maxV)
+	for _, c := range g.comps {
+		n := g.mxx * c.h * g.myy * c.v
+		c.want, c.got = make([]jblock, n), make([]jblock, n)
+		kind := s.Intn(4)
+		dc := int32(s.Range(-1024, 1023))
+		for i := range c.want {
+			c.want[i] = coefficients(s, kind, dc)
+		}
+	}
+}
+
+// coefficients returns the coefficients of a block of some kind: flat,
+// smooth, noisy, or with a few high frequencies. The DC coefficients of
+// a component wander about dc.
+func coefficients(s *gen.State, kind int, dc int32) jblock {
+	var b jblock
+	b[0] = max(-2047, min(2047, dc+int32(s.Range(-64, 64))))
+	switch kind {
+	case 1:
+		for k := range s.Intn(6) {
+			b[1+k] = int32(s.Range(-40, 40))
+		}
+	case 2:
+		for k := 1; k < 64; k++ {
+			if s.Chance(0.4) {
+				b[k] = int32(s.Range(-300, 300) >> (k / 16))
+			}
+		}
+	case 3:
+		for range s.Intn(4) {
+			b[s.Range(20, 63)] = int32(gen.Pick(s, 1, -1, 2, 127, -1023, 1023))
+		}
+	}
+	return b
+}
+
+// fill writes a few bytes between segments, which decoders skip, or a
+// restart marker where none belongs.
+func (g *jgen) fill() {
+	if g.s.Chance(0.01) {
+		g.b = append(g.b, gen.Pick(g.s, "\x00", "\xff\x00", "\xff\xd0", "junk")...)
+	}
+}
+
+// dri writes a restart interval, counted in MCUs, or none.
+func (g *jgen) dri() {
+	s := g.s
+	g.ri = gen.Pick(s, 0, 1, 2, 3, 7, 8, 16, s.Range(1, 100))
+	data := binary.BigEndian.AppendUint16(nil, uint16(g.ri))
+	if s.Chance(badRate) {
+		data = append(data, 0)
+	}
+	g.segment(mDRI, data)
+}
+
+// sequential returns the scans of a sequential frame: all components
+// together or one at a time.
+func (g *jgen) sequential() []jscan {
+	all := make([]int, len(g.comps))
+	for i := range all {
+		all[i] = i
+	}
+	var scans []jscan
+	for _, comps := range g.groups(all, g.s.Chance(0.2)) {
+		scans = append(scans, jscan{comps: comps, ss: 0, se: 63})
+	}
+	if g.s.Chance(0.03) {
+		// A component sent again, which overwrites it.
+		scans = append(scans, jscan{comps: []int{g.s.Intn(len(g.comps))}, ss: 0, se: 63})
+	}
+	return scans
+}
+
+// groups splits comps into the components of scans: one scan for all,
+// unless split is set or they have more blocks in an MCU than a scan
+// may, in which case one scan each.
+func (g *jgen) groups(comps []int, split bool) [][]int {
+	total := 0
+	for _, i := range comps {
+		total += g.comps[i].h * g.comps[i].v
+	}
+	if len(comps) > 1 && total > 10 && g.s.Chance(badRate*5) {
+		split = false
+	} else if total > 10 {
+		split = true
+	}
+	if !split {
+		return [][]int{comps}
+	}
+	var groups [][]int
+	for _, i := range comps {
+		groups = append(groups, []int{i})
+	}
+	return groups
+}
+
+// script returns the scans of a progressive frame: DC first, in one or
+// more bit planes, then the AC coefficients of each component in bands
+// and bit planes. Now and then scans are left out, sent twice or
+// shuffled, which a decoder must take in its stride, or have parameters
+// no decoder takes.
+func (g *jgen) script() []jscan {
+	s := g.s
+	all := make([]int, len(g.comps))
+	for i := range all {
+		all[i] = i
+	}
+	var scans []jscan
+	dcAl := gen.Pick(s, 0, 0, 1, 2)
+	for _, comps := range g.groups(all, s.Chance(0.2)) {
+		scans = append(scans, jscan{comps: comps, al: dcAl})
+	}
+	for i := range g.comps {
+		acAl := gen.Pick(s, 0, 0, 1, 2)
+		bands := gen.Pick(s, [][2]int{{1, 63}}, [][2]int{{1, 5}, {6, 63}}, [][2]int{{1, 2}, {3, 9}, {10, 63}}, [][2]int{{1, 63}, {1, 63}})
+		for _, band := range bands {
+			scans = append(scans, jscan{comps: []int{i}, ss: band[0], se: band[1], al: acAl})
+		}
+		for al := acAl - 1; al >= 0; al-- {
+			for _, band := range bands {
+				scans = append(scans, jscan{comps: []int{i}, ss: band[0], se: band[1], ah: al + 1, al: al})
+			}
+		}
+	}
+	for al := dcAl - 1; al >= 0; al-- {
+		for _, comps := range g.groups(all, s.Chance(0.2)) {
+			scans = append(scans, jscan{comps: comps, ah: al + 1, al: al})
+		}
+	}
+
+	switch {
+	case s.Chance(0.05):
+		i := s.Intn(len(scans))
+		scans = slices.Delete(scans, i, i+1)
+	case s.Chance(0.05):
+		i := s.Intn(len(scans))
+		scans = slices.Insert(scans, s.Intn(len(scans)+1), scans[i])
+	case s.Chance(0.03):
+		gen.Shuffle(s, scans)
+	}
+	if len(scans) > 0 && s.Chance(badRate*3) {
+		sc := &scans[s.Intn(len(scans))]
+		switch s.Intn(5) {
+		case 0:
+			sc.ah = sc.al + 2
+		case 1:
+			sc.ss, sc.se = 10, 5
+		case 2:
+			sc.ss, sc.se = 1, 64
+		case 3:
+			sc.ss, sc.se = 0, 5
+		default:
+			sc.ss, sc.se, sc.comps = 1, 63, all
+		}
+	}
+	return scans
+}
+
+// valid reports whether a decoder takes sc, given the frame.
+func (g *jgen) valid(sc jscan) bool {
+	if !g.progressive {
+		return sc.ss == 0 && sc.se == 63 && sc.ah == 0 && sc.al == 0
+	}
+	switch {
+	case sc.ss == 0 && sc.se != 0, sc.ss > sc.se, sc.se > 63:
+		return false
+	case sc.ss != 0 && len(sc.comps) != 1:
+		return false
+	case sc.ah != 0 && sc.ah != sc.al+1:
+		return false
+	}
+	return true
+}
+
+// scan writes the tables a scan codes with, its header and its data.
+func (g *jgen) scan(sc jscan) {
+	s := g.s
+	g.ops, g.run, g.corrections = g.ops[:0], 0, nil
+	g.dcSlot, g.acSlot = make([]int8, len(sc.comps)), make([]int8, len(sc.comps))
+	for i := range sc.comps {
+		slot := int8(min(i, 1))
+		if !g.baseline && s.Chance(0.2) {
+			slot = int8(s.Intn(4))
+		}
+		g.dcSlot[i], g.acSlot[i] = slot, slot
+	}
+	valid := g.valid(sc)
+	if valid {
+		g.encode(sc)
+	}
+
+	// The tables, built for the symbols the scan uses.
+	var used [2][4][]int
+	seen := map[[3]int]bool{}
+	for _, op := range g.ops {
+		if op.class < 0 {
+			continue
+		}
+		k := [3]int{int(op.class), int(op.slot), int(op.v)}
+		if !seen[k] {
+			seen[k] = true
+			used[op.class][op.slot] = append(used[op.class][op.slot], int(op.v))
+		}
+	}
+	var tables [2][4]*jtable
+	var dht []byte
+	for class := range used {
+		for slot, syms := range used[class] {
+			if len(syms) == 0 {
+				continue
+			}
+			t, data := g.table(class, slot, syms)
+			tables[class][slot] = t
+			if s.Chance(g.bad) {
+				continue
+			}
+			if s.Chance(0.5) {
+				dht = append(dht, data...)
+			} else {
+				g.segment(mDHT, data)
+			}
+		}
+	}
+	if len(dht) > 0 {
+		g.segment(mDHT, dht)
+	}
+
+	data := []byte{byte(len(sc.comps))}
+	for i, ci := range sc.comps {
+		data = append(data, byte(g.comps[ci].id), byte(g.dcSlot[i]<<4|g.acSlot[i]))
+	}
+	data = append(data, byte(sc.ss), byte(sc.se), byte(sc.ah<<4|sc.al))
+	if s.Chance(g.bad) {
+		switch s.Intn(3) {
+		case 0:
+			data[1] = 99
+		case 1:
+			data[0]++
+		default:
+			data = data[:len(data)-1]
+		}
+	}
+	g.segment(mSOS, data)
+	if !valid {
+		g.b = append(g.b, samples(s, s.Intn(64))...)
+		return
+	}
+
+	var w jpegWriter
+	w.b = g.b
+	cut := len(g.ops)
+	if s.Chance(g.bad * 2) {
+		cut = s.Intn(cut + 1)
+	}
+	for _, op := range g.ops[:cut] {
+		switch op.class {
+		case bitsOp:
+			w.write(op.v, uint(op.n))
+		case markerOp:
+			w.pad()
+			w.b = append(w.b, 0xff, byte(op.v))
+		default:
+			t := tables[op.class][op.slot]
+			w.write(t.codes[op.v], uint(t.lens[op.v]))
+		}
+	}
+	w.pad()
+	g.b = w.b
+	g.fill()
+}
+
+// table returns a Huffman table for syms, and the data of a DHT segment
+// that defines it. The code of all ones is given to no symbol, as JPEG
+// requires; now and then a table is oversubscribed or has too many or
+// no symbols.
+func (g *jgen) table(class, slot int, syms []int) (*jtable, []byte) {
+	s := g.s
+	gen.Shuffle(s, syms)
+	lens := shape(s, len(syms)+1, 16)
+	slices.Sort(lens)
+	lens = lens[:len(syms)]
+	codes := canonical(lens)
+	t := &jtable{}
+	var counts [16]byte
+	for i, sym := range syms {
+		t.codes[sym], t.lens[sym] = codes[i], lens[i]
+		counts[lens[i]-1]++
+	}
+	data := append([]byte{byte(class<<4 | slot)}, counts[:]...)
+	for _, sym := range syms {
+		data = append(data, byte(sym))
+	}
+	if s.Chance(g.bad) {
+		switch s.Intn(3) {
+		case 0:
+			data[1] += 2
+			data = append(data, 0, 1)
+		case 1:
+			clear(data[1:17])
+			data = data[:17]
+		default:
+			data[0] = byte(gen.Pick(s, 2<<4|slot, class<<4|4))
+		}
+	}
+	return t, data
+}
+
+// encode writes the symbols of sc, visiting blocks as a decoder does:
+// MCU by MCU, and within an MCU each component's blocks in turn, or for
+// a scan of one component, its blocks row by row, leaving out those
+// past the edge of the image.
+func (g *jgen) encode(sc jscan) {
+	var pred [4]int32
+	mcu, rst := 0, 0
+	count := make([]int, len(sc.comps))
+	for my := range g.myy {
+		for mx := range g.mxx {
+			for i, ci := range sc.comps {
+				c := g.comps[ci]
+				for j := range c.h * c.v {
+					var bx, by int
+					if len(sc.comps) != 1 {
+						bx, by = c.h*mx+j%c.h, c.v*my+j/c.h
+					} else {
+						q := g.mxx * c.h
+						bx, by = count[i]%q, count[i]/q
+						count[i]++
+						if bx*8 >= g.w || by*8 >= g.h {
+							continue
+						}
+					}
+					k := by*g.mxx*c.h + bx
+					if sc.ah == 0 {
+						g.first(sc, i, &c.want[k], &c.got[k], &pred[ci])
+					} else {
+						g.refine(sc, i, &c.want[k], &c.got[k])
+					}
+				}
+			}
+			mcu++
+			if g.ri > 0 && mcu%g.ri == 0 && mcu < g.mxx*g.myy {
+				g.flush()
+				marker := mRST0 + rst%8
+				if g.s.Chance(g.bad) {
+					marker = gen.Pick(g.s, mRST0+(rst+1)%8, mEOI, 0x00)
+				}
+				g.ops = append(g.ops, jop{class: markerOp, v: uint32(marker)})
+				rst++
+				pred = [4]int32{}
+			}
+		}
+	}
+	g.flush()
+}
+
+// sym writes symbol v of a table.
+func (g *jgen) sym(class int, slot int8, v int) {
+	g.ops = append(g.ops, jop{class: int8(class), slot: slot, v: uint32(v)})
+}
+
+// bits writes the n low bits of v.
+func (g *jgen) bits(v int32, n int) {
+	if n > 0 {
+		g.ops = append(g.ops, jop{class: bitsOp, v: uint32(v) & (1<<n - 1), n: uint8(n)})
+	}
+}
+
+// value writes the category of v as symbol sym|category, then v in that
+// many bits, less one if it is negative.
+func (g *jgen) value(class int, slot int8, sym int, v int32) {
+	n := bits.Len32(uint32(max(v, -v)))
+	g.sym(class, slot, sym|n)
+	if v < 0 {
+		v--
+	}
+	g.bits(v, n)
+}
+
+// flush writes the end-of-band run being written, if there is one, and
+// the correction bits that follow it. Runs are only written in scans of
+// one component.
+func (g *jgen) flush() {
+	if g.run == 0 {
+		return
+	}
+	n := bits.Len(uint(g.run)) - 1
+	g.sym(1, g.acSlot[0], n<<4)
+	g.bits(int32(g.run), n)
+	g.ops = append(g.ops, g.corrections...)
+	g.run, g.corrections = 0, nil
+}
+
+// end ends a block's band early: in a run, for a progressive scan,
+// which may cover the blocks that follow, or else on its own.
+func (g *jgen) end(slot int8) {
+	if !g.progressive {
+		g.sym(1, slot, 0x00)
+		return
+	}
+	g.run++
+	if g.run == 0x7fff {
+		g.flush()
+	}
+}
+
+// first writes the first pass over a block's band, at bit position al:
+// the DC difference, if the band starts at 0, then the AC coefficients
+// as runs of zeros and values.
+func (g *jgen) first(sc jscan, i int, want, got *jblock, pred *int32) {
+	start := sc.ss
+	if start == 0 {
+		x := want[0] >> sc.al
+		g.value(0, g.dcSlot[i], 0, x-*pred)
+		*pred = x
+		got[0] = x << sc.al
+		start = 1
+	}
+	if start > sc.se {
+		return
+	}
+	var vals [64]int32
+	last := -1
+	for k := start; k <= sc.se; k++ {
+		v := want[k]
+		if v < 0 {
+			vals[k] = -(-v >> sc.al)
+		} else {
+			vals[k] = v >> sc.al
+		}
+		if vals[k] != 0 {
+			last = k
+		}
+	}
+	slot := g.acSlot[i]
+	if last < 0 {
+		g.end(slot)
+		return
+	}
+	g.flush()
+	r := 0
+	for k := start; k <= last; k++ {
+		if vals[k] == 0 {
+			r++
+			continue
+		}
+		for ; r > 15; r -= 16 {
+			g.sym(1, slot, 0xf0)
+		}
+		g.value(1, slot, r<<4, vals[k])
+		got[k] = vals[k] << sc.al
+		r = 0
+	}
+	if last < sc.se {
+		g.end(slot)
+	}
+}
+
+// refine writes a pass over a block's band that refines it by bit al:
+// the bit of the DC coefficient, or the coefficients that become
+// nonzero, each after the correction bits of the nonzero ones it
+// passes.
+func (g *jgen) refine(sc jscan, i int, want, got *jblock) {
+	delta := int32(1) << sc.al
+	bit := func(v int32) int32 { return max(v, -v) >> sc.al & 1 }
+	if sc.ss == 0 {
+		b := want[0] >> sc.al & 1
+		g.bits(b, 1)
+		if b == 1 {
+			got[0] |= delta
+		}
+		return
+	}
+	// correct returns the correction bit of a nonzero coefficient, and
+	// applies it.
+	correct := func(k int) jop {
+		b := int32(0)
+		if bit(want[k]) == 1 && bit(got[k]) == 0 {
+			b = 1
+			if got[k] > 0 {
+				got[k] += delta
+			} else {
+				got[k] -= delta
+			}
+		}
+		return jop{class: bitsOp, v: uint32(b), n: 1}
+	}
+	slot := g.acSlot[i]
+	var fresh []int
+	for k := sc.ss; k <= sc.se; k++ {
+		if got[k] == 0 && bit(want[k]) == 1 {
+			fresh = append(fresh, k)
+		}
+	}
+	pos := sc.ss
+	if len(fresh) > 0 {
+		g.flush()
+	}
+	for _, k := range fresh {
+		var zeros []int
+		for j := pos; j < k; j++ {
+			if got[j] == 0 {
+				zeros = append(zeros, j)
+			}
+		}
+		for len(zeros) >= 16 {
+			g.sym(1, slot, 0xf0)
+			for ; pos <= zeros[15]; pos++ {
+				if got[pos] != 0 {
+					g.ops = append(g.ops, correct(pos))
+				}
+			}
+			zeros = zeros[16:]
+		}
+		g.sym(1, slot, len(zeros)<<4|1)
+		g.bits(int32(b2u(want[k] > 0)), 1)
+		for ; pos < k; pos++ {
+			if got[pos] != 0 {
+				g.ops = append(g.ops, correct(pos))
+			}
+		}
+		if want[k] > 0 {
+			got[k] = delta
+		} else {
+			got[k] = -delta
+		}
+		pos = k + 1
+	}
+	if pos > sc.se {
+		return
+	}
+	for ; pos <= sc.se; pos++ {
+		if got[pos] != 0 {
+			g.corrections = append(g.corrections, correct(pos))
+		}
+	}
+	g.end(slot)
+}
+
+// A jpegWriter writes bits most significant first, with a zero after
+// each 0xff byte so that it is not taken for a marker.
+type jpegWriter struct {
+	b    []byte
+	bits uint64
+	n    uint
+}
+
+// write writes the n low bits of v.
+func (w *jpegWriter) write(v uint32, n uint) {
+	w.bits = w.bits<<n | uint64(v)&(1<<n-1)
+	w.n += n
+	for w.n >= 8 {
+		c := byte(w.bits >> (w.n - 8))
+		w.b = append(w.b, c)
+		if c == 0xff {
+			w.b = append(w.b, 0)
+		}
+		w.n -= 8
+	}
+}
+
+// pad writes ones up to the next byte.
+func (w *jpegWriter) pad() {
+	if w.n > 0 {
+		w.write(0xff, 8-w.n)
+	}
+	w.bits = 0
+}
@@ -0,0 +1,289 @@
+package imagesrc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "image/png",
+		Doc:  "PNG files chunk by chunk: every color type and bit depth, interlaced or not, palettes, transparency, text, APNG and unknown chunks, IDAT split at random, and bad dimensions, depths, filters, methods, CRCs, lengths and chunk order",
+		Func: pngSeed,
+	})
+}
+
+// The color types of PNG.
+const (
+	ctGray      = 0
+	ctRGB       = 2
+	ctPaletted  = 3
+	ctGrayAlpha = 4
+	ctRGBA      = 6
+)
+
+var (
+	// depths are the bit depths each color type allows.
+	depths = map[int][]int{
+		ctGray:      {1, 2, 4, 8, 16},
+		ctRGB:       {8, 16},
+		ctPaletted:  {1, 2, 4, 8},
+		ctGrayAlpha: {8, 16},
+		ctRGBA:      {8, 16},
+	}
+	// channels are the samples a pixel of each color type has.
+	channels = map[int]int{ctGray: 1, ctRGB: 3, ctPaletted: 1, ctGrayAlpha: 2, ctRGBA: 4}
+)
+
+// adam7 are the passes of an interlaced image: where each starts and
+// how far apart its pixels are.
+var adam7 = []struct{ x, y, dx, dy int }{
+	{0, 0, 8, 8}, {4, 0, 8, 8}, {0, 4, 4, 8}, {2, 0, 4, 4}, {0, 2, 2, 4}, {1, 0, 2, 2}, {0, 1, 1, 2},
+}
+
+// pngSeed writes one PNG file.
+func pngSeed(s *gen.State) []gen.File {
+	w, h := size(s), size(s)
+	ct := gen.Pick(s, ctGray, ctRGB, ctPaletted, ctGrayAlpha, ctRGBA)
+	depth := gen.Pick(s, depths[ct]...)
+	interlaced := s.Chance(0.3)
+
+	b := []byte("\x89PNG\r\n\x1a\n")
+	if s.Chance(badRate) {
+		b = []byte(gen.Pick(s, "\x89PNG\r\n\x1a\x00", "\x89PNG\n\x1a\n", ""))
+	}
+
+	ihdr := binary.BigEndian.AppendUint32(nil, uint32(w))
+	ihdr = binary.BigEndian.AppendUint32(ihdr, uint32(h))
+	ihdr = append(ihdr, byte(depth), byte(ct), 0, 0, b2u(interlaced))
+	if s.Chance(0.02) {
+		// Dimensions that do not match the pixel data: zero, one too
+		// many, or too large to allocate or to multiply.
+		i := gen.Pick(s, 0, 4)
+		binary.BigEndian.PutUint32(ihdr[i:], gen.Pick[uint32](s, 0, uint32(max(w, h))+1, 1<<16, 1<<20, 1<<31-1, 1<<31, 1<<32-1))
+	}
+	if s.Chance(badRate) {
+		switch s.Intn(4) {
+		case 0:
+			ihdr[8] = gen.Pick[byte](s, 0, 3, 5, 32, byte(gen.Pick(s, 1, 2, 4)))
+		case 1:
+			ihdr[9] = gen.Pick[byte](s, 1, 5, 7, 8)
+		case 2:
+			ihdr[10+s.Intn(2)] = gen.Pick[byte](s, 1, 255)
+		default:
+			ihdr[12] = 2
+		}
+	}
+	if s.Chance(badRate) {
+		ihdr = ihdr[:s.Intn(len(ihdr))]
+	}
+	b = chunk(s, b, "IHDR", ihdr)
+
+	frames := 0
+	if s.Chance(0.1) {
+		// An APNG, whose frames after the first a PNG decoder skips.
+		frames = s.Range(1, 4)
+		b = chunk(s, b, "acTL", binary.BigEndian.AppendUint32(binary.BigEndian.AppendUint32(nil, uint32(frames)), uint32(s.Intn(3))))
+	}
+	b = ancillary(s, b, "gAMA", "cHRM", "sRGB", "iCCP", "sBIT")
+
+	var palette int
+	if ct == ctPaletted || (ct == ctRGB || ct == ctRGBA) && s.Chance(0.2) {
+		palette = s.Range(1, 256)
+		if ct == ctPaletted {
+			palette = min(palette, 1<<depth)
+		}
+		if !(ct == ctPaletted && s.Chance(badRate*5)) {
+			plte := samples(s, 3*palette)
+			if s.Chance(badRate) {
+				plte = append(plte, gen.Pick(s, []byte{0}, []byte{0, 0, 0}, nil)...)
+				if len(plte) == 3*palette {
+					plte = nil
+				}
+			}
+			b = chunk(s, b, "PLTE", plte)
+		}
+	}
+	if s.Chance(0.2) && ct != ctGrayAlpha && ct != ctRGBA {
+		var trns []byte
+		switch ct {
+		case ctGray:
+			trns = samples(s, 2)
+		case ctRGB:
+			trns = samples(s, 6)
+		default:
+			trns = samples(s, s.Range(1, max(palette, 1)))
+		}
+		if s.Chance(badRate) {
+			trns = append(trns, 0)
+		}
+		b = chunk(s, b, "tRNS", trns)
+	}
+	b = ancillary(s, b, "bKGD", "pHYs", "tIME", "tEXt", "zTXt", "iTXt", "prVt")
+	if frames > 0 {
+		b = chunk(s, b, "fcTL", fcTL(s, 0, w, h))
+	}
+	if s.Chance(badRate) {
+		// A chunk where it may not be.
+		b = chunk(s, b, gen.Pick(s, "IHDR", "PLTE", "IEND", "tRNS"), samples(s, gen.Pick(s, 0, 3, 13)))
+	}
+
+	raw := scanlines(s, w, h, depth*channels[ct], interlaced)
+	var z bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&z, gen.Pick(s, zlib.NoCompression, zlib.BestSpeed, zlib.DefaultCompression, zlib.BestCompression, zlib.HuffmanOnly))
+	if err != nil {
+		panic(err)
+	}
+	zw.Write(raw)
+	zw.Close()
+	idat := z.Bytes()
+	if s.Chance(badRate * 2) {
+		idat = idat[:s.Intn(len(idat)+1)]
+	}
+	for n := gen.Pick(s, 1, 1, 1, 2, 3, 8); n > 1 && len(idat) > 0; n-- {
+		cut := s.Intn(len(idat) + 1)
+		b = chunk(s, b, "IDAT", idat[:cut])
+		idat = idat[cut:]
+	}
+	b = chunk(s, b, "IDAT", idat)
+	if s.Chance(0.05) {
+		b = chunk(s, b, "IDAT", nil)
+	}
+	for i := 1; i < frames; i++ {
+		b = chunk(s, b, "fcTL", fcTL(s, 2*i-1, w, h))
+		b = chunk(s, b, "fdAT", binary.BigEndian.AppendUint32(nil, uint32(2*i)))
+	}
+	b = ancillary(s, b, "tEXt", "tIME", "eXIf")
+	if !s.Chance(badRate * 2) {
+		b = chunk(s, b, "IEND", nil)
+	}
+	switch {
+	case s.Chance(0.02):
+		b = append(b, gen.Pick(s, "\x00", "trailing", "\x00\x00\x00\x00IEND\xaeB`\x82")...)
+	case s.Chance(badRate * 2):
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.png", Data: b}}
+}
+
+// chunk appends a chunk to b, now and then with a bad length or CRC.
+func chunk(s *gen.State, b []byte, typ string, data []byte) []byte {
+	n := uint32(len(data))
+	if s.Chance(badRate / 4) {
+		n = gen.Pick(s, n+1, n-1, 1<<31, 1<<32-1)
+	}
+	b = binary.BigEndian.AppendUint32(b, n)
+	start := len(b)
+	b = append(b, typ...)
+	b = append(b, data...)
+	crc := crc32.ChecksumIEEE(b[start:])
+	if s.Chance(badRate / 4) {
+		crc ^= 1 << s.Intn(32)
+	}
+	return binary.BigEndian.AppendUint32(b, crc)
+}
+
+// ancillary appends a few chunks of the given types that a decoder
+// need not understand, each with a chance of being left out.
+func ancillary(s *gen.State, b []byte, types ...string) []byte {
+	for _, typ := range types {
+		if !s.Chance(0.1) {
+			continue
+		}
+		var data []byte
+		switch typ {
+		case "gAMA":
+			data = binary.BigEndian.AppendUint32(nil, gen.Pick[uint32](s, 45455, 100000, 0))
+		case "sRGB":
+			data = []byte{byte(s.Intn(4))}
+		case "tIME":
+			data = []byte{0x07, 0xe8, 1, 31, 23, 59, 60}
+		case "iCCP", "zTXt":
+			data = append([]byte(gen.Pick(s, "icc", "Comment", "x")), 0, 0)
+			data = append(data, compressed(samples(s, s.Intn(200)))...)
+		case "tEXt":
+			data = []byte(gen.Pick(s, "Title\x00a picture", "Software\x00gen", "\x00empty keyword", "Comment\x00"))
+		case "iTXt":
+			data = []byte(gen.Pick(s, "Title\x00\x00\x00en\x00Titel\x00ein Bild", "XML:com.adobe.xmp\x00\x00\x00\x00\x00<x:xmpmeta/>"))
+		default:
+			data = samples(s, gen.Pick(s, 0, 1, 6, 9, 32))
+		}
+		b = chunk(s, b, typ, data)
+	}
+	return b
+}
+
+// compressed returns data compressed with zlib.
+func compressed(data []byte) []byte {
+	var b bytes.Buffer
+	w := zlib.NewWriter(&b)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// fcTL returns the control chunk of an APNG frame.
+func fcTL(s *gen.State, seq, w, h int) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(seq))
+	b = binary.BigEndian.AppendUint32(b, uint32(w))
+	b = binary.BigEndian.AppendUint32(b, uint32(h))
+	b = binary.BigEndian.AppendUint64(b, 0)
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Intn(100)))
+	b = binary.BigEndian.AppendUint16(b, uint16(s.Intn(101)))
+	return append(b, byte(s.Intn(3)), byte(s.Intn(2)))
+}
+
+// scanlines returns the filtered scanlines of a w by h image of bpp
+// bits a pixel, each pass in turn if it is interlaced. The samples are
+// made up and the filters chosen at random, since any bytes a filter
+// undoes make a picture; now and then a filter is one no decoder knows,
+// or there is a byte too many or too few.
+func scanlines(s *gen.State, w, h, bpp int, interlaced bool) []byte {
+	passes := []struct{ x, y, dx, dy int }{{0, 0, 1, 1}}
+	if interlaced {
+		passes = adam7
+	}
+	filter := -1
+	if s.Chance(0.5) {
+		filter = s.Intn(5)
+	}
+	if s.Chance(badRate) {
+		filter = gen.Pick(s, 5, 255)
+	}
+	var b []byte
+	for _, p := range passes {
+		pw, ph := (w-p.x+p.dx-1)/p.dx, (h-p.y+p.dy-1)/p.dy
+		if pw <= 0 || ph <= 0 {
+			continue
+		}
+		row := (pw*bpp + 7) / 8
+		for range ph {
+			f := filter
+			if f < 0 {
+				f = s.Intn(5)
+			}
+			b = append(b, byte(f))
+			b = append(b, samples(s, row)...)
+		}
+	}
+	if s.Chance(badRate) {
+		if len(b) > 0 && s.Chance(0.5) {
+			b = b[:len(b)-1]
+		} else {
+			b = append(b, 0)
+		}
+	}
+	return b
+}
+
+// b2u returns 1 if b is set, 0 if not.
+func b2u(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
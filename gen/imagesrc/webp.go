@@ -0,0 +1,745 @@
+package imagesrc
+
+import (
+	"encoding/binary"
+	"math/bits"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "image/webp",
+		Doc:  "WebP files: lossy VP8 key frames with every header field and random macroblocks, lossless VP8L images with every transform, color caches, meta prefix codes, backward references and simple and normal prefix codes, extended files with alpha and metadata chunks, and bad sizes, chunk orders, RIFF lengths and codes",
+		Func: webpSeed,
+	})
+}
+
+// The transforms of a lossless image.
+const (
+	tPredictor = iota
+	tCrossColor
+	tSubtractGreen
+	tColorIndexing
+)
+
+// webpSeed writes one WebP file.
+func webpSeed(s *gen.State) []gen.File {
+	w, h := size(s), size(s)
+	lossless := s.Chance(0.5)
+	var b []byte
+	alpha := false
+	if s.Chance(0.3) {
+		// The extended format: flags for what follows, then the size of
+		// the canvas, which the image should but need not match.
+		// A decoder takes the alpha flag to promise an alpha chunk,
+		// which only a lossy image has.
+		alpha = !lossless && s.Chance(0.6) || s.Chance(badRate)
+		icc, exif, xmp := s.Chance(0.1), s.Chance(0.1), s.Chance(0.1)
+		flags := b2u(alpha)<<4 | b2u(icc)<<5 | b2u(exif)<<3 | b2u(xmp)<<2
+		if s.Chance(badRate) {
+			flags |= 1 << 1 // animated, with no frames
+		}
+		vw, vh := w, h
+		if s.Chance(0.02) {
+			vw, vh = gen.Pick(s, w, w+1, 1<<14, 1<<24), gen.Pick(s, h, h-1, 1<<14, 1<<24)
+		}
+		vp8x := []byte{flags, 0, 0, 0}
+		vp8x = append(vp8x, byte(vw-1), byte((vw-1)>>8), byte((vw-1)>>16))
+		vp8x = append(vp8x, byte(vh-1), byte((vh-1)>>8), byte((vh-1)>>16))
+		if s.Chance(badRate) {
+			vp8x = vp8x[:s.Intn(len(vp8x))]
+		}
+		b = riffChunk(s, b, "VP8X", vp8x)
+		if s.Chance(badRate) {
+			b = riffChunk(s, b, "VP8X", vp8x)
+		}
+		if icc {
+			b = riffChunk(s, b, "ICCP", samples(s, s.Range(1, 200)))
+		}
+		if alpha || s.Chance(badRate) {
+			b = riffChunk(s, b, "ALPH", alph(s, vw, vh))
+		}
+	}
+	if lossless {
+		b = riffChunk(s, b, "VP8L", vp8l(s, w, h, true))
+	} else {
+		b = riffChunk(s, b, "VP8 ", vp8(s, w, h))
+	}
+	if s.Chance(0.1) {
+		b = riffChunk(s, b, gen.Pick(s, "EXIF", "XMP ", "unkn"), samples(s, s.Range(0, 64)))
+	}
+
+	n := uint32(len(b) + 4)
+	if s.Chance(badRate * 2) {
+		n = gen.Pick(s, n-1, n+1, n-8, 0, 1<<32-1)
+	}
+	riff := []byte("RIFF")
+	riff = binary.LittleEndian.AppendUint32(riff, n)
+	if s.Chance(badRate) {
+		riff = append(riff, "WAVE"...)
+	} else {
+		riff = append(riff, "WEBP"...)
+	}
+	b = append(riff, b...)
+	switch {
+	case s.Chance(0.02):
+		b = append(b, gen.Pick(s, "\x00", "trailing", "VP8L\x00\x00\x00\x00")...)
+	case s.Chance(badRate * 2):
+		b = b[:s.Intn(len(b)+1)]
+	}
+	return []gen.File{{Name: "input.webp", Data: b}}
+}
+
+// riffChunk appends a chunk to b, padded to an even length, now and then
+// with a bad length or without its padding.
+func riffChunk(s *gen.State, b []byte, id string, data []byte) []byte {
+	n := uint32(len(data))
+	if s.Chance(badRate / 2) {
+		n = gen.Pick(s, n+1, n-1, 1<<31, 1<<32-1)
+	}
+	b = append(b, id...)
+	b = binary.LittleEndian.AppendUint32(b, n)
+	b = append(b, data...)
+	if len(data)%2 == 1 && !s.Chance(badRate) {
+		b = append(b, 0)
+	}
+	return b
+}
+
+// alph returns the data of an alpha chunk for a w by h canvas: its
+// compression, filter and preprocessing, then the alpha values as they
+// are or as the green of a lossless image with no header.
+func alph(s *gen.State, w, h int) []byte {
+	compression := b2u(s.Chance(0.5))
+	if s.Chance(badRate) {
+		compression = gen.Pick[byte](s, 2, 3)
+	}
+	b := []byte{compression | byte(s.Intn(4))<<2 | byte(s.Intn(2))<<4}
+	if compression == 1 && w*h <= 1<<20 {
+		return append(b, vp8l(s, w, h, false)...)
+	}
+	n := w * h
+	if s.Chance(badRate * 2) {
+		n = s.Intn(n + 1)
+	}
+	return append(b, samples(s, min(n, 1<<20))...)
+}
+
+// vp8 returns a lossy key frame of w by h pixels. Its header is written
+// field by field with a boolean encoder, up to the probabilities of the
+// coefficient tokens; those, the modes of the macroblocks and their
+// coefficients are random bytes, which a decoder reads as some picture
+// so long as there are enough of them.
+func vp8(s *gen.State, w, h int) []byte {
+	e := &boolEncoder{rng: 255, count: 24}
+	e.put(s.Chance(0.1), 128) // color space
+	e.put(s.Chance(0.5), 128) // clamping
+	if e.put(s.Chance(0.3), 128) {
+		// Segments, their quantizers and filter strengths, and the
+		// probabilities of the segment map.
+		update := e.put(s.Chance(0.5), 128)
+		if e.put(s.Chance(0.7), 128) {
+			e.put(s.Chance(0.5), 128)
+			for range 4 {
+				e.optional(s.Range(-127, 127), 7)
+			}
+			for range 4 {
+				e.optional(s.Range(-63, 63), 6)
+			}
+		}
+		if update {
+			for range 3 {
+				if e.put(s.Chance(0.5), 128) {
+					e.uint(uint32(s.Intn(256)), 8)
+				}
+			}
+		}
+	}
+	e.put(s.Chance(0.3), 128)                            // simple filter
+	e.uint(uint32(gen.Pick(s, 0, 0, 20, s.Intn(64))), 6) // filter level
+	e.uint(uint32(s.Intn(8)), 3)                         // sharpness
+	if e.put(s.Chance(0.3), 128) && e.put(s.Chance(0.5), 128) {
+		for range 8 {
+			e.optional(s.Range(-63, 63), 6)
+		}
+	}
+	nOP := gen.Pick(s, 0, 0, 0, 1, 2, 3)
+	e.uint(uint32(nOP), 2)
+	e.uint(uint32(s.Intn(128)), 7)
+	for range 5 {
+		e.optional(gen.Pick(s, 0, 0, s.Range(-15, 15)), 4)
+	}
+	e.put(s.Chance(0.5), 128) // refresh entropy probabilities
+	mbs := ((w + 15) / 16) * ((h + 15) / 16)
+	first := append(e.flush(), samples(s, 128+16*mbs)...)
+
+	// The token partitions, each but the last led by its size.
+	parts := make([][]byte, 1<<nOP)
+	for i := range parts {
+		parts[i] = samples(s, (256*mbs)>>nOP+16)
+	}
+	if s.Chance(badRate * 2) {
+		last := len(parts) - 1
+		parts[last] = parts[last][:s.Intn(len(parts[last])+1)]
+	}
+
+	tag := uint32(gen.Pick(s, 0, 0, 0, 1, 2, 3))<<1 | 1<<4 | uint32(len(first))<<5
+	if s.Chance(badRate) {
+		tag |= 1 // an inter frame
+	}
+	if s.Chance(badRate) {
+		tag += uint32(gen.Pick(s, 1, 1000, 1<<18)) << 5
+	}
+	b := []byte{byte(tag), byte(tag >> 8), byte(tag >> 16)}
+	b = append(b, 0x9d, 0x01, 0x2a)
+	if s.Chance(badRate) {
+		b[3] = 0x9c
+	}
+	b = binary.LittleEndian.AppendUint16(b, uint16(w)|uint16(s.Intn(4))<<14)
+	b = binary.LittleEndian.AppendUint16(b, uint16(h)|uint16(s.Intn(4))<<14)
+	b = append(b, first...)
+	for _, p := range parts[:len(parts)-1] {
+		n := len(p)
+		if s.Chance(badRate) {
+			n = 1<<24 - 1
+		}
+		b = append(b, byte(n), byte(n>>8), byte(n>>16))
+	}
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+// boolEncoder is the boolean entropy encoder of VP8, as RFC 6386 gives
+// it: a binary arithmetic coder of bits with a probability out of 256 of
+// being zero.
+type boolEncoder struct {
+	b      []byte
+	rng    uint32
+	bottom uint32
+	count  int
+}
+
+// put writes bit with a prob out of 256 chance of being zero, and
+// returns it.
+func (e *boolEncoder) put(bit bool, prob uint8) bool {
+	split := 1 + (e.rng-1)*uint32(prob)>>8
+	if bit {
+		e.bottom += split
+		e.rng -= split
+	} else {
+		e.rng = split
+	}
+	for e.rng < 128 {
+		e.rng <<= 1
+		if e.bottom&(1<<31) != 0 {
+			e.carry()
+		}
+		e.bottom <<= 1
+		if e.count--; e.count == 0 {
+			e.b = append(e.b, byte(e.bottom>>24))
+			e.bottom &= 1<<24 - 1
+			e.count = 8
+		}
+	}
+	return bit
+}
+
+// carry adds one to the bytes written.
+func (e *boolEncoder) carry() {
+	i := len(e.b) - 1
+	for ; i >= 0 && e.b[i] == 0xff; i-- {
+		e.b[i] = 0
+	}
+	if i >= 0 {
+		e.b[i]++
+	}
+}
+
+// uint writes the n low bits of v, high bit first, each as likely zero
+// as one.
+func (e *boolEncoder) uint(v uint32, n int) {
+	for i := n - 1; i >= 0; i-- {
+		e.put(v>>i&1 != 0, 128)
+	}
+}
+
+// optional writes a flag saying whether v is there and, if it is not
+// zero, its magnitude in n bits and its sign.
+func (e *boolEncoder) optional(v, n int) {
+	if e.put(v != 0, 128) {
+		e.uint(uint32(max(v, -v)), n)
+		e.put(v < 0, 128)
+	}
+}
+
+// flush writes what is left of the bits and returns them all.
+func (e *boolEncoder) flush() []byte {
+	c, v := e.count, e.bottom
+	if v&(1<<(32-c)) != 0 {
+		e.carry()
+	}
+	v <<= c & 7
+	for c >>= 3; c > 0; c-- {
+		v <<= 8
+	}
+	for range 4 {
+		e.b = append(e.b, byte(v>>24))
+		v <<= 8
+	}
+	return e.b
+}
+
+// vp8l returns a lossless image of w by h pixels: its header, unless it
+// is the alpha of a lossy one, its transforms and its pixels.
+func vp8l(s *gen.State, w, h int, header bool) []byte {
+	e := &lossless{s: s}
+	if header {
+		e.write(0x2f, 8)
+		if s.Chance(badRate) {
+			e.b[0] = 0x2e
+		}
+		e.write(uint32(w-1), 14)
+		e.write(uint32(h-1), 14)
+		e.write(uint32(s.Intn(2)), 1)
+		if s.Chance(badRate) {
+			e.write(uint32(s.Range(1, 7)), 3)
+		} else {
+			e.write(0, 3)
+		}
+	}
+	ts := []int{tPredictor, tCrossColor, tSubtractGreen, tColorIndexing}
+	gen.Shuffle(s, ts)
+	ts = ts[:gen.Pick(s, 0, 0, 1, 1, 2, 3, 4)]
+	if len(ts) > 0 && s.Chance(badRate) {
+		ts = append(ts, ts[0])
+	}
+	for _, t := range ts {
+		e.write(1, 1)
+		e.write(uint32(t), 2)
+		switch t {
+		case tPredictor, tCrossColor:
+			b := gen.Pick(s, 2, 2, 3, 4, s.Range(2, 9))
+			e.write(uint32(b-2), 3)
+			e.pixels(tiles(w, b), tiles(h, b), false, func() uint32 {
+				return uint32(s.Intn(14))<<8 | uint32(s.Intn(1<<16))&0xff00ff
+			})
+		case tColorIndexing:
+			n := gen.Pick(s, 1, 2, 3, 4, 5, 16, 17, 256, s.Range(1, 256))
+			e.write(uint32(n-1), 8)
+			e.pixels(n, 1, false, func() uint32 { return uint32(s.Uint64()) })
+			switch {
+			case n <= 2:
+				w = tiles(w, 3)
+			case n <= 4:
+				w = tiles(w, 2)
+			case n <= 16:
+				w = tiles(w, 1)
+			}
+		}
+	}
+	e.write(0, 1)
+	palette := make([]uint32, s.Range(1, 16))
+	for i := range palette {
+		palette[i] = uint32(s.Uint64())
+	}
+	e.pixels(w, h, true, func() uint32 {
+		if s.Chance(0.1) {
+			return uint32(s.Uint64())
+		}
+		return palette[s.Intn(len(palette))]
+	})
+	return e.bytes()
+}
+
+// tiles returns how many tiles of 1<<b pixels it takes to cover n.
+func tiles(n, b int) int {
+	return (n + 1<<b - 1) >> b
+}
+
+// lossless writes a VP8L bit stream, low bits first, as deflate does.
+type lossless struct {
+	s   *gen.State
+	b   []byte
+	acc uint64
+	n   uint
+}
+
+// write writes the n low bits of v.
+func (e *lossless) write(v uint32, n int) {
+	e.acc |= uint64(v&(1<<n-1)) << e.n
+	e.n += uint(n)
+	for e.n >= 8 {
+		e.b = append(e.b, byte(e.acc))
+		e.acc >>= 8
+		e.n -= 8
+	}
+}
+
+// bytes returns the stream, its last bits padded with zeros.
+func (e *lossless) bytes() []byte {
+	if e.n > 0 {
+		e.b = append(e.b, byte(e.acc))
+		e.acc, e.n = 0, 0
+	}
+	return e.b
+}
+
+// The prefix codes of a group, and the size of each alphabet but the
+// first, which grows with the color cache.
+const (
+	hGreen = iota
+	hRed
+	hBlue
+	hAlpha
+	hDistance
+)
+
+var alphabets = [5]int{256 + 24, 256, 256, 256, 40}
+
+// lop is a symbol of one of a group's prefix codes, or bits written as
+// they are if tree is -1.
+type lop struct {
+	tree, group int
+	v           uint32
+	n           int
+}
+
+// pixels writes a w by h image, the main one if top is set, with
+// literals drawn from lit: its color cache, for the main image the
+// entropy image that says which group of prefix codes codes each tile,
+// the groups, then the pixels as literals, backward references and
+// color cache indexes. It returns the pixels a decoder gets, which it
+// follows along.
+func (e *lossless) pixels(w, h int, top bool, lit func() uint32) []uint32 {
+	s := e.s
+	cacheBits := 0
+	if s.Chance(0.3) {
+		cacheBits = s.Range(1, 11)
+	}
+	if s.Chance(badRate) {
+		e.write(1, 1)
+		e.write(uint32(gen.Pick(s, 0, 12, 15)), 4)
+		return nil
+	}
+	e.write(b2u32(cacheBits > 0), 1)
+	if cacheBits > 0 {
+		e.write(uint32(cacheBits), 4)
+	}
+	var entropy []uint32
+	groups, metaBits := 1, 0
+	if top {
+		meta := s.Chance(0.3)
+		e.write(b2u32(meta), 1)
+		if meta {
+			metaBits = gen.Pick(s, 2, 2, 3, 4, s.Range(2, 9))
+			e.write(uint32(metaBits-2), 3)
+			n := s.Range(1, 6)
+			entropy = e.pixels(tiles(w, metaBits), tiles(h, metaBits), false, func() uint32 {
+				return uint32(s.Intn(n)) << 8
+			})
+			if entropy == nil {
+				return nil
+			}
+			for _, v := range entropy {
+				groups = max(groups, int(v>>8&0xffff)+1)
+			}
+		}
+	}
+
+	// The symbols, following along as a decoder reads them.
+	pix := make([]uint32, w*h)
+	var cache []uint32
+	if cacheBits > 0 {
+		cache = make([]uint32, 1<<cacheBits)
+	}
+	var ops []lop
+	p, cached, x, y, g := 0, 0, 0, 0, 0
+	lookup := metaBits != 0
+	for p < len(pix) {
+		if lookup {
+			v := entropy[tiles(w, metaBits)*(y>>metaBits)+x>>metaBits]
+			g = int(v >> 8 & 0xffff)
+		}
+		switch {
+		case p > 0 && s.Chance(0.2):
+			n := min(len(pix)-p, gen.Pick(s, 1, 2, 3, 4, s.Range(1, 64), s.Range(1, 4096)))
+			var code, dist int
+			switch s.Intn(5) {
+			case 0:
+				code, dist = 1, w
+			case 1:
+				code, dist = 2, 1
+			case 2:
+				code, dist = 3, w+1
+			case 3:
+				code, dist = 4, max(w-1, 1)
+			default:
+				dist = s.Range(1, p)
+				code = dist + 120
+			}
+			bad := s.Chance(badRate)
+			if bad {
+				// A reference before the start or past the end.
+				if len(pix)-p+10 > 4096 || s.Chance(0.5) {
+					dist = p + s.Range(1, 10)
+					code = dist + 120
+				} else {
+					n = len(pix) - p + s.Range(1, 10)
+				}
+			} else if dist > p {
+				dist = s.Range(1, p)
+				code = dist + 120
+			}
+			sym, extra, nb := prefix(n)
+			ops = append(ops, lop{hGreen, g, uint32(256 + sym), 0}, lop{-1, g, extra, nb})
+			sym, extra, nb = prefix(code)
+			ops = append(ops, lop{hDistance, g, uint32(sym), 0}, lop{-1, g, extra, nb})
+			if bad {
+				p = len(pix)
+				break
+			}
+			for i := range n {
+				pix[p+i] = pix[p-dist+i]
+			}
+			p += n
+			x += n
+			for x >= w {
+				x, y = x-w, y+1
+			}
+			lookup = metaBits != 0
+			continue
+		case cache != nil && p > 0 && s.Chance(0.2):
+			for ; cached < p; cached++ {
+				cache[pix[cached]*0x1e35a7bd>>(32-cacheBits)] = pix[cached]
+			}
+			i := pix[p-1] * 0x1e35a7bd >> (32 - cacheBits)
+			if s.Chance(0.3) {
+				i = uint32(s.Intn(len(cache)))
+			}
+			pix[p] = cache[i]
+			ops = append(ops, lop{hGreen, g, 256 + 24 + i, 0})
+		default:
+			v := lit()
+			pix[p] = v
+			ops = append(ops, lop{hGreen, g, v >> 8 & 0xff, 0}, lop{hRed, g, v >> 16 & 0xff, 0},
+				lop{hBlue, g, v & 0xff, 0}, lop{hAlpha, g, v >> 24, 0})
+		}
+		p++
+		if x++; x == w {
+			x, y = 0, y+1
+		}
+		lookup = metaBits != 0 && x&(1<<metaBits-1) == 0
+	}
+
+	// The groups' prefix codes, for the symbols each uses.
+	codes := make([][5]code, groups)
+	for gi := range codes {
+		for t := range 5 {
+			var used []int
+			for _, op := range ops {
+				if op.tree == t && op.group == gi && !slices.Contains(used, int(op.v)) {
+					used = append(used, int(op.v))
+				}
+			}
+			n := alphabets[t]
+			if t == hGreen {
+				n += len(cache)
+			}
+			codes[gi][t] = e.tree(n, used)
+		}
+	}
+	for _, op := range ops {
+		if op.tree < 0 {
+			e.write(op.v, op.n)
+		} else {
+			c := codes[op.group][op.tree]
+			e.write(c.codes[op.v], int(c.lens[op.v]))
+		}
+	}
+	return pix
+}
+
+// prefix returns the prefix code of v, which is at least one, for a
+// length or distance, and its extra bits and how many there are.
+func prefix(v int) (sym int, extra uint32, n int) {
+	if v <= 4 {
+		return v - 1, 0, 0
+	}
+	d := v - 1
+	hb := bits.Len(uint(d)) - 1
+	second := d >> (hb - 1) & 1
+	n = hb - 1
+	return 2*hb + second, uint32(d - (2+second)<<n), n
+}
+
+// code is a prefix code as it is written: each symbol's code, bits
+// reversed so that they go out first bit first, and its length, zero
+// for a code of one symbol, which takes no bits.
+type code struct {
+	codes []uint32
+	lens  []uint8
+}
+
+// tree writes a prefix code over an alphabet of n symbols for the
+// symbols used, as a simple code of one or two symbols or a normal code
+// whose code lengths are themselves coded, and returns it. Now and then
+// the code is one no decoder builds.
+func (e *lossless) tree(n int, used []int) code {
+	s := e.s
+	c := code{codes: make([]uint32, n), lens: make([]uint8, n)}
+	if len(used) == 0 {
+		used = []int{0}
+	}
+	gen.Shuffle(s, used)
+	if len(used) <= 2 && used[len(used)-1] < 256 && used[0] < 256 && s.Chance(0.7) {
+		// A simple code: one symbol, of one or eight bits, and perhaps
+		// a second of eight.
+		e.write(1, 1)
+		e.write(uint32(len(used)-1), 1)
+		short := used[0] < 2 && s.Chance(0.5)
+		e.write(b2u32(!short), 1)
+		first, second := used[0], used[len(used)-1]
+		if s.Chance(badRate) {
+			second = first // twice
+		}
+		if short {
+			e.write(uint32(first), 1)
+		} else {
+			e.write(uint32(first), 8)
+		}
+		if len(used) == 2 {
+			e.write(uint32(second), 8)
+			c.codes[used[1]], c.lens[used[0]], c.lens[used[1]] = 1, 1, 1
+		}
+		return c
+	}
+
+	lengths := make([]uint8, n)
+	if len(used) == 1 {
+		lengths[used[0]] = uint8(s.Range(1, 15))
+	} else {
+		for i, l := range shape(s, len(used), 15) {
+			lengths[used[i]] = l
+		}
+		codes := canonical(lengths)
+		for _, sym := range used {
+			l := lengths[sym]
+			c.codes[sym], c.lens[sym] = bits.Reverse32(codes[sym])>>(32-l), l
+		}
+	}
+	if s.Chance(badRate) {
+		// Oversubscribed, or too long.
+		lengths[used[0]] = gen.Pick[uint8](s, 1, 0)
+	}
+
+	// The code lengths, as literal lengths, repeats of the last length
+	// other than zero and runs of zeros, up to the last length that is
+	// not zero or to the end.
+	var ops []lop
+	end := n
+	limit := s.Chance(0.5)
+	if limit {
+		for end > 0 && lengths[end-1] == 0 {
+			end--
+		}
+		if end < 2 {
+			end, limit = n, false
+		}
+	}
+	prev := uint8(8)
+	for i := 0; i < end; {
+		l := lengths[i]
+		run := 1
+		for i+run < end && lengths[i+run] == l {
+			run++
+		}
+		switch {
+		case l == 0 && run >= 11 && s.Chance(0.8):
+			run = min(run, 138)
+			ops = append(ops, lop{0, 0, 18, 0}, lop{-1, 0, uint32(run - 11), 7})
+		case l == 0 && run >= 3 && s.Chance(0.8):
+			run = min(run, 10)
+			ops = append(ops, lop{0, 0, 17, 0}, lop{-1, 0, uint32(run - 3), 3})
+		case l != 0 && l == prev && run >= 3 && s.Chance(0.8):
+			run = min(run, 6)
+			ops = append(ops, lop{0, 0, 16, 0}, lop{-1, 0, uint32(run - 3), 2})
+		default:
+			run = 1
+			ops = append(ops, lop{0, 0, uint32(l), 0})
+			if l != 0 {
+				prev = l
+			}
+		}
+		i += run
+	}
+	if s.Chance(badRate) {
+		// A run of zeros past the end of the alphabet.
+		ops = append(ops, lop{0, 0, 18, 0}, lop{-1, 0, 127, 7})
+		limit = false
+	}
+
+	// The code of the code lengths.
+	var syms []int
+	for _, op := range ops {
+		if op.tree == 0 && !slices.Contains(syms, int(op.v)) {
+			syms = append(syms, int(op.v))
+		}
+	}
+	var ll [19]uint8
+	lc := code{codes: make([]uint32, 19), lens: make([]uint8, 19)}
+	if len(syms) == 1 {
+		ll[syms[0]] = 1
+	} else {
+		for i, l := range shape(s, len(syms), 7) {
+			ll[syms[i]] = l
+		}
+		codes := canonical(ll[:])
+		for _, sym := range syms {
+			l := ll[sym]
+			lc.codes[sym], lc.lens[sym] = bits.Reverse32(codes[sym])>>(32-l), l
+		}
+	}
+	order := []int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	ncodes := 4
+	for i, sym := range order {
+		if ll[sym] != 0 {
+			ncodes = max(ncodes, i+1)
+		}
+	}
+	e.write(0, 1)
+	e.write(uint32(ncodes-4), 4)
+	for _, sym := range order[:ncodes] {
+		e.write(uint32(ll[sym]), 3)
+	}
+	if limit {
+		// How many code length symbols there are, in 2 to 16 bits.
+		count := uint32(0)
+		for _, op := range ops {
+			count += b2u32(op.tree == 0)
+		}
+		nb := max(2, bits.Len32(count-2))
+		nb += nb % 2
+		e.write(1, 1)
+		e.write(uint32(nb-2)/2, 3)
+		e.write(count-2, nb)
+	} else {
+		e.write(0, 1)
+	}
+	for _, op := range ops {
+		if op.tree < 0 {
+			e.write(op.v, op.n)
+		} else {
+			e.write(lc.codes[op.v], int(lc.lens[op.v]))
+		}
+	}
+	return c
+}
+
+// b2u32 returns 1 if b is set, 0 if not.
+func b2u32(b bool) uint32 {
+	return uint32(b2u(b))
+}
@@ -0,0 +1,301 @@
+// Package ipsrc generates seeds for IP address and prefix parsers. It
+// registers the "ip/..." generators with package gen.
+//
+// "ip/addr" writes an address, input.ip, as net.ParseIP and
+// netip.ParseAddr read it: IPv4 in dotted decimal, IPv6 with the zeros
+// compressed anywhere or nowhere, in either case and with leading zeros
+// in its groups, and IPv4 in IPv6 as RFC 4291 maps and embeds it and as
+// NAT64 and SIIT do; loopback, private, link-local and multicast
+// addresses among them; and zones, on IPv6 and not. Now and then one has
+// leading zeros in a dotted field, a field too many or too few, a group
+// of five digits, a second "::" or one where no group is missing, a
+// dotted quad before the end, brackets, or a zone that is empty.
+//
+// "ip/prefix" writes a prefix, input.cidr, as net.ParseCIDR and
+// netip.ParsePrefix read it, the address in the forms "ip/addr" writes
+// and the length from zero to each family's limit, now and then past it,
+// far past it, signed or with leading zeros; then, most often, a space
+// and an address to look up in it, inside it or just outside it, in the
+// prefix's family or mapped into the other.
+package ipsrc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "ip/addr",
+		Doc:  "net.ParseIP and netip.ParseAddr text: dotted IPv4, compressed and expanded IPv6, IPv4-mapped, -compatible, NAT64 and SIIT forms, special-purpose addresses, zones, leading zeros, extra and missing fields, and misplaced ::",
+		Func: addrFile,
+	})
+	gen.Register(&gen.Generator{
+		Name: "ip/prefix",
+		Doc:  "net.ParseCIDR and netip.ParsePrefix text: IPv4 and IPv6 prefixes of every length and past it, signed and zero-padded lengths, zones, and an address to look up inside, outside or mapped across families",
+		Func: prefixFile,
+	})
+}
+
+// badRate is the chance that a part of an address is malformed. An
+// address has a handful of parts, so about one in twelve gets one.
+const badRate = 0.02
+
+// v4s are IPv4 addresses of the special-purpose blocks of RFC 6890 and
+// at the edges of the others: unspecified, loopback, private, shared,
+// link-local, documentation, multicast and broadcast.
+var v4s = [][4]byte{
+	{0, 0, 0, 0}, {127, 0, 0, 1}, {127, 255, 255, 255}, {10, 0, 0, 1}, {10, 255, 255, 255}, {172, 16, 0, 1}, {172, 31, 255, 255},
+	{172, 32, 0, 0}, {192, 168, 1, 1}, {192, 169, 0, 0}, {100, 64, 0, 1}, {169, 254, 169, 254}, {192, 0, 2, 1}, {198, 51, 100, 7},
+	{224, 0, 0, 1}, {224, 0, 0, 251}, {239, 255, 255, 250}, {255, 255, 255, 255}, {1, 1, 1, 1}, {8, 8, 8, 8}, {11, 0, 0, 0},
+}
+
+// v6s are IPv6 addresses of the special-purpose blocks: unspecified,
+// loopback, link-local, unique local, multicast of each scope,
+// documentation, 6to4 and Teredo.
+var v6s = [][8]uint16{
+	{}, {7: 1}, {0xfe80, 7: 1}, {0xfe80, 4: 0x0200, 5: 0x5eff, 6: 0xfe00, 7: 0x5301}, {0xfebf, 7: 0xffff}, {0xfec0, 7: 1},
+	{0xfc00, 7: 1}, {0xfd12, 0x3456, 0x789a, 1, 7: 1}, {0xff01, 7: 1}, {0xff02, 7: 1}, {0xff02, 7: 2}, {0xff05, 7: 0x1003},
+	{0xff0e, 7: 0x101}, {0x2001, 0xdb8, 7: 1}, {0x2001, 0xdb8, 0x85a3, 0, 0, 0x8a2e, 0x370, 0x7334}, {0x2002, 0xc000, 0x0204, 7: 1},
+	{0x2001, 0, 0x4136, 0xe378, 0x8000, 0x63bf, 0x3fff, 0xfdd2}, {0x2606, 0x4700, 0x4700, 7: 0x1111},
+	{0xffff, 0xffff, 0xffff, 0xffff, 0xffff, 0xffff, 0xffff, 0xffff},
+}
+
+// embeddings are the first six groups of the forms that carry an IPv4
+// address in the last two: mapped, compatible, SIIT's translated and
+// NAT64's well-known prefix.
+var embeddings = [][6]uint16{{5: 0xffff}, {}, {4: 0xffff}, {0x64, 0xff9b}, {0x64, 0xff9b, 1}}
+
+// zones are zone identifiers: interface names and indexes, the
+// percent-encoded form a URL holds, and odd ones.
+var zones = []string{"eth0", "en0", "1", "25", "lo", "wlan0.1", "%25eth0", "eth0%", "a/8", "a:b", "é", " ", "0"}
+
+// An address is an IPv4 address or an IPv6 address in groups.
+type address struct {
+	v4     [4]byte
+	v6     [8]uint16
+	is4    bool
+	embeds bool // v6 ends in v4, written dotted
+}
+
+// pick returns an address of the special-purpose blocks, random or
+// holding an IPv4 address the ways IPv6 does.
+func pick(s *gen.State) address {
+	var a address
+	a.v4 = gen.Pick(s, v4s...)
+	if s.Chance(0.2) {
+		for i := range a.v4 {
+			a.v4[i] = byte(s.Intn(256))
+		}
+	}
+	switch {
+	case s.Chance(0.45):
+		a.is4 = true
+	case s.Chance(0.4):
+		e := gen.Pick(s, embeddings...)
+		copy(a.v6[:], e[:])
+		a.v6[6], a.v6[7] = uint16(a.v4[0])<<8|uint16(a.v4[1]), uint16(a.v4[2])<<8|uint16(a.v4[3])
+		a.embeds = s.Chance(0.7)
+	default:
+		a.v6 = gen.Pick(s, v6s...)
+		if s.Chance(0.2) {
+			for i := range a.v6 {
+				a.v6[i] = uint16(s.Intn(1 << 16))
+			}
+		}
+	}
+	return a
+}
+
+// dotted writes b in dotted decimal, now and then with a leading zero, in
+// another base, or with a field too many or too few.
+func dotted(s *gen.State, b [4]byte) string {
+	f := make([]string, 4)
+	for i, v := range b {
+		f[i] = strconv.Itoa(int(v))
+		switch {
+		case s.Chance(badRate):
+			f[i] = gen.Pick(s, "0", "00") + f[i]
+		case s.Chance(badRate / 2):
+			f[i] = gen.Pick(s, fmt.Sprintf("0x%x", v), fmt.Sprintf("0%o", v), "256", "999", "", "-1", "+1", " 1", "١")
+		}
+	}
+	if s.Chance(badRate) {
+		switch s.Intn(4) {
+		case 0:
+			f = f[:s.Range(1, 3)]
+		case 1:
+			f = append(f, "0")
+		case 2:
+			return strconv.FormatUint(uint64(b[0])<<24|uint64(b[1])<<16|uint64(b[2])<<8|uint64(b[3]), 10)
+		default:
+			return strings.Join(f, ".") + "."
+		}
+	}
+	return strings.Join(f, ".")
+}
+
+// groups writes the groups of an IPv6 address, the longest run of zeros
+// compressed to "::" most often, and otherwise another run or none; the
+// last two groups dotted if embeds. Now and then a group has leading
+// zeros or upper case, or the compression is misplaced.
+func groups(s *gen.State, v6 [8]uint16, v4 [4]byte, embeds bool) string {
+	var f []string
+	n := 8
+	if embeds {
+		n = 6
+	}
+	upper := s.Chance(0.1)
+	for _, g := range v6[:n] {
+		h := strconv.FormatUint(uint64(g), 16)
+		if upper {
+			h = strings.ToUpper(h)
+		}
+		if s.Chance(0.05) {
+			h = strings.Repeat("0", 4-len(h)) + h
+		}
+		if s.Chance(badRate / 2) {
+			h = gen.Pick(s, "0"+strings.Repeat("0", 4-len(h))+h, "10000", "g", "", "-1")
+		}
+		f = append(f, h)
+	}
+	if embeds {
+		f = append(f, dotted(s, v4))
+	}
+	// The run of zero groups to compress: the longest, the first, or
+	// none.
+	start, length := -1, 0
+	for i := 0; i < n; {
+		j := i
+		for j < n && v6[j] == 0 {
+			j++
+		}
+		if j-i > length && (j-i > 1 || s.Chance(0.5)) {
+			start, length = i, j-i
+		}
+		i = j + 1
+	}
+	if s.Chance(0.1) {
+		start = -1
+	}
+	if s.Chance(badRate) {
+		switch s.Intn(4) {
+		case 0:
+			start, length = s.Intn(len(f)), 0 // :: where no group is missing
+		case 1:
+			return strings.Join(f[:len(f)/2], ":") + "::" + strings.Join(f[len(f)/2:len(f)/2+1], ":") + "::" + strings.Join(f[len(f)/2+1:], ":")
+		case 2:
+			f = append(f, "1")
+		case 3:
+			if len(f) > 2 {
+				f, start = append(f[:1], f[2:]...), -1 // seven groups, none compressed
+			}
+		}
+	}
+	if start < 0 {
+		return strings.Join(f, ":")
+	}
+	return strings.Join(f[:start], ":") + "::" + strings.Join(f[start+length:], ":")
+}
+
+// text writes a as an address, with a zone now and then.
+func text(s *gen.State, a address, zoned bool) string {
+	var t string
+	if a.is4 {
+		t = dotted(s, a.v4)
+	} else {
+		t = groups(s, a.v6, a.v4, a.embeds)
+	}
+	if zoned && (!a.is4 && s.Chance(0.15) || s.Chance(badRate)) {
+		t += "%" + gen.Pick(s, zones...)
+		if s.Chance(badRate) {
+			t = t[:strings.IndexByte(t, '%')+1]
+		}
+	}
+	if s.Chance(badRate) {
+		t = gen.Pick(s, "["+t+"]", " "+t, t+" ", t+"\n", t+"\x00", t+".", ":"+t, t+"::")
+	}
+	return t
+}
+
+func addrFile(s *gen.State) []gen.File {
+	return []gen.File{{Name: "input.ip", Data: []byte(text(s, pick(s), true))}}
+}
+
+// lengths are prefix lengths past both families' limits, signed, padded
+// or not decimal.
+var lengths = []string{
+	"129", "255", "256", "4294967296", "4294967328", "18446744073709551617", "99999999999999999999999", "16777216",
+	"-1", "+8", "-0", "08", "032", "0128", "00", " 8", "8 ", "", "0x10", "1e1", "8/8", "٨",
+}
+
+func prefixFile(s *gen.State) []gen.File {
+	a := pick(s)
+	limit := 128
+	if a.is4 {
+		limit = 32
+	}
+	bits := gen.Pick(s, 0, 1, limit, limit-1, limit/2, s.Range(0, limit))
+	if !a.is4 {
+		bits = gen.Pick(s, bits, 96, 104, 120)
+	}
+	if a.is4 {
+		bits = gen.Pick(s, bits, 8, 12, 16, 24, 10)
+	}
+	l := strconv.Itoa(bits)
+	switch {
+	case s.Chance(badRate * 4):
+		l = gen.Pick(s, lengths...)
+	case s.Chance(0.05):
+		l = strconv.Itoa(limit + 1)
+	}
+	t := text(s, a, false) + "/" + l
+	if s.Chance(0.1) {
+		t = text(s, a, true) + "/" + l
+	}
+	if s.Chance(0.8) {
+		t += " " + text(s, probe(s, a, bits), false)
+	}
+	return []gen.File{{Name: "input.cidr", Data: []byte(t)}}
+}
+
+// probe returns an address to look up in the prefix of a and bits: a
+// itself, a with a bit past the prefix flipped, so inside it, or with
+// the last bit of the prefix flipped, so outside it; and now and then
+// the IPv4 address a holds or is, mapped into IPv6 or out of it.
+func probe(s *gen.State, a address, bits int) address {
+	p := a
+	flip := func(bit int) {
+		if bit < 0 {
+			return
+		}
+		if p.is4 {
+			bit = min(bit, 31)
+			p.v4[bit/8] ^= 0x80 >> (bit % 8)
+			return
+		}
+		bit = min(bit, 127)
+		p.v6[bit/16] ^= 0x8000 >> (bit % 16)
+		if bit >= 96 {
+			p.v4[(bit-96)/8] ^= 0x80 >> (bit % 8)
+		}
+	}
+	switch s.Intn(3) {
+	case 0:
+		flip(s.Range(bits, bits+8))
+	case 1:
+		flip(bits - 1)
+	}
+	if s.Chance(0.25) {
+		if p.is4 {
+			p.is4, p.embeds = false, true
+			p.v6 = [8]uint16{5: 0xffff, 6: uint16(p.v4[0])<<8 | uint16(p.v4[1]), 7: uint16(p.v4[2])<<8 | uint16(p.v4[3])}
+		} else {
+			p.is4 = true
+		}
+	}
+	return p
+}
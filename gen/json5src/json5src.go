@@ -0,0 +1,262 @@
+// Package json5src generates relaxed JSON seeds. It registers the
+// "json5/..." generators with package gen.
+//
+// A document is written in one of three dialects: strict JSON, JWCC
+// (JSON with comments and trailing commas, as tsconfig.json and editor
+// settings are read) and JSON5. Each extends the one before, so a parser
+// of a wider dialect must read a narrower document the same way. Comments
+// end in the characters the dialects disagree on as line terminators, and
+// some hide an object member from one parser but not another; keys repeat
+// in spellings that decode to the same name, so that which value wins
+// depends on the parser having read every spelling. Most of a document is
+// well formed; each construct also has malformed variants, drawn rarely.
+package json5src
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "json5/doc",
+		Doc:  "JSON, JWCC and JSON5 documents: comments with odd terminators, member-hiding comments, trailing commas, unquoted and respelled duplicate keys, single quotes, line continuations, hex and signed numbers, Infinity, NaN and Unicode whitespace",
+		Func: doc,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// document has a few dozen of them, so about a fifth of documents get one.
+const badRate = 0.005
+
+// The dialects, each a superset of the one before.
+const (
+	strict = iota
+	jwcc
+	json5
+)
+
+// names are the keys an object draws from: few, so that they repeat.
+var names = []string{"a", "admin", "role", "A", "__proto__", "constructor", ""}
+
+// A jdoc accumulates a document in one dialect.
+type jdoc struct {
+	s       *gen.State
+	b       strings.Builder
+	dialect int
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &jdoc{s: s, dialect: s.Intn(3)}
+	if s.Chance(badRate) || d.dialect == json5 && s.Chance(0.05) {
+		d.b.WriteString("\ufeff") // a byte order mark, which JSON5 reads as space
+	}
+	d.space()
+	d.value(s.Depth(s.Limits.Literal, 5))
+	d.space()
+	if d.dialect > strict && s.Chance(0.1) {
+		d.b.WriteString(gen.Pick(s, "// end", "/* end */", "//")) // a comment with no newline after it
+	}
+	if s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(s, "x", "}", ",", "1", "/", "/*", "\x00", "\n{a: 1}"))
+	}
+	return []gen.File{{Name: "doc.json5", Data: []byte(d.b.String())}}
+}
+
+func (d *jdoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+// space writes whitespace or a comment, usually nothing.
+func (d *jdoc) space() {
+	if !d.s.Chance(0.4) {
+		return
+	}
+	switch {
+	case d.dialect > strict && d.s.Chance(0.4):
+		d.comment()
+	case d.dialect == json5 && d.s.Chance(0.1):
+		// Space JSON5 takes from ECMAScript but JSON lacks.
+		d.b.WriteString(gen.Pick(d.s, "\v", "\f", "\u00a0", "\ufeff", "\u2028", "\u2029", "\u2003", "\u3000", "\u1680"))
+	default:
+		d.b.WriteString(gen.Pick(d.s, " ", "\n", "\t", "\r\n", "\r", "  \n\t "))
+	}
+}
+
+// comment writes a comment, some of them looking like the members or
+// comments they enclose.
+func (d *jdoc) comment() {
+	if d.s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(d.s, "/* unterminated", "/", "/* */ */", "/* /* nested */ */", "/*/", "# hash\n", "// \xff\n", "/* \xed\xa0\x80 */"))
+		return
+	}
+	d.b.WriteString(gen.Pick(d.s,
+		"// c\n", "//\n", "/* c */", "/**/", "/***/", "/* * / */", "/* // */", "// /* \n", "/*\n * a\n */",
+		"// \"admin\": true,\n", "/* \"role\": \"root\", */", "// c\r\n", "// é\n", "/*/ */", "//*\n",
+	))
+}
+
+// hidden writes a line comment ending in a character JSON5 takes as a line
+// terminator and JWCC does not, followed by a member: a JSON5 parser reads
+// the member, and a JWCC parser reads it as the rest of the comment.
+func (d *jdoc) hidden(depth int) {
+	d.write("//%s", gen.Pick(d.s, "\r", "\u2028", "\u2029", " x\r"))
+	d.key()
+	d.b.WriteByte(':')
+	d.value(depth)
+	d.b.WriteString(",\n")
+}
+
+func (d *jdoc) value(depth int) {
+	if depth > 0 && d.s.Chance(0.5) {
+		if d.s.Chance(0.5) {
+			d.object(depth - 1)
+		} else {
+			d.array(depth - 1)
+		}
+		return
+	}
+	switch d.s.Intn(4) {
+	case 0:
+		d.b.WriteString(d.number())
+	case 1:
+		d.b.WriteString(gen.Pick(d.s, "true", "false", "null"))
+	default:
+		d.b.WriteString(d.str())
+	}
+}
+
+// comma writes a trailing comma, in the dialects that allow one, or a
+// doubled comma none allows.
+func (d *jdoc) comma(n int) {
+	switch {
+	case n > 0 && d.dialect > strict && d.s.Chance(0.3):
+		d.b.WriteByte(',')
+		d.space()
+	case d.s.Chance(badRate):
+		d.b.WriteString(gen.Pick(d.s, ",", ",,"))
+	}
+}
+
+func (d *jdoc) object(depth int) {
+	d.b.WriteByte('{')
+	n := d.s.Range(0, 5)
+	for i := range n {
+		if i > 0 {
+			d.b.WriteByte(',')
+		}
+		d.space()
+		if d.dialect > strict && d.s.Chance(0.1) {
+			d.hidden(depth)
+		}
+		d.key()
+		d.space()
+		d.b.WriteByte(':')
+		d.space()
+		d.value(depth)
+		d.space()
+	}
+	d.comma(n)
+	d.b.WriteByte('}')
+}
+
+func (d *jdoc) array(depth int) {
+	d.b.WriteByte('[')
+	n := d.s.Range(0, 6)
+	for i := range n {
+		if i > 0 {
+			d.b.WriteByte(',')
+		}
+		d.space()
+		d.value(depth)
+		d.space()
+	}
+	d.comma(n)
+	d.b.WriteByte(']')
+}
+
+// key writes an object key, in JSON5 often in a spelling other than a
+// double-quoted string: bare, single-quoted or escaped.
+func (d *jdoc) key() {
+	name := gen.Pick(d.s, names...)
+	if d.dialect < json5 || name == "" || d.s.Chance(0.3) {
+		switch {
+		case d.s.Chance(0.2):
+			d.b.WriteString(d.str())
+		case name == "a" && d.s.Chance(0.3):
+			d.b.WriteString(`"a"`)
+		default:
+			d.write("%q", name)
+		}
+		return
+	}
+	if d.s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(d.s, "1a", "a-b", "a b", "\\x61", "\\u006", "'a", "@", "a\\", "\\u0030"))
+		return
+	}
+	switch d.s.Intn(4) {
+	case 0:
+		d.write("'%s'", name)
+	case 1:
+		d.b.WriteString(gen.Pick(d.s, `'\x61'`, `'\a'`, `"\x61"`, `\u0061`, `\u0061dmin`, "'a\\\n'"))
+	case 2:
+		// Identifiers ECMAScript allows, some of them reserved words.
+		d.b.WriteString(gen.Pick(d.s, "$", "_", "$a", "_a", "a1", "true", "null", "NaN", "Infinity", "if", "ünï", "ℵ", "ǅ", "a\u200c", "\\u0024"))
+	default:
+		d.b.WriteString(name)
+	}
+}
+
+// number returns a number, in JSON5 often one of the forms JSON lacks.
+func (d *jdoc) number() string {
+	if d.s.Chance(badRate * 4) {
+		return gen.Pick(d.s, "01", "08", "0x", "0x1.8", "0b1", "0o7", "1_0", "Inf", "+-1", "0xg", "--1", "1e", ".", "+", "-NaN", "0X", "١")
+	}
+	if d.dialect == json5 && d.s.Chance(0.5) {
+		return gen.Pick(d.s,
+			"0x1F", "0X1f", "-0xff", "+0x10", "0x0", "0x7FFFFFFFFFFFFFFF", "0x8000000000000000", "0xFFFFFFFFFFFFFFFFFF",
+			"0x20000000000001", "+1", "+0", ".5", "-.5", "5.", "+.5e1", "5.e-1", "Infinity", "-Infinity", "+Infinity", "NaN", "+NaN",
+		)
+	}
+	return gen.Pick(d.s,
+		"0", "-0", "1", "-1", "1.5", "1e3", "1E+2", "2.5e-3", "9007199254740993", "9223372036854775808",
+		"1e400", "-1e400", "1e-400", "4.9e-324", "1.7976931348623157e308", "0.1",
+	)
+}
+
+// str returns a string. JSON5 strings may be single-quoted and take
+// ECMAScript's escapes, including escaped newlines that vanish.
+func (d *jdoc) str() string {
+	quote := "\""
+	if d.dialect == json5 && d.s.Chance(0.3) {
+		quote = "'"
+	}
+	if d.s.Chance(badRate * 4) {
+		return quote + gen.Pick(d.s, "unterminated", `\08`, `\1`, `\x4`, `\u12`, "new\nline", `\`, "\x00", `\u{41}`) + quote
+	}
+	parts := []string{
+		"a", "admin", "é", "😀", "\u2028", "\u2029", `\"`, `\\`, `\/`, `\b`, `\f`, `\n`, `\r`, `\t`,
+		`\u0000`, `\u0061`, `\ud83d\ude00`, `\ud800`, `\udc00`, "\xff", "//", "/*", "*/",
+	}
+	if d.dialect == json5 {
+		parts = append(parts, `\'`, "\\\n", "\\\r\n", "'", "\"")
+		if d.s.Chance(0.2) {
+			// Escapes JSON5 takes from ECMAScript that its parsers tend to
+			// miss, each failing the whole document for them.
+			parts = append(parts, `\x41`, `\xff`, `\0`, `\v`, `\a`, `\z`, "\\\r", "\\\u2028", "\\\u2029")
+		}
+	}
+	var b strings.Builder
+	b.WriteString(quote)
+	for range d.s.Range(0, 6) {
+		p := gen.Pick(d.s, parts...)
+		if p == quote {
+			p = `\` + p
+		}
+		b.WriteString(p)
+	}
+	b.WriteString(quote)
+	return b.String()
+}
@@ -0,0 +1,179 @@
+// Package jsonsrc generates JSON seeds. It registers the "json/..."
+// generators with package gen.
+//
+// Object keys are drawn from the JSON names of the fields of the struct
+// fuzz/json decodes into, in several cases, and from names no field has,
+// so that a seed fills some fields, collides on others and is ignored
+// elsewhere. Most of a document is well formed; each kind of value also
+// has malformed variants, drawn rarely, because a single bad token fails
+// the whole document.
+package jsonsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "json/doc",
+		Doc:  "JSON documents: deep nesting, huge and tiny numbers, duplicate and case-folded keys, surrogate escapes, NaN-like tokens and trailing garbage",
+		Func: doc,
+	})
+}
+
+// badRate is the chance that a value is drawn in a malformed form. A
+// document has a few dozen values, so about a fifth of them get one.
+const badRate = 0.005
+
+// keys are the JSON names of the fields of fuzz/json's struct, some in
+// other cases, and names of no field.
+var keys = []string{
+	"name", "Name", "NAME", "-", "str", "f", "b", "omit", "z", "raw", "num", "any", "ptr", "map", "imap",
+	"arr", "slice", "bytes", "u8", "名前", "a b", "dup", "Dup", "DUP", "Skip", "Inner", "inner", "Dash", "text",
+	"unknown", "", "ſtr", "K", "Key",
+}
+
+// A jdoc accumulates a JSON document.
+type jdoc struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &jdoc{s: s}
+	if s.Chance(badRate) {
+		d.b.WriteString("\ufeff") // a byte order mark, which JSON does not allow
+	}
+	d.space()
+	if s.Chance(0.05) {
+		// Nesting deep enough for the decoder's depth limit, which a
+		// large -depth.lit raises it to.
+		n := s.Depth(s.Limits.Literal, 100)
+		open, close := gen.Pick(s, "[", `{"a":`, `{"ptr":`), gen.Pick(s, "]", "}", "}")
+		d.b.WriteString(strings.Repeat(open, n))
+		d.value(1)
+		d.b.WriteString(strings.Repeat(close, n))
+	} else {
+		d.value(s.Depth(s.Limits.Literal, 5))
+	}
+	d.space()
+	if s.Chance(0.1) {
+		// Trailing garbage, or a second value that a Decoder reads but
+		// Unmarshal rejects.
+		d.b.WriteString(gen.Pick(s, "x", "]", ",", "{}", "1", `"s"`, "null", "\x00", "//", "/* */", "\n{\"name\": 1}"))
+	}
+	return []gen.File{{Name: "doc.json", Data: []byte(d.b.String())}}
+}
+
+func (d *jdoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+// space writes whitespace, usually none.
+func (d *jdoc) space() {
+	if d.s.Chance(0.3) {
+		d.b.WriteString(gen.Pick(d.s, " ", "\n", "\t", "\r\n", "  \n\t "))
+	}
+}
+
+func (d *jdoc) value(depth int) {
+	if depth > 0 && d.s.Chance(0.5) {
+		if d.s.Chance(0.5) {
+			d.object(depth - 1)
+		} else {
+			d.array(depth - 1)
+		}
+		return
+	}
+	switch d.s.Intn(4) {
+	case 0:
+		d.write("%s", d.number())
+	case 1:
+		d.write("%s", gen.Pick(d.s, "true", "false", "null"))
+	default:
+		d.write("%s", d.str())
+	}
+}
+
+func (d *jdoc) object(depth int) {
+	d.b.WriteByte('{')
+	n := d.s.Range(0, 5)
+	for i := range n {
+		if i > 0 {
+			d.b.WriteByte(',')
+		}
+		d.space()
+		if d.s.Chance(0.7) {
+			d.write("%q", gen.Pick(d.s, keys...))
+		} else {
+			d.write("%s", d.str())
+		}
+		d.space()
+		d.b.WriteByte(':')
+		d.space()
+		d.value(depth)
+		d.space()
+	}
+	if n > 0 && d.s.Chance(badRate*4) {
+		d.b.WriteByte(',') // a trailing comma
+	}
+	d.b.WriteByte('}')
+}
+
+func (d *jdoc) array(depth int) {
+	d.b.WriteByte('[')
+	n := d.s.Range(0, 6)
+	for i := range n {
+		if i > 0 {
+			d.b.WriteByte(',')
+		}
+		d.space()
+		d.value(depth)
+	}
+	if n > 0 && d.s.Chance(badRate*4) {
+		d.b.WriteByte(',')
+	}
+	d.b.WriteByte(']')
+}
+
+// number returns a number at or past the limits of the types a decoder
+// puts it in, or not quite a number.
+func (d *jdoc) number() string {
+	if d.s.Chance(badRate * 4) {
+		return gen.Pick(d.s, "01", "1.", ".5", "+1", "1e", "1e+", "0x10", "NaN", "Infinity", "-Infinity", "nan", "-", "--1", "1_000", "\u0661")
+	}
+	if d.s.Chance(0.1) {
+		// A number with hundreds of digits.
+		return gen.Pick(d.s, "", "-") + "1" + strings.Repeat(gen.Pick(d.s, "0", "9"), d.s.Range(20, 1000)) + gen.Pick(d.s, "", ".5", "e-400", "e400")
+	}
+	return gen.Pick(d.s,
+		"0", "-0", "1", "-1", "0.0", "-0.0", "1.5", "1e3", "1E+2", "1e-2", "2.5e-3",
+		"255", "256", "-129", "9007199254740993", "9223372036854775807", "9223372036854775808",
+		"-9223372036854775808", "-9223372036854775809", "18446744073709551615", "18446744073709551616",
+		"1e400", "-1e400", "1e-400", "4.9e-324", "5e-324", "2.4703282292062327e-324", "1.7976931348623157e308",
+		"1.7976931348623159e308", "0.1", "100000000000000000000000", "123456789.123456789e-10",
+	)
+}
+
+// str returns a quoted string with escapes of every kind, some of them
+// surrogates that do not pair up.
+func (d *jdoc) str() string {
+	if d.s.Chance(badRate * 4) {
+		return gen.Pick(d.s, `"unterminated`, `"\x41"`, `"\u12"`, `"\uZZZZ"`, `"\'"`, "\"tab\there\"", "\"new\nline\"", `'single'`, `"\"`, "\"\x00\"", `"\U0001F600"`)
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for range d.s.Range(0, 6) {
+		b.WriteString(gen.Pick(d.s,
+			"a", "name", "Name", "x y", "名前", "é", "😀", "ſ", "\u212a", "<", ">", "&", "\u2028", "\u2029",
+			`\"`, `\\`, `\/`, `\b`, `\f`, `\n`, `\r`, `\t`, `\u0000`, `\u001f`, `\u00e9`, `\u0041`,
+			`\ud83d\ude00`, `\ud800`, `\udc00`, `\udc00\ud800`, `\ud800A`, `\uD83D\uDE00`, `\ud800\ud800\udc00`,
+			`\ufffd`, `\uffff`, `\ufeff`, "\xff", "\xc3", "\xed\xa0\x80", "\x7f",
+		))
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,1045 @@
+// Package jssrc generates JavaScript seeds. It registers the "js/..."
+// generators with package gen.
+//
+// "js/polyglot" writes what gosrc writes for Go: a dense file of the
+// constructs a JavaScript front end finds hardest, for fuzzing the
+// parsers, printers and engines written in Go. Classes with private
+// fields and methods, static blocks, accessors and computed keys extend
+// each other; generators and async generators are driven by for-of and
+// for await; template literals nest in each other and are tagged, with
+// the escapes only a tag may see; regular expressions take every flag,
+// named groups, lookbehind and property escapes, and sit where a slash
+// could be division; optional chains call, index and meet ??; and names,
+// strings and regular expressions are spelled with Unicode escapes and
+// in scripts other than Latin. Between them are the places automatic
+// semicolon insertion and the sloppy mode grammar trip a parser up:
+// statements that begin with a parenthesis or a slash, HTML comments,
+// legacy octal literals, with and the contextual keywords as names. The
+// code runs to completion: loops are bounded and each statement at the
+// top level catches what it throws. A few constructs are malformed.
+package jssrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "js/polyglot",
+		Doc:  "JavaScript files dense with classes, private members and static blocks, generators, async iterators, nested and tagged template literals, regular expression literals of every flag, optional chaining, Unicode escapes and non-Latin names, automatic semicolon insertion hazards and sloppy mode syntax, runnable in an engine with a few malformed constructs",
+		Func: polyglot,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// file has a hundred or so of them, so about one file in eight gets one.
+const badRate = 0.0015
+
+// A ctx is where in a file an expression or statement is written.
+type ctx struct {
+	async  bool // await is an operator
+	gen    bool // yield is an operator
+	fn     bool // return, new.target and arguments are allowed
+	class  bool // private names are in scope
+	loop   bool // break and continue are allowed
+	strict bool // the file is a module or says "use strict"
+}
+
+// A jsgen accumulates a JavaScript file.
+type jsgen struct {
+	s      *gen.State
+	b      strings.Builder
+	depth  int // expressions
+	blocks int // statements
+	module bool
+	strict bool
+	vars   []string // names declared at the top level
+	// hoisted are the names declared by a var at the top of the file.
+	hoisted []string
+}
+
+func polyglot(s *gen.State) []gen.File {
+	g := &jsgen{s: s, depth: s.Depth(s.Limits.Expr, 3), blocks: s.Depth(s.Limits.Block, 3)}
+	g.module = s.Chance(0.1)
+	g.strict = g.module || s.Chance(0.2)
+	var head string
+	if s.Chance(0.1) {
+		head += "#!/usr/bin/env node\n"
+	}
+	if g.strict && !g.module {
+		head += gen.Pick(s, `"use strict";`, `'use strict'`) + "\n"
+	} else if s.Chance(0.05) {
+		head += `"use\x20strict";` + "\n" // not a directive
+	}
+	c := ctx{strict: g.strict, async: g.module}
+	for range s.Range(4, 14) {
+		g.item(c)
+	}
+	if g.module && s.Chance(0.7) {
+		g.b.WriteString(g.export())
+	}
+	if len(g.hoisted) > 0 {
+		head += "var " + strings.Join(g.hoisted, ", ") + ";\n"
+	}
+	return []gen.File{{Name: "input.js", Data: []byte(head + g.b.String())}}
+}
+
+func (g *jsgen) broken() bool { return g.s.Chance(badRate) }
+
+// item writes a statement at the top level, most of them inside a try
+// that catches what they throw.
+func (g *jsgen) item(c ctx) {
+	s := g.s
+	var text string
+	switch s.Intn(14) {
+	case 0, 1:
+		text = g.class(c)
+	case 2:
+		text = g.generator(c)
+	case 3:
+		text = g.asyncIter(c)
+	case 4:
+		text = g.templates(c)
+	case 5:
+		text = g.regexps(c)
+	case 6:
+		text = g.chains(c)
+	case 7:
+		text = g.escapes(c)
+	case 8:
+		text = g.hazard(c)
+	case 9:
+		text = g.labels(c)
+	default:
+		text = g.stmt(c, g.blocks)
+	}
+	if s.Chance(0.15) {
+		g.b.WriteString(g.comment())
+	}
+	if s.Chance(0.7) {
+		text = "try {\n" + text + "\n} catch" + gen.Pick(s, " ", " (e) ", " ({ message }) ") + "{}"
+	}
+	g.b.WriteString(text + "\n")
+}
+
+// declare returns a fresh name for a declaration at the top level.
+func (g *jsgen) declare(prefix string) string {
+	name := g.s.Fresh(prefix)
+	g.vars = append(g.vars, name)
+	return name
+}
+
+// names are identifiers, many of them spelled with escapes or in other
+// scripts. Each is declared once at the top of the file that uses it.
+var names = []string{
+	"café", "ǅemal", "ゆ", "π", "Ωmega", "имя", "名前", "변수", "தம", "x‌y", "x‍y", "$", "_", "$$", "_$",
+	`abc`, `\u{62}cd`, `\u{1D465}`, "𝑥", "𐊧", `a\u{200C}b`, "℮", "℘", "ゞ", "ᢅ", `ℹ`,
+}
+
+// sloppyNames are identifiers only sloppy mode outside generators and
+// async functions allows.
+var sloppyNames = []string{"let", "yield", "static", "implements", "package", "interface"}
+
+// contextual are keywords that are identifiers everywhere.
+var contextual = []string{"async", "of", "get", "set", "from", "as", "target", "meta", "accessor", "constructor"}
+
+// ident returns an identifier to use, declared or a global.
+func (g *jsgen) ident(c ctx) string {
+	s := g.s
+	if len(g.vars) > 0 && s.Chance(0.6) {
+		return gen.Pick(s, g.vars...)
+	}
+	return gen.Pick(s, "globalThis", "Object", "Array", "Math", "JSON", "Symbol", "Promise", "String", "undefined", "NaN", "Infinity", "Reflect", "RegExp")
+}
+
+// binding returns a name to declare.
+func (g *jsgen) binding(c ctx) string {
+	s := g.s
+	switch {
+	case s.Chance(0.1):
+		return gen.Pick(s, names...) + g.s.Fresh("")
+	case s.Chance(0.05):
+		return gen.Pick(s, contextual...) + g.s.Fresh("")
+	}
+	return g.s.Fresh("v")
+}
+
+func (g *jsgen) comment() string {
+	s := g.s
+	c := gen.Pick(s, "// line comment\n", "/* block */ ", "/* multi\n line */\n", "/** @type {number} */ ", "// ", "/*   */", "/*/ */ ", "/**/")
+	if !g.module && s.Chance(0.15) {
+		c = gen.Pick(s, "<!-- HTML open comment\n", "\n--> HTML close comment\n", "/*\n*/--> after a block\n")
+	}
+	return c
+}
+
+// number returns a numeric literal.
+func (g *jsgen) number(c ctx) string {
+	s := g.s
+	if !c.strict && s.Chance(0.05) {
+		return gen.Pick(s, "017", "08", "09.5", "0777", "00") // legacy octal and decimal
+	}
+	if g.broken() {
+		return gen.Pick(s, "1__0", "1_", "0_1", "08n", "1.5n", "0x", "1e", "0b2", "3in[]", "0xG")
+	}
+	return gen.Pick(s, "0", "1", "-1", "42", "1_000_000", "0xff", "0xFF_FF", "0b1010", "0o17", "0B1", "0O7_7",
+		".5e-3", "5.", "1e400", "2e-324", "0.1", "1E+21", "9007199254740993", "1n", "0xffn", "9007199254740993n", "123_456n",
+		"0.0000001", "1_0.0_1e1_0")
+}
+
+// str returns a string literal.
+func (g *jsgen) str(c ctx) string {
+	s := g.s
+	if !c.strict && s.Chance(0.05) {
+		return gen.Pick(s, `"\08"`, `'\101'`, `"\7"`, `'\377'`) // legacy octal escapes
+	}
+	if g.broken() {
+		return gen.Pick(s, `"\u{110000}"`, `'\x4'`, `"\u12"`, "'unterminated\n'", `"\u{}"`)
+	}
+	return gen.Pick(s, `""`, `''`, `"abc"`, `'it\'s'`, `"\u{1F600}"`, `"😀"`, `"\uD800"`, `"\x41\x00"`, `'\0'`,
+		`"  "`, "'  raw separators  '", `"line \`+"\n"+`continued"`, `"tab\there"`, `'\v\f\b'`,
+		`"</script>"`, `"<!--"`, `"日本語"`, `"\u{10FFFF}"`, `'${not} a template'`, `"\\"`, `"\q\a\c"`)
+}
+
+// regexps are regular expression literals without their flags, and the
+// flags they may take: u for those that need it, v for those that need
+// the sets v brings, and any of the rest otherwise.
+var regexps = []struct{ re, flags string }{
+	{`/(?<year>\d{4})-(?<month>\d\d)-(?<day>\d\d)/`, ""},
+	{`/(?<=\$)\d+(\.\d*)?/`, ""},
+	{`/(?<!\\)"/`, ""},
+	{`/\p{Script=Greek}+/`, "u"},
+	{`/\p{L}\P{Lu}\p{Emoji_Presentation}/`, "u"},
+	{`/[\p{L}--[a-z]]/`, "v"},
+	{`/[\q{abc|d}[e&&[a-z]]]/`, "v"},
+	{`/\u{1F600}+/`, "u"},
+	{`/(?<a>x)\k<a>/`, ""},
+	{`/\k<a>/`, ""}, // an identity escape without named groups
+	{`/[/]/`, ""},
+	{`/\//`, ""},
+	{`/=/`, ""},
+	{`/=>/`, ""},
+	{`/ /`, ""},
+	{`/[\]]/`, ""},
+	{`/a{2,}?b*?c+?/`, ""},
+	{`/^[^]$/`, ""},
+	{`/[]/`, ""},
+	{`/\cJ\cj\c1/`, ""},
+	{`/(a)|\1b/`, ""},
+	{`/((((((((((a))))))))))\10/`, ""},
+	{`/\0\x41A\101/`, ""},
+	{`/a{,5}/`, ""},
+	{`/{/`, ""},
+	{`/]/`, ""},
+	{`/(?:a|b)*?(?=c)(?!d)/`, ""},
+	{`/.\s\S\w\W\d\D\b\B/`, ""},
+	{`/[\u{1F600}-\u{1F64F}]/`, "u"},
+	{`/\//*comment?*/`, ""},
+}
+
+// regexp returns a regular expression literal.
+func (g *jsgen) regexp() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, `/(?<a>x)(?<a>y)/`, `/a/gg`, `/[z-a]/`, `/(/`, `/\p{Nope}/u`, `/a/uv`, `/a**/`, `/x/q`, `/(?<1a>x)/`)
+	}
+	r := gen.Pick(s, regexps...)
+	flags := ""
+	for _, f := range "dgimsy" {
+		if s.Chance(0.2) {
+			flags += string(f)
+		}
+	}
+	switch {
+	case r.flags != "":
+		flags += r.flags
+	case s.Chance(0.1):
+		flags += gen.Pick(s, "u", "v")
+	}
+	// u and v make unknown escapes and lone braces errors.
+	if strings.ContainsAny(flags, "uv") && r.flags == "" && strings.ContainsAny(r.re, `{}]\`) {
+		flags = strings.NewReplacer("u", "", "v", "").Replace(flags)
+	}
+	return r.re + flags
+}
+
+// template returns a template literal, nesting others in its
+// substitutions.
+func (g *jsgen) template(c ctx, d int) string {
+	s := g.s
+	var b strings.Builder
+	tagged := s.Chance(0.3)
+	if tagged {
+		b.WriteString(gen.Pick(s, "String.raw", g.ident(c), "((s, ...v) => s.raw.join(v))", "(function () { return arguments; })"))
+		if g.broken() {
+			b.Reset()
+			b.WriteString("a?.b") // a tagged template in an optional chain
+		}
+	}
+	b.WriteByte('`')
+	for range s.Range(0, 3) {
+		b.WriteString(gen.Pick(s, "text", "", " ", "$", "}", `\${}`, "\\`", "\n", "\r\n", `\u{1F600}`, `\x41`, "日本", `\\`, "$ {}", " "))
+		if tagged && s.Chance(0.2) {
+			b.WriteString(gen.Pick(s, `\unicode`, `\xg`, `\u{`, `\01`, `\u{110000}`)) // escapes only a tag may see
+		}
+		if s.Chance(0.6) {
+			b.WriteString("${")
+			if d > 0 && s.Chance(0.3) {
+				b.WriteString(g.template(c, d-1))
+			} else {
+				b.WriteString(g.expr(c, d-1))
+			}
+			b.WriteString(gen.Pick(s, "}", "}", " }", "/* } */}"))
+		}
+	}
+	b.WriteByte('`')
+	return b.String()
+}
+
+// expr returns an expression at most d deep.
+func (g *jsgen) expr(c ctx, d int) string {
+	s := g.s
+	if d <= 0 {
+		return g.primary(c)
+	}
+	switch s.Intn(22) {
+	case 0, 1:
+		op := gen.Pick(s, "+", "-", "*", "/", "%", "**", "<<", ">>", ">>>", "&", "|", "^", "&&", "||", "??", "==", "===", "!=", "!==", "<", ">", "<=", ">=", "in", "instanceof", ",")
+		return "(" + g.expr(c, d-1) + " " + op + " " + g.expr(c, d-1) + ")"
+	case 2:
+		// Precedence without parentheses.
+		var b strings.Builder
+		b.WriteString(g.primary(c))
+		for range s.Range(1, 4) {
+			b.WriteString(gen.Pick(s, " + ", " - ", " * ", " / ", " % ", " < ", " << ", " & ", " | ", " === ", " && ", " || "))
+			b.WriteString(g.primary(c))
+		}
+		return "(" + b.String() + ")"
+	case 3:
+		op := gen.Pick(s, "!", "~", "-", "+", "typeof ", "void ", "- -", "+ +", "- -- ", "!!", "~~")
+		if op == "- -- " {
+			return "(" + op + g.fresh() + ")"
+		}
+		return op + "(" + g.expr(c, d-1) + ")"
+	case 4:
+		return "(" + g.expr(c, d-1) + " ? " + g.expr(c, d-1) + " : " + g.expr(c, d-1) + ")"
+	case 5:
+		return g.arrow(c, d)
+	case 6:
+		return g.call(c, d)
+	case 7:
+		return g.member(c, d)
+	case 8:
+		return g.chain(c, d)
+	case 9:
+		return g.template(c, d-1)
+	case 10:
+		return g.regexp()
+	case 11:
+		return g.object(c, d)
+	case 12:
+		return g.array(c, d)
+	case 13:
+		return "(" + g.classExpr(c, d) + ")"
+	case 14:
+		return "new " + gen.Pick(s, "Map", "Set", "Date", "Error", "WeakRef", "Array", "Object", "Promise(r => r())", "(class {})") + gen.Pick(s, "", "()", "(1, 2)", "(...[])")
+	case 15:
+		if c.async {
+			return "(" + gen.Pick(s, "await ", "await await ") + g.expr(c, d-1) + ")"
+		}
+	case 16:
+		if c.gen {
+			if s.Chance(0.3) {
+				return "(yield* [" + g.expr(c, d-1) + "])"
+			}
+			return "(yield " + g.expr(c, d-1) + ")"
+		}
+	case 17:
+		target := g.fresh()
+		op := gen.Pick(s, " = ", " ??= ", " ||= ", " &&= ", " **= ", " >>>= ", " += ")
+		return "(" + target + op + g.expr(c, d-1) + ")"
+	case 18:
+		if s.Chance(0.5) {
+			return "([" + g.arrayPattern() + "] = [1, [2], 3])"
+		}
+		return "({" + g.objectPattern() + "} = { a: 1, b: 2 })"
+	case 19:
+		return g.function(c, d)
+	case 20:
+		if c.fn && !c.async && s.Chance(0.5) {
+			return gen.Pick(s, "new.target", "arguments", "arguments.length", "this")
+		}
+		if c.class {
+			return gen.Pick(s, "#p in this", "this.#p", "this?.#p")
+		}
+	}
+	return g.primary(c)
+}
+
+// fresh returns a fresh variable to assign to. It is declared with var
+// at the top of the file.
+func (g *jsgen) fresh() string {
+	name := g.declare("t")
+	g.hoisted = append(g.hoisted, name)
+	return name
+}
+
+// arrayPattern returns the inside of an array pattern that assigns
+// fresh variables.
+func (g *jsgen) arrayPattern() string {
+	a, b := g.fresh(), g.fresh()
+	return gen.Pick(g.s,
+		a+", "+b,
+		a+" = 1, ..."+b,
+		", "+a+", , "+b,
+		a+", ["+b+"] = [[]]",
+		"{ a: "+a+" } = {}, ...["+b+"]",
+	)
+}
+
+// objectPattern returns the inside of an object pattern that assigns
+// fresh variables.
+func (g *jsgen) objectPattern() string {
+	a, b := g.fresh(), g.fresh()
+	return gen.Pick(g.s,
+		"a: "+a+", b: "+b,
+		"a: "+a+" = 1, ..."+b,
+		"a: { x: "+a+" } = {}, b: ["+b+"] = []",
+		`"a": `+a+", [`b`]: "+b,
+		a+", "+b+" = 2",
+	)
+}
+
+// primary returns an expression that needs no parentheses.
+func (g *jsgen) primary(c ctx) string {
+	s := g.s
+	switch s.Intn(10) {
+	case 0, 1, 2:
+		return g.ident(c)
+	case 3, 4:
+		return g.number(c)
+	case 5, 6:
+		return g.str(c)
+	case 7:
+		return gen.Pick(s, "this", "null", "true", "false", "undefined", "void 0", "[]", "``")
+	case 8:
+		return gen.Pick(s, "Symbol.iterator", "Symbol.asyncIterator", "Symbol()", "Math.PI", "Number.MAX_SAFE_INTEGER", "-0", "globalThis")
+	}
+	return g.template(c, 0)
+}
+
+// arrow returns an arrow function.
+func (g *jsgen) arrow(c ctx, d int) string {
+	s := g.s
+	async := s.Chance(0.3)
+	inner := ctx{async: async, strict: c.strict, class: c.class}
+	params := gen.Pick(s, "()", "x", "(x)", "(x, y = 1)", "(...r)", "({ a, b } = {})", "([a, , b])", "(x,)", "(a, { b: [c] })")
+	if g.broken() {
+		params = gen.Pick(s, "(x, x)", "(...r,)", "(a = await 1)", "(1)")
+	}
+	head := params
+	if async {
+		head = "async " + head
+		if params == "x" && s.Chance(0.5) {
+			head = "async x"
+		}
+	}
+	var body string
+	if s.Chance(0.5) {
+		body = g.expr(inner, d-1)
+		if strings.HasPrefix(body, "{") {
+			body = "(" + body + ")"
+		}
+	} else {
+		inner.fn = true
+		body = "{ " + g.stmt(inner, 1) + " return " + g.expr(inner, d-1) + "; }"
+	}
+	return "(" + head + " => " + body + ")"
+}
+
+// function returns a function expression of any kind.
+func (g *jsgen) function(c ctx, d int) string {
+	s := g.s
+	async, star := s.Chance(0.3), s.Chance(0.3)
+	inner := ctx{async: async, gen: star, fn: true, strict: c.strict, class: c.class}
+	head := "function"
+	if star {
+		head += gen.Pick(s, "*", " *", "* ")
+	}
+	if async {
+		head = "async " + head
+	}
+	if s.Chance(0.5) {
+		head += " " + g.s.Fresh("f")
+	}
+	return "(" + head + "(" + gen.Pick(s, "", "a", "a, b", "...r", "a = 1, { b } = {}") + ") { " + g.stmt(inner, 1) + " return " + g.expr(inner, d-1) + "; })"
+}
+
+// call returns a call.
+func (g *jsgen) call(c ctx, d int) string {
+	s := g.s
+	callee := gen.Pick(s, "Math.max", "String", "Array.of", "Object.keys", "JSON.stringify", "Promise.resolve", "Array.from", "Reflect.ownKeys", "BigInt", "(x => x)", "parseInt")
+	var args []string
+	for range s.Range(0, 3) {
+		a := g.expr(c, d-1)
+		if s.Chance(0.15) {
+			a = "...[" + a + "]"
+		}
+		args = append(args, a)
+	}
+	trail := ""
+	if len(args) > 0 && s.Chance(0.1) {
+		trail = ","
+	}
+	return callee + "(" + strings.Join(args, ", ") + trail + ")"
+}
+
+// member returns a property access.
+func (g *jsgen) member(c ctx, d int) string {
+	s := g.s
+	obj := "(" + g.expr(c, d-1) + ")"
+	switch s.Intn(5) {
+	case 0:
+		return obj + "[" + g.expr(c, d-1) + "]"
+	case 1:
+		return obj + "." + gen.Pick(s, "if", "class", "new", "await", "yield", "\\u0069f", "length", "π", "\\u{62}")
+	case 2:
+		return gen.Pick(s, "1..toString()", "1.0.toFixed(2)", "1 .valueOf()", "0x10.toString(16)", "(1).constructor", "''.length")
+	}
+	return obj + "." + gen.Pick(s, "length", "constructor", "toString()", "valueOf()", "x", "__proto__", "raw")
+}
+
+// chain returns an optional chain.
+func (g *jsgen) chain(c ctx, d int) string {
+	s := g.s
+	base := gen.Pick(s, g.ident(c), "null", "undefined", "({ a: { b() { return 1; } } })", "[[1]]", "(void 0)")
+	var b strings.Builder
+	b.WriteString(base)
+	for range s.Range(1, 4) {
+		b.WriteString(gen.Pick(s, "?.a", "?.[0]", "?.()", "?.b()", ".a", "?.[\"b\"]", "?.\\u0061", "?.["+g.primary(c)+"]"))
+	}
+	if c.class && s.Chance(0.2) {
+		b.WriteString("?.#p")
+	}
+	text := b.String()
+	switch s.Intn(6) {
+	case 0:
+		return "(" + text + " ?? " + g.expr(c, d-1) + ")"
+	case 1:
+		return "(" + text + ").c" // parentheses end the chain
+	case 2:
+		return "(" + g.ident(c) + "?.5:1)" // a conditional, not a chain
+	}
+	if g.broken() {
+		return gen.Pick(s, "new a?.b()", "a?.b = 1", "a?.b`t`", "a?.b++", "delete a?.#p", "(a?.b) ??"+" c || d")
+	}
+	return text
+}
+
+// object returns an object literal.
+func (g *jsgen) object(c ctx, d int) string {
+	s := g.s
+	var props []string
+	for range s.Range(0, 5) {
+		switch s.Intn(12) {
+		case 0:
+			props = append(props, g.declareRef())
+		case 1:
+			props = append(props, "a: "+g.expr(c, d-1))
+		case 2:
+			props = append(props, "["+g.expr(c, d-1)+"]: "+g.expr(c, d-1))
+		case 3:
+			props = append(props, "..."+g.expr(c, d-1))
+		case 4:
+			props = append(props, "get x() { return "+g.expr(ctx{fn: true, strict: c.strict}, d-1)+"; }", "set x(v) {}")
+		case 5:
+			props = append(props, gen.Pick(s, "async *m() { yield 1; }", "async m() { await 0; }", "*g() { yield* [1]; }", "m() { return super.toString(); }"))
+		case 6:
+			props = append(props, gen.Pick(s, `"str": 1`, "1e3: 2", "0x10: 3", "1n: 4", `"__proto__": null`, "__proto__: null", "if: 5", "get: 6", "set: 7", "async: 8", "await: 9", `a: 10`, "π: 11"))
+		case 7:
+			props = append(props, gen.Pick(s, "get() {}", "set() {}", "async() {}", "static() {}", "get [Symbol.iterator]() { return [][Symbol.iterator]; }"))
+		default:
+			props = append(props, gen.Pick(s, "a", "b", "c")+": "+g.primary(c))
+		}
+	}
+	if g.broken() {
+		props = append(props, gen.Pick(s, "__proto__: 1, __proto__: 2", "a = 1", "get x(v) {}", "set x() {}", "async get x() {}"))
+	}
+	trail := ""
+	if len(props) > 0 && s.Chance(0.2) {
+		trail = ","
+	}
+	return "({ " + strings.Join(props, ", ") + trail + " })"
+}
+
+// declareRef returns a shorthand property of a declared name.
+func (g *jsgen) declareRef() string {
+	if len(g.vars) == 0 {
+		return "globalThis"
+	}
+	return gen.Pick(g.s, g.vars...)
+}
+
+// array returns an array literal.
+func (g *jsgen) array(c ctx, d int) string {
+	s := g.s
+	var elems []string
+	for range s.Range(0, 5) {
+		switch s.Intn(6) {
+		case 0:
+			elems = append(elems, "")
+		case 1:
+			elems = append(elems, "..."+g.expr(c, d-1))
+		case 2:
+			elems = append(elems, g.array(c, d-1))
+		default:
+			elems = append(elems, g.expr(c, d-1))
+		}
+	}
+	return "[" + strings.Join(elems, ", ") + gen.Pick(s, "", "", ",", ", ,") + "]"
+}
+
+// classExpr returns a class expression.
+func (g *jsgen) classExpr(c ctx, d int) string {
+	return "class" + g.classBody(c, d, gen.Pick(g.s, "", " "+g.s.Fresh("K")))
+}
+
+// classBody returns the heritage and body of a class named name, which
+// is empty or starts with a space.
+func (g *jsgen) classBody(c ctx, d int, name string) string {
+	s := g.s
+	inner := ctx{class: true, strict: true}
+	var b strings.Builder
+	b.WriteString(name)
+	extends := s.Chance(0.5)
+	if extends {
+		b.WriteString(" extends " + gen.Pick(s, "Object", "Array", "Error", "Map", "(class {})", "(Object ?? Array)", "(function () {})", "null"))
+	}
+	b.WriteString(" {\n")
+	b.WriteString("  #p = " + g.expr(inner, d-1) + ";\n")
+	field := gen.Pick(s, "x", "'quoted'", "123", "[Symbol.toStringTag]", "static", "get", "async", "await", "accessor", "\\u0061b")
+	switch field {
+	case "static", "get", "async", "accessor":
+		// A line break after these does not end the field.
+		field += gen.Pick(s, ";", " = 2;")
+	default:
+		field += gen.Pick(s, ";", " = 2;", "\n")
+	}
+	b.WriteString("  static #s" + gen.Pick(s, ";", " = 1;", ";;") + "\n")
+	members := []string{
+		"  " + field,
+		"  static {\n    " + g.stmt(ctx{class: true, strict: true}, 1) + "\n  }\n",
+		"  get #g() { return this.#p; }\n  set #g(v) { this.#p = v; }\n",
+		"  #m() { return #p in this; }\n",
+		"  static async *gen() { yield* [1, 2]; await null; }\n",
+		"  *[Symbol.iterator]() { yield this.#p; }\n",
+		"  get [`computed${1}`]() { return 1; }\n",
+		"  static m() { return this.#s; }\n",
+		"  async m() { return await this?.#p?.(); }\n",
+		"  'constructor'() {" + ctorBody(extends) + "}\n",
+		"  static constructor() {}\n",
+		"  static prototype" + "2" + "() {}\n",
+		"  get() {}\n  set() {}\n  static() {}\n  async() {}\n",
+		"  \\u0069f() {}\n",
+	}
+	gen.Shuffle(s, members)
+	for i, m := range members[:s.Range(2, 8)] {
+		if strings.HasPrefix(m, "  'constructor'") && i > 0 {
+			continue // once
+		}
+		b.WriteString(m)
+	}
+	if g.broken() {
+		b.WriteString(gen.Pick(s, "  constructor() {}\n  constructor() {}\n", "  #p;\n", "  get constructor() {}\n", "  #constructor() {}\n", "  static prototype() {}\n", "  m() { delete this.#p; }\n", "  m() { return this.#undeclared; }\n"))
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// ctorBody returns the body of a constructor that calls super when it
+// has to.
+func ctorBody(extends bool) string {
+	if extends {
+		return " super(); this.#p = new.target; "
+	}
+	return " this.#p = new.target; "
+}
+
+// class writes a class declaration and uses it.
+func (g *jsgen) class(c ctx) string {
+	s := g.s
+	name := g.declare("C")
+	text := "class" + g.classBody(c, g.depth, " "+name) + "\n"
+	sub := g.declare("C")
+	text += "class " + sub + " extends " + name + " {\n  #p = 1;\n  constructor(...a) { super(...a); }\n  static { this.x = super.constructor; }\n  m() { return super.m?.() ?? #p in this; }\n}\n"
+	text += gen.Pick(s, "new "+sub+"();", "new "+name+"().m?.();", "[...new "+sub+"()];", "Object.getOwnPropertyNames("+name+");", "String("+name+");")
+	return text
+}
+
+// generator writes a generator function and drives it.
+func (g *jsgen) generator(c ctx) string {
+	s := g.s
+	name := g.declare("g")
+	inner := ctx{gen: true, fn: true, strict: c.strict}
+	var b strings.Builder
+	fmt.Fprintf(&b, "function%s %s(n = 3) {\n", gen.Pick(s, "*", " *", "* "), name)
+	b.WriteString("  let i = 0;\n")
+	b.WriteString("  while (i < n) {\n    const x = yield i++;\n    if (x) " + gen.Pick(s, "continue;", "break;", "return x;", "yield x;") + "\n  }\n")
+	b.WriteString("  " + g.stmt(inner, 1) + "\n")
+	b.WriteString("  yield" + gen.Pick(s, "* [1, 2]", "*[]", " /re/g", "\n/ 2 /g", " yield 1", "") + ";\n")
+	b.WriteString("  try { yield 1; } finally { " + gen.Pick(s, "return 9;", "yield 2;", "") + " }\n")
+	b.WriteString("  return i;\n}\n")
+	b.WriteString(gen.Pick(s,
+		"for (const v of "+name+"()) {}",
+		"["+`...`+name+"(5)];",
+		"{ const it = "+name+"(); it.next(); it.next(true); it.return(1); it.next(); }",
+		"{ const it = "+name+"(); it.next(); try { it.throw(new Error('x')); } catch {} }",
+		"Array.from("+name+"(2), (x) => x * 2);",
+		"const ["+g.declare("a")+", ..."+g.declare("a")+"] = "+name+"(4);",
+	))
+	return b.String()
+}
+
+// asyncIter writes an async generator, an object with its own async
+// iterator, and a loop awaiting both.
+func (g *jsgen) asyncIter(c ctx) string {
+	s := g.s
+	name, obj := g.declare("ag"), g.declare("ai")
+	inner := ctx{async: true, gen: true, fn: true, strict: c.strict}
+	var b strings.Builder
+	fmt.Fprintf(&b, "async function* %s() {\n", name)
+	b.WriteString("  yield 1;\n  await null;\n  yield* [2, Promise.resolve(3)];\n")
+	b.WriteString("  yield " + g.expr(inner, g.depth) + ";\n")
+	b.WriteString("  for await (const x of [Promise.resolve(4), 5]) yield x;\n}\n")
+	fmt.Fprintf(&b, "const %s = {\n  [Symbol.asyncIterator]() {\n    let i = 0;\n    return { next: () => Promise.resolve({ value: i, done: i++ > %d }), return: async () => ({ done: true }) };\n  },\n};\n", obj, s.Range(0, 3))
+	loop := gen.Pick(s, "const v", "let v", "var v", g.fresh(), "const [v]", "const { value }")
+	body := gen.Pick(s, "{}", "{ break; }", "{ continue; }", "{ await 0; }")
+	src := gen.Pick(s, name+"()", obj)
+	if g.module && s.Chance(0.5) {
+		fmt.Fprintf(&b, "for await (%s of %s) %s\n", loop, src, body) // top-level await
+	} else {
+		fmt.Fprintf(&b, "(async () => {\n  for await (%s of %s) %s\n  for await (const w of %s) {}\n})();", loop, src, body, obj)
+	}
+	if g.broken() {
+		b.WriteString(gen.Pick(s, "\nfor await (x of y) {}", "\nasync function* f() { yield* await; }", "\nfunction f() { for await (x of y); }", "\nasync () => { for await (x in y); }"))
+	}
+	return b.String()
+}
+
+// templates writes template literals and tags.
+func (g *jsgen) templates(c ctx) string {
+	s := g.s
+	tag := "tag" + g.s.Fresh("")
+	var b strings.Builder
+	fmt.Fprintf(&b, "function %s(strings, ...values) { return strings.raw.map((r, i) => r + (values[i] ?? '')).join('') + strings.length; }\n", tag)
+	for range s.Range(1, 3) {
+		t := g.template(c, g.depth)
+		if strings.HasPrefix(t, "`") && s.Chance(0.4) {
+			t = tag + t
+		}
+		b.WriteString("const " + g.declare("s") + " = " + t + ";\n")
+	}
+	b.WriteString(gen.Pick(s,
+		tag+"`a${1}b${2}c`;",
+		tag+"`\\unicode and \\u{zz} and \\xg`;",
+		"String.raw`\\u{1F600}${`inner ${`innermost`}`}`;",
+		tag+"\n`on the next line`;",
+		"`${{}}`.length;",
+		"`${`${`${'deep'}`}`}`;",
+		"((x) => x)`a`;",
+		tag+"`a`"+"`b`;",
+		"new "+tag+"`x`.constructor;",
+	))
+	return b.String()
+}
+
+// regexps writes regular expressions and uses them.
+func (g *jsgen) regexps(c ctx) string {
+	s := g.s
+	name := g.declare("re")
+	var b strings.Builder
+	fmt.Fprintf(&b, "const %s = %s;\n", name, g.regexp())
+	subject := gen.Pick(s, `"2024-01-02"`, `"$12.50"`, `"αβγ abc"`, `"😀😃"`, `""`, `"a\nb"`, g.str(c))
+	b.WriteString(gen.Pick(s,
+		name+".test("+subject+");",
+		subject+".replace("+name+", '$<year>$1$&$`$\\'');",
+		"[..."+subject+".matchAll(new RegExp("+name+".source, 'g' + "+name+".flags.replace('g', '')))];",
+		name+".exec("+subject+")?.groups?.year;",
+		subject+".split("+name+");",
+		"RegExp.prototype[Symbol.replace].call("+name+", "+subject+", () => '');",
+	))
+	// A slash that is division, not a regular expression.
+	b.WriteString("\n" + gen.Pick(s,
+		"let "+g.declare("q")+" = 4 / 2 / 1;",
+		"let "+g.declare("q")+" = "+name+".lastIndex\n/2/1;",
+		"if (true) /re/.test('re');",
+		"{}\n/foo/g.test('foo');",
+		"let "+g.declare("q")+" = (1) / 2 / (3);",
+		"let "+g.declare("q")+" = [] / 1 / [];",
+		"let "+g.declare("q")+" = 1 /* / */ / 2;",
+		"let "+g.declare("q")+" = 'a'.length\n/ 1 /\n1;",
+		"var "+g.declare("q")+" = () => {}\n/1/g.test('1');",
+	))
+	return b.String()
+}
+
+// chains writes statements of optional chains.
+func (g *jsgen) chains(c ctx) string {
+	s := g.s
+	name := g.declare("o")
+	var b strings.Builder
+	fmt.Fprintf(&b, "const %s = { a: { b: [() => ({ c: null })] }, f: null, g() { return this; } };\n", name)
+	for range s.Range(1, 4) {
+		b.WriteString(gen.Pick(s,
+			name+"?.a?.b?.[0]?.()?.c?.d;",
+			name+".f?.();",
+			name+".g?.().g?.().a;",
+			"("+name+"?.a).b;",
+			name+"?.[\"a\"]?.b ?? 'default';",
+			name+"?.a.b[0]().c?.d.e;",
+			"delete "+name+"?.a?.b;",
+			name+"?.\\u0061?.b;",
+			"(0, "+name+"?.g)();",
+			name+"?.g?.call?.(null);",
+			g.chain(c, g.depth)+";",
+		) + "\n")
+	}
+	return b.String()
+}
+
+// escapes writes declarations and property accesses spelled with escapes
+// and names in other scripts.
+func (g *jsgen) escapes(c ctx) string {
+	s := g.s
+	var b strings.Builder
+	for range s.Range(1, 3) {
+		n := g.binding(c)
+		b.WriteString(gen.Pick(s, "var ", "let ", "const ") + n + " = " + g.str(c) + ";\n")
+	}
+	b.WriteString(gen.Pick(s,
+		"var \\u{61}\\u0062c = 1; abc;",
+		"var ｘ = 'fullwidth';",
+		"var a\\u200db = 'zwj';",
+		"({ \\u0069f: 1 }).if;",
+		"var \\u0024 = 2, \\u005f = 3;",
+		"'\\u{1D306}'.length;",
+		"var 𝑥 = 1, \\u{1D465}2 = 𝑥;",
+		"var ℘ = 1, ℮ = ℘;",
+		"label\\u0031: { break label1; }",
+	))
+	if !c.strict && s.Chance(0.5) {
+		name := gen.Pick(s, sloppyNames...)
+		b.WriteString("\nvar " + name + " = 1; " + name + ";")
+	}
+	if g.broken() {
+		b.WriteString(gen.Pick(s, "\nvar \\u0069f = 1;", "\nvar a\\u0020b;", "\nvar \\u{110000};", "\nvar 1a;", "\nvar \\u0031;", "\nvar x\\u;"))
+	}
+	return b.String()
+}
+
+// hazard writes the statements automatic semicolon insertion, sloppy
+// mode and lookahead restrictions make hard to parse.
+func (g *jsgen) hazard(c ctx) string {
+	s := g.s
+	a, b := g.declare("h"), g.declare("h")
+	hazards := []string{
+		fmt.Sprintf("var %s = 1, %s = 2\n%s\n++%s", a, b, a, b),
+		fmt.Sprintf("var %s = 1\nvar %s = %s\n(function () {})", a, b, "(x => x)"),
+		fmt.Sprintf("var %s = 1\n;[1, 2].forEach((x) => x)", a),
+		fmt.Sprintf("var %s = 1\n;`template`.length", a),
+		fmt.Sprintf("var %s = function () { return\n1 }()", a),
+		fmt.Sprintf("var %s = 1, %s = %s - -1 + +%s - -%s", a, b, a, a, a),
+		fmt.Sprintf("var %s = 1, %s = %s-- > 0", a, b, a),
+		fmt.Sprintf("var %s = 1, %s = %s+ ++%s", a, b, a, a),
+		fmt.Sprintf("var %s = 1, %s = %s < !--%s", a, b, a, a),
+		fmt.Sprintf("var %s = { a: 1 }\n%s.a\n++%s.a", a, a, a),
+		fmt.Sprintf("var %s = 1; do %s++; while (%s < 3) %s", a, a, a, a),
+		fmt.Sprintf("var %s\n=\n1\n,\n%s", a, b),
+		fmt.Sprintf("var %s = async\nfunction %s() {}", a, b),
+		fmt.Sprintf("var %s = (async) => async, %s = %s(1)", a, b, a),
+		fmt.Sprintf("var %s = { get\n: 1, set\n() {} }", a),
+		fmt.Sprintf("var %s = 1 ? .5 : 1, %s = 1?.5:1", a, b),
+		fmt.Sprintf("var %s = [1]\n[0]", a),
+		fmt.Sprintf("var %s = (x => x)\n(1)", a),
+		fmt.Sprintf("var %s = typeof typeof void !~-+%s", a, b),
+		fmt.Sprintf("var %s = 2 ** -1, %s = (-2) ** 2", a, b),
+		fmt.Sprintf("var %s = 1; %s = %s in {} ? 1 : 2; for (var %s = (1 in {}); false;);", a, b, a, g.s.Fresh("i")),
+		fmt.Sprintf("var %s = 'a' < 'b' > false", a),
+		fmt.Sprintf("var %s = 1; { function %sf() {} }", a, b),
+		fmt.Sprintf("var %s = 1 + /* \n */ 2\nvar %s = 3", a, b),
+		fmt.Sprintf("var %s = new new Function('return function () { this.x = 1; }')()", a),
+		fmt.Sprintf("var %s = 1,\n%s = %s\n-->0", a, b, a),
+		fmt.Sprintf("var %s = { 'a': 1 }['a'], %s = (0, eval)('1 + 1')", a, b),
+		fmt.Sprintf("var %s = 1; (%s) = 2; [(%s)] = [3]", a, a, a),
+		fmt.Sprintf("var %s = class { static async *m() {} }", a),
+	}
+	if !c.strict {
+		hazards = append(hazards,
+			fmt.Sprintf("var %s = { x: 1 }; with (%s) { x = 2; }", a, a),
+			fmt.Sprintf("var %s = 017 + 08 + 0.5, %s = '\\08'", a, b),
+			fmt.Sprintf("var %s = 1\nlet\n%sl = 2", a, b),
+			fmt.Sprintf("var %s = function yield() {}, %s = function await() {}", a, b),
+			fmt.Sprintf("if (1) function %s() {} else function %s() {}", a+"f", b+"f"),
+			fmt.Sprintf("var %s = 1; label: function %s() {}", a, b+"f"),
+			fmt.Sprintf("function %s(a, a) { arguments[0] = 2; return a; } %s(1, 1)", a+"f", a+"f"),
+			fmt.Sprintf("var %s = 1; delete %s", a, a),
+		)
+	}
+	if g.module {
+		hazards = append(hazards,
+			fmt.Sprintf("var %s = import.meta, %s = await 1", a, b),
+			fmt.Sprintf("var %s = await import('data:text/javascript,export default 1').catch(() => 0)", a),
+		)
+	}
+	text := gen.Pick(s, hazards...)
+	if g.broken() {
+		text = gen.Pick(s, "throw\nnew Error()", "var a = 1 ++ 2", "for (let let of x);", "var a = -1 ** 2", "a\n=>a", "async x\n=> x", "let [a] = [1], [a] = [2]", "var a = 1 ?? 2 || 3", "continue;", "new.target", "return 1", "var a = `${`")
+	}
+	return text
+}
+
+// labels writes labeled loops that jump out of each other.
+func (g *jsgen) labels(c ctx) string {
+	s := g.s
+	outer, inner := g.s.Fresh("outer"), g.s.Fresh("inner")
+	i, j := g.s.Fresh("i"), g.s.Fresh("j")
+	jump := gen.Pick(s, "continue "+outer+";", "break "+outer+";", "continue "+inner+";", "break;", "continue;", "break "+inner+";")
+	text := fmt.Sprintf("%s: for (let %s = 0; %s < 3; %s++) {\n  %s: for (const %s of [1, 2]) {\n    if (%s === %s) %s\n    switch (%s) { case 1: %s default: break; }\n  }\n}", outer, i, i, i, inner, j, j, i, jump, j, gen.Pick(s, "break "+inner+";", "continue "+outer+";", "break;"))
+	if s.Chance(0.3) {
+		text += "\n" + g.labeledBlock()
+	}
+	if g.broken() {
+		text += gen.Pick(s, "\nx: x: ;", "\nbreak nowhere;", "\nx: { continue x; }", "\nwhile (0) { continue y; }")
+	}
+	return text
+}
+
+// labeledBlock returns a block a break leaves.
+func (g *jsgen) labeledBlock() string {
+	l := g.s.Fresh("block")
+	return l + ": { if (true) break " + l + "; throw 1; }"
+}
+
+// stmt returns a statement with blocks at most d deep.
+func (g *jsgen) stmt(c ctx, d int) string {
+	s := g.s
+	k := s.Intn(14)
+	if d <= 0 && k >= 6 {
+		k = 0
+	}
+	switch k {
+	case 0, 1:
+		e := g.expr(c, g.depth)
+		if strings.HasPrefix(e, "{") || strings.HasPrefix(e, "function") || strings.HasPrefix(e, "class") || strings.HasPrefix(e, "let") {
+			e = "(" + e + ")"
+		}
+		return e + ";"
+	case 2, 3:
+		kind := gen.Pick(s, "var", "let", "const")
+		if kind == "var" && !c.strict && s.Chance(0.05) {
+			return "var " + gen.Pick(s, sloppyNames[:4]...) + "_" + g.s.Fresh("") + " = 1;"
+		}
+		if s.Chance(0.2) {
+			if s.Chance(0.5) {
+				return kind + " [..." + g.s.Fresh("r") + "] = [];"
+			}
+			return kind + " { ..." + g.s.Fresh("r") + " } = {};"
+		}
+		return kind + " " + g.binding(c) + " = " + g.expr(c, g.depth) + ";"
+	case 4:
+		if c.fn {
+			return "return " + g.expr(c, g.depth) + ";"
+		}
+		return ";"
+	case 5:
+		if c.loop {
+			return gen.Pick(s, "break;", "continue;")
+		}
+		return "debugger;"
+	case 6:
+		text := "if (" + g.expr(c, g.depth) + ") " + g.block(c, d-1)
+		if s.Chance(0.5) {
+			text += " else " + g.block(c, d-1)
+		}
+		return text
+	case 7:
+		i := g.s.Fresh("i")
+		lc := c
+		lc.loop = true
+		return fmt.Sprintf("for (let %s = 0; %s < %d; %s++) %s", i, i, s.Range(0, 3), i, g.block(lc, d-1))
+	case 8:
+		lc := c
+		lc.loop = true
+		head := gen.Pick(s, "const x of [1, 2]", "let [k, v] of new Map([[1, 2]])", "const k in { a: 1 }", "var { length } of ['ab']", "const ch of '😀日'")
+		if c.async && s.Chance(0.3) {
+			return "for await (const x of [Promise.resolve(1)]) " + g.block(lc, d-1)
+		}
+		return "for (" + head + ") " + g.block(lc, d-1)
+	case 9:
+		n := g.s.Fresh("n")
+		lc := c
+		lc.loop = true
+		if s.Chance(0.5) {
+			return fmt.Sprintf("{ let %s = 0; while (%s++ < 2) %s }", n, n, g.block(lc, d-1))
+		}
+		return fmt.Sprintf("{ let %s = 0; do %s while (%s++ < 2) }", n, g.block(lc, d-1), n)
+	case 10:
+		var b strings.Builder
+		b.WriteString("switch (" + g.expr(c, g.depth) + ") {")
+		sc := c
+		sc.loop = false
+		for range s.Range(0, 3) {
+			b.WriteString(" case " + g.primary(c) + ": " + g.stmt(sc, d-1))
+			if s.Chance(0.5) {
+				b.WriteString(" break;")
+			}
+		}
+		if s.Chance(0.5) {
+			b.WriteString(" default: { " + g.stmt(sc, d-1) + " }")
+		}
+		return b.String() + " }"
+	case 11:
+		text := "try " + g.block(c, d-1)
+		switch s.Intn(3) {
+		case 0:
+			text += " catch " + g.block(c, d-1)
+		case 1:
+			text += " catch (" + gen.Pick(s, "e", "{ message }", "[first]") + ") " + g.block(c, d-1)
+		default:
+			text += " finally " + g.block(c, d-1)
+		}
+		return text
+	case 12:
+		return "throw " + g.expr(c, g.depth) + ";"
+	}
+	return g.block(c, d-1)
+}
+
+// block returns a block of a few statements.
+func (g *jsgen) block(c ctx, d int) string {
+	var parts []string
+	for range g.s.Range(0, 3) {
+		parts = append(parts, g.stmt(c, d))
+	}
+	return "{ " + strings.Join(parts, " ") + " }"
+}
+
+// export returns the export declarations that end a module. Only the
+// hoisted names are sure to be declared at its top level.
+func (g *jsgen) export() string {
+	s := g.s
+	if len(g.hoisted) == 0 {
+		return "export {};\n"
+	}
+	v := gen.Pick(s, g.hoisted...)
+	return gen.Pick(s,
+		"export { "+v+" };\n",
+		"export { "+v+" as default };\n",
+		"export { "+v+" as \"string name\" };\n",
+		"export default "+v+";\n",
+		"export const "+g.s.Fresh("e")+" = "+v+";\n",
+	)
+}
@@ -0,0 +1,183 @@
+package jwtsrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// jsonSerialization writes a JWS, token.jws, or a JWE, token.jwe, in the
+// JSON serialization, general or flattened.
+func jsonSerialization(s *gen.State) []gen.File {
+	g := &jgen{s: s}
+	if s.Chance(0.5) {
+		return []gen.File{{Name: "token.jws", Data: g.document(g.signedJSON())}}
+	}
+	return []gen.File{{Name: "token.jwe", Data: g.document(g.encryptedJSON())}}
+}
+
+// document returns the JSON of the top-level members m, most often on
+// one line and otherwise indented. Now and then a member is there twice
+// or in another case.
+func (g *jgen) document(m []member) []byte {
+	s := g.s
+	if s.Chance(framingRate) {
+		d := gen.Pick(s, m...)
+		i := s.Intn(len(m) + 1)
+		if s.Chance(0.5) {
+			d.key = strings.ToUpper(d.key[:1]) + d.key[1:]
+		}
+		m = append(m[:i], append([]member{d}, m[i:]...)...)
+	}
+	if s.Chance(0.3) {
+		return []byte(spaced(m, "\n  "))
+	}
+	return []byte(object(m))
+}
+
+// unprotected returns the members of an unprotected header: a kid most
+// often, alg if the protected header leaves it out, and now and then a
+// nonce, which belongs in the protected header only.
+func (g *jgen) unprotected(alg string, withAlg bool) []member {
+	s := g.s
+	var h []member
+	if withAlg {
+		h = append(h, member{"alg", quote(alg)})
+	}
+	if s.Chance(0.6) {
+		h = append(h, member{"kid", g.kid()})
+	}
+	if s.Chance(badRate * 4) {
+		h = append(h, member{"nonce", quote(gen.Pick(s, "n-0S6_WzA2Mj", ""))})
+	}
+	if s.Chance(0.1) && len(h) > 0 {
+		h = append(h, member{h[0].key, gen.Pick(s, `"HS256"`, `"none"`, `null`)})
+	}
+	return h
+}
+
+// signedJSON returns the members of a JWS: general with one signature or
+// more, or flattened; now and then with the members of both, no
+// signature, a protected header that is an object and not its encoding,
+// or a payload that is not a string.
+func (g *jgen) signedJSON() []member {
+	s := g.s
+	payload := quote(g.b64(g.claims()))
+	if s.Chance(framingRate) {
+		payload = gen.Pick(s, `{"sub":"1"}`, `null`, `1`, `""`, `["e30"]`)
+	}
+	n := 1
+	if s.Chance(0.5) {
+		n = gen.Pick(s, 1, 2, 3, 0)
+	}
+	var sigs [][]member
+	for range n {
+		alg := g.sigAlg()
+		protected := g.sigHeader(alg)
+		inProtected := s.Chance(0.8)
+		if !inProtected {
+			protected = withoutAlg(protected)
+		}
+		var sig []member
+		if len(protected) > 0 || s.Chance(0.5) {
+			p := quote(g.b64(g.header(protected)))
+			if s.Chance(badRate) {
+				p = string(g.header(protected))
+			}
+			sig = append(sig, member{"protected", p})
+		}
+		if h := g.unprotected(alg, !inProtected); len(h) > 0 {
+			sig = append(sig, member{"header", object(h)})
+		}
+		if !s.Chance(badRate) {
+			sig = append(sig, member{"signature", quote(g.b64(g.signature(alg)))})
+		}
+		sigs = append(sigs, sig)
+	}
+	m := []member{{"payload", payload}}
+	if n == 1 && s.Chance(0.5) {
+		m = append(m, sigs[0]...)
+		if !s.Chance(framingRate) {
+			return m
+		}
+	}
+	var list []string
+	for _, sig := range sigs {
+		list = append(list, object(sig))
+	}
+	return append(m, member{"signatures", "[" + strings.Join(list, ",") + "]"})
+}
+
+// encryptedJSON returns the members of a JWE: general with one recipient
+// or more, or flattened. Its alg is in the protected header, the shared
+// unprotected header or each recipient's; enc is most often protected.
+func (g *jgen) encryptedJSON() []member {
+	s := g.s
+	alg, enc := g.encAlgs()
+	parts := g.encParts(alg, enc)
+	h := g.encHeader(alg, enc)
+	var protected, shared []member
+	for _, m := range h {
+		if m.key == "alg" || s.Chance(0.8) {
+			protected = append(protected, m)
+		} else {
+			shared = append(shared, m)
+		}
+	}
+	where := s.Intn(3)
+	if where > 0 {
+		protected = withoutAlg(protected)
+	}
+	if where == 1 {
+		shared = append(shared, member{"alg", quote(alg)})
+	}
+	var m []member
+	if len(protected) > 0 {
+		m = append(m, member{"protected", quote(g.b64(g.header(protected)))})
+	}
+	if len(shared) > 0 || s.Chance(0.1) {
+		m = append(m, member{"unprotected", object(append(shared, g.unprotected(alg, false)...))})
+	}
+	n := 1
+	if s.Chance(0.5) {
+		n = gen.Pick(s, 1, 2, 3, 0)
+	}
+	var recipients []string
+	for i := range n {
+		r := []member{{"encrypted_key", quote(g.b64(parts[0]))}}
+		if i > 0 {
+			r[0].value = quote(g.b64(g.bytes(len(parts[0]))))
+		}
+		if rh := g.unprotected(alg, where == 2); len(rh) > 0 {
+			r = append([]member{{"header", object(rh)}}, r...)
+		}
+		recipients = append(recipients, object(r))
+		if n == 1 && s.Chance(0.5) {
+			m = append(m, r...)
+			recipients = nil
+		}
+	}
+	if recipients != nil || s.Chance(framingRate) {
+		m = append(m, member{"recipients", "[" + strings.Join(recipients, ",") + "]"})
+	}
+	if s.Chance(0.2) {
+		m = append(m, member{"aad", quote(g.b64([]byte(gen.Pick(s, "additional data", "", "\x00"))))})
+	}
+	for i, name := range []string{"iv", "ciphertext", "tag"} {
+		if !s.Chance(badRate) {
+			m = append(m, member{name, quote(g.b64(parts[i+1]))})
+		}
+	}
+	return m
+}
+
+// withoutAlg returns h with its alg members dropped.
+func withoutAlg(h []member) []member {
+	var out []member
+	for _, m := range h {
+		if m.key != "alg" {
+			out = append(out, m)
+		}
+	}
+	return out
+}
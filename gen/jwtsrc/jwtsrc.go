@@ -0,0 +1,662 @@
+// Package jwtsrc generates seeds of JSON Web Signatures and JSON Web
+// Encryption, JWS and JWE, and of the JSON Web Tokens they carry. It
+// registers the "jwt/..." generators with package gen.
+//
+// "jwt/jws" writes a JWS in the compact serialization, token.jws: a
+// protected header, a payload that is most often a set of claims, and a
+// signature of the length its algorithm gives. "jwt/jwe" writes a JWE in
+// the compact serialization, token.jwe: a protected header naming a key
+// management algorithm and a content encryption, with the parameters
+// each takes, an encrypted key, an IV, a ciphertext and a tag of the
+// lengths they give. "jwt/json" writes a JWS, token.jws, or a JWE,
+// token.jwe, in the JSON serializations, general or flattened, with
+// unprotected headers, several signatures or recipients, and now and then
+// members of both forms at once, members in other cases, a nonce where
+// it is not protected or a payload that is not a string.
+//
+// Headers confuse algorithms: "none" in any case with a signature or
+// without, an HMAC algorithm with a public key in jwk, a key management
+// algorithm in a JWS and a signature algorithm in a JWE, an alg that is
+// empty, not a string or missing. Parameters come twice with different
+// values, or once plainly and once spelt with an escape; kid holds paths
+// and quotes, and crit names what is there, what is not and nothing.
+// Claims have audiences that are strings, arrays or neither, dates that
+// are fractions, strings, huge or negative, and claims of their own
+// nested deep. Segments are base64url without padding, but a few are
+// padded, use the standard alphabet, hold line breaks or spaces, set the
+// bits past their last byte, or have a length no encoding gives; now and
+// then a token has a segment too few or too many, or space around it.
+package jwtsrc
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "jwt/jws",
+		Doc:  "compact JWS tokens: alg none, HMAC with public keys and other algorithm confusion, duplicated and escaped header parameters, crit, deeply nested claims, and padded, line-broken and non-canonical base64url segments",
+		Func: jws,
+	})
+	gen.Register(&gen.Generator{
+		Name: "jwt/jwe",
+		Doc:  "compact JWE tokens: key management and content encryption algorithms with epk, PBES2 and GCM key wrap parameters, DEFLATE, confused and missing alg and enc, and encrypted keys, IVs and tags of right and wrong lengths",
+		Func: jwe,
+	})
+	gen.Register(&gen.Generator{
+		Name: "jwt/json",
+		Doc:  "JWS and JWE JSON serializations, general and flattened: several signatures and recipients, unprotected headers and nonces, mixed forms, members in other cases and duplicated members",
+		Func: jsonSerialization,
+	})
+}
+
+// badRate is the chance that a segment is encoded wrong or a header
+// parameter is drawn in a form readers differ on. A token has a handful
+// of segments and a dozen parameters, so about one in five gets one.
+const badRate = 0.015
+
+// framingRate is the chance that a token is put together wrong.
+const framingRate = 0.03
+
+// Algorithm names: those of RFC 7518 and some that are not.
+var (
+	sigAlgs = []string{
+		"HS256", "HS384", "HS512", "RS256", "RS384", "RS512", "PS256", "PS384", "PS512",
+		"ES256", "ES384", "ES512", "EdDSA",
+	}
+	keyAlgs = []string{
+		"RSA1_5", "RSA-OAEP", "RSA-OAEP-256", "A128KW", "A192KW", "A256KW", "dir",
+		"ECDH-ES", "ECDH-ES+A128KW", "ECDH-ES+A192KW", "ECDH-ES+A256KW",
+		"A128GCMKW", "A192GCMKW", "A256GCMKW", "PBES2-HS256+A128KW", "PBES2-HS384+A192KW", "PBES2-HS512+A256KW",
+	}
+	encs = []string{"A128CBC-HS256", "A192CBC-HS384", "A256CBC-HS512", "A128GCM", "A192GCM", "A256GCM"}
+
+	// oddAlgs are names of no algorithm, or of one in a case or form no
+	// reader should take for it.
+	oddAlgs = []string{
+		"none", "None", "NONE", "nOnE", "", "hs256", "HS256 ", " HS256", "HS256\u0000", "HS1", "RS1",
+		"Ed25519", "ES256K", "ECDSA", "HMAC", "RS256/none", "ＨＳ２５６",
+	}
+)
+
+// Keys for jwk and epk: points made from fixed private keys, which
+// random bytes almost never are, and a modulus that is not one.
+var (
+	curvePoints = map[string][]byte{
+		"P-256": point(ecdh.P256(), 32),
+		"P-384": point(ecdh.P384(), 48),
+		"P-521": point(ecdh.P521(), 66),
+	}
+	x25519Point = point(ecdh.X25519(), 32)
+	ed25519Key  = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+	modulus     = func() []byte {
+		n := make([]byte, 256)
+		for i := range n {
+			n[i] = byte(i*131 + 7)
+		}
+		n[0] |= 0x80
+		n[255] |= 1
+		return n
+	}()
+)
+
+// point returns the public key of the private key 1 on c.
+func point(c ecdh.Curve, size int) []byte {
+	k := make([]byte, size)
+	k[size-1] = 1
+	p, err := c.NewPrivateKey(k)
+	if err != nil {
+		panic(err)
+	}
+	return p.PublicKey().Bytes()
+}
+
+// A jgen builds the parts of one token.
+type jgen struct {
+	s *gen.State
+}
+
+// A member is a member of a JSON object, its value as JSON.
+type member struct {
+	key, value string
+}
+
+// quote returns s as a JSON string.
+func quote(s string) string {
+	b, err := json.Marshal(s)
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
+
+// object returns an object of members in order, a key written twice if
+// it is there twice.
+func object(members []member) string {
+	return spaced(members, "")
+}
+
+// spaced returns an object of members with space before each, after
+// each colon and, but for trailing blanks, before the closing brace. It
+// writes a key that is a JSON string already, as escape returns, as it
+// is.
+func spaced(members []member, space string) string {
+	colon := ":"
+	if space != "" {
+		colon = ": "
+	}
+	var b strings.Builder
+	b.WriteString("{" + space)
+	for i, m := range members {
+		if i > 0 {
+			b.WriteString("," + space)
+		}
+		key := m.key
+		if !strings.HasPrefix(key, `"`) {
+			key = quote(key)
+		}
+		b.WriteString(key + colon + m.value)
+	}
+	b.WriteString(strings.TrimRight(space, " \t") + "}")
+	return b.String()
+}
+
+// bytes returns n random bytes.
+func (g *jgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(g.s.Intn(256))
+	}
+	return b
+}
+
+// b64 returns b in base64url without padding, or now and then in a form
+// a strict reader does not take: padded, in the standard alphabet, broken
+// by a line or a space, with bits set past the last byte, a character
+// over or one from no alphabet.
+func (g *jgen) b64(b []byte) string {
+	s := g.s
+	enc := base64.RawURLEncoding.EncodeToString(b)
+	if !s.Chance(badRate) {
+		return enc
+	}
+	switch s.Intn(7) {
+	case 0:
+		return base64.URLEncoding.EncodeToString(b)
+	case 1:
+		return base64.RawStdEncoding.EncodeToString(b)
+	case 2:
+		i := s.Intn(len(enc) + 1)
+		return enc[:i] + gen.Pick(s, "\n", "\r\n", "\r") + enc[i:]
+	case 3:
+		i := s.Intn(len(enc) + 1)
+		return enc[:i] + gen.Pick(s, " ", "\t", "\u00a0") + enc[i:]
+	case 4:
+		if len(b)%3 == 0 {
+			return enc + "A"
+		}
+		const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+		last := strings.IndexByte(alphabet, enc[len(enc)-1])
+		return enc[:len(enc)-1] + string(alphabet[last|1])
+	case 5:
+		return enc + gen.Pick(s, "A", "AAAAA", "=", "==", "===")
+	default:
+		i := s.Intn(len(enc) + 1)
+		return enc[:i] + gen.Pick(s, "!", "%", "=", "\x00", "é", "+", "/") + enc[i:]
+	}
+}
+
+// value returns a JSON value nested up to depth, with strings that
+// escape what they can and numbers at the limits of what holds them.
+func (g *jgen) value(depth int) string {
+	s := g.s
+	if depth > 0 && s.Chance(0.4) {
+		n := s.Range(0, 4)
+		var parts []string
+		if s.Chance(0.5) {
+			for range n {
+				parts = append(parts, g.value(depth-1))
+			}
+			return "[" + strings.Join(parts, ",") + "]"
+		}
+		var members []member
+		for range n {
+			members = append(members, member{g.name(), g.value(depth - 1)})
+		}
+		return object(members)
+	}
+	switch s.Intn(4) {
+	case 0:
+		return gen.Pick(s, "0", "-0", "1", "-1", "1.5", "1e3", "9007199254740993", "18446744073709551616", "1e308", "4.9e-324", "123456789012345678901234567890")
+	case 1:
+		return gen.Pick(s, "true", "false", "null")
+	default:
+		return gen.Pick(s, `""`, `"a"`, `"é"`, `"\u00e9"`, `"😀"`, `"\ud83d\ude00"`, `"\ud800"`, `"\u0000"`, `"<script>"`, `"a\"b"`, `"\\"`, "\"\xff\"", `" "`)
+	}
+}
+
+// name returns the name of a claim or parameter of no standard.
+func (g *jgen) name() string {
+	return gen.Pick(g.s, "a", "b", "role", "admin", "scope", "groups", "data", "nested", "x-custom", "", "é", "😀", "__proto__", "constructor")
+}
+
+// sigHeader returns the protected header of a JWS whose algorithm is alg.
+func (g *jgen) sigHeader(alg string) []member {
+	s := g.s
+	h := []member{{"alg", quote(alg)}}
+	if s.Chance(0.7) {
+		h = append(h, member{"typ", quote(gen.Pick(s, "JWT", "JWT", "jwt", "at+jwt", "application/jwt", "JOSE", "JOSE+JSON", "dpop+jwt"))})
+	}
+	if s.Chance(0.3) {
+		h = append(h, member{"kid", g.kid()})
+	}
+	if s.Chance(0.15) {
+		h = append(h, member{"jwk", g.jwk()})
+	}
+	if s.Chance(0.1) {
+		h = append(h, member{gen.Pick(s, "jku", "x5u"), quote(gen.Pick(s, "https://example.com/jwks.json", "http://127.0.0.1/", "file:///etc/passwd", "", "//example.com", "https://user@example.com#@evil.example"))})
+	}
+	if s.Chance(0.1) {
+		h = append(h, member{"x5c", g.x5c()})
+	}
+	if s.Chance(0.1) {
+		h = append(h, member{gen.Pick(s, "x5t", "x5t#S256"), quote(g.b64(g.bytes(gen.Pick(s, 20, 32))))})
+	}
+	if s.Chance(0.1) {
+		h = append(h, member{"cty", quote(gen.Pick(s, "JWT", "jwt", "application/json", ""))})
+	}
+	if s.Chance(0.1) {
+		h = append(h, g.crit()...)
+	}
+	if s.Chance(0.05) {
+		h = append(h, member{g.name(), g.value(2)})
+	}
+	return g.confuse(h)
+}
+
+// crit returns crit and, now and then, what it names.
+func (g *jgen) crit() []member {
+	s := g.s
+	switch s.Intn(4) {
+	case 0:
+		return []member{{"b64", gen.Pick(s, "false", "true", `"false"`)}, {"crit", `["b64"]`}}
+	case 1:
+		return []member{{"crit", gen.Pick(s, `["exp"]`, `["alg"]`, `["x-unknown"]`, `["b64","b64"]`)}}
+	case 2:
+		return []member{{"crit", gen.Pick(s, `[]`, `null`, `"b64"`, `[1]`, `{}`)}}
+	default:
+		return []member{{"x-unknown", `1`}, {"crit", `["x-unknown"]`}}
+	}
+}
+
+// kid returns a key ID, most often an ordinary one, and otherwise one
+// that would do harm where it is put in a path or a query, or not a
+// string.
+func (g *jgen) kid() string {
+	s := g.s
+	if s.Chance(0.7) {
+		return quote(gen.Pick(s, "1", "key-1", "2024-01-01", "a1b2c3d4"))
+	}
+	return gen.Pick(s,
+		quote("../../../../../../dev/null"), quote("' OR '1'='1"), quote("key\u0000.pem"), quote(""),
+		quote(strings.Repeat("k", 1024)), quote("|id"), "1", "null", `["1"]`, `{}`,
+	)
+}
+
+// jwk returns an embedded key: a public key of each type, a private one,
+// a symmetric one, one missing what it needs or with the wrong curve.
+func (g *jgen) jwk() string {
+	s := g.s
+	enc := base64.RawURLEncoding.EncodeToString
+	var k []member
+	switch s.Intn(6) {
+	case 0:
+		k = []member{{"kty", `"RSA"`}, {"n", quote(enc(modulus))}, {"e", quote(gen.Pick(s, "AQAB", "Aw", "AA", ""))}}
+	case 1:
+		crv := gen.Pick(s, "P-256", "P-384", "P-521")
+		p := curvePoints[crv]
+		x, y := p[1:1+len(p)/2], p[1+len(p)/2:]
+		if s.Chance(0.2) {
+			crv = gen.Pick(s, "P-256", "P-384", "secp256k1", "")
+		}
+		k = []member{{"kty", `"EC"`}, {"crv", quote(crv)}, {"x", quote(enc(x))}, {"y", quote(enc(y))}}
+		if s.Chance(0.2) {
+			k = append(k, member{"d", quote(enc(g.bytes(len(x))))})
+		}
+	case 2:
+		k = []member{{"kty", `"OKP"`}, {"crv", `"Ed25519"`}, {"x", quote(enc(ed25519Key))}}
+	case 3:
+		k = []member{{"kty", `"OKP"`}, {"crv", `"X25519"`}, {"x", quote(enc(x25519Point))}}
+	case 4:
+		k = []member{{"kty", `"oct"`}, {"k", quote(enc(g.bytes(32)))}}
+	default:
+		k = []member{{"kty", gen.Pick(s, `"RSA"`, `"EC"`, `"OKP"`, `"x"`, `1`)}}
+	}
+	if s.Chance(0.3) {
+		k = append(k, member{gen.Pick(s, "use", "alg", "kid", "key_ops"), gen.Pick(s, `"sig"`, `"enc"`, `"HS256"`, `"1"`, `["verify"]`)})
+	}
+	return object(k)
+}
+
+// x5c returns a certificate chain: base64, in the standard alphabet, of
+// bytes that do not make a certificate, or an empty or odd chain.
+func (g *jgen) x5c() string {
+	s := g.s
+	switch s.Intn(3) {
+	case 0:
+		return `[` + quote(base64.StdEncoding.EncodeToString(append([]byte{0x30, 0x82, 0x01, 0x00}, g.bytes(s.Range(0, 32))...))) + `]`
+	case 1:
+		return gen.Pick(s, `[]`, `[""]`, `null`, `"MIIB"`, `[1]`)
+	default:
+		return `[` + quote(g.b64(g.bytes(s.Range(1, 16)))) + `]`
+	}
+}
+
+// confuse now and then gives a header a parameter twice with another
+// value, spelt the same, with an escape or in another case, or drops or
+// mangles its alg.
+func (g *jgen) confuse(h []member) []member {
+	s := g.s
+	if s.Chance(0.1) {
+		m := gen.Pick(s, h...)
+		value := gen.Pick(s, `"none"`, `"HS256"`, `"RS256"`, `null`, `""`, m.value)
+		m.key = gen.Pick(s, m.key, m.key, strings.ToUpper(m.key), escape(m.key))
+		i := s.Intn(len(h) + 1)
+		h = append(h[:i], append([]member{{m.key, value}}, h[i:]...)...)
+	}
+	if s.Chance(badRate) {
+		h[0].value = gen.Pick(s, `null`, `256`, `["HS256"]`, `{"alg":"HS256"}`, `true`)
+	}
+	if s.Chance(badRate) {
+		h = h[1:]
+	}
+	if s.Chance(badRate) {
+		gen.Shuffle(s, h)
+	}
+	return h
+}
+
+// escape returns key as a JSON string that spells its first letter with
+// a \u escape, which readers must take for the same key.
+func escape(key string) string {
+	if key == "" {
+		return `""`
+	}
+	return fmt.Sprintf(`"\u%04x%s"`, key[0], key[1:])
+}
+
+// header returns the JSON of h, now and then with space around the
+// members.
+func (g *jgen) header(h []member) []byte {
+	space := ""
+	if g.s.Chance(0.1) {
+		space = gen.Pick(g.s, " ", "\n", "\r\n\t")
+	}
+	return []byte(spaced(h, space))
+}
+
+// claims returns a payload: most often a JSON object of claims, nested
+// as deep as s.Limits.Literal asks, and otherwise a payload of a JWS that
+// is not JSON or not an object.
+func (g *jgen) claims() []byte {
+	s := g.s
+	if s.Chance(0.05) {
+		return []byte(gen.Pick(s, "", "hello", "null", "[]", `"claims"`, "1", "{", "\xff\xfe", `{"a":1}{"b":2}`, `{"a":1} `))
+	}
+	date := func() string {
+		if s.Chance(0.8) {
+			return gen.Pick(s, "1700000000", "1893456000", "0", "1", "2147483647", "2147483648")
+		}
+		return gen.Pick(s, "1.7e9", "1700000000.5", "-1", "-9223372036854775808", "9223372036854775807", "1e300", "4611686018427387904", `"1700000000"`, `"2024-01-01T00:00:00Z"`, "null", "true")
+	}
+	var c []member
+	if s.Chance(0.8) {
+		c = append(c, member{"iss", quote(gen.Pick(s, "https://issuer.example.com", "issuer", "", "https://issuer.example.com/"))})
+	}
+	if s.Chance(0.8) {
+		c = append(c, member{"sub", gen.Pick(s, `"1234567890"`, `"user@example.com"`, `""`, `1234567890`, `null`)})
+	}
+	if s.Chance(0.6) {
+		c = append(c, member{"aud", gen.Pick(s, `"api"`, `["api"]`, `["api","web"]`, `[]`, `[""]`, `[1]`, `{"aud":"api"}`, `null`, `""`)})
+	}
+	for _, name := range []string{"exp", "nbf", "iat"} {
+		if s.Chance(0.6) {
+			c = append(c, member{name, date()})
+		}
+	}
+	if s.Chance(0.4) {
+		c = append(c, member{"jti", quote(gen.Pick(s, "id-1", "", "\u0000"))})
+	}
+	for range s.Range(0, 3) {
+		c = append(c, member{g.name(), g.value(s.Depth(s.Limits.Literal, 4))})
+	}
+	if s.Chance(0.05) {
+		// Nesting deep enough for a decoder's depth limit, which a large
+		// -depth.lit raises it to.
+		n := s.Depth(s.Limits.Literal, 200)
+		open, close := "[", "]"
+		if s.Chance(0.5) {
+			open, close = `{"a":`, "}"
+		}
+		c = append(c, member{"nested", strings.Repeat(open, n) + "1" + strings.Repeat(close, n)})
+	}
+	if len(c) > 0 && s.Chance(0.1) {
+		// A claim twice, the second differing: readers keep the first,
+		// the last or neither.
+		m := gen.Pick(s, c...)
+		c = append(c, member{m.key, gen.Pick(s, `"other"`, `0`, `null`, `["x"]`)})
+	}
+	return g.header(c)
+}
+
+// sigLengths are the lengths of signatures by each algorithm but RSA's,
+// whose signatures are as long as the key's modulus.
+var sigLengths = map[string]int{
+	"HS256": 32, "HS384": 48, "HS512": 64, "ES256": 64, "ES384": 96, "ES512": 132, "EdDSA": 64,
+}
+
+// signature returns a signature as long as one by alg, or for alg none
+// none at all most of the time.
+func (g *jgen) signature(alg string) []byte {
+	s := g.s
+	n, ok := sigLengths[alg]
+	switch {
+	case ok:
+	case strings.HasPrefix(alg, "RS"), strings.HasPrefix(alg, "PS"):
+		n = gen.Pick(s, 256, 256, 128, 512)
+	case s.Chance(0.3):
+		n = gen.Pick(s, 32, 64, 256)
+	}
+	if s.Chance(badRate) {
+		n = gen.Pick(s, 0, 1, max(n-1, 0), n+1)
+	}
+	return g.bytes(n)
+}
+
+// sigAlg returns the alg of a JWS: most often one it may name, otherwise
+// one of no signature or one of JWE.
+func (g *jgen) sigAlg() string {
+	s := g.s
+	switch {
+	case s.Chance(0.8):
+		return gen.Pick(s, sigAlgs...)
+	case s.Chance(0.7):
+		return gen.Pick(s, oddAlgs...)
+	default:
+		return gen.Pick(s, keyAlgs...)
+	}
+}
+
+// compact joins segments with dots, now and then with one dropped, one
+// more, an empty one or space around the token.
+func (g *jgen) compact(segs []string) string {
+	s := g.s
+	if s.Chance(framingRate) {
+		switch s.Intn(4) {
+		case 0:
+			segs = segs[:len(segs)-1]
+		case 1:
+			segs = append(segs, gen.Pick(s, "", segs[len(segs)-1], "e30"))
+		default:
+			segs[s.Intn(len(segs))] = ""
+		}
+	}
+	tok := strings.Join(segs, ".")
+	if s.Chance(framingRate) {
+		tok = gen.Pick(s, " ", "\n", "\t", "Bearer ", "\ufeff") + tok + gen.Pick(s, "", "\n", "\r\n", " ")
+	}
+	return tok
+}
+
+func jws(s *gen.State) []gen.File {
+	g := &jgen{s: s}
+	alg := g.sigAlg()
+	segs := []string{g.b64(g.header(g.sigHeader(alg))), g.b64(g.claims()), g.b64(g.signature(alg))}
+	if s.Chance(0.05) {
+		// A nested JWT: a JWS whose payload is another.
+		inner := strings.Join([]string{g.b64(g.header(g.sigHeader(g.sigAlg()))), g.b64(g.claims()), g.b64(g.signature(alg))}, ".")
+		segs[1] = g.b64([]byte(inner))
+	}
+	return []gen.File{{Name: "token.jws", Data: []byte(g.compact(segs))}}
+}
+
+// encHeader returns the protected header of a JWE whose key management
+// algorithm is alg and content encryption enc, with the parameters alg
+// takes.
+func (g *jgen) encHeader(alg, enc string) []member {
+	s := g.s
+	h := []member{{"alg", quote(alg)}, {"enc", quote(enc)}}
+	switch {
+	case strings.HasPrefix(alg, "ECDH-ES"):
+		h = append(h, member{"epk", g.epk()})
+		if s.Chance(0.3) {
+			h = append(h, member{"apu", quote(g.b64([]byte("Alice")))}, member{"apv", quote(g.b64([]byte("Bob")))})
+		}
+	case strings.HasSuffix(alg, "GCMKW"):
+		h = append(h, member{"iv", quote(g.b64(g.bytes(12)))}, member{"tag", quote(g.b64(g.bytes(16)))})
+	case strings.HasPrefix(alg, "PBES2"):
+		h = append(h,
+			member{"p2s", quote(g.b64(g.bytes(gen.Pick(s, 16, 16, 8, 0, 64))))},
+			member{"p2c", gen.Pick(s, "1000", "4096", "600000", "0", "-1", "2147483647", "1e10", `"1000"`)},
+		)
+	}
+	if s.Chance(0.2) {
+		h = append(h, member{"zip", quote(gen.Pick(s, "DEF", "DEF", "deflate", "GZIP", ""))})
+	}
+	if s.Chance(0.3) {
+		h = append(h, member{"kid", g.kid()})
+	}
+	if s.Chance(0.3) {
+		h = append(h, member{"cty", quote(gen.Pick(s, "JWT", "jwt", "application/json"))})
+	}
+	if s.Chance(0.05) {
+		h = append(h, g.crit()...)
+	}
+	if s.Chance(badRate * 4) {
+		// The enc of no content encryption, or none.
+		if s.Chance(0.5) {
+			h = append(h[:1], h[2:]...)
+		} else {
+			h[1].value = quote(gen.Pick(s, "A128CBC", "HS256", "", "A256GCM ", "a128gcm", "A128CBC-HS256\u0000"))
+		}
+	}
+	return g.confuse(h)
+}
+
+// epk returns an ephemeral public key for ECDH-ES: a point on a NIST
+// curve or X25519, now and then a key of no curve ECDH-ES takes.
+func (g *jgen) epk() string {
+	s := g.s
+	enc := base64.RawURLEncoding.EncodeToString
+	if s.Chance(0.3) {
+		return object([]member{{"kty", `"OKP"`}, {"crv", `"X25519"`}, {"x", quote(enc(x25519Point))}})
+	}
+	if s.Chance(0.1) {
+		return g.jwk()
+	}
+	crv := gen.Pick(s, "P-256", "P-256", "P-384", "P-521")
+	p := curvePoints[crv]
+	x, y := p[1:1+len(p)/2], p[1+len(p)/2:]
+	if s.Chance(badRate * 4) {
+		// A point off the curve, or on another.
+		y = g.bytes(len(y))
+		crv = gen.Pick(s, crv, "P-256", "P-384")
+	}
+	return object([]member{{"kty", `"EC"`}, {"crv", quote(crv)}, {"x", quote(enc(x))}, {"y", quote(enc(y))}})
+}
+
+// keyLengths are the lengths of the encrypted key by each key management
+// algorithm but RSA's, whose keys are as long as its modulus; direct
+// encryption and direct key agreement have none.
+var keyLengths = map[string]int{
+	"A128KW": 24, "A192KW": 32, "A256KW": 40, "dir": 0,
+	"ECDH-ES": 0, "ECDH-ES+A128KW": 24, "ECDH-ES+A192KW": 32, "ECDH-ES+A256KW": 40,
+	"A128GCMKW": 16, "A192GCMKW": 24, "A256GCMKW": 32,
+	"PBES2-HS256+A128KW": 24, "PBES2-HS384+A192KW": 32, "PBES2-HS512+A256KW": 40,
+}
+
+// tagLengths are the lengths of the tag by each content encryption; the
+// CBC ones have an IV of 16 bytes and the GCM ones of 12.
+var tagLengths = map[string]int{
+	"A128CBC-HS256": 16, "A192CBC-HS384": 24, "A256CBC-HS512": 32, "A128GCM": 16, "A192GCM": 16, "A256GCM": 16,
+}
+
+// encParts returns the encrypted key, IV, ciphertext and tag of a JWE by
+// alg and enc, of the lengths those give, now and then a byte off.
+func (g *jgen) encParts(alg, enc string) [4][]byte {
+	s := g.s
+	key, ok := keyLengths[alg]
+	if !ok {
+		key = gen.Pick(s, 0, 256)
+	}
+	iv := 12
+	if strings.Contains(enc, "CBC") {
+		iv = 16
+	}
+	tag, ok := tagLengths[enc]
+	if !ok {
+		tag = 16
+	}
+	lens := [4]int{key, iv, s.Range(0, 64), tag}
+	if strings.Contains(enc, "CBC") {
+		lens[2] = 16 * s.Range(0, 4)
+	}
+	if s.Chance(badRate * 4) {
+		i := s.Intn(4)
+		lens[i] = gen.Pick(s, 0, 1, max(lens[i]-1, 0), lens[i]+1, 256)
+	}
+	var parts [4][]byte
+	for i, n := range lens {
+		parts[i] = g.bytes(n)
+	}
+	return parts
+}
+
+// encAlgs returns the alg and enc of a JWE: most often ones it may name,
+// otherwise the alg of a JWS or of nothing.
+func (g *jgen) encAlgs() (alg, enc string) {
+	s := g.s
+	alg, enc = gen.Pick(s, keyAlgs...), gen.Pick(s, encs...)
+	if s.Chance(0.1) {
+		alg = gen.Pick(s, sigAlgs[0], gen.Pick(s, sigAlgs...), gen.Pick(s, oddAlgs...))
+	}
+	return alg, enc
+}
+
+func jwe(s *gen.State) []gen.File {
+	g := &jgen{s: s}
+	alg, enc := g.encAlgs()
+	parts := g.encParts(alg, enc)
+	segs := []string{g.b64(g.header(g.encHeader(alg, enc)))}
+	for _, p := range parts {
+		segs = append(segs, g.b64(p))
+	}
+	return []gen.File{{Name: "token.jwe", Data: []byte(g.compact(segs))}}
+}
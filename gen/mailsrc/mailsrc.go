@@ -0,0 +1,299 @@
+// Package mailsrc generates email seeds. It registers the "mail/..."
+// generators with package gen.
+//
+// Addresses are the shapes that make one mail system deliver to, or
+// show, an address another did not check: display names that are
+// themselves addresses, encoded words (RFC 2047) hiding an '@', a '<' or
+// a comma, quoted local parts holding an '@' or a quote, comments,
+// groups, source routes and domain literals. Media types carry the
+// parameters MIME parsers disagree on: RFC 2231 continuations, charsets
+// and percent-encoding, and a filename given twice, plainly and
+// extended. Messages put both in headers that are folded, repeated and
+// spelled in other cases. Most of a seed is well formed; each part also
+// has malformed variants, drawn rarely.
+package mailsrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "mail/address",
+		Doc:  "RFC 5322 address lists: display names that are addresses, encoded words hiding delimiters, quoted local parts with '@' and quotes, comments, groups, source routes and domain literals",
+		Func: address,
+	})
+	gen.Register(&gen.Generator{
+		Name: "mail/mediatype",
+		Doc:  "Content-Type and Content-Disposition values: RFC 2231 continuations and charsets, percent-encoding cut short, duplicate and case-folded parameters, and filenames given both plainly and extended",
+		Func: mediaType,
+	})
+	gen.Register(&gen.Generator{
+		Name: "mail/message",
+		Doc:  "message headers: address, date, subject and content headers folded, repeated and case-folded, with encoded words, obsolete dates and zones, and a short body",
+		Func: message,
+	})
+}
+
+// trickRate is the chance that a part is drawn in a form parsers are
+// least likely to agree on, or to get right; badRate the chance that it
+// is malformed. A seed has a dozen parts or so.
+const (
+	trickRate = 0.1
+	badRate   = 0.01
+)
+
+// An mgen accumulates one seed.
+type mgen struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func address(s *gen.State) []gen.File {
+	m := &mgen{s: s}
+	m.list()
+	return []gen.File{{Name: "input.addr", Data: []byte(m.b.String())}}
+}
+
+func mediaType(s *gen.State) []gen.File {
+	m := &mgen{s: s}
+	m.mediaType(gen.Pick(s, "text/plain", "multipart/mixed", "attachment", "form-data"))
+	return []gen.File{{Name: "input.ct", Data: []byte(m.b.String())}}
+}
+
+func message(s *gen.State) []gen.File {
+	m := &mgen{s: s}
+	eol := "\r\n"
+	if s.Chance(0.2) {
+		eol = "\n"
+	}
+	for range s.Range(1, 10) {
+		name := gen.Pick(s, "From", "To", "Cc", "Bcc", "Reply-To", "Sender", "Subject", "Date", "Content-Type", "Content-Disposition", "Message-ID", "MIME-Version", "X-Mailer")
+		switch {
+		case s.Chance(0.1):
+			name = strings.ToLower(name)
+		case s.Chance(badRate):
+			name = gen.Pick(s, name+" ", " "+name, name+"\x00", "From To", "", "é")
+		}
+		m.b.WriteString(name + ":")
+		if s.Chance(0.9) {
+			m.b.WriteString(" ")
+		}
+		m.header(name, eol)
+		m.b.WriteString(eol)
+	}
+	if s.Chance(badRate) {
+		m.b.WriteString(gen.Pick(s, " continued"+eol, "no colon"+eol, "\r"))
+	}
+	m.b.WriteString(eol)
+	m.b.WriteString(gen.Pick(s, "", "body"+eol, "--b"+eol+"Content-Type: text/plain"+eol+eol+"x"+eol+"--b--"+eol))
+	return []gen.File{{Name: "message.eml", Data: []byte(m.b.String())}}
+}
+
+// header writes the value of the header named name, folded with eol.
+func (m *mgen) header(name, eol string) {
+	start := m.b.Len()
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "from", "to", "cc", "bcc", "reply-to", "sender":
+		m.list()
+	case "subject":
+		m.phrase()
+	case "date":
+		m.date()
+	case "content-type":
+		m.mediaType(gen.Pick(m.s, "text/plain", "multipart/mixed", "text/html"))
+	case "content-disposition":
+		m.mediaType(gen.Pick(m.s, "attachment", "inline", "form-data"))
+	case "message-id":
+		m.b.WriteString("<" + m.local() + "@" + m.domain() + ">")
+	default:
+		m.b.WriteString(gen.Pick(m.s, "1.0", "x", ""))
+	}
+	if !m.s.Chance(0.2) {
+		return
+	}
+	// Fold the value at one of its spaces, as long lines are.
+	v := m.b.String()[start:]
+	if i := strings.LastIndexAny(v, " \t"); i > 0 {
+		folded := v[:i] + eol + gen.Pick(m.s, " ", "\t", "  ") + v[i+1:]
+		if m.s.Chance(badRate) {
+			folded = v[:i] + eol + v[i+1:] // a break with no space after it
+		}
+		rest := m.b.String()[:start]
+		m.b.Reset()
+		m.b.WriteString(rest + folded)
+	}
+}
+
+// space writes whitespace or a comment between tokens, usually nothing.
+func (m *mgen) space() {
+	if m.s.Chance(0.2) {
+		m.b.WriteString(gen.Pick(m.s, " ", "  ", "\t", " (comment) ", "(nested (comment))", "(a@evil.com)", `(\))`, "\r\n "))
+	}
+}
+
+// list writes one address, a list or a group.
+func (m *mgen) list() {
+	n := 1
+	if m.s.Chance(0.4) {
+		n = m.s.Range(2, 4)
+	}
+	for i := range n {
+		if i > 0 {
+			m.b.WriteString(gen.Pick(m.s, ",", ", ", " , ", ",\r\n "))
+			if m.s.Chance(badRate) {
+				m.b.WriteString(gen.Pick(m.s, ",", ";", " "))
+			}
+		}
+		if m.s.Chance(0.1) {
+			m.group()
+		} else {
+			m.mailbox()
+		}
+	}
+}
+
+// group writes a named group of mailboxes, possibly none.
+func (m *mgen) group() {
+	m.phrase()
+	m.b.WriteString(":")
+	for i := range m.s.Range(0, 3) {
+		if i > 0 {
+			m.b.WriteString(",")
+		}
+		m.space()
+		m.mailbox()
+	}
+	m.b.WriteString(gen.Pick(m.s, ";", ";", "", ";;"))
+}
+
+// mailbox writes a bare address or a display name and an address in
+// angle brackets.
+func (m *mgen) mailbox() {
+	m.space()
+	if m.s.Chance(0.3) {
+		m.addrSpec()
+		m.space()
+		return
+	}
+	if m.s.Chance(0.8) {
+		m.phrase()
+		m.b.WriteString(gen.Pick(m.s, " ", " ", "", "\t"))
+	}
+	m.b.WriteString("<")
+	if m.s.Chance(0.05) {
+		m.b.WriteString(gen.Pick(m.s, "@relay.example:", "@a,@b:", "@[1.2.3.4]:", ",@a:", "@:"))
+	}
+	m.addrSpec()
+	m.b.WriteString(">")
+	m.space()
+	if m.s.Chance(badRate) {
+		m.b.WriteString(gen.Pick(m.s, ">", "<", "x", `"`))
+	}
+}
+
+func (m *mgen) addrSpec() {
+	m.b.WriteString(m.local())
+	if m.s.Chance(trickRate) {
+		m.space()
+	}
+	m.b.WriteString("@")
+	if m.s.Chance(trickRate) {
+		m.space()
+	}
+	m.b.WriteString(m.domain())
+}
+
+// local returns the local part of an address.
+func (m *mgen) local() string {
+	switch {
+	case m.s.Chance(badRate * 2):
+		return gen.Pick(m.s, ".a", "a..b", "a.", "", "a b", `"unterminated`, "a\"b", "a\x00", "a@b", "(a)")
+	case m.s.Chance(trickRate):
+		return gen.Pick(m.s, "!#$%&'*+-/=?^_`{|}~", "用户", `"a\\b"`, `""`, `"a.b"`, `"a\@b"`, "=?utf-8?q?a=40evil.com?=", `"a"."b"`)
+	}
+	return gen.Pick(m.s, "alice", "bob", "a.b", "a+tag", "postmaster", "ü", `"a b"`, `"alice"`, `"a@evil.com"`, `"a\"b"`, `"evil.com\"@good"`)
+}
+
+// domain returns the domain of an address.
+func (m *mgen) domain() string {
+	switch {
+	case m.s.Chance(badRate * 2):
+		return gen.Pick(m.s, "", "a..b", ".a", "a.", "[1.2.3.4", "[a[b]", "[a\\]]", "a b", "a@b", "-a.com", "a_b.com")
+	case m.s.Chance(trickRate):
+		return gen.Pick(m.s, "[IPv6:::1]", "[::1]", "[a@b]", "[good.com]", "[1.2.3.4.5]", "a.", "1.2.3.4", "a.b.c.d.e", "xn--bcher-kva.example")
+	}
+	return gen.Pick(m.s, "example.com", "good.com", "evil.com", "good.com.evil.com", "localhost", "[1.2.3.4]", "EXAMPLE.COM", "bücher.example")
+}
+
+// phrase writes a display name or a subject: atoms, quoted strings and
+// encoded words, the last able to hide any character.
+func (m *mgen) phrase() {
+	for i := range m.s.Range(1, 3) {
+		if i > 0 {
+			m.b.WriteString(gen.Pick(m.s, " ", " ", "  ", "\t", ""))
+		}
+		switch {
+		case m.s.Chance(badRate):
+			m.b.WriteString(gen.Pick(m.s, "=?utf-8?q?a", "=?utf-8?x?a?=", "=?utf-8?b?!!?=", "=??q?a?=", "=?utf-8?q?a b?=", `"a`, "a\x00", "=?utf-8?q?=ZZ?="))
+		case m.s.Chance(trickRate):
+			m.b.WriteString(gen.Pick(m.s,
+				"ceo@good.com", "a.b", `"a\"b"`, `""`, "=?utf-8?q?a=40evil.com=3E?=", "=?utf-8?q?a_b?=", "=?utf-8*en?q?a?=", "=?x-unknown?q?a?=",
+				"=?utf-8?b??=", `"=?utf-8?q?quoted?="`, "=?utf-8?q?=E2=80=AE?=", "=?utf-8?q?a?==?utf-8?q?b?=", "=?utf-8?q?=FF?=",
+			))
+		default:
+			m.b.WriteString(gen.Pick(m.s,
+				"Alice", "Smith", "Zoë", `"ceo@good.com"`, `"Smith, Alice"`, `"<evil@evil.com>"`, "=?utf-8?q?Al=C3=AFce?=", "=?UTF-8?B?w6k=?=",
+				"=?iso-8859-1?q?=E9?=", "=?us-ascii?q?a=40evil.com?=", "=?utf-8?q?=3Cevil=40evil.com=3E?=", "=?utf-8?q?a=2C_b?=",
+			))
+		}
+	}
+}
+
+// date writes a date in the forms RFC 5322 and its obsolete syntax allow.
+func (m *mgen) date() {
+	if m.s.Chance(badRate * 2) {
+		m.b.WriteString(gen.Pick(m.s, "yesterday", "32 Jan 2006 15:04:05 -0700", "02 Foo 2006 15:04:05 -0700", "02 Jan 2006 25:04:05 -0700", "02 Jan 2006 15:04:05 +9999", ""))
+		return
+	}
+	if m.s.Chance(0.6) {
+		m.b.WriteString(gen.Pick(m.s, "Mon, ", "mon, ", "Tue,", ""))
+	}
+	m.b.WriteString(gen.Pick(m.s, "2", "02", "31", "29"))
+	m.b.WriteString(" " + gen.Pick(m.s, "Jan", "Feb", "jan", "DEC") + " ")
+	m.b.WriteString(gen.Pick(m.s, "2006", "06", "99", "1900", "9999", "0001", "49", "50"))
+	m.b.WriteString(" " + gen.Pick(m.s, "15:04:05", "15:04", "23:59:60", "00:00:00", "1:2:3") + " ")
+	m.b.WriteString(gen.Pick(m.s, "-0700", "+0000", "-0000", "+1400", "-2359", "GMT", "UT", "EST", "PDT", "Z", "A", "+0000 (UTC)", "-0700 (MST)"))
+}
+
+// mediaType writes a media type or disposition and its parameters.
+func (m *mgen) mediaType(typ string) {
+	if m.s.Chance(badRate * 2) {
+		typ = gen.Pick(m.s, "text/", "/plain", "text/plain/x", "t\x80xt/plain", "", "text /plain", "text/pl ain", `"text/plain"`)
+	} else if m.s.Chance(0.2) {
+		typ = gen.Pick(m.s, strings.ToUpper(typ), "application/octet-stream", "x/y", "message/rfc822", "a")
+	}
+	m.b.WriteString(typ)
+	for range m.s.Range(0, 4) {
+		m.b.WriteString(gen.Pick(m.s, ";", "; ", " ; ", ";\r\n "))
+		if m.s.Chance(badRate * 2) {
+			m.b.WriteString(gen.Pick(m.s, "", ";", "a=", "=b", "a", `a="unterminated`, "a=é", "a=b c", "x*=bad'%zz", "a*0=x; a*0=y", `a="\`, "a = b"))
+			continue
+		}
+		m.b.WriteString(gen.Pick(m.s,
+			"charset=utf-8", `charset="utf-8"`, "Charset=a; CHARSET=b", "charset=a; charset=b", `boundary="a b"`, "boundary=b",
+			`name="a\"b"`, `name="a\\b"`, `filename="../../etc/passwd"`, `filename="evil.exe"`, "filename=safe.txt",
+			"filename*=utf-8''%E2%82%AC.txt", "filename*=UTF-8'en'a%20b", "filename*=iso-8859-1''%E9", "filename*=''a",
+			"filename*=x-unknown''a", "filename*=utf-8''%FF", "filename*=utf-8''%E2%82", "filename*=utf-8''%2", "filename*=a",
+			`filename*0="a"; filename*1="b"`, "filename*0*=utf-8''a%20; filename*1*=b%20; filename*2=c",
+			"filename*1=b", "filename*0=a; filename*2=c", `filename="evil.exe"; filename*=utf-8''safe.txt`,
+			`filename*=utf-8''safe.txt; filename="evil.exe"`, `filename*0=a; filename="b"`, "name=", `name=""`, "format=flowed",
+			`x="=?utf-8?q?a?="`, "q=%41",
+		))
+	}
+	if m.s.Chance(0.05) {
+		m.b.WriteString(gen.Pick(m.s, ";", "; ", " (comment)"))
+	}
+}
@@ -0,0 +1,434 @@
+// Package mdsrc generates Markdown seeds. It registers the "markdown/..."
+// generators with package gen.
+//
+// "markdown/doc" writes documents of every CommonMark block, headings,
+// paragraphs, block quotes, lists, fenced and indented code, thematic
+// breaks, HTML blocks of all seven kinds and link reference definitions,
+// and of the GFM ones, tables and footnotes, nested in each other, with
+// inline emphasis, code spans, links, images, autolinks, raw HTML,
+// entities and escapes. "markdown/pathological" writes the inputs that
+// have made Markdown parsers take quadratic time or worse: emphasis and
+// brackets nested or left open thousands deep, floods of reference
+// definitions and of references to them, block quotes and lists nested
+// past any reason, HTML blocks interleaved with Markdown, and tables of
+// many columns and rows without cells, each repeated a few thousand
+// times, enough that a parser linear in its input is quick and one with
+// worse than quadratic cost, or quadratic with a large factor, is not.
+package mdsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "markdown/doc",
+		Doc:  "Markdown documents: ATX and setext headings, paragraphs, nested block quotes and lists, fenced and indented code, thematic breaks, HTML blocks of every kind, link reference definitions, GFM tables and footnotes, with emphasis, code spans, links, images, autolinks, raw HTML, entities and escapes inline, and now and then a dangerous URL or script",
+		Func: doc,
+	})
+	gen.Register(&gen.Generator{
+		Name: "markdown/pathological",
+		Doc:  "Markdown inputs that have cost parsers quadratic time: emphasis and brackets nested or left unclosed thousands deep, reference definition floods, deeply nested block quotes and lists, HTML blocks interleaved with Markdown, backtick runs, and tables of many columns and missing cells, embedded in an ordinary document",
+		Func: pathological,
+	})
+}
+
+// badRate is the chance that an inline construct is written the way an
+// attacker would write it: a URL with a scheme that runs script, or raw
+// HTML with a script in it.
+const badRate = 0.03
+
+// words are the text of inline runs.
+var words = []string{
+	"a", "b", "foo", "bar", "hello", "world", "Markdown", "text", "x", "y_z", "snake_case", "2*3",
+	"日本語", "héllo", "😀", "a\u00a0b", "\x00", "&", "<", ">", "&amp;", "&#35;", "&#x1F600;", "&nbsp;", "&bogus;",
+	`\*`, `\_`, `\[`, `\\`, "\\`", "foo@example.com", "www.example.com", "https://example.com/a_b",
+}
+
+// urls are the destinations of links and images.
+var urls = []string{
+	"https://example.com", "http://example.com/a?b=c&d=e#f", "/path/to", "rel/path", "#frag", "", "<>",
+	"<a b>", "mailto:a@b.c", "foo%20bar", "a(b)c", `a\)b`, "https://example.com/é",
+}
+
+// badURLs are destinations that run script or load data.
+var badURLs = []string{
+	"javascript:alert(1)", "JaVaScRiPt:alert(1)", "java\tscript:alert(1)", "vbscript:msgbox(1)",
+	"data:text/html,<script>alert(1)</script>", "javascript&colon;alert(1)", "&#106;avascript:alert(1)",
+	" javascript:alert(1)", "<javascript:alert(1)>", "file:///etc/passwd",
+}
+
+// badHTML is raw HTML an attacker writes.
+var badHTML = []string{
+	"<script>alert(1)</script>", "<img src=x onerror=alert(1)>", "<a href=\"javascript:alert(1)\">x</a>",
+	"<svg onload=alert(1)>", "<iframe src=\"https://evil.example\"></iframe>", "<style>*{}</style>",
+	"<SCRIPT SRC=//evil.example/x.js></SCRIPT>", "<div style=\"x:expression(alert(1))\">",
+}
+
+// An mgen writes the document of one seed.
+type mgen struct {
+	s     *gen.State
+	b     strings.Builder
+	depth int
+	refs  []string // labels of the references and definitions written
+	notes []string // labels of the footnotes written
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &mgen{s: s, depth: s.Depth(s.Limits.Block, 4)}
+	for range s.Range(1, 12) {
+		d.block("", 0)
+	}
+	d.definitions()
+	return []gen.File{{Name: "input.md", Data: []byte(d.b.String())}}
+}
+
+func (d *mgen) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+// line writes one line after prefix, the markers of the containers it is
+// in.
+func (d *mgen) line(prefix, text string) {
+	d.b.WriteString(prefix)
+	d.b.WriteString(text)
+	d.b.WriteByte('\n')
+}
+
+// block writes a block at the given depth of containers.
+func (d *mgen) block(prefix string, depth int) {
+	s := d.s
+	k := s.Intn(16)
+	if depth >= d.depth && k >= 12 {
+		k = 0
+	}
+	switch k {
+	case 0, 1, 2, 3:
+		d.paragraph(prefix)
+	case 4:
+		d.line(prefix, strings.Repeat("#", s.Range(1, 7))+gen.Pick(s, " ", " ", "", "\t")+d.inline(3)+gen.Pick(s, "", "", " ##", " #######"))
+	case 5:
+		d.line(prefix, d.inline(3))
+		d.line(prefix, strings.Repeat(gen.Pick(s, "=", "-"), s.Range(1, 6)))
+	case 6:
+		d.code(prefix)
+	case 7:
+		d.line(prefix, gen.Pick(s, "***", "---", "___", "* * *", " - - -", "_____________", "**", "-- -"))
+	case 8:
+		d.html(prefix)
+	case 9:
+		d.table(prefix)
+	case 10:
+		d.definition(prefix)
+	case 11:
+		d.line(prefix, "")
+	case 12, 13:
+		p := prefix + gen.Pick(s, "> ", ">", " > ", ">  ")
+		for range s.Range(1, 3) {
+			d.block(p, depth+1)
+		}
+		if s.Chance(0.3) {
+			d.line(prefix, d.inline(4)) // lazy continuation
+		}
+	default:
+		d.list(prefix, depth)
+	}
+}
+
+// paragraph writes a paragraph of a few lines.
+func (d *mgen) paragraph(prefix string) {
+	s := d.s
+	n := s.Range(1, 3)
+	for i := range n {
+		text := d.inline(6)
+		if i < n-1 && s.Chance(0.2) {
+			text += gen.Pick(s, "  ", `\`, "   ")
+		}
+		d.line(prefix, text)
+	}
+	d.line(prefix, "")
+}
+
+// list writes a bullet or an ordered list, tight or loose, whose items
+// hold blocks of their own.
+func (d *mgen) list(prefix string, depth int) {
+	s := d.s
+	ordered := s.Chance(0.4)
+	marker := gen.Pick(s, "-", "*", "+")
+	delim := gen.Pick(s, ".", ")")
+	start := gen.Pick(s, 1, 0, 3, 999999999, 1234567890)
+	loose := s.Chance(0.3)
+	for i := range s.Range(1, 4) {
+		m := marker
+		if ordered {
+			m = fmt.Sprint(start+i) + delim
+		}
+		if s.Chance(0.1) {
+			m += gen.Pick(s, " [ ]", " [x]", " [X]")
+		}
+		pad := gen.Pick(s, " ", " ", "  ", "    ", "\t")
+		d.b.WriteString(prefix + m + pad)
+		d.b.WriteString(d.inline(4) + "\n")
+		inner := prefix + strings.Repeat(" ", len(m)+len(pad))
+		if s.Chance(0.3) && depth < d.depth {
+			d.block(inner, depth+1)
+		}
+		if loose {
+			d.line(prefix, "")
+		}
+	}
+}
+
+// code writes a fenced or an indented code block.
+func (d *mgen) code(prefix string) {
+	s := d.s
+	body := gen.Pick(s, "x := 1", "<script>alert(1)</script>", "*not emphasis*", "```", "~~~", "\tindented", "", "[a]: /url")
+	if s.Chance(0.3) {
+		d.line(prefix, "    "+body)
+		d.line(prefix, "\t"+body)
+		return
+	}
+	fence := strings.Repeat(gen.Pick(s, "`", "~"), s.Range(3, 5))
+	d.line(prefix, fence+gen.Pick(s, "", "go", " python ", "js {.x}", "a`b", "<b>"))
+	for range s.Range(0, 3) {
+		d.line(prefix, body)
+	}
+	if s.Chance(0.9) {
+		d.line(prefix, fence)
+	}
+}
+
+// html writes an HTML block of one of the seven kinds CommonMark has.
+func (d *mgen) html(prefix string) {
+	s := d.s
+	switch s.Intn(8) {
+	case 0:
+		d.line(prefix, gen.Pick(s, "<script>", "<pre class=\"x\">", "<style>", "<textarea>"))
+		d.line(prefix, d.inline(3))
+		d.line(prefix, gen.Pick(s, "</script>", "</pre>", "</style>", "</textarea>", ""))
+	case 1:
+		d.line(prefix, "<!-- "+d.inline(2))
+		d.line(prefix, gen.Pick(s, "-->", "--!>", "", "-- >"))
+	case 2:
+		d.line(prefix, "<?php echo 1; ?>")
+	case 3:
+		d.line(prefix, "<!DOCTYPE html>")
+	case 4:
+		d.line(prefix, "<![CDATA[ "+d.inline(2)+" ]]>")
+	case 5:
+		tag := gen.Pick(s, "div", "table", "p", "section", "details", "DIV")
+		d.line(prefix, "<"+tag+gen.Pick(s, "", " class=\"x\"", ">", " "))
+		d.line(prefix, "")
+		d.line(prefix, d.inline(3))
+		d.line(prefix, "")
+		d.line(prefix, "</"+tag+">")
+	case 6:
+		d.line(prefix, gen.Pick(s, "<x-custom a=\"b\">", "</span>", "<a href=\"/u\">", "<b c=d e='f' g>"))
+		d.line(prefix, d.inline(3))
+	default:
+		d.line(prefix, gen.Pick(s, badHTML...))
+	}
+	d.line(prefix, "")
+}
+
+// table writes a GFM table, now and then with rows short of cells or
+// past them.
+func (d *mgen) table(prefix string) {
+	s := d.s
+	cols := s.Range(1, 5)
+	row := func(n int, cell func() string) string {
+		var cells []string
+		for range n {
+			cells = append(cells, cell())
+		}
+		line := strings.Join(cells, " | ")
+		if s.Chance(0.7) {
+			line = "| " + line + " |"
+		}
+		return line
+	}
+	d.line(prefix, row(cols, func() string { return d.inline(2) }))
+	d.line(prefix, row(cols, func() string { return gen.Pick(s, "---", ":--", "--:", ":-:", "-", ":", "--- ") }))
+	for range s.Range(0, 4) {
+		n := cols
+		if s.Chance(0.3) {
+			n = s.Range(1, cols+2)
+		}
+		d.line(prefix, row(n, func() string { return gen.Pick(s, d.inline(2), `a \| b`, "`|`", "") }))
+	}
+	d.line(prefix, "")
+}
+
+// definition writes a link reference definition or a footnote.
+func (d *mgen) definition(prefix string) {
+	s := d.s
+	if s.Chance(0.3) {
+		label := "^" + gen.Pick(s, "1", "note", s.Fresh("n"))
+		d.notes = append(d.notes, label)
+		d.line(prefix, "["+label+"]: "+d.inline(3))
+		d.line(prefix, "")
+		return
+	}
+	label := gen.Pick(s, "foo", "Foo", "FOO", "a b", "a  b", "ẞ", "ß", s.Fresh("ref"), `a\]b`, "日本")
+	d.refs = append(d.refs, label)
+	title := gen.Pick(s, "", ` "title"`, " 'title'", " (title)", "\n  \"title\"", ` "a"b"`)
+	d.line(prefix, "["+label+"]:"+gen.Pick(s, " ", "\n   ")+d.url()+title)
+	d.line(prefix, "")
+}
+
+// definitions writes the definitions of some of the references the
+// document makes and has not defined.
+func (d *mgen) definitions() {
+	for _, label := range d.refs {
+		if d.s.Chance(0.3) {
+			d.line("", "["+label+"]: "+d.url())
+		}
+	}
+}
+
+// url returns a link destination.
+func (d *mgen) url() string {
+	if d.s.Chance(badRate) {
+		return gen.Pick(d.s, badURLs...)
+	}
+	return gen.Pick(d.s, urls...)
+}
+
+// inline returns a run of up to n inline constructs.
+func (d *mgen) inline(n int) string {
+	s := d.s
+	var parts []string
+	for range s.Range(1, n) {
+		parts = append(parts, d.span(2))
+	}
+	return strings.Join(parts, gen.Pick(s, " ", " ", "", "  "))
+}
+
+// span returns one inline construct, nested to at most depth.
+func (d *mgen) span(depth int) string {
+	s := d.s
+	inner := func() string {
+		if depth == 0 {
+			return gen.Pick(s, words...)
+		}
+		return d.span(depth - 1)
+	}
+	switch s.Intn(14) {
+	case 0:
+		m := gen.Pick(s, "*", "_", "**", "__", "***", "~~", "~")
+		return m + inner() + gen.Pick(s, m, m, m, "", "*", "_")
+	case 1:
+		ticks := strings.Repeat("`", s.Range(1, 3))
+		return ticks + gen.Pick(s, "code", " `a` ", "<b>", "a  b", "\\") + ticks
+	case 2:
+		return "[" + inner() + "](" + d.url() + gen.Pick(s, "", ` "t"`, " 't'", " (t)") + ")"
+	case 3:
+		return "![" + inner() + "](" + d.url() + ")"
+	case 4:
+		label := gen.Pick(s, "foo", "FOO", "a b", "ß", "missing")
+		if len(d.refs) > 0 && s.Chance(0.5) {
+			label = gen.Pick(s, d.refs...)
+		}
+		d.refs = append(d.refs, label)
+		return gen.Pick(s, "["+inner()+"]["+label+"]", "["+label+"]", "["+label+"][]")
+	case 5:
+		return "<" + gen.Pick(s, "https://example.com", "mailto:a@b.c", "a@b.c", "irc://x", "http://a b", "javascript:alert(1)") + ">"
+	case 6:
+		if s.Chance(badRate * 3) {
+			return gen.Pick(s, badHTML...)
+		}
+		return gen.Pick(s, "<span>", "</span>", "<b>x</b>", "<a href=\"/\">", "<!-- c -->", "<?x?>", "<br/>", "<x y=\"z\" />")
+	case 7:
+		if len(d.notes) > 0 {
+			return "[" + gen.Pick(s, d.notes...) + "]"
+		}
+		return "[^missing]"
+	default:
+		return gen.Pick(s, words...)
+	}
+}
+
+// pathological writes an ordinary document with one construct scaled to
+// cost a quadratic parser.
+func pathological(s *gen.State) []gen.File {
+	d := &mgen{s: s, depth: 2}
+	if s.Chance(0.5) {
+		d.block("", 0)
+	}
+	n := s.Range(250, 1500)
+	rep := strings.Repeat
+	switch s.Intn(24) {
+	case 0:
+		d.b.WriteString(rep("*a **a ", n) + "b" + rep(" a** a*", n))
+	case 1:
+		d.b.WriteString(rep(gen.Pick(s, "a_ ", "a* ", "a** "), n))
+	case 2:
+		d.b.WriteString(rep(gen.Pick(s, "_a ", "*a ", "**a "), n))
+	case 3:
+		d.b.WriteString(rep("a]", n))
+	case 4:
+		d.b.WriteString(rep("[a", n))
+	case 5:
+		d.b.WriteString(rep("*a_ ", n))
+	case 6:
+		d.b.WriteString("a**b" + rep("c* ", n))
+	case 7:
+		d.b.WriteString(rep("[ a_", n))
+	case 8:
+		d.b.WriteString(rep("[ (](", n))
+	case 9:
+		d.b.WriteString(rep("[", n) + "a" + rep("]", n))
+	case 10:
+		d.b.WriteString(rep("> ", n) + "a\n")
+	case 11:
+		for i := range n / 25 {
+			d.line(rep("  ", i), "* a")
+		}
+	case 12:
+		for i := 1; i < n/2; i++ {
+			d.b.WriteString("e" + rep("`", i%64+1))
+		}
+	case 13:
+		d.b.WriteString(rep(gen.Pick(s, "[a](<b", "[a](b", "[a](b \"c", "![a](b"), n))
+	case 14:
+		d.b.WriteString("</" + rep("<!--", n))
+	case 15:
+		for i := range n {
+			d.write("[%d]: /u%d\n", i, i)
+		}
+		for i := range n {
+			d.write("[%d] ", i%gen.Pick(s, n, 1, 16))
+		}
+	case 16:
+		d.b.WriteString("[x]: " + rep("a", n) + "\n\n" + rep("[x]", n))
+	case 17:
+		d.b.WriteString("[" + rep("a", min(n, 999)) + "]: /u\n\n" + rep("["+rep("a", min(n, 999))+"] ", n/10+1))
+	case 18:
+		for range n / 10 {
+			d.b.WriteString(gen.Pick(s, "<div>\n*a*\n</div>\n", "<div>\n\n*a*\n\n", "</div>\n", "<!--\n*a*\n", "-->\n", "<pre>\n", "</pre>\n"))
+		}
+	case 19:
+		cols := n / 12
+		d.line("", rep("|a", cols)+"|")
+		d.line("", rep("|-", cols)+"|")
+		for range n / 12 {
+			d.line("", gen.Pick(s, "|", "a", "|a|"))
+		}
+	case 20:
+		d.b.WriteString(rep("*", n*5) + "a" + rep("_", n*5))
+	case 21:
+		d.b.WriteString(rep(gen.Pick(s, "<", "&", "&#", "<a ", "</", "<!-", "\\", "~~"), n*2))
+	case 22:
+		d.b.WriteString(rep("- ", min(n, 2000)) + "a\n")
+	default:
+		d.b.WriteString(rep("[^", n) + rep("]", n) + "\n\n" + rep("[^a]: b\n", n/10+1))
+	}
+	d.b.WriteString("\n")
+	if s.Chance(0.5) {
+		d.block("", 0)
+	}
+	return []gen.File{{Name: "input.md", Data: []byte(d.b.String())}}
+}
@@ -0,0 +1,278 @@
+// Package modsrc generates go.mod, go.work and go.sum seeds, module
+// versions, and module zips and proxy responses. It registers the
+// "mod/..." generators with package gen.
+//
+// Most lines are well formed so that a seed gets past the parser to the
+// semantic checks; each directive also has malformed variants, drawn
+// rarely, because a single bad line fails the whole file.
+//
+// "mod/version" writes the string-level grammar the files are made of:
+// versions one to a line, some after a module path and some as retract
+// intervals, in every form semver and the pseudo-version rules take and
+// near misses of each.
+//
+// "mod/zip" and "mod/proxy" write files as a GOPROXY serves them, under
+// <escaped path>/@v/, so that a seed directory is a proxy the go command
+// can be pointed at with a file:// URL. "mod/zip" writes a version's zip
+// alone, often with an entry a module zip must not hold; "mod/proxy"
+// writes its zip, mostly a valid one, with its .info and .mod and the
+// module's list.
+package modsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "mod/gomod",
+		Doc:  "go.mod files: require/exclude/replace/retract blocks, toolchain, godebug, tool and ignore lines",
+		Func: goMod,
+	})
+	gen.Register(&gen.Generator{
+		Name: "mod/gowork",
+		Doc:  "go.work files: use and replace directives with local paths",
+		Func: goWork,
+	})
+	gen.Register(&gen.Generator{
+		Name: "mod/gosum",
+		Doc:  "go.sum files, including malformed hashes and versions",
+		Func: goSum,
+	})
+}
+
+// badRate is the chance that an element is drawn in a malformed form.
+// A file has a few dozen elements, so about a quarter of files get one.
+const badRate = 0.01
+
+// A modFile accumulates the lines of a module file.
+type modFile struct {
+	b strings.Builder
+}
+
+func (m *modFile) line(format string, args ...any) {
+	fmt.Fprintf(&m.b, format, args...)
+	m.b.WriteByte('\n')
+}
+
+// block writes verb with the given argument lines, as a single line if
+// there is one and a parenthesized block otherwise or by chance.
+func (m *modFile) block(s *gen.State, verb string, lines []string) {
+	if len(lines) == 1 && s.Chance(0.7) {
+		m.line("%s %s", verb, lines[0])
+		return
+	}
+	m.line("%s (", verb)
+	for _, l := range lines {
+		m.line("\t%s", l)
+	}
+	m.line(")")
+}
+
+func modPath(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, `"unterminated`, "Example.com/UPPER", "example.com/a//b", "-dash.com/x", "example.com/m/v1", "example.com/m/v2.0", ".", "")
+	}
+	return gen.Pick(s,
+		"example.com/m", "example.com/m/v2", "github.com/user/repo", "golang.org/x/text",
+		"gopkg.in/yaml.v3", "gopkg.in/check.v1", "rsc.io/quote/v3", "example.com/a/b/c",
+		`"example.com/quoted"`, "example.com/ünicode", "example.com/m/v10", "x",
+	)
+}
+
+// modVersion returns a version for path. Paths ending in a major version
+// suffix (/v2, .v3) take versions of that major; others take v0, v1 or
+// +incompatible ones.
+func modVersion(s *gen.State, path string) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "v1", "v1.2", "1.2.3", "v01.2.3", "v1.2.3-", "v1.2.3+meta", "latest", "master",
+			"v0.0.0-2019-daa7c04131f5", "v2.0.0", "v9.0.0", `"v1.0.0`, "v1.0.0-0.", "")
+	}
+	path = strings.Trim(path, `"`)
+	major := ""
+	if i := strings.LastIndexAny(path, "/."); i >= 0 && strings.HasPrefix(path[i+1:], "v") {
+		if n := path[i+2:]; n != "" && strings.Trim(n, "0123456789") == "" {
+			major = n
+		}
+	}
+	if major == "" {
+		if s.Chance(0.15) {
+			return "v2.0.0+incompatible"
+		}
+		major = gen.Pick(s, "0", "1")
+	}
+	return "v" + major + gen.Pick(s,
+		".0.0", ".1.0", ".2.3", ".2.3-pre", ".2.3-rc.1", ".10.0",
+		".0.0-20191109021931-daa7c04131f5", ".2.4-0.20191109021931-daa7c04131f5",
+	)
+}
+
+func goVersion(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "1.x", "go1.21", "1.21.0.0", "2", "1.021", "v1.21", "1.21rc", "")
+	}
+	return gen.Pick(s, "1.11", "1.17", "1.21", "1.21.0", "1.22rc1", "1.23.4", "1.24", "1.24.0", "1.25", "1.99")
+}
+
+func toolchain(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "1.24", "go1", "gccgo", "go1.24 extra", "local")
+	}
+	return gen.Pick(s, "go1.21.0", "go1.22rc1", "go1.24.1", "go1.24.1-custom", "default")
+}
+
+func localPath(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "./with space", `.\win`, `C:\dir`, "a/not/rooted", `"./unterminated`)
+	}
+	return gen.Pick(s, "./a", "../b", "/abs/dir", `"./with space"`, "../../up/up", "./", ".", "./a/../b")
+}
+
+func comment(s *gen.State) string {
+	if !s.Chance(0.3) {
+		return ""
+	}
+	return gen.Pick(s, " // indirect", " // indirect; comment", " // comment", " //indirect", " // Deprecated: no")
+}
+
+func godebug(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "nokey", "=1", "a b=1", `"x"=1`)
+	}
+	return gen.Pick(s, "default=go1.21", "panicnil=1", "httpmuxgo121=0", "tlsrsakex=1", "x509sha1=1", "zipinsecurepath=0")
+}
+
+func goMod(s *gen.State) []gen.File {
+	var m modFile
+	if s.Chance(0.3) {
+		m.line("// Deprecated: use example.com/m/v2 instead.")
+	}
+	m.line("module %s", modPath(s))
+	m.line("")
+	if s.Chance(0.9) {
+		m.line("go %s", goVersion(s))
+	}
+	if s.Chance(0.4) {
+		m.line("toolchain %s", toolchain(s))
+	}
+	if s.Chance(0.3) {
+		m.block(s, "godebug", lines(s, 1, 3, godebug))
+	}
+
+	directives := []func(){
+		func() {
+			m.block(s, "require", lines(s, 1, 6, func(s *gen.State) string {
+				p := modPath(s)
+				return p + " " + modVersion(s, p) + comment(s)
+			}))
+		},
+		func() {
+			m.block(s, "exclude", lines(s, 1, 3, func(s *gen.State) string {
+				p := modPath(s)
+				return p + " " + modVersion(s, p)
+			}))
+		},
+		func() { m.block(s, "replace", lines(s, 1, 4, replace)) },
+		func() { m.block(s, "retract", lines(s, 1, 4, retract)) },
+		func() {
+			m.block(s, "tool", lines(s, 1, 3, func(s *gen.State) string {
+				return gen.Pick(s, "example.com/cmd", "golang.org/x/tools/cmd/stringer", "example.com/m/tools/gen")
+			}))
+		},
+		func() {
+			m.block(s, "ignore", lines(s, 1, 3, func(s *gen.State) string {
+				return gen.Pick(s, "./node_modules", "static", "./testdata/big", `"./with space"`)
+			}))
+		},
+	}
+	for range s.Range(1, 8) {
+		m.line("")
+		gen.Pick(s, directives...)()
+	}
+	if s.Chance(badRate) {
+		m.line("%s", gen.Pick(s, "unknown directive", "require (", ")", "module second/module", "go 1.21\ngo 1.22", "require example.com/x v1.0.0 extra"))
+	}
+	return []gen.File{{Name: "go.mod", Data: []byte(m.b.String())}}
+}
+
+func lines(s *gen.State, lo, hi int, line func(*gen.State) string) []string {
+	out := make([]string, s.Range(lo, hi))
+	for i := range out {
+		out[i] = line(s)
+	}
+	return out
+}
+
+func replace(s *gen.State) string {
+	old := modPath(s)
+	if s.Chance(0.5) {
+		old += " " + modVersion(s, old)
+	}
+	if s.Chance(0.5) {
+		return old + " => " + localPath(s)
+	}
+	p := modPath(s)
+	return old + " => " + p + " " + modVersion(s, p)
+}
+
+func retract(s *gen.State) string {
+	// Retractions are versions of the module itself, example.com/m.
+	var r string
+	if s.Chance(0.6) {
+		r = modVersion(s, "example.com/m")
+	} else {
+		r = fmt.Sprintf("[%s, %s]", modVersion(s, "example.com/m"), modVersion(s, "example.com/m"))
+	}
+	if s.Chance(badRate) {
+		r = gen.Pick(s, "[v1.0.0,", "[v1.0.0]", "[v1.0.0 v1.1.0]", "v1.0.0, v1.1.0]")
+	}
+	if s.Chance(0.5) {
+		r += gen.Pick(s, " // security issue", " // Published accidentally.", " //", " // rationale // nested")
+	}
+	return r
+}
+
+func goWork(s *gen.State) []gen.File {
+	var m modFile
+	m.line("go %s", goVersion(s))
+	if s.Chance(0.3) {
+		m.line("toolchain %s", toolchain(s))
+	}
+	if s.Chance(0.2) {
+		m.block(s, "godebug", lines(s, 1, 2, godebug))
+	}
+	m.line("")
+	m.block(s, "use", lines(s, 1, 5, localPath))
+	if s.Chance(0.5) {
+		m.line("")
+		m.block(s, "replace", lines(s, 1, 3, replace))
+	}
+	if s.Chance(badRate) {
+		m.line("%s", gen.Pick(s, "module example.com/m", "require example.com/x v1.0.0", "use", "use ./a ./b"))
+	}
+	return []gen.File{{Name: "go.work", Data: []byte(m.b.String())}}
+}
+
+func goSum(s *gen.State) []gen.File {
+	var m modFile
+	for range s.Range(1, 10) {
+		path := strings.Trim(modPath(s), `"`)
+		v := strings.Trim(modVersion(s, path), `"`)
+		hash := gen.Pick(s,
+			"h1:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=",
+			"h1:n9eRCGUtrW8Jc6f0vWrNAn0Xk1fDSMc2qoZCTkjfTDs=",
+			"h1:qgOY6WgZOaTkIIMiVjBQcw93ERBE4m30iBm00nkL0i8=",
+		)
+		if s.Chance(badRate) {
+			hash = gen.Pick(s, "h1:", "h1:!!!=", "h2:47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU=", "47DEQpj8", "")
+		}
+		m.line("%s %s %s", path, v, hash)
+		if s.Chance(0.7) {
+			m.line("%s %s/go.mod %s", path, v, hash)
+		}
+	}
+	return []gen.File{{Name: "go.sum", Data: []byte(m.b.String())}}
+}
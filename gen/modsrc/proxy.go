@@ -0,0 +1,333 @@
+package modsrc
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"hash/crc32"
+	"io/fs"
+	"strings"
+
+	"golang.org/x/mod/module"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "mod/zip",
+		Doc:  "module zips: names that collide under case folding, files beside directories of the same name, go.mod in the wrong case or directory, vendor trees and nested modules, wrong prefixes, symlinks and other irregular files stored as files, and sizes past the limits or declared wrongly",
+		Func: modZip,
+	})
+	gen.Register(&gen.Generator{
+		Name: "mod/proxy",
+		Doc:  "GOPROXY responses for one module version: an @v/list with times, pseudo-versions and junk lines, a .info with the wrong version or time, a .mod declaring the module or another, and the version's zip",
+		Func: proxy,
+	})
+}
+
+// zipBadRate is the chance that an entry of a "mod/zip" zip is made one
+// a module zip must not hold. A zip has a handful of entries, so about
+// a third of zips have one.
+const zipBadRate = 0.08
+
+// tooLarge is the size of a go.mod or LICENSE file one byte past the
+// limit on each, which deflates to a few kilobytes.
+const tooLarge = 16<<20 + 1
+
+// zipPath returns the module path of a zip: with a major version suffix,
+// with upper case letters the proxy protocol escapes, or, rarely, one
+// that is invalid.
+func zipPath(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "example.com/m/v1", "example.com/ünicode", "Example", "example.com/m@v1", "example.com/m/", "")
+	}
+	return gen.Pick(s,
+		"example.com/m", "example.com/m/v2", "github.com/User/Repo", "gopkg.in/yaml.v3", "rsc.io/quote/v3",
+		"example.com/a/b/c", "example.com/~user/x", "golang.org/x/text",
+	)
+}
+
+// zipVersion returns a canonical version for path, or, rarely, one that
+// is not canonical or not of path's major version.
+func zipVersion(s *gen.State, path string) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "v1.2", "v1.0.0+meta", "V1.0.0", "latest", "v2.0.0", "v1.0.0-Pre")
+	}
+	v := modVersion(s, path)
+	if module.CanonicalVersion(v) != v {
+		return "v1.0.0"
+	}
+	return v
+}
+
+// zipNames are files of a module, some of which module zips hold but
+// the go command leaves out when it makes one.
+var zipNames = []string{
+	"LICENSE", "README.md", "m.go", "m_test.go", "doc.go", "internal/x/x.go", "cmd/tool/main.go",
+	"testdata/in.txt", "testdata/.hidden", "sub/sub.go", "a/b/c/d.go", ".gitignore", "go.sum", "x.s",
+	"vendor/modules.txt", "vendor/example.com/dep/dep.go", "pkg/vendor/v.go", "pkg/vendor/x/y.go",
+	".hg_archival.txt", "nested/n.go", "~tilde.go", "-dash.go", "a b.go", "ünicode.go", "dir/",
+}
+
+// badZipNames are names a module zip may not hold, or not beside the
+// names in zipNames: unclean and absolute paths, characters a file
+// system may not take, names that fold to others, files where others
+// have directories, and go.mod files out of place.
+var badZipNames = []string{
+	"a//b.go", "./a.go", "a/../b.go", "../up.go", "/abs.go", "a\\b.go", "a:b.go", "GO.MOD", "Go.mod",
+	"nested/go.mod", "sub/GO.MOD", "readme.md", "Readme.md", "license", "License", "M.go", "ſub/sub.go",
+	"k.go", "\u212a.go", "Internal/x/x.go", "a/B/c/d.go", "con.go", "aux/x.go", "nul", "a\x00.go",
+	"\xff.go", "a?.go", "a*.go", `a".go`, "a'.go", "a`.go", "a;b.go", "a.go.", "a.", ".", "..",
+	"a\t.go", "m.go/x.go", "sub", "sub/sub.go/", "cmd", "README.md",
+}
+
+// A zipEntry is one entry of a module zip.
+type zipEntry struct {
+	name   string
+	data   []byte
+	mode   fs.FileMode
+	method uint16
+	// size, if not -1, is the uncompressed size the entry declares in
+	// place of the size of its data.
+	size int64
+}
+
+// modZip writes a module zip, alone, at the path the proxy protocol
+// serves it from.
+func modZip(s *gen.State) []gen.File {
+	p := zipPath(s)
+	v := zipVersion(s, p)
+	return []gen.File{proxyFile(p, v, ".zip", buildZip(s, p, v, goModData(s, p), zipBadRate))}
+}
+
+// proxy writes what the proxy protocol serves for one version of a
+// module: the module's @v/list, and the version's .info, .mod and .zip.
+func proxy(s *gen.State) []gen.File {
+	p := zipPath(s)
+	v := zipVersion(s, p)
+	mod := goModData(s, p)
+	zipMod := mod
+	if s.Chance(0.1) {
+		// The .mod a proxy serves need not be the zip's go.mod.
+		zipMod = goModData(s, p)
+	}
+	return []gen.File{
+		proxyFile(p, v, ".info", revInfo(s, v)),
+		proxyFile(p, v, ".mod", mod),
+		proxyFile(p, v, ".zip", buildZip(s, p, v, zipMod, badRate)),
+		proxyFile(p, "", "list", versionList(s, p, v)),
+	}
+}
+
+// proxyFile returns the file the proxy protocol serves for version v of
+// path, with ext after it, or, for an empty v, the file of path named
+// ext. A path or version that does not escape is named as it is.
+func proxyFile(path, v, ext string, data []byte) gen.File {
+	esc, err := module.EscapePath(path)
+	if err != nil {
+		esc = path
+	}
+	if escV, err := module.EscapeVersion(v); err == nil {
+		v = escV
+	}
+	return gen.File{Name: esc + "/@v/" + v + ext, Data: data}
+}
+
+// goModData returns a go.mod for path, which rarely declares another
+// path or none.
+func goModData(s *gen.State, path string) []byte {
+	var m modFile
+	if s.Chance(badRate) {
+		m.line("%s", gen.Pick(s, "", "module", "module example.com/other", "module "+strings.ToUpper(path), "module "+path+"/v9"))
+	} else {
+		m.line("module %s", path)
+	}
+	if s.Chance(0.8) {
+		m.line("")
+		m.line("go %s", goVersion(s))
+	}
+	if s.Chance(0.3) {
+		m.line("")
+		m.block(s, "require", lines(s, 1, 3, func(s *gen.State) string {
+			p := strings.Trim(modPath(s), `"`)
+			return p + " " + modVersion(s, p)
+		}))
+	}
+	if s.Chance(0.1) {
+		m.line("")
+		m.block(s, "retract", lines(s, 1, 2, retract))
+	}
+	return []byte(m.b.String())
+}
+
+// zipFileData returns the contents of a file of the given name.
+func zipFileData(s *gen.State, name string) []byte {
+	switch {
+	case strings.HasSuffix(name, "/"):
+		return nil
+	case strings.HasSuffix(name, ".go"):
+		return []byte(gen.Pick(s, "package m\n", "package m\n\nfunc F() int { return 1 }\n", "package main\n\nfunc main() {}\n", "//go:build ignore\n\npackage x\n"))
+	case name == "LICENSE":
+		return []byte("Copyright 2024 The Authors. All rights reserved.\n")
+	case name == "vendor/modules.txt":
+		return []byte("# example.com/dep v1.0.0\n## explicit\nexample.com/dep\n")
+	case name == ".hg_archival.txt":
+		return []byte("repo: 0123456789abcdef\nnode: fedcba9876543210\n")
+	}
+	return []byte(gen.Pick(s, "", "text\n", "\x00\x01\x02\xff", strings.Repeat("x", 1000)))
+}
+
+// buildZip returns a zip of version v of path with goMod as its go.mod,
+// in which each entry is, with chance bad, made one a module zip must
+// not hold. Its first entry always has the zip's prefix.
+func buildZip(s *gen.State, path, v string, goMod []byte, bad float64) []byte {
+	prefix := path + "@" + v + "/"
+	var entries []zipEntry
+	add := func(name string, data []byte) {
+		entries = append(entries, zipEntry{name: name, data: data, mode: 0o644, method: zip.Deflate, size: -1})
+	}
+	if s.Chance(0.9) {
+		add(prefix+"go.mod", goMod)
+	}
+	names := append([]string(nil), zipNames...)
+	gen.Shuffle(s, names)
+	for _, name := range names[:s.Range(1, 8)] {
+		add(prefix+name, zipFileData(s, name))
+	}
+	for range len(entries) {
+		if !s.Chance(bad) {
+			continue
+		}
+		e := &entries[s.Intn(len(entries))]
+		switch s.Intn(8) {
+		case 0, 1:
+			name := gen.Pick(s, badZipNames...)
+			add(prefix+name, zipFileData(s, name))
+		case 2:
+			// Symlinks are stored with the target as their data.
+			e.mode = fs.ModeSymlink | 0o777
+			e.data = []byte(gen.Pick(s, "../../../../etc/passwd", "/etc/passwd", "m.go", "..", "sub"))
+		case 3:
+			e.mode = gen.Pick(s, fs.ModeDir|0o755, fs.ModeDevice|0o644, fs.ModeNamedPipe|0o644, fs.ModeSetuid|0o755, 0o777, 0)
+		case 4:
+			e.size = gen.Pick(s, int64(len(e.data))+1, int64(len(e.data))/2, 0, 1<<32, 500<<20+1)
+		case 5:
+			rest, data := strings.TrimPrefix(e.name, prefix), e.data
+			add(gen.Pick(s,
+				path+"@v0.0.0/"+rest, strings.ToUpper(prefix)+rest, strings.TrimSuffix(prefix, "/")+"x/"+rest, rest, prefix, path+"/"+rest,
+			), data)
+		case 6:
+			data := bytes.Repeat([]byte{'\n'}, tooLarge)
+			copy(data, goMod)
+			add(prefix+gen.Pick(s, "go.mod", "LICENSE"), data)
+		default:
+			// The same name again, which an extractor keeping the
+			// last entry of each name would take.
+			add(e.name, []byte("package m // again\n"))
+		}
+	}
+	gen.Shuffle(s, entries[1:])
+
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	for _, e := range entries {
+		if s.Chance(0.2) {
+			e.method = zip.Store
+		}
+		writeEntry(w, e)
+	}
+	if err := w.Close(); err != nil {
+		panic(err)
+	}
+	return b.Bytes()
+}
+
+// writeEntry writes e to w, declaring e.size as its size if it is not
+// -1.
+func writeEntry(w *zip.Writer, e zipEntry) {
+	h := &zip.FileHeader{Name: e.name, Method: e.method}
+	h.SetMode(e.mode)
+	if strings.HasSuffix(e.name, "/") {
+		h.Method = zip.Store
+		if _, err := w.CreateHeader(h); err != nil {
+			panic(err)
+		}
+		return
+	}
+	if e.size == -1 {
+		fw, err := w.CreateHeader(h)
+		if err != nil {
+			panic(err)
+		}
+		fw.Write(e.data)
+		return
+	}
+	raw := e.data
+	if e.method == zip.Deflate {
+		var c bytes.Buffer
+		fw, _ := flate.NewWriter(&c, flate.BestSpeed)
+		fw.Write(e.data)
+		fw.Close()
+		raw = c.Bytes()
+	}
+	h.CRC32 = crc32.ChecksumIEEE(e.data)
+	h.CompressedSize64 = uint64(len(raw))
+	h.UncompressedSize64 = uint64(e.size)
+	fw, err := w.CreateRaw(h)
+	if err != nil {
+		panic(err)
+	}
+	fw.Write(raw)
+}
+
+// revInfo returns the .info of version v: its version and time as JSON,
+// now and then with an origin, and rarely with another version, a time
+// that is not RFC 3339 or as JSON that is not an object.
+func revInfo(s *gen.State, v string) []byte {
+	version, t := v, gen.Pick(s, "2024-01-02T15:04:05Z", "2019-11-09T02:19:31Z", "2024-01-02T15:04:05.123456789+07:00")
+	if s.Chance(badRate) {
+		switch s.Intn(3) {
+		case 0:
+			version = gen.Pick(s, "", "v1.0", "v9.9.9", "master", strings.ToUpper(v), v+"+meta")
+		case 1:
+			t = gen.Pick(s, "", "2024-01-02", "0001-01-01T00:00:00Z", "2024-13-45T99:99:99Z", "10000-01-01T00:00:00Z")
+		default:
+			return []byte(gen.Pick(s, "", "null", "[]", "{", `{"Version":1}`, `"v1.0.0"`, `{"Version":"`+v+`","Time":0}`))
+		}
+	}
+	var b strings.Builder
+	b.WriteString(`{"Version":"` + version + `","Time":"` + t + `"`)
+	if s.Chance(0.2) {
+		b.WriteString(`,"Origin":{"VCS":"git","URL":"https://example.com/m","Hash":"0123456789abcdef0123456789abcdef01234567","Ref":"refs/tags/` + v + `"}`)
+	}
+	b.WriteString("}\n")
+	return []byte(b.String())
+}
+
+// versionList returns the @v/list of path, which lists v and other
+// versions, some with times after them, and, now and then, pseudo- and
+// non-canonical versions, duplicates and lines that are not versions.
+func versionList(s *gen.State, path, v string) []byte {
+	versions := []string{v}
+	for range s.Range(0, 5) {
+		versions = append(versions, modVersion(s, path))
+	}
+	if s.Chance(0.2) {
+		versions = append(versions, gen.Pick(s, "v1.0", "v1", "bogus", "", "v1.0.0 extra fields here", v, "v0.0.0-20191109021931-daa7c04131f5", "# comment"))
+	}
+	gen.Shuffle(s, versions)
+	eol := "\n"
+	if s.Chance(0.05) {
+		eol = "\r\n"
+	}
+	var b strings.Builder
+	for _, l := range versions {
+		b.WriteString(l)
+		if s.Chance(0.1) {
+			b.WriteString(" 2024-01-02T15:04:05Z")
+		}
+		b.WriteString(eol)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,193 @@
+package modsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "mod/version",
+		Doc:  "module versions, one to a line, alone, after a module path or as retract intervals: shorthand, prerelease and build forms, the three pseudo-version forms with good and bad timestamps and revisions, +incompatible, major version suffixes and gopkg.in paths, and near misses of each",
+		Func: versions,
+	})
+}
+
+// versionBadRate is the chance that a part of a version is drawn in a
+// form semver rejects. A version has a handful of parts, so about one
+// in five is invalid, and a seed of several lines usually has one.
+const versionBadRate = 0.04
+
+// versions writes lines of versions: a version alone, a module path and
+// a version, or a retract interval.
+func versions(s *gen.State) []gen.File {
+	var b strings.Builder
+	for range s.Range(1, 12) {
+		switch {
+		case s.Chance(0.3):
+			p := versionPath(s)
+			fmt.Fprintf(&b, "%s %s\n", p, pathVersion(s, p))
+		case s.Chance(0.15):
+			b.WriteString(interval(s) + "\n")
+		default:
+			b.WriteString(semverString(s) + "\n")
+		}
+	}
+	return []gen.File{{Name: "input.versions", Data: []byte(b.String())}}
+}
+
+// number returns a version number: small, large, past 64 bits or,
+// rarely, with a leading zero or not a number at all.
+func number(s *gen.State) string {
+	if s.Chance(versionBadRate) {
+		return gen.Pick(s, "01", "00", "", "-1", "1a", "١", "+1", " 1")
+	}
+	if s.Chance(0.05) {
+		return gen.Pick(s, "2147483648", "4294967296", "9223372036854775808", "18446744073709551616", "99999999999999999999999999")
+	}
+	return gen.Pick(s, "0", "0", "1", "1", "2", "3", "9", "10", "11", "100")
+}
+
+// identifier returns a prerelease or build identifier.
+func identifier(s *gen.State, build bool) string {
+	if s.Chance(versionBadRate) {
+		return gen.Pick(s, "", "a_b", "a b", "ä", "a+b", "a.")
+	}
+	if !build && s.Chance(versionBadRate) {
+		return gen.Pick(s, "01", "00", "0001")
+	}
+	return gen.Pick(s, "alpha", "beta", "rc", "pre", "0", "1", "2", "10", "11", "x-y", "-", "--", "0a", "a0", "A", "Z", "incompatible", "0.0")
+}
+
+// semverString returns a semantic version, usually valid: complete or
+// shorthand, with prereleases, builds and pseudo-version forms.
+func semverString(s *gen.State) string {
+	if s.Chance(0.2) {
+		return pseudo(s, gen.Pick(s, "v0", "v1", "v2", "v10"))
+	}
+	prefix := "v"
+	if s.Chance(versionBadRate) {
+		prefix = gen.Pick(s, "", "V", "vv", "v.", "go")
+	}
+	v := prefix + number(s)
+	if s.Chance(0.1) {
+		if s.Chance(0.5) {
+			v += "." + number(s)
+		}
+		if s.Chance(versionBadRate) {
+			v += gen.Pick(s, "-pre", "+build", ".", "..1")
+		}
+		return v
+	}
+	v += "." + number(s) + "." + number(s)
+	if s.Chance(0.3) {
+		v += "-" + identifier(s, false)
+		for range s.Range(0, 2) {
+			v += "." + identifier(s, false)
+		}
+	}
+	if s.Chance(0.2) {
+		if s.Chance(0.5) {
+			v += "+incompatible"
+		} else {
+			v += "+" + identifier(s, true)
+			for range s.Range(0, 2) {
+				v += "." + identifier(s, true)
+			}
+		}
+	}
+	if s.Chance(versionBadRate) {
+		v += gen.Pick(s, "-", "+", ".0", " ", "\x00", "/go.mod")
+	}
+	return v
+}
+
+// pseudo returns a pseudo-version of the given major version in one of
+// its three forms: with no base version, after a prerelease, and after
+// a release. Now and then its timestamp is not a time or its revision
+// not a commit hash, or it is near one of the forms but not of it.
+func pseudo(s *gen.State, major string) string {
+	ts := gen.Pick(s, "20191109021931", "20240229235959", "00010101000000", "99991231235959")
+	if s.Chance(versionBadRate * 2) {
+		ts = gen.Pick(s, "20191309021931", "20190230000000", "20191109246060", "2019110902193", "201911090219310", "2019-11-09")
+	}
+	rev := gen.Pick(s, "daa7c04131f5", "000000000000", "abcdefabcdef")
+	if s.Chance(versionBadRate * 2) {
+		rev = gen.Pick(s, "daa7c04131f", "daa7c04131f5a", "DAA7C04131F5", "zzzzzzzzzzzz", "daa7c04131f5daa7c04131f5daa7c04131f5daa7c041", "")
+	}
+	var v string
+	switch s.Intn(3) {
+	case 0:
+		v = major + ".0.0-" + ts + "-" + rev
+	case 1:
+		v = major + "." + number(s) + "." + number(s) + "-" + gen.Pick(s, "pre", "rc.1", "0", "alpha.beta") + ".0." + ts + "-" + rev
+	default:
+		v = major + "." + number(s) + "." + gen.Pick(s, "1", "0", "10") + "-0." + ts + "-" + rev
+	}
+	if s.Chance(0.15) {
+		v += "+incompatible"
+	}
+	if s.Chance(versionBadRate) {
+		v = gen.Pick(s,
+			strings.Replace(v, "-0.", "-1.", 1), strings.Replace(v, ".0.", ".00.", 1), strings.Replace(v, "-"+ts, "."+ts, 1),
+			strings.Replace(v, "-"+rev, "."+rev, 1), v+"+meta", v+"-"+rev,
+		)
+	}
+	return v
+}
+
+// versionPath returns a module path, often with a major version suffix
+// or a gopkg.in one, and now and then one near such a suffix.
+func versionPath(s *gen.State) string {
+	if s.Chance(0.2) {
+		return gen.Pick(s,
+			"example.com/m/v0", "example.com/m/v1", "example.com/m/v01", "example.com/m/v2.1", "example.com/m/V2",
+			"gopkg.in/yaml.v0", "gopkg.in/yaml.v01", "gopkg.in/check.v1-unstable", "gopkg.in/yaml", "gopkg.in/v2",
+			"example.com/v2", "v2", "example.com/m/v2/", "example.com/m/v2/v3", "example.com/m.v2",
+		)
+	}
+	return gen.Pick(s,
+		"example.com/m", "example.com/m/v2", "example.com/m/v3", "example.com/m/v10", "gopkg.in/yaml.v2",
+		"gopkg.in/yaml.v3", "gopkg.in/check.v1", "gopkg.in/user/repo.v1", "github.com/user/repo", "golang.org/x/mod",
+	)
+}
+
+// pathVersion returns a version for path, mostly of the major version
+// its suffix asks for.
+func pathVersion(s *gen.State, path string) string {
+	if s.Chance(0.3) {
+		return semverString(s)
+	}
+	major := "v1"
+	if i := strings.LastIndexAny(path, "/."); i >= 0 && strings.HasPrefix(path[i+1:], "v") {
+		major = strings.TrimSuffix(path[i+1:], "-unstable")
+	} else if s.Chance(0.3) {
+		major = gen.Pick(s, "v0", "v2")
+	}
+	if s.Chance(0.3) {
+		return pseudo(s, major)
+	}
+	if strings.HasPrefix(path, "gopkg.in/") && major == "v1" && s.Chance(0.2) {
+		// The go command once wrote v0.0.0- pseudo-versions for .v1.
+		return pseudo(s, "v0")
+	}
+	v := major + "." + number(s) + "." + number(s)
+	if major != "v0" && major != "v1" && !strings.Contains(path, "/v") && !strings.HasPrefix(path, "gopkg.in/") {
+		v += "+incompatible"
+	}
+	return v
+}
+
+// interval returns a retract interval, or rarely something near one.
+func interval(s *gen.State) string {
+	lo, hi := semverString(s), semverString(s)
+	if s.Chance(0.3) {
+		return lo
+	}
+	if s.Chance(versionBadRate) {
+		return gen.Pick(s, "["+lo+"]", "["+lo+", ]", "["+lo+" "+hi+"]", "("+lo+", "+hi+")", "["+lo+", "+hi, `["`+lo+`", "`+hi+`"]`, "[]")
+	}
+	return "[" + lo + ", " + hi + "]"
+}
@@ -0,0 +1,215 @@
+// Package multipartsrc generates multipart MIME seeds. It registers the
+// "multipart/..." generators with package gen.
+//
+// A seed is a header, whose Content-Type names the boundary, a blank
+// line and a multipart body, as an HTTP request or a mail message has
+// them. Bodies are framed the ways readers disagree on: boundaries that
+// are prefixes of one another, or of lines in the content, nested bodies
+// reusing the outer boundary, padding after a delimiter, line feeds for
+// CRLFs and a close delimiter missing or followed by more parts. Part
+// headers come in multiples and in thousands of bytes, and contents are
+// quoted-printable, base64 or plain. Most of a body is well formed; each
+// part of it also has malformed variants, drawn rarely.
+package multipartsrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "multipart/form",
+		Doc:  "multipart/form-data bodies: fields and files with odd and duplicate names, path-laden filenames, boundary-in-content collisions, huge and repeated part headers, and missing close delimiters",
+		Func: form,
+	})
+	gen.Register(&gen.Generator{
+		Name: "multipart/mixed",
+		Doc:  "multipart/mixed and alternative bodies nested in one another with boundaries that prefix or repeat the outer one, quoted-printable and base64 parts, preambles, epilogues and transport padding",
+		Func: mixed,
+	})
+}
+
+// badRate is the chance that a part of a body is drawn malformed. A body
+// has a dozen such parts or so, so about one in eight gets one.
+const badRate = 0.01
+
+// An mgen accumulates one seed, each line ended by eol.
+type mgen struct {
+	s   *gen.State
+	b   strings.Builder
+	eol string
+}
+
+func form(s *gen.State) []gen.File {
+	return seed(s, "form-data", false)
+}
+
+func mixed(s *gen.State) []gen.File {
+	return seed(s, gen.Pick(s, "mixed", "alternative", "related", "MIXED"), true)
+}
+
+func seed(s *gen.State, subtype string, nest bool) []gen.File {
+	m := &mgen{s: s, eol: "\r\n"}
+	if s.Chance(0.1) {
+		m.eol = "\n"
+	}
+	boundary := m.boundary()
+	m.line("Content-Type: multipart/" + subtype + "; boundary=" + quote(s, boundary))
+	if s.Chance(0.3) {
+		m.line(gen.Pick(s, "MIME-Version: 1.0", "Content-Length: 12", "Content-Transfer-Encoding: 8bit"))
+	}
+	m.line("")
+	m.body(boundary, subtype == "form-data", nest, s.Depth(s.Limits.Literal, 2))
+	return []gen.File{{Name: "input.multipart", Data: []byte(m.b.String())}}
+}
+
+func (m *mgen) line(s string) {
+	m.b.WriteString(s + m.eol)
+}
+
+// quote returns the boundary b as a parameter value, quoted where it
+// must be and sometimes where it need not be.
+func quote(s *gen.State, b string) string {
+	if strings.ContainsAny(b, " ()<>@,;:\\\"/[]?=") || b == "" || s.Chance(0.3) {
+		return `"` + b + `"`
+	}
+	return b
+}
+
+// boundary returns a boundary: a browser's, a mail client's, one at or
+// past the length limit of 70, or one that is short enough to turn up in
+// content.
+func (m *mgen) boundary() string {
+	if m.s.Chance(badRate * 2) {
+		return gen.Pick(m.s, "", strings.Repeat("b", 71), "b ", "b\x00", "é", "b\r\nX-Injected: 1")
+	}
+	return gen.Pick(m.s,
+		"b", "x", "--", "-", "==", "boundary", "----WebKitFormBoundary7MA4YWxkTrZu0gW",
+		"---------------------------974767299852498929531610575", "=_Part_0_12345.67890", "a b", "a:b",
+		strings.Repeat("b", 70), "b1", "0",
+	)
+}
+
+// body writes the parts of a multipart body between delimiters of
+// boundary, and, in form data, a field or file in each part. A nested
+// body is one of the parts, with a boundary of its own, or not.
+func (m *mgen) body(boundary string, form, nest bool, depth int) {
+	if m.s.Chance(0.2) {
+		m.line(gen.Pick(m.s, "This is a multi-part message in MIME format.", "preamble --"+boundary+"x", "", "--"+boundary+"x"))
+	}
+	for range m.s.Range(0, 5) {
+		m.delimiter(boundary, false)
+		if nest && depth > 0 && m.s.Chance(0.3) {
+			inner := gen.Pick(m.s, boundary+"1", boundary, "1"+boundary, boundary[:len(boundary)/2], "inner")
+			m.line("Content-Type: multipart/mixed; boundary=" + quote(m.s, inner))
+			m.line("")
+			m.body(inner, false, nest, depth-1)
+			continue
+		}
+		m.part(boundary, form)
+	}
+	switch {
+	case m.s.Chance(0.1):
+		// A body cut short, with no close delimiter.
+	case m.s.Chance(badRate * 4):
+		m.line(gen.Pick(m.s, "--"+boundary+"-", "--"+boundary+" --", boundary+"--", "--"+strings.ToUpper(boundary)+"--"))
+	default:
+		m.delimiter(boundary, true)
+		if m.s.Chance(0.2) {
+			m.line(gen.Pick(m.s, "epilogue", "--"+boundary, "--"+boundary+"--", ""))
+		}
+	}
+}
+
+// delimiter writes a delimiter line, with padding after it now and then.
+func (m *mgen) delimiter(boundary string, close bool) {
+	m.b.WriteString("--" + boundary)
+	if close {
+		m.b.WriteString("--")
+	}
+	switch {
+	case m.s.Chance(0.05):
+		m.b.WriteString(gen.Pick(m.s, " ", "\t", "  \t "))
+	case m.s.Chance(badRate):
+		m.b.WriteString(gen.Pick(m.s, "x", "--", "\r", " x"))
+	}
+	m.b.WriteString(m.eol)
+}
+
+// part writes the header and content of one part.
+func (m *mgen) part(boundary string, form bool) {
+	enc := ""
+	if form {
+		m.disposition()
+	} else if m.s.Chance(0.3) {
+		m.line("Content-Disposition: " + gen.Pick(m.s, "inline", "attachment; filename=a.txt", `attachment; filename*=utf-8''%E2%82%AC.txt`))
+	}
+	if m.s.Chance(0.4) {
+		m.line("Content-Type: " + gen.Pick(m.s, "text/plain", "text/plain; charset=utf-8", "application/octet-stream", "image/png", "text/html", "message/rfc822"))
+	}
+	if m.s.Chance(0.3) {
+		enc = gen.Pick(m.s, "quoted-printable", "base64", "7bit", "8bit", "binary", "Quoted-Printable", "x-unknown")
+		m.line("Content-Transfer-Encoding: " + enc)
+	}
+	if m.s.Chance(0.05) {
+		// Headers in the thousands, or thousands of bytes long, as
+		// readers must limit.
+		if m.s.Chance(0.5) {
+			for i := range m.s.Range(100, 2000) {
+				m.line("X-H" + strings.Repeat("h", i%7) + ": v")
+			}
+		} else {
+			m.line("X-Long: " + strings.Repeat("v", m.s.Range(1000, 20000)))
+		}
+	}
+	if m.s.Chance(badRate * 2) {
+		m.line(gen.Pick(m.s, "no colon", " folded", "Content-Type : text/plain", ": empty name", "X\x00: v"))
+	}
+	m.line("")
+	m.content(boundary, enc)
+	m.b.WriteString(m.eol)
+}
+
+// disposition writes a form-data Content-Disposition for a field or file.
+func (m *mgen) disposition() {
+	if m.s.Chance(badRate * 2) {
+		m.line("Content-Disposition: " + gen.Pick(m.s, "form-data", "form-data; name=", `form-data; name="unterminated`, "attachment; name=a", "form-data; name*=utf-8''a"))
+		return
+	}
+	d := "form-data; name=" + gen.Pick(m.s, `"a"`, `"file"`, "a", `""`, `"a[]"`, `"a\"b"`, `"é"`, `"a b"`, `"_charset_"`, `"A"`)
+	if m.s.Chance(0.4) {
+		d += "; filename=" + gen.Pick(m.s,
+			`"a.txt"`, `""`, `"../../etc/passwd"`, `".."`, `"."`, `"/abs/path"`, `"C:\\Windows\\win.ini"`, `"a/"`, `"a\x00.txt"`,
+			`"évil.exe"`, `"a.txt.exe"`, "a.txt", `"a\"b"`, `"%2e%2e%2fx"`,
+		)
+	}
+	if m.s.Chance(0.1) {
+		d += "; filename*=" + gen.Pick(m.s, "utf-8''%2E%2E%2Fx", "utf-8''safe.txt", "utf-8''..")
+	}
+	m.line("Content-Disposition: " + d)
+}
+
+// content writes the content of a part, now and then holding lines that
+// are, or nearly are, delimiters of the body it is in.
+func (m *mgen) content(boundary, enc string) {
+	switch enc {
+	case "quoted-printable", "Quoted-Printable":
+		m.b.WriteString(gen.Pick(m.s, "caf=C3=A9", "soft=\r\nbreak", "a=3D", "bad=ZZ", "trailing=", "=\n", "line  \r\nnext", "=0D=0A--"+boundary))
+		return
+	case "base64":
+		m.b.WriteString(gen.Pick(m.s, "aGVsbG8=", "aGVs\r\nbG8=", "!!!", "aGVsbG8", ""))
+		return
+	}
+	for i := range m.s.Range(0, 3) {
+		if i > 0 {
+			m.b.WriteString(m.eol)
+		}
+		if m.s.Chance(0.15) {
+			m.b.WriteString(gen.Pick(m.s, "--"+boundary+"x", "--"+boundary+" x", "-"+boundary, "--"+boundary[:len(boundary)/2], " --"+boundary, "\r--"+boundary, "--"+boundary+"\x00"))
+			continue
+		}
+		m.b.WriteString(gen.Pick(m.s, "value", "", "é", "\x00\x01\xff", "\r", "\n", "a\rb", strings.Repeat("x", m.s.Range(1, 5000)), "--", "-", "\t"))
+	}
+}
@@ -0,0 +1,142 @@
+// Package pathsrc generates file path seeds. It registers the "path/..."
+// generators with package gen.
+//
+// A seed, input.paths, is a few paths, one to a line, as a server gets
+// them from a request and joins, cleans and compares them with a base.
+// "path/traversal" writes slash-separated paths: ".." where it climbs out
+// of a base and where it only seems to, doubled, trailing and missing
+// separators, dot segments and names that are almost dot segments, NUL
+// bytes, invalid UTF-8 and segments past the length a file system takes.
+// Its names are those of the tree fuzz/filepath resolves symlinks in, so
+// that a path walks through links to a parent, to the root, to itself
+// and to nowhere. "path/windows" writes the same with the forms Windows
+// reads: drive letters, relative drives, UNC shares, device paths and
+// reserved device names, with backslashes, slashes or both.
+package pathsrc
+
+import (
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "path/traversal",
+		Doc:  "slash-separated paths: .. climbing out of a base or seeming to, doubled and trailing separators, dot and near-dot segments, NUL bytes, overlong segments, and names of symlinks to a parent, the root, themselves and nowhere",
+		Func: traversal,
+	})
+	gen.Register(&gen.Generator{
+		Name: "path/windows",
+		Doc:  "Windows paths: drive letters, relative drives, UNC shares, \\\\?\\ and \\\\.\\ device paths, reserved device names, trailing dots and spaces, and mixed separators",
+		Func: windows,
+	})
+}
+
+// badRate is the chance that a segment is one no file system takes. A
+// path has several segments and a seed several paths, so about one seed
+// in five gets one.
+const badRate = 0.01
+
+// names are the names in the tree fuzz/filepath builds, and a few more.
+var names = []string{
+	"a", "b", "f", "up", "abs", "self", "loop", "down", "back", "dangling", "up2", "outside", "root",
+	"index.html", "etc", "passwd", "x.tar.gz", ".hidden", "~", "-", "é",
+}
+
+// dots are segments that are dot segments, or look like them.
+var dots = []string{".", "..", "..", "..", "...", "....", ". ", ".. ", "..%2f", "%2e%2e", ".․", "．．"}
+
+func traversal(s *gen.State) []gen.File {
+	return seed(s, func() string {
+		var b strings.Builder
+		switch {
+		case s.Chance(0.3):
+			b.WriteString(gen.Pick(s, "/", "//", "///", "./", "../"))
+		case s.Chance(0.05):
+			b.WriteString(gen.Pick(s, "", "~/", "file:"))
+		}
+		for i := range s.Range(0, 8) {
+			if i > 0 {
+				b.WriteString(separator(s, "/", "//", "/./"))
+			}
+			b.WriteString(segment(s))
+		}
+		if s.Chance(0.2) {
+			b.WriteString(gen.Pick(s, "/", "//", "/.", "/.."))
+		}
+		return b.String()
+	})
+}
+
+func windows(s *gen.State) []gen.File {
+	return seed(s, func() string {
+		var b strings.Builder
+		drive := string(rune('A'+s.Intn(26))) + ":"
+		if s.Chance(0.5) {
+			drive = strings.ToLower(drive)
+		}
+		sep := gen.Pick(s, `\`, `\`, "/")
+		switch {
+		case s.Chance(0.4):
+			b.WriteString(gen.Pick(s, drive, drive+sep, sep))
+		case s.Chance(0.3):
+			b.WriteString(gen.Pick(s,
+				`\\server\share\`, `\\server\share`, `//server/share/`, `\\server`, `\\`, `\\?\`+drive+`\`, `\\?\UNC\server\share\`,
+				`\\.\`, `\\.\COM1`, `\\.\pipe\`, `\??\`+drive+`\`, `//?/`+drive+"/", `\\.\`+drive+`\`, `\/`, `/\`,
+			))
+		}
+		for i := range s.Range(0, 6) {
+			if i > 0 {
+				b.WriteString(separator(s, sep, `\\`, "/", `\.\`))
+			}
+			if s.Chance(0.15) {
+				b.WriteString(gen.Pick(s, "NUL", "nul", "CON", "con.txt", "PRN", "AUX", "COM1", "COM¹", "LPT9", "CONIN$", "CONOUT$", "nul.tar.gz", "NUL "))
+				continue
+			}
+			b.WriteString(segment(s))
+			if s.Chance(0.05) {
+				b.WriteString(gen.Pick(s, ".", " ", ". .", ":", "::$DATA", ":stream", "~1"))
+			}
+		}
+		if s.Chance(0.2) {
+			b.WriteString(sep)
+		}
+		return b.String()
+	})
+}
+
+// seed writes one to four paths from path, one to a line.
+func seed(s *gen.State, path func() string) []gen.File {
+	var b strings.Builder
+	for i := range s.Range(1, 4) {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(path())
+	}
+	return []gen.File{{Name: "input.paths", Data: []byte(b.String())}}
+}
+
+// separator returns the first separator in seps most of the time, and
+// the others now and then.
+func separator(s *gen.State, seps ...string) string {
+	if s.Chance(0.15) {
+		return gen.Pick(s, seps[1:]...)
+	}
+	return seps[0]
+}
+
+// segment returns a name, a dot segment or, rarely, a segment no file
+// system takes.
+func segment(s *gen.State) string {
+	switch {
+	case s.Chance(badRate):
+		return gen.Pick(s, "a\x00b", "\x00", "\xff", "\xc0\xae\xc0\xae", strings.Repeat("n", 256), strings.Repeat("n", s.Range(1000, 5000)))
+	case s.Chance(0.35):
+		return gen.Pick(s, dots...)
+	case s.Chance(0.05):
+		return ""
+	}
+	return gen.Pick(s, names...)
+}
@@ -0,0 +1,242 @@
+package pdfsrc
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rc4"
+	"encoding/binary"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// passwordPad is what pads a password to 32 bytes, from the PDF
+// specification, 7.6.3.3.
+var passwordPad = []byte{
+	0x28, 0xbf, 0x4e, 0x5e, 0x4e, 0x75, 0x8a, 0x41, 0x64, 0x00, 0x4e, 0x56, 0xff, 0xfa, 0x01, 0x08,
+	0x2e, 0x2e, 0x00, 0xb6, 0xd0, 0x68, 0x3e, 0x80, 0x2f, 0x0c, 0xa9, 0xfe, 0x64, 0x53, 0x69, 0x7a,
+}
+
+// A security is the standard security handler of an encrypted document:
+// its version, revision and key length in bytes, whether it encrypts
+// with AES, the O and U strings and permissions of its encryption
+// dictionary, and the file key they give.
+type security struct {
+	s       *gen.State
+	v, r, n int
+	aes     bool
+	o, u    []byte
+	p       int32
+	key     []byte
+	// metadata is false if the dictionary has EncryptMetadata false,
+	// which a revision 4 file key depends on.
+	metadata bool
+}
+
+// newSecurity returns a security handler for a document whose first ID
+// is id: RC4 at revision 2 with a 40-bit key, RC4 at revision 3 with one
+// of 40 to 128 bits, most often 128, or AESV2 at revision 4, with a user
+// password that is most often empty.
+func newSecurity(s *gen.State, id string) *security {
+	c := &security{s: s, p: int32(-3904 | s.Intn(1<<8)<<2), metadata: true}
+	switch s.Intn(3) {
+	case 0:
+		c.v, c.r, c.n = 1, 2, 5
+	case 1:
+		c.v, c.r, c.n = 2, 3, gen.Pick(s, 16, 16, s.Range(5, 16))
+	default:
+		c.v, c.r, c.n, c.aes = 4, 4, 16, true
+		c.metadata = !s.Chance(0.1)
+	}
+	user := ""
+	if s.Chance(0.15) {
+		user = gen.Pick(s, "secret", "user", "\xe9t\xe9")
+	}
+	owner := gen.Pick(s, "owner", "", "hunter2")
+	c.o = c.ownerString(owner, user)
+	c.key = c.fileKey(user, id)
+	c.u = c.userString(id)
+	return c
+}
+
+// pad returns pw padded or cut to 32 bytes.
+func pad(pw string) []byte {
+	b := []byte(pw)
+	if len(b) >= 32 {
+		return b[:32]
+	}
+	return append(b, passwordPad[:32-len(b)]...)
+}
+
+// ownerString returns O, from algorithm 3: the padded user password
+// encrypted with a key made from the owner password, or the user one if
+// it is empty.
+func (c *security) ownerString(owner, user string) []byte {
+	if owner == "" {
+		owner = user
+	}
+	h := md5.Sum(pad(owner))
+	key := h[:]
+	if c.r >= 3 {
+		for range 50 {
+			h = md5.Sum(key[:c.n])
+			key = h[:]
+		}
+	}
+	key = key[:c.n]
+	o := pad(user)
+	rc4XOR(key, o)
+	if c.r >= 3 {
+		for i := 1; i <= 19; i++ {
+			rc4XOR(xorKey(key, byte(i)), o)
+		}
+	}
+	return o
+}
+
+// fileKey returns the key of algorithm 2, made from the user password,
+// O, the permissions and the first ID.
+func (c *security) fileKey(user, id string) []byte {
+	h := md5.New()
+	h.Write(pad(user))
+	h.Write(c.o)
+	h.Write(binary.LittleEndian.AppendUint32(nil, uint32(c.p)))
+	h.Write([]byte(id))
+	if c.r >= 4 && !c.metadata {
+		h.Write([]byte{0xff, 0xff, 0xff, 0xff})
+	}
+	key := h.Sum(nil)
+	if c.r >= 3 {
+		for range 50 {
+			sum := md5.Sum(key[:c.n])
+			key = sum[:]
+		}
+	}
+	return key[:c.n]
+}
+
+// userString returns U: the padding encrypted with the file key at
+// revision 2, and at later ones, algorithm 5's hash of the padding and
+// the first ID, encrypted twenty times over and then padded out with
+// anything.
+func (c *security) userString(id string) []byte {
+	if c.r == 2 {
+		u := pad("")
+		rc4XOR(c.key, u)
+		return u
+	}
+	h := md5.New()
+	h.Write(passwordPad)
+	h.Write([]byte(id))
+	u := h.Sum(nil)
+	rc4XOR(c.key, u)
+	for i := 1; i <= 19; i++ {
+		rc4XOR(xorKey(c.key, byte(i)), u)
+	}
+	return append(u, randomBytes(c.s, 16)...)
+}
+
+// rc4XOR encrypts or decrypts b in place with RC4 and key.
+func rc4XOR(key, b []byte) {
+	r, _ := rc4.NewCipher(key)
+	r.XORKeyStream(b, b)
+}
+
+// xorKey returns key with each byte XORed with x.
+func xorKey(key []byte, x byte) []byte {
+	k := make([]byte, len(key))
+	for i := range key {
+		k[i] = key[i] ^ x
+	}
+	return k
+}
+
+// dict returns the encryption dictionary. A few break it: a filter other
+// than Standard, a version or revision out of range, a key length that
+// is not a whole number of bytes or is too long, O or U the wrong
+// length, or crypt filters that do not say AESV2.
+func (c *security) dict() dict {
+	s := c.s
+	d := dict{
+		{"Filter", name("Standard")},
+		{"V", c.v},
+		{"R", c.r},
+		{"O", string(c.o)},
+		{"U", string(c.u)},
+		{"P", int(c.p)},
+	}
+	if c.v >= 2 {
+		d = append(d, pair{"Length", c.n * 8})
+	}
+	if c.aes {
+		d = append(d,
+			pair{"CF", dict{{"StdCF", dict{{"CFM", name("AESV2")}, {"AuthEvent", name("DocOpen")}, {"Length", 16}}}}},
+			pair{"StmF", name("StdCF")},
+			pair{"StrF", name("StdCF")},
+		)
+	}
+	if !c.metadata {
+		d = append(d, pair{"EncryptMetadata", false})
+	}
+	if s.Chance(badRate * 10) {
+		switch s.Intn(7) {
+		case 0:
+			d = d.set("Filter", name(gen.Pick(s, "Adobe.PubSec", "FooSecurity")))
+		case 1:
+			d = d.set("V", gen.Pick(s, 0, 3, 5))
+		case 2:
+			d = d.set("R", gen.Pick(s, 1, 5, 6))
+		case 3:
+			d = d.set("Length", gen.Pick(s, 41, 256, 32, 0))
+		case 4:
+			d = d.set(gen.Pick[name](s, "O", "U"), string(c.o[:s.Intn(32)]))
+		case 5:
+			d = d.set("StmF", name("Identity"))
+		case 6:
+			d = d.set("CF", dict{{"StdCF", dict{{"CFM", name(gen.Pick(s, "V2", "None", "AESV3"))}}}})
+		}
+	}
+	return d
+}
+
+// objectKey returns the key of the object num, generation gen: the
+// file key, the low bytes of the numbers, and for AES a salt, hashed and
+// cut to the length of the file key and five.
+func (c *security) objectKey(num, gen int) []byte {
+	h := md5.New()
+	h.Write(c.key)
+	h.Write([]byte{byte(num), byte(num >> 8), byte(num >> 16), byte(gen), byte(gen >> 8)})
+	if c.aes {
+		h.Write([]byte("sAlT"))
+	}
+	return h.Sum(nil)[:min(c.n+5, 16)]
+}
+
+// encrypt returns data encrypted as the object num, generation gen has
+// it: with RC4, or with AES-128 in CBC mode after a random IV, padded to
+// whole blocks.
+func (c *security) encrypt(num, gen int, data []byte) []byte {
+	key := c.objectKey(num, gen)
+	if !c.aes {
+		out := []byte(string(data))
+		rc4XOR(key, out)
+		return out
+	}
+	block, _ := aes.NewCipher(key)
+	n := aes.BlockSize - len(data)%aes.BlockSize
+	plain := append([]byte(string(data)), make([]byte, n)...)
+	for i := len(data); i < len(plain); i++ {
+		plain[i] = byte(n)
+	}
+	out := []byte(randomBytes(c.s, aes.BlockSize))
+	cipher.NewCBCEncrypter(block, out).CryptBlocks(plain, plain)
+	return append(out, plain...)
+}
+
+// encryptedLen returns the length of n bytes once encrypted.
+func (c *security) encryptedLen(n int) int {
+	if !c.aes {
+		return n
+	}
+	return aes.BlockSize + (n/aes.BlockSize+1)*aes.BlockSize
+}
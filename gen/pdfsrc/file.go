@@ -0,0 +1,552 @@
+package pdfsrc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// An xrefStyle is how a document's objects are found: through a
+// cross-reference table, through xref streams, or through a table and
+// the xref stream its trailer's XRefStm names.
+type xrefStyle int
+
+const (
+	tableXref xrefStyle = iota
+	streamXref
+	hybridXref
+)
+
+// The kinds of cross-reference entry.
+const (
+	freeEntry = iota
+	usedEntry
+	packedEntry
+)
+
+// An entry is an entry of a cross-reference section: a free object, one
+// in use at an offset, or one packed in the object stream numbered off
+// at index idx.
+type entry struct {
+	num, gen int
+	kind     int
+	off, idx int
+}
+
+// A writer writes a document as a file.
+type writer struct {
+	valueWriter
+	d *doc
+	// prevAt is where a placeholder for the Prev of the first section
+	// was written, to be pointed at a later section, or -1.
+	prevAt int
+	// xrefStm is the offset of the xref stream of a hybrid file's first
+	// section, or 0.
+	xrefStm int
+}
+
+// writeFile returns d as a file whose objects are found as xref has it,
+// then updated incrementally now and then, and now and then cut short
+// or followed by more.
+func writeFile(d *doc, xref xrefStyle) []byte {
+	s := d.s
+	w := &writer{valueWriter: valueWriter{s: s}, d: d, prevAt: -1}
+	w.header(xref)
+	entries := w.body(d.objects, xref, true)
+	prev := w.section(entries, xref, -1)
+	for range gen.Pick(s, 0, 0, 0, 1, 1, 2, 3) {
+		kind := xref
+		if s.Chance(badRate * 10) {
+			kind = gen.Pick(s, tableXref, streamXref)
+		}
+		entries := w.body(d.update(), kind, false)
+		at := len(w.out)
+		w.section(entries, kind, prev)
+		if w.prevAt >= 0 {
+			copy(w.out[w.prevAt:], fmt.Sprintf("%010d", at))
+			w.prevAt = -1
+		}
+		prev = at
+	}
+	if s.Chance(badRate * 10) {
+		w.out = append(w.out, gen.Pick(s, "garbage after the end\n", strings.Repeat("\n", 120), "%%EOF\n%%EOF\n", "\x00\x00")...)
+	}
+	return truncate(s, w.out)
+}
+
+// truncate returns b, or now and then b cut short.
+func truncate(s *gen.State, b []byte) []byte {
+	if s.Chance(badRate*5) && len(b) > 0 {
+		return b[:s.Intn(len(b))]
+	}
+	return b
+}
+
+// header appends the header of a file whose objects are found as xref
+// has it, of a version that has xref streams if it needs them, and now
+// and then a comment of bytes outside ASCII. A few give a version a
+// reader does not know, or have garbage before the header.
+func (w *writer) header(xref xrefStyle) {
+	s := w.s
+	version := s.Range(0, 7)
+	if xref != tableXref {
+		version = s.Range(5, 7)
+	}
+	h := fmt.Sprintf("%%PDF-1.%d", version)
+	if s.Chance(badRate * 5) {
+		h = gen.Pick(s, "%PDF-2.0", "%PDF-1.9", "%PDF-1.", "garbage\n%PDF-1.4", "%FDP-1.4")
+	}
+	w.out = append(w.out, h...)
+	w.out = append(w.out, gen.Pick(s, "\n", "\n", "\r\n", "\r")...)
+	if s.Chance(0.6) {
+		w.out = append(w.out, "%\xe2\xe3\xcf\xd3\n"...)
+	}
+}
+
+// update changes d as an incremental update would, and returns the
+// objects it changed: a new title, a page's content stream rewritten, an
+// object deleted, and objects added.
+func (d *doc) update() []*object {
+	s := d.s
+	var changed []*object
+	info := d.objects[d.info.num-1]
+	info.val = info.val.(dict).set("Title", textString(s, "Updated "+gen.Pick(s, words...)))
+	changed = append(changed, info)
+	if c := d.objects[gen.Pick(s, d.contents...).num-1]; !c.free && s.Chance(0.7) {
+		c.val = d.newStream(nil, d.content())
+		changed = append(changed, c)
+		if l := c.val.(*stream).length; l != (ref{}) {
+			changed = append(changed, d.objects[l.num-1])
+		}
+	}
+	if len(d.extras) > 0 && s.Chance(0.3) {
+		if o := d.objects[gen.Pick(s, d.extras...).num-1]; !o.free {
+			o.free = true
+			o.gen++
+			changed = append(changed, o)
+		}
+	}
+	for range s.Range(0, 3) {
+		r := d.add(d.value(2))
+		d.extras = append(d.extras, r)
+		changed = append(changed, d.objects[r.num-1])
+	}
+	return changed
+}
+
+// body appends objs, each in use written out in an order close to
+// theirs, and packs some into object streams if xref has them, and
+// returns the entries a section must have for them. In the first body
+// of a hybrid file, it writes the xref stream of the packed objects too,
+// for the table's trailer to name.
+func (w *writer) body(objs []*object, xref xrefStyle, first bool) []entry {
+	s := w.s
+	var packed, loose []*object
+	for _, o := range objs {
+		switch {
+		case o.free:
+			continue
+		case xref != tableXref && o.packable && o.gen == 0 && s.Chance(0.7):
+			packed = append(packed, o)
+		default:
+			loose = append(loose, o)
+		}
+	}
+	if xref == hybridXref && !first {
+		loose = append(loose, packed...)
+		packed = nil
+	}
+	var entries []entry
+	for _, o := range objs {
+		if o.free {
+			entries = append(entries, entry{num: o.num, gen: o.gen, kind: freeEntry})
+		}
+	}
+	for i := range loose {
+		if j := i + 1; j < len(loose) && s.Chance(0.1) {
+			loose[i], loose[j] = loose[j], loose[i]
+		}
+	}
+	for _, o := range loose {
+		entries = append(entries, entry{num: o.num, gen: o.gen, kind: usedEntry, off: len(w.out)})
+		w.object(o)
+	}
+	if len(packed) == 0 {
+		return entries
+	}
+	var stms []ref
+	var packedEntries []entry
+	for len(packed) > 0 {
+		n := min(len(packed), s.Range(1, 10))
+		stm := w.d.reserve()
+		var extends ref
+		if len(stms) > 0 && s.Chance(0.3) {
+			extends = stms[0]
+		}
+		if s.Chance(badRate * 5) {
+			extends = stm
+		}
+		o := w.d.objects[stm.num-1]
+		o.packable = false
+		o.val = w.objStream(packed[:n], extends)
+		for i, p := range packed[:n] {
+			packedEntries = append(packedEntries, entry{num: p.num, kind: packedEntry, off: stm.num, idx: i})
+		}
+		if s.Chance(badRate * 2) {
+			packedEntries = append(packedEntries, entry{num: stm.num, kind: packedEntry, off: stm.num, idx: n})
+		} else {
+			entries = append(entries, entry{num: stm.num, kind: usedEntry, off: len(w.out)})
+		}
+		w.object(o)
+		stms = append(stms, stm)
+		packed = packed[n:]
+	}
+	if xref != hybridXref {
+		return append(entries, packedEntries...)
+	}
+	conflicts := w.conflicts(loose, entries)
+	xs := w.d.reserve()
+	w.xrefStm = len(w.out)
+	w.xrefStream(xs, append(packedEntries, conflicts...), nil)
+	return append(entries, entry{num: xs.num, kind: usedEntry, off: w.xrefStm})
+}
+
+// conflicts now and then writes another definition of an object of
+// loose, whose entry entries holds, and returns entries for an xref
+// stream of a hybrid file that point at it rather than where the table
+// does.
+func (w *writer) conflicts(loose []*object, entries []entry) []entry {
+	s := w.s
+	if !s.Chance(badRate*25) || len(loose) == 0 {
+		return nil
+	}
+	o := gen.Pick(s, loose...)
+	if _, ok := o.val.(*stream); ok {
+		return nil
+	}
+	at := len(w.out)
+	w.object(&object{num: o.num, gen: o.gen, val: dict{{"Conflict", true}}})
+	return []entry{{num: o.num, gen: o.gen, kind: usedEntry, off: at}}
+}
+
+// objStream returns an object stream of objs, which extends the one
+// extends refers to if it is set: the numbers of the objects and their
+// offsets after the first, and then the objects, most often compressed
+// with FlateDecode alone. A few have a count or a
+// first offset that is wrong, or offsets out of order.
+func (w *writer) objStream(objs []*object, extends ref) *stream {
+	s := w.s
+	vw := &valueWriter{s: s}
+	var header []byte
+	for i, o := range objs {
+		if i > 0 {
+			vw.out = append(vw.out, '\n')
+		}
+		off := len(vw.out)
+		if s.Chance(tokenRate) {
+			off += s.Range(1, 8)
+		}
+		header = fmt.Appendf(header, "%d %d ", o.num, off)
+		vw.value(o.val)
+	}
+	if s.Chance(badRate*2) && len(objs) > 1 {
+		header = []byte(strings.Replace(string(header), fmt.Sprint(objs[0].num), fmt.Sprint(objs[1].num), 1))
+	}
+	first := len(header)
+	n := len(objs)
+	if s.Chance(badRate * 5) {
+		n = gen.Pick(s, n+1, n-1, 0, -1, 1<<30)
+	}
+	if s.Chance(badRate * 5) {
+		first = gen.Pick(s, first+3, 0, -1, 1<<40)
+	}
+	data := append(header, vw.out...)
+	dict := dict{{"Type", name("ObjStm")}, {"N", n}, {"First", first}}
+	if extends != (ref{}) {
+		dict = append(dict, pair{"Extends", extends})
+	}
+	fs := []filter{{name: "FlateDecode", encode: flate}}
+	if s.Chance(0.15) {
+		fs = chain(s)
+	}
+	enc, filter, parms := encode(s, data, fs)
+	if filter != nil {
+		dict = append(dict, pair{"Filter", filter})
+	}
+	if parms != nil {
+		dict = append(dict, pair{"DecodeParms", parms})
+	}
+	return &stream{dict: dict, data: enc}
+}
+
+// object appends o, its strings and data encrypted if the document is,
+// unless o is its encryption dictionary or an xref stream.
+func (w *writer) object(o *object) {
+	s := w.s
+	d := w.d
+	w.out = fmt.Appendf(w.out, "%d %d obj", o.num, o.gen)
+	w.out = append(w.out, gen.Pick(s, "\n", " ", "\r\n")...)
+	w.crypt = nil
+	var crypt func([]byte) []byte
+	if d.sec != nil && o.num != d.encrypt.num {
+		crypt = func(b []byte) []byte { return d.sec.encrypt(o.num, o.gen, b) }
+	}
+	st, ok := o.val.(*stream)
+	if crypt != nil && !(ok && st.xref) {
+		w.crypt = func(t string) string { return string(crypt([]byte(t))) }
+	}
+	if !ok {
+		w.value(o.val)
+		w.crypt = nil
+		w.out = append(w.out, gen.Pick(s, "\nendobj\n", "\rendobj\r", " endobj\n")...)
+		return
+	}
+	data := st.data
+	if crypt != nil && !st.xref {
+		data = crypt(data)
+	}
+	dict := slices.Clone(st.dict)
+	if st.length != (ref{}) {
+		dict = append(dict, pair{"Length", st.length})
+	} else {
+		dict = append(dict, pair{"Length", d.length(len(data))})
+	}
+	w.value(dict)
+	w.crypt = nil
+	eol := gen.Pick(s, "\n", "\n", "\r\n")
+	if s.Chance(badRate) {
+		eol = gen.Pick(s, "\r", " ", "")
+	}
+	w.out = append(w.out, "\nstream"+eol...)
+	w.out = append(w.out, data...)
+	if !s.Chance(badRate) {
+		w.out = append(w.out, gen.Pick(s, "\nendstream", "\r\nendstream", "endstream")...)
+	}
+	w.out = append(w.out, "\nendobj\n"...)
+}
+
+// trailer returns the entries of a trailer: the size, the catalog, the
+// information dictionary, the IDs and the encryption dictionary, and the
+// offset of the section before if there is one.
+func (w *writer) trailer(prev int) dict {
+	s := w.s
+	d := w.d
+	size := len(d.objects) + 1
+	if s.Chance(badRate * 5) {
+		size = gen.Pick(s, size-1, size+5, 1<<31, 1<<40, -1, 0)
+	}
+	t := dict{{"Size", size}, {"Root", d.root}, {"Info", d.info}}
+	if d.sec == nil || !s.Chance(badRate*5) {
+		t = append(t, pair{"ID", []any{d.id[0], d.id[1]}})
+	}
+	if d.sec != nil {
+		t = append(t, pair{"Encrypt", d.encrypt})
+	}
+	if prev >= 0 {
+		if s.Chance(badRate * 5) {
+			prev = gen.Pick(s, len(w.out), prev+s.Range(1, 20), 0, 1<<40)
+		}
+		t = append(t, pair{"Prev", prev})
+	} else if s.Chance(badRate * 5) {
+		t = append(t, pair{"Prev", raw("0000000000")})
+	}
+	return t
+}
+
+// section appends a cross-reference section of entries as xref has it,
+// with its trailer, startxref and the end of file marker, and returns
+// its offset. prev is the offset of the section before, or -1.
+func (w *writer) section(entries []entry, xref xrefStyle, prev int) int {
+	s := w.s
+	at := len(w.out)
+	if xref == streamXref {
+		xs := w.d.reserve()
+		w.xrefStream(xs, entries, w.trailer(prev))
+	} else {
+		w.xrefTable(entries, prev)
+	}
+	if prev < 0 {
+		if i := strings.LastIndex(string(w.out[at:]), "/Prev"); i >= 0 {
+			if j := strings.Index(string(w.out[at+i:]), "0000000000"); j >= 0 {
+				w.prevAt = at + i + j
+			}
+		}
+	}
+	startxref := at
+	if s.Chance(badRate * 5) {
+		startxref = gen.Pick(s, at+s.Range(1, 5), at-s.Range(1, 5), 0, len(w.out))
+	}
+	if !s.Chance(badRate) {
+		w.out = fmt.Appendf(w.out, "startxref\n%d\n", startxref)
+	}
+	if !s.Chance(badRate) {
+		w.out = append(w.out, "%%EOF"...)
+	}
+	w.out = append(w.out, gen.Pick(s, "\n", "\r\n", "")...)
+	return at
+}
+
+// subsections returns the runs of entries with consecutive numbers, in
+// order of number, the first of a file's first section starting with
+// the head of the free list, object 0.
+func subsections(entries []entry, head bool) [][]entry {
+	entries = slices.Clone(entries)
+	if head {
+		entries = append(entries, entry{num: 0, gen: 65535, kind: freeEntry})
+	}
+	slices.SortStableFunc(entries, func(a, b entry) int { return a.num - b.num })
+	entries = slices.CompactFunc(entries, func(a, b entry) bool { return a.num == b.num })
+	var subs [][]entry
+	for i := 0; i < len(entries); {
+		j := i + 1
+		for j < len(entries) && entries[j].num == entries[j-1].num+1 {
+			j++
+		}
+		subs = append(subs, entries[i:j])
+		i = j
+	}
+	return subs
+}
+
+// xrefTable appends a cross-reference table of entries and its trailer.
+// A few have a subsection count that is wrong, entries that point a few
+// bytes off or are free though in use, and entries that are not twenty
+// bytes long.
+func (w *writer) xrefTable(entries []entry, prev int) {
+	s := w.s
+	w.out = append(w.out, "xref\n"...)
+	eol := gen.Pick(s, "\r\n", " \n", " \r")
+	for _, sub := range subsections(entries, prev < 0) {
+		count := len(sub)
+		if s.Chance(badRate) {
+			count = gen.Pick(s, count+1, count-1, 1<<40)
+		}
+		w.out = fmt.Appendf(w.out, "%d %d\n", sub[0].num, count)
+		for _, e := range sub {
+			off, g, kind := e.off, e.gen, "n"
+			if e.kind != usedEntry {
+				off, kind = 0, "f"
+			}
+			if e.kind == usedEntry && s.Chance(tokenRate) {
+				if s.Chance(0.5) {
+					off += s.Range(-4, 4)
+				} else {
+					kind = "f"
+				}
+			}
+			if s.Chance(tokenRate) {
+				w.out = fmt.Appendf(w.out, "%d %d %s\n", off, g, kind)
+				continue
+			}
+			w.out = fmt.Appendf(w.out, "%010d %05d %s%s", off, g, kind, eol)
+		}
+	}
+	w.out = append(w.out, "trailer\n"...)
+	t := w.trailer(prev)
+	if w.xrefStm > 0 {
+		t = append(t, pair{"XRefStm", w.xrefStm})
+		w.xrefStm = 0
+	}
+	w.value(t)
+	w.out = append(w.out, '\n')
+}
+
+// xrefStream appends the xref stream numbered xs of entries, and of its
+// own entry, with the entries of trailer t in its dictionary. A few
+// have field widths, an Index, a type of entry or a size that is wrong.
+func (w *writer) xrefStream(xs ref, entries []entry, t dict) {
+	s := w.s
+	entries = append(slices.Clone(entries), entry{num: xs.num, kind: usedEntry, off: len(w.out)})
+	maxOff, maxGen := 0, 0
+	for _, e := range entries {
+		maxOff = max(maxOff, e.off)
+		maxGen = max(maxGen, e.gen, e.idx)
+	}
+	widths := []int{1, byteWidth(maxOff), byteWidth(maxGen)}
+	if s.Chance(0.2) {
+		widths[1]++
+	}
+	subs := subsections(entries, t != nil && t.get("Prev") == nil)
+	var data []byte
+	var index []any
+	for _, sub := range subs {
+		index = append(index, sub[0].num, len(sub))
+		for _, e := range sub {
+			kind, f2, f3 := e.kind, e.off, e.gen
+			switch e.kind {
+			case freeEntry:
+				f2 = 0
+			case packedEntry:
+				f3 = e.idx
+			}
+			if s.Chance(tokenRate) {
+				kind = gen.Pick(s, 3, 255)
+			}
+			data = appendField(data, kind, widths[0])
+			data = appendField(data, f2, widths[1])
+			data = appendField(data, f3, widths[2])
+		}
+	}
+	size := len(w.d.objects) + 1
+	if t != nil {
+		size = t.get("Size").(int)
+	}
+	var w3 []any
+	for _, n := range widths {
+		w3 = append(w3, n)
+	}
+	if s.Chance(badRate * 5) {
+		w3 = gen.Pick(s, []any{1, 2}, []any{0, 0, 0}, []any{1, 40, 1}, []any{-1, 2, 1}, []any{1, 1 << 40, 1}, []any{1, 2, 1, 1})
+	}
+	d := dict{{"Type", name("XRef")}, {"Size", size}, {"W", w3}}
+	if len(subs) != 1 || subs[0][0].num != 0 || len(subs[0]) != size || s.Chance(0.2) {
+		if s.Chance(badRate * 5) {
+			index = gen.Pick(s, []any{0, 1 << 40}, []any{0}, []any{1 << 31, 2})
+		}
+		d = append(d, pair{"Index", index})
+	}
+	for _, p := range t {
+		if p.key != "Size" {
+			d = append(d, p)
+		}
+	}
+	st := &stream{dict: d, data: data, xref: true}
+	if s.Chance(0.8) {
+		var parms any
+		if s.Chance(0.5) {
+			columns := widths[0] + widths[1] + widths[2]
+			st.data = predict(s, data, 12, 1, columns)
+			parms = dict{{"Predictor", 12}, {"Columns", columns}}
+			if s.Chance(badRate * 5) {
+				parms = dict{{"Predictor", 12}, {"Columns", gen.Pick(s, 0, -1, columns+1, 1<<40)}}
+			}
+		}
+		st.data = flate(s, st.data)
+		st.dict = append(st.dict, pair{"Filter", name("FlateDecode")})
+		if parms != nil {
+			st.dict = append(st.dict, pair{"DecodeParms", parms})
+		}
+	}
+	o := w.d.objects[xs.num-1]
+	o.val, o.packable = st, false
+	w.object(o)
+}
+
+// byteWidth returns the bytes it takes to hold v, at least one.
+func byteWidth(v int) int {
+	n := 1
+	for v >= 1<<(8*n) {
+		n++
+	}
+	return n
+}
+
+// appendField appends the low width bytes of v, big-endian.
+func appendField(b []byte, v, width int) []byte {
+	for i := width - 1; i >= 0; i-- {
+		b = append(b, byte(v>>(8*i)))
+	}
+	return b
+}
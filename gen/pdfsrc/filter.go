@@ -0,0 +1,278 @@
+package pdfsrc
+
+import (
+	"bytes"
+	"compress/lzw"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A filter is one filter of a stream's chain: its name and parameters as
+// the stream's dictionary gives them, and what encodes data for it.
+type filter struct {
+	name   name
+	parms  dict
+	encode func(s *gen.State, data []byte) []byte
+}
+
+// chain returns the filters of a stream, which decode its data in
+// order: none, one, or two or three, the first of them now and then one
+// that only makes the data text. A few name filters no reader has, or
+// give a predictor columns that are zero, negative or huge.
+func chain(s *gen.State) []filter {
+	var fs []filter
+	switch n := s.Intn(10); {
+	case n < 2:
+		return nil
+	case n < 8:
+		fs = []filter{compression(s)}
+	default:
+		fs = []filter{text(s), compression(s)}
+		if s.Chance(0.2) {
+			fs = append([]filter{text(s)}, fs...)
+		}
+	}
+	if s.Chance(badRate) {
+		fs[s.Intn(len(fs))] = filter{name: name(gen.Pick(s, "JBIG2Decode", "DCTDecode", "Crypt", "CCITTFaxDecode", "Fl", "AHx", "FooDecode")), encode: identity}
+	}
+	return fs
+}
+
+func identity(s *gen.State, data []byte) []byte { return data }
+
+// compression returns a filter that compresses: FlateDecode most often,
+// LZWDecode or RunLengthDecode, the first two now and then after a
+// predictor.
+func compression(s *gen.State) filter {
+	switch s.Intn(6) {
+	case 0:
+		return filter{name: "RunLengthDecode", encode: runLength}
+	case 1:
+		f := filter{name: "LZWDecode", parms: dict{{"EarlyChange", 0}}, encode: lzwEncode}
+		return predicted(s, f)
+	}
+	f := filter{name: "FlateDecode", encode: flate}
+	if s.Chance(0.3) {
+		f = predicted(s, f)
+	}
+	return f
+}
+
+// text returns a filter that writes data as text: ASCIIHexDecode or
+// ASCII85Decode.
+func text(s *gen.State) filter {
+	if s.Chance(0.5) {
+		return filter{name: "ASCIIHexDecode", encode: asciiHex}
+	}
+	return filter{name: "ASCII85Decode", encode: ascii85Encode}
+}
+
+// predicted returns f with a predictor before it: a PNG one, with every
+// row Up as Predictor 12 says or each row as it likes, or TIFF's.
+func predicted(s *gen.State, f filter) filter {
+	colors := gen.Pick(s, 1, 1, 3, 4)
+	columns := s.Range(1, 32)
+	predictor := gen.Pick(s, 12, 12, 10, 11, 13, 14, 15, 2)
+	parms := dict{{"Predictor", predictor}, {"Columns", columns}}
+	if colors != 1 {
+		parms = append(parms, pair{"Colors", colors})
+	}
+	if s.Chance(badRate) {
+		parms = parms.set("Columns", gen.Pick(s, 0, -1, 1<<20, 1<<40))
+	}
+	f.parms = append(f.parms, parms...)
+	encode := f.encode
+	f.encode = func(s *gen.State, data []byte) []byte {
+		return encode(s, predict(s, data, predictor, colors, columns))
+	}
+	return f
+}
+
+// predict returns data, padded with zeros to whole rows of columns
+// pixels of colors bytes, as predictor encodes it.
+func predict(s *gen.State, data []byte, predictor, colors, columns int) []byte {
+	row := colors * columns
+	for len(data)%row != 0 {
+		data = append(data, 0)
+	}
+	var out []byte
+	prev := make([]byte, row)
+	for i := 0; i < len(data); i += row {
+		cur := data[i : i+row]
+		if predictor == 2 {
+			for j := range cur {
+				var left byte
+				if j >= colors {
+					left = cur[j-colors]
+				}
+				out = append(out, cur[j]-left)
+			}
+			continue
+		}
+		tag := predictor - 10
+		switch predictor {
+		case 12:
+			tag = 2
+		case 15:
+			tag = s.Intn(5)
+		}
+		out = append(out, byte(tag))
+		for j := range cur {
+			var a, c byte
+			if j >= colors {
+				a, c = cur[j-colors], prev[j-colors]
+			}
+			b := prev[j]
+			switch tag {
+			case 0:
+				out = append(out, cur[j])
+			case 1:
+				out = append(out, cur[j]-a)
+			case 2:
+				out = append(out, cur[j]-b)
+			case 3:
+				out = append(out, cur[j]-byte((int(a)+int(b))/2))
+			case 4:
+				out = append(out, cur[j]-paeth(a, b, c))
+			}
+		}
+		prev = cur
+	}
+	return out
+}
+
+// paeth is the PNG Paeth predictor of a pixel from those left, above and
+// above left of it.
+func paeth(a, b, c byte) byte {
+	p := int(a) + int(b) - int(c)
+	pa, pb, pc := abs(p-int(a)), abs(p-int(b)), abs(p-int(c))
+	switch {
+	case pa <= pb && pa <= pc:
+		return a
+	case pb <= pc:
+		return b
+	}
+	return c
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func flate(s *gen.State, data []byte) []byte {
+	var b bytes.Buffer
+	w, _ := zlib.NewWriterLevel(&b, gen.Pick(s, zlib.DefaultCompression, zlib.BestSpeed, zlib.BestCompression, zlib.NoCompression))
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// lzwEncode encodes data as LZWDecode with an EarlyChange of 0 has it,
+// which is the code widths of compress/lzw.
+func lzwEncode(s *gen.State, data []byte) []byte {
+	var b bytes.Buffer
+	w := lzw.NewWriter(&b, lzw.MSB, 8)
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// runLength encodes data as runs of up to 128 bytes the same and
+// literals of up to 128 others, then the end of data.
+func runLength(s *gen.State, data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		j := i + 1
+		for j < len(data) && j-i < 128 && data[j] == data[i] {
+			j++
+		}
+		if j-i > 1 {
+			out = append(out, byte(257-(j-i)), data[i])
+			i = j
+			continue
+		}
+		for j < len(data) && j-i < 128 && (j+1 >= len(data) || data[j+1] != data[j]) {
+			j++
+		}
+		out = append(out, byte(j-i-1))
+		out = append(out, data[i:j]...)
+		i = j
+	}
+	return append(out, 128)
+}
+
+// asciiHex encodes data as hex digits in lines, then >.
+func asciiHex(s *gen.State, data []byte) []byte {
+	var out []byte
+	for i, c := range data {
+		if i > 0 && i%32 == 0 {
+			out = append(out, '\n')
+		}
+		out = fmt.Appendf(out, "%02X", c)
+	}
+	if s.Chance(badRate) {
+		out = out[:len(out)-1]
+	}
+	return append(out, '>')
+}
+
+// ascii85Encode encodes data as base-85 in lines, then ~>.
+func ascii85Encode(s *gen.State, data []byte) []byte {
+	enc := make([]byte, ascii85.MaxEncodedLen(len(data)))
+	enc = enc[:ascii85.Encode(enc, data)]
+	var out []byte
+	for i := 0; i < len(enc); i += 64 {
+		out = append(out, enc[i:min(i+64, len(enc))]...)
+		out = append(out, '\n')
+	}
+	return append(out, "~>"...)
+}
+
+// encode returns data encoded by the filters of fs, and the Filter and
+// DecodeParms entries that say so, nil if there are none. Now and then
+// the data is corrupted once encoded, or is megabytes of zeros a few
+// kilobytes compress to.
+func encode(s *gen.State, data []byte, fs []filter) (_ []byte, filters, parms any) {
+	if len(fs) == 0 {
+		return data, nil, nil
+	}
+	if s.Chance(badRate / 2) {
+		data = make([]byte, gen.Pick(s, 1<<20, 8<<20))
+	}
+	for i := len(fs) - 1; i >= 0; i-- {
+		data = fs[i].encode(s, data)
+	}
+	if s.Chance(badRate) && len(data) > 0 {
+		data[s.Intn(len(data))] ^= byte(s.Range(1, 255))
+	}
+	names := make([]any, len(fs))
+	ps := make([]any, len(fs))
+	anyParms := false
+	for i, f := range fs {
+		names[i] = f.name
+		if f.parms != nil {
+			ps[i] = f.parms
+			anyParms = true
+		}
+	}
+	if s.Chance(badRate) {
+		ps = ps[:len(ps)-1]
+	}
+	if len(fs) == 1 && s.Chance(0.8) {
+		filters = names[0]
+		if anyParms && len(ps) > 0 {
+			parms = ps[0]
+		}
+		return data, filters, parms
+	}
+	if anyParms {
+		parms = ps
+	}
+	return data, names, parms
+}
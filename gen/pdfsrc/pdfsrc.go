@@ -0,0 +1,713 @@
+// Package pdfsrc generates PDF seeds. It registers the "pdf/..."
+// generators with package gen.
+//
+// Each writes a document, input.pdf: a catalog, a tree of pages that
+// inherit resources and media boxes or have their own, content streams
+// of text and paths, fonts, an information dictionary of text strings in
+// PDFDocEncoding and UTF-16, and a few other objects of arrays and
+// dictionaries that refer to each other. Streams are written through a
+// chain of filters, FlateDecode and LZWDecode with PNG and TIFF
+// predictors, ASCIIHexDecode, ASCII85Decode and RunLengthDecode, and
+// their lengths are direct or indirect.
+//
+// "pdf/xref" finds its objects through a cross-reference table, and now
+// and then through an xref stream too, named by XRefStm, that holds the
+// objects it packs into object streams. "pdf/xrefstream" finds them
+// through xref streams of any field widths, compressed with a PNG
+// predictor or not, and packs most objects into object streams, some
+// extending others. "pdf/encrypted" writes either, encrypted by the
+// standard security handler with RC4 or AES keys of 40 to 128 bits, its
+// user password empty or not. Any of them may be updated incrementally,
+// once or a few times, each update with a section of its own whose Prev
+// names the one before.
+//
+// A few break the format. Sections have Prev offsets that point at
+// themselves or at a later section, sections of the other kind, or
+// nowhere; sizes, counts and widths that are wrong, negative or huge;
+// and entries that point a few bytes off, or that the table and the
+// xref stream of a hybrid file disagree on. Objects refer to themselves
+// or to each other in loops, page trees have kids that are their
+// ancestors, arrays nest thousands deep, object streams extend
+// themselves, and filter chains name filters no reader has, give
+// predictors columns that are zero or huge, or decode to megabytes. An
+// encrypted file may have an encryption dictionary of a revision or
+// key length out of range, O and U strings of the wrong length, or no
+// ID. And strings, names and numbers are written in every form the
+// format allows, and a few it does not.
+package pdfsrc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "pdf/xref",
+		Doc:  "PDF documents with cross-reference tables: page trees, content streams, fonts and info dicts through filter chains, hybrid files with XRefStm, incremental updates, Prev loops, bad offsets and sizes, reference cycles and deep nesting",
+		Func: func(s *gen.State) []gen.File { return pdfFile(s, tableXref, false) },
+	})
+	gen.Register(&gen.Generator{
+		Name: "pdf/xrefstream",
+		Doc:  "PDF documents with xref streams and object streams: field widths, Index subsections, PNG predictors, Extends chains, incremental updates, and bad widths, counts, sizes and object stream headers",
+		Func: func(s *gen.State) []gen.File { return pdfFile(s, streamXref, false) },
+	})
+	gen.Register(&gen.Generator{
+		Name: "pdf/encrypted",
+		Doc:  "encrypted PDF documents: the standard security handler at revisions 2 to 4 with RC4 and AESV2, empty and other user passwords, and bad key lengths, revisions, O and U strings and IDs",
+		Func: func(s *gen.State) []gen.File {
+			return pdfFile(s, gen.Pick(s, tableXref, tableXref, streamXref, hybridXref), true)
+		},
+	})
+}
+
+// badRate is the chance that one of the fields and entries of a document
+// or a stream is wrong, so that one document in five or so is broken
+// somewhere. tokenRate is the chance for each of its hundreds of tokens
+// and cross-reference entries.
+const (
+	badRate   = 0.004
+	tokenRate = badRate / 20
+)
+
+// pdfFile returns a document whose objects are found through xref, and
+// encrypted if encrypt is set.
+func pdfFile(s *gen.State, xref xrefStyle, encrypt bool) []gen.File {
+	if xref == tableXref && s.Chance(0.15) {
+		xref = hybridXref
+	}
+	d := newDoc(s, encrypt)
+	return []gen.File{{Name: "input.pdf", Data: writeFile(d, xref)}}
+}
+
+// The values of a document: nil is null, and bool, int, float64 and
+// string are booleans, integers, reals and strings. A raw value is
+// written as it is, for tokens no value is written as.
+type (
+	name string
+	ref  struct{ num, gen int }
+	dict []pair
+	raw  string
+)
+
+type pair struct {
+	key name
+	val any
+}
+
+// A nest is an array nested depth deep around val.
+type nest struct {
+	depth int
+	val   any
+}
+
+// get returns the value of key in d, or nil.
+func (d dict) get(key name) any {
+	for _, p := range d {
+		if p.key == key {
+			return p.val
+		}
+	}
+	return nil
+}
+
+// set replaces the value of key in d, or adds it.
+func (d dict) set(key name, v any) dict {
+	for i, p := range d {
+		if p.key == key {
+			d[i].val = v
+			return d
+		}
+	}
+	return append(d, pair{key, v})
+}
+
+// A stream is a stream object: its dictionary, without the Length,
+// Filter and DecodeParms entries that writing it adds, and its data,
+// encoded by its filters. length, if set, is the object that holds its
+// length, which must be filled in before it is written.
+type stream struct {
+	dict   dict
+	data   []byte
+	length ref
+	xref   bool // an xref stream, which is not encrypted
+}
+
+// An object is an indirect object of a document.
+type object struct {
+	num, gen int
+	val      any
+	// free objects have been deleted by an update; packable ones may go
+	// in an object stream.
+	free, packable bool
+}
+
+// A doc is a document as the generators build it: its objects, numbered
+// from one, and the ones its trailer names.
+type doc struct {
+	s       *gen.State
+	objects []*object
+	root    ref
+	info    ref
+	sec     *security
+	encrypt ref
+	id      [2]string
+	fonts   []ref
+	// contents are the content streams of its pages, which updates
+	// replace, and extras the objects nothing needs, which they delete.
+	contents []ref
+	extras   []ref
+}
+
+// newDoc returns a document of a catalog, pages, fonts, an information
+// dictionary and a few other objects, encrypted if encrypt is set.
+func newDoc(s *gen.State, encrypt bool) *doc {
+	d := &doc{s: s}
+	d.id = [2]string{randomBytes(s, 16), randomBytes(s, 16)}
+	if encrypt {
+		d.sec = newSecurity(s, d.id[0])
+		d.encrypt = d.add(d.sec.dict())
+		d.objects[d.encrypt.num-1].packable = false
+	}
+	for range s.Range(1, 3) {
+		d.fonts = append(d.fonts, d.add(d.font()))
+	}
+	resources := dict{
+		{"Font", d.fontDict()},
+		{"ProcSet", []any{name("PDF"), name("Text")}},
+	}
+	pages := d.reserve()
+	d.pagesNode(pages, ref{}, s.Range(1, 12), resources, true)
+	catalog := dict{{"Type", name("Catalog")}, {"Pages", pages}}
+	if s.Chance(0.3) {
+		catalog = append(catalog, pair{"PageMode", name(gen.Pick(s, "UseNone", "UseOutlines", "FullScreen"))})
+	}
+	if s.Chance(0.3) {
+		catalog = append(catalog, pair{"Outlines", d.add(dict{{"Type", name("Outlines")}, {"Count", 0}})})
+	}
+	if s.Chance(0.2) {
+		catalog = append(catalog, pair{"Metadata", d.addStream(dict{{"Type", name("Metadata")}, {"Subtype", name("XML")}}, []byte(metadata))})
+	}
+	d.root = d.add(catalog)
+	d.info = d.add(d.infoDict())
+	for range s.Range(0, 4) {
+		d.extras = append(d.extras, d.add(d.value(3)))
+	}
+	d.faults(pages)
+	return d
+}
+
+const metadata = `<?xpacket begin="" id="W5M0MpCehiHzreSzNTczkc9d"?>
+<x:xmpmeta xmlns:x="adobe:ns:meta/"><rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#">
+<rdf:Description rdf:about="" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:format>application/pdf</dc:format></rdf:Description>
+</rdf:RDF></x:xmpmeta>
+<?xpacket end="w"?>`
+
+// add adds an object of value v and returns a reference to it.
+func (d *doc) add(v any) ref {
+	r := d.reserve()
+	d.set(r, v)
+	return r
+}
+
+// reserve adds an object whose value set gives later.
+func (d *doc) reserve() ref {
+	o := &object{num: len(d.objects) + 1, packable: true}
+	d.objects = append(d.objects, o)
+	return ref{o.num, 0}
+}
+
+// set sets the value of the object r refers to.
+func (d *doc) set(r ref, v any) {
+	o := d.objects[r.num-1]
+	o.val = v
+	if _, ok := v.(*stream); ok {
+		o.packable = false
+	}
+}
+
+// addStream adds a stream of dictionary dict and data, encoded by a
+// chain of filters, with a length direct or in an object of its own.
+func (d *doc) addStream(dict dict, data []byte) ref {
+	st := d.newStream(dict, data)
+	return d.add(st)
+}
+
+// newStream returns a stream of dictionary dict and data, encoded by a
+// chain of filters, with a length direct or in an object of its own.
+func (d *doc) newStream(dict dict, data []byte) *stream {
+	s := d.s
+	enc, filter, parms := encode(s, data, chain(s))
+	if filter != nil {
+		dict = append(dict, pair{"Filter", filter})
+	}
+	if parms != nil {
+		dict = append(dict, pair{"DecodeParms", parms})
+	}
+	st := &stream{dict: dict, data: enc}
+	if s.Chance(0.25) {
+		st.length = d.reserve()
+		n := len(enc)
+		if d.sec != nil {
+			n = d.sec.encryptedLen(n)
+		}
+		d.set(st.length, d.length(n))
+	}
+	return st
+}
+
+// length returns the length of a stream of n bytes as its dictionary
+// gives it, or now and then a wrong one.
+func (d *doc) length(n int) any {
+	s := d.s
+	if !s.Chance(badRate) {
+		return n
+	}
+	return gen.Pick[any](s, n+s.Range(1, 16), max(n-s.Range(1, 16), 0), 0, -1, 1<<40, float64(n), ref{len(d.objects) + 5, 0})
+}
+
+// font returns a simple font dictionary of a standard font.
+func (d *doc) font() dict {
+	s := d.s
+	first := s.Range(32, 64)
+	last := first + s.Range(0, 64)
+	widths := make([]any, last-first+1)
+	for i := range widths {
+		widths[i] = s.Range(200, 1000)
+	}
+	f := dict{
+		{"Type", name("Font")},
+		{"Subtype", name(gen.Pick(s, "Type1", "TrueType", "Type1"))},
+		{"BaseFont", name(gen.Pick(s, "Helvetica", "Times-Roman", "Courier-Bold", "Symbol", "ABCDEF+Minion Pro"))},
+		{"FirstChar", first},
+		{"LastChar", last},
+		{"Widths", widths},
+	}
+	if s.Chance(0.5) {
+		f = append(f, pair{"Encoding", name(gen.Pick(s, "WinAnsiEncoding", "MacRomanEncoding", "StandardEncoding"))})
+	}
+	return f
+}
+
+// fontDict returns the Font entry of a page's resources, naming each
+// font of d.
+func (d *doc) fontDict() dict {
+	var fonts dict
+	for i, f := range d.fonts {
+		fonts = append(fonts, pair{name(fmt.Sprintf("F%d", i+1)), f})
+	}
+	return fonts
+}
+
+// pagesNode sets the object self to a node of a page tree of n pages,
+// whose parent is parent, and those of its kids. The resources and a
+// media box go on the root, for the pages to inherit, or on each page.
+func (d *doc) pagesNode(self, parent ref, n int, resources dict, root bool) {
+	s := d.s
+	inherit := root && s.Chance(0.5)
+	var kids []any
+	for left := n; left > 0; {
+		k := 1
+		if left > 1 && s.Chance(0.3) {
+			k = s.Range(2, min(left, 5))
+		}
+		kid := d.reserve()
+		if k == 1 && s.Chance(0.8) {
+			d.page(kid, self, resources, !inherit)
+		} else {
+			d.pagesNode(kid, self, k, resources, false)
+		}
+		kids = append(kids, kid)
+		left -= k
+	}
+	node := dict{{"Type", name("Pages")}, {"Kids", kids}, {"Count", n}}
+	if parent != (ref{}) {
+		node = append(node, pair{"Parent", parent})
+	}
+	if inherit {
+		node = append(node, pair{"Resources", resources}, pair{"MediaBox", mediaBox(s)})
+	}
+	d.set(self, node)
+}
+
+// page sets the object self to a page whose parent is parent, with
+// content streams, and with resources and a media box of its own if own
+// is set.
+func (d *doc) page(self, parent ref, resources dict, own bool) {
+	s := d.s
+	p := dict{{"Type", name("Page")}, {"Parent", parent}}
+	if own {
+		p = append(p, pair{"Resources", resources}, pair{"MediaBox", mediaBox(s)})
+	}
+	var contents []any
+	for range s.Range(1, 3) {
+		c := d.addStream(nil, d.content())
+		d.contents = append(d.contents, c)
+		contents = append(contents, c)
+	}
+	if len(contents) == 1 && s.Chance(0.7) {
+		p = append(p, pair{"Contents", contents[0]})
+	} else {
+		p = append(p, pair{"Contents", contents})
+	}
+	if s.Chance(0.2) {
+		p = append(p, pair{"Rotate", gen.Pick(s, 0, 90, 180, 270)})
+	}
+	d.set(self, p)
+}
+
+// mediaBox returns a page size in points.
+func mediaBox(s *gen.State) []any {
+	switch s.Intn(3) {
+	case 0:
+		return []any{0, 0, 612, 792}
+	case 1:
+		return []any{0, 0, 595.276, 841.89}
+	}
+	return []any{0, 0, s.Range(1, 14400), s.Range(1, 14400)}
+}
+
+// words are what content streams and text strings say.
+var words = []string{"Hello", "world", "PDF", "(nested)", "back\\slash", "ünïcödé", "\x00\x01bin", "Lorem", "ipsum", "€100"}
+
+// content returns a content stream: text shown with the fonts of d, and
+// paths filled and stroked.
+func (d *doc) content() []byte {
+	s := d.s
+	var b strings.Builder
+	for range s.Range(1, 8) {
+		switch s.Intn(3) {
+		case 0, 1:
+			fmt.Fprintf(&b, "BT\n/F%d %d Tf\n%d %d Td\n", s.Range(1, len(d.fonts)), s.Range(6, 36), s.Range(0, 600), s.Range(0, 800))
+			for range s.Range(1, 4) {
+				if s.Chance(0.3) {
+					fmt.Fprintf(&b, "[%s %d %s] TJ\n", literal(gen.Pick(s, words...)), s.Range(-200, 200), literal(gen.Pick(s, words...)))
+				} else {
+					fmt.Fprintf(&b, "%s Tj\n0 -14 Td\n", literal(gen.Pick(s, words...)))
+				}
+			}
+			b.WriteString("ET\n")
+		case 2:
+			fmt.Fprintf(&b, "q %.2f %.2f %.2f rg %d %d %d %d re %s Q\n", float64(s.Range(0, 100))/100, float64(s.Range(0, 100))/100, float64(s.Range(0, 100))/100, s.Range(0, 600), s.Range(0, 800), s.Range(1, 300), s.Range(1, 300), gen.Pick(s, "f", "S", "B", "f*"))
+		}
+	}
+	return []byte(b.String())
+}
+
+// infoDict returns a document information dictionary.
+func (d *doc) infoDict() dict {
+	s := d.s
+	info := dict{
+		{"Title", textString(s, gen.Pick(s, words...)+" "+gen.Pick(s, words...))},
+		{"Producer", textString(s, "pdfsrc")},
+		{"CreationDate", fmt.Sprintf("D:%04d%02d%02d%02d%02d%02d%s", s.Range(1990, 2030), s.Range(1, 12), s.Range(1, 28), s.Range(0, 23), s.Range(0, 59), s.Range(0, 59), gen.Pick(s, "Z", "+01'00'", "-08'00", ""))},
+	}
+	if s.Chance(0.5) {
+		info = append(info, pair{"Author", textString(s, gen.Pick(s, words...))})
+	}
+	if s.Chance(0.2) {
+		info = append(info, pair{"Trapped", name(gen.Pick(s, "True", "False", "Unknown"))})
+	}
+	return info
+}
+
+// textString returns t as a text string: in PDFDocEncoding if it can
+// be, else, or now and then anyway, in UTF-16BE after a byte order mark.
+func textString(s *gen.State, t string) string {
+	latin := true
+	for _, r := range t {
+		if r >= 0x80 || r < 0x20 {
+			latin = false
+		}
+	}
+	if latin && !s.Chance(0.2) {
+		return t
+	}
+	b := []byte{0xfe, 0xff}
+	for _, r := range t {
+		if r > 0xffff {
+			r -= 0x10000
+			b = append(b, byte(0xd8|r>>18), byte(r>>10), byte(0xdc|r>>8&3), byte(r))
+			continue
+		}
+		b = append(b, byte(r>>8), byte(r))
+	}
+	return string(b)
+}
+
+// value returns a value of up to depth levels of arrays and
+// dictionaries, which may refer to any object of d, or to one it does
+// not have.
+func (d *doc) value(depth int) any {
+	s := d.s
+	n := s.Intn(10)
+	if depth <= 0 {
+		n = s.Intn(7)
+	}
+	switch n {
+	case 0:
+		return nil
+	case 1:
+		return s.Chance(0.5)
+	case 2:
+		return gen.Pick(s, 0, 1, -1, s.Range(-1000, 1000), 2147483647, -2147483648)
+	case 3:
+		return float64(s.Range(-100000, 100000)) / float64(gen.Pick(s, 10, 100, 1000))
+	case 4:
+		return name(gen.Pick(s, "Type", "Name With Space", "A#B", "Ünï", "", "Pages", "Parent", "Length"))
+	case 5:
+		return gen.Pick(s, words...)
+	case 6:
+		if len(d.objects) == 0 || s.Chance(0.1) {
+			return ref{len(d.objects) + s.Range(1, 100), 0}
+		}
+		return ref{s.Range(1, len(d.objects)), 0}
+	case 7, 8:
+		a := make([]any, s.Range(0, 5))
+		for i := range a {
+			a[i] = d.value(depth - 1)
+		}
+		return a
+	}
+	var m dict
+	for i := range s.Range(0, 5) {
+		m = append(m, pair{name(fmt.Sprintf("K%d", i)), d.value(depth - 1)})
+	}
+	return m
+}
+
+// faults breaks the objects of d now and then: objects that are
+// references to themselves or to each other in a loop, arrays nested
+// thousands deep, a page tree with a node among its own kids or a parent
+// that is its child, and counts that are wrong.
+func (d *doc) faults(pages ref) {
+	s := d.s
+	if s.Chance(badRate * 5) {
+		r := d.reserve()
+		d.set(r, r)
+	}
+	if s.Chance(badRate * 5) {
+		a, b := d.reserve(), d.reserve()
+		d.set(a, []any{b})
+		d.set(b, dict{{"Next", a}, {"Self", b}})
+	}
+	if s.Chance(badRate * 5) {
+		a := d.reserve()
+		d.set(a, ref{a.num + 1, 0})
+		d.set(d.reserve(), a)
+	}
+	if s.Chance(badRate * 5) {
+		d.add(nest{gen.Pick(s, 100, 999, 1000, 1001, 5000), d.value(1)})
+	}
+	root := d.objects[pages.num-1].val.(dict)
+	if s.Chance(badRate * 5) {
+		kids := root.get("Kids").([]any)
+		root.set("Kids", append(kids, pages))
+	}
+	if s.Chance(badRate * 5) {
+		kid := root.get("Kids").([]any)[0].(ref)
+		root = root.set("Parent", kid)
+	}
+	if s.Chance(badRate * 5) {
+		n := root.get("Count").(int)
+		root.set("Count", gen.Pick(s, n+1, n-1, 0, -1, 1<<31))
+	}
+	if s.Chance(badRate * 5) {
+		root.set("Type", name("Page"))
+	}
+	d.objects[pages.num-1].val = root
+}
+
+// randomBytes returns n random bytes.
+func randomBytes(s *gen.State, n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	return string(b)
+}
+
+// literal returns t as a literal string: in parentheses, which it keeps
+// unescaped where they balance, with backslashes and line ends escaped
+// and other bytes outside ASCII now and then as octal.
+func literal(t string) string {
+	depth := 0
+	balanced := true
+	for _, c := range []byte(t) {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth < 0 {
+				balanced = false
+			}
+		}
+	}
+	balanced = balanced && depth == 0
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range []byte(t) {
+		switch {
+		case c == '\\':
+			b.WriteString(`\\`)
+		case (c == '(' || c == ')') && !balanced:
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c == '\n':
+			b.WriteString(`\n`)
+		case c == '\r':
+			b.WriteString(`\r`)
+		case c < 0x20 || c >= 0x7f:
+			fmt.Fprintf(&b, `\%03o`, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// A valueWriter writes values, encrypting strings with crypt if it is
+// set.
+type valueWriter struct {
+	s     *gen.State
+	out   []byte
+	crypt func(string) string
+}
+
+// space appends the white space between tokens: most often a space,
+// sometimes a line end, and now and then a comment.
+func (w *valueWriter) space() {
+	s := w.s
+	switch {
+	case s.Chance(0.85):
+		w.out = append(w.out, ' ')
+	case s.Chance(0.8):
+		w.out = append(w.out, gen.Pick(s, "\n", "\r\n", "\r", "\t", "\x00", "\f")...)
+	default:
+		w.out = append(w.out, "% comment\n"...)
+	}
+}
+
+// value appends v.
+func (w *valueWriter) value(v any) {
+	s := w.s
+	switch v := v.(type) {
+	case nil:
+		w.out = append(w.out, "null"...)
+	case bool:
+		w.out = strconv.AppendBool(w.out, v)
+	case int:
+		if s.Chance(tokenRate) {
+			w.out = append(w.out, gen.Pick(s, "+", "00", "--", "9999999999999999999")...)
+		}
+		w.out = strconv.AppendInt(w.out, int64(v), 10)
+	case float64:
+		w.real(v)
+	case name:
+		w.name(v)
+	case string:
+		if w.crypt != nil {
+			v = w.crypt(v)
+		}
+		w.string(v)
+	case ref:
+		w.out = fmt.Appendf(w.out, "%d %d R", v.num, v.gen)
+	case raw:
+		w.out = append(w.out, v...)
+	case []any:
+		w.out = append(w.out, '[')
+		for i, x := range v {
+			if i > 0 {
+				w.space()
+			}
+			w.value(x)
+		}
+		w.out = append(w.out, ']')
+	case dict:
+		w.out = append(w.out, "<<"...)
+		for _, p := range v {
+			w.name(p.key)
+			w.space()
+			w.value(p.val)
+			if s.Chance(0.5) {
+				w.space()
+			}
+		}
+		if s.Chance(tokenRate) && len(v) > 0 {
+			w.name(v[0].key)
+			w.space()
+			w.value(nil)
+		}
+		w.out = append(w.out, ">>"...)
+	case nest:
+		w.out = append(w.out, strings.Repeat("[", v.depth)...)
+		w.value(v.val)
+		w.out = append(w.out, strings.Repeat("]", v.depth)...)
+	default:
+		panic(fmt.Sprintf("pdfsrc: value of type %T", v))
+	}
+}
+
+// real appends v as a real with a decimal point, as the format has
+// them, or now and then in a form it does not allow.
+func (w *valueWriter) real(v float64) {
+	s := w.s
+	if s.Chance(tokenRate) {
+		w.out = strconv.AppendFloat(w.out, v, 'e', -1, 64)
+		return
+	}
+	t := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(t, ".") {
+		t += gen.Pick(s, ".0", ".")
+	}
+	if s.Chance(0.1) {
+		t = strings.Replace(t, "0.", ".", 1)
+	}
+	w.out = append(w.out, t...)
+}
+
+// name appends n after a slash, with the bytes it may not hold as they
+// are written as # and two hex digits, and now and then a # that is not.
+func (w *valueWriter) name(n name) {
+	w.out = append(w.out, '/')
+	for _, c := range []byte(n) {
+		if c <= ' ' || c >= 0x7f || c == '#' || strings.IndexByte("()<>[]{}/%", c) >= 0 {
+			w.out = fmt.Appendf(w.out, "#%02X", c)
+			continue
+		}
+		w.out = append(w.out, c)
+	}
+	if w.s.Chance(tokenRate) {
+		w.out = append(w.out, gen.Pick(w.s, "#", "#G0", "#00")...)
+	}
+}
+
+// string appends t as a literal or hex string, a hex string now and
+// then with an odd number of digits, or a literal one with an escape
+// the format does not have.
+func (w *valueWriter) string(t string) {
+	s := w.s
+	if s.Chance(0.3) {
+		w.out = append(w.out, '<')
+		for i, c := range []byte(t) {
+			if i > 0 && s.Chance(0.1) {
+				w.out = append(w.out, ' ')
+			}
+			w.out = fmt.Appendf(w.out, gen.Pick(s, "%02x", "%02X"), c)
+		}
+		if s.Chance(tokenRate) {
+			w.out = append(w.out, gen.Pick(s, "7", "G")...)
+		}
+		w.out = append(w.out, '>')
+		return
+	}
+	l := literal(t)
+	if s.Chance(tokenRate) {
+		l = l[:len(l)-1] + gen.Pick(s, `\q`, `\400`, `(`) + ")"
+	}
+	w.out = append(w.out, l...)
+}
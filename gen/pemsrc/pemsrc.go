@@ -0,0 +1,286 @@
+// Package pemsrc generates seeds for readers of PEM and base64. It
+// registers the "pem/..." generators with package gen.
+//
+// "pem/blocks" writes PEM text, input.pem, as encoding/pem reads it: one
+// block or more, of the types TLS keys and certificates use and of odd
+// ones, with Proc-Type and DEK-Info headers, other headers, headers
+// wrapped onto the next line as RFC 1421 allows, and bodies broken into
+// lines of 64 or 76 characters, of mixed lengths or not at all. Lines end
+// in line feeds or carriage returns and line feeds, now and then with
+// spaces and tabs before them; text, stray markers and base64 lie between
+// the blocks; and now and then an END line names another type, a block
+// has no END, or its body is padded wrongly.
+//
+// "pem/base64" writes base64 text, input.b64, as the encodings of
+// encoding/base64 read it: the standard and URL alphabets, padded or not,
+// broken into lines as MIME and PEM break them or not at all, and now and
+// then with padding where it does not belong, too much or too little of
+// it, data after it, bits set past the last byte, a length no encoding
+// has, or characters no alphabet holds.
+package pemsrc
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "pem/blocks",
+		Doc:  "encoding/pem text: certificate, key and odd block types, Proc-Type, DEK-Info and wrapped headers, bodies in lines of mixed lengths, CRLF and trailing white space, garbage between blocks, mismatched END lines and bad padding",
+		Func: blocks,
+	})
+	gen.Register(&gen.Generator{
+		Name: "pem/base64",
+		Doc:  "encoding/base64 text: standard and URL alphabets, padded and raw, MIME and PEM line breaks, misplaced, missing and extra padding, data after padding, stray trailing bits and invalid characters",
+		Func: text,
+	})
+}
+
+// badRate is the chance that a part of a block or of base64 text is
+// malformed. A seed has a handful of parts, so about one in twelve gets
+// one.
+const badRate = 0.02
+
+// types are the block types of RFC 7468 and those OpenSSL, OpenSSH and
+// PGP write, and types no writer would: empty, lower case, with a colon,
+// dashes, trailing spaces or a character past ASCII.
+var types = []string{
+	"CERTIFICATE", "CERTIFICATE", "PRIVATE KEY", "RSA PRIVATE KEY", "EC PRIVATE KEY", "PUBLIC KEY", "RSA PUBLIC KEY",
+	"CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST", "X509 CRL", "ENCRYPTED PRIVATE KEY", "EC PARAMETERS", "DH PARAMETERS",
+	"OPENSSH PRIVATE KEY", "TRUSTED CERTIFICATE", "PKCS7", "CMS", "ATTRIBUTE CERTIFICATE", "PGP MESSAGE", "PGP SIGNATURE",
+}
+
+var oddTypes = []string{
+	"", "certificate", "A:B", "TYPE  ", " TYPE", "X-----Y", "X-", "ÉTÉ", "A\tB", "A\x00B", "END", "BEGIN CERTIFICATE",
+}
+
+// ders are starts of DER, which most PEM bodies hold: a SEQUENCE with a
+// long and a short length, an INTEGER and a lone tag.
+var ders = [][]byte{{0x30, 0x82, 0x01, 0x0a}, {0x30, 0x81, 0x9f}, {0x30, 0x0d}, {0x02, 0x01, 0x00}, {0x30}}
+
+// body returns the bytes of a block: DER-like most often, and otherwise
+// any, from none to a few kilobytes.
+func body(s *gen.State) []byte {
+	n := gen.Pick(s, 0, 1, 2, 3, 47, 48, 49, s.Range(1, 300), s.Range(300, 3000))
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(s.Intn(256))
+	}
+	if n > 4 && s.Chance(0.7) {
+		copy(b, gen.Pick(s, ders...))
+	}
+	return b
+}
+
+// newline returns the line ending of a text: a line feed most often, and
+// otherwise a carriage return and line feed.
+func newline(s *gen.State) string {
+	return gen.Pick(s, "\n", "\n", "\n", "\r\n")
+}
+
+// tail returns what a line ends in before its line ending: nothing most
+// often, and otherwise spaces and tabs, which readers drop, or, now and
+// then, a carriage return or a space past ASCII, which they do not.
+func tail(s *gen.State) string {
+	switch {
+	case s.Chance(badRate):
+		return gen.Pick(s, "\r", "\u00a0", "\x00", "x")
+	case s.Chance(0.1):
+		return gen.Pick(s, " ", "\t", "  ", " \t ")
+	}
+	return ""
+}
+
+// wrap breaks enc into lines: of 64 characters as PEM writers break it
+// or 76 as MIME does, of one length or another, or of lengths that
+// change from line to line; or not at all.
+func wrap(s *gen.State, enc string, nl string) string {
+	n := gen.Pick(s, 64, 64, 64, 76, 0, 4, 63, 65, s.Range(1, 100))
+	mixed := s.Chance(0.1)
+	var b strings.Builder
+	for len(enc) > 0 {
+		k := len(enc)
+		if mixed {
+			n = s.Range(1, 80)
+		}
+		if n > 0 && n < k {
+			k = n
+		}
+		b.WriteString(enc[:k])
+		enc = enc[k:]
+		if s.Chance(badRate) {
+			b.WriteString(gen.Pick(s, " ", "\t", "\r"))
+		}
+		b.WriteString(tail(s) + nl)
+	}
+	return b.String()
+}
+
+// pad spoils the padding of enc, a padded encoding: it moves it, adds to
+// it or drops it, sets bits past the last byte, or adds a character from
+// another alphabet or none.
+func pad(s *gen.State, enc string) string {
+	if enc == "" {
+		return gen.Pick(s, "=", "==", "====", "A", "AA=")
+	}
+	body := strings.TrimRight(enc, "=")
+	i := s.Intn(len(body) + 1)
+	switch s.Intn(7) {
+	case 0:
+		return body[:i] + "=" + body[i:] + enc[len(body):]
+	case 1:
+		return enc + gen.Pick(s, "=", "==", "A", "AA==", "=A")
+	case 2:
+		return body
+	case 3:
+		if len(body) < len(enc) {
+			return body + "=" + strings.Repeat("=", len(enc)-len(body))
+		}
+		return enc + "="
+	case 4:
+		last := strings.IndexByte(stdAlphabet, body[len(body)-1])
+		if last < 0 || len(body) == len(enc) {
+			return enc
+		}
+		return body[:len(body)-1] + string(stdAlphabet[last|1]) + enc[len(body):]
+	case 5:
+		return body[:i] + gen.Pick(s, "-", "_", "*", ".", "\x00", "é", "%3D") + body[i:] + enc[len(body):]
+	}
+	return body[:i] + gen.Pick(s, "=\n", "=\r\n=", "\n=") + body[i:]
+}
+
+const stdAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// headers returns the header lines of a block of type typ, each ending in
+// nl: Proc-Type and DEK-Info for a legacy encrypted key, and others.
+// Now and then one is wrapped onto the next line, has no space after its
+// colon or is there twice.
+func headers(s *gen.State, typ, nl string) string {
+	var h []string
+	if strings.HasSuffix(typ, "PRIVATE KEY") && s.Chance(0.4) {
+		h = append(h, "Proc-Type: 4,ENCRYPTED",
+			"DEK-Info: "+gen.Pick(s, "AES-128-CBC", "AES-256-CBC", "DES-EDE3-CBC", "DES-CBC")+","+gen.Pick(s, "3E1A0B7E5F6C2D4A9B8C7D6E5F4A3B2C", "0011223344556677", ""))
+	}
+	for range gen.Pick(s, 0, 0, 0, 1, 2, 5) {
+		h = append(h, gen.Pick(s, "Comment: \"2048-bit RSA key\"", "Version: GnuPG v2", "Hash: SHA256", "Subject: CN=example.com", "X-Empty:", "Key With Spaces :  value  ", ": no key", "a:b:c", "Comment:\u00a0nbsp\u00a0", "MessageID: <1@example.com>"))
+	}
+	if len(h) > 0 && s.Chance(0.1) {
+		h = append(h, h[s.Intn(len(h))])
+	}
+	if len(h) > 0 && s.Chance(0.1) {
+		// RFC 1421 continues a header on a line that starts with white
+		// space.
+		i := s.Intn(len(h))
+		h[i] += nl + gen.Pick(s, " continued", "\tcontinued: with a colon", " ")
+	}
+	if s.Chance(badRate) {
+		gen.Shuffle(s, h)
+	}
+	var b strings.Builder
+	for _, l := range h {
+		b.WriteString(l + tail(s) + nl)
+	}
+	if len(h) > 0 && !s.Chance(badRate*4) {
+		b.WriteString(nl)
+	}
+	return b.String()
+}
+
+// block returns a PEM block whose lines end in nl.
+func block(s *gen.State, nl string) string {
+	typ := gen.Pick(s, types...)
+	if s.Chance(badRate * 2) {
+		typ = gen.Pick(s, oddTypes...)
+	}
+	end := typ
+	if s.Chance(badRate) {
+		end = gen.Pick(s, strings.ToLower(typ), typ+" ", "X"+typ, gen.Pick(s, types...), "")
+	}
+	enc := base64.StdEncoding.EncodeToString(body(s))
+	if s.Chance(badRate * 2) {
+		enc = pad(s, enc)
+	}
+	var b strings.Builder
+	if s.Chance(badRate) {
+		b.WriteString(gen.Pick(s, " ", "\t", "x"))
+	}
+	b.WriteString("-----BEGIN " + typ + "-----" + tail(s) + nl)
+	b.WriteString(headers(s, typ, nl))
+	b.WriteString(wrap(s, enc, nl))
+	if s.Chance(badRate) {
+		return b.String()
+	}
+	if s.Chance(badRate) {
+		b.WriteString(gen.Pick(s, " ", "\t", "-"))
+	}
+	b.WriteString("-----END " + end + "-----" + tail(s))
+	return b.String()
+}
+
+// garbage is what lies between blocks: text, markers cut short or
+// standing alone, base64 and a BEGIN line deep inside another line.
+var garbage = []string{
+	"Certificate:", "    Data:", "subject=CN = example.com", "Bag Attributes", "    localKeyID: 01 00 00 00",
+	"-----BEGIN", "-----BEGIN CERTIFICATE", "-----BEGIN CERTIFICATE----", "----BEGIN CERTIFICATE-----",
+	"-----END CERTIFICATE-----", "-----END -----", "-----BEGIN -----", "MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA",
+	"text -----BEGIN CERTIFICATE-----", "\x00", "\ufeff", "",
+}
+
+func blocks(s *gen.State) []gen.File {
+	nl := newline(s)
+	var b strings.Builder
+	if s.Chance(0.2) {
+		b.WriteString(gen.Pick(s, garbage...) + nl)
+	}
+	for i := range gen.Pick(s, 1, 1, 1, 2, 3, 5) {
+		if i > 0 {
+			if s.Chance(0.1) {
+				nl = newline(s)
+			}
+			b.WriteString(nl)
+			if s.Chance(0.3) {
+				b.WriteString(gen.Pick(s, garbage...) + nl)
+			}
+		}
+		b.WriteString(block(s, nl))
+	}
+	if !s.Chance(0.2) {
+		b.WriteString(nl)
+	}
+	if s.Chance(0.1) {
+		b.WriteString(gen.Pick(s, garbage...))
+	}
+	return []gen.File{{Name: "input.pem", Data: []byte(b.String())}}
+}
+
+func text(s *gen.State) []gen.File {
+	enc := base64.StdEncoding
+	if s.Chance(0.4) {
+		enc = base64.URLEncoding
+	}
+	raw := s.Chance(0.4)
+	if raw {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	t := enc.EncodeToString(body(s))
+	if s.Chance(badRate * 8) {
+		if raw {
+			t += gen.Pick(s, "A", "=", "==", "-", "+")
+		} else {
+			t = pad(s, t)
+		}
+	}
+	if s.Chance(0.3) {
+		t = wrap(s, t, newline(s))
+		if s.Chance(0.5) {
+			t = strings.TrimRight(t, "\r\n")
+		}
+	}
+	if s.Chance(badRate) {
+		t = gen.Pick(s, " ", "\t", "\n", "\r", "\ufeff") + t
+	}
+	return []gen.File{{Name: "input.b64", Data: []byte(t)}}
+}
@@ -0,0 +1,365 @@
+// Package protosrc generates protocol buffer seeds. It registers the
+// "protobuf/..." generators with package gen.
+//
+// "protobuf/message" writes a schema, schema.desc, a FileDescriptorSet
+// of one proto2 or proto3 file, and a message of the schema's first
+// type in the wire format, input.pb. Schemas have a few message types
+// with fields of every scalar type, enums open and closed, fields that
+// refer to their own type or to each other's, repeated fields packed
+// and not, maps, oneofs, and, in proto2, groups and required fields.
+// Messages are soups of fields in any order: fields set twice, repeated
+// fields split between packed and unpacked runs, map entries without a
+// key or value or with one twice, unknown fields of every wire type,
+// enum values no enum has, and now and then a chain of messages or of
+// groups nested thousands deep, past where a decoder stops recursing.
+// A few break the format: varints longer than ten bytes or with bits
+// past 64, lengths past the end, groups that end with another field's
+// number or never end, known fields with the wrong wire type, field
+// number zero or past the largest, strings that are not UTF-8, and
+// messages cut short; and a few schemas do not hold together, with
+// numbers twice, reserved numbers, or types that do not exist.
+package protosrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "protobuf/message",
+		Doc:  "protobuf schemas as FileDescriptorSets and messages of them in the wire format: every scalar type, open and closed enums, recursive types, packed and unpacked repeated fields, maps, oneofs, groups and required fields, unknown fields, and deep nesting, overlong varints, bad lengths, mismatched groups and wire types, now and then",
+		Func: message,
+	})
+}
+
+// badRate is the chance that one of the many fields, numbers and lengths
+// of a schema or message is wrong, so that one message in ten or so is
+// broken.
+const badRate = 0.003
+
+// Field types and labels, short.
+type (
+	kind  = descriptorpb.FieldDescriptorProto_Type
+	label = descriptorpb.FieldDescriptorProto_Label
+)
+
+// scalars are the field types that are neither messages, groups nor
+// enums.
+var scalars = []kind{
+	descriptorpb.FieldDescriptorProto_TYPE_DOUBLE,
+	descriptorpb.FieldDescriptorProto_TYPE_FLOAT,
+	descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED64,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT32,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// mapKeys are the field types a map's key may have.
+var mapKeys = []kind{
+	descriptorpb.FieldDescriptorProto_TYPE_INT64,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT64,
+	descriptorpb.FieldDescriptorProto_TYPE_INT32,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED64,
+	descriptorpb.FieldDescriptorProto_TYPE_FIXED32,
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL,
+	descriptorpb.FieldDescriptorProto_TYPE_STRING,
+	descriptorpb.FieldDescriptorProto_TYPE_UINT32,
+	descriptorpb.FieldDescriptorProto_TYPE_SFIXED32,
+	descriptorpb.FieldDescriptorProto_TYPE_SINT64,
+}
+
+// A field is a field of a message type.
+type field struct {
+	name   string
+	num    int32
+	typ    kind
+	label  label
+	packed *bool // the packed option, if set
+	msg    int   // the type of a message, group or map field
+	enum   int   // the type of an enum field
+	oneof  bool  // whether the field is in the message's oneof
+	// typeName is the name of the field's type as the schema gives it,
+	// for a field that refers to a type that does not exist.
+	typeName string
+}
+
+// A msgType is a message type: its name, the type it is nested in, or
+// -1, and its fields.
+type msgType struct {
+	name     string
+	parent   int
+	fields   []*field
+	mapEntry bool
+	group    bool
+}
+
+// An enumType is an enum and its values.
+type enumType struct {
+	name   string
+	values []int32
+}
+
+// A schema is the types of one file, as the generator builds them and
+// writes messages of them.
+type schema struct {
+	s      *gen.State
+	proto3 bool
+	msgs   []*msgType
+	enums  []enumType
+}
+
+// message writes a schema and a message of its first type.
+func message(s *gen.State) []gen.File {
+	sc := newSchema(s)
+	desc, err := proto.Marshal(&descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{sc.file()}})
+	if err != nil {
+		panic(err)
+	}
+	e := &encoder{schema: sc, budget: 1 << 16}
+	if s.Chance(0.02) {
+		e.deep = gen.Pick(s, s.Range(500, maxDeepMessages), s.Range(5000, 20000))
+	}
+	b := e.message(nil, 0, 0)
+	if s.Chance(0.05) {
+		// A second message after the first, which a decoder merges into
+		// it.
+		b = e.message(b, 0, 0)
+	}
+	if s.Chance(badRate*10) && len(b) > 1 {
+		b = b[:s.Intn(len(b))]
+	}
+	return []gen.File{
+		{Name: "schema.desc", Data: desc},
+		{Name: "input.pb", Data: b},
+	}
+}
+
+func (sc *schema) broken() bool { return sc.s.Chance(badRate) }
+
+// newSchema returns a schema of a few message types and enums.
+func newSchema(s *gen.State) *schema {
+	sc := &schema{s: s, proto3: s.Chance(0.5)}
+	for i := range s.Range(1, 2) {
+		e := enumType{name: fmt.Sprintf("E%d", i)}
+		seen := map[int32]bool{}
+		if sc.proto3 && !sc.broken() {
+			e.values = append(e.values, 0)
+			seen[0] = true
+		}
+		for range s.Range(1, 5) {
+			v := gen.Pick[int32](s, 0, 1, 2, 3, -1, 100, 1<<31-1, -1<<31)
+			if !seen[v] {
+				seen[v] = true
+				e.values = append(e.values, v)
+			}
+		}
+		sc.enums = append(sc.enums, e)
+	}
+	top := s.Range(1, 3)
+	for i := range top {
+		sc.msgs = append(sc.msgs, &msgType{name: fmt.Sprintf("M%d", i), parent: -1})
+	}
+	// Fields for each type, including the groups and map entries that
+	// adds as it goes, up to a limit.
+	for i := 0; i < len(sc.msgs); i++ {
+		if !sc.msgs[i].mapEntry {
+			sc.fields(i, top)
+		}
+	}
+	return sc
+}
+
+// fields gives message type i its fields, of types among the first top
+// message types.
+func (sc *schema) fields(i, top int) {
+	s := sc.s
+	m := sc.msgs[i]
+	num := int32(0)
+	n := s.Intn(10)
+	for j := range n {
+		num += int32(gen.Pick(s, 1, 1, 1, 2, 10))
+		switch {
+		case j == n-1 && s.Chance(0.05):
+			num = 1<<29 - 1 // the largest there is
+		case s.Chance(0.03):
+			num = max(num, gen.Pick[int32](s, 18999, 1<<15, 1<<20, 1<<28))
+		}
+		if num >= 19000 && num <= 19999 {
+			num = 20000 // past the numbers reserved for the implementation
+		}
+		if sc.broken() {
+			num = gen.Pick[int32](s, 0, 19000, 19999, 1<<29, -1, num-1)
+		}
+		f := &field{name: fmt.Sprintf("f%d", uint32(num)), num: num, label: descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL}
+		switch r := s.Intn(20); {
+		case r < 10:
+			f.typ = gen.Pick(s, scalars...)
+		case r < 12:
+			f.typ = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+			f.enum = s.Intn(len(sc.enums))
+		case r < 16:
+			f.typ = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+			f.msg = s.Intn(top)
+		case r < 18 && !sc.proto3 && len(sc.msgs) < 12:
+			// A group, whose field is named for its type in lower case.
+			f.typ = descriptorpb.FieldDescriptorProto_TYPE_GROUP
+			f.msg = len(sc.msgs)
+			f.name = fmt.Sprintf("g%d", uint32(num))
+			sc.msgs = append(sc.msgs, &msgType{name: fmt.Sprintf("G%d", uint32(num)), parent: i, group: true})
+		case len(sc.msgs) < 12:
+			// A map, whose entry type is named for its field.
+			f.typ = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+			f.label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+			f.msg = len(sc.msgs)
+			f.name = fmt.Sprintf("m%d", uint32(num))
+			entry := &msgType{name: fmt.Sprintf("M%dEntry", uint32(num)), parent: i, mapEntry: true}
+			opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+			entry.fields = []*field{
+				{name: "key", num: 1, typ: gen.Pick(s, mapKeys...), label: opt},
+				{name: "value", num: 2, typ: gen.Pick(s, scalars...), label: opt},
+			}
+			switch s.Intn(4) {
+			case 0:
+				entry.fields[1].typ = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+				entry.fields[1].msg = s.Intn(top)
+			case 1:
+				// Only an enum whose first value is zero, as a map's must.
+				if e := s.Intn(len(sc.enums)); sc.enums[e].values[0] == 0 || sc.broken() {
+					entry.fields[1].typ = descriptorpb.FieldDescriptorProto_TYPE_ENUM
+					entry.fields[1].enum = e
+				}
+			}
+			sc.msgs = append(sc.msgs, entry)
+		default:
+			f.typ = gen.Pick(s, scalars...)
+		}
+		if f.label != descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+			switch {
+			case s.Chance(0.3):
+				f.label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+				if packable(f.typ) && s.Chance(0.3) {
+					packed := !sc.proto3
+					f.packed = &packed
+				}
+			case !sc.proto3 && s.Chance(0.1) || sc.broken():
+				f.label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED
+			}
+		}
+		if sc.broken() {
+			f.typeName = gen.Pick(s, ".seed.Missing", "M0", ".seed", ".google.protobuf.Any")
+		}
+		m.fields = append(m.fields, f)
+	}
+	// A oneof of the last few fields that may be in one, which follow
+	// each other as a .proto file declares them.
+	if s.Chance(0.3) {
+		for j := len(m.fields) - 1; j >= 0 && j >= len(m.fields)-3; j-- {
+			f := m.fields[j]
+			if f.label != descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL || f.typ == descriptorpb.FieldDescriptorProto_TYPE_GROUP && !sc.broken() {
+				break
+			}
+			f.oneof = true
+		}
+	}
+}
+
+// packable reports whether a repeated field of type t may be packed: it
+// is a number, a bool or an enum.
+func packable(t kind) bool {
+	switch t {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES,
+		descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return false
+	}
+	return true
+}
+
+// fullName returns the full name of message type i, with a leading dot.
+func (sc *schema) fullName(i int) string {
+	var parts []string
+	for ; i >= 0; i = sc.msgs[i].parent {
+		parts = append([]string{sc.msgs[i].name}, parts...)
+	}
+	return ".seed." + strings.Join(parts, ".")
+}
+
+// file returns the schema as a file descriptor.
+func (sc *schema) file() *descriptorpb.FileDescriptorProto {
+	fd := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("seed.proto"),
+		Package: proto.String("seed"),
+	}
+	if sc.proto3 {
+		fd.Syntax = proto.String("proto3")
+	} else if sc.s.Chance(0.5) {
+		fd.Syntax = proto.String("proto2")
+	}
+	for _, e := range sc.enums {
+		ed := &descriptorpb.EnumDescriptorProto{Name: proto.String(e.name)}
+		for j, v := range e.values {
+			ed.Value = append(ed.Value, &descriptorpb.EnumValueDescriptorProto{
+				Name:   proto.String(fmt.Sprintf("%s_V%d", e.name, j)),
+				Number: proto.Int32(v),
+			})
+		}
+		fd.EnumType = append(fd.EnumType, ed)
+	}
+	descs := make([]*descriptorpb.DescriptorProto, len(sc.msgs))
+	for i, m := range sc.msgs {
+		d := &descriptorpb.DescriptorProto{Name: proto.String(m.name)}
+		if m.mapEntry {
+			d.Options = &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)}
+		}
+		for _, f := range m.fields {
+			fdp := &descriptorpb.FieldDescriptorProto{
+				Name:     proto.String(f.name),
+				Number:   proto.Int32(f.num),
+				Type:     f.typ.Enum(),
+				Label:    f.label.Enum(),
+				JsonName: proto.String(f.name),
+			}
+			switch f.typ {
+			case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+				fdp.TypeName = proto.String(sc.fullName(f.msg))
+			case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+				fdp.TypeName = proto.String(".seed." + sc.enums[f.enum].name)
+			}
+			if f.typeName != "" {
+				fdp.TypeName = proto.String(f.typeName)
+			}
+			if f.packed != nil {
+				fdp.Options = &descriptorpb.FieldOptions{Packed: proto.Bool(*f.packed)}
+			}
+			if f.oneof {
+				fdp.OneofIndex = proto.Int32(0)
+			}
+			d.Field = append(d.Field, fdp)
+		}
+		if len(d.Field) > 0 && d.Field[len(d.Field)-1].OneofIndex != nil {
+			d.OneofDecl = []*descriptorpb.OneofDescriptorProto{{Name: proto.String("choice")}}
+		}
+		descs[i] = d
+	}
+	for i, m := range sc.msgs {
+		if m.parent < 0 {
+			fd.MessageType = append(fd.MessageType, descs[i])
+		} else {
+			descs[m.parent].NestedType = append(descs[m.parent].NestedType, descs[i])
+		}
+	}
+	return fd
+}
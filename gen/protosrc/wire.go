@@ -0,0 +1,352 @@
+package protosrc
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// Wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireStart   = 3 // start of a group
+	wireEnd     = 4 // end of a group
+	wireFixed32 = 5
+)
+
+// An encoder writes messages of a schema in the wire format.
+type encoder struct {
+	*schema
+	// budget is how many more bytes the message may have, roughly; past
+	// it, messages have only the fields they must.
+	budget int
+	// deep is how deep a chain of messages or groups, if the schema has
+	// a type that may hold itself, is to go, or 0.
+	deep int
+}
+
+// maxDeepMessages is as deep as a chain of messages goes, rather than
+// groups, each of which has the length of all those inside it.
+const maxDeepMessages = 3000
+
+// maxRequiredDepth is as deep as required message and group fields are
+// written once a message has no budget left.
+const maxRequiredDepth = 16
+
+// varint appends v as a varint, now and then padded to ten bytes with
+// continuation bytes, or broken: eleven bytes, or ten with bits set past
+// the 64th.
+func (e *encoder) varint(b []byte, v uint64) []byte {
+	switch {
+	case e.broken():
+		if e.s.Chance(0.5) {
+			return append(padded(b, v, 10)[:len(b)+9], byte(v>>63)|0x80, 0x01)
+		}
+		return append(padded(b, v, 10)[:len(b)+9], byte(v>>63)|byte(e.s.Range(1, 63))<<1)
+	case e.s.Chance(0.02):
+		return padded(b, v, e.s.Range(binary.PutUvarint(make([]byte, 10), v), 10))
+	}
+	return binary.AppendUvarint(b, v)
+}
+
+// padded appends v as a varint n bytes long, padded with continuation
+// bytes.
+func padded(b []byte, v uint64, n int) []byte {
+	for range n - 1 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v)&0x7f)
+}
+
+// tag appends the tag of field num of wire type wt.
+func (e *encoder) tag(b []byte, num int32, wt int) []byte {
+	if e.broken() {
+		// Field number zero, one past the largest, or a wire type that
+		// does not exist.
+		switch e.s.Intn(3) {
+		case 0:
+			num = 0
+		case 1:
+			num = 1 << 29
+		default:
+			wt = gen.Pick(e.s, 6, 7)
+		}
+	}
+	return e.varint(b, uint64(uint32(num))<<3|uint64(wt))
+}
+
+// delimited appends data after its length, which now and then runs past
+// it or stops short.
+func (e *encoder) delimited(b, data []byte) []byte {
+	n := uint64(len(data))
+	if e.broken() {
+		n = gen.Pick(e.s, n+1, n-1, n+uint64(e.s.Range(2, 1000)), 1<<31, math.MaxUint64)
+	}
+	b = e.varint(b, n)
+	return append(b, data...)
+}
+
+// wireType returns the wire type a value of type t goes as.
+func wireType(t kind) int {
+	switch t {
+	case descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, descriptorpb.FieldDescriptorProto_TYPE_FIXED64, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64:
+		return wireFixed64
+	case descriptorpb.FieldDescriptorProto_TYPE_FLOAT, descriptorpb.FieldDescriptorProto_TYPE_FIXED32, descriptorpb.FieldDescriptorProto_TYPE_SFIXED32:
+		return wireFixed32
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		return wireBytes
+	case descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		return wireStart
+	}
+	return wireVarint
+}
+
+// message appends the fields of a message of type m, at depth deep in
+// the message written.
+func (e *encoder) message(b []byte, m, depth int) []byte {
+	start := len(b)
+	fields := slices.Clone(e.msgs[m].fields)
+	if e.s.Chance(0.3) {
+		gen.Shuffle(e.s, fields)
+	}
+	// The field that carries the chain on, if the message is one link of
+	// a deep one.
+	var next *field
+	if depth < e.deep {
+		for _, f := range fields {
+			// Not a message of thousands of messages, which takes as
+			// long to write as the square of how deep it goes.
+			if f.typ == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE && !e.msgs[f.msg].mapEntry && e.deep <= maxDeepMessages || f.typ == descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+				next = f
+				break
+			}
+		}
+	}
+	if next != nil {
+		return e.field(b, next, depth)
+	}
+	if depth < e.deep && e.s.Chance(0.5) {
+		return e.unknownGroups(b, e.deep-depth)
+	}
+	for _, f := range fields {
+		if e.budget <= 0 || depth > 6 {
+			// Required messages may hold themselves, so that past a
+			// point they are left out, and the message is partial.
+			if f.label == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED && (depth < maxRequiredDepth || wireType(f.typ) != wireBytes && wireType(f.typ) != wireStart) {
+				b = e.field(b, f, depth)
+			}
+			continue
+		}
+		n := 0
+		switch {
+		case f.label == descriptorpb.FieldDescriptorProto_LABEL_REPEATED:
+			n = gen.Pick(e.s, 0, 1, 1, 2, 3, 5)
+		case f.label == descriptorpb.FieldDescriptorProto_LABEL_REQUIRED && !e.broken():
+			n = 1
+		case e.s.Chance(0.6):
+			n = 1
+			if e.s.Chance(0.05) {
+				n = 2 // set twice, which the last wins or a message merges
+			}
+		}
+		if n > 0 && f.packed != nil || n > 1 && packable(f.typ) && f.label == descriptorpb.FieldDescriptorProto_LABEL_REPEATED && e.s.Chance(0.3) {
+			b = e.packed(b, f, n)
+			continue
+		}
+		for range n {
+			b = e.field(b, f, depth)
+		}
+		if e.s.Chance(0.05) {
+			b = e.unknown(b)
+		}
+	}
+	e.budget -= len(b) - start
+	return b
+}
+
+// field appends one value of field f: its tag and value.
+func (e *encoder) field(b []byte, f *field, depth int) []byte {
+	wt := wireType(f.typ)
+	if e.broken() {
+		// A known field with the wrong wire type, which a decoder keeps
+		// as an unknown one.
+		wt = gen.Pick(e.s, wireVarint, wireFixed32, wireFixed64, wireBytes)
+		b = e.tag(b, f.num, wt)
+		return e.value(b, wt, nil)
+	}
+	b = e.tag(b, f.num, wt)
+	switch f.typ {
+	case descriptorpb.FieldDescriptorProto_TYPE_MESSAGE:
+		if e.msgs[f.msg].mapEntry {
+			return e.delimited(b, e.entry(f.msg, depth+1))
+		}
+		return e.delimited(b, e.message(nil, f.msg, depth+1))
+	case descriptorpb.FieldDescriptorProto_TYPE_GROUP:
+		b = e.message(b, f.msg, depth+1)
+		num := f.num
+		switch {
+		case e.broken():
+			num++ // the end of another group
+		case e.s.Chance(badRate):
+			return b // a group that never ends
+		}
+		return e.tag(b, num, wireEnd)
+	}
+	return e.scalar(b, f)
+}
+
+// entry returns a map entry of type m: a key and a value, now and then
+// one without the other, or one twice, or the value first.
+func (e *encoder) entry(m, depth int) []byte {
+	key, value := e.msgs[m].fields[0], e.msgs[m].fields[1]
+	var b []byte
+	switch e.s.Intn(10) {
+	case 0:
+		b = e.field(b, value, depth)
+	case 1:
+		b = e.field(b, key, depth)
+	case 2:
+		b = e.field(e.field(b, value, depth), key, depth)
+	case 3:
+		b = e.field(e.field(e.field(b, key, depth), value, depth), key, depth)
+	default:
+		b = e.field(e.field(b, key, depth), value, depth)
+	}
+	if e.s.Chance(0.05) {
+		b = e.unknown(b)
+	}
+	return b
+}
+
+// packed appends n values of a repeated field f packed in one run, or in
+// two, which a decoder appends together, or the values of a packed field
+// one by one, which a decoder takes too.
+func (e *encoder) packed(b []byte, f *field, n int) []byte {
+	if f.packed != nil && e.s.Chance(0.2) {
+		for range n {
+			b = e.field(b, f, 0)
+		}
+		return b
+	}
+	for run := range gen.Pick(e.s, 1, 1, 1, 2) {
+		var data []byte
+		for range n - run {
+			data = e.value(data, wireType(f.typ), f)
+		}
+		b = e.tag(b, f.num, wireBytes)
+		b = e.delimited(b, data)
+	}
+	return b
+}
+
+// scalar appends the value of a field of a scalar or enum type.
+func (e *encoder) scalar(b []byte, f *field) []byte {
+	switch f.typ {
+	case descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BYTES:
+		s := gen.Pick(e.s, "", "hello", "héllo, wörld", "\x00", "日本語", "\U0001F600", "a\u0301", "\ufeff")
+		if e.broken() || f.typ == descriptorpb.FieldDescriptorProto_TYPE_BYTES && e.s.Chance(0.3) {
+			// Not UTF-8, which proto3 strings must be.
+			s += gen.Pick(e.s, "\xff", "\xc0\x80", "\xed\xa0\x80", "\xe0\x80")
+		}
+		if e.s.Chance(0.01) {
+			s = string(make([]byte, e.s.Range(1000, 10000)))
+		}
+		return e.delimited(b, []byte(s))
+	}
+	return e.value(b, wireType(f.typ), f)
+}
+
+// value appends a value of wire type wt, of field f, or any if f is
+// nil.
+func (e *encoder) value(b []byte, wt int, f *field) []byte {
+	switch wt {
+	case wireFixed32:
+		v := uint32(e.number())
+		if f != nil && f.typ == descriptorpb.FieldDescriptorProto_TYPE_FLOAT && e.s.Chance(0.5) {
+			v = math.Float32bits(float32(e.float()))
+		}
+		return binary.LittleEndian.AppendUint32(b, v)
+	case wireFixed64:
+		v := e.number()
+		if f != nil && f.typ == descriptorpb.FieldDescriptorProto_TYPE_DOUBLE && e.s.Chance(0.5) {
+			v = math.Float64bits(e.float())
+		}
+		return binary.LittleEndian.AppendUint64(b, v)
+	case wireBytes:
+		return e.delimited(b, []byte(gen.Pick(e.s, "", "x", "\x08\x01", "\x0a\x00")))
+	}
+	v := e.number()
+	if f != nil {
+		switch f.typ {
+		case descriptorpb.FieldDescriptorProto_TYPE_ENUM:
+			// A value of the enum, mostly, or one it does not have, which
+			// a decoder keeps as an unknown field if the enum is closed.
+			values := e.enums[f.enum].values
+			if len(values) > 0 && e.s.Chance(0.8) {
+				v = uint64(int64(gen.Pick(e.s, values...)))
+			}
+		case descriptorpb.FieldDescriptorProto_TYPE_BOOL:
+			v = gen.Pick[uint64](e.s, 0, 1, 1, 2, 1<<32, 1<<63)
+		case descriptorpb.FieldDescriptorProto_TYPE_SINT32:
+			n := int32(v)
+			v = uint64(uint32(n<<1) ^ uint32(n>>31))
+		case descriptorpb.FieldDescriptorProto_TYPE_SINT64:
+			n := int64(v)
+			v = uint64(n<<1) ^ uint64(n>>63)
+		}
+	}
+	return e.varint(b, v)
+}
+
+// number returns a number, at the edges of the ranges of 32 and 64 bits
+// more often than not, and negative numbers sign-extended to 64 bits as
+// an int32 is sent.
+func (e *encoder) number() uint64 {
+	switch e.s.Intn(4) {
+	case 0:
+		return uint64(e.s.Range(0, 300))
+	case 1:
+		return gen.Pick[uint64](e.s, math.MaxUint32, math.MaxInt32, 1<<31, math.MaxUint64, math.MaxInt64, 1<<63, 1<<32)
+	case 2:
+		return e.s.Uint64()
+	}
+	return uint64(int64(-e.s.Range(1, 1000)))
+}
+
+// float returns a float: ordinary, at the edges of the range, an
+// infinity or a NaN.
+func (e *encoder) float() float64 {
+	return gen.Pick(e.s, 0, 1.5, -2.25, math.Pi, 1e300, -1e-300, math.MaxFloat32, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN(), math.Copysign(0, -1))
+}
+
+// unknown appends a field of a number no message type has, of any wire
+// type.
+func (e *encoder) unknown(b []byte) []byte {
+	num := int32(gen.Pick(e.s, 1000, 1001, 15, 16, 2047, 2048, 1<<29-1))
+	wt := gen.Pick(e.s, wireVarint, wireFixed32, wireFixed64, wireBytes, wireStart)
+	if wt == wireStart {
+		return e.unknownGroups(b, e.s.Range(1, 3))
+	}
+	b = e.tag(b, num, wt)
+	return e.value(b, wt, nil)
+}
+
+// unknownGroups appends groups of a number no message type has, nested
+// n deep.
+func (e *encoder) unknownGroups(b []byte, n int) []byte {
+	num := int32(gen.Pick(e.s, 1000, 1001, 1<<29-1))
+	for range n {
+		b = e.tag(b, num, wireStart)
+	}
+	b = e.value(e.tag(b, num, wireVarint), wireVarint, nil)
+	for range n {
+		b = e.tag(b, num, wireEnd)
+	}
+	return b
+}
@@ -0,0 +1,915 @@
+// Package pysrc generates Python seeds. It registers the "py/..."
+// generators with package gen.
+//
+// "py/polyglot" writes what gosrc writes for Go: a dense file of the
+// syntax a Python front end finds hardest, for fuzzing the parsers,
+// formatters and linters written in Go. Assignment expressions sit in
+// conditions, comprehensions and arguments; match statements take every
+// kind of pattern, with guards, and match and case are names elsewhere;
+// f-strings nest in each other, reuse their quotes as Python 3.12 allows,
+// and carry conversions, debug specifiers and format specs with fields
+// of their own; decorators are arbitrary expressions, stacked on
+// functions, async functions and classes; and async functions await in
+// comprehensions, async for and async with. Around them are type
+// parameters and aliases, positional-only and keyword-only parameters,
+// star targets, except*, parenthesized context managers, numbers with
+// underscores, implicit string concatenation, line continuations and
+// identifiers that only NFKC normalization makes equal. A few
+// constructs are malformed.
+package pysrc
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "py/polyglot",
+		Doc:  "Python files dense with assignment expressions, match statements of every pattern, nested f-strings with format specs and debug specifiers, arbitrary decorator expressions, async comprehensions, async for and async with, type parameters, except* and parenthesized context managers, with a few malformed constructs",
+		Func: polyglot,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// file draws a few hundred, so about one file in ten gets one.
+const badRate = 0.001
+
+// A ctx is where in a file an expression or statement is written.
+type ctx struct {
+	async bool // await, async for and async with are allowed
+	fn    bool // return is allowed
+	yield bool // yield is allowed, in the body of a function that is not async
+	loop  bool // break and continue are allowed
+}
+
+// A pygen accumulates a Python file.
+type pygen struct {
+	s      *gen.State
+	b      strings.Builder
+	depth  int // expressions
+	blocks int // statements
+	indent string
+	names  []string // names assigned at the top level
+	single int      // fields of single-quoted f-strings being written, which end at a newline
+}
+
+func polyglot(s *gen.State) []gen.File {
+	g := &pygen{s: s, depth: s.Depth(s.Limits.Expr, 3), blocks: s.Depth(s.Limits.Block, 3)}
+	g.indent = gen.Pick(s, "    ", "    ", "  ", "\t", " ")
+	if s.Chance(0.2) {
+		g.line(0, gen.Pick(s, "#!/usr/bin/env python3", "# -*- coding: utf-8 -*-", "# vim: set fileencoding=utf-8 :"))
+	}
+	if s.Chance(0.3) {
+		g.line(0, gen.Pick(s, `"""Module docstring."""`, `'''Module
+docstring.'''`, `r"\d docstring"`))
+	}
+	if s.Chance(0.3) {
+		g.line(0, "from __future__ import annotations")
+	}
+	g.line(0, "import asyncio, dataclasses")
+	for range s.Range(4, 12) {
+		g.item()
+	}
+	if s.Chance(0.3) {
+		if !strings.Contains(g.b.String(), "async def main(") {
+			g.async()
+		}
+		g.line(0, `if __name__ == "__main__":`)
+		g.line(1, "asyncio.run(main())")
+	}
+	return []gen.File{{Name: "input.py", Data: []byte(g.b.String())}}
+}
+
+func (g *pygen) broken() bool { return g.s.Chance(badRate) }
+
+// line writes text at the given level of indentation.
+func (g *pygen) line(level int, text string) {
+	g.b.WriteString(strings.Repeat(g.indent, level))
+	g.b.WriteString(text)
+	g.b.WriteString(gen.Pick(g.s, "\n", "\n", "\n", "\n", "\r\n", "  # comment\n"))
+}
+
+// item writes a statement at the top level.
+func (g *pygen) item() {
+	s := g.s
+	switch s.Intn(12) {
+	case 0:
+		g.walrus(0, ctx{})
+	case 1:
+		g.match(0, ctx{}, g.blocks)
+	case 2:
+		g.fstrings(0, ctx{})
+	case 3:
+		g.decorated(0)
+	case 4:
+		g.async()
+	case 5:
+		g.class(0)
+	case 6:
+		g.typing()
+	case 7:
+		g.hazard(0)
+	default:
+		g.stmt(0, ctx{}, g.blocks)
+	}
+	if s.Chance(0.2) {
+		g.b.WriteString(gen.Pick(s, "\n", "# comment\n", "\n\n", "    \n"))
+	}
+}
+
+// assign returns a fresh name assigned at the top level.
+func (g *pygen) assign(prefix string) string {
+	name := g.s.Fresh(prefix)
+	g.names = append(g.names, name)
+	return name
+}
+
+// names are identifiers, many in other scripts and some that NFKC
+// normalization turns into others.
+var names = []string{
+	"café", "π", "Ωmega", "имя", "名前", "변수", "ﬁle", "ℌ", "ｘ", "x̅", "_", "__", "_x_", "µ", "ª", "Ⅸ", "ǅ", "𝔘𝔫𝔦", "ｍａｔｃｈ",
+}
+
+// name returns a name to read.
+func (g *pygen) name() string {
+	s := g.s
+	if len(g.names) > 0 && s.Chance(0.6) {
+		return gen.Pick(s, g.names...)
+	}
+	return gen.Pick(s, "len", "print", "range", "None", "True", "False", "object", "int", "str", "dict", "list", "__name__", "Ellipsis", "NotImplemented", "__debug__")
+}
+
+// binding returns a name to assign.
+func (g *pygen) binding() string {
+	s := g.s
+	switch {
+	case s.Chance(0.1):
+		return gen.Pick(s, names...) + g.s.Fresh("")
+	case s.Chance(0.05):
+		return gen.Pick(s, "match", "case", "type")
+	}
+	return g.assign("v")
+}
+
+// number returns a numeric literal.
+func (g *pygen) number() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, "1__0", "1_", "0_1", "012", "0x", "1e", "0b2", "1.e_1", "0o8", "1jj")
+	}
+	return gen.Pick(s, "0", "1", "-1", "42", "1_000_000", "0xFF_FF", "0b1010", "0o17", "0O7_7", ".5e-3", "5.", "1e400",
+		"1E+21", "1j", "1.5J", "0_0", "00", "0.0_1", "1_0.0_1e1_0", "10**100", "9_223_372_036_854_775_808")
+}
+
+// str returns a string literal.
+func (g *pygen) str() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, `"unterminated`, `'\N{NO SUCH NAME}'`, `b"é"`, `'\x4'`, `"""unterminated`, `u"a" b"b"`, `'\U00110000'`)
+	}
+	strs := []string{`""`, `''`, `"abc"`, `'it\'s'`, `"\N{GREEK SMALL LETTER ALPHA}"`, `"😀"`, `"\ud800"`, `"\x41\0"`,
+		`r"\d+\"\\"`, `b"\xff\x00"`, `rb'\n'`, `Br"raw"`, `u"unicode"`, `"""triple "quoted" string"""`,
+		`"implicit" 'concat' "enation"`, `"\t\v\f"`, `"\U0001F600"`, `"{not} an f-string"`}
+	if g.single == 0 {
+		strs = append(strs, `'''a
+b'''`, `"line \
+continued"`)
+	}
+	return gen.Pick(s, strs...)
+}
+
+// fstring returns an f-string whose fields hold expressions at most d
+// deep, and f-strings of their own.
+func (g *pygen) fstring(c ctx, d int) string {
+	s := g.s
+	prefix := gen.Pick(s, "f", "F", "rf", "fr", "Rf", "fR")
+	quote := gen.Pick(s, `"`, `'`, `"""`, `'''`)
+	var b strings.Builder
+	b.WriteString(prefix + quote)
+	for range s.Range(0, 3) {
+		b.WriteString(gen.Pick(s, "text ", "", "{{", "}}", "{{}}", " ", "日本", "%s", "—"))
+		if len(quote) == 3 && g.single == 0 && s.Chance(0.2) {
+			b.WriteString("\nnew line\n")
+		}
+		if !s.Chance(0.7) {
+			continue
+		}
+		if len(quote) == 1 {
+			g.single++
+		}
+		var e string
+		switch {
+		case d > 0 && s.Chance(0.3):
+			e = g.fstring(c, d-1) // the same quotes nest since Python 3.12
+		case s.Chance(0.15):
+			e = gen.Pick(s, "(lambda x: x)(1)", "{'a': 1}['a']", "{1, 2}", "[*'ab']")
+		default:
+			e = g.expr(c, d-1)
+		}
+		if len(quote) == 1 {
+			g.single--
+		}
+		// A brace next to the braces around the field would double them.
+		if strings.HasPrefix(e, "{") {
+			e = " " + e
+		}
+		if strings.HasSuffix(e, "}") {
+			e += " "
+		}
+		b.WriteString("{" + e)
+		if s.Chance(0.2) {
+			b.WriteString(gen.Pick(s, "=", " = ", "= "))
+		}
+		if s.Chance(0.2) {
+			b.WriteString(gen.Pick(s, "!r", "!s", "!a"))
+		}
+		if s.Chance(0.3) {
+			b.WriteString(":" + gen.Pick(s, ">10", "^{w}", "{w}.{p}", ",", "_", "#x", "08.3f", "%Y-%m-%d", "", "{'>'}{10}", "=^+#030_.6e"))
+		}
+		b.WriteByte('}')
+	}
+	if g.broken() {
+		b.WriteString(gen.Pick(s, "{", "}", "{}", "{!r}", "{x!z}", "{x:{y:{z:{w}}}}", "{\\}"))
+	}
+	if len(quote) == 3 && b.Len() == len(prefix+quote) {
+		b.WriteString("text") // six quotes in a row end the string early in a field
+	}
+	b.WriteString(quote)
+	return b.String()
+}
+
+// expr returns an expression at most d deep.
+func (g *pygen) expr(c ctx, d int) string {
+	s := g.s
+	if d <= 0 {
+		return g.primary(c)
+	}
+	switch s.Intn(20) {
+	case 0, 1:
+		op := gen.Pick(s, "+", "-", "*", "/", "//", "%", "**", "@", "<<", ">>", "&", "|", "^", "and", "or", "==", "!=", "<", ">=", "is", "is not", "in", "not in")
+		return "(" + g.expr(c, d-1) + " " + op + " " + g.expr(c, d-1) + ")"
+	case 2:
+		// Chained comparisons and precedence without parentheses.
+		var b strings.Builder
+		b.WriteString(g.primary(c))
+		for range s.Range(1, 4) {
+			b.WriteString(gen.Pick(s, " < ", " <= ", " == ", " + ", " * ", " ** ", " if True else ", " and not ", " or ", " is not ", " not in "))
+			b.WriteString(g.primary(c))
+		}
+		return "(" + b.String() + ")"
+	case 3:
+		return "(" + gen.Pick(s, "-", "+", "~", "not ", "- -", "not not ", "-+~") + "(" + g.expr(c, d-1) + "))"
+	case 4:
+		return "(" + g.expr(c, d-1) + " if " + g.expr(c, d-1) + " else " + g.expr(c, d-1) + ")"
+	case 5:
+		return "(lambda " + gen.Pick(s, "", "x", "x, y=1", "*a, **k", "x, /, y, *, z=2", "x=lambda: 1") + ": " + g.expr(ctx{}, d-1) + ")"
+	case 6:
+		return g.call(c, d)
+	case 7:
+		return "(" + g.expr(c, d-1) + ")" + gen.Pick(s, ".real", ".__class__", "[0]", "[1:2]", "[::-1]", "[1:2, ...]", "[:, None]", "[()]")
+	case 8:
+		return g.comprehension(c, d)
+	case 9:
+		return g.fstring(c, d-1)
+	case 10:
+		return "(" + g.assign("w") + " := " + g.expr(c, d-1) + ")"
+	case 11:
+		if c.async {
+			return "(await (" + gen.Pick(s, "asyncio.sleep(0)", g.expr(c, d-1)) + "))"
+		}
+	case 12:
+		if c.yield && s.Chance(0.5) {
+			return "(" + gen.Pick(s, "yield", "yield "+g.primary(c), "yield from "+g.primary(c)) + ")"
+		}
+	case 13:
+		return "[" + g.exprs(c, d, true) + "]"
+	case 14:
+		return g.dict(c, d)
+	case 15:
+		return "(" + strings.TrimSuffix(g.exprs(c, d, true), ",") + ",)"
+	case 16:
+		return "{" + strings.TrimSuffix(g.exprs(c, d, true), ",") + gen.Pick(s, "", ",") + "}"
+	}
+	return g.primary(c)
+}
+
+// exprs returns a list of expressions, starred now and then.
+func (g *pygen) exprs(c ctx, d int, star bool) string {
+	s := g.s
+	var es []string
+	for range s.Range(1, 4) {
+		e := g.expr(c, d-1)
+		if star && s.Chance(0.15) {
+			e = "*(" + e + ")"
+		}
+		es = append(es, e)
+	}
+	if len(es) == 1 && strings.HasPrefix(es[0], "*") {
+		return es[0] + "," // a starred expression alone must be in a tuple
+	}
+	return strings.Join(es, ", ")
+}
+
+// dict returns a dict display.
+func (g *pygen) dict(c ctx, d int) string {
+	s := g.s
+	var items []string
+	for range s.Range(0, 4) {
+		if s.Chance(0.2) {
+			items = append(items, "**"+g.primary(c))
+			continue
+		}
+		items = append(items, g.primary(c)+": "+g.expr(c, d-1))
+	}
+	if len(items) > 0 && s.Chance(0.3) {
+		items[len(items)-1] += ","
+	}
+	return "{" + strings.Join(items, ", ") + "}"
+}
+
+// primary returns an expression that needs no parentheses.
+func (g *pygen) primary(c ctx) string {
+	s := g.s
+	switch s.Intn(10) {
+	case 0, 1, 2:
+		return g.name()
+	case 3, 4:
+		return g.number()
+	case 5, 6:
+		return g.str()
+	case 7:
+		return gen.Pick(s, "...", "None", "True", "()", "[]", "{}", "(1,)", "b''")
+	case 8:
+		return g.fstring(c, 0)
+	}
+	return gen.Pick(s, "dataclasses.field", "asyncio.sleep", "str.join", "__import__('os').sep")
+}
+
+// call returns a call with positional, keyword and unpacked arguments.
+func (g *pygen) call(c ctx, d int) string {
+	s := g.s
+	callee := gen.Pick(s, "print", "len", "dict", "sorted", "max", "str", "repr", "isinstance", "getattr", "(lambda *a, **k: (a, k))", "type", "list")
+	// Positional arguments come first, then keywords, then keywords
+	// unpacked.
+	var args, keywords, unpacked []string
+	for range s.Range(0, 4) {
+		switch s.Intn(6) {
+		case 0:
+			args = append(args, "*("+g.expr(c, d-1)+")")
+		case 1:
+			unpacked = append(unpacked, "**"+g.dict(c, d-1))
+		case 2:
+			keywords = append(keywords, s.Fresh("key")+"="+g.expr(c, d-1))
+		default:
+			args = append(args, g.expr(c, d-1))
+		}
+	}
+	args = append(append(args, keywords...), unpacked...)
+	if len(args) == 1 && s.Chance(0.2) {
+		// A generator expression as the only argument needs no parentheses
+		// of its own.
+		return callee + "(x for x in " + g.primary(c) + ")"
+	}
+	trail := ""
+	if len(args) > 0 && s.Chance(0.2) {
+		trail = ","
+	}
+	return callee + "(" + strings.Join(args, ", ") + trail + ")"
+}
+
+// comprehension returns a list, set, dict or generator comprehension,
+// asynchronous in an async function now and then.
+func (g *pygen) comprehension(c ctx, d int) string {
+	s := g.s
+	c.yield = false
+	var b strings.Builder
+	open, close := gen.Pick(s, "[", "{", "("), ""
+	switch open {
+	case "[":
+		close = "]"
+	case "{":
+		close = "}"
+	default:
+		close = ")"
+	}
+	if open == "{" && s.Chance(0.5) {
+		b.WriteString(open + "k: " + g.expr(c, d-1))
+	} else {
+		b.WriteString(open + g.expr(c, d-1))
+	}
+	for i := range s.Range(1, 3) {
+		kw := " for "
+		if c.async && s.Chance(0.4) {
+			kw = " async for "
+		}
+		target := gen.Pick(s, "x", "k", "(x, y)", "x, y", "[x, *y]", "k, (v, w)")
+		if i == 0 && open == "{" && strings.Contains(b.String(), "k: ") && !strings.HasPrefix(target, "k") {
+			target = "k"
+		}
+		src := gen.Pick(s, "range(3)", "'ab'", "[(1, 2)]", "{1: 2}.items()", g.primary(c))
+		if kw == " async for " {
+			src = "aiter_(" + src + ")"
+		}
+		b.WriteString(kw + target + " in " + src)
+		for range s.Range(0, 2) {
+			cond := g.expr(c, d-1)
+			if c.async && s.Chance(0.2) {
+				cond = "await asyncio.sleep(0, " + cond + ")"
+			}
+			if s.Chance(0.2) {
+				cond = "(" + g.assign("w") + " := " + cond + ")"
+			}
+			b.WriteString(" if " + cond)
+		}
+	}
+	b.WriteString(close)
+	return b.String()
+}
+
+// target returns an assignment target.
+func (g *pygen) target() string {
+	s := g.s
+	switch s.Intn(8) {
+	case 0:
+		return g.binding() + ", *" + g.binding()
+	case 1:
+		return "[" + g.binding() + ", (" + g.binding() + ", " + g.binding() + ")]"
+	case 2:
+		return "*" + g.binding() + ","
+	case 3:
+		return g.binding() + ": " + gen.Pick(s, "int", "list[int]", "dict[str, 'Fwd']", "int | None", "'str'", "tuple[int, ...]", "type[object]")
+	}
+	return g.binding()
+}
+
+// block writes the statements of a block at level.
+func (g *pygen) block(level int, c ctx, d int) {
+	n := g.s.Range(1, 3)
+	for range n {
+		g.stmt(level, c, d)
+	}
+}
+
+// stmt writes a statement at level with blocks at most d deep.
+func (g *pygen) stmt(level int, c ctx, d int) {
+	s := g.s
+	k := s.Intn(16)
+	if d <= 0 && k >= 8 {
+		k = s.Intn(8)
+	}
+	switch k {
+	case 0, 1:
+		g.line(level, g.expr(c, g.depth))
+	case 2, 3:
+		g.line(level, g.target()+" = "+g.exprs(c, g.depth, true))
+	case 4:
+		op := gen.Pick(s, "+=", "-=", "*=", "//=", "**=", "@=", "|=", ">>=", "%=")
+		v := g.binding()
+		g.line(level, v+" = 1; "+v+" "+op+" "+g.number())
+	case 5:
+		switch {
+		case c.yield:
+			g.line(level, gen.Pick(s, "return", "return "+g.primary(c), "yield "+g.primary(c), "yield from "+g.primary(c)))
+		case c.fn:
+			g.line(level, "return "+g.primary(c))
+		case c.loop:
+			g.line(level, gen.Pick(s, "break", "continue"))
+		default:
+			g.line(level, gen.Pick(s, "pass", "...", "assert True, 'message'", "global "+g.assign("gl")))
+		}
+	case 6:
+		g.line(level, gen.Pick(s, "pass", "...", "assert "+g.expr(c, g.depth)+", "+g.str(), "del ()"))
+	case 7:
+		g.walrus(level, c)
+	case 8:
+		g.line(level, "if "+g.expr(c, g.depth)+":")
+		g.block(level+1, c, d-1)
+		for range s.Range(0, 2) {
+			g.line(level, "elif "+g.expr(c, g.depth)+":")
+			g.block(level+1, c, d-1)
+		}
+		if s.Chance(0.5) {
+			g.line(level, "else:")
+			g.block(level+1, c, d-1)
+		}
+	case 9:
+		lc := c
+		lc.loop = true
+		kw := "for "
+		if c.async && s.Chance(0.3) {
+			kw = "async for "
+		}
+		src := gen.Pick(s, "range(2)", "'ab'", "[(1, 2)]", "{}", "zip([1], [2], strict=True)")
+		if kw == "async for " {
+			src = "aiter_(" + src + ")"
+		}
+		g.line(level, kw+gen.Pick(s, "x", "x, y", "(x, y)", "[x, *y]", "_")+" in "+src+":")
+		g.block(level+1, lc, d-1)
+		if s.Chance(0.3) {
+			g.line(level, "else:")
+			g.block(level+1, c, d-1)
+		}
+	case 10:
+		lc := c
+		lc.loop = true
+		n := g.assign("n")
+		g.line(level, n+" = 0")
+		g.line(level, "while ("+n+" := "+n+" + 1) < 3:")
+		g.block(level+1, lc, d-1)
+	case 11:
+		g.try(level, c, d)
+	case 12:
+		kw := "with "
+		if c.async && s.Chance(0.4) {
+			kw = "async with "
+		}
+		items := gen.Pick(s, "open(__file__) as f", "(open(__file__) as f, open(__file__) as g)", "open(__file__), open(__file__) as (h)", "(\n"+g.indent+"open(__file__) as f,\n)")
+		if kw == "async with " {
+			items = gen.Pick(s, "actx() as a", "(actx() as a, actx() as b,)", "actx()")
+		}
+		g.line(level, kw+items+":")
+		g.block(level+1, c, d-1)
+	case 13:
+		g.match(level, c, d)
+	case 14:
+		g.function(level, c, d)
+	default:
+		g.line(level, g.expr(c, g.depth)+gen.Pick(s, "", ";", "; pass", " ; "+g.primary(c)))
+	}
+}
+
+// try writes a try statement with except or except* clauses.
+func (g *pygen) try(level int, c ctx, d int) {
+	s := g.s
+	g.line(level, "try:")
+	g.block(level+1, c, d-1)
+	star := s.Chance(0.3)
+	kw := "except"
+	if star {
+		kw = "except*"
+	}
+	hc := c
+	if star {
+		// An except* block may not break, continue or return.
+		hc.fn, hc.yield, hc.loop = false, false, false
+	}
+	n := s.Range(0, 2)
+	for range n {
+		g.line(level, kw+" "+gen.Pick(s, "ValueError", "(TypeError, KeyError)", "Exception as e", "ExceptionGroup as eg", "(OSError) as e")+":")
+		g.block(level+1, hc, d-1)
+	}
+	if !star && s.Chance(0.3) {
+		g.line(level, "except:")
+		g.block(level+1, c, d-1)
+		n++
+	}
+	if n > 0 && s.Chance(0.3) {
+		g.line(level, "else:")
+		g.block(level+1, c, d-1)
+	}
+	if n == 0 || s.Chance(0.3) {
+		g.line(level, "finally:")
+		g.block(level+1, c, d-1)
+	}
+}
+
+// walrus writes statements built around assignment expressions.
+func (g *pygen) walrus(level int, c ctx) {
+	s := g.s
+	a, b := g.assign("w"), g.assign("w")
+	switch s.Intn(6) {
+	case 0:
+		g.line(level, "if ("+a+" := "+g.expr(c, g.depth)+") is not None and ("+b+" := "+a+"):")
+		g.line(level+1, "print("+a+", "+b+")")
+	case 1:
+		g.line(level, "print(("+a+" := 1), ("+b+" := "+a+" + 1))")
+	case 2:
+		g.line(level, "[("+b+" := x) for x in range(3) if ("+a+" := x % 2)]")
+	case 3:
+		g.line(level, a+" = 0")
+		g.line(level, "while ("+b+" := "+a+") < 3:")
+		g.line(level+1, a+" += 1")
+	case 4:
+		g.line(level, "print(f\"{("+a+" := 10)!r:>{5}}\")")
+		g.line(level, b+" = "+a)
+	default:
+		g.line(level, b+" = 1")
+		g.line(level, "("+a+" := lambda: "+b+")()")
+	}
+	if g.broken() {
+		g.line(level, gen.Pick(s, a+" := 1", "f("+a+" := 1 = 2)", "("+a+".x := 1)", "(lambda: x := 1)", "[i := 0 for i in range(3)]", "def f(x := 1): pass"))
+	}
+}
+
+// match writes a match statement with patterns of every kind.
+func (g *pygen) match(level int, c ctx, d int) {
+	s := g.s
+	subject := gen.Pick(s, g.expr(c, g.depth), "command.split()", "(1, 2)", "{'x': 1, 'y': 2}", "Point(1, 2)", "[1, [2, 3]]", "match", "1, 2")
+	if strings.Contains(subject, "command") {
+		g.line(level, "command = 'go north'")
+	}
+	if strings.Contains(subject, "Point") {
+		g.line(level, "@dataclasses.dataclass")
+		g.line(level, "class Point:")
+		g.line(level+1, "x: int = 0")
+		g.line(level+1, "y: int = 0")
+	}
+	if subject == "match" {
+		g.line(level, "match = [1]")
+	}
+	g.line(level, "match "+subject+":")
+	n := s.Range(1, 5)
+	for i := range n {
+		p := g.pattern(2)
+		switch {
+		case i == n-1 && s.Chance(0.5):
+			p = gen.Pick(s, "_", "other")
+		case i < n-1 && irrefutable.MatchString(p):
+			p = g.literal() // a case that matches anything must come last
+		}
+		guard := ""
+		if s.Chance(0.2) {
+			guard = " if " + g.expr(c, g.depth-1)
+		}
+		g.line(level+1, "case "+p+guard+":")
+		g.block(level+2, c, d-1)
+	}
+}
+
+// irrefutable matches a pattern that matches anything.
+var irrefutable = regexp.MustCompile(`^\(*(_|p\d+)\)*( as cap\d+\)*)*$`)
+
+// literal returns a pattern that binds no name.
+func (g *pygen) literal() string {
+	return gen.Pick(g.s, "1", "-1", "1 + 2j", "'s'", "b'b'", "None", "True", "Color.RED", "1.5", `"a" "b"`, "-0.0 - 1j")
+}
+
+// pattern returns a pattern at most d deep.
+func (g *pygen) pattern(d int) string {
+	s := g.s
+	if d <= 0 {
+		if s.Chance(0.3) {
+			return gen.Pick(s, "_", s.Fresh("p"))
+		}
+		return g.literal()
+	}
+	switch s.Intn(9) {
+	case 0:
+		return "[" + g.pattern(d-1) + ", *" + gen.Pick(s, "_", g.s.Fresh("rest")) + "]"
+	case 1:
+		return "(" + g.pattern(d-1) + ", " + g.pattern(d-1) + gen.Pick(s, ")", ",)")
+	case 2:
+		return "{" + gen.Pick(s, "'x'", "1", "None", "Color.RED") + ": " + g.pattern(d-1) + gen.Pick(s, "", ", **"+g.s.Fresh("kw")) + "}"
+	case 3:
+		return gen.Pick(s, "Point", "int", "str", "dataclasses.Field", "list") + "(" + gen.Pick(s, "", g.pattern(d-1), "x="+g.pattern(d-1), g.pattern(d-1)+", y="+g.pattern(d-1)) + ")"
+	case 4:
+		// The alternatives must bind the same names, and these bind none.
+		alts := []string{g.literal()}
+		for range s.Range(1, 3) {
+			alts = append(alts, g.literal())
+		}
+		return strings.Join(alts, " | ")
+	case 5:
+		return "(" + g.pattern(d-1) + ") as " + g.s.Fresh("cap")
+	case 6:
+		return "[" + gen.Pick(s, "", "*_", "1, 2, *_", g.pattern(d-1)+",") + "]"
+	case 7:
+		if g.broken() {
+			return gen.Pick(s, "x | y", "*a, *b", "{**_}", "1 + 1", "f(x)", "{x: 1}", "[x, x]")
+		}
+	}
+	return g.pattern(0)
+}
+
+// fstrings writes assignments of f-strings and uses them.
+func (g *pygen) fstrings(level int, c ctx) {
+	s := g.s
+	g.line(level, "w, p = 10, 3")
+	for range s.Range(1, 3) {
+		g.line(level, g.assign("fs")+" = "+g.fstring(c, g.depth))
+	}
+	g.line(level, gen.Pick(s,
+		`print(f"{'nested' + f'{"again" + f"{'deep'}"}'}")`,
+		`print(f"{w=}, {p = }, {w!r:>{p}}, {3.14159:{w}.{p}}")`,
+		`print(f"{'\n'.join(['a', 'b'])}")`,
+		`print(f"""{
+    w
+    +
+    p
+}""")`,
+		`print(f'{"{"}{"}"}{{}}')`,
+		`print(rf"\d{w}\{{p}}")`,
+		`print(f"{w:{'>'}{p}}")`,
+		`print(f"{f"{f"{f"{f"{1}"}"}"}"}")`,
+		`print(f"{ {'k': 1}['k'] }")`,
+		`print(f"{(lambda: 'l')()}" f'{w}' "plain")`,
+	))
+}
+
+// decorated writes functions and classes under stacks of decorators.
+func (g *pygen) decorated(level int) {
+	s := g.s
+	deco := g.assign("deco")
+	g.line(level, "def "+deco+"(*args, **kwargs):")
+	g.line(level+1, "return args[0] if args and callable(args[0]) else (lambda f: f)")
+	for range s.Range(1, 4) {
+		g.line(level, "@"+gen.Pick(s,
+			deco,
+			deco+"()",
+			deco+"(1, key=2)",
+			"(lambda f: f)",
+			"["+deco+"][0]",
+			"{'d': "+deco+"}['d']",
+			deco+" if True else None",
+			"("+g.assign("w")+" := "+deco+")",
+			deco+".__call__",
+			deco+"(*[], **{})",
+		))
+	}
+	switch s.Intn(3) {
+	case 0:
+		g.function(level, ctx{}, 1)
+	case 1:
+		g.line(level, "async def "+g.assign("af")+"():")
+		g.line(level+1, "return [x async for x in aiter_(range(2))]")
+		g.async()
+	default:
+		g.class(level)
+	}
+}
+
+// params returns a parameter list.
+func (g *pygen) params() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, "x, x", "x=1, y", "*, ", "/, x", "**k, *a", "x, /, /", "*a, *b")
+	}
+	return gen.Pick(s, "", "x", "x, y=1", "*args, **kwargs", "x, /, y, *, z=2", "a, b=2, /, *c, d, e=5, **f",
+		"x: int = 1, *, y: 'str' = ''", "x: list[int] = [], /", "*, key", "x=(1, 2)")
+}
+
+// function writes a function definition, async or a generator now and
+// then.
+func (g *pygen) function(level int, c ctx, d int) {
+	s := g.s
+	async := s.Chance(0.3)
+	head := "def "
+	if async {
+		head = "async def "
+	}
+	name := g.assign("fn")
+	tparams := ""
+	if s.Chance(0.2) {
+		tparams = gen.Pick(s, "[T]", "[T: int]", "[T: (int, str), *Ts, **P]", "[K, V]")
+	}
+	ret := ""
+	if s.Chance(0.3) {
+		ret = " -> " + gen.Pick(s, "None", "int", "'Fwd'", "tuple[int, ...]", "object")
+	}
+	g.line(level, head+name+tparams+"("+g.params()+")"+ret+":")
+	if s.Chance(0.3) {
+		g.line(level+1, gen.Pick(s, `"""Docstring."""`, `'''Multi
+`+strings.Repeat(g.indent, level+1)+`line.'''`))
+	}
+	inner := ctx{async: async, fn: true, yield: !async}
+	if s.Chance(0.3) {
+		g.line(level+1, gen.Pick(s, "global "+g.assign("gl"), "x = 1"))
+	}
+	g.block(level+1, inner, d-1)
+}
+
+// class writes a class definition.
+func (g *pygen) class(level int) {
+	s := g.s
+	name := g.assign("C")
+	bases := gen.Pick(s, "", "()", "(object)", "(int, metaclass=type)", "(*[object])", "(**{})", "(Exception)")
+	tparams := ""
+	if s.Chance(0.2) {
+		tparams = gen.Pick(s, "[T]", "[T: int, *Ts]", "[**P]")
+	}
+	g.line(level, "class "+name+tparams+bases+":")
+	g.line(level+1, gen.Pick(s, "x: int = 0", `"""Docstring."""`, "__slots__ = ()", "pass", "match = case = type = 1"))
+	if s.Chance(0.5) {
+		g.line(level+1, "def __init__(self, *a, **k):")
+		g.line(level+2, "super().__init__()")
+	}
+	if s.Chance(0.5) {
+		g.line(level+1, "@property")
+		g.line(level+1, "def p(self) -> int: return self.__private if hasattr(self, '_"+name+"__private') else 0")
+	}
+	if s.Chance(0.5) {
+		g.line(level+1, "async def __aenter__(self): return self")
+		g.line(level+1, "async def __aexit__(self, *exc): return False")
+	}
+	if s.Chance(0.3) {
+		g.line(level+1, "class Inner: match = 1; case = match")
+	}
+}
+
+// async writes coroutines that await in comprehensions, async for and
+// async with, and the helpers they use.
+func (g *pygen) async() {
+	s := g.s
+	if !strings.Contains(g.b.String(), "async def aiter_(") {
+		g.line(0, "async def aiter_(it):")
+		g.line(1, "for x in it:")
+		g.line(2, "await asyncio.sleep(0)")
+		g.line(2, "yield x")
+		g.line(0, "class actx:")
+		g.line(1, "async def __aenter__(self): return self")
+		g.line(1, "async def __aexit__(self, *exc): pass")
+	}
+	name := "main"
+	if strings.Contains(g.b.String(), "async def main(") {
+		name = g.assign("coro")
+	}
+	g.line(0, "async def "+name+"():")
+	c := ctx{async: true, fn: true}
+	for range s.Range(1, 4) {
+		switch s.Intn(5) {
+		case 0:
+			g.line(1, "r = "+gen.Pick(s,
+				"[x async for x in aiter_(range(3)) if await asyncio.sleep(0, x)]",
+				"{k: v async for k, v in aiter_({1: 2}.items())}",
+				"{x async for x in aiter_('ab')}",
+				"[await asyncio.sleep(0, x) for x in range(2)]",
+				"[y for x in [1] async for y in aiter_([x])]",
+				"(x async for x in aiter_([1]))",
+				"[x async for x in aiter_([1]) if (y := await asyncio.sleep(0, x))]",
+			))
+		case 1:
+			g.line(1, "async for "+gen.Pick(s, "x", "x, y", "_")+" in aiter_([(1, 2)]):")
+			g.block(2, ctx{async: true, fn: true, loop: true}, 1)
+		case 2:
+			g.line(1, "async with "+gen.Pick(s, "actx() as a", "actx() as a, actx() as b", "(actx() as a, actx())")+":")
+			g.block(2, c, 1)
+		case 3:
+			g.line(1, "await asyncio.gather(*[asyncio.sleep(0) for _ in range(2)])")
+		default:
+			g.stmt(1, c, g.blocks)
+		}
+	}
+	if g.broken() {
+		g.line(0, gen.Pick(s, "await x", "def f(): await x", "def f(): [x async for x in y]", "async def f(): yield from x", "async with x: pass"))
+	}
+}
+
+// typing writes type aliases and generic declarations.
+func (g *pygen) typing() {
+	s := g.s
+	g.line(0, gen.Pick(s,
+		"type "+g.assign("Alias")+" = list[int | None]",
+		"type "+g.assign("Alias")+"[T] = dict[str, T]",
+		"type "+g.assign("Alias")+"[*Ts, **P] = tuple[*Ts]",
+		"type = 1; type("+"type"+")",
+		g.assign("ann")+": dict[str, list[tuple[int, ...]]] = {}",
+		g.assign("ann")+": 'Forward' ",
+		"def "+g.assign("gen")+"[T: (int, str)](x: T, *args: *tuple[int, ...]) -> T: return x",
+		"class "+g.assign("G")+"[T]: ...",
+	))
+}
+
+// hazard writes the statements the tokenizer and the grammar make hard:
+// line continuations, soft keywords as names, and the like.
+func (g *pygen) hazard(level int) {
+	s := g.s
+	a := g.assign("h")
+	hazards := []string{
+		a + " = 1 + \\\n    2",
+		a + " = (1 +\n2)",
+		a + " = [\n1,\n    2,\n        3,\n]",
+		"match = 1\nmatch * 2\nmatch(-1) if callable(match) else match",
+		"case = [1]\ncase[0]",
+		"print(match := 3)",
+		a + " = 1 if True else 2 if False else 3",
+		a + " = not 1 == 2 < 3 is not None",
+		a + " = -1 ** 2, (-1) ** 2, 2 ** -1",
+		a + " = 'a' 'b' f'{1}' \"c\"",
+		a + " = [*range(2), *'ab'], {**{}, 'a': 1}, (*[1],)",
+		a + ", *_ = 1, 2, 3",
+		a + " = x = y = 1",
+		a + " = lambda: (yield)",
+		a + " = 1; " + a + " += 1; del " + a,
+		a + " = 1_000.000_1e-1_0",
+		"if 1: pass\nelif 2: pass\nelse: pass",
+		"for _ in []: pass\nelse: " + a + " = 1",
+		"while False: pass\nelse: pass",
+		"ﬁle = 1; print(file)",
+		a + " = " + "\"\"\"\\\n\"\"\"",
+		"global " + a + "; " + a + " = 1",
+		a + " = [i for i in range(3) if i if not i]",
+		a + " = __import__('sys').version_info >= (3, 12)",
+		a + " = ()[:] or [][:] or ''[::]",
+		"assert (" + a + " := 1), 'message'",
+		"print(*(), **{}, sep='', end='\\n')",
+		"exec('pass'); eval('1')",
+		a + " = \\\n1",
+	}
+	g.line(level, gen.Pick(s, hazards...))
+	if g.broken() {
+		g.line(level, gen.Pick(s, "if True:\npass", "  x = 1", "if 1:\n\tpass\n        pass", "x = (1,\n", "print 'py2'", "exec 'py2'", "x = 1 +", "return 1", "break", "lambda: (x := 1) = 2", "def f(): nonlocal x", "x = 1 if 2", "x = `1`", "0777", "a = b = := 1"))
+	}
+}
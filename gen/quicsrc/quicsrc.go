@@ -0,0 +1,614 @@
+// Package quicsrc generates QUIC seeds. It registers the "quic/..."
+// generators with package gen.
+//
+// "quic/initial" writes what a client sends to open a connection: Initial
+// packets whose CRYPTO frames carry a TLS 1.3 ClientHello with the
+// client's transport parameters, now and then coalesced with Handshake
+// and 0-RTT packets, packets of other versions and other connections.
+// Packets are written as a server reads them once it has removed their
+// protection, with the reserved bits and packet number in the clear and
+// the payload unencrypted. CRYPTO frames split the ClientHello at any
+// byte, out of order, overlapping and repeated, some at offsets no
+// server buffers; "quic/params" writes a transport parameter blob alone.
+// Variable-length integers are drawn at the edges of each encoded length
+// and now and then in more bytes than they need, and, rarely, lengths
+// run wrong.
+package quicsrc
+
+import (
+	"crypto/mlkem"
+	"math/bits"
+	"slices"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "quic/initial",
+		Doc:  "QUIC client Initial packets: ClientHellos with transport parameters in CRYPTO frames split, reordered and overlapping, coalesced Handshake and 0-RTT packets, odd versions, connection IDs and variable-length integers",
+		Func: initial,
+	})
+	gen.Register(&gen.Generator{
+		Name: "quic/params",
+		Doc:  "QUIC transport parameter blobs: every RFC 9000 parameter and later ones, values at variable-length integer edges and out of range, overlong encodings, reserved and duplicate parameters",
+		Func: params,
+	})
+}
+
+// badRate is the chance that a length is drawn wrong. An Initial flight
+// has about eighty lengths, in its packets, frames, ClientHello and
+// transport parameters, so about a third of them get one.
+const badRate = 0.005
+
+// Long header packet types, and the versions a client may try.
+const (
+	typeInitial   = 0
+	typeZeroRTT   = 1
+	typeHandshake = 2
+	typeRetry     = 3
+
+	version1 = 0x00000001
+)
+
+// versions are versions other than QUIC version 1: version negotiation,
+// QUIC version 2, a draft, and a reserved one of the form 0x?a?a?a?a,
+// which servers must answer with version negotiation.
+var versions = []uint32{0, 0x6b3343cf, 0xff00001d, 0x1a2a3a4a}
+
+// Transport parameter IDs: those of RFC 9000, version_information of
+// RFC 9368, max_datagram_frame_size of RFC 9221, grease_quic_bit of RFC
+// 9287 and min_ack_delay of the acknowledgment frequency draft.
+const (
+	paramOriginalDCID     = 0x00
+	paramIdleTimeout      = 0x01
+	paramResetToken       = 0x02
+	paramMaxUDPPayload    = 0x03
+	paramMaxData          = 0x04
+	paramMaxStreamDataBL  = 0x05
+	paramMaxStreamDataBR  = 0x06
+	paramMaxStreamDataUni = 0x07
+	paramMaxStreamsBidi   = 0x08
+	paramMaxStreamsUni    = 0x09
+	paramAckDelayExponent = 0x0a
+	paramMaxAckDelay      = 0x0b
+	paramNoMigration      = 0x0c
+	paramPreferredAddress = 0x0d
+	paramCIDLimit         = 0x0e
+	paramInitialSCID      = 0x0f
+	paramRetrySCID        = 0x10
+	paramVersionInfo      = 0x11
+	paramMaxDatagram      = 0x20
+	paramGreaseQUICBit    = 0x2ab2
+	paramMinAckDelay      = 0xff04de1b
+)
+
+// TLS extension types.
+const (
+	extServerName       = 0
+	extGroups           = 10
+	extSigAlgs          = 13
+	extALPN             = 16
+	extPadding          = 21
+	extPSK              = 41
+	extEarlyData        = 42
+	extSupportedVersion = 43
+	extPSKModes         = 45
+	extKeyShare         = 51
+	extQUICParams       = 0x39
+	extQUICParamsDraft  = 0xffa5
+)
+
+// mlkem768 is an ML-KEM-768 encapsulation key made from a fixed seed,
+// whose coefficients random bytes almost never keep in range.
+var mlkem768 = func() []byte {
+	k, err := mlkem.NewDecapsulationKey768(make([]byte, mlkem.SeedSize))
+	if err != nil {
+		panic(err)
+	}
+	return k.EncapsulationKey().Bytes()
+}()
+
+// p256Point is the base point of P-256, uncompressed, the public key of
+// the private key 1.
+var p256Point = []byte{
+	0x04,
+	0x6b, 0x17, 0xd1, 0xf2, 0xe1, 0x2c, 0x42, 0x47, 0xf8, 0xbc, 0xe6, 0xe5, 0x63, 0xa4, 0x40, 0xf2,
+	0x77, 0x03, 0x7d, 0x81, 0x2d, 0xeb, 0x33, 0xa0, 0xf4, 0xa1, 0x39, 0x45, 0xd8, 0x98, 0xc2, 0x96,
+	0x4f, 0xe3, 0x42, 0xe2, 0xfe, 0x1a, 0x7f, 0x9b, 0x8e, 0xe7, 0xeb, 0x4a, 0x7c, 0x0f, 0x9e, 0x16,
+	0x2b, 0xce, 0x33, 0x57, 0x6b, 0x31, 0x5e, 0xce, 0xcb, 0xb6, 0x40, 0x68, 0x37, 0xbf, 0x51, 0xf5,
+}
+
+// A qgen draws the packets of one seed.
+type qgen struct {
+	s *gen.State
+}
+
+// bytes returns n random bytes.
+func (q *qgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(q.s.Intn(256))
+	}
+	return b
+}
+
+// varint returns v as a variable-length integer, most of the time in
+// the fewest bytes it fits in and now and then in more, which a reader
+// must take all the same. v must be less than 2^62.
+func (q *qgen) varint(v uint64) []byte {
+	n := 1
+	for n < 8 && v >= 1<<(8*n-2) {
+		n *= 2
+	}
+	if n < 8 && q.s.Chance(0.05) {
+		n = max(n, gen.Pick(q.s, 2, 4, 8))
+	}
+	return encode(v, n)
+}
+
+// encode returns v as a variable-length integer of n bytes.
+func encode(v uint64, n int) []byte {
+	b := make([]byte, n)
+	for i := range n {
+		b[n-1-i] = byte(v >> (8 * i))
+	}
+	b[0] |= byte(bits.Len(uint(n))-1) << 6
+	return b
+}
+
+// edge returns a value at an edge of the encoded lengths of a
+// variable-length integer, or a small one.
+func (q *qgen) edge() uint64 {
+	return gen.Pick(q.s, 0, 1, 63, 64, 16383, 16384, 1<<30-1, 1<<30, 1<<62-1, uint64(q.s.Intn(1<<16)))
+}
+
+// vec returns body preceded by its length as a variable-length integer,
+// now and then a length past the end of body or short of it.
+func (q *qgen) vec(body ...[]byte) []byte {
+	b := slices.Concat(body...)
+	n := uint64(len(b))
+	if q.s.Chance(badRate) {
+		n = gen.Pick(q.s, n+1, n+100, max(n, 1)-1, 1<<62-1)
+	}
+	return append(q.varint(n), b...)
+}
+
+// tvec returns body preceded by its length in size bytes, as TLS writes
+// vectors, now and then a length past the end of body or short of it.
+func (q *qgen) tvec(size int, body ...[]byte) []byte {
+	b := slices.Concat(body...)
+	n := len(b)
+	if q.s.Chance(badRate) {
+		n = gen.Pick(q.s, n+1, max(n-1, 0), 1<<(8*size)-1)
+	}
+	l := []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	return append(l[3-size:], b...)
+}
+
+// u16 returns v big-endian in two bytes.
+func u16(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
+
+// params writes a transport parameter blob.
+func params(s *gen.State) []gen.File {
+	q := &qgen{s: s}
+	return []gen.File{{Name: "params.qtp", Data: q.params(q.bytes(8))}}
+}
+
+// params returns a client's transport parameters, the initial source
+// connection ID most of the time scid, in a random order. Values are
+// the usual ones, ones at the edges of variable-length integers, and,
+// now and then, ones out of range for their parameter or parameters
+// only a server may send.
+func (q *qgen) params(scid []byte) []byte {
+	s := q.s
+	var ps [][]byte
+	add := func(p float64, id uint64, value func() []byte) {
+		if s.Chance(p) {
+			v := value()
+			ps = append(ps, slices.Concat(q.varint(id), q.vec(v)))
+		}
+	}
+	num := func(usual ...uint64) func() []byte {
+		return func() []byte {
+			if s.Chance(0.3) {
+				return q.varint(q.edge())
+			}
+			return q.varint(gen.Pick(s, usual...))
+		}
+	}
+	add(0.7, paramIdleTimeout, num(30000, 0, 600000))
+	add(0.6, paramMaxUDPPayload, num(1452, 1472, 65527, 1200, 1199))
+	add(0.9, paramMaxData, num(1<<20, 15<<20, 0))
+	add(0.8, paramMaxStreamDataBL, num(1<<20, 6<<20, 0))
+	add(0.8, paramMaxStreamDataBR, num(1<<20, 6<<20, 0))
+	add(0.8, paramMaxStreamDataUni, num(1<<20, 6<<20, 0))
+	add(0.8, paramMaxStreamsBidi, num(100, 0, 1<<60, 1<<60+1))
+	add(0.8, paramMaxStreamsUni, num(100, 3, 1<<60, 1<<60+1))
+	add(0.3, paramAckDelayExponent, num(3, 0, 20, 21))
+	add(0.3, paramMaxAckDelay, num(25, 0, 1<<14-1, 1<<14))
+	add(0.2, paramNoMigration, func() []byte {
+		if s.Chance(0.1) {
+			return []byte{0}
+		}
+		return nil
+	})
+	add(0.5, paramCIDLimit, num(2, 4, 8, 0, 1))
+	add(0.95, paramInitialSCID, func() []byte {
+		if s.Chance(0.05) {
+			return q.bytes(gen.Pick(s, 0, 8, 20, 21))
+		}
+		return scid
+	})
+	add(0.3, paramMaxDatagram, num(65536, 1200, 0))
+	add(0.2, paramGreaseQUICBit, func() []byte { return gen.Pick(s, nil, nil, nil, []byte{1}) })
+	add(0.2, paramMinAckDelay, num(1000, 0, 25000, 25001))
+	add(0.2, paramVersionInfo, func() []byte {
+		chosen := gen.Pick(s, version1, version1, version1, 0, versions[1])
+		b := be32(chosen)
+		for range s.Range(0, 3) {
+			b = append(b, be32(gen.Pick(s, slices.Concat(versions, []uint32{version1, version1})...))...)
+		}
+		if s.Chance(0.05) {
+			b = append(b, 0xff) // not a whole version
+		}
+		return b
+	})
+	// Parameters only a server may send.
+	add(0.02, paramOriginalDCID, func() []byte { return q.bytes(8) })
+	add(0.02, paramResetToken, func() []byte { return q.bytes(gen.Pick(s, 16, 15)) })
+	add(0.02, paramRetrySCID, func() []byte { return q.bytes(8) })
+	add(0.02, paramPreferredAddress, func() []byte {
+		return slices.Concat(q.bytes(4+2+16+2), []byte{8}, q.bytes(8), q.bytes(16))
+	})
+	// Reserved parameters, 31 * N + 27, which a reader must ignore.
+	for range gen.Pick(s, 0, 0, 1, 2) {
+		add(1, 31*uint64(s.Intn(1<<20))+27, func() []byte { return q.bytes(s.Range(0, 16)) })
+	}
+	// An unknown parameter, which a reader must ignore too.
+	add(0.1, uint64(s.Range(0x21, 0x3fff)), func() []byte { return q.bytes(s.Range(0, 8)) })
+	gen.Shuffle(s, ps)
+	if len(ps) > 0 && s.Chance(0.03) {
+		ps = append(ps, ps[s.Intn(len(ps))]) // a duplicate
+	}
+	b := slices.Concat(ps...)
+	if s.Chance(0.01) {
+		b = append(b, q.bytes(s.Range(1, 3))...) // a parameter cut short
+	}
+	return b
+}
+
+// be32 returns v big-endian in four bytes.
+func be32(v uint32) []byte { return []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)} }
+
+// ext returns a TLS extension of type typ.
+func (q *qgen) ext(typ int, body ...[]byte) []byte {
+	return append(u16(typ), q.tvec(2, body...)...)
+}
+
+// clientHello returns a TLS 1.3 ClientHello carrying params, with the
+// extensions a QUIC client sends in a random order, a key share for the
+// first of its groups unless retry, which offers none so that a server
+// asks again, and now and then a PSK it cannot know.
+func (q *qgen) clientHello(params []byte, retry bool) []byte {
+	s := q.s
+	suites := []byte{0x13, 0x01, 0x13, 0x02, 0x13, 0x03}
+	if s.Chance(0.03) {
+		suites = gen.Pick(s, []byte{0x13, 0x04}, []byte{0x13, 0x05}, []byte{0xc0, 0x2b}, []byte{0x0a, 0x0a, 0x13, 0x01})
+	}
+	id := 0
+	if s.Chance(0.05) {
+		// QUIC has no use for the middlebox compatibility session ID.
+		id = 32
+	}
+	group := gen.Pick(s, 0x001d, 0x001d, 0x11ec, 0x0017)
+	groups := u16(group)
+	if s.Chance(0.5) {
+		groups = append(groups, u16(gen.Pick(s, 0x001d, 0x0017, 0x0018, 0x11ec, 0x0a0a))...)
+	}
+	var share []byte
+	switch group {
+	case 0x001d:
+		share = q.bytes(32)
+		if s.Chance(0.02) {
+			// Points of small order, whose shared secret is all zeros.
+			share = gen.Pick(s, make([]byte, 32), append([]byte{1}, make([]byte, 31)...))
+		}
+	case 0x11ec:
+		share = slices.Concat(mlkem768, q.bytes(32))
+	case 0x0017:
+		share = p256Point
+	}
+	if s.Chance(0.02) {
+		share = q.bytes(gen.Pick(s, 0, 1, 31, 33))
+	}
+	var shares []byte
+	if !retry {
+		shares = slices.Concat(u16(group), q.tvec(2, share))
+	}
+
+	var exts [][]byte
+	add := func(p float64, e func() []byte) {
+		if s.Chance(p) {
+			exts = append(exts, e())
+		}
+	}
+	add(0.8, func() []byte {
+		return q.ext(extServerName, q.tvec(2, []byte{0}, q.tvec(2, []byte(gen.Pick(s, "example.com", "example.com", "localhost", "127.0.0.1")))))
+	})
+	add(0.97, func() []byte { return q.ext(extGroups, q.tvec(2, groups)) })
+	add(0.97, func() []byte {
+		// ECDSA with P-256, for the server's key, and now and then only
+		// schemes it cannot sign with.
+		algs := gen.Pick(s, []byte{4, 3, 8, 4, 8, 7}, []byte{4, 3})
+		if s.Chance(0.03) {
+			algs = gen.Pick(s, []byte{8, 7}, []byte{2, 1})
+		}
+		return q.ext(extSigAlgs, q.tvec(2, algs))
+	})
+	add(0.97, func() []byte {
+		var protos [][]byte
+		n := 1
+		if s.Chance(0.05) {
+			n = gen.Pick(s, 0, 2, 3)
+		}
+		for range n {
+			proto := "h3"
+			if s.Chance(0.05) {
+				proto = gen.Pick(s, "h3-29", "hq-interop", "h2", "")
+			}
+			protos = append(protos, q.tvec(1, []byte(proto)))
+		}
+		return q.ext(extALPN, q.tvec(2, protos...))
+	})
+	add(0.97, func() []byte {
+		vs := gen.Pick(s, []byte{3, 4}, []byte{3, 4}, []byte{0x0a, 0x0a, 3, 4})
+		if s.Chance(0.03) {
+			// Versions QUIC may not offer.
+			vs = gen.Pick(s, []byte{3, 4, 3, 3}, []byte{3, 3}, []byte{0x7f, 0x1c})
+		}
+		return q.ext(extSupportedVersion, q.tvec(1, vs))
+	})
+	add(0.97, func() []byte { return q.ext(extKeyShare, q.tvec(2, shares)) })
+	add(0.6, func() []byte { return q.ext(extPSKModes, q.tvec(1, gen.Pick(s, []byte{1}, []byte{1}, []byte{0}))) })
+	add(0.03, func() []byte { return q.ext(extEarlyData) })
+	add(0.1, func() []byte { return q.ext(extPadding, make([]byte, s.Range(0, 200))) })
+	add(0.2, func() []byte { return q.ext(gen.Pick(s, 0x0a0a, 0x1a1a, 0xfafa), gen.Pick(s, nil, []byte{0})) })
+	switch {
+	case s.Chance(0.95):
+		exts = append(exts, q.ext(extQUICParams, params))
+	case s.Chance(0.5):
+		// The code point of the drafts, which version 1 does not use.
+		exts = append(exts, q.ext(extQUICParamsDraft, params))
+	case s.Chance(0.5):
+		exts = append(exts, q.ext(extQUICParams, params), q.ext(extQUICParams, params))
+	}
+	gen.Shuffle(s, exts)
+	if s.Chance(0.05) {
+		// A PSK the server cannot know, last where it must be, with a
+		// binder of the length one made with SHA-256 would have.
+		exts = append(exts, q.ext(extPSK,
+			q.tvec(2, q.tvec(2, q.bytes(s.Range(1, 64))), q.bytes(4)),
+			q.tvec(2, q.tvec(1, q.bytes(32))),
+		))
+	}
+
+	body := slices.Concat(u16(0x0303), q.bytes(32), q.tvec(1, q.bytes(id)), q.tvec(2, suites), q.tvec(1, []byte{0}), q.tvec(2, exts...))
+	return append([]byte{1}, q.tvec(3, body)...)
+}
+
+// crypto returns a CRYPTO frame of data at off. Its type and length are
+// right, since a flight may have thousands of them; flight gets one
+// wrong now and then.
+func (q *qgen) crypto(off uint64, data []byte) []byte {
+	return slices.Concat([]byte{0x06}, q.varint(off), q.varint(uint64(len(data))), data)
+}
+
+// frameType returns the frame type typ, which must be in the fewest
+// bytes it fits in but, rarely, is not.
+func (q *qgen) frameType(typ uint64) []byte {
+	if q.s.Chance(badRate) {
+		return encode(typ, gen.Pick(q.s, 2, 4, 8))
+	}
+	return encode(typ, 1)
+}
+
+// flight returns the Initial packets that carry stream, which starts at
+// base in the crypto stream, in CRYPTO frames: most of the time in order,
+// each once and padded, and now and then split small, out of order,
+// repeated with other boundaries, or with a frame far past the end of
+// anything a server buffers.
+func (q *qgen) flight(c *conn, version uint32, stream []byte, base int) []byte {
+	s := q.s
+	size := gen.Pick(s, 1100, 1100, 1100, 500, 64, 1, 1<<14)
+	var fs [][]byte
+	for off := 0; off < len(stream); {
+		n := min(len(stream)-off, s.Range(max(size/2, 1), size))
+		fs = append(fs, q.crypto(uint64(base+off), stream[off:off+n]))
+		off += n
+	}
+	if s.Chance(0.1) {
+		// Data sent again, cut at other bytes.
+		off := s.Intn(len(stream))
+		end := min(len(stream), off+s.Range(1, 600))
+		fs = append(fs, q.crypto(uint64(base+off), stream[off:end]))
+	}
+	if s.Chance(0.05) {
+		fs = append(fs, q.crypto(uint64(base+s.Intn(len(stream)+1)), nil))
+	}
+	if s.Chance(0.02) {
+		// Data at an offset no server keeps, up to the largest a stream
+		// may reach.
+		off := gen.Pick(s, uint64(1<<14), uint64(1<<20), 1<<62-1-uint64(len(stream)), 1<<62-1)
+		fs = append(fs, q.crypto(off, stream[:min(len(stream), 16)]))
+	}
+	if s.Chance(0.2) {
+		gen.Shuffle(s, fs)
+	}
+	if s.Chance(badRate * 4) {
+		// A frame cut short, whose length runs into what follows it.
+		i := s.Intn(len(fs))
+		fs[i] = fs[i][:len(fs[i])-1]
+	}
+
+	// Small frames go many to a packet, and packets are padded only when
+	// they carry a frame of size to pad.
+	per := []int{1, 1, 1, 2, 3}
+	if size < 100 {
+		per = []int{10, 40, 100}
+	}
+	var out []byte
+	for len(fs) > 0 {
+		n := min(len(fs), gen.Pick(s, per...))
+		var payload []byte
+		if s.Chance(0.1) {
+			payload = append(payload, q.frameType(0x01)...) // PING
+		}
+		payload = append(payload, slices.Concat(fs[:n]...)...)
+		fs = fs[n:]
+		if s.Chance(0.02) {
+			payload = append(payload, q.forbidden()...)
+		}
+		if size >= 500 {
+			payload = q.pad(payload)
+		}
+		out = append(out, q.packet(c, typeInitial, version, payload)...)
+	}
+	return out
+}
+
+// A conn is the connection IDs and next packet number of the packets a
+// client sends on one connection.
+type conn struct {
+	dcid, scid []byte
+	pn         uint64
+}
+
+// packet returns a long header packet of type typ carrying payload,
+// with its protection removed: the reserved bits are zeros but rarely,
+// and the packet number, whose length is drawn, is in the clear.
+func (q *qgen) packet(c *conn, typ int, version uint32, payload []byte) []byte {
+	s := q.s
+	pnLen := gen.Pick(s, 1, 2, 2, 4)
+	if s.Chance(0.05) {
+		c.pn += uint64(gen.Pick(s, 0, 1000, 1<<16))
+	}
+	first := byte(0xc0 | typ<<4 | (pnLen - 1))
+	if s.Chance(badRate) {
+		first |= byte(s.Range(1, 3)) << 2
+	}
+	if s.Chance(badRate) {
+		first &^= 0x40 // the fixed bit
+	}
+	b := append([]byte{first}, be32(version)...)
+	for _, id := range [][]byte{c.dcid, c.scid} {
+		if s.Chance(badRate) {
+			// A connection ID longer than version 1 allows.
+			b = append(b, byte(s.Range(21, 255)))
+		}
+		b = append(b, byte(len(id)))
+		b = append(b, id...)
+	}
+	if typ == typeInitial {
+		var token []byte
+		if s.Chance(0.05) {
+			token = q.bytes(s.Range(1, 80))
+		}
+		b = append(b, q.vec(token)...)
+	}
+	pn := make([]byte, pnLen)
+	for i := range pn {
+		pn[pnLen-1-i] = byte(c.pn >> (8 * i))
+	}
+	c.pn++
+	return append(b, q.vec(pn, payload)...)
+}
+
+// pad returns payload padded, most of the time, so that its packet
+// fills the 1200 bytes a client's Initial datagram must, which the
+// generator counts without the packet's header.
+func (q *qgen) pad(payload []byte) []byte {
+	if len(payload) < 1160 && q.s.Chance(0.6) {
+		return append(payload, make([]byte, 1160-len(payload))...)
+	}
+	return payload
+}
+
+// initial writes a client's first flight.
+func initial(s *gen.State) []gen.File {
+	q := &qgen{s: s}
+	c := &conn{dcid: q.bytes(8), scid: q.bytes(8)}
+	if s.Chance(0.2) {
+		c.dcid = q.bytes(gen.Pick(s, 9, 16, 20, 0, 7))
+	}
+	if s.Chance(0.2) {
+		c.scid = q.bytes(gen.Pick(s, 0, 4, 20))
+	}
+	version := uint32(version1)
+	if s.Chance(0.03) {
+		version = gen.Pick(s, versions...)
+	}
+	retry := s.Chance(0.05)
+	stream := q.clientHello(q.params(c.scid), retry)
+	out := q.flight(c, version, stream, 0)
+	if retry {
+		// A second ClientHello, in packets of its own, for the server to
+		// read after it asked for one with a share for P-256.
+		again := q.clientHello(q.params(c.scid), false)
+		out = append(out, q.flight(c, version, again, len(stream))...)
+	}
+
+	if s.Chance(0.1) {
+		// A Handshake packet with a Finished the client cannot have
+		// computed, as if it had the server's flight.
+		fin := append([]byte{20}, q.tvec(3, q.bytes(gen.Pick(s, 32, 32, 48, 0)))...)
+		hc := &conn{dcid: c.dcid, scid: c.scid}
+		out = append(out, q.packet(hc, typeHandshake, version1, q.crypto(0, fin))...)
+	}
+	if s.Chance(0.1) {
+		// 0-RTT data, which a server without the keys drops.
+		zc := &conn{dcid: c.dcid, scid: c.scid}
+		frame := slices.Concat(q.frameType(0x0b), q.varint(0), q.vec([]byte("GET /\r\n")))
+		out = append(out, q.packet(zc, typeZeroRTT, version1, frame)...)
+	}
+	if s.Chance(0.05) {
+		// An Initial of another connection, coalesced with this one's.
+		oc := &conn{dcid: q.bytes(8), scid: q.bytes(8)}
+		out = append(out, q.packet(oc, typeInitial, version1, q.pad(q.crypto(0, stream[:min(len(stream), 200)])))...)
+	}
+	if s.Chance(0.02) {
+		out = append(out, q.packet(c, typeRetry, version1, q.bytes(16))...)
+	}
+	if s.Chance(0.03) {
+		// The client gives up: CONNECTION_CLOSE with an error code, the
+		// frame type it was reading and a reason.
+		closing := slices.Concat(q.frameType(0x1c), q.varint(gen.Pick(s, 0, 0x0a, 0x0100+40, q.edge())), q.varint(0x06), q.vec([]byte("bye")))
+		out = append(out, q.packet(c, typeInitial, version1, closing)...)
+	}
+	if s.Chance(0.02) {
+		out = append(out, q.bytes(s.Range(1, 32))...) // bytes after the last packet
+	}
+	return []gen.File{{Name: "client.quic", Data: out}}
+}
+
+// forbidden returns a frame an Initial packet may not carry, or an ACK
+// of a packet the server never sent.
+func (q *qgen) forbidden() []byte {
+	s := q.s
+	switch s.Intn(6) {
+	case 0:
+		// ACK: largest acknowledged, delay, range count and first range.
+		return slices.Concat(q.frameType(0x02), q.varint(q.edge()), q.varint(q.edge()), q.varint(0), q.varint(0))
+	case 1:
+		// STREAM with offset, length and FIN.
+		return slices.Concat(q.frameType(0x0f), q.varint(0), q.varint(0), q.vec([]byte("x")))
+	case 2:
+		return q.frameType(0x1e) // HANDSHAKE_DONE
+	case 3:
+		return slices.Concat(q.frameType(0x07), q.vec(q.bytes(16))) // NEW_TOKEN
+	case 4:
+		return slices.Concat(q.frameType(0x10), q.varint(q.edge())) // MAX_DATA
+	}
+	// A frame type no version defines.
+	return slices.Concat(q.varint(uint64(s.Range(0x40, 0x3fff))), q.bytes(s.Range(0, 8)))
+}
@@ -0,0 +1,275 @@
+// Package regexpsrc generates regular expression seeds. It registers the
+// "regexp/..." generators with package gen.
+//
+// A seed is a pattern, pattern.re, and a subject to match it against,
+// input.txt, drawn from the same alphabet so that matches happen. Most
+// of a pattern is well formed; each construct also has malformed
+// variants, drawn rarely, because a single bad one fails the whole
+// pattern.
+package regexpsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "regexp/perl",
+		Doc:  "Perl-syntax regexps: flag groups, named captures, lazy and counted repeats up to the 1000 limit, nested repetition, Unicode classes, \\Q...\\E and invalid escapes",
+		Func: func(s *gen.State) []gen.File { return seed(s, true) },
+	})
+	gen.Register(&gen.Generator{
+		Name: "regexp/posix",
+		Doc:  "POSIX ERE-syntax regexps for CompilePOSIX's leftmost-longest matching: alternations of overlapping branches, nested repetition and bracket expressions",
+		Func: func(s *gen.State) []gen.File { return seed(s, false) },
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form.
+// A pattern has a few dozen constructs, so about a quarter of patterns
+// get one.
+const badRate = 0.003
+
+// A pattern accumulates a regexp and the alphabet its literals use.
+type pattern struct {
+	s     *gen.State
+	perl  bool
+	b     strings.Builder
+	runes []string // literal text used, for building the subject
+	names int
+}
+
+func seed(s *gen.State, perl bool) []gen.File {
+	p := &pattern{s: s, perl: perl}
+	if perl && s.Chance(0.2) {
+		p.b.WriteString(gen.Pick(s, "(?i)", "(?s)", "(?m)", "(?U)", "(?ims)", "(?i-s)", "(?-m)"))
+	}
+	if s.Chance(0.2) {
+		p.b.WriteString("^")
+	}
+	p.alt(s.Depth(s.Limits.Expr, 5))
+	if s.Chance(0.2) {
+		p.b.WriteString("$")
+	} else if perl && s.Chance(0.1) {
+		p.b.WriteString(`\z`)
+	}
+	return []gen.File{
+		{Name: "pattern.re", Data: []byte(p.b.String())},
+		{Name: "input.txt", Data: []byte(p.subject())},
+	}
+}
+
+func (p *pattern) write(format string, args ...any) {
+	fmt.Fprintf(&p.b, format, args...)
+}
+
+// alt writes an alternation of concatenations, usually of one branch.
+func (p *pattern) alt(depth int) {
+	n := 1
+	if p.s.Chance(0.3) {
+		n = p.s.Range(2, 4)
+	}
+	for i := range n {
+		if i > 0 {
+			p.b.WriteByte('|')
+		}
+		if p.s.Chance(0.05) {
+			continue // an empty branch
+		}
+		p.concat(depth)
+	}
+}
+
+func (p *pattern) concat(depth int) {
+	for range p.s.Range(1, 4) {
+		p.repeat(depth)
+	}
+}
+
+// repeat writes an atom with, sometimes, a repetition operator.
+func (p *pattern) repeat(depth int) {
+	// A repetition operator right after another is rejected; the
+	// backtracking shapes atom writes end in one.
+	if p.atom(depth) || !p.s.Chance(0.35) {
+		return
+	}
+	if p.s.Chance(badRate * 4) {
+		p.write("%s", gen.Pick(p.s, "**", "+*", "{1001}", "{2,1}", "{,3}", "{99999999999}", "{1,1001}"))
+		return
+	}
+	op := gen.Pick(p.s, "*", "+", "?", "*", "+", "{0}", "{1}", "{2}", "{3,}", "{2,5}", "{0,1}", "{1000}", "{0,1000}", "{10}", "{,}", "{")
+	p.write("%s", op)
+	if p.perl && op != "{,}" && op != "{" && p.s.Chance(0.2) {
+		p.b.WriteByte('?') // lazy
+	}
+}
+
+// atom writes a literal, class, assertion or group, and reports whether
+// it ends in a repetition operator.
+func (p *pattern) atom(depth int) bool {
+	if depth <= 0 || p.s.Chance(0.5) {
+		p.leaf()
+		return false
+	}
+	switch p.s.Intn(4) {
+	case 0:
+		p.write("(")
+		p.alt(depth - 1)
+		p.write(")")
+	case 1:
+		p.write("%s", p.groupStart())
+		p.alt(depth - 1)
+		p.write(")")
+	case 2:
+		// The classic shapes of catastrophic backtracking: a repeated
+		// group of a repeated atom, or of branches that overlap.
+		shape := gen.Pick(p.s, "(%s+)+", "(%s*)*", "(%s|%s)*", "(%s|%s%s)*", "((%s+)+)+", "(%s?){20}%s{20}", "(%s{1,10}){1,10}")
+		p.write("%s", strings.ReplaceAll(shape, "%s", p.lit()))
+		return true
+	default:
+		p.class()
+	}
+	return false
+}
+
+// groupStart returns the opening of a group other than a plain one.
+func (p *pattern) groupStart() string {
+	if !p.perl {
+		return "("
+	}
+	if p.s.Chance(badRate * 4) {
+		return gen.Pick(p.s, "(?P<>", "(?P<1a>", "(?<a", "(?P=a)", "(?=", "(?!", "(?<=", "(?#", "(?z:", "(?i", "(?P>")
+	}
+	switch p.s.Intn(4) {
+	case 0:
+		return "(?:"
+	case 1:
+		p.names++
+		return fmt.Sprintf(gen.Pick(p.s, "(?P<n%d>", "(?<n%d>", "(?P<name_%d>"), p.names)
+	case 2:
+		if p.names > 0 && p.s.Chance(0.1) {
+			// A name used twice, which the parser rejects.
+			return "(?P<n1>"
+		}
+		return "(?:"
+	}
+	return gen.Pick(p.s, "(?i:", "(?s:", "(?m:", "(?U:", "(?-i:", "(?i-s:", "(?im:")
+}
+
+// literals are the characters of patterns and subjects: ASCII letters,
+// which case folding relates, multi-byte runes, and ones whose case
+// folding is not one to one.
+var literals = []string{"a", "a", "b", "b", "c", "x", "A", "B", "0", "1", " ", "-", "é", "É", "名", "ſ", "K", "k", "K", "Σ", "σ", "ς", "😀"}
+
+// escapes are escaped literals, with the text each matches.
+var escapes = [][2]string{
+	{`\.`, "."}, {`\*`, "*"}, {`\\`, `\`}, {`\(`, "("}, {`\[`, "["}, {`\{`, "{"}, {`\|`, "|"},
+	{`\^`, "^"}, {`\$`, "$"}, {`\-`, "-"}, {`\x41`, "A"}, {`\x{10FFFF}`, "\U0010FFFF"},
+	{`\x{1F600}`, "😀"}, {`\101`, "A"}, {`\n`, "\n"}, {`\t`, "\t"}, {`\f`, "\f"}, {`\a`, "\a"},
+	{`\v`, "\v"}, {`\r`, "\r"},
+}
+
+// lit returns a literal character or escape, remembering the text it
+// matches for the subject.
+func (p *pattern) lit() string {
+	if p.s.Chance(0.85) {
+		l := gen.Pick(p.s, literals...)
+		p.runes = append(p.runes, l)
+		return l
+	}
+	e := gen.Pick(p.s, escapes...)
+	p.runes = append(p.runes, e[1])
+	return e[0]
+}
+
+// leaf writes a literal, escape, class shorthand or assertion.
+func (p *pattern) leaf() {
+	if p.s.Chance(badRate * 4) {
+		p.write("%s", gen.Pick(p.s, `\8`, `\c`, `\e`, `\Z`, `\k<a>`, `\xZZ`, `\x{110000}`, `\x{`, `\p{Unknown}`, `\pZZ`,
+			`\p`, `\Q`, `\`, `)`, `(`, `[`, `*`, `\C`, `\G`, `\X`, `\R`, `\N{DIGIT ONE}`, `\0`))
+		return
+	}
+	if !p.perl {
+		switch p.s.Intn(6) {
+		case 0:
+			p.write("%s", gen.Pick(p.s, ".", "^", "$"))
+		case 1:
+			p.class()
+		default:
+			p.write("%s", p.lit())
+		}
+		return
+	}
+	switch p.s.Intn(8) {
+	case 0:
+		p.write("%s", gen.Pick(p.s, ".", "^", "$", `\A`, `\z`, `\b`, `\B`))
+	case 1:
+		p.write("%s", gen.Pick(p.s, `\d`, `\D`, `\w`, `\W`, `\s`, `\S`, `\pL`, `\PL`, `\pN`, `\p{Greek}`, `\p{Han}`,
+			`\P{Lu}`, `\p{^Lu}`, `\p{Any}`, `\pZ`, `\p{Braille}`))
+	case 2:
+		// Quoted text runs to \E or the end of the pattern.
+		text := gen.Pick(p.s, literals...) + gen.Pick(p.s, ".*", "(", "|", `\`, "a")
+		p.runes = append(p.runes, text)
+		p.write(`\Q%s%s`, text, gen.Pick(p.s, `\E`, `\E`, ""))
+	case 3:
+		p.class()
+	default:
+		p.write("%s", p.lit())
+	}
+}
+
+// class writes a bracket expression.
+func (p *pattern) class() {
+	if p.s.Chance(badRate * 4) {
+		p.write("%s", gen.Pick(p.s, "[z-a]", "[]", "[^]", "[a", "[[:foo:]]", `[a-\d]`, `[\`, "[[:alpha:]"))
+		return
+	}
+	var b strings.Builder
+	b.WriteByte('[')
+	if p.s.Chance(0.3) {
+		b.WriteByte('^')
+	}
+	if p.s.Chance(0.1) {
+		b.WriteByte(']') // a literal ]
+	}
+	for range p.s.Range(1, 4) {
+		switch p.s.Intn(5) {
+		case 0:
+			b.WriteString(gen.Pick(p.s, "a-z", "A-Z", "0-9", "a-a", "\x01-\x7f", "α-ω", "\u0080-\U0010FFFF", "-a", "a-"))
+		case 1:
+			b.WriteString(gen.Pick(p.s, "[:alpha:]", "[:^digit:]", "[:space:]", "[:word:]", "[:upper:]", "[:punct:]"))
+		case 2:
+			if p.perl {
+				b.WriteString(gen.Pick(p.s, `\d`, `\W`, `\pL`, `\p{Greek}`, `\x{41}-\x{5a}`, `\n`, `\]`, `\-`))
+				break
+			}
+			fallthrough
+		default:
+			b.WriteString(p.lit())
+		}
+	}
+	b.WriteByte(']')
+	p.write("%s", b.String())
+}
+
+// subject returns a string to match the pattern against: its literal
+// text in runs, with text from outside the pattern, newlines and invalid
+// UTF-8 between, and sometimes one character repeated to a length that
+// would take a backtracking matcher forever.
+func (p *pattern) subject() string {
+	alphabet := append(p.runes, "a", "\n", "\xff", "\xc3", " ", "z", "K")
+	var b strings.Builder
+	for range p.s.Range(1, 20) {
+		b.WriteString(strings.Repeat(gen.Pick(p.s, alphabet...), p.s.Range(1, 5)))
+	}
+	if p.s.Chance(0.15) {
+		// A long run and a mismatch at the end, as used against (a+)+$.
+		b.Reset()
+		b.WriteString(strings.Repeat(gen.Pick(p.s, alphabet...), p.s.Range(30, 10000)))
+		b.WriteString(gen.Pick(p.s, "!", "\xff", ""))
+	}
+	return b.String()
+}
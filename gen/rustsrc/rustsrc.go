@@ -0,0 +1,604 @@
+// Package rustsrc generates Rust seeds. It registers the "rust/..."
+// generators with package gen.
+//
+// "rust/polyglot" writes what gosrc writes for Go: a dense crate root of
+// the syntax a Rust front end finds hardest, for fuzzing the Rust parsers
+// and syntax highlighters written in Go or bound to it. Declarative
+// macros match every fragment, repeat, nest, recurse and define items,
+// and are invoked with each delimiter; lifetimes are bounded, elided,
+// higher-ranked and written where a character literal could start; const
+// generics take defaults and braced expressions; async functions, blocks
+// and closures await each other; and raw identifiers name functions,
+// parameters, fields and macros after keywords. Around them are raw,
+// byte and C string literals with hashes, labeled blocks and loops that
+// break with values, slice, range, binding and or-patterns, let-else,
+// generic associated types, unions and extern functions, nested block
+// comments, numeric literals with suffixes and underscores, tuple indexes
+// that lex as floats, and non-ASCII identifiers. A few constructs are
+// malformed.
+package rustsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "rust/polyglot",
+		Doc:  "Rust files dense with declarative macros of every fragment and repetition, lifetimes and higher-ranked bounds, const generics, async functions, blocks and closures, raw identifiers, raw, byte and C strings, labeled blocks, slice, range and or-patterns, let-else, generic associated types and unions, with a few malformed constructs",
+		Func: polyglot,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// file draws a few dozen, so about one file in ten gets one.
+const badRate = 0.009
+
+// A rgen accumulates a Rust crate root.
+type rgen struct {
+	s     *gen.State
+	b     strings.Builder
+	depth int // expressions
+	// ints are expressions of type i64 that may appear anywhere in main:
+	// constants, statics and calls of the functions written so far.
+	ints []string
+	// uses are the statements main runs.
+	uses []string
+	// once are the names of the items written once per file.
+	once map[string]bool
+}
+
+func polyglot(s *gen.State) []gen.File {
+	g := &rgen{s: s, depth: s.Depth(s.Limits.Expr, 3), once: map[string]bool{}}
+	if s.Chance(0.1) {
+		g.line("%s", gen.Pick(s, "#!/usr/bin/env rustc", "#! shebang, not an attribute", "#!"))
+	}
+	if s.Chance(0.5) {
+		g.line("//! rust/polyglot: a crate root of the syntax Rust front ends find hardest.")
+	}
+	g.line("#![allow(unused, dead_code, non_snake_case, non_camel_case_types, non_upper_case_globals, unreachable_code, unreachable_patterns)]")
+	g.line("#![allow(uncommon_codepoints, mixed_script_confusables, confusable_idents, unused_must_use, clippy::all)]")
+	for range s.Range(5, 10) {
+		gen.Pick(s, g.macros, g.macros, g.lifetimes, g.constGenerics, g.async, g.rawIdents, g.rawIdents,
+			g.literals, g.patterns, g.traits, g.labels, g.unsafes, g.modules, g.numbers, g.unicode)()
+	}
+	g.main()
+	return []gen.File{{Name: "main.rs", Data: []byte(g.b.String())}}
+}
+
+func (g *rgen) broken() bool { return g.s.Chance(badRate) }
+
+// line writes a line formatted as by fmt.Sprintf.
+func (g *rgen) line(format string, args ...any) {
+	fmt.Fprintf(&g.b, format, args...)
+	g.b.WriteByte('\n')
+}
+
+// raw writes lines as they are.
+func (g *rgen) raw(text string) {
+	g.b.WriteString(text)
+	g.b.WriteByte('\n')
+}
+
+// integer returns an i64 literal, or an expression of a literal of
+// another type converted to i64.
+func (g *rgen) integer() string {
+	s := g.s
+	// A file draws many more literals than other constructs.
+	if g.broken() && s.Chance(0.25) {
+		return gen.Pick(s, "0x", "1__i6", "0b102", "1i65", "'ab' as i64", "0o8_i64", "1e_i64", "256u8 as i64")
+	}
+	return gen.Pick(s, "0i64", "1_i64", "42i64", "0x_7f_i64", "0o17i64", "0b1010_1010i64", "1_000i64", "(-5i64)", "i64::MAX",
+		"('a' as i64)", "(b'z' as i64)", "(true as i64)", "i64::pow(2, 10)", "(\"abc\".len() as i64)", "(r#\"x\"#.len() as i64)",
+		"(u8::MAX as i64)", "(-1i8 as i64)", "(2.9f64 as i64)", "('\\u{1F980}' as i64)", "(b\"\\x00\\xff\".len() as i64)",
+		"i64::MIN", "((1u128 << 100 >> 98) as i64)", "((0.1f32 + 0.2 > 0.3) as i64)", "(!0u32 as i64)", "(1e3 as i64)")
+}
+
+// expr returns an expression of type i64 at most d deep over the
+// expressions in main so far, if inMain, or over constants alone.
+func (g *rgen) expr(d int, inMain bool) string {
+	s := g.s
+	if d <= 0 || s.Chance(0.25) {
+		if inMain && len(g.ints) > 0 && s.Chance(0.5) {
+			return gen.Pick(s, g.ints...)
+		}
+		return g.integer()
+	}
+	a := func() string { return g.expr(d-1, inMain) }
+	switch s.Intn(14) {
+	case 0:
+		return fmt.Sprintf("i64::wrapping_%s(%s, %s)", gen.Pick(s, "add", "sub", "mul"), a(), a())
+	case 1:
+		return fmt.Sprintf("(%s %s %s)", a(), gen.Pick(s, "^", "&", "|"), a())
+	case 2:
+		return fmt.Sprintf("(%s %s)", a(), gen.Pick(s, "/ 3", "% 7", "/ -2", ">> 1"))
+	case 3:
+		return fmt.Sprintf("((%s %s %s) as i64)", a(), gen.Pick(s, "==", "!=", "<", ">=", "<=", ">"), a())
+	case 4:
+		return fmt.Sprintf("(!%s)", a())
+	case 5:
+		return fmt.Sprintf("if %s > %s { %s } else if %s == 0 { %s } else { %s }", a(), a(), a(), a(), a(), a())
+	case 6:
+		return fmt.Sprintf("match %s { 0 => %s, n @ 1..=9 => n, i64::MIN..0 => -1, 10 | 20 | 30 => %s, _ => %s }", a(), a(), a(), a())
+	case 7:
+		v := s.Fresh("t")
+		return fmt.Sprintf("{ let %s = %s; let _: () = (); %s }", v, a(), v)
+	case 8:
+		return fmt.Sprintf("(|x: i64| -> i64 { x ^ 1 })(%s)", a())
+	case 9:
+		l := "'" + s.Fresh("l")
+		return fmt.Sprintf("(%s: { if %s > 0 { break %s %s; } %s })", l, a(), l, a(), a())
+	case 10:
+		return fmt.Sprintf("loop { break %s; }", a())
+	case 11:
+		return fmt.Sprintf("[%s, %s].iter().copied().fold(0i64, i64::wrapping_add)", a(), a())
+	case 12:
+		return fmt.Sprintf("%s.max(%s).min(i64::MAX)", paren(a()), a())
+	}
+	return fmt.Sprintf("i64::wrapping_neg(%s)", a())
+}
+
+// paren returns e in parentheses unless it is a plain literal, a path or
+// a call.
+func paren(e string) string {
+	if strings.HasPrefix(e, "(") && strings.HasSuffix(e, ")") {
+		return e
+	}
+	return "(" + e + ")"
+}
+
+// use appends a statement that adds e to the sink in main.
+func (g *rgen) use(e string) {
+	g.uses = append(g.uses, fmt.Sprintf("sink = sink.wrapping_add(%s);", e))
+}
+
+// macros writes declarative macros and invokes them.
+func (g *rgen) macros() {
+	s := g.s
+	switch s.Intn(6) {
+	case 0:
+		// A tt muncher counting its input recursively.
+		m := s.Fresh("count")
+		g.line("macro_rules! %s {", m)
+		g.line("    () => { 0i64 };")
+		g.line("    ($head:tt $($tail:tt)*) => { 1i64 + %s!($($tail)*) };", m)
+		g.line("}")
+		toks := gen.Pick(s, "a b c", "(a b) [c] {d}", "'a 'b", "r#match => ::", "$ # @ ?", "1.0.1 ..= ...", "\"s\" b'c' r#\"r\"#")
+		g.use(fmt.Sprintf("%s!(%s)", m, toks))
+		g.use(fmt.Sprintf("%s![%s]", m, toks))
+		g.uses = append(g.uses, fmt.Sprintf("%s! { %s };", m, toks))
+	case 1:
+		m := s.Fresh("maxm")
+		g.line("macro_rules! %s {", m)
+		g.line("    ($x:expr $(,)?) => { $x };")
+		g.line("    ($x:expr, $($rest:expr),+ $(,)?) => {{ let a: i64 = $x; let b = %s!($($rest),+); if a > b { a } else { b } }};", m)
+		g.line("}")
+		g.use(fmt.Sprintf("%s!(%s, %s, %s,)", m, g.expr(g.depth, true), g.expr(1, true), g.integer()))
+	case 2:
+		// Macros that define items, named by their arguments.
+		m := s.Fresh("make_fn")
+		f := s.Fresh("made")
+		g.line("macro_rules! %s {", m)
+		g.line("    ($(#[$m:meta])* $vis:vis fn $name:ident -> $t:ty = $body:block) => { $(#[$m])* $vis fn $name() -> $t $body };")
+		g.line("}")
+		g.line("%s!(#[inline] #[must_use = \"a reason\"] pub(crate) fn %s -> i64 = { %s });", m, f, g.expr(g.depth, false))
+		g.ints = append(g.ints, f+"()")
+		if s.Chance(0.5) {
+			g.line("%s! { fn r#%s -> i64 = { 1i64 } }", m, s.Fresh("gen"))
+		}
+	case 3:
+		// Nested repetitions.
+		m := s.Fresh("matrix")
+		g.line("macro_rules! %s {", m)
+		g.line("    ($([$($x:expr),* $(,)?]),* $(,)?) => { [$([$($x as i64),*]),*] };")
+		g.line("}")
+		rows, cols := s.Range(1, 3), s.Range(0, 3)
+		var rs []string
+		for range rows {
+			var cs []string
+			for range cols {
+				cs = append(cs, g.integer())
+			}
+			rs = append(rs, "["+strings.Join(cs, ", ")+"]")
+		}
+		v := s.Fresh("mat")
+		g.uses = append(g.uses, fmt.Sprintf("let %s: [[i64; %d]; %d] = %s!(%s);", v, cols, rows, m, strings.Join(rs, ", ")))
+		g.use(fmt.Sprintf("%s.len() as i64", v))
+	case 4:
+		// Fragments of every kind.
+		m := s.Fresh("frag")
+		g.line("macro_rules! %s {", m)
+		g.line("    (pat $e:expr, $p:pat) => { match $e { $p => 1i64, _ => 0i64 } };")
+		g.line("    (ty $t:ty) => { ::core::mem::size_of::<$t>() as i64 };")
+		g.line("    (lit $l:literal) => { stringify!($l).len() as i64 };")
+		g.line("    (path $p:path) => { $p(2) };")
+		g.line("    (lt $l:lifetime) => { { fn f<$l>(x: &$l i64) -> &$l i64 { x } *f(&3) } };")
+		g.line("    (ident $i:ident) => { { let $i = 4i64; $i } };")
+		g.line("    (block $b:block) => { $b };")
+		g.line("    (stmt $s:stmt) => { { $s; 5i64 } };")
+		g.line("    (tt $($t:tt)*) => { 0i64 $(+ { stringify!($t); 1i64 })* };")
+		g.line("}")
+		g.line("fn %s_f(x: i64) -> i64 { x }", m)
+		g.use(fmt.Sprintf("%s!(pat %s, 1..=5 | 7)", m, g.expr(1, true)))
+		g.use(fmt.Sprintf("%s!(pat Some(3i64), Some(x @ (1 | 3)))", m))
+		g.use(fmt.Sprintf("%s!(ty %s)", m, gen.Pick(s, "Vec<Vec<Option<u8>>>", "[u8; 3]", "(i64, &'static str)", "fn(&u8) -> &u8", "Box<dyn for<'a> Fn(&'a i64) -> &'a i64 + Send>", "Option<&dyn Fn()>")))
+		g.use(fmt.Sprintf("%s!(lit %s)", m, gen.Pick(s, "-1", "b'x'", "r##\"a\"##", "1e-3_f64", "c\"c\"", "'\\''", "\"\\u{0}\"")))
+		g.use(fmt.Sprintf("%s!(path %s_f)", m, m))
+		g.use(fmt.Sprintf("%s!(lt 'a)", m))
+		g.use(fmt.Sprintf("%s!(ident r#match)", m))
+		g.use(fmt.Sprintf("%s!(block { %s })", m, g.expr(1, true)))
+		g.use(fmt.Sprintf("%s!(stmt let _x = 1)", m))
+		g.use(fmt.Sprintf("%s!(tt a 'a 'b' \"s\" ::b $ # ? -> => ..= ... .. ; , .)", m))
+	default:
+		// $crate and a macro that defines a macro.
+		m := s.Fresh("outer")
+		g.line("macro_rules! %s {", m)
+		g.line("    ($inner:ident, $v:expr) => {")
+		g.line("        macro_rules! $inner { () => { $crate::%s_helper($v) }; }", m)
+		g.line("    };")
+		g.line("}")
+		g.line("pub fn %s_helper(x: i64) -> i64 { x }", m)
+		inner := s.Fresh("inner")
+		g.line("%s!(%s, %s);", m, inner, g.expr(g.depth, false))
+		g.use(inner + "!()")
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "macro_rules! bad { ($x) => {} }", "macro_rules! bad { ($x:expr) => { $y } }", "macro_rules! bad { ($($x:tt)*) => { $x } }", "bad!(", "macro_rules! bad { () => {} ", "macro_rules! bad { ($x:unknown) => {} }"))
+	}
+}
+
+// lifetimes writes functions and types with lifetimes of every kind.
+func (g *rgen) lifetimes() {
+	s := g.s
+	f := s.Fresh("longest")
+	g.line("fn %s<'a, 'b: 'a>(x: &'a str, y: &'b str) -> &'a str { if x.len() >= y.len() { x } else { y } }", f)
+	st := s.Fresh("Holder")
+	g.line("struct %s<'a, T: 'a + ?Sized> { r: &'a T, s: &'static str }", st)
+	g.line("impl<'a, T: ?Sized> %s<'a, T> { fn get(&self) -> &'a T { self.r } fn name(&self) -> &'_ str { self.s } }", st)
+	h := s.Fresh("apply")
+	g.line("fn %s<F>(f: F) -> i64 where F: for<'x> Fn(&'x i64) -> &'x i64 { *f(&(%s)) }", h, g.integer())
+	c := s.Fresh("chars")
+	// A character literal whose quote could begin a lifetime, and a
+	// lifetime named like one.
+	g.line("fn %s<'a>(c: &'a char) -> &'a char { let _: &'static char = &'a'; c }", c)
+	g.use(fmt.Sprintf("%s(\"ab\", \"c\").len() as i64", f))
+	g.use(fmt.Sprintf("%s { r: &%s, s: \"name\" }.get().len() as i64", st, gen.Pick(s, `"str"[..]`, `[1u8, 2]`, `*"s"`)))
+	g.use(fmt.Sprintf("%s(|x| x)", h))
+	g.use(fmt.Sprintf("(*%s(&'b') as i64)", c))
+	if s.Chance(0.3) {
+		// Raw lifetimes.
+		g.line("fn %s_raw<'r#fn>(x: &'r#fn i64) -> &'r#fn i64 { x }", c)
+		g.use(fmt.Sprintf("*%s_raw(&1)", c))
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn bad<'a>(x: &'a) {}", "fn bad(x: &'a i64) {}", "struct Bad<'a, 'a>(&'a u8);", "fn bad() -> &str { \"\" }", "fn bad<'static>() {}"))
+	}
+}
+
+// constGenerics writes types and functions with const parameters.
+func (g *rgen) constGenerics() {
+	s := g.s
+	t := s.Fresh("Buf")
+	n := s.Range(0, 4)
+	g.line("#[derive(Debug, Clone, Copy, PartialEq)]")
+	def := s.Chance(0.4)
+	if def {
+		g.line("struct %s<T, const N: usize = %d> { data: [T; N] }", t, n)
+	} else {
+		g.line("struct %s<T, const N: usize> { data: [T; N] }", t)
+	}
+	g.line("impl<T: Copy + Default, const N: usize> %s<T, N> {", t)
+	g.line("    const LEN: usize = N;")
+	g.line("    fn new() -> Self { Self { data: [T::default(); N] } }")
+	g.line("    const fn len(&self) -> usize { N }")
+	g.line("}")
+	f := s.Fresh("sum")
+	g.line("fn %s<const N: usize>(a: [i64; N]) -> i64 { a.iter().copied().fold(0, i64::wrapping_add) }", f)
+	k := strings.ToUpper(s.Fresh("K"))
+	g.line("const %s: usize = { let mut i = 0; while i < %d { i += 1; } i };", k, s.Range(0, 5))
+	g.use(fmt.Sprintf("%s::<u8, { 1 + 2 }>::LEN as i64", t))
+	g.use(fmt.Sprintf("%s::<i64, %s>::new().len() as i64", t, k))
+	if def {
+		g.use(fmt.Sprintf("{ let b: %s<bool> = %s::new(); b.data.len() as i64 }", t, t))
+	}
+	g.use(fmt.Sprintf("%s([%s, %s])", f, g.integer(), g.integer()))
+	g.use(fmt.Sprintf("%s::<0>([])", f))
+	g.use(fmt.Sprintf("const { %s as i64 * 2 }", k))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "struct Bad<const N>;", "fn bad<const N: usize>() -> [u8; N + 1] { todo!() }", "struct Bad<const N: usize = >;", "type Bad = [u8; -1];"))
+	}
+}
+
+// async writes async functions, blocks and closures, which main builds
+// and polls once.
+func (g *rgen) async() {
+	s := g.s
+	f := s.Fresh("fetch")
+	g.line("async fn %s(x: i64) -> i64 {", f)
+	g.line("    let a = async { x }.await;")
+	g.line("    let b = async move { x.wrapping_mul(2) }.await;")
+	g.line("    let c = ::std::future::ready(%s).await;", g.integer())
+	g.line("    let fut = Box::pin(async { 1i64 });")
+	g.line("    a.wrapping_add(b).wrapping_add(c).wrapping_add(fut.await)")
+	g.line("}")
+	r := s.Fresh("recurse")
+	g.line("fn %s(n: u8) -> ::std::pin::Pin<Box<dyn ::std::future::Future<Output = i64>>> {", r)
+	g.line("    Box::pin(async move { if n == 0 { 0 } else { 1 + %s(n - 1).await } })", r)
+	g.line("}")
+	g.line("trait %s_T { async fn get(&self) -> i64; }", f)
+	g.line("impl %s_T for () { async fn get(&self) -> i64 { 7 } }", f)
+	poll := func(fut string) string {
+		return fmt.Sprintf("{ let mut cx = ::std::task::Context::from_waker(::std::task::Waker::noop()); match ::std::pin::pin!(%s).poll(&mut cx) { ::std::task::Poll::Ready(v) => v, ::std::task::Poll::Pending => -1 } }", fut)
+	}
+	g.use(poll(fmt.Sprintf("%s(%s)", f, g.integer())))
+	g.use(poll(fmt.Sprintf("%s(3)", r)))
+	g.use(poll(fmt.Sprintf("<() as %s_T>::get(&())", f)))
+	g.use(poll(fmt.Sprintf("async { let c = async |x: i64| x + 1; c(%s).await }", g.integer())))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn bad() { async {}.await; }", "async fn bad() { await x; }", "fn bad() { let _ = async move; }", "async async fn bad() {}"))
+	}
+}
+
+// rawKeywords are the keywords and reserved words a raw identifier may
+// spell.
+var rawKeywords = []string{"match", "type", "fn", "in", "loop", "let", "mut", "ref", "move", "async", "await", "dyn", "struct",
+	"enum", "trait", "impl", "where", "while", "for", "if", "else", "return", "static", "const", "unsafe", "use", "mod", "pub",
+	"extern", "true", "false", "as", "break", "continue", "box", "do", "final", "macro", "override", "priv", "typeof", "unsized",
+	"virtual", "yield", "try", "abstract", "become", "gen", "union", "default", "auto", "safe", "raw"}
+
+// rawIdents writes items named by raw identifiers.
+func (g *rgen) rawIdents() {
+	s := g.s
+	fn := gen.Pick(s, rawKeywords...)
+	param := gen.Pick(s, rawKeywords...)
+	local := gen.Pick(s, rawKeywords...)
+	fn = fn + "_" + s.Fresh("")
+	if s.Chance(0.5) {
+		fn = strings.TrimSuffix(fn, "_")
+	}
+	g.line("fn r#%s(r#%s: i64) -> i64 { let r#%s = r#%s; r#%s }", fn, param, local, param, local)
+	g.ints = append(g.ints, fmt.Sprintf("r#%s(%s)", fn, g.integer()))
+	st := s.Fresh("R")
+	field := gen.Pick(s, rawKeywords...)
+	g.line("struct %s { r#%s: i64, r#%s_2: (i64, i64) }", st, field, field)
+	g.use(fmt.Sprintf("{ let v = %s { r#%s: %s, r#%s_2: (1, 2) }; v.r#%s.wrapping_add(v.r#%s_2.1) }", st, field, g.integer(), field, field, field))
+	if s.Chance(0.5) {
+		m := gen.Pick(s, "match", "fn", "loop", "type")
+		g.line("macro_rules! r#%s_%s { () => { 1i64 } }", m, st)
+		g.use(fmt.Sprintf("r#%s_%s!()", m, st))
+	}
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn r#self() {}", "fn r#crate() {}", "fn r#super() {}", "fn r#Self() {}", "fn r#() {}", "fn r #match() {}", "let r#_ = 1;"))
+	}
+}
+
+// literals writes string, byte string, C string and character literals
+// with the escapes, hashes and line continuations a lexer must follow.
+func (g *rgen) literals() {
+	s := g.s
+	v := strings.ToUpper(s.Fresh("S"))
+	g.line("/* a block comment /* nested */ and \"not a string */")
+	g.line("const %s: &str = %s;", v, gen.Pick(s,
+		`r"\d+ is not an escape"`,
+		`r#"a "quoted" string"#`,
+		`r##"has "# inside"##`,
+		`"a string \
+        continued"`,
+		"\"a string\nwith a newline\"",
+		`"\u{10FFFF}\u{0}\x7f\t\r\n\\\"\'"`,
+		`r"" `,
+		"r#\"\"#",
+		`"// not a comment /* nor this"`,
+		`"{{}} {} not a format string"`,
+	))
+	g.use(v + ".len() as i64")
+	b := strings.ToUpper(s.Fresh("B"))
+	g.line("static %s: &[u8] = %s;", b, gen.Pick(s, `b"\x00\xff\n"`, `br"\x00"`, `br#"raw "bytes""#`, `b"a\
+    b"`, `&[b'\'', b'"', b'\\', b'\x7f']`))
+	g.use(b + ".len() as i64")
+	if s.Chance(0.5) {
+		c := strings.ToUpper(s.Fresh("C"))
+		g.line("const %s: &::core::ffi::CStr = %s;", c, gen.Pick(s, `c"a C string"`, `cr"raw \n"`, `cr#"with "quotes""#`, `c"\u{e9}\x7f"`))
+		g.use(c + ".to_bytes().len() as i64")
+	}
+	ch := s.Fresh("ch")
+	g.line("/** A doc comment with /* a nested */ comment. */")
+	g.line("#[doc = %s]", gen.Pick(s, `"an attribute doc"`, `r#"raw "doc""#`, `concat!("a", "b")`))
+	g.line("fn %s() -> [char; 6] { ['\\'', '\"', '\\u{1F980}', '\\x41', '\\0', '%s'] }", ch, gen.Pick(s, "a", "ñ", "\\\\", "\\t", "日"))
+	g.use(fmt.Sprintf("%s().len() as i64", ch))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, `const BAD: &str = r#"unterminated"##;`, `const BAD: char = 'ab';`, `const BAD: &str = "\u{110000}";`, `const BAD: &str = "\q";`, `const BAD: u8 = b'é';`, `/* unterminated comment`, `const BAD: &str = r##"x"#;`, `const BAD: char = '';`))
+	}
+}
+
+// patterns writes functions that match every kind of pattern.
+func (g *rgen) patterns() {
+	s := g.s
+	f := s.Fresh("classify")
+	g.line("fn %s(v: &[i64], o: Option<(i64, char)>) -> i64 {", f)
+	g.line("    let total = match v {")
+	g.line("        [] => 0,")
+	g.line("        [x] | [x, _] => *x,")
+	g.line("        [first, .., last] if first == last => *first,")
+	g.line("        [first, rest @ ..] => first.wrapping_add(rest.len() as i64),")
+	g.line("    };")
+	g.line("    let Some((n @ (0..=9 | 100..), c)) = o else { return total; };")
+	g.line("    let tag = match c { 'a'..='z' | 'A'..='Z' => 1, '0'..'9' => 2, _ => 3 };")
+	g.line("    if let Some(&x @ 1..) = v.first() { return x; }")
+	g.line("    let ((a, _), ref b, ref mut m) = ((n, tag), total, 0i64);")
+	g.line("    *m += a;")
+	g.line("    if matches!(o, Some((_, 'x' | 'y'))) { *m += 1; }")
+	g.line("    let &(p, q) = &(1i64, 2i64);")
+	g.line("    let Pt { x: px, .. } = Pt { x: p, y: q };")
+	g.line("    *m + b + tag + px")
+	g.line("}")
+	if !g.once["Pt"] {
+		g.once["Pt"] = true
+		g.line("struct Pt { x: i64, y: i64 }")
+	}
+	g.use(fmt.Sprintf("%s(&[%s, %s, %s], Some((%s, '%s')))", f, g.integer(), g.integer(), g.integer(), gen.Pick(s, "5", "100", "50"), gen.Pick(s, "a", "x", "7", "-")))
+	g.use(fmt.Sprintf("%s(&[], None)", f))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn bad(v: &[i64]) { let [a, .., b, ..] = v; }", "fn bad() { let x @ = 1; }", "fn bad() { match 1 { 1... => {} } }", "fn bad() { let Some(x) = None; }", "fn bad() { match 1 { 5..1 => {} _ => {} } }"))
+	}
+}
+
+// traits writes traits with generic associated types, associated
+// constants and default methods, and generic impls of them.
+func (g *rgen) traits() {
+	s := g.s
+	t := s.Fresh("Lend")
+	g.line("trait %s {", t)
+	g.line("    type Item<'a> where Self: 'a;")
+	g.line("    const ID: i64 = %s;", g.integer())
+	g.line("    fn lend<'a>(&'a mut self) -> Option<Self::Item<'a>>;")
+	g.line("    fn count(&mut self) -> i64 where Self: Sized { let mut n = 0; while self.lend().is_some() { n += 1; if n > 3 { break; } } n }")
+	g.line("}")
+	st := s.Fresh("Win")
+	g.line("struct %s<T>(Vec<T>, usize);", st)
+	g.line("impl<T: ::core::fmt::Debug> %s for %s<T> {", t, st)
+	g.line("    type Item<'a> = &'a mut [T] where T: 'a;")
+	g.line("    const ID: i64 = <i64>::MIN;")
+	g.line("    fn lend<'a>(&'a mut self) -> Option<Self::Item<'a>> { let i = self.1; self.1 += 1; self.0.get_mut(i..) }")
+	g.line("}")
+	op := s.Fresh("V")
+	g.line("#[derive(Clone, Copy, Debug, Default, PartialEq, Eq, Hash, PartialOrd, Ord)]")
+	g.line("struct %s(i64);", op)
+	g.line("impl ::std::ops::Add<%s> for %s { type Output = Self; fn add(self, o: Self) -> Self { %s(self.0.wrapping_add(o.0)) } }", op, op, op)
+	g.line("impl<'a> ::std::ops::Neg for &'a %s { type Output = %s; fn neg(self) -> %s { %s(self.0.wrapping_neg()) } }", op, op, op, op)
+	mk := s.Fresh("adder")
+	g.line("fn %s(n: i64) -> impl Fn(i64) -> Box<dyn Fn(i64) -> i64 + 'static> { move |a| Box::new(move |b| a + b + n) }", mk)
+	g.use(fmt.Sprintf("%s(vec![%s, 2], 0).count()", st, g.integer()))
+	g.use(fmt.Sprintf("<%s<u8> as %s>::ID", st, t))
+	g.use(fmt.Sprintf("(-&(%s(%s) + %s::default())).0", op, g.integer(), op))
+	g.use(fmt.Sprintf("%s(1)(2)(3)", mk))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "trait Bad { type Item<'a>; fn f(&self) -> Self::Item; }", "impl Bad for {}", "trait Bad: {}", "fn bad() -> impl {}", "struct Bad<T>(T) where;;"))
+	}
+}
+
+// labels writes loops and blocks that break and continue by label.
+func (g *rgen) labels() {
+	s := g.s
+	f := s.Fresh("search")
+	g.line("fn %s(limit: i64) -> i64 {", f)
+	g.line("    let mut n = 0i64;")
+	g.line("    'outer: for i in 0..limit.clamp(0, 10) {")
+	g.line("        'inner: while n < 100 {")
+	g.line("            n += i;")
+	g.line("            if n %% 3 == 0 { continue 'outer; }")
+	g.line("            if n %% 5 == 0 { break 'inner; }")
+	g.line("            if n > 50 { break 'outer; }")
+	g.line("        }")
+	g.line("    }")
+	g.line("    let found = 'search: { for x in [3i64, 5, 7] { if x * x > n { break 'search x; } } -1 };")
+	g.line("    let r#loop = 'l: loop { break 'l n.wrapping_add(found) };")
+	g.line("    let c = |x: i64| -> i64 { 'c: { if x > 0 { break 'c x; } 0 } };")
+	g.line("    r#loop + c(n)")
+	g.line("}")
+	g.use(fmt.Sprintf("%s(%s)", f, g.integer()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn bad() { break 'nowhere; }", "fn bad() { 'a: { continue 'a; } }", "fn bad() { 'a loop {} }", "fn bad() { loop { break 'a: 1; } }"))
+	}
+}
+
+// unsafes writes unsafe and extern functions, raw pointers and unions.
+func (g *rgen) unsafes() {
+	s := g.s
+	u := s.Fresh("Bits")
+	g.line("#[repr(C)]")
+	g.line("union %s { i: u32, f: f32, b: [u8; 4] }", u)
+	f := s.Fresh("peek")
+	g.line("unsafe fn %s(p: *const i64) -> i64 { unsafe { *p } }", f)
+	e := s.Fresh("cb")
+	g.line("extern \"C\" fn %s(x: i64) -> i64 { x }", e)
+	if s.Chance(0.3) {
+		g.line("unsafe extern \"C\" { #[link_name = \"abs\"] safe fn %s_abs(x: i32) -> i32; }", e)
+		g.use(fmt.Sprintf("%s_abs(-3) as i64", e))
+	} else {
+		g.line("extern \"C\" { #[link_name = \"abs\"] fn %s_abs(x: i32) -> i32; }", e)
+		g.use(fmt.Sprintf("unsafe { %s_abs(-3) } as i64", e))
+	}
+	g.use(fmt.Sprintf("{ let union = %s { f: 1.0 }; (unsafe { union.i } >> 20) as i64 }", u))
+	g.use(fmt.Sprintf("{ let x = %s; unsafe { %s(::core::ptr::addr_of!(x)) } }", g.integer(), f))
+	g.use(fmt.Sprintf("{ let f: extern \"C\" fn(i64) -> i64 = %s; f(%s) }", e, g.integer()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "union Bad {}", "unsafe extern { fn bad() }", "extern \"nope\" fn bad() {}", "unsafe unsafe fn bad() {}"))
+	}
+}
+
+// modules writes modules with visibility paths, use trees and
+// conditional compilation.
+func (g *rgen) modules() {
+	s := g.s
+	m := s.Fresh("m")
+	g.line("mod %s {", m)
+	g.line("    pub(crate) mod inner { pub(in crate::%s) fn f() -> i64 { super::g() } pub(super) struct S; }", m)
+	g.line("    pub(self) fn g() -> i64 { %s }", g.expr(g.depth, false))
+	g.line("    pub fn h() -> i64 { self::inner::f() }")
+	g.line("    #[cfg(any())] fn never() { let only = [\"parsed\", 'c']; }")
+	g.line("    #[cfg(any())] macro_rules! never { () => { this is only tokens: 'a' 'b [(...)] -> <= }; }")
+	g.line("    #[cfg(all())] pub const ON: bool = true;")
+	g.line("}")
+	g.line("use self::%s::{self as %s_alias, h as %s_h, ON as %s_ON};", m, m, m, m)
+	e := s.Fresh("E")
+	g.line("#[repr(u8)]")
+	g.line("#[derive(Debug, Clone, Copy)]")
+	g.line("#[cfg_attr(all(), derive(PartialEq))]")
+	g.line("enum %s { A = 1, B = 2, #[allow(unused)] C { x: u8 } = 3, D(u16) = 4 }", e)
+	g.use(fmt.Sprintf("%s_h() + %s_alias::h() + (%s_ON as i64)", m, m, m))
+	g.use(fmt.Sprintf("(%s::A == %s::B) as i64 + (%s::D(1) == %s::C { x: 2 }) as i64", e, e, e, e))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "mod bad { pub(in) fn f() {} }", "use self::{;", "use ::*;", "#[cfg(any()] fn bad() {}", "#[cfg(any())] fn bad() { ) }"))
+	}
+}
+
+// numbers writes numeric literals, and tuple indexes that lex as floats.
+func (g *rgen) numbers() {
+	s := g.s
+	t := s.Fresh("tup")
+	g.line("fn %s() -> i64 {", t)
+	g.line("    let t = ((1i64, (2i64, 3i64)), 4.5f64);")
+	g.line("    let a = t.0.1.0 + t.0 .1 .1 + (t.1 as i64);")
+	g.line("    let f = 1e3_f64 + 1.5e-3 + 2. + 0.1_f32 as f64 + 1E+2 + 3f64.mul_add(2.0, 1.0) + 1.0f64.max(2.0);")
+	g.line("    let u = 0x_FF_u8 as i64 + 0b1111_0000 + 0o7_7 + 1_i8 as i64 + i8::MIN as i64 + u128::MAX.count_ones() as i64;")
+	g.line("    let r = 1..=5; let h = ..3; let e = 2..; let full = ..;")
+	g.line("    let _ = (full, h, e);")
+	g.line("    a + u + f as i64 + r.sum::<i64>() + 1.max(2) + -1i64.abs() + (-1i64).abs()")
+	g.line("}")
+	g.use(t + "()")
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "const BAD: u8 = 256;", "const BAD: f64 = 1e;", "const BAD: i64 = 0x;", "const BAD: f32 = 1.0.0;", "const BAD: i64 = 1_i64_i64;"))
+	}
+}
+
+// unicode writes items with non-ASCII identifiers and text.
+func (g *rgen) unicode() {
+	s := g.s
+	id := gen.Pick(s, "ñame", "変数", "Σ", "café", "ŋ", "ǅ", "ℌ", "µ")
+	suffix := s.Fresh("")
+	g.line("fn %s%s(ü: i64) -> i64 { let λ = ü; let _emoji = \"🦀 \\u{200B} \\u{202E}\"; λ }", id, suffix)
+	g.use(fmt.Sprintf("%s%s(%s)", id, suffix, g.integer()))
+	if g.broken() {
+		g.line("%s", gen.Pick(s, "fn 🦀() {}", "fn a\u200bb() {}", "fn \u00adx() {}", "const ＡＢ: i32 = 1;"))
+	}
+}
+
+// main writes main, which adds up what the file defines.
+func (g *rgen) main() {
+	s := g.s
+	g.line("fn main() {")
+	g.line("    use ::std::future::Future as _;")
+	g.line("    let mut sink: i64 = 0;")
+	for _, u := range g.uses {
+		g.line("    %s", u)
+	}
+	for range s.Range(1, 4) {
+		g.line("    sink = sink.wrapping_add(%s);", g.expr(g.depth, true))
+	}
+	g.line("    ::std::hint::black_box(sink);")
+	g.line("}")
+}
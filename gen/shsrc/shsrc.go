@@ -0,0 +1,771 @@
+// Package shsrc generates shell seeds. It registers the "sh/..."
+// generators with package gen.
+//
+// "sh/polyglot" writes what gosrc writes for Go: a dense script of the
+// syntax a shell parser finds hardest, for fuzzing the shell parsers,
+// formatters and interpreters written in Go. Half the scripts are POSIX
+// sh and half bash, as their shebangs say. Command substitutions nest in
+// each other, in double quotes, in backquotes and in here-documents, and
+// hold case clauses whose patterns close a parenthesis; here-documents
+// are quoted and unquoted, strip tabs, share a line and hold expansions;
+// parameter expansions take every operator, with words that are quoted,
+// nested and substituted; arithmetic expansions, commands and for loops
+// take every operator; and quoting traps abound: quotes that end and
+// resume inside a word, escaped newlines, hashes that do not start
+// comments, and $'...' and $"..." strings. Around them are functions,
+// subshells and groups, pipelines, lists, every redirection, and in bash
+// [[ ]] tests with regular expressions and extended globs, arrays,
+// process substitutions, coprocesses and select loops. A few constructs
+// are malformed.
+package shsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "sh/polyglot",
+		Doc:  "POSIX sh and bash scripts dense with nested command substitutions, here-documents of every kind, parameter expansions of every operator, arithmetic contexts, quoting traps, functions, pipelines and redirections, and in bash [[ ]] tests, arrays, process substitutions and coprocesses, with a few malformed constructs",
+		Func: polyglot,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// file draws a few hundred, so about one file in ten gets one.
+const badRate = 0.0015
+
+// A shgen accumulates a shell script.
+type shgen struct {
+	s      *gen.State
+	b      strings.Builder
+	depth  int // words and commands
+	blocks int // compound commands
+	indent string
+	bash   bool     // bash syntax is allowed, not only POSIX
+	vars   []string // variables assigned so far
+	funcs  []string // functions defined so far
+}
+
+func polyglot(s *gen.State) []gen.File {
+	g := &shgen{s: s, depth: s.Depth(s.Limits.Expr, 3), blocks: s.Depth(s.Limits.Block, 3), bash: s.Chance(0.5)}
+	g.indent = gen.Pick(s, "\t", "  ", "    ", "")
+	switch {
+	case g.bash:
+		g.raw(gen.Pick(s, "#!/usr/bin/env bash", "#!/bin/bash", "#!/usr/bin/env bash\n# shellcheck shell=bash"))
+	default:
+		g.raw(gen.Pick(s, "#!/bin/sh", "#!/bin/sh -e", "#! /bin/sh\n# shellcheck shell=sh"))
+	}
+	if s.Chance(0.5) {
+		g.raw(gen.Pick(s, "set -eu", "set -o errexit -o nounset", "set -f", "IFS=' \t\n'"))
+	}
+	for range s.Range(6, 14) {
+		g.stmt(0, g.blocks)
+		if s.Chance(0.2) {
+			g.raw(gen.Pick(s, "", "# comment with 'quotes' and $(no substitution)", "#", "  # indented comment"))
+		}
+	}
+	return []gen.File{{Name: "script.sh", Data: []byte(g.b.String())}}
+}
+
+func (g *shgen) broken() bool { return g.s.Chance(badRate) }
+
+// raw writes text and a newline.
+func (g *shgen) raw(text string) {
+	g.b.WriteString(text)
+	g.b.WriteByte('\n')
+}
+
+// line writes a command at the given level of indentation.
+func (g *shgen) line(level int, text string) {
+	g.b.WriteString(strings.Repeat(g.indent, level))
+	g.b.WriteString(text)
+	if strings.HasSuffix(text, "&") {
+		g.b.WriteString("\n")
+		return
+	}
+	g.b.WriteString(gen.Pick(g.s, "\n", "\n", "\n", "\n", " # comment\n", ";\n"))
+}
+
+// open writes at the given level of indentation text that a command must
+// follow, such as the head of a compound command.
+func (g *shgen) open(level int, text string) {
+	g.b.WriteString(strings.Repeat(g.indent, level))
+	g.b.WriteString(text)
+	g.b.WriteString(gen.Pick(g.s, "\n", "\n", "\n", " # comment\n"))
+}
+
+// assign returns a fresh variable name.
+func (g *shgen) assign() string {
+	v := g.s.Fresh(gen.Pick(g.s, "v", "x", "_", "IFS_", "PATH_", "n"))
+	g.vars = append(g.vars, v)
+	return v
+}
+
+// variable returns the name of a variable to read.
+func (g *shgen) variable() string {
+	s := g.s
+	if len(g.vars) > 0 && s.Chance(0.7) {
+		return gen.Pick(s, g.vars...)
+	}
+	return gen.Pick(s, "HOME", "PATH", "IFS", "PWD", "x", "_x", "a1")
+}
+
+// special returns a special or positional parameter.
+func (g *shgen) special() string {
+	return gen.Pick(g.s, "$1", "${10}", "$#", "$?", "$$", "$!", "$-", "$0", "$*", "$@", "${#}", "${@}", "${#1}", "$9")
+}
+
+// literal returns an unquoted or quoted word without expansions.
+func (g *shgen) literal() string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, `"unterminated`, `'unterminated`, "`unterminated", `\`, `$'\`, `a"b`)
+	}
+	lits := []string{"a", "foo.txt", "-n", "--opt=val", "a#b", `\#`, `\ x`, `'single $x'`, `'it'\''s'`, `"a\"b\\c\$d\` + "`" + `e"`,
+		"*.[ch]", "~", "~/dir", "''", `""`, "a\\\nb", "'multi\nline'", `"#not a comment"`, `\$HOME`, "x=y", "--", `\*`,
+		`a'b'"c"d`, `"'"`, `'"'`, "[!a-z]?", "-", "!", "1", "0x10", "é", "'a b'", `"tab	here"`}
+	if g.bash {
+		lits = append(lits, "{a,b}c", "{1..5}", "{a..z..2}", `$'\t\x41é\'\n\cA\0101'`, `$"localized $x"`, `$''`)
+	}
+	return gen.Pick(s, lits...)
+}
+
+// word returns a word of at most d levels of expansions.
+func (g *shgen) word(d int) string {
+	s := g.s
+	if d <= 0 || s.Chance(0.3) {
+		switch s.Intn(4) {
+		case 0:
+			return "$" + g.variable()
+		case 1:
+			return g.special()
+		}
+		return g.literal()
+	}
+	switch s.Intn(12) {
+	case 0, 1:
+		return g.param(d - 1)
+	case 2:
+		return "$" + parens(g.subst(d-1))
+	case 3:
+		return `"$` + parens(g.subst(d-1)) + `"`
+	case 4:
+		return "`" + g.backquoted(d-1) + "`"
+	case 5:
+		return g.arithExp(d - 1)
+	case 6:
+		return g.dquoted(d - 1)
+	case 7:
+		return g.word(d-1) + g.word(d-1)
+	case 8:
+		if g.bash {
+			return gen.Pick(s, "<", ">") + parens(g.cmd(d-1))
+		}
+	case 9:
+		if g.bash {
+			a := g.variable()
+			return gen.Pick(s, "${"+a+"[@]}", `"${`+a+`[@]}"`, "${#"+a+"[@]}", "${"+a+"[*]}", "${!"+a+"[@]}",
+				"${"+a+"[$(("+g.arith(d-1)+"))]}", "${"+a+"[i+1]}", `"${`+a+`[@]:1:2}"`)
+		}
+	case 10:
+		if g.bash {
+			return "$[" + g.arith(d-1) + "]"
+		}
+	}
+	return "${" + g.variable() + "}"
+}
+
+// dquoted returns a double-quoted word of at most d levels of expansions.
+func (g *shgen) dquoted(d int) string {
+	s := g.s
+	var b strings.Builder
+	b.WriteByte('"')
+	for range s.Range(1, 4) {
+		switch s.Intn(9) {
+		case 0:
+			b.WriteString(gen.Pick(s, "text ", " ", "it's ", `\"`, `\\`, `\$`, "\\`", `\n is not a newline`, "\\\n", "\n", "'", "#", "*"))
+		case 1:
+			b.WriteString("$" + g.variable())
+		case 2:
+			b.WriteString(g.special())
+		case 3:
+			b.WriteString(g.param(d))
+		case 4:
+			b.WriteString("$" + parens(g.subst(d)))
+		case 5:
+			b.WriteString("`" + g.backquoted(d) + "`")
+		case 6:
+			b.WriteString(g.arithExp(d))
+		case 7:
+			b.WriteString("${" + g.variable() + "}text")
+		default:
+			b.WriteString(gen.Pick(s, "a b", "~", "{a,b}", "'single'"))
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// param returns a parameter expansion with an operator, whose word is at
+// most d levels deep.
+func (g *shgen) param(d int) string {
+	s := g.s
+	v := g.variable()
+	if s.Chance(0.1) {
+		v = gen.Pick(s, "1", "@", "*", "#", "?")
+	}
+	if g.broken() {
+		return gen.Pick(s, "${"+v, "${"+v+":-", "${}", "${"+v+"%%", "${ "+v+"}", "${"+v+"!}")
+	}
+	w := func() string {
+		if s.Chance(0.3) {
+			return ""
+		}
+		return g.word(d)
+	}
+	if g.bash && s.Chance(0.4) {
+		switch s.Intn(8) {
+		case 0:
+			return fmt.Sprintf("${%s%s%s/%s}", v, gen.Pick(s, "/", "//", "/#", "/%"), w(), w())
+		case 1:
+			return fmt.Sprintf("${%s:%s}", v, gen.Pick(s, "1", "1:2", " -1", "(-2):1", "$((1+1))", "i:j", "0:-1"))
+		case 2:
+			return fmt.Sprintf("${%s%s}", v, gen.Pick(s, "^", "^^", ",", ",,", "^^[ab]"))
+		case 3:
+			return fmt.Sprintf("${%s@%s}", v, gen.Pick(s, "Q", "E", "P", "A", "a", "U", "u", "L", "K"))
+		case 4:
+			return fmt.Sprintf("${!%s}", gen.Pick(s, v, v+"*", v+"@"))
+		case 5:
+			return fmt.Sprintf("${%s/%s}", v, w())
+		}
+	}
+	switch s.Intn(4) {
+	case 0:
+		return "${#" + v + "}"
+	case 1:
+		ops := []string{"#", "##", "%", "%%"}
+		if v == "#" {
+			// Some parsers take ${##a} for ${#} and then another
+			// operator.
+			ops = ops[2:]
+		}
+		return fmt.Sprintf("${%s%s%s}", v, gen.Pick(s, ops...), gen.Pick(s, "*/", "*.", "[a-z]*", `\*`, "?", "'*'", `"$x"`, "$(echo a)", "", "a\\}"))
+	}
+	return fmt.Sprintf("${%s%s%s}", v, gen.Pick(s, "-", ":-", "=", ":=", "?", ":?", "+", ":+"), w())
+}
+
+// arithExp returns an arithmetic expansion at most d deep.
+func (g *shgen) arithExp(d int) string {
+	a := g.arith(d)
+	if strings.HasPrefix(a, "(") {
+		// $((( is a command substitution of a subshell to some shells.
+		return "$(( " + a + " ))"
+	}
+	return "$((" + a + "))"
+}
+
+// arith returns an arithmetic expression at most d deep, which may be an
+// assignment.
+func (g *shgen) arith(d int) string {
+	s := g.s
+	if d > 0 && s.Chance(0.15) {
+		return gen.Pick(s, "i", "n", "i") + " " + gen.Pick(s, "=", "+=", "-=", "*=", "<<=", "|=", "^=") + " " + g.arith(d-1)
+	}
+	return g.arithTerm(d)
+}
+
+// arithTerm returns an arithmetic expression at most d deep that may be
+// an operand: an assignment in it is in parentheses.
+func (g *shgen) arithTerm(d int) string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, "1 +", "(1", "1 ? 2", "1 2", ")", "2#", "**")
+	}
+	if d <= 0 || s.Chance(0.3) {
+		leaves := []string{"0", "1", "42", "0x1F", "010", "i", "$i", "${#x}", "$1", "$#", "${n:-0}", "$(echo 1)", " 7 "}
+		if g.bash {
+			leaves = append(leaves, "2#101", "64#_@", "i++", "--i", "a[1]", "RANDOM")
+		}
+		return gen.Pick(s, leaves...)
+	}
+	a := func() string { return g.arithTerm(d - 1) }
+	switch s.Intn(4) {
+	case 0:
+		ops := []string{"+", "-", "*", "/", "%", "<<", ">>", "&", "|", "^", "&&", "||", "==", "!=", "<", "<=", ">", ">="}
+		if g.bash {
+			ops = append(ops, "**", ",")
+		}
+		return a() + " " + gen.Pick(s, ops...) + " " + a()
+	case 1:
+		return "(" + g.arith(d-1) + ")"
+	case 2:
+		return a() + " ? " + a() + " : " + a()
+	}
+	return gen.Pick(s, "- ", "+ ", "!", "~") + a()
+}
+
+// subst returns the commands of a command substitution at most d deep,
+// which may span lines.
+func (g *shgen) subst(d int) string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, "echo (", "case x in", "if true", ")", "echo \"")
+	}
+	switch s.Intn(8) {
+	case 0:
+		// A case clause whose pattern closes a parenthesis.
+		return fmt.Sprintf("case %s in a) echo a;; %s*) echo %s;; esac", g.word(d), gen.Pick(s, "", "("), g.word(d))
+	case 1:
+		// A subshell, which the callers space apart from an arithmetic
+		// expansion.
+		return "(" + g.cmd(d) + ")"
+	case 2:
+		// A here-document inside a command substitution.
+		delim := gen.Pick(s, "EOF", "'EOF'", "E\"O\"F", "END")
+		// The body of E"O"F is quoted, but some parsers take it for
+		// unquoted, so it is one that parses either way.
+		body := g.heredocBody(d, delim == "'EOF'")
+		return fmt.Sprintf("cat <<%s\n%s%s\n", delim, body, strings.NewReplacer("'", "", "\"", "").Replace(delim))
+	case 3:
+		return "\n" + g.indent + g.cmd(d) + "\n" + g.indent + g.cmd(d) + "\n"
+	case 4:
+		return g.cmd(d) + " # a comment ) in a substitution\n"
+	}
+	return g.cmd(d)
+}
+
+// backquoted returns the commands of a backquoted command substitution,
+// with backquotes and backslashes escaped.
+func (g *shgen) backquoted(d int) string {
+	s := g.s
+	if s.Chance(0.2) {
+		return "echo \\`echo " + g.literalSimple() + "\\`"
+	}
+	// Quotes in backquotes in double quotes are read differently by each
+	// shell, so the words hold none.
+	var words []string
+	for range s.Range(1, 3) {
+		words = append(words, gen.Pick(s, "a", "-n", "$x", "${y:-z}", "$(echo a)", "$((1+2))", "*.txt", "$1"))
+	}
+	return gen.Pick(s, "echo", "printf %s", "tr a b") + " " + strings.Join(words, " ")
+}
+
+// literalSimple returns a plain word.
+func (g *shgen) literalSimple() string {
+	return gen.Pick(g.s, "a", "b", "x y", "-n", "$x", "'q'", `"dq"`)
+}
+
+// heredocBody returns the lines of a here-document and its end, but for
+// its delimiter: of the text of quoted delimiter if quoted, or with
+// expansions if not.
+func (g *shgen) heredocBody(d int, quoted bool) string {
+	s := g.s
+	var b strings.Builder
+	for range s.Range(0, 4) {
+		switch {
+		case quoted:
+			b.WriteString(gen.Pick(s, "$(not a substitution", "${unbalanced", "`", "'", `"`, `\`, "EOF is not the delimiter", " EOF", "$x", "\t\ttabs"))
+		case s.Chance(0.5):
+			b.WriteString(gen.Pick(s, "text with 'quotes' and \"quotes\"", "$x ${y:-z} $((1+2))", `\$not \`+"`"+`not`, "EOFX", "line \\\ncontinued", "\\\\", "#no comment", "  "))
+		default:
+			w := g.word(d)
+			if strings.ContainsAny(w, "\n") {
+				w = "$(echo sub)"
+			}
+			b.WriteString("word: " + w)
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// value returns a word that is the value of an assignment. A process
+// substitution cannot start it.
+func (g *shgen) value(d int) string {
+	w := g.word(d)
+	if strings.HasPrefix(w, "<(") || strings.HasPrefix(w, ">(") {
+		return `"$x"`
+	}
+	return w
+}
+
+// parens returns cmd in parentheses, spaced apart from a parenthesis it
+// starts with so that the two do not open an arithmetic expansion.
+func parens(cmd string) string {
+	if strings.HasPrefix(cmd, "(") {
+		return "( " + cmd + ")"
+	}
+	return "(" + cmd + ")"
+}
+
+// simpleCmd returns a simple command of words at most d deep.
+func (g *shgen) simpleCmd(d int) string {
+	s := g.s
+	var parts []string
+	if s.Chance(0.2) {
+		parts = append(parts, g.assign()+"="+g.value(d))
+	}
+	switch s.Intn(10) {
+	case 0:
+		v := g.assign()
+		if parts == nil {
+			return v + "=" + g.value(d)
+		}
+		return strings.Join(parts, " ") + " " + v + "=" + g.value(d)
+	case 1:
+		parts = append(parts, "printf", `'%s\n'`)
+	case 2:
+		parts = append(parts, "test", g.word(d), gen.Pick(s, "=", "!=", "-eq", "-lt"), g.word(d))
+		return strings.Join(parts, " ")
+	case 3:
+		parts = append(parts, "[", gen.Pick(s, "-n", "-z", "-f", "-d", "!"), g.word(d), "]")
+		return strings.Join(parts, " ")
+	case 4:
+		parts = append(parts, gen.Pick(s, "read -r", "set --", "export", "readonly", "unset", "shift", "eval", "exec", ":", "true", "false", "command", "."))
+	case 5:
+		if len(g.funcs) > 0 {
+			parts = append(parts, gen.Pick(s, g.funcs...))
+			break
+		}
+		fallthrough
+	default:
+		parts = append(parts, gen.Pick(s, "echo", "echo", "cat", "printf %s", "ls", "grep -e", "sed -e", "tr", "wc -l", "\\echo", "'echo'", "e\\cho"))
+	}
+	for range s.Range(0, 3) {
+		parts = append(parts, g.word(d))
+	}
+	for range s.Range(0, 1) {
+		parts = append(parts, g.redirect(d))
+	}
+	return strings.Join(parts, " ")
+}
+
+// redirect returns a redirection other than a here-document.
+func (g *shgen) redirect(d int) string {
+	s := g.s
+	if g.broken() {
+		return gen.Pick(s, ">", "2>&", "<", "&>", ">>|")
+	}
+	ops := []string{">", ">>", "<", "2>", "2>&1", ">&2", "<>", ">|", "3>&-", "0<&3", "9>"}
+	if g.bash {
+		ops = append(ops, "&>", "&>>", "{fd}>", "<<<", ">&")
+	}
+	op := gen.Pick(s, ops...)
+	switch op {
+	case "2>&1", ">&2", "3>&-", "0<&3":
+		return op
+	}
+	w := g.word(d)
+	if strings.ContainsAny(w, "\n") || strings.Contains(w, "{") {
+		w = gen.Pick(s, "/dev/null", "out.txt", `"$x"`)
+	}
+	if strings.ContainsAny(w[:1], "<>(") {
+		return op + " " + w
+	}
+	return op + gen.Pick(s, "", " ") + w
+}
+
+// cmd returns a command of at most d levels on a single line, but for
+// what the words it holds span.
+func (g *shgen) cmd(d int) string {
+	s := g.s
+	if d <= 0 || s.Chance(0.4) {
+		return g.simpleCmd(d)
+	}
+	c := func() string { return g.cmd(d - 1) }
+	// Only a pipeline may be negated, and only once.
+	unnegated := func() string { return strings.TrimPrefix(c(), "! ") }
+	switch s.Intn(14) {
+	case 0:
+		op := gen.Pick(s, " | ", " && ", " || ", "; ", " & ", "|", "&&")
+		if strings.Contains(op, "|") && !strings.Contains(op, "||") {
+			return c() + op + unnegated()
+		}
+		return c() + op + c()
+	case 1:
+		return "! " + unnegated()
+	case 2:
+		return parens(c())
+	case 3:
+		return "{ " + c() + "; }"
+	case 4:
+		return fmt.Sprintf("if %s; then %s; elif %s; then %s; else %s; fi", c(), c(), c(), c(), c())
+	case 5:
+		return fmt.Sprintf("for %s in %s %s; do %s; done", g.assign(), g.word(d-1), g.word(d-1), c())
+	case 6:
+		return fmt.Sprintf("while %s; do %s; done", c(), c())
+	case 7:
+		return fmt.Sprintf("case %s in %s) %s;; *) ;; esac", g.word(d-1), g.pattern(), c())
+	case 8:
+		if g.bash {
+			return "[[ " + g.cond(d-1) + " ]]"
+		}
+	case 9:
+		if g.bash {
+			return "(( " + g.arith(d-1) + " ))"
+		}
+	case 10:
+		if g.bash {
+			// Bash does not parse time before a compound command in a
+			// command substitution, so it times a simple one.
+			return gen.Pick(s, c()+" |& "+unnegated(), "time -p "+g.simpleCmd(d-1), "coproc { "+c()+"; }")
+		}
+	}
+	return g.simpleCmd(d - 1)
+}
+
+// operand returns a word that is an operand in a [[ ]] test, and not an
+// operator.
+func (g *shgen) operand(d int) string {
+	w := g.word(d)
+	if strings.HasPrefix(w, "-") || strings.HasPrefix(w, "!") {
+		return `"$x"`
+	}
+	return w
+}
+
+// cond returns the expression of a [[ ]] test at most d deep.
+func (g *shgen) cond(d int) string {
+	s := g.s
+	if d <= 0 || s.Chance(0.4) {
+		switch s.Intn(5) {
+		case 0:
+			return g.operand(d) + " =~ " + gen.Pick(s, "^[0-9]+$", `^(a|b)\ c$`, "$re", `'lit.'`, "a(b)?", `[[:space:]]+`, "(^|x)")
+		case 1:
+			return g.operand(d) + " " + gen.Pick(s, "==", "!=", "=") + " " + gen.Pick(s, "@(a|b)", "!(x)*", "*.txt", `"$x"`, "+([0-9])", "?(a)", "[[:alpha:]]")
+		case 2:
+			return gen.Pick(s, "-n", "-z", "-f", "-e", "-v", "-o") + " " + g.operand(d)
+		case 3:
+			return g.operand(d) + " " + gen.Pick(s, "<", ">", "-eq", "-nt", "-ef") + " " + g.operand(d)
+		}
+		return g.operand(d)
+	}
+	switch s.Intn(3) {
+	case 0:
+		return "( " + g.cond(d-1) + " )"
+	case 1:
+		return "! " + g.cond(d-1)
+	}
+	return g.cond(d-1) + gen.Pick(s, " && ", " || ") + g.cond(d-1)
+}
+
+// stmt writes a statement at the given level, with compound commands of
+// at most d levels.
+func (g *shgen) stmt(level, d int) {
+	s := g.s
+	if d <= 0 {
+		g.line(level, g.cmd(g.depth))
+		return
+	}
+	switch s.Intn(14) {
+	case 0, 1:
+		g.heredoc(level)
+	case 2:
+		g.function(level, d)
+	case 3:
+		g.open(level, "if "+g.cmd(g.depth)+"; then")
+		g.body(level+1, d)
+		if s.Chance(0.5) {
+			g.line(level, "elif "+g.cmd(1))
+			g.open(level, "then")
+			g.body(level+1, d)
+		}
+		if s.Chance(0.5) {
+			g.open(level, "else")
+			g.body(level+1, d)
+		}
+		g.line(level, "fi"+g.trailer())
+	case 4:
+		g.line(level, fmt.Sprintf("%s %s", gen.Pick(s, "while", "until"), g.cmd(g.depth)))
+		g.open(level, "do")
+		g.body(level+1, d)
+		g.line(level, "done"+g.trailer())
+	case 5:
+		v := g.assign()
+		switch {
+		case g.bash && s.Chance(0.3):
+			g.open(level, "for (( "+gen.Pick(s, "i = 0; i < 3; i++", ";;", "i=0, j=1; i<j; i+=2, j--")+" )); do")
+		case s.Chance(0.2):
+			g.open(level, "for "+v+gen.Pick(s, "; do", "\ndo", " do"))
+		default:
+			g.open(level, fmt.Sprintf("for %s in %s %s; do", v, g.word(g.depth), g.word(g.depth)))
+		}
+		g.body(level+1, d)
+		g.line(level, "done")
+	case 6:
+		g.open(level, "case "+g.word(g.depth)+gen.Pick(s, " in", "\nin"))
+		for range s.Range(1, 3) {
+			g.open(level+1, g.pattern()+")")
+			g.body(level+2, d)
+			term := []string{";;", ";;"}
+			if g.bash {
+				term = append(term, ";&", ";;&")
+			}
+			g.open(level+2, gen.Pick(s, term...))
+		}
+		g.line(level, "esac")
+	case 7:
+		if s.Chance(0.5) {
+			g.open(level, "(")
+			g.body(level+1, d)
+			g.line(level, ")"+g.trailer())
+		} else {
+			g.open(level, "{")
+			g.body(level+1, d)
+			g.line(level, "}"+g.trailer())
+		}
+	case 8:
+		if g.bash {
+			g.bashOnly(level, d)
+			return
+		}
+		fallthrough
+	default:
+		g.line(level, g.cmd(g.depth))
+	}
+}
+
+// pattern returns the pattern of a case item, without its closing
+// parenthesis.
+func (g *shgen) pattern() string {
+	s := g.s
+	p := gen.Pick(s, "a|b", "*.txt", `"$x"`, "[0-9]*", `\)`, "''", "*", "$(echo a)", "${x%.*}", "in|do", `a|\(|b`, `[\)]`)
+	if s.Chance(0.3) {
+		// Patterns may open the parenthesis they close.
+		p = "(" + p
+	}
+	return p
+}
+
+// trailer returns what may follow a compound command on its line.
+func (g *shgen) trailer() string {
+	s := g.s
+	if !s.Chance(0.3) {
+		return ""
+	}
+	return gen.Pick(s, " > /dev/null", " 2>&1", " | cat", " &", " && :", " || exit 1")
+}
+
+// body writes the commands of a compound command.
+func (g *shgen) body(level, d int) {
+	for range g.s.Range(1, 3) {
+		g.stmt(level, d-1)
+	}
+}
+
+// heredoc writes a command with one or two here-documents.
+func (g *shgen) heredoc(level int) {
+	s := g.s
+	delims := []string{"EOF", "'EOF'", `"EOF"`, "\\EOF", "E'O'F", "END_OF_TEXT", "'-'", "'a b'", `""`}
+	n := s.Range(1, 2)
+	var ops, ends []string
+	var quoted, strip []bool
+	for i := range n {
+		delim := gen.Pick(s, delims...)
+		if i > 0 {
+			delim = "TWO"
+		}
+		op := gen.Pick(s, "<<", "<<", "<<-", "<< ")
+		ops = append(ops, op+delim)
+		ends = append(ends, strings.NewReplacer("'", "", "\"", "", "\\", "").Replace(delim))
+		// As in subst, the body of E'O'F parses quoted or not.
+		quoted = append(quoted, strings.ContainsAny(delim, `'"\`) && delim != "E'O'F")
+		strip = append(strip, strings.HasPrefix(op, "<<-"))
+	}
+	cmd := gen.Pick(s, "cat", "cat -", "read -r x", "tr a-z A-Z", "sh -s", "exec 3")
+	if s.Chance(0.3) {
+		g.line(level, fmt.Sprintf("%s %s | %s", cmd, strings.Join(ops, " "), gen.Pick(s, "cat", "wc -c", "sort")))
+	} else {
+		g.line(level, fmt.Sprintf("%s %s%s", cmd, strings.Join(ops, " "), g.trailer()))
+	}
+	for i := range n {
+		body := g.heredocBody(g.depth, quoted[i])
+		if strip[i] {
+			body = strings.ReplaceAll("\t"+strings.TrimSuffix(body, "\n"), "\n", "\n\t")
+			if body != "\t" {
+				body += "\n"
+			} else {
+				body = ""
+			}
+		}
+		g.b.WriteString(body)
+		end := ends[i]
+		if g.broken() {
+			end += " "
+		}
+		if strip[i] {
+			end = "\t" + end
+		}
+		g.raw(end)
+	}
+}
+
+// function writes a function definition.
+func (g *shgen) function(level, d int) {
+	s := g.s
+	name := g.s.Fresh(gen.Pick(s, "f", "do_it", "_helper", "a.b", "x-y"))
+	if !g.bash {
+		name = strings.NewReplacer(".", "_", "-", "_").Replace(name)
+	}
+	switch {
+	case g.bash && s.Chance(0.3):
+		g.open(level, "function "+name+gen.Pick(s, " {", "() {", " () {"))
+	case s.Chance(0.2):
+		g.open(level, name+"() (")
+		g.body(level+1, d)
+		g.line(level, ")")
+		g.funcs = append(g.funcs, name)
+		return
+	default:
+		g.open(level, name+gen.Pick(s, "() {", " () {", "()\n"+strings.Repeat(g.indent, level)+"{"))
+	}
+	if g.bash && s.Chance(0.5) {
+		g.line(level+1, "local "+g.assign()+"="+g.word(1)+" "+g.assign())
+	}
+	g.body(level+1, d)
+	if s.Chance(0.3) {
+		g.line(level+1, "return "+gen.Pick(s, "0", "1", "$?", "$((1+1))"))
+	}
+	g.line(level, "}"+g.trailer())
+	g.funcs = append(g.funcs, name)
+}
+
+// bashOnly writes a statement of bash syntax.
+func (g *shgen) bashOnly(level, d int) {
+	s := g.s
+	switch s.Intn(8) {
+	case 0:
+		a := g.assign()
+		g.line(level, fmt.Sprintf("%s=(%s %s [5]=%s %s)", a, g.word(1), g.word(1), g.word(1), gen.Pick(s, "", "'x y'", "$(echo z)", "# comment\n  more")))
+		g.line(level, fmt.Sprintf("%s+=(%s) %s[1]+=%s", a, g.word(1), a, g.word(1)))
+	case 1:
+		m := g.assign()
+		g.line(level, fmt.Sprintf("declare -A %s=([key]=%s [\"k 2\"]=%s [$x]=)", m, g.word(1), g.word(1)))
+		g.line(level, fmt.Sprintf("%s[k]=%s; unset '%s[k]'", m, g.word(1), m))
+	case 2:
+		g.open(level, "while IFS= read -r "+g.assign()+"; do")
+		g.body(level+1, d)
+		g.line(level, "done < <("+g.cmd(1)+")")
+	case 3:
+		g.open(level, "select "+g.assign()+" in "+g.word(1)+" "+g.word(1)+"; do")
+		g.body(level+1, d)
+		g.line(level+1, "break")
+		g.line(level, "done")
+	case 4:
+		g.line(level, "coproc "+g.s.Fresh("CO")+" { "+g.cmd(1)+"; }")
+	case 5:
+		g.open(level, "if [[ "+g.cond(g.depth)+" ]]; then")
+		g.line(level+1, `echo "${BASH_REMATCH[0]}"`)
+		g.line(level, "fi")
+	case 6:
+		// Not g.line: some parsers take a comment after let for more of
+		// its expressions.
+		g.raw(strings.Repeat(g.indent, level) + "(( " + g.arith(g.depth) + " )) && let " + gen.Pick(s, `"x = 1 + 2"`, "x++", "'y<<=1'"))
+	default:
+		g.line(level, "mapfile -t "+g.assign()+" <<< "+g.word(g.depth))
+	}
+}
@@ -0,0 +1,993 @@
+// Package sqlsrc generates SQL seeds. It registers the "sql/..."
+// generators with package gen.
+//
+// A script is a run of statements, most of them queries: SELECTs with
+// joins, derived tables, subqueries nested in their expressions and
+// FROM clauses, common table expressions, recursive ones among them, and
+// set operations, with INSERT, UPDATE, DELETE, CREATE TABLE and the odd
+// transaction or SET statement between. Each script leans to MySQL or to
+// PostgreSQL, and borrows from the other and from SQL Server now and
+// then: backquoted and bracketed identifiers beside double-quoted ones,
+// backslash escapes beside doubled quotes, dollar-quoted, escape and
+// Unicode strings, :: casts, LIMIT in each of its forms, ON DUPLICATE
+// KEY UPDATE and ON CONFLICT, and placeholders of every style.
+// Identifiers are bare, quoted, keywords, and in scripts other than
+// Latin; strings hold quotes, backslashes, comment markers, semicolons,
+// NULs, invalid UTF-8 and the other ways out of a literal. Each
+// construct also has malformed variants, drawn rarely.
+package sqlsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "sql/script",
+		Doc:  "SQL scripts leaning to MySQL or PostgreSQL: SELECTs with joins, nested subqueries, CTEs and set operations, DML and DDL, identifiers quoted every way and in many scripts, strings with escapes and quotes that try to end them early, comments of each kind, and the syntax of one dialect borrowed by another",
+		Func: script,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// script has a few hundred of them, so about a third of scripts get one.
+const badRate = 0.002
+
+// Dialects a script leans to.
+const (
+	mysql = iota
+	postgres
+)
+
+// A script accumulates SQL statements.
+type sqlScript struct {
+	s       *gen.State
+	b       strings.Builder
+	dialect int
+	// borrow is the chance that a construct is written in the syntax
+	// of a dialect other than the script's.
+	borrow float64
+	// ctes are the names of the common table expressions in scope.
+	ctes []string
+}
+
+func script(s *gen.State) []gen.File {
+	d := &sqlScript{s: s, dialect: gen.Pick(s, mysql, postgres), borrow: gen.Pick(s, 0, 0, 0.02, 0.15)}
+	depth := s.Depth(s.Limits.Expr, 3)
+	n := s.Range(1, 8)
+	for i := range n {
+		if s.Chance(0.15) {
+			d.comment()
+		}
+		d.statement(depth)
+		if i < n-1 || s.Chance(0.7) {
+			d.b.WriteString(gen.Pick(s, ";", ";", " ;", ";;"))
+		}
+		d.b.WriteString(gen.Pick(s, "\n", "\n", "\n\n", " ", "\r\n"))
+	}
+	return []gen.File{{Name: "input.sql", Data: []byte(d.b.String())}}
+}
+
+func (d *sqlScript) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+func (d *sqlScript) broken() bool { return d.s.Chance(badRate) }
+
+// is reports whether a construct is written for dialect, the script's or
+// one it borrows from.
+func (d *sqlScript) is(dialect int) bool {
+	if d.s.Chance(d.borrow) {
+		return d.dialect != dialect
+	}
+	return d.dialect == dialect
+}
+
+// kw returns a keyword in the case it is written in, which SQL ignores.
+func (d *sqlScript) kw(k string) string {
+	switch d.s.Intn(8) {
+	case 0:
+		return strings.ToLower(k)
+	case 1:
+		return strings.ToUpper(k[:1]) + strings.ToLower(k[1:])
+	}
+	return k
+}
+
+// sp returns the space between tokens, now and then a comment.
+func (d *sqlScript) sp() string {
+	s := d.s
+	if s.Chance(0.9) {
+		return " "
+	}
+	switch s.Intn(6) {
+	case 0:
+		return "\n  "
+	case 1:
+		return "\t"
+	case 2:
+		return " /* c */ "
+	case 3:
+		return "/**/"
+	case 4:
+		return " -- note\n"
+	default:
+		if d.is(mysql) {
+			return gen.Pick(s, " # note\n", " /*! */ ", " /*+ INDEX(t) */ ")
+		}
+		return gen.Pick(s, " /* outer /* nested */ still */ ", "\f", " --\n")
+	}
+}
+
+// comment writes a comment on a line of its own.
+func (d *sqlScript) comment() {
+	s := d.s
+	if d.broken() {
+		d.b.WriteString(gen.Pick(s, "/* unterminated", "/* /* */", "--", "#"))
+		return
+	}
+	c := gen.Pick(s, "-- a comment\n", "--\n", "/* block */\n", "/* multi\n   line */\n", "-- é ☺ 日本\n", "-- ' \" ` ; */\n", "/* '; DROP TABLE t; -- */\n")
+	if d.is(mysql) && s.Chance(0.3) {
+		c = gen.Pick(s, "# hash comment\n", "/*!40101 SET NAMES utf8mb4 */;\n", "/*!50503 SET character_set_client = utf8mb4 */;\n")
+	}
+	d.b.WriteString(c)
+}
+
+// statement writes a statement.
+func (d *sqlScript) statement(depth int) {
+	s := d.s
+	d.ctes = nil
+	switch s.Intn(16) {
+	case 0, 1:
+		d.insert(depth)
+	case 2:
+		d.update(depth)
+	case 3:
+		d.delete(depth)
+	case 4:
+		d.create()
+	case 5:
+		d.misc()
+	case 6:
+		d.write("%s%s", d.kw(gen.Pick(s, "EXPLAIN", "EXPLAIN ANALYZE", "DESCRIBE")), d.sp())
+		d.query(depth)
+	default:
+		d.query(depth)
+	}
+}
+
+// query writes a query: a SELECT, or set operations on them, after
+// common table expressions now and then.
+func (d *sqlScript) query(depth int) {
+	s := d.s
+	if depth > 0 && s.Chance(0.1) {
+		d.with(depth - 1)
+	}
+	d.selectStmt(depth)
+	for range gen.Pick(s, 0, 0, 0, 0, 1, 2) {
+		op := gen.Pick(s, "UNION", "UNION ALL")
+		if d.is(mysql) && s.Chance(0.2) {
+			op = "UNION DISTINCT"
+		} else if d.is(postgres) && s.Chance(0.4) {
+			op = gen.Pick(s, "INTERSECT", "EXCEPT", "INTERSECT ALL", "EXCEPT ALL")
+		}
+		d.write("%s%s%s", d.sp(), d.kw(op), d.sp())
+		if s.Chance(0.3) {
+			d.b.WriteString("(")
+			d.selectStmt(depth)
+			d.b.WriteString(")")
+		} else {
+			d.selectStmt(depth)
+		}
+	}
+	if s.Chance(0.3) {
+		d.orderBy(depth)
+	}
+	if s.Chance(0.3) {
+		d.limit()
+	}
+	if s.Chance(0.05) {
+		d.write("%s%s", d.sp(), d.kw(gen.Pick(s, "FOR UPDATE", "FOR SHARE", "LOCK IN SHARE MODE", "FOR UPDATE NOWAIT", "FOR UPDATE SKIP LOCKED")))
+	}
+}
+
+// with writes common table expressions, whose names are in scope for the
+// query after them.
+func (d *sqlScript) with(depth int) {
+	s := d.s
+	d.write("%s%s", d.kw("WITH"), d.sp())
+	if s.Chance(0.3) {
+		d.write("%s%s", d.kw("RECURSIVE"), d.sp())
+	}
+	for i := range s.Range(1, 3) {
+		if i > 0 {
+			d.b.WriteString(", ")
+		}
+		name := s.Fresh("cte")
+		d.b.WriteString(name)
+		if s.Chance(0.4) {
+			d.write("(%s)", d.names(s.Range(1, 3)))
+		}
+		d.write("%s%s%s", d.sp(), d.kw("AS"), d.sp())
+		if s.Chance(0.1) {
+			d.write("%s ", d.kw(gen.Pick(s, "MATERIALIZED", "NOT MATERIALIZED")))
+		}
+		d.b.WriteString("(")
+		if s.Chance(0.3) && len(d.ctes) > 0 {
+			// A recursive member that refers to an earlier one.
+			d.write("%s 1 %s %s", d.kw("SELECT"), d.kw("UNION ALL SELECT n + 1 FROM"), gen.Pick(s, d.ctes...))
+			d.write(" %s n < %d", d.kw("WHERE"), s.Range(1, 100))
+		} else {
+			d.query(depth)
+		}
+		d.b.WriteString(")")
+		d.ctes = append(d.ctes, name)
+	}
+	d.b.WriteString(d.sp())
+}
+
+// selectStmt writes a SELECT with its clauses.
+func (d *sqlScript) selectStmt(depth int) {
+	s := d.s
+	if d.broken() {
+		d.b.WriteString(gen.Pick(s, "SELECT", "SELECT FROM t", "SELECT * FROM", "SELECT 1,", "SELECT (1", "SELECT 1)", "SELECT * FROM t WHERE", "SELECT * t", "SELEC 1"))
+		return
+	}
+	d.write("%s%s", d.kw("SELECT"), d.sp())
+	if d.is(mysql) && s.Chance(0.05) {
+		d.write("%s ", d.kw(gen.Pick(s, "SQL_NO_CACHE", "STRAIGHT_JOIN", "SQL_CALC_FOUND_ROWS", "HIGH_PRIORITY")))
+	}
+	if s.Chance(0.15) {
+		d.write("%s%s", d.kw(gen.Pick(s, "DISTINCT", "DISTINCT", "ALL")), d.sp())
+	}
+	if !d.is(mysql) && s.Chance(0.05) {
+		d.write("%s %d ", d.kw("TOP"), s.Range(1, 100))
+	}
+	for i := range s.Range(1, 4) {
+		if i > 0 {
+			d.b.WriteString(gen.Pick(s, ", ", ",", " ,\n"))
+		}
+		switch s.Intn(8) {
+		case 0:
+			d.b.WriteString("*")
+		case 1:
+			d.write("%s.*", d.table())
+		default:
+			d.expr(depth)
+			if s.Chance(0.4) {
+				if s.Chance(0.6) {
+					d.write("%s%s", d.sp(), d.kw("AS"))
+				}
+				d.write("%s%s", d.sp(), d.ident())
+			}
+		}
+	}
+	if s.Chance(0.1) {
+		return
+	}
+	d.write("%s%s%s", d.sp(), d.kw("FROM"), d.sp())
+	d.from(depth)
+	if s.Chance(0.6) {
+		d.write("%s%s%s", d.sp(), d.kw("WHERE"), d.sp())
+		d.cond(depth)
+	}
+	if s.Chance(0.2) {
+		d.write("%s%s%s", d.sp(), d.kw("GROUP BY"), d.sp())
+		d.exprs(depth, s.Range(1, 3))
+		if d.is(mysql) && s.Chance(0.1) {
+			d.write(" %s", d.kw("WITH ROLLUP"))
+		}
+		if s.Chance(0.5) {
+			d.write("%s%s%s", d.sp(), d.kw("HAVING"), d.sp())
+			d.cond(depth)
+		}
+	}
+	if s.Chance(0.05) {
+		d.write("%s%s w %s (%s a %s b)", d.sp(), d.kw("WINDOW"), d.kw("AS"), d.kw("PARTITION BY"), d.kw("ORDER BY"))
+	}
+}
+
+// from writes the table references of a FROM clause.
+func (d *sqlScript) from(depth int) {
+	s := d.s
+	d.tableRef(depth)
+	for range gen.Pick(s, 0, 0, 1, 1, 2, 3) {
+		if s.Chance(0.2) {
+			d.b.WriteString(", ")
+			d.tableRef(depth)
+			continue
+		}
+		join := gen.Pick(s, "JOIN", "INNER JOIN", "LEFT JOIN", "LEFT OUTER JOIN", "RIGHT JOIN", "CROSS JOIN", "NATURAL JOIN")
+		if d.is(mysql) && s.Chance(0.1) {
+			join = "STRAIGHT_JOIN"
+		} else if d.is(postgres) && s.Chance(0.1) {
+			join = gen.Pick(s, "FULL OUTER JOIN", "FULL JOIN")
+		}
+		d.write("%s%s%s", d.sp(), d.kw(join), d.sp())
+		d.tableRef(depth)
+		if strings.Contains(join, "CROSS") || strings.Contains(join, "NATURAL") {
+			continue
+		}
+		if s.Chance(0.2) {
+			d.write("%s%s (%s)", d.sp(), d.kw("USING"), d.names(s.Range(1, 2)))
+		} else {
+			d.write("%s%s%s", d.sp(), d.kw("ON"), d.sp())
+			d.cond(depth)
+		}
+	}
+}
+
+// tableRef writes a table, common table expression or derived table,
+// with an alias now and then.
+func (d *sqlScript) tableRef(depth int) {
+	s := d.s
+	switch {
+	case depth > 0 && s.Chance(0.15):
+		if d.is(postgres) && s.Chance(0.2) {
+			d.write("%s ", d.kw("LATERAL"))
+		}
+		d.b.WriteString("(")
+		d.query(depth - 1)
+		d.b.WriteString(")")
+		d.write("%s%s%s", d.sp(), d.kw("AS"), d.sp())
+		d.b.WriteString(d.ident())
+		return
+	case len(d.ctes) > 0 && s.Chance(0.4):
+		d.b.WriteString(gen.Pick(s, d.ctes...))
+	case s.Chance(0.05):
+		if d.is(mysql) {
+			d.b.WriteString("dual")
+		} else {
+			d.b.WriteString(gen.Pick(s, "generate_series(1, 10)", "unnest(ARRAY[1, 2])", "(VALUES (1, 'a'), (2, 'b'))"))
+		}
+	default:
+		d.b.WriteString(d.table())
+	}
+	if s.Chance(0.3) {
+		if s.Chance(0.5) {
+			d.write("%s%s", d.sp(), d.kw("AS"))
+		}
+		d.write("%s%s", d.sp(), d.ident())
+	}
+	if d.is(mysql) && s.Chance(0.05) {
+		d.write(" %s (%s)", d.kw(gen.Pick(s, "USE INDEX", "FORCE INDEX", "IGNORE INDEX")), d.ident())
+	}
+}
+
+// table returns the name of a table, qualified by its schema or
+// database now and then.
+func (d *sqlScript) table() string {
+	t := d.ident()
+	if d.s.Chance(0.2) {
+		t = d.ident() + "." + t
+	}
+	return t
+}
+
+// names returns a list of n column names.
+func (d *sqlScript) names(n int) string {
+	var b strings.Builder
+	for i := range n {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(d.ident())
+	}
+	return b.String()
+}
+
+// idents are bare identifiers, some of them keywords in one dialect or
+// another that it lets be identifiers.
+var idents = []string{
+	"t", "u", "users", "orders", "a", "b", "c", "id", "name", "n", "x", "_", "_1", "a$b", "t1", "CamelCase",
+	"status", "type", "date", "time", "year", "text", "action", "comment", "name",
+}
+
+// letters are identifiers in letters other than ASCII, which both
+// dialects allow bare.
+var letters = []string{"é", "café", "naïve", "Straße", "日本語", "表", "имя", "δοκιμή", "اسم", "שם", "ｓｅｌｅｃｔ", "ǅ", "ﬁ", "𠁡", "𝑥"}
+
+// keywords are reserved words, which only quoted may be identifiers.
+var keywords = []string{"select", "from", "where", "order", "group", "table", "union", "join", "on", "limit", "null", "and", "default", "check", "key", "user", "value"}
+
+// ident returns an identifier, bare or quoted in the style of a dialect.
+func (d *sqlScript) ident() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, `"unterminated`, "`unterminated", "[unterminated", "1a", "a-b", "select", `""`, "``", "a\x00b", "\xff")
+	}
+	name := gen.Pick(s, idents...)
+	if s.Chance(0.1) {
+		name = gen.Pick(s, letters...)
+	}
+	if s.Chance(0.5) {
+		return name
+	}
+	if s.Chance(0.2) {
+		name = gen.Pick(s, append(keywords,
+			"a b", "a.b", "a;b", "a'b", `a"b`, "a`b", "a]b", "-- x", "/*x*/", "\t", " ", "A", "é́", "​", "‮", "☺", "a\nb", "$1", "?",
+		)...)
+	}
+	switch {
+	case d.is(mysql):
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case s.Chance(0.02):
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	case s.Chance(0.05):
+		// A Unicode identifier, with its characters escaped.
+		var b strings.Builder
+		for _, r := range name {
+			if r < 0x80 && r != '\\' && r != '"' {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(&b, `\%04x`, r)
+			}
+		}
+		return `U&"` + b.String() + `"`
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// exprs writes a list of n expressions.
+func (d *sqlScript) exprs(depth, n int) {
+	for i := range n {
+		if i > 0 {
+			d.b.WriteString(", ")
+		}
+		d.expr(depth)
+	}
+}
+
+// cond writes a condition: comparisons joined by AND and OR, and the
+// predicates that take subqueries.
+func (d *sqlScript) cond(depth int) {
+	s := d.s
+	if depth > 0 && s.Chance(0.3) {
+		d.b.WriteString(gen.Pick(s, "", "NOT ", "("))
+		d.cond(depth - 1)
+		op := gen.Pick(s, "AND", "OR")
+		if d.is(mysql) && s.Chance(0.1) {
+			op = gen.Pick(s, "XOR", "&&", "||")
+		}
+		d.write("%s%s%s", d.sp(), d.kw(op), d.sp())
+		d.cond(depth - 1)
+		return
+	}
+	switch s.Intn(10) {
+	case 0:
+		d.expr(depth)
+		d.write(" %s ", d.kw(gen.Pick(s, "IN", "NOT IN")))
+		d.b.WriteString("(")
+		if depth > 0 && s.Chance(0.5) {
+			d.query(depth - 1)
+		} else {
+			d.exprs(0, s.Range(1, 4))
+		}
+		d.b.WriteString(")")
+	case 1:
+		if depth > 0 {
+			d.write("%s (", d.kw(gen.Pick(s, "EXISTS", "NOT EXISTS")))
+			d.query(depth - 1)
+			d.b.WriteString(")")
+			return
+		}
+		d.expr(depth)
+	case 2:
+		d.expr(depth)
+		d.write(" %s ", d.kw(gen.Pick(s, "BETWEEN", "NOT BETWEEN")))
+		d.expr(0)
+		d.write(" %s ", d.kw("AND"))
+		d.expr(0)
+	case 3:
+		d.expr(depth)
+		d.write(" %s", d.kw(gen.Pick(s, "IS NULL", "IS NOT NULL", "IS TRUE", "IS NOT FALSE")))
+	case 4:
+		d.expr(depth)
+		op := gen.Pick(s, "LIKE", "NOT LIKE")
+		if d.is(mysql) && s.Chance(0.2) {
+			op = gen.Pick(s, "REGEXP", "NOT REGEXP", "RLIKE")
+		} else if d.is(postgres) && s.Chance(0.2) {
+			op = gen.Pick(s, "ILIKE", "SIMILAR TO", "~", "~*", "!~")
+		}
+		d.write(" %s %s", d.kw(op), d.str())
+		if s.Chance(0.2) {
+			d.write(" %s '\\'", d.kw("ESCAPE"))
+		}
+	case 5:
+		if depth > 0 && d.is(postgres) {
+			d.expr(depth)
+			d.write(" %s %s (", gen.Pick(s, "=", ">", "<>"), d.kw(gen.Pick(s, "ANY", "ALL", "SOME")))
+			d.query(depth - 1)
+			d.b.WriteString(")")
+			return
+		}
+		fallthrough
+	default:
+		d.expr(depth)
+		op := gen.Pick(s, "=", "<>", "!=", "<", "<=", ">", ">=")
+		if d.is(mysql) && s.Chance(0.1) {
+			op = "<=>"
+		} else if d.is(postgres) && s.Chance(0.1) {
+			op = d.kw(gen.Pick(s, "IS DISTINCT FROM", "IS NOT DISTINCT FROM"))
+		}
+		d.write("%s%s%s", d.sp(), op, d.sp())
+		d.expr(depth)
+	}
+}
+
+// expr writes an expression, operators, calls and subqueries nesting
+// down to depth.
+func (d *sqlScript) expr(depth int) {
+	s := d.s
+	if depth <= 0 || s.Chance(0.4) {
+		d.operand()
+		return
+	}
+	switch s.Intn(12) {
+	case 0, 1:
+		d.expr(depth - 1)
+		op := gen.Pick(s, "+", "-", "*", "/", "%", "&", "|", "<<", ">>")
+		if d.is(mysql) && s.Chance(0.2) {
+			op = gen.Pick(s, "^", "DIV", "MOD", "->", "->>")
+		} else if d.is(postgres) && s.Chance(0.2) {
+			op = gen.Pick(s, "||", "#", "->", "->>", "#>>", "@>", "<@", "?|")
+		}
+		d.write("%s%s%s", d.sp(), d.kw(op), d.sp())
+		d.expr(depth - 1)
+	case 2:
+		d.write("%s", gen.Pick(s, "-", "+", "~", "NOT ", "- "))
+		d.expr(depth - 1)
+	case 3:
+		d.b.WriteString("(")
+		d.expr(depth - 1)
+		d.b.WriteString(")")
+	case 4:
+		// A scalar subquery.
+		if s.Chance(0.5) {
+			d.operand()
+			return
+		}
+		d.b.WriteString("(")
+		d.query(depth - 1)
+		d.b.WriteString(")")
+	case 5:
+		fn := gen.Pick(s, "count", "sum", "max", "min", "avg", "coalesce", "concat", "lower", "upper", "length", "now", "nullif", "greatest", "md5")
+		if d.is(mysql) && s.Chance(0.2) {
+			fn = gen.Pick(s, "ifnull", "json_extract", "group_concat", "hex", "unhex", "char")
+		} else if d.is(postgres) && s.Chance(0.2) {
+			fn = gen.Pick(s, "string_agg", "array_agg", "jsonb_build_object", "to_char", "encode")
+		}
+		d.write("%s(", gen.Pick(s, fn, strings.ToUpper(fn)))
+		switch {
+		case fn == "count" && s.Chance(0.5):
+			d.b.WriteString(gen.Pick(s, "*", "DISTINCT a", "1"))
+		case fn == "now":
+		default:
+			d.exprs(depth-1, s.Range(1, 3))
+		}
+		d.b.WriteString(")")
+		if s.Chance(0.1) {
+			d.write(" %s (%s a %s b %s)", d.kw("OVER"), d.kw("PARTITION BY"), d.kw("ORDER BY"), d.kw(gen.Pick(s, "DESC", "ASC", "ROWS BETWEEN UNBOUNDED PRECEDING AND CURRENT ROW")))
+		}
+	case 6:
+		d.write("%s", d.kw("CASE"))
+		if s.Chance(0.3) {
+			d.b.WriteString(" ")
+			d.expr(depth - 1)
+		}
+		for range s.Range(1, 3) {
+			d.write(" %s ", d.kw("WHEN"))
+			d.cond(depth - 1)
+			d.write(" %s ", d.kw("THEN"))
+			d.expr(depth - 1)
+		}
+		if s.Chance(0.5) {
+			d.write(" %s ", d.kw("ELSE"))
+			d.expr(depth - 1)
+		}
+		d.write(" %s", d.kw("END"))
+	case 7:
+		typ := d.typ()
+		if d.is(postgres) {
+			d.expr(depth - 1)
+			d.write("::%s", typ)
+			return
+		}
+		d.write("%s(", d.kw(gen.Pick(s, "CAST", "CONVERT")))
+		d.expr(depth - 1)
+		d.write(" %s %s)", d.kw("AS"), typ)
+	case 8:
+		if d.is(postgres) {
+			d.write("%s[", d.kw("ARRAY"))
+			d.exprs(depth-1, s.Range(0, 3))
+			d.b.WriteString("]")
+			if s.Chance(0.3) {
+				d.write("[%d]", s.Range(0, 3))
+			}
+			return
+		}
+		d.write("%s ", d.kw("INTERVAL"))
+		d.expr(depth - 1)
+		d.write(" %s", d.kw(gen.Pick(s, "DAY", "HOUR", "MINUTE_SECOND", "YEAR_MONTH")))
+	default:
+		d.b.WriteString("(")
+		d.exprs(depth-1, s.Range(2, 3))
+		d.b.WriteString(")")
+	}
+}
+
+// typ returns the name of a type.
+func (d *sqlScript) typ() string {
+	return d.kw(gen.Pick(d.s,
+		"INT", "INTEGER", "BIGINT", "SIGNED", "UNSIGNED", "TEXT", "CHAR", "CHAR(10)", "VARCHAR(255)", "DECIMAL(10, 2)",
+		"NUMERIC", "FLOAT", "DOUBLE PRECISION", "BOOLEAN", "DATE", "DATETIME", "TIMESTAMP", "TIMESTAMPTZ", "JSON",
+		"JSONB", "BYTEA", "BINARY", "UUID", "INT[]", "TEXT[][]", "INTERVAL", "BIT(8)", "regclass",
+	))
+}
+
+// operand writes a column, a literal or a placeholder.
+func (d *sqlScript) operand() {
+	s := d.s
+	switch s.Intn(10) {
+	case 0, 1, 2:
+		if s.Chance(0.2) {
+			d.write("%s.", d.ident())
+		}
+		d.b.WriteString(d.ident())
+	case 3, 4:
+		d.b.WriteString(d.str())
+	case 5:
+		d.b.WriteString(d.number())
+	case 6:
+		d.b.WriteString(d.placeholder())
+	case 7:
+		d.b.WriteString(d.kw(gen.Pick(s, "NULL", "TRUE", "FALSE", "CURRENT_TIMESTAMP", "CURRENT_USER")))
+	case 8:
+		switch {
+		case s.Chance(0.5):
+			d.b.WriteString(d.str())
+		case d.is(postgres) && s.Chance(0.3):
+			d.write("%s %s", d.kw("INTERVAL"), gen.Pick(s, "'1 day'", "'-1 year 2 mons'", "'P1Y2M'", "'1 day ago'"))
+		default:
+			d.write("%s %s", d.kw(gen.Pick(s, "DATE", "TIME", "TIMESTAMP")), gen.Pick(s, "'2024-02-29'", "'12:34:56.789'", "'2024-01-01 00:00:00+05:30'", "'-infinity'", "'2024-13-45'"))
+		}
+	default:
+		if d.is(mysql) {
+			d.b.WriteString(gen.Pick(s, "@v", "@v", "@@session.sql_mode", "@@global.max_connections", "@@autocommit", gen.Pick(s, "@`quoted var`", "@'str var'")))
+		} else {
+			d.b.WriteString(gen.Pick(s, "$1::int", "current_setting('x')", "E'\\x41'::bytea", "'{1,2}'::int[]", "B'1010'", "'(1,2)'::point"))
+		}
+	}
+}
+
+// placeholder returns a bind parameter in one driver's style or another.
+func (d *sqlScript) placeholder() string {
+	s := d.s
+	if d.is(mysql) {
+		return gen.Pick(s, "?", "?", ":v1", ":name")
+	}
+	if d.broken() {
+		return gen.Pick(s, "$0", "$99999999999", "$", "@p1")
+	}
+	return gen.Pick(s, "$1", "$2", "$10", "$65536")
+}
+
+// number returns a numeric literal, past the limits of int64 and
+// float64 now and then.
+func (d *sqlScript) number() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, "1e", "0x", "1.2.3", "0b2", "1e+", "0xg", "1_000_")
+	}
+	return gen.Pick(s,
+		"0", "1", "-1", "42", "007", "1.5", ".5", "5.", "1e10", "1E-3", "2.5e+4", "-0", "0.0",
+		"9223372036854775807", "9223372036854775808", "-9223372036854775809", "18446744073709551616",
+		"123456789012345678901234567890", "1e308", "1e309", "4.9e-324", "0x1F", "0xff", "0b1010", "1_000",
+	)
+}
+
+// contents are the parts of the contents of strings: ordinary text, and
+// the characters that end a literal or begin a comment or a statement
+// when a quoting routine misses them.
+var contents = []string{
+	"a", "hello world", "O'Reilly", "it''s", "é", "日本語", "☺", " ", "\t", "\n", "\r\n", "%", "_", "\\%", "[a-z]",
+	"'; DROP TABLE users; --", "' OR '1'='1", "\" OR \"\"=\"", "*/", "/*", "--", "#", ";", "`", "$$", "$tag$",
+	"\\", "\\\\", "\\'", "\\\"", "\\n", "\\0", "\\Z", "\\x41", "\\u0041", "\x00", "\x1a", "\xbf'", "\xa5\\", "\xff", "\xc0\xa7", "’", "＇", "ʼ",
+}
+
+// str returns a string literal in one of the forms of one dialect or
+// another.
+func (d *sqlScript) str() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, "'unterminated", "'a''", "'\\'", "E'\\", "$$unterminated", "$a$x$b$", "U&'\\00zz'", "X'4G'", "X'123'", "B'102'", "N'", "e'\\u'")
+	}
+	var b strings.Builder
+	for range s.Range(0, 4) {
+		b.WriteString(gen.Pick(s, contents...))
+	}
+	text := b.String()
+	mysqlish := d.is(mysql)
+	switch s.Intn(10) {
+	case 0, 1, 2, 3:
+		if mysqlish {
+			// MySQL reads a backslash as an escape, and \' as a quote.
+			text = strings.ReplaceAll(text, `\`, `\\`)
+		}
+		return "'" + strings.ReplaceAll(text, "'", gen.Pick(s, "''", "''", `\'`)) + "'"
+	case 4:
+		if mysqlish {
+			return `"` + strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), `"`, `""`) + `"`
+		}
+		return "E'" + strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), "'", `\'`) + "'"
+	case 5:
+		if mysqlish {
+			return gen.Pick(s, "_utf8mb4", "_binary", "_latin1", "N") + "'" + strings.ReplaceAll(strings.ReplaceAll(text, `\`, `\\`), "'", "''") + "'"
+		}
+		// A dollar-quoted string, tagged so that the text cannot end it.
+		tag := gen.Pick(s, "", "tag", "x", "_1")
+		if strings.Contains(text, "$"+tag+"$") {
+			tag = s.Fresh("q")
+		}
+		return "$" + tag + "$" + text + "$" + tag + "$"
+	case 6:
+		return fmt.Sprintf("%s'%x'", gen.Pick(s, "X", "x"), text)
+	case 7:
+		if mysqlish {
+			return fmt.Sprintf("0x%x", text)
+		}
+		var u strings.Builder
+		for _, r := range text {
+			if r >= ' ' && r < 0x7f && r != '\\' && r != '\'' {
+				u.WriteRune(r)
+			} else if r <= 0xffff {
+				fmt.Fprintf(&u, `\%04x`, r)
+			} else {
+				fmt.Fprintf(&u, `\+%06x`, r)
+			}
+		}
+		return "U&'" + u.String() + "'"
+	case 8:
+		// Literals side by side, which both dialects join; PostgreSQL
+		// only across a newline.
+		return "'a'\n'" + strings.ReplaceAll(text, "'", "''") + "'"
+	default:
+		return "''"
+	}
+}
+
+// orderBy writes an ORDER BY clause.
+func (d *sqlScript) orderBy(depth int) {
+	s := d.s
+	d.write("%s%s%s", d.sp(), d.kw("ORDER BY"), d.sp())
+	for i := range s.Range(1, 3) {
+		if i > 0 {
+			d.b.WriteString(", ")
+		}
+		if s.Chance(0.3) {
+			d.write("%d", s.Range(1, 3))
+		} else {
+			d.expr(depth)
+		}
+		if s.Chance(0.5) {
+			d.write(" %s", d.kw(gen.Pick(s, "ASC", "DESC")))
+		}
+		if d.is(postgres) && s.Chance(0.2) {
+			d.write(" %s", d.kw(gen.Pick(s, "NULLS FIRST", "NULLS LAST")))
+		}
+	}
+}
+
+// limit writes a LIMIT clause in one of its forms.
+func (d *sqlScript) limit() {
+	s := d.s
+	n, m := d.count(), d.count()
+	switch {
+	case d.is(mysql):
+		if s.Chance(0.5) {
+			d.write(" %s %s, %s", d.kw("LIMIT"), m, n)
+			return
+		}
+		d.write(" %s %s", d.kw("LIMIT"), n)
+		if s.Chance(0.5) {
+			d.write(" %s %s", d.kw("OFFSET"), m)
+		}
+	case s.Chance(0.5):
+		d.write(" %s %s %s %s %s", d.kw("OFFSET"), m, d.kw("ROWS FETCH"), d.kw(gen.Pick(s, "FIRST", "NEXT")), n)
+		d.write(" %s", d.kw(gen.Pick(s, "ROWS ONLY", "ROW ONLY", "ROWS WITH TIES")))
+	default:
+		d.write(" %s %s", d.kw("LIMIT"), gen.Pick(s, n, "ALL"))
+		if s.Chance(0.5) {
+			d.write(" %s %s", d.kw("OFFSET"), m)
+		}
+	}
+}
+
+// count returns a row count for LIMIT or OFFSET.
+func (d *sqlScript) count() string {
+	return gen.Pick(d.s, "0", "1", "10", "100", "?", "$1", "18446744073709551615", "9223372036854775808")
+}
+
+// insert writes an INSERT of values or of a query, with what to do on a
+// duplicate key in the style of one dialect or the other.
+func (d *sqlScript) insert(depth int) {
+	s := d.s
+	verb := "INSERT"
+	if d.is(mysql) && s.Chance(0.2) {
+		verb = gen.Pick(s, "INSERT IGNORE", "REPLACE", "INSERT LOW_PRIORITY")
+	}
+	d.write("%s %s %s", d.kw(verb), d.kw("INTO"), d.table())
+	cols := s.Range(1, 4)
+	if s.Chance(0.8) {
+		d.write(" (%s)", d.names(cols))
+	}
+	switch {
+	case s.Chance(0.2):
+		d.b.WriteString(d.sp())
+		d.query(depth)
+	case s.Chance(0.1):
+		d.write(" %s", d.kw("DEFAULT VALUES"))
+	default:
+		d.write(" %s ", d.kw(gen.Pick(s, "VALUES", "VALUES", "VALUE")))
+		for i := range s.Range(1, 4) {
+			if i > 0 {
+				d.b.WriteString(gen.Pick(s, ", ", ",\n  "))
+			}
+			d.b.WriteString("(")
+			d.exprs(depth, cols)
+			d.b.WriteString(")")
+		}
+	}
+	if s.Chance(0.3) {
+		if d.is(mysql) {
+			d.write(" %s %s = ", d.kw("ON DUPLICATE KEY UPDATE"), d.ident())
+			d.b.WriteString(gen.Pick(s, "VALUES(a)", "a + 1", "NULL"))
+		} else {
+			d.write(" %s (%s) %s", d.kw("ON CONFLICT"), d.ident(), d.kw(gen.Pick(s, "DO NOTHING", "DO UPDATE SET a = EXCLUDED.a")))
+		}
+	}
+	d.returning(depth)
+}
+
+// update writes an UPDATE, joined to other tables now and then.
+func (d *sqlScript) update(depth int) {
+	s := d.s
+	d.write("%s %s", d.kw("UPDATE"), d.table())
+	if d.is(mysql) && s.Chance(0.2) {
+		d.write(" %s %s %s ", d.kw("JOIN"), d.table(), d.kw("ON"))
+		d.cond(0)
+	}
+	d.write(" %s ", d.kw("SET"))
+	for i := range s.Range(1, 3) {
+		if i > 0 {
+			d.b.WriteString(", ")
+		}
+		d.write("%s = ", d.ident())
+		d.expr(depth)
+	}
+	if d.is(postgres) && s.Chance(0.2) {
+		d.write(" %s ", d.kw("FROM"))
+		d.from(depth)
+	}
+	if s.Chance(0.8) {
+		d.write(" %s ", d.kw("WHERE"))
+		d.cond(depth)
+	}
+	if d.is(mysql) && s.Chance(0.2) {
+		d.write(" %s %s", d.kw("LIMIT"), d.count())
+	}
+	d.returning(depth)
+}
+
+// delete writes a DELETE.
+func (d *sqlScript) delete(depth int) {
+	s := d.s
+	d.write("%s %s %s", d.kw("DELETE"), d.kw("FROM"), d.table())
+	if d.is(postgres) && s.Chance(0.2) {
+		d.write(" %s ", d.kw("USING"))
+		d.from(depth)
+	}
+	if s.Chance(0.8) {
+		d.write(" %s ", d.kw("WHERE"))
+		d.cond(depth)
+	}
+	if d.is(mysql) && s.Chance(0.2) {
+		d.orderBy(0)
+		d.write(" %s %s", d.kw("LIMIT"), d.count())
+	}
+	d.returning(depth)
+}
+
+// returning writes a RETURNING clause now and then.
+func (d *sqlScript) returning(depth int) {
+	if d.is(postgres) && d.s.Chance(0.2) {
+		d.write(" %s ", d.kw("RETURNING"))
+		if d.s.Chance(0.5) {
+			d.b.WriteString("*")
+			return
+		}
+		d.exprs(depth, d.s.Range(1, 2))
+	}
+}
+
+// create writes a CREATE TABLE, or an index or view.
+func (d *sqlScript) create() {
+	s := d.s
+	switch s.Intn(5) {
+	case 0:
+		d.write("%s %s %s %s (%s)", d.kw(gen.Pick(s, "CREATE INDEX", "CREATE UNIQUE INDEX")), d.ident(), d.kw("ON"), d.table(), d.names(s.Range(1, 2)))
+		return
+	case 1:
+		d.write("%s %s %s ", d.kw(gen.Pick(s, "CREATE VIEW", "CREATE OR REPLACE VIEW")), d.table(), d.kw("AS"))
+		d.query(1)
+		return
+	}
+	d.write("%s ", d.kw("CREATE"))
+	if s.Chance(0.1) {
+		d.write("%s ", d.kw(gen.Pick(s, "TEMPORARY", "TEMP", "UNLOGGED")))
+	}
+	d.write("%s ", d.kw("TABLE"))
+	if s.Chance(0.3) {
+		d.write("%s ", d.kw("IF NOT EXISTS"))
+	}
+	d.write("%s (", d.table())
+	for i := range s.Range(1, 5) {
+		if i > 0 {
+			d.b.WriteString(gen.Pick(s, ", ", ",\n  "))
+		}
+		d.write("%s %s", d.ident(), d.typ())
+		for range s.Range(0, 2) {
+			d.b.WriteString(" ")
+			switch s.Intn(8) {
+			case 0:
+				d.b.WriteString(d.kw("NOT NULL"))
+			case 1:
+				d.b.WriteString(d.kw("PRIMARY KEY"))
+			case 2:
+				d.write("%s %s", d.kw("DEFAULT"), gen.Pick(s, d.str, d.number)())
+			case 3:
+				d.write("%s %s", d.kw("COMMENT"), d.str())
+			case 4:
+				d.write("%s (%s > 0)", d.kw("CHECK"), d.ident())
+			case 5:
+				d.b.WriteString(d.kw(gen.Pick(s, "AUTO_INCREMENT", "UNIQUE", "NULL", "GENERATED ALWAYS AS IDENTITY")))
+			case 6:
+				d.write("%s %s(%s)", d.kw("REFERENCES"), d.table(), d.ident())
+			default:
+				d.write("%s %s", d.kw("COLLATE"), gen.Pick(s, `"C"`, "utf8mb4_unicode_ci", `"en_US"`))
+			}
+		}
+	}
+	if s.Chance(0.3) {
+		d.write(", %s (%s)", d.kw(gen.Pick(s, "PRIMARY KEY", "UNIQUE", "KEY k", "INDEX i")), d.names(s.Range(1, 2)))
+	}
+	d.b.WriteString(")")
+	if d.is(mysql) && s.Chance(0.3) {
+		d.write(" %s", gen.Pick(s, "ENGINE=InnoDB", "DEFAULT CHARSET=utf8mb4", "ENGINE=InnoDB DEFAULT CHARSET=latin1 COLLATE=latin1_bin"))
+	}
+}
+
+// misc writes a statement of transactions, settings or introspection.
+func (d *sqlScript) misc() {
+	s := d.s
+	if d.is(mysql) {
+		d.b.WriteString(gen.Pick(s,
+			"BEGIN", "COMMIT", "ROLLBACK", "START TRANSACTION", "SET NAMES utf8mb4", "SET NAMES gbk",
+			"SET @v = 'x'", "SET SESSION sql_mode = 'NO_BACKSLASH_ESCAPES'", "SET autocommit = 0",
+			"SHOW TABLES", "SHOW DATABASES", "SHOW CREATE TABLE t", "USE db", "USE `d b`", "DROP TABLE IF EXISTS t",
+			"TRUNCATE TABLE t", "ALTER TABLE t ADD COLUMN c INT", "RENAME TABLE a TO b",
+		))
+		return
+	}
+	d.b.WriteString(gen.Pick(s,
+		"BEGIN", "COMMIT", "ROLLBACK", "BEGIN TRANSACTION ISOLATION LEVEL SERIALIZABLE", "SAVEPOINT s", "RELEASE SAVEPOINT s",
+		"SET standard_conforming_strings = off", "SET search_path = public, \"$user\"", "SET TIME ZONE 'UTC'", "SHOW server_version",
+		"DROP TABLE IF EXISTS t CASCADE", "TRUNCATE t RESTART IDENTITY", "ALTER TABLE t ADD COLUMN c INT DEFAULT 0", "PREPARE p AS SELECT $1",
+		"EXECUTE p(1)", "DEALLOCATE p", "GRANT SELECT ON t TO public", "COMMENT ON TABLE t IS 'it''s'",
+		"DO $$BEGIN RAISE NOTICE 'x'; END$$", "DO $do$ DECLARE n INT := 0; BEGIN WHILE n < 3 LOOP n := n + 1; END LOOP; END $do$",
+		"DO LANGUAGE plpgsql $$ BEGIN EXECUTE 'SELECT ' || quote_literal('it''s'); END $$",
+	))
+}
@@ -0,0 +1,68 @@
+package sshsrc
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// message writes one message of the key exchange, as the client and
+// server seeds carry them but with lengths, mpints and name-lists drawn
+// wrong more often, and now and then with bytes after its end or cut
+// short.
+func message(s *gen.State) []gen.File {
+	g := &sgen{s: s, bad: messageBadRate}
+	kex := g.kex()
+	var msgs [][]byte
+	switch s.Intn(5) {
+	case 0:
+		msgs = [][]byte{g.extInfo()}
+	case 1:
+		algo, _, _ := g.hostKey()
+		msgs = [][]byte{g.kexInit([]string{kex, gen.Pick(s, "ext-info-c", "kex-strict-s-v00@openssh.com")}, []string{algo}, 3)}
+	case 2, 3:
+		msgs = g.clientKex(kex)
+	default:
+		_, key, sig := g.hostKey()
+		msgs = g.serverKex(kex, key, sig)
+	}
+	b := gen.Pick(s, msgs...)
+	switch {
+	case s.Chance(0.05):
+		b = append(b, g.bytes(s.Range(1, 8))...)
+	case s.Chance(0.05):
+		b = b[:s.Intn(len(b))]
+	}
+	return []gen.File{{Name: "message.ssh", Data: b}}
+}
+
+// extInfo returns an EXT_INFO: a count of extensions, now and then not
+// the number that follow, and the name and value of each.
+func (g *sgen) extInfo() []byte {
+	s := g.s
+	n := s.Range(0, 3)
+	var exts [][]byte
+	for range n {
+		name := gen.Pick(s, "server-sig-algs", "delay-compression", "no-flow-control", "elevation", "global-requests-ok", "publickey-hostbound@openssh.com", "ping@openssh.com")
+		var value string
+		switch name {
+		case "server-sig-algs":
+			value = strings.Join(sigAlgos[:s.Range(1, len(sigAlgos))], ",")
+		case "delay-compression":
+			value = string(slices.Concat(g.names([]string{"zlib@openssh.com"}, compressions, 0, 1), g.names([]string{"zlib@openssh.com"}, compressions, 0, 1)))
+		case "no-flow-control":
+			value = gen.Pick(s, "s", "p")
+		case "elevation":
+			value = gen.Pick(s, "y", "n", "d")
+		default:
+			value = "0"
+		}
+		exts = append(exts, g.str([]byte(name)), g.str([]byte(value)))
+	}
+	count := uint32(n)
+	if s.Chance(g.bad) {
+		count = gen.Pick(s, count+1, max(count, 1)-1, 0xffffffff)
+	}
+	return slices.Concat([]byte{msgExtInfo}, u32(count), slices.Concat(exts...))
+}
@@ -0,0 +1,619 @@
+// Package sshsrc generates seeds of the SSH transport protocol. It
+// registers the "ssh/..." generators with package gen.
+//
+// "ssh/client" writes what a client sends before it needs an answer,
+// client.ssh: its identification string, then binary packets holding a
+// KEXINIT, the first message of the key exchange it prefers and a
+// NEWKEYS. "ssh/server" writes what a server sends, server.ssh: lines
+// before its identification string now and then, the string, a KEXINIT,
+// the reply of the key exchange it offers, with a host key and signature
+// of the right shape, and a NEWKEYS. IGNORE, DEBUG, UNIMPLEMENTED and
+// unknown messages come among the others. Identification strings carry
+// comments, other protocol versions, bytes past ASCII and NULs, end in a
+// bare line feed or come close to the 255 bytes a reader takes, and pass
+// it; packets have padding that is too short, too long or leaves them off
+// a multiple of eight, and lengths of zero or past what a reader takes.
+//
+// "ssh/message" writes one message of the key exchange, message.ssh, for
+// code that decodes messages: EXT_INFO, KEXINIT, and the Diffie-Hellman,
+// ECDH and group exchange messages.
+//
+// Public values are points on the curve, ML-KEM keys a decoder accepts,
+// and for Diffie-Hellman numbers at the bounds of the group and past
+// them, negative ones and ones thousands of bits long. A few mpints take
+// a byte more than they need, name-lists hold empty names, names past 64
+// bytes and ones that are not ASCII, and strings have lengths that
+// overrun what holds them.
+package sshsrc
+
+import (
+	"crypto/ecdh"
+	"crypto/ed25519"
+	"crypto/mlkem"
+	"encoding/binary"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "ssh/client",
+		Doc:  "what an SSH client sends before the keys change: identification strings, KEXINIT, Diffie-Hellman, ECDH, ML-KEM and group exchange messages with public values at and past their bounds, NEWKEYS, IGNORE and DEBUG messages, and bad packet framing",
+		Func: client,
+	})
+	gen.Register(&gen.Generator{
+		Name: "ssh/server",
+		Doc:  "what an SSH server sends before the keys change: banner lines, identification strings, KEXINIT, key exchange replies with Ed25519, ECDSA and RSA host keys and signatures, group exchange groups, NEWKEYS, and bad packet framing",
+		Func: server,
+	})
+	gen.Register(&gen.Generator{
+		Name: "ssh/message",
+		Doc:  "single SSH key exchange messages: EXT_INFO, KEXINIT, Diffie-Hellman, ECDH and group exchange messages, with negative, non-minimal and huge mpints, odd name-lists, overrunning strings and trailing bytes",
+		Func: message,
+	})
+}
+
+// badRate is the chance that a length, an mpint or a name-list of a
+// transport seed is drawn wrong. A seed has about forty of them, so about
+// one in five gets one.
+const badRate = 0.005
+
+// messageBadRate is badRate for "ssh/message", whose seeds are a single
+// message with a few lengths, so about one in five of them gets one.
+const messageBadRate = 0.05
+
+// framingRate is the chance that a packet is framed wrong.
+const framingRate = 0.02
+
+// Message numbers.
+const (
+	msgIgnore        = 2
+	msgUnimplemented = 3
+	msgDebug         = 4
+	msgExtInfo       = 7
+	msgKexInit       = 20
+	msgNewKeys       = 21
+	msgKexDHInit     = 30 // KEXDH_INIT and KEX_ECDH_INIT
+	msgKexDHReply    = 31 // KEXDH_REPLY, KEX_ECDH_REPLY and KEX_DH_GEX_GROUP
+	msgKexGexInit    = 32
+	msgKexGexReply   = 33
+	msgKexGexRequest = 34
+)
+
+// Algorithm names: those x/crypto/ssh implements and some it does not.
+var (
+	kexAlgos = []string{
+		"mlkem768x25519-sha256", "curve25519-sha256", "curve25519-sha256@libssh.org",
+		"ecdh-sha2-nistp256", "ecdh-sha2-nistp384", "ecdh-sha2-nistp521",
+		"diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512",
+		"diffie-hellman-group14-sha1", "diffie-hellman-group1-sha1",
+		"diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1",
+	}
+	otherKexAlgos = []string{"sntrup761x25519-sha512@openssh.com", "diffie-hellman-group18-sha512"}
+	hostKeyAlgos  = []string{
+		"ssh-ed25519", "ecdsa-sha2-nistp256", "ecdsa-sha2-nistp384", "rsa-sha2-256", "rsa-sha2-512", "ssh-rsa",
+		"ssh-ed25519-cert-v01@openssh.com", "sk-ssh-ed25519@openssh.com", "ssh-dss",
+	}
+	ciphers = []string{
+		"aes128-gcm@openssh.com", "aes256-gcm@openssh.com", "chacha20-poly1305@openssh.com",
+		"aes128-ctr", "aes192-ctr", "aes256-ctr", "aes128-cbc", "3des-cbc", "arcfour", "none",
+	}
+	macs = []string{
+		"hmac-sha2-256-etm@openssh.com", "hmac-sha2-512-etm@openssh.com", "hmac-sha2-256", "hmac-sha2-512",
+		"hmac-sha1", "hmac-sha1-96", "umac-64@openssh.com", "none",
+	}
+	compressions = []string{"none", "zlib@openssh.com", "zlib"}
+	languages    = []string{"en-US", "en", "i-default"}
+	sigAlgos     = []string{"ssh-ed25519", "ecdsa-sha2-nistp256", "rsa-sha2-256", "rsa-sha2-512", "sk-ssh-ed25519@openssh.com"}
+
+	// oddNames break the rules for names: empty, longer than 64 bytes,
+	// not ASCII, with a space or a NUL, or an @ with nothing around it.
+	oddNames = []string{"", strings.Repeat("x", 65), "ключ-sha256", "aes128 ctr", "none\x00", "@", "x@", "@x"}
+)
+
+// The Diffie-Hellman groups of RFC 2409 and RFC 3526: group 2, which
+// diffie-hellman-group1-sha1 names, and groups 14, 15 and 16.
+var (
+	group1  = prime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE65381FFFFFFFFFFFFFFFF")
+	group14 = prime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AACAA68FFFFFFFFFFFFFFFF")
+	group15 = prime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A93AD2CAFFFFFFFFFFFFFFFF")
+	group16 = prime("FFFFFFFFFFFFFFFFC90FDAA22168C234C4C6628B80DC1CD129024E088A67CC74020BBEA63B139B22514A08798E3404DDEF9519B3CD3A431B302B0A6DF25F14374FE1356D6D51C245E485B576625E7EC6F44C42E9A637ED6B0BFF5CB6F406B7EDEE386BFB5A899FA5AE9F24117C4B1FE649286651ECE45B3DC2007CB8A163BF0598DA48361C55D39A69163FA8FD24CF5F83655D23DCA3AD961C62F356208552BB9ED529077096966D670C354E4ABC9804F1746C08CA18217C32905E462E36CE3BE39E772C180E86039B2783A2EC07A28FB5C55DF06F4C52C9DE2BCBF6955817183995497CEA956AE515D2261898FA051015728E5A8AAAC42DAD33170D04507A33A85521ABDF1CBA64ECFB850458DBEF0A8AEA71575D060C7DB3970F85A6E1E4C7ABF5AE8CDB0933D71E8C94E04A25619DCEE3D2261AD2EE6BF12FFA06D98A0864D87602733EC86A64521F2B18177B200CBBE117577A615D6C770988C0BAD946E208E24FA074E5AB3143DB5BFCE0FD108E4B82D120A92108011A723C12A787E6D788719A10BDBA5B2699C327186AF4E23C1A946834B6150BDA2583E9CA2AD44CE8DBBBC2DB04DE8EF92E8EFC141FBECAA6287C59474E6BC05D99B2964FA090C3A2233BA186515BE7ED1F612970CEE2D7AFB81BDD762170481CD0069127D5B05AA993B4EA988D8FDDC186FFB7DC90A6C08F4DF435C934063199FFFFFFFFFFFFFFFF")
+)
+
+func prime(hex string) *big.Int {
+	p, ok := new(big.Int).SetString(hex, 16)
+	if !ok {
+		panic("sshsrc: bad prime " + hex)
+	}
+	return p
+}
+
+// Public keys made from fixed private keys: points on the curves, which
+// random bytes almost never are, an ML-KEM encapsulation key, whose
+// coefficients random bytes almost never keep in range, and the Ed25519
+// key of the server seeds.
+var (
+	x25519Point = point(ecdh.X25519(), 32)
+	p256Point   = point(ecdh.P256(), 32)
+	p384Point   = point(ecdh.P384(), 48)
+	p521Point   = point(ecdh.P521(), 66)
+	mlkem768    = must(mlkem.NewDecapsulationKey768(make([]byte, mlkem.SeedSize))).EncapsulationKey().Bytes()
+	ed25519Key  = ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)).Public().(ed25519.PublicKey)
+)
+
+// point returns the public key of the private key 1 on c.
+func point(c ecdh.Curve, size int) []byte {
+	k := make([]byte, size)
+	k[size-1] = 1
+	return must(c.NewPrivateKey(k)).PublicKey().Bytes()
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// An sgen builds the messages of one seed.
+type sgen struct {
+	s   *gen.State
+	bad float64 // the chance that a length, an mpint or a name-list is drawn wrong
+}
+
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+
+// bytes returns n random bytes.
+func (g *sgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(g.s.Intn(256))
+	}
+	return b
+}
+
+// str returns body as a string, preceded by its length, now and then a
+// length past the end of body, short of it, or the largest there is.
+func (g *sgen) str(body ...[]byte) []byte {
+	b := slices.Concat(body...)
+	n := uint32(len(b))
+	if g.s.Chance(g.bad) {
+		n = gen.Pick(g.s, n+1, max(n, 1)-1, 0x7fffffff, 0xffffffff, 0)
+	}
+	return append(u32(n), b...)
+}
+
+// names returns a name-list of first and a few names from from. Now and
+// then it is empty, or holds a name twice or one that breaks the rules.
+func (g *sgen) names(first, from []string, lo, hi int) []byte {
+	s := g.s
+	names := slices.Clone(first)
+	for range s.Range(lo, hi) {
+		names = append(names, gen.Pick(s, from...))
+	}
+	if s.Chance(g.bad) {
+		switch s.Intn(3) {
+		case 0:
+			names = nil
+		case 1:
+			names = slices.Insert(names, s.Intn(len(names)+1), gen.Pick(s, oddNames...))
+		default:
+			if len(names) > 0 {
+				names = append(names, names[s.Intn(len(names))])
+			}
+		}
+	}
+	return g.str([]byte(strings.Join(names, ",")))
+}
+
+// mpint returns n as an mpint, now and then with a byte more than it
+// takes.
+func (g *sgen) mpint(n *big.Int) []byte {
+	b := twos(n)
+	if g.s.Chance(g.bad) {
+		pad := byte(0)
+		if n.Sign() < 0 {
+			pad = 0xff
+		}
+		b = append([]byte{pad}, b...)
+	}
+	return g.str(b)
+}
+
+// twos returns n in two's complement, big-endian, in as few bytes as keep
+// its sign: none for zero.
+func twos(n *big.Int) []byte {
+	switch n.Sign() {
+	case 0:
+		return nil
+	case 1:
+		b := n.Bytes()
+		if b[0]&0x80 != 0 {
+			b = append([]byte{0}, b...)
+		}
+		return b
+	}
+	// The bits of -n-1 flipped are those of n.
+	b := new(big.Int).Sub(new(big.Int).Neg(n), big.NewInt(1)).Bytes()
+	for i := range b {
+		b[i] ^= 0xff
+	}
+	if len(b) == 0 || b[0]&0x80 == 0 {
+		b = append([]byte{0xff}, b...)
+	}
+	return b
+}
+
+// bound returns a public value for the group of prime p: one in range
+// most of the time, else one at or past the bounds of the group, negative
+// or thousands of bits longer than p.
+func (g *sgen) bound(p *big.Int) *big.Int {
+	s := g.s
+	if s.Chance(0.7) {
+		return new(big.Int).SetBytes(g.bytes(max(p.BitLen()/8-1, 0)))
+	}
+	one := big.NewInt(1)
+	switch s.Intn(9) {
+	case 0:
+		return big.NewInt(0)
+	case 1:
+		return one
+	case 2:
+		return big.NewInt(2)
+	case 3:
+		return new(big.Int).Sub(p, big.NewInt(2))
+	case 4:
+		return new(big.Int).Sub(p, one)
+	case 5:
+		return new(big.Int).Set(p)
+	case 6:
+		return new(big.Int).Add(p, one)
+	case 7:
+		return big.NewInt(gen.Pick[int64](s, -1, -2, -128, -129, -256))
+	}
+	return new(big.Int).Lsh(one, uint(p.BitLen()+gen.Pick(s, 1, 8, 1024, 8192)))
+}
+
+// ident returns an identification string for software and its line
+// ending.
+func (g *sgen) ident(software string) []byte {
+	s := g.s
+	id := "SSH-2.0-" + software
+	switch {
+	case s.Chance(0.2):
+		id += " " + gen.Pick(s, "Ubuntu-3ubuntu13.5", "Debian-2+deb12u3", "FreeBSD-20240806")
+	case s.Chance(0.05):
+		// Lines of 250 to 256 bytes, ending included, of which a reader
+		// takes 255.
+		id += " " + strings.Repeat("x", max(gen.Pick(s, 250, 252, 253, 254, 255, 256)-3-len(id), 0))
+	case s.Chance(0.05):
+		id = gen.Pick(s, "SSH-1.99-"+software, "SSH-1.5-"+software, "SSH-3.0-"+software, "SSH-2.0-", "SSH-2.0", "SSH-", "SSH-2.0-ПО", "SSH-2.0-a\x00b", "SSH-2.0-\t")
+	}
+	end := "\r\n"
+	if s.Chance(0.1) {
+		end = gen.Pick(s, "\n", "\n", "\r\r\n", "\r")
+	}
+	return []byte(id + end)
+}
+
+// banner returns lines a server sends before its identification string,
+// which a client has to skip.
+func (g *sgen) banner() []byte {
+	var b []byte
+	for range g.s.Range(1, 3) {
+		b = append(b, gen.Pick(g.s, "Welcome\r\n", "\r\n", "\n", "Authorized use only\n", "SSH\r\n", "ssh-2.0-lowercase\r\n", strings.Repeat("=", 80)+"\r\n")...)
+	}
+	return b
+}
+
+// kexInit returns a KEXINIT offering the key exchanges kex and the host
+// key algorithms hostKeys first, then up to more others of each, and a
+// cipher and MAC both sides know. Now and then it says a guessed key
+// exchange message follows.
+func (g *sgen) kexInit(kex, hostKeys []string, more int) []byte {
+	s := g.s
+	cipher := []string{gen.Pick(s, ciphers[:6]...)}
+	mac := []string{gen.Pick(s, macs[:5]...)}
+	follows := byte(0)
+	if s.Chance(0.05) {
+		follows = gen.Pick[byte](s, 1, 1, 2, 0xff)
+	}
+	reserved := uint32(0)
+	if s.Chance(0.02) {
+		reserved = gen.Pick[uint32](s, 1, 0xffffffff)
+	}
+	return slices.Concat([]byte{msgKexInit}, g.bytes(16),
+		g.names(kex, kexAlgos, 0, more),
+		g.names(hostKeys, hostKeyAlgos, 0, more),
+		g.names(cipher, ciphers, 0, 3), g.names(cipher, ciphers, 0, 3),
+		g.names(mac, macs, 0, 3), g.names(mac, macs, 0, 3),
+		g.names([]string{"none"}, compressions, 0, 1), g.names([]string{"none"}, compressions, 0, 1),
+		g.names(nil, languages, 0, gen.Pick(s, 0, 0, 0, 1)), g.names(nil, languages, 0, gen.Pick(s, 0, 0, 0, 1)),
+		[]byte{follows}, u32(reserved),
+	)
+}
+
+// kex returns a key exchange for a seed to run: one x/crypto/ssh
+// implements, most of the time.
+func (g *sgen) kex() string {
+	if g.s.Chance(0.05) {
+		return gen.Pick(g.s, otherKexAlgos...)
+	}
+	return gen.Pick(g.s, kexAlgos...)
+}
+
+// group returns the prime of the Diffie-Hellman key exchange kex.
+func group(kex string) *big.Int {
+	switch kex {
+	case "diffie-hellman-group1-sha1":
+		return group1
+	case "diffie-hellman-group16-sha512", "diffie-hellman-group18-sha512":
+		return group16
+	}
+	return group14
+}
+
+// ecdhPublic returns an ephemeral public key for the key exchange kex,
+// a server's or a client's, now and then one of the wrong length or the
+// X25519 point of order one.
+func (g *sgen) ecdhPublic(kex string, server bool) []byte {
+	s := g.s
+	if s.Chance(0.03) {
+		return g.bytes(gen.Pick(s, 0, 1, 31, 33, 65))
+	}
+	x := x25519Point
+	if s.Chance(0.03) {
+		x = make([]byte, 32)
+	}
+	switch kex {
+	case "curve25519-sha256", "curve25519-sha256@libssh.org":
+		return x
+	case "mlkem768x25519-sha256":
+		if server {
+			// Any bytes decapsulate.
+			return slices.Concat(g.bytes(mlkem.CiphertextSize768), x)
+		}
+		return slices.Concat(mlkem768, x)
+	case "ecdh-sha2-nistp256":
+		return p256Point
+	case "ecdh-sha2-nistp384":
+		return p384Point
+	case "ecdh-sha2-nistp521":
+		return p521Point
+	}
+	return g.bytes(32)
+}
+
+// clientKex returns what a client sends to run the key exchange kex: a
+// public value, or for the group exchange a request for a group and a
+// public value in the group the request gets.
+func (g *sgen) clientKex(kex string) [][]byte {
+	s := g.s
+	switch kex {
+	case "diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1", "diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512", "diffie-hellman-group18-sha512":
+		return [][]byte{slices.Concat([]byte{msgKexDHInit}, g.mpint(g.bound(group(kex))))}
+	case "diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1":
+		lo, pref, hi := uint32(2048), gen.Pick[uint32](s, 2048, 3072, 4096, 8192), uint32(8192)
+		if s.Chance(0.2) {
+			hi = gen.Pick[uint32](s, 2048, 3071, 3072, 4095, 4096)
+			pref = min(pref, hi)
+		}
+		if s.Chance(0.1) {
+			// Requests a server has to refuse, or nearly so.
+			r := gen.Pick(s, [3]uint32{0, 0, 0}, [3]uint32{1024, 1024, 1024}, [3]uint32{2048, 1024, 8192}, [3]uint32{8192, 4096, 2048},
+				[3]uint32{4096, 4096, 4096}, [3]uint32{4097, 8192, 8192}, [3]uint32{0, 0, 0xffffffff}, [3]uint32{0xffffffff, 0xffffffff, 0xffffffff})
+			lo, pref, hi = r[0], r[1], r[2]
+		}
+		p := group14
+		switch {
+		case hi >= 4096:
+			p = group16
+		case hi >= 3072:
+			p = group15
+		}
+		return [][]byte{
+			slices.Concat([]byte{msgKexGexRequest}, u32(lo), u32(pref), u32(hi)),
+			slices.Concat([]byte{msgKexGexInit}, g.mpint(g.bound(p))),
+		}
+	}
+	return [][]byte{slices.Concat([]byte{msgKexDHInit}, g.str(g.ecdhPublic(kex, false)))}
+}
+
+// serverKex returns what a server sends to run the key exchange kex with
+// the host key and signature blobs key and sig: a reply, or for the group
+// exchange a group and a reply.
+func (g *sgen) serverKex(kex string, key, sig []byte) [][]byte {
+	s := g.s
+	switch kex {
+	case "diffie-hellman-group1-sha1", "diffie-hellman-group14-sha1", "diffie-hellman-group14-sha256", "diffie-hellman-group16-sha512", "diffie-hellman-group18-sha512":
+		return [][]byte{slices.Concat([]byte{msgKexDHReply}, g.str(key), g.mpint(g.bound(group(kex))), g.str(sig))}
+	case "diffie-hellman-group-exchange-sha256", "diffie-hellman-group-exchange-sha1":
+		p := gen.Pick(s, group14, group15, group16)
+		base := big.NewInt(2)
+		if s.Chance(0.1) {
+			// Groups a client has to refuse: one too small, an even
+			// modulus, one past 8192 bits, and generators that are not.
+			p = gen.Pick(s, group1, new(big.Int).Add(group14, big.NewInt(1)), new(big.Int).Lsh(big.NewInt(1), 8193), big.NewInt(0))
+		}
+		if s.Chance(0.1) {
+			base = gen.Pick(s, big.NewInt(0), big.NewInt(1), new(big.Int).Sub(p, big.NewInt(1)), big.NewInt(5), big.NewInt(-2))
+		}
+		return [][]byte{
+			slices.Concat([]byte{msgKexDHReply}, g.mpint(p), g.mpint(base)),
+			slices.Concat([]byte{msgKexGexReply}, g.str(key), g.mpint(g.bound(p)), g.str(sig)),
+		}
+	}
+	return [][]byte{slices.Concat([]byte{msgKexDHReply}, g.str(key), g.str(g.ecdhPublic(kex, true)), g.str(sig))}
+}
+
+// hostKey returns the name of a host key algorithm, a public key blob of
+// it and a signature blob of the shape its signatures have. Now and then
+// the key is of an unknown type, short or not a key at all.
+func (g *sgen) hostKey() (algo string, key, sig []byte) {
+	s := g.s
+	switch s.Intn(3) {
+	case 0:
+		algo = "ssh-ed25519"
+		key = slices.Concat(g.str([]byte(algo)), g.str(ed25519Key))
+		sig = slices.Concat(g.str([]byte(algo)), g.str(g.bytes(ed25519.SignatureSize)))
+	case 1:
+		algo = "ecdsa-sha2-nistp256"
+		key = slices.Concat(g.str([]byte(algo)), g.str([]byte("nistp256")), g.str(p256Point))
+		rs := slices.Concat(g.mpint(new(big.Int).SetBytes(g.bytes(32))), g.mpint(new(big.Int).SetBytes(g.bytes(32))))
+		sig = slices.Concat(g.str([]byte(algo)), g.str(rs))
+	default:
+		// A modulus of 2048 bits, odd, which is all a parser can check.
+		n := new(big.Int).SetBytes(g.bytes(256))
+		n.SetBit(n, 2047, 1)
+		n.SetBit(n, 0, 1)
+		algo = gen.Pick(s, "rsa-sha2-256", "rsa-sha2-512", "ssh-rsa")
+		key = slices.Concat(g.str([]byte("ssh-rsa")), g.mpint(big.NewInt(65537)), g.mpint(n))
+		sig = slices.Concat(g.str([]byte(algo)), g.str(g.bytes(256)))
+	}
+	if s.Chance(0.05) {
+		key = gen.Pick(s, g.bytes(s.Range(0, 40)), key[:s.Intn(len(key))],
+			slices.Concat(g.str([]byte(gen.Pick(s, "ssh-dss", "ssh-ed25519-cert-v01@openssh.com", "unknown"))), g.str(g.bytes(32))),
+			slices.Concat(g.str([]byte("ssh-ed25519")), g.str(g.bytes(gen.Pick(s, 0, 31, 33)))))
+	}
+	return algo, key, sig
+}
+
+// interloper returns a message that may come anywhere: IGNORE, DEBUG,
+// UNIMPLEMENTED, or one of a number nobody uses.
+func (g *sgen) interloper() []byte {
+	s := g.s
+	switch s.Intn(4) {
+	case 0:
+		return slices.Concat([]byte{msgIgnore}, g.str(g.bytes(s.Range(0, 64))))
+	case 1:
+		msg := gen.Pick(s, "debug", "", "ünïcode", strings.Repeat("d", 300))
+		return slices.Concat([]byte{msgDebug, byte(s.Intn(2))}, g.str([]byte(msg)), g.str([]byte(gen.Pick(s, "", "en"))))
+	case 2:
+		return slices.Concat([]byte{msgUnimplemented}, u32(uint32(s.Range(0, 8))))
+	}
+	return slices.Concat([]byte{gen.Pick[byte](s, 0, 8, 19, 29, 49, 50, 80, 255)}, g.bytes(s.Range(0, 8)))
+}
+
+// newKeys returns a NEWKEYS, now and then with a byte after it.
+func (g *sgen) newKeys() []byte {
+	if g.s.Chance(0.02) {
+		return []byte{msgNewKeys, 0}
+	}
+	return []byte{msgNewKeys}
+}
+
+// packets frames msgs as binary packets, with interlopers among them now
+// and then.
+func (g *sgen) packets(msgs [][]byte) []byte {
+	var b []byte
+	for _, m := range msgs {
+		if g.s.Chance(0.1) {
+			b = append(b, g.packet(g.interloper())...)
+		}
+		b = append(b, g.packet(m)...)
+	}
+	return b
+}
+
+// packet returns payload as a binary packet without a MAC, as packets are
+// before the first NEWKEYS: its length, the length of its padding, the
+// payload and padding of at least four bytes that brings the whole to a
+// multiple of eight, now and then more of it than it takes. Now and then
+// the padding is too short, leaves the packet off a multiple of eight or
+// says it is longer than the packet, or the length is zero or more than
+// a reader takes, with a little of the packet after it.
+func (g *sgen) packet(payload []byte) []byte {
+	s := g.s
+	pad := 8 - (5+len(payload))%8
+	if pad < 4 {
+		pad += 8
+	}
+	if s.Chance(0.1) {
+		pad += 8 * s.Range(1, 30)
+	}
+	padLen := pad
+	if s.Chance(framingRate) {
+		switch s.Intn(4) {
+		case 0:
+			pad = s.Range(0, 3)
+			padLen = pad
+		case 1:
+			pad += s.Range(1, 7)
+			padLen = pad
+		case 2:
+			padLen = min(gen.Pick(s, 255, len(payload)+pad, len(payload)+pad+1), 255)
+		default:
+			n := gen.Pick[uint32](s, 0, 1, 256*1024+1, 35000*8, 0xffffffff)
+			return slices.Concat(u32(n), []byte{byte(pad)}, payload[:min(len(payload), 16)])
+		}
+	}
+	return slices.Concat(u32(uint32(1+len(payload)+pad)), []byte{byte(padLen)}, payload, g.bytes(pad))
+}
+
+// client writes what a client sends before the keys change.
+func client(s *gen.State) []gen.File {
+	g := &sgen{s: s, bad: badRate}
+	var b []byte
+	if s.Chance(0.02) {
+		b = append(b, g.banner()...)
+	}
+	b = append(b, g.ident(gen.Pick(s, "OpenSSH_9.6", "OpenSSH_8.9p1", "PuTTY_Release_0.81", "libssh_0.10.6", "Go"))...)
+	kex := g.kex()
+	first := []string{kex}
+	if s.Chance(0.7) {
+		first = append(first, "ext-info-c")
+	}
+	if s.Chance(0.7) {
+		first = append(first, "kex-strict-c-v00@openssh.com")
+	}
+	// The server fuzz/ssh drives has an Ed25519 host key.
+	hostKey := "ssh-ed25519"
+	if s.Chance(0.1) {
+		hostKey = gen.Pick(s, "ecdsa-sha2-nistp256", "rsa-sha2-256")
+	}
+	msgs := [][]byte{g.kexInit(first, []string{hostKey}, 3)}
+	msgs = append(msgs, g.clientKex(kex)...)
+	msgs = append(msgs, g.newKeys())
+	b = append(b, g.packets(msgs)...)
+	return []gen.File{{Name: "client.ssh", Data: g.end(b)}}
+}
+
+// server writes what a server sends before the keys change.
+func server(s *gen.State) []gen.File {
+	g := &sgen{s: s, bad: badRate}
+	var b []byte
+	if s.Chance(0.2) {
+		b = append(b, g.banner()...)
+	}
+	b = append(b, g.ident(gen.Pick(s, "OpenSSH_9.6", "OpenSSH_9.9", "dropbear_2022.83", "Go"))...)
+	kex := g.kex()
+	first := []string{kex}
+	if s.Chance(0.7) {
+		first = append(first, "kex-strict-s-v00@openssh.com")
+	}
+	// A client takes the first algorithms of its own it finds among
+	// those offered, so offering others now and then gets a reply of one
+	// it did not agree to.
+	algo, key, sig := g.hostKey()
+	msgs := [][]byte{g.kexInit(first, []string{algo}, gen.Pick(s, 0, 0, 0, 0, 0, 0, 0, 0, 1, 2))}
+	msgs = append(msgs, g.serverKex(kex, key, sig)...)
+	msgs = append(msgs, g.newKeys())
+	b = append(b, g.packets(msgs)...)
+	return []gen.File{{Name: "server.ssh", Data: g.end(b)}}
+}
+
+// end follows b with bytes that stand for encrypted packets, which
+// nobody can read, now and then, or cuts it short.
+func (g *sgen) end(b []byte) []byte {
+	s := g.s
+	switch {
+	case s.Chance(0.3):
+		return append(b, g.bytes(s.Range(16, 128))...)
+	case s.Chance(0.03):
+		return b[:s.Intn(len(b))]
+	}
+	return b
+}
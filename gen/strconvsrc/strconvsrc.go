@@ -0,0 +1,252 @@
+// Package strconvsrc generates seeds for the number and quote parsers of
+// strconv. It registers the "strconv/..." generators with package gen.
+//
+// "strconv/float" writes a floating-point number, input.float, as
+// ParseFloat reads it: the largest and smallest normal and subnormal
+// values of float32 and float64 and the numbers halfway between them and
+// their neighbours, ties that round to even only if every one of hundreds
+// of digits is read, hexadecimal mantissas with binary exponents, and
+// exponents far past any a float can hold.
+//
+// "strconv/int" writes an integer, input.int, as ParseInt and ParseUint
+// read it in base 0, 10 or 16: values at the limits of each integer size,
+// base prefixes and underscores, and signs where ParseUint takes none.
+//
+// "strconv/quoted" writes a quoted string, input.quoted, as Unquote reads
+// it: interpreted, raw and rune literals holding every kind of escape,
+// escapes naming surrogates and code points past the last, quotes of the
+// wrong kind, invalid UTF-8 and literals cut short.
+package strconvsrc
+
+import (
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "strconv/float",
+		Doc:  "strconv.ParseFloat text: float32 and float64 limits, subnormal boundaries and the halfway points beside them, long rounding ties, hexadecimal floats, huge exponents, infinities and NaNs",
+		Func: float,
+	})
+	gen.Register(&gen.Generator{
+		Name: "strconv/int",
+		Doc:  "strconv.ParseInt and ParseUint text: values at the limits of every integer size, base prefixes, underscores in and out of place, and signs",
+		Func: integer,
+	})
+	gen.Register(&gen.Generator{
+		Name: "strconv/quoted",
+		Doc:  "strconv.Unquote text: interpreted, raw and rune literals with every escape, surrogate and out-of-range escapes, mismatched and missing quotes, and invalid UTF-8",
+		Func: quoted,
+	})
+}
+
+// badRate is the chance that a part of a number or literal is malformed.
+// A seed has a handful of parts, so about one in twelve gets one.
+const badRate = 0.02
+
+// floats are numbers at the float32 and float64 edges: the largest, the
+// smallest normal and subnormal, the halfway points beside them, and
+// those between two floats whose ties only the last digit breaks.
+var floats = []string{
+	"1.7976931348623157e308", "1.7976931348623158e308", "1.7976931348623159e308", "179769313486231580793728971405301e276",
+	"2.2250738585072011e-308", "2.2250738585072012e-308", "2.2250738585072014e-308",
+	"4.9406564584124654e-324", "4.9e-324", "5e-324", "2.4703282292062327e-324", "2.4703282292062328e-324",
+	"3.4028234663852886e38", "3.4028235e38", "3.4028236e38", "3.4028235677973366e38", "3.4028235677973367e38",
+	"1.1754943508222875e-38", "1.17549435e-38", "1.401298464324817e-45", "1.4e-45", "7.006492321624085e-46", "7.0064924e-46", "7.0064923e-46",
+	"9007199254740993", "9007199254740993.0000000000000000000001", "9007199254740992.9999999999999999999999", "9007199254740995",
+	"16777217", "16777217.000000000001", "33554434", "0.1", "0.3", "1e23", "8.41e21", "5e-1", "123456789012345678901234567890",
+	"0x1p-1074", "0x1p-1075", "0x1.0000000000001p-1075", "0x1.fffffffffffffp1023", "0x1.fffffffffffff8p1023", "0x1p1024",
+	"0x1p-1022", "0x0.fffffffffffffp-1022", "0x1p-149", "0x1.fffffep127", "0x1.ffffffp127", "0x1.000001p0", "0x1.0000010000000000001p0",
+	"0x.8p-1073", "0X1P+0", "0x_1p0", "0x1_0p-4", "0x1p-2_0",
+	"0", "-0", "0e999999999999", "0x0p99999999999", "inf", "-Inf", "+INF", "infinity", "-Infinity", "NaN", "nan",
+}
+
+// halfway returns the exact decimal value halfway between a float32 or
+// float64 at an edge and the next one up, a tie only the digits past the
+// seventeenth break.
+func halfway(s *gen.State) string {
+	var lo, hi float64
+	if s.Chance(0.5) {
+		f := gen.Pick(s, 0, math.SmallestNonzeroFloat64, 0x1p-1022-math.SmallestNonzeroFloat64, 0x1p-1022, 1, 0.1, 1<<53, 1e23, math.Nextafter(math.MaxFloat64, 0))
+		lo, hi = f, math.Nextafter(f, math.Inf(1))
+	} else {
+		f := gen.Pick(s, 0, math.SmallestNonzeroFloat32, 0x1p-126, 1, 0.1, 1<<24, math.Nextafter32(math.MaxFloat32, 0))
+		lo, hi = float64(f), float64(math.Nextafter32(f, float32(math.Inf(1))))
+	}
+	mid := new(big.Float).SetPrec(200).SetFloat64(lo)
+	mid.Add(mid, big.NewFloat(hi)).Quo(mid, big.NewFloat(2))
+	mant, exp, _ := strings.Cut(mid.Text('e', 1100), "e")
+	return strings.TrimRight(mant, "0") + "e" + exp
+}
+
+// exponents are decimal exponents at the float32 and float64 limits and
+// far past them, where a parser that sums them may overflow.
+var exponents = []string{
+	"308", "309", "-307", "-308", "-323", "-324", "-325", "-343", "38", "39", "-38", "-45", "-46",
+	"400", "-400", "2147483647", "-2147483648", "99999999999", "-99999999999", "9223372036854775807", "99999999999999999999999",
+}
+
+func float(s *gen.State) []gen.File {
+	var b strings.Builder
+	b.WriteString(sign(s))
+	switch {
+	case s.Chance(0.4):
+		b.WriteString(gen.Pick(s, floats...))
+	case s.Chance(0.2):
+		// A tie between two floats, or just off one, spelled out in as
+		// many digits as it takes.
+		h := halfway(s)
+		switch {
+		case s.Chance(0.3):
+			mant, exp, _ := strings.Cut(h, "e")
+			h = mant + strings.Repeat("0", s.Range(0, 100)) + "1e" + exp
+		case s.Chance(0.3):
+			mant, exp, _ := strings.Cut(h, "e")
+			h = mant[:len(mant)-1] + "4" + strings.Repeat("9", s.Range(0, 100)) + "e" + exp
+		}
+		b.WriteString(h)
+	case s.Chance(0.3):
+		b.WriteString(gen.Pick(s, "0x", "0X"))
+		b.WriteString(digitString(s, "0123456789abcdefABCDEF", true))
+		if s.Chance(0.5) {
+			b.WriteString("." + digitString(s, "0123456789abcdef", true))
+		}
+		if !s.Chance(badRate) {
+			b.WriteString(gen.Pick(s, "p", "P") + strconv.Itoa(s.Range(-1200, 1200)))
+		}
+	default:
+		b.WriteString(digitString(s, "0123456789", false))
+		if s.Chance(0.6) {
+			b.WriteString("." + digitString(s, "0123456789", false))
+		}
+		if s.Chance(0.6) {
+			b.WriteString(gen.Pick(s, "e", "E"))
+			if s.Chance(0.5) {
+				b.WriteString(gen.Pick(s, exponents...))
+			} else {
+				b.WriteString(strconv.Itoa(s.Range(-400, 400)))
+			}
+		}
+	}
+	if s.Chance(badRate) {
+		b.WriteString(gen.Pick(s, "e", "e+", "p1", ".", "..5", "_", "x", " ", "\x00", "f", "i", "e1.5"))
+	}
+	return []gen.File{{Name: "input.float", Data: []byte(b.String())}}
+}
+
+// sign returns a sign, or none.
+func sign(s *gen.State) string {
+	if s.Chance(badRate) {
+		return gen.Pick(s, "--", "+-", " ", "−", "++")
+	}
+	return gen.Pick(s, "", "", "", "-", "+")
+}
+
+// digitString returns digits drawn from set, from one to hundreds, with
+// underscores between them now and then if under is set, as only a base
+// prefix allows.
+func digitString(s *gen.State, set string, under bool) string {
+	n := gen.Pick(s, 1, 1, 2, 3, 9, 16, 17, 19, 20, 40, s.Range(1, 1000))
+	var b strings.Builder
+	for i := range n {
+		if i > 0 && (under && s.Chance(0.1) || s.Chance(badRate/8)) {
+			b.WriteByte('_')
+		}
+		b.WriteByte(set[s.Intn(len(set))])
+	}
+	return b.String()
+}
+
+// ints are integers at the limits of the integer sizes.
+var ints = []string{
+	"0", "1", "127", "128", "255", "256", "32767", "32768", "65535", "65536",
+	"2147483647", "2147483648", "4294967295", "4294967296",
+	"9223372036854775807", "9223372036854775808", "18446744073709551615", "18446744073709551616",
+	"0x7f", "0x80", "0xff", "0x7fffffffffffffff", "0x8000000000000000", "0xffffffffffffffff", "0x10000000000000000",
+	"0b11111111", "0o377", "0377", "0400", "1_000", "0x_ff", "0_7", "0b_1", "0o_7_7", "00", "09", "0x", "0b", "0o",
+}
+
+func integer(s *gen.State) []gen.File {
+	var b strings.Builder
+	b.WriteString(sign(s))
+	switch {
+	case s.Chance(0.5):
+		b.WriteString(gen.Pick(s, ints...))
+	case s.Chance(0.3):
+		p := gen.Pick(s, "0x", "0X", "0b", "0B", "0o", "0O", "0")
+		set := "01234567"
+		switch strings.ToLower(p) {
+		case "0x":
+			set = "0123456789abcdefABCDEF"
+		case "0b":
+			set = "01"
+		}
+		if s.Chance(0.2) {
+			p += "_"
+		}
+		b.WriteString(p + digitString(s, set, true))
+	default:
+		if s.Chance(0.1) {
+			b.WriteString(strings.Repeat("0", s.Range(1, 100)))
+		}
+		b.WriteString(digitString(s, "0123456789", false))
+	}
+	if s.Chance(badRate) {
+		b.WriteString(gen.Pick(s, "_", " ", "\x00", "L", "u", "e3", ".0", "z"))
+	}
+	return []gen.File{{Name: "input.int", Data: []byte(b.String())}}
+}
+
+// escapes are escapes, each valid in one kind of literal or another.
+var escapes = []string{
+	`\a`, `\b`, `\f`, `\n`, `\r`, `\t`, `\v`, `\\`, `\"`, `\'`,
+	`\x00`, `\x7f`, `\x80`, `\xff`, `\xFF`, `\000`, `\177`, `\377`,
+	`\u0000`, `\u00e9`, `\u00A0`, `\u200b`, `\ufeff`, `\ufffd`, `\uFFFF`, `\U0001F600`, `\U0010FFFF`,
+}
+
+// badEscapes are escapes no literal allows: octal past 255, surrogates,
+// code points past the last, and escapes cut short or unknown.
+var badEscapes = []string{
+	`\400`, `\777`, `\ud800`, `\udfff`, `\U00110000`, `\UFFFFFFFF`,
+	`\x`, `\x4`, `\xg0`, `\u12`, `\U0001F60`, `\0`, `\8`, `\q`, `\ `, `\`,
+}
+
+// texts are unescaped characters: printable, graphic but not printable,
+// neither, and invalid UTF-8.
+var texts = []string{
+	"a", "\u00e9", "\u20ac", "\U0001F600", " ", "\u00a0", "\u3000", "\u200b", "\ufeff", "\u0301",
+	"\x00", "\x01", "\x7f", "\t", "\r", "\n", "\x80", "\xff", "\xed\xa0\x80", "\xf4\x90\x80\x80", "\xc0\xaf",
+}
+
+func quoted(s *gen.State) []gen.File {
+	q := gen.Pick(s, `"`, `"`, `"`, "'", "`")
+	var b strings.Builder
+	n := s.Range(0, 12)
+	if q == "'" && s.Chance(0.8) {
+		n = 1
+	}
+	for range n {
+		switch {
+		case q == "`":
+			b.WriteString(gen.Pick(s, "a", `\n`, `"`, "\r", "\r\n", "\n", "\u00e9", "\x00", "\xff", "'"))
+		case s.Chance(0.4):
+			b.WriteString(gen.Pick(s, escapes...))
+		case s.Chance(badRate * 2):
+			b.WriteString(gen.Pick(s, badEscapes...))
+		case s.Chance(badRate):
+			b.WriteString(gen.Pick(s, `"`, "'", "`", "\n"))
+		default:
+			b.WriteString(gen.Pick(s, texts...))
+		}
+	}
+	text := q + b.String() + q
+	if s.Chance(badRate * 2) {
+		text = gen.Pick(s, text[:len(text)-1], text[1:], q, text+q, text+" ", " "+text, "`"+text[1:], text[:len(text)-1]+"'")
+	}
+	return []gen.File{{Name: "input.quoted", Data: []byte(text)}}
+}
@@ -0,0 +1,548 @@
+// Package tarsrc generates tar seeds. It registers the "tar/..."
+// generators with package gen.
+//
+// "tar/archive" writes one tar stream in the V7, USTAR, PAX, GNU or star
+// format, or in a mix of them: regular files, directories, links,
+// devices and FIFOs, names too long for a header carried in a USTAR
+// prefix, a PAX record or a GNU long-name entry, PAX records of every
+// key package tar reads and global headers, and sparse files in the old
+// GNU format, with extension blocks, and in the three PAX forms, 0.0,
+// 0.1 and 1.0. Numbers are octal, space- or NUL-terminated, or base-256
+// where they do not fit, and some sizes and times are far larger than
+// an archive of a few kilobytes could back. A few break the format:
+// checksums, sizes and numeric fields that are wrong, PAX records whose
+// lengths lie, sparse maps out of order or past the end of the file, and
+// streams that end without a trailer or in the middle of a block.
+package tarsrc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "tar/archive",
+		Doc:  "tar streams in V7, USTAR, PAX, GNU and star formats: long names by prefix, PAX record and GNU long-name entry, global and per-file PAX records, old GNU and PAX 0.0, 0.1 and 1.0 sparse maps, base-256 numbers, huge sizes, bad checksums and record lengths, and truncated trailers",
+		Func: archive,
+	})
+}
+
+// badRate is the chance that a field, a record or a sparse map is wrong.
+// A stream has a few dozen of them, so about one in five has one.
+const badRate = 0.005
+
+// blockSize is the size of a tar block.
+const blockSize = 512
+
+// Formats.
+const (
+	formatV7 = iota
+	formatUSTAR
+	formatPAX
+	formatGNU
+	formatSTAR
+)
+
+// Type flags.
+const (
+	typeReg       = '0'
+	typeRegA      = '\x00'
+	typeLink      = '1'
+	typeSymlink   = '2'
+	typeChar      = '3'
+	typeBlock     = '4'
+	typeDir       = '5'
+	typeFifo      = '6'
+	typeCont      = '7'
+	typeXHeader   = 'x'
+	typeXGlobal   = 'g'
+	typeLongName  = 'L'
+	typeLongLink  = 'K'
+	typeGNUSparse = 'S'
+)
+
+var (
+	names = []string{
+		"README", "main.go", "src/main.go", "src/lib/util.go", "docs/", "docs/index.html", "a/b/c/d.txt", "bin/tool",
+		"etc/config.toml", "empty", ".hidden", "日本語.txt", "Straße/ü.txt", "usr/share/doc/pkg/copyright",
+	}
+	// escapes are names that leave the directory a stream is extracted
+	// into, or that one platform or another reads as something other
+	// than a plain relative path.
+	escapes = []string{
+		"../evil", "../../../../etc/passwd", "/etc/passwd", "/", `..\..\evil.dll`, `C:\evil.txt`, "C:/evil",
+		"a/../../evil", "./a", "a//b", ".", "..", "a\x00b", "CON", "\xff\xfe.txt", "a\nb", " ",
+	}
+	targets = []string{"README", "../../../../etc/shadow", "/etc/passwd", "..", "docs", "a/b/c", "/", "self"}
+	texts   = []string{
+		"hello, world\n", "", "package main\n\nfunc main() {}\n", "#!/bin/sh\necho hi\n", "\x00\x01\x02\xff",
+		strings.Repeat("line\n", 150), "[section]\nkey = \"value\"\n",
+	}
+	users    = []string{"root", "", "nobody", "user", "www-data", strings.Repeat("u", 32), "ü"}
+	comments = []string{"", "created by tarsrc", "a=b=c", "line\nbreak", strings.Repeat("c", 600)}
+)
+
+// A header is what one header block says, before it is formatted.
+type header struct {
+	name, linkname, uname, gname string
+	mode, uid, gid, size         int64
+	mtime, atime, ctime          int64
+	typ                          byte
+	devmajor, devminor           int64
+	// sparse and realsize are an old GNU sparse file's map and size.
+	sparse   []fragment
+	realsize int64
+}
+
+// A fragment is a run of data in a sparse file.
+type fragment struct {
+	offset, length int64
+}
+
+// A tgen writes one stream.
+type tgen struct {
+	s      *gen.State
+	b      []byte
+	format int
+}
+
+// archive writes one stream.
+func archive(s *gen.State) []gen.File {
+	t := &tgen{s: s, format: gen.Pick(s, formatV7, formatUSTAR, formatUSTAR, formatPAX, formatPAX, formatPAX, formatGNU, formatGNU, formatSTAR)}
+	if t.format == formatPAX && s.Chance(0.1) {
+		t.pax(typeXGlobal, "pax_global_header", t.records(&header{}, true))
+	}
+	for range s.Range(0, 6) {
+		if s.Chance(0.1) {
+			t.format = gen.Pick(s, formatV7, formatUSTAR, formatPAX, formatGNU, formatSTAR)
+		}
+		t.entry()
+	}
+	switch {
+	case s.Chance(badRate * 10):
+		t.b = t.b[:s.Intn(len(t.b)+1)]
+	case s.Chance(0.05):
+		// One zero block or none, which ends the stream as well as two
+		// at EOF, or junk after the trailer.
+		t.b = append(t.b, make([]byte, blockSize*s.Intn(2))...)
+	default:
+		t.b = append(t.b, make([]byte, 2*blockSize)...)
+		if s.Chance(0.05) {
+			t.b = append(t.b, gen.Pick(s, "junk", strings.Repeat("\x00", 10240), "ustar\x0000")...)
+		}
+	}
+	return []gen.File{{Name: "input.tar", Data: t.b}}
+}
+
+// entry writes one entry, and the entries before it that give it a long
+// name or PAX records.
+func (t *tgen) entry() {
+	s := t.s
+	h := &header{
+		name:  t.name(),
+		mode:  gen.Pick[int64](s, 0644, 0755, 0600, 0777, 04755, 02755, 01777, 0),
+		uid:   gen.Pick[int64](s, 0, 0, 1000, 65534, 2097151, 1<<21, 1<<32),
+		gid:   gen.Pick[int64](s, 0, 0, 1000, 65534, 2097151, 1<<21),
+		uname: gen.Pick(s, users...),
+		gname: gen.Pick(s, users...),
+		mtime: gen.Pick[int64](s, 0, 1700000000, 1<<33-1, 1<<33, -1, int64(s.Intn(1<<31))),
+		typ:   typeReg,
+	}
+	var data []byte
+	switch {
+	case strings.HasSuffix(h.name, "/"):
+		h.typ = gen.Pick[byte](s, typeDir, typeDir, typeRegA)
+	case s.Chance(0.05):
+		h.typ, h.linkname = typeSymlink, gen.Pick(s, targets...)
+	case s.Chance(0.04):
+		h.typ, h.linkname = typeLink, gen.Pick(s, targets...)
+	case s.Chance(0.03):
+		h.typ = gen.Pick[byte](s, typeChar, typeBlock)
+		h.devmajor, h.devminor = gen.Pick[int64](s, 0, 1, 8, 2097151, 1<<21), gen.Pick[int64](s, 0, 3, 255, 1<<21)
+	case s.Chance(0.02):
+		h.typ = typeFifo
+	case s.Chance(0.02):
+		h.typ = gen.Pick[byte](s, typeCont, 'D', 'M', 'N', 'V', 'A', 'Z', '9')
+		data = []byte(gen.Pick(s, texts...))
+	case s.Chance(0.08):
+		t.sparse(h)
+		return
+	case s.Chance(0.1):
+		data = make([]byte, s.Intn(2048))
+		for i := range data {
+			data[i] = byte(s.Intn(256))
+		}
+	default:
+		data = []byte(gen.Pick(s, texts...))
+		if h.typ == typeReg && s.Chance(0.2) {
+			h.typ = typeRegA
+		}
+	}
+	h.size = int64(len(data))
+	if s.Chance(0.02) {
+		// A size far past the end of the stream.
+		h.size = gen.Pick[int64](s, 1<<33-1, 1<<33, 1<<40, 1<<62, 1<<63-1)
+	}
+	if s.Chance(0.05) {
+		h.atime, h.ctime = h.mtime, h.mtime+1
+	}
+	t.write(h, data)
+}
+
+// write writes h and data, with whatever must come before h for the
+// format to carry all of it.
+func (t *tgen) write(h *header, data []byte) {
+	s := t.s
+	switch t.format {
+	case formatPAX:
+		if recs := t.records(h, false); recs != "" || s.Chance(0.05) {
+			t.pax(typeXHeader, "PaxHeaders.0/"+strings.TrimSuffix(h.name, "/"), recs)
+		}
+	case formatGNU:
+		if len(h.name) > 100 || s.Chance(0.03) {
+			t.long(typeLongName, h.name)
+		}
+		if len(h.linkname) > 100 || h.linkname != "" && s.Chance(0.03) {
+			t.long(typeLongLink, h.linkname)
+		}
+	}
+	t.block(h)
+	t.data(data)
+}
+
+// name returns a name for an entry: a plain one, one that escapes, or
+// one too long for a header's name field.
+func (t *tgen) name() string {
+	s := t.s
+	switch {
+	case s.Chance(0.08):
+		return gen.Pick(s, escapes...)
+	case s.Chance(0.08):
+		// Long enough to need a USTAR prefix, or more than one fits.
+		return strings.Repeat(gen.Pick(s, "dir/", "d/", "long-directory-name/"), s.Range(10, 80)) + gen.Pick(s, names...)
+	case s.Chance(0.01):
+		return strings.Repeat("x", gen.Pick(s, 99, 100, 101, 155, 156, 256, 1<<12))
+	}
+	return gen.Pick(s, names...)
+}
+
+// records returns the PAX records for h, those a writer adds for what
+// the USTAR fields cannot hold, and now and then others; global
+// records are for a global header.
+func (t *tgen) records(h *header, global bool) string {
+	s := t.s
+	var b strings.Builder
+	if len(h.name) > 100 || !global && s.Chance(0.1) {
+		b.WriteString(record("path", h.name))
+	}
+	if len(h.linkname) > 100 {
+		b.WriteString(record("linkpath", h.linkname))
+	}
+	if h.size >= 1<<33 {
+		b.WriteString(record("size", strconv.FormatInt(h.size, 10)))
+	}
+	if h.uid >= 1<<21 {
+		b.WriteString(record("uid", strconv.FormatInt(h.uid, 10)))
+	}
+	if h.gid >= 1<<21 {
+		b.WriteString(record("gid", strconv.FormatInt(h.gid, 10)))
+	}
+	if len(h.uname) > 32 || !isASCII(h.uname) {
+		b.WriteString(record("uname", h.uname))
+	}
+	if len(h.gname) > 32 || !isASCII(h.gname) {
+		b.WriteString(record("gname", h.gname))
+	}
+	if h.mtime < 0 || h.mtime >= 1<<33 || s.Chance(0.2) {
+		b.WriteString(record("mtime", gen.Pick(s, strconv.FormatInt(h.mtime, 10), strconv.FormatInt(h.mtime, 10)+".123456789", "1350244992.02396050", "-1.5", "0.000000001")))
+	}
+	if s.Chance(0.05) {
+		b.WriteString(record(gen.Pick(s, "atime", "ctime"), gen.Pick(s, "1350244992.023960108", "1e9", "1.", "-0.5", "+1", "00000000001.1")))
+	}
+	if s.Chance(0.1) {
+		b.WriteString(record("SCHILY.xattr."+gen.Pick(s, "user.mime_type", "security.capability", "user.a=b", "trusted.x"), gen.Pick(s, "text/plain", "\x01\x00\x00\x02", "", "v")))
+	}
+	if s.Chance(0.05) {
+		b.WriteString(record(gen.Pick(s, "comment", "charset", "hdrcharset", "LIBARCHIVE.creationtime", "GOLANG.pkg", "VENDOR.key"), gen.Pick(s, comments...)))
+	}
+	if s.Chance(badRate) {
+		// Records whose lengths lie, that have no '=' or newline, whose
+		// key or value is empty, or whose numbers do not parse.
+		b.WriteString(gen.Pick(s, "30 path=short\n", "5 a=b\n", "11 nokey\n", "6 =x\n", "7 path=\n", "9 size=x\n",
+			"12 uid=-1\n", "99999999999999999999 x=y\n", "0 x=y\n", "13 path=abc", "10 a=\x00\n", "14 mtime=1e99\n"))
+	}
+	return b.String()
+}
+
+// record formats the PAX record k=v, whose length counts its own digits.
+func record(k, v string) string {
+	base := len(k) + len(v) + 3 // the space, '=' and newline
+	n := base + 1
+	for base+len(strconv.Itoa(n)) != n {
+		n = base + len(strconv.Itoa(n))
+	}
+	return fmt.Sprintf("%d %s=%s\n", n, k, v)
+}
+
+// pax writes a PAX header of type typ with recs as its data.
+func (t *tgen) pax(typ byte, name, recs string) {
+	h := &header{name: name, mode: 0644, typ: typ, size: int64(len(recs)), uname: "root", gname: "root"}
+	if len(h.name) > 100 {
+		h.name = h.name[:100]
+	}
+	f := t.format
+	t.format = formatUSTAR
+	t.block(h)
+	t.format = f
+	t.data([]byte(recs))
+}
+
+// long writes a GNU long-name or long-link entry for name.
+func (t *tgen) long(typ byte, name string) {
+	data := []byte(name)
+	if t.s.Chance(0.8) {
+		data = append(data, 0)
+	}
+	t.block(&header{name: "././@LongLink", mode: 0644, typ: typ, size: int64(len(data))})
+	t.data(data)
+}
+
+// sparse writes a sparse file, in the old GNU format or one of the PAX
+// ones as the stream's format has it.
+func (t *tgen) sparse(h *header) {
+	s := t.s
+	h.realsize = gen.Pick[int64](s, 1<<12, 1<<16, 1<<20, 1<<24, 1<<30, 1<<40, 1<<62)
+	var frags []fragment
+	at := int64(0)
+	var data []byte
+	for range s.Range(0, gen.Pick(s, 3, 3, 3, 30)) {
+		if at >= h.realsize {
+			break
+		}
+		at += int64(s.Intn(int(min(h.realsize-at, 1<<20))))
+		n := int64(s.Range(0, 600))
+		n = min(n, h.realsize-at)
+		frags = append(frags, fragment{at, n})
+		for range n {
+			data = append(data, byte('a'+s.Intn(26)))
+		}
+		at += n
+	}
+	if s.Chance(badRate * 10) {
+		// Fragments out of order, overlapping, with a negative offset
+		// or running past the end of the file.
+		switch s.Intn(4) {
+		case 0:
+			frags = append(frags, fragment{int64(s.Intn(100)), 10})
+		case 1:
+			frags = append(frags, fragment{-1, 1})
+		case 2:
+			frags = append(frags, fragment{h.realsize - 5, 10})
+		case 3:
+			frags = append(frags, fragment{1 << 62, 1 << 62})
+		}
+	}
+	if t.format != formatPAX {
+		h.typ = typeGNUSparse
+		h.sparse = frags
+		h.size = int64(len(data))
+		format := t.format
+		t.format = formatGNU
+		t.block(h)
+		t.format = format
+		t.data(data)
+		return
+	}
+	var recs strings.Builder
+	name := h.name
+	switch s.Intn(3) {
+	case 0: // 0.0: offset and numbytes records, one pair a fragment
+		recs.WriteString(record("GNU.sparse.size", strconv.FormatInt(h.realsize, 10)))
+		recs.WriteString(record("GNU.sparse.numblocks", strconv.Itoa(len(frags))))
+		for _, f := range frags {
+			recs.WriteString(record("GNU.sparse.offset", strconv.FormatInt(f.offset, 10)))
+			recs.WriteString(record("GNU.sparse.numbytes", strconv.FormatInt(f.length, 10)))
+		}
+	case 1: // 0.1: one map record
+		recs.WriteString(record("GNU.sparse.size", strconv.FormatInt(h.realsize, 10)))
+		recs.WriteString(record("GNU.sparse.numblocks", strconv.Itoa(len(frags))))
+		var m []string
+		for _, f := range frags {
+			m = append(m, strconv.FormatInt(f.offset, 10), strconv.FormatInt(f.length, 10))
+		}
+		recs.WriteString(record("GNU.sparse.map", strings.Join(m, ",")))
+	case 2: // 1.0: the map at the start of the data
+		recs.WriteString(record("GNU.sparse.major", "1"))
+		recs.WriteString(record("GNU.sparse.minor", "0"))
+		recs.WriteString(record("GNU.sparse.name", name))
+		recs.WriteString(record("GNU.sparse.realsize", strconv.FormatInt(h.realsize, 10)))
+		var m strings.Builder
+		fmt.Fprintf(&m, "%d\n", len(frags))
+		for _, f := range frags {
+			fmt.Fprintf(&m, "%d\n%d\n", f.offset, f.length)
+		}
+		if s.Chance(badRate * 10) {
+			m.WriteString(gen.Pick(s, "x\n", "99999999999999999999\n", "\n"))
+		}
+		mb := []byte(m.String())
+		mb = append(mb, make([]byte, pad(len(mb)))...)
+		data = append(mb, data...)
+		name = "GNUSparseFile.0/" + name
+	}
+	t.pax(typeXHeader, "PaxHeaders.0/"+h.name, recs.String())
+	h.name = name
+	h.size = int64(len(data))
+	t.block(h)
+	t.data(data)
+}
+
+// block writes h as a header block in the stream's format.
+func (t *tgen) block(h *header) {
+	s := t.s
+	blk := make([]byte, blockSize)
+	name := h.name
+	if t.format == formatUSTAR || t.format == formatPAX || t.format == formatSTAR {
+		if i := strings.LastIndex(name[:min(len(name), 156)], "/"); len(name) > 100 && i > 0 && len(name)-i-1 <= 100 {
+			prefix := name[:i]
+			if t.format == formatSTAR {
+				prefix = prefix[:min(len(prefix), 131)]
+			}
+			copy(blk[345:], prefix)
+			name = name[i+1:]
+		}
+	}
+	copy(blk[0:100], name)
+	t.num(blk[100:108], h.mode)
+	t.num(blk[108:116], h.uid)
+	t.num(blk[116:124], h.gid)
+	t.num(blk[124:136], h.size)
+	t.num(blk[136:148], h.mtime)
+	blk[156] = h.typ
+	copy(blk[157:257], h.linkname)
+	switch t.format {
+	case formatUSTAR, formatPAX:
+		copy(blk[257:], "ustar\x0000")
+	case formatSTAR:
+		copy(blk[257:], "ustar\x0000")
+		t.num(blk[476:488], h.atime)
+		t.num(blk[488:500], h.ctime)
+		copy(blk[508:], "tar\x00")
+	case formatGNU:
+		copy(blk[257:], "ustar  \x00")
+		if h.atime != 0 {
+			t.num(blk[345:357], h.atime)
+			t.num(blk[357:369], h.ctime)
+		}
+	}
+	if t.format != formatV7 {
+		copy(blk[265:297], h.uname)
+		copy(blk[297:329], h.gname)
+		if h.typ == typeChar || h.typ == typeBlock || s.Chance(0.1) {
+			t.num(blk[329:337], h.devmajor)
+			t.num(blk[337:345], h.devminor)
+		}
+	}
+	var ext [][]fragment
+	if h.typ == typeGNUSparse {
+		frags := h.sparse
+		for i := 0; i < 4 && len(frags) > 0; i++ {
+			t.num(blk[386+24*i:398+24*i], frags[0].offset)
+			t.num(blk[398+24*i:410+24*i], frags[0].length)
+			frags = frags[1:]
+		}
+		for len(frags) > 0 {
+			n := min(len(frags), 21)
+			ext = append(ext, frags[:n])
+			frags = frags[n:]
+		}
+		if len(ext) > 0 {
+			blk[482] = 1
+		}
+		t.num(blk[483:495], h.realsize)
+	}
+	t.checksum(blk)
+	t.b = append(t.b, blk...)
+	for i, frags := range ext {
+		blk := make([]byte, blockSize)
+		for j, f := range frags {
+			t.num(blk[24*j:24*j+12], f.offset)
+			t.num(blk[24*j+12:24*j+24], f.length)
+		}
+		if i < len(ext)-1 || s.Chance(badRate) {
+			blk[504] = 1
+		}
+		t.b = append(t.b, blk...)
+	}
+}
+
+// checksum fills in blk's checksum: the sum of its bytes, with those of
+// the checksum itself counted as spaces.
+func (t *tgen) checksum(blk []byte) {
+	s := t.s
+	copy(blk[148:156], "        ")
+	sum := 0
+	for _, c := range blk {
+		sum += int(c)
+	}
+	if s.Chance(badRate * 2) {
+		sum += gen.Pick(s, 1, -1, 256, 1<<18)
+	}
+	// Writers end the field with a NUL and a space, or a NUL alone.
+	f := fmt.Sprintf("%06o\x00 ", sum)
+	if s.Chance(0.2) {
+		f = fmt.Sprintf("%07o\x00", sum)
+	}
+	copy(blk[148:156], f[len(f)-8:])
+}
+
+// num writes v into the numeric field f: in octal where it fits, in
+// base-256 where it does not, and now and then written oddly.
+func (t *tgen) num(f []byte, v int64) {
+	s := t.s
+	octal := strconv.FormatInt(v, 8)
+	switch {
+	case s.Chance(badRate / 5):
+		copy(f, gen.Pick(s, "0x1f", "9", "-1", "  12 34", "\xff", "+7", "1e3", "\x80"))
+	case v < 0 || len(octal) > len(f)-1 || s.Chance(0.01):
+		// Base-256: the first byte's top bit set, big-endian two's
+		// complement in the rest.
+		for i := len(f) - 1; i > 0; i-- {
+			f[i] = byte(v)
+			v >>= 8
+		}
+		f[0] = 0x80
+		if v < 0 {
+			f[0] = 0xff
+		}
+	case s.Chance(0.1):
+		// Space-padded on the left and space-terminated, as old tars
+		// wrote numbers.
+		copy(f, fmt.Sprintf("%*s ", len(f)-1, octal))
+	default:
+		copy(f, fmt.Sprintf("%0*o", len(f)-1, v))
+	}
+}
+
+// data writes data and the padding that fills out its last block.
+func (t *tgen) data(data []byte) {
+	t.b = append(t.b, data...)
+	t.b = append(t.b, make([]byte, pad(len(data)))...)
+}
+
+// pad returns the bytes of padding after n bytes to fill out a block.
+func pad(n int) int {
+	return -n & (blockSize - 1)
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
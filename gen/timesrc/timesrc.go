@@ -0,0 +1,343 @@
+// Package timesrc generates time seeds. It registers the "time/..."
+// generators with package gen.
+//
+// "time/parse" writes a layout, layout.txt, and a value to parse with
+// it, value.txt: a time formatted with the layout, most of the time,
+// and edited now and then to break it. Layouts mix the standard ones
+// with layouts built from the elements package time knows, some of
+// which read ambiguously: numbers without padding or a separator
+// between them, a month or weekday both by name and by number, a
+// 12-hour clock without AM or PM, the day of the year with a month
+// and day, and fractional seconds of any width after a dot or comma.
+// Times sit at the edges: the years 0 and 9999 and past them, leap
+// days and the days after them, 23:59:60, the Unix epoch and 2038, and
+// zones as far from UTC as offsets go, with seconds in their offsets
+// and abbreviations package time has never heard of.
+//
+// "time/duration" writes a duration string, input.dur, of one or more
+// components in any unit, including both micro signs, with fractions
+// as long as a parser reads and whole numbers at and past the limits
+// of an int64 count of nanoseconds.
+package timesrc
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "time/parse",
+		Doc:  "time.Parse layouts and values: standard and built layouts with ambiguous, repeated and unpadded elements, fractional seconds, zone offsets and abbreviations, and times at era, leap-day, leap-second and 2038 boundaries, sometimes broken",
+		Func: parse,
+	})
+	gen.Register(&gen.Generator{
+		Name: "time/duration",
+		Doc:  "time.ParseDuration strings: many components, every unit including both micro signs, long fractions, signs, and values at and past the int64 nanosecond limits",
+		Func: duration,
+	})
+}
+
+// badRate is the chance that a value is edited after it is formatted,
+// or that a duration string is malformed.
+const badRate = 0.15
+
+// standard are the layouts package time declares.
+var standard = []string{
+	time.Layout, time.ANSIC, time.UnixDate, time.RubyDate, time.RFC822, time.RFC822Z,
+	time.RFC850, time.RFC1123, time.RFC1123Z, time.RFC3339, time.RFC3339Nano,
+	time.Kitchen, time.Stamp, time.StampMilli, time.StampMicro, time.StampNano,
+	time.DateTime, time.DateOnly, time.TimeOnly,
+}
+
+// Elements of a layout, by what they stand for.
+var (
+	years    = []string{"2006", "06"}
+	months   = []string{"01", "1", "Jan", "January"}
+	days     = []string{"02", "2", "_2"}
+	yeardays = []string{"002", "__2"}
+	weekdays = []string{"Mon", "Monday"}
+	hours    = []string{"15", "03", "3"}
+	minutes  = []string{"04", "4"}
+	seconds  = []string{"05", "5"}
+	fracs    = []string{".000", ".000000", ".000000000", ".999", ".999999999", ".9", ",000", ",999", ".0", ".00000000000"}
+	ampms    = []string{"PM", "pm"}
+	zones    = []string{"MST", "Z07:00", "Z0700", "Z07", "Z07:00:00", "Z070000", "-07:00", "-0700", "-07", "-07:00:00", "-070000"}
+	seps     = []string{"-", "/", " ", ":", "T", ".", ", ", "", "  ", "_", "'", "at "}
+)
+
+// A tgen writes a layout element by element.
+type tgen struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func (t *tgen) elem(xs []string) {
+	t.b.WriteString(gen.Pick(t.s, xs...))
+}
+
+func (t *tgen) sep() {
+	if t.s.Chance(0.15) {
+		t.b.WriteString(gen.Pick(t.s, seps...)) // sometimes nothing at all
+		return
+	}
+	t.b.WriteString(gen.Pick(t.s, "-", "/", " ", ":", "T", " "))
+}
+
+// layout returns a layout built from elements: a date, a time or both,
+// and a zone, with now and then an element repeated, out of place or
+// ambiguous.
+func layout(s *gen.State) string {
+	t := &tgen{s: s}
+	if s.Chance(0.2) {
+		t.elem(weekdays)
+		t.b.WriteString(gen.Pick(s, ", ", " "))
+	}
+	date := s.Chance(0.8)
+	if date {
+		switch s.Intn(6) {
+		case 0:
+			t.elem(months)
+			t.sep()
+			t.elem(days)
+			t.sep()
+			t.elem(years)
+		case 1:
+			t.elem(days)
+			t.sep()
+			t.elem(months)
+			t.sep()
+			t.elem(years)
+		case 2:
+			// The day of the year, alone or beside a month and day
+			// that must agree with it.
+			t.elem(years)
+			t.sep()
+			t.elem(yeardays)
+			if s.Chance(0.3) {
+				t.sep()
+				t.elem(months)
+				t.sep()
+				t.elem(days)
+			}
+		default:
+			t.elem(years)
+			t.sep()
+			t.elem(months)
+			t.sep()
+			t.elem(days)
+		}
+	}
+	if !date || s.Chance(0.7) {
+		if date {
+			t.b.WriteString(gen.Pick(s, "T", " ", " ", "", "_"))
+		}
+		t.elem(hours)
+		if s.Chance(0.9) {
+			t.b.WriteString(gen.Pick(s, ":", ":", ":", "", "."))
+			t.elem(minutes)
+			if s.Chance(0.8) {
+				t.b.WriteString(gen.Pick(s, ":", ":", ":", ""))
+				t.elem(seconds)
+				if s.Chance(0.4) {
+					t.elem(fracs)
+				}
+			}
+		}
+		if s.Chance(0.3) {
+			t.b.WriteString(gen.Pick(s, " ", ""))
+			t.elem(ampms)
+		}
+	}
+	if s.Chance(0.5) {
+		t.b.WriteString(gen.Pick(s, " ", "", " "))
+		t.elem(zones)
+		if s.Chance(0.1) {
+			t.b.WriteString(" ")
+			t.elem(zones)
+		}
+	}
+	if s.Chance(0.1) {
+		// An element again, which the value must repeat.
+		t.b.WriteString(" ")
+		t.elem(gen.Pick(s, years, months, days, hours, weekdays, zones))
+	}
+	if s.Chance(0.05) {
+		t.b.WriteString(gen.Pick(s, " (local)", " UTC", "Z", " +0000", " day"))
+	}
+	return t.b.String()
+}
+
+// zone returns the location a time is formatted in.
+func zone(s *gen.State) *time.Location {
+	switch s.Intn(6) {
+	case 0:
+		return time.UTC
+	case 1:
+		name := gen.Pick(s, "MST", "PST", "CEST", "IST", "JST", "AEDT", "XYZT", "GMT", "UTC", "ChST", "+03", "-0330")
+		return time.FixedZone(name, gen.Pick(s, -7, -8, 2, 5, 9, 11, 0, 3, 10)*3600+gen.Pick(s, 0, 0, 0, 1800, 2700))
+	case 2:
+		// Offsets at and past what a layout's fields hold.
+		return time.FixedZone("", gen.Pick(s, 14*3600, -12*3600, 5*3600+30*60, 5*3600+45*60+30, -(3600+1), 99*3600+59*60, -(23*3600+59*60+59), 24*3600, 1))
+	default:
+		return time.FixedZone("", s.Range(-14*4, 14*4)*900)
+	}
+}
+
+// when returns a time to format, at an edge as often as not.
+func when(s *gen.State) time.Time {
+	loc := zone(s)
+	if s.Chance(0.5) {
+		return time.Date(s.Range(1900, 2100), time.Month(s.Range(1, 12)), s.Range(1, 28), s.Intn(24), s.Intn(60), s.Intn(60), s.Intn(1e9), loc)
+	}
+	switch s.Intn(9) {
+	case 0:
+		return time.Date(gen.Pick(s, 0, 1, 9999, 10000, -1, 99, 100, 1969, 1970), time.Month(s.Range(1, 12)), s.Range(1, 28), s.Intn(24), 0, 0, 0, loc)
+	case 1:
+		// Leap days, and the days after them in years that have none.
+		return time.Date(gen.Pick(s, 2000, 2024, 1900, 2100, 2023, 0, 4, 400), time.February, gen.Pick(s, 28, 29, 30), 12, 0, 0, 0, loc)
+	case 2:
+		return time.Date(s.Range(1970, 2030), time.Month(s.Range(1, 12)), gen.Pick(s, 30, 31), 23, 59, 59, 999999999, loc)
+	case 3:
+		return time.Unix(gen.Pick[int64](s, 0, -1, 1<<31-1, 1<<31, -1<<31, 253402300799, 253402300800, -62135596800, -62135596801), 0).In(loc)
+	case 4:
+		return time.Date(2016, time.December, 31, 23, 59, 59, gen.Pick(s, 0, 1, 999999999, 500000000, 100), loc)
+	case 5:
+		// Hours that read differently on a 12-hour clock.
+		return time.Date(2006, time.January, 2, gen.Pick(s, 0, 12, 13, 23, 11), gen.Pick(s, 0, 59), 0, 0, loc)
+	case 6:
+		return time.Date(2006, time.Month(s.Range(1, 12)), 1, 0, 0, 0, 0, loc).AddDate(0, 0, -1)
+	case 7:
+		return time.Date(s.Range(1, 12)*gen.Pick(s, 1, 100, 1000), 1, 1, 0, 0, 0, gen.Pick(s, 1, 10, 1000, 1000000), loc)
+	default:
+		return time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func parse(s *gen.State) []gen.File {
+	l := gen.Pick(s, standard...)
+	if s.Chance(0.6) {
+		l = layout(s)
+	}
+	v := when(s).Format(l)
+	if s.Chance(badRate) {
+		v = breakValue(s, v)
+	}
+	return []gen.File{
+		{Name: "layout.txt", Data: []byte(l)},
+		{Name: "value.txt", Data: []byte(v)},
+	}
+}
+
+// breakValue edits a formatted value, into one that should not parse
+// or that parses only because a parser is lenient.
+func breakValue(s *gen.State, v string) string {
+	if v == "" {
+		return gen.Pick(s, " ", "0", "Z")
+	}
+	i := s.Intn(len(v))
+	switch s.Intn(10) {
+	case 0:
+		return v[:i] // cut short
+	case 1:
+		return v + gen.Pick(s, " ", "0", "Z", ".5", "x", "\x00")
+	case 2:
+		// A digit for another, which may push a field out of range.
+		for j := i; j < len(v); j++ {
+			if v[j] >= '0' && v[j] <= '9' {
+				return v[:j] + gen.Pick(s, "9", "0", "6", "3") + v[j+1:]
+			}
+		}
+		return v + "9"
+	case 3:
+		return v[:i] + gen.Pick(s, "0", "1", "00", "+") + v[i:] // a field one digit wider
+	case 4:
+		return v[:i] + v[i+1:]
+	case 5:
+		// Fractional seconds where the layout has none, or longer.
+		return strings.Replace(v, ":", gen.Pick(s, ".1234567890123:", ",5:", ".:"), 1) + gen.Pick(s, "", ".999999999999")
+	case 6:
+		return strings.NewReplacer("Z", "z", "+", "-", "AM", "am", "PM", "Am", "Jan", "JAN", "Mon", "mon").Replace(v)
+	case 7:
+		return strings.NewReplacer(":59:59", ":59:60", "Z", "+00:00", "+0", "+2", "-0", "+9").Replace(v)
+	case 8:
+		return gen.Pick(s, " ", "+", "-", "0") + v
+	default:
+		return strings.ToUpper(v)
+	}
+}
+
+// units are ParseDuration's units, with both micro signs.
+var units = []string{"ns", "us", "µs", "μs", "ms", "s", "m", "h"}
+
+// limits are duration strings at and just past the edges of an int64
+// count of nanoseconds, in each unit.
+var limits = []string{
+	"9223372036854775807ns", "9223372036854775808ns", "-9223372036854775808ns", "-9223372036854775809ns",
+	"9223372036854775.807us", "9223372036854775.808us", "9223372036854.775807ms", "9223372036854.775808ms",
+	"9223372036.854775807s", "9223372036.854775808s", "-9223372036.854775808s", "-9223372036.854775809s",
+	"153722867m16.854775807s", "153722867m16.854775808s", "153722867.28091292678m",
+	"2562047h47m16.854775807s", "2562047h47m16.854775808s", "-2562047h47m16.854775808s", "-2562047h47m16.854775809s",
+	"2562047.7880152155h", "2562048h", "2562047h48m", "2562047h47m17s", "18446744073709551616ns",
+	"2562047h47m16s854ms775us807ns", "2562047h47m16s854ms775us808ns", "-2562047h47m16s854ms775us808ns",
+	"99999999999999999999h", "0.99999999999999999999999999h", "1.00000000000000000001ns",
+}
+
+func duration(s *gen.State) []gen.File {
+	var b strings.Builder
+	if s.Chance(0.2) {
+		b.WriteString(limits[s.Intn(len(limits))])
+	} else {
+		if s.Chance(0.2) {
+			b.WriteString(gen.Pick(s, "-", "+", "-"))
+		}
+		for range s.Range(1, 4) {
+			component(s, &b)
+		}
+	}
+	d := b.String()
+	if s.Chance(badRate) {
+		i := s.Intn(len(d) + 1)
+		switch s.Intn(6) {
+		case 0:
+			d = d[:i]
+		case 1:
+			d = d[:i] + gen.Pick(s, " ", "-", "+", ".", "..", "e3", "_", "µ") + d[i:]
+		case 2:
+			d += gen.Pick(s, "", "1", ".5", "s", "hh", "S", "H", "sec", "d", "y", "μ", "µś")
+		case 3:
+			d = gen.Pick(s, "", "0", "-0", "+0", "00", "0.0", ".", "-", "+", ".s", "s", "1", "-.5h", "+.5h", "5.s", "0x10s", "1e3s", "١s")
+		case 4:
+			d = strings.ToUpper(d)
+		default:
+			d = gen.Pick(s, "-", "+") + d
+		}
+	}
+	return []gen.File{{Name: "input.dur", Data: []byte(d)}}
+}
+
+// component writes a number and a unit.
+func component(s *gen.State, b *strings.Builder) {
+	switch s.Intn(8) {
+	case 0:
+		// A fraction as long as any parser reads, and longer.
+		fmt.Fprintf(b, "%d.%s", s.Intn(100), strings.Repeat(gen.Pick(s, "9", "0", "5", "3"), s.Range(1, 40)))
+	case 1:
+		fmt.Fprintf(b, ".%d", s.Intn(1000))
+	case 2:
+		fmt.Fprintf(b, "%d.", s.Intn(1000))
+	case 3:
+		fmt.Fprintf(b, "%d", s.Uint64()>>s.Intn(64))
+	case 4:
+		fmt.Fprintf(b, "%s%d", strings.Repeat("0", s.Range(1, 30)), s.Intn(100))
+	default:
+		fmt.Fprintf(b, "%d", s.Intn(1000))
+		if s.Chance(0.3) {
+			fmt.Fprintf(b, ".%d", s.Intn(1000))
+		}
+	}
+	b.WriteString(gen.Pick(s, units...))
+}
@@ -0,0 +1,640 @@
+// Package tlssrc generates TLS seeds. It registers the "tls/..."
+// generators with package gen.
+//
+// A seed is what one side of a handshake sends before it waits for an
+// answer, as records: "tls/client" writes a ClientHello, for a server to
+// read, and "tls/server" writes a server's first flight of TLS 1.0 to
+// 1.3, for a client to read. The client fuzz/tls drives draws zeros for
+// its randomness, so a server flight echoes its session ID of 32 zero
+// bytes. Messages are well formed but for lengths that overlap or run
+// short, drawn rarely, and carry GREASE values, unknown and duplicate
+// extensions and odd versions; records split messages at any byte and
+// interleave them with change cipher spec, alert and empty records.
+package tlssrc
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/mlkem"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"slices"
+	"time"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "tls/client",
+		Doc:  "TLS ClientHello records: SSL 3.0 to TLS 1.3 and unknown versions, GREASE cipher suites, groups, versions and extensions, key shares, PSKs, overlapping lengths and fragmented or interleaved records",
+		Func: client,
+	})
+	gen.Register(&gen.Generator{
+		Name: "tls/server",
+		Doc:  "TLS server flights: ServerHello, HelloRetryRequest, Certificate, ServerKeyExchange, CertificateRequest and ServerHelloDone of TLS 1.0 to 1.3, with downgrade sentinels, bogus extensions, overlapping lengths and fragmented or interleaved records",
+		Func: server,
+	})
+}
+
+// badRate is the chance that a length is drawn wrong. A ClientHello has
+// about fifty lengths, so about a fifth of them get one.
+const badRate = 0.005
+
+// Record content types and handshake message types.
+const (
+	recordCCS       = 20
+	recordAlert     = 21
+	recordHandshake = 22
+	recordAppData   = 23
+
+	typeClientHello       = 1
+	typeServerHello       = 2
+	typeCertificate       = 11
+	typeServerKeyExchange = 12
+	typeCertificateReq    = 13
+	typeServerHelloDone   = 14
+	typeCertificateStatus = 22
+)
+
+// Extension types.
+const (
+	extServerName       = 0
+	extStatusRequest    = 5
+	extGroups           = 10
+	extPointFormats     = 11
+	extSigAlgs          = 13
+	extALPN             = 16
+	extSCT              = 18
+	extPadding          = 21
+	extEMS              = 23
+	extSessionTicket    = 35
+	extPSK              = 41
+	extEarlyData        = 42
+	extSupportedVersion = 43
+	extCookie           = 44
+	extPSKModes         = 45
+	extKeyShare         = 51
+	extRenegotiation    = 0xff01
+)
+
+// grease are the values RFC 8701 reserves for clients to send so that
+// servers learn to ignore what they do not know.
+var grease = []int{0x0a0a, 0x1a1a, 0x2a2a, 0x3a3a, 0x4a4a, 0x5a5a, 0x6a6a, 0x7a7a, 0x8a8a, 0x9a9a, 0xaaaa, 0xbaba, 0xcaca, 0xdada, 0xeaea, 0xfafa}
+
+// suites are cipher suites: those of TLS 1.3, ECDHE and RSA key exchange,
+// the renegotiation and fallback SCSVs, and ones nobody implements.
+var suites = []int{0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc009, 0xc013, 0xc00a, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035, 0x000a, 0x00ff, 0x5600, 0x0000, 0xffff}
+
+// groups are named groups: X25519, the NIST curves, the hybrid and pure
+// ML-KEM groups, finite fields and unknown ones.
+var groups = []int{0x001d, 0x0017, 0x0018, 0x0019, 0x11ec, 0x11eb, 0x11ed, 0x0200, 0x0100, 0x001e, 0x0000, 0xffff}
+
+// sigAlgs are signature schemes: ECDSA, PSS, PKCS #1, Ed25519 and SHA-1,
+// and unknown ones.
+var sigAlgs = []int{0x0403, 0x0503, 0x0603, 0x0804, 0x0805, 0x0806, 0x0401, 0x0501, 0x0601, 0x0807, 0x0201, 0x0203, 0x0000, 0xffff}
+
+// versions are protocol versions: SSL 3.0 to TLS 1.3, a TLS 1.3 draft
+// and ones past TLS 1.3.
+var versions = []int{0x0300, 0x0301, 0x0302, 0x0303, 0x0304, 0x7f1c, 0x0305, 0xfefd}
+
+// Public keys for key shares, made from fixed private keys: points on the
+// NIST curves, which random bytes almost never are, and ML-KEM
+// encapsulation keys, whose coefficients random bytes almost never keep
+// in range.
+var (
+	p256Point = point(ecdh.P256(), 32)
+	p384Point = point(ecdh.P384(), 48)
+	mlkem768  = must(mlkem.NewDecapsulationKey768(make([]byte, mlkem.SeedSize))).EncapsulationKey().Bytes()
+	mlkem1024 = must(mlkem.NewDecapsulationKey1024(make([]byte, mlkem.SeedSize))).EncapsulationKey().Bytes()
+)
+
+// point returns the public key of the private key 1 on c, which is the
+// curve's base point.
+func point(c ecdh.Curve, size int) []byte {
+	k := make([]byte, size)
+	k[size-1] = 1
+	return must(c.NewPrivateKey(k)).PublicKey().Bytes()
+}
+
+func must[T any](v T, err error) T {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// share returns a key share for group: a client's public key, or a
+// server's, which for ML-KEM is a ciphertext, any bytes of which
+// decapsulate. Now and then it is of the wrong length.
+func (t *tgen) share(group int, server bool) []byte {
+	if t.s.Chance(0.02) {
+		return t.bytes(gen.Pick(t.s, 0, 1, 31, 33, 64))
+	}
+	switch group {
+	case 0x001d:
+		return t.bytes(32)
+	case 0x0017:
+		return p256Point
+	case 0x0018:
+		return p384Point
+	case 0x11ec:
+		if server {
+			return t.bytes(mlkem.CiphertextSize768 + 32)
+		}
+		return slices.Concat(mlkem768, t.bytes(32))
+	case 0x11eb:
+		if server {
+			return slices.Concat(p256Point, t.bytes(mlkem.CiphertextSize768))
+		}
+		return slices.Concat(p256Point, mlkem768)
+	case 0x11ed:
+		if server {
+			return slices.Concat(p384Point, t.bytes(mlkem.CiphertextSize1024))
+		}
+		return slices.Concat(p384Point, mlkem1024)
+	}
+	return t.bytes(t.s.Range(1, 64))
+}
+
+// A tgen accumulates the records of one side of a handshake.
+type tgen struct {
+	s *gen.State
+}
+
+// u16 and u24 return v big-endian in two and three bytes.
+func u16(v int) []byte { return []byte{byte(v >> 8), byte(v)} }
+func u24(v int) []byte { return []byte{byte(v >> 16), byte(v >> 8), byte(v)} }
+
+// vec returns body preceded by its length in size bytes, now and then
+// a length past the end of body, short of it, or the largest there is.
+func (t *tgen) vec(size int, body ...[]byte) []byte {
+	var b []byte
+	for _, p := range body {
+		b = append(b, p...)
+	}
+	n := len(b)
+	if t.s.Chance(badRate) {
+		n = gen.Pick(t.s, n+1, max(n-1, 0), 1<<(8*size)-1, 0)
+	}
+	l := []byte{byte(n >> 16), byte(n >> 8), byte(n)}
+	return append(l[3-size:], b...)
+}
+
+// list returns a vector of 16-bit values drawn from from, most of the
+// time after the usual ones, and with a GREASE value among them now and
+// then.
+func (t *tgen) list(size int, from []int, lo, hi int, usual ...int) []byte {
+	var b []byte
+	if t.s.Chance(0.3) {
+		b = append(b, u16(gen.Pick(t.s, grease...))...)
+	}
+	if t.s.Chance(0.8) {
+		for _, v := range usual {
+			b = append(b, u16(v)...)
+		}
+	}
+	for range t.s.Range(lo, hi) {
+		b = append(b, u16(gen.Pick(t.s, from...))...)
+	}
+	return t.vec(size, b)
+}
+
+// bytes returns n random bytes.
+func (t *tgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(t.s.Intn(256))
+	}
+	return b
+}
+
+// handshake returns a handshake message of type typ.
+func (t *tgen) handshake(typ int, body ...[]byte) []byte {
+	return append([]byte{byte(typ)}, t.vec(3, body...)...)
+}
+
+// ext returns an extension of type typ.
+func (t *tgen) ext(typ int, body ...[]byte) []byte {
+	return append(u16(typ), t.vec(2, body...)...)
+}
+
+// records frames messages as handshake records: usually one record for
+// all of them, or one each, sometimes split at random bytes, and with
+// other records among them now and then. version is the record version.
+func (t *tgen) records(version int, msgs [][]byte) []byte {
+	var frags [][]byte
+	switch {
+	case t.s.Chance(0.6):
+		var all []byte
+		for _, m := range msgs {
+			all = append(all, m...)
+		}
+		frags = [][]byte{all}
+	case t.s.Chance(0.5):
+		frags = msgs
+	default:
+		// Fragments as small as a byte, which the reader has to put back
+		// together, some across message boundaries.
+		var all []byte
+		for _, m := range msgs {
+			all = append(all, m...)
+		}
+		for len(all) > 0 {
+			n := min(len(all), gen.Pick(t.s, 1, 2, 3, 5, 64, 1000))
+			frags = append(frags, all[:n])
+			all = all[n:]
+		}
+	}
+	var out []byte
+	for i, f := range frags {
+		if t.s.Chance(0.05) {
+			out = append(out, t.interloper(version, i == 0)...)
+		}
+		out = append(out, t.record(recordHandshake, version, f)...)
+	}
+	if t.s.Chance(0.05) {
+		out = append(out, t.interloper(version, false)...)
+	}
+	return out
+}
+
+// record returns a record of type typ holding fragment.
+func (t *tgen) record(typ, version int, fragment []byte) []byte {
+	return append([]byte{byte(typ), byte(version >> 8), byte(version)}, t.vec(2, fragment)...)
+}
+
+// interloper returns a record that is not a handshake record: a change
+// cipher spec, an alert, an empty record, an oversized one, one of a
+// type that does not exist, or an SSL 2.0 header.
+func (t *tgen) interloper(version int, first bool) []byte {
+	switch t.s.Intn(7) {
+	case 0:
+		return t.record(recordCCS, version, []byte{1})
+	case 1:
+		return t.record(recordAlert, version, []byte{byte(gen.Pick(t.s, 1, 2)), byte(gen.Pick(t.s, 0, 10, 40, 90, 255))})
+	case 2:
+		return t.record(recordHandshake, version, nil)
+	case 3:
+		return t.record(recordAppData, version, t.bytes(t.s.Range(0, 64)))
+	case 4:
+		// A record longer than the protocol allows, of which only the
+		// header and a little of the body are sent.
+		return append([]byte{recordHandshake, byte(version >> 8), byte(version)}, append(u16(gen.Pick(t.s, 16384+1, 16384+256+1, 16384+2048+1, 0xffff)), t.bytes(16)...)...)
+	case 5:
+		return t.record(gen.Pick(t.s, 0, 19, 24, 25, 255), version, t.bytes(t.s.Range(0, 8)))
+	}
+	if first {
+		// An SSL 2.0 ClientHello header, which the reader recognizes
+		// only at the start of a connection.
+		return append([]byte{0x80, 0x2e, 0x01, 0x03, 0x01}, t.bytes(0x2b)...)
+	}
+	return t.record(recordCCS, version, gen.Pick(t.s, []byte{2}, []byte{1, 1}, nil))
+}
+
+// client writes a ClientHello.
+func client(s *gen.State) []gen.File {
+	t := &tgen{s: s}
+	hello := t.clientHello()
+	return []gen.File{{Name: "client.tls", Data: t.records(gen.Pick(s, 0x0301, 0x0301, 0x0303, 0x0300, 0x0304), [][]byte{hello})}}
+}
+
+// clientHello returns a ClientHello offering TLS 1.3 and 1.2, most of the
+// time, with the extensions browsers send in a random order.
+func (t *tgen) clientHello() []byte {
+	s := t.s
+	legacy := 0x0303
+	if s.Chance(0.2) {
+		legacy = gen.Pick(s, versions...)
+	}
+	var b [][]byte
+	b = append(b, u16(legacy), t.bytes(32))
+	id := 32
+	if s.Chance(0.1) {
+		id = gen.Pick(s, 0, 16, 33)
+	}
+	b = append(b, t.vec(1, t.bytes(id)))
+	b = append(b, t.list(2, suites, 1, 12, 0x1301, 0xc02b))
+	if s.Chance(0.1) {
+		b = append(b, t.vec(1, gen.Pick(s, []byte{}, []byte{1}, []byte{0, 1}, []byte{1, 0})))
+	} else {
+		b = append(b, t.vec(1, []byte{0}))
+	}
+	if s.Chance(0.05) {
+		// No extensions at all, as clients before TLS 1.2 sent.
+		return t.handshake(typeClientHello, b...)
+	}
+	exts := t.clientExtensions()
+	b = append(b, t.vec(2, exts...))
+	if s.Chance(0.01) {
+		// Bytes after the extensions.
+		b = append(b, t.bytes(s.Range(1, 4)))
+	}
+	return t.handshake(typeClientHello, b...)
+}
+
+// clientExtensions returns the extensions of a ClientHello, shuffled, a
+// pre_shared_key last where it must be, or not.
+func (t *tgen) clientExtensions() [][]byte {
+	s := t.s
+	var exts [][]byte
+	add := func(p float64, e func() []byte) {
+		if s.Chance(p) {
+			exts = append(exts, e())
+		}
+	}
+	add(0.8, func() []byte {
+		var names [][]byte
+		n := 1
+		if s.Chance(0.02) {
+			n = gen.Pick(s, 0, 2)
+		}
+		for range n {
+			name := "example.com"
+			if s.Chance(0.2) {
+				name = gen.Pick(s, "EXAMPLE.COM", "127.0.0.1", "a..b", "例え.jp", "xn--r8jz45g.jp", "x\x00y")
+			}
+			if s.Chance(0.02) {
+				name = gen.Pick(s, "", "example.com.")
+			}
+			typ := 0
+			if s.Chance(0.05) {
+				typ = 1
+			}
+			names = append(names, []byte{byte(typ)}, t.vec(2, []byte(name)))
+		}
+		return t.ext(extServerName, t.vec(2, names...))
+	})
+	add(0.9, func() []byte { return t.ext(extGroups, t.list(2, groups, 1, 5, 0x001d)) })
+	add(0.5, func() []byte {
+		return t.ext(extPointFormats, t.vec(1, gen.Pick(s, []byte{0}, []byte{0}, []byte{0}, []byte{0, 1, 2}, []byte{1})))
+	})
+	add(0.9, func() []byte { return t.ext(extSigAlgs, t.list(2, sigAlgs, 1, 8, 0x0807)) })
+	add(0.5, func() []byte {
+		var protos [][]byte
+		for range s.Range(1, 3) {
+			protos = append(protos, t.vec(1, []byte(gen.Pick(s, "h2", "h2", "http/1.1", "h3", "acme-tls/1"))))
+		}
+		if s.Chance(0.02) {
+			protos = append(protos, t.vec(1))
+		}
+		return t.ext(extALPN, t.vec(2, protos...))
+	})
+	add(0.9, func() []byte { return t.ext(extSupportedVersion, t.list(1, versions, 1, 4, 0x0304, 0x0303)) })
+	add(0.8, func() []byte {
+		var shares [][]byte
+		for range s.Range(0, 3) {
+			group := gen.Pick(s, groups...)
+			shares = append(shares, u16(group), t.vec(2, t.share(group, false)))
+		}
+		if s.Chance(0.3) {
+			shares = append(shares, u16(gen.Pick(s, grease...)), t.vec(2, []byte{0}))
+		}
+		return t.ext(extKeyShare, t.vec(2, shares...))
+	})
+	add(0.5, func() []byte {
+		return t.ext(extPSKModes, t.vec(1, gen.Pick(s, []byte{1}, []byte{1}, []byte{0, 1}, []byte{0}, []byte{2})))
+	})
+	add(0.4, func() []byte { return t.ext(extRenegotiation, t.vec(1, gen.Pick(s, nil, nil, nil, t.bytes(12)))) })
+	add(0.4, func() []byte { return t.ext(extEMS) })
+	add(0.3, func() []byte { return t.ext(extSessionTicket, t.bytes(gen.Pick(s, 0, 0, 16, 200))) })
+	add(0.3, func() []byte { return t.ext(extStatusRequest, []byte{1}, t.vec(2), t.vec(2)) })
+	add(0.2, func() []byte { return t.ext(extSCT) })
+	add(0.1, func() []byte { return t.ext(extCookie, t.vec(2, t.bytes(s.Range(1, 40)))) })
+	add(0.1, func() []byte { return t.ext(extEarlyData) })
+	add(0.2, func() []byte { return t.ext(extPadding, make([]byte, s.Range(0, 300))) })
+	add(0.3, func() []byte { return t.ext(gen.Pick(s, grease...), gen.Pick(s, nil, []byte{0})) })
+	add(0.1, func() []byte {
+		return t.ext(gen.Pick(s, 0x0002, 0x3374, 0x4469, 0xfe0d, 0xfffe), t.bytes(s.Range(0, 8)))
+	})
+	for i := len(exts) - 1; i > 0; i-- {
+		j := s.Intn(i + 1)
+		exts[i], exts[j] = exts[j], exts[i]
+	}
+	if len(exts) > 0 && s.Chance(0.03) {
+		exts = append(exts, exts[s.Intn(len(exts))]) // a duplicate
+	}
+	if s.Chance(0.15) {
+		// A PSK the server cannot know, with a binder of the length one
+		// made with SHA-256 would have.
+		psk := t.ext(extPSK,
+			t.vec(2, t.vec(2, t.bytes(s.Range(1, 64))), t.bytes(4)),
+			t.vec(2, t.vec(1, t.bytes(gen.Pick(s, 32, 32, 32, 32, 48, 48, 0)))),
+		)
+		if s.Chance(0.9) || len(exts) == 0 {
+			exts = append(exts, psk)
+		} else {
+			exts = append(exts[:1], append([][]byte{psk}, exts[1:]...)...)
+		}
+	}
+	return exts
+}
+
+// certDER is the certificate of the server flights: a P-256 key, which
+// ECDHE_ECDSA suites of every version can use, signed by an Ed25519 key
+// derived from a fixed seed, so that it is the same in every seed. The
+// client fuzz/tls drives verifies neither signature.
+var certDER = func() []byte {
+	params := elliptic.P256().Params()
+	pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: params.Gx, Y: params.Gy}
+	key := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(nil, tmpl, tmpl, pub, key)
+	if err != nil {
+		panic(err)
+	}
+	return der
+}()
+
+// hrrRandom is the random of a HelloRetryRequest, and downgrade12 and
+// downgrade11 end the random of a server that could have negotiated a
+// later version than the one it did.
+var (
+	hrrRandom = []byte{
+		0xcf, 0x21, 0xad, 0x74, 0xe5, 0x9a, 0x61, 0x11, 0xbe, 0x1d, 0x8c, 0x02, 0x1e, 0x65, 0xb8, 0x91,
+		0xc2, 0xa2, 0x11, 0x16, 0x7a, 0xbb, 0x8c, 0x5e, 0x07, 0x9e, 0x09, 0xe2, 0xc8, 0xa8, 0x33, 0x9c,
+	}
+	downgrade12 = []byte("DOWNGRD\x01")
+	downgrade11 = []byte("DOWNGRD\x00")
+)
+
+// server writes a server's first flight.
+func server(s *gen.State) []gen.File {
+	t := &tgen{s: s}
+	var data []byte
+	switch {
+	case s.Chance(0.4):
+		data = t.flight13()
+	case s.Chance(0.8):
+		data = t.flight12(0x0303)
+	default:
+		data = t.flight12(gen.Pick(s, 0x0301, 0x0301, 0x0302, 0x0302, 0x0300))
+	}
+	return []gen.File{{Name: "server.tls", Data: data}}
+}
+
+// random returns a server random, now and then with a downgrade sentinel.
+func (t *tgen) random() []byte {
+	r := t.bytes(32)
+	if t.s.Chance(0.05) {
+		copy(r[24:], gen.Pick(t.s, downgrade12, downgrade11))
+	}
+	return r
+}
+
+// serverHello returns a ServerHello of the given legacy version, random,
+// session ID, suite and extensions, now and then with another session ID.
+func (t *tgen) serverHello(version int, random, id []byte, suite int, exts ...[]byte) []byte {
+	if t.s.Chance(0.05) {
+		id = t.bytes(gen.Pick(t.s, 0, 32, 33))
+	}
+	b := [][]byte{u16(version), random, t.vec(1, id), u16(suite), {0}}
+	if t.s.Chance(0.02) {
+		b[4] = []byte{1} // a compression method
+	}
+	if len(exts) > 0 || t.s.Chance(0.5) {
+		b = append(b, t.vec(2, exts...))
+	}
+	return t.handshake(typeServerHello, b...)
+}
+
+// flight13 returns a TLS 1.3 ServerHello, or a HelloRetryRequest and
+// then a ServerHello, followed by records that stand for the encrypted
+// rest of the flight, which no client can decrypt.
+func (t *tgen) flight13() []byte {
+	s := t.s
+	suite := gen.Pick(s, 0x1301, 0x1301, 0x1302, 0x1303)
+	if s.Chance(0.05) {
+		suite = gen.Pick(s, 0xc02f, 0x1304)
+	}
+	v := 0x0304
+	if s.Chance(0.05) {
+		v = gen.Pick(s, 0x0303, 0x7f1c, 0x0305)
+	}
+	version := t.ext(extSupportedVersion, u16(v))
+	// The legacy session ID of a client whose random bytes are zeros.
+	echo := make([]byte, 32)
+	group := gen.Pick(s, 0x001d, 0x11ec)
+	if s.Chance(0.1) {
+		group = gen.Pick(s, 0x0017, 0x0018)
+	}
+	var msgs [][]byte
+	if s.Chance(0.2) {
+		// A retry asks for a group the client offered no share for, as
+		// P-256 and P-384, and the ServerHello after it uses that group.
+		group = gen.Pick(s, 0x0017, 0x0017, 0x0018, 0x001d, 0xffff)
+		exts := [][]byte{version, t.ext(extKeyShare, u16(group))}
+		if s.Chance(0.5) {
+			exts = append(exts, t.ext(extCookie, t.vec(2, t.bytes(s.Range(1, 64)))))
+		}
+		msgs = append(msgs, t.serverHello(0x0303, hrrRandom, echo, suite, exts...))
+	}
+	exts := [][]byte{version, t.ext(extKeyShare, u16(group), t.vec(2, t.share(group, true)))}
+	if s.Chance(0.1) {
+		exts = append(exts, t.ext(extPSK, u16(0)))
+	}
+	if s.Chance(0.1) {
+		exts = append(exts, t.ext(gen.Pick(s, extALPN, extServerName, extEMS, 0xfafa), t.bytes(s.Range(0, 4))))
+	}
+	msgs = append(msgs, t.serverHello(0x0303, t.random(), echo, suite, exts...))
+	out := t.records(0x0303, msgs)
+	if s.Chance(0.7) {
+		out = append(out, t.record(recordCCS, 0x0303, []byte{1})...)
+	}
+	for range s.Range(1, 3) {
+		out = append(out, t.record(recordAppData, 0x0303, t.bytes(s.Range(17, 200)))...)
+	}
+	return out
+}
+
+// flight12 returns a ServerHello, Certificate, ServerKeyExchange,
+// CertificateRequest and ServerHelloDone, some of them left out or in
+// the wrong order, for a version before TLS 1.3.
+func (t *tgen) flight12(version int) []byte {
+	s := t.s
+	suite := gen.Pick(s, 0xc02b, 0xc02b, 0xc02c, 0xcca9, 0xc009, 0xc00a)
+	if version < 0x0303 {
+		suite = gen.Pick(s, 0xc009, 0xc00a)
+	}
+	if s.Chance(0.05) {
+		suite = gen.Pick(s, 0xc02f, 0xc013, 0x009c, 0x002f, 0x1301, 0xffff)
+	}
+	var exts [][]byte
+	for _, e := range []struct {
+		p   float64
+		ext func() []byte
+	}{
+		{0.7, func() []byte {
+			return t.ext(extRenegotiation, t.vec(1, gen.Pick(s, nil, nil, nil, nil, nil, nil, nil, nil, nil, t.bytes(24))))
+		}},
+		{0.6, func() []byte { return t.ext(extEMS) }},
+		{0.4, func() []byte {
+			return t.ext(extPointFormats, t.vec(1, gen.Pick(s, []byte{0}, []byte{0}, []byte{0}, []byte{0}, []byte{0}, []byte{0, 1}, []byte{0, 1}, []byte{1}, []byte{1}, nil)))
+		}},
+		{0.3, func() []byte {
+			return t.ext(extALPN, t.vec(2, t.vec(1, []byte(gen.Pick(s, "h2", "h2", "h2", "http/1.1", "http/1.1", "h3", "")))))
+		}},
+		{0.2, func() []byte { return t.ext(extSessionTicket) }},
+		{0.2, func() []byte { return t.ext(extStatusRequest) }},
+		{0.1, func() []byte { return t.ext(extSCT, t.vec(2, t.vec(2, t.bytes(s.Range(0, 40))))) }},
+		{0.1, func() []byte { return t.ext(extSupportedVersion, u16(0x0303)) }},
+		{0.1, func() []byte { return t.ext(gen.Pick(s, grease...)) }},
+	} {
+		if s.Chance(e.p) {
+			exts = append(exts, e.ext())
+		}
+	}
+	// A new session, since the client offered none to resume.
+	msgs := [][]byte{t.serverHello(version, t.random(), t.bytes(gen.Pick(s, 32, 32, 0)), suite, exts...)}
+	if !s.Chance(0.05) {
+		var certs [][]byte
+		for range gen.Pick(s, 1, 1, 1, 1, 1, 1, 1, 1, 2, 0) {
+			c := certDER
+			if s.Chance(0.05) {
+				c = gen.Pick(s, certDER[:len(certDER)/2], t.bytes(s.Range(0, 40)), append(certDER[:len(certDER):len(certDER)], 0))
+			}
+			certs = append(certs, t.vec(3, c))
+		}
+		msgs = append(msgs, t.handshake(typeCertificate, t.vec(3, certs...)))
+	}
+	if s.Chance(0.1) {
+		msgs = append(msgs, t.handshake(typeCertificateStatus, []byte{1}, t.vec(3, t.bytes(s.Range(0, 64)))))
+	}
+	if !s.Chance(0.1) {
+		group := gen.Pick(s, 0x001d, 0x001d, 0x0017, 0x0018)
+		b := [][]byte{{byte(gen.Pick(s, 3, 3, 3, 3, 3, 3, 3, 3, 1, 2))}, u16(group), t.vec(1, t.share(group, true))}
+		if version >= 0x0303 {
+			b = append(b, u16(gen.Pick(s, 0x0403, 0x0403, 0x0403, 0x0503, 0x0807, 0x0804, 0x0201, 0xffff)))
+		}
+		b = append(b, t.vec(2, t.bytes(gen.Pick(s, 64, 64, 72, 0))))
+		msgs = append(msgs, t.handshake(typeServerKeyExchange, b...))
+	}
+	if s.Chance(0.2) {
+		b := [][]byte{t.vec(1, gen.Pick(s, []byte{1, 64}, []byte{64}, nil, []byte{255}))}
+		if version >= 0x0303 {
+			b = append(b, t.list(2, sigAlgs, 0, 4))
+		}
+		var cas [][]byte
+		for range s.Range(0, 2) {
+			cas = append(cas, t.vec(2, gen.Pick(s, []byte{0x30, 0x00}, t.bytes(s.Range(0, 20)))))
+		}
+		b = append(b, t.vec(2, cas...))
+		msgs = append(msgs, t.handshake(typeCertificateReq, b...))
+	}
+	msgs = append(msgs, t.handshake(typeServerHelloDone, gen.Pick(s, nil, nil, nil, []byte{0})))
+	if len(msgs) > 2 && s.Chance(0.05) {
+		// Two messages out of order.
+		i := s.Range(1, len(msgs)-1)
+		msgs[i-1], msgs[i] = msgs[i], msgs[i-1]
+	}
+	return t.records(version, msgs)
+}
@@ -0,0 +1,357 @@
+// Package tmplsrc generates text/template and html/template seeds. It
+// registers the "tmpl/..." generators with package gen.
+//
+// Templates use the fields, methods and functions of the data that
+// fuzz/template executes them with: .Name, .URL, .CSS and .JS hold
+// strings built to break out of their context, .N, .F and .B are scalars,
+// .Items, .M, .Any, .Nested and .Nil are for range and with, and upper,
+// join, fail and boom are the extra functions. Most actions are well
+// formed; each kind also has malformed variants, drawn rarely, because a
+// single bad action fails the whole template.
+package tmplsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "tmpl/text",
+		Doc:  "text/template: nested if/range/with/block, pipelines, variables, break/continue, trim markers and malformed delimiters",
+		Func: textTemplate,
+	})
+	gen.Register(&gen.Generator{
+		Name: "tmpl/html",
+		Doc:  "html/template: actions in text, attribute, URL, srcset, event handler, style, script and RCDATA contexts, and branches that end in different ones",
+		Func: htmlTemplate,
+	})
+}
+
+// badRate is the chance that a piece of an action is drawn in a
+// malformed form. A template has a few hundred pieces, so about a third
+// of templates get one.
+const badRate = 0.002
+
+// A tmpl accumulates a template and the names of the templates it
+// defines.
+type tmpl struct {
+	s       *gen.State
+	b       strings.Builder
+	defined []string
+	depth   int // bound on the nesting of control structures
+	ranges  int // range actions around the current position
+	vars    []string
+	leaf    func(t *tmpl) // writes text and actions with no structure
+}
+
+func newTmpl(s *gen.State, leaf func(t *tmpl)) *tmpl {
+	return &tmpl{s: s, depth: s.Depth(s.Limits.Block, 4), leaf: leaf}
+}
+
+func (t *tmpl) write(format string, args ...any) {
+	fmt.Fprintf(&t.b, format, args...)
+}
+
+// action writes {{body}}, with trim markers by chance.
+func (t *tmpl) action(body string) {
+	l, r := "{{", "}}"
+	if t.s.Chance(0.1) {
+		l = "{{- "
+	}
+	if t.s.Chance(0.1) {
+		r = " -}}"
+	}
+	t.write("%s%s%s", l, body, r)
+}
+
+// files returns the template as a single-file seed named name.
+func (t *tmpl) files(name string) []gen.File {
+	return []gen.File{{Name: name, Data: []byte(t.b.String())}}
+}
+
+// fields are the data's fields and methods, as the data and as a field
+// of .Nested; .Nil is a nil *Data, so its fields fail at run time.
+var fields = []string{
+	".Name", ".URL", ".CSS", ".JS", ".N", ".F", ".B", ".Items", ".M", ".M.k", ".M.missing",
+	".Any", ".Nested", ".Nested.Name", ".Nested.Nested.Items", ".Nil", ".Nil.Name",
+	".Method", ".Err", ".Fn",
+}
+
+// operand returns a field, variable, constant or parenthesized pipeline.
+func (t *tmpl) operand(depth int) string {
+	if t.s.Chance(badRate) {
+		return gen.Pick(t.s, ".Missing", "$undefined", ".Name.Deeper", "..", ".N.", `"unterminated`, "'ab'", "0x", "1e", "(", "@")
+	}
+	switch t.s.Intn(6) {
+	case 0, 1:
+		return gen.Pick(t.s, fields...)
+	case 2:
+		if len(t.vars) > 0 {
+			return gen.Pick(t.s, t.vars...) + gen.Pick(t.s, "", "", ".Name", ".N")
+		}
+		return gen.Pick(t.s, "$", "$.Name", "$.Items")
+	case 3:
+		return gen.Pick(t.s, "1", "-2", "0x10", "1_000", "1.5", "1e3", "0i", "'a'", `'\n'`,
+			`"str"`, `"<b>\"q\"</b>"`, "`raw`", "true", "false", "nil", "9223372036854775807")
+	case 4:
+		if depth > 0 {
+			return "(" + t.pipeline(depth-1) + ")"
+		}
+	}
+	return "."
+}
+
+// command returns a function call, method call or operand.
+func (t *tmpl) command(depth int) string {
+	a := func() string { return t.operand(depth) }
+	switch t.s.Intn(8) {
+	case 0:
+		return gen.Pick(t.s, "len", "print", "println", "html", "js", "urlquery", "not") + " " + a()
+	case 1:
+		return fmt.Sprintf("%s %s %s", gen.Pick(t.s, "eq", "ne", "lt", "le", "gt", "ge", "and", "or", "index", "printf"), a(), a())
+	case 2:
+		return gen.Pick(t.s,
+			`printf "%d %q %v" .N .Name .Items`, `index .Items 0`, `index .M "k"`, `index .Items 99`,
+			`slice .Name 1 3`, `slice .Items 1`, `call .Fn 2`, `.Method "arg"`, `.Nested.Method .Name`,
+			`upper .Name`, `join .Items ", "`, "fail", "boom", `eq .N 1 2 3`, `and .B .Nil.Name`, `or 0 "" .Name`,
+		)
+	case 3:
+		if t.s.Chance(badRate * 8) {
+			return gen.Pick(t.s, "len", "index", "call .N", "printf", `slice .N 1`, "upper 1 2", "eq", "nofunc .Name")
+		}
+	}
+	return a()
+}
+
+// pipeline returns commands joined by |, sometimes declaring or
+// assigning a variable.
+func (t *tmpl) pipeline(depth int) string {
+	cmds := []string{t.command(depth)}
+	for range t.s.Intn(3) {
+		cmds = append(cmds, gen.Pick(t.s, "print", `printf "%q"`, "html", "js", "urlquery", "len", "upper", "printf \"%v\" 1"))
+	}
+	p := strings.Join(cmds, " | ")
+	if t.s.Chance(badRate) {
+		return gen.Pick(t.s, p+" |", "| "+p, p+" | 1", "$x = "+p, p+" :=", ":= "+p)
+	}
+	return p
+}
+
+// decl returns a pipeline that declares a new variable, or assigns one in
+// scope.
+func (t *tmpl) decl() string {
+	if len(t.vars) > 0 && t.s.Chance(0.3) {
+		return gen.Pick(t.s, t.vars...) + " = " + t.pipeline(1)
+	}
+	v, p := t.s.Fresh("$v"), t.pipeline(1)
+	t.vars = append(t.vars, v)
+	return v + " := " + p
+}
+
+// body writes text, actions and control structures down to depth.
+func (t *tmpl) body(depth int) {
+	for range t.s.Range(1, 5) {
+		if depth <= 0 || t.s.Chance(0.4) {
+			t.leaf(t)
+			continue
+		}
+		t.control(depth)
+	}
+}
+
+// scoped writes a body whose variables go out of scope at its end.
+func (t *tmpl) scoped(depth int) {
+	n := len(t.vars)
+	t.body(depth)
+	t.vars = t.vars[:n]
+}
+
+// control writes one control structure, or a malformed one.
+func (t *tmpl) control(depth int) {
+	if t.s.Chance(badRate) {
+		t.write("%s", gen.Pick(t.s,
+			"{{end}}", "{{else}}", "{{if}}", "{{if .B}}unclosed", "{{range}}{{end}}", "{{with}}{{end}}",
+			"{{break}}", "{{continue}}", `{{define "inner"}}{{end}}`, "{{template}}", "{{template .Name}}",
+			"{{if .B}}{{else}}{{else}}{{end}}", "{{range $a, $b, $c := .M}}{{end}}", "{{range .Items}}{{end}}{{end}}",
+			"{{", "}}{{", "{{{.Name}}}", "{{/* unterminated", "{{/*x*/ .Name}}", "{{- 3}}", "{{-3}}", "{{.Name -}", "{{ }}",
+		))
+		return
+	}
+	switch t.s.Intn(6) {
+	case 0:
+		t.action("if " + t.pipeline(1))
+		t.scoped(depth - 1)
+		for range t.s.Intn(3) {
+			t.action("else if " + t.pipeline(1))
+			t.scoped(depth - 1)
+		}
+		if t.s.Chance(0.5) {
+			t.action("else")
+			t.scoped(depth - 1)
+		}
+		t.action("end")
+	case 1:
+		head := t.pipeline(1)
+		if t.s.Chance(0.3) {
+			head = gen.Pick(t.s, ".Items", ".M", ".N", "3", "0", ".Nil", ".Nested.Items", "$.Items")
+		}
+		n := len(t.vars)
+		switch t.s.Intn(3) {
+		case 0:
+			v := t.s.Fresh("$e")
+			t.vars = append(t.vars, v)
+			head = v + " := " + head
+		case 1:
+			k, v := t.s.Fresh("$i"), t.s.Fresh("$e")
+			t.vars = append(t.vars, k, v)
+			head = k + ", " + v + " := " + head
+		}
+		t.action("range " + head)
+		t.ranges++
+		t.scoped(depth - 1)
+		if t.s.Chance(0.3) {
+			t.action(gen.Pick(t.s, "break", "continue"))
+		}
+		t.ranges--
+		t.vars = t.vars[:n]
+		if t.s.Chance(0.3) {
+			t.action("else")
+			t.scoped(depth - 1)
+		}
+		t.action("end")
+	case 2:
+		n := len(t.vars)
+		if t.s.Chance(0.3) {
+			t.action("with " + t.decl())
+		} else {
+			t.action("with " + t.pipeline(1))
+		}
+		t.scoped(depth - 1)
+		if t.s.Chance(0.3) {
+			t.action("else with " + t.pipeline(1))
+			t.scoped(depth - 1)
+		}
+		if t.s.Chance(0.3) {
+			t.action("else")
+			t.scoped(depth - 1)
+		}
+		t.vars = t.vars[:n]
+		t.action("end")
+	case 3:
+		name := t.s.Fresh("block")
+		t.defined = append(t.defined, name)
+		t.action(fmt.Sprintf("block %q %s", name, gen.Pick(t.s, ".", ".Nested", "$", ".Items")))
+		// A block's body is a template of its own, so the variables
+		// around it are out of scope.
+		vars, ranges := t.vars, t.ranges
+		t.vars, t.ranges = nil, 0
+		t.body(depth - 1)
+		t.vars, t.ranges = vars, ranges
+		t.action("end")
+	case 4:
+		if t.ranges > 0 {
+			t.action(gen.Pick(t.s, "if .B}}{{break}}{{end", "break", "continue", "if eq .N 3}}{{continue}}{{end"))
+			return
+		}
+		t.action(t.decl())
+	default:
+		if len(t.defined) > 0 {
+			t.action(fmt.Sprintf("template %q %s", gen.Pick(t.s, t.defined...), gen.Pick(t.s, ".", ".Nested", ".Nil", "$", t.pipeline(0))))
+			return
+		}
+		t.action(t.decl())
+	}
+}
+
+// defines writes {{define}} blocks at the top level, some of them calling
+// themselves: through .Nested, which ends, or on ., which goes on until
+// the executor's depth limit.
+func (t *tmpl) defines() {
+	for range t.s.Intn(3) {
+		name := t.s.Fresh("t")
+		t.defined = append(t.defined, name)
+		t.write("{{define %q}}", name)
+		t.body(t.depth - 1)
+		t.vars = nil
+		switch t.s.Intn(4) {
+		case 0:
+			t.write("{{with .Nested}}{{template %q .}}{{end}}", name)
+		case 1:
+			t.write("{{if .B}}{{template %q .}}{{end}}", name)
+		}
+		t.write("{{end}}")
+		if t.s.Chance(0.5) {
+			t.write("\n")
+		}
+	}
+}
+
+// textLeaf writes plain text, comments and value actions.
+func textLeaf(t *tmpl) {
+	switch t.s.Intn(5) {
+	case 0:
+		t.write("%s", gen.Pick(t.s, "Hello, ", "\n", "  ", "\t", "{ ", "}", "{ { ", "} }", "}}", "<b>", "名前", "\r\n", "x"))
+	case 1:
+		t.write("%s", gen.Pick(t.s, "{{/* comment */}}", "{{- /* trimmed */ -}}", "{{/* multi\nline */}}", "{{- /**/}}"))
+	default:
+		t.action(t.pipeline(2))
+	}
+}
+
+func textTemplate(s *gen.State) []gen.File {
+	t := newTmpl(s, textLeaf)
+	t.defines()
+	t.body(t.depth)
+	return t.files("page.tmpl")
+}
+
+// htmlLeaf writes an HTML fragment with actions in one of the contexts
+// the escaper tracks, occasionally one it must reject.
+func htmlLeaf(t *tmpl) {
+	a := func() string { return "{{" + t.pipeline(1) + "}}" }
+	f := gen.Pick(t.s,
+		"<p>%s</p>", "<b title=\"%s\">x</b>", "<b title='%s'>x</b>", "<b title=%s>x</b>",
+		"<a href=\"%s\">x</a>", "<a href=\"/search?q=%s&amp;p=1\">x</a>", "<a href='https://example.com/%s'>x</a>",
+		"<img src=%s>", "<img srcset=\"%s 2x, /b.png 1x\">", "<form action=\"%s\"></form>",
+		"<button onclick=\"f(%s)\">x</button>", "<body onload='x = \"%s\"'>",
+		"<p style=\"color: %s\">x</p>", "<p style='background: url(%s)'>x</p>",
+		"<script>var x = %s;</script>", "<script>var s = \"%s\";</script>", "<script>var s = '%s';</script>",
+		"<script>var t = `a${ %s }b`;</script>", "<script>var r = /%s/;</script>", "<script>// %s\n</script>",
+		"<script type=\"module\">import(%s)</script>", "<script type=\"application/ld+json\">{\"a\": %s}</script>",
+		"<script type=\"text/template\">%s</script>",
+		"<style>p { color: %s }</style>", "<style>p::after { content: \"%s\" }</style>",
+		"<textarea>%s</textarea>", "<title>%s</title>", "<!-- %s -->", "<div data-x=\"%s\">x</div>",
+		"<svg><a xlink:href=\"%s\">x</a></svg>", "<iframe srcdoc=\"%s\"></iframe>", "<meta http-equiv=\"refresh\" content=\"0; url=%s\">",
+		"%s", "%s", "%s",
+	)
+	if t.s.Chance(badRate * 5) {
+		// Contexts the escaper rejects or cannot see the end of.
+		f = gen.Pick(t.s,
+			"<p %s=\"x\">", "<%s>", "<a href=\"%s", "<script>/* %s", "<a href=%s", "<p title=\"%s\"/>",
+			"<a href=\"{{if .B}}/x{{else}}javascript:{{end}}%s\">x</a>", "<script>var s = \"{{if .B}}\"{{end}}%s\";</script>",
+			"<a onclick=\"{{if .B}}'{{end}}%s\">x</a>", "<style>/* %s</style>", "<script>`%s</script>",
+		)
+	}
+	switch strings.Count(f, "%s") {
+	case 1:
+		t.write(f, a())
+	default:
+		t.write("%s", f)
+	}
+}
+
+func htmlTemplate(s *gen.State) []gen.File {
+	t := newTmpl(s, htmlLeaf)
+	t.defines()
+	if s.Chance(0.5) {
+		t.write("<!DOCTYPE html>\n<html><head><title>{{.Name}}</title></head><body>\n")
+		t.body(t.depth)
+		t.write("\n</body></html>\n")
+	} else {
+		t.body(t.depth)
+	}
+	return t.files("page.html")
+}
@@ -0,0 +1,493 @@
+// Package tomlsrc generates TOML seeds. It registers the "toml/..."
+// generators with package gen.
+//
+// A document is key/value pairs at the root and then under table and
+// array-of-tables headers, whose paths extend the headers before them,
+// with bare, quoted and dotted keys and values of every type: strings
+// basic, literal and multiline, integers in each base, floats with their
+// infinities and NaNs, the four kinds of datetime, and arrays and inline
+// tables nested in each other. A few documents define a table twice, or
+// as a table and an array of tables, or by a header and a dotted key,
+// which TOML forbids; each construct also has malformed variants, drawn
+// rarely, because a single bad one fails the document.
+package tomlsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "toml/doc",
+		Doc:  "TOML documents: tables and arrays of tables nested by their headers, bare, quoted and dotted keys, inline tables, basic, literal and multiline strings, integers in every base, special floats, offset and local datetimes, TOML 1.1 forms, and tables redefined by headers, arrays of tables and dotted keys now and then",
+		Func: doc,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// document has a few dozen of them, so about a tenth of them get one.
+const badRate = 0.003
+
+// conflictRate is the chance that a document defines a table twice over.
+const conflictRate = 0.1
+
+// keys are the keys of key/value pairs, bare and quoted.
+var keys = []string{
+	"a", "b", "name", "key", "port", "enabled", "title", "x-y", "_", "1234", "-", "true", "inf", "nan",
+	`"quoted key"`, `"a.b"`, `""`, `'lit'`, `"キー"`, `"\u00e9"`, `"tab\there"`, `'#'`, `"="`,
+}
+
+// headers are the parts of the paths of table headers, which keys are
+// not, so that a header does not run into a key by chance.
+var headers = []string{"server", "database", "fruit", "physical", "variety", "owner", "t", "u", `"s p"`, `'x.y'`, "v1"}
+
+// A tdoc accumulates a TOML document.
+type tdoc struct {
+	s *gen.State
+	b strings.Builder
+	// nl is the line ending of the document.
+	nl string
+	// tables are the paths of the tables headers defined, and arrays
+	// those of the arrays of tables.
+	tables, arrays []string
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &tdoc{s: s, nl: "\n"}
+	if s.Chance(0.2) {
+		d.nl = "\r\n"
+	}
+	if s.Chance(0.1) {
+		d.line("# " + gen.Pick(s, "This is a TOML document", "comment = 1", "[not.a.table]", "é ☺"))
+	}
+	depth := s.Depth(s.Limits.Literal, 3)
+	d.pairs(depth)
+	n := s.Range(0, 6)
+	conflict := -1
+	if s.Chance(conflictRate) {
+		conflict = s.Range(0, n)
+	}
+	for i := range n + 1 {
+		if i == conflict {
+			d.conflict()
+		}
+		if i < n {
+			d.section(depth)
+		}
+	}
+	if s.Chance(badRate * 5) {
+		d.b.WriteString(gen.Pick(s, "[", "[[a]", "a =", "= 1", "a = 1 b = 2", "\x00", "\r", "a = 1\rb = 2"))
+	}
+	return []gen.File{{Name: "input.toml", Data: []byte(d.b.String())}}
+}
+
+func (d *tdoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+func (d *tdoc) broken() bool { return d.s.Chance(badRate) }
+
+// line writes text and a line ending, now and then after a comment or
+// space.
+func (d *tdoc) line(text string) {
+	d.b.WriteString(text)
+	if d.s.Chance(0.1) {
+		d.b.WriteString(gen.Pick(d.s, " ", "\t", "  "))
+	}
+	if d.s.Chance(0.1) {
+		d.b.WriteString(d.comment())
+	}
+	d.b.WriteString(d.nl)
+}
+
+// comment returns a comment, which may not hold a control character.
+func (d *tdoc) comment() string {
+	if d.broken() {
+		return gen.Pick(d.s, "# \x01", "# \x7f", "# a\rb", "# \xff")
+	}
+	return gen.Pick(d.s, " # comment", "# ", " #x = 1", " # [t]", " # é\t☺", " #")
+}
+
+// pairs writes the key/value pairs of a table, each with a key of its
+// own. Dotted keys share their first part with one another, and only
+// with one another, as a table they define together.
+func (d *tdoc) pairs(depth int) {
+	s := d.s
+	used := map[string]bool{}
+	var dotted []string
+	for range s.Range(0, 5) {
+		key := d.key(used)
+		if s.Chance(0.2) {
+			// A dotted key, in a table of dotted keys old or new.
+			if len(dotted) > 0 && s.Chance(0.5) {
+				key = gen.Pick(s, dotted...)
+			} else {
+				dotted = append(dotted, key)
+			}
+			key += gen.Pick(s, ".", " . ", ".") + s.Fresh("k")
+		}
+		if s.Chance(0.1) {
+			d.b.WriteString(gen.Pick(s, " ", "\t"))
+		}
+		d.line(key + gen.Pick(s, " = ", "=", " =\t", "  =  ") + d.value(depth))
+	}
+	if s.Chance(0.2) {
+		d.b.WriteString(d.nl)
+	}
+}
+
+// key returns a key not in used, which it adds it to. A broken one may
+// be in used already, or not a key at all.
+func (d *tdoc) key(used map[string]bool) string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, "a b", "é", "a.", ".a", "a..b", `"unterminated`, "'a'b", "#", "[a]", `"\q"`, "\"a\nb\"", "")
+	}
+	if d.broken() {
+		for k := range used {
+			return k // a key defined twice
+		}
+	}
+	for range 8 {
+		k := gen.Pick(s, keys...)
+		if !used[k] {
+			used[k] = true
+			return k
+		}
+	}
+	k := s.Fresh("key")
+	used[k] = true
+	return k
+}
+
+// section writes a table or array-of-tables header and the pairs of the
+// table it starts.
+func (d *tdoc) section(depth int) {
+	s := d.s
+	array := s.Chance(0.3)
+	var path string
+	if array && len(d.arrays) > 0 && s.Chance(0.5) {
+		// Another table of an array of tables.
+		path = gen.Pick(s, d.arrays...)
+	} else {
+		// A table inside one before it, which may be an array of tables,
+		// whose last table it is inside then, or at the root.
+		parents := append(append([]string{}, d.tables...), d.arrays...)
+		if len(parents) > 0 && s.Chance(0.6) {
+			path = gen.Pick(s, parents...) + sep + gen.Pick(s, headers...)
+		} else {
+			path = gen.Pick(s, headers...)
+		}
+		if !d.free(path, array) {
+			path += sep + s.Fresh("t")
+		}
+		if array {
+			d.arrays = append(d.arrays, path)
+		} else {
+			d.tables = append(d.tables, path)
+		}
+	}
+	if s.Chance(0.2) {
+		d.b.WriteString(d.nl)
+	}
+	if s.Chance(0.1) {
+		d.b.WriteString(gen.Pick(s, " ", "\t"))
+	}
+	open, close := "[", "]"
+	if array {
+		open, close = "[[", "]]"
+		if d.broken() {
+			close = gen.Pick(s, "] ]", "]", "]]]")
+		}
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(path, sep) {
+		if i > 0 {
+			b.WriteString(gen.Pick(s, ".", ".", " . ", "\t."))
+		}
+		b.WriteString(part)
+	}
+	if s.Chance(0.1) {
+		d.line(open + gen.Pick(s, " ", "\t") + b.String() + gen.Pick(s, " ", "\t") + close)
+	} else {
+		d.line(open + b.String() + close)
+	}
+	d.pairs(depth)
+}
+
+// sep separates the parts of the paths of tables, which a quoted part
+// may have a dot in.
+const sep = "\x00"
+
+// free reports whether a header may define path as a table, or as an
+// array of tables, one not defined yet or, for an array, inside one.
+func (d *tdoc) free(path string, array bool) bool {
+	for _, p := range append(append([]string{}, d.tables...), d.arrays...) {
+		if p == path || array && strings.HasPrefix(p, path+sep) {
+			return false
+		}
+	}
+	return true
+}
+
+// conflict writes tables that TOML forbids: one defined twice, by
+// headers, dotted keys or a key and a header, or as both a table and an
+// array of tables. Some of them look much like ones that are allowed,
+// which it writes now and then instead.
+func (d *tdoc) conflict() {
+	s := d.s
+	c := s.Fresh("c")
+	nl := d.nl
+	switch s.Intn(14) {
+	case 0:
+		d.write("[%s]%sx = 1%s[%s]%s", c, nl, nl, c, nl)
+	case 1:
+		d.write("[%s]%s[[%s]]%s", c, nl, c, nl)
+	case 2:
+		d.write("[[%s]]%s[%s]%s", c, nl, c, nl)
+	case 3:
+		d.write("[%s]%sd = 1%s[%s.d]%s", c, nl, nl, c, nl)
+	case 4:
+		d.write("[%s]%sd.e = 1%s[%s.d]%s", c, nl, nl, c, nl)
+	case 5:
+		d.write("[%s.d]%s[%s]%sd = 1%s", c, nl, c, nl, nl)
+	case 6:
+		d.write("[%s]%sd = {}%s[%s.d]%s", c, nl, nl, c, nl)
+	case 7:
+		d.write("[%s]%sd = {e = 1}%sd.f = 2%s", c, nl, nl, nl)
+	case 8:
+		d.write("[%s]%sd = [1]%s[[%s.d]]%s", c, nl, nl, c, nl)
+	case 9:
+		d.write("[%s]%s[%s.d]%s[%s]%s", c, nl, c, nl, c, nl)
+	case 10:
+		d.write("[[%s]]%sd = 1%s[%s.d]%s", c, nl, nl, c, nl)
+	case 11:
+		// Allowed: each table of an array of tables has its own d.
+		d.write("[[%s]]%s[%s.d]%s[[%s]]%s[%s.d]%s", c, nl, c, nl, c, nl, c, nl)
+	case 12:
+		// Allowed: a table after one inside it.
+		d.write("[%s.d]%s[%s]%s", c, nl, c, nl)
+	case 13:
+		// Allowed: a table inside one dotted keys define.
+		d.write("[%s]%sd.e = 1%s[%s.d.f]%s", c, nl, nl, c, nl)
+	}
+}
+
+// value returns a value of any type, arrays and inline tables nesting
+// down to depth.
+func (d *tdoc) value(depth int) string {
+	s := d.s
+	if depth > 0 && s.Chance(0.25) {
+		if s.Chance(0.6) {
+			return d.array(depth - 1)
+		}
+		return d.inline(depth - 1)
+	}
+	switch s.Intn(8) {
+	case 0, 1:
+		return d.str()
+	case 2:
+		return d.integer()
+	case 3:
+		return d.float()
+	case 4:
+		if d.broken() {
+			return gen.Pick(s, "True", "FALSE", "yes", "t")
+		}
+		return gen.Pick(s, "true", "false")
+	case 5:
+		return d.datetime()
+	default:
+		return gen.Pick(s, d.str, d.integer, d.float, d.datetime)()
+	}
+}
+
+// str returns a string in one of the four forms.
+func (d *tdoc) str() string {
+	s := d.s
+	switch s.Intn(6) {
+	case 0, 1, 2:
+		return d.basic()
+	case 3:
+		if d.broken() {
+			return gen.Pick(s, "'unterminated", "'a\nb'", "'a'b'", "'\x01'")
+		}
+		return gen.Pick(s, `''`, `'C:\Users\nodejs\templates'`, `'\\ServerX\admin$\system32\'`, `'Tom "Dubs" Preston-Werner'`, `'<\i\c*\s*>'`, "'é\t☺'")
+	case 4:
+		return d.multiline()
+	default:
+		if d.broken() {
+			return gen.Pick(s, "'''unterminated", "''''''''", "'''a\x01'''")
+		}
+		return "'''" + gen.Pick(s, "", d.nl) + gen.Pick(s,
+			`I [dw]on't need \d{2} apples`,
+			"The first newline is"+d.nl+"trimmed in raw strings."+d.nl+"   All other whitespace"+d.nl+"   is preserved."+d.nl,
+			"Here are fifteen quotation marks: \"\"\"\"\"\"\"\"\"\"\"\"\"\"\"",
+			"'That,' she said, 'is still pointless.'",
+			"a''", "\\", "\t",
+		) + "'''"
+	}
+}
+
+// basic returns a basic string with escapes of every kind.
+func (d *tdoc) basic() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, `"unterminated`, `"\q"`, `"\u12"`, `"\uD800"`, `"\U00110000"`, `"\x4"`, "\"\x01\"", "\"a\nb\"", `"\"`, `"\ "`)
+	}
+	var b strings.Builder
+	b.WriteByte('"')
+	for range s.Range(0, 5) {
+		b.WriteString(gen.Pick(s,
+			"a", "hello world", "é", "☺", "日本", " ", "\t", "#", "=", "'", "[x]", "{}",
+			`\"`, `\\`, `\b`, `\t`, `\n`, `\f`, `\r`, `\u00e9`, `\u0000`, `\U0001F600`, `\u007f`, `\uFFFF`,
+		))
+		if s.Chance(0.02) {
+			// TOML 1.1 escapes.
+			b.WriteString(gen.Pick(s, `\e`, `\x41`, `\xff`, `\x00`))
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// multiline returns a multiline basic string, with lines joined by a
+// backslash that ends them and quotes short of three inside.
+func (d *tdoc) multiline() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, `"""unterminated`, `""""""""`, `"""\q"""`, `"""a\ b"""`, "\"\"\"\x01\"\"\"")
+	}
+	var b strings.Builder
+	b.WriteString(`"""`)
+	if s.Chance(0.5) {
+		b.WriteString(d.nl) // trimmed
+	}
+	for range s.Range(0, 5) {
+		b.WriteString(gen.Pick(s,
+			"Roses are red", "Violets are blue", "The quick brown", `\t`, `\u00e9`, "é", `""`, `"`, `\"""`, " ", "\t",
+			d.nl, `\`+d.nl, `\`+d.nl+"    ", "\\ \t"+d.nl+d.nl+"  ",
+		))
+	}
+	b.WriteString(gen.Pick(s, `"""`, `"""`, `""""`, `"""""`))
+	return b.String()
+}
+
+// integer returns an integer in one of the four bases, at or past the
+// limits of int64 now and then.
+func (d *tdoc) integer() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, "01", "+0x1", "0X10", "0x", "1__000", "_1", "1_", "9223372036854775808", "-9223372036854775809", "0b2", "0o8", "0xg", "--1", "1e")
+	}
+	return gen.Pick(s,
+		"0", "+0", "-0", "1", "+99", "42", "-17", "1_000", "5_349_221", "53_49_221", "1_2_3_4_5",
+		"0xDEADBEEF", "0xdeadbeef", "0xdead_beef", "0o01234567", "0o755", "0b11010110", "0x0",
+		"9223372036854775807", "-9223372036854775808", "0x7FFFFFFFFFFFFFFF", "0o777777777777777777777",
+		"0b111111111111111111111111111111111111111111111111111111111111111",
+	)
+}
+
+// float returns a float, or one of its infinities or NaNs.
+func (d *tdoc) float() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s, ".7", "7.", "3.e+20", "1e", "nan123", "Inf", "NaN", "1._5", "1_.5", "0x1p3", "1e_3", "00.1", "1e309")
+	}
+	return gen.Pick(s,
+		"+1.0", "3.1415", "-0.01", "5e+22", "1e06", "-2E-2", "6.626e-34", "224_617.445_991_228", "0.0", "-0.0", "+0.0",
+		"inf", "+inf", "-inf", "nan", "+nan", "-nan", "1e308", "1.7976931348623157e308", "5e-324", "1e-400",
+		"9007199254740993.0", "0.1", "1E1_0",
+	)
+}
+
+// datetime returns an offset or a local datetime, a date or a time.
+func (d *tdoc) datetime() string {
+	s := d.s
+	if d.broken() {
+		return gen.Pick(s,
+			"1979-02-30", "1900-02-29", "1979-05-27T25:00:00", "1979-5-27", "1979-05-27T07:32:00+25:00", "07:60:00",
+			"1979-05-27T07:32:00.Z", "1979-05-27t", "1979-05-27T07:32:00+0700", "24:00:00", "1979-13-01",
+			"1990-12-31T23:59:60Z",
+		)
+	}
+	if s.Chance(0.05) {
+		// TOML 1.1 leaves out the seconds.
+		return gen.Pick(s, "07:32", "1979-05-27T07:32", "1979-05-27 07:32Z", "1979-05-27T07:32-07:00")
+	}
+	return gen.Pick(s,
+		"1979-05-27T07:32:00Z", "1979-05-27T00:32:00-07:00", "1979-05-27T00:32:00.999999-07:00", "1979-05-27 07:32:00Z",
+		"1979-05-27t07:32:00z", "1979-05-27T07:32:00", "1979-05-27T00:32:00.999999", "1979-05-27 00:32:00.123456789123",
+		"1979-05-27", "2000-02-29", "0001-01-01", "9999-12-31", "07:32:00", "00:32:00.999999", "23:59:59.9999999999",
+		"1987-07-05T17:45:00+14:00", "1987-07-05T17:45:00-00:00",
+	)
+}
+
+// array returns an array of values of any types, over several lines and
+// with comments now and then.
+func (d *tdoc) array(depth int) string {
+	s := d.s
+	n := s.Range(0, 5)
+	multi := n > 0 && s.Chance(0.3)
+	var b strings.Builder
+	b.WriteByte('[')
+	for i := range n {
+		if i > 0 {
+			b.WriteString(gen.Pick(s, ", ", ",", " , "))
+		}
+		if multi {
+			if s.Chance(0.3) {
+				b.WriteString(d.comment())
+			}
+			b.WriteString(d.nl + "  ")
+		}
+		if d.broken() {
+			b.WriteString(gen.Pick(s, "", ",", "a", "=1"))
+			continue
+		}
+		b.WriteString(d.value(depth))
+	}
+	if n > 0 && s.Chance(0.2) {
+		b.WriteString(",") // a trailing comma, allowed in an array
+	}
+	if multi {
+		b.WriteString(d.nl)
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// inline returns an inline table, on one line, but for now and then as
+// TOML 1.1 allows.
+func (d *tdoc) inline(depth int) string {
+	s := d.s
+	used := map[string]bool{}
+	n := s.Range(0, 4)
+	// TOML 1.1 allows newlines and a trailing comma.
+	v11 := n > 0 && s.Chance(0.03)
+	var b strings.Builder
+	b.WriteString(gen.Pick(s, "{", "{ "))
+	for i := range n {
+		if i > 0 {
+			b.WriteString(gen.Pick(s, ", ", ","))
+		}
+		if v11 {
+			b.WriteString(d.nl + "  ")
+		}
+		key := d.key(used)
+		if s.Chance(0.2) {
+			key += "." + s.Fresh("k")
+		}
+		b.WriteString(key + gen.Pick(s, " = ", "=") + d.value(depth))
+	}
+	if v11 {
+		b.WriteString("," + d.nl)
+	} else if n > 0 && d.broken() {
+		b.WriteString(",")
+	}
+	b.WriteString(gen.Pick(s, "}", " }"))
+	return b.String()
+}
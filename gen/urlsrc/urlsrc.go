@@ -0,0 +1,301 @@
+// Package urlsrc generates URL seeds. It registers the "url/..."
+// generator with package gen.
+//
+// A seed is one URL reference, input.url, in any of the forms a parser
+// meets: absolute URLs with an authority, scheme-relative ones, absolute
+// paths as a request line carries them, relative references, opaque
+// URLs and strings that only look like one of these. Most are what a
+// browser or an HTTP client would send; the rest are the shapes URL
+// confusion attacks take, where two parsers, or one parser and the
+// code that checks what it returns, may disagree on the host: userinfo
+// holding an '@', a ':' or an encoded one, hosts that are IPv4 numbers
+// written as no resolver writes them, IPv6 literals with zone
+// identifiers escaped well or badly, backslashes where slashes belong,
+// semicolons in queries, and percent-encodings cut short, of
+// delimiters or of bytes that are not UTF-8.
+package urlsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "url/ref",
+		Doc:  "URL references: absolute, scheme-relative, absolute-path, relative and opaque forms with userinfo tricks, odd IPv4 and IPv6 hosts with zone identifiers, ports out of range, backslashes, semicolons and percent-encoding boundary cases",
+		Func: ref,
+	})
+}
+
+// trickRate is the chance that a part of a URL is written the way URL
+// confusion attacks write it; badRate the chance that it is plainly
+// malformed. A URL has about six parts.
+const (
+	trickRate = 0.08
+	badRate   = 0.02
+)
+
+// A ugen writes the URL of one seed.
+type ugen struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func ref(s *gen.State) []gen.File {
+	u := &ugen{s: s}
+	switch s.Intn(10) {
+	case 0, 1, 2, 3:
+		u.scheme()
+		u.b.WriteString(u.slashes())
+		u.authority()
+		u.path(true)
+	case 4:
+		u.b.WriteString(u.slashes())
+		u.authority()
+		u.path(true)
+	case 5, 6:
+		u.b.WriteString("/")
+		u.path(false)
+	case 7:
+		u.relative()
+	case 8:
+		u.opaque()
+	default:
+		u.odd()
+	}
+	if s.Chance(0.5) {
+		u.query()
+	}
+	if s.Chance(0.2) {
+		u.fragment()
+	}
+	out := u.b.String()
+	if s.Chance(badRate) {
+		// Whitespace and control bytes around or inside the URL, which
+		// some parsers strip and others reject.
+		c := gen.Pick(s, " ", "\t", "\n", "\r\n", "\x00", "\x7f", "\x1f", "\u00a0", "\u3000")
+		switch s.Intn(3) {
+		case 0:
+			out = c + out
+		case 1:
+			out += c
+		default:
+			i := s.Intn(len(out) + 1)
+			out = out[:i] + c + out[i:]
+		}
+	}
+	return []gen.File{{Name: "input.url", Data: []byte(out)}}
+}
+
+func (u *ugen) write(format string, args ...any) {
+	fmt.Fprintf(&u.b, format, args...)
+}
+
+// scheme writes a scheme and its colon.
+func (u *ugen) scheme() {
+	s := u.s
+	switch {
+	case s.Chance(badRate):
+		u.b.WriteString(gen.Pick(s, "1http:", "ht tp:", "h_t:", "-http:", ":", "ht%74p:", "http;:", "\u0127ttp:"))
+	case s.Chance(trickRate):
+		u.b.WriteString(gen.Pick(s, "HTTP:", "HtTpS:", "a+b.c-d:", "javascript:", "data:", "file:", "view-source:http:", "blob:http:", "x:"))
+	default:
+		u.b.WriteString(gen.Pick(s, "http:", "https:", "http:", "https:", "ws:", "wss:", "ftp:", "file:", "git+ssh:", "s3:"))
+	}
+}
+
+// slashes writes what comes between a scheme and an authority.
+func (u *ugen) slashes() string {
+	s := u.s
+	if s.Chance(trickRate) {
+		return gen.Pick(s, `\\`, `/\`, `\/`, "///", "/", "", "////", `/%5C`, "%2F%2F", "/\t/")
+	}
+	return "//"
+}
+
+// authority writes userinfo, a host and a port.
+func (u *ugen) authority() {
+	s := u.s
+	if s.Chance(0.25) {
+		u.userinfo()
+	}
+	u.host()
+	if s.Chance(0.3) {
+		u.port()
+	}
+}
+
+func (u *ugen) userinfo() {
+	s := u.s
+	switch {
+	case s.Chance(badRate * 3):
+		u.b.WriteString(gen.Pick(s, "us er@", "user%@", "user%zz@", "u\x00@", "user:pa ss@", "[::1]@", "user/x@", "user?x@", "user#x@"))
+	case s.Chance(trickRate * 4):
+		// An '@' the parser may take for the end of the userinfo, or
+		// a host the reader may take for the real one.
+		u.b.WriteString(gen.Pick(s,
+			"user@evil.com@", "user%40evil.com@", "evil.com%2F@", "a@b@c@", "@", ":@", "::@",
+			"user:pass:word@", "user:%40@", "user:@", ":pass@", "example.com@", "example.com:80@",
+			"%61dmin@", "user%3Apass@", "user;x=y@", "!$&'()*+,;=@", "~user.name_-@",
+			"user%00@", "\u00e9l\u00e8ve@", "%C3%A9@", "%FF@"))
+	default:
+		u.write("%s@", gen.Pick(s, "user", "user:pass", "alice", "bob:s3cr3t", "anonymous:", "u%20v"))
+	}
+}
+
+func (u *ugen) host() {
+	s := u.s
+	switch {
+	case s.Chance(badRate * 2):
+		u.b.WriteString(gen.Pick(s,
+			"exa mple.com", "ex%zzample.com", "exa%", "[::1", "::1]", "[::1]x", "[::1]]", "[[::1]]",
+			"[fe80::1%en0]", "[fe80::1%]", "[fe80::1%25]", "[1.2.3.4]", "[v1.x]", "[::g]", "[]", "ex<ample>.com", "a\"b.com",
+			"ex|ample.com", "ex{ample}.com", "ex^ample.com", "ex`ample.com"))
+	case s.Chance(trickRate * 3):
+		u.b.WriteString(gen.Pick(s,
+			// IPv4 addresses written as no resolver writes them
+			// today, but some still read them.
+			"0x7f.0.0.1", "0177.0.0.1", "2130706433", "127.1", "127.0.1", "0x7f000001",
+			"017700000001", "127.0.0.1.", "127.000.000.001", "1.2.3.4.5", "256.0.0.1", "4294967296",
+			// IPv6 literals with zone identifiers, mapped addresses
+			// and what follows the bracket.
+			"[fe80::1%25en0]", "[fe80::1%25eth0%2F1]", "[fe80::1%25%65n0]", "[fe80::1%2525]",
+			"[::ffff:127.0.0.1]", "[::ffff:7f00:1]", "[0:0:0:0:0:0:0:1]", "[::]", "[FE80::A]",
+			"[2001:db8::1]", "[::1%25lo]", "[fe80::1%25%ff]", "[v7.future]",
+			// Names with encoded, unusual and non-ASCII characters.
+			"ex%61mple.com", "%65xample.com", "example.com%2Fevil.com", "example.com%00.evil.com",
+			"exa_mple.com", "EXAMPLE.com", "example.com.", "xn--nxasmq6b.com", "\u00e9xample.com",
+			"%C3%A9xample.com", "ex%C3ample.com", "a.b.c.d.e.f.g", "localhost", "-a-.com",
+			"evil.com\\@example.com", "evil.com;example.com", "*.example.com", "example.com*"))
+	case s.Chance(0.02):
+		// An empty host.
+	default:
+		u.b.WriteString(gen.Pick(s, "example.com", "www.example.org", "127.0.0.1", "[::1]", "[2001:db8::7]", "api.example.net", "10.0.0.1", "a.b"))
+	}
+}
+
+func (u *ugen) port() {
+	s := u.s
+	switch {
+	case s.Chance(badRate * 3):
+		u.write(":%s", gen.Pick(s, "8a", "-1", "+80", " 80", "80:80", "0x50", "%38%30", "８０"))
+	case s.Chance(trickRate * 3):
+		u.write(":%s", gen.Pick(s, "", "0", "00080", "65535", "65536", "99999", "4294967376", "18446744073709551696", "99999999999999999999999"))
+	default:
+		u.write(":%d", gen.Pick(s, 80, 443, 8080, 1, 3000))
+	}
+}
+
+// path writes the segments of a path after its first slash, or after
+// an authority, whose path, if it has one, starts with a slash.
+func (u *ugen) path(rooted bool) {
+	s := u.s
+	n := s.Intn(5)
+	if rooted && n > 0 {
+		u.b.WriteString("/")
+	}
+	for i := range n {
+		if i > 0 {
+			if s.Chance(trickRate / 2) {
+				u.b.WriteString(gen.Pick(s, "//", `\`, "/./", "%2F", "%2f", "%5C"))
+			} else {
+				u.b.WriteString("/")
+			}
+		}
+		u.segment()
+	}
+	if n > 0 && s.Chance(0.2) {
+		u.b.WriteString("/")
+	}
+}
+
+// segment writes one path segment.
+func (u *ugen) segment() {
+	s := u.s
+	switch {
+	case s.Chance(badRate):
+		u.b.WriteString(gen.Pick(s, "%", "%2", "%G0", "%0g", "a%", "a b", "a\x00b", "a\x7fb", "%%41"))
+	case s.Chance(trickRate * 2):
+		u.b.WriteString(gen.Pick(s,
+			".", "..", "%2e", "%2e%2e", ".%2E", "%2E.", "...", ".. ", "..;", "..;x=y", "a;b=c", ";",
+			"%00", "%25", "%2525", "%252e%252e", "%C0%AE%C0%AE", "%e2%82", "%e2%82%ac", "%FF%FE",
+			"\u00e9", "%C3%A9", "\u202e", "a+b", "%20", "a%2Fb", "a%3Fb", "a%23b", ":", "a:b",
+			"@", "[", "]", "{}", "|", "^", "`", "~", "!$&'()*,=", "a%3Bb"))
+	default:
+		u.b.WriteString(gen.Pick(s, "index.html", "api", "v1", "users", "42", "a", "b", "search", "img.png", "foo-bar_baz", "~user"))
+	}
+}
+
+// relative writes a relative reference, which has no scheme and does
+// not start with two slashes.
+func (u *ugen) relative() {
+	s := u.s
+	switch {
+	case s.Chance(0.3):
+		u.b.WriteString(gen.Pick(s, "./", "../", "../../", ".", "..", "", "./a:b", "a:b/../c", "./%2e%2e/"))
+	case s.Chance(0.2):
+		// What a relative reference with a colon in its first segment
+		// looks like: a scheme, to some parsers.
+		u.b.WriteString(gen.Pick(s, "example.com:80", "localhost:8080", "a:", "1:2", "host:port/path", "[::1]:80", "user@example.com"))
+		return
+	}
+	u.path(false)
+}
+
+// opaque writes a URL with a scheme and no authority.
+func (u *ugen) opaque() {
+	s := u.s
+	u.b.WriteString(gen.Pick(s,
+		"mailto:user@example.com", "mailto:a@b.c,d@e.f", "urn:isbn:0451450523", "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66",
+		"tel:+1-816-555-1212", "news:comp.lang.go", "javascript:alert(1)", "javascript://%0aalert(1)",
+		"data:text/html,<script>alert(1)</script>", "data:,", "http:example.com", "http:/example.com",
+		"file:/etc/passwd", "file:c:/windows", "file:///c:/windows", "x:%zz", "a:b:c", "sip:alice@atlanta.com;transport=tcp"))
+}
+
+// odd writes strings that are URLs only at a stretch.
+func (u *ugen) odd() {
+	s := u.s
+	u.b.WriteString(gen.Pick(s,
+		"*", "", "?", "#", "//", "///", "/", `\`, `\\example.com`, `/\example.com`, `\/example.com`,
+		"http://", "http:", "http:?", "http:#", "http://?", "http://#", "//@", "//:", "//[", "http://[::1]:", "http://:80",
+		"http://@/", "https://%2F%2Fevil.com", "%", "%%", "%41", "%2F%2Fexample.com", ":", "::", ":/", ":80",
+		"..//example.com", "/..//example.com", "/%2e%2e/%2e%2e/", "//example.com@evil.com", "///example.com"))
+}
+
+// query writes a query.
+func (u *ugen) query() {
+	s := u.s
+	u.b.WriteString("?")
+	for i := range s.Intn(4) {
+		if i > 0 {
+			if s.Chance(trickRate) {
+				u.b.WriteString(gen.Pick(s, ";", "&&", "&;", "%26"))
+			} else {
+				u.b.WriteString("&")
+			}
+		}
+		switch {
+		case s.Chance(badRate):
+			u.b.WriteString(gen.Pick(s, "a=%", "a=%zz", "%=b", "a=b c", "a=\x00", "a%3"))
+		case s.Chance(trickRate * 2):
+			u.b.WriteString(gen.Pick(s,
+				"a", "=", "a=", "=b", "a=b=c", "a=1;b=2", "a+b=c+d", "a%20b=c%2Bd", "a[]=1", "a[b]=c",
+				"next=//evil.com", "redirect=http://evil.com", "%3F=%3D", "a=%26b%3Dc", "q=%E2%82%AC", "q=%FF",
+				"a=?b", "a=/b", "a=b@c:d"))
+		default:
+			u.write("%s=%s", gen.Pick(s, "q", "page", "id", "lang", "sort"), gen.Pick(s, "go", "1", "en", "asc", "", "a+b"))
+		}
+	}
+	if s.Chance(0.05) {
+		u.b.WriteString(gen.Pick(s, "?", "??", "?a=b"))
+	}
+}
+
+// fragment writes a fragment.
+func (u *ugen) fragment() {
+	s := u.s
+	u.write("#%s", gen.Pick(s, "", "top", "section-2", "a#b", "%23", "%zz", "%", "a b", "/path?q=1", "!/hashbang", "\u00e9", "%C3%A9", "%FF"))
+}
@@ -0,0 +1,706 @@
+package wasmsrc
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// Opcodes, of the control instructions and of those that take an
+// immediate other than a memory argument; the numeric ones are in the
+// tables below.
+const (
+	opUnreachable  = 0x00
+	opNop          = 0x01
+	opBlock        = 0x02
+	opLoop         = 0x03
+	opIf           = 0x04
+	opElse         = 0x05
+	opEnd          = 0x0b
+	opBr           = 0x0c
+	opBrIf         = 0x0d
+	opBrTable      = 0x0e
+	opReturn       = 0x0f
+	opCall         = 0x10
+	opCallIndirect = 0x11
+	opDrop         = 0x1a
+	opSelect       = 0x1b
+	opLocalGet     = 0x20
+	opLocalSet     = 0x21
+	opLocalTee     = 0x22
+	opGlobalGet    = 0x23
+	opGlobalSet    = 0x24
+	opMemorySize   = 0x3f
+	opMemoryGrow   = 0x40
+	opI32Const     = 0x41
+	opI64Const     = 0x42
+	opF32Const     = 0x43
+	opF64Const     = 0x44
+	opI32Sub       = 0x6b
+	opPrefix       = 0xfc // saturating truncation and bulk memory
+	blockEmpty     = 0x40
+)
+
+// An op is a numeric instruction: its opcode, after 0xfc if it has the
+// prefix, and the types of its operands.
+type op struct {
+	code     byte
+	prefixed bool
+	args     []byte
+}
+
+// ops are the numeric instructions by the type of their result.
+var ops = map[byte][]op{
+	i32: concat(
+		run(0x6a, 0x78, i32, i32), // add to rotr
+		run(0x67, 0x69, i32),      // clz, ctz and popcnt
+		run(0x45, 0x45, i32),      // eqz
+		run(0x46, 0x4f, i32, i32), // comparisons
+		run(0x50, 0x50, i64),
+		run(0x51, 0x5a, i64, i64),
+		run(0x5b, 0x60, f32, f32),
+		run(0x61, 0x66, f64, f64),
+		run(0xa7, 0xa7, i64), // wrap
+		run(0xa8, 0xa9, f32), // truncations
+		run(0xaa, 0xab, f64),
+		run(0xbc, 0xbc, f32), // reinterpret
+		run(0xc0, 0xc1, i32), // sign extensions
+		prefixed(0, 1, f32),  // saturating truncations
+		prefixed(2, 3, f64),
+	),
+	i64: concat(
+		run(0x7c, 0x8a, i64, i64), // add to rotr
+		run(0x79, 0x7b, i64),      // clz, ctz and popcnt
+		run(0xac, 0xad, i32),      // extensions
+		run(0xae, 0xaf, f32),      // truncations
+		run(0xb0, 0xb1, f64),
+		run(0xbd, 0xbd, f64), // reinterpret
+		run(0xc2, 0xc4, i64), // sign extensions
+		prefixed(4, 5, f32),  // saturating truncations
+		prefixed(6, 7, f64),
+	),
+	f32: concat(
+		run(0x92, 0x98, f32, f32), // add to copysign
+		run(0x8b, 0x91, f32),      // abs to sqrt
+		run(0xb2, 0xb3, i32),      // conversions
+		run(0xb4, 0xb5, i64),
+		run(0xb6, 0xb6, f64), // demote
+		run(0xbe, 0xbe, i32), // reinterpret
+	),
+	f64: concat(
+		run(0xa0, 0xa6, f64, f64), // add to copysign
+		run(0x99, 0x9f, f64),      // abs to sqrt
+		run(0xb7, 0xb8, i32),      // conversions
+		run(0xb9, 0xba, i64),
+		run(0xbb, 0xbb, f32), // promote
+		run(0xbf, 0xbf, i64), // reinterpret
+	),
+}
+
+func concat(runs ...[]op) []op {
+	var r []op
+	for _, o := range runs {
+		r = append(r, o...)
+	}
+	return r
+}
+
+// prefixed returns the instructions from lo to hi after the 0xfc prefix,
+// which all take operands of types args.
+func prefixed(lo, hi byte, args ...byte) []op {
+	r := run(lo, hi, args...)
+	for i := range r {
+		r[i].prefixed = true
+	}
+	return r
+}
+
+// run returns the instructions from opcode lo to hi, which all take
+// operands of types args.
+func run(lo, hi byte, args ...byte) []op {
+	var r []op
+	for c := lo; c <= hi; c++ {
+		r = append(r, op{code: c, args: args})
+	}
+	return r
+}
+
+// A memOp is a load or store: its opcode, the type it loads or stores,
+// and the log2 of how many bytes it reads or writes, its natural
+// alignment.
+type memOp struct {
+	code  byte
+	typ   byte
+	align uint32
+}
+
+var (
+	loads = []memOp{
+		{0x28, i32, 2}, {0x29, i64, 3}, {0x2a, f32, 2}, {0x2b, f64, 3},
+		{0x2c, i32, 0}, {0x2d, i32, 0}, {0x2e, i32, 1}, {0x2f, i32, 1},
+		{0x30, i64, 0}, {0x31, i64, 0}, {0x32, i64, 1}, {0x33, i64, 1}, {0x34, i64, 2}, {0x35, i64, 2},
+	}
+	stores = []memOp{
+		{0x36, i32, 2}, {0x37, i64, 3}, {0x38, f32, 2}, {0x39, f64, 3},
+		{0x3a, i32, 0}, {0x3b, i32, 1}, {0x3c, i64, 0}, {0x3d, i64, 1}, {0x3e, i64, 2},
+	}
+	// badOps are opcodes no instruction has, or has only in proposals
+	// a runtime may not take: exception handling, GC and threads.
+	badOps = []byte{0x06, 0x07, 0x08, 0x09, 0x12, 0x13, 0x14, 0x18, 0x19, 0x1d, 0x27, 0xc5, 0xcf, 0xd3, 0xd6, 0xfb, 0xfe, 0xff}
+)
+
+// A label is a block, loop or if that code may branch out of: whether
+// it is a loop, which a branch starts again, and the types of the values
+// a branch to it takes.
+type label struct {
+	loop    bool
+	results []byte
+}
+
+// An fgen writes the body of one function.
+type fgen struct {
+	*mgen
+	b       []byte
+	fn      int // the function's index
+	locals  []byte
+	counter int  // a local loops count down in
+	looping bool // whether code is in a loop, which may not have another
+	labels  []label
+	// budget is how many more instructions the body may have, roughly.
+	budget int
+}
+
+// codeSection returns the code section: a body for each function the
+// module defines, now and then one more or one fewer.
+func (m *mgen) codeSection(defined []int) []byte {
+	if len(defined) == 0 {
+		return nil
+	}
+	n := len(defined)
+	if m.broken() {
+		n = gen.Pick(m.s, n+1, n-1)
+	}
+	b := m.u32(nil, uint32(n))
+	for i := range n {
+		t := m.types[defined[min(i, len(defined)-1)]]
+		body := m.body(m.imported+i, t)
+		b = m.u32(b, uint32(len(body)))
+		b = append(b, body...)
+	}
+	return b
+}
+
+// body returns the body of function fn, of type t: its locals, a few
+// statements and the values it returns.
+func (m *mgen) body(fn int, t funcType) []byte {
+	f := &fgen{mgen: m, fn: fn, budget: m.s.Range(10, 200)}
+	f.locals = append(f.locals, t.params...)
+
+	// Locals in runs of one type, then the counter.
+	var runs [][2]uint32
+	for range m.s.Intn(4) {
+		typ := gen.Pick(m.s, numeric...)
+		n := uint32(m.s.Range(1, 4))
+		runs = append(runs, [2]uint32{n, uint32(typ)})
+		for range n {
+			f.locals = append(f.locals, typ)
+		}
+	}
+	f.counter = len(f.locals)
+	f.locals = append(f.locals, i32)
+	runs = append(runs, [2]uint32{1, i32})
+	if m.broken() {
+		// Locals by the billion, which a runtime that makes room for
+		// them all at once runs out of memory for, in one run or
+		// adding up past what 32 bits hold.
+		runs = append(runs, [2]uint32{gen.Pick[uint32](m.s, 1<<30, math.MaxUint32, 50001), i64})
+		if m.s.Chance(0.5) {
+			runs = append(runs, [2]uint32{math.MaxUint32, i32})
+		}
+	}
+	f.b = m.count(f.b, len(runs))
+	for _, r := range runs {
+		f.b = m.u32(f.b, r[0])
+		f.b = append(f.b, byte(r[1]))
+	}
+
+	f.labels = []label{{results: t.results}}
+	if m.s.Chance(0.01) {
+		f.nest()
+	}
+	for range m.s.Intn(8) {
+		f.stmt(3)
+	}
+	for _, r := range t.results {
+		f.expr(r, 4)
+	}
+	switch {
+	case m.broken():
+		// A body without its end, with one too many, or with an else
+		// that follows no if.
+		switch m.s.Intn(3) {
+		case 1:
+			f.b = append(f.b, opEnd, opEnd)
+		case 2:
+			f.b = append(f.b, opElse, opEnd)
+		}
+	default:
+		f.b = append(f.b, opEnd)
+	}
+	return f.b
+}
+
+// nest writes blocks nested thousands deep, of no type, with nothing in
+// them but now and then a branch out of a few of them.
+func (f *fgen) nest() {
+	n := f.s.Range(500, 20000)
+	for range n {
+		f.b = append(f.b, gen.Pick[byte](f.s, opBlock, opBlock, opLoop), blockEmpty)
+	}
+	if f.s.Chance(0.5) {
+		// A branch, never taken, from the innermost to any of them.
+		f.b = append(f.b, opI32Const, 0, opBrIf)
+		f.b = f.index(f.b, f.s.Intn(n), n+1)
+	}
+	for range n {
+		f.b = append(f.b, opEnd)
+	}
+}
+
+// emit appends a numeric instruction.
+func (f *fgen) emit(o op) {
+	if o.prefixed {
+		f.b = append(f.b, opPrefix)
+		f.b = f.u32(f.b, uint32(o.code))
+		return
+	}
+	f.b = append(f.b, o.code)
+}
+
+// leaf appends an instruction that pushes a value of type t with nothing
+// under it: a constant, a local or a global.
+func (f *fgen) leaf(t byte) {
+	switch f.s.Intn(4) {
+	case 0:
+		if i := f.localOf(t); i >= 0 {
+			f.b = append(f.b, opLocalGet)
+			f.b = f.index(f.b, i, len(f.locals))
+			return
+		}
+	case 1:
+		for i, g := range f.globals {
+			if g.typ == t {
+				f.b = append(f.b, opGlobalGet)
+				f.b = f.index(f.b, i, len(f.globals))
+				return
+			}
+		}
+	}
+	switch t {
+	case i32:
+		f.b = f.s32(append(f.b, opI32Const), int32(f.number()))
+	case i64:
+		f.b = f.s64(append(f.b, opI64Const), f.number())
+	case f32:
+		f.b = binary.LittleEndian.AppendUint32(append(f.b, opF32Const), math.Float32bits(float32(f.float())))
+	default:
+		f.b = binary.LittleEndian.AppendUint64(append(f.b, opF64Const), math.Float64bits(f.float()))
+	}
+}
+
+// localOf returns a local of type t, other than the counter, or -1.
+func (f *fgen) localOf(t byte) int {
+	var is []int
+	for i, l := range f.locals {
+		if l == t && i != f.counter {
+			is = append(is, i)
+		}
+	}
+	if len(is) == 0 {
+		return -1
+	}
+	return gen.Pick(f.s, is...)
+}
+
+// address appends an address in memory: small, mostly, so that loads
+// and stores find the memory there.
+func (f *fgen) address() {
+	if f.s.Chance(0.9) {
+		f.b = f.s32(append(f.b, opI32Const), int32(f.s.Intn(1024)))
+		return
+	}
+	f.expr(i32, 1)
+}
+
+// memArg appends the alignment and offset of a load or store that reads
+// or writes 1<<align bytes.
+func (f *fgen) memArg(align uint32) {
+	a := uint32(f.s.Intn(int(align) + 1))
+	if f.broken() {
+		a = gen.Pick(f.s, align+1, 32, 64, math.MaxUint32)
+	}
+	f.b = f.u32(f.b, a)
+	off := uint32(f.s.Intn(64))
+	if f.s.Chance(0.05) {
+		off = gen.Pick[uint32](f.s, 65536, 1<<31, math.MaxUint32)
+	}
+	f.b = f.u32(f.b, off)
+}
+
+// blockType appends the type of a block giving results: empty, one
+// value type, or the index of a type without parameters that has them.
+func (f *fgen) blockType(results []byte) {
+	switch len(results) {
+	case 0:
+		f.b = append(f.b, blockEmpty)
+		return
+	case 1:
+		if !f.broken() {
+			f.b = append(f.b, results[0])
+			return
+		}
+	}
+	for i, t := range f.types {
+		if len(t.params) == 0 && string(t.results) == string(results) {
+			f.b = f.s32(f.b, int32(i))
+			return
+		}
+	}
+	// No such type: one out of range, which is negative as a 33-bit
+	// signed number, or past the end.
+	f.b = f.s32(f.b, gen.Pick(f.s, int32(len(f.types)), math.MaxInt32, 1<<20))
+}
+
+// expr appends instructions that push one value of type t, nesting up
+// to depth deep.
+func (f *fgen) expr(t byte, depth int) {
+	f.budget--
+	if depth <= 0 || f.budget <= 0 || f.s.Chance(0.3) {
+		f.leaf(t)
+		return
+	}
+	if f.broken() {
+		f.b = append(f.b, gen.Pick(f.s, badOps...))
+	}
+	switch f.s.Intn(12) {
+	case 0, 1, 2, 3:
+		o := gen.Pick(f.s, ops[t]...)
+		for _, a := range o.args {
+			f.expr(a, depth-1)
+		}
+		f.emit(o)
+	case 4:
+		if f.memories > 0 {
+			var ls []memOp
+			for _, l := range loads {
+				if l.typ == t {
+					ls = append(ls, l)
+				}
+			}
+			l := gen.Pick(f.s, ls...)
+			f.address()
+			f.b = append(f.b, l.code)
+			f.memArg(l.align)
+			return
+		}
+		f.leaf(t)
+	case 5:
+		if f.call(t, depth) {
+			return
+		}
+		f.leaf(t)
+	case 6:
+		f.b = append(f.b, opBlock)
+		f.blockType([]byte{t})
+		f.labels = append(f.labels, label{results: []byte{t}})
+		if f.s.Chance(0.5) {
+			f.stmt(depth - 1)
+		}
+		f.expr(t, depth-1)
+		f.labels = f.labels[:len(f.labels)-1]
+		f.b = append(f.b, opEnd)
+	case 7:
+		f.expr(i32, depth-1)
+		f.b = append(f.b, opIf)
+		f.blockType([]byte{t})
+		f.labels = append(f.labels, label{results: []byte{t}})
+		f.expr(t, depth-1)
+		f.b = append(f.b, opElse)
+		f.expr(t, depth-1)
+		f.labels = f.labels[:len(f.labels)-1]
+		f.b = append(f.b, opEnd)
+	case 8:
+		f.expr(t, depth-1)
+		f.expr(t, depth-1)
+		f.expr(i32, depth-1)
+		if f.s.Chance(0.2) {
+			// The typed form, with a vector of one type.
+			f.b = append(f.b, 0x1c, 1, t)
+		} else {
+			f.b = append(f.b, opSelect)
+		}
+	case 9:
+		if i := f.localOf(t); i >= 0 {
+			f.expr(t, depth-1)
+			f.b = append(f.b, opLocalTee)
+			f.b = f.index(f.b, i, len(f.locals))
+			return
+		}
+		f.leaf(t)
+	case 10:
+		if t == i32 && f.memories > 0 {
+			if f.s.Chance(0.5) {
+				f.b = append(f.b, opMemorySize, 0)
+			} else {
+				f.expr(i32, depth-1)
+				f.b = append(f.b, opMemoryGrow, 0)
+			}
+			return
+		}
+		f.leaf(t)
+	default:
+		f.leaf(t)
+	}
+}
+
+// call appends a call that leaves one value of type t, to a function, or
+// through the table, whose first result is of type t, dropping any
+// other results. It reports whether it found one to call.
+func (f *fgen) call(t byte, depth int) bool {
+	// Only functions before this one, so that no call recurses, or now
+	// and then any of them.
+	n := f.fn
+	if f.s.Chance(0.02) {
+		n = len(f.funcs)
+	}
+	var callees []int
+	for i := range min(n, len(f.funcs)) {
+		if rs := f.types[f.funcs[i]].results; len(rs) > 0 && rs[0] == t {
+			callees = append(callees, i)
+		}
+	}
+	if len(callees) == 0 {
+		return false
+	}
+	callee := gen.Pick(f.s, callees...)
+	ft := f.types[f.funcs[callee]]
+	for _, p := range ft.params {
+		f.expr(p, depth-1)
+	}
+	if f.tables > 0 && f.s.Chance(0.3) {
+		// Whatever function the table holds there, if any, which must
+		// have the type given.
+		f.b = f.s32(append(f.b, opI32Const), int32(f.s.Intn(20)))
+		f.b = append(f.b, opCallIndirect)
+		f.b = f.index(f.b, f.funcs[callee], len(f.types))
+		f.b = append(f.b, 0)
+	} else {
+		f.b = append(f.b, opCall)
+		f.b = f.index(f.b, callee, len(f.funcs))
+	}
+	for range len(ft.results) - 1 {
+		f.b = append(f.b, opDrop)
+	}
+	return true
+}
+
+// stmt appends instructions that leave the stack as they found it,
+// nesting up to depth deep.
+func (f *fgen) stmt(depth int) {
+	f.budget--
+	if f.budget <= 0 {
+		f.b = append(f.b, opNop)
+		return
+	}
+	switch f.s.Intn(14) {
+	case 0, 1:
+		f.expr(gen.Pick(f.s, numeric...), depth)
+		f.b = append(f.b, opDrop)
+	case 2:
+		t := gen.Pick(f.s, numeric...)
+		if i := f.localOf(t); i >= 0 {
+			f.expr(t, depth)
+			f.b = append(f.b, opLocalSet)
+			f.b = f.index(f.b, i, len(f.locals))
+		}
+	case 3:
+		for i, g := range f.globals {
+			if g.mutable {
+				f.expr(g.typ, depth)
+				f.b = append(f.b, opGlobalSet)
+				f.b = f.index(f.b, i, len(f.globals))
+				break
+			}
+		}
+	case 4, 5:
+		if f.memories > 0 {
+			st := gen.Pick(f.s, stores...)
+			f.address()
+			f.expr(st.typ, depth)
+			f.b = append(f.b, st.code)
+			f.memArg(st.align)
+		}
+	case 6:
+		if depth > 0 && !f.looping {
+			f.loop(depth)
+		}
+	case 7:
+		if depth > 0 {
+			f.expr(i32, depth-1)
+			f.b = append(f.b, opIf, blockEmpty)
+			f.labels = append(f.labels, label{})
+			f.stmt(depth - 1)
+			if f.s.Chance(0.5) {
+				f.b = append(f.b, opElse)
+				f.stmt(depth - 1)
+			}
+			f.labels = f.labels[:len(f.labels)-1]
+			f.b = append(f.b, opEnd)
+		}
+	case 8:
+		if depth > 0 {
+			f.brTable(depth)
+		}
+	case 9:
+		f.branch(depth)
+	case 10:
+		if f.memories > 0 {
+			f.bulk()
+		}
+	case 11:
+		if depth > 0 {
+			f.b = append(f.b, opBlock, blockEmpty)
+			f.labels = append(f.labels, label{})
+			for range f.s.Range(1, 3) {
+				f.stmt(depth - 1)
+			}
+			f.labels = f.labels[:len(f.labels)-1]
+			f.b = append(f.b, opEnd)
+		}
+	case 12:
+		if f.s.Chance(0.1) {
+			// A trap, or a return, after which the rest of the block
+			// is unreachable, and checked as code that may have any
+			// values on the stack.
+			if f.s.Chance(0.5) {
+				f.b = append(f.b, opUnreachable)
+			} else {
+				for _, r := range f.labels[0].results {
+					f.expr(r, depth)
+				}
+				f.b = append(f.b, opReturn)
+			}
+		}
+	default:
+		f.b = append(f.b, opNop)
+	}
+}
+
+// loop appends a loop that runs its body a few times, counting down in
+// the counter local.
+func (f *fgen) loop(depth int) {
+	n := f.s.Range(1, 16)
+	if f.s.Chance(0.01) {
+		n = f.s.Range(1000, 1<<20)
+	}
+	f.b = f.s32(append(f.b, opI32Const), int32(n))
+	f.b = append(f.b, opLocalSet)
+	f.b = f.u32(f.b, uint32(f.counter))
+	f.b = append(f.b, opLoop, blockEmpty)
+	f.labels = append(f.labels, label{loop: true})
+	// A loop in the body would count down in the same local, and never
+	// end, so it has none.
+	f.looping = true
+	for range f.s.Range(1, 3) {
+		f.stmt(depth - 1)
+	}
+	f.looping = false
+	f.b = append(f.b, opLocalGet)
+	f.b = f.u32(f.b, uint32(f.counter))
+	f.b = append(f.b, opI32Const, 1, opI32Sub, opLocalTee)
+	f.b = f.u32(f.b, uint32(f.counter))
+	f.b = append(f.b, opBrIf, 0)
+	f.labels = f.labels[:len(f.labels)-1]
+	f.b = append(f.b, opEnd)
+}
+
+// brTable appends blocks nested a few deep with a br_table in the
+// innermost that branches out of one of them, or past all of them.
+func (f *fgen) brTable(depth int) {
+	n := f.s.Range(1, 4)
+	for range n {
+		f.b = append(f.b, opBlock, blockEmpty)
+		f.labels = append(f.labels, label{})
+	}
+	f.expr(i32, depth-1)
+	f.b = append(f.b, opBrTable)
+	k := f.s.Range(0, 6)
+	if f.s.Chance(0.01) {
+		k = f.s.Range(1000, 100000)
+	}
+	f.b = f.count(f.b, k)
+	for range k {
+		f.b = f.index(f.b, f.s.Intn(n), len(f.labels))
+	}
+	f.b = f.index(f.b, f.s.Intn(n), len(f.labels))
+	for range n {
+		f.labels = f.labels[:len(f.labels)-1]
+		f.b = append(f.b, opEnd)
+		if f.s.Chance(0.5) {
+			f.stmt(depth - 1)
+		}
+	}
+}
+
+// branch appends a br_if out of an enclosing block that takes no values,
+// if there is one that is not a loop, which the branch would start
+// again.
+func (f *fgen) branch(depth int) {
+	var targets []int
+	for d := range len(f.labels) - 1 { // not the function's own
+		if l := f.labels[len(f.labels)-1-d]; !l.loop && len(l.results) == 0 {
+			targets = append(targets, d)
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+	f.expr(i32, depth)
+	f.b = append(f.b, opBrIf)
+	f.b = f.index(f.b, gen.Pick(f.s, targets...), len(f.labels))
+}
+
+// bulk appends a bulk memory instruction: memory.fill or memory.copy over
+// a few bytes, or memory.init or data.drop of a passive data segment.
+func (f *fgen) bulk() {
+	small := func() { f.b = f.s32(append(f.b, opI32Const), int32(f.s.Intn(256))) }
+	switch f.s.Intn(3) {
+	case 0:
+		small()
+		f.expr(i32, 1)
+		small()
+		f.b = append(f.b, opPrefix, 11, 0)
+	case 1:
+		small()
+		small()
+		small()
+		f.b = append(f.b, opPrefix, 10, 0, 0)
+	default:
+		if f.passive == 0 {
+			return
+		}
+		seg := f.s.Intn(f.passive)
+		if f.s.Chance(0.3) {
+			f.b = append(f.b, opPrefix, 9)
+			f.b = f.u32(f.b, uint32(seg))
+			return
+		}
+		small()
+		f.b = f.s32(append(f.b, opI32Const), 0)
+		f.b = f.s32(append(f.b, opI32Const), int32(f.s.Intn(4)))
+		f.b = append(f.b, opPrefix, 8)
+		f.b = f.u32(f.b, uint32(seg))
+		f.b = append(f.b, 0)
+	}
+}
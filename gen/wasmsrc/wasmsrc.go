@@ -0,0 +1,677 @@
+// Package wasmsrc generates WebAssembly seeds. It registers the
+// "wasm/..." generators with package gen.
+//
+// "wasm/module" writes a binary module, input.wasm: types of several
+// parameters and results, functions, tables, memories and globals
+// imported from the host or defined, exports, a start function, active,
+// passive and declarative element segments, data segments and a name
+// section. Function bodies are well-typed code built expression by
+// expression: arithmetic, conversions, loads and stores, calls direct
+// and through a table, blocks, loops that count down, ifs, br_tables and
+// selects, and now and then blocks nested thousands deep. Numbers are
+// now and then written in LEB128 longer than they need, padded with
+// continuation bytes as a linker leaves them. A few break the format:
+// sections out of order or twice, sections whose sizes lie, LEB128
+// numbers longer than their type allows or with bits set past it, vector
+// counts larger than what follows, function and code counts that
+// disagree, type, function, local and label indices far out of range,
+// locals by the billion, value types and opcodes that do not exist,
+// limits whose minimum is past their maximum, bodies without their end,
+// and modules cut short.
+package wasmsrc
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "wasm/module",
+		Doc:  "WebAssembly binary modules: typed function bodies with nested blocks, loops, br_tables and indirect calls, imports, tables, memories, globals, element and data segments, and sections out of order, overlong LEB128, huge indices and counts, and bad limits and opcodes",
+		Func: module,
+	})
+}
+
+// badRate is the chance that one of the many numbers, indices and
+// sections of a module is wrong, so that one module in ten or so is
+// broken.
+const badRate = 0.001
+
+// Section IDs, in the order a module must have them but for custom
+// sections, which go anywhere, and the data count section, which goes
+// between the element and code sections.
+const (
+	secCustom    = 0
+	secType      = 1
+	secImport    = 2
+	secFunction  = 3
+	secTable     = 4
+	secMemory    = 5
+	secGlobal    = 6
+	secExport    = 7
+	secStart     = 8
+	secElement   = 9
+	secCode      = 10
+	secData      = 11
+	secDataCount = 12
+)
+
+// Value types.
+const (
+	i32       = 0x7f
+	i64       = 0x7e
+	f32       = 0x7d
+	f64       = 0x7c
+	v128      = 0x7b
+	funcref   = 0x70
+	externref = 0x6f
+)
+
+// numeric are the value types code is built from.
+var numeric = []byte{i32, i32, i32, i64, i64, f32, f64}
+
+// A funcType is a function type: its parameters and results.
+type funcType struct {
+	params, results []byte
+}
+
+// A global is a global's type and whether it is mutable.
+type global struct {
+	typ     byte
+	mutable bool
+}
+
+// A mgen writes the sections of one module.
+type mgen struct {
+	s *gen.State
+
+	types []funcType
+	// funcs are the types of the functions, imported ones first.
+	funcs    []int
+	imported int // functions imported
+	globals  []global
+	// globalImports are the globals imported, which alone a constant
+	// expression may read.
+	globalImports int
+	tables        int
+	memories      int
+	passive       int // passive data segments, which memory.init copies from
+}
+
+// module writes one module.
+func module(s *gen.State) []gen.File {
+	m := &mgen{s: s}
+	for range s.Range(1, 8) {
+		m.types = append(m.types, m.funcType())
+	}
+	m.types = append(m.types, funcType{}) // () -> (), for the start function
+
+	type section struct {
+		id   byte
+		body []byte
+	}
+	var secs []section
+	add := func(id byte, body []byte) {
+		if body != nil {
+			secs = append(secs, section{id, body})
+		}
+	}
+	add(secType, m.typeSection())
+	add(secImport, m.importSection())
+	functions := s.Range(0, 6)
+	var defined []int
+	for range functions {
+		defined = append(defined, s.Intn(len(m.types)))
+	}
+	if functions > 0 && s.Chance(0.3) {
+		defined = append(defined, len(m.types)-1)
+	}
+	first := len(m.funcs)
+	m.funcs = append(m.funcs, defined...)
+	add(secFunction, m.functionSection(defined))
+	add(secTable, m.tableSection())
+	add(secMemory, m.memorySection())
+	add(secGlobal, m.globalSection())
+	add(secExport, m.exportSection(first))
+	if start := len(m.funcs) - 1; len(defined) > 0 && m.funcs[start] == len(m.types)-1 && s.Chance(0.5) {
+		add(secStart, m.index(nil, start, len(m.funcs)))
+	}
+	add(secElement, m.elementSection())
+	data := m.dataSegments()
+	if len(data) > 0 && (m.passive > 0 || s.Chance(0.5)) {
+		add(secDataCount, m.u32(nil, uint32(len(data))))
+	}
+	add(secCode, m.codeSection(defined))
+	if len(data) > 0 {
+		b := m.count(nil, len(data))
+		for _, d := range data {
+			b = append(b, d...)
+		}
+		add(secData, b)
+	}
+	if s.Chance(0.5) {
+		add(secCustom, m.nameSection())
+	}
+
+	if s.Chance(badRate * 20) {
+		// Sections out of order, or one twice.
+		if len(secs) >= 2 {
+			i, j := s.Intn(len(secs)), s.Intn(len(secs))
+			if s.Chance(0.5) {
+				secs[i], secs[j] = secs[j], secs[i]
+			} else {
+				secs = append(secs[:j], append([]section{secs[i]}, secs[j:]...)...)
+			}
+		}
+	}
+	var b []byte
+	if s.Chance(badRate * 5) {
+		b = append(b, gen.Pick(s, "\x00asm\x02\x00\x00\x00", "\x00ASM\x01\x00\x00\x00", "\x00asm\x01\x00\x00", "\x00asm\x0d\x00\x01\x00")...)
+	} else {
+		b = append(b, "\x00asm\x01\x00\x00\x00"...)
+	}
+	for i, sec := range secs {
+		if i > 0 && s.Chance(0.05) {
+			b = m.customSection(b)
+		}
+		b = append(b, sec.id)
+		n := uint32(len(sec.body))
+		if s.Chance(badRate * 10) {
+			// A size that runs past the end of the section or stops short.
+			n = gen.Pick(s, n+1, n-1, 0, n+uint32(s.Range(2, 100)), math.MaxUint32)
+		}
+		b = m.u32(b, n)
+		b = append(b, sec.body...)
+	}
+	if s.Chance(badRate*10) && len(b) > 8 {
+		b = b[:s.Range(8, len(b)-1)]
+	}
+	return []gen.File{{Name: "input.wasm", Data: b}}
+}
+
+func (m *mgen) broken() bool { return m.s.Chance(badRate) }
+
+// u32 appends v as an unsigned LEB128 number, now and then padded to
+// five bytes, which is as long as one may be. Now and then it is
+// broken: six bytes long or more, or five with bits set past the 32nd.
+func (m *mgen) u32(b []byte, v uint32) []byte {
+	switch {
+	case m.broken():
+		if m.s.Chance(0.5) {
+			return padded(b, uint64(v), 5+m.s.Range(1, 5))
+		}
+		b = padded(b, uint64(v), 4)
+		return append(b, byte(v>>28)|byte(m.s.Range(1, 7))<<4)
+	case m.s.Chance(0.05):
+		return padded(b, uint64(v), 5)
+	}
+	return binary.AppendUvarint(b, uint64(v))
+}
+
+// padded appends v as an unsigned LEB128 number n bytes long, padded
+// with continuation bytes.
+func padded(b []byte, v uint64, n int) []byte {
+	for range n - 1 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(b, byte(v)&0x7f)
+}
+
+// s32 appends v as a signed LEB128 number, now and then padded with
+// continuation bytes that extend its sign, and now and then broken.
+func (m *mgen) s32(b []byte, v int32) []byte {
+	return m.signed(b, int64(v), 5)
+}
+
+// s64 appends v as a signed LEB128 number of up to ten bytes.
+func (m *mgen) s64(b []byte, v int64) []byte {
+	return m.signed(b, v, 10)
+}
+
+// signed appends v as a signed LEB128 number at most max bytes long.
+func (m *mgen) signed(b []byte, v int64, max int) []byte {
+	var enc []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 && c&0x40 == 0 || v == -1 && c&0x40 != 0 {
+			enc = append(enc, c)
+			break
+		}
+		enc = append(enc, c|0x80)
+	}
+	pad := 0
+	switch {
+	case m.broken():
+		pad = max + 1 - len(enc) // one byte too long
+	case len(enc) < max && m.s.Chance(0.05):
+		pad = max - len(enc)
+	}
+	if pad > 0 {
+		sign := byte(0)
+		if enc[len(enc)-1]&0x40 != 0 {
+			sign = 0x7f
+		}
+		enc[len(enc)-1] |= 0x80
+		for range pad - 1 {
+			enc = append(enc, sign|0x80)
+		}
+		enc = append(enc, sign)
+	}
+	return append(b, enc...)
+}
+
+// count appends the length of a vector of n items, now and then more
+// than there are.
+func (m *mgen) count(b []byte, n int) []byte {
+	if m.broken() {
+		return m.u32(b, gen.Pick(m.s, uint32(n+1), uint32(n+m.s.Range(2, 1000)), math.MaxUint32))
+	}
+	return m.u32(b, uint32(n))
+}
+
+// index appends index i of a space of n things, now and then one past
+// the end or far out of range.
+func (m *mgen) index(b []byte, i, n int) []byte {
+	if m.broken() {
+		return m.u32(b, gen.Pick(m.s, uint32(n), uint32(n+m.s.Range(1, 100)), 1<<31, math.MaxUint32))
+	}
+	return m.u32(b, uint32(i))
+}
+
+// name appends a name: its length, then its bytes, now and then not
+// UTF-8.
+func (m *mgen) name(b []byte, name string) []byte {
+	if m.broken() {
+		name += gen.Pick(m.s, "\xff", "\xc0\x80", "\xed\xa0\x80")
+	}
+	b = m.u32(b, uint32(len(name)))
+	return append(b, name...)
+}
+
+// valType returns a value type, now and then a byte that is none.
+func (m *mgen) valType() byte {
+	if m.broken() {
+		return gen.Pick[byte](m.s, 0x40, 0x00, 0x60, 0x7a, 0xff, 0x69)
+	}
+	if m.s.Chance(0.05) {
+		return gen.Pick[byte](m.s, v128, funcref, externref)
+	}
+	return gen.Pick(m.s, numeric...)
+}
+
+// funcType returns a function type of a few parameters and up to three
+// results, numbers mostly, now and then a great many of them.
+func (m *mgen) funcType() funcType {
+	var t funcType
+	n := m.s.Intn(5)
+	if m.s.Chance(0.02) {
+		n = m.s.Range(100, 1000)
+	}
+	for range n {
+		t.params = append(t.params, gen.Pick(m.s, numeric...))
+	}
+	for range gen.Pick(m.s, 0, 1, 1, 1, 2, 3) {
+		t.results = append(t.results, gen.Pick(m.s, numeric...))
+	}
+	return t
+}
+
+// typeSection returns the type section.
+func (m *mgen) typeSection() []byte {
+	b := m.count(nil, len(m.types))
+	for _, t := range m.types {
+		form := byte(0x60)
+		if m.broken() {
+			form = gen.Pick[byte](m.s, 0x5f, 0x5e, 0x4e, 0x00)
+		}
+		b = append(b, form)
+		b = m.count(b, len(t.params))
+		for _, p := range t.params {
+			if m.broken() {
+				p = m.valType()
+			}
+			b = append(b, p)
+		}
+		b = m.count(b, len(t.results))
+		b = append(b, t.results...)
+	}
+	return b
+}
+
+// limits appends the limits of a table or memory of at least min, with
+// a maximum now and then, up to max.
+func (m *mgen) limits(b []byte, min, max uint32) []byte {
+	lo := uint32(m.s.Intn(int(min) + 1))
+	if !m.s.Chance(0.4) {
+		return m.u32(append(b, 0x00), lo)
+	}
+	hi := lo + uint32(m.s.Intn(int(max-lo)+1))
+	flag := byte(0x01)
+	if m.broken() {
+		// A maximum below the minimum, one past what a memory may have,
+		// or a flag of shared memory or 64-bit memory, or of nothing.
+		switch m.s.Intn(3) {
+		case 0:
+			lo = hi + 1
+		case 1:
+			hi = gen.Pick[uint32](m.s, 65537, 1<<31, math.MaxUint32)
+		default:
+			flag = gen.Pick[byte](m.s, 0x02, 0x03, 0x04, 0x05, 0x07, 0x80)
+		}
+	}
+	b = m.u32(append(b, flag), lo)
+	return m.u32(b, hi)
+}
+
+// importSection returns the import section: functions, a table, a memory
+// and globals from the host, or nil if nothing is imported.
+func (m *mgen) importSection() []byte {
+	if !m.s.Chance(0.4) {
+		return nil
+	}
+	var b []byte
+	n := 0
+	for range m.s.Range(1, 4) {
+		kind := gen.Pick(m.s, 0, 0, 1, 2, 3)
+		if kind == 1 && m.tables > 0 || kind == 2 && m.memories > 0 {
+			kind = 0
+		}
+		b = m.name(b, gen.Pick(m.s, "env", "wasi_snapshot_preview1", "a"))
+		b = m.name(b, gen.Pick(m.s, "f", "print", "fd_write", "memory", "table", "g", "__stack_pointer"))
+		if m.broken() {
+			kind = m.s.Range(4, 255)
+		}
+		b = append(b, byte(kind))
+		switch kind {
+		case 0:
+			t := m.s.Intn(len(m.types))
+			m.funcs = append(m.funcs, t)
+			m.imported++
+			b = m.index(b, t, len(m.types))
+		case 1:
+			m.tables++
+			b = m.limits(append(b, funcref), 16, 64)
+		case 2:
+			m.memories++
+			b = m.limits(b, 2, 16)
+		case 3:
+			g := global{typ: gen.Pick(m.s, numeric...), mutable: m.s.Chance(0.3)}
+			m.globals = append(m.globals, g)
+			m.globalImports++
+			b = append(b, g.typ, boolByte(g.mutable))
+		}
+		n++
+	}
+	return append(m.count(nil, n), b...)
+}
+
+func boolByte(v bool) byte {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+// functionSection returns the function section, the types of the
+// functions the module defines, or nil if it defines none. Now and then
+// it lists one more or one fewer than the code section has bodies.
+func (m *mgen) functionSection(defined []int) []byte {
+	if len(defined) == 0 && !m.broken() {
+		return nil
+	}
+	n := len(defined)
+	if m.broken() {
+		n = gen.Pick(m.s, n+1, max(n-1, 0))
+	}
+	b := m.u32(nil, uint32(n))
+	for i := range n {
+		t := 0
+		if i < len(defined) {
+			t = defined[i]
+		}
+		b = m.index(b, t, len(m.types))
+	}
+	return b
+}
+
+// tableSection returns the table section, a table of funcref for
+// indirect calls and now and then one of externref, or nil.
+func (m *mgen) tableSection() []byte {
+	if m.tables > 0 || !m.s.Chance(0.5) {
+		return nil
+	}
+	m.tables++
+	b := m.limits([]byte{1, funcref}, 16, 64)
+	if m.s.Chance(0.2) {
+		m.tables++
+		b[0]++
+		b = m.limits(append(b, externref), 4, 8)
+	}
+	return b
+}
+
+// memorySection returns the memory section, or nil if the module has no
+// memory or imports it.
+func (m *mgen) memorySection() []byte {
+	if m.memories > 0 || !m.s.Chance(0.6) {
+		return nil
+	}
+	m.memories++
+	return m.limits([]byte{1}, 2, 16)
+}
+
+// constExpr appends a constant expression giving a value of type t: a
+// constant, or an imported global of the same type.
+func (m *mgen) constExpr(b []byte, t byte) []byte {
+	for i, g := range m.globals[:m.globalImports] {
+		if g.typ == t && !g.mutable && m.s.Chance(0.3) {
+			return append(m.index(append(b, 0x23), i, len(m.globals)), 0x0b)
+		}
+	}
+	switch t {
+	case i32:
+		b = m.s32(append(b, 0x41), int32(m.number()))
+	case i64:
+		b = m.s64(append(b, 0x42), m.number())
+	case f32:
+		b = binary.LittleEndian.AppendUint32(append(b, 0x43), math.Float32bits(float32(m.float())))
+	case f64:
+		b = binary.LittleEndian.AppendUint64(append(b, 0x44), math.Float64bits(m.float()))
+	default:
+		b = append(b, 0xd0, funcref)
+	}
+	if m.broken() {
+		// An instruction a constant expression may not have, or none
+		// to end it.
+		return append(b, gen.Pick[byte](m.s, 0x6a, 0x1a, 0x00, 0x41))
+	}
+	return append(b, 0x0b)
+}
+
+// number returns an integer, at the edges of the ranges more often than
+// not.
+func (m *mgen) number() int64 {
+	switch m.s.Intn(4) {
+	case 0:
+		return int64(m.s.Range(-8, 100))
+	case 1:
+		return gen.Pick[int64](m.s, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64, -1, 1<<31, 0xffffffff, 65536)
+	case 2:
+		return int64(m.s.Uint64())
+	}
+	return int64(m.s.Intn(1 << 16))
+}
+
+// float returns a float: ordinary, at the edges of the range, an
+// infinity or a NaN.
+func (m *mgen) float() float64 {
+	return gen.Pick(m.s, 0, 1.5, -2.25, math.Pi, 1e300, -1e-300, math.MaxFloat32, math.SmallestNonzeroFloat64, math.Inf(1), math.Inf(-1), math.NaN(), math.Copysign(0, -1), 2147483648, -9.3e18)
+}
+
+// globalSection returns the global section, or nil.
+func (m *mgen) globalSection() []byte {
+	n := m.s.Intn(4)
+	if n == 0 {
+		return nil
+	}
+	b := m.count(nil, n)
+	for range n {
+		g := global{typ: gen.Pick(m.s, numeric...), mutable: m.s.Chance(0.6)}
+		b = append(b, g.typ, boolByte(g.mutable))
+		if m.broken() {
+			b[len(b)-1] = gen.Pick[byte](m.s, 2, 0x80, 0xff)
+		}
+		b = m.constExpr(b, g.typ)
+		m.globals = append(m.globals, g)
+	}
+	return b
+}
+
+// exportSection returns the export section: some of the functions the
+// module defines, from first on, under names now and then repeated, and
+// its memory and table.
+func (m *mgen) exportSection(first int) []byte {
+	var b []byte
+	n := 0
+	used := map[string]bool{}
+	export := func(name string, kind byte, i, count int) {
+		if used[name] && !m.broken() {
+			return
+		}
+		used[name] = true
+		b = m.name(b, name)
+		b = m.index(append(b, kind), i, count)
+		n++
+	}
+	for i := first; i < len(m.funcs); i++ {
+		if m.s.Chance(0.8) {
+			name := gen.Pick(m.s, "main", "_start", "run", "f", "add", "", "λ")
+			if used[name] {
+				name += string(rune('0' + i%10))
+			}
+			export(name, 0, i, len(m.funcs))
+		}
+	}
+	if m.memories > 0 && m.s.Chance(0.7) {
+		export("memory", 2, 0, m.memories)
+	}
+	if m.tables > 0 && m.s.Chance(0.3) {
+		export("table", 1, 0, m.tables)
+	}
+	for i := range m.globals {
+		if m.s.Chance(0.2) {
+			export("g"+string(rune('0'+i%10)), 3, i, len(m.globals))
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	return append(m.count(nil, n), b...)
+}
+
+// elementSection returns the element section, which fills the module's
+// table with functions, from an offset or passively or only declaring
+// them, or nil.
+func (m *mgen) elementSection() []byte {
+	if m.tables == 0 || len(m.funcs) == 0 || !m.s.Chance(0.7) {
+		return nil
+	}
+	n := m.s.Range(1, 3)
+	b := m.count(nil, n)
+	for range n {
+		flags := gen.Pick[byte](m.s, 0, 0, 1, 2, 3, 4, 5)
+		b = append(b, flags)
+		if flags&1 == 0 { // active: a table, for 2 and 6, and an offset
+			if flags&2 != 0 {
+				b = m.index(b, 0, m.tables)
+			}
+			// An offset that puts the functions past the end of the
+			// table fails when the module is instantiated.
+			off := int32(m.s.Intn(8))
+			if m.broken() {
+				off = gen.Pick[int32](m.s, 64, -1, math.MaxInt32)
+			}
+			b = append(m.s32(append(b, 0x41), off), 0x0b)
+		}
+		if flags == 1 || flags == 2 || flags == 3 {
+			b = append(b, 0x00) // elemkind funcref
+		}
+		if flags == 5 {
+			b = append(b, funcref)
+		}
+		k := m.s.Range(1, 8)
+		b = m.count(b, k)
+		for range k {
+			f := m.s.Intn(len(m.funcs))
+			if flags >= 4 {
+				b = append(m.index(append(b, 0xd2), f, len(m.funcs)), 0x0b)
+			} else {
+				b = m.index(b, f, len(m.funcs))
+			}
+		}
+	}
+	return b
+}
+
+// dataSegments returns the data segments, active ones at small offsets
+// into the memory and passive ones.
+func (m *mgen) dataSegments() [][]byte {
+	if m.memories == 0 || !m.s.Chance(0.6) {
+		return nil
+	}
+	var segs [][]byte
+	for range m.s.Range(1, 3) {
+		var b []byte
+		if m.s.Chance(0.3) {
+			b = append(b, 1)
+			m.passive++
+		} else {
+			if m.s.Chance(0.2) {
+				b = m.index(append(b, 2), 0, m.memories)
+			} else {
+				b = append(b, 0)
+			}
+			b = m.s32(append(b, 0x41), int32(m.s.Intn(1024)))
+			b = append(b, 0x0b)
+		}
+		data := []byte(gen.Pick(m.s, "hello, world\n", "\x00\x01\x02\x03", "", "\xff\xff\xff\xff\xff\xff\xff\x7f"))
+		b = m.count(b, len(data))
+		segs = append(segs, append(b, data...))
+	}
+	return segs
+}
+
+// customSection appends a custom section of a vendor's, or one whose
+// name runs past it.
+func (m *mgen) customSection(b []byte) []byte {
+	var body []byte
+	body = m.name(body, gen.Pick(m.s, "producers", "target_features", ".debug_info", "sourceMappingURL", ""))
+	body = append(body, gen.Pick(m.s, "", "\x01\x0clanguage\x01\x04Rust\x00", "x")...)
+	if m.broken() {
+		body = m.u32(nil, uint32(len(body)+100))
+	}
+	return append(m.u32(append(b, secCustom), uint32(len(body))), body...)
+}
+
+// nameSection returns the body of the name custom section, which names
+// the module and its functions.
+func (m *mgen) nameSection() []byte {
+	b := m.name(nil, "name")
+	sub := func(id byte, body []byte) {
+		b = append(m.u32(append(b, id), uint32(len(body))), body...)
+	}
+	sub(0, m.name(nil, gen.Pick(m.s, "seed", "", "main.wasm")))
+	var fn []byte
+	fn = m.count(fn, len(m.funcs))
+	for i := range m.funcs {
+		fn = m.index(fn, i, len(m.funcs))
+		fn = m.name(fn, gen.Pick(m.s, "main", "$f", "std::rt::lang_start", "", "runtime.main"))
+	}
+	sub(1, fn)
+	return b
+}
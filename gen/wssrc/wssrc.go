@@ -0,0 +1,313 @@
+// Package wssrc generates WebSocket seeds. It registers the "ws/..."
+// generators with package gen.
+//
+// "ws/client" writes the frames a client sends once its handshake is
+// done, masked, and "ws/server" those a server sends, unmasked: text and
+// binary messages, whole or in fragments with pings and pongs between
+// them, and now and then a close frame to end the stream. Text is
+// UTF-8, now and then split inside a character where a fragment ends;
+// payloads sit at the edges of the 7-, 16- and 64-bit length forms and
+// sometimes take a longer form than they need. A few frames break the
+// rules: continuations with no message to continue, data frames in the
+// middle of one, fragmented and oversized control frames, reserved bits
+// and opcodes, a frame masked or not as its sender's must not be, 64-bit
+// lengths with the top bit set or far past the end, invalid UTF-8, and
+// close frames of one byte, with codes no endpoint may send, or with
+// reasons too long or not UTF-8.
+package wssrc
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "ws/client",
+		Doc:  "WebSocket frames a client sends, masked: fragmented text and binary messages, control frames between fragments, close frames, and continuation abuse, reserved bits and opcodes, unmasked frames, huge 64-bit lengths and bad close payloads",
+		Func: func(s *gen.State) []gen.File { return stream(s, true) },
+	})
+	gen.Register(&gen.Generator{
+		Name: "ws/server",
+		Doc:  "WebSocket frames a server sends, unmasked: fragmented text and binary messages, control frames between fragments, close frames, and continuation abuse, reserved bits and opcodes, masked frames, huge 64-bit lengths and bad close payloads",
+		Func: func(s *gen.State) []gen.File { return stream(s, false) },
+	})
+}
+
+// badRate is the chance that a frame, or a message, breaks a rule. A
+// stream has about ten frames, so about one in ten has one.
+const badRate = 0.01
+
+// Opcodes.
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xa
+)
+
+// maxControl is the most payload a control frame may have.
+const maxControl = 125
+
+var (
+	texts = []string{
+		"hello", "", "{\"type\":\"subscribe\",\"channel\":\"ticker\"}", "héllo wörld", "日本語のテキスト",
+		"emoji 😀🎉", "a\x00b", "  ", "\ufeffbom", strings.Repeat("x", 200),
+	}
+	// bad are byte sequences that are not UTF-8: a stray continuation
+	// byte, an overlong encoding, a surrogate, a code point past
+	// U+10FFFF and a character cut short.
+	bad = []string{"\x80", "\xc0\xaf", "\xed\xa0\x80", "\xf4\x90\x80\x80", "\xe2\x82", "\xff"}
+	// codes are the close codes an endpoint may send.
+	codes = []int{1000, 1000, 1000, 1001, 1002, 1003, 1007, 1008, 1009, 1010, 1011, 1012, 1013, 1014, 3000, 4000, 4999}
+	// badCodes are close codes none may: unused, reserved for what no
+	// frame carries, unassigned and out of range.
+	badCodes = []int{0, 999, 1004, 1005, 1006, 1015, 1016, 2999, 5000, 65535}
+	reasons  = []string{"", "bye", "going away", "au revoir ✓", strings.Repeat("r", maxControl-2)}
+)
+
+// A wgen writes the frames of one stream.
+type wgen struct {
+	s *gen.State
+	// client is whether the frames are a client's, which are masked.
+	client bool
+	b      []byte
+	// done is whether a frame's length ran past the end of the stream,
+	// after which nothing more can be written.
+	done bool
+}
+
+// stream writes the frames of a client, or of a server.
+func stream(s *gen.State, client bool) []gen.File {
+	w := &wgen{s: s, client: client}
+	for range s.Range(1, 6) {
+		if w.done {
+			break
+		}
+		if s.Chance(badRate) {
+			// A continuation that continues nothing.
+			w.frame(true, 0, opContinuation, w.payload(false))
+		}
+		w.message()
+		if s.Chance(0.2) {
+			w.control(gen.Pick(s, opPing, opPong))
+		}
+	}
+	if !w.done && s.Chance(0.6) {
+		w.close()
+		if s.Chance(0.05) {
+			// Frames after the close, which a reader must not read.
+			w.message()
+		}
+	}
+	if s.Chance(badRate) && len(w.b) > 0 {
+		w.b = w.b[:s.Intn(len(w.b))]
+	}
+	name := "server.ws"
+	if client {
+		name = "client.ws"
+	}
+	return []gen.File{{Name: name, Data: w.b}}
+}
+
+// message writes a text or binary message, whole or in fragments with
+// control frames between them.
+func (w *wgen) message() {
+	s := w.s
+	text := s.Chance(0.6)
+	op := opBinary
+	if text {
+		op = opText
+	}
+	p := w.payload(text)
+	if !s.Chance(0.3) {
+		w.frame(true, 0, op, p)
+		return
+	}
+	cuts := []int{0}
+	for range s.Range(1, 4) {
+		cuts = append(cuts, s.Intn(len(p)+1))
+	}
+	cuts = append(cuts, len(p))
+	slices.Sort(cuts)
+	for i := 1; i < len(cuts); i++ {
+		if w.done {
+			return
+		}
+		last := i == len(cuts)-1
+		fop := op
+		switch {
+		case i > 1 && s.Chance(badRate):
+			// A new message in the middle of this one.
+		case i > 1:
+			fop = opContinuation
+		}
+		w.frame(last && !s.Chance(badRate), 0, fop, p[cuts[i-1]:cuts[i]])
+		if !last && s.Chance(0.2) {
+			w.control(gen.Pick(s, opPing, opPing, opPong, opClose))
+		}
+	}
+}
+
+// payload returns the payload of a message, text if text is set, at
+// times as long as the edges of the length forms and now and then, for
+// text, not UTF-8.
+func (w *wgen) payload(text bool) []byte {
+	s := w.s
+	var p []byte
+	if text {
+		for range s.Range(1, 3) {
+			p = append(p, gen.Pick(s, texts...)...)
+		}
+		if s.Chance(badRate) {
+			i := s.Intn(len(p) + 1)
+			p = append(p[:i], append([]byte(gen.Pick(s, bad...)), p[i:]...)...)
+		}
+	} else {
+		p = w.bytes(gen.Pick(s, 0, 1, 4, 16, 100))
+	}
+	if s.Chance(0.05) {
+		n := gen.Pick(s, 125, 126, 127, 65535, 65536)
+		if s.Chance(0.8) {
+			n = gen.Pick(s, 125, 126, 127)
+		}
+		fill := "y"
+		if !text {
+			fill = "\x00"
+		}
+		p = append(p, strings.Repeat(fill, max(n-len(p), 0))...)[:n]
+		if text && !utf8.Valid(p) {
+			p = []byte(strings.Repeat("y", n))
+		}
+	}
+	return p
+}
+
+// control writes a control frame of opcode op, rarely one fragmented or
+// longer than a control frame may be.
+func (w *wgen) control(op int) {
+	s := w.s
+	if op == opClose {
+		w.close()
+		return
+	}
+	p := w.bytes(gen.Pick(s, 0, 4, 8, maxControl))
+	if s.Chance(badRate) {
+		p = w.bytes(gen.Pick(s, maxControl+1, 200))
+	}
+	if s.Chance(badRate) {
+		w.frame(false, 0, op, p[:len(p)/2])
+		w.frame(true, 0, opContinuation, p[len(p)/2:])
+		return
+	}
+	w.frame(true, 0, op, p)
+}
+
+// close writes a close frame: empty, or a code and a reason; rarely a
+// single byte, a code no endpoint may send, or a reason that is not
+// UTF-8 or makes the payload too long for a control frame.
+func (w *wgen) close() {
+	s := w.s
+	if s.Chance(0.05) {
+		w.frame(true, 0, opClose, nil)
+		return
+	}
+	if s.Chance(badRate) {
+		w.frame(true, 0, opClose, []byte{0x03})
+		return
+	}
+	code := gen.Pick(s, codes...)
+	if s.Chance(badRate * 2) {
+		code = gen.Pick(s, badCodes...)
+	}
+	reason := gen.Pick(s, reasons...)
+	if s.Chance(badRate) {
+		reason = gen.Pick(s, "bad \xff reason", strings.Repeat("r", maxControl-1), "\xe2\x82")
+	}
+	w.frame(true, 0, opClose, append([]byte{byte(code >> 8), byte(code)}, reason...))
+}
+
+// frame writes a frame, masked if it is a client's. Now and then its
+// length takes more bytes than it needs; rarely it has reserved bits or
+// a reserved opcode, is masked or not as its sender's must not be, or
+// has a length wrong for its payload.
+func (w *wgen) frame(fin bool, rsv, op int, p []byte) {
+	s := w.s
+	if w.done {
+		return
+	}
+	if s.Chance(badRate) {
+		rsv = s.Range(1, 7)
+	}
+	if s.Chance(badRate) {
+		op = gen.Pick(s, 0x3, 0x7, 0xb, 0xf)
+	}
+	b0 := byte(rsv<<4 | op)
+	if fin {
+		b0 |= 0x80
+	}
+	masked := w.client
+	if s.Chance(badRate) {
+		masked = !masked
+	}
+	n := uint64(len(p))
+	switch {
+	case s.Chance(badRate):
+		n = gen.Pick(s, n+1, n+65536, 1<<63, 1<<64-1, 1<<40)
+		w.done = n > uint64(len(p))
+	case s.Chance(badRate):
+		n = gen.Pick(s, max(n, 1)-1, 0)
+	}
+	form := 0
+	switch {
+	case n > 0xffff:
+		form = 8
+	case n > 125:
+		form = 2
+	}
+	if s.Chance(0.05) {
+		form = max(form, gen.Pick(s, 2, 8))
+	}
+	var mbit byte
+	if masked {
+		mbit = 0x80
+	}
+	w.b = append(w.b, b0)
+	switch form {
+	case 0:
+		w.b = append(w.b, mbit|byte(n))
+	case 2:
+		w.b = append(w.b, mbit|126, byte(n>>8), byte(n))
+	default:
+		w.b = append(w.b, mbit|127)
+		for i := 7; i >= 0; i-- {
+			w.b = append(w.b, byte(n>>(8*i)))
+		}
+	}
+	if !masked {
+		w.b = append(w.b, p...)
+		return
+	}
+	key := w.bytes(4)
+	if s.Chance(0.1) {
+		key = []byte{0, 0, 0, 0}
+	}
+	w.b = append(w.b, key...)
+	for i, c := range p {
+		w.b = append(w.b, c^key[i%4])
+	}
+}
+
+// bytes returns n random bytes.
+func (w *wgen) bytes(n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte(w.s.Intn(256))
+	}
+	return b
+}
@@ -0,0 +1,257 @@
+// Package xmlsrc generates XML seeds. It registers the "xml/..."
+// generators with package gen.
+//
+// Element and attribute names are drawn from those of the struct
+// fuzz/xml unmarshals into, so that a seed fills its fields, and from
+// prefixed names whose prefixes are declared, redeclared, undeclared or
+// reserved. Most of a document is well formed; each construct also has
+// malformed variants, drawn rarely, because a single bad one fails the
+// whole document.
+package xmlsrc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "xml/doc",
+		Doc:  "XML documents: deep nesting, entity and character references, CDATA edge cases, namespace prefix abuse, DOCTYPE internal subsets with nested entities, comments, processing instructions and directives",
+		Func: doc,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// document has a few dozen constructs, so about a fifth of them get one.
+const badRate = 0.005
+
+// elements are the names of the elements of fuzz/xml's struct, a path
+// of them, and names it has no field for.
+var elements = []string{"doc", "doc", "name", "item", "item", "note", "n", "f", "up", "a", "b", "c", "other", "名前", "é", "x.y-z"}
+
+// attributes are the names of its attributes, and ones it has no field
+// for.
+var attributes = []string{"id", "b", "kind", "lang", "xml:lang", "xml:space", "other", "href"}
+
+// prefixes are the namespace prefixes of names, the first few of which a
+// document declares somewhere.
+var prefixes = []string{"p", "q", "urn", "xml", "xmlns", "undeclared"}
+
+// spaces are the namespace names prefixes are bound to.
+var spaces = []string{"urn:x", "urn:y", "http://www.w3.org/XML/1998/namespace", "http://www.w3.org/2000/xmlns/", "", "p", "urn:x ", "urn:X"}
+
+// A xdoc accumulates an XML document.
+type xdoc struct {
+	s *gen.State
+	b strings.Builder
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &xdoc{s: s}
+	if s.Chance(0.5) {
+		d.b.WriteString(d.prolog())
+	}
+	if s.Chance(0.2) {
+		d.misc()
+	}
+	if s.Chance(0.15) {
+		d.doctype()
+	}
+	root := "doc"
+	if s.Chance(0.1) {
+		root = gen.Pick(s, "item", d.name(elements))
+	}
+	if s.Chance(0.05) {
+		// Nesting deep enough for the unmarshaler's depth limit, which a
+		// large -depth.block raises it to.
+		n := s.Depth(s.Limits.Block, 100)
+		inner := gen.Pick(s, "doc", "item", "a")
+		d.write("<%s>", root)
+		d.b.WriteString(strings.Repeat("<"+inner+">", n))
+		d.text()
+		d.b.WriteString(strings.Repeat("</"+inner+">", n))
+		d.write("</%s>", root)
+	} else {
+		d.element(root, s.Depth(s.Limits.Block, 4))
+	}
+	if s.Chance(0.2) {
+		d.misc()
+	}
+	if s.Chance(0.05) {
+		// A second root, or text after the root, which XML forbids and
+		// the decoder only notices as tokens.
+		d.b.WriteString(gen.Pick(s, "<doc/>", "text", "&amp;", "</doc>", "<", "\x00"))
+	}
+	return []gen.File{{Name: "doc.xml", Data: []byte(d.b.String())}}
+}
+
+func (d *xdoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+// prolog returns an XML declaration, some with an encoding the decoder
+// has no reader for.
+func (d *xdoc) prolog() string {
+	if d.s.Chance(badRate * 10) {
+		return gen.Pick(d.s, `<?xml?>`, `<?xml version="2.0"?>`, `<?xml encoding="UTF-8" version="1.0"?>`, `<?xml version='1.0`, ` <?xml version="1.0"?>`, `<?XML version="1.0"?>`)
+	}
+	decl := gen.Pick(d.s,
+		`<?xml version="1.0"?>`, `<?xml version="1.0" encoding="UTF-8"?>`, `<?xml version='1.0' encoding='utf-8'?>`,
+		`<?xml version="1.0" standalone="yes"?>`, `<?xml version="1.0"  encoding = "UTF-8"  standalone='no' ?>`,
+	)
+	if d.s.Chance(0.1) {
+		// Ones the decoder has no reader for, or does not support.
+		decl = gen.Pick(d.s, `<?xml version="1.1"?>`, `<?xml version="1.0" encoding="ISO-8859-1"?>`, `<?xml version="1.0" encoding="UTF-16"?>`, `<?xml version="1.0" encoding="US-ASCII"?>`)
+	}
+	return decl + gen.Pick(d.s, "", "\n", "\r\n")
+}
+
+// doctype writes a document type declaration with an internal subset,
+// often one whose entities expand to each other many times over.
+func (d *xdoc) doctype() {
+	d.b.WriteString("<!DOCTYPE doc")
+	if d.s.Chance(0.3) {
+		d.b.WriteString(gen.Pick(d.s, ` SYSTEM "doc.dtd"`, ` PUBLIC "-//X//DTD X//EN" "http://example.com/x.dtd"`, ` SYSTEM 'file:///etc/passwd'`))
+	}
+	d.b.WriteString(" [\n")
+	if d.s.Chance(0.5) {
+		// The billion laughs: each entity is ten of the one before.
+		d.b.WriteString(`<!ENTITY lol0 "lol">` + "\n")
+		for i := 1; i < 10; i++ {
+			d.write("<!ENTITY lol%d \"%s\">\n", i, strings.Repeat(fmt.Sprintf("&lol%d;", i-1), 10))
+		}
+	}
+	for range d.s.Range(0, 4) {
+		d.b.WriteString(gen.Pick(d.s,
+			`<!ELEMENT doc ANY>`, `<!ELEMENT item (#PCDATA|note)*>`, `<!ATTLIST doc id ID #IMPLIED b CDATA "false">`,
+			`<!ENTITY e "<item>x</item>">`, `<!ENTITY ext SYSTEM "file:///etc/passwd">`, `<!ENTITY % pe "<!ENTITY y 'z'>"> %pe;`,
+			`<!ENTITY quote '">'>`, `<!-- in the subset ]> -->`, `<?pi in the subset ]>?>`, `<!NOTATION n SYSTEM "n">`,
+			`<!ENTITY gt ">">`,
+		))
+		d.b.WriteByte('\n')
+	}
+	if d.s.Chance(badRate * 10) {
+		d.b.WriteString(gen.Pick(d.s, "<!ENTITY", `"`, "'", "<!--", "[", "<"))
+	}
+	d.b.WriteString("]>\n")
+}
+
+// name returns a name from names, sometimes prefixed.
+func (d *xdoc) name(names []string) string {
+	if d.s.Chance(badRate * 4) {
+		return gen.Pick(d.s, ":x", "x:", "a:b:c", "1x", "-x", "x y", "", ":", "x:1")
+	}
+	n := gen.Pick(d.s, names...)
+	if !strings.Contains(n, ":") && d.s.Chance(0.2) {
+		return gen.Pick(d.s, prefixes...) + ":" + n
+	}
+	return n
+}
+
+// element writes an element named name with attributes and content.
+func (d *xdoc) element(name string, depth int) {
+	d.write("<%s", name)
+	for range d.s.Range(0, 3) {
+		d.b.WriteString(gen.Pick(d.s, " ", "\n\t", "  "))
+		d.attr()
+	}
+	if d.s.Chance(0.15) {
+		d.write("%s/>", gen.Pick(d.s, "", " "))
+		return
+	}
+	d.b.WriteByte('>')
+	for range d.s.Range(0, 5) {
+		if depth > 0 && d.s.Chance(0.5) {
+			d.element(d.name(elements), depth-1)
+			continue
+		}
+		switch d.s.Intn(6) {
+		case 0:
+			d.cdata()
+		case 1:
+			d.misc()
+		default:
+			d.text()
+		}
+	}
+	if d.s.Chance(badRate * 4) {
+		// A missing or mismatched end tag, which only a lax decoder
+		// invents or accepts.
+		d.b.WriteString(gen.Pick(d.s, "", "</other>", "</"+strings.ToUpper(name)+">", "</>", "</"+name))
+		return
+	}
+	d.write("</%s%s>", name, gen.Pick(d.s, "", "", " ", "\n"))
+}
+
+// attr writes an attribute, a namespace declaration or, rarely, one a
+// strict decoder rejects.
+func (d *xdoc) attr() {
+	if d.s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(d.s, "id=1", "id", `id="1" id="2"`, `id="<"`, `id="a`, `="x"`, `id='"'`, `id = "x"`))
+		return
+	}
+	if d.s.Chance(0.3) {
+		prefix := gen.Pick(d.s, prefixes[:len(prefixes)-1]...)
+		if d.s.Chance(0.3) {
+			d.write(`xmlns="%s"`, gen.Pick(d.s, spaces...))
+		} else {
+			d.write(`xmlns:%s="%s"`, prefix, gen.Pick(d.s, spaces...))
+		}
+		return
+	}
+	q := gen.Pick(d.s, `"`, `"`, `'`)
+	d.write("%s=%s", d.name(attributes), q)
+	for range d.s.Range(0, 3) {
+		v := d.chars()
+		if strings.Contains(v, q) {
+			v = strings.ReplaceAll(v, q, "&quot;")
+		}
+		d.b.WriteString(v)
+	}
+	d.b.WriteString(q)
+}
+
+// text writes character data.
+func (d *xdoc) text() {
+	for range d.s.Range(1, 4) {
+		d.b.WriteString(d.chars())
+	}
+}
+
+// chars returns a run of text or a reference, well formed but for a few.
+func (d *xdoc) chars() string {
+	if d.s.Chance(badRate * 4) {
+		return gen.Pick(d.s, "&", "&;", "&#;", "&#x;", "&#0;", "&#xD800;", "&#x110000;", "&#xFFFE;", "&nbsp;", "&lol9;",
+			"&e;", "&ext;", "&ampx;", "<", "]]>", "\x00", "\x1b", "\xff", "\xc3")
+	}
+	return gen.Pick(d.s,
+		"x", "text", " ", "\n", "\r\n", "\t", "1", "-1", "1.5", "true", "名前", "é", "😀", ">", "]]", `"`, "'",
+		"&amp;", "&lt;", "&gt;", "&quot;", "&apos;", "&#65;", "&#x41;", "&#x1F600;", "&#xD;", "&#9;", "&#x20;",
+		"&#0065;", "&#x000041;", "&lt;doc&gt;",
+	)
+}
+
+// cdata writes a CDATA section, some holding what looks like its end.
+func (d *xdoc) cdata() {
+	if d.s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(d.s, "<![CDATA[", "<![CDATA[x]]", "<![cdata[x]]>", "<![CDATA x]]>", "<![CDATA[]]]]>]]>"))
+		return
+	}
+	d.write("<![CDATA[%s]]>", gen.Pick(d.s, "", "x", "]", "]]", "]]]", "<doc>", "&amp;", "<![CDATA[", "]>", "\r\n", "名前", "-->"))
+}
+
+// misc writes a comment, processing instruction or directive.
+func (d *xdoc) misc() {
+	if d.s.Chance(badRate * 4) {
+		d.b.WriteString(gen.Pick(d.s, "<!-- a -- b -->", "<!--->", "<!-- x", "<?", "<??>", "<?xml version=\"1.0\"?>", "<!>", "<!X", "<![INCLUDE[x]]>"))
+		return
+	}
+	d.b.WriteString(gen.Pick(d.s,
+		"<!-- c -->", "<!---->", "<!-- <doc> -->", "<!-- - -->", "<!--\n-->",
+		"<?pi?>", "<?pi data?>", "<?xml-stylesheet href=\"s.xsl\" type=\"text/xsl\"?>", "<?t ??>", "<?t <doc>?>", "<?名前 x?>",
+		"<!DOCTYPE x>", "<!ENTITY y 'z'>", "<!DOCTYPE x [ <!-- ] --> ]>", `<!X "quoted > text">`, "<!X 'a'\"b\">",
+	))
+}
@@ -0,0 +1,588 @@
+// Package yamlsrc generates YAML seeds. It registers the "yaml/..."
+// generators with package gen.
+//
+// A document is a tree of block and flow collections, each indented by a
+// step of its own, with scalars plain, quoted and in literal and folded
+// blocks, tags by their shorthands and in full, anchors and the aliases
+// and merge keys that refer back to them, and comments, now and then
+// after directives and among more documents. A few are alias bombs:
+// levels of sequences each of which refers several times to the level
+// before. Each construct has malformed variants, drawn rarely, because a
+// single bad one fails the document.
+package yamlsrc
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "yaml/doc",
+		Doc:  "YAML streams: block and flow collections mixed at varied indentation, plain, quoted, literal and folded scalars, tags by shorthand, %TAG handle and verbatim, anchors, aliases and merge keys, alias bombs, comments, directives and several documents, and tabs, bad indentation, unclosed flows, undefined aliases and mismatched tags now and then",
+		Func: doc,
+	})
+}
+
+// badRate is the chance that a construct is drawn in a malformed form. A
+// document has a few dozen of them, so about a tenth of them get one.
+const badRate = 0.003
+
+// keys are mapping keys, some of which YAML 1.1 would read as something
+// other than a string.
+var keys = []string{
+	"a", "b", "name", "key", "items", "spec", "metadata", "x y", "é", "キー", "on", "y", "no", "null", "1", "0x10",
+	"true", "~", "-1", "1.5", `"quoted key"`, `'single'`, `""`, "a.b", "a/b", "a-b", "_", "<",
+}
+
+// plains are plain scalars that may go in flow context as well as in
+// block context.
+var plains = []string{
+	"x", "hello world", "yes", "No", "on", "OFF", "y", "n", "true", "False", "null", "Null", "~", "0", "-0", "+1",
+	"42", "0x1F", "0o17", "017", "0b101", "1_000", "1e3", "-1.5e-3", "6.8523015e+5", "685_230.15", ".inf", "-.Inf",
+	"+.INF", ".nan", ".NaN", "9223372036854775807", "9223372036854775808", "-9223372036854775809",
+	"18446744073709551615", "1.7976931348623157e309", "2001-12-14t21:59:43.10-05:00", "2002-12-14",
+	"2001-12-14 21:59:43.10 -5", "12:30:45", "=", "<<", "a=b", "~foo",
+}
+
+// blockPlains are plain scalars that may go only in block context.
+var blockPlains = []string{"a, b", "a,b", "x ]", "a [b]", "x:y", "a #b", "http://example.com/a?b=c"}
+
+// tags are tags for any node, in shorthand, verbatim or local.
+var tags = []string{"!!str", "!!map", "!!seq", "!<tag:yaml.org,2002:str>", "!local", "!", "!e!thing", "!!python/object"}
+
+// scalarTags go with the scalars that match them.
+var scalarTags = map[string][]string{
+	"!!int":       {"1", "-17", "0x2a", "0o17", "1_000", "0b11"},
+	"!!float":     {"1.5", ".inf", "-.inf", ".nan", "1e-9", "3"},
+	"!!bool":      {"true", "false", "True", "FALSE"},
+	"!!null":      {"~", "null", ""},
+	"!!binary":    {"aGVsbG8=", `"AAECAwQF"`, "R0lGODlhDAAMAIQAAP//9/X17unp5WZmZgAAAOfn515eXvPz7Y6OjuDg4J+fn5OTk6enp56enmleECcgggoBADs="},
+	"!!timestamp": {"2001-12-14t21:59:43.10-05:00", "2002-12-14", "2001-12-14T02:59:43.1Z"},
+	"!!str":       {"1", "true", "~", "0x10"},
+}
+
+// A ydoc accumulates a YAML stream.
+type ydoc struct {
+	s *gen.State
+	b strings.Builder
+	// anchors are those defined so far in the document, which aliases
+	// may refer to, and maps those of them that are of mappings, which
+	// merge keys may.
+	anchors, maps []string
+	// tagHandle is whether the document declared the !e! handle.
+	tagHandle bool
+	// ended is whether the last document was ended with "...", which
+	// directives for the next must follow.
+	ended bool
+}
+
+func doc(s *gen.State) []gen.File {
+	d := &ydoc{s: s}
+	if s.Chance(0.05) {
+		d.bomb()
+		return []gen.File{{Name: "input.yaml", Data: []byte(d.b.String())}}
+	}
+	for i := range gen.Pick(s, 1, 1, 1, 2, 3) {
+		d.document(i)
+	}
+	if s.Chance(0.05) {
+		d.b.WriteString(gen.Pick(s, "...\n", "--- \n", "# the end\n", "\n\n"))
+	}
+	return []gen.File{{Name: "input.yaml", Data: []byte(d.b.String())}}
+}
+
+func (d *ydoc) write(format string, args ...any) {
+	fmt.Fprintf(&d.b, format, args...)
+}
+
+func (d *ydoc) broken() bool { return d.s.Chance(badRate) }
+
+// document writes one document of the stream, the ith, with directives
+// and its start and end marked now and then, as a later one must be.
+func (d *ydoc) document(i int) {
+	s := d.s
+	d.anchors, d.maps = d.anchors[:0], d.maps[:0]
+	d.tagHandle = false
+	// Directives go before the first document, or after one ended, but
+	// for now and then, when they are read as its text.
+	directives := false
+	if i == 0 || d.ended || d.broken() {
+		if s.Chance(0.1) {
+			v := "1.1"
+			if d.broken() {
+				v = gen.Pick(s, "1.2", "1.3", "2.0", "1") // versions the decoder does not take
+			}
+			d.write("%%YAML %s\n", v)
+			directives = true
+		}
+		if s.Chance(0.1) {
+			d.write("%%TAG !e! %s\n", gen.Pick(s, "tag:example.com,2000:app/", "!foo-", "tag:yaml.org,2002:"))
+			d.tagHandle = true
+			directives = true
+		}
+	}
+	if s.Chance(0.05) {
+		d.b.WriteString("# a comment before it all\n")
+	}
+	if directives || i > 0 || s.Chance(0.2) {
+		d.b.WriteString("---")
+		if s.Chance(0.2) {
+			// A node on the line of the marker.
+			d.b.WriteString(" ")
+			d.scalar(false)
+			d.b.WriteString("\n")
+			d.end()
+			return
+		}
+		d.b.WriteString("\n")
+	}
+	depth := s.Depth(s.Limits.Literal, 5)
+	switch s.Intn(10) {
+	case 0:
+		d.sequence(0, depth)
+	case 1:
+		d.flow(0, depth)
+		d.b.WriteString("\n")
+	case 2:
+		d.scalar(false)
+		d.b.WriteString("\n")
+	default:
+		d.mapping(0, depth, false)
+	}
+	d.end()
+}
+
+// end ends a document now and then, as a later document's start would.
+func (d *ydoc) end() {
+	d.ended = d.s.Chance(0.3)
+	if d.ended {
+		d.b.WriteString("...\n")
+	}
+}
+
+// step returns how much deeper a collection is indented than its parent,
+// which each collection is free to choose.
+func (d *ydoc) step() int {
+	return gen.Pick(d.s, 2, 2, 2, 4, 1, 3, 8)
+}
+
+// indent writes n spaces of indentation, now and then with a tab, which
+// YAML does not allow: rarely, as every line has indentation.
+func (d *ydoc) indent(n int) {
+	if n > 0 && d.s.Chance(badRate/5) {
+		d.b.WriteString("\t")
+		n--
+	}
+	d.b.WriteString(strings.Repeat(" ", n))
+}
+
+// mapping writes a block mapping whose keys are indented by n, with
+// values depth deep at most. If compact, its first key goes where the
+// line is, after a dash.
+func (d *ydoc) mapping(n, depth int, compact bool) {
+	s := d.s
+	written := map[string]bool{}
+	for i := range s.Range(1, 5) {
+		if i > 0 || !compact {
+			if s.Chance(0.05) {
+				d.indent(n)
+				d.b.WriteString(gen.Pick(s, "# comment", "#", "#: not a key"))
+				d.b.WriteString("\n")
+			}
+			d.indent(n)
+		}
+		switch {
+		case len(d.maps) > 0 && s.Chance(0.1):
+			// A merge key, of one mapping or several, now and then of
+			// something else.
+			anchors := d.maps
+			if d.broken() {
+				anchors = d.anchors
+			}
+			if s.Chance(0.3) {
+				d.write("<<: [*%s, *%s]\n", gen.Pick(s, anchors...), gen.Pick(s, anchors...))
+			} else {
+				d.write("<<: *%s\n", gen.Pick(s, anchors...))
+			}
+			continue
+		case s.Chance(0.03):
+			// A complex key, behind "? ", which may run over lines.
+			d.b.WriteString("? ")
+			if s.Chance(0.5) {
+				d.block(n)
+			} else {
+				d.scalar(false)
+				d.b.WriteString("\n")
+			}
+			d.indent(n)
+			d.b.WriteString(":")
+			d.value(n, depth-1, false)
+			continue
+		}
+		k := gen.Pick(s, keys...)
+		for written[k] && !d.broken() {
+			k = d.s.Fresh("k")
+		}
+		written[k] = true
+		if s.Chance(0.05) {
+			name := d.anchor()
+			d.define(name)
+			k = "&" + name + " " + k
+		}
+		d.write("%s:", k)
+		d.value(n, depth-1, true)
+	}
+}
+
+// sequence writes a block sequence whose dashes are indented by n.
+func (d *ydoc) sequence(n, depth int) {
+	for range d.s.Range(1, 5) {
+		d.indent(n)
+		d.b.WriteString("-")
+		d.value(n, depth-1, false)
+	}
+}
+
+// value writes a node after a key or dash on a line indented by n,
+// through the end of its last line: on the line, or on the lines after
+// it if it is a block collection or scalar. A block sequence that is a
+// mapping value may sit at the mapping's own indentation, and a block
+// collection in a sequence may start on the dash's line.
+func (d *ydoc) value(n, depth int, inMapping bool) {
+	s := d.s
+	if depth <= 0 {
+		d.b.WriteString(" ")
+		d.inline()
+		d.b.WriteString("\n")
+		return
+	}
+	switch r := s.Intn(20); {
+	case r < 8:
+		d.b.WriteString(" ")
+		d.inline()
+		d.comment()
+		d.b.WriteString("\n")
+	case r < 10:
+		name := d.props()
+		d.b.WriteString(" ")
+		d.flow(n, depth)
+		d.define(name)
+		d.comment()
+		d.b.WriteString("\n")
+	case r < 11:
+		name := d.props()
+		d.b.WriteString(" ")
+		d.block(n)
+		d.define(name)
+	case r < 16:
+		name := d.props()
+		d.comment()
+		d.b.WriteString("\n")
+		m := n + d.step()
+		if d.broken() {
+			m = max(n-1, 0) // less indented than its parent
+		}
+		d.mapping(m, depth, false)
+		d.define(name)
+		if name != "" {
+			d.maps = append(d.maps, name)
+		}
+	case !inMapping && r < 17:
+		// A compact mapping, whose first key is on the dash's line and the
+		// others under it.
+		d.b.WriteString(" ")
+		d.mapping(n+2, depth, true)
+	case !inMapping && r < 18:
+		// A compact sequence, "- - x".
+		d.b.WriteString(" -")
+		d.value(n+2, depth-1, false)
+		for range s.Range(0, 2) {
+			d.indent(n + 2)
+			d.b.WriteString("-")
+			d.value(n+2, depth-1, false)
+		}
+	default:
+		name := d.props()
+		d.comment()
+		m := n + d.step()
+		if inMapping && s.Chance(0.4) {
+			m = n
+		}
+		d.b.WriteString("\n")
+		d.sequence(m, depth)
+		d.define(name)
+	}
+}
+
+// props writes the properties of a node, an anchor and a tag, or none,
+// each after a space, and returns the anchor's name or "".
+func (d *ydoc) props() string {
+	s := d.s
+	name := ""
+	if s.Chance(0.1) {
+		name = d.anchor()
+		d.write(" &%s", name)
+	}
+	if s.Chance(0.05) {
+		t := gen.Pick(s, tags...)
+		if t == "!e!thing" && !d.tagHandle && !d.broken() {
+			t = "!thing"
+		}
+		d.write(" %s", t)
+	}
+	return name
+}
+
+// anchor returns a name for an anchor, now and then one defined already,
+// which is then redefined.
+func (d *ydoc) anchor() string {
+	s := d.s
+	if len(d.anchors) > 0 && s.Chance(0.1) {
+		return gen.Pick(s, d.anchors...)
+	}
+	if d.broken() {
+		return s.Fresh("ä") // of a letter the decoder does not take
+	}
+	return s.Fresh(gen.Pick(s, "a", "anchor", "x-1", "A_b"))
+}
+
+// define notes the anchor name, if not "", for aliases to come, once its
+// node is written: an alias inside the node would refer to the node.
+func (d *ydoc) define(name string) {
+	if name != "" && !slices.Contains(d.anchors, name) {
+		d.anchors = append(d.anchors, name)
+	}
+}
+
+// comment writes a comment at the end of a line, or nothing.
+func (d *ydoc) comment() {
+	if d.s.Chance(0.05) {
+		d.b.WriteString(gen.Pick(d.s, " # note", " #", " #x: y"))
+	}
+}
+
+// inline writes a node that fits on the rest of a line: a scalar or an
+// alias, with properties.
+func (d *ydoc) inline() {
+	s := d.s
+	if len(d.anchors) > 0 && s.Chance(0.15) {
+		d.alias()
+		return
+	}
+	d.scalar(false)
+}
+
+// alias writes an alias of an anchor, now and then of one not defined.
+func (d *ydoc) alias() {
+	name := gen.Pick(d.s, d.anchors...)
+	if d.broken() {
+		name = "undefined"
+	}
+	d.write("*%s", name)
+}
+
+// scalar writes a scalar that fits on one line, with properties, in flow
+// context if flow.
+func (d *ydoc) scalar(flow bool) {
+	s := d.s
+	if s.Chance(0.1) {
+		name := d.anchor()
+		d.write("&%s ", name)
+		defer d.define(name)
+	}
+	if s.Chance(0.1) {
+		tag := gen.Pick(s, "!!int", "!!float", "!!bool", "!!null", "!!binary", "!!timestamp", "!!str")
+		v := gen.Pick(s, scalarTags[tag]...)
+		if d.broken() {
+			v = gen.Pick(s, "abc", "1.5.6", "[]", "!!") // a value that is not of the tag
+		}
+		d.write("%s %s", tag, v)
+		return
+	}
+	switch r := s.Intn(10); {
+	case r < 5:
+		if !flow && s.Chance(0.1) {
+			d.b.WriteString(gen.Pick(s, blockPlains...))
+			return
+		}
+		d.b.WriteString(gen.Pick(s, plains...))
+	case r < 8:
+		d.double()
+	default:
+		d.single()
+	}
+}
+
+// double writes a double-quoted scalar with escapes.
+func (d *ydoc) double() {
+	s := d.s
+	d.b.WriteString(`"`)
+	for range s.Range(0, 6) {
+		d.b.WriteString(gen.Pick(s, "a", " ", "text", "é", "日本", `\n`, `\t`, `\\`, `\"`, `\0`, `\a`, `\e`, `\ `,
+			`\_`, `\N`, `\L`, `\P`, `\x41`, `\xff`, `☺`, `\U0001F600`, `\u00e9`, "'", "#", ": ", "- "))
+	}
+	if d.broken() {
+		// An escape YAML does not have, or one of YAML 1.2 the decoder does
+		// not take, or of no character.
+		d.b.WriteString(gen.Pick(s, `\q`, `\x4`, `\U110000`, `\ud800`, `\/`, `\`))
+	}
+	if !d.broken() {
+		d.b.WriteString(`"`)
+	}
+}
+
+// single writes a single-quoted scalar, in which a quote is doubled.
+func (d *ydoc) single() {
+	s := d.s
+	d.b.WriteString("'")
+	for range s.Range(0, 5) {
+		d.b.WriteString(gen.Pick(s, "a", " ", "''", `\n`, "\"", "#", ": ", "[x]", "é"))
+	}
+	if !d.broken() {
+		d.b.WriteString("'")
+	}
+}
+
+// block writes a literal or folded scalar after the rest of a line
+// indented by n: its header, with chomping and indentation indicators,
+// and its lines.
+func (d *ydoc) block(n int) {
+	s := d.s
+	d.b.WriteString(gen.Pick(s, "|", ">"))
+	m := n + d.step()
+	explicit := false
+	switch s.Intn(4) {
+	case 0:
+		d.b.WriteString(gen.Pick(s, "-", "+"))
+	case 1:
+		explicit = true
+		// An explicit indentation indicator, for text whose first line
+		// begins with spaces.
+		k := s.Range(1, 9)
+		if d.broken() {
+			k = gen.Pick(s, 0, 10) // which does not exist
+		}
+		d.write("%d%s", k, gen.Pick(s, "", "-", "+"))
+		m = n + max(k, 1)
+	}
+	d.comment()
+	d.b.WriteString("\n")
+	for i := range s.Range(1, 4) {
+		if s.Chance(0.15) {
+			d.b.WriteString("\n") // a blank line
+		}
+		d.indent(m)
+		// A line more indented, kept as it is when folded, which without
+		// an indicator the first line cannot be, as it sets the
+		// indentation.
+		if (i > 0 || explicit) && s.Chance(0.15) {
+			d.b.WriteString("  ")
+		}
+		d.b.WriteString(gen.Pick(s, "text", "line with # no comment", "- not a dash", "key: not a key", `"not quoted"`, "a\ttab", "é"))
+		d.b.WriteString("\n")
+	}
+	if s.Chance(0.1) {
+		d.b.WriteString("\n\n") // trailing blank lines, kept by "+"
+	}
+}
+
+// flow writes a flow collection at most depth deep, on a line indented
+// by n, more than which the lines it runs on to must be.
+func (d *ydoc) flow(n, depth int) {
+	s := d.s
+	mapping := s.Chance(0.4)
+	open, close := "[", "]"
+	if mapping {
+		open, close = "{", "}"
+	}
+	d.b.WriteString(open)
+	next := "\n" + strings.Repeat(" ", n+2)
+	items := s.Range(0, 4)
+	for i := range items {
+		if i > 0 {
+			d.b.WriteString(gen.Pick(s, ", ", ",", " ,", ","+next))
+		}
+		if mapping || s.Chance(0.05) {
+			// A pair, which in a sequence is a mapping of one. A key but
+			// one after "? " is on one line.
+			if s.Chance(0.05) {
+				d.b.WriteString("? ")
+				d.flowNode(n, depth-1)
+			} else if len(d.anchors) > 0 && s.Chance(0.1) {
+				d.alias()
+				d.b.WriteString(" ")
+			} else {
+				d.scalar(true)
+			}
+			d.b.WriteString(gen.Pick(s, ": ", ": ", " : ", ":"+next))
+			d.flowNode(n, depth-1)
+			continue
+		}
+		d.flowNode(n, depth-1)
+	}
+	if items > 0 && s.Chance(0.1) {
+		d.b.WriteString(",")
+	}
+	if !d.broken() {
+		d.b.WriteString(close)
+	}
+}
+
+// flowNode writes a node in a flow collection on a line indented by n.
+func (d *ydoc) flowNode(n, depth int) {
+	s := d.s
+	switch {
+	case depth > 0 && s.Chance(0.3):
+		name := ""
+		if s.Chance(0.1) {
+			name = d.anchor()
+			d.write("&%s ", name)
+		}
+		d.flow(n, depth)
+		d.define(name)
+	case len(d.anchors) > 0 && s.Chance(0.1):
+		d.alias()
+	default:
+		d.scalar(true)
+	}
+}
+
+// bomb writes an alias bomb: a sequence of items, then levels of
+// sequences each of whose items is an alias of the level before, so that
+// the last level stands for the count to the power of the levels of
+// items.
+func (d *ydoc) bomb() {
+	s := d.s
+	count, levels := s.Range(2, 10), s.Range(2, 9)
+	flow := s.Chance(0.5)
+	item := func(i int) string {
+		if i == 0 {
+			return gen.Pick(s, `"lol"`, "lol", "1", "{a: b}")
+		}
+		return fmt.Sprintf("*l%d", i-1)
+	}
+	for i := range levels {
+		d.write("l%d: &l%d", i, i)
+		if flow {
+			items := make([]string, count)
+			for j := range items {
+				items[j] = item(i)
+			}
+			d.write(" [%s]\n", strings.Join(items, ", "))
+			continue
+		}
+		d.b.WriteString("\n")
+		for range count {
+			d.write("  - %s\n", item(i))
+		}
+	}
+	if s.Chance(0.3) {
+		// A key whose value is merged from the last level, or the mapping
+		// as a whole an alias of itself.
+		d.write("last: %s\n", gen.Pick(s, fmt.Sprintf("*l%d", levels-1), fmt.Sprintf("{<<: *l%d}", levels-1)))
+	}
+}
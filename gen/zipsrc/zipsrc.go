@@ -0,0 +1,534 @@
+// Package zipsrc generates ZIP seeds. It registers the "zip/..."
+// generators with package gen.
+//
+// "zip/archive" writes one archive: local headers and the data of a few
+// entries, stored or deflated, a central directory and its end record,
+// sometimes after a stub as a self-extracting archive has. Entries carry
+// the extra fields writers add for timestamps, Unix ids and zip64 sizes,
+// data descriptors, symlinks and Unix modes; names include directories,
+// duplicates, and paths that leave the root an extractor writes into.
+// Now and then an archive is zip64, with its sizes and offsets in extra
+// fields and a zip64 end record, is a bomb whose entries deflate to
+// megabytes of zeros or point the central directory at one local header
+// many times, or has a central directory that disagrees with its local
+// headers. A few break the format: sizes, offsets, counts and lengths
+// that are wrong or run past the end, truncated extra fields, data
+// descriptors with the wrong checksum, and archives cut short.
+package zipsrc
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func init() {
+	gen.Register(&gen.Generator{
+		Name: "zip/archive",
+		Doc:  "ZIP archives: stored and deflated entries, extra fields, data descriptors, symlinks, zip64, self-extracting stubs, path traversal and duplicate names, bombs by ratio and by overlapping entries, conflicting central and local headers, and bad sizes, offsets and counts",
+		Func: archive,
+	})
+}
+
+// badRate is the chance that a header field, an extra field or the end
+// record is wrong. An archive has a few dozen of them, so about one in
+// five has one.
+const badRate = 0.005
+
+// Signatures.
+const (
+	sigLocal      = 0x04034b50
+	sigCentral    = 0x02014b50
+	sigDescriptor = 0x08074b50
+	sigEnd        = 0x06054b50
+	sigEnd64      = 0x06064b50
+	sigLocator64  = 0x07064b50
+)
+
+// Methods.
+const (
+	methodStore   = 0
+	methodDeflate = 8
+)
+
+// Flags.
+const (
+	flagEncrypted  = 0x1
+	flagDescriptor = 0x8
+	flagUTF8       = 0x800
+)
+
+// Extra field ids.
+const (
+	extraZip64   = 0x0001
+	extraNTFS    = 0x000a
+	extraUnix    = 0x000d
+	extraExtTime = 0x5455
+	extraUnixIDs = 0x7875
+)
+
+// max32 and max16 are the values that say a field's real value is in
+// the zip64 extra field or end record.
+const (
+	max32 = 0xffffffff
+	max16 = 0xffff
+)
+
+var (
+	names = []string{
+		"README.md", "main.go", "src/main.go", "src/lib/util.go", "docs/", "docs/index.html", "a/b/c/d.txt",
+		"data.bin", "empty", "META-INF/MANIFEST.MF", "mimetype", "word/document.xml", "[Content_Types].xml",
+		"日本語.txt", "Straße/ü.txt", ".hidden", "dir/",
+	}
+	// escapes are names that leave the directory an archive is
+	// extracted into, or that one platform or another reads as
+	// something other than a plain relative path.
+	escapes = []string{
+		"../evil.txt", "../../../../etc/passwd", "/etc/passwd", "/", `..\..\windows\system32\evil.dll`, `C:\evil.txt`,
+		"C:/evil.txt", `\\server\share\evil`, "a/../../evil", "a/b/../../../evil", "./a", "a//b", "a/./b", ".", "..",
+		"...", "a\x00.txt", "CON", "nul.txt", "a:b", "com1.txt", "a/", "\xe9t\xe9.txt", "a\\b", " ", "a ",
+	}
+	// targets are what symlinks point to.
+	targets = []string{"../../../../etc/passwd", "/etc/shadow", "..", "docs", "a/b", "/", "src/../../.."}
+	texts   = []string{
+		"hello, world\n", "", "package main\n\nfunc main() {}\n", "Manifest-Version: 1.0\r\n\r\n", "application/epub+zip",
+		"<?xml version=\"1.0\"?><w:document/>", "\x00\x01\x02\x03\xff\xfe", strings.Repeat("abc", 100),
+	}
+	comments = []string{"", "", "", "a comment", "PK\x05\x06", "comment\x00with nul", strings.Repeat("c", 300)}
+)
+
+// An entry is what an archive records of one entry in its central
+// directory.
+type entry struct {
+	name, comment     string
+	flags, method     int
+	time, date        int
+	crc               uint32
+	csize, usize      uint64
+	offset            uint64
+	creator, external uint32
+	extra             []byte
+	zip64             bool
+}
+
+// A zgen writes one archive.
+type zgen struct {
+	s       *gen.State
+	b       []byte
+	entries []entry
+	// base is where offsets count from: the start of the archive's
+	// data, or the start of the stub before it.
+	base int
+	// zip64 is whether the archive is written as zip64 throughout.
+	zip64 bool
+}
+
+// archive writes one archive.
+func archive(s *gen.State) []gen.File {
+	z := &zgen{s: s, zip64: s.Chance(0.1)}
+	if s.Chance(0.1) {
+		z.b = append(z.b, gen.Pick(s, "MZ\x90\x00\x03\x00\x00\x00", "#!/bin/sh\nexec unzip \"$0\"\n", "\x7fELF\x02\x01\x01")...)
+		z.b = append(z.b, make([]byte, s.Intn(64))...)
+		if s.Chance(0.5) {
+			z.base = len(z.b)
+		}
+	}
+	for range s.Range(0, 6) {
+		z.entry()
+	}
+	if len(z.entries) > 0 && s.Chance(0.05) {
+		z.overlap()
+	}
+	if s.Chance(0.04) {
+		z.bomb()
+	}
+	if len(z.entries) > 1 && s.Chance(0.1) {
+		gen.Shuffle(s, z.entries)
+	}
+	z.directory()
+	if s.Chance(badRate * 4) {
+		z.b = z.b[:s.Intn(len(z.b))]
+	}
+	return []gen.File{{Name: "input.zip", Data: z.b}}
+}
+
+// entry writes a local header and data for a new entry.
+func (z *zgen) entry() {
+	s := z.s
+	e := entry{
+		name:     z.name(),
+		method:   gen.Pick(s, methodStore, methodDeflate, methodDeflate),
+		time:     s.Intn(24)<<11 | s.Intn(60)<<5 | s.Intn(30),
+		date:     (s.Range(1980, 2107)-1980)<<9 | s.Range(1, 12)<<5 | s.Range(1, 31),
+		creator:  gen.Pick[uint32](s, 0x0314, 0x031e, 0x0a3f, 0x0b14),
+		external: gen.Pick[uint32](s, 0, 0x20, 0100644<<16, 0100755<<16),
+		zip64:    z.zip64 || s.Chance(0.05),
+	}
+	var content []byte
+	switch {
+	case strings.HasSuffix(e.name, "/"):
+		e.method = methodStore
+		e.external = 040755<<16 | 0x10
+	case s.Chance(0.05):
+		e.external = 0120777 << 16
+		content = []byte(gen.Pick(s, targets...))
+	case s.Chance(0.1):
+		content = make([]byte, s.Intn(1024))
+		for i := range content {
+			content[i] = byte(s.Intn(256))
+		}
+	default:
+		content = []byte(gen.Pick(s, texts...))
+	}
+	if s.Chance(0.05) {
+		e.method = gen.Pick(s, 1, 6, 9, 12, 14, 19, 93, 95, 98, 99, 0xffff)
+	}
+	if s.Chance(0.3) {
+		e.flags |= flagDescriptor
+	}
+	if s.Chance(0.03) {
+		e.flags |= flagEncrypted
+	}
+	if s.Chance(0.5) && !isASCII(e.name) || s.Chance(0.05) {
+		e.flags |= flagUTF8
+	}
+	if s.Chance(0.2) {
+		e.comment = gen.Pick(s, comments...)
+	}
+	z.write(&e, content, gen.Pick(s, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression, flate.HuffmanOnly))
+}
+
+// write writes e's local header and data, with content compressed as
+// e's method has it at level, and adds e to the archive.
+func (z *zgen) write(e *entry, content []byte, level int) {
+	s := z.s
+	data := compress(e.method, level, content)
+	e.crc = crc32.ChecksumIEEE(content)
+	e.usize, e.csize = uint64(len(content)), uint64(len(data))
+	e.offset = uint64(len(z.b) - z.base)
+	z.header(e)
+	z.b = append(z.b, data...)
+	if e.flags&flagDescriptor != 0 {
+		if s.Chance(0.7) {
+			z.u32(sigDescriptor)
+		}
+		crc := e.crc
+		if s.Chance(badRate) {
+			crc ^= 1 << s.Intn(32)
+		}
+		z.u32(crc)
+		if e.zip64 {
+			z.u64(e.csize)
+			z.u64(e.usize)
+		} else {
+			z.u32(uint32(e.csize))
+			z.u32(uint32(e.usize))
+		}
+	}
+	e.extra = z.extras(e, false)
+	z.entries = append(z.entries, *e)
+}
+
+// header writes e's local header. Its fields agree with those the
+// central directory gives, but for a few that do not.
+func (z *zgen) header(e *entry) {
+	s := z.s
+	name, method, flags := e.name, e.method, e.flags
+	crc, csize, usize := e.crc, e.csize, e.usize
+	if s.Chance(badRate * 4) {
+		// The local header disagrees with the central directory, where
+		// readers that trust one over the other see different archives.
+		switch s.Intn(4) {
+		case 0:
+			name = gen.Pick(s, escapes...)
+		case 1:
+			method ^= methodDeflate
+		case 2:
+			csize, usize = usize, csize
+		case 3:
+			crc ^= 0xdeadbeef
+		}
+	}
+	if flags&flagDescriptor != 0 && s.Chance(0.8) {
+		crc, csize, usize = 0, 0, 0
+	}
+	z.u32(sigLocal)
+	z.u16(gen.Pick(s, 10, 20, 20, 45))
+	z.u16(flags)
+	z.u16(method)
+	z.u16(e.time)
+	z.u16(e.date)
+	z.u32(crc)
+	extra := z.extras(&entry{zip64: e.zip64, usize: usize, csize: csize}, true)
+	if e.zip64 {
+		z.u32(max32)
+		z.u32(max32)
+	} else {
+		z.u32(uint32(csize))
+		z.u32(uint32(usize))
+	}
+	z.u16(z.length(len(name)))
+	z.u16(z.length(len(extra)))
+	z.b = append(z.b, name...)
+	z.b = append(z.b, extra...)
+}
+
+// extras returns e's extra fields, those of its local header if local
+// and of the central directory's record for it otherwise.
+func (z *zgen) extras(e *entry, local bool) []byte {
+	s := z.s
+	var b []byte
+	if e.zip64 {
+		var f []byte
+		f = binary.LittleEndian.AppendUint64(f, e.usize)
+		f = binary.LittleEndian.AppendUint64(f, e.csize)
+		if !local {
+			f = binary.LittleEndian.AppendUint64(f, e.offset)
+		}
+		if s.Chance(badRate * 4) {
+			f = f[:s.Intn(len(f))]
+		}
+		b = extra(b, extraZip64, f)
+	}
+	if s.Chance(0.4) {
+		b = extra(b, extraExtTime, binary.LittleEndian.AppendUint32([]byte{1}, z.unixTime()))
+	}
+	if s.Chance(0.1) {
+		var f []byte
+		f = binary.LittleEndian.AppendUint32(f, 0)
+		f = binary.LittleEndian.AppendUint16(f, 1)
+		f = binary.LittleEndian.AppendUint16(f, 24)
+		for range 3 {
+			f = binary.LittleEndian.AppendUint64(f, gen.Pick[uint64](s, 0, 116444736000000000, 133000000000000000, 1<<63-1, 1<<64-1))
+		}
+		b = extra(b, extraNTFS, f)
+	}
+	if s.Chance(0.2) {
+		f := []byte{1, 4}
+		f = binary.LittleEndian.AppendUint32(f, gen.Pick[uint32](s, 0, 1000, 65534, max32))
+		f = append(f, 4)
+		f = binary.LittleEndian.AppendUint32(f, gen.Pick[uint32](s, 0, 1000, 65534, max32))
+		b = extra(b, extraUnixIDs, f)
+	}
+	if s.Chance(0.05) {
+		var f []byte
+		f = binary.LittleEndian.AppendUint32(f, z.unixTime())
+		f = binary.LittleEndian.AppendUint32(f, z.unixTime())
+		b = extra(b, extraUnix, f)
+	}
+	if s.Chance(0.05) {
+		b = extra(b, gen.Pick(s, 0x0007, 0x0017, 0x9901, 0xcafe, 0x6375, 0x7075, 0xffff), []byte(gen.Pick(s, "", "x", "\x01\x00\x00\x00abc")))
+	}
+	if s.Chance(badRate) {
+		// A field whose length runs past the end of the extra data, or
+		// one cut short before its length.
+		b = append(b, gen.Pick(s, "\x55\x54\xff\x00\x01", "\x01\x00\x10\x00\xff\xff", "\x0a")...)
+	}
+	return b
+}
+
+// extra appends a field with id and data to b.
+func extra(b []byte, id int, data []byte) []byte {
+	b = binary.LittleEndian.AppendUint16(b, uint16(id))
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(data)))
+	return append(b, data...)
+}
+
+// unixTime returns a modification time in seconds since 1970.
+func (z *zgen) unixTime() uint32 {
+	return gen.Pick[uint32](z.s, 0, 315532800, 1700000000, 1<<31-1, 1<<31, max32, uint32(z.s.Intn(1<<31)))
+}
+
+// overlap adds entries that share the local header and data of one
+// already written, some under the same name and some under others, so
+// that extracting the archive reads the same data many times, or whose
+// offset points into the middle of another entry.
+func (z *zgen) overlap() {
+	s := z.s
+	first := z.entries[s.Intn(len(z.entries))]
+	for i := range s.Range(1, 64) {
+		e := first
+		switch {
+		case s.Chance(0.1):
+			e.offset += uint64(s.Range(1, 64))
+		case s.Chance(0.8):
+			e.name = strings.TrimSuffix(e.name, "/") + "." + string(rune('a'+i%26)) + strings.Repeat("x", i/26)
+		}
+		e.extra = z.extras(&e, false)
+		z.entries = append(z.entries, e)
+	}
+}
+
+// bomb adds an entry whose data deflates to far more than it takes up:
+// megabytes of zeros, or of a short pattern, and now and then a central
+// directory that points at it many times over.
+func (z *zgen) bomb() {
+	s := z.s
+	pattern := gen.Pick(s, "\x00", "\x00", "A", "ab", "bomb ")
+	content := []byte(strings.Repeat(pattern, gen.Pick(s, 1<<16, 1<<20, 1<<22, 1<<24)/len(pattern)))
+	e := entry{
+		name:    gen.Pick(s, "bomb.bin", "zeros", "a/b/c/zeros.txt"),
+		method:  methodDeflate,
+		date:    0x21,
+		creator: 0x031e,
+		zip64:   z.zip64,
+	}
+	z.write(&e, content, flate.BestCompression)
+	if s.Chance(0.3) {
+		z.overlap()
+	}
+}
+
+// name returns a name for a new entry: a plain one, one already used,
+// or one that escapes.
+func (z *zgen) name() string {
+	s := z.s
+	switch {
+	case len(z.entries) > 0 && s.Chance(0.05):
+		return z.entries[s.Intn(len(z.entries))].name
+	case s.Chance(0.08):
+		return gen.Pick(s, escapes...)
+	case s.Chance(0.01):
+		return strings.Repeat("d/", s.Range(100, 2000)) + "f"
+	}
+	return gen.Pick(s, names...)
+}
+
+// directory writes the central directory and the records that end the
+// archive.
+func (z *zgen) directory() {
+	s := z.s
+	start := len(z.b)
+	for _, e := range z.entries {
+		z.central(e)
+	}
+	size, offset := uint64(len(z.b)-start), uint64(start-z.base)
+	count := uint64(len(z.entries))
+	if s.Chance(badRate * 4) {
+		switch s.Intn(4) {
+		case 0:
+			count = gen.Pick[uint64](s, 0, count+1, count-1, max16, 1<<62)
+		case 1:
+			offset = gen.Pick[uint64](s, 0, offset+1, offset-1, max32, 1<<63)
+		case 2:
+			size = gen.Pick[uint64](s, 0, size+1, size-1, max32, 1<<63)
+		case 3:
+			z.base = gen.Pick(s, 0, z.base+1)
+		}
+	}
+	if z.zip64 || count > max16-1 || s.Chance(0.02) {
+		end64 := len(z.b)
+		z.u32(sigEnd64)
+		z.u64(44)
+		z.u16(0x031e)
+		z.u16(45)
+		z.u32(0)
+		z.u32(0)
+		z.u64(count)
+		z.u64(count)
+		z.u64(size)
+		z.u64(offset)
+		z.u32(sigLocator64)
+		z.u32(0)
+		z.u64(uint64(end64 - z.base))
+		z.u32(gen.Pick[uint32](z.s, 1, 1, 1, 0, 2))
+		count, size, offset = max16, max32, max32
+	}
+	comment := gen.Pick(s, comments...)
+	z.u32(sigEnd)
+	z.u16(0)
+	z.u16(0)
+	z.u16(int(min(count, max16)))
+	z.u16(int(min(count, max16)))
+	z.u32(uint32(min(size, max32)))
+	z.u32(uint32(min(offset, max32)))
+	z.u16(z.length(len(comment)))
+	z.b = append(z.b, comment...)
+}
+
+// central writes e's record in the central directory.
+func (z *zgen) central(e entry) {
+	s := z.s
+	csize, usize := e.csize, e.usize
+	if s.Chance(badRate) {
+		csize = gen.Pick[uint64](s, 0, csize+1, max32-1, 1<<62)
+	}
+	if s.Chance(badRate) {
+		usize = gen.Pick[uint64](s, 0, usize+1, max32-1, 1<<40, 1<<62)
+	}
+	z.u32(sigCentral)
+	z.u16(int(e.creator))
+	z.u16(gen.Pick(s, 10, 20, 20, 45))
+	z.u16(e.flags)
+	z.u16(e.method)
+	z.u16(e.time)
+	z.u16(e.date)
+	z.u32(e.crc)
+	if e.zip64 {
+		z.u32(max32)
+		z.u32(max32)
+	} else {
+		z.u32(uint32(csize))
+		z.u32(uint32(usize))
+	}
+	z.u16(len(e.name))
+	z.u16(z.length(len(e.extra)))
+	z.u16(len(e.comment))
+	if s.Chance(badRate) {
+		z.u16(1)
+	} else {
+		z.u16(0)
+	}
+	z.u16(0)
+	z.u32(e.external)
+	if e.zip64 {
+		z.u32(max32)
+	} else {
+		z.u32(uint32(e.offset))
+	}
+	z.b = append(z.b, e.name...)
+	z.b = append(z.b, e.extra...)
+	z.b = append(z.b, e.comment...)
+}
+
+// length returns n, the length of a field about to be written, or
+// rarely one that is wrong.
+func (z *zgen) length(n int) int {
+	if z.s.Chance(badRate) {
+		return gen.Pick(z.s, 0, n+1, n-1, max16)
+	}
+	return n
+}
+
+func (z *zgen) u16(v int)    { z.b = binary.LittleEndian.AppendUint16(z.b, uint16(v)) }
+func (z *zgen) u32(v uint32) { z.b = binary.LittleEndian.AppendUint32(z.b, v) }
+func (z *zgen) u64(v uint64) { z.b = binary.LittleEndian.AppendUint64(z.b, v) }
+
+// compress returns content as method stores it, deflated at level. A
+// method other than deflate gets content as it is, as if compressed.
+func compress(method, level int, content []byte) []byte {
+	if method != methodDeflate {
+		return content
+	}
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, level)
+	if err != nil {
+		panic(err)
+	}
+	w.Write(content)
+	w.Close()
+	return b.Bytes()
+}
+
+func isASCII(s string) bool {
+	for i := range len(s) {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,210 @@
+// Package crash runs fuzz targets on single corpus entries and reads
+// the output of a failed run, as printed by `go test` or by a test
+// binary, reducing it to a signature under which crashes with the same
+// cause collect.
+package crash
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/geeknik/fuzzing/corpus/native"
+)
+
+// A Report is what a failed run said about itself.
+type Report struct {
+	// Kind is "panic", "hang" or "blowup" for the failures of package
+	// internal/harness and for uncaught panics and test timeouts,
+	// "fatal" for fatal runtime errors such as stack exhaustion, and
+	// "fail" for a target that reported an error.
+	Kind string
+
+	// Message is the panic value or error message, first line only.
+	Message string
+
+	// Frames are the innermost functions of the panicking goroutine's
+	// stack, leaving out the runtime, testing and harness machinery
+	// that recovered the panic.
+	Frames []string
+}
+
+// MaxFrames is how many frames Parse keeps.
+var MaxFrames = 5
+
+var (
+	// A failure line, as printed by t.Fatal ("    x_test.go:22: panic:
+	// ...") or by the runtime ("panic: ...", "fatal error: ...").
+	failLine  = regexp.MustCompile(`^(?:\S+\.go:\d+: )?(panic|hang|blowup|fatal error): (.*)$`)
+	errorLine = regexp.MustCompile(`^\S+_test\.go:\d+: (.*)$`)
+	funcLine  = regexp.MustCompile(`^([\w./*()\[\],~-]+)\(.*\)$`)
+	fileLine  = regexp.MustCompile(`^/?\S*\.(go|s):\d+`)
+
+	// The note the runtime adds to a panic that was recovered and
+	// panicked again, as testing does.
+	recovered = regexp.MustCompile(` \[recovered[^\]]*\]$`)
+)
+
+// Parse reads the output of a failed run. It returns nil if the output
+// holds no failure.
+func Parse(out []byte) *Report {
+	lines := strings.Split(string(out), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	var r *Report
+	for i, l := range lines {
+		if m := failLine.FindStringSubmatch(l); m != nil {
+			r = &Report{Kind: m[1], Message: recovered.ReplaceAllString(m[2], "")}
+			switch {
+			case r.Kind == "fatal error":
+				r.Kind = "fatal"
+			case r.Kind == "panic" && strings.HasPrefix(r.Message, "test timed out after"):
+				r.Kind = "hang"
+			}
+			r.Frames = frames(lines[i+1:])
+			return r
+		}
+		if r == nil {
+			if m := errorLine.FindStringSubmatch(l); m != nil {
+				r = &Report{Kind: "fail", Message: m[1]}
+			}
+		}
+	}
+	return r
+}
+
+// frames returns the first MaxFrames interesting functions of the first
+// goroutine stack in lines.
+func frames(lines []string) []string {
+	var fs []string
+	in := false
+	for i := 0; i+1 < len(lines) && len(fs) < MaxFrames; i++ {
+		l := lines[i]
+		if strings.HasPrefix(l, "goroutine ") {
+			if in {
+				break
+			}
+			in = true
+			continue
+		}
+		m := funcLine.FindStringSubmatch(l)
+		if !in || m == nil || !fileLine.MatchString(lines[i+1]) {
+			continue
+		}
+		if fn := trimArgs(l); !machinery(fn) {
+			fs = append(fs, fn)
+		}
+		i++
+	}
+	return fs
+}
+
+// trimArgs removes the argument list from a stack frame's function line.
+func trimArgs(l string) string {
+	return l[:strings.LastIndex(l, "(")]
+}
+
+// machinery reports whether fn belongs to the code that runs targets
+// and recovers their panics rather than to the code under test.
+func machinery(fn string) bool {
+	for _, p := range []string{"runtime.", "runtime/debug.", "testing.", "reflect.", "github.com/geeknik/fuzzing/internal/harness."} {
+		if strings.HasPrefix(fn, p) {
+			return true
+		}
+	}
+	return fn == "panic"
+}
+
+var (
+	hexes  = regexp.MustCompile(`0x[0-9a-fA-F]+`)
+	digits = regexp.MustCompile(`[0-9]+`)
+)
+
+// Signature returns a short hash identifying r's cause: its kind, its
+// message with numbers blanked out and its frames. Crashes in the same
+// place for the same reason share a signature even when they differ in
+// index values, sizes and addresses.
+func (r *Report) Signature() string {
+	msg := hexes.ReplaceAllString(r.Message, "0x_")
+	msg = digits.ReplaceAllString(msg, "_")
+	if len(msg) > 200 {
+		msg = msg[:200]
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", r.Kind, msg)
+	for _, f := range r.Frames {
+		fmt.Fprintln(h, f)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:12]
+}
+
+func (r *Report) String() string {
+	s := r.Kind + ": " + r.Message
+	for _, f := range r.Frames {
+		s += "\n\t" + f
+	}
+	return s
+}
+
+// Build compiles the test binary of the package pkg, a path or pattern
+// as the go command takes it, to bin.
+func Build(ctx context.Context, pkg, bin string) error {
+	if out, err := exec.CommandContext(ctx, "go", "test", "-c", "-o", bin, pkg).CombinedOutput(); err != nil {
+		return fmt.Errorf("building %s: %v\n%s", pkg, err, out)
+	}
+	return nil
+}
+
+// Run runs the fuzz target of the test binary bin on one "go test fuzz
+// v1" entry and returns the report of its failure, or nil if it passed,
+// and its output. A run that takes longer than timeout is killed and
+// reported as a hang.
+//
+// The test binary reads its corpus from testdata/fuzz/FuzzXxx in its
+// working directory, so each run gets a scratch directory holding just
+// the entry, and -test.run selects the entry over the seeds the target
+// adds itself.
+func Run(ctx context.Context, bin, target string, entry []byte, timeout time.Duration) (*Report, []byte, error) {
+	dir, err := os.MkdirTemp("", "crash")
+	if err != nil {
+		return nil, nil, err
+	}
+	defer os.RemoveAll(dir)
+	name := native.Name(entry)
+	corpusDir := filepath.Join(dir, "testdata", "fuzz", target)
+	if err := os.MkdirAll(corpusDir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(filepath.Join(corpusDir, name), entry, 0o644); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, bin, "-test.run=^"+target+"$/^"+name+"$", "-test.timeout="+timeout.String())
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return &Report{Kind: "hang", Message: fmt.Sprintf("killed after %v", timeout)}, out, nil
+	}
+	var exit *exec.ExitError
+	switch {
+	case err == nil:
+		return nil, out, nil
+	case !errors.As(err, &exit):
+		return nil, out, err
+	}
+	r := Parse(out)
+	if r == nil {
+		r = &Report{Kind: "fail", Message: exit.String()}
+	}
+	return r, out, nil
+}
@@ -0,0 +1,89 @@
+package crash
+
+import (
+	"fmt"
+	"testing"
+)
+
+// panicOutput is a panic as a test binary prints it, with the values
+// that differ between runs left to fill in: the goroutine ID, argument
+// and frame addresses, and the index out of range.
+const panicOutput = `--- FAIL: FuzzDecode (0.00s)
+    --- FAIL: FuzzDecode/seed#0 (0.00s)
+panic: runtime error: index out of range [%[2]d] with length 3 [recovered]
+	panic: runtime error: index out of range [%[2]d] with length 3
+
+goroutine %[1]d [running]:
+testing.tRunner.func1.2({0x%[3]x, 0xc000%[3]x})
+	/usr/local/go/src/testing/testing.go:1734 +0x%[3]x
+panic({0x%[3]x?, 0xc000%[3]x?})
+	/usr/local/go/src/runtime/panic.go:791 +0x132
+github.com/geeknik/fuzzing/fuzz/gob.(*decoder).field(0xc000%[3]x, {0xc000%[3]x, 0x3, 0x3})
+	/src/fuzz/gob/gob.go:88 +0x%[3]x
+github.com/geeknik/fuzzing/fuzz/gob.CheckDecode({0xc000%[3]x?, 0x%[2]x?, 0x8?})
+	/src/fuzz/gob/gob.go:42 +0x6b
+github.com/geeknik/fuzzing/fuzz/gob.FuzzDecode.func1(0xc000%[3]x?, {0xc000%[3]x, 0x%[2]x, 0x8})
+	/src/fuzz/gob/gob_test.go:17 +0x25
+reflect.Value.call({0x%[3]x?, 0xc000%[3]x?, 0x13?}, {0x%[3]x, 0x4}, {0xc000%[3]x, 0x2, 0x2?})
+	/usr/local/go/src/reflect/value.go:584 +0xca6
+testing.tRunner(0xc000%[3]x, 0xc000%[3]x)
+	/usr/local/go/src/testing/testing.go:1792 +0xf4
+created by testing.(*T).Run in goroutine %[4]d
+	/usr/local/go/src/testing/testing.go:1851 +0x413
+
+goroutine %[4]d [chan receive]:
+testing.(*T).Run(0xc000%[3]x, {0x%[3]x?, 0x%[3]x?}, 0xc000%[3]x)
+	/usr/local/go/src/testing/testing.go:1859 +0x431
+`
+
+func TestSignatureStable(t *testing.T) {
+	runs := []struct {
+		goroutine, index, addr, parent int
+	}{
+		{7, 5, 0x1a2b3c, 1},
+		{19, 5, 0x4d5e6f, 1},
+		{7, 12, 0x1a2b3c, 6},
+		{1234, 99, 0xdeadbeef, 42},
+	}
+	var want string
+	for i, r := range runs {
+		out := fmt.Sprintf(panicOutput, r.goroutine, r.index, r.addr, r.parent)
+		rep := Parse([]byte(out))
+		if rep == nil {
+			t.Fatalf("run %d: Parse found no failure in\n%s", i, out)
+		}
+		if rep.Kind != "panic" {
+			t.Errorf("run %d: Kind = %q, want panic", i, rep.Kind)
+		}
+		if i == 0 {
+			want = rep.Signature()
+			wantFrames := []string{
+				"github.com/geeknik/fuzzing/fuzz/gob.(*decoder).field",
+				"github.com/geeknik/fuzzing/fuzz/gob.CheckDecode",
+				"github.com/geeknik/fuzzing/fuzz/gob.FuzzDecode.func1",
+			}
+			if fmt.Sprint(rep.Frames) != fmt.Sprint(wantFrames) {
+				t.Errorf("Frames = %q, want %q", rep.Frames, wantFrames)
+			}
+			continue
+		}
+		if got := rep.Signature(); got != want {
+			t.Errorf("run %d: Signature = %s, want %s as for run 0\n%v", i, got, want, rep)
+		}
+	}
+}
+
+func TestSignatureDiffers(t *testing.T) {
+	base := &Report{Kind: "panic", Message: "index out of range [5] with length 3", Frames: []string{"p.f", "p.g"}}
+	others := []*Report{
+		{Kind: "hang", Message: base.Message, Frames: base.Frames},
+		{Kind: "panic", Message: "nil map write", Frames: base.Frames},
+		{Kind: "panic", Message: base.Message, Frames: []string{"p.h", "p.g"}},
+		{Kind: "panic", Message: base.Message, Frames: []string{"p.f"}},
+	}
+	for _, o := range others {
+		if o.Signature() == base.Signature() {
+			t.Errorf("%v\nhas the signature of\n%v", o, base)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package findings writes the results of the runners in cmd as files
+// other programs read: plain JSON, an array with an object per finding,
+// for crash metadata, and SARIF 2.1.0 for the code scanning dashboards
+// and issue trackers that take it.
+//
+// Each runner takes -json and -sarif flags naming the files to write and
+// calls Write with what it found.
+package findings
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A Finding is one failure a runner reports.
+type Finding struct {
+	// Kind is the class of failure: for crashes the kind of
+	// internal/crash ("panic", "hang", ...), for other runners a word of
+	// their own such as "unexpected-race" or "divergence". It is the
+	// SARIF rule.
+	Kind string `json:"kind"`
+
+	// Message says what happened, on one line.
+	Message string `json:"message"`
+
+	// Signature, if set, identifies the cause, so that findings with the
+	// same cause are recognized as one across runs.
+	Signature string `json:"signature,omitempty"`
+
+	// Input is the path of the file that caused the failure, and Line,
+	// if not 0, the line of it the failure points at.
+	Input string `json:"input"`
+	Line  int    `json:"line,omitempty"`
+
+	// Minimized is the path of a smaller input failing the same way, if
+	// the runner wrote one.
+	Minimized string `json:"minimized,omitempty"`
+
+	// Origin names the generator and seed that produced the input, if
+	// its header says.
+	Origin *Origin `json:"origin,omitempty"`
+
+	// Count is how many inputs failed this way, for runners that bucket
+	// them, and Frames the innermost functions of the failing stack.
+	Count  int      `json:"count,omitempty"`
+	Frames []string `json:"frames,omitempty"`
+
+	// Output is the start of what the failing run printed.
+	Output string `json:"output,omitempty"`
+}
+
+// An Origin is where a generated input came from: the generator and the
+// seed of its State (see gen.Origin).
+type Origin struct {
+	Generator string `json:"generator"`
+	Seed      uint64 `json:"seed,string"` // a string, as JSON numbers lose bits past 2^53
+}
+
+// OriginOf returns the origin of the first of srcs that has a seedgen
+// header, or nil if none has.
+func OriginOf(srcs ...[]byte) *Origin {
+	for _, src := range srcs {
+		if g, seed, ok := gen.Origin(src); ok {
+			return &Origin{Generator: g, Seed: seed}
+		}
+	}
+	return nil
+}
+
+// Write writes fs as JSON to jsonFile and as SARIF to sarifFile, each
+// only if named. tool names the runner in the SARIF output.
+func Write(jsonFile, sarifFile, tool string, fs []Finding) error {
+	if jsonFile != "" {
+		if err := writeFile(jsonFile, func(w io.Writer) error { return WriteJSON(w, fs) }); err != nil {
+			return err
+		}
+	}
+	if sarifFile != "" {
+		if err := writeFile(sarifFile, func(w io.Writer) error { return WriteSARIF(w, tool, fs) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// WriteJSON writes fs to w as a JSON array.
+func WriteJSON(w io.Writer, fs []Finding) error {
+	if fs == nil {
+		fs = []Finding{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(fs)
+}
+
+// The subset of SARIF 2.1.0 written by WriteSARIF.
+type (
+	sarifLog struct {
+		Schema  string     `json:"$schema"`
+		Version string     `json:"version"`
+		Runs    []sarifRun `json:"runs"`
+	}
+	sarifRun struct {
+		Tool    sarifTool     `json:"tool"`
+		Results []sarifResult `json:"results"`
+	}
+	sarifTool struct {
+		Driver sarifDriver `json:"driver"`
+	}
+	sarifDriver struct {
+		Name           string      `json:"name"`
+		InformationURI string      `json:"informationUri"`
+		Rules          []sarifRule `json:"rules"`
+	}
+	sarifRule struct {
+		ID               string       `json:"id"`
+		ShortDescription sarifMessage `json:"shortDescription"`
+	}
+	sarifMessage struct {
+		Text string `json:"text"`
+	}
+	sarifResult struct {
+		RuleID              string            `json:"ruleId"`
+		RuleIndex           int               `json:"ruleIndex"`
+		Level               string            `json:"level"`
+		Message             sarifMessage      `json:"message"`
+		Locations           []sarifLocation   `json:"locations"`
+		PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+		Stacks              []sarifStack      `json:"stacks,omitempty"`
+		Properties          map[string]any    `json:"properties,omitempty"`
+	}
+	sarifLocation struct {
+		PhysicalLocation *sarifPhysical `json:"physicalLocation,omitempty"`
+		LogicalLocations []sarifLogical `json:"logicalLocations,omitempty"`
+	}
+	sarifPhysical struct {
+		ArtifactLocation sarifArtifact `json:"artifactLocation"`
+		Region           *sarifRegion  `json:"region,omitempty"`
+	}
+	sarifArtifact struct {
+		URI string `json:"uri"`
+	}
+	sarifRegion struct {
+		StartLine int `json:"startLine"`
+	}
+	sarifLogical struct {
+		FullyQualifiedName string `json:"fullyQualifiedName"`
+	}
+	sarifStack struct {
+		Frames []sarifFrame `json:"frames"`
+	}
+	sarifFrame struct {
+		Location sarifLocation `json:"location"`
+	}
+)
+
+// WriteSARIF writes fs to w as a SARIF log of one run of tool, with a
+// rule for each kind of finding.
+func WriteSARIF(w io.Writer, tool string, fs []Finding) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           tool,
+			InformationURI: "https://github.com/geeknik/fuzzing",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	var kinds []string
+	for _, f := range fs {
+		if !slices.Contains(kinds, f.Kind) {
+			kinds = append(kinds, f.Kind)
+		}
+	}
+	slices.Sort(kinds)
+	for _, k := range kinds {
+		run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: k, ShortDescription: sarifMessage{Text: k}})
+	}
+	for _, f := range fs {
+		r := sarifResult{
+			RuleID:    f.Kind,
+			RuleIndex: slices.Index(kinds, f.Kind),
+			Level:     "error",
+			Message:   sarifMessage{Text: f.Message},
+			Locations: []sarifLocation{{PhysicalLocation: physical(f.Input, f.Line)}},
+		}
+		if f.Signature != "" {
+			r.PartialFingerprints = map[string]string{"signature/v1": f.Signature}
+		}
+		if len(f.Frames) > 0 {
+			var st sarifStack
+			for _, fn := range f.Frames {
+				st.Frames = append(st.Frames, sarifFrame{Location: sarifLocation{LogicalLocations: []sarifLogical{{FullyQualifiedName: fn}}}})
+			}
+			r.Stacks = []sarifStack{st}
+		}
+		props := map[string]any{}
+		if f.Minimized != "" {
+			props["minimized"] = uri(f.Minimized)
+		}
+		if f.Origin != nil {
+			props["generator"] = f.Origin.Generator
+			props["seed"] = strconv.FormatUint(f.Origin.Seed, 10)
+		}
+		if f.Count > 0 {
+			props["count"] = f.Count
+		}
+		if len(props) > 0 {
+			r.Properties = props
+		}
+		run.Results = append(run.Results, r)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+func physical(path string, line int) *sarifPhysical {
+	p := &sarifPhysical{ArtifactLocation: sarifArtifact{URI: uri(path)}}
+	if line > 0 {
+		p.Region = &sarifRegion{StartLine: line}
+	}
+	return p
+}
+
+// uri returns path as a URI reference: relative for a relative path and
+// a file URI for an absolute one.
+func uri(path string) string {
+	u := &url.URL{Path: filepath.ToSlash(path)}
+	if filepath.IsAbs(path) {
+		u.Scheme = "file"
+		if !strings.HasPrefix(u.Path, "/") {
+			u.Path = "/" + u.Path // a Windows drive letter
+		}
+	}
+	return u.String()
+}
@@ -0,0 +1,88 @@
+package harness
+
+import (
+	"fmt"
+	"runtime/metrics"
+	"strings"
+	"time"
+)
+
+// A Cost is an amount of time and memory.
+type Cost struct {
+	Time   time.Duration
+	Memory uint64 // bytes
+}
+
+// Plus returns c plus n times per.
+func (c Cost) Plus(n int, per Cost) Cost {
+	return Cost{
+		Time:   c.Time + time.Duration(n)*per.Time,
+		Memory: c.Memory + uint64(n)*per.Memory,
+	}
+}
+
+// A Budget is what checking an input may cost: Base, plus Per for each
+// unit of the input's size. The unit is a byte unless the target counts
+// something else, such as syntax nodes.
+type Budget struct {
+	Base, Per Cost
+}
+
+// For returns the budget for an input of size n.
+func (b Budget) For(n int) Cost {
+	return b.Base.Plus(n, b.Per)
+}
+
+// HangFactor is how far past its time budget a check may run before it
+// is abandoned as a hang.
+const HangFactor = 4
+
+// Allocated returns the bytes allocated on the heap by the process so
+// far.
+func Allocated() uint64 {
+	s := []metrics.Sample{{Name: "/gc/heap/allocs:bytes"}}
+	metrics.Read(s)
+	return s[0].Value.Uint64()
+}
+
+// Spend calls fn under Run, giving up on it as a Hang at HangFactor
+// times limit.Time, and returns how long it took and how many bytes it
+// allocated.
+func Spend(limit Cost, fn func()) (Cost, error) {
+	var spent Cost
+	err := Run(HangFactor*limit.Time, func() error {
+		before := Allocated()
+		start := time.Now()
+		fn()
+		spent = Cost{Time: time.Since(start), Memory: Allocated() - before}
+		return nil
+	})
+	return spent, err
+}
+
+// Measure calls fn as Spend does and returns a Failure of kind Blowup if
+// it took more time or allocated more memory than limit. what says what
+// fn did, for the failure's message, e.g. "decoding 12 bytes".
+func Measure(what string, limit Cost, fn func()) error {
+	spent, err := Spend(limit, fn)
+	if err != nil {
+		return err
+	}
+	return Over(what, spent, limit)
+}
+
+// Over returns a Failure of kind Blowup if spent exceeds limit, in time
+// or memory, and nil otherwise.
+func Over(what string, spent, limit Cost) error {
+	var excess []string
+	if spent.Time > limit.Time {
+		excess = append(excess, fmt.Sprintf("took %v (budget %v)", spent.Time, limit.Time))
+	}
+	if spent.Memory > limit.Memory {
+		excess = append(excess, fmt.Sprintf("used %d MiB (budget %d MiB)", spent.Memory>>20, limit.Memory>>20))
+	}
+	if len(excess) == 0 {
+		return nil
+	}
+	return &Failure{Kind: Blowup, Value: what + " " + strings.Join(excess, " and ")}
+}
@@ -0,0 +1,82 @@
+// Package harness runs fuzz target bodies under a time budget and turns
+// panics, hangs and blowups into distinguishable errors. Budget and
+// Measure weigh what a body cost against what its input warrants.
+package harness
+
+import (
+	"fmt"
+	"runtime/debug"
+	"time"
+)
+
+// A Kind classifies a Failure.
+type Kind int
+
+const (
+	Panic  Kind = iota + 1 // the target panicked
+	Hang                   // the target did not return within its budget
+	Blowup                 // the target returned but cost far more than its input warrants
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Panic:
+		return "panic"
+	case Hang:
+		return "hang"
+	case Blowup:
+		return "blowup"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// A Failure is a crash of the code under test, as opposed to an ordinary
+// error it reported.
+type Failure struct {
+	Kind  Kind
+	Value any           // recovered value, for panics; what was exceeded, for blowups
+	Stack []byte        // stack of the panicking goroutine
+	After time.Duration // time waited before giving up, for hangs
+}
+
+func (f *Failure) Error() string {
+	switch f.Kind {
+	case Panic:
+		return fmt.Sprintf("panic: %v\n\n%s", f.Value, f.Stack)
+	case Hang:
+		return fmt.Sprintf("hang: no result after %v", f.After)
+	case Blowup:
+		return fmt.Sprintf("blowup: %v", f.Value)
+	}
+	return f.Kind.String()
+}
+
+// Run calls fn on a new goroutine and waits for it for at most timeout; a
+// timeout of zero waits forever. A panic in fn is returned as a *Failure of
+// kind Panic, and exceeding the timeout as a *Failure of kind Hang.
+// Otherwise Run returns fn's result.
+//
+// A hung fn cannot be stopped: its goroutine keeps running after Run
+// returns. Callers should treat a Hang as fatal for the process.
+func Run(timeout time.Duration, fn func() error) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				done <- &Failure{Kind: Panic, Value: v, Stack: debug.Stack()}
+			}
+		}()
+		done <- fn()
+	}()
+	if timeout <= 0 {
+		return <-done
+	}
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-t.C:
+		return &Failure{Kind: Hang, After: timeout}
+	}
+}
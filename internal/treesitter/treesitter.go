@@ -0,0 +1,189 @@
+// Package treesitter checks the trees github.com/smacker/go-tree-sitter
+// parses, for the fuzz targets of the languages it has grammars of.
+package treesitter
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// A Grammar is a language and what its trees leave out.
+type Grammar struct {
+	Language *sitter.Language
+	// Leaves are the types of nodes whose children do not cover them,
+	// such as strings whose text between interpolations is in no token.
+	Leaves []string
+	// Skipped reports whether tree-sitter skips r between tokens.
+	Skipped func(r rune) bool
+}
+
+// Check parses src, which may not parse, and checks that its nodes lie
+// inside their parents, in order. If src parses without error, it also
+// checks that src is covered by its tokens but for what g skips between
+// them; and that edited a byte at a time, src parses incrementally to the
+// tree it parses to from scratch, and back to the tree it was.
+func Check(g *Grammar, src []byte) error {
+	p := sitter.NewParser()
+	defer p.Close()
+	p.SetLanguage(g.Language)
+	tree, err := p.ParseCtx(context.Background(), nil, src)
+	if err != nil {
+		return nil
+	}
+	defer tree.Close()
+	root := tree.RootNode()
+	if err := checkNode(root, src); err != nil {
+		return err
+	}
+	if root.HasError() {
+		return nil
+	}
+	if err := g.checkGaps(root, src); err != nil {
+		return err
+	}
+	want := dump(root)
+	// The edits are at fixed places, for the fuzzer to bring constructs
+	// to.
+	for _, i := range []int{len(src) / 3, len(src) / 2, 2 * len(src) / 3} {
+		if err := checkEdit(p, tree, src, i, want); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dump returns the type and bytes of n and all below it.
+func dump(n *sitter.Node) string {
+	var b strings.Builder
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		fmt.Fprintf(&b, "(%s %d %d", n.Type(), n.StartByte(), n.EndByte())
+		for i := range int(n.ChildCount()) {
+			b.WriteByte(' ')
+			walk(n.Child(i))
+		}
+		b.WriteByte(')')
+	}
+	walk(n)
+	return b.String()
+}
+
+// checkNode checks that n lies in src, and each node below it in its
+// parent, after its previous sibling.
+func checkNode(n *sitter.Node, src []byte) error {
+	start, end := n.StartByte(), n.EndByte()
+	if start > end || int(end) > len(src) {
+		return fmt.Errorf("%s node spans %d to %d of %d bytes", n.Type(), start, end, len(src))
+	}
+	pos := start
+	for i := range int(n.ChildCount()) {
+		c := n.Child(i)
+		if c.StartByte() < pos || c.EndByte() > end {
+			return fmt.Errorf("%s node at %d to %d has a child %s at %d to %d", n.Type(), start, end, c.Type(), c.StartByte(), c.EndByte())
+		}
+		pos = c.EndByte()
+		if err := checkNode(c, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkGaps checks that the tokens below root, and the leaves of g, cover
+// src but for what g skips between them.
+func (g *Grammar) checkGaps(root *sitter.Node, src []byte) error {
+	var pos uint32
+	var walk func(n *sitter.Node) error
+	walk = func(n *sitter.Node) error {
+		if n.ChildCount() == 0 || g.leaf(n) {
+			if gap := src[pos:max(pos, n.StartByte())]; strings.TrimFunc(string(gap), g.Skipped) != "" {
+				return fmt.Errorf("%q at %d is in no token", gap, pos)
+			}
+			pos = max(pos, n.EndByte())
+			return nil
+		}
+		for i := range int(n.ChildCount()) {
+			if err := walk(n.Child(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return walk(root)
+}
+
+func (g *Grammar) leaf(n *sitter.Node) bool {
+	for _, t := range g.Leaves {
+		if n.Type() == t {
+			return true
+		}
+	}
+	return false
+}
+
+// checkEdit changes the byte of src at i, or the first after it that is
+// ASCII and not a newline, and checks that tree, the tree of src whose
+// dump is want, edited, parses incrementally as the new src parses from
+// scratch, if it parses without error; and edited back, as src.
+//
+// Known: go-tree-sitter passes the old end point of an edit to
+// tree-sitter as the new one too. An edit that replaces one byte with
+// another moves no point.
+func checkEdit(p *sitter.Parser, tree *sitter.Tree, src []byte, i int, want string) error {
+	for i < len(src) && (src[i] == '\n' || src[i] >= utf8.RuneSelf) {
+		i++
+	}
+	if i >= len(src) {
+		return nil
+	}
+	c := src[i]
+	var r byte
+	switch {
+	case '0' <= c && c <= '8', 'a' <= c && c < 'z', 'A' <= c && c < 'Z':
+		r = c + 1 // likely to leave the file as it parsed
+	default:
+		r = '('
+	}
+	edited := bytes.Clone(src)
+	edited[i] = r
+	row := bytes.Count(src[:i], []byte("\n"))
+	col := i - (bytes.LastIndexByte(src[:i], '\n') + 1)
+	edit := sitter.EditInput{
+		StartIndex:  uint32(i),
+		OldEndIndex: uint32(i + 1),
+		NewEndIndex: uint32(i + 1),
+		StartPoint:  sitter.Point{Row: uint32(row), Column: uint32(col)},
+		OldEndPoint: sitter.Point{Row: uint32(row), Column: uint32(col + 1)},
+		NewEndPoint: sitter.Point{Row: uint32(row), Column: uint32(col + 1)},
+	}
+	t := tree.Copy()
+	t.Edit(edit)
+	inc, err := p.ParseCtx(context.Background(), t, edited)
+	if err != nil {
+		return nil
+	}
+	fresh, err := p.ParseCtx(context.Background(), nil, edited)
+	if err != nil {
+		return nil
+	}
+	if !fresh.RootNode().HasError() {
+		if got, w := dump(inc.RootNode()), dump(fresh.RootNode()); got != w {
+			return fmt.Errorf("byte %d changed to %q, incremental:\n%s\nfresh:\n%s", i, r, got, w)
+		}
+	}
+	edited[i] = c
+	inc.Edit(edit)
+	back, err := p.ParseCtx(context.Background(), inc, edited)
+	if err != nil {
+		return nil
+	}
+	if got := dump(back.RootNode()); got != want {
+		return fmt.Errorf("byte %d changed to %q and back, incremental:\n%s\nfresh:\n%s", i, r, got, want)
+	}
+	return nil
+}
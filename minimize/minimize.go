@@ -0,0 +1,318 @@
+// Package minimize reduces Go source files with delta debugging.
+//
+// A Reducer repeatedly collects removable units from the current source —
+// top-level declarations, then statements, then subexpressions — and runs
+// ddmin over them, keeping only the units needed for the Interesting
+// predicate to keep holding. Units are applied as text edits at AST node
+// boundaries, and the result is gofmt'ed at the end if it still parses.
+// Once the source no longer parses, reduction falls back to whole lines.
+package minimize
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+)
+
+// A Reducer minimizes inputs against a predicate.
+type Reducer struct {
+	// Interesting reports whether a candidate still exhibits the
+	// behavior being preserved, e.g. "the compiler panics".
+	Interesting func(src []byte) bool
+
+	// Logf, if non-nil, receives progress messages.
+	Logf func(format string, args ...any)
+
+	cache map[[sha256.Size]byte]bool
+	tests int
+}
+
+// Tests returns the number of times the predicate has been run.
+func (r *Reducer) Tests() int {
+	return r.tests
+}
+
+// Reduce returns the smallest variant of src it finds for which
+// Interesting holds. src itself must be interesting.
+func (r *Reducer) Reduce(src []byte) []byte {
+	if r.cache == nil {
+		r.cache = map[[sha256.Size]byte]bool{}
+	}
+	for {
+		before := len(src)
+		for _, lv := range levels {
+			units, ok := collect(src, lv)
+			name := lv.String()
+			if !ok {
+				units, name = lineUnits(src), "lines"
+			}
+			if len(units) > 0 {
+				keep := r.ddmin(src, units)
+				src = apply(src, units, keep)
+				r.logf("%s: %d units, kept %d, %d bytes", name, len(units), len(keep), len(src))
+			}
+			if !ok {
+				break
+			}
+		}
+		if len(src) >= before {
+			return r.tidy(src)
+		}
+	}
+}
+
+// tidy gofmts src, which removes the blank lines and stray commas left
+// by deletions, as long as the result is still interesting.
+func (r *Reducer) tidy(src []byte) []byte {
+	if out, err := format.Source(src); err == nil && r.test(out) {
+		return out
+	}
+	return src
+}
+
+func (r *Reducer) logf(format string, args ...any) {
+	if r.Logf != nil {
+		r.Logf(format, args...)
+	}
+}
+
+func (r *Reducer) test(src []byte) bool {
+	sum := sha256.Sum256(src)
+	if v, ok := r.cache[sum]; ok {
+		return v
+	}
+	r.tests++
+	v := r.Interesting(src)
+	r.cache[sum] = v
+	return v
+}
+
+// ddmin returns the indexes of a 1-minimal subset of units that must stay
+// unapplied for the predicate to hold.
+func (r *Reducer) ddmin(src []byte, units []edit) []int {
+	keep := make([]int, len(units))
+	for i := range keep {
+		keep[i] = i
+	}
+	try := func(k []int) bool { return r.test(apply(src, units, k)) }
+	if try(nil) {
+		return nil
+	}
+	n := 2
+	for len(keep) >= 2 {
+		chunks := split(keep, n)
+		reduced := false
+		for _, c := range chunks {
+			if try(c) {
+				keep, n, reduced = c, 2, true
+				break
+			}
+		}
+		if !reduced {
+			for i := range chunks {
+				rest := complement(chunks, i)
+				if try(rest) {
+					keep, n, reduced = rest, max(n-1, 2), true
+					break
+				}
+			}
+		}
+		if !reduced {
+			if n >= len(keep) {
+				break
+			}
+			n = min(2*n, len(keep))
+		}
+	}
+	return keep
+}
+
+func split(xs []int, n int) [][]int {
+	var out [][]int
+	start := 0
+	for i := range n {
+		end := start + (len(xs)-start)/(n-i)
+		out = append(out, xs[start:end])
+		start = end
+	}
+	return out
+}
+
+func complement(chunks [][]int, skip int) []int {
+	var out []int
+	for i, c := range chunks {
+		if i != skip {
+			out = append(out, c...)
+		}
+	}
+	return out
+}
+
+// An edit replaces src[start:end] with repl.
+type edit struct {
+	start, end int
+	repl       string
+}
+
+// apply applies every unit whose index is not in keep. When applied edits
+// overlap, the one starting first (and, on ties, the longer one) wins.
+func apply(src []byte, units []edit, keep []int) []byte {
+	kept := make(map[int]bool, len(keep))
+	for _, i := range keep {
+		kept[i] = true
+	}
+	var es []edit
+	for i, e := range units {
+		if !kept[i] {
+			es = append(es, e)
+		}
+	}
+	sort.Slice(es, func(i, j int) bool {
+		if es[i].start != es[j].start {
+			return es[i].start < es[j].start
+		}
+		return es[i].end > es[j].end
+	})
+	var b bytes.Buffer
+	pos := 0
+	for _, e := range es {
+		if e.start < pos {
+			continue
+		}
+		b.Write(src[pos:e.start])
+		b.WriteString(e.repl)
+		pos = e.end
+	}
+	b.Write(src[pos:])
+	return b.Bytes()
+}
+
+type level int
+
+const (
+	decls level = iota
+	stmts
+	exprs
+)
+
+var levels = []level{decls, stmts, exprs}
+
+func (l level) String() string {
+	return [...]string{"decls", "stmts", "exprs"}[l]
+}
+
+// collect returns the units of src at level l, or false if src does not
+// parse.
+func collect(src []byte, l level) ([]edit, bool) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, false
+	}
+	off := func(p token.Pos) int { return fset.Position(p).Offset }
+	del := func(n ast.Node) edit { return edit{off(n.Pos()), off(n.End()), ""} }
+	with := func(n ast.Node, keep ast.Node) edit {
+		return edit{off(n.Pos()), off(n.End()), string(src[off(keep.Pos()):off(keep.End())])}
+	}
+	// delList deletes a list element together with a following comma.
+	delList := func(n ast.Node) edit {
+		e := del(n)
+		if e.end < len(src) && src[e.end] == ',' {
+			e.end++
+		}
+		return e
+	}
+
+	var units []edit
+	switch l {
+	case decls:
+		for _, d := range f.Decls {
+			units = append(units, del(d))
+			if g, ok := d.(*ast.GenDecl); ok && g.Lparen.IsValid() {
+				for _, s := range g.Specs {
+					units = append(units, del(s))
+				}
+			}
+		}
+	case stmts:
+		ast.Inspect(f, func(n ast.Node) bool {
+			var list []ast.Stmt
+			switch n := n.(type) {
+			case *ast.BlockStmt:
+				list = n.List
+			case *ast.CaseClause:
+				list = n.Body
+			case *ast.CommClause:
+				list = n.Body
+			case *ast.IfStmt:
+				if n.Else != nil {
+					units = append(units, edit{off(n.Body.End()), off(n.Else.End()), ""})
+				}
+			case *ast.DeclStmt:
+				// A local const or var group, spec by spec, as at the
+				// top level.
+				if g, ok := n.Decl.(*ast.GenDecl); ok && g.Lparen.IsValid() {
+					for _, s := range g.Specs {
+						units = append(units, del(s))
+					}
+				}
+			}
+			for _, s := range list {
+				units = append(units, del(s))
+			}
+			return true
+		})
+	case exprs:
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.BinaryExpr:
+				units = append(units, with(n, n.X), with(n, n.Y))
+			case *ast.ParenExpr:
+				units = append(units, with(n, n.X))
+			case *ast.UnaryExpr:
+				units = append(units, with(n, n.X))
+			case *ast.StarExpr:
+				units = append(units, with(n, n.X))
+			case *ast.CallExpr:
+				for _, a := range n.Args {
+					units = append(units, delList(a))
+				}
+			case *ast.CompositeLit:
+				for _, e := range n.Elts {
+					units = append(units, delList(e))
+				}
+			case *ast.FieldList:
+				for _, fl := range n.List {
+					units = append(units, delList(fl))
+				}
+			case *ast.IndexListExpr:
+				for _, ix := range n.Indices {
+					units = append(units, delList(ix))
+				}
+			}
+			return true
+		})
+	}
+	return units, true
+}
+
+// lineUnits returns one unit per line of src.
+func lineUnits(src []byte) []edit {
+	var units []edit
+	start := 0
+	for start < len(src) {
+		end := bytes.IndexByte(src[start:], '\n')
+		if end < 0 {
+			end = len(src)
+		} else {
+			end += start + 1
+		}
+		units = append(units, edit{start, end, ""})
+		start = end
+	}
+	return units
+}
@@ -0,0 +1,115 @@
+package minimize
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"testing"
+)
+
+var reductions = []struct {
+	name string
+	src  string
+	// Interesting holds of src where it contains want.
+	want string
+}{
+	{
+		name: "decl",
+		src: `package p
+
+import "fmt"
+
+var a, b = 1, 2
+
+func f() { fmt.Println(a) }
+
+func g() int {
+	return b
+}
+
+type T struct{ x, y int }
+`,
+		want: "func g",
+	},
+	{
+		name: "stmt",
+		src: `package p
+
+func f(x int) int {
+	y := x + 1
+	if y > 2 {
+		y--
+	}
+	for range 3 {
+		y *= 2
+	}
+	panic("boom")
+	return y
+}
+`,
+		want: `panic("boom")`,
+	},
+	{
+		name: "expr",
+		src: `package p
+
+var v = []int{1, 2, 3, 4, 5, 6, 7, 8, 42, 9, 10}
+`,
+		want: "42",
+	},
+	{
+		name: "lines",
+		src: `package p
+func f( {
+	a
+	b
+	keep
+	c
+}
+`,
+		want: "keep",
+	},
+}
+
+func TestReduce(t *testing.T) {
+	for _, tt := range reductions {
+		t.Run(tt.name, func(t *testing.T) {
+			interesting := func(src []byte) bool { return bytes.Contains(src, []byte(tt.want)) }
+			r := &Reducer{Interesting: interesting}
+			got := r.Reduce([]byte(tt.src))
+			if !interesting(got) {
+				t.Fatalf("Reduce gives\n%s\nwhich is not interesting", got)
+			}
+			if len(got) >= len(tt.src) {
+				t.Errorf("Reduce gives %d bytes of %d:\n%s", len(got), len(tt.src), got)
+			}
+			if r.Tests() == 0 {
+				t.Errorf("Reduce never ran the predicate")
+			}
+		})
+	}
+}
+
+func TestDdmin(t *testing.T) {
+	src := []byte("abcdefghij")
+	var units []edit
+	for i := range src {
+		units = append(units, edit{i, i + 1, ""})
+	}
+	for _, need := range []string{"", "a", "j", "cf", "bdhj", "abcdefghij"} {
+		r := &Reducer{
+			Interesting: func(b []byte) bool {
+				for i := range len(need) {
+					if !bytes.ContainsRune(b, rune(need[i])) {
+						return false
+					}
+				}
+				return true
+			},
+			cache: map[[sha256.Size]byte]bool{},
+		}
+		keep := r.ddmin(src, units)
+		if got := string(apply(src, units, keep)); got != need {
+			t.Errorf("ddmin keeping %q: kept %q", need, got)
+		}
+	}
+}
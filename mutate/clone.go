@@ -0,0 +1,57 @@
+package mutate
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+)
+
+var (
+	posType    = reflect.TypeFor[token.Pos]()
+	objectType = reflect.TypeFor[*ast.Object]()
+	scopeType  = reflect.TypeFor[*ast.Scope]()
+)
+
+// clearPos returns a deep copy of n with every position cleared, so that
+// the printer lays the copy out afresh wherever it is grafted.
+func clearPos[T ast.Node](n T) T {
+	return clone(reflect.ValueOf(n)).Interface().(T)
+}
+
+func clone(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		// Objects and scopes link back into the tree; copies do without.
+		if v.IsNil() || v.Type() == objectType || v.Type() == scopeType {
+			return reflect.Zero(v.Type())
+		}
+		n := reflect.New(v.Type().Elem())
+		n.Elem().Set(clone(v.Elem()))
+		return n
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.New(v.Type()).Elem()
+		n.Set(clone(v.Elem()))
+		return n
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		n := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := range v.Len() {
+			n.Index(i).Set(clone(v.Index(i)))
+		}
+		return n
+	case reflect.Struct:
+		n := reflect.New(v.Type()).Elem()
+		for i := range v.NumField() {
+			if f := v.Field(i); f.Type() != posType && n.Field(i).CanSet() {
+				n.Field(i).Set(clone(f))
+			}
+		}
+		return n
+	}
+	return v
+}
@@ -0,0 +1,274 @@
+package mutate
+
+import (
+	"go/ast"
+	"go/token"
+	"math/rand/v2"
+	"path"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// CrossOver combines a and b by one of four operations, each keeping a's
+// package clause:
+//
+//   - a random interleaving of the top-level declarations of both
+//   - a function body of a replaced by one of b's
+//   - type declarations of b grafted into a, in place of a's types of the
+//     same names
+//   - any other subtree of a replaced by a subtree of b that fits its
+//     place: a statement by a statement, a field list by a field list, and
+//     so on
+//
+// The imports of b that the result refers to are merged into a's. The
+// result is checked to parse.
+func CrossOver(a, b []byte, seed uint64, maxSize int) ([]byte, bool) {
+	fa, err := Parse(a)
+	if err != nil {
+		return nil, false
+	}
+	fb, err := Parse(b)
+	if err != nil {
+		return nil, false
+	}
+	r := rand.New(rand.NewPCG(seed, seed^0x2545f4914f6cdd1d))
+	x := &crosser{r: r, a: fa.AST, b: fb.AST}
+	ops := []func() bool{x.interleave, x.swapBody, x.graftTypes, x.graftSubtree}
+	ok := false
+	for range 4 {
+		if ok = ops[r.IntN(len(ops))](); ok {
+			break
+		}
+	}
+	if !ok {
+		x.interleave()
+	}
+	x.mergeImports()
+	// Comments are placed by position, and the nodes of b have none in
+	// a's file set, so the result keeps only the comments attached to a's
+	// own nodes.
+	fa.AST.Comments = nil
+	out, err := fa.Bytes()
+	if err != nil || len(out) > maxSize {
+		return nil, false
+	}
+	if _, err := Parse(out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+type crosser struct {
+	r    *rand.Rand
+	a, b *ast.File
+}
+
+// interleave keeps a's imports and a random half of the other top-level
+// declarations of each file.
+func (x *crosser) interleave() bool {
+	var decls []ast.Decl
+	for _, d := range x.a.Decls {
+		if isImport(d) || x.r.IntN(2) == 0 {
+			decls = append(decls, d)
+		}
+	}
+	for _, d := range x.b.Decls {
+		if !isImport(d) && x.r.IntN(2) == 0 {
+			decls = append(decls, clearPos(d))
+		}
+	}
+	x.a.Decls = decls
+	return true
+}
+
+// bodies returns the bodies of the functions and function literals in f.
+func bodies(f *ast.File) []**ast.BlockStmt {
+	var out []**ast.BlockStmt
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.FuncDecl:
+			if n.Body != nil {
+				out = append(out, &n.Body)
+			}
+		case *ast.FuncLit:
+			out = append(out, &n.Body)
+		}
+		return true
+	})
+	return out
+}
+
+func (x *crosser) swapBody() bool {
+	dst, src := bodies(x.a), bodies(x.b)
+	if len(dst) == 0 || len(src) == 0 {
+		return false
+	}
+	*dst[x.r.IntN(len(dst))] = clearPos(*src[x.r.IntN(len(src))])
+	return true
+}
+
+// graftTypes copies some of b's type specs into a new declaration at the
+// end of a, deleting a's specs of the same names.
+func (x *crosser) graftTypes() bool {
+	var specs []ast.Spec
+	for _, d := range x.b.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.TYPE {
+			for _, s := range g.Specs {
+				if x.r.IntN(2) == 0 {
+					specs = append(specs, clearPos(s))
+				}
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return false
+	}
+	names := map[string]bool{}
+	for _, s := range specs {
+		names[s.(*ast.TypeSpec).Name.Name] = true
+	}
+	x.a.Decls = slices.DeleteFunc(x.a.Decls, func(d ast.Decl) bool {
+		g, ok := d.(*ast.GenDecl)
+		if !ok || g.Tok != token.TYPE {
+			return false
+		}
+		g.Specs = slices.DeleteFunc(g.Specs, func(s ast.Spec) bool { return names[s.(*ast.TypeSpec).Name.Name] })
+		return len(g.Specs) == 0
+	})
+	x.a.Decls = append(x.a.Decls, &ast.GenDecl{Tok: token.TYPE, Specs: specs})
+	return true
+}
+
+var (
+	nodeType    = reflect.TypeFor[ast.Node]()
+	identType   = reflect.TypeFor[*ast.Ident]()
+	commentType = reflect.TypeFor[*ast.CommentGroup]()
+)
+
+// slots returns the fields and slice elements of the nodes of f that hold
+// a node, leaving out identifiers, comments and imports.
+func slots(f *ast.File) []reflect.Value {
+	var out []reflect.Value
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil || n == f {
+			return n != nil
+		}
+		if d, ok := n.(ast.Decl); ok && isImport(d) {
+			return false
+		}
+		v := reflect.ValueOf(n).Elem()
+		if v.Kind() != reflect.Struct {
+			return true
+		}
+		add := func(s reflect.Value) {
+			if s.IsNil() || s.Type() == identType || s.Type() == commentType {
+				return
+			}
+			if d, ok := s.Interface().(ast.Decl); ok && isImport(d) {
+				return
+			}
+			out = append(out, s)
+		}
+		for i := range v.NumField() {
+			switch fv := v.Field(i); {
+			case fv.Kind() == reflect.Slice && fv.Type().Elem().Implements(nodeType):
+				for j := range fv.Len() {
+					add(fv.Index(j))
+				}
+			case (fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface) && fv.Type().Implements(nodeType):
+				add(fv)
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// graftSubtree replaces a subtree of a by one of b that can take its
+// place, preferring one of the same kind.
+func (x *crosser) graftSubtree() bool {
+	dst := slots(x.a)
+	if len(dst) == 0 {
+		return false
+	}
+	s := dst[x.r.IntN(len(dst))]
+	kind := s.Elem().Type()
+	if s.Kind() == reflect.Pointer {
+		kind = s.Type()
+	}
+	var fits, same []ast.Node
+	ast.Inspect(x.b, func(n ast.Node) bool {
+		if d, ok := n.(ast.Decl); ok && isImport(d) {
+			return false
+		}
+		switch n.(type) {
+		case nil, *ast.File, *ast.CommentGroup, *ast.Comment:
+			return n != nil
+		}
+		t := reflect.TypeOf(n)
+		if t.AssignableTo(s.Type()) {
+			fits = append(fits, n)
+			if t == kind {
+				same = append(same, n)
+			}
+		}
+		return true
+	})
+	if len(same) > 0 && x.r.IntN(4) != 0 {
+		fits = same
+	}
+	if len(fits) == 0 {
+		return false
+	}
+	s.Set(reflect.ValueOf(clearPos(fits[x.r.IntN(len(fits))])))
+	return true
+}
+
+// mergeImports adds to a the imports of b whose names a now refers to and
+// does not import itself.
+func (x *crosser) mergeImports() {
+	have := map[string]bool{}
+	for _, s := range x.a.Imports {
+		have[importName(s)] = true
+		have[s.Path.Value] = true
+	}
+	used := map[string]bool{}
+	ast.Inspect(x.a, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				used[id.Name] = true
+			}
+		}
+		return true
+	})
+	var add []ast.Spec
+	for _, s := range x.b.Imports {
+		name := importName(s)
+		if !used[name] || have[name] || have[s.Path.Value] {
+			continue
+		}
+		have[name] = true
+		add = append(add, clearPos(s))
+	}
+	if len(add) == 0 {
+		return
+	}
+	for _, d := range x.a.Decls {
+		if g, ok := d.(*ast.GenDecl); ok && g.Tok == token.IMPORT {
+			g.Specs = append(g.Specs, add...)
+			return
+		}
+	}
+	x.a.Decls = append([]ast.Decl{&ast.GenDecl{Tok: token.IMPORT, Specs: add}}, x.a.Decls...)
+}
+
+// importName returns the name an import declares: its explicit name or
+// the last element of its path.
+func importName(s *ast.ImportSpec) string {
+	if s.Name != nil {
+		return s.Name.Name
+	}
+	p, _ := strconv.Unquote(s.Path.Value)
+	return path.Base(p)
+}
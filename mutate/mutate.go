@@ -0,0 +1,334 @@
+// Package mutate implements structure-aware mutations of Go source files
+// for use as a fuzzing engine's custom mutator.
+//
+// Inputs are bridged to and from go/ast with Parse and File.Bytes. Mutate
+// and CrossOver are deterministic for a given seed, as libFuzzer expects,
+// and report false when the input does not parse so the caller can fall
+// back to byte-level mutation.
+//
+// MutateTokens works a level lower, on the token stream, and so takes any
+// input; its output is mostly almost valid, for the parser's error
+// recovery, where Mutate and CrossOver only write programs that parse.
+package mutate
+
+import (
+	"bytes"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"math/rand/v2"
+)
+
+// A File is a parsed Go source file.
+type File struct {
+	Fset *token.FileSet
+	AST  *ast.File
+}
+
+// Parse parses src. Comments are kept so that directives survive.
+func Parse(src []byte) (*File, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	return &File{Fset: fset, AST: f}, nil
+}
+
+// Bytes prints f back to source.
+func (f *File) Bytes() ([]byte, error) {
+	var b bytes.Buffer
+	if err := format.Node(&b, f.Fset, f.AST); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// Mutate applies between one and four random AST mutations to src and
+// returns the result if it fits in maxSize bytes.
+func Mutate(src []byte, seed uint64, maxSize int) ([]byte, bool) {
+	f, err := Parse(src)
+	if err != nil {
+		return nil, false
+	}
+	r := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	m := &mutator{r: r, f: f}
+	for range 1 + r.IntN(4) {
+		m.mutate()
+	}
+	out, err := f.Bytes()
+	if err != nil || len(out) > maxSize {
+		return nil, false
+	}
+	if _, err := Parse(out); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func isImport(d ast.Decl) bool {
+	g, ok := d.(*ast.GenDecl)
+	return ok && g.Tok == token.IMPORT
+}
+
+type mutator struct {
+	r *rand.Rand
+	f *File
+}
+
+func (m *mutator) mutate() {
+	ops := []func() bool{
+		m.swapStmts, m.deleteStmt, m.dupStmt,
+		m.swapOp, m.replaceLit, m.swapDecls,
+		m.graftExpr, m.renameIdent, m.wrapStmt,
+	}
+	// Not every operation applies to every file; try a few.
+	for range 8 {
+		if ops[m.r.IntN(len(ops))]() {
+			return
+		}
+	}
+}
+
+// lists returns every statement list in the file.
+func (m *mutator) lists() []*[]ast.Stmt {
+	var out []*[]ast.Stmt
+	ast.Inspect(m.f.AST, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			out = append(out, &n.List)
+		case *ast.CaseClause:
+			out = append(out, &n.Body)
+		case *ast.CommClause:
+			out = append(out, &n.Body)
+		}
+		return true
+	})
+	return out
+}
+
+func (m *mutator) pickList(minLen int) *[]ast.Stmt {
+	var ok []*[]ast.Stmt
+	for _, l := range m.lists() {
+		if len(*l) >= minLen {
+			ok = append(ok, l)
+		}
+	}
+	if len(ok) == 0 {
+		return nil
+	}
+	return ok[m.r.IntN(len(ok))]
+}
+
+func (m *mutator) swapStmts() bool {
+	l := m.pickList(2)
+	if l == nil {
+		return false
+	}
+	i, j := m.r.IntN(len(*l)), m.r.IntN(len(*l))
+	(*l)[i], (*l)[j] = (*l)[j], (*l)[i]
+	return i != j
+}
+
+func (m *mutator) deleteStmt() bool {
+	l := m.pickList(1)
+	if l == nil {
+		return false
+	}
+	i := m.r.IntN(len(*l))
+	*l = append((*l)[:i], (*l)[i+1:]...)
+	return true
+}
+
+func (m *mutator) dupStmt() bool {
+	l := m.pickList(1)
+	if l == nil {
+		return false
+	}
+	i := m.r.IntN(len(*l))
+	s := clearPos((*l)[i])
+	*l = append((*l)[:i+1], append([]ast.Stmt{s}, (*l)[i+1:]...)...)
+	return true
+}
+
+func (m *mutator) wrapStmt() bool {
+	l := m.pickList(1)
+	if l == nil {
+		return false
+	}
+	i := m.r.IntN(len(*l))
+	// The body of a switch or select is a list of clauses, which only
+	// that body can hold.
+	switch (*l)[i].(type) {
+	case *ast.CaseClause, *ast.CommClause:
+		return false
+	}
+	body := &ast.BlockStmt{List: []ast.Stmt{(*l)[i]}}
+	switch m.r.IntN(4) {
+	case 0:
+		(*l)[i] = body
+	case 1:
+		(*l)[i] = &ast.IfStmt{Cond: ast.NewIdent("true"), Body: body}
+	case 2:
+		(*l)[i] = &ast.ForStmt{Body: body}
+	default:
+		(*l)[i] = &ast.RangeStmt{X: &ast.BasicLit{Kind: token.INT, Value: "1"}, Body: body}
+	}
+	return true
+}
+
+// opClasses groups binary operators that take operands of the same kind.
+var opClasses = [][]token.Token{
+	{token.ADD, token.SUB, token.MUL, token.QUO, token.REM},
+	{token.AND, token.OR, token.XOR, token.AND_NOT, token.SHL, token.SHR},
+	{token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ},
+	{token.LAND, token.LOR},
+}
+
+// inspectValues calls fn for every node of n in order, as ast.Inspect
+// does, but does not go into the places that only hold types: interface
+// elements, type parameter lists and lists of type arguments. There a
+// BinaryExpr is a union of type sets rather than an operation on values.
+func inspectValues(n ast.Node, fn func(ast.Node)) {
+	ast.Inspect(n, func(n ast.Node) bool {
+		if n == nil {
+			return true
+		}
+		fn(n)
+		switch n := n.(type) {
+		case *ast.InterfaceType:
+			return false
+		case *ast.FuncType:
+			inspectValues(n.Params, fn)
+			if n.Results != nil {
+				inspectValues(n.Results, fn)
+			}
+			return false
+		case *ast.TypeSpec:
+			inspectValues(n.Type, fn)
+			return false
+		case *ast.IndexListExpr:
+			inspectValues(n.X, fn)
+			return false
+		}
+		return true
+	})
+}
+
+func (m *mutator) swapOp() bool {
+	var bins []*ast.BinaryExpr
+	inspectValues(m.f.AST, func(n ast.Node) {
+		if b, ok := n.(*ast.BinaryExpr); ok {
+			bins = append(bins, b)
+		}
+	})
+	if len(bins) == 0 {
+		return false
+	}
+	b := bins[m.r.IntN(len(bins))]
+	for _, class := range opClasses {
+		for _, op := range class {
+			if op == b.Op {
+				b.Op = class[m.r.IntN(len(class))]
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// interesting lists replacement literal values by kind.
+var interesting = map[token.Token][]string{
+	token.INT:    {"0", "1", "-1", "0x7f", "0xff", "1<<31 - 1", "1<<63 - 1", "1 << 64", "0b1", "0o777", "1_0"},
+	token.FLOAT:  {"0.0", "1e308", "1e-324", "0x1p-1074", "1.7976931348623157e308", ".0", "1e1000"},
+	token.IMAG:   {"0i", "1i", "1e308i"},
+	token.CHAR:   {"'\\x00'", "'\\uFFFD'", "'\\U0010FFFF'", "'\\''", "'é'"},
+	token.STRING: {`""`, `"\x00"`, `"\xff"`, "`\r`", `"\U0010FFFF"`, `"%s%d%v"`},
+}
+
+func (m *mutator) replaceLit() bool {
+	var lits []*ast.BasicLit
+	ast.Inspect(m.f.AST, func(n ast.Node) bool {
+		if _, ok := n.(*ast.ImportSpec); ok {
+			return false
+		}
+		if l, ok := n.(*ast.BasicLit); ok {
+			lits = append(lits, l)
+		}
+		return true
+	})
+	if len(lits) == 0 {
+		return false
+	}
+	l := lits[m.r.IntN(len(lits))]
+	vals := interesting[l.Kind]
+	l.Value = vals[m.r.IntN(len(vals))]
+	return true
+}
+
+func (m *mutator) swapDecls() bool {
+	ds := m.f.AST.Decls
+	if len(ds) < 2 {
+		return false
+	}
+	i, j := m.r.IntN(len(ds)), m.r.IntN(len(ds))
+	if isImport(ds[i]) || isImport(ds[j]) {
+		return false
+	}
+	ds[i], ds[j] = ds[j], ds[i]
+	return i != j
+}
+
+// graftExpr replaces one expression with a copy of another from the same
+// file.
+func (m *mutator) graftExpr() bool {
+	var slots []*ast.Expr
+	inspectValues(m.f.AST, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.BinaryExpr:
+			slots = append(slots, &n.X, &n.Y)
+		case *ast.CallExpr:
+			for i := range n.Args {
+				slots = append(slots, &n.Args[i])
+			}
+		case *ast.ReturnStmt:
+			for i := range n.Results {
+				slots = append(slots, &n.Results[i])
+			}
+		case *ast.AssignStmt:
+			for i := range n.Rhs {
+				slots = append(slots, &n.Rhs[i])
+			}
+		case *ast.ValueSpec:
+			for i := range n.Values {
+				slots = append(slots, &n.Values[i])
+			}
+		}
+	})
+	if len(slots) < 2 {
+		return false
+	}
+	dst, src := slots[m.r.IntN(len(slots))], slots[m.r.IntN(len(slots))]
+	if dst == src {
+		return false
+	}
+	*dst = clearPos(*src)
+	return true
+}
+
+func (m *mutator) renameIdent() bool {
+	var ids []*ast.Ident
+	ast.Inspect(m.f.AST, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id != m.f.AST.Name {
+			ids = append(ids, id)
+		}
+		return true
+	})
+	if len(ids) < 2 {
+		return false
+	}
+	a, b := ids[m.r.IntN(len(ids))], ids[m.r.IntN(len(ids))]
+	a.Name = b.Name
+	return true
+}
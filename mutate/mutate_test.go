@@ -0,0 +1,273 @@
+package mutate
+
+import (
+	"go/scanner"
+	"go/token"
+	"math/rand/v2"
+	"strings"
+	"testing"
+)
+
+var sources = []string{
+	`package p
+
+import "fmt"
+
+type T struct{ a, b int }
+
+func (t T) Sum() int { return t.a + t.b }
+
+func f(x, y int) int {
+	z := x * y
+	if z > 10 {
+		z -= 3
+	}
+	for i := range 4 {
+		z += i
+	}
+	fmt.Println(z, "z")
+	return z
+}
+`,
+	`package q
+
+import (
+	"os"
+	"strings"
+)
+
+type Pair struct {
+	Key, Value string
+}
+
+var names = []string{"a", "b"}
+
+func g(s string) bool {
+	switch {
+	case strings.HasPrefix(s, "-"):
+		return false
+	case len(s) == 0 || s == ".":
+		os.Exit(1)
+	}
+	p := Pair{Key: s, Value: s + "!"}
+	return p.Key != p.Value && !false
+}
+
+func h() {
+	defer func() { _ = recover() }()
+	ch := make(chan int, 1)
+	ch <- 1
+	<-ch
+}
+`,
+	`package r
+
+type C interface{ ~int | ~string }
+
+type Num interface {
+	~int8 | ~int16 | ~uint8 | ~uint16
+}
+
+func F[T C]() {}
+
+func Sum[T Num, U ~int | ~uint](xs []T, u U) (s T) {
+	for _, x := range xs {
+		s += x * 2
+	}
+	return s + T(u&1)
+}
+
+type Pair[K comparable, V any] struct {
+	k K
+	v V
+}
+
+func use() {
+	F[int]()
+	_ = Sum[uint8, int]([]uint8{1, 2}, 3|4)
+	_ = Pair[string, int]{"a", 1 + 2}
+}
+`,
+}
+
+// tokenSources are inputs for MutateTokens: Go with comments and
+// directives between its tokens, and text that is not Go.
+var tokenSources = []string{
+	`//go:build linux && !cgo
+
+// Package p has a doc comment.
+package p
+
+import "unsafe"
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+//go:noinline
+func f(x int) int { /* inline */ return x + 1 } // trailing
+
+//line other.go:10
+var v = f(2) /*line other.go:20:5*/ * 3
+`,
+	"x := `raw\r\n` + \"s\" // not a file\n/* unterminated",
+}
+
+var mutations = []struct {
+	name string
+	op   func(*mutator) bool
+}{
+	{"swapStmts", (*mutator).swapStmts},
+	{"deleteStmt", (*mutator).deleteStmt},
+	{"dupStmt", (*mutator).dupStmt},
+	{"swapOp", (*mutator).swapOp},
+	{"replaceLit", (*mutator).replaceLit},
+	{"swapDecls", (*mutator).swapDecls},
+	{"graftExpr", (*mutator).graftExpr},
+	{"renameIdent", (*mutator).renameIdent},
+	{"wrapStmt", (*mutator).wrapStmt},
+}
+
+func TestMutationsParse(t *testing.T) {
+	for _, tt := range mutations {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := 0
+			for _, src := range sources {
+				for seed := range uint64(50) {
+					f, err := Parse([]byte(src))
+					if err != nil {
+						t.Fatal(err)
+					}
+					m := &mutator{r: rand.New(rand.NewPCG(seed, seed)), f: f}
+					if !tt.op(m) {
+						continue
+					}
+					applied++
+					out, err := f.Bytes()
+					if err != nil {
+						t.Fatalf("seed %d: mutated file does not print: %v", seed, err)
+					}
+					if _, err := Parse(out); err != nil {
+						t.Fatalf("seed %d: mutated file does not parse: %v\n%s", seed, err, out)
+					}
+				}
+			}
+			if applied == 0 {
+				t.Errorf("never applied")
+			}
+		})
+	}
+}
+
+var crossovers = []struct {
+	name string
+	op   func(*crosser) bool
+}{
+	{"interleave", (*crosser).interleave},
+	{"swapBody", (*crosser).swapBody},
+	{"graftTypes", (*crosser).graftTypes},
+	{"graftSubtree", (*crosser).graftSubtree},
+}
+
+func TestCrossoversParse(t *testing.T) {
+	for _, tt := range crossovers {
+		t.Run(tt.name, func(t *testing.T) {
+			applied := 0
+			for seed := range uint64(50) {
+				fa, err := Parse([]byte(sources[0]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				fb, err := Parse([]byte(sources[1]))
+				if err != nil {
+					t.Fatal(err)
+				}
+				x := &crosser{r: rand.New(rand.NewPCG(seed, seed)), a: fa.AST, b: fb.AST}
+				if !tt.op(x) {
+					continue
+				}
+				applied++
+				x.mergeImports()
+				fa.AST.Comments = nil
+				out, err := fa.Bytes()
+				if err != nil {
+					t.Fatalf("seed %d: crossed file does not print: %v", seed, err)
+				}
+				if _, err := Parse(out); err != nil {
+					t.Fatalf("seed %d: crossed file does not parse: %v\n%s", seed, err, out)
+				}
+			}
+			if applied == 0 {
+				t.Errorf("never applied")
+			}
+		})
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	a, b := []byte(sources[0]), []byte(sources[1])
+	for seed := range uint64(20) {
+		m1, ok1 := Mutate(a, seed, 1<<20)
+		m2, ok2 := Mutate(a, seed, 1<<20)
+		if ok1 != ok2 || string(m1) != string(m2) {
+			t.Errorf("Mutate, seed %d: two runs differ", seed)
+		}
+		c1, ok1 := CrossOver(a, b, seed, 1<<20)
+		c2, ok2 := CrossOver(a, b, seed, 1<<20)
+		if ok1 != ok2 || string(c1) != string(c2) {
+			t.Errorf("CrossOver, seed %d: two runs differ", seed)
+		}
+		if ok1 {
+			if _, err := Parse(c1); err != nil {
+				t.Errorf("CrossOver, seed %d: result does not parse: %v", seed, err)
+			}
+		}
+	}
+}
+
+func TestMutateTokens(t *testing.T) {
+	for _, src := range append(tokenSources, sources...) {
+		comments := commentsOf(src)
+		for seed := range uint64(50) {
+			out, ok := MutateTokens([]byte(src), seed, 1<<20)
+			again, okAgain := MutateTokens([]byte(src), seed, 1<<20)
+			if ok != okAgain || string(out) != string(again) {
+				t.Fatalf("seed %d: two runs differ:\n%s\n---\n%s", seed, out, again)
+			}
+			if !ok {
+				t.Fatalf("seed %d: no mutation of\n%s", seed, src)
+			}
+			rest := string(out)
+			for _, c := range comments {
+				i := strings.Index(rest, c)
+				if i < 0 {
+					t.Fatalf("seed %d: comment %q is lost or out of order:\n%s", seed, c, out)
+				}
+				rest = rest[i+len(c):]
+			}
+			// The same mutations must be refused where they do not fit.
+			limit := len(out) - 1
+			if small, ok := MutateTokens([]byte(src), seed, limit); ok || len(small) > limit {
+				t.Errorf("seed %d, maxSize %d: got %d bytes", seed, limit, len(small))
+			}
+		}
+	}
+}
+
+// commentsOf returns the comments of src in order, as the scanner
+// finds them.
+func commentsOf(src string) []string {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, []byte(src), nil, scanner.ScanComments)
+	var cs []string
+	for {
+		_, tok, lit := s.Scan()
+		if tok == token.EOF {
+			return cs
+		}
+		if tok == token.COMMENT {
+			cs = append(cs, lit)
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package mutate
+
+import (
+	"bytes"
+	"go/scanner"
+	"go/token"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// MutateTokens applies between one and four random mutations to the
+// token stream of src: it swaps adjacent tokens, replaces an operator
+// with another of the same arity, duplicates or deletes a token, or
+// splices in a keyword. Unlike Mutate it works on any input, valid Go or
+// not, and its output is mostly almost valid Go, which is what a
+// parser's error recovery needs. It reports false if src has no tokens
+// or the result does not fit in maxSize bytes.
+//
+// Whitespace and comments between tokens stay where they were, so line
+// structure and directives survive.
+func MutateTokens(src []byte, seed uint64, maxSize int) ([]byte, bool) {
+	ts := tokenize(src)
+	if len(ts) == 0 {
+		return nil, false
+	}
+	r := rand.New(rand.NewPCG(seed, seed^0x3c6ef372fe94f82b))
+	m := &tokenMutator{r: r, ts: ts}
+	for range 1 + r.IntN(4) {
+		m.mutate()
+	}
+	out := m.bytes()
+	if len(out) > maxSize {
+		return nil, false
+	}
+	return out, true
+}
+
+// A tok is one token of the source and the text before it that the
+// scanner skipped.
+type tok struct {
+	gap  string
+	tok  token.Token
+	text string
+}
+
+// tokenize splits src into tokens, dropping the semicolons the scanner
+// inserts at line ends. The text after the last token is a final tok of
+// kind EOF.
+func tokenize(src []byte) []tok {
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(src))
+	var s scanner.Scanner
+	s.Init(file, src, func(token.Position, string) {}, 0)
+	var ts []tok
+	end := 0
+	for {
+		pos, t, lit := s.Scan()
+		off := file.Offset(pos)
+		if t == token.EOF {
+			if len(ts) > 0 {
+				ts = append(ts, tok{gap: string(src[end:]), tok: token.EOF})
+			}
+			return ts
+		}
+		if t == token.SEMICOLON && lit == "\n" || off < end {
+			continue
+		}
+		next := tokenEnd(src, off, t, lit)
+		ts = append(ts, tok{gap: string(src[end:off]), tok: t, text: string(src[off:next])})
+		end = next
+	}
+}
+
+// tokenEnd returns the offset just past the token at off. The scanner
+// drops carriage returns from raw strings, so their literal is not
+// always the source text.
+func tokenEnd(src []byte, off int, t token.Token, lit string) int {
+	if lit == "" {
+		lit = t.String()
+	}
+	if bytes.HasPrefix(src[off:], []byte(lit)) {
+		return off + len(lit)
+	}
+	if t == token.STRING && src[off] == '`' {
+		if i := bytes.IndexByte(src[off+1:], '`'); i >= 0 {
+			return off + 1 + i + 1
+		}
+		return len(src)
+	}
+	return min(off+len(lit), len(src))
+}
+
+type tokenMutator struct {
+	r  *rand.Rand
+	ts []tok // the last is the EOF tok
+}
+
+func (m *tokenMutator) mutate() {
+	ops := []func() bool{
+		m.swapTokens, m.replaceOp, m.dupToken, m.deleteToken, m.spliceKeyword,
+	}
+	for range 8 {
+		if ops[m.r.IntN(len(ops))]() {
+			return
+		}
+	}
+}
+
+// n is the number of tokens, not counting EOF.
+func (m *tokenMutator) n() int { return len(m.ts) - 1 }
+
+func (m *tokenMutator) swapTokens() bool {
+	if m.n() < 2 {
+		return false
+	}
+	i := m.r.IntN(m.n() - 1)
+	a, b := &m.ts[i], &m.ts[i+1]
+	a.tok, b.tok = b.tok, a.tok
+	a.text, b.text = b.text, a.text
+	return a.text != b.text
+}
+
+// tokenClasses groups operators that take the same number of operands
+// in the same positions. +, -, ^, * and & are binary after an operand and
+// unary elsewhere.
+var tokenClasses = [][]token.Token{
+	{token.ADD, token.SUB, token.MUL, token.QUO, token.REM, token.AND, token.OR, token.XOR, token.SHL, token.SHR, token.AND_NOT},
+	{token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ},
+	{token.LAND, token.LOR},
+	{token.ADD, token.SUB, token.NOT, token.XOR, token.MUL, token.AND, token.ARROW, token.TILDE},
+	{token.ASSIGN, token.DEFINE, token.ADD_ASSIGN, token.SUB_ASSIGN, token.MUL_ASSIGN, token.QUO_ASSIGN, token.REM_ASSIGN,
+		token.AND_ASSIGN, token.OR_ASSIGN, token.XOR_ASSIGN, token.SHL_ASSIGN, token.SHR_ASSIGN, token.AND_NOT_ASSIGN},
+	{token.INC, token.DEC},
+	{token.COMMA, token.SEMICOLON, token.COLON},
+	{token.PERIOD, token.ELLIPSIS},
+	{token.LPAREN, token.LBRACK, token.LBRACE},
+	{token.RPAREN, token.RBRACK, token.RBRACE},
+}
+
+// operand reports whether t ends an operand, making the operator after
+// it binary.
+func operand(t token.Token) bool {
+	return t.IsLiteral() || t == token.RPAREN || t == token.RBRACK || t == token.RBRACE
+}
+
+func (m *tokenMutator) replaceOp() bool {
+	var ops []int
+	for i, t := range m.ts[:m.n()] {
+		if t.tok.IsOperator() {
+			ops = append(ops, i)
+		}
+	}
+	if len(ops) == 0 {
+		return false
+	}
+	i := ops[m.r.IntN(len(ops))]
+	t := &m.ts[i]
+	unary := i == 0 || !operand(m.ts[i-1].tok)
+	for ci, class := range tokenClasses {
+		if ci == 0 && unary && slices.Contains(tokenClasses[3], t.tok) {
+			continue
+		}
+		for _, op := range class {
+			if op == t.tok {
+				t.tok = class[m.r.IntN(len(class))]
+				t.text = t.tok.String()
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (m *tokenMutator) dupToken() bool {
+	if m.n() == 0 {
+		return false
+	}
+	i := m.r.IntN(m.n())
+	t := m.ts[i]
+	if t.gap == "" || strings.Contains(t.gap, "\n") {
+		t.gap = " "
+	}
+	m.ts = append(m.ts[:i+1], append([]tok{t}, m.ts[i+1:]...)...)
+	return true
+}
+
+func (m *tokenMutator) deleteToken() bool {
+	if m.n() < 2 {
+		return false
+	}
+	i := m.r.IntN(m.n())
+	// Keep the line structure: the gap moves to the next token.
+	m.ts[i+1].gap = m.ts[i].gap + m.ts[i+1].gap
+	m.ts = append(m.ts[:i], m.ts[i+1:]...)
+	return true
+}
+
+// keywords are the tokens spliceKeyword inserts.
+var keywords = func() []token.Token {
+	var ks []token.Token
+	for t := token.BREAK; t <= token.VAR; t++ {
+		if t.IsKeyword() {
+			ks = append(ks, t)
+		}
+	}
+	return ks
+}()
+
+// spliceKeyword puts a keyword before a token or in place of an
+// identifier.
+func (m *tokenMutator) spliceKeyword() bool {
+	if m.n() == 0 {
+		return false
+	}
+	k := keywords[m.r.IntN(len(keywords))]
+	i := m.r.IntN(m.n())
+	if m.ts[i].tok == token.IDENT && m.r.IntN(2) == 0 {
+		m.ts[i].tok, m.ts[i].text = k, k.String()
+		return true
+	}
+	t := tok{gap: m.ts[i].gap, tok: k, text: k.String()}
+	m.ts[i].gap = " "
+	m.ts = append(m.ts[:i], append([]tok{t}, m.ts[i:]...)...)
+	return true
+}
+
+// bytes reassembles the tokens, separating two that would otherwise run
+// together into one.
+func (m *tokenMutator) bytes() []byte {
+	var b []byte
+	for _, t := range m.ts {
+		if t.gap == "" && len(b) > 0 && len(t.text) > 0 && wordByte(b[len(b)-1]) && wordByte(t.text[0]) {
+			b = append(b, ' ')
+		}
+		b = append(b, t.gap...)
+		b = append(b, t.text...)
+	}
+	return b
+}
+
+func wordByte(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,250 @@
+package seedgen
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A Profile steers a corpus toward a fuzz target by weighting the
+// generators: a generator of weight w writes w times the count of seeds
+// (see WithCount), rounded to the nearest integer, and one of weight 0
+// none.
+//
+// Profiles are written in JSON or in the subset of YAML below, which
+// ParseProfile reads:
+//
+//	# Comments run to the end of the line.
+//	name: generics
+//	description: type parameters first
+//	default: 0.5
+//	weights:
+//	  go/instantiate: 5
+//	  go/typesets: 5
+//	  go/cgo: 0
+//	  "mod/*": 0
+//
+// The keys of weights are generator names or path.Match patterns, as
+// WithGenerators takes them. A generator's own name takes precedence over
+// patterns, and a longer pattern over a shorter one.
+type Profile struct {
+	Name        string             `json:"name"`
+	Description string             `json:"description"`
+	Default     float64            `json:"default"` // weight of generators no key matches
+	Weights     map[string]float64 `json:"weights"`
+}
+
+//go:embed profiles/*.yaml
+var builtin embed.FS
+
+// Profiles returns the names of the built-in profiles.
+func Profiles() []string {
+	ents, _ := builtin.ReadDir("profiles")
+	var names []string
+	for _, e := range ents {
+		names = append(names, strings.TrimSuffix(e.Name(), ".yaml"))
+	}
+	return names
+}
+
+// LoadProfile returns the built-in profile of the given name, such as
+// "frontend", "runtime" or "typeparams", or else the profile in the file
+// of that name.
+func LoadProfile(name string) (*Profile, error) {
+	data, err := builtin.ReadFile("profiles/" + name + ".yaml")
+	if err != nil {
+		data, err = os.ReadFile(name)
+		if err != nil {
+			return nil, fmt.Errorf("seedgen: no built-in profile %q (have %s) and %v", name, strings.Join(Profiles(), ", "), err)
+		}
+	}
+	p, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("seedgen: profile %s: %v", name, err)
+	}
+	return p, nil
+}
+
+// ParseProfile reads a profile in JSON, if data starts with '{', or in
+// YAML. A profile that does not give default has default 1, so that
+// generators it does not name keep their count.
+func ParseProfile(data []byte) (*Profile, error) {
+	p, err := parseProfile(data)
+	if err != nil {
+		return nil, fmt.Errorf("seedgen: profile: %v", err)
+	}
+	return p, nil
+}
+
+func parseProfile(data []byte) (*Profile, error) {
+	p := &Profile{Default: 1}
+	if d := bytes.TrimSpace(data); len(d) > 0 && d[0] == '{' {
+		dec := json.NewDecoder(bytes.NewReader(d))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(p); err != nil {
+			return nil, err
+		}
+	} else if err := p.parseYAML(string(data)); err != nil {
+		return nil, err
+	}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// parseYAML reads the YAML subset of a profile: top-level "key: value"
+// lines and a weights block of indented ones.
+func (p *Profile) parseYAML(src string) error {
+	inWeights := false
+	for i, line := range strings.Split(src, "\n") {
+		errorf := func(format string, args ...any) error {
+			return fmt.Errorf("line %d: %s", i+1, fmt.Sprintf(format, args...))
+		}
+		line = stripComment(strings.TrimRight(line, " \t\r"))
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		indented := line[0] == ' ' || line[0] == '\t'
+		key, val, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok {
+			return errorf("want key: value")
+		}
+		key, err := unquote(strings.TrimSpace(key))
+		if err != nil {
+			return errorf("%v", err)
+		}
+		val, err = unquote(strings.TrimSpace(val))
+		if err != nil {
+			return errorf("%v", err)
+		}
+		if indented {
+			if !inWeights {
+				return errorf("indented line outside weights")
+			}
+			w, err := strconv.ParseFloat(val, 64)
+			if err != nil {
+				return errorf("weight of %s: %q is not a number", key, val)
+			}
+			if _, dup := p.Weights[key]; dup {
+				return errorf("%s weighted twice", key)
+			}
+			p.Weights[key] = w
+			continue
+		}
+		inWeights = false
+		switch key {
+		case "name":
+			p.Name = val
+		case "description":
+			p.Description = val
+		case "default":
+			if p.Default, err = strconv.ParseFloat(val, 64); err != nil {
+				return errorf("default: %q is not a number", val)
+			}
+		case "weights":
+			if val != "" {
+				return errorf("weights must be an indented block")
+			}
+			inWeights = true
+			if p.Weights == nil {
+				p.Weights = map[string]float64{}
+			}
+		default:
+			return errorf("unknown key %q", key)
+		}
+	}
+	return nil
+}
+
+// stripComment removes a comment starting with '#' at the start of line
+// or after a space, outside quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return strings.TrimRight(line[:i], " \t")
+		}
+	}
+	return line
+}
+
+// unquote returns s without the double or single quotes around it.
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		return strconv.Unquote(s)
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
+
+// check reports negative weights and bad patterns.
+func (p *Profile) check() error {
+	if p.Default < 0 || math.IsNaN(p.Default) || math.IsInf(p.Default, 0) {
+		return fmt.Errorf("bad default %v", p.Default)
+	}
+	for k, w := range p.Weights {
+		if _, err := path.Match(k, ""); err != nil {
+			return fmt.Errorf("bad pattern %q", k)
+		}
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return fmt.Errorf("bad weight %v for %s", w, k)
+		}
+	}
+	return nil
+}
+
+// Weight returns the weight p gives the generator of the given name.
+func (p *Profile) Weight(name string) float64 {
+	if w, ok := p.Weights[name]; ok {
+		return w
+	}
+	best := ""
+	w := p.Default
+	for k, kw := range p.Weights {
+		if ok, _ := path.Match(k, name); !ok {
+			continue
+		}
+		if len(k) > len(best) || len(k) == len(best) && k < best {
+			best, w = k, kw
+		}
+	}
+	return w
+}
+
+// Count returns how many seeds p has g write for a count of n.
+func (p *Profile) Count(g *gen.Generator, n int) int {
+	return int(math.Round(float64(n) * p.Weight(g.Name)))
+}
+
+// unmatched returns the keys of p that name no registered generator.
+func (p *Profile) unmatched() []string {
+	var keys []string
+	for k := range p.Weights {
+		if gs, _ := gen.Match(k); len(gs) == 0 {
+			keys = append(keys, k)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,438 @@
+// Package seedgen runs the registered generators programmatically, for
+// fuzzing projects that embed the generator instead of vendoring a
+// corpus written by cmd/seedgen:
+//
+//	seeds, err := seedgen.Generate(ctx,
+//		seedgen.WithGenerators("go/*"),
+//		seedgen.WithCount(100),
+//		seedgen.WithMaxDepth(50),
+//		seedgen.WithGoVersion("1.22"),
+//		seedgen.WithSeed(42))
+//
+// Generation is deterministic: the seeds are a function of the options
+// alone, so one integer, the master seed, reproduces a whole corpus.
+//
+// A Profile weights the generators to steer the corpus toward a fuzz
+// target; LoadProfile reads the built-in ones, "frontend", "runtime" and
+// "typeparams", and profiles in JSON or YAML files:
+//
+//	p, err := seedgen.LoadProfile("typeparams")
+//	...
+//	seeds, err := seedgen.Generate(ctx, seedgen.WithWeights(p))
+//
+// Importing seedgen registers every generator in gen/asn1src,
+// gen/bencodesrc, gen/bigsrc, gen/cborsrc, gen/compresssrc, gen/csrc,
+// gen/csvsrc, gen/debugsrc, gen/dnssrc, gen/fontsrc, gen/gitsrc,
+// gen/gobsrc, gen/gosrc, gen/htmlsrc, gen/http2src, gen/httpsrc,
+// gen/imagesrc, gen/ipsrc, gen/json5src, gen/jsonsrc, gen/jssrc,
+// gen/jwtsrc, gen/mailsrc, gen/mdsrc, gen/modsrc, gen/multipartsrc,
+// gen/pathsrc, gen/pdfsrc, gen/pemsrc, gen/protosrc, gen/pysrc,
+// gen/quicsrc, gen/regexpsrc, gen/rustsrc, gen/shsrc, gen/sqlsrc,
+// gen/sshsrc, gen/strconvsrc, gen/tarsrc, gen/timesrc, gen/tlssrc,
+// gen/tmplsrc, gen/tomlsrc, gen/urlsrc, gen/wasmsrc, gen/wssrc,
+// gen/xmlsrc, gen/yamlsrc and gen/zipsrc.
+package seedgen
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go/version"
+	"math/rand/v2"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/geeknik/fuzzing/corpus"
+	"github.com/geeknik/fuzzing/corpus/native"
+	"github.com/geeknik/fuzzing/gen"
+	_ "github.com/geeknik/fuzzing/gen/asn1src"
+	_ "github.com/geeknik/fuzzing/gen/bencodesrc"
+	_ "github.com/geeknik/fuzzing/gen/bigsrc"
+	_ "github.com/geeknik/fuzzing/gen/cborsrc"
+	_ "github.com/geeknik/fuzzing/gen/compresssrc"
+	_ "github.com/geeknik/fuzzing/gen/csrc"
+	_ "github.com/geeknik/fuzzing/gen/csvsrc"
+	_ "github.com/geeknik/fuzzing/gen/debugsrc"
+	_ "github.com/geeknik/fuzzing/gen/dnssrc"
+	_ "github.com/geeknik/fuzzing/gen/fontsrc"
+	_ "github.com/geeknik/fuzzing/gen/gitsrc"
+	_ "github.com/geeknik/fuzzing/gen/gobsrc"
+	_ "github.com/geeknik/fuzzing/gen/gosrc"
+	_ "github.com/geeknik/fuzzing/gen/htmlsrc"
+	_ "github.com/geeknik/fuzzing/gen/http2src"
+	_ "github.com/geeknik/fuzzing/gen/httpsrc"
+	_ "github.com/geeknik/fuzzing/gen/imagesrc"
+	_ "github.com/geeknik/fuzzing/gen/ipsrc"
+	_ "github.com/geeknik/fuzzing/gen/json5src"
+	_ "github.com/geeknik/fuzzing/gen/jsonsrc"
+	_ "github.com/geeknik/fuzzing/gen/jssrc"
+	_ "github.com/geeknik/fuzzing/gen/jwtsrc"
+	_ "github.com/geeknik/fuzzing/gen/mailsrc"
+	_ "github.com/geeknik/fuzzing/gen/mdsrc"
+	_ "github.com/geeknik/fuzzing/gen/modsrc"
+	_ "github.com/geeknik/fuzzing/gen/multipartsrc"
+	_ "github.com/geeknik/fuzzing/gen/pathsrc"
+	_ "github.com/geeknik/fuzzing/gen/pdfsrc"
+	_ "github.com/geeknik/fuzzing/gen/pemsrc"
+	_ "github.com/geeknik/fuzzing/gen/protosrc"
+	_ "github.com/geeknik/fuzzing/gen/pysrc"
+	_ "github.com/geeknik/fuzzing/gen/quicsrc"
+	_ "github.com/geeknik/fuzzing/gen/regexpsrc"
+	_ "github.com/geeknik/fuzzing/gen/rustsrc"
+	_ "github.com/geeknik/fuzzing/gen/shsrc"
+	_ "github.com/geeknik/fuzzing/gen/sqlsrc"
+	_ "github.com/geeknik/fuzzing/gen/sshsrc"
+	_ "github.com/geeknik/fuzzing/gen/strconvsrc"
+	_ "github.com/geeknik/fuzzing/gen/tarsrc"
+	_ "github.com/geeknik/fuzzing/gen/timesrc"
+	_ "github.com/geeknik/fuzzing/gen/tlssrc"
+	_ "github.com/geeknik/fuzzing/gen/tmplsrc"
+	_ "github.com/geeknik/fuzzing/gen/tomlsrc"
+	_ "github.com/geeknik/fuzzing/gen/urlsrc"
+	_ "github.com/geeknik/fuzzing/gen/wasmsrc"
+	_ "github.com/geeknik/fuzzing/gen/wssrc"
+	_ "github.com/geeknik/fuzzing/gen/xmlsrc"
+	_ "github.com/geeknik/fuzzing/gen/yamlsrc"
+	_ "github.com/geeknik/fuzzing/gen/zipsrc"
+	"github.com/geeknik/fuzzing/validate"
+)
+
+// A Seed is one generated corpus entry.
+type Seed struct {
+	Generator string // name of the generator that wrote it, e.g. "go/iota"
+	Index     int    // its number among the seeds of that generator
+	Files     []gen.File
+
+	// StateSeed is the seed of the gen.State it was generated from,
+	// which Go files also carry in their header.
+	StateSeed uint64
+}
+
+// Path returns the slash-separated path of s in a corpus written by
+// cmd/seedgen: <generator>/<index><ext> for a single-file seed and the
+// directory <generator>/<index> for a multi-file one.
+func (s Seed) Path() string {
+	p := path.Join(s.Generator, fmt.Sprintf("%06d", s.Index))
+	if len(s.Files) == 1 {
+		p += path.Ext(s.Files[0].Name)
+	}
+	return p
+}
+
+// ShardPath is Path for a corpus split into shards of size seeds, for
+// generators with too many seeds for one directory: seed i goes to the
+// directory <generator>/shard-<i/size> as <index><ext> or <index>. A size
+// of zero or less gives Path.
+func (s Seed) ShardPath(size int) string {
+	if size <= 0 {
+		return s.Path()
+	}
+	dir, name := path.Split(s.Path())
+	return path.Join(dir, fmt.Sprintf("shard-%04d", s.Index/size), name)
+}
+
+// Write stores s under the corpus directory dir at s.Path(), where
+// package corpus reads it back.
+func (s Seed) Write(dir string) error {
+	return s.WriteShard(dir, 0)
+}
+
+// WriteShard stores s under dir at s.ShardPath(size). Package corpus
+// reads sharded corpora too.
+func (s Seed) WriteShard(dir string, size int) error {
+	p := filepath.Join(dir, filepath.FromSlash(s.ShardPath(size)))
+	if len(s.Files) == 1 {
+		// The file takes the seed's name; its own name only lends the
+		// extension.
+		dir, name := filepath.Split(p)
+		return corpus.Seed{Files: []gen.File{{Name: name, Data: s.Files[0].Data}}}.Write(dir)
+	}
+	return corpus.Seed{Files: s.Files}.Write(p)
+}
+
+// WriteNative stores every file of s whose name has the extension ext
+// as a "go test fuzz v1" entry of one []byte argument in dir, which is
+// meant to be the testdata/fuzz/FuzzXxx directory of a target such as
+// func(t *testing.T, src []byte). Entries are named the way `go test`
+// names them, by content.
+func (s Seed) WriteNative(dir, ext string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, f := range s.Files {
+		if path.Ext(f.Name) != ext {
+			continue
+		}
+		b := native.Bytes(f.Data)
+		if err := os.WriteFile(filepath.Join(dir, native.Name(b)), b, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// An Option configures Generate.
+type Option func(*config)
+
+type config struct {
+	patterns  []string
+	count     int
+	limits    gen.Limits
+	goVersion string
+	dangerous bool
+	seed      uint64
+	seeded    bool
+	workers   int
+	weights   *Profile
+	level     validate.Level
+	reject    func(Seed, error)
+}
+
+// WithGenerators selects the generators whose names match any of the
+// path.Match patterns, e.g. "go/*". Without it every generator runs.
+func WithGenerators(patterns ...string) Option {
+	return func(c *config) { c.patterns = append(c.patterns, patterns...) }
+}
+
+// WithWeights scales the count of each generator by its weight in p, so
+// that a profile such as LoadProfile("typeparams") steers the corpus
+// toward the constructs a fuzz target exercises. Generators of weight 0
+// are left out. WithGenerators still selects which generators p weighs.
+func WithWeights(p *Profile) Option {
+	return func(c *config) { c.weights = p }
+}
+
+// WithCount sets the number of seeds written by each generator. The
+// default is 10.
+func WithCount(n int) Option {
+	return func(c *config) { c.count = n }
+}
+
+// WithMaxDepth bounds every kind of recursive structure at depth, as if
+// each field of gen.Limits were set to it.
+func WithMaxDepth(depth int) Option {
+	return func(c *config) { c.limits = gen.Limits{Expr: depth, Literal: depth, Generic: depth, Block: depth} }
+}
+
+// WithLimits sets the nesting bounds one kind of structure at a time.
+func WithLimits(l gen.Limits) Option {
+	return func(c *config) { c.limits = l }
+}
+
+// WithGoVersion adds a go.mod declaring module "seed" at the language
+// version v, e.g. "1.22", to every seed with Go files and no go.mod of
+// its own, so that the seed builds as a module at that version.
+func WithGoVersion(v string) Option {
+	return func(c *config) { c.goVersion = v }
+}
+
+// WithDangerous includes the generators marked gen.Generator.Dangerous,
+// which are otherwise left out.
+func WithDangerous() Option {
+	return func(c *config) { c.dangerous = true }
+}
+
+// WithSeed sets the master seed. Seed number i of every generator comes
+// from a gen.State seeded with master+i, so the seed in the header of a
+// generated file is the master seed of a run of one seed that writes the
+// file again:
+//
+//	seedgen.Generate(ctx, seedgen.WithGenerators("go/iota"), seedgen.WithCount(1), seedgen.WithSeed(n))
+//
+// Without WithSeed the master seed is random.
+func WithSeed(master uint64) Option {
+	return func(c *config) { c.seed, c.seeded = master, true }
+}
+
+// WithWorkers sets the number of goroutines generating seeds at once.
+// The default is runtime.GOMAXPROCS(0). The seeds do not depend on it:
+// each has a random stream of its own, seeded as WithSeed describes,
+// whichever worker generates it.
+func WithWorkers(n int) Option {
+	return func(c *config) { c.workers = n }
+}
+
+// WithValidation admits only seeds that pass validate.Check at level,
+// such as validate.Types for a corpus of programs that must compile, and
+// calls reject, if not nil, with each seed left out and the reason. reject
+// is called from several goroutines at once. Rejected seeds leave gaps in
+// the indexes; the others are the same as without validation.
+func WithValidation(level validate.Level, reject func(Seed, error)) Option {
+	return func(c *config) { c.level, c.reject = level, reject }
+}
+
+// Generate runs the selected generators and returns their seeds, grouped
+// by generator in name order. It stops with ctx's error if ctx is done
+// before the last seed.
+func Generate(ctx context.Context, opts ...Option) ([]Seed, error) {
+	var seeds []Seed
+	jobs, err := start(ctx, opts, func(j job, s Seed) error {
+		seeds[j.n] = s
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	seeds = make([]Seed, len(jobs.list))
+	if err := jobs.run(); err != nil {
+		return nil, err
+	}
+	// Drop the places of rejected seeds.
+	return slices.DeleteFunc(seeds, func(s Seed) bool { return s.Generator == "" }), nil
+}
+
+// Each is Generate for corpora too large to hold in memory: it calls fn
+// with each seed as soon as it is generated instead of returning them.
+// fn is called from several goroutines at once and in no particular
+// order. Each stops at the first error from fn and returns it.
+func Each(ctx context.Context, fn func(Seed) error, opts ...Option) error {
+	jobs, err := start(ctx, opts, func(_ job, s Seed) error { return fn(s) })
+	if err != nil {
+		return err
+	}
+	return jobs.run()
+}
+
+// A job is seed number i of a generator, the nth of the run.
+type job struct {
+	g    *gen.Generator
+	i, n int
+}
+
+// A jobList is a configured run, ready to start.
+type jobList struct {
+	ctx  context.Context
+	c    config
+	list []job
+	done func(job, Seed) error
+}
+
+// start applies opts and lists the seeds they ask for.
+func start(ctx context.Context, opts []Option, done func(job, Seed) error) (*jobList, error) {
+	c := config{count: 10, workers: runtime.GOMAXPROCS(0)}
+	for _, o := range opts {
+		o(&c)
+	}
+	if c.count < 0 {
+		return nil, fmt.Errorf("seedgen: negative count %d", c.count)
+	}
+	if c.workers < 1 {
+		return nil, fmt.Errorf("seedgen: %d workers", c.workers)
+	}
+	if c.goVersion != "" && !version.IsValid("go"+c.goVersion) {
+		return nil, fmt.Errorf("seedgen: bad go version %q", c.goVersion)
+	}
+	gens, err := gen.Match(c.patterns...)
+	if err != nil {
+		return nil, err
+	}
+	if n := len(gens); !c.dangerous {
+		gens = slices.DeleteFunc(gens, func(g *gen.Generator) bool { return g.Dangerous })
+		if n > 0 && len(gens) == 0 {
+			return nil, fmt.Errorf("seedgen: %q matches only dangerous generators", c.patterns)
+		}
+	}
+	if len(gens) == 0 {
+		return nil, fmt.Errorf("seedgen: no generators match %q", c.patterns)
+	}
+	if c.weights != nil {
+		if err := c.weights.check(); err != nil {
+			return nil, fmt.Errorf("seedgen: profile: %v", err)
+		}
+		if keys := c.weights.unmatched(); len(keys) > 0 {
+			return nil, fmt.Errorf("seedgen: profile weighs %q, which match no generators", keys)
+		}
+	}
+	if !c.seeded {
+		c.seed = rand.Uint64()
+	}
+	l := &jobList{ctx: ctx, c: c, done: done}
+	for _, g := range gens {
+		n := c.count
+		if c.weights != nil {
+			n = c.weights.Count(g, n)
+		}
+		for i := range n {
+			l.list = append(l.list, job{g, i, len(l.list)})
+		}
+	}
+	if len(l.list) == 0 && c.count > 0 {
+		return nil, fmt.Errorf("seedgen: profile gives every selected generator weight 0")
+	}
+	return l, nil
+}
+
+// run generates the listed seeds on c.workers goroutines and hands each
+// to done. The first error stops the other workers.
+func (l *jobList) run() error {
+	ctx, cancel := context.WithCancelCause(l.ctx)
+	defer cancel(nil)
+	jobs := make(chan job)
+	var wg sync.WaitGroup
+	for range min(l.c.workers, len(l.list)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				s := l.generate(j)
+				if err := validate.Check(ctx, s.Files, l.c.level); err != nil {
+					var verr *validate.Error
+					if !errors.As(err, &verr) {
+						cancel(err)
+					} else if l.c.reject != nil {
+						l.c.reject(s, err)
+					}
+					continue
+				}
+				if err := l.done(j, s); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+	for _, j := range l.list {
+		if ctx.Err() != nil {
+			break
+		}
+		select {
+		case jobs <- j:
+		case <-ctx.Done():
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if ctx.Err() != nil {
+		return context.Cause(ctx)
+	}
+	return nil
+}
+
+// generate writes the seed of j.
+func (l *jobList) generate(j job) Seed {
+	s := gen.NewStateSeed(l.c.seed + uint64(j.i))
+	s.Limits = l.c.limits
+	seed := Seed{Generator: j.g.Name, Index: j.i, Files: j.g.Func(s), StateSeed: s.Seed()}
+	if l.c.goVersion != "" {
+		addGoMod(&seed, l.c.goVersion)
+	}
+	return seed
+}
+
+// addGoMod gives s a go.mod at version v if it has Go files and no
+// go.mod.
+func addGoMod(s *Seed, v string) {
+	hasGo := false
+	for _, f := range s.Files {
+		if f.Name == "go.mod" {
+			return
+		}
+		hasGo = hasGo || path.Ext(f.Name) == ".go"
+	}
+	if hasGo {
+		s.Files = append(s.Files, gen.File{Name: "go.mod", Data: []byte("module seed\n\ngo " + v + "\n")})
+	}
+}
@@ -0,0 +1,72 @@
+package seedgen
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+func TestDeterministic(t *testing.T) {
+	ctx := context.Background()
+	run := func(workers int) []Seed {
+		seeds, err := Generate(ctx, WithGenerators("go/*", "json/*"), WithCount(3), WithSeed(42), WithWorkers(workers))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return seeds
+	}
+	want := run(1)
+	for _, workers := range []int{1, 4, 16} {
+		if got := run(workers); !reflect.DeepEqual(got, want) {
+			t.Errorf("WithSeed(42), WithWorkers(%d): seeds differ from those of a run with one worker", workers)
+		}
+	}
+}
+
+func TestOriginRegenerates(t *testing.T) {
+	ctx := context.Background()
+	seeds, err := Generate(ctx, WithGenerators("go/*"), WithCount(2), WithSeed(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checked := 0
+	for _, s := range seeds {
+		for _, f := range s.Files {
+			if path.Ext(f.Name) != ".go" {
+				continue
+			}
+			name, seed, ok := gen.Origin(f.Data)
+			if !ok {
+				continue
+			}
+			if name != s.Generator || seed != s.StateSeed {
+				t.Errorf("%s: header names %s, seed %d; want %s, seed %d", s.Path(), name, seed, s.Generator, s.StateSeed)
+				continue
+			}
+			again, err := Generate(ctx, WithGenerators(name), WithCount(1), WithSeed(seed))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !hasFile(again[0], f) {
+				t.Errorf("%s: seed %d of %s does not write %s again", s.Path(), seed, name, f.Name)
+			}
+			checked++
+		}
+	}
+	if checked == 0 {
+		t.Fatal("no generated Go file has a seedgen header")
+	}
+}
+
+func hasFile(s Seed, want gen.File) bool {
+	for _, f := range s.Files {
+		if f.Name == want.Name && bytes.Equal(f.Data, want.Data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,424 @@
+// Package spec reports which Go language constructs a source file uses.
+//
+// Features are named after the spec productions and type-system rules
+// they stand for. Syntactic features are read off the AST; the rest come
+// from type-checking the file, which is done even when the file has type
+// errors so that the deliberately invalid parts of a seed still count for
+// whatever checked cleanly.
+package spec
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// A Feature is one construct that can be reported.
+type Feature struct {
+	Name string
+	Doc  string
+}
+
+// Features lists every feature in report order.
+var Features = []Feature{
+	{"import/dot", "dot import"},
+	{"import/blank", "blank import"},
+	{"import/named", "renamed import"},
+	{"import/cgo", `import "C"`},
+	{"decl/const-iota", "constant declaration using iota"},
+	{"decl/type-alias", "alias declaration"},
+	{"decl/generic-alias", "alias declaration with type parameters"},
+	{"decl/generic-type", "generic type declaration"},
+	{"decl/generic-func", "generic function"},
+	{"decl/method", "method declaration"},
+	{"decl/generic-method", "method on a generic receiver"},
+	{"decl/local-type", "type declared inside a function"},
+	{"type/struct-embed", "embedded struct field"},
+	{"type/struct-tag", "struct field tag"},
+	{"type/iface-embed", "embedded interface element"},
+	{"type/union", "union term in an interface"},
+	{"type/tilde", "~T term"},
+	{"type/chan-dir", "directional channel type"},
+	{"type/array-ellipsis", "[...]T composite literal"},
+	{"func/variadic", "variadic parameter"},
+	{"func/named-results", "named results"},
+	{"func/literal", "function literal"},
+	{"stmt/if-init", "if with init statement"},
+	{"stmt/switch", "expression switch"},
+	{"stmt/type-switch", "type switch"},
+	{"stmt/fallthrough", "fallthrough"},
+	{"stmt/select", "select"},
+	{"stmt/for-clause", "three-clause for"},
+	{"stmt/range", "for range"},
+	{"stmt/range-int", "range over an integer"},
+	{"stmt/range-func", "range over a function iterator"},
+	{"stmt/label", "labeled statement"},
+	{"stmt/break-label", "break with label"},
+	{"stmt/continue-label", "continue with label"},
+	{"stmt/goto", "goto"},
+	{"stmt/defer", "defer"},
+	{"stmt/go", "go"},
+	{"stmt/send", "channel send"},
+	{"expr/receive", "channel receive"},
+	{"expr/slice3", "full slice expression"},
+	{"expr/type-assert", "type assertion"},
+	{"expr/composite-elided", "composite literal with elided element types"},
+	{"expr/conversion", "conversion"},
+	{"expr/method-value", "method value"},
+	{"expr/method-expr", "method expression"},
+	{"expr/promoted", "promoted field or method"},
+	{"generic/instantiate", "explicit instantiation"},
+	{"generic/infer", "inferred type arguments"},
+	{"generic/multi-param", "several type parameters"},
+	{"builtin/min-max", "min or max"},
+	{"builtin/clear", "clear"},
+	{"builtin/unsafe", "package unsafe"},
+	{"directive/go", "//go: directive"},
+	{"directive/line", "//line directive"},
+	{"directive/build", "//go:build constraint"},
+	{"check/error", "file has type errors"},
+}
+
+var known = map[string]bool{}
+
+func init() {
+	for _, f := range Features {
+		known[f.Name] = true
+	}
+}
+
+// A Set holds the features present in a file.
+type Set map[string]bool
+
+func (s Set) add(name string) {
+	if !known[name] {
+		panic("spec: unknown feature " + name)
+	}
+	s[name] = true
+}
+
+var imports = importer.Default()
+
+// Scan returns the features used by src. It fails only if src does not
+// parse.
+func Scan(src []byte) (Set, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "seed.go", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	s := Set{}
+	syntax(s, f)
+	typed(s, fset, f)
+	return s, nil
+}
+
+func syntax(s Set, f *ast.File) {
+	for _, imp := range f.Imports {
+		switch {
+		case imp.Path.Value == `"C"`:
+			s.add("import/cgo")
+		case imp.Name == nil:
+		case imp.Name.Name == ".":
+			s.add("import/dot")
+		case imp.Name.Name == "_":
+			s.add("import/blank")
+		default:
+			s.add("import/named")
+		}
+	}
+	for _, cg := range f.Comments {
+		for _, c := range cg.List {
+			switch {
+			case strings.HasPrefix(c.Text, "//go:build"):
+				s.add("directive/build")
+			case strings.HasPrefix(c.Text, "//go:"):
+				s.add("directive/go")
+			case strings.HasPrefix(c.Text, "//line ") || strings.HasPrefix(c.Text, "/*line "):
+				s.add("directive/line")
+			}
+		}
+	}
+
+	var funcs int // depth of enclosing function bodies
+	var stack []ast.Node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			if _, ok := stack[len(stack)-1].(*ast.BlockStmt); ok && isFuncBody(stack) {
+				funcs--
+			}
+			stack = stack[:len(stack)-1]
+			return true
+		}
+		stack = append(stack, n)
+		if _, ok := n.(*ast.BlockStmt); ok && isFuncBody(stack) {
+			funcs++
+		}
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			if n.Tok == token.CONST && usesIota(n) {
+				s.add("decl/const-iota")
+			}
+		case *ast.TypeSpec:
+			if funcs > 0 {
+				s.add("decl/local-type")
+			}
+			switch {
+			case n.Assign.IsValid() && n.TypeParams != nil:
+				s.add("decl/generic-alias")
+			case n.Assign.IsValid():
+				s.add("decl/type-alias")
+			case n.TypeParams != nil:
+				s.add("decl/generic-type")
+			}
+			if n.TypeParams != nil && n.TypeParams.NumFields() > 1 {
+				s.add("generic/multi-param")
+			}
+		case *ast.FuncDecl:
+			if n.Recv != nil {
+				s.add("decl/method")
+				if len(n.Recv.List) > 0 && isGenericRecv(n.Recv.List[0].Type) {
+					s.add("decl/generic-method")
+				}
+			}
+			if n.Type.TypeParams != nil {
+				s.add("decl/generic-func")
+				if n.Type.TypeParams.NumFields() > 1 {
+					s.add("generic/multi-param")
+				}
+			}
+		case *ast.FuncType:
+			if n.Params != nil {
+				for _, p := range n.Params.List {
+					if _, ok := p.Type.(*ast.Ellipsis); ok {
+						s.add("func/variadic")
+					}
+				}
+			}
+			if n.Results != nil && len(n.Results.List) > 0 && len(n.Results.List[0].Names) > 0 {
+				s.add("func/named-results")
+			}
+		case *ast.FuncLit:
+			s.add("func/literal")
+		case *ast.StructType:
+			for _, fl := range n.Fields.List {
+				if len(fl.Names) == 0 {
+					s.add("type/struct-embed")
+				}
+				if fl.Tag != nil {
+					s.add("type/struct-tag")
+				}
+			}
+		case *ast.InterfaceType:
+			for _, fl := range n.Methods.List {
+				if len(fl.Names) > 0 {
+					continue
+				}
+				s.add("type/iface-embed")
+				if b, ok := fl.Type.(*ast.BinaryExpr); ok && b.Op == token.OR {
+					s.add("type/union")
+				}
+			}
+		case *ast.UnaryExpr:
+			switch n.Op {
+			case token.TILDE:
+				s.add("type/tilde")
+			case token.ARROW:
+				s.add("expr/receive")
+			}
+		case *ast.ChanType:
+			if n.Dir != ast.SEND|ast.RECV {
+				s.add("type/chan-dir")
+			}
+		case *ast.CompositeLit:
+			if a, ok := n.Type.(*ast.ArrayType); ok {
+				if _, ok := a.Len.(*ast.Ellipsis); ok {
+					s.add("type/array-ellipsis")
+				}
+			}
+			for _, e := range n.Elts {
+				if kv, ok := e.(*ast.KeyValueExpr); ok {
+					e = kv.Value
+				}
+				if c, ok := e.(*ast.CompositeLit); ok && c.Type == nil {
+					s.add("expr/composite-elided")
+				}
+			}
+		case *ast.IfStmt:
+			if n.Init != nil {
+				s.add("stmt/if-init")
+			}
+		case *ast.SwitchStmt:
+			s.add("stmt/switch")
+		case *ast.TypeSwitchStmt:
+			s.add("stmt/type-switch")
+		case *ast.SelectStmt:
+			s.add("stmt/select")
+		case *ast.ForStmt:
+			if n.Init != nil || n.Post != nil {
+				s.add("stmt/for-clause")
+			}
+		case *ast.RangeStmt:
+			s.add("stmt/range")
+		case *ast.LabeledStmt:
+			s.add("stmt/label")
+		case *ast.BranchStmt:
+			switch {
+			case n.Tok == token.FALLTHROUGH:
+				s.add("stmt/fallthrough")
+			case n.Tok == token.GOTO:
+				s.add("stmt/goto")
+			case n.Label == nil:
+			case n.Tok == token.BREAK:
+				s.add("stmt/break-label")
+			case n.Tok == token.CONTINUE:
+				s.add("stmt/continue-label")
+			}
+		case *ast.DeferStmt:
+			s.add("stmt/defer")
+		case *ast.GoStmt:
+			s.add("stmt/go")
+		case *ast.SendStmt:
+			s.add("stmt/send")
+		case *ast.SliceExpr:
+			if n.Slice3 {
+				s.add("expr/slice3")
+			}
+		case *ast.TypeAssertExpr:
+			if n.Type != nil {
+				s.add("expr/type-assert")
+			}
+		}
+		return true
+	})
+}
+
+// isFuncBody reports whether the top of stack is the body of a function.
+func isFuncBody(stack []ast.Node) bool {
+	if len(stack) < 2 {
+		return false
+	}
+	switch stack[len(stack)-2].(type) {
+	case *ast.FuncDecl, *ast.FuncLit:
+		return true
+	}
+	return false
+}
+
+func usesIota(d *ast.GenDecl) bool {
+	found := false
+	ast.Inspect(d, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok && id.Name == "iota" {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+func isGenericRecv(e ast.Expr) bool {
+	if star, ok := e.(*ast.StarExpr); ok {
+		e = star.X
+	}
+	switch e.(type) {
+	case *ast.IndexExpr, *ast.IndexListExpr:
+		return true
+	}
+	return false
+}
+
+func typed(s Set, fset *token.FileSet, f *ast.File) {
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Uses:       map[*ast.Ident]types.Object{},
+		Instances:  map[*ast.Ident]types.Instance{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+	conf := types.Config{
+		Importer:    imports,
+		FakeImportC: true,
+		Error:       func(error) { s.add("check/error") },
+	}
+	conf.Check("p", fset, []*ast.File{f}, info)
+
+	// indexed holds the identifiers given explicit type arguments and
+	// called the selectors used as callees, which are not method values.
+	indexed := map[*ast.Ident]bool{}
+	called := map[*ast.SelectorExpr]bool{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.IndexExpr:
+			indexed[identOf(n.X)] = true
+		case *ast.IndexListExpr:
+			indexed[identOf(n.X)] = true
+		case *ast.CallExpr:
+			if tv, ok := info.Types[n.Fun]; ok && tv.IsType() {
+				s.add("expr/conversion")
+			}
+			if sel, ok := ast.Unparen(n.Fun).(*ast.SelectorExpr); ok {
+				called[sel] = true
+			}
+		case *ast.RangeStmt:
+			if tv, ok := info.Types[n.X]; ok && tv.Type != nil {
+				switch u := tv.Type.Underlying().(type) {
+				case *types.Basic:
+					if u.Info()&types.IsInteger != 0 {
+						s.add("stmt/range-int")
+					}
+				case *types.Signature:
+					s.add("stmt/range-func")
+				}
+			}
+		}
+		return true
+	})
+
+	for id, obj := range info.Uses {
+		switch obj := obj.(type) {
+		case *types.Builtin:
+			switch obj.Name() {
+			case "min", "max":
+				s.add("builtin/min-max")
+			case "clear":
+				s.add("builtin/clear")
+			}
+		case *types.PkgName:
+			if obj.Imported().Path() == "unsafe" {
+				s.add("builtin/unsafe")
+			}
+		}
+		if _, ok := info.Instances[id]; ok {
+			if indexed[id] {
+				s.add("generic/instantiate")
+			} else {
+				s.add("generic/infer")
+			}
+		}
+	}
+	for e, sel := range info.Selections {
+		switch {
+		case sel.Kind() == types.MethodExpr:
+			s.add("expr/method-expr")
+		case sel.Kind() == types.MethodVal && !called[e]:
+			s.add("expr/method-value")
+		}
+		if len(sel.Index()) > 1 {
+			s.add("expr/promoted")
+		}
+	}
+}
+
+// identOf returns the identifier naming the (possibly qualified) operand
+// x, or nil.
+func identOf(x ast.Expr) *ast.Ident {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x
+	case *ast.SelectorExpr:
+		return x.Sel
+	}
+	return nil
+}
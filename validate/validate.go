@@ -0,0 +1,374 @@
+// Package validate checks seeds before they are admitted to a corpus, so
+// that seeds broken by a generator bug or a careless edit do not waste a
+// fuzzer's executions.
+//
+// Check takes a seed as far as a Level asks: Parse parses every Go,
+// go.mod, go.work and go.sum file; Types also type-checks each package of
+// the seed, resolving imports between its own packages by its go.mod
+// module path; Vet also runs go vet on it. Many generators deliberately
+// write some seeds that must fail to type-check, so the level to ask for
+// depends on the target the corpus is for.
+package validate
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/mod/modfile"
+
+	"github.com/geeknik/fuzzing/gen"
+)
+
+// A Level is how far Check takes a seed.
+type Level int
+
+const (
+	None  Level = iota // no checks
+	Parse              // every file parses
+	Types              // and every package type-checks
+	Vet                // and go vet finds nothing
+)
+
+var levels = []string{"none", "parse", "types", "vet"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levels) {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levels[l]
+}
+
+// ParseLevel returns the level of the given name: "none", "parse",
+// "types" or "vet".
+func ParseLevel(name string) (Level, error) {
+	if i := slices.Index(levels, name); i >= 0 {
+		return Level(i), nil
+	}
+	return None, fmt.Errorf("validate: unknown level %q (want %s)", name, strings.Join(levels, ", "))
+}
+
+// An Error says why Check rejected a seed: the level it failed at and the
+// first problem found there.
+type Error struct {
+	Level Level
+	Msg   string // "file:line:col: message" where there is a position
+}
+
+func (e *Error) Error() string {
+	return e.Level.String() + ": " + e.Msg
+}
+
+// GoVersion is the language version of seeds without a go.mod of their
+// own, for go vet.
+var GoVersion = "1.24"
+
+// VetTimeout bounds one go vet run.
+var VetTimeout = time.Minute
+
+// Check checks the files of a seed up to level and returns an *Error if
+// they fail. Other errors are of running go vet.
+func Check(ctx context.Context, files []gen.File, level Level) error {
+	if level >= Parse {
+		if err := parseAll(files); err != nil {
+			return err
+		}
+	}
+	if level >= Types {
+		if err := typeCheck(files); err != nil {
+			return err
+		}
+	}
+	if level >= Vet {
+		return vet(ctx, files)
+	}
+	return nil
+}
+
+func parseAll(files []gen.File) error {
+	fail := func(err error) error { return &Error{Level: Parse, Msg: err.Error()} }
+	for _, f := range files {
+		var err error
+		// Single-file seeds of a corpus are named <index><ext>, so go.mod
+		// is also 000001.mod.
+		switch path.Ext(f.Name) {
+		case ".go":
+			_, err = parser.ParseFile(token.NewFileSet(), f.Name, f.Data, parser.SkipObjectResolution)
+		case ".mod":
+			_, err = modfile.Parse(f.Name, f.Data, nil)
+		case ".work":
+			_, err = modfile.ParseWork(f.Name, f.Data, nil)
+		case ".sum":
+			err = parseSum(f.Name, f.Data)
+		}
+		if err != nil {
+			return fail(err)
+		}
+	}
+	return nil
+}
+
+// parseSum checks the lines of a go.sum file the way the go command
+// reads them: a module path, a version and a hash.
+func parseSum(name string, data []byte) error {
+	for i, l := range strings.Split(string(data), "\n") {
+		f := strings.Fields(l)
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 3 || !strings.Contains(f[2], ":") {
+			return fmt.Errorf("%s:%d: malformed go.sum line", name, i+1)
+		}
+	}
+	return nil
+}
+
+// std imports the standard library for every Check. The importer is not
+// safe for concurrent use, hence the lock.
+var std = struct {
+	sync.Mutex
+	types.Importer
+}{Importer: importer.Default()}
+
+// A checker type-checks the packages of one seed.
+type checker struct {
+	fset    *token.FileSet
+	ctx     build.Context
+	module  string // module path of the seed's packages
+	version string // go version of the go.mod, "" if none
+	done    map[string]*types.Package
+	active  map[string]bool
+}
+
+// root is where the seed's files seem to be for go/build.
+const root = "/seed"
+
+func typeCheck(files []gen.File) error {
+	c := &checker{
+		fset:   token.NewFileSet(),
+		module: "seed",
+		done:   map[string]*types.Package{},
+		active: map[string]bool{},
+	}
+	byName := map[string][]byte{}
+	var dirs []string
+	for _, f := range files {
+		byName[path.Join(root, f.Name)] = f.Data
+		if f.Name == "go.mod" {
+			if mf, err := modfile.ParseLax(f.Name, f.Data, nil); err == nil {
+				if mf.Module != nil {
+					c.module = mf.Module.Mod.Path
+				}
+				if mf.Go != nil {
+					c.version = "go" + mf.Go.Version
+				}
+			}
+		}
+		if d := path.Dir(f.Name); path.Ext(f.Name) == ".go" && !slices.Contains(dirs, d) {
+			dirs = append(dirs, d)
+		}
+	}
+	c.ctx = memContext(byName)
+	slices.Sort(dirs)
+	for _, d := range dirs {
+		if _, err := c.check(path.Join(c.module, d)); err != nil {
+			return &Error{Level: Types, Msg: err.Error()}
+		}
+	}
+	return nil
+}
+
+// memContext returns a build context that reads the files of a seed from
+// memory.
+func memContext(files map[string][]byte) build.Context {
+	ctx := build.Default
+	ctx.CgoEnabled = true
+	ctx.GOPATH = ""
+	ctx.JoinPath = path.Join
+	ctx.IsAbsPath = path.IsAbs
+	ctx.IsDir = func(p string) bool {
+		p += "/"
+		for name := range files {
+			if strings.HasPrefix(name, p) {
+				return true
+			}
+		}
+		return false
+	}
+	ctx.HasSubdir = func(root, dir string) (string, bool) {
+		rel, ok := strings.CutPrefix(dir, root+"/")
+		return rel, ok
+	}
+	ctx.ReadDir = func(dir string) ([]fs.FileInfo, error) {
+		var fis []fs.FileInfo
+		for name := range files {
+			if path.Dir(name) == dir {
+				fis = append(fis, fileInfo{name: path.Base(name), size: int64(len(files[name]))})
+			}
+		}
+		return fis, nil
+	}
+	ctx.OpenFile = func(name string) (io.ReadCloser, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	return ctx
+}
+
+// check type-checks the package of the seed with the given import path.
+func (c *checker) check(importPath string) (*types.Package, error) {
+	if p, ok := c.done[importPath]; ok {
+		return p, nil
+	}
+	if c.active[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+	c.active[importPath] = true
+	defer delete(c.active, importPath)
+
+	rel := strings.TrimPrefix(strings.TrimPrefix(importPath, c.module), "/")
+	bp, err := c.ctx.ImportDir(path.Join(root, rel), 0)
+	if _, ok := err.(*build.NoGoError); ok {
+		// Every file is excluded by build constraints.
+		c.done[importPath] = nil
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range append(bp.GoFiles, bp.CgoFiles...) {
+		rc, err := c.ctx.OpenFile(path.Join(bp.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+		src, _ := io.ReadAll(rc)
+		f, err := parser.ParseFile(c.fset, path.Join(rel, name), src, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	var first error
+	conf := types.Config{
+		Importer:    importerFunc(c.importPkg),
+		FakeImportC: true,
+		GoVersion:   c.version,
+		Error: func(err error) {
+			if first == nil {
+				first = err
+			}
+		},
+	}
+	p, _ := conf.Check(importPath, c.fset, files, nil)
+	if first != nil {
+		return nil, first
+	}
+	c.done[importPath] = p
+	return p, nil
+}
+
+// importPkg imports a package of the seed or of the standard library.
+func (c *checker) importPkg(importPath string) (*types.Package, error) {
+	if importPath == c.module || strings.HasPrefix(importPath, c.module+"/") {
+		p, err := c.check(importPath)
+		if err == nil && p == nil {
+			err = fmt.Errorf("no Go files in %s", importPath)
+		}
+		return p, err
+	}
+	std.Lock()
+	defer std.Unlock()
+	return std.Import(importPath)
+}
+
+type importerFunc func(string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+type fileInfo struct {
+	name string
+	size int64
+}
+
+func (fi fileInfo) Name() string       { return fi.name }
+func (fi fileInfo) Size() int64        { return fi.size }
+func (fi fileInfo) Mode() fs.FileMode  { return 0o644 }
+func (fi fileInfo) ModTime() time.Time { return time.Time{} }
+func (fi fileInfo) IsDir() bool        { return false }
+func (fi fileInfo) Sys() any           { return nil }
+
+// vet writes the seed to a temporary module and runs go vet on it, if it
+// has Go files.
+func vet(ctx context.Context, files []gen.File) error {
+	if !slices.ContainsFunc(files, func(f gen.File) bool { return path.Ext(f.Name) == ".go" }) {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "validate")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	hasMod := false
+	for _, f := range files {
+		hasMod = hasMod || f.Name == "go.mod"
+		name := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(name, f.Data, 0o644); err != nil {
+			return err
+		}
+	}
+	if !hasMod {
+		mod := []byte("module seed\n\ngo " + GoVersion + "\n")
+		if err := os.WriteFile(filepath.Join(dir, "go.mod"), mod, 0o644); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, VetTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "go", "vet", "./...")
+	cmd.Dir = dir
+	// A go line past the installed toolchain is a reject, not a download.
+	cmd.Env = append(os.Environ(), "GOTOOLCHAIN=local")
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		return &Error{Level: Vet, Msg: fmt.Sprintf("go vet did not finish in %v", VetTimeout)}
+	}
+	if err != nil {
+		return &Error{Level: Vet, Msg: firstProblem(string(out), dir)}
+	}
+	return nil
+}
+
+// firstProblem returns the first line of go vet output that is not a
+// package header, with the temporary directory removed.
+func firstProblem(out, dir string) string {
+	for _, l := range strings.Split(out, "\n") {
+		l = strings.TrimSpace(strings.ReplaceAll(l, dir+string(filepath.Separator), ""))
+		if l != "" && !strings.HasPrefix(l, "#") {
+			return strings.TrimPrefix(l, "vet: ")
+		}
+	}
+	return strings.TrimSpace(out)
+}